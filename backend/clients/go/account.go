@@ -0,0 +1,134 @@
+package chatcommerce
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// UpdateProfileRequest changes the user's profile; empty fields are kept
+type UpdateProfileRequest struct {
+	FirstName   string                 `json:"first_name,omitempty"`
+	LastName    string                 `json:"last_name,omitempty"`
+	Phone       string                 `json:"phone,omitempty"`
+	Preferences map[string]interface{} `json:"preferences,omitempty"`
+}
+
+// OrderViolation is an order rule a checkout breaks, e.g. a quantity limit
+type OrderViolation struct {
+	Code      string     `json:"code"`
+	Message   string     `json:"message"`
+	RuleID    uuid.UUID  `json:"rule_id"`
+	ProductID *uuid.UUID `json:"product_id,omitempty"`
+}
+
+// OrderViolations returns the rules a rejected order broke, or nil when err
+// isn't a rule violation
+func OrderViolations(err error) []OrderViolation {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnprocessableEntity {
+		return nil
+	}
+	var body struct {
+		Violations []OrderViolation `json:"violations"`
+	}
+	_ = json.Unmarshal(apiErr.Body, &body)
+	return body.Violations
+}
+
+// GetProfile returns the signed in user
+func (c *Client) GetProfile(ctx context.Context) (*User, error) {
+	return c.user(ctx, http.MethodGet, "/api/v1/user/profile", nil)
+}
+
+// UpdateProfile changes the signed in user's profile
+func (c *Client) UpdateProfile(ctx context.Context, req UpdateProfileRequest) (*User, error) {
+	return c.user(ctx, http.MethodPut, "/api/v1/user/profile", req)
+}
+
+// ChangePassword changes the signed in user's password
+func (c *Client) ChangePassword(ctx context.Context, currentPassword, newPassword string) error {
+	body := map[string]string{"current_password": currentPassword, "new_password": newPassword}
+	return c.Do(ctx, http.MethodPost, "/api/v1/user/change-password", nil, body, nil)
+}
+
+// VerifyEmail marks the signed in user's email as verified
+func (c *Client) VerifyEmail(ctx context.Context) error {
+	return c.Do(ctx, http.MethodPost, "/api/v1/user/verify-email", nil, nil, nil)
+}
+
+// DeleteAccount deletes the signed in user's account and signs out
+func (c *Client) DeleteAccount(ctx context.Context) error {
+	if err := c.Do(ctx, http.MethodDelete, "/api/v1/user/account", nil, nil, nil); err != nil {
+		return err
+	}
+	c.setTokens(Tokens{})
+	return nil
+}
+
+// CreateOrder places an order. Orders breaking the store's rules fail with
+// the broken rules in OrderViolations(err).
+func (c *Client) CreateOrder(ctx context.Context, req OrderRequest) (*Order, error) {
+	return c.order(ctx, http.MethodPost, "/api/v1/orders/", req)
+}
+
+// ValidateOrder checks an order against the store's rules without placing
+// it and returns the broken rules, none when it may be placed
+func (c *Client) ValidateOrder(ctx context.Context, req OrderRequest) ([]OrderViolation, error) {
+	err := c.Do(ctx, http.MethodPost, "/api/v1/orders/validate", nil, req, nil)
+	if violations := OrderViolations(err); violations != nil {
+		return violations, nil
+	}
+	return nil, err
+}
+
+// GetOrder returns one of the user's orders
+func (c *Client) GetOrder(ctx context.Context, id uuid.UUID) (*Order, error) {
+	return c.order(ctx, http.MethodGet, pathf("/api/v1/orders/%s", id), nil)
+}
+
+// GetOrderByNumber returns one of the user's orders by its order number
+func (c *Client) GetOrderByNumber(ctx context.Context, number string) (*Order, error) {
+	return c.order(ctx, http.MethodGet, pathf("/api/v1/orders/number/%s", number), nil)
+}
+
+// ListOrders lists the user's orders. They can be filtered by status,
+// payment_status, order_number, total_amount and created_at and sorted by
+// created_at, total_amount and status.
+func (c *Client) ListOrders(ctx context.Context, params *ListParams) (*OrderList, error) {
+	var list OrderList
+	if err := c.Do(ctx, http.MethodGet, "/api/v1/orders/", params.Values(), nil, &list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// CancelOrder cancels one of the user's orders
+func (c *Client) CancelOrder(ctx context.Context, id uuid.UUID) (*Order, error) {
+	return c.order(ctx, http.MethodDelete, pathf("/api/v1/orders/%s", id), nil)
+}
+
+// user calls an endpoint answering with {"user": ...}
+func (c *Client) user(ctx context.Context, method, path string, body interface{}) (*User, error) {
+	var resp struct {
+		User User `json:"user"`
+	}
+	if err := c.Do(ctx, method, path, nil, body, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.User, nil
+}
+
+// order calls an endpoint answering with {"order": ...}
+func (c *Client) order(ctx context.Context, method, path string, body interface{}) (*Order, error) {
+	var resp struct {
+		Order Order `json:"order"`
+	}
+	if err := c.Do(ctx, method, path, nil, body, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.Order, nil
+}
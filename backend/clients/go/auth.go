@@ -0,0 +1,103 @@
+package chatcommerce
+
+import (
+	"context"
+	"net/http"
+)
+
+// RegisterRequest creates an account
+type RegisterRequest struct {
+	Email     string `json:"email"`
+	Password  string `json:"password"` // at least 8 characters
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Phone     string `json:"phone,omitempty"`
+}
+
+// signInResponse is the body of register, login and refresh
+type signInResponse struct {
+	User *User `json:"user"`
+	Tokens
+}
+
+// Register creates an account and signs the client in as it
+func (c *Client) Register(ctx context.Context, req RegisterRequest) (*User, error) {
+	var resp signInResponse
+	if err := c.Do(ctx, http.MethodPost, "/api/v1/auth/register", nil, req, &resp); err != nil {
+		return nil, err
+	}
+	c.setTokens(resp.Tokens)
+	return resp.User, nil
+}
+
+// Login signs the client in
+func (c *Client) Login(ctx context.Context, email, password string) (*User, error) {
+	var resp signInResponse
+	body := map[string]string{"email": email, "password": password}
+	if err := c.Do(ctx, http.MethodPost, "/api/v1/auth/login", nil, body, &resp); err != nil {
+		return nil, err
+	}
+	c.setTokens(resp.Tokens)
+	return resp.User, nil
+}
+
+// Refresh renews the access token. Refresh tokens are single use, so the
+// client keeps the new one; requests refresh on their own when the access
+// token has expired.
+func (c *Client) Refresh(ctx context.Context) (Tokens, error) {
+	var resp signInResponse
+	body := map[string]string{"refresh_token": c.Tokens().RefreshToken}
+	if err := c.Do(ctx, http.MethodPost, "/api/v1/auth/refresh", nil, body, &resp); err != nil {
+		if IsUnauthorized(err) {
+			c.setTokens(Tokens{})
+		}
+		return Tokens{}, err
+	}
+	c.setTokens(resp.Tokens)
+	return resp.Tokens, nil
+}
+
+// Logout ends the client's sign-in
+func (c *Client) Logout(ctx context.Context) error {
+	body := map[string]string{"refresh_token": c.Tokens().RefreshToken}
+	if err := c.Do(ctx, http.MethodPost, "/api/v1/auth/logout", nil, body, nil); err != nil {
+		return err
+	}
+	c.setTokens(Tokens{})
+	return nil
+}
+
+// LogoutEverywhere ends every sign-in of the user, this one included, and
+// returns how many were ended
+func (c *Client) LogoutEverywhere(ctx context.Context) (int, error) {
+	var resp struct {
+		SessionsRevoked int `json:"sessions_revoked"`
+	}
+	if err := c.Do(ctx, http.MethodPost, "/api/v1/user/logout-all", nil, nil, &resp); err != nil {
+		return 0, err
+	}
+	c.setTokens(Tokens{})
+	return resp.SessionsRevoked, nil
+}
+
+// ResetPassword sets a new password with the token of a reset link
+func (c *Client) ResetPassword(ctx context.Context, token, newPassword string) error {
+	body := map[string]string{"token": token, "new_password": newPassword}
+	return c.Do(ctx, http.MethodPost, "/api/v1/auth/reset-password", nil, body, nil)
+}
+
+// UnlockAccount unlocks an account locked after failed sign-ins, with the
+// token of the unlock link
+func (c *Client) UnlockAccount(ctx context.Context, token string) error {
+	return c.Do(ctx, http.MethodPost, "/api/v1/auth/unlock-account", nil, map[string]string{"token": token}, nil)
+}
+
+func (c *Client) setTokens(tokens Tokens) {
+	c.mu.Lock()
+	c.tokens = tokens
+	onTokens := c.onTokens
+	c.mu.Unlock()
+	if onTokens != nil {
+		onTokens(tokens)
+	}
+}
@@ -0,0 +1,103 @@
+package chatcommerce
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/google/uuid"
+)
+
+// Cart endpoints act on the client's session, see WithSessionID, and on the
+// signed in user's cart once signed in.
+
+// GetCart returns the cart
+func (c *Client) GetCart(ctx context.Context) (*Cart, error) {
+	var cart Cart
+	if err := c.Do(ctx, http.MethodGet, "/api/v1/cart/", nil, nil, &cart); err != nil {
+		return nil, err
+	}
+	return &cart, nil
+}
+
+// AddToCart adds quantity of a product, or of one of its variants, to the cart
+func (c *Client) AddToCart(ctx context.Context, productID uuid.UUID, variantID *uuid.UUID, quantity int) error {
+	body := OrderItem{ProductID: productID, VariantID: variantID, Quantity: quantity}
+	return c.Do(ctx, http.MethodPost, "/api/v1/cart/add", nil, body, nil)
+}
+
+// UpdateCartItem sets the quantity of a cart item, removing it at 0
+func (c *Client) UpdateCartItem(ctx context.Context, productID uuid.UUID, variantID *uuid.UUID, quantity int) error {
+	body := OrderItem{ProductID: productID, VariantID: variantID, Quantity: quantity}
+	return c.Do(ctx, http.MethodPut, "/api/v1/cart/update", nil, body, nil)
+}
+
+// RemoveFromCart removes a product, or one of its variants, from the cart
+func (c *Client) RemoveFromCart(ctx context.Context, productID uuid.UUID, variantID *uuid.UUID) error {
+	params := url.Values{}
+	if variantID != nil {
+		params.Set("variant_id", variantID.String())
+	}
+	return c.Do(ctx, http.MethodDelete, pathf("/api/v1/cart/remove/%s", productID), params, nil, nil)
+}
+
+// ClearCart empties the cart
+func (c *Client) ClearCart(ctx context.Context) error {
+	return c.Do(ctx, http.MethodDelete, "/api/v1/cart/clear", nil, nil, nil)
+}
+
+// CalculateCart returns the cart with tax and shipping worked out
+func (c *Client) CalculateCart(ctx context.Context) (*Cart, error) {
+	var cart Cart
+	if err := c.Do(ctx, http.MethodPost, "/api/v1/cart/calculate", nil, nil, &cart); err != nil {
+		return nil, err
+	}
+	return &cart, nil
+}
+
+// CartItemCount returns how many items are in the cart
+func (c *Client) CartItemCount(ctx context.Context) (int, error) {
+	var resp struct {
+		ItemCount int `json:"item_count"`
+	}
+	if err := c.Do(ctx, http.MethodGet, "/api/v1/cart/count", nil, nil, &resp); err != nil {
+		return 0, err
+	}
+	return resp.ItemCount, nil
+}
+
+// SetGiftOptions sets the cart's gift wrap and message
+func (c *Client) SetGiftOptions(ctx context.Context, options GiftOptions) (*Cart, error) {
+	var cart Cart
+	if err := c.Do(ctx, http.MethodPut, "/api/v1/cart/gift-options", nil, options, &cart); err != nil {
+		return nil, err
+	}
+	return &cart, nil
+}
+
+// ShareCart creates a link others can copy the cart from
+func (c *Client) ShareCart(ctx context.Context) (*CartShare, error) {
+	var share CartShare
+	if err := c.Do(ctx, http.MethodPost, "/api/v1/cart/share", nil, nil, &share); err != nil {
+		return nil, err
+	}
+	return &share, nil
+}
+
+// GetSharedCart returns the cart a share link points to
+func (c *Client) GetSharedCart(ctx context.Context, token string) (*Cart, error) {
+	var cart Cart
+	if err := c.Do(ctx, http.MethodGet, pathf("/api/v1/cart/shared/%s", token), nil, nil, &cart); err != nil {
+		return nil, err
+	}
+	return &cart, nil
+}
+
+// CloneSharedCart copies a shared cart's items into the cart
+func (c *Client) CloneSharedCart(ctx context.Context, token string) (*CartClone, error) {
+	var clone CartClone
+	if err := c.Do(ctx, http.MethodPost, pathf("/api/v1/cart/shared/%s/clone", token), nil, nil, &clone); err != nil {
+		return nil, err
+	}
+	return &clone, nil
+}
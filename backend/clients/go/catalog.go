@@ -0,0 +1,191 @@
+package chatcommerce
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// ListProducts lists published products. They can be filtered by search,
+// name, sku, category_id, brand_id, price, status, popularity, created_at
+// and updated_at and sorted by created_at, updated_at, name, price,
+// popularity and sku.
+func (c *Client) ListProducts(ctx context.Context, params *ListParams) (*ProductList, error) {
+	var list ProductList
+	if err := c.Do(ctx, http.MethodGet, "/api/v1/products/", params.Values(), nil, &list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// GetProduct returns a product by ID
+func (c *Client) GetProduct(ctx context.Context, id uuid.UUID) (*Product, error) {
+	var product Product
+	if err := c.Do(ctx, http.MethodGet, pathf("/api/v1/products/%s", id), nil, nil, &product); err != nil {
+		return nil, err
+	}
+	return &product, nil
+}
+
+// GetProductBySKU returns a product by SKU
+func (c *Client) GetProductBySKU(ctx context.Context, sku string) (*Product, error) {
+	var product Product
+	if err := c.Do(ctx, http.MethodGet, pathf("/api/v1/products/sku/%s", sku), nil, nil, &product); err != nil {
+		return nil, err
+	}
+	return &product, nil
+}
+
+// SearchProducts returns up to limit products matching query, narrowed to a
+// brand when brandID is set or the query names one
+func (c *Client) SearchProducts(ctx context.Context, query string, brandID *uuid.UUID, limit int) ([]Product, error) {
+	params := url.Values{"q": {query}}
+	if brandID != nil {
+		params.Set("brand_id", brandID.String())
+	}
+	if limit > 0 {
+		params.Set("limit", strconv.Itoa(limit))
+	}
+	return c.products(ctx, "/api/v1/products/search", params)
+}
+
+// FeaturedProducts returns up to limit featured products
+func (c *Client) FeaturedProducts(ctx context.Context, limit int) ([]Product, error) {
+	return c.products(ctx, "/api/v1/products/featured", limitParam(limit))
+}
+
+// RelatedProducts returns up to limit products related to a product
+func (c *Client) RelatedProducts(ctx context.Context, id uuid.UUID, limit int) ([]Product, error) {
+	return c.products(ctx, pathf("/api/v1/products/%s/related", id), limitParam(limit))
+}
+
+// Autocomplete returns up to limit type-ahead matches of each kind for prefix
+func (c *Client) Autocomplete(ctx context.Context, prefix string, limit int) (*Autocomplete, error) {
+	params := limitParam(limit)
+	params.Set("q", prefix)
+	var result Autocomplete
+	if err := c.Do(ctx, http.MethodGet, "/api/v1/products/autocomplete", params, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Availability returns the stock status of each product
+func (c *Client) Availability(ctx context.Context, ids ...uuid.UUID) ([]ProductAvailability, error) {
+	values := make([]string, len(ids))
+	for i, id := range ids {
+		values[i] = id.String()
+	}
+	var resp struct {
+		Availability []ProductAvailability `json:"availability"`
+	}
+	params := url.Values{"ids": {strings.Join(values, ",")}}
+	if err := c.Do(ctx, http.MethodGet, "/api/v1/products/availability", params, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Availability, nil
+}
+
+// ProductQuestions returns a product's answered questions
+func (c *Client) ProductQuestions(ctx context.Context, productID uuid.UUID) ([]ProductQuestion, error) {
+	var resp struct {
+		Questions []ProductQuestion `json:"questions"`
+	}
+	if err := c.Do(ctx, http.MethodGet, pathf("/api/v1/products/%s/questions", productID), nil, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Questions, nil
+}
+
+// AskQuestion asks a question about a product, shown once it's answered
+func (c *Client) AskQuestion(ctx context.Context, productID uuid.UUID, question, askerName string) (*ProductQuestion, error) {
+	var resp struct {
+		Question ProductQuestion `json:"question"`
+	}
+	body := map[string]string{"question": question, "asker_name": askerName}
+	if err := c.Do(ctx, http.MethodPost, pathf("/api/v1/products/%s/questions", productID), nil, body, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.Question, nil
+}
+
+// ListCategories returns the product categories
+func (c *Client) ListCategories(ctx context.Context) ([]Category, error) {
+	var resp struct {
+		Categories []Category `json:"categories"`
+	}
+	if err := c.Do(ctx, http.MethodGet, "/api/v1/categories/", nil, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Categories, nil
+}
+
+// GetCategory returns a category by ID
+func (c *Client) GetCategory(ctx context.Context, id uuid.UUID) (*Category, error) {
+	var category Category
+	if err := c.Do(ctx, http.MethodGet, pathf("/api/v1/categories/%s", id), nil, nil, &category); err != nil {
+		return nil, err
+	}
+	return &category, nil
+}
+
+// GetCategoryBySlug returns a category by slug, following renamed slugs
+func (c *Client) GetCategoryBySlug(ctx context.Context, slug string) (*Category, error) {
+	var category Category
+	if err := c.Do(ctx, http.MethodGet, pathf("/api/v1/categories/slug/%s", slug), nil, nil, &category); err != nil {
+		return nil, err
+	}
+	return &category, nil
+}
+
+// ListBrands returns the active brands with their product counts
+func (c *Client) ListBrands(ctx context.Context) ([]BrandListing, error) {
+	var resp struct {
+		Brands []BrandListing `json:"brands"`
+	}
+	if err := c.Do(ctx, http.MethodGet, "/api/v1/brands/", nil, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Brands, nil
+}
+
+// GetBrand returns a brand by slug
+func (c *Client) GetBrand(ctx context.Context, slug string) (*Brand, error) {
+	var brand Brand
+	if err := c.Do(ctx, http.MethodGet, pathf("/api/v1/brands/%s", slug), nil, nil, &brand); err != nil {
+		return nil, err
+	}
+	return &brand, nil
+}
+
+// ListBrandProducts lists a brand's products with the parameters of ListProducts
+func (c *Client) ListBrandProducts(ctx context.Context, slug string, params *ListParams) (*ProductList, error) {
+	var list ProductList
+	if err := c.Do(ctx, http.MethodGet, pathf("/api/v1/brands/%s/products", slug), params.Values(), nil, &list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// products calls an endpoint answering with {"products": [...]}
+func (c *Client) products(ctx context.Context, path string, params url.Values) ([]Product, error) {
+	var resp struct {
+		Products []Product `json:"products"`
+	}
+	if err := c.Do(ctx, http.MethodGet, path, params, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Products, nil
+}
+
+func limitParam(limit int) url.Values {
+	params := url.Values{}
+	if limit > 0 {
+		params.Set("limit", strconv.Itoa(limit))
+	}
+	return params
+}
@@ -0,0 +1,178 @@
+package chatcommerce
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// StartChatSession starts a conversation owned by the signed in user or, for
+// guests, by this client, whose cookie jar keeps the proof
+func (c *Client) StartChatSession(ctx context.Context) (*ChatSession, error) {
+	var session ChatSession
+	if err := c.getData(ctx, http.MethodPost, "/api/v1/chat/session", nil, nil, &session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// GetChatSession returns one of the shopper's conversations
+func (c *Client) GetChatSession(ctx context.Context, sessionID string) (*ChatSession, error) {
+	var session ChatSession
+	if err := c.getData(ctx, http.MethodGet, pathf("/api/v1/chat/session/%s", sessionID), nil, nil, &session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// SendChatMessage sends a message to the assistant. An empty or someone
+// else's sessionID starts a new conversation, returned in the reply.
+func (c *Client) SendChatMessage(ctx context.Context, sessionID, message string) (*ChatReply, error) {
+	var reply ChatReply
+	body := map[string]string{"session_id": sessionID, "message": message}
+	if err := c.getData(ctx, http.MethodPost, "/api/v1/chat/message", nil, body, &reply); err != nil {
+		return nil, err
+	}
+	return &reply, nil
+}
+
+// StreamChatMessage sends a message to the assistant like SendChatMessage,
+// calling onDelta with each piece of the reply as it's written. The returned
+// reply is final; its message may differ from the streamed text, e.g. when a
+// share link was added.
+func (c *Client) StreamChatMessage(ctx context.Context, sessionID, message string, onDelta func(delta string)) (*ChatReply, error) {
+	if err := c.waitForRateLimit(ctx); err != nil {
+		return nil, err
+	}
+	query := url.Values{"message": {message}}
+	if sessionID != "" {
+		query.Set("session_id", sessionID)
+	}
+	resp, err := c.send(ctx, http.MethodGet, "/api/v1/chat/stream", query, nil)
+	if err != nil {
+		return nil, fmt.Errorf("chatcommerce: GET /api/v1/chat/stream: %w", err)
+	}
+	defer resp.Body.Close()
+	c.recordRateLimit(resp.Header)
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, newAPIError(resp, body)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	var event, data string
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data += strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " ")
+		case line == "":
+			reply, err := handleStreamEvent(event, data, onDelta)
+			if err != nil || reply != nil {
+				return reply, err
+			}
+			event, data = "", ""
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("chatcommerce: failed to read chat stream: %w", err)
+	}
+	return nil, errors.New("chatcommerce: chat stream ended without a reply")
+}
+
+// handleStreamEvent handles one server-sent event of a chat stream, returning
+// the reply once the final message arrived
+func handleStreamEvent(event, data string, onDelta func(delta string)) (*ChatReply, error) {
+	switch event {
+	case "delta":
+		var delta struct {
+			Delta string `json:"delta"`
+		}
+		if err := json.Unmarshal([]byte(data), &delta); err != nil {
+			return nil, fmt.Errorf("chatcommerce: failed to decode chat delta: %v", err)
+		}
+		if onDelta != nil {
+			onDelta(delta.Delta)
+		}
+	case "message":
+		var reply ChatReply
+		if err := json.Unmarshal([]byte(data), &reply); err != nil {
+			return nil, fmt.Errorf("chatcommerce: failed to decode chat reply: %v", err)
+		}
+		return &reply, nil
+	case "error":
+		var failure struct {
+			Error string `json:"error"`
+		}
+		_ = json.Unmarshal([]byte(data), &failure)
+		return nil, fmt.Errorf("chatcommerce: chat error: %s", failure.Error)
+	}
+	return nil, nil
+}
+
+// ChatHistory returns a conversation's recent messages. Reads are throttled
+// per address; throttled reads are retried after the server's Retry-After.
+func (c *Client) ChatHistory(ctx context.Context, sessionID string) (*ChatHistory, error) {
+	var history ChatHistory
+	if err := c.getData(ctx, http.MethodGet, pathf("/api/v1/chat/history/%s", sessionID), nil, nil, &history); err != nil {
+		return nil, err
+	}
+	return &history, nil
+}
+
+// ChatSuggestions returns products suggested from a conversation so far
+func (c *Client) ChatSuggestions(ctx context.Context, sessionID string) ([]ProductSuggestion, error) {
+	var suggestions []ProductSuggestion
+	params := url.Values{"session_id": {sessionID}}
+	if err := c.getData(ctx, http.MethodGet, "/api/v1/chat/suggestions", params, nil, &suggestions); err != nil {
+		return nil, err
+	}
+	return suggestions, nil
+}
+
+// ChatSearch finds up to limit products for a natural language query
+func (c *Client) ChatSearch(ctx context.Context, query string, limit int) ([]ProductSuggestion, error) {
+	var suggestions []ProductSuggestion
+	params := url.Values{"q": {query}}
+	if limit > 0 {
+		params.Set("limit", strconv.Itoa(limit))
+	}
+	if err := c.getData(ctx, http.MethodGet, "/api/v1/chat/search", params, nil, &suggestions); err != nil {
+		return nil, err
+	}
+	return suggestions, nil
+}
+
+// ListChatSessions returns a page of the signed in user's conversations from
+// every device, most recent first
+func (c *Client) ListChatSessions(ctx context.Context, page, limit int) (*ChatSessionList, error) {
+	params := limitParam(limit)
+	if page > 0 {
+		params.Set("page", strconv.Itoa(page))
+	}
+	var list ChatSessionList
+	if err := c.getData(ctx, http.MethodGet, "/api/v1/user/chat-sessions", params, nil, &list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// ResumeChatSession reopens one of the signed in user's conversations on
+// this device with its recent messages
+func (c *Client) ResumeChatSession(ctx context.Context, sessionID string) (*ResumedChatSession, error) {
+	var resumed ResumedChatSession
+	if err := c.getData(ctx, http.MethodPost, pathf("/api/v1/user/chat-sessions/%s/resume", sessionID), nil, nil, &resumed); err != nil {
+		return nil, err
+	}
+	return &resumed, nil
+}
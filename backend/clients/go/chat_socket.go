@@ -0,0 +1,289 @@
+package chatcommerce
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Chat WebSocket event types. The server also sends notifications, such as
+// maintenance notices, cart updates and job progress, under their own types.
+const (
+	ChatEventMessage     = "message"
+	ChatEventDelta       = "chat_response"
+	ChatEventTyping      = "typing"
+	ChatEventActions     = "actions"
+	ChatEventSuggestions = "suggestions"
+	ChatEventError       = "error"
+)
+
+// ErrChatClosed is returned by ChatConn.Next once the connection is closed
+var ErrChatClosed = errors.New("chatcommerce: chat connection closed")
+
+// ChatEvent is a message from the chat WebSocket. Data depends on Type and
+// is decoded with the event's methods.
+type ChatEvent struct {
+	Type      string          `json:"type"`
+	SessionID string          `json:"session_id"`
+	UserID    *string         `json:"user_id,omitempty"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// SocketMessage is a chat message sent over the WebSocket. Replies carry
+// their actions and suggestions in Metadata too.
+type SocketMessage struct {
+	ID        string                 `json:"id"`
+	SessionID string                 `json:"session_id"`
+	Role      string                 `json:"role"`
+	Content   string                 `json:"content"`
+	Metadata  map[string]interface{} `json:"metadata,omitempty"`
+	Timestamp time.Time              `json:"timestamp"`
+}
+
+// Decode decodes the event's data into v
+func (e *ChatEvent) Decode(v interface{}) error {
+	if err := json.Unmarshal(e.Data, v); err != nil {
+		return fmt.Errorf("chatcommerce: failed to decode %s event: %v", e.Type, err)
+	}
+	return nil
+}
+
+// Message decodes a message event
+func (e *ChatEvent) Message() (*SocketMessage, error) {
+	var message SocketMessage
+	if err := e.Decode(&message); err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
+
+// Delta decodes a chat_response event: a piece of the reply being written.
+// The message event with the same ID carries the final reply.
+func (e *ChatEvent) Delta() (id, delta string, err error) {
+	var data struct {
+		ID    string `json:"id"`
+		Delta string `json:"delta"`
+	}
+	err = e.Decode(&data)
+	return data.ID, data.Delta, err
+}
+
+// Typing decodes a typing event: whether the assistant is writing a reply
+func (e *ChatEvent) Typing() (bool, error) {
+	var typing struct {
+		IsTyping bool `json:"is_typing"`
+	}
+	err := e.Decode(&typing)
+	return typing.IsTyping, err
+}
+
+// Actions decodes an actions event
+func (e *ChatEvent) Actions() ([]ChatAction, error) {
+	var actions []ChatAction
+	err := e.Decode(&actions)
+	return actions, err
+}
+
+// Suggestions decodes a suggestions event
+func (e *ChatEvent) Suggestions() ([]ProductSuggestion, error) {
+	var suggestions []ProductSuggestion
+	err := e.Decode(&suggestions)
+	return suggestions, err
+}
+
+// Err returns the message of an error event as an error, or nil for other events
+func (e *ChatEvent) Err() error {
+	if e.Type != ChatEventError {
+		return nil
+	}
+	var data struct {
+		Message string `json:"message"`
+	}
+	_ = json.Unmarshal(e.Data, &data)
+	return fmt.Errorf("chatcommerce: chat error: %s", data.Message)
+}
+
+// ChatConn is a chat WebSocket connection. Reads and writes may happen on
+// different goroutines.
+type ChatConn struct {
+	conn *websocket.Conn
+
+	writeMu sync.Mutex
+	mu      sync.Mutex
+	pending *ChatEvent
+	session string
+}
+
+// DialChat connects to the chat WebSocket, continuing sessionID when it's
+// the shopper's or starting a new conversation. fields trims the products
+// of suggestions, e.g. "name", "price". It returns once the welcome message
+// arrived, so SessionID is known; the welcome message is the first event
+// Next returns.
+func (c *Client) DialChat(ctx context.Context, sessionID string, fields ...string) (*ChatConn, error) {
+	u := *c.baseURL
+	switch u.Scheme {
+	case "https":
+		u.Scheme = "wss"
+	default:
+		u.Scheme = "ws"
+	}
+	u.Path = strings.TrimRight(u.Path, "/") + "/api/v1/chat/ws"
+	query := url.Values{}
+	if sessionID != "" {
+		query.Set("session_id", sessionID)
+	}
+	if len(fields) > 0 {
+		query.Set("fields", strings.Join(fields, ","))
+	}
+	u.RawQuery = query.Encode()
+
+	dialer := websocket.Dialer{
+		Proxy:            http.ProxyFromEnvironment,
+		HandshakeTimeout: DefaultTimeout,
+		Jar:              c.httpClient.Jar,
+	}
+
+	for attempt := 0; ; attempt++ {
+		header := http.Header{"User-Agent": {c.userAgent}}
+		if token := c.Tokens().AccessToken; token != "" {
+			header.Set("Authorization", "Bearer "+token)
+		}
+		conn, resp, err := dialer.DialContext(ctx, u.String(), header)
+		if err == nil {
+			chat := &ChatConn{conn: conn}
+			welcome, err := chat.read()
+			if err != nil {
+				conn.Close()
+				return nil, err
+			}
+			chat.pending = welcome
+			return chat, nil
+		}
+
+		retryAfter := time.Duration(0)
+		if resp != nil {
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			apiErr := newAPIError(resp, body)
+			if !retryable(http.MethodGet, resp.StatusCode) || apiErr.RetryAfter > maxBackoff {
+				return nil, apiErr
+			}
+			retryAfter = apiErr.RetryAfter
+		}
+		if ctx.Err() != nil || attempt >= c.maxRetries {
+			return nil, fmt.Errorf("chatcommerce: failed to connect to chat: %w", err)
+		}
+		if err := c.sleep(ctx, c.retryDelay(attempt, retryAfter)); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// SessionID is the conversation the connection belongs to
+func (cc *ChatConn) SessionID() string {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	return cc.session
+}
+
+// Send sends a message to the assistant; the reply arrives as events
+func (cc *ChatConn) Send(content string) error {
+	return cc.write(ChatEventMessage, map[string]string{"content": content})
+}
+
+// SendTyping tells the server whether the shopper is typing
+func (cc *ChatConn) SendTyping(isTyping bool) error {
+	return cc.write(ChatEventTyping, map[string]bool{"is_typing": isTyping})
+}
+
+// Next waits for the next event. It returns ErrChatClosed once the
+// connection was closed normally.
+func (cc *ChatConn) Next() (*ChatEvent, error) {
+	cc.mu.Lock()
+	pending := cc.pending
+	cc.pending = nil
+	cc.mu.Unlock()
+	if pending != nil {
+		return pending, nil
+	}
+	return cc.read()
+}
+
+// Listen calls handle with each event until the connection closes or ctx is
+// done, which closes the connection
+func (cc *ChatConn) Listen(ctx context.Context, handle func(*ChatEvent)) error {
+	stop := context.AfterFunc(ctx, func() { cc.Close() })
+	defer stop()
+	for {
+		event, err := cc.Next()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if errors.Is(err, ErrChatClosed) {
+				return nil
+			}
+			return err
+		}
+		handle(event)
+	}
+}
+
+// Close closes the connection
+func (cc *ChatConn) Close() error {
+	cc.writeMu.Lock()
+	_ = cc.conn.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+	cc.writeMu.Unlock()
+	return cc.conn.Close()
+}
+
+func (cc *ChatConn) read() (*ChatEvent, error) {
+	var event ChatEvent
+	if err := cc.conn.ReadJSON(&event); err != nil {
+		if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) || errors.Is(err, net.ErrClosed) {
+			return nil, ErrChatClosed
+		}
+		return nil, fmt.Errorf("chatcommerce: failed to read chat event: %w", err)
+	}
+	if event.SessionID != "" {
+		cc.mu.Lock()
+		cc.session = event.SessionID
+		cc.mu.Unlock()
+	}
+	return &event, nil
+}
+
+func (cc *ChatConn) write(eventType string, data interface{}) error {
+	// Each frame carries a fresh nonce and timestamp, without which the
+	// server refuses chat messages as replays
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("chatcommerce: failed to generate nonce: %w", err)
+	}
+	cc.writeMu.Lock()
+	defer cc.writeMu.Unlock()
+	err := cc.conn.WriteJSON(map[string]interface{}{
+		"type":       eventType,
+		"data":       data,
+		"session_id": cc.SessionID(),
+		"nonce":      hex.EncodeToString(nonce),
+		"timestamp":  time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("chatcommerce: failed to send chat %s: %w", eventType, err)
+	}
+	return nil
+}
@@ -0,0 +1,405 @@
+// Package chatcommerce is the Go client for the chat commerce API. It wraps
+// the storefront and customer endpoints and the chat WebSocket with typed
+// requests and responses, signs in and refreshes tokens, keeps the cart and
+// chat session of one shopper, and retries throttled and failed requests.
+//
+//	client := chatcommerce.New("https://shop.example.com")
+//	if _, err := client.Login(ctx, "ada@example.com", "secret123"); err != nil {
+//		return err
+//	}
+//	products, err := client.ListProducts(ctx, chatcommerce.NewListParams().
+//		Filter("price", "lte", "50").Sort("-popularity"))
+//
+// Admin endpoints aren't wrapped; Do calls any endpoint with the client's
+// authentication, session and retries.
+package chatcommerce
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SessionHeader carries the shopper's cart session
+const SessionHeader = "X-Session-ID"
+
+// Defaults for New
+const (
+	DefaultMaxRetries = 3
+	DefaultTimeout    = 30 * time.Second
+	defaultBackoff    = 500 * time.Millisecond
+	maxBackoff        = 30 * time.Second
+)
+
+// Client calls the API as one shopper. It's safe for concurrent use.
+type Client struct {
+	baseURL    *url.URL
+	httpClient *http.Client
+	userAgent  string
+	maxRetries int
+	backoff    time.Duration
+	throttle   bool
+
+	mu        sync.Mutex
+	sessionID string
+	tokens    Tokens
+	onTokens  func(Tokens)
+	rateLimit RateLimit
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithHTTPClient sends requests with hc. A cookie jar is added when hc has
+// none, since anonymous chat sessions are proven by a cookie.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.httpClient = hc }
+}
+
+// WithTokens signs the client in with tokens saved from an earlier sign-in
+func WithTokens(tokens Tokens) Option {
+	return func(c *Client) { c.tokens = tokens }
+}
+
+// WithTokenCallback calls fn whenever the client signs in or refreshes its
+// tokens, e.g. to save them
+func WithTokenCallback(fn func(Tokens)) Option {
+	return func(c *Client) { c.onTokens = fn }
+}
+
+// WithSessionID sets the cart session, e.g. to continue a guest's cart. By
+// default each client starts a new one.
+func WithSessionID(sessionID string) Option {
+	return func(c *Client) { c.sessionID = sessionID }
+}
+
+// WithRetries retries a failed request up to maxRetries times, waiting
+// backoff before the first retry and twice as long before each next one
+func WithRetries(maxRetries int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.maxRetries = maxRetries
+		c.backoff = backoff
+	}
+}
+
+// WithSelfThrottle turns waiting for the rate limit window to reset, once
+// the server reports no requests left, on or off. It's on by default.
+func WithSelfThrottle(enabled bool) Option {
+	return func(c *Client) { c.throttle = enabled }
+}
+
+// WithUserAgent sets the User-Agent header
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) { c.userAgent = userAgent }
+}
+
+// New creates a client for the API at baseURL, e.g. https://shop.example.com
+func New(baseURL string, opts ...Option) *Client {
+	u, err := url.Parse(strings.TrimRight(baseURL, "/"))
+	if err != nil {
+		u = &url.URL{}
+	}
+	c := &Client{
+		baseURL:    u,
+		userAgent:  "chatcommerce-go",
+		maxRetries: DefaultMaxRetries,
+		backoff:    defaultBackoff,
+		throttle:   true,
+		sessionID:  "sdk_" + uuid.New().String(),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.httpClient == nil {
+		c.httpClient = &http.Client{Timeout: DefaultTimeout}
+	}
+	if c.httpClient.Jar == nil {
+		jar, _ := cookiejar.New(nil)
+		hc := *c.httpClient
+		hc.Jar = jar
+		c.httpClient = &hc
+	}
+	return c
+}
+
+// SessionID returns the cart session requests are sent with
+func (c *Client) SessionID() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.sessionID
+}
+
+// Tokens returns the current sign-in, empty when signed out
+func (c *Client) Tokens() Tokens {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.tokens
+}
+
+// RateLimit returns the rate limit reported by the last response
+func (c *Client) RateLimit() RateLimit {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.rateLimit
+}
+
+// RateLimit is the server's X-RateLimit-* headers
+type RateLimit struct {
+	Limit     int
+	Remaining int
+	Reset     time.Time // when the count starts over
+}
+
+// APIError is an error response from the API
+type APIError struct {
+	StatusCode int
+	Message    string
+	RetryAfter time.Duration // from Retry-After, on 429 and 503
+	Body       []byte        // e.g. order violations, to decode further
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("chatcommerce: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("chatcommerce: %d %s", e.StatusCode, e.Message)
+}
+
+// IsNotFound reports whether err is a 404 from the API
+func IsNotFound(err error) bool {
+	return hasStatus(err, http.StatusNotFound)
+}
+
+// IsUnauthorized reports whether err is a 401 from the API
+func IsUnauthorized(err error) bool {
+	return hasStatus(err, http.StatusUnauthorized)
+}
+
+// IsRateLimited reports whether err is a 429 from the API
+func IsRateLimited(err error) bool {
+	return hasStatus(err, http.StatusTooManyRequests)
+}
+
+func hasStatus(err error, status int) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == status
+}
+
+// Do calls method on path, e.g. /api/v1/store/availability, with query and
+// a JSON body and decodes the JSON response into out. Both body and out may
+// be nil.
+func (c *Client) Do(ctx context.Context, method, path string, query url.Values, body, out interface{}) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return fmt.Errorf("chatcommerce: failed to encode request: %v", err)
+		}
+	}
+
+	refreshed := false
+	for attempt := 0; ; attempt++ {
+		if err := c.waitForRateLimit(ctx); err != nil {
+			return err
+		}
+		resp, err := c.send(ctx, method, path, query, payload)
+		if err != nil {
+			if ctx.Err() != nil || attempt >= c.maxRetries || !idempotent(method) {
+				return fmt.Errorf("chatcommerce: %s %s: %w", method, path, err)
+			}
+			if err := c.sleep(ctx, c.retryDelay(attempt, 0)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		data, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("chatcommerce: failed to read response: %v", err)
+		}
+		c.recordRateLimit(resp.Header)
+
+		if resp.StatusCode < 300 {
+			if out == nil || len(data) == 0 {
+				return nil
+			}
+			if err := json.Unmarshal(data, out); err != nil {
+				return fmt.Errorf("chatcommerce: failed to decode response: %v", err)
+			}
+			return nil
+		}
+
+		apiErr := newAPIError(resp, data)
+		// An expired access token is renewed once with the refresh token
+		if resp.StatusCode == http.StatusUnauthorized && !refreshed && !strings.HasPrefix(path, "/api/v1/auth/") && c.Tokens().RefreshToken != "" {
+			refreshed = true
+			if _, err := c.Refresh(ctx); err == nil {
+				continue
+			}
+			return apiErr
+		}
+		// Waits longer than the backoff cap, like a maintenance window, are
+		// left to the caller
+		if attempt >= c.maxRetries || !retryable(method, resp.StatusCode) || apiErr.RetryAfter > maxBackoff {
+			return apiErr
+		}
+		if err := c.sleep(ctx, c.retryDelay(attempt, apiErr.RetryAfter)); err != nil {
+			return err
+		}
+	}
+}
+
+// send makes one request with the session and access token
+func (c *Client) send(ctx context.Context, method, path string, query url.Values, payload []byte) (*http.Response, error) {
+	u := *c.baseURL
+	u.Path = strings.TrimRight(u.Path, "/") + path
+	u.RawQuery = query.Encode()
+
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("User-Agent", c.userAgent)
+
+	c.mu.Lock()
+	req.Header.Set(SessionHeader, c.sessionID)
+	if c.tokens.AccessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.tokens.AccessToken)
+	}
+	c.mu.Unlock()
+
+	return c.httpClient.Do(req)
+}
+
+// idempotent methods are safe to send again after a network error
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+// retryable reports whether a failed request may be sent again. Throttled
+// requests and those refused during maintenance weren't processed, so they
+// are retried whatever the method.
+func retryable(method string, status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return true
+	case http.StatusBadGateway, http.StatusGatewayTimeout, http.StatusInternalServerError:
+		return idempotent(method)
+	}
+	return false
+}
+
+// retryDelay is Retry-After when the server sent one, or an exponential
+// backoff with jitter
+func (c *Client) retryDelay(attempt int, retryAfter time.Duration) time.Duration {
+	if retryAfter > 0 {
+		return retryAfter
+	}
+	delay := c.backoff << attempt
+	if delay <= 0 || delay > maxBackoff {
+		delay = maxBackoff
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+func (c *Client) sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// waitForRateLimit holds a request back until the window resets when the
+// last response said none were left
+func (c *Client) waitForRateLimit(ctx context.Context) error {
+	if !c.throttle {
+		return nil
+	}
+	limit := c.RateLimit()
+	if limit.Limit == 0 || limit.Remaining > 0 {
+		return nil
+	}
+	return c.sleep(ctx, time.Until(limit.Reset))
+}
+
+func (c *Client) recordRateLimit(header http.Header) {
+	limit, err := strconv.Atoi(header.Get("X-RateLimit-Limit"))
+	if err != nil {
+		return
+	}
+	remaining, _ := strconv.Atoi(header.Get("X-RateLimit-Remaining"))
+	reset, _ := strconv.Atoi(header.Get("X-RateLimit-Reset"))
+	c.mu.Lock()
+	c.rateLimit = RateLimit{Limit: limit, Remaining: remaining, Reset: time.Now().Add(time.Duration(reset) * time.Second)}
+	c.mu.Unlock()
+}
+
+func newAPIError(resp *http.Response, body []byte) *APIError {
+	apiErr := &APIError{StatusCode: resp.StatusCode, Body: body}
+	var payload struct {
+		Error   string `json:"error"`
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(body, &payload) == nil {
+		apiErr.Message = payload.Error
+		if apiErr.Message == "" {
+			apiErr.Message = payload.Message
+		}
+	}
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+		apiErr.RetryAfter = time.Duration(seconds) * time.Second
+	}
+	return apiErr
+}
+
+// envelope is the {"success": true, "data": ...} response of newer endpoints
+type envelope struct {
+	Data interface{} `json:"data"`
+}
+
+// getData calls an endpoint answering with an envelope and decodes its data into out
+func (c *Client) getData(ctx context.Context, method, path string, query url.Values, body, out interface{}) error {
+	return c.Do(ctx, method, path, query, body, &envelope{Data: out})
+}
+
+// pathf builds a path, escaping each argument as one path segment
+func pathf(format string, args ...interface{}) string {
+	escaped := make([]interface{}, len(args))
+	for i, arg := range args {
+		escaped[i] = url.PathEscape(fmt.Sprint(arg))
+	}
+	return fmt.Sprintf(format, escaped...)
+}
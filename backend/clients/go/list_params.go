@@ -0,0 +1,69 @@
+package chatcommerce
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// ListParams filters, sorts and pages a list endpoint:
+//
+//	NewListParams().Filter("status", "eq", "active").Filter("price", "gte", "10").
+//		Sort("-created_at", "name").Page(2, 20)
+//
+// Each endpoint documents the fields it can be filtered and sorted by.
+type ListParams struct {
+	values url.Values
+}
+
+// NewListParams creates empty list parameters
+func NewListParams() *ListParams {
+	return &ListParams{values: url.Values{}}
+}
+
+// Filter adds a filter on field with op, one of eq, ne, gt, gte, lt, lte,
+// in and contains. The values of an in filter are joined with commas.
+func (p *ListParams) Filter(field, op string, values ...string) *ListParams {
+	key := "filter[" + field + "]"
+	if op != "" && op != "eq" {
+		key += "[" + op + "]"
+	}
+	p.values.Set(key, strings.Join(values, ","))
+	return p
+}
+
+// Search matches the list's searchable text, e.g. product names and descriptions
+func (p *ListParams) Search(query string) *ListParams {
+	return p.Filter("search", "", query)
+}
+
+// Sort orders the list by fields, descending when prefixed with "-"
+func (p *ListParams) Sort(fields ...string) *ListParams {
+	p.values.Set("sort", strings.Join(fields, ","))
+	return p
+}
+
+// Page selects a page of size items, counting pages from 1
+func (p *ListParams) Page(number, size int) *ListParams {
+	p.values.Set("page[number]", strconv.Itoa(number))
+	p.values.Set("page[size]", strconv.Itoa(size))
+	return p
+}
+
+// Fields trims the items to the named fields, e.g. name,price,images
+func (p *ListParams) Fields(fields ...string) *ListParams {
+	p.values.Set("fields", strings.Join(fields, ","))
+	return p
+}
+
+// Values returns the parameters as a query
+func (p *ListParams) Values() url.Values {
+	if p == nil {
+		return url.Values{}
+	}
+	values := url.Values{}
+	for key, v := range p.values {
+		values[key] = append([]string(nil), v...)
+	}
+	return values
+}
@@ -0,0 +1,57 @@
+package chatcommerce
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/google/uuid"
+)
+
+// StoreAvailability returns whether staff are around and when orders ship.
+// An empty store is the default storefront.
+func (c *Client) StoreAvailability(ctx context.Context, store string) (*StoreAvailability, error) {
+	params := url.Values{}
+	if store != "" {
+		params.Set("store", store)
+	}
+	var availability StoreAvailability
+	if err := c.getData(ctx, http.MethodGet, "/api/v1/store/availability", params, nil, &availability); err != nil {
+		return nil, err
+	}
+	return &availability, nil
+}
+
+// Locale returns the country, currency and tax display prices are shown in
+// for the shopper
+func (c *Client) Locale(ctx context.Context) (*Locale, error) {
+	var locale Locale
+	if err := c.getData(ctx, http.MethodGet, "/api/v1/locale", nil, nil, &locale); err != nil {
+		return nil, err
+	}
+	return &locale, nil
+}
+
+// Maintenance returns planned or ongoing maintenance
+func (c *Client) Maintenance(ctx context.Context) (*Maintenance, error) {
+	var maintenance Maintenance
+	if err := c.getData(ctx, http.MethodGet, "/api/v1/maintenance", nil, nil, &maintenance); err != nil {
+		return nil, err
+	}
+	return &maintenance, nil
+}
+
+// DeliverySlots returns the delivery dates offered at checkout
+func (c *Client) DeliverySlots(ctx context.Context) ([]DeliverySlot, error) {
+	var slots []DeliverySlot
+	if err := c.getData(ctx, http.MethodGet, "/api/v1/delivery-slots", nil, nil, &slots); err != nil {
+		return nil, err
+	}
+	return slots, nil
+}
+
+// RecordCampaignClick records that the shopper followed a campaign link
+func (c *Client) RecordCampaignClick(ctx context.Context, campaignID uuid.UUID) error {
+	body := map[string]string{"session_id": c.SessionID()}
+	return c.Do(ctx, http.MethodPost, pathf("/api/v1/campaigns/%s/clicks", campaignID), nil, body, nil)
+}
@@ -0,0 +1,409 @@
+package chatcommerce
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Tokens is a sign-in: a short-lived access token and the single use
+// refresh token that renews it
+type Tokens struct {
+	AccessToken      string    `json:"token"`
+	RefreshToken     string    `json:"refresh_token"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+}
+
+// User is a customer account
+type User struct {
+	ID              uuid.UUID       `json:"id"`
+	Email           string          `json:"email"`
+	FirstName       string          `json:"first_name"`
+	LastName        string          `json:"last_name"`
+	Phone           string          `json:"phone"`
+	DateOfBirth     *time.Time      `json:"date_of_birth"`
+	Preferences     json.RawMessage `json:"preferences"`
+	EmailVerified   bool            `json:"email_verified"`
+	Status          string          `json:"status"`
+	LastLoginAt     *time.Time      `json:"last_login_at"`
+	CustomerGroupID *uuid.UUID      `json:"customer_group_id"`
+	CreatedAt       time.Time       `json:"created_at"`
+	UpdatedAt       time.Time       `json:"updated_at"`
+}
+
+// Product is a catalog product. Price is the signed in customer's price;
+// ListPrice is set when it differs from the catalog price.
+type Product struct {
+	ID          uuid.UUID        `json:"id"`
+	Name        string           `json:"name"`
+	Description string           `json:"description"`
+	Price       float64          `json:"price"`
+	ListPrice   *float64         `json:"list_price,omitempty"`
+	CategoryID  uuid.UUID        `json:"category_id"`
+	BrandID     *uuid.UUID       `json:"brand_id"`
+	SKU         string           `json:"sku"`
+	Status      string           `json:"status"`
+	Metadata    json.RawMessage  `json:"metadata"`
+	Popularity  int              `json:"popularity"`
+	CreatedAt   time.Time        `json:"created_at"`
+	UpdatedAt   time.Time        `json:"updated_at"`
+	Category    *Category        `json:"category,omitempty"`
+	Brand       *Brand           `json:"brand,omitempty"`
+	Variants    []ProductVariant `json:"variants"`
+	Images      []ProductImage   `json:"images"`
+	Inventory   []Inventory      `json:"inventory"`
+}
+
+// ProductVariant is an option of a product, e.g. Size: M
+type ProductVariant struct {
+	ID            uuid.UUID `json:"id"`
+	VariantName   string    `json:"variant_name"`
+	VariantValue  string    `json:"variant_value"`
+	PriceModifier float64   `json:"price_modifier"`
+	SKUSuffix     string    `json:"sku_suffix"`
+	IsDefault     bool      `json:"is_default"`
+}
+
+// ProductImage is a product photo
+type ProductImage struct {
+	ID        uuid.UUID `json:"id"`
+	URL       string    `json:"url"`
+	AltText   string    `json:"alt_text"`
+	IsPrimary bool      `json:"is_primary"`
+	SortOrder int       `json:"sort_order"`
+}
+
+// Inventory is a product's stock at a warehouse
+type Inventory struct {
+	ID                uuid.UUID  `json:"id"`
+	VariantID         *uuid.UUID `json:"variant_id"`
+	WarehouseLocation string     `json:"warehouse_location"`
+	QuantityAvailable int        `json:"quantity_available"`
+	QuantityReserved  int        `json:"quantity_reserved"`
+}
+
+// ProductList is a page of products
+type ProductList struct {
+	Products    []Product `json:"products"`
+	Total       int64     `json:"total"`
+	Page        int       `json:"page"`
+	Limit       int       `json:"limit"`
+	TotalPages  int       `json:"total_pages"`
+	HasNext     bool      `json:"has_next"`
+	HasPrevious bool      `json:"has_previous"`
+}
+
+// ProductAvailability is a product's stock status
+type ProductAvailability struct {
+	ProductID uuid.UUID `json:"product_id"`
+	Status    string    `json:"status"`
+	InStock   bool      `json:"in_stock"`
+	LowStock  bool      `json:"low_stock"`
+}
+
+// AutocompleteSuggestion is a type-ahead match
+type AutocompleteSuggestion struct {
+	Kind string     `json:"kind"` // product, category or query
+	Text string     `json:"text"`
+	ID   *uuid.UUID `json:"id,omitempty"`
+	Slug string     `json:"slug,omitempty"`
+}
+
+// Autocomplete groups the type-ahead matches of a prefix by kind
+type Autocomplete struct {
+	Query      string                   `json:"query"`
+	Products   []AutocompleteSuggestion `json:"products"`
+	Categories []AutocompleteSuggestion `json:"categories"`
+	Queries    []AutocompleteSuggestion `json:"queries"`
+}
+
+// ProductQuestion is a shopper's question about a product and its answer
+type ProductQuestion struct {
+	ID         uuid.UUID  `json:"id"`
+	ProductID  uuid.UUID  `json:"product_id"`
+	AskerName  string     `json:"asker_name"`
+	Question   string     `json:"question"`
+	Answer     string     `json:"answer"`
+	Status     string     `json:"status"`
+	AnsweredAt *time.Time `json:"answered_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// Category is a product category
+type Category struct {
+	ID          uuid.UUID  `json:"id"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	ParentID    *uuid.UUID `json:"parent_id"`
+	Slug        string     `json:"slug"`
+	SortOrder   int        `json:"sort_order"`
+	IsActive    bool       `json:"is_active"`
+	Children    []Category `json:"children"`
+}
+
+// Brand is a product brand
+type Brand struct {
+	ID          uuid.UUID `json:"id"`
+	Name        string    `json:"name"`
+	Slug        string    `json:"slug"`
+	Description string    `json:"description"`
+	LogoURL     string    `json:"logo_url"`
+	IsActive    bool      `json:"is_active"`
+}
+
+// BrandListing is a brand with how many products it has
+type BrandListing struct {
+	Brand
+	ProductCount int64 `json:"product_count"`
+}
+
+// CartItem is a line of the cart
+type CartItem struct {
+	ProductID   uuid.UUID  `json:"product_id"`
+	VariantID   *uuid.UUID `json:"variant_id,omitempty"`
+	Quantity    int        `json:"quantity"`
+	UnitPrice   float64    `json:"unit_price"`
+	TotalPrice  float64    `json:"total_price"`
+	ProductName string     `json:"product_name"`
+	SKU         string     `json:"sku"`
+}
+
+// Cart is the shopper's cart with its totals
+type Cart struct {
+	Items          []CartItem `json:"items"`
+	Subtotal       float64    `json:"subtotal"`
+	TaxAmount      float64    `json:"tax_amount"`
+	TaxRate        float64    `json:"tax_rate,omitempty"`
+	TaxIncluded    bool       `json:"tax_included"`
+	ShippingAmount float64    `json:"shipping_amount"`
+	TotalAmount    float64    `json:"total_amount"`
+	Currency       string     `json:"currency"`
+	ItemCount      int        `json:"item_count"`
+	ReservedUntil  *time.Time `json:"reserved_until,omitempty"`
+	GiftWrap       bool       `json:"gift_wrap"`
+	GiftWrapAmount float64    `json:"gift_wrap_amount"`
+	GiftMessage    string     `json:"gift_message,omitempty"`
+}
+
+// CartShare is a link to a copy of the cart
+type CartShare struct {
+	Token     string    `json:"token"`
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// CartClone is the cart after copying a shared one into it
+type CartClone struct {
+	Cart    *Cart      `json:"cart"`
+	Skipped []CartItem `json:"skipped"` // no longer available in the shared quantity
+}
+
+// GiftOptions are the cart's gift wrap and message
+type GiftOptions struct {
+	GiftWrap    bool   `json:"gift_wrap"`
+	GiftMessage string `json:"gift_message"`
+}
+
+// ChatAction is something the assistant did or suggests, e.g. add_to_cart
+type ChatAction struct {
+	Type    string                 `json:"type"`
+	Payload map[string]interface{} `json:"payload"`
+}
+
+// ProductCard is the compact product of a chat suggestion
+type ProductCard struct {
+	ID           uuid.UUID       `json:"id"`
+	Name         string          `json:"name"`
+	Price        float64         `json:"price"`
+	ListPrice    *float64        `json:"list_price,omitempty"`
+	ImageURL     string          `json:"image_url,omitempty"`
+	CategoryName string          `json:"category_name,omitempty"`
+	InStock      bool            `json:"in_stock"`
+	Variants     []VariantOption `json:"variants,omitempty"`
+}
+
+// VariantOption lists a product's variants of one kind, e.g. Color: Red, Blue
+type VariantOption struct {
+	Name   string   `json:"name"`
+	Values []string `json:"values"`
+}
+
+// ProductSuggestion is a product the assistant suggests
+type ProductSuggestion struct {
+	Product    ProductCard `json:"product"`
+	Reason     string      `json:"reason"`
+	Confidence float64     `json:"confidence"`
+}
+
+// ChatReply is the assistant's answer to a message
+type ChatReply struct {
+	SessionID   string                 `json:"session_id"` // a new session when the requested one wasn't the shopper's
+	Message     string                 `json:"message"`
+	Actions     []ChatAction           `json:"actions,omitempty"`
+	Suggestions []ProductSuggestion    `json:"suggestions,omitempty"`
+	Context     map[string]interface{} `json:"context,omitempty"`
+	Error       string                 `json:"error,omitempty"`
+}
+
+// ChatMessage is a message of a conversation
+type ChatMessage struct {
+	ID        uuid.UUID              `json:"id"`
+	SessionID string                 `json:"session_id"`
+	UserID    *uuid.UUID             `json:"user_id,omitempty"`
+	Role      string                 `json:"role"` // user, assistant or system
+	Content   string                 `json:"content"`
+	Metadata  map[string]interface{} `json:"metadata,omitempty"`
+	CreatedAt string                 `json:"created_at"`
+}
+
+// ChatHistory is a conversation's recent messages
+type ChatHistory struct {
+	SessionID string        `json:"session_id"`
+	Messages  []ChatMessage `json:"messages"`
+}
+
+// ChatSession is a conversation with the assistant
+type ChatSession struct {
+	SessionID string          `json:"id"` // the signed session ID chat requests are sent with
+	UserID    *uuid.UUID      `json:"user_id,omitempty"`
+	Context   json.RawMessage `json:"context,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// ChatSessionPreview summarises one of a user's past conversations
+type ChatSessionPreview struct {
+	SessionID     string    `json:"session_id"`
+	Title         string    `json:"title"`
+	LastMessage   string    `json:"last_message"`
+	LastRole      string    `json:"last_role"`
+	MessageCount  int       `json:"message_count"`
+	Status        string    `json:"status"`
+	StartedAt     time.Time `json:"started_at"`
+	LastMessageAt time.Time `json:"last_message_at"`
+}
+
+// ChatSessionList is a page of a user's past conversations
+type ChatSessionList struct {
+	Sessions    []ChatSessionPreview `json:"sessions"`
+	Total       int64                `json:"total"`
+	Page        int                  `json:"page"`
+	Limit       int                  `json:"limit"`
+	TotalPages  int                  `json:"total_pages"`
+	HasNext     bool                 `json:"has_next"`
+	HasPrevious bool                 `json:"has_previous"`
+}
+
+// ResumedChatSession is a past conversation reopened on this device
+type ResumedChatSession struct {
+	SessionID string          `json:"session_id"`
+	Messages  []ChatMessage   `json:"messages"`
+	ExpiresAt time.Time       `json:"expires_at"`
+	Greeting  string          `json:"greeting,omitempty"`
+	Context   json.RawMessage `json:"context,omitempty"`
+	Cart      *Cart           `json:"cart,omitempty"`
+}
+
+// OrderItem is a line of an order request
+type OrderItem struct {
+	ProductID uuid.UUID  `json:"product_id"`
+	VariantID *uuid.UUID `json:"variant_id,omitempty"`
+	Quantity  int        `json:"quantity"`
+}
+
+// OrderRequest places an order
+type OrderRequest struct {
+	Items           []OrderItem            `json:"items"`
+	ShippingAddress map[string]interface{} `json:"shipping_address"`
+	BillingAddress  map[string]interface{} `json:"billing_address"`
+	PaymentMethod   string                 `json:"payment_method"`
+	Notes           string                 `json:"notes,omitempty"`
+	AllowBackorder  bool                   `json:"allow_backorder,omitempty"`
+	Gift            *GiftOptions           `json:"gift,omitempty"`
+	DeliveryDate    string                 `json:"delivery_date,omitempty"` // YYYY-MM-DD, from DeliverySlots
+	DeliveryCarrier string                 `json:"delivery_carrier,omitempty"`
+}
+
+// OrderLine is an ordered product
+type OrderLine struct {
+	ID         uuid.UUID  `json:"id"`
+	ProductID  uuid.UUID  `json:"product_id"`
+	VariantID  *uuid.UUID `json:"variant_id"`
+	Quantity   int        `json:"quantity"`
+	UnitPrice  float64    `json:"unit_price"`
+	TotalPrice float64    `json:"total_price"`
+}
+
+// Order is a placed order
+type Order struct {
+	ID              uuid.UUID       `json:"id"`
+	OrderNumber     string          `json:"order_number"`
+	Status          string          `json:"status"`
+	Subtotal        float64         `json:"subtotal"`
+	TaxAmount       float64         `json:"tax_amount"`
+	TaxIncluded     bool            `json:"tax_included"`
+	ShippingAmount  float64         `json:"shipping_amount"`
+	TotalAmount     float64         `json:"total_amount"`
+	Currency        string          `json:"currency"`
+	PaymentStatus   string          `json:"payment_status"`
+	PaymentMethod   string          `json:"payment_method"`
+	ShippingAddress json.RawMessage `json:"shipping_address"`
+	BillingAddress  json.RawMessage `json:"billing_address"`
+	GiftWrap        bool            `json:"gift_wrap"`
+	GiftMessage     string          `json:"gift_message,omitempty"`
+	DeliveryDate    *time.Time      `json:"delivery_date,omitempty"`
+	CreatedAt       time.Time       `json:"created_at"`
+	UpdatedAt       time.Time       `json:"updated_at"`
+	Items           []OrderLine     `json:"items"`
+}
+
+// OrderList is a page of the user's orders
+type OrderList struct {
+	Orders      []Order `json:"orders"`
+	Total       int64   `json:"total"`
+	Page        int     `json:"page"`
+	Limit       int     `json:"limit"`
+	TotalPages  int     `json:"total_pages"`
+	HasNext     bool    `json:"has_next"`
+	HasPrevious bool    `json:"has_previous"`
+}
+
+// StoreAvailability is whether staff are around and when orders ship
+type StoreAvailability struct {
+	Store           string     `json:"store"`
+	Open            bool       `json:"open"`
+	HoursConfigured bool       `json:"hours_configured"`
+	ClosesAt        *time.Time `json:"closes_at,omitempty"`
+	OpensAt         *time.Time `json:"opens_at,omitempty"`
+	NextShipDate    *time.Time `json:"next_ship_date,omitempty"`
+	ShippingCutoff  *time.Time `json:"shipping_cutoff,omitempty"`
+}
+
+// Locale is the country, currency and tax display prices are shown in
+type Locale struct {
+	Country        string `json:"country"`
+	Currency       string `json:"currency"`
+	TaxInclusive   bool   `json:"tax_inclusive"`
+	ShipsToCountry bool   `json:"ships_to_country"`
+	StoreCurrency  string `json:"store_currency"`
+	Source         string `json:"source"`
+}
+
+// Maintenance is planned or ongoing API maintenance
+type Maintenance struct {
+	Planned           bool       `json:"planned"`
+	Active            bool       `json:"active"` // writes are refused
+	Message           string     `json:"message,omitempty"`
+	StartsAt          *time.Time `json:"starts_at,omitempty"`
+	ExpectedEnd       *time.Time `json:"expected_end,omitempty"`
+	SecondsUntilStart int        `json:"seconds_until_start"`
+	RetryAfterSeconds int        `json:"retry_after_seconds,omitempty"`
+}
+
+// DeliverySlot is a delivery date offered at checkout
+type DeliverySlot struct {
+	Date    string `json:"date"`
+	Carrier string `json:"carrier"`
+	ShipBy  string `json:"ship_by"`
+}
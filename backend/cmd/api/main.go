@@ -3,13 +3,20 @@ package main
 import (
 	"chat-ecommerce-backend/internal/handlers"
 	"chat-ecommerce-backend/internal/middleware"
+	"chat-ecommerce-backend/internal/models"
 	"chat-ecommerce-backend/internal/routes"
 	"chat-ecommerce-backend/internal/services"
 	"chat-ecommerce-backend/internal/services/search"
 	"chat-ecommerce-backend/pkg/database"
+	"chat-ecommerce-backend/pkg/encryption"
+	"chat-ecommerce-backend/pkg/listquery"
+	wsproto "chat-ecommerce-backend/pkg/websocket"
+	"context"
+	"crypto/tls"
 	"log"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -23,6 +30,16 @@ func main() {
 		log.Fatal("Fatal error: .env file not found, using system environment variables")
 	}
 
+	// Encrypt personal data columns, before anything reads or writes them
+	keyring, err := encryption.KeyringFromEnv()
+	if err != nil {
+		log.Fatal("Invalid PII_ENCRYPTION_KEYS:", err)
+	}
+	if keyring == nil && os.Getenv("ENVIRONMENT") == "production" {
+		log.Println("Warning: PII_ENCRYPTION_KEYS is not set, personal data is stored unencrypted")
+	}
+	models.SetPIIKeyring(keyring)
+
 	// Initialize database
 	db, err := database.ConnectDatabase()
 	if err != nil {
@@ -47,13 +64,16 @@ func main() {
 	// Create Gin router
 	r := gin.Default()
 
-	// Configure CORS
+	// Configure CORS; the admin WebSocket checks the same origins
+	allowedOrigins := []string{"http://localhost:3000"}
 	config := cors.DefaultConfig()
-	config.AllowOrigins = []string{"http://localhost:3000"}
+	config.AllowOrigins = allowedOrigins
 	config.AllowMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
-	config.AllowHeaders = []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Requested-With", "X-Session-ID"}
+	config.AllowHeaders = []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Requested-With", "X-Session-ID", "X-Client-Type", "X-CSRF-Token"}
+	config.ExposeHeaders = []string{"X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset", "Retry-After"}
 	config.AllowCredentials = true
 	r.Use(cors.New(config))
+	r.Use(middleware.CSRFMiddleware()) // cookie sessions only; bearer tokens pass
 
 	// Health check endpoint
 	r.GET("/health", func(c *gin.Context) {
@@ -64,26 +84,193 @@ func main() {
 	})
 
 	// Initialize services and handlers
-	productService := services.NewProductService(db)
-	productHandler := handlers.NewProductHandler(productService)
-	cartService := services.NewShoppingCartService(db)
+	synonymService := services.NewSynonymService(db)
+	synonymHandler := handlers.NewSynonymHandler(synonymService)
+	searchMissHandler := handlers.NewSearchMissHandler(services.NewSearchMissService(db))
+	searchRankingHandler := handlers.NewSearchRankingHandler(services.NewSearchRankingService(db))
+	brandService := services.NewBrandService(db)
+	// Time-of-day, stock clearance and member discounts apply to prices as
+	// they are read; admin changes to the rules apply at once
+	pricingRuleService := services.NewPricingRuleService(db, services.PricingRuleConfigFromEnv())
+	pricingRuleHandler := handlers.NewPricingRuleHandler(pricingRuleService)
+	// Product views, chat suggestions, cart adds and orders are published to
+	// Kafka or Kinesis for analytics pipelines when a sink is configured
+	eventStream := services.NewEventStream(services.EventStreamConfigFromEnv())
+	eventStream.SchedulePublishing(context.Background())
+	eventStreamHandler := handlers.NewEventStreamHandler(eventStream)
+	// Drop day flash sales: warm product caches, a waiting room for
+	// checkouts and stricter purchase limits
+	availabilityService := services.NewAvailabilityService(db, services.AvailabilityCacheTTLFromEnv())
+	flashSaleService := services.NewFlashSaleService(db, services.FlashSaleConfigFromEnv()).WithAvailability(availabilityService)
+	flashSaleService.ScheduleWarming(context.Background())
+	flashSaleHandler := handlers.NewFlashSaleHandler(flashSaleService)
+	productService := services.NewProductService(db).WithSynonyms(synonymService).WithBrands(brandService).WithPricingRules(pricingRuleService)
+	productHandler := handlers.NewProductHandler(productService).WithEventStream(eventStream).WithFlashSales(flashSaleService)
+	brandHandler := handlers.NewBrandHandler(brandService, productService)
+	productQuestionHandler := handlers.NewProductQuestionHandler(services.NewProductQuestionService(db))
+	// Viewer, recent purchase and low stock hints are pushed to shoppers on
+	// the product channels as they change
+	productSignalsHandler := handlers.NewProductSignalsHandler(services.NewProductSignalsService(db, services.ProductSignalsConfigFromEnv()))
+	productSignalsHandler.SchedulePushing(context.Background())
+	cartService := services.NewShoppingCartService(db).WithPricingRules(pricingRuleService).WithEventStream(eventStream)
 	cartHandler := handlers.NewCartHandler(cartService)
-	userService := services.NewUserService(db)
-	userHandler := handlers.NewUserHandler(userService, os.Getenv("JWT_SECRET"))
-	orderService := services.NewOrderService(db)
-	orderHandler := handlers.NewOrderHandler(orderService)
+	loginSecurityService := services.NewLoginSecurityService(db)
+	userService := services.NewUserService(db).WithLoginSecurity(loginSecurityService)
+	refreshTokenService := services.NewRefreshTokenService(db)
+	userHandler := handlers.NewUserHandler(userService, refreshTokenService, os.Getenv("JWT_SECRET"))
+	loginSecurityHandler := handlers.NewLoginSecurityHandler(loginSecurityService)
+	// Paid orders are pushed to the ERP, retrying failed exports
+	orderExportService := services.NewOrderExportService(db, services.OrderExportConfigFromEnv())
+	orderExportHandler := handlers.NewOrderExportHandler(orderExportService)
+	orderService := services.NewOrderService(db).WithPricingRules(pricingRuleService).WithOrderExports(orderExportService).WithEventStream(eventStream).WithFlashSales(flashSaleService)
 	paymentService := services.NewPaymentService()
-	paymentHandler := handlers.NewPaymentHandler(paymentService, orderService)
-	chatService := services.NewChatService(db, productService, cartService)
-	chatHandler := handlers.NewChatHandler(chatService)
+	// Rank chat suggestions by meaning when an embeddings provider and
+	// pgvector are available
+	embeddingConfig := services.EmbeddingConfigFromEnv()
+	embeddingService := services.NewProductEmbeddingService(db, services.EmbeddingProviderFromConfig(embeddingConfig), embeddingConfig)
+	embeddingService.ScheduleIndexing(context.Background())
+	// Check shoppers' messages and the assistant's replies against the content policy
+	moderationConfig := services.ModerationConfigFromEnv()
+	// Storefront clickstream, stitched to chat sessions, for recommendations
+	// and chat experiment reports
+	clickstreamService := services.NewClickstreamService(db, services.ClickstreamConfigFromEnv())
+	chatService := services.NewChatService(db, productService, cartService).
+		WithEmbeddings(embeddingService).
+		WithCheckout(orderService, paymentService).
+		WithModeration(services.ModeratorFromConfig(moderationConfig), moderationConfig).
+		WithLimits(services.NewChatLimiter(db, services.ChatLimitConfigFromEnv())).
+		WithEventStream(eventStream).
+		WithClickstream(clickstreamService)
+	maintenanceService := services.NewMaintenanceService(db)
+	chatSchemas, err := wsproto.NewSchemaRegistry()
+	if err != nil {
+		log.Fatal("Failed to load WebSocket message schemas:", err)
+	}
+	chatHandler := handlers.NewChatHandler(chatService).WithMaintenance(maintenanceService).WithClickstream(clickstreamService).
+		WithSchemaValidation(chatSchemas).
+		WithReplayGuard(wsproto.NewReplayGuard(wsproto.DefaultReplayWindow, nil, false))
+	clickstreamHandler := handlers.NewClickstreamHandler(clickstreamService, chatService)
+	maintenanceHandler := handlers.NewMaintenanceHandler(maintenanceService, chatHandler)
+	// Checkouts accepted through the order pipeline are worked on in the
+	// background, with progress pushed over the chat socket
+	orderPipeline := services.NewOrderPipeline(db, orderService, paymentService, services.OrderPipelineConfigFromEnv()).WithNotifier(chatHandler)
+	orderPipeline.Start(context.Background())
+	orderHandler := handlers.NewOrderHandler(orderService, chatHandler).WithPipeline(orderPipeline)
+	dunningService := services.NewDunningService(db, paymentService, chatHandler, services.DunningConfigFromEnv())
+	paymentHandler := handlers.NewPaymentHandler(paymentService, orderService, dunningService)
+	expressCheckoutHandler := handlers.NewExpressCheckoutHandler(services.NewExpressCheckoutService(db, cartService, orderService, paymentService, services.ApplePayConfigFromEnv()), orderHandler)
+	// Journals of the payment ledger for QuickBooks or Xero, exported daily when configured
+	accountingExportService := services.NewAccountingExportService(db, services.AccountingExportConfigFromEnv())
+	financeHandler := handlers.NewFinanceHandler(services.NewFinanceService(db)).WithAccountingExports(accountingExportService)
 	adminProductService := services.NewAdminProductService(db)
 	inventoryService := services.NewInventoryService(db)
+	inventoryReportHandler := handlers.NewInventoryReportHandler(inventoryService)
+	catalogLintHandler := handlers.NewCatalogLintHandler(services.NewCatalogLintService(db, services.CatalogLintConfigFromEnv()))
 	alertService := services.NewAlertService(db)
-	adminHandler := handlers.NewAdminHandler(adminProductService, productService)
+	// Long admin operations run as background jobs that report progress to
+	// the admin's chat socket; jobs cut short by a restart are failed
+	jobService := services.NewJobService(db, services.JobConfigFromEnv()).WithNotifier(chatHandler)
+	if interrupted, err := jobService.FailInterrupted(context.Background(), time.Now()); err != nil {
+		log.Printf("Failed to clean up interrupted jobs: %v", err)
+	} else if interrupted > 0 {
+		log.Printf("Marked %d interrupted background jobs as failed", interrupted)
+	}
+	jobHandler := handlers.NewJobHandler(jobService)
+	// Users deleting their chat history have it purged in the background
+	chatHandler.WithJobs(jobService)
+	// Admins editing the same product or stock level see each other's locks
+	// and saves on the admin channel
+	adminLiveHandler := handlers.NewAdminLiveHandler(services.NewAdminPresence(services.AdminPresenceConfigFromEnv())).WithAllowedOrigins(allowedOrigins)
+	adminHandler := handlers.NewAdminHandler(adminProductService, productService).WithJobs(jobService).WithLiveEdits(adminLiveHandler)
+	// Shoppers asking for a person, or getting frustrated, are handed to an
+	// admin alerted on the admin channel
+	chatService.WithHandoff(adminLiveHandler, chatHandler)
+	chatHandoffHandler := handlers.NewChatHandoffHandler(chatService)
+	adminUserHandler := handlers.NewAdminUserHandler(services.NewAdminUserService(db))
+	consentHandler := handlers.NewConsentHandler(services.NewConsentService(db))
+	llmSettingsHandler := handlers.NewLLMSettingsHandler(services.NewLLMSettingsService(db))
+	promptTemplateHandler := handlers.NewPromptTemplateHandler(services.NewPromptTemplateService(db))
+	chatAnalyticsHandler := handlers.NewChatAnalyticsHandler(services.NewChatAnalyticsService(db))
+	adminAssistantHandler := handlers.NewAdminAssistantHandler(services.NewAdminAssistantService(db, services.AdminAssistantConfigFromEnv()))
+	productLifecycleService := services.NewProductLifecycleService(db)
+	productLifecycleHandler := handlers.NewProductLifecycleHandler(productLifecycleService)
+	segmentService := services.NewSegmentService(db)
+	segmentHandler := handlers.NewSegmentHandler(segmentService)
+	quoteHandler := handlers.NewQuoteHandler(services.NewQuoteService(db))
+	customerGroupHandler := handlers.NewCustomerGroupHandler(services.NewCustomerGroupService(db))
+	orderRuleHandler := handlers.NewOrderRuleHandler(services.NewOrderValidator(db))
+
+	// Publish and unpublish scheduled products in the background
+	productLifecycleService.ScheduleTransitions(context.Background(), services.ProductSchedulerIntervalFromEnv())
+
+	// Re-evaluate customer segment membership every night
+	segmentService.ScheduleNightlyEvaluation(context.Background(), services.SegmentEvaluationHourFromEnv())
+
+	// Expire lapsed cart reservations and warn shoppers over the chat socket
+	services.NewCartReservationService(db, services.CartReservationConfigFromEnv()).ScheduleSweeps(context.Background(), chatHandler)
+
+	// Retry failed payments and cancel orders that stay unpaid
+	dunningService.ScheduleRetries(context.Background())
+	orderService.ScheduleOfflinePaymentExpiry(context.Background())
+
+	// Export paid orders to the ERP and retry the ones that failed
+	orderExportService.ScheduleExports(context.Background())
+
+	// Export the previous day's ledger journals for the accounting package
+	accountingExportService.ScheduleDailyExports(context.Background())
+
+	// Forecast product demand every night for reorder suggestions
+	forecastService := services.NewInventoryForecastService(db, services.ForecastConfigFromEnv())
+	forecastService.ScheduleForecasts(context.Background())
+
+	// Send scheduled promotional broadcasts to open chat sessions
+	campaignService := services.NewCampaignService(db, services.CampaignConfigFromEnv())
+	campaignService.ScheduleDispatch(context.Background(), chatHandler)
+	campaignHandler := handlers.NewCampaignHandler(campaignService, chatHandler).WithJobs(jobService)
+	hoursService := services.NewBusinessHoursService(db, services.StoreLocationFromEnv())
+	businessHoursHandler := handlers.NewBusinessHoursHandler(hoursService)
+	deliveryHandler := handlers.NewDeliveryHandler(services.NewDeliveryScheduleService(hoursService, services.DeliveryConfigFromEnv()))
+	localeService := services.NewStoreLocaleService(db, services.LocaleConfigFromEnv())
+	localeHandler := handlers.NewLocaleHandler(localeService)
+	apiUsageService := services.NewAPIUsageService(db, services.APIUsageConfigFromEnv())
+	apiUsageService.ScheduleFlushes(context.Background())
+	apiUsageHandler := handlers.NewAPIUsageHandler(apiUsageService)
+	networkPolicyService := services.NewNetworkPolicyService(db)
+	adminPolicy, err := services.NetworkPolicyFromEnv(services.NetworkPolicyAdmin, "ADMIN")
+	if err != nil {
+		log.Fatal("Invalid admin network policy:", err)
+	}
+	webhookPolicy, err := services.NetworkPolicyFromEnv(services.NetworkPolicyWebhook, "WEBHOOK")
+	if err != nil {
+		log.Fatal("Invalid webhook network policy:", err)
+	}
+	// Only trust X-Forwarded-For from these proxies, as the network
+	// policies go by the client address
+	if err := middleware.TrustProxies(r, os.Getenv("TRUSTED_PROXIES"), adminPolicy, webhookPolicy); err != nil {
+		log.Fatal("Invalid TRUSTED_PROXIES:", err)
+	}
+	networkPolicyHandler := handlers.NewNetworkPolicyHandler(networkPolicyService, adminPolicy, webhookPolicy)
+
+	// Keep product, category and popular query suggestions in memory for type-ahead
+	autocompleteIndex := services.NewAutocompleteIndex(db)
+	autocompleteIndex.ScheduleRefresh(context.Background(), services.AutocompleteRefreshIntervalFromEnv())
+	autocompleteHandler := handlers.NewAutocompleteHandler(autocompleteIndex)
+	availabilityHandler := handlers.NewAvailabilityHandler(availabilityService)
 
 	// Initialize search service
 	searchService := search.NewService(db)
 
+	// Count requests per route and consumer for /admin/api-usage
+	r.Use(middleware.APIUsageMiddleware(apiUsageService))
+
+	// Tell clients how many requests they have left this minute
+	r.Use(middleware.RateLimitHeadersMiddleware(services.APIRateThrottleFromEnv()))
+
+	// During maintenance only reads are served, apart from admins (who end
+	// it), sign-ins and payment provider webhooks
+	r.Use(middleware.MaintenanceMiddleware(maintenanceService,
+		"/api/v1/admin", "/api/v1/auth/login", "/api/v1/auth/refresh", "/api/v1/auth/logout", "/api/v1/payments/webhook"))
+
 	// API v1 routes
 	v1 := r.Group("/api/v1")
 	{
@@ -92,14 +279,22 @@ func main() {
 		{
 			// Product routes (public)
 			products := public.Group("products")
+			products.Use(middleware.OptionalAuthMiddleware(refreshTokenService)) // prices depend on the customer group
+			products.Use(middleware.LocaleMiddleware(localeService))
 			{
 				products.GET("/", productHandler.GetProducts)
 				products.HEAD("/", productHandler.GetProducts) // Support HEAD requests for CORS
 				products.GET("/:id", productHandler.GetProductByID)
 				products.GET("/sku/:sku", productHandler.GetProductBySKU)
 				products.GET("/search", productHandler.SearchProducts)
+				products.GET("/autocomplete", autocompleteHandler.Autocomplete)
+				products.GET("/availability", availabilityHandler.GetAvailability)
 				products.GET("/featured", productHandler.GetFeaturedProducts)
 				products.GET("/:id/related", productHandler.GetRelatedProducts)
+				products.GET("/:id/questions", productQuestionHandler.GetProductQuestions)
+				products.POST("/:id/questions", productQuestionHandler.AskQuestion)
+				products.GET("/:id/signals", productSignalsHandler.GetSignals)
+				products.GET("/ws", productSignalsHandler.HandleWebSocket)
 			}
 
 			// Category routes (public)
@@ -108,30 +303,75 @@ func main() {
 				categories.GET("/", productHandler.GetCategories)
 				categories.HEAD("/", productHandler.GetCategories) // Support HEAD requests for CORS
 				categories.GET("/:id", productHandler.GetCategoryByID)
+				categories.GET("/:id/facets", productHandler.GetCategoryFacets)
 				categories.GET("/slug/:slug", productHandler.GetCategoryBySlug)
 			}
 
+			// Brand routes (public)
+			brands := public.Group("brands")
+			{
+				brands.GET("/", brandHandler.GetBrands)
+				brands.GET("/:slug", brandHandler.GetBrandBySlug)
+				brands.GET("/:slug/products", brandHandler.GetBrandProducts)
+			}
+
 			// Auth routes (public)
 			auth := public.Group("auth")
 			{
 				auth.POST("/register", userHandler.Register)
 				auth.POST("/login", userHandler.Login)
 				auth.POST("/refresh", userHandler.RefreshToken)
+				auth.POST("/logout", userHandler.Logout)
+				auth.POST("/reset-password", userHandler.ResetPassword)
+				auth.POST("/unlock-account", loginSecurityHandler.UnlockAccount)
 			}
 
 			// Chat routes (public)
 			chat := public.Group("chat")
+			chat.Use(middleware.OptionalAuthMiddleware(refreshTokenService)) // answers follow the customer's locale preferences
+			chat.Use(middleware.LocaleMiddleware(localeService))
 			{
 				chat.GET("/ws", chatHandler.HandleWebSocket)
 				chat.POST("/message", chatHandler.SendMessage)
+				chat.POST("/image", chatHandler.SendImageMessage)
+				chat.POST("/voice", chatHandler.SendVoiceMessage)
+				chat.GET("/voice/:session_id/replies/:message_id", chatHandler.GetVoiceReply)
+				chat.GET("/stream", chatHandler.StreamChatMessage)
 				chat.GET("/history/:session_id", chatHandler.GetChatHistory)
 				chat.GET("/suggestions", chatHandler.GetProductSuggestions)
 				chat.GET("/search", chatHandler.SearchProducts)
+				chat.POST("/session", chatHandler.StartChatSession)
 				chat.GET("/session/:session_id", chatHandler.GetChatSession)
+				chat.POST("/events", chatHandler.TrackEvent)
 			}
 
+			// Whether staff are around and when orders ship (public)
+			public.GET("store/availability", businessHoursHandler.GetAvailability)
+
+			// Country, currency and tax display the storefront defaults to (public)
+			public.GET("locale", middleware.OptionalAuthMiddleware(refreshTokenService), localeHandler.GetLocale)
+
+			// Batched storefront page views and suggestion impressions and clicks (public)
+			public.POST("events", middleware.OptionalAuthMiddleware(refreshTokenService), clickstreamHandler.IngestEvents)
+
+			// Planned maintenance for the storefront banner (public)
+			public.GET("maintenance", maintenanceHandler.GetStatus)
+
+			// Flash sale and its waiting room, polled until checkout opens (public)
+			public.GET("flash-sale", flashSaleHandler.GetStatus)
+			public.POST("flash-sale/queue", middleware.OptionalAuthMiddleware(refreshTokenService), flashSaleHandler.JoinWaitingRoom)
+			public.GET("flash-sale/queue/:token", flashSaleHandler.GetWaitingRoomTicket)
+
+			// Delivery dates offered at checkout (public)
+			public.GET("delivery-slots", deliveryHandler.GetSlots)
+
+			// Campaign link clicks (public - session-based)
+			public.POST("campaigns/:id/clicks", campaignHandler.RecordClick)
+
 			// Cart routes (public - session-based)
 			cart := public.Group("cart")
+			cart.Use(middleware.OptionalAuthMiddleware(refreshTokenService)) // prices depend on the customer group
+			cart.Use(middleware.LocaleMiddleware(localeService))
 			{
 				cart.GET("/", cartHandler.GetCart)
 				cart.HEAD("/", cartHandler.GetCart) // Support HEAD requests for CORS
@@ -141,18 +381,23 @@ func main() {
 				cart.DELETE("/clear", cartHandler.ClearCart)
 				cart.POST("/calculate", cartHandler.CalculateTotals)
 				cart.GET("/count", cartHandler.GetCartItemCount)
+				cart.PUT("/gift-options", cartHandler.SetGiftOptions)
+				cart.POST("/share", cartHandler.ShareCart)
+				cart.GET("/shared/:token", cartHandler.GetSharedCart)
+				cart.POST("/shared/:token/clone", cartHandler.CloneSharedCart)
 			}
 
 			// Payment webhook (public)
 			payments := public.Group("payments")
 			{
-				payments.POST("/webhook", paymentHandler.HandleWebhook)
+				payments.POST("/webhook", middleware.NetworkPolicyMiddleware(networkPolicyService, webhookPolicy), paymentHandler.HandleWebhook)
 			}
 		}
 
 		// Protected routes
 		protected := v1.Group("/")
 		protected.Use(middleware.AuthMiddleware())
+		protected.Use(middleware.RevocationMiddleware(refreshTokenService))
 		{
 			// User routes
 			users := protected.Group("user")
@@ -160,16 +405,42 @@ func main() {
 				users.GET("/profile", userHandler.GetProfile)
 				users.PUT("/profile", userHandler.UpdateProfile)
 				users.POST("/change-password", userHandler.ChangePassword)
+				users.POST("/logout-all", userHandler.LogoutEverywhere)
 				users.DELETE("/account", userHandler.DeleteAccount)
 				users.POST("/verify-email", userHandler.VerifyEmail)
+				users.GET("/offers", segmentHandler.GetUserOffers)
+				users.GET("/security-notifications", loginSecurityHandler.GetSecurityNotifications)
+				users.POST("/security-notifications/read", loginSecurityHandler.MarkSecurityNotificationsRead)
+				users.GET("/consents", consentHandler.GetConsents)
+				users.PUT("/consents", consentHandler.UpdateConsents)
+				users.GET("/consents/history", consentHandler.GetConsentHistory)
+				users.GET("/chat-sessions", chatHandler.ListUserChatSessions)
+				users.POST("/chat-sessions/:session_id/resume", chatHandler.ResumeUserChatSession)
+				users.GET("/chat-history/export", chatHandler.ExportUserChatHistory)
+				users.DELETE("/chat-history", chatHandler.DeleteUserChatHistory)
+				users.GET("/chat-history/deletions/:id", chatHandler.GetChatHistoryDeletion)
+			}
+
+			// B2B quotes
+			quotes := protected.Group("user/quotes")
+			{
+				quotes.POST("/", quoteHandler.CreateQuote)
+				quotes.GET("/", quoteHandler.GetUserQuotes)
+				quotes.GET("/:id", quoteHandler.GetUserQuote)
+				quotes.GET("/:id/pdf", quoteHandler.GetUserQuotePDF)
+				quotes.POST("/:id/accept", quoteHandler.AcceptQuote)
+				quotes.POST("/:id/convert", quoteHandler.ConvertQuote)
 			}
 
 			// Order routes
 			orders := protected.Group("orders")
 			{
 				orders.POST("/", orderHandler.CreateOrder)
+				orders.POST("/validate", orderHandler.ValidateOrder)
 				orders.GET("/:id", orderHandler.GetOrder)
 				orders.GET("/number/:number", orderHandler.GetOrderByNumber)
+				orders.POST("/submissions", orderHandler.SubmitOrder)
+				orders.GET("/submissions/:id", orderHandler.GetOrderSubmission)
 				orders.GET("/", orderHandler.GetUserOrders)
 				orders.GET("/:id/summary", orderHandler.GetOrderSummary)
 				orders.DELETE("/:id", orderHandler.CancelOrder)
@@ -184,12 +455,16 @@ func main() {
 				payments.POST("/:payment_intent_id/cancel", paymentHandler.CancelPayment)
 				payments.POST("/:payment_intent_id/refund", paymentHandler.RefundPayment)
 				payments.GET("/methods", paymentHandler.GetPaymentMethods)
+				payments.POST("/express/apple-pay/validate-merchant", expressCheckoutHandler.ValidateApplePayMerchant)
+				payments.POST("/express/checkout", expressCheckoutHandler.Checkout)
 			}
 		}
 
 		// Admin routes
 		admin := v1.Group("/admin")
+		admin.Use(middleware.NetworkPolicyMiddleware(networkPolicyService, adminPolicy))
 		admin.Use(middleware.AuthMiddleware())
+		admin.Use(middleware.RevocationMiddleware(refreshTokenService))
 		admin.Use(middleware.AdminMiddleware())
 		{
 			// Product management
@@ -199,10 +474,84 @@ func main() {
 				products.GET("/", adminHandler.GetProducts)
 				products.GET("/:id", adminHandler.GetProductWithDetails)
 				products.PUT("/:id", adminHandler.UpdateProduct)
+				products.PATCH("/:id", adminHandler.PatchProduct)
 				products.DELETE("/:id", adminHandler.DeleteProduct)
 				products.POST("/bulk-import", adminHandler.BulkImportProducts)
+				products.POST("/bulk-price", adminHandler.BulkUpdatePrices)
 				products.GET("/export", adminHandler.ExportProducts)
+				products.GET("/variants/export", adminHandler.ExportVariants)
+				products.POST("/variants/import", adminHandler.ImportVariants)
 				products.GET("/stats", adminHandler.GetProductStats)
+				products.GET("/scheduled", productLifecycleHandler.GetScheduledProducts)
+				products.POST("/scheduled/run", productLifecycleHandler.RunSchedule)
+				products.PUT("/:id/schedule", productLifecycleHandler.SetSchedule)
+				products.GET("/:id/price-history", adminHandler.GetPriceHistory)
+				products.GET("/:id/revisions", adminHandler.GetProductRevisions)
+				products.GET("/:id/revisions/:version", adminHandler.GetProductRevision)
+				products.POST("/:id/revisions/:version/rollback", adminHandler.RollbackProduct)
+			}
+
+			// Catalog data quality: missing images, stock records and default
+			// variants, orphaned categories and malformed SKUs
+			admin.GET("/catalog/lint", catalogLintHandler.GetCatalogLint)
+
+			// Review of product edits by admins who can't publish directly
+			productChanges := admin.Group("product-changes")
+			{
+				productChanges.GET("/", adminHandler.GetProductChanges)
+				productChanges.GET("/:id", adminHandler.GetProductChange)
+				productChanges.POST("/:id/submit", adminHandler.SubmitProductChange)
+				productChanges.POST("/:id/approve", adminHandler.ApproveProductChange)
+				productChanges.POST("/:id/reject", adminHandler.RejectProductChange)
+			}
+
+			// Customer account management
+			adminUsers := admin.Group("users")
+			{
+				adminUsers.GET("/", adminUserHandler.GetUsers)
+				adminUsers.GET("/export", adminUserHandler.ExportUsers)
+				adminUsers.POST("/suspend", adminUserHandler.SuspendUsers)
+				adminUsers.POST("/reactivate", adminUserHandler.ReactivateUsers)
+				adminUsers.POST("/force-password-reset", adminUserHandler.ForcePasswordReset)
+				adminUsers.GET("/:id/consents", consentHandler.GetUserConsents)
+			}
+
+			// Product Q&A moderation
+			questions := admin.Group("questions")
+			{
+				questions.GET("/", productQuestionHandler.GetQuestions)
+				questions.POST("/:id/answer", productQuestionHandler.AnswerQuestion)
+				questions.POST("/:id/suggest", productQuestionHandler.SuggestAnswer)
+				questions.POST("/:id/approve", productQuestionHandler.ApproveAnswer)
+				questions.POST("/:id/reject", productQuestionHandler.RejectQuestion)
+				questions.DELETE("/:id", productQuestionHandler.DeleteQuestion)
+			}
+
+			// Search merchandising: synonyms, spelling correction, zero-result queries and ranking
+			adminSearch := admin.Group("search")
+			{
+				adminSearch.GET("/synonyms", synonymHandler.GetSynonyms)
+				adminSearch.POST("/synonyms", synonymHandler.CreateSynonym)
+				adminSearch.PUT("/synonyms/:id", synonymHandler.UpdateSynonym)
+				adminSearch.DELETE("/synonyms/:id", synonymHandler.DeleteSynonym)
+				adminSearch.GET("/rewrite", synonymHandler.PreviewRewrite)
+				adminSearch.GET("/misses", searchMissHandler.GetMissReport)
+				adminSearch.GET("/misses/export", searchMissHandler.ExportMissReport)
+				adminSearch.GET("/boosts", searchRankingHandler.GetBoosts)
+				adminSearch.PUT("/boosts", searchRankingHandler.SetBoost)
+				adminSearch.DELETE("/boosts/:id", searchRankingHandler.DeleteBoost)
+				adminSearch.GET("/pins", searchRankingHandler.GetPins)
+				adminSearch.POST("/pins", searchRankingHandler.PinProduct)
+				adminSearch.DELETE("/pins/:id", searchRankingHandler.UnpinProduct)
+			}
+
+			// Brand management
+			adminBrands := admin.Group("brands")
+			{
+				adminBrands.GET("/", brandHandler.GetAdminBrands)
+				adminBrands.POST("/", brandHandler.CreateBrand)
+				adminBrands.PUT("/:id", brandHandler.UpdateBrand)
+				adminBrands.DELETE("/:id", brandHandler.DeleteBrand)
 			}
 
 			// Category management
@@ -210,8 +559,96 @@ func main() {
 			{
 				categories.GET("/", adminHandler.GetCategories)
 				categories.POST("/", adminHandler.CreateCategory)
+				categories.GET("/export", adminHandler.ExportCategories)
+				categories.POST("/import", adminHandler.ImportCategories)
 				categories.PUT("/:id", adminHandler.UpdateCategory)
 				categories.DELETE("/:id", adminHandler.DeleteCategory)
+				categories.POST("/:id/merge", adminHandler.MergeCategory)
+				categories.POST("/:id/move", adminHandler.MoveCategory)
+				categories.GET("/:id/metadata-schema", adminHandler.GetCategoryMetadataSchema)
+				categories.PUT("/:id/metadata-schema", adminHandler.UpdateCategoryMetadataSchema)
+			}
+
+			// Customer segments
+			segments := admin.Group("segments")
+			{
+				segments.GET("/", segmentHandler.GetSegments)
+				segments.POST("/evaluate", segmentHandler.EvaluateSegments)
+				segments.PUT("/:slug", segmentHandler.UpdateSegment)
+				segments.DELETE("/:slug", segmentHandler.DeleteSegment)
+				segments.GET("/:slug/members", segmentHandler.GetSegmentMembers)
+			}
+
+			// Customer groups and tiered pricing
+			customerGroups := admin.Group("customer-groups")
+			{
+				customerGroups.GET("/", customerGroupHandler.GetGroups)
+				customerGroups.PUT("/:slug", customerGroupHandler.UpdateGroup)
+				customerGroups.DELETE("/:slug", customerGroupHandler.DeleteGroup)
+				customerGroups.GET("/:slug/prices", customerGroupHandler.GetGroupPrices)
+				customerGroups.PUT("/:slug/prices", customerGroupHandler.SetGroupPrices)
+				customerGroups.DELETE("/:slug/prices/:product_id", customerGroupHandler.DeleteGroupPrice)
+				customerGroups.PUT("/:slug/members/:user_id", customerGroupHandler.AddMember)
+				customerGroups.DELETE("/:slug/members/:user_id", customerGroupHandler.RemoveMember)
+			}
+
+			// Pricing rules applied on top of customer group prices
+			pricingRules := admin.Group("pricing-rules")
+			{
+				pricingRules.GET("/", pricingRuleHandler.GetRules)
+				pricingRules.POST("/", pricingRuleHandler.CreateRule)
+				pricingRules.GET("/:id", pricingRuleHandler.GetRule)
+				pricingRules.PUT("/:id", pricingRuleHandler.UpdateRule)
+				pricingRules.DELETE("/:id", pricingRuleHandler.DeleteRule)
+			}
+
+			// Order fulfillments, packing slips, offline payments, totals audits, pricing simulations and ERP exports
+			adminOrders := admin.Group("orders")
+			{
+				adminOrders.PUT("/:id/fulfillments/:fulfillment_id", orderHandler.UpdateFulfillment)
+				adminOrders.GET("/:id/fulfillments/:fulfillment_id/packing-slip", orderHandler.GetPackingSlip)
+				adminOrders.POST("/:id/mark-paid", orderHandler.MarkOrderPaid)
+				adminOrders.POST("/:id/recalculate", orderHandler.RecalculateOrder)
+				adminOrders.POST("/simulate", orderHandler.SimulateOrder)
+				adminOrders.GET("/:id/export", orderExportHandler.GetOrderExport)
+				adminOrders.POST("/:id/export", orderExportHandler.ReexportOrder)
+			}
+			admin.GET("/order-exports", orderExportHandler.GetExports)
+
+			// Warehouse pick lists for the shipments ready to go
+			admin.GET("/warehouse/pick-list", orderHandler.GetPickList)
+
+			// Settlement reporting and payout reconciliation
+			finance := admin.Group("finance")
+			{
+				finance.GET("/settlements", financeHandler.GetSettlements)
+				finance.GET("/settlements/export", financeHandler.ExportSettlements)
+				finance.GET("/reconciliations", financeHandler.GetReconciliations)
+				finance.POST("/reconciliations", financeHandler.CreateReconciliation)
+				finance.GET("/reconciliations/:id", financeHandler.GetReconciliation)
+				finance.GET("/accounting-exports", financeHandler.GetAccountingExports)
+				finance.POST("/accounting-exports", financeHandler.CreateAccountingExport)
+				finance.GET("/accounting-exports/:id/download", financeHandler.DownloadAccountingExport)
+			}
+
+			// Order validation rules
+			orderRules := admin.Group("order-rules")
+			{
+				orderRules.GET("/", orderRuleHandler.GetRules)
+				orderRules.POST("/", orderRuleHandler.CreateRule)
+				orderRules.PUT("/:id", orderRuleHandler.UpdateRule)
+				orderRules.DELETE("/:id", orderRuleHandler.DeleteRule)
+			}
+
+			// B2B quote approval
+			quotes := admin.Group("quotes")
+			{
+				quotes.GET("/", quoteHandler.GetQuotes)
+				quotes.GET("/:id", quoteHandler.GetQuote)
+				quotes.GET("/:id/pdf", quoteHandler.GetQuotePDF)
+				quotes.PUT("/:id", quoteHandler.UpdateQuote)
+				quotes.POST("/:id/approve", quoteHandler.ApproveQuote)
+				quotes.POST("/:id/reject", quoteHandler.RejectQuote)
 			}
 
 			// Inventory management
@@ -226,7 +663,7 @@ func main() {
 						}
 					}
 
-					levels, err := inventoryService.GetInventoryLevels(productID, nil)
+					levels, err := inventoryService.GetInventoryLevels(c.Request.Context(), productID, nil)
 					if err != nil {
 						c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 						return
@@ -242,16 +679,28 @@ func main() {
 						return
 					}
 
-					if err := inventoryService.UpdateInventory(req); err != nil {
+					// Only "set" can replace another admin's change; "add" and
+					// "subtract" apply on top of it
+					var before *time.Time
+					if req.Operation == "set" {
+						before, _ = inventoryService.InventoryUpdatedAt(c.Request.Context(), req.ProductID, req.VariantID)
+					}
+
+					if err := inventoryService.UpdateInventory(c.Request.Context(), req); err != nil {
 						c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 						return
 					}
 
-					c.JSON(http.StatusOK, gin.H{"success": true, "message": "Inventory updated successfully"})
+					body := gin.H{"success": true, "message": "Inventory updated successfully"}
+					resource := services.EditResource{Kind: services.EditResourceInventory, ID: req.ProductID, VariantID: req.VariantID}
+					if conflict := adminLiveHandler.RecordSave(c, resource, req.ExpectedUpdatedAt, before); conflict != nil {
+						body["conflict"] = conflict
+					}
+					c.JSON(http.StatusOK, body)
 				})
 
 				inventory.GET("/report", func(c *gin.Context) {
-					report, err := inventoryService.GetInventoryReport()
+					report, err := inventoryService.GetInventoryReport(c.Request.Context())
 					if err != nil {
 						c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 						return
@@ -259,31 +708,148 @@ func main() {
 
 					c.JSON(http.StatusOK, gin.H{"success": true, "data": report})
 				})
+
+				inventory.GET("/dead-stock", inventoryReportHandler.GetDeadStock)
+				inventory.GET("/dead-stock/export", inventoryReportHandler.ExportDeadStock)
+
+				inventory.GET("/forecasts", func(c *gin.Context) {
+					forecasts, err := forecastService.ListForecasts(c.Request.Context(), c.Query("reorder") == "true")
+					if err != nil {
+						c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+						return
+					}
+
+					c.JSON(http.StatusOK, gin.H{"success": true, "data": forecasts})
+				})
+
+				inventory.POST("/forecasts/run", func(c *gin.Context) {
+					result, err := forecastService.RunForecasts(c.Request.Context(), time.Now())
+					if err != nil {
+						c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+						return
+					}
+
+					c.JSON(http.StatusOK, gin.H{"success": true, "data": result})
+				})
+			}
+
+			// Chat assistant model settings
+			llmSettings := admin.Group("llm-settings")
+			{
+				llmSettings.GET("/", llmSettingsHandler.GetSettings)
+				llmSettings.PUT("/:variant", llmSettingsHandler.UpdateSettings)
+				llmSettings.DELETE("/:variant", llmSettingsHandler.DeleteSettings)
+			}
+
+			chatSessions := admin.Group("chat-sessions")
+			{
+				chatSessions.GET("/:session_id/llm", llmSettingsHandler.GetSessionConfig)
+				chatSessions.PUT("/:session_id/llm", llmSettingsHandler.SetSessionOverride)
+				chatSessions.DELETE("/:session_id/llm", llmSettingsHandler.ClearSessionOverride)
+			}
+
+			// The chat assistant's persona, tone, banned topics and action instructions
+			chatPrompts := admin.Group("chat/prompts")
+			{
+				chatPrompts.GET("/", promptTemplateHandler.GetTemplates)
+				chatPrompts.POST("/", promptTemplateHandler.CreateTemplate)
+				chatPrompts.GET("/:id", promptTemplateHandler.GetTemplate)
+				chatPrompts.PUT("/:id", promptTemplateHandler.UpdateTemplate)
+				chatPrompts.DELETE("/:id", promptTemplateHandler.DeleteTemplate)
+			}
+
+			// Live agents taking over chats handed to a person
+			admin.GET("/chat/handoffs", chatHandoffHandler.GetHandoffs)
+			chatAgent := admin.Group("chat/sessions/:session_id")
+			{
+				chatAgent.GET("/messages", chatHandoffHandler.GetSessionMessages)
+				chatAgent.POST("/messages", chatHandoffHandler.SendAgentMessage)
+				chatAgent.POST("/join", chatHandoffHandler.JoinSession)
+				chatAgent.POST("/release", chatHandoffHandler.ReleaseSession)
+			}
+
+			admin.GET("/chat-analytics/routing", chatAnalyticsHandler.GetModelRouting)
+			admin.GET("/analytics/chat/funnel", chatAnalyticsHandler.GetFunnel)
+			admin.GET("/analytics/chat/unanswered", chatAnalyticsHandler.GetUnansweredQueries)
+			admin.GET("/analytics/experiments", clickstreamHandler.GetExperimentReport)
+
+			// The analytics event stream's counts and Avro schema
+			admin.GET("/event-stream", eventStreamHandler.GetStatus)
+
+			// Which social proof and scarcity hints shoppers see
+			admin.GET("/product-signals/settings", productSignalsHandler.GetSettings)
+			admin.PUT("/product-signals/settings", productSignalsHandler.UpdateSettings)
+
+			// API traffic per route and consumer
+			admin.GET("/api-usage", apiUsageHandler.GetUsage)
+			admin.GET("/network-policy", networkPolicyHandler.GetNetworkPolicy)
+
+			// Presence, edit locks and conflicting saves of admins editing the catalog
+			admin.GET("/ws", adminLiveHandler.HandleWebSocket)
+
+			// Maintenance mode: writes get 503s once it starts
+			admin.GET("/maintenance", maintenanceHandler.GetStatus)
+			admin.PUT("/maintenance", maintenanceHandler.PlanMaintenance)
+			admin.DELETE("/maintenance", maintenanceHandler.EndMaintenance)
+
+			// Flash sales: warm caches, waiting room and per-shopper limits
+			admin.GET("/flash-sale", flashSaleHandler.GetStatus)
+			admin.PUT("/flash-sale", flashSaleHandler.PlanFlashSale)
+			admin.DELETE("/flash-sale", flashSaleHandler.EndFlashSale)
+
+			// Staff chat assistant for analytics and inventory, with an audit log
+			assistant := admin.Group("assistant")
+			{
+				assistant.POST("/messages", adminAssistantHandler.SendMessage)
+				assistant.POST("/actions/:id/confirm", adminAssistantHandler.ConfirmAction)
+				assistant.GET("/actions", adminAssistantHandler.GetActions)
+			}
+
+			// Business hours, and chat messages asking for a person while closed
+			admin.GET("/store-hours", businessHoursHandler.GetHours)
+			admin.PUT("/store-hours", businessHoursHandler.SetHours)
+			escalations := admin.Group("escalations")
+			{
+				escalations.GET("/", businessHoursHandler.GetEscalations)
+				escalations.POST("/:id/resolve", businessHoursHandler.ResolveEscalation)
+			}
+
+			// Scheduled promotional broadcasts to shoppers' chat sessions
+			campaigns := admin.Group("campaigns")
+			{
+				campaigns.GET("/", campaignHandler.GetCampaigns)
+				campaigns.POST("/", campaignHandler.CreateCampaign)
+				campaigns.POST("/preview", campaignHandler.PreviewCampaign)
+				campaigns.GET("/:id", campaignHandler.GetCampaign)
+				campaigns.POST("/:id/cancel", campaignHandler.CancelCampaign)
+				campaigns.POST("/:id/send", campaignHandler.SendCampaign)
+			}
+
+			// Background jobs: bulk imports, exports, price updates and campaign sends
+			jobs := admin.Group("jobs")
+			{
+				jobs.GET("/", jobHandler.GetJobs)
+				jobs.GET("/:id", jobHandler.GetJob)
+				jobs.GET("/:id/artifact", jobHandler.DownloadArtifact)
 			}
 
 			// Alert management
 			alerts := admin.Group("alerts")
 			{
 				alerts.GET("/", func(c *gin.Context) {
-					isReadStr := c.Query("is_read")
-					var isRead *bool
-					if isReadStr != "" {
-						if isReadStr == "true" {
-							read := true
-							isRead = &read
-						} else if isReadStr == "false" {
-							read := false
-							isRead = &read
-						}
+					list, err := listquery.Parse(services.InventoryAlertListSchema, c.Request.URL.Query())
+					if err != nil {
+						c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid list parameters", "details": err})
+						return
 					}
 
-					alerts, err := inventoryService.GetInventoryAlerts(isRead)
+					alerts, total, err := inventoryService.GetInventoryAlerts(c.Request.Context(), list)
 					if err != nil {
 						c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 						return
 					}
 
-					c.JSON(http.StatusOK, gin.H{"success": true, "data": alerts})
+					c.JSON(http.StatusOK, gin.H{"success": true, "data": alerts, "meta": list.PageInfo(total)})
 				})
 
 				alerts.POST("/mark-read", func(c *gin.Context) {
@@ -329,6 +895,21 @@ func main() {
 		port = "8080"
 	}
 
+	// Serve TLS directly when given a certificate. Client certificates are
+	// requested but only verified by the network policies that need them.
+	if certFile, keyFile := os.Getenv("TLS_CERT_FILE"), os.Getenv("TLS_KEY_FILE"); certFile != "" && keyFile != "" {
+		server := &http.Server{
+			Addr:      ":" + port,
+			Handler:   r,
+			TLSConfig: &tls.Config{ClientAuth: tls.RequestClientCert},
+		}
+		log.Printf("Server starting with TLS on port %s", port)
+		if err := server.ListenAndServeTLS(certFile, keyFile); err != nil {
+			log.Fatal("Failed to start server:", err)
+		}
+		return
+	}
+
 	log.Printf("Server starting on port %s", port)
 	if err := r.Run(":" + port); err != nil {
 		log.Fatal("Failed to start server:", err)
@@ -0,0 +1,53 @@
+// Command pii-rekey re-encrypts the personal data columns with the current
+// key in PII_ENCRYPTION_KEYS. Run it after turning encryption on, and after
+// adding a new current key, before dropping the old one.
+package main
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/pkg/database"
+	"chat-ecommerce-backend/pkg/encryption"
+	"context"
+	"flag"
+	"log"
+
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	batchSize := flag.Int("batch", 500, "rows read per batch")
+	flag.Parse()
+
+	// Load environment variables from .env file if there is one
+	_ = godotenv.Load()
+
+	keyring, err := encryption.KeyringFromEnv()
+	if err != nil {
+		log.Fatal("Invalid PII_ENCRYPTION_KEYS:", err)
+	}
+	if keyring == nil {
+		log.Fatal("PII_ENCRYPTION_KEYS is not set")
+	}
+	models.SetPIIKeyring(keyring)
+
+	db, err := database.ConnectDatabase()
+	if err != nil {
+		log.Fatal("Failed to connect to database:", err)
+	}
+	defer database.CloseDatabase()
+
+	// Encrypted values are text, so the columns must be migrated first
+	if err := database.MigrateDatabase(db); err != nil {
+		log.Fatal("Failed to run migrations:", err)
+	}
+
+	results, err := services.NewPIIRekeyService(db, *batchSize).Rekey(context.Background())
+	for _, result := range results {
+		log.Printf("%s: %d rows checked, %d re-encrypted with key %q", result.Table, result.Scanned, result.Rekeyed, keyring.CurrentKeyID())
+	}
+	if err != nil {
+		log.Fatal("Failed to re-encrypt personal data:", err)
+	}
+	log.Println("Personal data is encrypted with the current key")
+}
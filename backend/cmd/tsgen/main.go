@@ -0,0 +1,48 @@
+// Command tsgen writes the TypeScript types of the chat WebSocket messages
+// and chat REST bodies, generated from the Go structs they are encoded from,
+// so the frontend's types follow the backend's.
+//
+//	go run ./cmd/tsgen          # rewrite the frontend's types
+//	go run ./cmd/tsgen -check   # fail when they are out of date
+package main
+
+import (
+	"bytes"
+	"chat-ecommerce-backend/internal/tsgen"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+func main() {
+	root := flag.String("root", ".", "directory of the backend's go.mod")
+	out := flag.String("out", "../frontend/src/types/generated/chat.ts", "TypeScript file to write")
+	check := flag.Bool("check", false, "fail when the file is out of date instead of writing it")
+	flag.Parse()
+
+	generated, err := tsgen.Generate(*root, tsgen.ChatContract)
+	if err != nil {
+		log.Fatalf("Failed to generate TypeScript types: %v", err)
+	}
+
+	if *check {
+		current, err := os.ReadFile(*out)
+		if err != nil {
+			log.Fatalf("Failed to read %s: %v", *out, err)
+		}
+		if !bytes.Equal(current, generated) {
+			log.Fatalf("%s is out of date, run go run ./cmd/tsgen", *out)
+		}
+		return
+	}
+
+	if err := os.MkdirAll(filepath.Dir(*out), 0o755); err != nil {
+		log.Fatalf("Failed to create %s: %v", filepath.Dir(*out), err)
+	}
+	if err := os.WriteFile(*out, generated, 0o644); err != nil {
+		log.Fatalf("Failed to write %s: %v", *out, err)
+	}
+	fmt.Printf("Wrote %s\n", *out)
+}
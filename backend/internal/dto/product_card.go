@@ -0,0 +1,31 @@
+package dto
+
+import (
+	"github.com/google/uuid"
+)
+
+// ProductCardDTO is the compact product sent in chat suggestions and
+// WebSocket messages, with only what a product card renders
+type ProductCardDTO struct {
+	ID           uuid.UUID          `json:"id"`
+	Name         string             `json:"name"`
+	Price        float64            `json:"price"`
+	ListPrice    *float64           `json:"list_price,omitempty"`
+	ImageURL     string             `json:"image_url,omitempty"`
+	CategoryName string             `json:"category_name,omitempty"`
+	InStock      bool               `json:"in_stock"`
+	Variants     []VariantOptionDTO `json:"variants,omitempty"`
+}
+
+// VariantOptionDTO summarizes a product's variants of one kind, e.g. Color: Red, Blue
+type VariantOptionDTO struct {
+	Name   string   `json:"name"`
+	Values []string `json:"values"`
+}
+
+// ProductSuggestionDTO is a chat product suggestion with its product card
+type ProductSuggestionDTO struct {
+	Product    ProductCardDTO `json:"product"`
+	Reason     string         `json:"reason"`
+	Confidence float64        `json:"confidence"`
+}
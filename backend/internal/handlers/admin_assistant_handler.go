@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"chat-ecommerce-backend/internal/services"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// AdminAssistantHandler handles the staff chat assistant
+type AdminAssistantHandler struct {
+	assistantService *services.AdminAssistantService
+}
+
+// NewAdminAssistantHandler creates a new AdminAssistantHandler
+func NewAdminAssistantHandler(assistantService *services.AdminAssistantService) *AdminAssistantHandler {
+	return &AdminAssistantHandler{
+		assistantService: assistantService,
+	}
+}
+
+// SendMessage handles POST /api/v1/admin/assistant/messages, e.g.
+// {"message": "how many orders today?"}
+func (h *AdminAssistantHandler) SendMessage(c *gin.Context) {
+	staff, ok := adminStaff(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Message string `json:"message" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	reply, err := h.assistantService.Ask(c.Request.Context(), staff, req.Message)
+	if err != nil {
+		c.JSON(assistantErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": reply})
+}
+
+// ConfirmAction handles POST /api/v1/admin/assistant/actions/:id/confirm
+func (h *AdminAssistantHandler) ConfirmAction(c *gin.Context) {
+	staff, ok := adminStaff(c)
+	if !ok {
+		return
+	}
+
+	actionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid action ID"})
+		return
+	}
+
+	reply, err := h.assistantService.Confirm(c.Request.Context(), staff, actionID)
+	if err != nil {
+		c.JSON(assistantErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": reply})
+}
+
+// GetActions handles GET /api/v1/admin/assistant/actions, the assistant's
+// audit log, with the list parameters of services.AssistantActionListSchema
+func (h *AdminAssistantHandler) GetActions(c *gin.Context) {
+	list, ok := listQuery(c, services.AssistantActionListSchema)
+	if !ok {
+		return
+	}
+
+	actions, total, err := h.assistantService.ListActions(c.Request.Context(), list)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": actions, "meta": list.PageInfo(total)})
+}
+
+// adminStaff identifies the signed-in admin, responding with 401 when the
+// request isn't authenticated
+func adminStaff(c *gin.Context) (services.AdminStaff, bool) {
+	userID := requestUserID(c)
+	if userID == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return services.AdminStaff{}, false
+	}
+	return services.AdminStaff{
+		ID:        *userID,
+		Email:     c.GetString("user_email"),
+		IPAddress: c.ClientIP(),
+	}, true
+}
+
+func assistantErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrAssistantNotAllowed), errors.Is(err, services.ErrAssistantToolDenied):
+		return http.StatusForbidden
+	case errors.Is(err, services.ErrAssistantActionNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrAssistantActionNotPending):
+		return http.StatusConflict
+	case errors.Is(err, services.ErrAssistantConfirmationExpired):
+		return http.StatusGone
+	default:
+		return http.StatusBadRequest
+	}
+}
@@ -2,8 +2,12 @@ package handlers
 
 import (
 	"chat-ecommerce-backend/internal/services"
+	"context"
+	"errors"
+	"io"
 	"net/http"
-	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -13,6 +17,8 @@ import (
 type AdminHandler struct {
 	adminProductService *services.AdminProductService
 	productService      *services.ProductService
+	jobs                *services.JobService
+	live                *AdminLiveHandler
 }
 
 // NewAdminHandler creates a new AdminHandler
@@ -23,6 +29,49 @@ func NewAdminHandler(adminProductService *services.AdminProductService, productS
 	}
 }
 
+// WithJobs lets bulk imports, exports and price updates run as background
+// jobs when asked with ?async=true
+func (h *AdminHandler) WithJobs(jobs *services.JobService) *AdminHandler {
+	h.jobs = jobs
+	return h
+}
+
+// WithLiveEdits tells the admin channel about product saves and warns when a
+// save replaced another admin's change
+func (h *AdminHandler) WithLiveEdits(live *AdminLiveHandler) *AdminHandler {
+	h.live = live
+	return h
+}
+
+// productVersion returns when a product last changed, for spotting a save
+// over another admin's change, or nil when no one is told about saves
+func (h *AdminHandler) productVersion(c *gin.Context, id uuid.UUID) *time.Time {
+	if h.live == nil {
+		return nil
+	}
+	updatedAt, err := h.adminProductService.ProductUpdatedAt(c.Request.Context(), id)
+	if err != nil {
+		return nil
+	}
+	return &updatedAt
+}
+
+// savedProduct answers a product save, with the conflict when it replaced a
+// version the admin hadn't seen
+func (h *AdminHandler) savedProduct(c *gin.Context, id uuid.UUID, response *services.AdminProductResponse, expected, before *time.Time) {
+	body := gin.H{
+		"success": true,
+		"data":    response,
+	}
+	if h.live != nil {
+		resource := services.EditResource{Kind: services.EditResourceProduct, ID: id}
+		if conflict := h.live.RecordSave(c, resource, expected, before); conflict != nil {
+			body["conflict"] = conflict
+		}
+	}
+	c.JSON(http.StatusOK, body)
+}
+
 // CreateProduct handles POST /api/v1/admin/products
 func (h *AdminHandler) CreateProduct(c *gin.Context) {
 	var req services.AdminProductRequest
@@ -33,6 +82,9 @@ func (h *AdminHandler) CreateProduct(c *gin.Context) {
 
 	response, err := h.adminProductService.CreateProduct(req)
 	if err != nil {
+		if respondSKUError(c, err) || respondMetadataViolations(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -58,16 +110,100 @@ func (h *AdminHandler) UpdateProduct(c *gin.Context) {
 		return
 	}
 
+	if !h.adminProductService.CanPublish(c.GetString("user_email")) {
+		h.submitForReview(c, id, services.ChangeKindUpdate, req)
+		return
+	}
+
+	before := h.productVersion(c, id)
 	response, err := h.adminProductService.UpdateProduct(id, req)
 	if err != nil {
+		if respondSKUError(c, err) || respondMetadataViolations(c, err) {
+			return
+		}
+		if errors.Is(err, services.ErrVariantInUse) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"data":    response,
+	h.savedProduct(c, id, response, req.ExpectedUpdatedAt, before)
+}
+
+// PatchProduct handles PATCH /api/v1/admin/products/:id
+func (h *AdminHandler) PatchProduct(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		return
+	}
+
+	var patch services.AdminProductPatch
+	if err := c.ShouldBindJSON(&patch); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !h.adminProductService.CanPublish(c.GetString("user_email")) {
+		h.submitForReview(c, id, services.ChangeKindPatch, patch)
+		return
+	}
+
+	before := h.productVersion(c, id)
+	response, err := h.adminProductService.PatchProduct(c.Request.Context(), id, patch)
+	if err != nil {
+		if respondSKUError(c, err) || respondMetadataViolations(c, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, services.ErrProductNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrVariantInUse):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	h.savedProduct(c, id, response, patch.ExpectedUpdatedAt, before)
+}
+
+// respondSKUError writes a 409 naming the product that already has the SKU,
+// or a 400 for a missing or reserved SKU, and reports whether it did
+func respondSKUError(c *gin.Context, err error) bool {
+	var conflictErr *services.SKUConflictError
+	switch {
+	case errors.As(err, &conflictErr):
+		c.JSON(http.StatusConflict, gin.H{
+			"error":    conflictErr.Error(),
+			"code":     services.SKUConflictCode,
+			"conflict": conflictErr,
+		})
+	case errors.Is(err, services.ErrSKURequired), errors.Is(err, services.ErrSKUReserved):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "field": "sku"})
+	default:
+		return false
+	}
+	return true
+}
+
+// respondMetadataViolations writes a 422 listing how a product's metadata
+// breaks its category's schema, and reports whether it did
+func respondMetadataViolations(c *gin.Context, err error) bool {
+	var validationErr *services.MetadataValidationError
+	if !errors.As(err, &validationErr) {
+		return false
+	}
+
+	c.JSON(http.StatusUnprocessableEntity, gin.H{
+		"error":      validationErr.Error(),
+		"field":      "metadata",
+		"violations": validationErr.Violations,
 	})
+	return true
 }
 
 // DeleteProduct handles DELETE /api/v1/admin/products/:id
@@ -112,7 +248,8 @@ func (h *AdminHandler) GetProductWithDetails(c *gin.Context) {
 	})
 }
 
-// BulkImportProducts handles POST /api/v1/admin/products/bulk-import
+// BulkImportProducts handles POST /api/v1/admin/products/bulk-import, in
+// the background with ?async=true
 func (h *AdminHandler) BulkImportProducts(c *gin.Context) {
 	var req services.BulkImportRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -120,6 +257,18 @@ func (h *AdminHandler) BulkImportProducts(c *gin.Context) {
 		return
 	}
 
+	if runAsJob(c, h.jobs) {
+		params := gin.H{"products": len(req.Products), "update_existing": req.UpdateExisting, "duplicate_strategy": req.DuplicateStrategy}
+		startJob(c, h.jobs, services.JobKindProductImport, params, func(ctx context.Context) (*services.JobOutput, error) {
+			response, err := h.adminProductService.BulkImportProductsInBatches(ctx, req)
+			if err != nil {
+				return nil, err
+			}
+			return &services.JobOutput{Result: response}, nil
+		})
+		return
+	}
+
 	response, err := h.adminProductService.BulkImportProducts(req)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -132,7 +281,61 @@ func (h *AdminHandler) BulkImportProducts(c *gin.Context) {
 	})
 }
 
-// ExportProducts handles GET /api/v1/admin/products/export
+// BulkUpdatePrices handles POST /api/v1/admin/products/bulk-price, in the
+// background with ?async=true
+func (h *AdminHandler) BulkUpdatePrices(c *gin.Context) {
+	var req services.BulkPriceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if runAsJob(c, h.jobs) {
+		changedBy := requestUserID(c)
+		startJob(c, h.jobs, services.JobKindBulkPrice, req, func(ctx context.Context) (*services.JobOutput, error) {
+			result, err := h.adminProductService.BulkUpdatePrices(ctx, req, changedBy)
+			if err != nil {
+				return nil, err
+			}
+			return &services.JobOutput{Result: result}, nil
+		})
+		return
+	}
+
+	result, err := h.adminProductService.BulkUpdatePrices(c.Request.Context(), req, requestUserID(c))
+	if err != nil {
+		c.JSON(categoryErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    result,
+	})
+}
+
+// GetPriceHistory handles GET /api/v1/admin/products/:id/price-history
+func (h *AdminHandler) GetPriceHistory(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		return
+	}
+
+	history, err := h.adminProductService.GetPriceHistory(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    history,
+	})
+}
+
+// ExportProducts handles GET /api/v1/admin/products/export. With
+// ?async=true the CSV is built in the background and downloaded from the job.
 func (h *AdminHandler) ExportProducts(c *gin.Context) {
 	// Parse query parameters
 	filters := services.ProductFilters{
@@ -146,6 +349,21 @@ func (h *AdminHandler) ExportProducts(c *gin.Context) {
 		}
 	}
 
+	if runAsJob(c, h.jobs) {
+		startJob(c, h.jobs, services.JobKindProductExport, filters, func(ctx context.Context) (*services.JobOutput, error) {
+			csvData, err := h.adminProductService.ExportProducts(filters)
+			if err != nil {
+				return nil, err
+			}
+			return &services.JobOutput{
+				Artifact:     csvData,
+				ArtifactName: "products.csv",
+				ArtifactType: "text/csv",
+			}, nil
+		})
+		return
+	}
+
 	csvData, err := h.adminProductService.ExportProducts(filters)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -173,42 +391,13 @@ func (h *AdminHandler) GetProductStats(c *gin.Context) {
 
 // GetProducts handles GET /api/v1/admin/products
 func (h *AdminHandler) GetProducts(c *gin.Context) {
-	// Parse query parameters
-	filters := services.ProductFilters{
-		Status:    c.Query("status"),
-		Search:    c.Query("search"),
-		SortBy:    c.Query("sort_by"),
-		SortOrder: c.Query("sort_order"),
-	}
-
-	if pageStr := c.Query("page"); pageStr != "" {
-		if page, err := strconv.Atoi(pageStr); err == nil {
-			filters.Page = page
-		}
-	}
-
-	if limitStr := c.Query("limit"); limitStr != "" {
-		if limit, err := strconv.Atoi(limitStr); err == nil {
-			filters.Limit = limit
-		}
-	}
-
-	if categoryIDStr := c.Query("category_id"); categoryIDStr != "" {
-		if categoryID, err := uuid.Parse(categoryIDStr); err == nil {
-			filters.CategoryID = categoryID
-		}
-	}
-
-	if minPriceStr := c.Query("min_price"); minPriceStr != "" {
-		if minPrice, err := strconv.ParseFloat(minPriceStr, 64); err == nil {
-			filters.MinPrice = minPrice
-		}
+	list, ok := listQuery(c, services.ProductListSchema)
+	if !ok {
+		return
 	}
-
-	if maxPriceStr := c.Query("max_price"); maxPriceStr != "" {
-		if maxPrice, err := strconv.ParseFloat(maxPriceStr, 64); err == nil {
-			filters.MaxPrice = maxPrice
-		}
+	filters := services.ProductFilters{
+		IncludeUnpublished: true,
+		List:               list,
 	}
 
 	response, err := h.productService.GetProducts(filters)
@@ -273,3 +462,202 @@ func (h *AdminHandler) DeleteCategory(c *gin.Context) {
 		"error": "Category deletion not yet implemented",
 	})
 }
+
+// MergeCategory handles POST /api/v1/admin/categories/:id/merge
+func (h *AdminHandler) MergeCategory(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category ID"})
+		return
+	}
+
+	var req services.CategoryMergeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := h.adminProductService.MergeCategories(c.Request.Context(), id, req.TargetID)
+	if err != nil {
+		c.JSON(categoryErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    result,
+	})
+}
+
+// MoveCategory handles POST /api/v1/admin/categories/:id/move
+func (h *AdminHandler) MoveCategory(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category ID"})
+		return
+	}
+
+	var req services.CategoryMoveRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	category, err := h.adminProductService.MoveCategory(c.Request.Context(), id, req.ParentID)
+	if err != nil {
+		c.JSON(categoryErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    category,
+	})
+}
+
+// categoryErrorStatus maps category reorganization errors to HTTP status codes
+// GetCategoryMetadataSchema handles GET /api/v1/admin/categories/:id/metadata-schema
+func (h *AdminHandler) GetCategoryMetadataSchema(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category ID"})
+		return
+	}
+
+	result, err := h.adminProductService.GetCategoryMetadataSchema(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(categoryErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    result,
+	})
+}
+
+// UpdateCategoryMetadataSchema handles PUT /api/v1/admin/categories/:id/metadata-schema
+func (h *AdminHandler) UpdateCategoryMetadataSchema(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category ID"})
+		return
+	}
+
+	var req services.CategoryMetadataSchemaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := h.adminProductService.SetCategoryMetadataSchema(c.Request.Context(), id, req.Schema)
+	if err != nil {
+		c.JSON(categoryErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    result,
+	})
+}
+
+func categoryErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrCategoryNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrCategoryCycle):
+		return http.StatusConflict
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+// ExportCategories handles GET /api/v1/admin/categories/export
+func (h *AdminHandler) ExportCategories(c *gin.Context) {
+	csvData, err := h.adminProductService.ExportCategories(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Disposition", "attachment; filename=categories.csv")
+	c.Data(http.StatusOK, "text/csv", csvData)
+}
+
+// ImportCategories handles POST /api/v1/admin/categories/import
+func (h *AdminHandler) ImportCategories(c *gin.Context) {
+	file, err := importFile(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer file.Close()
+
+	response, err := h.adminProductService.ImportCategories(c.Request.Context(), file)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    response,
+	})
+}
+
+// ExportVariants handles GET /api/v1/admin/products/variants/export
+func (h *AdminHandler) ExportVariants(c *gin.Context) {
+	filters := services.ProductFilters{
+		Status: c.Query("status"),
+	}
+
+	if categoryIDStr := c.Query("category_id"); categoryIDStr != "" {
+		if categoryID, err := uuid.Parse(categoryIDStr); err == nil {
+			filters.CategoryID = categoryID
+		}
+	}
+
+	csvData, err := h.adminProductService.ExportVariantMatrix(c.Request.Context(), filters)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Disposition", "attachment; filename=variants.csv")
+	c.Data(http.StatusOK, "text/csv", csvData)
+}
+
+// ImportVariants handles POST /api/v1/admin/products/variants/import
+func (h *AdminHandler) ImportVariants(c *gin.Context) {
+	file, err := importFile(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer file.Close()
+
+	response, err := h.adminProductService.ImportVariantMatrix(c.Request.Context(), file)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    response,
+	})
+}
+
+// importFile returns the uploaded "file" form field, or the raw request body
+// when the CSV is posted directly
+func importFile(c *gin.Context) (io.ReadCloser, error) {
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		header, err := c.FormFile("file")
+		if err != nil {
+			return nil, errors.New("file is required")
+		}
+		return header.Open()
+	}
+	return c.Request.Body, nil
+}
@@ -0,0 +1,226 @@
+package handlers
+
+import (
+	"chat-ecommerce-backend/internal/services"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// AdminLiveHandler runs the admin WebSocket channel. Admins see who else is
+// connected and which records they are editing, are told when a record they
+// have open is saved, and are warned when a save replaced a change its
+// editor hadn't seen.
+type AdminLiveHandler struct {
+	presence *services.AdminPresence
+	upgrader websocket.Upgrader
+	origins  map[string]bool
+
+	connMu sync.RWMutex
+	conns  map[*adminConn]struct{}
+}
+
+// adminConn serialises writes to an admin's WebSocket connection, which
+// other admins' edits write to alongside the read loop
+type adminConn struct {
+	*websocket.Conn
+	mu     sync.Mutex
+	editor services.AdminEditor
+}
+
+// WriteJSON writes a message to the connection
+func (c *adminConn) WriteJSON(v interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.Conn.WriteJSON(v)
+}
+
+// AdminMessage is a message on the admin channel. The server sends the
+// services.Admin*Message types; admins send "edit_start", repeated to keep
+// the lock, and "edit_end" with the record as data.
+type AdminMessage struct {
+	Type string      `json:"type"`
+	Data interface{} `json:"data"`
+}
+
+// adminEditRequest is an admin taking, keeping or giving up a record's edit lock
+type adminEditRequest struct {
+	Type     string                `json:"type"`
+	Resource services.EditResource `json:"data"`
+}
+
+// NewAdminLiveHandler creates a new AdminLiveHandler that only upgrades
+// same-origin requests until WithAllowedOrigins adds more
+func NewAdminLiveHandler(presence *services.AdminPresence) *AdminLiveHandler {
+	h := &AdminLiveHandler{
+		presence: presence,
+		origins:  make(map[string]bool),
+		conns:    make(map[*adminConn]struct{}),
+	}
+	h.upgrader = websocket.Upgrader{CheckOrigin: h.checkOrigin}
+	return h
+}
+
+// WithAllowedOrigins lets pages on the CORS allowlist open the channel
+func (h *AdminLiveHandler) WithAllowedOrigins(origins []string) *AdminLiveHandler {
+	for _, origin := range origins {
+		h.origins[origin] = true
+	}
+	return h
+}
+
+// checkOrigin keeps other sites from opening the channel with an admin's
+// session cookie. Browsers always send an Origin; requests without one are
+// only accepted from clients signing in with a bearer token.
+func (h *AdminLiveHandler) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return r.Header.Get("Authorization") != ""
+	}
+	if h.origins[origin] {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+// HandleWebSocket handles GET /api/v1/admin/ws
+func (h *AdminLiveHandler) HandleWebSocket(c *gin.Context) {
+	userID := requestUserID(c)
+	if userID == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	wsConn, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		log.Printf("Failed to upgrade admin WebSocket connection: %v", err)
+		return
+	}
+	conn := &adminConn{Conn: wsConn, editor: services.AdminEditor{UserID: *userID, Email: c.GetString("user_email")}}
+	defer conn.Close()
+
+	h.register(conn)
+	defer h.unregister(conn)
+
+	for {
+		var req adminEditRequest
+		if err := conn.ReadJSON(&req); err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+				log.Printf("Admin WebSocket error: %v", err)
+			}
+			break
+		}
+		if !req.Resource.Valid() {
+			conn.WriteJSON(AdminMessage{Type: "error", Data: ChatError{Message: "data must name a product or inventory record"}})
+			continue
+		}
+
+		switch req.Type {
+		case "edit_start":
+			lock, acquired := h.presence.Lock(req.Resource, conn.editor, time.Now())
+			if !acquired {
+				// Only the admin who asked needs to hear who holds it
+				conn.WriteJSON(AdminMessage{Type: services.AdminEditLockMessage, Data: lock})
+				continue
+			}
+			h.NotifyAdmins(services.AdminEditLockMessage, lock)
+		case "edit_end":
+			if released := h.presence.Unlock(req.Resource, conn.editor); released != nil {
+				h.NotifyAdmins(services.AdminEditLockMessage, released)
+			}
+		default:
+			log.Printf("Unknown admin message type: %s", req.Type)
+		}
+	}
+}
+
+// NotifyAdmins sends a message to every admin connected to the admin channel
+func (h *AdminLiveHandler) NotifyAdmins(messageType string, data interface{}) {
+	h.connMu.RLock()
+	conns := make([]*adminConn, 0, len(h.conns))
+	for conn := range h.conns {
+		conns = append(conns, conn)
+	}
+	h.connMu.RUnlock()
+
+	msg := AdminMessage{Type: messageType, Data: data}
+	for _, conn := range conns {
+		if err := conn.WriteJSON(msg); err != nil {
+			log.Printf("Failed to notify admin %s: %v", conn.editor.Email, err)
+		}
+	}
+}
+
+// RecordSave tells the admins a record was saved. current is the record's
+// version before the save and expected the one the editor's copy was loaded
+// at; when they differ the save replaced a change the editor hadn't seen,
+// and the conflict is broadcast and returned for the save's response.
+func (h *AdminLiveHandler) RecordSave(c *gin.Context, resource services.EditResource, expected, current *time.Time) *services.EditConflict {
+	editor := services.AdminEditor{Email: c.GetString("user_email")}
+	if userID := requestUserID(c); userID != nil {
+		editor.UserID = *userID
+	}
+
+	saved, conflict := h.presence.Saved(resource, editor, expected, current, time.Now())
+	h.NotifyAdmins(services.AdminRecordSavedMessage, saved)
+	if conflict != nil {
+		h.NotifyAdmins(services.AdminEditConflictMessage, conflict)
+	}
+	return conflict
+}
+
+// snapshot returns the connected admins, each once, and the held locks
+func (h *AdminLiveHandler) snapshot() services.AdminPresenceSnapshot {
+	h.connMu.RLock()
+	seen := make(map[uuid.UUID]bool)
+	admins := make([]services.AdminEditor, 0, len(h.conns))
+	for conn := range h.conns {
+		if !seen[conn.editor.UserID] {
+			seen[conn.editor.UserID] = true
+			admins = append(admins, conn.editor)
+		}
+	}
+	h.connMu.RUnlock()
+
+	sort.Slice(admins, func(i, j int) bool { return admins[i].Email < admins[j].Email })
+	return services.AdminPresenceSnapshot{Admins: admins, Locks: h.presence.Locks(time.Now())}
+}
+
+func (h *AdminLiveHandler) register(conn *adminConn) {
+	h.connMu.Lock()
+	h.conns[conn] = struct{}{}
+	h.connMu.Unlock()
+
+	h.NotifyAdmins(services.AdminPresenceMessage, h.snapshot())
+}
+
+// unregister removes a connection. An admin's locks are given up when their
+// last connection closes.
+func (h *AdminLiveHandler) unregister(conn *adminConn) {
+	h.connMu.Lock()
+	delete(h.conns, conn)
+	connected := false
+	for other := range h.conns {
+		if other.editor.UserID == conn.editor.UserID {
+			connected = true
+			break
+		}
+	}
+	h.connMu.Unlock()
+
+	if !connected {
+		for _, released := range h.presence.UnlockAll(conn.editor) {
+			h.NotifyAdmins(services.AdminEditLockMessage, released)
+		}
+	}
+	h.NotifyAdmins(services.AdminPresenceMessage, h.snapshot())
+}
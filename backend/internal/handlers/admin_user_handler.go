@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"chat-ecommerce-backend/internal/services"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminUserHandler handles customer account management for admins
+type AdminUserHandler struct {
+	userService *services.AdminUserService
+}
+
+// NewAdminUserHandler creates a new AdminUserHandler
+func NewAdminUserHandler(userService *services.AdminUserService) *AdminUserHandler {
+	return &AdminUserHandler{
+		userService: userService,
+	}
+}
+
+// GetUsers handles GET /api/v1/admin/users with the list parameters of
+// services.AdminUserListSchema
+func (h *AdminUserHandler) GetUsers(c *gin.Context) {
+	list, ok := listQuery(c, services.AdminUserListSchema)
+	if !ok {
+		return
+	}
+	filters := services.AdminUserFilters{List: list}
+
+	result, err := h.userService.ListUsers(c.Request.Context(), filters)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    result,
+	})
+}
+
+// ExportUsers handles GET /api/v1/admin/users/export with the same filters
+// as GetUsers, and returns every matching customer as CSV
+func (h *AdminUserHandler) ExportUsers(c *gin.Context) {
+	list, ok := listQuery(c, services.AdminUserListSchema)
+	if !ok {
+		return
+	}
+	filters := services.AdminUserFilters{List: list}
+
+	csvData, err := h.userService.ExportCustomersCSV(c.Request.Context(), filters)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Disposition", "attachment; filename=customers.csv")
+	c.Data(http.StatusOK, "text/csv", csvData)
+}
+
+// SuspendUsers handles POST /api/v1/admin/users/suspend
+func (h *AdminUserHandler) SuspendUsers(c *gin.Context) {
+	var req services.BulkUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := h.userService.SuspendUsers(c.Request.Context(), req)
+	if err != nil {
+		c.JSON(adminUserErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    result,
+	})
+}
+
+// ReactivateUsers handles POST /api/v1/admin/users/reactivate
+func (h *AdminUserHandler) ReactivateUsers(c *gin.Context) {
+	var req services.BulkUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := h.userService.ReactivateUsers(c.Request.Context(), req)
+	if err != nil {
+		c.JSON(adminUserErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    result,
+	})
+}
+
+// ForcePasswordReset handles POST /api/v1/admin/users/force-password-reset and
+// returns a reset link for each user, for the admin to send on
+func (h *AdminUserHandler) ForcePasswordReset(c *gin.Context) {
+	var req services.BulkUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := h.userService.ForcePasswordReset(c.Request.Context(), req)
+	if err != nil {
+		c.JSON(adminUserErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    result,
+	})
+}
+
+// adminUserErrorStatus maps user management errors to HTTP status codes
+func adminUserErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrTooManyUsers):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
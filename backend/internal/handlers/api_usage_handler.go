@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"chat-ecommerce-backend/internal/services"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APIUsageHandler handles admin API usage reports
+type APIUsageHandler struct {
+	usageService *services.APIUsageService
+}
+
+// NewAPIUsageHandler creates a new APIUsageHandler
+func NewAPIUsageHandler(usageService *services.APIUsageService) *APIUsageHandler {
+	return &APIUsageHandler{
+		usageService: usageService,
+	}
+}
+
+// GetUsage handles GET /api/v1/admin/api-usage?hours=24&route=&consumer=,
+// reporting request counts, latencies and error rates per route and consumer
+func (h *APIUsageHandler) GetUsage(c *gin.Context) {
+	hours, err := strconv.Atoi(c.DefaultQuery("hours", "24"))
+	if err != nil || hours < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "hours must be a positive integer"})
+		return
+	}
+
+	until := time.Now()
+	report, err := h.usageService.Usage(c.Request.Context(), services.APIUsageFilter{
+		Since:    until.Add(-time.Duration(hours) * time.Hour),
+		Until:    until,
+		Route:    c.Query("route"),
+		Consumer: c.Query("consumer"),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    report,
+	})
+}
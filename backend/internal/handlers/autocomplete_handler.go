@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"chat-ecommerce-backend/internal/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AutocompleteHandler serves type-ahead suggestions for the storefront search
+// box and the chat input
+type AutocompleteHandler struct {
+	index *services.AutocompleteIndex
+}
+
+// NewAutocompleteHandler creates a new autocomplete handler
+func NewAutocompleteHandler(index *services.AutocompleteIndex) *AutocompleteHandler {
+	return &AutocompleteHandler{index: index}
+}
+
+// Autocomplete handles GET /api/v1/products/autocomplete?q=&limit=
+func (h *AutocompleteHandler) Autocomplete(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "5"))
+	if limit < 1 || limit > 20 {
+		limit = 5
+	}
+
+	// Suggestions only change when the index is rebuilt
+	c.Header("Cache-Control", "public, max-age=60")
+	c.JSON(http.StatusOK, h.index.Complete(c.Query("q"), limit))
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"chat-ecommerce-backend/internal/services"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// maxAvailabilityIDs caps how many products one availability request can ask for
+const maxAvailabilityIDs = 100
+
+// AvailabilityHandler serves stock statuses for storefront polling
+type AvailabilityHandler struct {
+	availabilityService *services.AvailabilityService
+}
+
+// NewAvailabilityHandler creates a new availability handler
+func NewAvailabilityHandler(availabilityService *services.AvailabilityService) *AvailabilityHandler {
+	return &AvailabilityHandler{availabilityService: availabilityService}
+}
+
+// GetAvailability handles GET /api/v1/products/availability?ids=id1,id2 and
+// returns only the stock status of each product
+func (h *AvailabilityHandler) GetAvailability(c *gin.Context) {
+	var ids []uuid.UUID
+	for _, value := range c.QueryArray("ids") {
+		for _, raw := range strings.Split(value, ",") {
+			raw = strings.TrimSpace(raw)
+			if raw == "" {
+				continue
+			}
+			id, err := uuid.Parse(raw)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid product ID: %s", raw)})
+				return
+			}
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "At least one product ID is required"})
+		return
+	}
+	if len(ids) > maxAvailabilityIDs {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("At most %d product IDs can be requested at once", maxAvailabilityIDs)})
+		return
+	}
+
+	availability, err := h.availabilityService.GetAvailability(c.Request.Context(), ids)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Cache-Control", "public, max-age=5")
+	c.JSON(http.StatusOK, gin.H{"availability": availability})
+}
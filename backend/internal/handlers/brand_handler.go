@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"chat-ecommerce-backend/internal/services"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// BrandHandler handles brand listings, brand pages and brand management
+type BrandHandler struct {
+	brandService   *services.BrandService
+	productService *services.ProductService
+}
+
+// NewBrandHandler creates a new BrandHandler
+func NewBrandHandler(brandService *services.BrandService, productService *services.ProductService) *BrandHandler {
+	return &BrandHandler{
+		brandService:   brandService,
+		productService: productService,
+	}
+}
+
+// GetBrands handles GET /api/v1/brands and lists the active brands with
+// their product counts
+func (h *BrandHandler) GetBrands(c *gin.Context) {
+	brands, err := h.brandService.ListBrands(c.Request.Context(), false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"brands": brands})
+}
+
+// GetBrandBySlug handles GET /api/v1/brands/:slug
+func (h *BrandHandler) GetBrandBySlug(c *gin.Context) {
+	brand, err := h.brandService.GetBrandBySlug(c.Request.Context(), c.Param("slug"))
+	if err != nil {
+		c.JSON(brandErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, brand)
+}
+
+// GetBrandProducts handles GET /api/v1/brands/:slug/products and pages
+// through a brand's products like GET /api/v1/products
+func (h *BrandHandler) GetBrandProducts(c *gin.Context) {
+	brand, err := h.brandService.GetBrandBySlug(c.Request.Context(), c.Param("slug"))
+	if err != nil {
+		c.JSON(brandErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	list, ok := listQuery(c, services.ProductListSchema)
+	if !ok {
+		return
+	}
+	result, err := h.productService.GetProducts(services.ProductFilters{
+		BrandID: brand.ID,
+		List:    list,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.productService.ApplyCustomerPricing(c.Request.Context(), requestUserID(c), result.Products); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if notModified(c, newETagBuilder().add(brand.ID, brand.UpdatedAt.UnixNano(), result.Total).addProducts(result.Products).tag()) {
+		return
+	}
+
+	jsonWithFields(c, http.StatusOK, result, "products")
+}
+
+// GetAdminBrands handles GET /api/v1/admin/brands, including inactive brands
+func (h *BrandHandler) GetAdminBrands(c *gin.Context) {
+	brands, err := h.brandService.ListBrands(c.Request.Context(), true)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    brands,
+	})
+}
+
+// CreateBrand handles POST /api/v1/admin/brands
+func (h *BrandHandler) CreateBrand(c *gin.Context) {
+	var req services.BrandRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	brand, err := h.brandService.CreateBrand(c.Request.Context(), req)
+	if err != nil {
+		c.JSON(brandErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"data":    brand,
+	})
+}
+
+// UpdateBrand handles PUT /api/v1/admin/brands/:id
+func (h *BrandHandler) UpdateBrand(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid brand ID"})
+		return
+	}
+
+	var req services.BrandRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	brand, err := h.brandService.UpdateBrand(c.Request.Context(), id, req)
+	if err != nil {
+		c.JSON(brandErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    brand,
+	})
+}
+
+// DeleteBrand handles DELETE /api/v1/admin/brands/:id
+func (h *BrandHandler) DeleteBrand(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid brand ID"})
+		return
+	}
+
+	if err := h.brandService.DeleteBrand(c.Request.Context(), id); err != nil {
+		c.JSON(brandErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Brand deleted successfully",
+	})
+}
+
+// parseBrandID reads the optional brand_id query parameter, answering 400
+// when it isn't a UUID
+func parseBrandID(c *gin.Context) (uuid.UUID, bool) {
+	raw := c.Query("brand_id")
+	if raw == "" {
+		return uuid.Nil, true
+	}
+	id, err := uuid.Parse(raw)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid brand ID"})
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// brandErrorStatus maps brand errors to HTTP status codes
+func brandErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrBrandNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrBrandExists):
+		return http.StatusConflict
+	default:
+		return http.StatusBadRequest
+	}
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"chat-ecommerce-backend/internal/services"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// BusinessHoursHandler handles store opening hours and the after-hours
+// escalation queue
+type BusinessHoursHandler struct {
+	hoursService *services.BusinessHoursService
+}
+
+// NewBusinessHoursHandler creates a new BusinessHoursHandler
+func NewBusinessHoursHandler(hoursService *services.BusinessHoursService) *BusinessHoursHandler {
+	return &BusinessHoursHandler{
+		hoursService: hoursService,
+	}
+}
+
+// GetAvailability handles GET /api/v1/store/availability?store=default so the
+// storefront can show whether staff are around
+func (h *BusinessHoursHandler) GetAvailability(c *gin.Context) {
+	availability, err := h.hoursService.Availability(c.Request.Context(), c.DefaultQuery("store", services.DefaultStoreVariant), time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": availability})
+}
+
+// GetHours handles GET /api/v1/admin/store-hours?store=default
+func (h *BusinessHoursHandler) GetHours(c *gin.Context) {
+	hours, err := h.hoursService.GetHours(c.Request.Context(), c.DefaultQuery("store", services.DefaultStoreVariant))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": hours})
+}
+
+// SetHours handles PUT /api/v1/admin/store-hours, replacing the week, e.g.
+// {"store": "default", "hours": [{"weekday": 1, "opens": "09:00", "closes": "17:00", "shipping_cutoff": "14:00"}]}
+func (h *BusinessHoursHandler) SetHours(c *gin.Context) {
+	var req struct {
+		Store string                       `json:"store"`
+		Hours []services.StoreHoursRequest `json:"hours" binding:"dive"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	hours, err := h.hoursService.SetHours(c.Request.Context(), req.Store, req.Hours)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": hours})
+}
+
+// GetEscalations handles GET /api/v1/admin/escalations?status=pending
+func (h *BusinessHoursHandler) GetEscalations(c *gin.Context) {
+	escalations, err := h.hoursService.ListEscalations(c.Request.Context(), c.Query("status"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": escalations})
+}
+
+// ResolveEscalation handles POST /api/v1/admin/escalations/:id/resolve once a
+// queued message has been followed up, e.g. {"notes": "emailed tracking link"}
+func (h *BusinessHoursHandler) ResolveEscalation(c *gin.Context) {
+	escalationID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid escalation ID"})
+		return
+	}
+
+	var req struct {
+		Notes string `json:"notes"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil && c.Request.ContentLength > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	escalation, err := h.hoursService.ResolveEscalation(c.Request.Context(), escalationID, requestUserID(c), req.Notes)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrEscalationNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": escalation})
+}
@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"chat-ecommerce-backend/internal/services"
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// CampaignHandler handles broadcast campaigns to shoppers' chat sessions
+type CampaignHandler struct {
+	campaignService *services.CampaignService
+	broadcaster     services.SessionBroadcaster
+	jobs            *services.JobService
+}
+
+// NewCampaignHandler creates a new CampaignHandler
+func NewCampaignHandler(campaignService *services.CampaignService, broadcaster services.SessionBroadcaster) *CampaignHandler {
+	return &CampaignHandler{
+		campaignService: campaignService,
+		broadcaster:     broadcaster,
+	}
+}
+
+// WithJobs lets campaigns be sent right away as background jobs
+func (h *CampaignHandler) WithJobs(jobs *services.JobService) *CampaignHandler {
+	h.jobs = jobs
+	return h
+}
+
+// GetCampaigns handles GET /api/v1/admin/campaigns?status=scheduled
+func (h *CampaignHandler) GetCampaigns(c *gin.Context) {
+	campaigns, err := h.campaignService.ListCampaigns(c.Request.Context(), c.Query("status"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": campaigns})
+}
+
+// CreateCampaign handles POST /api/v1/admin/campaigns
+func (h *CampaignHandler) CreateCampaign(c *gin.Context) {
+	var req services.CampaignRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	campaign, err := h.campaignService.CreateCampaign(c.Request.Context(), requestUserID(c), req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"success": true, "data": campaign})
+}
+
+// PreviewCampaign handles POST /api/v1/admin/campaigns/preview, showing the
+// notice and its current audience without sending it
+func (h *CampaignHandler) PreviewCampaign(c *gin.Context) {
+	var req services.CampaignRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	preview, err := h.campaignService.Preview(c.Request.Context(), req, h.broadcaster)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": preview})
+}
+
+// GetCampaign handles GET /api/v1/admin/campaigns/:id with delivery stats
+func (h *CampaignHandler) GetCampaign(c *gin.Context) {
+	campaignID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid campaign ID"})
+		return
+	}
+
+	stats, err := h.campaignService.GetStats(c.Request.Context(), campaignID)
+	if err != nil {
+		c.JSON(campaignErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": stats})
+}
+
+// CancelCampaign handles POST /api/v1/admin/campaigns/:id/cancel
+func (h *CampaignHandler) CancelCampaign(c *gin.Context) {
+	campaignID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid campaign ID"})
+		return
+	}
+
+	campaign, err := h.campaignService.CancelCampaign(c.Request.Context(), campaignID)
+	if err != nil {
+		c.JSON(campaignErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": campaign})
+}
+
+// SendCampaign handles POST /api/v1/admin/campaigns/:id/send, sending a
+// scheduled campaign now as a background job
+func (h *CampaignHandler) SendCampaign(c *gin.Context) {
+	campaignID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid campaign ID"})
+		return
+	}
+	if h.jobs == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "background jobs are not available"})
+		return
+	}
+
+	campaign, err := h.campaignService.ClaimCampaign(c.Request.Context(), campaignID)
+	if err != nil {
+		c.JSON(campaignErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	startJob(c, h.jobs, services.JobKindCampaignSend, gin.H{"campaign_id": campaign.ID}, func(ctx context.Context) (*services.JobOutput, error) {
+		stats, err := h.campaignService.SendCampaign(ctx, campaign, h.broadcaster)
+		if err != nil {
+			return nil, err
+		}
+		return &services.JobOutput{Result: stats}, nil
+	})
+}
+
+// RecordClick handles POST /api/v1/campaigns/:id/clicks when a shopper opens
+// a campaign's link, e.g. {"session_id": "..."}
+func (h *CampaignHandler) RecordClick(c *gin.Context) {
+	campaignID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid campaign ID"})
+		return
+	}
+
+	var req struct {
+		SessionID string `json:"session_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.campaignService.RecordClick(c.Request.Context(), campaignID, req.SessionID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func campaignErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrCampaignNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrCampaignNotScheduled), errors.Is(err, services.ErrCampaignNotSendable):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"chat-ecommerce-backend/internal/services"
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -33,12 +34,7 @@ func (h *CartHandler) GetCart(c *gin.Context) {
 	}
 
 	// Get user ID from context (set by auth middleware)
-	var userID *uuid.UUID
-	if userIDStr, exists := c.Get("user_id"); exists {
-		if id, ok := userIDStr.(uuid.UUID); ok {
-			userID = &id
-		}
-	}
+	userID := requestUserID(c)
 
 	cart, err := h.cartService.GetCart(sessionID, userID)
 	if err != nil {
@@ -62,12 +58,7 @@ func (h *CartHandler) AddToCart(c *gin.Context) {
 	}
 
 	// Get user ID from context (set by auth middleware)
-	var userID *uuid.UUID
-	if userIDStr, exists := c.Get("user_id"); exists {
-		if id, ok := userIDStr.(uuid.UUID); ok {
-			userID = &id
-		}
-	}
+	userID := requestUserID(c)
 
 	var req services.AddToCartRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -96,12 +87,7 @@ func (h *CartHandler) UpdateCartItem(c *gin.Context) {
 	}
 
 	// Get user ID from context (set by auth middleware)
-	var userID *uuid.UUID
-	if userIDStr, exists := c.Get("user_id"); exists {
-		if id, ok := userIDStr.(uuid.UUID); ok {
-			userID = &id
-		}
-	}
+	userID := requestUserID(c)
 
 	var req services.UpdateCartItemRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -130,12 +116,7 @@ func (h *CartHandler) RemoveFromCart(c *gin.Context) {
 	}
 
 	// Get user ID from context (set by auth middleware)
-	var userID *uuid.UUID
-	if userIDStr, exists := c.Get("user_id"); exists {
-		if id, ok := userIDStr.(uuid.UUID); ok {
-			userID = &id
-		}
-	}
+	userID := requestUserID(c)
 
 	productIDStr := c.Param("product_id")
 	productID, err := uuid.Parse(productIDStr)
@@ -173,12 +154,7 @@ func (h *CartHandler) ClearCart(c *gin.Context) {
 	}
 
 	// Get user ID from context (set by auth middleware)
-	var userID *uuid.UUID
-	if userIDStr, exists := c.Get("user_id"); exists {
-		if id, ok := userIDStr.(uuid.UUID); ok {
-			userID = &id
-		}
-	}
+	userID := requestUserID(c)
 
 	if err := h.cartService.ClearCart(sessionID, userID); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -201,12 +177,7 @@ func (h *CartHandler) CalculateTotals(c *gin.Context) {
 	}
 
 	// Get user ID from context (set by auth middleware)
-	var userID *uuid.UUID
-	if userIDStr, exists := c.Get("user_id"); exists {
-		if id, ok := userIDStr.(uuid.UUID); ok {
-			userID = &id
-		}
-	}
+	userID := requestUserID(c)
 
 	cart, err := h.cartService.GetCart(sessionID, userID)
 	if err != nil {
@@ -215,7 +186,7 @@ func (h *CartHandler) CalculateTotals(c *gin.Context) {
 	}
 
 	// Calculate totals with tax and shipping
-	totals, err := h.cartService.CalculateCartTotals(cart)
+	totals, err := h.cartService.CalculateCartTotals(c.Request.Context(), cart)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -224,8 +195,14 @@ func (h *CartHandler) CalculateTotals(c *gin.Context) {
 	c.JSON(http.StatusOK, totals)
 }
 
-// GetCartItemCount handles GET /api/v1/cart/count
-func (h *CartHandler) GetCartItemCount(c *gin.Context) {
+// SetGiftOptions handles PUT /api/v1/cart/gift-options
+func (h *CartHandler) SetGiftOptions(c *gin.Context) {
+	var req services.GiftOptions
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	// Get session ID from header or generate one
 	sessionID := c.GetHeader("X-Session-ID")
 	if sessionID == "" {
@@ -237,13 +214,36 @@ func (h *CartHandler) GetCartItemCount(c *gin.Context) {
 	}
 
 	// Get user ID from context (set by auth middleware)
-	var userID *uuid.UUID
-	if userIDStr, exists := c.Get("user_id"); exists {
-		if id, ok := userIDStr.(uuid.UUID); ok {
-			userID = &id
+	userID := requestUserID(c)
+
+	cart, err := h.cartService.SetGiftOptions(sessionID, userID, req)
+	if err != nil {
+		if errors.Is(err, services.ErrGiftMessageTooLong) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, cart)
+}
+
+// GetCartItemCount handles GET /api/v1/cart/count
+func (h *CartHandler) GetCartItemCount(c *gin.Context) {
+	// Get session ID from header or generate one
+	sessionID := c.GetHeader("X-Session-ID")
+	if sessionID == "" {
+		sessionID = c.GetString("session_id")
+		if sessionID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Session ID is required"})
+			return
 		}
 	}
 
+	// Get user ID from context (set by auth middleware)
+	userID := requestUserID(c)
+
 	cart, err := h.cartService.GetCart(sessionID, userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -252,3 +252,74 @@ func (h *CartHandler) GetCartItemCount(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"item_count": cart.ItemCount})
 }
+
+// ShareCart handles POST /api/v1/cart/share
+func (h *CartHandler) ShareCart(c *gin.Context) {
+	// Get session ID from header or generate one
+	sessionID := c.GetHeader("X-Session-ID")
+	if sessionID == "" {
+		sessionID = c.GetString("session_id")
+		if sessionID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Session ID is required"})
+			return
+		}
+	}
+
+	// Get user ID from context (set by auth middleware)
+	userID := requestUserID(c)
+
+	share, err := h.cartService.ShareCart(sessionID, userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, share)
+}
+
+// GetSharedCart handles GET /api/v1/cart/shared/:token
+func (h *CartHandler) GetSharedCart(c *gin.Context) {
+	cart, err := h.cartService.GetSharedCart(c.Param("token"))
+	if err != nil {
+		c.JSON(sharedCartErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, cart)
+}
+
+// CloneSharedCart handles POST /api/v1/cart/shared/:token/clone
+func (h *CartHandler) CloneSharedCart(c *gin.Context) {
+	// Get session ID from header or generate one
+	sessionID := c.GetHeader("X-Session-ID")
+	if sessionID == "" {
+		sessionID = c.GetString("session_id")
+		if sessionID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Session ID is required"})
+			return
+		}
+	}
+
+	// Get user ID from context (set by auth middleware)
+	userID := requestUserID(c)
+
+	result, err := h.cartService.CloneSharedCart(c.Param("token"), sessionID, userID)
+	if err != nil {
+		c.JSON(sharedCartErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// sharedCartErrorStatus maps share link errors to HTTP status codes
+func sharedCartErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrInvalidShareToken):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrShareTokenExpired):
+		return http.StatusGone
+	default:
+		return http.StatusBadRequest
+	}
+}
@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"chat-ecommerce-backend/internal/services"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CatalogLintHandler handles the catalog data quality report
+type CatalogLintHandler struct {
+	lintService *services.CatalogLintService
+}
+
+// NewCatalogLintHandler creates a new CatalogLintHandler
+func NewCatalogLintHandler(lintService *services.CatalogLintService) *CatalogLintHandler {
+	return &CatalogLintHandler{lintService: lintService}
+}
+
+// GetCatalogLint handles GET /api/v1/admin/catalog/lint?severity=warning&rule=missing_image
+func (h *CatalogLintHandler) GetCatalogLint(c *gin.Context) {
+	filter := services.CatalogLintFilter{
+		Severity: c.Query("severity"),
+		Rule:     c.Query("rule"),
+	}
+	if filter.Severity != "" && !services.ValidLintSeverity(filter.Severity) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "severity must be error, warning or info"})
+		return
+	}
+
+	report, err := h.lintService.Lint(c.Request.Context(), filter, time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": report})
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"chat-ecommerce-backend/internal/services"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ChatAnalyticsHandler handles admin chat analytics requests
+type ChatAnalyticsHandler struct {
+	analyticsService *services.ChatAnalyticsService
+}
+
+// NewChatAnalyticsHandler creates a new ChatAnalyticsHandler
+func NewChatAnalyticsHandler(analyticsService *services.ChatAnalyticsService) *ChatAnalyticsHandler {
+	return &ChatAnalyticsHandler{
+		analyticsService: analyticsService,
+	}
+}
+
+// GetModelRouting handles GET /api/v1/admin/chat-analytics/routing?hours=24
+func (h *ChatAnalyticsHandler) GetModelRouting(c *gin.Context) {
+	hours, err := strconv.Atoi(c.DefaultQuery("hours", "24"))
+	if err != nil || hours < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "hours must be a positive integer"})
+		return
+	}
+
+	since := time.Now().Add(-time.Duration(hours) * time.Hour)
+	report, err := h.analyticsService.GetModelRoutingReport(c.Request.Context(), since)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    report,
+	})
+}
+
+// GetFunnel handles GET /api/v1/admin/analytics/chat/funnel?from=2024-01-01&to=2024-01-31
+func (h *ChatAnalyticsHandler) GetFunnel(c *gin.Context) {
+	from, to, err := dateRangeQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	funnel, err := h.analyticsService.Funnel(c.Request.Context(), from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    funnel,
+	})
+}
+
+// GetUnansweredQueries handles GET /api/v1/admin/analytics/chat/unanswered?from=2024-01-01&to=2024-01-31&limit=20
+func (h *ChatAnalyticsHandler) GetUnansweredQueries(c *gin.Context) {
+	from, to, err := dateRangeQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if limit < 1 || limit > 1000 {
+		limit = 20
+	}
+
+	queries, err := h.analyticsService.UnansweredQueries(c.Request.Context(), from, to, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    queries,
+	})
+}
@@ -1,10 +1,19 @@
 package handlers
 
 import (
+	"chat-ecommerce-backend/internal/dto"
+	"chat-ecommerce-backend/internal/models"
 	"chat-ecommerce-backend/internal/services"
+	wsproto "chat-ecommerce-backend/pkg/websocket"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -12,10 +21,42 @@ import (
 	"github.com/gorilla/websocket"
 )
 
+// chatSessionCookieMaxAge is how long the browser keeps the chat session cookie
+const chatSessionCookieMaxAge = 30 * 24 * time.Hour
+
 // ChatHandler handles chat-related HTTP requests and WebSocket connections
 type ChatHandler struct {
-	chatService *services.ChatService
-	upgrader    websocket.Upgrader
+	chatService     *services.ChatService
+	maintenance     *services.MaintenanceService
+	upgrader        websocket.Upgrader
+	tokens          *services.ChatSessionTokens
+	cookies         SessionCookieConfig
+	historyThrottle *services.RequestThrottle
+	clickstream     *services.ClickstreamService
+	jobs            *services.JobService
+	replay          *wsproto.ReplayGuard
+	schemas         *wsproto.SchemaRegistry
+
+	// Open WebSocket connections by session and by signed in user, for
+	// server-initiated messages
+	connMu    sync.RWMutex
+	conns     map[string]map[*chatConn]struct{}
+	userConns map[uuid.UUID]map[*chatConn]struct{}
+}
+
+// chatConn serialises writes to a WebSocket connection, which background
+// notifications may write to alongside the chat loop
+type chatConn struct {
+	*websocket.Conn
+	mu     sync.Mutex
+	userID *uuid.UUID
+}
+
+// WriteJSON writes a message to the connection
+func (c *chatConn) WriteJSON(v interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.Conn.WriteJSON(v)
 }
 
 // NewChatHandler creates a new ChatHandler
@@ -27,9 +68,63 @@ func NewChatHandler(chatService *services.ChatService) *ChatHandler {
 				return true // Allow all origins in development
 			},
 		},
+		tokens:          services.ChatSessionTokensFromEnv(),
+		cookies:         SessionCookieConfigFromEnv(),
+		historyThrottle: services.ChatHistoryThrottleFromEnv(),
+		conns:           make(map[string]map[*chatConn]struct{}),
+		userConns:       make(map[uuid.UUID]map[*chatConn]struct{}),
+	}
+}
+
+// WithMaintenance tells new connections about planned maintenance and pauses
+// chat messages while it's in progress
+func (h *ChatHandler) WithMaintenance(maintenance *services.MaintenanceService) *ChatHandler {
+	h.maintenance = maintenance
+	return h
+}
+
+// WithClickstream stitches the storefront session of requests to the chat
+// session they're proven to own, so storefront events count towards the chat
+func (h *ChatHandler) WithClickstream(clickstream *services.ClickstreamService) *ChatHandler {
+	h.clickstream = clickstream
+	return h
+}
+
+// WithJobs lets users delete their chat history, which runs as a background
+// job
+func (h *ChatHandler) WithJobs(jobs *services.JobService) *ChatHandler {
+	h.jobs = jobs
+	return h
+}
+
+// WithReplayGuard refuses chat messages sent again from a captured
+// connection, or whose timestamp is stale. Chat messages are guarded like
+// cart mutations, as the assistant changes the cart on the shopper's word.
+func (h *ChatHandler) WithReplayGuard(guard *wsproto.ReplayGuard) *ChatHandler {
+	h.replay = guard.WithCriticalTypes(chatMessageType)
+	return h
+}
+
+// WithSchemaValidation refuses inbound frames that don't match their schema.
+// The socket's chat messages and typing indicators carry the data of the
+// registry's chat_message and chat_typing messages.
+func (h *ChatHandler) WithSchemaValidation(registry *wsproto.SchemaRegistry) *ChatHandler {
+	if schema, ok := registry.Schema(wsproto.MessageTypeChatMessage); ok {
+		registry.Register(chatMessageType, schema)
+	}
+	if schema, ok := registry.Schema(wsproto.MessageTypeChatTyping); ok {
+		registry.Register(chatTypingType, schema)
 	}
+	h.schemas = registry
+	return h
 }
 
+// Types of the frames shoppers send on the socket
+const (
+	chatMessageType = "message"
+	chatTypingType  = "typing"
+)
+
 // ChatMessage represents a chat message
 type ChatMessage struct {
 	ID        string                 `json:"id"`
@@ -47,18 +142,50 @@ type ChatRequest struct {
 	SessionID string `json:"session_id"`
 }
 
+// ChatEventRequest reports what the shopper did with a product suggestion
+type ChatEventRequest struct {
+	SessionID string    `json:"session_id" binding:"required"`
+	Type      string    `json:"type" binding:"required"` // suggestion_clicked or add_to_cart
+	ProductID uuid.UUID `json:"product_id" binding:"required"`
+}
+
 // ChatResponse represents a chat response
 type ChatResponse struct {
-	Message     string                       `json:"message"`
-	Actions     []services.ChatAction        `json:"actions,omitempty"`
-	Suggestions []services.ProductSuggestion `json:"suggestions,omitempty"`
-	Context     map[string]interface{}       `json:"context,omitempty"`
-	Error       string                       `json:"error,omitempty"`
+	SessionID   string                     `json:"session_id"` // a new session when the requested one isn't the sender's
+	Message     string                     `json:"message"`
+	Intent      *services.MessageIntent    `json:"intent,omitempty"`   // what the shopper's message was about
+	Language    string                     `json:"language,omitempty"` // the language the shopper writes in, when known
+	Actions     []services.ChatAction      `json:"actions,omitempty"`
+	Suggestions []dto.ProductSuggestionDTO `json:"suggestions,omitempty"`
+	Context     map[string]interface{}     `json:"context,omitempty"`
+	Handoff     *services.ChatHandoff      `json:"handoff,omitempty"` // set once the chat is handed to a person
+	Error       string                     `json:"error,omitempty"`
+}
+
+// ChatDelta is a piece of an assistant reply streamed as the model writes it.
+// The final "message" with the same ID replaces the streamed text.
+type ChatDelta struct {
+	ID    string `json:"id"`
+	Delta string `json:"delta"`
 }
 
-// WebSocketMessage represents a WebSocket message
+// TypingIndicator tells whether the assistant is writing a reply
+type TypingIndicator struct {
+	IsTyping bool `json:"is_typing"`
+}
+
+// ChatError is a chat message that couldn't be answered
+type ChatError struct {
+	Message    string `json:"message"`
+	Code       string `json:"code,omitempty"`        // chat_rate_limited when the shopper is sending too fast, invalid_message for a malformed frame, stale_message or replayed_message for a refused replay
+	RetryAfter int    `json:"retry_after,omitempty"` // seconds until a limited shopper may send again
+}
+
+// WebSocketMessage represents a WebSocket message. The message types the
+// server sends and their data are listed in tsgen.ChatContract, which the
+// frontend's types are generated from.
 type WebSocketMessage struct {
-	Type      string      `json:"type"` // "message", "typing", "error"
+	Type      string      `json:"type"` // "message", "chat_response", "typing", "error"
 	Data      interface{} `json:"data"`
 	SessionID string      `json:"session_id"`
 	UserID    *string     `json:"user_id,omitempty"`
@@ -66,26 +193,35 @@ type WebSocketMessage struct {
 
 // HandleWebSocket handles WebSocket connections for real-time chat
 func (h *ChatHandler) HandleWebSocket(c *gin.Context) {
-	conn, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
+	userID := requestUserID(c)
+
+	// Continue the requested session if it's the shopper's, otherwise start
+	// a new one and hand its cookie over with the upgrade
+	sessionID, started, err := h.resolveSession(c, c.Query("session_id"), userID)
+	if err != nil {
+		log.Printf("Failed to start chat session: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start chat session"})
+		return
+	}
+	var header http.Header
+	if started {
+		header = http.Header{"Set-Cookie": []string{h.sessionCookie(sessionID).String()}}
+	}
+
+	wsConn, err := h.upgrader.Upgrade(c.Writer, c.Request, header)
 	if err != nil {
 		log.Printf("Failed to upgrade WebSocket connection: %v", err)
 		return
 	}
+	conn := &chatConn{Conn: wsConn, userID: userID}
 	defer conn.Close()
 
-	// Get session ID from query parameters
-	sessionID := c.Query("session_id")
-	if sessionID == "" {
-		sessionID = uuid.New().String()
-	}
+	h.register(sessionID, conn)
+	defer h.unregister(sessionID, conn)
 
-	// Get user ID from context (if authenticated)
-	var userID *uuid.UUID
-	if userIDStr, exists := c.Get("user_id"); exists {
-		if id, ok := userIDStr.(uuid.UUID); ok {
-			userID = &id
-		}
-	}
+	// ?fields= trims the products in suggestions for the whole connection,
+	// e.g. fields=name,price,images for a compact chat card
+	fields := parseFields(c.Query("fields"))
 
 	log.Printf("WebSocket connection established for session: %s", sessionID)
 
@@ -107,22 +243,40 @@ func (h *ChatHandler) HandleWebSocket(c *gin.Context) {
 		return
 	}
 
+	// Shoppers connecting during a maintenance countdown see the banner too
+	if h.maintenance != nil {
+		if status, err := h.maintenance.Status(c.Request.Context(), time.Now()); err == nil && status.Planned {
+			conn.WriteJSON(WebSocketMessage{
+				Type:      services.MaintenanceNotification,
+				Data:      services.NewMaintenanceNotice(status),
+				SessionID: sessionID,
+			})
+		}
+	}
+
 	// Handle incoming messages
 	for {
-		var wsMsg WebSocketMessage
-		err := conn.ReadJSON(&wsMsg)
+		_, frame, err := conn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				log.Printf("WebSocket error: %v", err)
 			}
 			break
 		}
+		if !h.checkSchema(conn, frame, sessionID) || !h.checkReplay(conn, frame, sessionID) {
+			continue
+		}
+		var wsMsg WebSocketMessage
+		if err := json.Unmarshal(frame, &wsMsg); err != nil {
+			h.sendError(conn, "Invalid message", sessionID)
+			continue
+		}
 
 		// Handle different message types
 		switch wsMsg.Type {
-		case "message":
-			h.handleChatMessage(conn, wsMsg, sessionID, userID)
-		case "typing":
+		case chatMessageType:
+			h.handleChatMessage(c.Request.Context(), conn, wsMsg, sessionID, userID, fields)
+		case chatTypingType:
 			h.handleTypingIndicator(conn, wsMsg)
 		default:
 			log.Printf("Unknown message type: %s", wsMsg.Type)
@@ -130,8 +284,55 @@ func (h *ChatHandler) HandleWebSocket(c *gin.Context) {
 	}
 }
 
+// checkSchema reports whether a frame matches its schema, telling the sender
+// what's wrong when it doesn't
+func (h *ChatHandler) checkSchema(conn *chatConn, frame []byte, sessionID string) bool {
+	if h.schemas == nil {
+		return true
+	}
+	if err := h.schemas.ValidateJSON(frame); err != nil {
+		conn.WriteJSON(WebSocketMessage{
+			Type:      "error",
+			Data:      ChatError{Message: err.Error(), Code: "invalid_message"},
+			SessionID: sessionID,
+		})
+		return false
+	}
+	return true
+}
+
+// checkReplay reports whether a frame passes the replay guard, telling the
+// sender why when it doesn't. Frames are checked against the connection's
+// session, the one its welcome message names.
+func (h *ChatHandler) checkReplay(conn *chatConn, frame []byte, sessionID string) bool {
+	if h.replay == nil {
+		return true
+	}
+	var msg wsproto.WebSocketMessage
+	err := json.Unmarshal(frame, &msg)
+	if err == nil {
+		err = h.replay.Check(sessionID, &msg, time.Now())
+	}
+	if err == nil {
+		return true
+	}
+
+	code := "invalid_message"
+	var replayErr *wsproto.ReplayError
+	if errors.As(err, &replayErr) {
+		code = replayErr.Code
+		log.Printf("Refused %s on chat session %s: %v", msg.Type, sessionID, err)
+	}
+	conn.WriteJSON(WebSocketMessage{
+		Type:      "error",
+		Data:      ChatError{Message: err.Error(), Code: code},
+		SessionID: sessionID,
+	})
+	return false
+}
+
 // handleChatMessage processes a chat message
-func (h *ChatHandler) handleChatMessage(conn *websocket.Conn, wsMsg WebSocketMessage, sessionID string, userID *uuid.UUID) {
+func (h *ChatHandler) handleChatMessage(ctx context.Context, conn *chatConn, wsMsg WebSocketMessage, sessionID string, userID *uuid.UUID, fields fieldSet) {
 	// Extract message content
 	msgData, ok := wsMsg.Data.(map[string]interface{})
 	if !ok {
@@ -145,11 +346,41 @@ func (h *ChatHandler) handleChatMessage(conn *websocket.Conn, wsMsg WebSocketMes
 		return
 	}
 
+	if h.maintenance != nil {
+		if refused, _ := h.maintenance.RefusesWrites(ctx, time.Now()); refused {
+			h.sendError(conn, "The store is down for maintenance. Please try again shortly.", sessionID)
+			return
+		}
+	}
+
 	// Send typing indicator
 	h.sendTypingIndicator(conn, sessionID, true)
 
-	// Process message with chat service
-	response, err := h.chatService.ProcessMessage(sessionID, userID, content)
+	// Stream the reply as chat_response deltas while the model writes it,
+	// the typing indicator stopping at the first one
+	messageID := uuid.New().String()
+	typing := true
+	response, err := h.chatService.StreamMessage(ctx, sessionID, userID, content, func(delta string) error {
+		if typing {
+			h.sendTypingIndicator(conn, sessionID, false)
+			typing = false
+		}
+		return conn.WriteJSON(WebSocketMessage{
+			Type:      "chat_response",
+			Data:      ChatDelta{ID: messageID, Delta: delta},
+			SessionID: sessionID,
+		})
+	})
+	var limitErr *services.ChatLimitError
+	if errors.As(err, &limitErr) {
+		h.sendTypingIndicator(conn, sessionID, false)
+		conn.WriteJSON(WebSocketMessage{
+			Type:      "error",
+			Data:      ChatError{Message: limitErr.Message(), Code: limitErr.Limit, RetryAfter: limitErr.RetryAfterSeconds()},
+			SessionID: sessionID,
+		})
+		return
+	}
 	if err != nil {
 		log.Printf("Failed to process chat message: %v", err)
 		h.sendError(conn, "Failed to process message", sessionID)
@@ -157,19 +388,35 @@ func (h *ChatHandler) handleChatMessage(conn *websocket.Conn, wsMsg WebSocketMes
 	}
 
 	// Stop typing indicator
-	h.sendTypingIndicator(conn, sessionID, false)
+	if typing {
+		h.sendTypingIndicator(conn, sessionID, false)
+	}
+
+	// Messages to an agent who joined the chat are answered by them
+	if response.Handoff != nil && response.Message == "" {
+		return
+	}
+
+	cards := convertToSuggestionDTOs(response.Suggestions)
+	suggestions, err := fields.selectAt(cards, "product")
+	if err != nil {
+		log.Printf("Failed to select suggestion fields: %v", err)
+		suggestions = cards
+	}
 
 	// Send response
 	responseMsg := WebSocketMessage{
 		Type: "message",
 		Data: ChatMessage{
-			ID:        uuid.New().String(),
+			ID:        messageID,
 			SessionID: sessionID,
 			Role:      "assistant",
 			Content:   response.Message,
 			Metadata: map[string]interface{}{
+				"intent":      response.Intent,
+				"language":    response.Language,
 				"actions":     response.Actions,
-				"suggestions": response.Suggestions,
+				"suggestions": suggestions,
 			},
 			Timestamp: time.Now().Format(time.RFC3339),
 		},
@@ -195,7 +442,7 @@ func (h *ChatHandler) handleChatMessage(conn *websocket.Conn, wsMsg WebSocketMes
 	if len(response.Suggestions) > 0 {
 		suggestionsMsg := WebSocketMessage{
 			Type:      "suggestions",
-			Data:      response.Suggestions,
+			Data:      suggestions,
 			SessionID: sessionID,
 		}
 		conn.WriteJSON(suggestionsMsg)
@@ -203,35 +450,116 @@ func (h *ChatHandler) handleChatMessage(conn *websocket.Conn, wsMsg WebSocketMes
 }
 
 // handleTypingIndicator handles typing indicators
-func (h *ChatHandler) handleTypingIndicator(conn *websocket.Conn, wsMsg WebSocketMessage) {
+func (h *ChatHandler) handleTypingIndicator(conn *chatConn, wsMsg WebSocketMessage) {
 	// Echo typing indicator to other clients (in a real app, you'd broadcast to other clients)
 	// For now, just acknowledge
 }
 
 // sendTypingIndicator sends a typing indicator
-func (h *ChatHandler) sendTypingIndicator(conn *websocket.Conn, sessionID string, isTyping bool) {
+func (h *ChatHandler) sendTypingIndicator(conn *chatConn, sessionID string, isTyping bool) {
 	typingMsg := WebSocketMessage{
-		Type: "typing",
-		Data: map[string]interface{}{
-			"is_typing": isTyping,
-		},
+		Type:      "typing",
+		Data:      TypingIndicator{IsTyping: isTyping},
 		SessionID: sessionID,
 	}
 	conn.WriteJSON(typingMsg)
 }
 
 // sendError sends an error message
-func (h *ChatHandler) sendError(conn *websocket.Conn, message string, sessionID string) {
+func (h *ChatHandler) sendError(conn *chatConn, message string, sessionID string) {
 	errorMsg := WebSocketMessage{
-		Type: "error",
-		Data: map[string]interface{}{
-			"message": message,
-		},
+		Type:      "error",
+		Data:      ChatError{Message: message},
 		SessionID: sessionID,
 	}
 	conn.WriteJSON(errorMsg)
 }
 
+// NotifySession sends a server-initiated message to every open connection of a session
+func (h *ChatHandler) NotifySession(sessionID, messageType string, data interface{}) {
+	h.connMu.RLock()
+	conns := make([]*chatConn, 0, len(h.conns[sessionID]))
+	for conn := range h.conns[sessionID] {
+		conns = append(conns, conn)
+	}
+	h.connMu.RUnlock()
+
+	msg := WebSocketMessage{
+		Type:      messageType,
+		Data:      data,
+		SessionID: sessionID,
+	}
+	for _, conn := range conns {
+		if err := conn.WriteJSON(msg); err != nil {
+			log.Printf("Failed to send %s to session %s: %v", messageType, sessionID, err)
+		}
+	}
+}
+
+// NotifyUser sends a server-initiated message to every open connection of a
+// signed in user, whichever session it's in
+func (h *ChatHandler) NotifyUser(userID uuid.UUID, messageType string, data interface{}) {
+	h.connMu.RLock()
+	conns := make([]*chatConn, 0, len(h.userConns[userID]))
+	for conn := range h.userConns[userID] {
+		conns = append(conns, conn)
+	}
+	h.connMu.RUnlock()
+
+	userIDString := userID.String()
+	msg := WebSocketMessage{
+		Type:   messageType,
+		Data:   data,
+		UserID: &userIDString,
+	}
+	for _, conn := range conns {
+		if err := conn.WriteJSON(msg); err != nil {
+			log.Printf("Failed to send %s to user %s: %v", messageType, userID, err)
+		}
+	}
+}
+
+// ConnectedSessions returns the sessions with at least one open connection
+func (h *ChatHandler) ConnectedSessions() []string {
+	h.connMu.RLock()
+	defer h.connMu.RUnlock()
+	sessionIDs := make([]string, 0, len(h.conns))
+	for sessionID := range h.conns {
+		sessionIDs = append(sessionIDs, sessionID)
+	}
+	return sessionIDs
+}
+
+func (h *ChatHandler) register(sessionID string, conn *chatConn) {
+	h.connMu.Lock()
+	defer h.connMu.Unlock()
+	if h.conns[sessionID] == nil {
+		h.conns[sessionID] = make(map[*chatConn]struct{})
+	}
+	h.conns[sessionID][conn] = struct{}{}
+	if conn.userID != nil {
+		if h.userConns[*conn.userID] == nil {
+			h.userConns[*conn.userID] = make(map[*chatConn]struct{})
+		}
+		h.userConns[*conn.userID][conn] = struct{}{}
+	}
+}
+
+func (h *ChatHandler) unregister(sessionID string, conn *chatConn) {
+	h.connMu.Lock()
+	defer h.connMu.Unlock()
+	delete(h.conns[sessionID], conn)
+	if len(h.conns[sessionID]) == 0 {
+		delete(h.conns, sessionID)
+	}
+	if conn.userID != nil {
+		delete(h.userConns[*conn.userID], conn)
+		if len(h.userConns[*conn.userID]) == 0 {
+			delete(h.userConns, *conn.userID)
+		}
+	}
+}
+
 // SendMessage handles HTTP POST requests for sending messages
 func (h *ChatHandler) SendMessage(c *gin.Context) {
 	var req ChatRequest
@@ -240,43 +568,160 @@ func (h *ChatHandler) SendMessage(c *gin.Context) {
 		return
 	}
 
-	// Get user ID from context (if authenticated)
-	var userID *uuid.UUID
-	if userIDStr, exists := c.Get("user_id"); exists {
-		if id, ok := userIDStr.(uuid.UUID); ok {
-			userID = &id
-		}
+	userID := requestUserID(c)
+	sessionID, started, err := h.resolveSession(c, req.SessionID, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
 	}
-
-	// Generate session ID if not provided
-	sessionID := req.SessionID
-	if sessionID == "" {
-		sessionID = uuid.New().String()
+	if started {
+		http.SetCookie(c.Writer, h.sessionCookie(sessionID))
 	}
 
 	// Process message
-	response, err := h.chatService.ProcessMessage(sessionID, userID, req.Message)
+	response, err := h.chatService.ProcessMessage(c.Request.Context(), sessionID, userID, req.Message)
 	if err != nil {
+		if respondChatLimit(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	jsonWithFields(c, http.StatusOK, gin.H{
 		"success": true,
-		"data":    response,
+		"data": ChatResponse{
+			SessionID:   sessionID,
+			Message:     response.Message,
+			Intent:      response.Intent,
+			Language:    response.Language,
+			Actions:     response.Actions,
+			Suggestions: convertToSuggestionDTOs(response.Suggestions),
+			Context:     response.Context,
+			Handoff:     response.Handoff,
+			Error:       response.Error,
+		},
+	}, "data", "suggestions", "product")
+}
+
+// respondChatLimit writes a 429 with Retry-After telling the shopper to slow
+// down if err is a chat limit, and reports whether it did
+func respondChatLimit(c *gin.Context, err error) bool {
+	var limitErr *services.ChatLimitError
+	if !errors.As(err, &limitErr) {
+		return false
+	}
+	c.Header("Retry-After", strconv.Itoa(limitErr.RetryAfterSeconds()))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error":       limitErr.Message(),
+		"code":        limitErr.Limit,
+		"retry_after": limitErr.RetryAfterSeconds(),
+	})
+	return true
+}
+
+// StreamChatMessage handles GET /api/v1/chat/stream?message=...&session_id=...
+// for clients without WebSockets. It answers with server-sent events: a
+// session event naming the conversation, a delta event for each piece of the
+// reply as the model writes it and a message event with the final reply, its
+// actions and the product suggestions. A failure once streaming started
+// arrives as an error event, as does a chat limit the shopper reached.
+func (h *ChatHandler) StreamChatMessage(c *gin.Context) {
+	message := strings.TrimSpace(c.Query("message"))
+	if message == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "message is required"})
+		return
+	}
+	ctx := c.Request.Context()
+	if h.maintenance != nil {
+		if refused, _ := h.maintenance.RefusesWrites(ctx, time.Now()); refused {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "The store is down for maintenance"})
+			return
+		}
+	}
+
+	userID := requestUserID(c)
+	sessionID, started, err := h.resolveSession(c, c.Query("session_id"), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if started {
+		http.SetCookie(c.Writer, h.sessionCookie(sessionID))
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // proxies would otherwise hold the deltas back
+	c.Status(http.StatusOK)
+	c.SSEvent("session", gin.H{"session_id": sessionID})
+	c.Writer.Flush()
+
+	response, err := h.chatService.StreamMessage(ctx, sessionID, userID, message, func(delta string) error {
+		c.SSEvent("delta", gin.H{"delta": delta})
+		c.Writer.Flush()
+		// Stop generating once the client went away
+		return ctx.Err()
 	})
+	var limitErr *services.ChatLimitError
+	if errors.As(err, &limitErr) {
+		c.SSEvent("error", ChatError{Message: limitErr.Message(), Code: limitErr.Limit, RetryAfter: limitErr.RetryAfterSeconds()})
+		c.Writer.Flush()
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to process streamed chat message: %v", err)
+		c.SSEvent("error", gin.H{"error": "Failed to process message"})
+		c.Writer.Flush()
+		return
+	}
+
+	final, err := parseFields(c.Query("fields")).selectAt(ChatResponse{
+		SessionID:   sessionID,
+		Message:     response.Message,
+		Intent:      response.Intent,
+		Language:    response.Language,
+		Actions:     response.Actions,
+		Suggestions: convertToSuggestionDTOs(response.Suggestions),
+		Context:     response.Context,
+		Handoff:     response.Handoff,
+		Error:       response.Error,
+	}, "suggestions", "product")
+	if err != nil {
+		c.SSEvent("error", gin.H{"error": err.Error()})
+		c.Writer.Flush()
+		return
+	}
+	c.SSEvent("message", final)
+	c.Writer.Flush()
 }
 
-// GetChatHistory retrieves chat history for a session
+// GetChatHistory retrieves chat history for a session the requester owns.
+// Reads are throttled per client address against guessing session IDs, and
+// the rate limit headers report this throttle.
 func (h *ChatHandler) GetChatHistory(c *gin.Context) {
+	status, allowed := h.historyThrottle.Take(c.ClientIP(), time.Now())
+	if h.historyThrottle.Limited() {
+		status.WriteHeaders(c.Writer.Header())
+	}
+	if !allowed {
+		c.Header("Retry-After", strconv.Itoa(int(status.Reset.Seconds())+1))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many requests, please try again later"})
+		return
+	}
+
 	sessionID := c.Param("session_id")
 	if sessionID == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "session_id is required"})
 		return
 	}
+	if !h.authorizeRead(c, sessionID) {
+		return
+	}
 
 	// Get conversation history
-	history, err := h.chatService.GetConversationHistory(sessionID, 50)
+	history, err := h.chatService.GetConversationHistory(c.Request.Context(), sessionID, 50)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -298,26 +743,22 @@ func (h *ChatHandler) GetProductSuggestions(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "session_id is required"})
 		return
 	}
-
-	// Get user ID from context (if authenticated)
-	var userID *uuid.UUID
-	if userIDStr, exists := c.Get("user_id"); exists {
-		if id, ok := userIDStr.(uuid.UUID); ok {
-			userID = &id
-		}
+	if !h.authorizeRead(c, sessionID) {
+		return
 	}
+	userID := requestUserID(c)
 
 	// Get recommendations
-	suggestions, err := h.chatService.GetProductRecommendations(sessionID, userID, 10)
+	suggestions, err := h.chatService.GetProductRecommendations(c.Request.Context(), sessionID, userID, 10)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	jsonWithFields(c, http.StatusOK, gin.H{
 		"success": true,
-		"data":    suggestions,
-	})
+		"data":    convertToSuggestionDTOs(suggestions),
+	}, "data", "product")
 }
 
 // SearchProducts searches for products based on natural language query
@@ -336,35 +777,75 @@ func (h *ChatHandler) SearchProducts(c *gin.Context) {
 	}
 
 	// Search products
-	suggestions, err := h.chatService.SearchProducts(query, limit)
+	suggestions, err := h.chatService.SearchProducts(c.Request.Context(), query, limit)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	jsonWithFields(c, http.StatusOK, gin.H{
 		"success": true,
-		"data":    suggestions,
-	})
+		"data":    convertToSuggestionDTOs(suggestions),
+	}, "data", "product")
 }
 
-// GetChatSession retrieves or creates a chat session
-func (h *ChatHandler) GetChatSession(c *gin.Context) {
-	sessionID := c.Param("session_id")
-	if sessionID == "" {
-		sessionID = uuid.New().String()
+// TrackEvent handles POST /api/v1/chat/events, recording a product
+// suggestion the shopper clicked or added to the cart from its card
+func (h *ChatHandler) TrackEvent(c *gin.Context) {
+	var req ChatEventRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !h.authorizeRead(c, req.SessionID) {
+		return
 	}
 
-	// Get user ID from context (if authenticated)
-	var userID *uuid.UUID
-	if userIDStr, exists := c.Get("user_id"); exists {
-		if id, ok := userIDStr.(uuid.UUID); ok {
-			userID = &id
+	err := h.chatService.TrackSuggestionEvent(c.Request.Context(), req.SessionID, requestUserID(c), req.Type, req.ProductID)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrInvalidChatEvent) {
+			status = http.StatusBadRequest
 		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"success": true})
+}
+
+// StartChatSession handles POST /api/v1/chat/session, starting a session
+// owned by the signed in user or, for anonymous shoppers, the cookie it sets
+func (h *ChatHandler) StartChatSession(c *gin.Context) {
+	userID := requestUserID(c)
+	sessionID, _, err := h.resolveSession(c, "", userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	session, err := h.chatService.GetChatSession(c.Request.Context(), sessionID, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	http.SetCookie(c.Writer, h.sessionCookie(sessionID))
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"data":    session,
+	})
+}
+
+// GetChatSession retrieves a chat session the requester owns
+func (h *ChatHandler) GetChatSession(c *gin.Context) {
+	sessionID := c.Param("session_id")
+	if !h.authorizeRead(c, sessionID) {
+		return
 	}
+	userID := requestUserID(c)
 
 	// Get or create session
-	session, err := h.chatService.GetChatSession(sessionID, userID)
+	session, err := h.chatService.GetChatSession(c.Request.Context(), sessionID, userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -376,7 +857,183 @@ func (h *ChatHandler) GetChatSession(c *gin.Context) {
 	})
 }
 
+// ListUserChatSessions handles GET /api/v1/user/chat-sessions, listing the
+// signed in user's past conversations from every device, most recent first
+func (h *ChatHandler) ListUserChatSessions(c *gin.Context) {
+	userID := requestUserID(c)
+	if userID == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+	sessions, err := h.chatService.ListUserSessions(c.Request.Context(), *userID, page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    sessions,
+	})
+}
+
+// ResumeUserChatSession handles POST /api/v1/user/chat-sessions/:session_id/resume,
+// reopening one of the user's conversations with its recent messages so it
+// can be continued on this device
+func (h *ChatHandler) ResumeUserChatSession(c *gin.Context) {
+	userID := requestUserID(c)
+	if userID == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	resumed, err := h.chatService.ResumeUserSession(c.Request.Context(), *userID, c.Param("session_id"), time.Now())
+	if errors.Is(err, services.ErrChatSessionForbidden) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Chat session not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    resumed,
+	})
+}
+
+// resolveSession returns the chat session a request continues: the one it
+// names when the requester owns it, or a newly started one
+func (h *ChatHandler) resolveSession(c *gin.Context, requested string, userID *uuid.UUID) (string, bool, error) {
+	ctx := c.Request.Context()
+	if requested != "" {
+		cookie, _ := c.Cookie(services.ChatSessionCookie)
+		err := h.chatService.AuthorizeSession(ctx, h.tokens, requested, userID, cookie)
+		if err == nil {
+			// A shopper signing in mid-conversation keeps it with their account
+			if userID != nil {
+				if err := h.chatService.ClaimSession(ctx, requested, *userID); err != nil {
+					return "", false, err
+				}
+			}
+			h.linkStorefront(c, requested)
+			return requested, false, nil
+		}
+		if !errors.Is(err, services.ErrChatSessionForbidden) {
+			return "", false, err
+		}
+	}
+
+	sessionID, err := h.tokens.Issue()
+	if err != nil {
+		return "", false, err
+	}
+	if _, err := h.chatService.GetChatSession(ctx, sessionID, userID); err != nil {
+		return "", false, fmt.Errorf("failed to start chat session: %v", err)
+	}
+	h.linkStorefront(c, sessionID)
+	return sessionID, true, nil
+}
+
+// authorizeRead checks the requester owns the session, answering 404 when
+// they don't so other shoppers' session IDs can't be confirmed
+func (h *ChatHandler) authorizeRead(c *gin.Context, sessionID string) bool {
+	cookie, _ := c.Cookie(services.ChatSessionCookie)
+	err := h.chatService.AuthorizeSession(c.Request.Context(), h.tokens, sessionID, requestUserID(c), cookie)
+	switch {
+	case errors.Is(err, services.ErrChatSessionForbidden):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Chat session not found"})
+		return false
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+	h.linkStorefront(c, sessionID)
+	return true
+}
+
+// linkStorefront stitches the storefront session the request came from to a
+// chat session it owns
+func (h *ChatHandler) linkStorefront(c *gin.Context, sessionID string) {
+	storefront := c.GetHeader("X-Session-ID")
+	if h.clickstream == nil || storefront == "" {
+		return
+	}
+	if err := h.clickstream.Link(c.Request.Context(), storefront, sessionID, requestUserID(c)); err != nil {
+		log.Printf("Warning: failed to link storefront session: %v", err)
+	}
+}
+
+// sessionCookie is the httpOnly cookie proving an anonymous shopper owns a session
+func (h *ChatHandler) sessionCookie(sessionID string) *http.Cookie {
+	return h.cookies.cookie(services.ChatSessionCookie, sessionID, "/api/v1/chat", int(chatSessionCookieMaxAge.Seconds()), true)
+}
+
 // Helper function to parse integer from string
 func parseInt(s string) (int, error) {
 	return strconv.Atoi(s)
 }
+
+// convertToSuggestionDTOs converts suggestions to their compact product cards
+func convertToSuggestionDTOs(suggestions []services.ProductSuggestion) []dto.ProductSuggestionDTO {
+	dtos := make([]dto.ProductSuggestionDTO, 0, len(suggestions))
+	for _, suggestion := range suggestions {
+		if suggestion.Product == nil {
+			continue
+		}
+		dtos = append(dtos, dto.ProductSuggestionDTO{
+			Product:    convertToProductCardDTO(suggestion.Product),
+			Reason:     suggestion.Reason,
+			Confidence: suggestion.Confidence,
+		})
+	}
+	return dtos
+}
+
+// convertToProductCardDTO keeps what a product card shows: the primary image
+// (or the first one), the category name, whether any stock is left and the
+// variant options. Products without inventory records aren't tracked and are
+// in stock.
+func convertToProductCardDTO(product *models.Product) dto.ProductCardDTO {
+	card := dto.ProductCardDTO{
+		ID:           product.ID,
+		Name:         product.Name,
+		Price:        product.Price,
+		ListPrice:    product.ListPrice,
+		CategoryName: product.Category.Name,
+		InStock:      len(product.Inventory) == 0,
+	}
+
+	for i, image := range product.Images {
+		if image.IsPrimary || i == 0 {
+			card.ImageURL = image.URL
+		}
+		if image.IsPrimary {
+			break
+		}
+	}
+
+	for _, inventory := range product.Inventory {
+		if inventory.QuantityAvailable-inventory.QuantityReserved > 0 {
+			card.InStock = true
+			break
+		}
+	}
+
+	options := make(map[string]int)
+	for _, variant := range product.Variants {
+		i, ok := options[variant.VariantName]
+		if !ok {
+			i = len(card.Variants)
+			options[variant.VariantName] = i
+			card.Variants = append(card.Variants, dto.VariantOptionDTO{Name: variant.VariantName})
+		}
+		card.Variants[i].Values = append(card.Variants[i].Values, variant.VariantValue)
+	}
+	return card
+}
@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"chat-ecommerce-backend/internal/services"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ChatHandoffHandler lets admins take over shoppers' chats handed to a person
+type ChatHandoffHandler struct {
+	chatService *services.ChatService
+}
+
+// NewChatHandoffHandler creates a new ChatHandoffHandler
+func NewChatHandoffHandler(chatService *services.ChatService) *ChatHandoffHandler {
+	return &ChatHandoffHandler{
+		chatService: chatService,
+	}
+}
+
+// AgentMessageRequest is an admin's reply in a chat they joined
+type AgentMessageRequest struct {
+	Content string `json:"content" binding:"required"`
+}
+
+// GetHandoffs handles GET /api/v1/admin/chat/handoffs, optionally filtered by
+// status (needs_agent or with_agent)
+func (h *ChatHandoffHandler) GetHandoffs(c *gin.Context) {
+	status := c.Query("status")
+	switch status {
+	case "", services.ChatSessionNeedsAgent, services.ChatSessionWithAgent:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be needs_agent or with_agent"})
+		return
+	}
+
+	handoffs, err := h.chatService.ListHandoffs(c.Request.Context(), status)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    handoffs,
+	})
+}
+
+// GetSessionMessages handles GET /api/v1/admin/chat/sessions/:session_id/messages,
+// the conversation so far for an admin joining it
+func (h *ChatHandoffHandler) GetSessionMessages(c *gin.Context) {
+	messages, err := h.chatService.GetConversationHistory(c.Request.Context(), c.Param("session_id"), 100)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    messages,
+	})
+}
+
+// JoinSession handles POST /api/v1/admin/chat/sessions/:session_id/join. The
+// assistant stops answering and the shopper's messages go to the admin.
+func (h *ChatHandoffHandler) JoinSession(c *gin.Context) {
+	agentID := requestUserID(c)
+	if agentID == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	handoff, err := h.chatService.JoinSession(c.Request.Context(), c.Param("session_id"), *agentID)
+	if err != nil {
+		c.JSON(chatHandoffErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    handoff,
+	})
+}
+
+// SendAgentMessage handles POST /api/v1/admin/chat/sessions/:session_id/messages,
+// an admin's reply to the shopper of a chat they joined
+func (h *ChatHandoffHandler) SendAgentMessage(c *gin.Context) {
+	agentID := requestUserID(c)
+	if agentID == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	var req AgentMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if strings.TrimSpace(req.Content) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "content is required"})
+		return
+	}
+
+	message, err := h.chatService.SendAgentMessage(c.Request.Context(), c.Param("session_id"), *agentID, req.Content)
+	if err != nil {
+		c.JSON(chatHandoffErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"data":    message,
+	})
+}
+
+// ReleaseSession handles POST /api/v1/admin/chat/sessions/:session_id/release,
+// handing the chat back to the assistant
+func (h *ChatHandoffHandler) ReleaseSession(c *gin.Context) {
+	handoff, err := h.chatService.ReleaseSession(c.Request.Context(), c.Param("session_id"))
+	if err != nil {
+		c.JSON(chatHandoffErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    handoff,
+	})
+}
+
+// chatHandoffErrorStatus maps chat handoff errors to HTTP statuses
+func chatHandoffErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrHandoffNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrHandoffTaken):
+		return http.StatusConflict
+	case errors.Is(err, services.ErrNotSessionAgent):
+		return http.StatusForbidden
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"chat-ecommerce-backend/internal/services"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ChatImageRequest is a chat message with a photo: uploaded as the "image"
+// form field, or linked with image_url
+type ChatImageRequest struct {
+	Message   string `form:"message" json:"message"`
+	SessionID string `form:"session_id" json:"session_id"`
+	ImageURL  string `form:"image_url" json:"image_url"`
+}
+
+// SendImageMessage handles POST /api/v1/chat/image: the assistant answers the
+// message and suggests the products that look most like the photo. The
+// vision model's reading of the photo is in the response's context.image.
+func (h *ChatHandler) SendImageMessage(c *gin.Context) {
+	var req ChatImageRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	image := services.ChatImage{URL: strings.TrimSpace(req.ImageURL)}
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		if header, err := c.FormFile("image"); err == nil {
+			file, err := header.Open()
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			image.Data, err = io.ReadAll(file)
+			file.Close()
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+	}
+
+	userID := requestUserID(c)
+	sessionID, started, err := h.resolveSession(c, req.SessionID, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if started {
+		http.SetCookie(c.Writer, h.sessionCookie(sessionID))
+	}
+
+	response, err := h.chatService.ProcessImageMessage(c.Request.Context(), sessionID, userID, req.Message, image)
+	if err != nil {
+		if respondChatLimit(c, err) {
+			return
+		}
+		c.JSON(chatImageErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	jsonWithFields(c, http.StatusOK, gin.H{
+		"success": true,
+		"data": ChatResponse{
+			SessionID:   sessionID,
+			Message:     response.Message,
+			Intent:      response.Intent,
+			Language:    response.Language,
+			Actions:     response.Actions,
+			Suggestions: convertToSuggestionDTOs(response.Suggestions),
+			Context:     response.Context,
+			Handoff:     response.Handoff,
+			Error:       response.Error,
+		},
+	}, "data", "suggestions", "product")
+}
+
+// chatImageErrorStatus maps image search errors to HTTP status codes
+func chatImageErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrInvalidChatImage):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrChatImageTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, services.ErrVisualSearchUnavailable):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
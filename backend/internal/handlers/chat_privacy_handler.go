@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"chat-ecommerce-backend/internal/services"
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ExportUserChatHistory handles GET /api/v1/user/chat-history/export?format=json|pdf,
+// every conversation the signed in user had, to download
+func (h *ChatHandler) ExportUserChatHistory(c *gin.Context) {
+	userID := requestUserID(c)
+	if userID == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "pdf" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be json or pdf"})
+		return
+	}
+
+	now := time.Now()
+	export, err := h.chatService.ExportUserHistory(c.Request.Context(), *userID, now)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	filename := "chat-history-" + now.Format("2006-01-02")
+	if format == "pdf" {
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s.pdf", filename))
+		c.Data(http.StatusOK, "application/pdf", services.RenderChatHistoryPDF(export))
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s.json", filename))
+	c.JSON(http.StatusOK, export)
+}
+
+// DeleteUserChatHistory handles DELETE /api/v1/user/chat-history: every
+// conversation of the signed in user is deleted by a background job, which
+// answers 202 and can be followed at its Location
+func (h *ChatHandler) DeleteUserChatHistory(c *gin.Context) {
+	userID := requestUserID(c)
+	if userID == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	if h.jobs == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Chat history deletion is not available"})
+		return
+	}
+
+	user := *userID
+	job, err := h.jobs.Start(c.Request.Context(), services.JobKindChatPurge, &user, gin.H{"user_id": user},
+		func(ctx context.Context) (*services.JobOutput, error) {
+			result, err := h.chatService.PurgeUserHistory(ctx, user)
+			if err != nil {
+				return nil, err
+			}
+			return &services.JobOutput{Result: result}, nil
+		})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Location", fmt.Sprintf("/api/v1/user/chat-history/deletions/%s", job.ID))
+	c.JSON(http.StatusAccepted, gin.H{"success": true, "data": job})
+}
+
+// GetChatHistoryDeletion handles GET /api/v1/user/chat-history/deletions/:id,
+// the progress of one of the signed in user's chat history deletions
+func (h *ChatHandler) GetChatHistoryDeletion(c *gin.Context) {
+	userID := requestUserID(c)
+	if userID == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	jobID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid deletion ID"})
+		return
+	}
+	if h.jobs == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Deletion not found"})
+		return
+	}
+
+	job, err := h.jobs.GetJob(c.Request.Context(), jobID)
+	if err != nil {
+		c.JSON(jobErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	// Other jobs, and other users' deletions, aren't the user's to see
+	if job.Kind != services.JobKindChatPurge || job.CreatedBy == nil || *job.CreatedBy != *userID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Deletion not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": job})
+}
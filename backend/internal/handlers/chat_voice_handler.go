@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"chat-ecommerce-backend/internal/services"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ChatVoiceRequest is a voice message, recorded as the "audio" form field.
+// ReplyAudio asks for the reply to be read aloud too.
+type ChatVoiceRequest struct {
+	SessionID  string `form:"session_id"`
+	ReplyAudio bool   `form:"reply_audio"`
+}
+
+// ChatVoiceResponse answers a voice message like a typed one, with what the
+// shopper said and, when asked for, where to fetch the reply read aloud
+type ChatVoiceResponse struct {
+	ChatResponse
+	Transcript string `json:"transcript"`
+	AudioURL   string `json:"audio_url,omitempty"`
+}
+
+// SendVoiceMessage handles POST /api/v1/chat/voice: the recording is
+// transcribed and the transcript answered as a typed message
+func (h *ChatHandler) SendVoiceMessage(c *gin.Context) {
+	var req ChatVoiceRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	header, err := c.FormFile("audio")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "audio is required"})
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var audio services.ChatAudio
+	audio.Data, err = io.ReadAll(file)
+	file.Close()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := requestUserID(c)
+	sessionID, started, err := h.resolveSession(c, req.SessionID, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if started {
+		http.SetCookie(c.Writer, h.sessionCookie(sessionID))
+	}
+
+	response, err := h.chatService.ProcessVoiceMessage(c.Request.Context(), sessionID, userID, audio)
+	if err != nil {
+		if respondChatLimit(c, err) {
+			return
+		}
+		c.JSON(chatVoiceErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	data := ChatVoiceResponse{
+		ChatResponse: ChatResponse{
+			SessionID:   sessionID,
+			Message:     response.Message,
+			Intent:      response.Intent,
+			Language:    response.Language,
+			Actions:     response.Actions,
+			Suggestions: convertToSuggestionDTOs(response.Suggestions),
+			Context:     response.Context,
+			Handoff:     response.Handoff,
+			Error:       response.Error,
+		},
+		Transcript: response.Transcript,
+	}
+	if req.ReplyAudio && response.ReplyID != nil && h.chatService.CanSpeak() {
+		data.AudioURL = "/api/v1/chat/voice/" + url.PathEscape(sessionID) + "/replies/" + response.ReplyID.String()
+	}
+
+	jsonWithFields(c, http.StatusOK, gin.H{
+		"success": true,
+		"data":    data,
+	}, "data", "suggestions", "product")
+}
+
+// GetVoiceReply handles GET /api/v1/chat/voice/:session_id/replies/:message_id,
+// a reply in the session read aloud
+func (h *ChatHandler) GetVoiceReply(c *gin.Context) {
+	sessionID := c.Param("session_id")
+	messageID, err := uuid.Parse(c.Param("message_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid message ID"})
+		return
+	}
+	if !h.authorizeRead(c, sessionID) {
+		return
+	}
+
+	spoken, err := h.chatService.SpeakReply(c.Request.Context(), sessionID, messageID)
+	if err != nil {
+		c.JSON(chatVoiceErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	// Replies don't change, so players seeking through one don't read it again
+	c.Header("Cache-Control", "private, max-age=3600")
+	c.Data(http.StatusOK, spoken.ContentType, spoken.Data)
+}
+
+// chatVoiceErrorStatus maps voice message errors to HTTP status codes
+func chatVoiceErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrInvalidChatAudio):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrChatAudioTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, services.ErrVoiceReplyNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrVoiceUnavailable), errors.Is(err, services.ErrSpeechUnavailable):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
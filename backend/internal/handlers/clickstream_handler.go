@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"chat-ecommerce-backend/internal/services"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ClickstreamHandler takes storefront clickstream events and reports how chat
+// experiment variants engage with the storefront
+type ClickstreamHandler struct {
+	clickstream *services.ClickstreamService
+	chatService *services.ChatService
+	tokens      *services.ChatSessionTokens
+}
+
+// NewClickstreamHandler creates a new ClickstreamHandler
+func NewClickstreamHandler(clickstream *services.ClickstreamService, chatService *services.ChatService) *ClickstreamHandler {
+	return &ClickstreamHandler{
+		clickstream: clickstream,
+		chatService: chatService,
+		tokens:      services.ChatSessionTokensFromEnv(),
+	}
+}
+
+// ClickstreamRequest is a batch of storefront events
+type ClickstreamRequest struct {
+	SessionID     string                      `json:"session_id"`      // defaults to the X-Session-ID header
+	ChatSessionID string                      `json:"chat_session_id"` // the shopper's chat, when the storefront knows it
+	Events        []services.ClickstreamEvent `json:"events" binding:"required"`
+}
+
+// IngestEvents handles POST /api/v1/events. Events are stitched to the chat
+// session the request proves it owns, else the one the storefront session was
+// linked to by earlier chat requests.
+func (h *ClickstreamHandler) IngestEvents(c *gin.Context) {
+	var req ClickstreamRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.SessionID == "" {
+		req.SessionID = c.GetHeader("X-Session-ID")
+	}
+	userID := requestUserID(c)
+
+	// A chat session the requester can't prove is theirs is ignored rather
+	// than refused, so other shoppers' session IDs can't be confirmed
+	ctx := c.Request.Context()
+	if req.ChatSessionID != "" {
+		cookie, _ := c.Cookie(services.ChatSessionCookie)
+		err := h.chatService.AuthorizeSession(ctx, h.tokens, req.ChatSessionID, userID, cookie)
+		if errors.Is(err, services.ErrChatSessionForbidden) {
+			req.ChatSessionID = ""
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	result, err := h.clickstream.Ingest(ctx, services.ClickstreamBatch{
+		SessionID:     req.SessionID,
+		ChatSessionID: req.ChatSessionID,
+		UserID:        userID,
+		Events:        req.Events,
+	}, time.Now())
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrInvalidClickstream) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"success": true,
+		"data":    result,
+	})
+}
+
+// GetExperimentReport handles GET /api/v1/admin/analytics/experiments?from=2024-01-01&to=2024-01-31:
+// storefront page views, suggestion impressions, clicks and click-through
+// rate of each chat experiment variant
+func (h *ClickstreamHandler) GetExperimentReport(c *gin.Context) {
+	from, to, err := dateRangeQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	report, err := h.clickstream.ExperimentReport(c.Request.Context(), from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    report,
+	})
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"chat-ecommerce-backend/internal/services"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ConsentHandler handles users' marketing and analytics consent
+type ConsentHandler struct {
+	consentService *services.ConsentService
+}
+
+// NewConsentHandler creates a new ConsentHandler
+func NewConsentHandler(consentService *services.ConsentService) *ConsentHandler {
+	return &ConsentHandler{
+		consentService: consentService,
+	}
+}
+
+// GetConsents handles GET /api/v1/user/consents
+func (h *ConsentHandler) GetConsents(c *gin.Context) {
+	userID := requestUserID(c)
+	if userID == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	consents, err := h.consentService.GetConsents(c.Request.Context(), *userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"consents": consents})
+}
+
+// UpdateConsents handles PUT /api/v1/user/consents, e.g.
+// {"consents": {"marketing_email": true}, "source": "cookie_banner"}
+func (h *ConsentHandler) UpdateConsents(c *gin.Context) {
+	userID := requestUserID(c)
+	if userID == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	var req services.UpdateConsentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	consents, err := h.consentService.UpdateConsents(c.Request.Context(), *userID, req, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		c.JSON(consentErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"consents": consents})
+}
+
+// GetConsentHistory handles GET /api/v1/user/consents/history
+func (h *ConsentHandler) GetConsentHistory(c *gin.Context) {
+	userID := requestUserID(c)
+	if userID == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	history, err := h.consentService.History(c.Request.Context(), *userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"history": history})
+}
+
+// GetUserConsents handles GET /api/v1/admin/users/:id/consents and returns a
+// customer's current consent with its full history
+func (h *ConsentHandler) GetUserConsents(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	consents, err := h.consentService.GetConsents(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	history, err := h.consentService.History(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"consents": consents,
+			"history":  history,
+		},
+	})
+}
+
+// consentErrorStatus maps consent errors to HTTP status codes
+func consentErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrUnknownConsentPurpose), errors.Is(err, services.ErrUnknownConsentSource):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"chat-ecommerce-backend/internal/services"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// CustomerGroupHandler handles customer group and price list management
+type CustomerGroupHandler struct {
+	groupService *services.CustomerGroupService
+}
+
+// NewCustomerGroupHandler creates a new CustomerGroupHandler
+func NewCustomerGroupHandler(groupService *services.CustomerGroupService) *CustomerGroupHandler {
+	return &CustomerGroupHandler{
+		groupService: groupService,
+	}
+}
+
+// GetGroups handles GET /api/v1/admin/customer-groups
+func (h *CustomerGroupHandler) GetGroups(c *gin.Context) {
+	groups, err := h.groupService.ListGroups(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    groups,
+	})
+}
+
+// UpdateGroup handles PUT /api/v1/admin/customer-groups/:slug
+func (h *CustomerGroupHandler) UpdateGroup(c *gin.Context) {
+	var req services.CustomerGroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	group, err := h.groupService.UpsertGroup(c.Request.Context(), c.Param("slug"), req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    group,
+	})
+}
+
+// DeleteGroup handles DELETE /api/v1/admin/customer-groups/:slug
+func (h *CustomerGroupHandler) DeleteGroup(c *gin.Context) {
+	if err := h.groupService.DeleteGroup(c.Request.Context(), c.Param("slug")); err != nil {
+		c.JSON(customerGroupErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Customer group deleted successfully",
+	})
+}
+
+// GetGroupPrices handles GET /api/v1/admin/customer-groups/:slug/prices
+func (h *CustomerGroupHandler) GetGroupPrices(c *gin.Context) {
+	prices, err := h.groupService.GetGroupPrices(c.Request.Context(), c.Param("slug"))
+	if err != nil {
+		c.JSON(customerGroupErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    prices,
+	})
+}
+
+// SetGroupPrices handles PUT /api/v1/admin/customer-groups/:slug/prices
+func (h *CustomerGroupHandler) SetGroupPrices(c *gin.Context) {
+	var req []services.GroupPriceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	prices, err := h.groupService.SetGroupPrices(c.Request.Context(), c.Param("slug"), req)
+	if err != nil {
+		c.JSON(customerGroupErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    prices,
+	})
+}
+
+// DeleteGroupPrice handles DELETE /api/v1/admin/customer-groups/:slug/prices/:product_id
+func (h *CustomerGroupHandler) DeleteGroupPrice(c *gin.Context) {
+	productID, err := uuid.Parse(c.Param("product_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		return
+	}
+
+	if err := h.groupService.DeleteGroupPrice(c.Request.Context(), c.Param("slug"), productID); err != nil {
+		c.JSON(customerGroupErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Group price deleted successfully",
+	})
+}
+
+// AddMember handles PUT /api/v1/admin/customer-groups/:slug/members/:user_id
+func (h *CustomerGroupHandler) AddMember(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	if err := h.groupService.AssignUser(c.Request.Context(), c.Param("slug"), userID); err != nil {
+		c.JSON(customerGroupErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "User added to customer group",
+	})
+}
+
+// RemoveMember handles DELETE /api/v1/admin/customer-groups/:slug/members/:user_id
+func (h *CustomerGroupHandler) RemoveMember(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	if err := h.groupService.RemoveUser(c.Request.Context(), c.Param("slug"), userID); err != nil {
+		c.JSON(customerGroupErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "User removed from customer group",
+	})
+}
+
+func customerGroupErrorStatus(err error) int {
+	if errors.Is(err, services.ErrCustomerGroupNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusBadRequest
+}
+
+// requestUserID returns the authenticated user, if any. The auth middleware
+// stores the ID from the token claims as a string.
+func requestUserID(c *gin.Context) *uuid.UUID {
+	value, exists := c.Get("user_id")
+	if !exists {
+		return nil
+	}
+
+	switch id := value.(type) {
+	case uuid.UUID:
+		return &id
+	case string:
+		if parsed, err := uuid.Parse(id); err == nil {
+			return &parsed
+		}
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"chat-ecommerce-backend/internal/services"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DeliveryHandler handles the delivery dates offered at checkout
+type DeliveryHandler struct {
+	deliveryService *services.DeliveryScheduleService
+}
+
+// NewDeliveryHandler creates a new DeliveryHandler
+func NewDeliveryHandler(deliveryService *services.DeliveryScheduleService) *DeliveryHandler {
+	return &DeliveryHandler{
+		deliveryService: deliveryService,
+	}
+}
+
+// GetSlots handles GET /api/v1/delivery-slots, listing the dates an order
+// placed now can be delivered on. Checkout takes one as delivery_date.
+func (h *DeliveryHandler) GetSlots(c *gin.Context) {
+	slots, err := h.deliveryService.Slots(c.Request.Context(), time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": slots})
+}
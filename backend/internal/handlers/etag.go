@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// catalogMaxAge is how many seconds anonymous clients may reuse a catalog
+// response before revalidating it
+const catalogMaxAge = 60
+
+// etagBuilder hashes the IDs and update times of the records in a response
+// into a weak ETag, so unchanged listings can be answered with a 304 without
+// serializing them
+type etagBuilder struct {
+	h hash.Hash
+}
+
+func newETagBuilder() *etagBuilder {
+	return &etagBuilder{h: sha256.New()}
+}
+
+func (b *etagBuilder) add(parts ...interface{}) *etagBuilder {
+	for _, part := range parts {
+		fmt.Fprintf(b.h, "%v|", part)
+	}
+	return b
+}
+
+// addProducts hashes products with their variants, images, category and brand.
+// Variants and images have no update time so their served fields are hashed,
+// and the price is hashed as served so a customer group price change gives a
+// new ETag.
+func (b *etagBuilder) addProducts(products []models.Product) *etagBuilder {
+	for _, product := range products {
+		b.add("p", product.ID, product.UpdatedAt.UnixNano(), product.Price, product.Category.UpdatedAt.UnixNano())
+		if product.Brand != nil {
+			b.add("b", product.Brand.ID, product.Brand.UpdatedAt.UnixNano())
+		}
+		for _, variant := range product.Variants {
+			b.add("v", variant.ID, variant.VariantName, variant.VariantValue, variant.PriceModifier, variant.SKUSuffix, variant.IsDefault)
+		}
+		for _, image := range product.Images {
+			b.add("i", image.ID, image.URL, image.AltText, image.SortOrder)
+		}
+	}
+	return b
+}
+
+// addCategories hashes categories with their products
+func (b *etagBuilder) addCategories(categories []models.Category) *etagBuilder {
+	for _, category := range categories {
+		b.add("c", category.ID, category.UpdatedAt.UnixNano())
+		b.addProducts(category.Products)
+	}
+	return b
+}
+
+func (b *etagBuilder) tag() string {
+	return `W/"` + hex.EncodeToString(b.h.Sum(nil)[:16]) + `"`
+}
+
+// notModified sets the ETag and Cache-Control headers of a catalog response
+// and answers 304 when the client's copy is current. Responses priced for a
+// signed-in customer may only be cached by their browser.
+func notModified(c *gin.Context, etag string) bool {
+	c.Header("ETag", etag)
+	c.Header("Vary", "Authorization")
+	if requestUserID(c) != nil {
+		c.Header("Cache-Control", "private, no-cache")
+	} else {
+		c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", catalogMaxAge))
+	}
+
+	if !etagMatches(c.GetHeader("If-None-Match"), etag) {
+		return false
+	}
+	c.Status(http.StatusNotModified)
+	return true
+}
+
+// etagMatches compares If-None-Match against an ETag, weakly as RFC 7232 requires for GET
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"chat-ecommerce-backend/internal/services"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// EventStreamHandler reports on the analytics event stream
+type EventStreamHandler struct {
+	events *services.EventStream
+}
+
+// NewEventStreamHandler creates a new EventStreamHandler
+func NewEventStreamHandler(events *services.EventStream) *EventStreamHandler {
+	return &EventStreamHandler{
+		events: events,
+	}
+}
+
+// GetStatus handles GET /api/v1/admin/event-stream: where events are
+// published, how many were published, failed or dropped, and the Avro schema
+// data teams read them with
+func (h *EventStreamHandler) GetStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"stats":          h.events.Stats(),
+			"schema_version": services.DomainEventSchemaVersion,
+			"schema":         json.RawMessage(services.DomainEventAvroSchema),
+		},
+	})
+}
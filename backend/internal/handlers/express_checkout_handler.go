@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"chat-ecommerce-backend/internal/services"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ExpressCheckoutHandler handles Apple Pay and Google Pay express checkout
+type ExpressCheckoutHandler struct {
+	expressService *services.ExpressCheckoutService
+	orders         *OrderHandler
+}
+
+// NewExpressCheckoutHandler creates a new ExpressCheckoutHandler. Order
+// violations and checkout conflicts are reported the same way as for
+// regular checkout through orders.
+func NewExpressCheckoutHandler(expressService *services.ExpressCheckoutService, orders *OrderHandler) *ExpressCheckoutHandler {
+	return &ExpressCheckoutHandler{
+		expressService: expressService,
+		orders:         orders,
+	}
+}
+
+// ValidateApplePayMerchant handles POST /api/v1/payments/express/apple-pay/validate-merchant
+func (h *ExpressCheckoutHandler) ValidateApplePayMerchant(c *gin.Context) {
+	var req struct {
+		ValidationURL string `json:"validation_url" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	session, err := h.expressService.ValidateMerchant(c.Request.Context(), req.ValidationURL)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrApplePayNotConfigured):
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"merchant_session": session})
+}
+
+// Checkout handles POST /api/v1/payments/express/checkout
+func (h *ExpressCheckoutHandler) Checkout(c *gin.Context) {
+	var req services.ExpressCheckoutRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := requestUserID(c)
+	if userID == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return
+	}
+
+	sessionID := c.GetHeader("X-Session-ID")
+	if sessionID == "" {
+		if value, exists := c.Get("session_id"); exists {
+			sessionID = value.(string)
+		} else {
+			sessionID = uuid.New().String()
+		}
+	}
+
+	result, err := h.expressService.Checkout(c.Request.Context(), sessionID, *userID, &req)
+	if err != nil {
+		orderReq := &services.CreateOrderRequest{UserID: *userID, SessionID: sessionID}
+		if respondWaitingRoom(c, err) || respondOrderViolations(c, err) || h.orders.respondCheckoutConflict(c, orderReq, err) {
+			return
+		}
+		if errors.Is(err, services.ErrWalletPaymentDeclined) {
+			c.JSON(http.StatusPaymentRequired, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"order": result.Order, "payment_status": result.Payment})
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// fieldSet is a sparse fieldset parsed from ?fields=name,price,category.name.
+// A nil fieldSet keeps everything; a field mapped to nil is kept whole.
+type fieldSet map[string]fieldSet
+
+// parseFields parses a comma separated list of JSON field names, with dots
+// selecting fields of nested objects. "id" is always kept so clients can
+// match sparse records to the ones they have.
+func parseFields(raw string) fieldSet {
+	var fields fieldSet
+	for _, field := range strings.Split(raw, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		if fields == nil {
+			fields = fieldSet{"id": nil}
+		}
+
+		node := fields
+		parts := strings.Split(field, ".")
+		for i, part := range parts {
+			child, seen := node[part]
+			if i == len(parts)-1 {
+				// "category" after "category.name" keeps the whole category
+				node[part] = nil
+				break
+			}
+			if seen && child == nil {
+				break
+			}
+			if child == nil {
+				child = fieldSet{"id": nil}
+				node[part] = child
+			}
+			node = child
+		}
+	}
+	return fields
+}
+
+// selectAt trims the resources found at path in a response to the fields.
+// Arrays along the path are walked element by element.
+func (f fieldSet) selectAt(body interface{}, path ...string) (interface{}, error) {
+	if f == nil {
+		return body, nil
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal response: %v", err)
+	}
+	var tree interface{}
+	if err := json.Unmarshal(data, &tree); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %v", err)
+	}
+	return f.walk(tree, path), nil
+}
+
+func (f fieldSet) walk(node interface{}, path []string) interface{} {
+	switch value := node.(type) {
+	case []interface{}:
+		for i := range value {
+			value[i] = f.walk(value[i], path)
+		}
+		return value
+	case map[string]interface{}:
+		if len(path) == 0 {
+			return f.prune(value)
+		}
+		if child, ok := value[path[0]]; ok {
+			value[path[0]] = f.walk(child, path[1:])
+		}
+		return value
+	default:
+		return node
+	}
+}
+
+func (f fieldSet) prune(node interface{}) interface{} {
+	if f == nil {
+		return node
+	}
+
+	switch value := node.(type) {
+	case []interface{}:
+		for i := range value {
+			value[i] = f.prune(value[i])
+		}
+		return value
+	case map[string]interface{}:
+		for key, child := range value {
+			sub, keep := f[key]
+			if !keep {
+				delete(value, key)
+				continue
+			}
+			value[key] = sub.prune(child)
+		}
+		return value
+	default:
+		return node
+	}
+}
+
+// jsonWithFields writes a JSON response, trimming the resources found at
+// path to the fields asked for in ?fields= when there is one
+func jsonWithFields(c *gin.Context, status int, body interface{}, path ...string) {
+	selected, err := parseFields(c.Query("fields")).selectAt(body, path...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(status, selected)
+}
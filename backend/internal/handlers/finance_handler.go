@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"chat-ecommerce-backend/internal/services"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// FinanceHandler handles admin settlement reporting and payout reconciliation
+type FinanceHandler struct {
+	financeService    *services.FinanceService
+	accountingService *services.AccountingExportService
+}
+
+// NewFinanceHandler creates a new FinanceHandler
+func NewFinanceHandler(financeService *services.FinanceService) *FinanceHandler {
+	return &FinanceHandler{
+		financeService: financeService,
+	}
+}
+
+// WithAccountingExports enables the QuickBooks and Xero journal exports
+func (h *FinanceHandler) WithAccountingExports(accountingService *services.AccountingExportService) *FinanceHandler {
+	h.accountingService = accountingService
+	return h
+}
+
+// GetSettlements handles GET /api/v1/admin/finance/settlements?from=2024-01-01&to=2024-01-31&provider=stripe
+func (h *FinanceHandler) GetSettlements(c *gin.Context) {
+	filter, err := settlementFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	summaries, err := h.financeService.SettlementSummaries(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    summaries,
+	})
+}
+
+// ExportSettlements handles GET /api/v1/admin/finance/settlements/export
+func (h *FinanceHandler) ExportSettlements(c *gin.Context) {
+	filter, err := settlementFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	csvData, err := h.financeService.ExportSettlementsCSV(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Disposition", "attachment; filename=settlements.csv")
+	c.Data(http.StatusOK, "text/csv", csvData)
+}
+
+// CreateReconciliation handles POST /api/v1/admin/finance/reconciliations?provider=stripe
+// with the provider's payout report as a CSV upload
+func (h *FinanceHandler) CreateReconciliation(c *gin.Context) {
+	provider := c.Query("provider")
+	if provider == "" {
+		provider = c.PostForm("provider")
+	}
+	if provider == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "provider is required"})
+		return
+	}
+
+	file, err := importFile(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer file.Close()
+
+	fileName := c.Query("file_name")
+	if header, err := c.FormFile("file"); err == nil {
+		fileName = header.Filename
+	}
+
+	reconciliation, err := h.financeService.Reconcile(c.Request.Context(), provider, fileName, file, requestUserID(c))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"data":    reconciliation,
+	})
+}
+
+// GetReconciliations handles GET /api/v1/admin/finance/reconciliations?provider=stripe
+func (h *FinanceHandler) GetReconciliations(c *gin.Context) {
+	reconciliations, err := h.financeService.ListReconciliations(c.Request.Context(), c.Query("provider"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    reconciliations,
+	})
+}
+
+// GetReconciliation handles GET /api/v1/admin/finance/reconciliations/:id
+func (h *FinanceHandler) GetReconciliation(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid reconciliation ID"})
+		return
+	}
+
+	reconciliation, err := h.financeService.GetReconciliation(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    reconciliation,
+	})
+}
+
+// CreateAccountingExport handles POST /api/v1/admin/finance/accounting-exports?format=xero&from=2024-01-01&to=2024-01-31
+func (h *FinanceHandler) CreateAccountingExport(c *gin.Context) {
+	from, to, err := dateRangeQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	export, err := h.accountingService.Export(c.Request.Context(), c.Query("format"), from, to, services.AccountingExportManual, requestUserID(c))
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidAccountingExport) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"data":    export,
+	})
+}
+
+// GetAccountingExports handles GET /api/v1/admin/finance/accounting-exports
+func (h *FinanceHandler) GetAccountingExports(c *gin.Context) {
+	exports, err := h.accountingService.ListExports(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    exports,
+	})
+}
+
+// DownloadAccountingExport handles GET /api/v1/admin/finance/accounting-exports/:id/download
+func (h *FinanceHandler) DownloadAccountingExport(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid accounting export ID"})
+		return
+	}
+
+	export, err := h.accountingService.GetExport(c.Request.Context(), id)
+	if err != nil {
+		if errors.Is(err, services.ErrAccountingExportNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Disposition", "attachment; filename="+export.FileName)
+	c.Data(http.StatusOK, "text/csv", []byte(export.Content))
+}
+
+// settlementFilter reads the report's dates and provider from the query string
+func settlementFilter(c *gin.Context) (services.SettlementFilter, error) {
+	from, to, err := dateRangeQuery(c)
+	return services.SettlementFilter{From: from, To: to, Provider: c.Query("provider")}, err
+}
+
+// dateRangeQuery reads a report's from and to dates (inclusive, UTC,
+// defaulting to the last 30 days). The returned to is exclusive.
+func dateRangeQuery(c *gin.Context) (time.Time, time.Time, error) {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	from, to := today.AddDate(0, 0, -29), today.AddDate(0, 0, 1)
+
+	if fromStr := c.Query("from"); fromStr != "" {
+		date, err := time.Parse("2006-01-02", fromStr)
+		if err != nil {
+			return from, to, errors.New("from must be a date like 2006-01-02")
+		}
+		from = date
+	}
+	if toStr := c.Query("to"); toStr != "" {
+		date, err := time.Parse("2006-01-02", toStr)
+		if err != nil {
+			return from, to, errors.New("to must be a date like 2006-01-02")
+		}
+		to = date.AddDate(0, 0, 1)
+	}
+	if !to.After(from) {
+		return from, to, errors.New("from must not be after to")
+	}
+	return from, to, nil
+}
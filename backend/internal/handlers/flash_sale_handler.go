@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"chat-ecommerce-backend/internal/services"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// FlashSaleHandler handles drop day flash sales and their waiting room
+type FlashSaleHandler struct {
+	flashSaleService *services.FlashSaleService
+}
+
+// NewFlashSaleHandler creates a new FlashSaleHandler
+func NewFlashSaleHandler(flashSaleService *services.FlashSaleService) *FlashSaleHandler {
+	return &FlashSaleHandler{
+		flashSaleService: flashSaleService,
+	}
+}
+
+// GetStatus handles GET /api/v1/flash-sale and GET /api/v1/admin/flash-sale,
+// the planned or running flash sale and how many shoppers are waiting
+func (h *FlashSaleHandler) GetStatus(c *gin.Context) {
+	status, err := h.flashSaleService.Status(c.Request.Context(), time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": status})
+}
+
+// PlanFlashSale handles PUT /api/v1/admin/flash-sale, replacing any planned
+// sale. The products' caches are warmed when it starts soon.
+func (h *FlashSaleHandler) PlanFlashSale(c *gin.Context) {
+	var req services.FlashSaleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	status, err := h.flashSaleService.Plan(c.Request.Context(), req, requestUserID(c), time.Now())
+	if err != nil {
+		c.JSON(flashSaleErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": status})
+}
+
+// EndFlashSale handles DELETE /api/v1/admin/flash-sale, ending the sale and
+// emptying its waiting room
+func (h *FlashSaleHandler) EndFlashSale(c *gin.Context) {
+	if err := h.flashSaleService.End(c.Request.Context(), time.Now()); err != nil {
+		c.JSON(flashSaleErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// JoinWaitingRoom handles POST /api/v1/flash-sale/queue, giving the shopper
+// a ticket in the waiting room, or the one they hold
+func (h *FlashSaleHandler) JoinWaitingRoom(c *gin.Context) {
+	sessionID := c.GetHeader("X-Session-ID")
+	if sessionID == "" {
+		sessionID = c.GetString("session_id")
+	}
+	userID := requestUserID(c)
+	if sessionID == "" && userID == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Session ID is required"})
+		return
+	}
+
+	ticket, err := h.flashSaleService.Join(c.Request.Context(), sessionID, userID, time.Now())
+	if err != nil {
+		c.JSON(flashSaleErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	respondWaitingRoomTicket(c, ticket)
+}
+
+// GetWaitingRoomTicket handles GET /api/v1/flash-sale/queue/:token, the
+// ticket's place in line, polled until it's let through
+func (h *FlashSaleHandler) GetWaitingRoomTicket(c *gin.Context) {
+	ticket, err := h.flashSaleService.Ticket(c.Request.Context(), c.Param("token"), time.Now())
+	if err != nil {
+		c.JSON(flashSaleErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	respondWaitingRoomTicket(c, ticket)
+}
+
+// respondWaitingRoomTicket sends a ticket, telling shoppers still in line
+// when to check again
+func respondWaitingRoomTicket(c *gin.Context, ticket *services.WaitingRoomTicket) {
+	if ticket.RetryAfterSeconds > 0 {
+		c.Header("Retry-After", strconv.Itoa(ticket.RetryAfterSeconds))
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": ticket})
+}
+
+// respondWaitingRoom answers checkouts the flash sale waiting room hasn't let
+// through with 429, Retry-After and the shopper's place in line, reporting
+// whether err was one
+func respondWaitingRoom(c *gin.Context, err error) bool {
+	var waitErr *services.WaitingRoomError
+	if !errors.As(err, &waitErr) {
+		return false
+	}
+	c.Header("Retry-After", strconv.Itoa(waitErr.RetryAfterSeconds()))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error":  waitErr.Error(),
+		"code":   "waiting_room",
+		"ticket": waitErr.Ticket,
+	})
+	return true
+}
+
+// flashSaleErrorStatus maps flash sale errors to HTTP status codes
+func flashSaleErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrInvalidFlashSale):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrNoFlashSale), errors.Is(err, services.ErrWaitingRoomTicketNotFound):
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
+}
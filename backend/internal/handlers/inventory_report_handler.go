@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"chat-ecommerce-backend/internal/services"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// InventoryReportHandler handles inventory reports for merchandising
+type InventoryReportHandler struct {
+	inventoryService *services.InventoryService
+}
+
+// NewInventoryReportHandler creates a new InventoryReportHandler
+func NewInventoryReportHandler(inventoryService *services.InventoryService) *InventoryReportHandler {
+	return &InventoryReportHandler{inventoryService: inventoryService}
+}
+
+// GetDeadStock handles GET /api/v1/admin/inventory/dead-stock?days=90&min_stock=1
+func (h *InventoryReportHandler) GetDeadStock(c *gin.Context) {
+	filter, ok := deadStockFilter(c)
+	if !ok {
+		return
+	}
+
+	report, err := h.inventoryService.GetDeadStockReport(c.Request.Context(), filter, time.Now())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": report})
+}
+
+// ExportDeadStock handles GET /api/v1/admin/inventory/dead-stock/export
+func (h *InventoryReportHandler) ExportDeadStock(c *gin.Context) {
+	filter, ok := deadStockFilter(c)
+	if !ok {
+		return
+	}
+
+	csvData, err := h.inventoryService.ExportDeadStockCSV(c.Request.Context(), filter, time.Now())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Disposition", "attachment; filename=dead-stock.csv")
+	c.Data(http.StatusOK, "text/csv", csvData)
+}
+
+// deadStockFilter reads the report filter from the query string, responding
+// with 400 when it is invalid
+func deadStockFilter(c *gin.Context) (services.DeadStockFilter, bool) {
+	var filter services.DeadStockFilter
+	for param, target := range map[string]*int{"days": &filter.Days, "min_stock": &filter.MinStock} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid " + param})
+			return filter, false
+		}
+		*target = parsed
+	}
+	return filter, true
+}
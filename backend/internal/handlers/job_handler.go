@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"chat-ecommerce-backend/internal/services"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// JobHandler handles the status and results of background admin jobs
+type JobHandler struct {
+	jobService *services.JobService
+}
+
+// NewJobHandler creates a new JobHandler
+func NewJobHandler(jobService *services.JobService) *JobHandler {
+	return &JobHandler{jobService: jobService}
+}
+
+// GetJobs handles GET /api/v1/admin/jobs with the list parameters of
+// services.JobListSchema
+func (h *JobHandler) GetJobs(c *gin.Context) {
+	list, ok := listQuery(c, services.JobListSchema)
+	if !ok {
+		return
+	}
+
+	jobs, total, err := h.jobService.ListJobs(c.Request.Context(), list)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": jobs, "meta": list.PageInfo(total)})
+}
+
+// GetJob handles GET /api/v1/admin/jobs/:id with the job's progress and,
+// once it's done, its result
+func (h *JobHandler) GetJob(c *gin.Context) {
+	jobID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid job ID"})
+		return
+	}
+
+	job, err := h.jobService.GetJob(c.Request.Context(), jobID)
+	if err != nil {
+		c.JSON(jobErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": job})
+}
+
+// DownloadArtifact handles GET /api/v1/admin/jobs/:id/artifact, the file a
+// finished job produced, such as an export
+func (h *JobHandler) DownloadArtifact(c *gin.Context) {
+	jobID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid job ID"})
+		return
+	}
+
+	job, err := h.jobService.Artifact(c.Request.Context(), jobID)
+	if err != nil {
+		c.JSON(jobErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", job.ArtifactName))
+	c.Data(http.StatusOK, job.ArtifactType, job.Artifact)
+}
+
+// runAsJob reports whether a request asked with ?async=true to be run as a
+// background job
+func runAsJob(c *gin.Context, jobs *services.JobService) bool {
+	async, _ := strconv.ParseBool(c.Query("async"))
+	return async && jobs != nil
+}
+
+// startJob starts a background job for the requesting admin and answers 202
+// with it; its progress is at the job's URL and on the admin's chat socket
+func startJob(c *gin.Context, jobs *services.JobService, kind string, params interface{}, work services.JobWork) {
+	job, err := jobs.Start(c.Request.Context(), kind, requestUserID(c), params, work)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Location", fmt.Sprintf("/api/v1/admin/jobs/%s", job.ID))
+	c.JSON(http.StatusAccepted, gin.H{"success": true, "data": job})
+}
+
+func jobErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrJobNotFound), errors.Is(err, services.ErrJobNoArtifact):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrJobNotFinished):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,19 @@
+package handlers
+
+import (
+	"chat-ecommerce-backend/pkg/listquery"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// listQuery reads a list endpoint's filter, sort and page parameters,
+// answering 400 with everything wrong with them when they're invalid
+func listQuery(c *gin.Context, schema *listquery.Schema) (*listquery.Query, bool) {
+	list, err := listquery.Parse(schema, c.Request.URL.Query())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid list parameters", "details": err})
+		return nil, false
+	}
+	return list, true
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"chat-ecommerce-backend/internal/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LLMSettingsHandler handles admin configuration of the chat assistant's model settings
+type LLMSettingsHandler struct {
+	settingsService *services.LLMSettingsService
+}
+
+// NewLLMSettingsHandler creates a new LLMSettingsHandler
+func NewLLMSettingsHandler(settingsService *services.LLMSettingsService) *LLMSettingsHandler {
+	return &LLMSettingsHandler{
+		settingsService: settingsService,
+	}
+}
+
+// GetSettings handles GET /api/v1/admin/llm-settings
+func (h *LLMSettingsHandler) GetSettings(c *gin.Context) {
+	settings, err := h.settingsService.ListSettings(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    settings,
+	})
+}
+
+// UpdateSettings handles PUT /api/v1/admin/llm-settings/:variant
+func (h *LLMSettingsHandler) UpdateSettings(c *gin.Context) {
+	var req services.LLMSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	settings, err := h.settingsService.UpsertSettings(c.Request.Context(), c.Param("variant"), req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    settings,
+	})
+}
+
+// DeleteSettings handles DELETE /api/v1/admin/llm-settings/:variant
+func (h *LLMSettingsHandler) DeleteSettings(c *gin.Context) {
+	if err := h.settingsService.DeleteSettings(c.Request.Context(), c.Param("variant")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Settings deleted successfully",
+	})
+}
+
+// GetSessionConfig handles GET /api/v1/admin/chat-sessions/:session_id/llm
+func (h *LLMSettingsHandler) GetSessionConfig(c *gin.Context) {
+	sessionID := c.Param("session_id")
+	override, err := h.settingsService.GetSessionOverride(c.Request.Context(), sessionID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"effective": h.settingsService.ResolveConfig(c.Request.Context(), sessionID),
+			"override":  override,
+		},
+	})
+}
+
+// SetSessionOverride handles PUT /api/v1/admin/chat-sessions/:session_id/llm
+func (h *LLMSettingsHandler) SetSessionOverride(c *gin.Context) {
+	var req services.LLMOverrideRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sessionID := c.Param("session_id")
+	if err := h.settingsService.SetSessionOverride(c.Request.Context(), sessionID, &req); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    h.settingsService.ResolveConfig(c.Request.Context(), sessionID),
+	})
+}
+
+// ClearSessionOverride handles DELETE /api/v1/admin/chat-sessions/:session_id/llm
+func (h *LLMSettingsHandler) ClearSessionOverride(c *gin.Context) {
+	if err := h.settingsService.SetSessionOverride(c.Request.Context(), c.Param("session_id"), nil); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Session override cleared",
+	})
+}
@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"chat-ecommerce-backend/internal/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LocaleHandler handles the customer's store locale
+type LocaleHandler struct {
+	localeService *services.StoreLocaleService
+}
+
+// NewLocaleHandler creates a new LocaleHandler
+func NewLocaleHandler(localeService *services.StoreLocaleService) *LocaleHandler {
+	return &LocaleHandler{
+		localeService: localeService,
+	}
+}
+
+// GetLocale handles GET /api/v1/locale, returning the country, currency, tax
+// display and shipping availability the storefront should default to. These
+// are the same the chat assistant answers with.
+func (h *LocaleHandler) GetLocale(c *gin.Context) {
+	locale, ok := services.StoreLocaleFromContext(c.Request.Context())
+	if !ok {
+		locale = h.localeService.Resolve(c.Request.Context(), c.ClientIP(), c.Request.Header, requestUserID(c))
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": locale})
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"chat-ecommerce-backend/internal/services"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LoginSecurityHandler handles account unlock links and the security
+// notifications shown to a user's signed-in sessions
+type LoginSecurityHandler struct {
+	security *services.LoginSecurityService
+}
+
+// NewLoginSecurityHandler creates a new LoginSecurityHandler
+func NewLoginSecurityHandler(security *services.LoginSecurityService) *LoginSecurityHandler {
+	return &LoginSecurityHandler{
+		security: security,
+	}
+}
+
+// UnlockAccount handles POST /api/v1/auth/unlock-account with the token from
+// the unlock link emailed when an account is locked
+func (h *LoginSecurityHandler) UnlockAccount(c *gin.Context) {
+	var req struct {
+		Token string `json:"token" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.security.UnlockAccount(c.Request.Context(), req.Token); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrInvalidUnlockToken) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "account unlocked successfully"})
+}
+
+// GetSecurityNotifications handles GET /api/v1/user/security-notifications?unread=true
+func (h *LoginSecurityHandler) GetSecurityNotifications(c *gin.Context) {
+	userID := requestUserID(c)
+	if userID == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	notifications, err := h.security.ListNotifications(c.Request.Context(), *userID, c.Query("unread") == "true")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"notifications": notifications})
+}
+
+// MarkSecurityNotificationsRead handles POST /api/v1/user/security-notifications/read
+func (h *LoginSecurityHandler) MarkSecurityNotificationsRead(c *gin.Context) {
+	userID := requestUserID(c)
+	if userID == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	if err := h.security.MarkNotificationsRead(c.Request.Context(), *userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "notifications marked as read"})
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"chat-ecommerce-backend/internal/services"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MaintenanceHandler handles planned API maintenance
+type MaintenanceHandler struct {
+	maintenanceService *services.MaintenanceService
+	broadcaster        services.SessionBroadcaster
+}
+
+// NewMaintenanceHandler creates a new MaintenanceHandler
+func NewMaintenanceHandler(maintenanceService *services.MaintenanceService, broadcaster services.SessionBroadcaster) *MaintenanceHandler {
+	return &MaintenanceHandler{
+		maintenanceService: maintenanceService,
+		broadcaster:        broadcaster,
+	}
+}
+
+// GetStatus handles GET /api/v1/maintenance and GET /api/v1/admin/maintenance,
+// returning the planned or running maintenance for the storefront banner
+func (h *MaintenanceHandler) GetStatus(c *gin.Context) {
+	status, err := h.maintenanceService.Status(c.Request.Context(), time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": status})
+}
+
+// PlanMaintenance handles PUT /api/v1/admin/maintenance. Connected shoppers
+// are sent a countdown to the start; from then on writes get 503s.
+func (h *MaintenanceHandler) PlanMaintenance(c *gin.Context) {
+	var req services.MaintenanceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	status, err := h.maintenanceService.Plan(c.Request.Context(), req, requestUserID(c), time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	notified := services.BroadcastMaintenance(h.broadcaster, status)
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": status, "notified_sessions": notified})
+}
+
+// EndMaintenance handles DELETE /api/v1/admin/maintenance, reopening the API
+// and telling connected shoppers
+func (h *MaintenanceHandler) EndMaintenance(c *gin.Context) {
+	if err := h.maintenanceService.End(c.Request.Context(), time.Now()); err != nil {
+		if errors.Is(err, services.ErrNoMaintenance) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	notified := services.BroadcastMaintenance(h.broadcaster, &services.MaintenanceStatus{})
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "notified_sessions": notified})
+}
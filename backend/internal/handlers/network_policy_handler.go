@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"chat-ecommerce-backend/internal/services"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// NetworkPolicyHandler handles the admin view of network policies
+type NetworkPolicyHandler struct {
+	policyService *services.NetworkPolicyService
+	policies      []*services.NetworkPolicy
+}
+
+// NewNetworkPolicyHandler creates a new NetworkPolicyHandler
+func NewNetworkPolicyHandler(policyService *services.NetworkPolicyService, policies ...*services.NetworkPolicy) *NetworkPolicyHandler {
+	return &NetworkPolicyHandler{
+		policyService: policyService,
+		policies:      policies,
+	}
+}
+
+// GetNetworkPolicy handles GET /api/v1/admin/network-policy?hours=24&policy=&limit=,
+// describing the policies and the requests they blocked
+func (h *NetworkPolicyHandler) GetNetworkPolicy(c *gin.Context) {
+	hours, err := strconv.Atoi(c.DefaultQuery("hours", "24"))
+	if err != nil || hours < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "hours must be a positive integer"})
+		return
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+
+	blocked, err := h.policyService.BlockedRequests(c.Request.Context(), c.Query("policy"), time.Now().Add(-time.Duration(hours)*time.Hour), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	policies := make([]services.NetworkPolicySummary, 0, len(h.policies))
+	for _, policy := range h.policies {
+		policies = append(policies, policy.Summary())
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"policies": policies,
+			"blocked":  blocked,
+		},
+	})
+}
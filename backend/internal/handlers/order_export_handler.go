@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"chat-ecommerce-backend/internal/services"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// OrderExportHandler handles the ERP export of paid orders
+type OrderExportHandler struct {
+	exportService *services.OrderExportService
+}
+
+// NewOrderExportHandler creates a new OrderExportHandler
+func NewOrderExportHandler(exportService *services.OrderExportService) *OrderExportHandler {
+	return &OrderExportHandler{
+		exportService: exportService,
+	}
+}
+
+// GetExports handles GET /api/v1/admin/order-exports, optionally filtered by
+// status (pending, exported or failed)
+func (h *OrderExportHandler) GetExports(c *gin.Context) {
+	status := c.Query("status")
+	switch status {
+	case "", services.OrderExportPending, services.OrderExportExported, services.OrderExportFailed:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be pending, exported or failed"})
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if limit < 1 || limit > 1000 {
+		limit = 100
+	}
+
+	exports, err := h.exportService.ListExports(c.Request.Context(), status, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    exports,
+	})
+}
+
+// GetOrderExport handles GET /api/v1/admin/orders/:id/export
+func (h *OrderExportHandler) GetOrderExport(c *gin.Context) {
+	orderID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	export, err := h.exportService.GetExport(c.Request.Context(), orderID)
+	if err != nil {
+		c.JSON(orderExportErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    export,
+	})
+}
+
+// ReexportOrder handles POST /api/v1/admin/orders/:id/export, sending a paid
+// order to the ERP again now. A failed send is reported on the export and
+// retried like any other.
+func (h *OrderExportHandler) ReexportOrder(c *gin.Context) {
+	orderID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	export, err := h.exportService.Reexport(c.Request.Context(), orderID)
+	if err != nil {
+		c.JSON(orderExportErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    export,
+	})
+}
+
+// orderExportErrorStatus maps order export errors to HTTP statuses
+func orderExportErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrOrderExportNotFound), err.Error() == "order not found":
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrOrderNotExportable):
+		return http.StatusConflict
+	case errors.Is(err, services.ErrOrderExportDisabled):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
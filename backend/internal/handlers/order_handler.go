@@ -2,8 +2,11 @@ package handlers
 
 import (
 	"chat-ecommerce-backend/internal/services"
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
-	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -12,12 +15,16 @@ import (
 // OrderHandler handles order-related HTTP requests
 type OrderHandler struct {
 	orderService *services.OrderService
+	notifier     services.SessionNotifier
+	pipeline     *services.OrderPipeline
 }
 
-// NewOrderHandler creates a new OrderHandler
-func NewOrderHandler(orderService *services.OrderService) *OrderHandler {
+// NewOrderHandler creates a new OrderHandler. Checkout conflicts are pushed
+// to the shopper's chat connections through notifier.
+func NewOrderHandler(orderService *services.OrderService, notifier services.SessionNotifier) *OrderHandler {
 	return &OrderHandler{
 		orderService: orderService,
+		notifier:     notifier,
 	}
 }
 
@@ -30,24 +37,78 @@ func (h *OrderHandler) CreateOrder(c *gin.Context) {
 	}
 
 	// Get user ID from context if authenticated
-	if userID, exists := c.Get("user_id"); exists {
-		req.UserID = userID.(uuid.UUID)
+	if userID := requestUserID(c); userID != nil {
+		req.UserID = *userID
 	}
 
-	// Get session ID from context or generate one
+	req.SessionID = orderSessionID(c)
+
+	order, err := h.orderService.CreateOrder(c.Request.Context(), &req)
+	if err != nil {
+		if respondWaitingRoom(c, err) {
+			return
+		}
+		if errors.Is(err, services.ErrDeliveryDateUnavailable) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if !respondOrderViolations(c, err) && !h.respondCheckoutConflict(c, &req, err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	// Confirm the order, and when it should arrive, in the shopper's chat
+	if h.notifier != nil {
+		h.notifier.NotifySession(order.SessionID, services.OrderConfirmationMessage, services.NewOrderConfirmation(order))
+	}
+
+	// Let the shopper know part of the order will ship later
+	if backordered := services.BackorderedFulfillment(order); backordered != nil && h.notifier != nil {
+		h.notifier.NotifySession(order.SessionID, services.FulfillmentUpdateMessage, services.NewFulfillmentNotice(order, backordered))
+	}
+
+	// Tell the shopper how to pay for offline payments
+	if instructions := h.orderService.OfflinePaymentInstructions(order); instructions != "" {
+		c.JSON(http.StatusCreated, gin.H{"order": order, "payment_instructions": instructions})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"order": order})
+}
+
+// orderSessionID returns the session ID from the header or context, or
+// generates one
+func orderSessionID(c *gin.Context) string {
+	if sessionID := c.GetHeader("X-Session-ID"); sessionID != "" {
+		return sessionID
+	}
 	if sessionID, exists := c.Get("session_id"); exists {
-		req.SessionID = sessionID.(string)
-	} else {
-		req.SessionID = uuid.New().String()
+		return sessionID.(string)
 	}
+	return uuid.New().String()
+}
 
-	order, err := h.orderService.CreateOrder(&req)
-	if err != nil {
+// ValidateOrder handles POST /api/v1/orders/validate
+func (h *OrderHandler) ValidateOrder(c *gin.Context) {
+	var req services.CreateOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{"order": order})
+	if userID := requestUserID(c); userID != nil {
+		req.UserID = *userID
+	}
+
+	if err := h.orderService.ValidateOrder(c.Request.Context(), &req); err != nil {
+		if !respondOrderViolations(c, err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"valid": true, "violations": []services.OrderViolation{}})
 }
 
 // GetOrder handles GET /api/v1/orders/:id
@@ -59,15 +120,15 @@ func (h *OrderHandler) GetOrder(c *gin.Context) {
 		return
 	}
 
-	order, err := h.orderService.GetOrderByID(orderID)
+	order, err := h.orderService.GetOrderByID(c.Request.Context(), orderID)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
 	}
 
 	// Check if user can access this order
-	if userID, exists := c.Get("user_id"); exists {
-		if order.UserID != userID.(uuid.UUID) {
+	if userID := requestUserID(c); userID != nil {
+		if order.UserID != *userID {
 			c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
 			return
 		}
@@ -84,7 +145,7 @@ func (h *OrderHandler) GetOrder(c *gin.Context) {
 		}
 	}
 
-	c.JSON(http.StatusOK, gin.H{"order": order})
+	jsonWithFields(c, http.StatusOK, gin.H{"order": order}, "order")
 }
 
 // GetOrderByNumber handles GET /api/v1/orders/number/:number
@@ -95,15 +156,15 @@ func (h *OrderHandler) GetOrderByNumber(c *gin.Context) {
 		return
 	}
 
-	order, err := h.orderService.GetOrderByNumber(orderNumber)
+	order, err := h.orderService.GetOrderByNumber(c.Request.Context(), orderNumber)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
 	}
 
 	// Check if user can access this order
-	if userID, exists := c.Get("user_id"); exists {
-		if order.UserID != userID.(uuid.UUID) {
+	if userID := requestUserID(c); userID != nil {
+		if order.UserID != *userID {
 			c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
 			return
 		}
@@ -120,50 +181,39 @@ func (h *OrderHandler) GetOrderByNumber(c *gin.Context) {
 		}
 	}
 
-	c.JSON(http.StatusOK, gin.H{"order": order})
+	jsonWithFields(c, http.StatusOK, gin.H{"order": order}, "order")
 }
 
-// GetUserOrders handles GET /api/v1/user/orders
+// GetUserOrders handles GET /api/v1/user/orders with the list parameters of
+// services.UserOrderListSchema
 func (h *OrderHandler) GetUserOrders(c *gin.Context) {
-	userID, exists := c.Get("user_id")
-	if !exists {
+	userID := requestUserID(c)
+	if userID == nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
 		return
 	}
 
-	// Parse pagination parameters
-	page := 1
-	limit := 10
-
-	if pageStr := c.Query("page"); pageStr != "" {
-		if p, err := strconv.Atoi(pageStr); err == nil && p > 0 {
-			page = p
-		}
-	}
-
-	if limitStr := c.Query("limit"); limitStr != "" {
-		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 100 {
-			limit = l
-		}
+	list, ok := listQuery(c, services.UserOrderListSchema)
+	if !ok {
+		return
 	}
 
-	orders, total, err := h.orderService.GetUserOrders(userID.(uuid.UUID), page, limit)
+	orders, total, err := h.orderService.GetUserOrders(c.Request.Context(), *userID, list)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	totalPages := (total + int64(limit) - 1) / int64(limit)
-
-	c.JSON(http.StatusOK, gin.H{
+	page := list.PageInfo(total)
+	jsonWithFields(c, http.StatusOK, gin.H{
 		"orders":       orders,
 		"total":        total,
-		"page":         page,
-		"limit":        limit,
-		"total_pages":  totalPages,
-		"has_next":     page < int(totalPages),
-		"has_previous": page > 1,
-	})
+		"page":         page.Page,
+		"limit":        page.Size,
+		"total_pages":  page.TotalPages,
+		"has_next":     page.HasNext,
+		"has_previous": page.HasPrevious,
+	}, "orders")
 }
 
 // UpdateOrderStatus handles PUT /api/v1/orders/:id/status (Admin only)
@@ -181,13 +231,183 @@ func (h *OrderHandler) UpdateOrderStatus(c *gin.Context) {
 		return
 	}
 
-	order, err := h.orderService.UpdateOrderStatus(orderID, &req)
+	order, err := h.orderService.UpdateOrderStatus(c.Request.Context(), orderID, &req)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"order": order})
+	jsonWithFields(c, http.StatusOK, gin.H{"order": order}, "order")
+}
+
+// UpdateFulfillment handles PUT /api/v1/admin/orders/:id/fulfillments/:fulfillment_id
+func (h *OrderHandler) UpdateFulfillment(c *gin.Context) {
+	orderID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid order ID"})
+		return
+	}
+	fulfillmentID, err := uuid.Parse(c.Param("fulfillment_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid fulfillment ID"})
+		return
+	}
+
+	var req services.UpdateFulfillmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	order, fulfillment, err := h.orderService.UpdateFulfillment(c.Request.Context(), orderID, fulfillmentID, req)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, services.ErrFulfillmentNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	if h.notifier != nil {
+		h.notifier.NotifySession(order.SessionID, services.FulfillmentUpdateMessage, services.NewFulfillmentNotice(order, fulfillment))
+	}
+
+	jsonWithFields(c, http.StatusOK, gin.H{"order": order}, "order")
+}
+
+// MarkOrderPaid handles POST /api/v1/admin/orders/:id/mark-paid
+func (h *OrderHandler) MarkOrderPaid(c *gin.Context) {
+	orderID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid order ID"})
+		return
+	}
+
+	var req services.MarkOrderPaidRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	order, err := h.orderService.MarkOrderPaid(c.Request.Context(), orderID, req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	jsonWithFields(c, http.StatusOK, gin.H{"order": order}, "order")
+}
+
+// RecalculateOrder handles POST /api/v1/admin/orders/:id/recalculate
+func (h *OrderHandler) RecalculateOrder(c *gin.Context) {
+	orderID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid order ID"})
+		return
+	}
+
+	result, err := h.orderService.RecalculateOrder(c.Request.Context(), orderID)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if err.Error() == "order not found" {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": result})
+}
+
+// SimulateOrder handles POST /api/v1/admin/orders/simulate
+func (h *OrderHandler) SimulateOrder(c *gin.Context) {
+	var req services.OrderSimulationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	simulation, err := h.orderService.SimulateOrder(c.Request.Context(), &req)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, services.ErrInvalidOrderSimulation):
+			status = http.StatusBadRequest
+		case errors.Is(err, services.ErrCustomerGroupNotFound):
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": simulation})
+}
+
+// GetPackingSlip handles GET /api/v1/admin/orders/:id/fulfillments/:fulfillment_id/packing-slip.
+// The slip is a PDF unless ?format=json is given.
+func (h *OrderHandler) GetPackingSlip(c *gin.Context) {
+	orderID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid order ID"})
+		return
+	}
+	fulfillmentID, err := uuid.Parse(c.Param("fulfillment_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid fulfillment ID"})
+		return
+	}
+
+	slip, err := h.orderService.PackingSlip(c.Request.Context(), orderID, fulfillmentID)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrFulfillmentNotFound) || err.Error() == "order not found" {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	if c.Query("format") == "json" {
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": slip})
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s-%d-packing-slip.pdf", slip.OrderNumber, slip.Shipment))
+	c.Data(http.StatusOK, "application/pdf", services.RenderPackingSlipPDF(slip))
+}
+
+// GetPickList handles GET /api/v1/admin/warehouse/pick-list?location=A1,B2&order_id=id1,id2
+func (h *OrderHandler) GetPickList(c *gin.Context) {
+	var filter services.PickListFilter
+	for _, value := range c.QueryArray("location") {
+		for _, location := range strings.Split(value, ",") {
+			if location = strings.TrimSpace(location); location != "" {
+				filter.Locations = append(filter.Locations, location)
+			}
+		}
+	}
+	for _, value := range c.QueryArray("order_id") {
+		for _, raw := range strings.Split(value, ",") {
+			raw = strings.TrimSpace(raw)
+			if raw == "" {
+				continue
+			}
+			orderID, err := uuid.Parse(raw)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid order ID: %s", raw)})
+				return
+			}
+			filter.OrderIDs = append(filter.OrderIDs, orderID)
+		}
+	}
+
+	list, err := h.orderService.PickList(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": list})
 }
 
 // UpdatePaymentStatus handles PUT /api/v1/orders/:id/payment-status
@@ -209,13 +429,13 @@ func (h *OrderHandler) UpdatePaymentStatus(c *gin.Context) {
 		return
 	}
 
-	order, err := h.orderService.UpdatePaymentStatus(orderID, req.PaymentStatus, req.PaymentIntentID)
+	order, err := h.orderService.UpdatePaymentStatus(c.Request.Context(), orderID, req.PaymentStatus, req.PaymentIntentID)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"order": order})
+	jsonWithFields(c, http.StatusOK, gin.H{"order": order}, "order")
 }
 
 // CancelOrder handles DELETE /api/v1/orders/:id
@@ -227,13 +447,13 @@ func (h *OrderHandler) CancelOrder(c *gin.Context) {
 		return
 	}
 
-	order, err := h.orderService.CancelOrder(orderID)
+	order, err := h.orderService.CancelOrder(c.Request.Context(), orderID)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"order": order})
+	jsonWithFields(c, http.StatusOK, gin.H{"order": order}, "order")
 }
 
 // GetOrderSummary handles GET /api/v1/orders/:id/summary
@@ -245,15 +465,15 @@ func (h *OrderHandler) GetOrderSummary(c *gin.Context) {
 		return
 	}
 
-	order, err := h.orderService.GetOrderByID(orderID)
+	order, err := h.orderService.GetOrderByID(c.Request.Context(), orderID)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
 	}
 
 	// Check access permissions
-	if userID, exists := c.Get("user_id"); exists {
-		if order.UserID != userID.(uuid.UUID) {
+	if userID := requestUserID(c); userID != nil {
+		if order.UserID != *userID {
 			c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
 			return
 		}
@@ -282,7 +502,63 @@ func (h *OrderHandler) GetOrderSummary(c *gin.Context) {
 		"created_at":      order.CreatedAt,
 		"updated_at":      order.UpdatedAt,
 		"item_count":      len(order.Items),
+		"fulfillments":    len(order.Fulfillments),
+		"backordered":     services.BackorderedFulfillment(order) != nil,
 	}
 
 	c.JSON(http.StatusOK, gin.H{"summary": summary})
 }
+
+// respondCheckoutConflict writes a 409 with in-stock alternatives if err is an
+// inventory conflict, sends the same report to the session's chat connections,
+// and reports whether it did
+func (h *OrderHandler) respondCheckoutConflict(c *gin.Context, req *services.CreateOrderRequest, err error) bool {
+	var conflictErr *services.InventoryConflictError
+	if !errors.As(err, &conflictErr) {
+		return false
+	}
+
+	var userID *uuid.UUID
+	if req.UserID != uuid.Nil {
+		userID = &req.UserID
+	}
+	conflict, buildErr := h.orderService.CheckoutConflict(c.Request.Context(), userID, conflictErr, 4)
+	if buildErr != nil {
+		log.Printf("Failed to build checkout conflict: %v", buildErr)
+		conflict = &services.CheckoutConflict{
+			ProductID:    conflictErr.ProductID,
+			VariantID:    conflictErr.VariantID,
+			Requested:    conflictErr.Requested,
+			Available:    conflictErr.Available,
+			Message:      "Sorry, this item is no longer available in the quantity you asked for.",
+			Alternatives: []services.ProductSuggestion{},
+		}
+	}
+
+	if h.notifier != nil {
+		h.notifier.NotifySession(req.SessionID, services.CheckoutConflictMessage, conflict)
+	}
+
+	c.JSON(http.StatusConflict, gin.H{
+		"error":    conflictErr.Error(),
+		"code":     services.CheckoutConflictMessage,
+		"conflict": conflict,
+	})
+	return true
+}
+
+// respondOrderViolations writes a 422 listing the broken order rules if err is
+// an order validation error, and reports whether it did
+func respondOrderViolations(c *gin.Context, err error) bool {
+	var validationErr *services.OrderValidationError
+	if !errors.As(err, &validationErr) {
+		return false
+	}
+
+	c.JSON(http.StatusUnprocessableEntity, gin.H{
+		"error":      validationErr.Error(),
+		"valid":      false,
+		"violations": validationErr.Violations,
+	})
+	return true
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/internal/services"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// OrderSubmissionResponse is an order submission with, once it completed,
+// the order and how to pay for offline payments
+type OrderSubmissionResponse struct {
+	*models.OrderSubmission
+	Order               *services.Order `json:"order,omitempty"`
+	PaymentInstructions string          `json:"payment_instructions,omitempty"`
+}
+
+// WithPipeline accepts checkouts through the order pipeline
+func (h *OrderHandler) WithPipeline(pipeline *services.OrderPipeline) *OrderHandler {
+	h.pipeline = pipeline
+	return h
+}
+
+// SubmitOrder handles POST /api/v1/orders/submissions: the checkout is
+// accepted at once with 202, and the order is created, its payment started
+// and the shopper notified in the background. Progress is at the Location,
+// and pushed over the shopper's chat socket as order_submission messages.
+// Sending the checkout again with the same Idempotency-Key header answers
+// with the submission it was first accepted as.
+func (h *OrderHandler) SubmitOrder(c *gin.Context) {
+	if h.pipeline == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Order submissions are not available"})
+		return
+	}
+	var req services.CreateOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID := requestUserID(c)
+	if userID == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	req.UserID = *userID
+	req.SessionID = orderSessionID(c)
+
+	submission, err := h.pipeline.Submit(c.Request.Context(), &req, c.GetHeader("Idempotency-Key"), time.Now())
+	if err != nil {
+		if respondWaitingRoom(c, err) {
+			return
+		}
+		if errors.Is(err, services.ErrDeliveryDateUnavailable) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Location", fmt.Sprintf("/api/v1/orders/submissions/%s", submission.ID))
+	c.Header("Retry-After", "1")
+	c.JSON(http.StatusAccepted, gin.H{"success": true, "data": OrderSubmissionResponse{OrderSubmission: submission}})
+}
+
+// GetOrderSubmission handles GET /api/v1/orders/submissions/:id, one of the
+// signed in user's submissions, with its order once it completed
+func (h *OrderHandler) GetOrderSubmission(c *gin.Context) {
+	userID := requestUserID(c)
+	if userID == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid submission ID"})
+		return
+	}
+	if h.pipeline == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": services.ErrOrderSubmissionNotFound.Error()})
+		return
+	}
+
+	submission, err := h.pipeline.GetSubmission(c.Request.Context(), id, *userID)
+	if err != nil {
+		if errors.Is(err, services.ErrOrderSubmissionNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := OrderSubmissionResponse{OrderSubmission: submission}
+	if submission.Status == services.OrderSubmissionCompleted && submission.OrderID != nil {
+		order, err := h.orderService.GetOrderByID(c.Request.Context(), *submission.OrderID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		response.Order = order
+		response.PaymentInstructions = h.orderService.OfflinePaymentInstructions(order)
+	}
+	if submission.Status == services.OrderSubmissionAccepted || submission.Status == services.OrderSubmissionProcessing {
+		c.Header("Retry-After", "1")
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": response})
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"chat-ecommerce-backend/internal/services"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// OrderRuleHandler handles management of order validation rules
+type OrderRuleHandler struct {
+	validator *services.OrderValidator
+}
+
+// NewOrderRuleHandler creates a new OrderRuleHandler
+func NewOrderRuleHandler(validator *services.OrderValidator) *OrderRuleHandler {
+	return &OrderRuleHandler{
+		validator: validator,
+	}
+}
+
+// GetRules handles GET /api/v1/admin/order-rules
+func (h *OrderRuleHandler) GetRules(c *gin.Context) {
+	rules, err := h.validator.ListRules(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    rules,
+	})
+}
+
+// CreateRule handles POST /api/v1/admin/order-rules
+func (h *OrderRuleHandler) CreateRule(c *gin.Context) {
+	var req services.OrderRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rule, err := h.validator.CreateRule(c.Request.Context(), req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"data":    rule,
+	})
+}
+
+// UpdateRule handles PUT /api/v1/admin/order-rules/:id
+func (h *OrderRuleHandler) UpdateRule(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid rule ID"})
+		return
+	}
+
+	var req services.OrderRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rule, err := h.validator.UpdateRule(c.Request.Context(), id, req)
+	if err != nil {
+		c.JSON(orderRuleErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    rule,
+	})
+}
+
+// DeleteRule handles DELETE /api/v1/admin/order-rules/:id
+func (h *OrderRuleHandler) DeleteRule(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid rule ID"})
+		return
+	}
+
+	if err := h.validator.DeleteRule(c.Request.Context(), id); err != nil {
+		c.JSON(orderRuleErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Order rule deleted successfully",
+	})
+}
+
+func orderRuleErrorStatus(err error) int {
+	if errors.Is(err, services.ErrOrderRuleNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusBadRequest
+}
@@ -2,23 +2,25 @@ package handlers
 
 import (
 	"chat-ecommerce-backend/internal/services"
+	"log"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
 )
 
 // PaymentHandler handles payment-related HTTP requests
 type PaymentHandler struct {
 	paymentService *services.PaymentService
 	orderService   *services.OrderService
+	dunningService *services.DunningService
 }
 
 // NewPaymentHandler creates a new PaymentHandler
-func NewPaymentHandler(paymentService *services.PaymentService, orderService *services.OrderService) *PaymentHandler {
+func NewPaymentHandler(paymentService *services.PaymentService, orderService *services.OrderService, dunningService *services.DunningService) *PaymentHandler {
 	return &PaymentHandler{
 		paymentService: paymentService,
 		orderService:   orderService,
+		dunningService: dunningService,
 	}
 }
 
@@ -31,15 +33,15 @@ func (h *PaymentHandler) CreatePaymentIntent(c *gin.Context) {
 	}
 
 	// Verify order exists and belongs to user
-	order, err := h.orderService.GetOrderByID(req.OrderID)
+	order, err := h.orderService.GetOrderByID(c.Request.Context(), req.OrderID)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "order not found"})
 		return
 	}
 
 	// Check if user can access this order
-	if userID, exists := c.Get("user_id"); exists {
-		if order.UserID != userID.(uuid.UUID) {
+	if userID := requestUserID(c); userID != nil {
+		if order.UserID != *userID {
 			c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
 			return
 		}
@@ -70,12 +72,18 @@ func (h *PaymentHandler) CreatePaymentIntent(c *gin.Context) {
 	}
 
 	// Update order with payment intent ID
-	_, err = h.orderService.UpdatePaymentStatus(req.OrderID, "processing", response.ID)
+	_, err = h.orderService.UpdatePaymentStatus(c.Request.Context(), req.OrderID, "processing", response.ID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update order payment status"})
 		return
 	}
 
+	// Remember the provider so later calls for this payment go to it
+	if err := h.orderService.UpdatePaymentProvider(c.Request.Context(), req.OrderID, response.Provider); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
 	c.JSON(http.StatusCreated, gin.H{"payment_intent": response})
 }
 
@@ -88,15 +96,15 @@ func (h *PaymentHandler) ConfirmPayment(c *gin.Context) {
 	}
 
 	// Verify order exists and belongs to user
-	order, err := h.orderService.GetOrderByID(req.OrderID)
+	order, err := h.orderService.GetOrderByID(c.Request.Context(), req.OrderID)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "order not found"})
 		return
 	}
 
 	// Check if user can access this order
-	if userID, exists := c.Get("user_id"); exists {
-		if order.UserID != userID.(uuid.UUID) {
+	if userID := requestUserID(c); userID != nil {
+		if order.UserID != *userID {
 			c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
 			return
 		}
@@ -113,7 +121,7 @@ func (h *PaymentHandler) ConfirmPayment(c *gin.Context) {
 	}
 
 	// Confirm payment
-	status, err := h.paymentService.ConfirmPayment(&req)
+	status, err := h.paymentService.ConfirmPayment(order.PaymentProvider, &req)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -132,12 +140,30 @@ func (h *PaymentHandler) ConfirmPayment(c *gin.Context) {
 		orderPaymentStatus = "failed"
 	}
 
-	_, err = h.orderService.UpdatePaymentStatus(req.OrderID, orderPaymentStatus, req.PaymentIntentID)
+	// Failed confirmations go to dunning, which schedules the retry and
+	// cancels the order once the retries run out
+	if orderPaymentStatus == "failed" {
+		if _, err := h.dunningService.RecordFailure(c.Request.Context(), req.OrderID, req.PaymentIntentID, "payment status: "+status.Status); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to schedule payment retry"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"payment_status": status})
+		return
+	}
+
+	_, err = h.orderService.UpdatePaymentStatus(c.Request.Context(), req.OrderID, orderPaymentStatus, req.PaymentIntentID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update order payment status"})
 		return
 	}
 
+	if orderPaymentStatus == "paid" {
+		if err := h.dunningService.RecordSuccess(c.Request.Context(), req.OrderID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{"payment_status": status})
 }
 
@@ -149,7 +175,7 @@ func (h *PaymentHandler) GetPaymentStatus(c *gin.Context) {
 		return
 	}
 
-	status, err := h.paymentService.GetPaymentStatus(paymentIntentID)
+	status, err := h.paymentService.GetPaymentStatus(h.paymentProvider(c, paymentIntentID), paymentIntentID)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
@@ -166,7 +192,7 @@ func (h *PaymentHandler) CancelPayment(c *gin.Context) {
 		return
 	}
 
-	status, err := h.paymentService.CancelPaymentIntent(paymentIntentID)
+	status, err := h.paymentService.CancelPaymentIntent(h.paymentProvider(c, paymentIntentID), paymentIntentID)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -198,12 +224,16 @@ func (h *PaymentHandler) RefundPayment(c *gin.Context) {
 		req.Reason = "requested_by_customer"
 	}
 
-	status, err := h.paymentService.RefundPayment(paymentIntentID, req.Amount, req.Reason)
+	status, err := h.paymentService.RefundPayment(h.paymentProvider(c, paymentIntentID), paymentIntentID, req.Amount, req.Reason)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	if err := h.orderService.RecordRefund(c.Request.Context(), paymentIntentID, req.Amount); err != nil {
+		log.Printf("Failed to record refund for %s: %v", paymentIntentID, err)
+	}
+
 	c.JSON(http.StatusOK, gin.H{"payment_status": status})
 }
 
@@ -258,32 +288,31 @@ func (h *PaymentHandler) HandleWebhook(c *gin.Context) {
 		return
 	}
 
+	if err := h.dunningService.ProcessPaymentEvent(c.Request.Context(), eventType, eventData); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{"status": "webhook processed"})
 }
 
 // GetPaymentMethods handles GET /api/v1/payments/methods
 func (h *PaymentHandler) GetPaymentMethods(c *gin.Context) {
-	// Return available payment methods
-	methods := []gin.H{
-		{
-			"id":          "card",
-			"name":        "Credit/Debit Card",
-			"description": "Pay with Visa, Mastercard, American Express",
-			"enabled":     true,
-		},
-		{
-			"id":          "apple_pay",
-			"name":        "Apple Pay",
-			"description": "Pay with Apple Pay",
-			"enabled":     false, // Disabled for now
-		},
-		{
-			"id":          "google_pay",
-			"name":        "Google Pay",
-			"description": "Pay with Google Pay",
-			"enabled":     false, // Disabled for now
-		},
-	}
-
-	c.JSON(http.StatusOK, gin.H{"payment_methods": methods})
+	// Only offer methods whose provider accepts the shopper's currency
+	currency := c.Query("currency")
+
+	c.JSON(http.StatusOK, gin.H{
+		"payment_methods": h.paymentService.PaymentMethods(currency),
+		"providers":       h.paymentService.ProviderCapabilities(),
+	})
+}
+
+// paymentProvider returns the provider that created a payment intent, or ""
+// (the default provider) for payments not linked to an order
+func (h *PaymentHandler) paymentProvider(c *gin.Context, paymentIntentID string) string {
+	order, err := h.orderService.GetOrderByPaymentIntentID(c.Request.Context(), paymentIntentID)
+	if err != nil {
+		return ""
+	}
+	return order.PaymentProvider
 }
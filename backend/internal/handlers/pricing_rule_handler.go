@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"chat-ecommerce-backend/internal/services"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// PricingRuleHandler handles admin management of the pricing rules
+type PricingRuleHandler struct {
+	ruleService *services.PricingRuleService
+}
+
+// NewPricingRuleHandler creates a new PricingRuleHandler
+func NewPricingRuleHandler(ruleService *services.PricingRuleService) *PricingRuleHandler {
+	return &PricingRuleHandler{
+		ruleService: ruleService,
+	}
+}
+
+// GetRules handles GET /api/v1/admin/pricing-rules
+func (h *PricingRuleHandler) GetRules(c *gin.Context) {
+	rules, err := h.ruleService.ListRules(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": rules})
+}
+
+// GetRule handles GET /api/v1/admin/pricing-rules/:id
+func (h *PricingRuleHandler) GetRule(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid pricing rule ID"})
+		return
+	}
+
+	rule, err := h.ruleService.GetRule(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(pricingRuleErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": rule})
+}
+
+// CreateRule handles POST /api/v1/admin/pricing-rules
+func (h *PricingRuleHandler) CreateRule(c *gin.Context) {
+	var req services.PricingRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rule, err := h.ruleService.CreateRule(c.Request.Context(), requestUserID(c), req)
+	if err != nil {
+		c.JSON(pricingRuleErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"success": true, "data": rule})
+}
+
+// UpdateRule handles PUT /api/v1/admin/pricing-rules/:id
+func (h *PricingRuleHandler) UpdateRule(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid pricing rule ID"})
+		return
+	}
+
+	var req services.PricingRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rule, err := h.ruleService.UpdateRule(c.Request.Context(), id, req)
+	if err != nil {
+		c.JSON(pricingRuleErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": rule})
+}
+
+// DeleteRule handles DELETE /api/v1/admin/pricing-rules/:id
+func (h *PricingRuleHandler) DeleteRule(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid pricing rule ID"})
+		return
+	}
+
+	if err := h.ruleService.DeleteRule(c.Request.Context(), id); err != nil {
+		c.JSON(pricingRuleErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Pricing rule deleted successfully"})
+}
+
+func pricingRuleErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrPricingRuleNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrInvalidPricingRule):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
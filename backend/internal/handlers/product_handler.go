@@ -3,9 +3,11 @@ package handlers
 import (
 	"chat-ecommerce-backend/internal/models"
 	"chat-ecommerce-backend/internal/services"
+	"errors"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -14,6 +16,8 @@ import (
 // ProductHandler handles product-related HTTP requests
 type ProductHandler struct {
 	productService *services.ProductService
+	events         *services.EventStream
+	flashSales     *services.FlashSaleService
 }
 
 // NewProductHandler creates a new ProductHandler
@@ -23,18 +27,28 @@ func NewProductHandler(productService *services.ProductService) *ProductHandler
 	}
 }
 
-// GetProducts handles GET /api/v1/products
+// WithEventStream publishes product page views to the analytics stream
+func (h *ProductHandler) WithEventStream(events *services.EventStream) *ProductHandler {
+	h.events = events
+	return h
+}
+
+// WithFlashSales serves the products of an upcoming or running flash sale
+// from its warm cache
+func (h *ProductHandler) WithFlashSales(flashSales *services.FlashSaleService) *ProductHandler {
+	h.flashSales = flashSales
+	return h
+}
+
+// GetProducts handles GET /api/v1/products, filtered, sorted and paged
+// with the list parameters of services.ProductListSchema and the metadata
+// attribute filters of categories' schemas
 func (h *ProductHandler) GetProducts(c *gin.Context) {
-	// Parse query parameters
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
-	search := c.Query("search")
-	categoryIDStr := c.Query("category_id")
-	minPrice, _ := strconv.ParseFloat(c.Query("min_price"), 64)
-	maxPrice, _ := strconv.ParseFloat(c.Query("max_price"), 64)
-	status := c.Query("status")
-	sortBy := c.DefaultQuery("sort_by", "created_at")
-	sortOrder := c.DefaultQuery("sort_order", "desc")
+	// Categories' metadata schemas add filters on their facet attributes
+	list, ok := listQuery(c, h.productService.ListSchema(c.Request.Context()))
+	if !ok {
+		return
+	}
 
 	// Parse tags
 	var tags []string
@@ -42,37 +56,9 @@ func (h *ProductHandler) GetProducts(c *gin.Context) {
 		tags = strings.Split(tagsStr, ",")
 	}
 
-	// Parse category ID
-	var categoryID uuid.UUID
-	if categoryIDStr != "" {
-		var err error
-		categoryID, err = uuid.Parse(categoryIDStr)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category ID"})
-			return
-		}
-	}
-
-	// Validate pagination parameters
-	if page < 1 {
-		page = 1
-	}
-	if limit < 1 || limit > 100 {
-		limit = 10
-	}
-
-	// Create filters
 	filters := services.ProductFilters{
-		Search:     search,
-		CategoryID: categoryID,
-		MinPrice:   minPrice,
-		MaxPrice:   maxPrice,
-		Status:     status,
-		Tags:       tags,
-		Page:       page,
-		Limit:      limit,
-		SortBy:     sortBy,
-		SortOrder:  sortOrder,
+		Tags: tags,
+		List: list,
 	}
 
 	// Get products
@@ -81,8 +67,14 @@ func (h *ProductHandler) GetProducts(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	if !h.applyCustomerPricing(c, result.Products) {
+		return
+	}
+	if notModified(c, newETagBuilder().add(result.Total).addProducts(result.Products).tag()) {
+		return
+	}
 
-	c.JSON(http.StatusOK, result)
+	jsonWithFields(c, http.StatusOK, result, "products")
 }
 
 // GetProductByID handles GET /api/v1/products/:id
@@ -94,17 +86,29 @@ func (h *ProductHandler) GetProductByID(c *gin.Context) {
 		return
 	}
 
-	product, err := h.productService.GetProductByID(id)
-	if err != nil {
-		if err.Error() == "product not found" {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+	// Drop day page views of the sale's products don't reach the database
+	product, warm := h.flashSales.Product(id, time.Now())
+	if !warm {
+		product, err = h.productService.GetProductByID(id)
+		if err != nil {
+			if err.Error() == "product not found" {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+	priced := []models.Product{*product}
+	if !h.applyCustomerPricing(c, priced) {
+		return
+	}
+	h.events.ProductViewed(c.GetHeader("X-Session-ID"), requestUserID(c), &priced[0])
+	if notModified(c, newETagBuilder().addProducts(priced).tag()) {
 		return
 	}
 
-	c.JSON(http.StatusOK, product)
+	jsonWithFields(c, http.StatusOK, priced[0])
 }
 
 // GetProductBySKU handles GET /api/v1/products/sku/:sku
@@ -124,8 +128,16 @@ func (h *ProductHandler) GetProductBySKU(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	priced := []models.Product{*product}
+	if !h.applyCustomerPricing(c, priced) {
+		return
+	}
+	h.events.ProductViewed(c.GetHeader("X-Session-ID"), requestUserID(c), &priced[0])
+	if notModified(c, newETagBuilder().addProducts(priced).tag()) {
+		return
+	}
 
-	c.JSON(http.StatusOK, product)
+	jsonWithFields(c, http.StatusOK, priced[0])
 }
 
 // CreateProduct handles POST /api/v1/products
@@ -149,6 +161,9 @@ func (h *ProductHandler) CreateProduct(c *gin.Context) {
 	}
 
 	if err := h.productService.CreateProduct(newProduct); err != nil {
+		if respondSKUError(c, err) || respondMetadataViolations(c, err) {
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -172,6 +187,9 @@ func (h *ProductHandler) UpdateProduct(c *gin.Context) {
 	}
 
 	if err := h.productService.UpdateProduct(id, updates); err != nil {
+		if respondSKUError(c, err) || respondMetadataViolations(c, err) {
+			return
+		}
 		if err.Error() == "product not found" {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
 			return
@@ -211,6 +229,9 @@ func (h *ProductHandler) GetCategories(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	if notModified(c, newETagBuilder().addCategories(categories).tag()) {
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{"categories": categories})
 }
@@ -233,10 +254,34 @@ func (h *ProductHandler) GetCategoryByID(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	if notModified(c, newETagBuilder().addCategories([]models.Category{*category}).tag()) {
+		return
+	}
 
 	c.JSON(http.StatusOK, category)
 }
 
+// GetCategoryFacets handles GET /api/v1/categories/:id/facets
+func (h *ProductHandler) GetCategoryFacets(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category ID"})
+		return
+	}
+
+	facets, err := h.productService.CategoryFacets(c.Request.Context(), id)
+	if err != nil {
+		if errors.Is(err, services.ErrCategoryNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"facets": facets})
+}
+
 // GetCategoryBySlug handles GET /api/v1/categories/slug/:slug
 func (h *ProductHandler) GetCategoryBySlug(c *gin.Context) {
 	slug := c.Param("slug")
@@ -254,11 +299,15 @@ func (h *ProductHandler) GetCategoryBySlug(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	if notModified(c, newETagBuilder().addCategories([]models.Category{*category}).tag()) {
+		return
+	}
 
 	c.JSON(http.StatusOK, category)
 }
 
-// SearchProducts handles GET /api/v1/products/search
+// SearchProducts handles GET /api/v1/products/search?q=&brand_id=. Without
+// brand_id, a brand named in the query narrows the search to it.
 func (h *ProductHandler) SearchProducts(c *gin.Context) {
 	query := c.Query("q")
 	if query == "" {
@@ -271,13 +320,24 @@ func (h *ProductHandler) SearchProducts(c *gin.Context) {
 		limit = 20
 	}
 
-	products, err := h.productService.SearchProducts(query, limit)
+	brandID, ok := parseBrandID(c)
+	if !ok {
+		return
+	}
+
+	products, err := h.productService.SearchBrandProducts(query, brandID, limit)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	if len(products) == 0 {
+		h.productService.RecordSearchMiss(c.Request.Context(), query, c.GetHeader("X-Session-ID"), requestUserID(c))
+	}
+	if !h.applyCustomerPricing(c, products) {
+		return
+	}
 
-	c.JSON(http.StatusOK, gin.H{"products": products})
+	jsonWithFields(c, http.StatusOK, gin.H{"products": products}, "products")
 }
 
 // GetFeaturedProducts handles GET /api/v1/products/featured
@@ -292,8 +352,15 @@ func (h *ProductHandler) GetFeaturedProducts(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	if !h.applyCustomerPricing(c, products) {
+		return
+	}
+
+	if notModified(c, newETagBuilder().addProducts(products).tag()) {
+		return
+	}
 
-	c.JSON(http.StatusOK, gin.H{"products": products})
+	jsonWithFields(c, http.StatusOK, gin.H{"products": products}, "products")
 }
 
 // GetRelatedProducts handles GET /api/v1/products/:id/related
@@ -315,6 +382,23 @@ func (h *ProductHandler) GetRelatedProducts(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	if !h.applyCustomerPricing(c, products) {
+		return
+	}
+
+	if notModified(c, newETagBuilder().addProducts(products).tag()) {
+		return
+	}
 
-	c.JSON(http.StatusOK, gin.H{"products": products})
+	jsonWithFields(c, http.StatusOK, gin.H{"products": products}, "products")
+}
+
+// applyCustomerPricing prices products for the requesting customer's group,
+// responding with an error and returning false if pricing fails
+func (h *ProductHandler) applyCustomerPricing(c *gin.Context, products []models.Product) bool {
+	if err := h.productService.ApplyCustomerPricing(c.Request.Context(), requestUserID(c), products); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+	return true
 }
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"chat-ecommerce-backend/internal/services"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ProductLifecycleHandler handles admin scheduling of product publish and unpublish times
+type ProductLifecycleHandler struct {
+	lifecycleService *services.ProductLifecycleService
+}
+
+// NewProductLifecycleHandler creates a new ProductLifecycleHandler
+func NewProductLifecycleHandler(lifecycleService *services.ProductLifecycleService) *ProductLifecycleHandler {
+	return &ProductLifecycleHandler{
+		lifecycleService: lifecycleService,
+	}
+}
+
+// SetSchedule handles PUT /api/v1/admin/products/:id/schedule
+func (h *ProductLifecycleHandler) SetSchedule(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		return
+	}
+
+	var req services.ProductScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	product, err := h.lifecycleService.SetSchedule(c.Request.Context(), id, req)
+	if err != nil {
+		if err.Error() == "product not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    product,
+	})
+}
+
+// GetScheduledProducts handles GET /api/v1/admin/products/scheduled
+func (h *ProductLifecycleHandler) GetScheduledProducts(c *gin.Context) {
+	products, err := h.lifecycleService.GetScheduledProducts(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    products,
+	})
+}
+
+// RunSchedule handles POST /api/v1/admin/products/scheduled/run
+func (h *ProductLifecycleHandler) RunSchedule(c *gin.Context) {
+	result, err := h.lifecycleService.RunScheduledTransitions(c.Request.Context(), time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    result,
+	})
+}
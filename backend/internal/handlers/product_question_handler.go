@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"chat-ecommerce-backend/internal/services"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ProductQuestionHandler handles product Q&A: customers asking questions on
+// product pages and admins answering or approving drafted answers
+type ProductQuestionHandler struct {
+	questionService *services.ProductQuestionService
+}
+
+// NewProductQuestionHandler creates a new ProductQuestionHandler
+func NewProductQuestionHandler(questionService *services.ProductQuestionService) *ProductQuestionHandler {
+	return &ProductQuestionHandler{
+		questionService: questionService,
+	}
+}
+
+// GetProductQuestions handles GET /api/v1/products/:id/questions and lists
+// the product's answered questions
+func (h *ProductQuestionHandler) GetProductQuestions(c *gin.Context) {
+	productID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		return
+	}
+
+	questions, err := h.questionService.ListAnswered(c.Request.Context(), productID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"questions": questions})
+}
+
+// AskQuestion handles POST /api/v1/products/:id/questions. The question is
+// shown once an admin has answered it.
+func (h *ProductQuestionHandler) AskQuestion(c *gin.Context) {
+	productID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		return
+	}
+
+	var req services.AskQuestionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	question, err := h.questionService.AskQuestion(c.Request.Context(), productID, requestUserID(c), req)
+	if err != nil {
+		c.JSON(questionErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":  "Thanks! Your question will appear once it has been answered.",
+		"question": question,
+	})
+}
+
+// GetQuestions handles GET /api/v1/admin/questions?status=pending&product_id=
+func (h *ProductQuestionHandler) GetQuestions(c *gin.Context) {
+	var productID *uuid.UUID
+	if productIDStr := c.Query("product_id"); productIDStr != "" {
+		id, err := uuid.Parse(productIDStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+			return
+		}
+		productID = &id
+	}
+
+	questions, err := h.questionService.ListQuestions(c.Request.Context(), c.Query("status"), productID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    questions,
+	})
+}
+
+// AnswerQuestion handles POST /api/v1/admin/questions/:id/answer
+func (h *ProductQuestionHandler) AnswerQuestion(c *gin.Context) {
+	id, req, ok := bindAnswer(c)
+	if !ok {
+		return
+	}
+
+	question, err := h.questionService.AnswerQuestion(c.Request.Context(), id, req.Answer, requestUserID(c))
+	if err != nil {
+		c.JSON(questionErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    question,
+	})
+}
+
+// SuggestAnswer handles POST /api/v1/admin/questions/:id/suggest and has the
+// assistant draft an answer for approval
+func (h *ProductQuestionHandler) SuggestAnswer(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid question ID"})
+		return
+	}
+
+	question, err := h.questionService.SuggestAnswer(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(questionErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    question,
+	})
+}
+
+// ApproveAnswer handles POST /api/v1/admin/questions/:id/approve. An answer
+// in the body replaces the suggested one.
+func (h *ProductQuestionHandler) ApproveAnswer(c *gin.Context) {
+	id, req, ok := bindAnswer(c)
+	if !ok {
+		return
+	}
+
+	question, err := h.questionService.ApproveAnswer(c.Request.Context(), id, req.Answer, requestUserID(c))
+	if err != nil {
+		c.JSON(questionErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    question,
+	})
+}
+
+// RejectQuestion handles POST /api/v1/admin/questions/:id/reject
+func (h *ProductQuestionHandler) RejectQuestion(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid question ID"})
+		return
+	}
+
+	question, err := h.questionService.RejectQuestion(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(questionErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    question,
+	})
+}
+
+// DeleteQuestion handles DELETE /api/v1/admin/questions/:id
+func (h *ProductQuestionHandler) DeleteQuestion(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid question ID"})
+		return
+	}
+
+	if err := h.questionService.DeleteQuestion(c.Request.Context(), id); err != nil {
+		c.JSON(questionErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Question deleted successfully",
+	})
+}
+
+// bindAnswer reads the question ID and an optional answer body
+func bindAnswer(c *gin.Context) (uuid.UUID, services.AnswerQuestionRequest, bool) {
+	var req services.AnswerQuestionRequest
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid question ID"})
+		return id, req, false
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return id, req, false
+		}
+	}
+	return id, req, true
+}
+
+// questionErrorStatus maps product question errors to HTTP status codes
+func questionErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrQuestionNotFound), errors.Is(err, services.ErrProductNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrNoSuggestedAnswer):
+		return http.StatusConflict
+	case errors.Is(err, services.ErrAnswerDraftFailed):
+		return http.StatusBadGateway
+	default:
+		return http.StatusBadRequest
+	}
+}
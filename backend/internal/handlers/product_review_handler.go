@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"chat-ecommerce-backend/internal/services"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// submitForReview saves a product edit by an admin who can't publish directly
+// as a change request. ?draft=true keeps it as a draft.
+func (h *AdminHandler) submitForReview(c *gin.Context, productID uuid.UUID, kind string, changes interface{}) {
+	change, err := h.adminProductService.SubmitProductChange(c.Request.Context(), productID, kind, changes,
+		requestUserID(c), c.GetString("user_email"), c.Query("draft") == "true")
+	if err != nil {
+		c.JSON(productChangeErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"success": true,
+		"message": "Change submitted for review",
+		"data":    change,
+	})
+}
+
+// GetProductChanges handles GET /api/v1/admin/product-changes?status=pending&product_id=
+func (h *AdminHandler) GetProductChanges(c *gin.Context) {
+	var productID *uuid.UUID
+	if productIDStr := c.Query("product_id"); productIDStr != "" {
+		id, err := uuid.Parse(productIDStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+			return
+		}
+		productID = &id
+	}
+
+	changes, err := h.adminProductService.ListProductChanges(c.Request.Context(), c.Query("status"), productID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    changes,
+	})
+}
+
+// GetProductChange handles GET /api/v1/admin/product-changes/:id
+func (h *AdminHandler) GetProductChange(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid change request ID"})
+		return
+	}
+
+	change, err := h.adminProductService.GetProductChange(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(productChangeErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    change,
+	})
+}
+
+// SubmitProductChange handles POST /api/v1/admin/product-changes/:id/submit
+func (h *AdminHandler) SubmitProductChange(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid change request ID"})
+		return
+	}
+
+	change, err := h.adminProductService.SubmitDraftChange(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(productChangeErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    change,
+	})
+}
+
+// ApproveProductChange handles POST /api/v1/admin/product-changes/:id/approve
+func (h *AdminHandler) ApproveProductChange(c *gin.Context) {
+	h.reviewProductChange(c, true)
+}
+
+// RejectProductChange handles POST /api/v1/admin/product-changes/:id/reject
+func (h *AdminHandler) RejectProductChange(c *gin.Context) {
+	h.reviewProductChange(c, false)
+}
+
+func (h *AdminHandler) reviewProductChange(c *gin.Context, approve bool) {
+	if !h.adminProductService.CanPublish(c.GetString("user_email")) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only senior admins can review product changes"})
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid change request ID"})
+		return
+	}
+
+	var req struct {
+		Comment string `json:"comment"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil && c.Request.ContentLength > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	review := h.adminProductService.RejectProductChange
+	if approve {
+		review = h.adminProductService.ApproveProductChange
+	}
+	change, err := review(c.Request.Context(), id, requestUserID(c), req.Comment)
+	if err != nil {
+		c.JSON(productChangeErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    change,
+	})
+}
+
+// productChangeErrorStatus maps product review errors to HTTP status codes
+func productChangeErrorStatus(err error) int {
+	var skuConflict *services.SKUConflictError
+	var metadataErr *services.MetadataValidationError
+	switch {
+	case errors.Is(err, services.ErrChangeRequestNotFound), errors.Is(err, services.ErrProductNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrChangeNotReviewable), errors.Is(err, services.ErrVariantInUse), errors.As(err, &skuConflict):
+		return http.StatusConflict
+	case errors.As(err, &metadataErr):
+		return http.StatusUnprocessableEntity
+	default:
+		return http.StatusBadRequest
+	}
+}
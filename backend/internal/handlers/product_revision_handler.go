@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"chat-ecommerce-backend/internal/services"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// GetProductRevisions handles GET /api/v1/admin/products/:id/revisions
+func (h *AdminHandler) GetProductRevisions(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		return
+	}
+
+	revisions, err := h.adminProductService.ListProductRevisions(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    revisions,
+	})
+}
+
+// GetProductRevision handles GET /api/v1/admin/products/:id/revisions/:version
+func (h *AdminHandler) GetProductRevision(c *gin.Context) {
+	id, version, ok := revisionParams(c)
+	if !ok {
+		return
+	}
+
+	revision, err := h.adminProductService.GetProductRevision(c.Request.Context(), id, version)
+	if err != nil {
+		c.JSON(revisionErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    revision,
+	})
+}
+
+// RollbackProduct handles POST /api/v1/admin/products/:id/revisions/:version/rollback
+func (h *AdminHandler) RollbackProduct(c *gin.Context) {
+	if !h.adminProductService.CanPublish(c.GetString("user_email")) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only senior admins can roll back products"})
+		return
+	}
+
+	id, version, ok := revisionParams(c)
+	if !ok {
+		return
+	}
+
+	response, err := h.adminProductService.RollbackProduct(c.Request.Context(), id, version, requestUserID(c))
+	if err != nil {
+		c.JSON(revisionErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Product rolled back",
+		"data":    response,
+	})
+}
+
+func revisionParams(c *gin.Context) (uuid.UUID, int, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		return uuid.Nil, 0, false
+	}
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil || version < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid revision version"})
+		return uuid.Nil, 0, false
+	}
+	return id, version, true
+}
+
+// revisionErrorStatus maps product revision errors to HTTP status codes
+func revisionErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrRevisionNotFound), errors.Is(err, services.ErrProductNotFound):
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
+}
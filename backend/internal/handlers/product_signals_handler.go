@@ -0,0 +1,344 @@
+package handlers
+
+import (
+	"chat-ecommerce-backend/internal/services"
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// maxProductSubscriptions is how many product channels one connection may follow
+const maxProductSubscriptions = 50
+
+// ProductSignalsHandler runs the product channels: the storefront and chat
+// subscribe to products over a WebSocket and are sent their viewer, purchase
+// and stock hints as they change
+type ProductSignalsHandler struct {
+	signals  *services.ProductSignalsService
+	upgrader websocket.Upgrader
+
+	connMu      sync.RWMutex
+	subscribers map[uuid.UUID]map[*productConn]bool // product -> connection -> viewing it
+	lastSent    map[uuid.UUID]services.ProductSignals
+}
+
+// productConn serialises writes to a product channel connection, which
+// broadcasts write to alongside the read loop
+type productConn struct {
+	*websocket.Conn
+	mu     sync.Mutex
+	viewer string
+}
+
+// WriteJSON writes a message to the connection
+func (c *productConn) WriteJSON(v interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.Conn.WriteJSON(v)
+}
+
+// ProductChannelMessage is a message on the product channels. The server
+// sends "product_signals" with services.ProductSignals; clients send
+// "subscribe" and "unsubscribe" with a ProductSubscription.
+type ProductChannelMessage struct {
+	Type string      `json:"type"`
+	Data interface{} `json:"data"`
+}
+
+// ProductSubscription follows a product's hints. Viewing counts the shopper
+// as looking at the product, as its page does; chat suggestions only follow.
+type ProductSubscription struct {
+	ProductID uuid.UUID `json:"product_id"`
+	Viewing   bool      `json:"viewing"`
+}
+
+type productChannelRequest struct {
+	Type string              `json:"type"`
+	Data ProductSubscription `json:"data"`
+}
+
+// NewProductSignalsHandler creates a new ProductSignalsHandler
+func NewProductSignalsHandler(signals *services.ProductSignalsService) *ProductSignalsHandler {
+	return &ProductSignalsHandler{
+		signals: signals,
+		upgrader: websocket.Upgrader{
+			CheckOrigin: func(r *http.Request) bool {
+				return true // Allow all origins in development
+			},
+		},
+		subscribers: make(map[uuid.UUID]map[*productConn]bool),
+		lastSent:    make(map[uuid.UUID]services.ProductSignals),
+	}
+}
+
+// HandleWebSocket handles GET /api/v1/products/ws?session_id=... The
+// storefront session counts the shopper once however many tabs they have open.
+func (h *ProductSignalsHandler) HandleWebSocket(c *gin.Context) {
+	session := c.Query("session_id")
+	if session == "" {
+		session = uuid.NewString()
+	}
+
+	wsConn, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		log.Printf("Failed to upgrade product WebSocket connection: %v", err)
+		return
+	}
+	conn := &productConn{Conn: wsConn, viewer: services.ViewerKey(session)}
+	defer conn.Close()
+	defer h.unsubscribeAll(conn)
+
+	ctx := c.Request.Context()
+	for {
+		var req productChannelRequest
+		if err := conn.ReadJSON(&req); err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+				log.Printf("Product WebSocket error: %v", err)
+			}
+			break
+		}
+		if req.Data.ProductID == uuid.Nil {
+			conn.WriteJSON(ProductChannelMessage{Type: "error", Data: ChatError{Message: "data must name a product_id"}})
+			continue
+		}
+
+		switch req.Type {
+		case "subscribe":
+			if !h.subscribe(conn, req.Data) {
+				conn.WriteJSON(ProductChannelMessage{Type: "error", Data: ChatError{Message: "too many product subscriptions"}})
+				continue
+			}
+			if req.Data.Viewing {
+				h.signals.View(req.Data.ProductID, conn.viewer, time.Now())
+				h.broadcast(ctx, req.Data.ProductID)
+				continue
+			}
+			// Only the new subscriber needs the current hints
+			if signals, err := h.signals.Signals(ctx, req.Data.ProductID, time.Now()); err == nil {
+				conn.WriteJSON(ProductChannelMessage{Type: services.ProductSignalsMessage, Data: signals})
+			}
+		case "unsubscribe":
+			if h.unsubscribe(conn, req.Data.ProductID) {
+				h.broadcast(ctx, req.Data.ProductID)
+			}
+		default:
+			log.Printf("Unknown product channel message type: %s", req.Type)
+		}
+	}
+}
+
+// subscribe adds the connection to a product's channel, reporting false when
+// it follows too many products already
+func (h *ProductSignalsHandler) subscribe(conn *productConn, sub ProductSubscription) bool {
+	h.connMu.Lock()
+	defer h.connMu.Unlock()
+	if _, following := h.subscribers[sub.ProductID][conn]; !following {
+		count := 0
+		for _, conns := range h.subscribers {
+			if _, ok := conns[conn]; ok {
+				count++
+			}
+		}
+		if count >= maxProductSubscriptions {
+			return false
+		}
+	}
+	if h.subscribers[sub.ProductID] == nil {
+		h.subscribers[sub.ProductID] = make(map[*productConn]bool)
+	}
+	h.subscribers[sub.ProductID][conn] = h.subscribers[sub.ProductID][conn] || sub.Viewing
+	return true
+}
+
+// unsubscribe removes the connection from a product's channel, reporting
+// whether the product lost a viewer
+func (h *ProductSignalsHandler) unsubscribe(conn *productConn, productID uuid.UUID) bool {
+	h.connMu.Lock()
+	viewing, following := h.subscribers[productID][conn]
+	delete(h.subscribers[productID], conn)
+	if len(h.subscribers[productID]) == 0 {
+		delete(h.subscribers, productID)
+		delete(h.lastSent, productID)
+	}
+	// The shopper may still be viewing it in another tab
+	stillViewing := false
+	for other, otherViewing := range h.subscribers[productID] {
+		if otherViewing && other.viewer == conn.viewer {
+			stillViewing = true
+			break
+		}
+	}
+	h.connMu.Unlock()
+
+	if !following || !viewing || stillViewing {
+		return false
+	}
+	h.signals.Leave(productID, conn.viewer)
+	return true
+}
+
+// unsubscribeAll removes a closed connection from every product's channel
+func (h *ProductSignalsHandler) unsubscribeAll(conn *productConn) {
+	h.connMu.RLock()
+	var products []uuid.UUID
+	for productID, conns := range h.subscribers {
+		if _, ok := conns[conn]; ok {
+			products = append(products, productID)
+		}
+	}
+	h.connMu.RUnlock()
+
+	for _, productID := range products {
+		if h.unsubscribe(conn, productID) {
+			h.broadcast(context.Background(), productID)
+		}
+	}
+}
+
+// broadcast sends a product's current hints to its subscribers
+func (h *ProductSignalsHandler) broadcast(ctx context.Context, productID uuid.UUID) {
+	signals, err := h.signals.Signals(ctx, productID, time.Now())
+	if err != nil {
+		log.Printf("Failed to get product signals: %v", err)
+		return
+	}
+	h.send(*signals)
+}
+
+// send writes hints to the product's subscribers, remembering them so
+// unchanged hints aren't sent again
+func (h *ProductSignalsHandler) send(signals services.ProductSignals) {
+	h.connMu.Lock()
+	conns := make([]*productConn, 0, len(h.subscribers[signals.ProductID]))
+	for conn := range h.subscribers[signals.ProductID] {
+		conns = append(conns, conn)
+	}
+	if len(conns) > 0 {
+		h.lastSent[signals.ProductID] = signals
+	}
+	h.connMu.Unlock()
+
+	msg := ProductChannelMessage{Type: services.ProductSignalsMessage, Data: signals}
+	for _, conn := range conns {
+		if err := conn.WriteJSON(msg); err != nil {
+			log.Printf("Failed to send product signals: %v", err)
+		}
+	}
+}
+
+// SchedulePushing keeps connected viewers counted and sends each followed
+// product's hints when they change, as purchases and stock move
+func (h *ProductSignalsHandler) SchedulePushing(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(h.signals.Config().PushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				h.push(ctx)
+			}
+		}
+	}()
+}
+
+// push refreshes the connected viewers and sends changed hints
+func (h *ProductSignalsHandler) push(ctx context.Context) {
+	now := time.Now()
+	h.connMu.RLock()
+	products := make([]uuid.UUID, 0, len(h.subscribers))
+	for productID, conns := range h.subscribers {
+		products = append(products, productID)
+		for conn, viewing := range conns {
+			if viewing {
+				h.signals.View(productID, conn.viewer, now)
+			}
+		}
+	}
+	h.connMu.RUnlock()
+	if len(products) == 0 {
+		return
+	}
+
+	snapshot, err := h.signals.Snapshot(ctx, products, now)
+	if err != nil {
+		log.Printf("Failed to get product signals: %v", err)
+		return
+	}
+	for _, signals := range snapshot {
+		h.connMu.RLock()
+		last, sent := h.lastSent[signals.ProductID]
+		h.connMu.RUnlock()
+		if !sent || last != signals {
+			h.send(signals)
+		}
+	}
+}
+
+// GetSignals handles GET /api/v1/products/:id/signals, the hints for
+// clients without WebSockets
+func (h *ProductSignalsHandler) GetSignals(c *gin.Context) {
+	productID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		return
+	}
+
+	signals, err := h.signals.Signals(c.Request.Context(), productID, time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    signals,
+	})
+}
+
+// GetSettings handles GET /api/v1/admin/product-signals/settings
+func (h *ProductSignalsHandler) GetSettings(c *gin.Context) {
+	settings, err := h.signals.GetSettings(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    settings,
+	})
+}
+
+// UpdateSettings handles PUT /api/v1/admin/product-signals/settings, turning
+// the viewer, purchase and low stock hints on or off and setting when they show
+func (h *ProductSignalsHandler) UpdateSettings(c *gin.Context) {
+	var req services.SocialProofSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	settings, err := h.signals.UpdateSettings(c.Request.Context(), req, requestUserID(c))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrInvalidSocialProofSettings) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    settings,
+	})
+}
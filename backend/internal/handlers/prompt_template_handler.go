@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"chat-ecommerce-backend/internal/services"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// PromptTemplateHandler handles admin management of the chat assistant's
+// persona and instructions
+type PromptTemplateHandler struct {
+	promptService *services.PromptTemplateService
+}
+
+// NewPromptTemplateHandler creates a new PromptTemplateHandler
+func NewPromptTemplateHandler(promptService *services.PromptTemplateService) *PromptTemplateHandler {
+	return &PromptTemplateHandler{
+		promptService: promptService,
+	}
+}
+
+// GetTemplates handles GET /api/v1/admin/chat/prompts
+func (h *PromptTemplateHandler) GetTemplates(c *gin.Context) {
+	templates, err := h.promptService.ListTemplates(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": templates})
+}
+
+// GetTemplate handles GET /api/v1/admin/chat/prompts/:id
+func (h *PromptTemplateHandler) GetTemplate(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid prompt template ID"})
+		return
+	}
+
+	template, err := h.promptService.GetTemplate(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(promptTemplateErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": template})
+}
+
+// CreateTemplate handles POST /api/v1/admin/chat/prompts
+func (h *PromptTemplateHandler) CreateTemplate(c *gin.Context) {
+	var req services.PromptTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	template, err := h.promptService.CreateTemplate(c.Request.Context(), requestUserID(c), req)
+	if err != nil {
+		c.JSON(promptTemplateErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"success": true, "data": template})
+}
+
+// UpdateTemplate handles PUT /api/v1/admin/chat/prompts/:id
+func (h *PromptTemplateHandler) UpdateTemplate(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid prompt template ID"})
+		return
+	}
+
+	var req services.PromptTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	template, err := h.promptService.UpdateTemplate(c.Request.Context(), id, req)
+	if err != nil {
+		c.JSON(promptTemplateErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": template})
+}
+
+// DeleteTemplate handles DELETE /api/v1/admin/chat/prompts/:id
+func (h *PromptTemplateHandler) DeleteTemplate(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid prompt template ID"})
+		return
+	}
+
+	if err := h.promptService.DeleteTemplate(c.Request.Context(), id); err != nil {
+		c.JSON(promptTemplateErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Prompt template deleted successfully"})
+}
+
+func promptTemplateErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrPromptTemplateNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrPromptTemplateNameTaken):
+		return http.StatusConflict
+	case errors.Is(err, services.ErrPromptTemplateNameRequired):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,310 @@
+package handlers
+
+import (
+	"chat-ecommerce-backend/internal/services"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// QuoteHandler handles B2B quote requests, negotiation and conversion
+type QuoteHandler struct {
+	quoteService *services.QuoteService
+}
+
+// NewQuoteHandler creates a new QuoteHandler
+func NewQuoteHandler(quoteService *services.QuoteService) *QuoteHandler {
+	return &QuoteHandler{
+		quoteService: quoteService,
+	}
+}
+
+// CreateQuote handles POST /api/v1/user/quotes
+func (h *QuoteHandler) CreateQuote(c *gin.Context) {
+	userID, ok := quoteUserID(c)
+	if !ok {
+		return
+	}
+
+	sessionID := c.GetHeader("X-Session-ID")
+	if sessionID == "" {
+		sessionID = c.GetString("session_id")
+	}
+
+	var req services.CreateQuoteRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	quote, err := h.quoteService.CreateFromCart(c.Request.Context(), sessionID, userID, req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"data":    quote,
+	})
+}
+
+// GetUserQuotes handles GET /api/v1/user/quotes
+func (h *QuoteHandler) GetUserQuotes(c *gin.Context) {
+	userID, ok := quoteUserID(c)
+	if !ok {
+		return
+	}
+
+	quotes, err := h.quoteService.ListQuotes(c.Request.Context(), &userID, c.Query("status"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    quotes,
+	})
+}
+
+// GetUserQuote handles GET /api/v1/user/quotes/:id
+func (h *QuoteHandler) GetUserQuote(c *gin.Context) {
+	userID, ok := quoteUserID(c)
+	if !ok {
+		return
+	}
+	quoteID, ok := quoteParamID(c)
+	if !ok {
+		return
+	}
+
+	quote, err := h.quoteService.GetQuote(c.Request.Context(), quoteID, &userID)
+	if err != nil {
+		c.JSON(quoteErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    quote,
+	})
+}
+
+// GetUserQuotePDF handles GET /api/v1/user/quotes/:id/pdf
+func (h *QuoteHandler) GetUserQuotePDF(c *gin.Context) {
+	userID, ok := quoteUserID(c)
+	if !ok {
+		return
+	}
+	h.renderPDF(c, &userID)
+}
+
+// AcceptQuote handles POST /api/v1/user/quotes/:id/accept
+func (h *QuoteHandler) AcceptQuote(c *gin.Context) {
+	userID, ok := quoteUserID(c)
+	if !ok {
+		return
+	}
+	quoteID, ok := quoteParamID(c)
+	if !ok {
+		return
+	}
+
+	quote, err := h.quoteService.AcceptQuote(c.Request.Context(), quoteID, userID)
+	if err != nil {
+		c.JSON(quoteErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    quote,
+	})
+}
+
+// ConvertQuote handles POST /api/v1/user/quotes/:id/convert
+func (h *QuoteHandler) ConvertQuote(c *gin.Context) {
+	userID, ok := quoteUserID(c)
+	if !ok {
+		return
+	}
+	quoteID, ok := quoteParamID(c)
+	if !ok {
+		return
+	}
+
+	var req services.ConvertQuoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	order, err := h.quoteService.ConvertToOrder(c.Request.Context(), quoteID, userID, req)
+	if err != nil {
+		if !respondOrderViolations(c, err) {
+			c.JSON(quoteErrorStatus(err), gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"order": order})
+}
+
+// GetQuotes handles GET /api/v1/admin/quotes
+func (h *QuoteHandler) GetQuotes(c *gin.Context) {
+	quotes, err := h.quoteService.ListQuotes(c.Request.Context(), nil, c.Query("status"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    quotes,
+	})
+}
+
+// GetQuote handles GET /api/v1/admin/quotes/:id
+func (h *QuoteHandler) GetQuote(c *gin.Context) {
+	quoteID, ok := quoteParamID(c)
+	if !ok {
+		return
+	}
+
+	quote, err := h.quoteService.GetQuote(c.Request.Context(), quoteID, nil)
+	if err != nil {
+		c.JSON(quoteErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    quote,
+	})
+}
+
+// GetQuotePDF handles GET /api/v1/admin/quotes/:id/pdf
+func (h *QuoteHandler) GetQuotePDF(c *gin.Context) {
+	h.renderPDF(c, nil)
+}
+
+// UpdateQuote handles PUT /api/v1/admin/quotes/:id
+func (h *QuoteHandler) UpdateQuote(c *gin.Context) {
+	quoteID, ok := quoteParamID(c)
+	if !ok {
+		return
+	}
+
+	var req services.UpdateQuoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	quote, err := h.quoteService.UpdateQuote(c.Request.Context(), quoteID, req)
+	if err != nil {
+		c.JSON(quoteErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    quote,
+	})
+}
+
+// ApproveQuote handles POST /api/v1/admin/quotes/:id/approve
+func (h *QuoteHandler) ApproveQuote(c *gin.Context) {
+	quoteID, ok := quoteParamID(c)
+	if !ok {
+		return
+	}
+
+	quote, err := h.quoteService.ApproveQuote(c.Request.Context(), quoteID, requestUserID(c))
+	if err != nil {
+		c.JSON(quoteErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    quote,
+	})
+}
+
+// RejectQuote handles POST /api/v1/admin/quotes/:id/reject
+func (h *QuoteHandler) RejectQuote(c *gin.Context) {
+	quoteID, ok := quoteParamID(c)
+	if !ok {
+		return
+	}
+
+	var req services.RejectQuoteRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	quote, err := h.quoteService.RejectQuote(c.Request.Context(), quoteID, req)
+	if err != nil {
+		c.JSON(quoteErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    quote,
+	})
+}
+
+// renderPDF writes a quote as a PDF attachment, limited to the user's own quotes when userID is set
+func (h *QuoteHandler) renderPDF(c *gin.Context, userID *uuid.UUID) {
+	quoteID, ok := quoteParamID(c)
+	if !ok {
+		return
+	}
+
+	quote, err := h.quoteService.GetQuote(c.Request.Context(), quoteID, userID)
+	if err != nil {
+		c.JSON(quoteErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s.pdf", quote.QuoteNumber))
+	c.Data(http.StatusOK, "application/pdf", services.RenderQuotePDF(quote))
+}
+
+// quoteUserID reads the authenticated user, responding with 401 when there is none
+func quoteUserID(c *gin.Context) (uuid.UUID, bool) {
+	userID := requestUserID(c)
+	if userID == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return uuid.Nil, false
+	}
+	return *userID, true
+}
+
+// quoteParamID parses the :id path parameter, responding with 400 when it is invalid
+func quoteParamID(c *gin.Context) (uuid.UUID, bool) {
+	quoteID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid quote ID"})
+		return uuid.Nil, false
+	}
+	return quoteID, true
+}
+
+func quoteErrorStatus(err error) int {
+	if errors.Is(err, services.ErrQuoteNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusBadRequest
+}
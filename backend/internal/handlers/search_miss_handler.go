@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"chat-ecommerce-backend/internal/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SearchMissHandler handles the report of searches and chat queries that found no products
+type SearchMissHandler struct {
+	missService *services.SearchMissService
+}
+
+// NewSearchMissHandler creates a new SearchMissHandler
+func NewSearchMissHandler(missService *services.SearchMissService) *SearchMissHandler {
+	return &SearchMissHandler{
+		missService: missService,
+	}
+}
+
+// GetMissReport handles GET /api/v1/admin/search/misses?from=2024-01-01&to=2024-01-31&source=chat&limit=100
+func (h *SearchMissHandler) GetMissReport(c *gin.Context) {
+	filter, err := searchMissFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	summaries, err := h.missService.MissReport(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    summaries,
+	})
+}
+
+// ExportMissReport handles GET /api/v1/admin/search/misses/export
+func (h *SearchMissHandler) ExportMissReport(c *gin.Context) {
+	filter, err := searchMissFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	csvData, err := h.missService.ExportMissReportCSV(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Disposition", "attachment; filename=search-misses.csv")
+	c.Data(http.StatusOK, "text/csv", csvData)
+}
+
+// searchMissFilter reads the report's dates, source and limit from the query string
+func searchMissFilter(c *gin.Context) (services.SearchMissFilter, error) {
+	from, to, err := dateRangeQuery(c)
+	if err != nil {
+		return services.SearchMissFilter{}, err
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if limit < 1 || limit > 1000 {
+		limit = 100
+	}
+	return services.SearchMissFilter{From: from, To: to, Source: c.Query("source"), Limit: limit}, nil
+}
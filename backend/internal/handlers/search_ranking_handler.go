@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"chat-ecommerce-backend/internal/services"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// SearchRankingHandler handles the search ranking controls: category and
+// brand boosts and products pinned to queries
+type SearchRankingHandler struct {
+	rankingService *services.SearchRankingService
+}
+
+// NewSearchRankingHandler creates a new SearchRankingHandler
+func NewSearchRankingHandler(rankingService *services.SearchRankingService) *SearchRankingHandler {
+	return &SearchRankingHandler{
+		rankingService: rankingService,
+	}
+}
+
+// GetBoosts handles GET /api/v1/admin/search/boosts
+func (h *SearchRankingHandler) GetBoosts(c *gin.Context) {
+	boosts, err := h.rankingService.ListBoosts(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    boosts,
+	})
+}
+
+// SetBoost handles PUT /api/v1/admin/search/boosts and creates or replaces
+// the boost of a category or brand
+func (h *SearchRankingHandler) SetBoost(c *gin.Context) {
+	var req services.SearchBoostRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	boost, err := h.rankingService.SetBoost(c.Request.Context(), req)
+	if err != nil {
+		c.JSON(rankingErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    boost,
+	})
+}
+
+// DeleteBoost handles DELETE /api/v1/admin/search/boosts/:id
+func (h *SearchRankingHandler) DeleteBoost(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid boost ID"})
+		return
+	}
+
+	if err := h.rankingService.DeleteBoost(c.Request.Context(), id); err != nil {
+		c.JSON(rankingErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Boost deleted successfully",
+	})
+}
+
+// GetPins handles GET /api/v1/admin/search/pins?query=
+func (h *SearchRankingHandler) GetPins(c *gin.Context) {
+	pins, err := h.rankingService.ListPins(c.Request.Context(), c.Query("query"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    pins,
+	})
+}
+
+// PinProduct handles POST /api/v1/admin/search/pins
+func (h *SearchRankingHandler) PinProduct(c *gin.Context) {
+	var req services.PinRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	pin, err := h.rankingService.PinProduct(c.Request.Context(), req)
+	if err != nil {
+		c.JSON(rankingErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    pin,
+	})
+}
+
+// UnpinProduct handles DELETE /api/v1/admin/search/pins/:id
+func (h *SearchRankingHandler) UnpinProduct(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid pin ID"})
+		return
+	}
+
+	if err := h.rankingService.UnpinProduct(c.Request.Context(), id); err != nil {
+		c.JSON(rankingErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Product unpinned successfully",
+	})
+}
+
+// rankingErrorStatus maps search ranking errors to HTTP status codes
+func rankingErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrBoostNotFound), errors.Is(err, services.ErrPinNotFound),
+		errors.Is(err, services.ErrCategoryNotFound), errors.Is(err, services.ErrProductNotFound):
+		return http.StatusNotFound
+	default:
+		return http.StatusBadRequest
+	}
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"chat-ecommerce-backend/internal/services"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SegmentHandler handles customer segment management and targeted offers
+type SegmentHandler struct {
+	segmentService *services.SegmentService
+}
+
+// NewSegmentHandler creates a new SegmentHandler
+func NewSegmentHandler(segmentService *services.SegmentService) *SegmentHandler {
+	return &SegmentHandler{
+		segmentService: segmentService,
+	}
+}
+
+// GetSegments handles GET /api/v1/admin/segments
+func (h *SegmentHandler) GetSegments(c *gin.Context) {
+	segments, err := h.segmentService.ListSegments(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    segments,
+	})
+}
+
+// UpdateSegment handles PUT /api/v1/admin/segments/:slug
+func (h *SegmentHandler) UpdateSegment(c *gin.Context) {
+	var req services.SegmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	segment, err := h.segmentService.UpsertSegment(c.Request.Context(), c.Param("slug"), req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    segment,
+	})
+}
+
+// DeleteSegment handles DELETE /api/v1/admin/segments/:slug
+func (h *SegmentHandler) DeleteSegment(c *gin.Context) {
+	if err := h.segmentService.DeleteSegment(c.Request.Context(), c.Param("slug")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Segment deleted successfully",
+	})
+}
+
+// GetSegmentMembers handles GET /api/v1/admin/segments/:slug/members
+func (h *SegmentHandler) GetSegmentMembers(c *gin.Context) {
+	members, err := h.segmentService.GetSegmentMembers(c.Request.Context(), c.Param("slug"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    members,
+	})
+}
+
+// EvaluateSegments handles POST /api/v1/admin/segments/evaluate
+func (h *SegmentHandler) EvaluateSegments(c *gin.Context) {
+	result, err := h.segmentService.EvaluateSegments(c.Request.Context(), time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    result,
+	})
+}
+
+// GetUserOffers handles GET /api/v1/user/offers
+func (h *SegmentHandler) GetUserOffers(c *gin.Context) {
+	userID := requestUserID(c)
+	if userID == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return
+	}
+
+	segments, err := h.segmentService.GetUserSegments(c.Request.Context(), *userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	offers := []gin.H{}
+	for _, segment := range segments {
+		if segment.PromotionMessage == "" {
+			continue
+		}
+		offers = append(offers, gin.H{
+			"segment":   segment.Slug,
+			"promotion": segment.PromotionMessage,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    offers,
+	})
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"chat-ecommerce-backend/pkg/auth"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// accessTokenTTL is how long an access token, and its cookie, lasts
+const accessTokenTTL = 24 * time.Hour
+
+// refreshCookiePath limits the refresh token cookie to the auth endpoints
+const refreshCookiePath = "/api/v1/auth"
+
+// SessionCookieConfig sets the attributes of cookie session mode's cookies
+type SessionCookieConfig struct {
+	Secure bool   // only sent over HTTPS
+	Domain string // empty for the API's own host
+}
+
+// SessionCookieConfigFromEnv reads AUTH_COOKIE_SECURE (default on) and
+// AUTH_COOKIE_DOMAIN
+func SessionCookieConfigFromEnv() SessionCookieConfig {
+	secure, err := strconv.ParseBool(os.Getenv("AUTH_COOKIE_SECURE"))
+	if err != nil {
+		secure = true
+	}
+	return SessionCookieConfig{
+		Secure: secure,
+		Domain: os.Getenv("AUTH_COOKIE_DOMAIN"),
+	}
+}
+
+// wantsCookieSession reports whether the client asked for cookie session mode
+func wantsCookieSession(c *gin.Context) bool {
+	return c.GetHeader(auth.ClientTypeHeader) == auth.ClientTypeWeb
+}
+
+// setSessionCookies stores a sign-in's tokens in httpOnly cookies along
+// with a fresh CSRF token the storefront script can read
+func (config SessionCookieConfig) setSessionCookies(c *gin.Context, accessToken, refreshToken string, refreshExpiresAt time.Time) error {
+	csrfToken, err := auth.GenerateRandomString(32)
+	if err != nil {
+		return err
+	}
+
+	refreshMaxAge := int(time.Until(refreshExpiresAt).Seconds())
+	config.setCookie(c, auth.AccessTokenCookie, accessToken, "/", int(accessTokenTTL.Seconds()), true)
+	config.setCookie(c, auth.RefreshTokenCookie, refreshToken, refreshCookiePath, refreshMaxAge, true)
+	config.setCookie(c, auth.CSRFCookie, csrfToken, "/", refreshMaxAge, false)
+	return nil
+}
+
+// clearSessionCookies removes cookie session mode's cookies
+func (config SessionCookieConfig) clearSessionCookies(c *gin.Context) {
+	config.setCookie(c, auth.AccessTokenCookie, "", "/", -1, true)
+	config.setCookie(c, auth.RefreshTokenCookie, "", refreshCookiePath, -1, true)
+	config.setCookie(c, auth.CSRFCookie, "", "/", -1, false)
+}
+
+func (config SessionCookieConfig) setCookie(c *gin.Context, name, value, path string, maxAge int, httpOnly bool) {
+	http.SetCookie(c.Writer, config.cookie(name, value, path, maxAge, httpOnly))
+}
+
+func (config SessionCookieConfig) cookie(name, value, path string, maxAge int, httpOnly bool) *http.Cookie {
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		Domain:   config.Domain,
+		MaxAge:   maxAge,
+		Secure:   config.Secure,
+		HttpOnly: httpOnly,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
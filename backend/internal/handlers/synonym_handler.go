@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"chat-ecommerce-backend/internal/services"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// SynonymHandler handles the search synonyms dictionary
+type SynonymHandler struct {
+	synonymService *services.SynonymService
+}
+
+// NewSynonymHandler creates a new SynonymHandler
+func NewSynonymHandler(synonymService *services.SynonymService) *SynonymHandler {
+	return &SynonymHandler{
+		synonymService: synonymService,
+	}
+}
+
+// GetSynonyms handles GET /api/v1/admin/search/synonyms
+func (h *SynonymHandler) GetSynonyms(c *gin.Context) {
+	synonyms, err := h.synonymService.ListSynonyms(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    synonyms,
+	})
+}
+
+// CreateSynonym handles POST /api/v1/admin/search/synonyms
+func (h *SynonymHandler) CreateSynonym(c *gin.Context) {
+	var req services.SynonymRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	synonym, err := h.synonymService.CreateSynonym(c.Request.Context(), req)
+	if err != nil {
+		c.JSON(synonymErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"data":    synonym,
+	})
+}
+
+// UpdateSynonym handles PUT /api/v1/admin/search/synonyms/:id
+func (h *SynonymHandler) UpdateSynonym(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid synonym ID"})
+		return
+	}
+
+	var req services.SynonymRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	synonym, err := h.synonymService.UpdateSynonym(c.Request.Context(), id, req)
+	if err != nil {
+		c.JSON(synonymErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    synonym,
+	})
+}
+
+// DeleteSynonym handles DELETE /api/v1/admin/search/synonyms/:id
+func (h *SynonymHandler) DeleteSynonym(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid synonym ID"})
+		return
+	}
+
+	if err := h.synonymService.DeleteSynonym(c.Request.Context(), id); err != nil {
+		c.JSON(synonymErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Synonym deleted successfully",
+	})
+}
+
+// PreviewRewrite handles GET /api/v1/admin/search/rewrite?q= and shows how a
+// query is corrected and expanded before it is searched
+func (h *SynonymHandler) PreviewRewrite(c *gin.Context) {
+	query := strings.TrimSpace(c.Query("q"))
+	if query == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Search query is required"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    h.synonymService.Rewrite(c.Request.Context(), query),
+	})
+}
+
+// synonymErrorStatus maps synonym errors to HTTP status codes
+func synonymErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrSynonymNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrSynonymExists):
+		return http.StatusConflict
+	default:
+		return http.StatusBadRequest
+	}
+}
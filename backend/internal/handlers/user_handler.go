@@ -2,6 +2,8 @@ package handlers
 
 import (
 	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/pkg/auth"
+	"errors"
 	"net/http"
 	"time"
 
@@ -12,15 +14,19 @@ import (
 
 // UserHandler handles user-related HTTP requests
 type UserHandler struct {
-	userService *services.UserService
-	jwtSecret   string
+	userService   *services.UserService
+	refreshTokens *services.RefreshTokenService
+	cookies       SessionCookieConfig
+	jwtSecret     string
 }
 
 // NewUserHandler creates a new UserHandler
-func NewUserHandler(userService *services.UserService, jwtSecret string) *UserHandler {
+func NewUserHandler(userService *services.UserService, refreshTokens *services.RefreshTokenService, jwtSecret string) *UserHandler {
 	return &UserHandler{
-		userService: userService,
-		jwtSecret:   jwtSecret,
+		userService:   userService,
+		refreshTokens: refreshTokens,
+		cookies:       SessionCookieConfigFromEnv(),
+		jwtSecret:     jwtSecret,
 	}
 }
 
@@ -38,17 +44,7 @@ func (h *UserHandler) Register(c *gin.Context) {
 		return
 	}
 
-	// Generate JWT token
-	token, err := h.generateJWTToken(user.ID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate token"})
-		return
-	}
-
-	c.JSON(http.StatusCreated, gin.H{
-		"user":  user,
-		"token": token,
-	})
+	h.signIn(c, http.StatusCreated, user)
 }
 
 // Login handles POST /api/v1/auth/login
@@ -59,34 +55,25 @@ func (h *UserHandler) Login(c *gin.Context) {
 		return
 	}
 
+	req.IPAddress = c.ClientIP()
 	user, err := h.userService.Login(&req)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		c.JSON(loginErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
-	// Generate JWT token
-	token, err := h.generateJWTToken(user.ID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate token"})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"user":  user,
-		"token": token,
-	})
+	h.signIn(c, http.StatusOK, user)
 }
 
 // GetProfile handles GET /api/v1/user/profile
 func (h *UserHandler) GetProfile(c *gin.Context) {
-	userID, exists := c.Get("user_id")
-	if !exists {
+	userID := requestUserID(c)
+	if userID == nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
 		return
 	}
 
-	user, err := h.userService.GetUserByID(userID.(uuid.UUID))
+	user, err := h.userService.GetUserByID(*userID)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
@@ -97,8 +84,8 @@ func (h *UserHandler) GetProfile(c *gin.Context) {
 
 // UpdateProfile handles PUT /api/v1/user/profile
 func (h *UserHandler) UpdateProfile(c *gin.Context) {
-	userID, exists := c.Get("user_id")
-	if !exists {
+	userID := requestUserID(c)
+	if userID == nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
 		return
 	}
@@ -109,7 +96,7 @@ func (h *UserHandler) UpdateProfile(c *gin.Context) {
 		return
 	}
 
-	user, err := h.userService.UpdateProfile(userID.(uuid.UUID), &req)
+	user, err := h.userService.UpdateProfile(*userID, &req)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -120,8 +107,8 @@ func (h *UserHandler) UpdateProfile(c *gin.Context) {
 
 // ChangePassword handles POST /api/v1/user/change-password
 func (h *UserHandler) ChangePassword(c *gin.Context) {
-	userID, exists := c.Get("user_id")
-	if !exists {
+	userID := requestUserID(c)
+	if userID == nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
 		return
 	}
@@ -136,7 +123,7 @@ func (h *UserHandler) ChangePassword(c *gin.Context) {
 		return
 	}
 
-	err := h.userService.ChangePassword(userID.(uuid.UUID), req.CurrentPassword, req.NewPassword)
+	err := h.userService.ChangePassword(*userID, req.CurrentPassword, req.NewPassword)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -147,13 +134,13 @@ func (h *UserHandler) ChangePassword(c *gin.Context) {
 
 // DeleteAccount handles DELETE /api/v1/user/account
 func (h *UserHandler) DeleteAccount(c *gin.Context) {
-	userID, exists := c.Get("user_id")
-	if !exists {
+	userID := requestUserID(c)
+	if userID == nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
 		return
 	}
 
-	err := h.userService.DeleteUser(userID.(uuid.UUID))
+	err := h.userService.DeleteUser(*userID)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -164,13 +151,13 @@ func (h *UserHandler) DeleteAccount(c *gin.Context) {
 
 // VerifyEmail handles POST /api/v1/user/verify-email
 func (h *UserHandler) VerifyEmail(c *gin.Context) {
-	userID, exists := c.Get("user_id")
-	if !exists {
+	userID := requestUserID(c)
+	if userID == nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
 		return
 	}
 
-	err := h.userService.VerifyEmail(userID.(uuid.UUID))
+	err := h.userService.VerifyEmail(*userID)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -179,10 +166,12 @@ func (h *UserHandler) VerifyEmail(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "email verified successfully"})
 }
 
-// RefreshToken handles POST /api/v1/auth/refresh
-func (h *UserHandler) RefreshToken(c *gin.Context) {
+// ResetPassword handles POST /api/v1/auth/reset-password with the token from
+// a password reset link
+func (h *UserHandler) ResetPassword(c *gin.Context) {
 	var req struct {
-		RefreshToken string `json:"refresh_token" binding:"required"`
+		Token       string `json:"token" binding:"required"`
+		NewPassword string `json:"new_password" binding:"required,min=8"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -190,52 +179,178 @@ func (h *UserHandler) RefreshToken(c *gin.Context) {
 		return
 	}
 
-	// Parse and validate refresh token
-	token, err := jwt.Parse(req.RefreshToken, func(token *jwt.Token) (interface{}, error) {
-		return []byte(h.jwtSecret), nil
-	})
-
-	if err != nil || !token.Valid {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid refresh token"})
+	if err := h.userService.ResetPassword(req.Token, req.NewPassword); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	claims, ok := token.Claims.(jwt.MapClaims)
+	c.JSON(http.StatusOK, gin.H{"message": "password reset successfully"})
+}
+
+// RefreshToken handles POST /api/v1/auth/refresh. Refresh tokens are single
+// use: each call returns a new one, and reusing a spent token ends the sign-in
+// on every device. Browser clients in cookie session mode send no body; their
+// refresh token comes from its cookie.
+func (h *UserHandler) RefreshToken(c *gin.Context) {
+	refreshToken, fromCookie, ok := requestRefreshToken(c)
 	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token claims"})
 		return
 	}
 
-	userIDStr, ok := claims["user_id"].(string)
-	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid user ID in token"})
+	userID, refresh, err := h.refreshTokens.Rotate(c.Request.Context(), refreshToken, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrInvalidRefreshToken) || errors.Is(err, services.ErrRefreshTokenReused) {
+			status = http.StatusUnauthorized
+			if fromCookie {
+				h.cookies.clearSessionCookies(c)
+			}
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
 		return
 	}
 
-	userID, err := uuid.Parse(userIDStr)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid user ID format"})
+	// Suspended accounts and those awaiting a password reset can't renew their tokens
+	if err := h.userService.CheckCanSignIn(userID); err != nil {
+		_ = h.refreshTokens.Revoke(c.Request.Context(), refresh.Token)
+		if fromCookie {
+			h.cookies.clearSessionCookies(c)
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 		return
 	}
 
 	// Generate new access token
-	newToken, err := h.generateJWTToken(userID)
+	newToken, err := h.generateJWTToken(userID, refresh.FamilyID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate new token"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"token": newToken})
+	h.respondWithTokens(c, http.StatusOK, gin.H{}, newToken, refresh, fromCookie || wantsCookieSession(c))
+}
+
+// Logout handles POST /api/v1/auth/logout and ends the sign-in the refresh
+// token belongs to, including its access tokens
+func (h *UserHandler) Logout(c *gin.Context) {
+	refreshToken, fromCookie, ok := requestRefreshToken(c)
+	if !ok {
+		return
+	}
+
+	if err := h.refreshTokens.Revoke(c.Request.Context(), refreshToken); err != nil && !errors.Is(err, services.ErrInvalidRefreshToken) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if fromCookie || wantsCookieSession(c) {
+		h.cookies.clearSessionCookies(c)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "logged out successfully"})
+}
+
+// LogoutEverywhere handles POST /api/v1/user/logout-all and ends every
+// sign-in of the user, including the current one
+func (h *UserHandler) LogoutEverywhere(c *gin.Context) {
+	userID := requestUserID(c)
+	if userID == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	sessions, err := h.refreshTokens.RevokeAll(c.Request.Context(), *userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := c.Cookie(auth.AccessTokenCookie); err == nil {
+		h.cookies.clearSessionCookies(c)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":          "logged out of all sessions",
+		"sessions_revoked": sessions,
+	})
+}
+
+// signIn starts a sign-in for the user and responds with its access and
+// refresh tokens
+func (h *UserHandler) signIn(c *gin.Context, status int, user *services.User) {
+	refresh, err := h.refreshTokens.Issue(c.Request.Context(), user.ID, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate token"})
+		return
+	}
+
+	// Generate JWT token
+	token, err := h.generateJWTToken(user.ID, refresh.FamilyID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate token"})
+		return
+	}
+
+	h.respondWithTokens(c, status, gin.H{"user": user}, token, refresh, wantsCookieSession(c))
 }
 
-// generateJWTToken creates a JWT token for the given user ID
-func (h *UserHandler) generateJWTToken(userID uuid.UUID) (string, error) {
+// respondWithTokens adds a sign-in's tokens to the response body, or in
+// cookie session mode sets them as cookies instead
+func (h *UserHandler) respondWithTokens(c *gin.Context, status int, body gin.H, token string, refresh *services.IssuedRefreshToken, cookieMode bool) {
+	body["refresh_expires_at"] = refresh.ExpiresAt
+	if cookieMode {
+		if err := h.cookies.setSessionCookies(c, token, refresh.Token, refresh.ExpiresAt); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate token"})
+			return
+		}
+	} else {
+		body["token"] = token
+		body["refresh_token"] = refresh.Token
+	}
+	c.JSON(status, body)
+}
+
+// requestRefreshToken reads the refresh token from the request body, or from
+// its cookie in cookie session mode
+func requestRefreshToken(c *gin.Context) (token string, fromCookie bool, ok bool) {
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return "", false, false
+		}
+	}
+	if req.RefreshToken != "" {
+		return req.RefreshToken, false, true
+	}
+	if cookie, err := c.Cookie(auth.RefreshTokenCookie); err == nil && cookie != "" {
+		return cookie, true, true
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": "refresh_token is required"})
+	return "", false, false
+}
+
+// generateJWTToken creates a JWT token for the given user ID and sign-in
+func (h *UserHandler) generateJWTToken(userID, sessionID uuid.UUID) (string, error) {
 	claims := jwt.MapClaims{
 		"user_id": userID.String(),
-		"exp":     time.Now().Add(time.Hour * 24).Unix(), // 24 hours
+		"sid":     sessionID.String(),
+		"exp":     time.Now().Add(accessTokenTTL).Unix(),
 		"iat":     time.Now().Unix(),
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString([]byte(h.jwtSecret))
 }
+
+// loginErrorStatus maps sign-in errors to HTTP status codes
+func loginErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrAccountLocked):
+		return http.StatusLocked
+	case errors.Is(err, services.ErrTooManyLoginAttempts):
+		return http.StatusTooManyRequests
+	default:
+		return http.StatusUnauthorized
+	}
+}
@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"chat-ecommerce-backend/internal/services"
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APIUsageMiddleware counts every request per route and consumer. The
+// consumer is the signed in user, set by the auth middleware of the route's
+// group while the request is handled, or the client address otherwise.
+func APIUsageMiddleware(usage *services.APIUsageService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		consumer := "ip:" + c.ClientIP()
+		if userID, exists := c.Get("user_id"); exists {
+			consumer = fmt.Sprintf("user:%v", userID)
+		}
+		usage.Record(c.Request.Method, c.FullPath(), consumer, c.Writer.Status(), time.Since(start), time.Now())
+	}
+}
@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"chat-ecommerce-backend/pkg/auth"
+	"context"
 	"net/http"
 	"strings"
 
@@ -13,9 +14,14 @@ func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
-			c.Abort()
-			return
+			// Browser clients in cookie session mode send the token as a cookie
+			cookie, err := c.Cookie(auth.AccessTokenCookie)
+			if err != nil || cookie == "" {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
+				c.Abort()
+				return
+			}
+			authHeader = "Bearer " + cookie
 		}
 
 		// Check if header starts with "Bearer "
@@ -37,17 +43,26 @@ func AuthMiddleware() gin.HandlerFunc {
 		// Set user ID in context
 		c.Set("user_id", claims.UserID)
 		c.Set("user_email", claims.Email)
+		if claims.SessionID != "" {
+			c.Set("session_id", claims.SessionID)
+		}
 		c.Next()
 	}
 }
 
-// OptionalAuthMiddleware validates JWT tokens but doesn't require them
-func OptionalAuthMiddleware() gin.HandlerFunc {
+// OptionalAuthMiddleware validates JWT tokens but doesn't require them.
+// Requests with a token whose sign-in was revoked go on as anonymous ones;
+// revocations may be nil where sign-ins are never revoked.
+func OptionalAuthMiddleware(revocations TokenRevocationList) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			c.Next()
-			return
+			cookie, err := c.Cookie(auth.AccessTokenCookie)
+			if err != nil || cookie == "" {
+				c.Next()
+				return
+			}
+			authHeader = "Bearer " + cookie
 		}
 
 		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
@@ -61,9 +76,36 @@ func OptionalAuthMiddleware() gin.HandlerFunc {
 			c.Next()
 			return
 		}
+		if claims.SessionID != "" && revocations != nil && revocations.IsRevoked(c.Request.Context(), claims.SessionID) {
+			c.Next()
+			return
+		}
 
 		c.Set("user_id", claims.UserID)
 		c.Set("user_email", claims.Email)
+		if claims.SessionID != "" {
+			c.Set("session_id", claims.SessionID)
+		}
+		c.Next()
+	}
+}
+
+// TokenRevocationList reports whether the sign-in an access token belongs to
+// has been revoked, by logout, token reuse or an admin
+type TokenRevocationList interface {
+	IsRevoked(ctx context.Context, sessionID string) bool
+}
+
+// RevocationMiddleware rejects access tokens whose sign-in was revoked. It
+// runs after AuthMiddleware.
+func RevocationMiddleware(revocations TokenRevocationList) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sessionID := c.GetString("session_id")
+		if sessionID != "" && revocations.IsRevoked(c.Request.Context(), sessionID) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token has been revoked"})
+			c.Abort()
+			return
+		}
 		c.Next()
 	}
 }
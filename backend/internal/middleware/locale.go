@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"chat-ecommerce-backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// LocaleMiddleware works out the customer's store locale and puts it on both
+// the gin context ("locale") and the request context, where services read it.
+// Register it after OptionalAuthMiddleware so signed in customers' preferences
+// are applied.
+func LocaleMiddleware(locales *services.StoreLocaleService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var userID *uuid.UUID
+		if value, exists := c.Get("user_id"); exists {
+			if id, ok := value.(string); ok {
+				if parsed, err := uuid.Parse(id); err == nil {
+					userID = &parsed
+				}
+			}
+		}
+
+		ctx := c.Request.Context()
+		locale := locales.Resolve(ctx, c.ClientIP(), c.Request.Header, userID)
+		c.Set("locale", locale)
+		c.Request = c.Request.WithContext(services.WithStoreLocale(ctx, locale))
+		c.Next()
+	}
+}
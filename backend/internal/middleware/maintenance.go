@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"chat-ecommerce-backend/internal/services"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MaintenanceMiddleware refuses writes with 503 and Retry-After while a
+// maintenance is in progress. Reads keep working, and so do the paths under
+// the exempt prefixes, such as the admin API that ends the maintenance.
+func MaintenanceMiddleware(maintenance *services.MaintenanceService, exempt ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+		for _, prefix := range exempt {
+			if strings.HasPrefix(c.Request.URL.Path, prefix) {
+				c.Next()
+				return
+			}
+		}
+
+		now := time.Now()
+		refused, retryAfter := maintenance.RefusesWrites(c.Request.Context(), now)
+		if !refused {
+			c.Next()
+			return
+		}
+
+		status, _ := maintenance.Status(c.Request.Context(), now)
+		c.Header("Retry-After", strconv.Itoa(int(retryAfter.Seconds())))
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "The store is down for maintenance", "maintenance": status})
+		c.Abort()
+	}
+}
@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"chat-ecommerce-backend/internal/services"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TrustProxies sets the proxies whose X-Forwarded-For the router believes,
+// from a comma separated list such as TRUSTED_PROXIES. Without any, gin
+// trusts every client, so when a network policy is restricted the router
+// trusts none instead and policies go by the connection's address; a client
+// can't get past an allowlist by sending the header itself.
+func TrustProxies(r *gin.Engine, proxies string, policies ...*services.NetworkPolicy) error {
+	var trusted []string
+	for _, proxy := range strings.Split(proxies, ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			trusted = append(trusted, proxy)
+		}
+	}
+	if len(trusted) > 0 {
+		return r.SetTrustedProxies(trusted)
+	}
+	for _, policy := range policies {
+		if policy.Restricted() {
+			return r.SetTrustedProxies(nil)
+		}
+	}
+	return nil
+}
+
+// NetworkPolicyMiddleware refuses requests the policy doesn't allow with 403
+// and records them in the audit log. Register it before authentication so
+// requests from outside the allowed networks never reach the sign-in checks.
+func NetworkPolicyMiddleware(policies *services.NetworkPolicyService, policy *services.NetworkPolicy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !policy.Restricted() {
+			c.Next()
+			return
+		}
+
+		reason, detail := policy.Check(c.Request, c.ClientIP())
+		if reason == "" {
+			c.Next()
+			return
+		}
+
+		policies.RecordBlocked(c.Request.Context(), services.BlockedAttempt{
+			Policy:    policy.Name,
+			Reason:    reason,
+			Detail:    detail,
+			IPAddress: c.ClientIP(),
+			Method:    c.Request.Method,
+			Path:      c.Request.URL.Path,
+			UserAgent: c.Request.UserAgent(),
+		}, time.Now())
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied from this network"})
+		c.Abort()
+	}
+}
@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"chat-ecommerce-backend/internal/services"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimitHeadersMiddleware counts every request per client address and
+// sends the address's standing as X-RateLimit-* headers, so clients can slow
+// down before a throttled endpoint answers 429. Requests over the limit are
+// still served; endpoints with their own throttle replace the headers with
+// their stricter one.
+func RateLimitHeadersMiddleware(throttle *services.RequestThrottle) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if throttle.Limited() {
+			status, _ := throttle.Take(c.ClientIP(), time.Now())
+			status.WriteHeaders(c.Writer.Header())
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,28 @@
+package auth
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AccountUnlockToken is a one-time token, emailed when an account is locked
+// after repeated failed sign-ins, that unlocks it before the lockout ends
+type AccountUnlockToken struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null;index"`
+	Token     string    `gorm:"size:128;uniqueIndex;not null"`
+	ExpiresAt time.Time `gorm:"index;not null"`
+	Used      bool      `gorm:"default:false"`
+	CreatedAt time.Time
+}
+
+// TableName specifies the table name for the AccountUnlockToken model
+func (AccountUnlockToken) TableName() string {
+	return "account_unlock_tokens"
+}
+
+// IsValid checks if the token is valid (not used and not expired)
+func (a *AccountUnlockToken) IsValid() bool {
+	return !a.Used && time.Now().Before(a.ExpiresAt)
+}
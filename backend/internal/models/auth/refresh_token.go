@@ -0,0 +1,34 @@
+package auth
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RefreshToken is a one-time-use refresh token. Each use replaces it with a
+// new token in the same family; a family is one sign-in, and reusing a spent
+// token revokes the whole family.
+type RefreshToken struct {
+	ID         uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	UserID     uuid.UUID  `gorm:"type:uuid;not null;index"`
+	FamilyID   uuid.UUID  `gorm:"type:uuid;not null;index"`
+	Token      string     `gorm:"size:128;uniqueIndex;not null"` // SHA-256 of the token
+	IPAddress  string     `gorm:"size:45"`
+	UserAgent  string     `gorm:"size:255"`
+	ExpiresAt  time.Time  `gorm:"index;not null"`
+	UsedAt     *time.Time // set when the token is exchanged for a new one
+	ReplacedBy *uuid.UUID `gorm:"type:uuid"`
+	RevokedAt  *time.Time `gorm:"index"`
+	CreatedAt  time.Time
+}
+
+// TableName specifies the table name for the RefreshToken model
+func (RefreshToken) TableName() string {
+	return "refresh_tokens"
+}
+
+// IsExpired checks if the token has expired
+func (r *RefreshToken) IsExpired() bool {
+	return time.Now().After(r.ExpiresAt)
+}
@@ -14,24 +14,415 @@ type Product struct {
 	Description string         `gorm:"type:text;not null" json:"description"`
 	Price       float64        `gorm:"type:decimal(10,2);not null;index" json:"price"`
 	CategoryID  uuid.UUID      `gorm:"type:uuid;not null;index" json:"category_id"`
-	SKU         string         `gorm:"size:100;uniqueIndex;not null" json:"sku"`
+	BrandID     *uuid.UUID     `gorm:"type:uuid;index" json:"brand_id"`
+	SKU         string         `gorm:"size:100;uniqueIndex;index:idx_products_sku_lower,unique,expression:LOWER(sku);not null" json:"sku"`
 	Status      string         `gorm:"size:20;default:'active';index" json:"status"`
 	Metadata    datatypes.JSON `gorm:"type:jsonb" json:"metadata"`
 	// Tags        pq.StringArray `gorm:"type:text[]" json:"tags"`
-	SearchVector string    `gorm:"type:tsvector" json:"search_vector"`
-	SearchWeight float64   `gorm:"default:0" json:"search_weight"`
-	Popularity   int       `gorm:"default:0" json:"popularity"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	SearchVector string     `gorm:"type:tsvector" json:"search_vector"`
+	SearchWeight float64    `gorm:"default:0" json:"search_weight"`
+	Popularity   int        `gorm:"default:0" json:"popularity"`
+	PublishAt    *time.Time `gorm:"index" json:"publish_at"`   // product goes live at this time
+	UnpublishAt  *time.Time `gorm:"index" json:"unpublish_at"` // product is taken down at this time
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+
+	// ListPrice is the catalog price when Price has been adjusted for the customer's group
+	ListPrice *float64 `gorm:"-" json:"list_price,omitempty"`
+	// PriceAdjustments explains how Price was reached from ListPrice
+	PriceAdjustments []PriceAdjustment `gorm:"-" json:"price_adjustments,omitempty"`
 
 	// Relationships
 	Category   Category         `gorm:"foreignKey:CategoryID" json:"category"`
+	Brand      *Brand           `gorm:"foreignKey:BrandID" json:"brand,omitempty"`
 	Variants   []ProductVariant `gorm:"foreignKey:ProductID" json:"variants"`
 	Images     []ProductImage   `gorm:"foreignKey:ProductID" json:"images"`
 	Inventory  []Inventory      `gorm:"foreignKey:ProductID" json:"inventory"`
 	OrderItems []OrderItem      `gorm:"foreignKey:ProductID" json:"order_items"`
 }
 
+// PriceHistory records a change to a product's price
+type PriceHistory struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	ProductID uuid.UUID  `gorm:"type:uuid;not null;index" json:"product_id"`
+	OldPrice  float64    `gorm:"type:decimal(10,2);not null" json:"old_price"`
+	NewPrice  float64    `gorm:"type:decimal(10,2);not null" json:"new_price"`
+	Source    string     `gorm:"size:30;not null" json:"source"` // e.g. bulk_price
+	Reason    string     `gorm:"size:255" json:"reason"`
+	ChangedBy *uuid.UUID `gorm:"type:uuid" json:"changed_by"`
+	CreatedAt time.Time  `gorm:"index" json:"created_at"`
+
+	// Relationships
+	Product Product `gorm:"foreignKey:ProductID" json:"-"`
+}
+
+// ProductChangeRequest is an edit to a product awaiting review. Edits by
+// admins who can't publish directly only reach the live catalog once a
+// reviewer approves them.
+type ProductChangeRequest struct {
+	ID               uuid.UUID      `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	ProductID        uuid.UUID      `gorm:"type:uuid;not null;index" json:"product_id"`
+	Kind             string         `gorm:"size:10;not null" json:"kind"`         // update (full replace) or patch
+	Status           string         `gorm:"size:20;not null;index" json:"status"` // draft, pending, approved, rejected
+	Changes          datatypes.JSON `gorm:"type:jsonb;not null" json:"changes"`   // the update or patch payload
+	SubmittedBy      *uuid.UUID     `gorm:"type:uuid;index" json:"submitted_by"`
+	SubmittedByEmail string         `gorm:"size:255" json:"submitted_by_email"`
+	ReviewedBy       *uuid.UUID     `gorm:"type:uuid" json:"reviewed_by"`
+	ReviewComment    string         `gorm:"type:text" json:"review_comment"`
+	ReviewedAt       *time.Time     `json:"reviewed_at"`
+	CreatedAt        time.Time      `json:"created_at"`
+	UpdatedAt        time.Time      `json:"updated_at"`
+
+	// Relationships
+	Product Product `gorm:"foreignKey:ProductID" json:"-"`
+}
+
+// ProductRevision is an immutable snapshot of a product's data, recorded on
+// every change so the product can be rolled back to it
+type ProductRevision struct {
+	ID        uuid.UUID      `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	ProductID uuid.UUID      `gorm:"type:uuid;not null;uniqueIndex:idx_product_revision_version" json:"product_id"`
+	Version   int            `gorm:"not null;uniqueIndex:idx_product_revision_version" json:"version"`
+	Source    string         `gorm:"size:30;not null" json:"source"` // e.g. update, bulk_import, rollback
+	Snapshot  datatypes.JSON `gorm:"type:jsonb;not null" json:"snapshot"`
+	ChangedBy *uuid.UUID     `gorm:"type:uuid" json:"changed_by"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+// SearchSynonym maps a search term to the words it should also match, e.g.
+// "earbuds" to "headphones". Managed by admins.
+type SearchSynonym struct {
+	ID        uuid.UUID      `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Term      string         `gorm:"size:100;uniqueIndex;not null" json:"term"` // lower case, may be several words
+	Synonyms  datatypes.JSON `gorm:"type:jsonb;not null" json:"synonyms"`       // list of lower-case terms
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+}
+
+// SearchMiss is a storefront search or chat query that found no products,
+// kept so merchandisers can see what customers look for but can't find
+type SearchMiss struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Query     string     `gorm:"size:500;not null;index" json:"query"` // lower case
+	Source    string     `gorm:"size:20;not null;index" json:"source"` // search or chat
+	SessionID string     `gorm:"size:100" json:"session_id"`
+	UserID    *uuid.UUID `gorm:"type:uuid" json:"user_id"`
+	CreatedAt time.Time  `gorm:"index" json:"created_at"`
+}
+
+// SearchBoost scales the search and chat ranking of a category's or a
+// brand's products. Category boosts cover subcategories too.
+type SearchBoost struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Kind      string    `gorm:"size:20;not null;uniqueIndex:idx_search_boost_target" json:"kind"`    // category or brand
+	Target    string    `gorm:"size:100;not null;uniqueIndex:idx_search_boost_target" json:"target"` // category ID or lower-case brand
+	Factor    float64   `gorm:"not null" json:"factor"`                                              // above 1 promotes, below 1 demotes
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// PinnedSearchResult puts a product at a fixed position in the results of a search query
+type PinnedSearchResult struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Query     string    `gorm:"size:255;not null;uniqueIndex:idx_pinned_query_product" json:"query"` // lower case
+	ProductID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_pinned_query_product" json:"product_id"`
+	Position  int       `gorm:"not null" json:"position"` // 1 is the first result
+	CreatedAt time.Time `json:"created_at"`
+
+	// Relationships
+	Product Product `gorm:"foreignKey:ProductID" json:"product"`
+}
+
+// ProductQuestion is a customer's question about a product. Answers written
+// or approved by an admin are shown on the product page and given to the chat
+// assistant when the product is discussed.
+type ProductQuestion struct {
+	ID           uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	ProductID    uuid.UUID  `gorm:"type:uuid;not null;index" json:"product_id"`
+	UserID       *uuid.UUID `gorm:"type:uuid;index" json:"user_id,omitempty"`
+	AskerName    string     `gorm:"size:100" json:"asker_name"`
+	Question     string     `gorm:"type:text;not null" json:"question"`
+	Answer       string     `gorm:"type:text" json:"answer"`
+	Status       string     `gorm:"size:20;not null;index" json:"status"` // pending, suggested, approved, rejected
+	AnswerSource string     `gorm:"size:10" json:"answer_source"`         // admin or llm
+	AnsweredBy   *uuid.UUID `gorm:"type:uuid" json:"answered_by,omitempty"`
+	AnsweredAt   *time.Time `json:"answered_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+
+	// Relationships
+	Product Product `gorm:"foreignKey:ProductID" json:"-"`
+}
+
+// LoginAttempt records a sign-in attempt, for locking accounts and throttling
+// IP addresses after repeated failures and for spotting sign-ins from new places
+type LoginAttempt struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Email     string     `gorm:"size:255;not null;index" json:"email"`
+	UserID    *uuid.UUID `gorm:"type:uuid;index" json:"user_id,omitempty"` // nil when no account has the email
+	IPAddress string     `gorm:"size:45;index" json:"ip_address"`
+	Success   bool       `gorm:"not null;default:false" json:"success"`
+	CreatedAt time.Time  `gorm:"index" json:"created_at"`
+}
+
+// SecurityNotification tells a user about security events on their account.
+// Session notifications are shown to the user's signed-in sessions; email
+// notifications are queued as pending for the mailer to send.
+type SecurityNotification struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	UserID    uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	Type      string     `gorm:"size:30;not null" json:"type"`          // account_locked, new_sign_in, account_unlocked
+	Channel   string     `gorm:"size:10;not null;index" json:"channel"` // session or email
+	Recipient string     `gorm:"size:255" json:"-"`
+	Subject   string     `gorm:"size:255" json:"subject"`
+	Message   string     `gorm:"type:text;not null" json:"message"`
+	IPAddress string     `gorm:"size:45" json:"ip_address"`
+	Status    string     `gorm:"size:20;not null;default:'pending'" json:"-"` // email delivery status
+	ReadAt    *time.Time `json:"read_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// ConsentRecord is one change to a user's consent for a purpose. Records
+// are never updated; the latest one per purpose is the user's current choice.
+type ConsentRecord struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null;index:idx_consent_user_purpose" json:"user_id"`
+	Purpose   string    `gorm:"size:30;not null;index:idx_consent_user_purpose" json:"purpose"` // marketing_email, marketing_sms, analytics_cookies
+	Granted   bool      `gorm:"not null" json:"granted"`
+	Source    string    `gorm:"size:30;not null" json:"source"` // where the choice was made, e.g. signup or cookie_banner
+	IPAddress string    `gorm:"size:45" json:"ip_address"`
+	UserAgent string    `gorm:"size:255" json:"user_agent"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
+
+// OutboundMessage is an email or SMS queued for the mailer and SMS gateway.
+// Marketing messages to users without consent are kept as suppressed.
+type OutboundMessage struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null;index" json:"user_id"`
+	Channel   string    `gorm:"size:10;not null" json:"channel"`  // email or sms
+	Category  string    `gorm:"size:20;not null" json:"category"` // marketing or transactional
+	Recipient string    `gorm:"size:255" json:"recipient"`
+	Subject   string    `gorm:"size:255" json:"subject"`
+	Body      string    `gorm:"type:text;not null" json:"body"`
+	Status    string    `gorm:"size:20;not null;index" json:"status"` // pending, sent, failed, suppressed
+	Reason    string    `gorm:"size:100" json:"reason,omitempty"`     // why a message was suppressed
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// DemandForecast is a product's projected demand from the last forecasting
+// run, with the quantity to reorder to cover the supplier lead time
+type DemandForecast struct {
+	ID                uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	ProductID         uuid.UUID `gorm:"type:uuid;not null;uniqueIndex" json:"product_id"`
+	Method            string    `gorm:"size:30;not null" json:"method"`                              // moving_average or exponential_smoothing
+	DailyDemand       float64   `gorm:"type:decimal(12,4);not null" json:"daily_demand"`             // smoothed units sold per day
+	SeasonalFactor    float64   `gorm:"type:decimal(6,3);not null;default:1" json:"seasonal_factor"` // next 30 days last year against the yearly average
+	Forecast30        int       `gorm:"not null" json:"forecast_30"`
+	Forecast60        int       `gorm:"not null" json:"forecast_60"`
+	Forecast90        int       `gorm:"not null" json:"forecast_90"`
+	QuantityAvailable int       `gorm:"not null" json:"quantity_available"`
+	ReorderQuantity   int       `gorm:"not null;index" json:"reorder_quantity"`
+	ComputedAt        time.Time `gorm:"index" json:"computed_at"`
+
+	// Relationships
+	Product Product `gorm:"foreignKey:ProductID" json:"product,omitempty"`
+}
+
+// AdminAssistantAction is the audit record of a staff request to the admin
+// chat assistant and the tool it ran. Tools that change data wait in
+// pending_confirmation until the same admin confirms them.
+type AdminAssistantAction struct {
+	ID          uuid.UUID      `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	StaffID     uuid.UUID      `gorm:"type:uuid;not null;index" json:"staff_id"`
+	StaffEmail  string         `gorm:"size:255" json:"staff_email"`
+	Role        string         `gorm:"size:30" json:"role"`
+	Message     string         `gorm:"type:text;not null" json:"message"`
+	Tool        string         `gorm:"size:50;index" json:"tool"`
+	Arguments   datatypes.JSON `gorm:"type:jsonb" json:"arguments"`
+	Status      string         `gorm:"size:30;not null;index" json:"status"` // answered, executed, pending_confirmation, denied, failed, expired
+	Result      datatypes.JSON `gorm:"type:jsonb" json:"result,omitempty"`
+	Error       string         `gorm:"type:text" json:"error,omitempty"`
+	IPAddress   string         `gorm:"size:45" json:"ip_address"`
+	ConfirmedAt *time.Time     `json:"confirmed_at"`
+	CreatedAt   time.Time      `gorm:"index" json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+}
+
+// BroadcastCampaign is a promotional notice pushed to shoppers' open chat
+// connections at a scheduled time, to everyone or to one segment
+type BroadcastCampaign struct {
+	ID          uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Name        string     `gorm:"size:100;not null" json:"name"`
+	Title       string     `gorm:"size:150;not null" json:"title"`
+	Message     string     `gorm:"type:text;not null" json:"message"`
+	LinkURL     string     `gorm:"size:500" json:"link_url"`
+	SegmentID   *uuid.UUID `gorm:"type:uuid;index" json:"segment_id"` // nil sends to every connected session
+	ScheduledAt time.Time  `gorm:"not null;index" json:"scheduled_at"`
+	Status      string     `gorm:"size:20;not null;default:'scheduled';index" json:"status"` // scheduled, sending, sent, cancelled
+	Targeted    int        `gorm:"not null;default:0" json:"targeted"`
+	Delivered   int        `gorm:"not null;default:0" json:"delivered"`
+	Throttled   int        `gorm:"not null;default:0" json:"throttled"` // skipped for having had a campaign recently
+	Clicked     int        `gorm:"not null;default:0" json:"clicked"`
+	CreatedBy   *uuid.UUID `gorm:"type:uuid" json:"created_by"`
+	SentAt      *time.Time `json:"sent_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+
+	// Relationships
+	Segment *Segment `gorm:"foreignKey:SegmentID" json:"segment,omitempty"`
+}
+
+// CampaignDelivery records a campaign sent to, or withheld from, one session
+type CampaignDelivery struct {
+	ID          uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	CampaignID  uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex:idx_campaign_session" json:"campaign_id"`
+	SessionID   string     `gorm:"size:100;not null;uniqueIndex:idx_campaign_session;index" json:"session_id"`
+	UserID      *uuid.UUID `gorm:"type:uuid" json:"user_id"`
+	Status      string     `gorm:"size:20;not null" json:"status"` // delivered or throttled
+	DeliveredAt time.Time  `gorm:"index" json:"delivered_at"`
+	ClickedAt   *time.Time `json:"clicked_at"`
+}
+
+// StoreHours is one weekday's opening hours for a store ("default" for the
+// main store). Times are "15:04" in the store's time zone.
+type StoreHours struct {
+	ID             uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Store          string    `gorm:"size:50;not null;default:'default';uniqueIndex:idx_store_weekday" json:"store"`
+	Weekday        int       `gorm:"not null;uniqueIndex:idx_store_weekday" json:"weekday"` // 0 is Sunday
+	Opens          string    `gorm:"size:5" json:"opens"`
+	Closes         string    `gorm:"size:5" json:"closes"`
+	ShippingCutoff string    `gorm:"size:5" json:"shipping_cutoff"` // orders placed before it ship the same day
+	Closed         bool      `gorm:"default:false" json:"closed"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// EscalationMessage is a customer's request for a person that arrived while
+// the store was closed, kept for follow-up when it reopens
+type EscalationMessage struct {
+	ID         uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Store      string     `gorm:"size:50;not null;default:'default';index" json:"store"`
+	SessionID  string     `gorm:"size:100;not null;index" json:"session_id"`
+	UserID     *uuid.UUID `gorm:"type:uuid;index" json:"user_id"`
+	Message    string     `gorm:"type:text;not null" json:"message"`
+	Status     string     `gorm:"size:20;not null;default:'pending';index" json:"status"` // pending or resolved
+	FollowUpAt *time.Time `gorm:"index" json:"follow_up_at"`                              // when the store next opens
+	ResolvedBy *uuid.UUID `gorm:"type:uuid" json:"resolved_by"`
+	ResolvedAt *time.Time `json:"resolved_at"`
+	Notes      string     `gorm:"type:text" json:"notes"`
+	CreatedAt  time.Time  `gorm:"index" json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// APIUsageBucket counts one consumer's requests to one route during one
+// minute. Request logs are summed into buckets so usage can be reported
+// without keeping every request.
+type APIUsageBucket struct {
+	ID             uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	WindowStart    time.Time `gorm:"not null;index;uniqueIndex:idx_api_usage_bucket" json:"window_start"`
+	Method         string    `gorm:"size:10;not null;uniqueIndex:idx_api_usage_bucket" json:"method"`
+	Route          string    `gorm:"size:200;not null;index;uniqueIndex:idx_api_usage_bucket" json:"route"`    // route pattern, e.g. /api/v1/products/:id
+	Consumer       string    `gorm:"size:100;not null;index;uniqueIndex:idx_api_usage_bucket" json:"consumer"` // user:<id>, or ip:<address> for anonymous requests
+	Requests       int64     `gorm:"not null;default:0" json:"requests"`
+	ClientErrors   int64     `gorm:"not null;default:0" json:"client_errors"` // 4xx responses
+	ServerErrors   int64     `gorm:"not null;default:0" json:"server_errors"` // 5xx responses
+	TotalLatencyMs float64   `gorm:"not null;default:0" json:"total_latency_ms"`
+	MaxLatencyMs   float64   `gorm:"not null;default:0" json:"max_latency_ms"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// MaintenanceWindow is a planned outage of the API. Until StartsAt clients
+// count down to it; from then on the API serves reads only until an admin
+// ends it.
+type MaintenanceWindow struct {
+	ID          uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Message     string     `gorm:"type:text" json:"message"`
+	StartsAt    time.Time  `gorm:"not null;index" json:"starts_at"`
+	ExpectedEnd *time.Time `json:"expected_end,omitempty"` // sent to refused clients as Retry-After
+	CreatedBy   *uuid.UUID `gorm:"type:uuid" json:"created_by,omitempty"`
+	EndedAt     *time.Time `gorm:"index" json:"ended_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// FlashSale is a drop day: its products' caches are warmed ahead of the
+// start, checkouts of them are admitted through a virtual waiting room and
+// each shopper may only buy a few of them
+type FlashSale struct {
+	ID             uuid.UUID      `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Name           string         `gorm:"size:200;not null" json:"name"`
+	ProductIDs     datatypes.JSON `gorm:"type:jsonb;not null" json:"product_ids"` // list of product IDs on sale
+	StartsAt       time.Time      `gorm:"not null;index" json:"starts_at"`
+	EndsAt         time.Time      `gorm:"not null;index" json:"ends_at"`
+	PerUserLimit   int            `gorm:"not null;default:0" json:"per_user_limit"`   // units of each product a shopper may buy, 0 for no limit
+	AdmitPerMinute int            `gorm:"not null;default:0" json:"admit_per_minute"` // shoppers let through the waiting room a minute, 0 for no waiting room
+	CreatedBy      *uuid.UUID     `gorm:"type:uuid" json:"created_by,omitempty"`
+	EndedAt        *time.Time     `gorm:"index" json:"ended_at,omitempty"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+}
+
+// FlashSaleShopper is a shopper who ordered during a flash sale. Their
+// orders lock the row while checking the sale's purchase limit, so two
+// placed at once are counted one after the other.
+type FlashSaleShopper struct {
+	ID          uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	FlashSaleID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_flash_sale_shopper" json:"flash_sale_id"`
+	Shopper     string    `gorm:"size:150;not null;uniqueIndex:idx_flash_sale_shopper" json:"shopper"` // user:<id> or session:<id>
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// BlockedRequest is the audit record of a request refused by a network
+// policy, such as an admin request from outside the allowed networks
+type BlockedRequest struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Policy    string    `gorm:"size:30;not null;index" json:"policy"` // admin or webhook
+	Reason    string    `gorm:"size:30;not null" json:"reason"`       // denylisted, not_allowlisted or client_certificate
+	Detail    string    `gorm:"size:255" json:"detail,omitempty"`     // why a client certificate was refused
+	IPAddress string    `gorm:"size:45;index" json:"ip_address"`
+	Method    string    `gorm:"size:10" json:"method"`
+	Path      string    `gorm:"size:255" json:"path"`
+	UserAgent string    `gorm:"size:255" json:"user_agent"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
+
+// AsyncJob is a long-running admin operation, such as a bulk import or a
+// campaign send, run in the background. Its result and any file it produced
+// stay with it for download.
+type AsyncJob struct {
+	ID           uuid.UUID      `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Kind         string         `gorm:"size:50;not null;index" json:"kind"`                    // product_import, product_export, bulk_price, campaign_send or chat_purge
+	Status       string         `gorm:"size:20;not null;default:'queued';index" json:"status"` // queued, running, succeeded or failed
+	Progress     int            `gorm:"not null;default:0" json:"progress"`                    // percent done
+	Processed    int            `gorm:"not null;default:0" json:"processed"`
+	Total        int            `gorm:"not null;default:0" json:"total"`
+	Params       datatypes.JSON `gorm:"type:jsonb" json:"params,omitempty"`
+	Result       datatypes.JSON `gorm:"type:jsonb" json:"result,omitempty"`
+	Error        string         `gorm:"type:text" json:"error,omitempty"`
+	Artifact     []byte         `json:"-"`
+	ArtifactName string         `gorm:"size:255" json:"artifact_name,omitempty"`
+	ArtifactType string         `gorm:"size:100" json:"artifact_type,omitempty"`
+	CreatedBy    *uuid.UUID     `gorm:"type:uuid;index" json:"created_by"`
+	StartedAt    *time.Time     `json:"started_at"`
+	FinishedAt   *time.Time     `json:"finished_at"`
+	CreatedAt    time.Time      `gorm:"index" json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+}
+
+// ProductEmbedding is a product's name, category and description embedded
+// for semantic search. The vector column needs PostgreSQL's pgvector
+// extension; ContentHash tells when the product changed and needs
+// embedding again.
+type ProductEmbedding struct {
+	ProductID   uuid.UUID `gorm:"type:uuid;primary_key" json:"product_id"`
+	Model       string    `gorm:"size:100;not null;index" json:"model"`
+	ContentHash string    `gorm:"size:64;not null" json:"content_hash"`
+	Embedding   Vector    `gorm:"type:vector;not null" json:"-"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
 // ProductVariant represents product variations like size, color, material
 type ProductVariant struct {
 	ID            uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
@@ -70,7 +461,10 @@ type Category struct {
 	Slug        string     `gorm:"size:100;uniqueIndex;not null" json:"slug"`
 	SortOrder   int        `gorm:"default:0" json:"sort_order"`
 	IsActive    bool       `gorm:"default:true" json:"is_active"`
-	CreatedAt   time.Time  `json:"created_at"`
+	// MetadataSchema is the JSON schema its products' metadata must match
+	MetadataSchema datatypes.JSON `gorm:"type:jsonb" json:"metadata_schema,omitempty"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
 
 	// Relationships
 	Parent   *Category  `gorm:"foreignKey:ParentID" json:"parent"`
@@ -78,6 +472,26 @@ type Category struct {
 	Products []Product  `gorm:"foreignKey:CategoryID" json:"products"`
 }
 
+// Brand is the manufacturer or label products are sold under
+type Brand struct {
+	ID          uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Name        string    `gorm:"size:100;uniqueIndex;not null" json:"name"`
+	Slug        string    `gorm:"size:100;uniqueIndex;not null" json:"slug"`
+	Description string    `gorm:"type:text" json:"description"`
+	LogoURL     string    `gorm:"size:500" json:"logo_url"`
+	IsActive    bool      `gorm:"default:true" json:"is_active"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// CategorySlugRedirect maps the slug of a merged category to the category that replaced it
+type CategorySlugRedirect struct {
+	ID         uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	FromSlug   string    `gorm:"size:100;uniqueIndex;not null" json:"from_slug"`
+	CategoryID uuid.UUID `gorm:"type:uuid;not null;index" json:"category_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
 // Inventory represents stock levels and warehouse information
 type Inventory struct {
 	ID                uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
@@ -124,6 +538,7 @@ type InventoryReservation struct {
 	QuantityReserved int        `gorm:"not null" json:"quantity_reserved"`
 	ExpiresAt        time.Time  `gorm:"not null;index" json:"expires_at"`
 	Status           string     `gorm:"size:20;default:'active';index" json:"status"`
+	ExpiryNotifiedAt *time.Time `json:"expiry_notified_at,omitempty"`
 	CreatedAt        time.Time  `json:"created_at"`
 
 	// Relationships
@@ -133,22 +548,24 @@ type InventoryReservation struct {
 
 // User represents customer accounts
 type User struct {
-	ID                  uuid.UUID      `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
-	Email               string         `gorm:"size:255;uniqueIndex;not null" json:"email"`
-	PasswordHash        string         `gorm:"size:255;not null" json:"-"`
-	FirstName           string         `gorm:"size:50;not null" json:"first_name"`
-	LastName            string         `gorm:"size:50;not null" json:"last_name"`
-	Phone               string         `gorm:"size:20" json:"phone"`
-	DateOfBirth         *time.Time     `json:"date_of_birth"`
-	Preferences         datatypes.JSON `gorm:"type:jsonb" json:"preferences"`
-	EmailVerified       bool           `gorm:"default:false" json:"email_verified"`
-	Status              string         `gorm:"size:20;default:'active';index" json:"status"`
-	AccountState        string         `gorm:"size:20;default:'active';index" json:"account_state"`
-	FailedLoginAttempts int            `gorm:"default:0;not null" json:"failed_login_attempts"`
-	LockoutUntil        *time.Time     `gorm:"index" json:"lockout_until"`
-	LastLoginAt         *time.Time     `json:"last_login_at"`
-	CreatedAt           time.Time      `json:"created_at"`
-	UpdatedAt           time.Time      `json:"updated_at"`
+	ID                    uuid.UUID      `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Email                 string         `gorm:"size:255;uniqueIndex;not null" json:"email"`
+	PasswordHash          string         `gorm:"size:255;not null" json:"-"`
+	FirstName             string         `gorm:"size:50;not null" json:"first_name"`
+	LastName              string         `gorm:"size:50;not null" json:"last_name"`
+	Phone                 string         `gorm:"type:text;serializer:pii" json:"phone"`         // encrypted at rest
+	DateOfBirth           *time.Time     `gorm:"type:text;serializer:pii" json:"date_of_birth"` // encrypted at rest
+	Preferences           datatypes.JSON `gorm:"type:jsonb" json:"preferences"`
+	EmailVerified         bool           `gorm:"default:false" json:"email_verified"`
+	Status                string         `gorm:"size:20;default:'active';index" json:"status"`
+	AccountState          string         `gorm:"size:20;default:'active';index" json:"account_state"`
+	FailedLoginAttempts   int            `gorm:"default:0;not null" json:"failed_login_attempts"`
+	LockoutUntil          *time.Time     `gorm:"index" json:"lockout_until"`
+	LastLoginAt           *time.Time     `json:"last_login_at"`
+	PasswordResetRequired bool           `gorm:"default:false;not null" json:"password_reset_required"` // set by admins; sign-in fails until a reset link is used
+	CustomerGroupID       *uuid.UUID     `gorm:"type:uuid;index" json:"customer_group_id"`
+	CreatedAt             time.Time      `json:"created_at"`
+	UpdatedAt             time.Time      `json:"updated_at"`
 
 	// Relationships
 	ChatSessions  []ChatSession          `gorm:"foreignKey:UserID" json:"chat_sessions"`
@@ -192,7 +609,11 @@ type ChatSession struct {
 	Context             datatypes.JSON `gorm:"type:jsonb" json:"context"`
 	CartState           datatypes.JSON `gorm:"type:jsonb" json:"cart_state"`
 	Preferences         datatypes.JSON `gorm:"type:jsonb" json:"preferences"`
-	Status              string         `gorm:"size:20;default:'active';index" json:"status"`
+	Locale              string         `gorm:"size:10" json:"locale"`                        // language detected from the shopper's messages, e.g. "es"
+	Status              string         `gorm:"size:20;default:'active';index" json:"status"` // active, needs_agent or with_agent
+	AgentID             *uuid.UUID     `gorm:"type:uuid;index" json:"agent_id,omitempty"`    // the admin who joined the chat
+	HandoffReason       string         `gorm:"size:20" json:"handoff_reason,omitempty"`      // requested or frustrated
+	HandoffAt           *time.Time     `json:"handoff_at,omitempty"`                         // when the shopper was handed to a person
 	LastActivity        time.Time      `gorm:"index" json:"last_activity"`
 	CreatedAt           time.Time      `json:"created_at"`
 	ExpiresAt           time.Time      `gorm:"index" json:"expires_at"`
@@ -208,7 +629,7 @@ type ChatMessage struct {
 	ChatSessionID uuid.UUID      `gorm:"type:uuid;not null;index" json:"chat_session_id"`
 	SessionID     string         `gorm:"size:100;not null;index" json:"session_id"`
 	UserID        *uuid.UUID     `gorm:"type:uuid;index" json:"user_id"`
-	Role          string         `gorm:"size:20;not null" json:"role"` // "user", "assistant", "system"
+	Role          string         `gorm:"size:20;not null" json:"role"` // "user", "assistant", "agent", "system"
 	Content       string         `gorm:"type:text;not null" json:"content"`
 	Metadata      datatypes.JSON `gorm:"type:jsonb" json:"metadata"`
 	CreatedAt     time.Time      `json:"created_at"`
@@ -229,6 +650,8 @@ type ShoppingCart struct {
 	ShippingAmount float64        `gorm:"type:decimal(10,2);default:0" json:"shipping_amount"`
 	TotalAmount    float64        `gorm:"type:decimal(10,2);default:0" json:"total_amount"`
 	Currency       string         `gorm:"size:3;default:'USD'" json:"currency"`
+	GiftWrap       bool           `gorm:"default:false" json:"gift_wrap"`
+	GiftMessage    string         `gorm:"type:text" json:"gift_message"`
 	CreatedAt      time.Time      `json:"created_at"`
 	UpdatedAt      time.Time      `json:"updated_at"`
 
@@ -238,39 +661,55 @@ type ShoppingCart struct {
 
 // Order represents completed purchase transactions
 type Order struct {
-	ID              uuid.UUID      `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
-	OrderNumber     string         `gorm:"size:50;uniqueIndex;not null" json:"order_number"`
-	UserID          uuid.UUID      `gorm:"type:uuid;not null;index" json:"user_id"`
-	SessionID       string         `gorm:"size:100;not null" json:"session_id"`
-	Status          string         `gorm:"size:20;default:'pending';index" json:"status"`
-	Subtotal        float64        `gorm:"type:decimal(10,2);not null" json:"subtotal"`
-	TaxAmount       float64        `gorm:"type:decimal(10,2);not null" json:"tax_amount"`
-	ShippingAmount  float64        `gorm:"type:decimal(10,2);not null" json:"shipping_amount"`
-	TotalAmount     float64        `gorm:"type:decimal(10,2);not null" json:"total_amount"`
-	Currency        string         `gorm:"size:3;not null" json:"currency"`
-	PaymentStatus   string         `gorm:"size:20;default:'pending';index" json:"payment_status"`
-	ShippingAddress datatypes.JSON `gorm:"type:jsonb;not null" json:"shipping_address"`
-	BillingAddress  datatypes.JSON `gorm:"type:jsonb;not null" json:"billing_address"`
-	PaymentIntentID string         `gorm:"size:100" json:"payment_intent_id"`
-	CreatedAt       time.Time      `json:"created_at"`
-	UpdatedAt       time.Time      `json:"updated_at"`
+	ID               uuid.UUID      `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	OrderNumber      string         `gorm:"size:50;uniqueIndex;not null" json:"order_number"`
+	UserID           uuid.UUID      `gorm:"type:uuid;not null;index" json:"user_id"`
+	SessionID        string         `gorm:"size:100;not null" json:"session_id"`
+	Status           string         `gorm:"size:20;default:'pending';index" json:"status"`
+	Subtotal         float64        `gorm:"type:decimal(10,2);not null" json:"subtotal"`
+	TaxAmount        float64        `gorm:"type:decimal(10,2);not null" json:"tax_amount"`
+	TaxRate          *float64       `gorm:"type:decimal(6,4)" json:"tax_rate,omitempty"` // unset on orders placed before per-country rates, which were taxed at 8%
+	TaxIncluded      bool           `gorm:"default:false" json:"tax_included"`           // the subtotal already includes TaxAmount
+	ShippingAmount   float64        `gorm:"type:decimal(10,2);not null" json:"shipping_amount"`
+	TotalAmount      float64        `gorm:"type:decimal(10,2);not null" json:"total_amount"`
+	Currency         string         `gorm:"size:3;not null" json:"currency"`
+	PaymentStatus    string         `gorm:"size:20;default:'pending';index" json:"payment_status"`
+	ShippingAddress  datatypes.JSON `gorm:"type:text;not null;serializer:pii" json:"shipping_address"` // encrypted at rest
+	BillingAddress   datatypes.JSON `gorm:"type:text;not null;serializer:pii" json:"billing_address"`  // encrypted at rest
+	PaymentMethod    string         `gorm:"size:30" json:"payment_method"`
+	PaymentIntentID  string         `gorm:"size:100" json:"payment_intent_id"`
+	PaymentProvider  string         `gorm:"size:20" json:"payment_provider"`
+	PaymentReference string         `gorm:"size:100" json:"payment_reference,omitempty"`
+	PaymentDueAt     *time.Time     `gorm:"index" json:"payment_due_at,omitempty"` // offline payments must arrive by then
+	GiftWrap         bool           `gorm:"default:false" json:"gift_wrap"`
+	GiftWrapAmount   float64        `gorm:"type:decimal(10,2);default:0" json:"gift_wrap_amount"`
+	GiftMessage      string         `gorm:"type:text" json:"gift_message,omitempty"`        // printed on the packing slip, which then shows no prices
+	DeliveryDate     *time.Time     `gorm:"type:date;index" json:"delivery_date,omitempty"` // the customer's preferred delivery day
+	DeliveryCarrier  string         `gorm:"size:50" json:"delivery_carrier,omitempty"`
+	ShipBy           *time.Time     `gorm:"type:date;index" json:"ship_by,omitempty"` // last warehouse ship day that still arrives on the delivery date
+	SubmissionID     *uuid.UUID     `gorm:"type:uuid;uniqueIndex" json:"-"`           // the order pipeline submission that placed it, which places it once
+	CreatedAt        time.Time      `json:"created_at"`
+	UpdatedAt        time.Time      `json:"updated_at"`
 
 	// Relationships
-	User  User        `gorm:"foreignKey:UserID" json:"user"`
-	Items []OrderItem `gorm:"foreignKey:OrderID" json:"items"`
+	User         User          `gorm:"foreignKey:UserID" json:"user"`
+	Items        []OrderItem   `gorm:"foreignKey:OrderID" json:"items"`
+	Fulfillments []Fulfillment `gorm:"foreignKey:OrderID" json:"fulfillments"`
 }
 
 // OrderItem represents individual items within an order
 type OrderItem struct {
-	ID              uuid.UUID      `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
-	OrderID         uuid.UUID      `gorm:"type:uuid;not null;index" json:"order_id"`
-	ProductID       uuid.UUID      `gorm:"type:uuid;not null;index" json:"product_id"`
-	VariantID       *uuid.UUID     `gorm:"type:uuid;index" json:"variant_id"`
-	Quantity        int            `gorm:"not null" json:"quantity"`
-	UnitPrice       float64        `gorm:"type:decimal(10,2);not null" json:"unit_price"`
-	TotalPrice      float64        `gorm:"type:decimal(10,2);not null" json:"total_price"`
-	ProductSnapshot datatypes.JSON `gorm:"type:jsonb" json:"product_snapshot"`
-	CreatedAt       time.Time      `json:"created_at"`
+	ID               uuid.UUID      `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	OrderID          uuid.UUID      `gorm:"type:uuid;not null;index" json:"order_id"`
+	FulfillmentID    *uuid.UUID     `gorm:"type:uuid;index" json:"fulfillment_id"`
+	ProductID        uuid.UUID      `gorm:"type:uuid;not null;index" json:"product_id"`
+	VariantID        *uuid.UUID     `gorm:"type:uuid;index" json:"variant_id"`
+	Quantity         int            `gorm:"not null" json:"quantity"`
+	UnitPrice        float64        `gorm:"type:decimal(10,2);not null" json:"unit_price"`
+	TotalPrice       float64        `gorm:"type:decimal(10,2);not null" json:"total_price"`
+	ProductSnapshot  datatypes.JSON `gorm:"type:jsonb" json:"product_snapshot"`
+	PriceAdjustments datatypes.JSON `gorm:"type:jsonb" json:"price_adjustments,omitempty"` // the group price and pricing rules UnitPrice was reached with
+	CreatedAt        time.Time      `json:"created_at"`
 
 	// Relationships
 	Order   Order           `gorm:"foreignKey:OrderID" json:"order"`
@@ -278,6 +717,416 @@ type OrderItem struct {
 	Variant *ProductVariant `gorm:"foreignKey:VariantID" json:"variant"`
 }
 
+// PaymentRetry tracks the dunning of an order whose payment failed: automatic
+// retries on a backoff schedule until the payment succeeds or the order is
+// cancelled after too many failures
+type PaymentRetry struct {
+	ID              uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	OrderID         uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex" json:"order_id"`
+	PaymentIntentID string     `gorm:"size:100" json:"payment_intent_id"`
+	Status          string     `gorm:"size:20;not null;index" json:"status"` // retrying, recovered, cancelled
+	Attempts        int        `gorm:"not null;default:0" json:"attempts"`
+	NextRetryAt     *time.Time `gorm:"index" json:"next_retry_at"`
+	LastError       string     `gorm:"type:text" json:"last_error"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+
+	// Relationships
+	Order Order `gorm:"foreignKey:OrderID" json:"-"`
+}
+
+// OrderExport tracks pushing a paid order to the ERP or fulfillment system:
+// failed pushes are retried on a backoff schedule until they go through or
+// run out of attempts, and admins can export an order again
+type OrderExport struct {
+	ID            uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	OrderID       uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex" json:"order_id"`
+	Status        string     `gorm:"size:20;not null;index" json:"status"` // pending, exported, failed
+	Transport     string     `gorm:"size:20" json:"transport"`             // how the last attempt was sent: http, sftp or directory
+	Format        string     `gorm:"size:10" json:"format"`                // json or csv
+	Attempts      int        `gorm:"not null;default:0" json:"attempts"`
+	NextAttemptAt *time.Time `gorm:"index" json:"next_attempt_at"`
+	LastError     string     `gorm:"type:text" json:"last_error"`
+	Reference     string     `gorm:"size:255" json:"reference"` // the ERP's ID for the order, or the file it was written to
+	ExportedAt    *time.Time `json:"exported_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+
+	// Relationships
+	Order Order `gorm:"foreignKey:OrderID" json:"-"`
+}
+
+// OrderSubmission is a checkout accepted by the order pipeline. Workers
+// create its order, reserving the stock, start the payment and send the
+// confirmation, while the shopper polls it or follows it over WebSocket.
+type OrderSubmission struct {
+	ID              uuid.UUID      `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	UserID          uuid.UUID      `gorm:"type:uuid;not null;index;uniqueIndex:idx_order_submissions_idempotency_key" json:"user_id"`
+	IdempotencyKey  *string        `gorm:"size:255;uniqueIndex:idx_order_submissions_idempotency_key" json:"-"` // the shopper's Idempotency-Key, so a checkout sent twice is accepted once
+	SessionID       string         `gorm:"size:255;index" json:"session_id"`
+	Request         datatypes.JSON `gorm:"type:text;not null;serializer:pii" json:"-"` // the order request, encrypted at rest
+	Status          string         `gorm:"size:20;not null;index" json:"status"`       // accepted, processing, completed or failed
+	Stage           string         `gorm:"size:30" json:"stage,omitempty"`             // the step being worked on, or that failed
+	FlashSaleID     *uuid.UUID     `gorm:"type:uuid" json:"-"`                         // the flash sale whose waiting room let the checkout through when it was accepted
+	DeliverySlot    datatypes.JSON `gorm:"type:jsonb" json:"-"`                        // the delivery slot the checkout was accepted with
+	OrderID         *uuid.UUID     `gorm:"type:uuid" json:"order_id,omitempty"`
+	PaymentIntentID string         `gorm:"size:255" json:"payment_intent_id,omitempty"`
+	PaymentProvider string         `gorm:"size:20" json:"payment_provider,omitempty"`
+	ClientSecret    string         `gorm:"type:text;serializer:pii" json:"client_secret,omitempty"` // encrypted at rest
+	Attempts        int            `gorm:"not null;default:0" json:"attempts"`
+	NextAttemptAt   *time.Time     `gorm:"index" json:"next_attempt_at,omitempty"`
+	LockedUntil     *time.Time     `json:"-"` // a worker is on it until then
+	Error           string         `gorm:"type:text" json:"error,omitempty"`
+	Violations      datatypes.JSON `gorm:"type:jsonb" json:"violations,omitempty"` // the order rules it broke
+	ConfirmedAt     *time.Time     `json:"-"`                                      // the confirmation went out, recorded before sending so retries don't send it again
+	FinishedAt      *time.Time     `json:"finished_at,omitempty"`
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+}
+
+// PaymentTransaction is a ledger entry for money moving through a payment
+// provider: a charge when an order is paid, or a refund. Fees are estimated
+// from the provider's published rates.
+type PaymentTransaction struct {
+	ID              uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	OrderID         uuid.UUID `gorm:"type:uuid;not null;index" json:"order_id"`
+	Provider        string    `gorm:"size:20;not null;index" json:"provider"`
+	PaymentIntentID string    `gorm:"size:100;index" json:"payment_intent_id"`
+	Type            string    `gorm:"size:20;not null" json:"type"` // charge, refund
+	Amount          float64   `gorm:"type:decimal(10,2);not null" json:"amount"`
+	Fee             float64   `gorm:"type:decimal(10,2);not null;default:0" json:"fee"`
+	Currency        string    `gorm:"size:3;not null" json:"currency"`
+	OccurredAt      time.Time `gorm:"not null;index" json:"occurred_at"`
+	CreatedAt       time.Time `json:"created_at"`
+
+	// Relationships
+	Order Order `gorm:"foreignKey:OrderID" json:"-"`
+}
+
+// PayoutReconciliation is the result of matching a provider payout report
+// uploaded by an admin against the payment ledger
+type PayoutReconciliation struct {
+	ID              uuid.UUID      `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Provider        string         `gorm:"size:20;not null;index" json:"provider"`
+	FileName        string         `gorm:"size:255" json:"file_name"`
+	PeriodStart     *time.Time     `json:"period_start"`
+	PeriodEnd       *time.Time     `json:"period_end"`
+	Rows            int            `gorm:"not null;default:0" json:"rows"`
+	Matched         int            `gorm:"not null;default:0" json:"matched"`
+	Mismatched      int            `gorm:"not null;default:0" json:"mismatched"`
+	MissingInLedger int            `gorm:"not null;default:0" json:"missing_in_ledger"`
+	MissingInReport int            `gorm:"not null;default:0" json:"missing_in_report"`
+	ReportNet       float64        `gorm:"type:decimal(12,2);not null;default:0" json:"report_net"`
+	LedgerNet       float64        `gorm:"type:decimal(12,2);not null;default:0" json:"ledger_net"`
+	Discrepancies   datatypes.JSON `gorm:"type:jsonb" json:"discrepancies"`
+	CreatedBy       *uuid.UUID     `gorm:"type:uuid" json:"created_by"`
+	CreatedAt       time.Time      `json:"created_at"`
+}
+
+// AccountingExport is a journal file of the payment ledger's charges, refunds
+// and fees over a period, in a format an accounting package imports
+type AccountingExport struct {
+	ID          uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Format      string     `gorm:"size:20;not null;index" json:"format"` // quickbooks, xero
+	Source      string     `gorm:"size:20;not null" json:"source"`       // scheduled, manual
+	PeriodStart time.Time  `gorm:"not null;index" json:"period_start"`
+	PeriodEnd   time.Time  `gorm:"not null" json:"period_end"` // exclusive
+	Orders      int        `gorm:"not null;default:0" json:"orders"`
+	Refunds     int        `gorm:"not null;default:0" json:"refunds"`
+	Journals    int        `gorm:"not null;default:0" json:"journals"`
+	Debits      float64    `gorm:"type:decimal(12,2);not null;default:0" json:"debits"` // equal to the credits
+	FileName    string     `gorm:"size:255" json:"file_name"`
+	Content     string     `gorm:"type:text" json:"-"`
+	CreatedBy   *uuid.UUID `gorm:"type:uuid" json:"created_by"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// Fulfillment is a shipment of some of an order's items. Orders with items
+// that aren't all in stock are split into one fulfillment for what can ship
+// now and a backordered one for the rest.
+type Fulfillment struct {
+	ID             uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	OrderID        uuid.UUID  `gorm:"type:uuid;not null;index" json:"order_id"`
+	Sequence       int        `gorm:"not null" json:"sequence"`
+	Status         string     `gorm:"size:20;default:'pending';index" json:"status"` // pending, backordered, shipped, delivered, cancelled
+	Carrier        string     `gorm:"size:50" json:"carrier"`
+	TrackingNumber string     `gorm:"size:100" json:"tracking_number"`
+	ShippedAt      *time.Time `json:"shipped_at"`
+	DeliveredAt    *time.Time `json:"delivered_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+
+	// Relationships
+	Items []OrderItem `gorm:"foreignKey:FulfillmentID" json:"items"`
+}
+
+// ChatAnalytics records how one assistant turn was answered
+type ChatAnalytics struct {
+	ID               uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	SessionID        string     `gorm:"size:100;index" json:"session_id"`
+	UserID           *uuid.UUID `gorm:"type:uuid;index" json:"user_id"`
+	Intent           string     `gorm:"size:50;index" json:"intent"`         // the model router's intent
+	MessageIntent    string     `gorm:"size:30;index" json:"message_intent"` // browse, add_to_cart, support, smalltalk or checkout
+	ModelTier        string     `gorm:"size:20;index" json:"model_tier"`     // "economy", "premium" or "fallback"
+	Model            string     `gorm:"size:100" json:"model"`
+	Variant          string     `gorm:"size:50" json:"variant"`
+	PromptTokens     int        `json:"prompt_tokens"`
+	CompletionTokens int        `json:"completion_tokens"`
+	LatencyMs        int        `json:"latency_ms"`
+	CreatedAt        time.Time  `gorm:"index" json:"created_at"`
+}
+
+// ChatEvent is a step a chat session took towards an order: a message, a
+// product suggested or clicked, a product added to the cart from chat, or an
+// order placed after chatting
+type ChatEvent struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	SessionID string     `gorm:"size:100;not null;index" json:"session_id"`
+	UserID    *uuid.UUID `gorm:"type:uuid;index" json:"user_id"`
+	Type      string     `gorm:"size:30;not null;index" json:"type"` // message, suggestion_shown, suggestion_clicked, add_to_cart or order
+	ProductID *uuid.UUID `gorm:"type:uuid;index" json:"product_id"`
+	OrderID   *uuid.UUID `gorm:"type:uuid;index" json:"order_id"`
+	Quantity  int        `json:"quantity"`
+	Amount    float64    `gorm:"type:decimal(10,2)" json:"amount"` // the order total for orders
+	CreatedAt time.Time  `gorm:"index" json:"created_at"`
+}
+
+// StorefrontEvent is a clickstream event the storefront reported: a page
+// view, or a product suggestion shown or clicked. Events are stitched to the
+// chat session of the same shopper when it's known.
+type StorefrontEvent struct {
+	ID            uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	SessionID     string     `gorm:"size:100;not null;index" json:"session_id"` // the storefront session
+	ChatSessionID string     `gorm:"size:100;index" json:"chat_session_id,omitempty"`
+	UserID        *uuid.UUID `gorm:"type:uuid;index" json:"user_id,omitempty"`
+	Type          string     `gorm:"size:30;not null;index" json:"type"` // page_view, suggestion_impression or suggestion_click
+	ProductID     *uuid.UUID `gorm:"type:uuid;index" json:"product_id,omitempty"`
+	Path          string     `gorm:"size:500" json:"path,omitempty"`
+	Placement     string     `gorm:"size:50" json:"placement,omitempty"` // where a suggestion was shown, e.g. chat or product_page
+	Position      int        `json:"position,omitempty"`
+	Variant       string     `gorm:"size:50;index" json:"variant,omitempty"` // the chat experiment variant of the stitched session
+	OccurredAt    time.Time  `gorm:"index" json:"occurred_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// SessionLink stitches a storefront session to the shopper's chat session
+type SessionLink struct {
+	ID            uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	SessionID     string     `gorm:"size:100;not null;uniqueIndex" json:"session_id"` // the storefront session
+	ChatSessionID string     `gorm:"size:100;not null;index" json:"chat_session_id"`
+	UserID        *uuid.UUID `gorm:"type:uuid;index" json:"user_id,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// SocialProofSettings are a store's toggles for the hints shown on products:
+// how many shoppers are viewing one, how often it sold lately and how few are
+// left ("default" for the main store). Counts under the minimums aren't shown,
+// so a hint never points at a single shopper.
+type SocialProofSettings struct {
+	ID                  uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Store               string     `gorm:"size:50;not null;uniqueIndex" json:"store"`
+	ShowViewers         bool       `gorm:"not null" json:"show_viewers"`
+	ShowPurchases       bool       `gorm:"not null" json:"show_purchases"`
+	ShowLowStock        bool       `gorm:"not null" json:"show_low_stock"`
+	MinViewers          int        `gorm:"not null" json:"min_viewers"`
+	MinPurchases        int        `gorm:"not null" json:"min_purchases"`
+	PurchaseWindowHours int        `gorm:"not null" json:"purchase_window_hours"`
+	LowStockThreshold   int        `gorm:"not null" json:"low_stock_threshold"` // units left at or under which the hint shows
+	UpdatedBy           *uuid.UUID `gorm:"type:uuid" json:"updated_by,omitempty"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+}
+
+// ChatTokenUsage is the language model tokens a shopper's chat used in a day,
+// counted against the daily token budget
+type ChatTokenUsage struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Shopper   string    `gorm:"size:120;not null;uniqueIndex:idx_chat_token_usage" json:"shopper"`  // user:<id>, or session:<id> for anonymous shoppers
+	Day       string    `gorm:"size:10;not null;index;uniqueIndex:idx_chat_token_usage" json:"day"` // UTC date, e.g. 2024-05-01
+	Messages  int       `gorm:"not null;default:0" json:"messages"`
+	Tokens    int       `gorm:"not null;default:0" json:"tokens"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// StoreSettings holds merchant-configurable assistant settings for the store
+// ("default" variant) or for an experiment variant
+type StoreSettings struct {
+	ID             uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Variant        string    `gorm:"size:50;uniqueIndex;not null" json:"variant"`
+	LLMModel       string    `gorm:"size:100" json:"llm_model"`
+	LLMTemperature *float32  `json:"llm_temperature"`
+	LLMMaxTokens   *int      `json:"llm_max_tokens"`
+	TrafficPercent int       `gorm:"default:0" json:"traffic_percent"` // share of chat sessions assigned to an experiment variant
+	IsActive       bool      `gorm:"default:true" json:"is_active"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// PromptTemplate is a store owner's persona and instructions for the chat
+// assistant. The active template shapes the system prompt; with none active
+// the built-in one is used.
+type PromptTemplate struct {
+	ID                 uuid.UUID      `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Name               string         `gorm:"size:100;uniqueIndex;not null" json:"name"`
+	Persona            string         `gorm:"type:text" json:"persona"`             // who the assistant is, replacing the opening of the prompt
+	Tone               string         `gorm:"type:text" json:"tone"`                // how it speaks
+	BannedTopics       datatypes.JSON `gorm:"type:jsonb" json:"banned_topics"`      // list of topics it declines to discuss
+	ActionInstructions string         `gorm:"type:text" json:"action_instructions"` // when and how to use its tools
+	IsActive           bool           `gorm:"not null;default:false;index" json:"is_active"`
+	CreatedBy          *uuid.UUID     `gorm:"type:uuid" json:"created_by,omitempty"`
+	CreatedAt          time.Time      `json:"created_at"`
+	UpdatedAt          time.Time      `json:"updated_at"`
+}
+
+// Segment groups customers by rules over their order history so the assistant
+// can greet them and target promotions differently
+type Segment struct {
+	ID               uuid.UUID      `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Slug             string         `gorm:"size:50;uniqueIndex;not null" json:"slug"`
+	Name             string         `gorm:"size:100;not null" json:"name"`
+	Description      string         `gorm:"type:text" json:"description"`
+	Rules            datatypes.JSON `gorm:"type:jsonb" json:"rules"`
+	Greeting         string         `gorm:"type:text" json:"greeting"`          // shown to members instead of the default greeting
+	PromotionMessage string         `gorm:"type:text" json:"promotion_message"` // offer the assistant may mention to members
+	Priority         int            `gorm:"default:0;index" json:"priority"`    // higher wins when a customer is in several segments
+	IsActive         bool           `gorm:"default:true" json:"is_active"`
+	MemberCount      int            `gorm:"default:0" json:"member_count"`
+	LastEvaluatedAt  *time.Time     `json:"last_evaluated_at"`
+	CreatedAt        time.Time      `json:"created_at"`
+	UpdatedAt        time.Time      `json:"updated_at"`
+}
+
+// SegmentMembership records that a user matched a segment at the last evaluation
+type SegmentMembership struct {
+	ID          uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	SegmentID   uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_segment_user" json:"segment_id"`
+	UserID      uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_segment_user;index" json:"user_id"`
+	EvaluatedAt time.Time `json:"evaluated_at"`
+}
+
+// CustomerGroup prices the catalog for a group of customers such as retail,
+// wholesale or VIP. Products in the group's price list use that price; all
+// other products are adjusted by AdjustmentPercent.
+type CustomerGroup struct {
+	ID                uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Slug              string    `gorm:"size:50;uniqueIndex;not null" json:"slug"`
+	Name              string    `gorm:"size:100;not null" json:"name"`
+	Description       string    `gorm:"type:text" json:"description"`
+	AdjustmentPercent float64   `gorm:"type:decimal(5,2);default:0" json:"adjustment_percent"` // -15 is 15% off list price
+	IsDefault         bool      `gorm:"default:false" json:"is_default"`                       // applies to guests and customers without a group
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+// GroupPrice is a customer group's fixed price for a product
+type GroupPrice struct {
+	ID              uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	CustomerGroupID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_group_product" json:"customer_group_id"`
+	ProductID       uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_group_product;index" json:"product_id"`
+	Price           float64   `gorm:"type:decimal(10,2);not null" json:"price"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// PricingRule adjusts the price shoppers see when they see it. Which fields
+// apply depends on Type: time_of_day rules discount between StartTime and
+// EndTime in the store's timezone, stock_clearance rules mark down products
+// with more than MinDaysOfStock days of stock at forecast demand, and member
+// rules discount for signed-in customers, or only those in CustomerGroupID.
+// Rules with a ProductID or CategoryID only apply to that product or category
+// and its subcategories.
+type PricingRule struct {
+	ID              uuid.UUID      `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Name            string         `gorm:"size:100;not null" json:"name"`
+	Type            string         `gorm:"size:30;not null;index" json:"type"`                 // time_of_day, stock_clearance or member
+	DiscountPercent float64        `gorm:"type:decimal(5,2);not null" json:"discount_percent"` // 20 is 20% off
+	Priority        int            `gorm:"not null;default:0" json:"priority"`                 // lower priorities apply first
+	Exclusive       bool           `gorm:"not null;default:false" json:"exclusive"`            // no rule applies after this one
+	ProductID       *uuid.UUID     `gorm:"type:uuid;index" json:"product_id,omitempty"`
+	CategoryID      *uuid.UUID     `gorm:"type:uuid;index" json:"category_id,omitempty"`
+	StartTime       string         `gorm:"size:5" json:"start_time,omitempty"` // "15:04"; windows past midnight wrap
+	EndTime         string         `gorm:"size:5" json:"end_time,omitempty"`
+	Weekdays        datatypes.JSON `gorm:"type:jsonb" json:"weekdays,omitempty"` // 0 is Sunday; empty is every day
+	MinDaysOfStock  int            `gorm:"not null;default:0" json:"min_days_of_stock,omitempty"`
+	CustomerGroupID *uuid.UUID     `gorm:"type:uuid;index" json:"customer_group_id,omitempty"`
+	StartsAt        *time.Time     `json:"starts_at,omitempty"`
+	EndsAt          *time.Time     `json:"ends_at,omitempty"`
+	IsActive        bool           `gorm:"not null;default:false;index" json:"is_active"`
+	CreatedBy       *uuid.UUID     `gorm:"type:uuid" json:"created_by,omitempty"`
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+}
+
+// PriceAdjustment is one step from a product's catalog price to what a
+// shopper pays: their customer group's price or a pricing rule
+type PriceAdjustment struct {
+	Source      string     `json:"source"`       // customer_group or pricing_rule
+	ID          *uuid.UUID `json:"id,omitempty"` // the customer group or pricing rule
+	Name        string     `json:"name"`
+	Type        string     `json:"type,omitempty"`    // the pricing rule's type
+	Percent     float64    `json:"percent,omitempty"` // the rule's discount
+	PriceBefore float64    `json:"price_before"`
+	PriceAfter  float64    `json:"price_after"`
+}
+
+// OrderRule is a configurable check every order must pass before it is created.
+// Which fields apply depends on Type; rules with a ProductID only apply to that product.
+type OrderRule struct {
+	ID        uuid.UUID      `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Type      string         `gorm:"size:50;not null;index" json:"type"` // min_order_total, restricted_shipping_countries, quantity_multiple
+	ProductID *uuid.UUID     `gorm:"type:uuid;index" json:"product_id"`
+	MinTotal  float64        `gorm:"type:decimal(10,2);default:0" json:"min_total"`
+	Multiple  int            `gorm:"default:0" json:"multiple"`
+	Countries datatypes.JSON `gorm:"type:jsonb" json:"countries"` // ISO country codes the product cannot ship to
+	Message   string         `gorm:"type:text" json:"message"`    // shown instead of the default violation message
+	IsActive  bool           `gorm:"default:true" json:"is_active"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+}
+
+// Quote is a formal price offer for a B2B cart. Customers request a quote
+// from their cart, an admin negotiates prices and approves it, and the
+// customer accepts it and converts it into an order while it is valid.
+type Quote struct {
+	ID              uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	QuoteNumber     string     `gorm:"size:50;uniqueIndex;not null" json:"quote_number"`
+	UserID          uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	SessionID       string     `gorm:"size:100" json:"session_id"`
+	Status          string     `gorm:"size:20;default:'requested';index" json:"status"` // requested, approved, rejected, accepted, converted, expired
+	Subtotal        float64    `gorm:"type:decimal(10,2);not null" json:"subtotal"`
+	TaxAmount       float64    `gorm:"type:decimal(10,2);not null" json:"tax_amount"`
+	ShippingAmount  float64    `gorm:"type:decimal(10,2);not null" json:"shipping_amount"`
+	TotalAmount     float64    `gorm:"type:decimal(10,2);not null" json:"total_amount"`
+	Currency        string     `gorm:"size:3;not null" json:"currency"`
+	CustomerNotes   string     `gorm:"type:text" json:"customer_notes"`
+	AdminNotes      string     `gorm:"type:text" json:"admin_notes"`
+	ValidUntil      *time.Time `json:"valid_until"`
+	ApprovedBy      *uuid.UUID `gorm:"type:uuid" json:"approved_by"`
+	ApprovedAt      *time.Time `json:"approved_at"`
+	RejectionReason string     `gorm:"type:text" json:"rejection_reason"`
+	OrderID         *uuid.UUID `gorm:"type:uuid;index" json:"order_id"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+
+	// Relationships
+	Items []QuoteItem `gorm:"foreignKey:QuoteID" json:"items"`
+}
+
+// QuoteItem is a quoted line with its list price and negotiated price
+type QuoteItem struct {
+	ID          uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	QuoteID     uuid.UUID  `gorm:"type:uuid;not null;index" json:"quote_id"`
+	ProductID   uuid.UUID  `gorm:"type:uuid;not null" json:"product_id"`
+	VariantID   *uuid.UUID `gorm:"type:uuid" json:"variant_id"`
+	ProductName string     `gorm:"size:255;not null" json:"product_name"`
+	SKU         string     `gorm:"size:100" json:"sku"`
+	Quantity    int        `gorm:"not null" json:"quantity"`
+	ListPrice   float64    `gorm:"type:decimal(10,2);not null" json:"list_price"`
+	UnitPrice   float64    `gorm:"type:decimal(10,2);not null" json:"unit_price"` // negotiated price
+	TotalPrice  float64    `gorm:"type:decimal(10,2);not null" json:"total_price"`
+}
+
 // TableName methods for custom table names
 func (Product) TableName() string {
 	return "products"
@@ -295,6 +1144,10 @@ func (Category) TableName() string {
 	return "categories"
 }
 
+func (CategorySlugRedirect) TableName() string {
+	return "category_slug_redirects"
+}
+
 func (Inventory) TableName() string {
 	return "inventory"
 }
@@ -330,3 +1183,139 @@ func (Order) TableName() string {
 func (OrderItem) TableName() string {
 	return "order_items"
 }
+
+func (StoreSettings) TableName() string {
+	return "store_settings"
+}
+
+func (ChatAnalytics) TableName() string {
+	return "chat_analytics"
+}
+
+func (Segment) TableName() string {
+	return "segments"
+}
+
+func (SegmentMembership) TableName() string {
+	return "segment_memberships"
+}
+
+func (Quote) TableName() string {
+	return "quotes"
+}
+
+func (QuoteItem) TableName() string {
+	return "quote_items"
+}
+
+func (CustomerGroup) TableName() string {
+	return "customer_groups"
+}
+
+func (GroupPrice) TableName() string {
+	return "group_prices"
+}
+
+func (OrderRule) TableName() string {
+	return "order_rules"
+}
+
+func (Fulfillment) TableName() string {
+	return "fulfillments"
+}
+
+func (PaymentRetry) TableName() string {
+	return "payment_retries"
+}
+
+func (PaymentTransaction) TableName() string {
+	return "payment_transactions"
+}
+
+func (PayoutReconciliation) TableName() string {
+	return "payout_reconciliations"
+}
+
+func (PriceHistory) TableName() string {
+	return "price_history"
+}
+
+func (ProductChangeRequest) TableName() string {
+	return "product_change_requests"
+}
+
+func (ProductRevision) TableName() string {
+	return "product_revisions"
+}
+
+func (SearchSynonym) TableName() string {
+	return "search_synonyms"
+}
+
+func (SearchMiss) TableName() string {
+	return "search_misses"
+}
+
+func (SearchBoost) TableName() string {
+	return "search_boosts"
+}
+
+func (PinnedSearchResult) TableName() string {
+	return "pinned_search_results"
+}
+
+func (Brand) TableName() string {
+	return "brands"
+}
+
+func (ProductQuestion) TableName() string {
+	return "product_questions"
+}
+
+func (LoginAttempt) TableName() string {
+	return "login_attempts"
+}
+
+func (SecurityNotification) TableName() string {
+	return "security_notifications"
+}
+
+func (ConsentRecord) TableName() string {
+	return "consent_records"
+}
+
+func (OutboundMessage) TableName() string {
+	return "outbound_messages"
+}
+
+func (DemandForecast) TableName() string {
+	return "demand_forecasts"
+}
+
+func (AdminAssistantAction) TableName() string {
+	return "admin_assistant_actions"
+}
+
+func (BroadcastCampaign) TableName() string {
+	return "broadcast_campaigns"
+}
+
+func (CampaignDelivery) TableName() string {
+	return "campaign_deliveries"
+}
+
+func (StoreHours) TableName() string {
+	return "store_hours"
+}
+
+func (EscalationMessage) TableName() string {
+	return "escalation_messages"
+}
+
+func (AsyncJob) TableName() string {
+	return "async_jobs"
+}
+
+func (ProductEmbedding) TableName() string {
+	return "product_embeddings"
+}
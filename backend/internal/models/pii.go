@@ -0,0 +1,117 @@
+package models
+
+import (
+	"chat-ecommerce-backend/pkg/encryption"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"gorm.io/gorm/schema"
+)
+
+// piiKeyring encrypts the columns tagged serializer:pii. Without one they
+// are stored in plain text.
+var (
+	piiKeyringMu sync.RWMutex
+	piiKeyring   *encryption.Keyring
+)
+
+func init() {
+	schema.RegisterSerializer("pii", PIISerializer{})
+}
+
+// SetPIIKeyring sets the keyring personal data columns are encrypted with
+func SetPIIKeyring(keyring *encryption.Keyring) {
+	piiKeyringMu.Lock()
+	defer piiKeyringMu.Unlock()
+	piiKeyring = keyring
+}
+
+// PIIKeyring returns the keyring set with SetPIIKeyring, or nil
+func PIIKeyring() *encryption.Keyring {
+	piiKeyringMu.RLock()
+	defer piiKeyringMu.RUnlock()
+	return piiKeyring
+}
+
+// PIISerializer encrypts a column with AES-GCM, bound to the column name.
+// Strings are encrypted as they are and anything else as JSON. Values
+// written before encryption was turned on are still read as plain text.
+type PIISerializer struct{}
+
+// Scan implements serializer interface
+func (PIISerializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue interface{}) error {
+	var stored string
+	switch v := dbValue.(type) {
+	case nil:
+		field.ReflectValueOf(ctx, dst).Set(reflect.Zero(field.FieldType))
+		return nil
+	case []byte:
+		stored = string(v)
+	case string:
+		stored = v
+	default:
+		// Legacy plain columns, such as timestamps, come back typed
+		return field.Set(ctx, dst, v)
+	}
+
+	plaintext := []byte(stored)
+	if encryption.IsEncrypted(stored) {
+		keyring := PIIKeyring()
+		if keyring == nil {
+			return errors.New("PII_ENCRYPTION_KEYS is required to read encrypted columns")
+		}
+		var err error
+		if plaintext, err = keyring.Decrypt(stored, []byte(field.DBName)); err != nil {
+			return fmt.Errorf("failed to decrypt %s: %w", field.DBName, err)
+		}
+	}
+
+	if field.FieldType.Kind() == reflect.String {
+		return field.Set(ctx, dst, string(plaintext))
+	}
+	value := reflect.New(field.FieldType)
+	if len(plaintext) > 0 {
+		if err := json.Unmarshal(plaintext, value.Interface()); err != nil {
+			// Legacy plain text that isn't JSON, such as a date
+			return field.Set(ctx, dst, string(plaintext))
+		}
+	}
+	field.ReflectValueOf(ctx, dst).Set(value.Elem())
+	return nil
+}
+
+// Value implements serializer interface
+func (PIISerializer) Value(ctx context.Context, field *schema.Field, dst reflect.Value, fieldValue interface{}) (interface{}, error) {
+	var plaintext []byte
+	if s, ok := fieldValue.(string); ok {
+		if s == "" {
+			return "", nil
+		}
+		plaintext = []byte(s)
+	} else {
+		if rv := reflect.ValueOf(fieldValue); !rv.IsValid() || (rv.Kind() == reflect.Ptr && rv.IsNil()) {
+			return nil, nil
+		}
+		data, err := json.Marshal(fieldValue)
+		if err != nil {
+			return nil, err
+		}
+		if string(data) == "null" {
+			if field.NotNull {
+				return "", nil
+			}
+			return nil, nil
+		}
+		plaintext = data
+	}
+
+	keyring := PIIKeyring()
+	if keyring == nil {
+		return string(plaintext), nil
+	}
+	return keyring.Encrypt(plaintext, []byte(field.DBName))
+}
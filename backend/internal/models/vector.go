@@ -0,0 +1,71 @@
+package models
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Vector is an embedding stored in pgvector's text format, e.g. [0.1,0.2].
+// Other databases keep the same text.
+type Vector []float32
+
+// String formats the vector the way pgvector reads it
+func (v Vector) String() string {
+	var b strings.Builder
+	b.WriteByte('[')
+	for i, x := range v {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(strconv.FormatFloat(float64(x), 'g', -1, 32))
+	}
+	b.WriteByte(']')
+	return b.String()
+}
+
+// Value implements driver.Valuer
+func (v Vector) Value() (driver.Value, error) {
+	if v == nil {
+		return nil, nil
+	}
+	return v.String(), nil
+}
+
+// Scan implements sql.Scanner
+func (v *Vector) Scan(value interface{}) error {
+	var text string
+	switch value := value.(type) {
+	case nil:
+		*v = nil
+		return nil
+	case string:
+		text = value
+	case []byte:
+		text = string(value)
+	default:
+		return fmt.Errorf("cannot scan %T into a vector", value)
+	}
+
+	text = strings.TrimSpace(text)
+	if len(text) < 2 || text[0] != '[' || text[len(text)-1] != ']' {
+		return fmt.Errorf("invalid vector %q", text)
+	}
+	text = text[1 : len(text)-1]
+	if text == "" {
+		*v = Vector{}
+		return nil
+	}
+	parts := strings.Split(text, ",")
+	vector := make(Vector, len(parts))
+	for i, part := range parts {
+		x, err := strconv.ParseFloat(strings.TrimSpace(part), 32)
+		if err != nil {
+			return fmt.Errorf("invalid vector value %q: %v", part, err)
+		}
+		vector[i] = float32(x)
+	}
+	*v = vector
+	return nil
+}
@@ -0,0 +1,426 @@
+package services
+
+import (
+	"bytes"
+	"chat-ecommerce-backend/internal/models"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Accounting export formats
+const (
+	AccountingFormatQuickBooks = "quickbooks"
+	AccountingFormatXero       = "xero"
+)
+
+// Accounting export sources
+const (
+	AccountingExportScheduled = "scheduled"
+	AccountingExportManual    = "manual"
+)
+
+// xeroTaxRate is the tax rate given to every Xero journal line: tax is posted
+// to its own account instead of being worked out by Xero
+const xeroTaxRate = "Tax Exempt"
+
+var (
+	// ErrInvalidAccountingExport is returned for exports that can't be made
+	ErrInvalidAccountingExport = errors.New("invalid accounting export")
+	// ErrAccountingExportNotFound is returned for unknown exports
+	ErrAccountingExportNotFound = errors.New("accounting export not found")
+)
+
+// AccountingAccounts maps the ledger's money to the accounting package's
+// chart of accounts, by code for Xero or by name for QuickBooks
+type AccountingAccounts struct {
+	Sales    string
+	Tax      string // sales tax payable
+	Shipping string
+	GiftWrap string
+	Refunds  string // sales returns and allowances
+	Fees     string // payment processing fees
+	Clearing string // where payments land before they are paid out
+	// ProviderClearing overrides Clearing for a payment provider's money
+	ProviderClearing map[string]string
+}
+
+// ClearingFor returns the clearing account for a payment provider
+func (a AccountingAccounts) ClearingFor(provider string) string {
+	if account, ok := a.ProviderClearing[provider]; ok {
+		return account
+	}
+	return a.Clearing
+}
+
+// AccountingAccountsFromEnv reads the account mapping from ACCOUNTING_ACCOUNTS,
+// e.g. "sales=4000,tax=2200,clearing.stripe=1210". Accounts that aren't
+// listed keep their defaults.
+func AccountingAccountsFromEnv() AccountingAccounts {
+	accounts := AccountingAccounts{
+		Sales:            "4000",
+		Tax:              "2200",
+		Shipping:         "4100",
+		GiftWrap:         "4200",
+		Refunds:          "4900",
+		Fees:             "6100",
+		Clearing:         "1200",
+		ProviderClearing: map[string]string{},
+	}
+
+	for _, entry := range strings.Split(os.Getenv("ACCOUNTING_ACCOUNTS"), ",") {
+		key, account, ok := strings.Cut(entry, "=")
+		key, account = strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(account)
+		if !ok || account == "" {
+			continue
+		}
+		switch key {
+		case "sales":
+			accounts.Sales = account
+		case "tax":
+			accounts.Tax = account
+		case "shipping":
+			accounts.Shipping = account
+		case "gift_wrap":
+			accounts.GiftWrap = account
+		case "refunds":
+			accounts.Refunds = account
+		case "fees":
+			accounts.Fees = account
+		case "clearing":
+			accounts.Clearing = account
+		default:
+			if provider, ok := strings.CutPrefix(key, "clearing."); ok {
+				accounts.ProviderClearing[provider] = account
+				continue
+			}
+			log.Printf("Invalid ACCOUNTING_ACCOUNTS entry %q ignored", entry)
+		}
+	}
+	return accounts
+}
+
+// AccountingExportConfig controls the daily accounting export
+type AccountingExportConfig struct {
+	Format   string // quickbooks or xero; empty turns the daily export off
+	Hour     int    // the hour (UTC) the previous day is exported at
+	Accounts AccountingAccounts
+}
+
+// AccountingExportConfigFromEnv reads ACCOUNTING_EXPORT_FORMAT,
+// ACCOUNTING_EXPORT_HOUR (3) and ACCOUNTING_ACCOUNTS
+func AccountingExportConfigFromEnv() AccountingExportConfig {
+	hour := envInt("ACCOUNTING_EXPORT_HOUR", 3)
+	if hour < 0 || hour > 23 {
+		hour = 3
+	}
+	return AccountingExportConfig{
+		Format:   strings.ToLower(os.Getenv("ACCOUNTING_EXPORT_FORMAT")),
+		Hour:     hour,
+		Accounts: AccountingAccountsFromEnv(),
+	}
+}
+
+// AccountingJournalLine debits or credits one account
+type AccountingJournalLine struct {
+	Account     string  `json:"account"`
+	Debit       float64 `json:"debit"`
+	Credit      float64 `json:"credit"`
+	Description string  `json:"description"`
+}
+
+// AccountingJournal is a balanced journal entry for one ledger transaction
+type AccountingJournal struct {
+	Type     string                  `json:"type"` // charge or refund, as in the ledger
+	Number   string                  `json:"number"`
+	Date     time.Time               `json:"date"`
+	Currency string                  `json:"currency"`
+	Memo     string                  `json:"memo"`
+	Lines    []AccountingJournalLine `json:"lines"`
+}
+
+// AccountingExportService turns the payment ledger into journal entries for
+// QuickBooks or Xero, keeping every export it makes
+type AccountingExportService struct {
+	db     *gorm.DB
+	config AccountingExportConfig
+}
+
+// NewAccountingExportService creates a new AccountingExportService
+func NewAccountingExportService(db *gorm.DB, config AccountingExportConfig) *AccountingExportService {
+	return &AccountingExportService{
+		db:     db,
+		config: config,
+	}
+}
+
+// Journals returns the journal entries for the ledger's transactions in the
+// period. To is exclusive. A charge credits the order's sales, tax, shipping
+// and gift wrap and debits the provider's clearing account, with the
+// provider's fee moved from clearing to fees. A refund debits refunds and
+// the share of the order's tax it gives back.
+func (s *AccountingExportService) Journals(ctx context.Context, from, to time.Time) ([]AccountingJournal, error) {
+	var transactions []models.PaymentTransaction
+	if err := s.db.WithContext(ctx).
+		Preload("Order").
+		Where("occurred_at >= ? AND occurred_at < ?", from, to).
+		Order("occurred_at ASC").
+		Find(&transactions).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch payment transactions: %v", err)
+	}
+
+	accounts := s.config.Accounts
+	journals := make([]AccountingJournal, 0, len(transactions))
+	for _, transaction := range transactions {
+		order := transaction.Order
+		clearing := accounts.ClearingFor(transaction.Provider)
+		journal := AccountingJournal{
+			Type:     transaction.Type,
+			Number:   order.OrderNumber,
+			Date:     transaction.OccurredAt.UTC(),
+			Currency: transaction.Currency,
+		}
+
+		switch transaction.Type {
+		case TransactionCharge:
+			journal.Memo = "Order " + order.OrderNumber
+			sales := roundCents(transaction.Amount - order.TaxAmount - order.ShippingAmount - order.GiftWrapAmount)
+			journal.Lines = appendJournalLines(journal.Lines,
+				AccountingJournalLine{Account: clearing, Debit: transaction.Amount, Description: "Payment via " + transaction.Provider},
+				AccountingJournalLine{Account: accounts.Sales, Credit: sales, Description: "Sales"},
+				AccountingJournalLine{Account: accounts.Tax, Credit: order.TaxAmount, Description: "Sales tax" + taxRateLabel(order.TaxRate)},
+				AccountingJournalLine{Account: accounts.Shipping, Credit: order.ShippingAmount, Description: "Shipping"},
+				AccountingJournalLine{Account: accounts.GiftWrap, Credit: order.GiftWrapAmount, Description: "Gift wrap"},
+				AccountingJournalLine{Account: accounts.Fees, Debit: transaction.Fee, Description: "Payment processing fee"},
+				AccountingJournalLine{Account: clearing, Credit: transaction.Fee, Description: "Payment processing fee"},
+			)
+		case TransactionRefund:
+			// Numbered by the ledger entry, so refunds keep their number across exports
+			journal.Number = order.OrderNumber + "-R" + transaction.ID.String()[:8]
+			journal.Memo = "Refund of order " + order.OrderNumber
+			var tax float64
+			if order.TotalAmount > 0 {
+				tax = roundCents(transaction.Amount * order.TaxAmount / order.TotalAmount)
+			}
+			journal.Lines = appendJournalLines(journal.Lines,
+				AccountingJournalLine{Account: accounts.Refunds, Debit: roundCents(transaction.Amount - tax), Description: "Refund"},
+				AccountingJournalLine{Account: accounts.Tax, Debit: tax, Description: "Sales tax refunded" + taxRateLabel(order.TaxRate)},
+				AccountingJournalLine{Account: clearing, Credit: transaction.Amount, Description: "Refund via " + transaction.Provider},
+			)
+		default:
+			continue
+		}
+		journals = append(journals, journal)
+	}
+	return journals, nil
+}
+
+// Export writes the period's journals in the format and keeps the file in
+// the export history. To is exclusive.
+func (s *AccountingExportService) Export(ctx context.Context, format string, from, to time.Time, source string, createdBy *uuid.UUID) (*models.AccountingExport, error) {
+	format = strings.ToLower(format)
+	if format != AccountingFormatQuickBooks && format != AccountingFormatXero {
+		return nil, fmt.Errorf("%w: format must be %s or %s", ErrInvalidAccountingExport, AccountingFormatQuickBooks, AccountingFormatXero)
+	}
+	if !to.After(from) {
+		return nil, fmt.Errorf("%w: the period is empty", ErrInvalidAccountingExport)
+	}
+
+	journals, err := s.Journals(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	export := &models.AccountingExport{
+		ID:          uuid.New(),
+		Format:      format,
+		Source:      source,
+		PeriodStart: from,
+		PeriodEnd:   to,
+		Journals:    len(journals),
+		CreatedBy:   createdBy,
+		CreatedAt:   time.Now(),
+	}
+	for _, journal := range journals {
+		if journal.Type == TransactionRefund {
+			export.Refunds++
+		} else {
+			export.Orders++
+		}
+		for _, line := range journal.Lines {
+			export.Debits += line.Debit
+		}
+	}
+	export.Debits = roundCents(export.Debits)
+
+	var content []byte
+	if format == AccountingFormatXero {
+		content, err = xeroJournalsCSV(journals)
+	} else {
+		content, err = quickBooksJournalsCSV(journals)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to write accounting export: %v", err)
+	}
+	export.Content = string(content)
+	export.FileName = fmt.Sprintf("%s-journals-%s-to-%s.csv", format, from.UTC().Format("2006-01-02"), to.UTC().AddDate(0, 0, -1).Format("2006-01-02"))
+
+	if err := s.db.WithContext(ctx).Create(export).Error; err != nil {
+		return nil, fmt.Errorf("failed to save accounting export: %v", err)
+	}
+	return export, nil
+}
+
+// ListExports returns the export history, newest first, without the files
+func (s *AccountingExportService) ListExports(ctx context.Context) ([]models.AccountingExport, error) {
+	exports := []models.AccountingExport{}
+	if err := s.db.WithContext(ctx).Omit("content").Order("created_at DESC").Find(&exports).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch accounting exports: %v", err)
+	}
+	return exports, nil
+}
+
+// GetExport returns an export with its file
+func (s *AccountingExportService) GetExport(ctx context.Context, id uuid.UUID) (*models.AccountingExport, error) {
+	var export models.AccountingExport
+	if err := s.db.WithContext(ctx).Where("id = ?", id).First(&export).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAccountingExportNotFound
+		}
+		return nil, fmt.Errorf("failed to fetch accounting export: %v", err)
+	}
+	return &export, nil
+}
+
+// RunDailyExport exports the UTC day before now in the configured format,
+// unless it has already been exported on schedule. It returns nil when there
+// was nothing to do.
+func (s *AccountingExportService) RunDailyExport(ctx context.Context, now time.Time) (*models.AccountingExport, error) {
+	if s.config.Format == "" {
+		return nil, nil
+	}
+	to := now.UTC().Truncate(24 * time.Hour)
+	from := to.AddDate(0, 0, -1)
+
+	var count int64
+	if err := s.db.WithContext(ctx).Model(&models.AccountingExport{}).
+		Where("format = ? AND source = ? AND period_start = ?", s.config.Format, AccountingExportScheduled, from).
+		Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("failed to check accounting exports: %v", err)
+	}
+	if count > 0 {
+		return nil, nil
+	}
+	return s.Export(ctx, s.config.Format, from, to, AccountingExportScheduled, nil)
+}
+
+// ScheduleDailyExports runs RunDailyExport every day at the configured hour
+// (UTC) until ctx is done
+func (s *AccountingExportService) ScheduleDailyExports(ctx context.Context) {
+	if s.config.Format == "" {
+		return
+	}
+	go func() {
+		for {
+			timer := time.NewTimer(time.Until(nextDailyRun(time.Now().UTC(), s.config.Hour)))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case now := <-timer.C:
+				export, err := s.RunDailyExport(ctx, now)
+				if err != nil {
+					log.Printf("Failed to export accounting journals: %v", err)
+					continue
+				}
+				if export != nil {
+					log.Printf("Exported %d accounting journals to %s", export.Journals, export.FileName)
+				}
+			}
+		}
+	}()
+}
+
+// appendJournalLines appends the lines that move money, skipping zero amounts
+func appendJournalLines(lines []AccountingJournalLine, candidates ...AccountingJournalLine) []AccountingJournalLine {
+	for _, line := range candidates {
+		line.Debit, line.Credit = roundCents(line.Debit), roundCents(line.Credit)
+		if line.Debit == 0 && line.Credit == 0 {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// taxRateLabel describes an order's tax rate, for orders that recorded one
+func taxRateLabel(rate *float64) string {
+	if rate == nil {
+		return ""
+	}
+	return " (" + strconv.FormatFloat(roundTo(*rate*100, 2), 'f', -1, 64) + "%)"
+}
+
+// quickBooksJournalsCSV writes journals in QuickBooks Online's journal entry
+// import layout: one row per line, debits and credits in their own columns
+func quickBooksJournalsCSV(journals []AccountingJournal) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"Journal No", "Journal Date", "Currency Code", "Account", "Debits", "Credits", "Description", "Memo"})
+	for _, journal := range journals {
+		for _, line := range journal.Lines {
+			w.Write([]string{
+				journal.Number,
+				journal.Date.Format("01/02/2006"),
+				journal.Currency,
+				line.Account,
+				journalAmount(line.Debit),
+				journalAmount(line.Credit),
+				line.Description,
+				journal.Memo,
+			})
+		}
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// xeroJournalsCSV writes journals in Xero's manual journal import layout: one
+// row per line, debits positive and credits negative
+func xeroJournalsCSV(journals []AccountingJournal) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"*Narration", "*Date", "Description", "*AccountCode", "*TaxRate", "*Amount"})
+	for _, journal := range journals {
+		narration := journal.Number + " " + journal.Memo
+		for _, line := range journal.Lines {
+			w.Write([]string{
+				narration,
+				journal.Date.Format("2006-01-02"),
+				line.Description,
+				line.Account,
+				xeroTaxRate,
+				strconv.FormatFloat(roundCents(line.Debit-line.Credit), 'f', 2, 64),
+			})
+		}
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// journalAmount formats a debit or credit, leaving zero blank
+func journalAmount(amount float64) string {
+	if amount == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(amount, 'f', 2, 64)
+}
@@ -0,0 +1,692 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/pkg/listquery"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sashabaranov/go-openai"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// Admin assistant permissions
+const (
+	AdminPermAnalyticsRead  = "analytics:read"
+	AdminPermInventoryRead  = "inventory:read"
+	AdminPermInventoryWrite = "inventory:write"
+)
+
+// Admin assistant roles. Staff with no role can't use the assistant.
+const (
+	AdminAssistantRoleNone             = "none"
+	AdminAssistantRoleViewer           = "viewer"
+	AdminAssistantRoleInventoryManager = "inventory_manager"
+)
+
+// adminAssistantRoles are the permissions of each role
+var adminAssistantRoles = map[string][]string{
+	AdminAssistantRoleViewer:           {AdminPermAnalyticsRead, AdminPermInventoryRead},
+	AdminAssistantRoleInventoryManager: {AdminPermAnalyticsRead, AdminPermInventoryRead, AdminPermInventoryWrite},
+}
+
+// Admin assistant action statuses
+const (
+	AssistantActionAnswered = "answered"
+	AssistantActionExecuted = "executed"
+	AssistantActionPending  = "pending_confirmation"
+	AssistantActionDenied   = "denied"
+	AssistantActionFailed   = "failed"
+	AssistantActionExpired  = "expired"
+)
+
+// assistantConfirmationTTL is how long a proposed change can be confirmed
+const assistantConfirmationTTL = 10 * time.Minute
+
+// Admin assistant errors
+var (
+	ErrAssistantNotAllowed          = errors.New("your account doesn't have access to the admin assistant")
+	ErrAssistantToolDenied          = errors.New("your role doesn't allow this action")
+	ErrAssistantActionNotFound      = errors.New("assistant action not found")
+	ErrAssistantActionNotPending    = errors.New("this action isn't waiting for confirmation")
+	ErrAssistantConfirmationExpired = errors.New("the confirmation window has passed; ask the assistant again")
+)
+
+// AdminAssistantConfig assigns assistant roles to staff
+type AdminAssistantConfig struct {
+	Roles       map[string]string // by lower-case email
+	DefaultRole string            // role of admins who aren't listed
+}
+
+// AdminAssistantConfigFromEnv reads ADMIN_ASSISTANT_ROLES, e.g.
+// "ops@example.com=inventory_manager,intern@example.com=none", and
+// ADMIN_ASSISTANT_DEFAULT_ROLE (default viewer)
+func AdminAssistantConfigFromEnv() AdminAssistantConfig {
+	config := AdminAssistantConfig{
+		Roles:       make(map[string]string),
+		DefaultRole: AdminAssistantRoleViewer,
+	}
+	if role := strings.TrimSpace(os.Getenv("ADMIN_ASSISTANT_DEFAULT_ROLE")); role != "" {
+		if !isAdminAssistantRole(role) {
+			log.Printf("Invalid ADMIN_ASSISTANT_DEFAULT_ROLE %q ignored", role)
+		} else {
+			config.DefaultRole = role
+		}
+	}
+	for _, entry := range strings.Split(os.Getenv("ADMIN_ASSISTANT_ROLES"), ",") {
+		email, role, ok := strings.Cut(entry, "=")
+		if !ok {
+			continue
+		}
+		role = strings.TrimSpace(role)
+		if !isAdminAssistantRole(role) {
+			log.Printf("Invalid ADMIN_ASSISTANT_ROLES entry %q ignored", entry)
+			continue
+		}
+		config.Roles[strings.ToLower(strings.TrimSpace(email))] = role
+	}
+	return config
+}
+
+// RoleFor returns the assistant role of a staff member
+func (c AdminAssistantConfig) RoleFor(email string) string {
+	if role, ok := c.Roles[strings.ToLower(email)]; ok {
+		return role
+	}
+	return c.DefaultRole
+}
+
+func isAdminAssistantRole(role string) bool {
+	_, ok := adminAssistantRoles[role]
+	return ok || role == AdminAssistantRoleNone
+}
+
+func roleHasPermission(role, permission string) bool {
+	for _, granted := range adminAssistantRoles[role] {
+		if granted == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// AdminStaff identifies the admin talking to the assistant
+type AdminStaff struct {
+	ID        uuid.UUID
+	Email     string
+	IPAddress string
+}
+
+// AdminAssistantReply is the assistant's answer to a staff message
+type AdminAssistantReply struct {
+	ActionID             uuid.UUID   `json:"action_id"`
+	Status               string      `json:"status"`
+	Tool                 string      `json:"tool,omitempty"`
+	Message              string      `json:"message"`
+	Data                 interface{} `json:"data,omitempty"`
+	RequiresConfirmation bool        `json:"requires_confirmation"`
+}
+
+// adminToolCall is a tool the language model chose for a staff message
+type adminToolCall struct {
+	Tool      string                 `json:"tool"`
+	Arguments map[string]interface{} `json:"arguments"`
+}
+
+// adminTool is an operation the assistant can run for staff with Permission.
+// Tools that change data have a prepare step that validates the arguments
+// and describes the change; they only run once the admin confirms it.
+type adminTool struct {
+	Name        string
+	Description string
+	Arguments   string
+	Permission  string
+	prepare     func(ctx context.Context, args map[string]interface{}) (map[string]interface{}, string, error)
+	run         func(ctx context.Context, args map[string]interface{}) (interface{}, string, error)
+}
+
+// AdminAssistantService lets staff ask questions and make changes in plain
+// language. The language model only picks a tool; every tool is checked
+// against the admin's role, changes wait for confirmation, and every request
+// is written to the audit log.
+type AdminAssistantService struct {
+	db        *gorm.DB
+	llm       LLMProvider
+	config    AdminAssistantConfig
+	orders    *OrderService
+	inventory *InventoryService
+	tools     []adminTool
+}
+
+// NewAdminAssistantService creates a new AdminAssistantService
+func NewAdminAssistantService(db *gorm.DB, config AdminAssistantConfig) *AdminAssistantService {
+	s := &AdminAssistantService{
+		db:        db,
+		llm:       NewResilientLLM(NewOpenAIProvider(os.Getenv("OPENAI_API_KEY")), ResilientLLMConfigFromEnv()),
+		config:    config,
+		orders:    NewOrderService(db),
+		inventory: NewInventoryService(db),
+	}
+	s.tools = s.defaultTools()
+	return s
+}
+
+// WithLLM replaces the provider that picks tools
+func (s *AdminAssistantService) WithLLM(llm LLMProvider) *AdminAssistantService {
+	s.llm = llm
+	return s
+}
+
+// Ask answers a staff message, running the tool it calls for. Tools that
+// change data are only proposed; the reply carries the action to confirm.
+func (s *AdminAssistantService) Ask(ctx context.Context, staff AdminStaff, message string) (*AdminAssistantReply, error) {
+	message = strings.TrimSpace(message)
+	if message == "" {
+		return nil, errors.New("message is required")
+	}
+
+	action := &models.AdminAssistantAction{
+		ID:         uuid.New(),
+		StaffID:    staff.ID,
+		StaffEmail: staff.Email,
+		Role:       s.config.RoleFor(staff.Email),
+		Message:    message,
+		IPAddress:  staff.IPAddress,
+	}
+	if len(adminAssistantRoles[action.Role]) == 0 {
+		action.Status = AssistantActionDenied
+		action.Error = ErrAssistantNotAllowed.Error()
+		s.audit(ctx, action)
+		return nil, ErrAssistantNotAllowed
+	}
+
+	call, answer := s.plan(ctx, action.Role, message)
+	if call == nil {
+		action.Status = AssistantActionAnswered
+		action.Result = jsonResult(answer)
+		return s.reply(ctx, action, answer, nil), nil
+	}
+
+	tool, ok := s.tool(call.Tool)
+	if !ok {
+		answer = "I can't do that yet. I can report orders and stock, and update stock levels."
+		action.Status = AssistantActionAnswered
+		action.Result = jsonResult(answer)
+		return s.reply(ctx, action, answer, nil), nil
+	}
+	action.Tool = tool.Name
+	action.Arguments = jsonResult(call.Arguments)
+	if !roleHasPermission(action.Role, tool.Permission) {
+		action.Status = AssistantActionDenied
+		action.Error = ErrAssistantToolDenied.Error()
+		s.audit(ctx, action)
+		return nil, ErrAssistantToolDenied
+	}
+
+	if tool.prepare != nil {
+		args, prompt, err := tool.prepare(ctx, call.Arguments)
+		if err != nil {
+			action.Status = AssistantActionFailed
+			action.Error = err.Error()
+			return s.reply(ctx, action, err.Error(), nil), nil
+		}
+		action.Status = AssistantActionPending
+		action.Arguments = jsonResult(args)
+		reply := s.reply(ctx, action, prompt, args)
+		reply.RequiresConfirmation = true
+		return reply, nil
+	}
+
+	data, summary, err := tool.run(ctx, call.Arguments)
+	if err != nil {
+		action.Status = AssistantActionFailed
+		action.Error = err.Error()
+		return s.reply(ctx, action, err.Error(), nil), nil
+	}
+	action.Status = AssistantActionExecuted
+	action.Result = jsonResult(data)
+	return s.reply(ctx, action, summary, data), nil
+}
+
+// Confirm applies a change the assistant proposed to the same admin. The
+// admin's role is checked again, since it may have changed in between.
+func (s *AdminAssistantService) Confirm(ctx context.Context, staff AdminStaff, actionID uuid.UUID) (*AdminAssistantReply, error) {
+	db := s.db.WithContext(ctx)
+	var action models.AdminAssistantAction
+	if err := db.Where("id = ? AND staff_id = ?", actionID, staff.ID).First(&action).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAssistantActionNotFound
+		}
+		return nil, fmt.Errorf("failed to fetch assistant action: %v", err)
+	}
+	if action.Status != AssistantActionPending {
+		return nil, ErrAssistantActionNotPending
+	}
+	if time.Since(action.CreatedAt) > assistantConfirmationTTL {
+		s.setStatus(ctx, &action, AssistantActionExpired, "")
+		return nil, ErrAssistantConfirmationExpired
+	}
+	tool, ok := s.tool(action.Tool)
+	if !ok {
+		return nil, ErrAssistantActionNotFound
+	}
+	if !roleHasPermission(s.config.RoleFor(staff.Email), tool.Permission) {
+		s.setStatus(ctx, &action, AssistantActionDenied, ErrAssistantToolDenied.Error())
+		return nil, ErrAssistantToolDenied
+	}
+
+	// Claim the action so a double submit can't apply it twice
+	now := time.Now()
+	result := db.Model(&models.AdminAssistantAction{}).
+		Where("id = ? AND status = ?", action.ID, AssistantActionPending).
+		Updates(map[string]interface{}{"status": AssistantActionExecuted, "confirmed_at": now})
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to confirm assistant action: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrAssistantActionNotPending
+	}
+	action.Status = AssistantActionExecuted
+	action.ConfirmedAt = &now
+
+	var args map[string]interface{}
+	if err := json.Unmarshal(action.Arguments, &args); err != nil {
+		return nil, fmt.Errorf("failed to read assistant action: %v", err)
+	}
+	data, summary, err := tool.run(ctx, args)
+	if err != nil {
+		s.setStatus(ctx, &action, AssistantActionFailed, err.Error())
+		return &AdminAssistantReply{ActionID: action.ID, Status: action.Status, Tool: action.Tool, Message: err.Error()}, nil
+	}
+
+	action.Result = jsonResult(data)
+	if err := db.Model(&action).Update("result", action.Result).Error; err != nil {
+		log.Printf("Failed to record result of assistant action %s: %v", action.ID, err)
+	}
+	return &AdminAssistantReply{ActionID: action.ID, Status: action.Status, Tool: action.Tool, Message: summary, Data: data}, nil
+}
+
+// AssistantActionListSchema is what the assistant's audit log can be
+// filtered and sorted by
+var AssistantActionListSchema = &listquery.Schema{
+	Filters: map[string]listquery.Field{
+		"staff_id": listquery.Column("staff_id", listquery.UUID),
+		"tool":     listquery.Column("tool", listquery.String),
+		"status": listquery.Column("status", listquery.String).OneOf(AssistantActionAnswered, AssistantActionExecuted,
+			AssistantActionPending, AssistantActionDenied, AssistantActionFailed, AssistantActionExpired),
+		"created_at": listquery.Column("created_at", listquery.Time),
+	},
+	Sorts: map[string]string{
+		"created_at": "created_at",
+		"tool":       "tool",
+		"status":     "status",
+	},
+	DefaultSort: "-created_at",
+	TieBreaker:  "id",
+	DefaultSize: 50,
+	MaxSize:     200,
+	Aliases: map[string]string{
+		"staff_id": "staff_id",
+	},
+}
+
+// ListActions returns a page of the audit log, newest first unless the list
+// asks otherwise, and how many actions match
+func (s *AdminAssistantService) ListActions(ctx context.Context, list *listquery.Query) ([]models.AdminAssistantAction, int64, error) {
+	query := list.Filter(s.db.WithContext(ctx).Model(&models.AdminAssistantAction{}))
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count assistant actions: %v", err)
+	}
+
+	var actions []models.AdminAssistantAction
+	if err := list.Paginate(list.Order(query)).Find(&actions).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to fetch assistant actions: %v", err)
+	}
+	return actions, total, nil
+}
+
+// plan asks the language model which tool answers the message. When the
+// model is unavailable the message is matched against a few fixed commands.
+func (s *AdminAssistantService) plan(ctx context.Context, role, message string) (*adminToolCall, string) {
+	response, err := s.llm.Complete(ctx, LLMRequest{
+		Model: DefaultLLMConfig().Model,
+		Messages: []LLMMessage{
+			{Role: openai.ChatMessageRoleSystem, Content: s.systemPrompt(role)},
+			{Role: openai.ChatMessageRoleUser, Content: message},
+		},
+		MaxTokens:   300,
+		Temperature: 0,
+	})
+	if err != nil {
+		log.Printf("Admin assistant falling back to command matching: %v", err)
+		if call := parseAdminCommand(message); call != nil {
+			return call, ""
+		}
+		return nil, "The assistant is unavailable right now. Try a direct command such as \"how many orders today?\" or \"set Wireless Headphones stock to 40\"."
+	}
+	if call := parseToolCall(response.Content); call != nil {
+		return call, ""
+	}
+	return nil, strings.TrimSpace(response.Content)
+}
+
+// systemPrompt lists the tools the role may use
+func (s *AdminAssistantService) systemPrompt(role string) string {
+	var prompt strings.Builder
+	prompt.WriteString("You are the store's internal admin assistant. Answer staff questions by calling one of the tools below. ")
+	prompt.WriteString("To call a tool, reply with only a JSON object such as {\"tool\": \"orders_summary\", \"arguments\": {\"period\": \"today\"}}. ")
+	prompt.WriteString("If no tool fits, reply briefly in plain text. Never make up figures.\n\nTools:\n")
+	for _, tool := range s.tools {
+		if roleHasPermission(role, tool.Permission) {
+			fmt.Fprintf(&prompt, "- %s %s: %s\n", tool.Name, tool.Arguments, tool.Description)
+		}
+	}
+	return prompt.String()
+}
+
+func (s *AdminAssistantService) tool(name string) (adminTool, bool) {
+	for _, tool := range s.tools {
+		if tool.Name == name {
+			return tool, true
+		}
+	}
+	return adminTool{}, false
+}
+
+// reply records the action in the audit log and builds the reply
+func (s *AdminAssistantService) reply(ctx context.Context, action *models.AdminAssistantAction, message string, data interface{}) *AdminAssistantReply {
+	s.audit(ctx, action)
+	return &AdminAssistantReply{
+		ActionID: action.ID,
+		Status:   action.Status,
+		Tool:     action.Tool,
+		Message:  message,
+		Data:     data,
+	}
+}
+
+// audit writes an action to the audit log. A failed write is logged, so the
+// log line is the audit record of last resort.
+func (s *AdminAssistantService) audit(ctx context.Context, action *models.AdminAssistantAction) {
+	if err := s.db.WithContext(ctx).Create(action).Error; err != nil {
+		log.Printf("Failed to audit admin assistant action (staff=%s tool=%s status=%s): %v", action.StaffID, action.Tool, action.Status, err)
+	}
+}
+
+func (s *AdminAssistantService) setStatus(ctx context.Context, action *models.AdminAssistantAction, status, reason string) {
+	action.Status = status
+	action.Error = reason
+	if err := s.db.WithContext(ctx).Model(action).Updates(map[string]interface{}{"status": status, "error": reason}).Error; err != nil {
+		log.Printf("Failed to update assistant action %s: %v", action.ID, err)
+	}
+}
+
+// defaultTools are the analytics and inventory tools
+func (s *AdminAssistantService) defaultTools() []adminTool {
+	return []adminTool{
+		{
+			Name:        "orders_summary",
+			Description: "number of orders and revenue in a period",
+			Arguments:   `{"period": "today|yesterday|7d|30d"}`,
+			Permission:  AdminPermAnalyticsRead,
+			run:         s.ordersSummary,
+		},
+		{
+			Name:        "stock_level",
+			Description: "stock on hand of a product, by name or SKU",
+			Arguments:   `{"product": "name or SKU"}`,
+			Permission:  AdminPermInventoryRead,
+			run:         s.stockLevel,
+		},
+		{
+			Name:        "inventory_report",
+			Description: "low and out of stock counts and products to reorder",
+			Arguments:   `{}`,
+			Permission:  AdminPermInventoryRead,
+			run:         s.inventoryReport,
+		},
+		{
+			Name:        "set_stock",
+			Description: "set the available quantity of a product",
+			Arguments:   `{"product": "name or SKU", "quantity": 40}`,
+			Permission:  AdminPermInventoryWrite,
+			prepare:     s.prepareSetStock,
+			run:         s.setStock,
+		},
+	}
+}
+
+func (s *AdminAssistantService) ordersSummary(ctx context.Context, args map[string]interface{}) (interface{}, string, error) {
+	period, _ := args["period"].(string)
+	if period == "" {
+		period = "today"
+	}
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	from, to, label := today, today.AddDate(0, 0, 1), "today"
+	switch period {
+	case "today":
+	case "yesterday":
+		from, to, label = today.AddDate(0, 0, -1), today, "yesterday"
+	case "7d":
+		from, label = today.AddDate(0, 0, -6), "in the last 7 days"
+	case "30d":
+		from, label = today.AddDate(0, 0, -29), "in the last 30 days"
+	default:
+		return nil, "", fmt.Errorf("unknown period %q; use today, yesterday, 7d or 30d", period)
+	}
+
+	summary, err := s.orders.SalesSummary(ctx, from, to)
+	if err != nil {
+		return nil, "", err
+	}
+	return summary, fmt.Sprintf("%d orders %s, totalling %.2f.", summary.Orders, label, summary.Revenue), nil
+}
+
+func (s *AdminAssistantService) stockLevel(ctx context.Context, args map[string]interface{}) (interface{}, string, error) {
+	product, err := s.resolveProduct(ctx, args["product"])
+	if err != nil {
+		return nil, "", err
+	}
+	levels, err := s.inventory.GetInventoryLevels(ctx, &product.ID, nil)
+	if err != nil {
+		return nil, "", err
+	}
+
+	available, reserved := 0, 0
+	for _, level := range levels {
+		available += level.QuantityAvailable
+		reserved += level.QuantityReserved
+	}
+	data := map[string]interface{}{
+		"product_id": product.ID,
+		"name":       product.Name,
+		"sku":        product.SKU,
+		"available":  available,
+		"reserved":   reserved,
+	}
+	return data, fmt.Sprintf("%s (%s): %d available, %d reserved.", product.Name, product.SKU, available, reserved), nil
+}
+
+func (s *AdminAssistantService) inventoryReport(ctx context.Context, _ map[string]interface{}) (interface{}, string, error) {
+	report, err := s.inventory.GetInventoryReport(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	return report, fmt.Sprintf("%d products in stock records: %d low on stock, %d out of stock, %d to reorder.",
+		report.TotalProducts, report.LowStockItems, report.OutOfStockItems, len(report.ReorderSuggestions)), nil
+}
+
+func (s *AdminAssistantService) prepareSetStock(ctx context.Context, args map[string]interface{}) (map[string]interface{}, string, error) {
+	product, err := s.resolveProduct(ctx, args["product"])
+	if err != nil {
+		return nil, "", err
+	}
+	quantity, ok := intArgument(args["quantity"])
+	if !ok || quantity < 0 {
+		return nil, "", errors.New("quantity must be a whole number of zero or more")
+	}
+	levels, err := s.inventory.GetInventoryLevels(ctx, &product.ID, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	current := 0
+	for _, level := range levels {
+		if level.VariantID == nil {
+			current = level.QuantityAvailable
+		}
+	}
+
+	prepared := map[string]interface{}{
+		"product_id": product.ID.String(),
+		"name":       product.Name,
+		"sku":        product.SKU,
+		"quantity":   quantity,
+		"previous":   current,
+	}
+	return prepared, fmt.Sprintf("Set the stock of %s (%s) from %d to %d? Confirm to apply.", product.Name, product.SKU, current, quantity), nil
+}
+
+func (s *AdminAssistantService) setStock(ctx context.Context, args map[string]interface{}) (interface{}, string, error) {
+	productIDStr, _ := args["product_id"].(string)
+	productID, err := uuid.Parse(productIDStr)
+	if err != nil {
+		return nil, "", errors.New("invalid product_id")
+	}
+	quantity, ok := intArgument(args["quantity"])
+	if !ok || quantity < 0 {
+		return nil, "", errors.New("invalid quantity")
+	}
+
+	if err := s.inventory.UpdateInventory(ctx, InventoryUpdateRequest{
+		ProductID: productID,
+		Quantity:  quantity,
+		Operation: "set",
+	}); err != nil {
+		return nil, "", err
+	}
+	name, _ := args["name"].(string)
+	return args, fmt.Sprintf("Stock of %s set to %d.", name, quantity), nil
+}
+
+// resolveProduct finds the one product a name or SKU refers to
+func (s *AdminAssistantService) resolveProduct(ctx context.Context, value interface{}) (*models.Product, error) {
+	query, _ := value.(string)
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, errors.New("which product? Give its name or SKU")
+	}
+	lower := strings.ToLower(query)
+
+	db := s.db.WithContext(ctx).Select("id", "name", "sku")
+	var products []models.Product
+	if err := db.Where("LOWER(sku) = ? OR LOWER(name) = ?", lower, lower).Limit(2).Find(&products).Error; err != nil {
+		return nil, fmt.Errorf("failed to find product: %v", err)
+	}
+	if len(products) == 0 {
+		if err := db.Where("LOWER(name) LIKE ?", "%"+lower+"%").Limit(5).Find(&products).Error; err != nil {
+			return nil, fmt.Errorf("failed to find product: %v", err)
+		}
+	}
+
+	switch len(products) {
+	case 0:
+		return nil, fmt.Errorf("no product matches %q", query)
+	case 1:
+		return &products[0], nil
+	default:
+		matches := make([]string, len(products))
+		for i, product := range products {
+			matches[i] = fmt.Sprintf("%s (%s)", product.Name, product.SKU)
+		}
+		return nil, fmt.Errorf("%q matches several products: %s. Use the SKU", query, strings.Join(matches, ", "))
+	}
+}
+
+// parseToolCall reads a tool call from the model's reply: the whole reply or
+// a line of it as a JSON object with a tool
+func parseToolCall(content string) *adminToolCall {
+	candidates := append([]string{strings.TrimSpace(content)}, strings.Split(content, "\n")...)
+	for _, candidate := range candidates {
+		candidate = strings.TrimSpace(candidate)
+		candidate = strings.TrimSuffix(strings.TrimPrefix(candidate, "```json"), "```")
+		candidate = strings.TrimSpace(candidate)
+		if !strings.HasPrefix(candidate, "{") || !strings.HasSuffix(candidate, "}") {
+			continue
+		}
+		var call adminToolCall
+		if err := json.Unmarshal([]byte(candidate), &call); err == nil && call.Tool != "" {
+			if call.Arguments == nil {
+				call.Arguments = map[string]interface{}{}
+			}
+			return &call
+		}
+	}
+	return nil
+}
+
+var (
+	ordersCommand   = regexp.MustCompile(`(?i)\borders?\b.*\b(today|yesterday|week|month)\b`)
+	setStockCommand = regexp.MustCompile(`(?i)^set\s+(?:the\s+)?(?:stock\s+(?:of|for)\s+(.+?)|(.+?)\s+stock)\s+to\s+(\d+)\.?$`)
+	stockCommand    = regexp.MustCompile(`(?i)^(?:what(?:'s|\s+is)\s+the\s+)?stock\s+(?:level\s+)?(?:of|for)\s+(.+?)\??$`)
+)
+
+// parseAdminCommand matches the most common requests when the language model
+// is unavailable
+func parseAdminCommand(message string) *adminToolCall {
+	message = strings.TrimSpace(message)
+	if match := setStockCommand.FindStringSubmatch(message); match != nil {
+		product := match[1]
+		if product == "" {
+			product = match[2]
+		}
+		quantity, _ := strconv.Atoi(match[3])
+		return &adminToolCall{Tool: "set_stock", Arguments: map[string]interface{}{"product": product, "quantity": quantity}}
+	}
+	if match := stockCommand.FindStringSubmatch(message); match != nil {
+		return &adminToolCall{Tool: "stock_level", Arguments: map[string]interface{}{"product": match[1]}}
+	}
+	if match := ordersCommand.FindStringSubmatch(message); match != nil {
+		period := map[string]string{"today": "today", "yesterday": "yesterday", "week": "7d", "month": "30d"}[strings.ToLower(match[1])]
+		return &adminToolCall{Tool: "orders_summary", Arguments: map[string]interface{}{"period": period}}
+	}
+	return nil
+}
+
+// intArgument reads a whole number the model may have sent as a number or a string
+func intArgument(value interface{}) (int, bool) {
+	switch v := value.(type) {
+	case float64:
+		if v != float64(int(v)) {
+			return 0, false
+		}
+		return int(v), true
+	case int:
+		return v, true
+	case string:
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		return n, err == nil
+	default:
+		return 0, false
+	}
+}
+
+func jsonResult(value interface{}) datatypes.JSON {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil
+	}
+	return datatypes.JSON(data)
+}
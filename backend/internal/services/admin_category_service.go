@@ -0,0 +1,187 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Category reorganization errors
+var (
+	ErrCategoryNotFound = errors.New("category not found")
+	ErrCategoryCycle    = errors.New("move would create a cycle in the category tree")
+)
+
+// CategoryMergeRequest represents the request to merge a category into another
+type CategoryMergeRequest struct {
+	TargetID uuid.UUID `json:"target_id" binding:"required"`
+}
+
+// CategoryMoveRequest represents the request to move a category subtree; a
+// nil ParentID moves it to the root
+type CategoryMoveRequest struct {
+	ParentID *uuid.UUID `json:"parent_id"`
+}
+
+// CategoryMergeResult reports what a merge changed
+type CategoryMergeResult struct {
+	Target            *models.Category `json:"target"`
+	ProductsMoved     int64            `json:"products_moved"`
+	ChildrenMoved     int64            `json:"children_moved"`
+	RedirectedSlugs   []string         `json:"redirected_slugs"`
+	DeletedCategoryID uuid.UUID        `json:"deleted_category_id"`
+}
+
+// MergeCategories moves the products and child categories of source into
+// target, redirects source's slug to target and deletes source
+func (s *AdminProductService) MergeCategories(ctx context.Context, sourceID, targetID uuid.UUID) (*CategoryMergeResult, error) {
+	if sourceID == targetID {
+		return nil, errors.New("cannot merge a category into itself")
+	}
+
+	result := &CategoryMergeResult{DeletedCategoryID: sourceID}
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		source, err := findCategory(tx, sourceID)
+		if err != nil {
+			return err
+		}
+		target, err := findCategory(tx, targetID)
+		if err != nil {
+			return err
+		}
+
+		// Re-pointing source's children at a descendant of source would orphan that branch
+		descendant, err := isDescendant(tx, targetID, sourceID)
+		if err != nil {
+			return err
+		}
+		if descendant {
+			return fmt.Errorf("cannot merge into a descendant category: %w", ErrCategoryCycle)
+		}
+
+		products := tx.Model(&models.Product{}).Where("category_id = ?", sourceID).Update("category_id", targetID)
+		if products.Error != nil {
+			return fmt.Errorf("failed to move products: %v", products.Error)
+		}
+		result.ProductsMoved = products.RowsAffected
+
+		children := tx.Model(&models.Category{}).Where("parent_id = ?", sourceID).Update("parent_id", targetID)
+		if children.Error != nil {
+			return fmt.Errorf("failed to move child categories: %v", children.Error)
+		}
+		result.ChildrenMoved = children.RowsAffected
+
+		// Slugs that already redirected to source now redirect to target
+		var redirects []models.CategorySlugRedirect
+		if err := tx.Where("category_id = ?", sourceID).Find(&redirects).Error; err != nil {
+			return fmt.Errorf("failed to fetch slug redirects: %v", err)
+		}
+		if err := tx.Model(&models.CategorySlugRedirect{}).Where("category_id = ?", sourceID).Update("category_id", targetID).Error; err != nil {
+			return fmt.Errorf("failed to update slug redirects: %v", err)
+		}
+		for _, redirect := range redirects {
+			result.RedirectedSlugs = append(result.RedirectedSlugs, redirect.FromSlug)
+		}
+
+		redirect := models.CategorySlugRedirect{
+			ID:         uuid.New(),
+			FromSlug:   source.Slug,
+			CategoryID: targetID,
+			CreatedAt:  time.Now(),
+		}
+		if err := tx.Create(&redirect).Error; err != nil {
+			return fmt.Errorf("failed to create slug redirect: %v", err)
+		}
+		result.RedirectedSlugs = append(result.RedirectedSlugs, source.Slug)
+
+		if err := tx.Delete(&models.Category{}, "id = ?", sourceID).Error; err != nil {
+			return fmt.Errorf("failed to delete merged category: %v", err)
+		}
+
+		result.Target = target
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// MoveCategory moves a category, with its subtree, under a new parent
+func (s *AdminProductService) MoveCategory(ctx context.Context, id uuid.UUID, parentID *uuid.UUID) (*models.Category, error) {
+	var category *models.Category
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		category, err = findCategory(tx, id)
+		if err != nil {
+			return err
+		}
+
+		if parentID != nil {
+			if *parentID == id {
+				return ErrCategoryCycle
+			}
+			if _, err := findCategory(tx, *parentID); err != nil {
+				return fmt.Errorf("parent %w", err)
+			}
+			descendant, err := isDescendant(tx, *parentID, id)
+			if err != nil {
+				return err
+			}
+			if descendant {
+				return ErrCategoryCycle
+			}
+		}
+
+		if err := tx.Model(category).Update("parent_id", parentID).Error; err != nil {
+			return fmt.Errorf("failed to move category: %v", err)
+		}
+		category.ParentID = parentID
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return category, nil
+}
+
+func findCategory(tx *gorm.DB, id uuid.UUID) (*models.Category, error) {
+	var category models.Category
+	if err := tx.Where("id = ?", id).First(&category).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCategoryNotFound
+		}
+		return nil, fmt.Errorf("failed to fetch category: %v", err)
+	}
+	return &category, nil
+}
+
+// isDescendant reports whether id sits below ancestorID by walking up the
+// parent chain. The walk is bounded so a corrupted tree cannot loop forever.
+func isDescendant(tx *gorm.DB, id, ancestorID uuid.UUID) (bool, error) {
+	current := id
+	for depth := 0; depth < 100; depth++ {
+		var category models.Category
+		if err := tx.Select("id", "parent_id").Where("id = ?", current).First(&category).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return false, nil
+			}
+			return false, fmt.Errorf("failed to walk category tree: %v", err)
+		}
+		if category.ParentID == nil {
+			return false, nil
+		}
+		if *category.ParentID == ancestorID {
+			return true, nil
+		}
+		current = *category.ParentID
+	}
+	return false, ErrCategoryCycle
+}
@@ -0,0 +1,232 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Admin channel message types, sent to every admin connected to the admin
+// WebSocket
+const (
+	AdminPresenceMessage     = "admin_presence" // the admins connected and the records being edited
+	AdminEditLockMessage     = "edit_lock"      // an admin started, kept or stopped editing a record
+	AdminRecordSavedMessage  = "record_saved"   // a record was saved, so open copies of it are out of date
+	AdminEditConflictMessage = "edit_conflict"  // a save overwrote a change its editor hadn't seen
+)
+
+// Kinds of records admins edit
+const (
+	EditResourceProduct   = "product"
+	EditResourceInventory = "inventory"
+)
+
+// AdminPresenceConfig holds how long an edit lock lasts without being refreshed
+type AdminPresenceConfig struct {
+	LockTTL time.Duration
+}
+
+// AdminPresenceConfigFromEnv reads ADMIN_EDIT_LOCK_SECONDS
+func AdminPresenceConfigFromEnv() AdminPresenceConfig {
+	return AdminPresenceConfig{
+		LockTTL: time.Duration(envInt("ADMIN_EDIT_LOCK_SECONDS", 120)) * time.Second,
+	}
+}
+
+// AdminNotifier sends a message to every admin connected to the admin channel
+type AdminNotifier interface {
+	NotifyAdmins(messageType string, data interface{})
+}
+
+// EditResource is a record an admin edits: a product, or the stock of a
+// product or one of its variants
+type EditResource struct {
+	Kind      string     `json:"kind"`
+	ID        uuid.UUID  `json:"id"`
+	VariantID *uuid.UUID `json:"variant_id,omitempty"`
+}
+
+// key identifies the resource in the presence maps
+func (r EditResource) key() string {
+	if r.VariantID != nil {
+		return fmt.Sprintf("%s:%s:%s", r.Kind, r.ID, *r.VariantID)
+	}
+	return fmt.Sprintf("%s:%s", r.Kind, r.ID)
+}
+
+// Valid reports whether the resource names a record admins can edit
+func (r EditResource) Valid() bool {
+	return (r.Kind == EditResourceProduct || r.Kind == EditResourceInventory) && r.ID != uuid.Nil
+}
+
+// AdminEditor is an admin editing records
+type AdminEditor struct {
+	UserID uuid.UUID `json:"user_id"`
+	Email  string    `json:"email"`
+}
+
+// EditLock tells the other admins who is editing a record. Locks are
+// advisory: saving a record someone else holds isn't refused.
+type EditLock struct {
+	Resource  EditResource `json:"resource"`
+	Editor    AdminEditor  `json:"editor"`
+	Since     time.Time    `json:"since"`
+	ExpiresAt time.Time    `json:"expires_at"`         // unless refreshed by the editor
+	Released  bool         `json:"released,omitempty"` // the editor stopped editing
+}
+
+// AdminPresenceSnapshot is who is connected to the admin channel and what they are editing
+type AdminPresenceSnapshot struct {
+	Admins []AdminEditor `json:"admins"`
+	Locks  []EditLock    `json:"locks"`
+}
+
+// RecordSaved tells the admins a record changed, so copies of it open in
+// their editors are out of date
+type RecordSaved struct {
+	Resource EditResource `json:"resource"`
+	Editor   AdminEditor  `json:"editor"`
+	SavedAt  time.Time    `json:"saved_at"`
+}
+
+// EditConflict is a save that overwrote a version of the record its editor
+// hadn't seen. The save is kept, last write wins, and both admins are told.
+type EditConflict struct {
+	Resource          EditResource `json:"resource"`
+	Editor            AdminEditor  `json:"editor"`                   // who saved over the change
+	ExpectedUpdatedAt time.Time    `json:"expected_updated_at"`      // the version their edit started from
+	OverwrittenAt     time.Time    `json:"overwritten_at"`           // the version they replaced
+	OverwrittenBy     *AdminEditor `json:"overwritten_by,omitempty"` // who saved that version, when known
+	Message           string       `json:"message"`
+}
+
+// AdminPresence keeps the admins' edit locks and the last save of each
+// record, and spots saves made over a change the editor hadn't seen. It
+// lives in memory: locks and saves are forgotten on restart.
+type AdminPresence struct {
+	config AdminPresenceConfig
+
+	mu     sync.Mutex
+	locks  map[string]*EditLock
+	lastBy map[string]AdminEditor // who saved each record last
+}
+
+// NewAdminPresence creates the admins' presence and edit lock registry
+func NewAdminPresence(config AdminPresenceConfig) *AdminPresence {
+	if config.LockTTL <= 0 {
+		config.LockTTL = 2 * time.Minute
+	}
+	return &AdminPresence{
+		config: config,
+		locks:  make(map[string]*EditLock),
+		lastBy: make(map[string]AdminEditor),
+	}
+}
+
+// Lock takes or refreshes an editor's lock on a record. When another admin
+// holds it, their lock is returned and acquired is false.
+func (p *AdminPresence) Lock(resource EditResource, editor AdminEditor, now time.Time) (lock EditLock, acquired bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key := resource.key()
+	held, ok := p.locks[key]
+	if ok && now.Before(held.ExpiresAt) {
+		if held.Editor.UserID != editor.UserID {
+			return *held, false
+		}
+	} else {
+		held = &EditLock{Resource: resource, Editor: editor, Since: now}
+		p.locks[key] = held
+	}
+	held.ExpiresAt = now.Add(p.config.LockTTL)
+	return *held, true
+}
+
+// Unlock gives up an editor's lock on a record. It returns the released lock,
+// or nil when the editor didn't hold it.
+func (p *AdminPresence) Unlock(resource EditResource, editor AdminEditor) *EditLock {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key := resource.key()
+	held, ok := p.locks[key]
+	if !ok || held.Editor.UserID != editor.UserID {
+		return nil
+	}
+	delete(p.locks, key)
+	released := *held
+	released.Released = true
+	return &released
+}
+
+// UnlockAll gives up every lock an editor holds, e.g. when they disconnect
+func (p *AdminPresence) UnlockAll(editor AdminEditor) []EditLock {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var released []EditLock
+	for key, held := range p.locks {
+		if held.Editor.UserID == editor.UserID {
+			delete(p.locks, key)
+			lock := *held
+			lock.Released = true
+			released = append(released, lock)
+		}
+	}
+	return released
+}
+
+// Locks returns the locks still held, oldest first
+func (p *AdminPresence) Locks(now time.Time) []EditLock {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	locks := make([]EditLock, 0, len(p.locks))
+	for key, held := range p.locks {
+		if !now.Before(held.ExpiresAt) {
+			delete(p.locks, key)
+			continue
+		}
+		locks = append(locks, *held)
+	}
+	sort.Slice(locks, func(i, j int) bool { return locks[i].Since.Before(locks[j].Since) })
+	return locks
+}
+
+// Saved records an editor's save of a record whose version was current
+// before it. expected is the version the editor's copy was loaded at; when
+// it's older than current the save overwrote a change they hadn't seen and
+// the conflict is returned. Without expected no conflict is detected.
+func (p *AdminPresence) Saved(resource EditResource, editor AdminEditor, expected, current *time.Time, now time.Time) (RecordSaved, *EditConflict) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key := resource.key()
+	saved := RecordSaved{Resource: resource, Editor: editor, SavedAt: now}
+
+	var conflict *EditConflict
+	if expected != nil && current != nil && !sameVersion(*expected, *current) {
+		conflict = &EditConflict{
+			Resource:          resource,
+			Editor:            editor,
+			ExpectedUpdatedAt: *expected,
+			OverwrittenAt:     *current,
+			Message:           fmt.Sprintf("This %s changed after you opened it; your save replaced those changes.", resource.Kind),
+		}
+		if by, ok := p.lastBy[key]; ok && by.UserID != editor.UserID {
+			conflict.OverwrittenBy = &by
+			conflict.Message = fmt.Sprintf("%s changed this %s after you opened it; your save replaced their changes.", by.Email, resource.Kind)
+		}
+	}
+	p.lastBy[key] = editor
+	return saved, conflict
+}
+
+// sameVersion compares update times at the database's microsecond precision
+func sameVersion(a, b time.Time) bool {
+	return a.Truncate(time.Microsecond).Equal(b.Truncate(time.Microsecond))
+}
@@ -2,7 +2,9 @@ package services
 
 import (
 	"chat-ecommerce-backend/internal/models"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
@@ -14,13 +16,17 @@ import (
 
 // AdminProductService handles admin-specific product operations
 type AdminProductService struct {
-	db *gorm.DB
+	db     *gorm.DB
+	review ProductReviewConfig
+	skus   SKUConfig
 }
 
 // NewAdminProductService creates a new AdminProductService
 func NewAdminProductService(db *gorm.DB) *AdminProductService {
 	return &AdminProductService{
-		db: db,
+		db:     db,
+		review: ProductReviewConfigFromEnv(),
+		skus:   SKUConfigFromEnv(),
 	}
 }
 
@@ -30,6 +36,7 @@ type AdminProductRequest struct {
 	Description string                  `json:"description" binding:"required"`
 	Price       float64                 `json:"price" binding:"required,min=0"`
 	CategoryID  uuid.UUID               `json:"category_id" binding:"required"`
+	BrandID     *uuid.UUID              `json:"brand_id"`
 	SKU         string                  `json:"sku" binding:"required"`
 	Status      string                  `json:"status"`
 	Metadata    map[string]interface{}  `json:"metadata"`
@@ -37,6 +44,17 @@ type AdminProductRequest struct {
 	Images      []ProductImageRequest   `json:"images"`
 	Variants    []ProductVariantRequest `json:"variants"`
 	Inventory   []InventoryRequest      `json:"inventory"`
+	PublishAt   *time.Time              `json:"publish_at"`
+	UnpublishAt *time.Time              `json:"unpublish_at"`
+
+	// On update, children are only deleted when listed here
+	RemoveVariantIDs   []uuid.UUID `json:"remove_variant_ids"`
+	RemoveImageIDs     []uuid.UUID `json:"remove_image_ids"`
+	RemoveInventoryIDs []uuid.UUID `json:"remove_inventory_ids"`
+
+	// ExpectedUpdatedAt is the updated_at of the product the admin's edit
+	// started from; saving over a newer version is reported as a conflict
+	ExpectedUpdatedAt *time.Time `json:"expected_updated_at,omitempty"`
 }
 
 // ProductImageRequest represents a product image request
@@ -74,16 +92,18 @@ type AdminProductResponse struct {
 
 // BulkImportRequest represents a bulk import request
 type BulkImportRequest struct {
-	Products       []AdminProductRequest `json:"products" binding:"required"`
-	UpdateExisting bool                  `json:"update_existing"`
+	Products          []AdminProductRequest `json:"products" binding:"required"`
+	UpdateExisting    bool                  `json:"update_existing"`
+	DuplicateStrategy string                `json:"duplicate_strategy" binding:"omitempty,oneof=flag skip merge"`
 }
 
 // BulkImportResponse represents the response for bulk import
 type BulkImportResponse struct {
-	TotalProcessed int               `json:"total_processed"`
-	Created        int               `json:"created"`
-	Updated        int               `json:"updated"`
-	Errors         []BulkImportError `json:"errors"`
+	TotalProcessed int                   `json:"total_processed"`
+	Created        int                   `json:"created"`
+	Updated        int                   `json:"updated"`
+	Errors         []BulkImportError     `json:"errors"`
+	Duplicates     []BulkImportDuplicate `json:"duplicates"`
 }
 
 // BulkImportError represents an error in bulk import
@@ -95,6 +115,11 @@ type BulkImportError struct {
 
 // CreateProduct creates a new product with all related data
 func (s *AdminProductService) CreateProduct(req AdminProductRequest) (*AdminProductResponse, error) {
+	return s.createProduct(req, RevisionSourceCreate)
+}
+
+// createProduct creates a product, recording its first revision with source
+func (s *AdminProductService) createProduct(req AdminProductRequest, source string) (*AdminProductResponse, error) {
 	// Start transaction
 	tx := s.db.Begin()
 	defer func() {
@@ -120,15 +145,30 @@ func (s *AdminProductService) CreateProduct(req AdminProductRequest) (*AdminProd
 		Description: req.Description,
 		Price:       req.Price,
 		CategoryID:  req.CategoryID,
+		BrandID:     req.BrandID,
 		SKU:         req.SKU,
 		Status:      req.Status,
 		Metadata:    metadataJSON,
 		// Tags:        pq.StringArray(req.Tags),
 	}
+	if err := applyPublishWindow(product, req.PublishAt, req.UnpublishAt, time.Now()); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := s.checkSKU(tx, req.SKU, uuid.Nil); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := validateProductMetadata(tx, req.CategoryID, req.Metadata); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
 
 	if err := tx.Create(product).Error; err != nil {
 		tx.Rollback()
-		return nil, fmt.Errorf("failed to create product: %v", err)
+		return nil, lostSKURace(s.db, req.SKU, uuid.Nil, fmt.Errorf("failed to create product: %v", err))
 	}
 
 	// Create variants
@@ -186,6 +226,11 @@ func (s *AdminProductService) CreateProduct(req AdminProductRequest) (*AdminProd
 		inventory = append(inventory, inventoryItem)
 	}
 
+	if err := recordProductRevision(tx, product.ID, source, nil); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
 	// Commit transaction
 	if err := tx.Commit().Error; err != nil {
 		return nil, fmt.Errorf("failed to commit transaction: %v", err)
@@ -199,8 +244,25 @@ func (s *AdminProductService) CreateProduct(req AdminProductRequest) (*AdminProd
 	}, nil
 }
 
+// ProductUpdatedAt returns when a product last changed
+func (s *AdminProductService) ProductUpdatedAt(ctx context.Context, id uuid.UUID) (time.Time, error) {
+	var product models.Product
+	if err := s.db.WithContext(ctx).Select("updated_at").First(&product, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return time.Time{}, ErrProductNotFound
+		}
+		return time.Time{}, fmt.Errorf("failed to fetch product: %v", err)
+	}
+	return product.UpdatedAt, nil
+}
+
 // UpdateProduct updates an existing product
 func (s *AdminProductService) UpdateProduct(id uuid.UUID, req AdminProductRequest) (*AdminProductResponse, error) {
+	return s.updateProduct(id, req, RevisionSourceUpdate)
+}
+
+// updateProduct updates a product, recording a revision with source
+func (s *AdminProductService) updateProduct(id uuid.UUID, req AdminProductRequest, source string) (*AdminProductResponse, error) {
 	// Start transaction
 	tx := s.db.Begin()
 	defer func() {
@@ -227,89 +289,61 @@ func (s *AdminProductService) UpdateProduct(id uuid.UUID, req AdminProductReques
 		metadataJSON = datatypes.JSON(metadataBytes)
 	}
 
+	if req.SKU != product.SKU {
+		if err := s.checkSKU(tx, req.SKU, product.ID); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	if err := validateProductMetadata(tx, req.CategoryID, req.Metadata); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
 	// Update product fields
 	product.Name = req.Name
 	product.Description = req.Description
 	product.Price = req.Price
 	product.CategoryID = req.CategoryID
+	product.BrandID = req.BrandID
 	product.SKU = req.SKU
 	product.Status = req.Status
 	product.Metadata = metadataJSON
 	// product.Tags = pq.StringArray(req.Tags)
-
-	if err := tx.Save(&product).Error; err != nil {
+	if err := applyPublishWindow(&product, req.PublishAt, req.UnpublishAt, time.Now()); err != nil {
 		tx.Rollback()
-		return nil, fmt.Errorf("failed to update product: %v", err)
+		return nil, err
 	}
 
-	// Update variants (delete existing and create new)
-	if err := tx.Where("product_id = ?", product.ID).Delete(&models.ProductVariant{}).Error; err != nil {
+	if err := tx.Save(&product).Error; err != nil {
 		tx.Rollback()
-		return nil, fmt.Errorf("failed to delete existing variants: %v", err)
+		return nil, lostSKURace(s.db, req.SKU, product.ID, fmt.Errorf("failed to update product: %v", err))
 	}
 
-	var variants []models.ProductVariant
-	for _, variantReq := range req.Variants {
-		variant := models.ProductVariant{
-			ProductID:     product.ID,
-			VariantName:   variantReq.VariantName,
-			VariantValue:  variantReq.VariantValue,
-			PriceModifier: variantReq.PriceModifier,
-			SKUSuffix:     variantReq.SKUSuffix,
-			IsDefault:     variantReq.IsDefault,
-		}
-		if err := tx.Create(&variant).Error; err != nil {
-			tx.Rollback()
-			return nil, fmt.Errorf("failed to create variant: %v", err)
-		}
-		variants = append(variants, variant)
-	}
-
-	// Update images (delete existing and create new)
-	if err := tx.Where("product_id = ?", product.ID).Delete(&models.ProductImage{}).Error; err != nil {
+	// Upsert children so variant, image and inventory IDs referenced by carts
+	// and orders survive the update
+	variants, err := upsertVariants(tx, product.ID, product.Variants, req.Variants, req.RemoveVariantIDs)
+	if err != nil {
 		tx.Rollback()
-		return nil, fmt.Errorf("failed to delete existing images: %v", err)
+		return nil, err
 	}
 
-	var images []models.ProductImage
-	for i, imageReq := range req.Images {
-		image := models.ProductImage{
-			ProductID: product.ID,
-			URL:       imageReq.URL,
-			AltText:   imageReq.AltText,
-			IsPrimary: imageReq.IsPrimary,
-			SortOrder: imageReq.SortOrder,
-		}
-		if imageReq.SortOrder == 0 {
-			image.SortOrder = i + 1
-		}
-		if err := tx.Create(&image).Error; err != nil {
-			tx.Rollback()
-			return nil, fmt.Errorf("failed to create image: %v", err)
-		}
-		images = append(images, image)
+	images, err := upsertImages(tx, product.ID, product.Images, req.Images, req.RemoveImageIDs)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
 	}
 
-	// Update inventory (delete existing and create new)
-	if err := tx.Where("product_id = ?", product.ID).Delete(&models.Inventory{}).Error; err != nil {
+	inventory, err := upsertInventory(tx, product.ID, product.Inventory, req.Inventory, req.RemoveInventoryIDs, req.RemoveVariantIDs)
+	if err != nil {
 		tx.Rollback()
-		return nil, fmt.Errorf("failed to delete existing inventory: %v", err)
+		return nil, err
 	}
 
-	var inventory []models.Inventory
-	for _, invReq := range req.Inventory {
-		inventoryItem := models.Inventory{
-			ProductID:         product.ID,
-			VariantID:         invReq.VariantID,
-			QuantityAvailable: invReq.Quantity,
-			WarehouseLocation: invReq.Location,
-			QuantityReserved:  invReq.Reserved,
-		}
-		if err := tx.Create(&inventoryItem).Error; err != nil {
-			tx.Rollback()
-			return nil, fmt.Errorf("failed to create inventory: %v", err)
-		}
-		inventory = append(inventory, inventoryItem)
+	if err := recordProductRevision(tx, product.ID, source, nil); err != nil {
+		tx.Rollback()
+		return nil, err
 	}
 
 	// Commit transaction
@@ -353,14 +387,41 @@ func (s *AdminProductService) DeleteProduct(id uuid.UUID) error {
 		return fmt.Errorf("failed to delete images: %v", err)
 	}
 
+	// Reservations hold the product's inventory rows, so they go first
+	inventories := tx.Model(&models.Inventory{}).Select("id").Where("product_id = ?", id)
+	if err := tx.Where("inventory_id IN (?)", inventories).Delete(&models.InventoryReservation{}).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to delete inventory reservations: %v", err)
+	}
+
 	if err := tx.Where("product_id = ?", id).Delete(&models.Inventory{}).Error; err != nil {
 		tx.Rollback()
 		return fmt.Errorf("failed to delete inventory: %v", err)
 	}
 
-	if err := tx.Where("product_id = ?", id).Delete(&models.InventoryReservation{}).Error; err != nil {
+	if err := tx.Where("product_id = ?", id).Delete(&models.PriceHistory{}).Error; err != nil {
 		tx.Rollback()
-		return fmt.Errorf("failed to delete inventory reservations: %v", err)
+		return fmt.Errorf("failed to delete price history: %v", err)
+	}
+
+	if err := tx.Where("product_id = ?", id).Delete(&models.ProductChangeRequest{}).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to delete change requests: %v", err)
+	}
+
+	if err := tx.Where("product_id = ?", id).Delete(&models.ProductRevision{}).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to delete product revisions: %v", err)
+	}
+
+	if err := tx.Where("product_id = ?", id).Delete(&models.PinnedSearchResult{}).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to delete pinned search results: %v", err)
+	}
+
+	if err := tx.Where("product_id = ?", id).Delete(&models.ProductQuestion{}).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to delete product questions: %v", err)
 	}
 
 	// Delete product
@@ -397,12 +458,18 @@ func (s *AdminProductService) BulkImportProducts(req BulkImportRequest) (*BulkIm
 	response := &BulkImportResponse{
 		TotalProcessed: len(req.Products),
 		Errors:         []BulkImportError{},
+		Duplicates:     []BulkImportDuplicate{},
+	}
+
+	strategy := req.DuplicateStrategy
+	if strategy == "" {
+		strategy = DuplicateStrategyFlag
 	}
 
 	for i, productReq := range req.Products {
 		// Check if product exists
 		var existingProduct models.Product
-		err := s.db.Where("sku = ?", productReq.SKU).First(&existingProduct).Error
+		err := s.db.Where("LOWER(sku) = LOWER(?)", productReq.SKU).First(&existingProduct).Error
 
 		if err == nil && !req.UpdateExisting {
 			// Product exists and we're not updating
@@ -416,7 +483,7 @@ func (s *AdminProductService) BulkImportProducts(req BulkImportRequest) (*BulkIm
 
 		if err == nil && req.UpdateExisting {
 			// Update existing product
-			_, err = s.UpdateProduct(existingProduct.ID, productReq)
+			_, err = s.updateProduct(existingProduct.ID, productReq, RevisionSourceBulkImport)
 			if err != nil {
 				response.Errors = append(response.Errors, BulkImportError{
 					Index: i,
@@ -427,8 +494,54 @@ func (s *AdminProductService) BulkImportProducts(req BulkImportRequest) (*BulkIm
 			}
 			response.Updated++
 		} else {
+			// New SKU: check for a probable duplicate under a different SKU
+			duplicate, similarity, dupErr := s.findProbableDuplicate(productReq)
+			if dupErr != nil {
+				response.Errors = append(response.Errors, BulkImportError{
+					Index: i,
+					SKU:   productReq.SKU,
+					Error: dupErr.Error(),
+				})
+				continue
+			}
+			if duplicate != nil {
+				report := BulkImportDuplicate{
+					Index:            i,
+					SKU:              productReq.SKU,
+					Name:             productReq.Name,
+					MatchedProductID: duplicate.ID.String(),
+					MatchedSKU:       duplicate.SKU,
+					MatchedName:      duplicate.Name,
+					Similarity:       similarity,
+					Action:           "flagged",
+				}
+
+				switch strategy {
+				case DuplicateStrategySkip:
+					report.Action = "skipped"
+					response.Duplicates = append(response.Duplicates, report)
+					continue
+				case DuplicateStrategyMerge:
+					merged := productReq
+					merged.SKU = duplicate.SKU
+					if _, err := s.updateProduct(duplicate.ID, merged, RevisionSourceBulkImport); err != nil {
+						response.Errors = append(response.Errors, BulkImportError{
+							Index: i,
+							SKU:   productReq.SKU,
+							Error: err.Error(),
+						})
+						continue
+					}
+					report.Action = "merged"
+					response.Duplicates = append(response.Duplicates, report)
+					response.Updated++
+					continue
+				}
+				response.Duplicates = append(response.Duplicates, report)
+			}
+
 			// Create new product
-			_, err = s.CreateProduct(productReq)
+			_, err = s.createProduct(productReq, RevisionSourceBulkImport)
 			if err != nil {
 				response.Errors = append(response.Errors, BulkImportError{
 					Index: i,
@@ -444,6 +557,48 @@ func (s *AdminProductService) BulkImportProducts(req BulkImportRequest) (*BulkIm
 	return response, nil
 }
 
+// importBatchSize is how many products a background import handles between
+// progress reports
+const importBatchSize = 50
+
+// BulkImportProductsInBatches imports products like BulkImportProducts a
+// batch at a time, reporting progress when run as a background job
+func (s *AdminProductService) BulkImportProductsInBatches(ctx context.Context, req BulkImportRequest) (*BulkImportResponse, error) {
+	response := &BulkImportResponse{
+		TotalProcessed: len(req.Products),
+		Errors:         []BulkImportError{},
+		Duplicates:     []BulkImportDuplicate{},
+	}
+	for start := 0; start < len(req.Products); start += importBatchSize {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		end := start + importBatchSize
+		if end > len(req.Products) {
+			end = len(req.Products)
+		}
+
+		batch := req
+		batch.Products = req.Products[start:end]
+		result, err := s.BulkImportProducts(batch)
+		if err != nil {
+			return nil, err
+		}
+		response.Created += result.Created
+		response.Updated += result.Updated
+		for _, importErr := range result.Errors {
+			importErr.Index += start
+			response.Errors = append(response.Errors, importErr)
+		}
+		for _, duplicate := range result.Duplicates {
+			duplicate.Index += start
+			response.Duplicates = append(response.Duplicates, duplicate)
+		}
+		reportJobProgress(ctx, end, len(req.Products))
+	}
+	return response, nil
+}
+
 // ExportProducts exports products to CSV format
 func (s *AdminProductService) ExportProducts(filters ProductFilters) ([]byte, error) {
 	var products []models.Product
@@ -458,6 +613,10 @@ func (s *AdminProductService) ExportProducts(filters ProductFilters) ([]byte, er
 		query = query.Where("category_id = ?", filters.CategoryID)
 	}
 
+	if filters.BrandID != uuid.Nil {
+		query = query.Where("brand_id = ?", filters.BrandID)
+	}
+
 	if filters.Search != "" {
 		query = query.Where("name ILIKE ? OR description ILIKE ?", "%"+filters.Search+"%", "%"+filters.Search+"%")
 	}
@@ -0,0 +1,434 @@
+package services
+
+import (
+	"bytes"
+	"chat-ecommerce-backend/internal/models"
+	authmodels "chat-ecommerce-backend/internal/models/auth"
+	"chat-ecommerce-backend/pkg/listquery"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// User account statuses
+const (
+	UserStatusActive    = "active"
+	UserStatusSuspended = "suspended"
+	UserStatusDeleted   = "deleted"
+)
+
+// passwordResetTTL is how long a password reset link forced by an admin works
+const passwordResetTTL = 72 * time.Hour
+
+// maxBulkUsers caps how many users one bulk action can change
+const maxBulkUsers = 500
+
+// User management errors
+var (
+	ErrUserNotFound          = errors.New("user not found")
+	ErrPasswordResetRequired = errors.New("a password reset is required; use the reset link sent to you")
+	ErrInvalidResetToken     = errors.New("the password reset link is invalid or has expired")
+	ErrTooManyUsers          = fmt.Errorf("at most %d users can be changed at once", maxBulkUsers)
+)
+
+// AdminUserFilters selects users for the admin user list and export
+type AdminUserFilters struct {
+	Search       string     // matches email, first or last name
+	Status       string     // active, suspended or deleted
+	SignedUpFrom *time.Time // inclusive
+	SignedUpTo   *time.Time // exclusive
+	Page         int
+	Limit        int
+	SortBy       string // created_at, email, last_login_at, total_spent or order_count
+	SortOrder    string
+
+	// List is a list request's filters, sort and page. The fields above
+	// narrow it further; without it they also set the page and sort.
+	List *listquery.Query
+}
+
+// AdminUserListSchema is what the admin user list and export can be
+// filtered and sorted by. The aliases keep the original parameters working.
+var AdminUserListSchema = &listquery.Schema{
+	Filters: map[string]listquery.Field{
+		"search":            listquery.Search("users.email", "users.first_name", "users.last_name"),
+		"email":             listquery.Column("users.email", listquery.String, listquery.Eq, listquery.Contains),
+		"status":            listquery.Column("users.status", listquery.String).OneOf(UserStatusActive, UserStatusSuspended, UserStatusDeleted),
+		"email_verified":    listquery.Column("users.email_verified", listquery.Bool),
+		"customer_group_id": listquery.Column("users.customer_group_id", listquery.UUID),
+		"created_at":        listquery.Column("users.created_at", listquery.Time),
+		"last_login_at":     listquery.Column("users.last_login_at", listquery.Time),
+	},
+	Sorts: map[string]string{
+		"created_at":    "users.created_at",
+		"email":         "users.email",
+		"last_login_at": "users.last_login_at",
+		"total_spent":   "total_spent",
+		"order_count":   "order_count",
+	},
+	DefaultSort: "-created_at",
+	TieBreaker:  "users.id",
+	DefaultSize: 20,
+	MaxSize:     100,
+	Aliases: map[string]string{
+		"search":         "search",
+		"status":         "status",
+		"signed_up_from": "created_at[gte]",
+		"signed_up_to":   "created_at[lte]",
+	},
+}
+
+// list returns the filters' list query, or one paging and sorting as the
+// fields ask
+func (f AdminUserFilters) list() *listquery.Query {
+	if f.List != nil {
+		return f.List
+	}
+	return listquery.New(AdminUserListSchema).
+		SetPage(f.Page, f.Limit).
+		SortBy(f.SortBy, !strings.EqualFold(f.SortOrder, "asc"))
+}
+
+// CustomerSummary is a user with their order aggregates. Only paid orders
+// that weren't cancelled count.
+type CustomerSummary struct {
+	ID                    uuid.UUID  `json:"id"`
+	Email                 string     `json:"email"`
+	FirstName             string     `json:"first_name"`
+	LastName              string     `json:"last_name"`
+	Phone                 string     `gorm:"serializer:pii" json:"phone"`
+	Status                string     `json:"status"`
+	EmailVerified         bool       `json:"email_verified"`
+	PasswordResetRequired bool       `json:"password_reset_required"`
+	CustomerGroupID       *uuid.UUID `json:"customer_group_id"`
+	CreatedAt             time.Time  `json:"created_at"`
+	LastLoginAt           *time.Time `json:"last_login_at"`
+	OrderCount            int        `json:"order_count"`
+	TotalSpent            float64    `json:"total_spent"`
+	LastOrderAt           *time.Time `json:"last_order_at"`
+}
+
+// AdminUserListResponse is a page of users
+type AdminUserListResponse struct {
+	Users       []CustomerSummary `json:"users"`
+	Total       int64             `json:"total"`
+	Page        int               `json:"page"`
+	Limit       int               `json:"limit"`
+	TotalPages  int               `json:"total_pages"`
+	HasNext     bool              `json:"has_next"`
+	HasPrevious bool              `json:"has_previous"`
+}
+
+// BulkUserRequest is the payload for the bulk user actions
+type BulkUserRequest struct {
+	UserIDs []uuid.UUID `json:"user_ids" binding:"required,min=1"`
+}
+
+// BulkUserResult reports which users a bulk action changed
+type BulkUserResult struct {
+	Updated  []uuid.UUID `json:"updated"`
+	NotFound []uuid.UUID `json:"not_found"`
+	Skipped  []uuid.UUID `json:"skipped"` // e.g. deleted accounts
+}
+
+// PasswordResetLink is a reset link issued when an admin forces a password
+// reset, for the admin to send to the customer
+type PasswordResetLink struct {
+	UserID    uuid.UUID `json:"user_id"`
+	Email     string    `json:"email"`
+	ResetURL  string    `json:"reset_url"`
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// ForcePasswordResetResult reports the reset links issued by a bulk reset
+type ForcePasswordResetResult struct {
+	Links    []PasswordResetLink `json:"links"`
+	NotFound []uuid.UUID         `json:"not_found"`
+}
+
+// AdminUserService handles customer account management for admins
+type AdminUserService struct {
+	db *gorm.DB
+}
+
+// NewAdminUserService creates a new AdminUserService
+func NewAdminUserService(db *gorm.DB) *AdminUserService {
+	return &AdminUserService{db: db}
+}
+
+// passwordResetBaseURLFromEnv returns PASSWORD_RESET_BASE_URL, or the local storefront's reset page
+func passwordResetBaseURLFromEnv() string {
+	if baseURL := os.Getenv("PASSWORD_RESET_BASE_URL"); baseURL != "" {
+		return strings.TrimRight(baseURL, "/")
+	}
+	return "http://localhost:3000/reset-password"
+}
+
+// ListUsers returns a page of users with their order aggregates
+func (s *AdminUserService) ListUsers(ctx context.Context, filters AdminUserFilters) (*AdminUserListResponse, error) {
+	list := filters.list()
+
+	var total int64
+	if err := s.userQuery(ctx, filters).Count(&total).Error; err != nil {
+		return nil, fmt.Errorf("failed to count users: %v", err)
+	}
+
+	users := []CustomerSummary{}
+	if err := list.Paginate(s.withOrderStats(s.userQuery(ctx, filters), filters)).Scan(&users).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch users: %v", err)
+	}
+
+	page := list.PageInfo(total)
+	return &AdminUserListResponse{
+		Users:       users,
+		Total:       total,
+		Page:        page.Page,
+		Limit:       page.Size,
+		TotalPages:  page.TotalPages,
+		HasNext:     page.HasNext,
+		HasPrevious: page.HasPrevious,
+	}, nil
+}
+
+var customerCSVHeader = []string{
+	"id", "email", "first_name", "last_name", "phone", "status", "email_verified",
+	"signed_up_at", "last_login_at", "order_count", "total_spent", "last_order_at",
+}
+
+// ExportCustomersCSV exports every user matching the filters, ignoring
+// pagination, with their order aggregates
+func (s *AdminUserService) ExportCustomersCSV(ctx context.Context, filters AdminUserFilters) ([]byte, error) {
+	var users []CustomerSummary
+	if err := s.withOrderStats(s.userQuery(ctx, filters), filters).Scan(&users).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch users: %v", err)
+	}
+
+	formatTime := func(t *time.Time) string {
+		if t == nil {
+			return ""
+		}
+		return t.UTC().Format(time.RFC3339)
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write(customerCSVHeader)
+	for _, user := range users {
+		w.Write([]string{
+			user.ID.String(),
+			user.Email,
+			user.FirstName,
+			user.LastName,
+			user.Phone,
+			user.Status,
+			strconv.FormatBool(user.EmailVerified),
+			user.CreatedAt.UTC().Format(time.RFC3339),
+			formatTime(user.LastLoginAt),
+			strconv.Itoa(user.OrderCount),
+			strconv.FormatFloat(user.TotalSpent, 'f', 2, 64),
+			formatTime(user.LastOrderAt),
+		})
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, fmt.Errorf("failed to write customers CSV: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// SuspendUsers stops users from signing in and ends their current sign-ins
+func (s *AdminUserService) SuspendUsers(ctx context.Context, req BulkUserRequest) (*BulkUserResult, error) {
+	return s.setStatus(ctx, req.UserIDs, UserStatusSuspended)
+}
+
+// ReactivateUsers lets suspended users sign in again
+func (s *AdminUserService) ReactivateUsers(ctx context.Context, req BulkUserRequest) (*BulkUserResult, error) {
+	return s.setStatus(ctx, req.UserIDs, UserStatusActive)
+}
+
+// ForcePasswordReset makes users choose a new password before they can sign
+// in again, and issues each a reset link. Earlier unused links stop working
+// and current sign-ins are ended.
+func (s *AdminUserService) ForcePasswordReset(ctx context.Context, req BulkUserRequest) (*ForcePasswordResetResult, error) {
+	if len(req.UserIDs) > maxBulkUsers {
+		return nil, ErrTooManyUsers
+	}
+
+	result := &ForcePasswordResetResult{Links: []PasswordResetLink{}, NotFound: []uuid.UUID{}}
+	baseURL := passwordResetBaseURLFromEnv()
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		users, err := findUsers(tx, req.UserIDs)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		for _, id := range req.UserIDs {
+			user, ok := users[id]
+			if !ok {
+				result.NotFound = append(result.NotFound, id)
+				continue
+			}
+
+			token, err := newResetToken()
+			if err != nil {
+				return err
+			}
+			if err := tx.Model(&authmodels.PasswordResetToken{}).
+				Where("user_id = ? AND used = ?", id, false).
+				Update("used", true).Error; err != nil {
+				return fmt.Errorf("failed to expire reset links: %v", err)
+			}
+			record := authmodels.PasswordResetToken{
+				ID:        uuid.New(),
+				UserID:    id,
+				Token:     hashResetToken(token),
+				ExpiresAt: now.Add(passwordResetTTL),
+			}
+			if err := tx.Create(&record).Error; err != nil {
+				return fmt.Errorf("failed to save reset link: %v", err)
+			}
+			if err := tx.Model(&models.User{}).Where("id = ?", id).
+				Updates(map[string]interface{}{"password_reset_required": true, "updated_at": now}).Error; err != nil {
+				return fmt.Errorf("failed to flag password reset: %v", err)
+			}
+			if err := revokeUserRefreshTokens(tx, []uuid.UUID{id}); err != nil {
+				return err
+			}
+
+			result.Links = append(result.Links, PasswordResetLink{
+				UserID:    id,
+				Email:     user.Email,
+				ResetURL:  baseURL + "?token=" + token,
+				Token:     token,
+				ExpiresAt: record.ExpiresAt,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *AdminUserService) setStatus(ctx context.Context, ids []uuid.UUID, status string) (*BulkUserResult, error) {
+	if len(ids) > maxBulkUsers {
+		return nil, ErrTooManyUsers
+	}
+
+	result := &BulkUserResult{Updated: []uuid.UUID{}, NotFound: []uuid.UUID{}, Skipped: []uuid.UUID{}}
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		users, err := findUsers(tx, ids)
+		if err != nil {
+			return err
+		}
+
+		var update []uuid.UUID
+		for _, id := range ids {
+			user, ok := users[id]
+			switch {
+			case !ok:
+				result.NotFound = append(result.NotFound, id)
+			case user.Status == UserStatusDeleted:
+				result.Skipped = append(result.Skipped, id)
+			default:
+				update = append(update, id)
+			}
+		}
+		if len(update) == 0 {
+			return nil
+		}
+
+		if err := tx.Model(&models.User{}).Where("id IN ?", update).
+			Updates(map[string]interface{}{"status": status, "updated_at": time.Now()}).Error; err != nil {
+			return fmt.Errorf("failed to update users: %v", err)
+		}
+		if status == UserStatusSuspended {
+			if err := revokeUserRefreshTokens(tx, update); err != nil {
+				return err
+			}
+		}
+		result.Updated = update
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// userQuery applies the filters to the users table
+func (s *AdminUserService) userQuery(ctx context.Context, filters AdminUserFilters) *gorm.DB {
+	query := s.db.WithContext(ctx).Model(&models.User{})
+	if search := strings.ToLower(strings.TrimSpace(filters.Search)); search != "" {
+		term := "%" + search + "%"
+		query = query.Where("LOWER(users.email) LIKE ? OR LOWER(users.first_name) LIKE ? OR LOWER(users.last_name) LIKE ?", term, term, term)
+	}
+	if filters.Status != "" {
+		query = query.Where("users.status = ?", filters.Status)
+	}
+	if filters.SignedUpFrom != nil {
+		query = query.Where("users.created_at >= ?", *filters.SignedUpFrom)
+	}
+	if filters.SignedUpTo != nil {
+		query = query.Where("users.created_at < ?", *filters.SignedUpTo)
+	}
+	return filters.list().Filter(query)
+}
+
+// withOrderStats joins each user's qualifying order totals and sorts the result
+func (s *AdminUserService) withOrderStats(query *gorm.DB, filters AdminUserFilters) *gorm.DB {
+	stats := s.db.Model(&models.Order{}).
+		Select("user_id, COUNT(*) AS order_count, COALESCE(SUM(total_amount), 0) AS total_spent, MAX(created_at) AS last_order_at").
+		Where("payment_status = ? AND status <> ?", "paid", "cancelled").
+		Group("user_id")
+
+	return filters.list().Order(query.
+		Select("users.id, users.email, users.first_name, users.last_name, users.phone, users.status, "+
+			"users.email_verified, users.password_reset_required, users.customer_group_id, users.created_at, users.last_login_at, "+
+			"COALESCE(stats.order_count, 0) AS order_count, COALESCE(stats.total_spent, 0) AS total_spent, stats.last_order_at").
+		Joins("LEFT JOIN (?) AS stats ON stats.user_id = users.id", stats))
+}
+
+// findUsers loads the listed users by ID
+func findUsers(tx *gorm.DB, ids []uuid.UUID) (map[uuid.UUID]models.User, error) {
+	var users []models.User
+	if err := tx.Where("id IN ?", ids).Find(&users).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch users: %v", err)
+	}
+	byID := make(map[uuid.UUID]models.User, len(users))
+	for _, user := range users {
+		byID[user.ID] = user
+	}
+	return byID, nil
+}
+
+// newResetToken returns a random URL-safe password reset token
+func newResetToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate reset token: %v", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// hashResetToken is what's stored for a reset token, so a leaked table
+// can't be used to reset passwords
+func hashResetToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,369 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// UnmatchedRoute is the route requests that match no route are counted under,
+// so scanners probing random paths can't grow the usage table
+const UnmatchedRoute = "unmatched"
+
+// APIUsageConfig configures how request counts are kept
+type APIUsageConfig struct {
+	FlushInterval    time.Duration
+	Retention        time.Duration
+	ConsumerQuota    int64 // requests a consumer may make in a minute; 0 for none
+	TopConsumerCount int
+}
+
+// APIUsageConfigFromEnv writes counts to the database every
+// API_USAGE_FLUSH_SECONDS (30), keeps them for API_USAGE_RETENTION_HOURS (168),
+// flags consumers over API_USAGE_QUOTA_PER_MINUTE requests in a minute (0, no
+// quota) and reports the API_USAGE_TOP_CONSUMERS (20) busiest consumers
+func APIUsageConfigFromEnv() APIUsageConfig {
+	return APIUsageConfig{
+		FlushInterval:    time.Duration(envInt("API_USAGE_FLUSH_SECONDS", 30)) * time.Second,
+		Retention:        time.Duration(envInt("API_USAGE_RETENTION_HOURS", 168)) * time.Hour,
+		ConsumerQuota:    int64(envInt("API_USAGE_QUOTA_PER_MINUTE", 0)),
+		TopConsumerCount: envInt("API_USAGE_TOP_CONSUMERS", 20),
+	}
+}
+
+// apiUsageKey identifies a usage bucket
+type apiUsageKey struct {
+	windowStart time.Time
+	method      string
+	route       string
+	consumer    string
+}
+
+// APIUsageService counts requests per route and consumer in per-minute
+// buckets. Requests are summed in memory and flushed to the database, where
+// the counts of every API instance add up.
+type APIUsageService struct {
+	db     *gorm.DB
+	config APIUsageConfig
+
+	mu      sync.Mutex
+	pending map[apiUsageKey]*models.APIUsageBucket
+}
+
+// NewAPIUsageService creates a new APIUsageService
+func NewAPIUsageService(db *gorm.DB, config APIUsageConfig) *APIUsageService {
+	return &APIUsageService{
+		db:      db,
+		config:  config,
+		pending: map[apiUsageKey]*models.APIUsageBucket{},
+	}
+}
+
+// Record counts a request that finished at the given time
+func (s *APIUsageService) Record(method, route, consumer string, status int, latency time.Duration, at time.Time) {
+	if route == "" {
+		route = UnmatchedRoute
+	}
+	key := apiUsageKey{windowStart: at.UTC().Truncate(time.Minute), method: method, route: route, consumer: consumer}
+	latencyMs := float64(latency.Microseconds()) / 1000
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bucket, ok := s.pending[key]
+	if !ok {
+		bucket = &models.APIUsageBucket{WindowStart: key.windowStart, Method: method, Route: route, Consumer: consumer}
+		s.pending[key] = bucket
+	}
+	bucket.Requests++
+	switch {
+	case status >= 500:
+		bucket.ServerErrors++
+	case status >= 400:
+		bucket.ClientErrors++
+	}
+	bucket.TotalLatencyMs += latencyMs
+	if latencyMs > bucket.MaxLatencyMs {
+		bucket.MaxLatencyMs = latencyMs
+	}
+}
+
+// Flush adds the counts kept in memory to the stored buckets. Counts that
+// fail to save are put back for the next flush.
+func (s *APIUsageService) Flush(ctx context.Context) error {
+	s.mu.Lock()
+	pending := s.pending
+	s.pending = map[apiUsageKey]*models.APIUsageBucket{}
+	s.mu.Unlock()
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	buckets := make([]*models.APIUsageBucket, 0, len(pending))
+	for _, bucket := range pending {
+		bucket.ID = uuid.New()
+		bucket.UpdatedAt = now
+		buckets = append(buckets, bucket)
+	}
+
+	err := s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "window_start"}, {Name: "method"}, {Name: "route"}, {Name: "consumer"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"requests":         gorm.Expr("api_usage_buckets.requests + excluded.requests"),
+			"client_errors":    gorm.Expr("api_usage_buckets.client_errors + excluded.client_errors"),
+			"server_errors":    gorm.Expr("api_usage_buckets.server_errors + excluded.server_errors"),
+			"total_latency_ms": gorm.Expr("api_usage_buckets.total_latency_ms + excluded.total_latency_ms"),
+			"max_latency_ms":   gorm.Expr("CASE WHEN excluded.max_latency_ms > api_usage_buckets.max_latency_ms THEN excluded.max_latency_ms ELSE api_usage_buckets.max_latency_ms END"),
+			"updated_at":       now,
+		}),
+	}).CreateInBatches(buckets, 200).Error
+	if err != nil {
+		s.restore(pending)
+		return fmt.Errorf("failed to save API usage: %v", err)
+	}
+	return nil
+}
+
+// restore puts counts that failed to save back with the ones recorded since
+func (s *APIUsageService) restore(pending map[apiUsageKey]*models.APIUsageBucket) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, bucket := range pending {
+		current, ok := s.pending[key]
+		if !ok {
+			s.pending[key] = bucket
+			continue
+		}
+		current.Requests += bucket.Requests
+		current.ClientErrors += bucket.ClientErrors
+		current.ServerErrors += bucket.ServerErrors
+		current.TotalLatencyMs += bucket.TotalLatencyMs
+		if bucket.MaxLatencyMs > current.MaxLatencyMs {
+			current.MaxLatencyMs = bucket.MaxLatencyMs
+		}
+	}
+}
+
+// Prune deletes the buckets that fell out of the retention window
+func (s *APIUsageService) Prune(ctx context.Context, now time.Time) (int64, error) {
+	result := s.db.WithContext(ctx).Where("window_start < ?", now.UTC().Add(-s.config.Retention)).Delete(&models.APIUsageBucket{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to prune API usage: %v", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// ScheduleFlushes flushes the recorded counts every FlushInterval and drops
+// expired buckets, until ctx is cancelled
+func (s *APIUsageService) ScheduleFlushes(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(s.config.FlushInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				if err := s.Flush(ctx); err != nil {
+					log.Printf("Failed to flush API usage: %v", err)
+					continue
+				}
+				if _, err := s.Prune(ctx, now); err != nil {
+					log.Printf("Failed to prune API usage: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// APIUsageFilter narrows an API usage report
+type APIUsageFilter struct {
+	Since    time.Time
+	Until    time.Time
+	Route    string
+	Consumer string
+}
+
+// APIRouteUsage is the traffic of one route
+type APIRouteUsage struct {
+	Method       string  `json:"method"`
+	Route        string  `json:"route"`
+	Requests     int64   `json:"requests"`
+	ClientErrors int64   `json:"client_errors"`
+	ServerErrors int64   `json:"server_errors"`
+	ErrorRate    float64 `json:"error_rate"` // share of 5xx responses
+	AvgLatencyMs float64 `json:"avg_latency_ms"`
+	MaxLatencyMs float64 `json:"max_latency_ms"`
+}
+
+// APIConsumerUsage is the traffic of one user or anonymous address
+type APIConsumerUsage struct {
+	Consumer      string  `json:"consumer"`
+	Requests      int64   `json:"requests"`
+	ClientErrors  int64   `json:"client_errors"`
+	ServerErrors  int64   `json:"server_errors"`
+	ErrorRate     float64 `json:"error_rate"` // share of 4xx and 5xx responses, high for credential stuffing and scraping
+	PeakPerMinute int64   `json:"peak_per_minute"`
+	OverQuota     bool    `json:"over_quota"`
+}
+
+// APIUsagePoint is the traffic of one minute
+type APIUsagePoint struct {
+	Minute   time.Time `json:"minute"`
+	Requests int64     `json:"requests"`
+	Errors   int64     `json:"errors"`
+}
+
+// APIUsageReport summarizes API traffic for capacity planning and abuse detection
+type APIUsageReport struct {
+	Since          time.Time          `json:"since"`
+	Until          time.Time          `json:"until"`
+	Requests       int64              `json:"requests"`
+	ServerErrors   int64              `json:"server_errors"`
+	ErrorRate      float64            `json:"error_rate"`
+	QuotaPerMinute int64              `json:"quota_per_minute,omitempty"`
+	Routes         []APIRouteUsage    `json:"routes"`        // busiest first
+	TopConsumers   []APIConsumerUsage `json:"top_consumers"` // busiest first
+	OverQuota      []APIConsumerUsage `json:"over_quota"`
+	Timeline       []APIUsagePoint    `json:"timeline"`
+}
+
+// Usage reports the traffic between Since and Until per route, per
+// consumer and per minute. The counts kept in memory are flushed first, so
+// the report is current for this instance.
+func (s *APIUsageService) Usage(ctx context.Context, filter APIUsageFilter) (*APIUsageReport, error) {
+	if err := s.Flush(ctx); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+
+	query := s.db.WithContext(ctx).Model(&models.APIUsageBucket{}).
+		Where("window_start >= ? AND window_start < ?", filter.Since.UTC(), filter.Until.UTC())
+	if filter.Route != "" {
+		query = query.Where("route = ?", filter.Route)
+	}
+	if filter.Consumer != "" {
+		query = query.Where("consumer = ?", filter.Consumer)
+	}
+
+	var buckets []models.APIUsageBucket
+	if err := query.Order("window_start ASC").Find(&buckets).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch API usage: %v", err)
+	}
+
+	report := &APIUsageReport{
+		Since:          filter.Since,
+		Until:          filter.Until,
+		QuotaPerMinute: s.config.ConsumerQuota,
+		Routes:         []APIRouteUsage{},
+		TopConsumers:   []APIConsumerUsage{},
+		OverQuota:      []APIConsumerUsage{},
+		Timeline:       []APIUsagePoint{},
+	}
+
+	routes := map[string]*APIRouteUsage{}
+	consumers := map[string]*APIConsumerUsage{}
+	perMinute := map[string]map[time.Time]int64{}
+	var timeline []*APIUsagePoint
+	for _, bucket := range buckets {
+		report.Requests += bucket.Requests
+		report.ServerErrors += bucket.ServerErrors
+
+		routeKey := bucket.Method + " " + bucket.Route
+		route, ok := routes[routeKey]
+		if !ok {
+			route = &APIRouteUsage{Method: bucket.Method, Route: bucket.Route}
+			routes[routeKey] = route
+		}
+		route.Requests += bucket.Requests
+		route.ClientErrors += bucket.ClientErrors
+		route.ServerErrors += bucket.ServerErrors
+		route.AvgLatencyMs += bucket.TotalLatencyMs // divided by the requests below
+		if bucket.MaxLatencyMs > route.MaxLatencyMs {
+			route.MaxLatencyMs = bucket.MaxLatencyMs
+		}
+
+		consumer, ok := consumers[bucket.Consumer]
+		if !ok {
+			consumer = &APIConsumerUsage{Consumer: bucket.Consumer}
+			consumers[bucket.Consumer] = consumer
+			perMinute[bucket.Consumer] = map[time.Time]int64{}
+		}
+		consumer.Requests += bucket.Requests
+		consumer.ClientErrors += bucket.ClientErrors
+		consumer.ServerErrors += bucket.ServerErrors
+		perMinute[bucket.Consumer][bucket.WindowStart] += bucket.Requests
+
+		if len(timeline) == 0 || !timeline[len(timeline)-1].Minute.Equal(bucket.WindowStart) {
+			timeline = append(timeline, &APIUsagePoint{Minute: bucket.WindowStart})
+		}
+		timeline[len(timeline)-1].Requests += bucket.Requests
+		timeline[len(timeline)-1].Errors += bucket.ServerErrors
+	}
+	report.ErrorRate = usageShare(report.ServerErrors, report.Requests)
+
+	for _, route := range routes {
+		route.ErrorRate = usageShare(route.ServerErrors, route.Requests)
+		if route.Requests > 0 {
+			route.AvgLatencyMs = roundCents(route.AvgLatencyMs / float64(route.Requests))
+		}
+		report.Routes = append(report.Routes, *route)
+	}
+	sort.Slice(report.Routes, func(i, j int) bool {
+		a, b := report.Routes[i], report.Routes[j]
+		if a.Requests != b.Requests {
+			return a.Requests > b.Requests
+		}
+		return a.Method+" "+a.Route < b.Method+" "+b.Route
+	})
+
+	var all []APIConsumerUsage
+	for name, consumer := range consumers {
+		consumer.ErrorRate = usageShare(consumer.ClientErrors+consumer.ServerErrors, consumer.Requests)
+		for _, count := range perMinute[name] {
+			if count > consumer.PeakPerMinute {
+				consumer.PeakPerMinute = count
+			}
+		}
+		consumer.OverQuota = s.config.ConsumerQuota > 0 && consumer.PeakPerMinute > s.config.ConsumerQuota
+		all = append(all, *consumer)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].Requests != all[j].Requests {
+			return all[i].Requests > all[j].Requests
+		}
+		return all[i].Consumer < all[j].Consumer
+	})
+	for i, consumer := range all {
+		if i < s.config.TopConsumerCount || s.config.TopConsumerCount <= 0 {
+			report.TopConsumers = append(report.TopConsumers, consumer)
+		}
+		if consumer.OverQuota {
+			report.OverQuota = append(report.OverQuota, consumer)
+		}
+	}
+
+	for _, point := range timeline {
+		report.Timeline = append(report.Timeline, *point)
+	}
+	return report, nil
+}
+
+// usageShare is part over total, rounded to four places
+func usageShare(part, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(int64(float64(part)/float64(total)*10000+0.5)) / 10000
+}
@@ -0,0 +1,394 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/pkg/listquery"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// JobProgressMessage is the WebSocket message type of background job progress
+const JobProgressMessage = "job_progress"
+
+// Background job kinds
+const (
+	JobKindProductImport = "product_import"
+	JobKindProductExport = "product_export"
+	JobKindBulkPrice     = "bulk_price"
+	JobKindCampaignSend  = "campaign_send"
+	JobKindChatPurge     = "chat_purge"
+)
+
+// Background job statuses
+const (
+	JobStatusQueued    = "queued"
+	JobStatusRunning   = "running"
+	JobStatusSucceeded = "succeeded"
+	JobStatusFailed    = "failed"
+)
+
+// Background job errors
+var (
+	ErrJobNotFound    = errors.New("job not found")
+	ErrJobNoArtifact  = errors.New("job has no file to download")
+	ErrJobNotFinished = errors.New("job hasn't finished yet")
+)
+
+// UserNotifier sends server-initiated messages to every open connection of
+// a signed in user
+type UserNotifier interface {
+	NotifyUser(userID uuid.UUID, messageType string, data interface{})
+}
+
+// JobConfig controls how many background jobs run at once
+type JobConfig struct {
+	Workers int
+}
+
+// JobConfigFromEnv reads JOB_WORKERS (default 2)
+func JobConfigFromEnv() JobConfig {
+	config := JobConfig{Workers: envInt("JOB_WORKERS", 2)}
+	if config.Workers <= 0 {
+		config.Workers = 2
+	}
+	return config
+}
+
+// JobListSchema is what the job list can be filtered and sorted by
+var JobListSchema = &listquery.Schema{
+	Filters: map[string]listquery.Field{
+		"kind": listquery.Column("kind", listquery.String).OneOf(JobKindProductImport, JobKindProductExport,
+			JobKindBulkPrice, JobKindCampaignSend, JobKindChatPurge),
+		"status":     listquery.Column("status", listquery.String).OneOf(JobStatusQueued, JobStatusRunning, JobStatusSucceeded, JobStatusFailed),
+		"created_by": listquery.Column("created_by", listquery.UUID),
+		"created_at": listquery.Column("created_at", listquery.Time),
+	},
+	Sorts: map[string]string{
+		"created_at": "created_at",
+	},
+	DefaultSort: "-created_at",
+	TieBreaker:  "id",
+	DefaultSize: 20,
+	MaxSize:     100,
+	Aliases: map[string]string{
+		"kind":   "kind",
+		"status": "status",
+	},
+}
+
+// JobOutput is what a job's work produced: a result shown with the job and
+// optionally a file to download
+type JobOutput struct {
+	Result       interface{}
+	Artifact     []byte
+	ArtifactName string
+	ArtifactType string
+}
+
+// JobWork does a job's work, reporting progress with reportJobProgress on ctx
+type JobWork func(ctx context.Context) (*JobOutput, error)
+
+// JobStatus is a background job as the API shows it
+type JobStatus struct {
+	models.AsyncJob
+	ArtifactURL string `json:"artifact_url,omitempty"`
+}
+
+// JobService runs long admin operations in the background, recording their
+// progress and results and telling the admin who started them as they go
+type JobService struct {
+	db       *gorm.DB
+	notifier UserNotifier
+	slots    chan struct{}
+	running  sync.WaitGroup
+}
+
+// NewJobService creates a new JobService
+func NewJobService(db *gorm.DB, config JobConfig) *JobService {
+	if config.Workers <= 0 {
+		config.Workers = 1
+	}
+	return &JobService{
+		db:    db,
+		slots: make(chan struct{}, config.Workers),
+	}
+}
+
+// WithNotifier sends progress events to the admin who started each job
+func (s *JobService) WithNotifier(notifier UserNotifier) *JobService {
+	s.notifier = notifier
+	return s
+}
+
+// Start records a job and runs its work in the background once a worker is
+// free. The job is returned queued; its progress can be followed with GetJob
+// or over the chat socket of the admin who started it.
+func (s *JobService) Start(ctx context.Context, kind string, createdBy *uuid.UUID, params interface{}, work JobWork) (*JobStatus, error) {
+	job := &models.AsyncJob{
+		ID:        uuid.New(),
+		Kind:      kind,
+		Status:    JobStatusQueued,
+		CreatedBy: createdBy,
+	}
+	if params != nil {
+		encoded, err := json.Marshal(params)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode job parameters: %v", err)
+		}
+		job.Params = datatypes.JSON(encoded)
+	}
+	if err := s.db.WithContext(ctx).Create(job).Error; err != nil {
+		return nil, fmt.Errorf("failed to create job: %v", err)
+	}
+
+	s.running.Add(1)
+	go s.run(*job, work)
+	return jobStatus(*job), nil
+}
+
+// Wait blocks until every job started so far has finished
+func (s *JobService) Wait() {
+	s.running.Wait()
+}
+
+// GetJob returns a job's status and result
+func (s *JobService) GetJob(ctx context.Context, jobID uuid.UUID) (*JobStatus, error) {
+	var job models.AsyncJob
+	if err := s.db.WithContext(ctx).Omit("artifact").Where("id = ?", jobID).First(&job).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrJobNotFound
+		}
+		return nil, fmt.Errorf("failed to fetch job: %v", err)
+	}
+	return jobStatus(job), nil
+}
+
+// ListJobs returns a page of jobs, newest first unless the list asks
+// otherwise, and how many match
+func (s *JobService) ListJobs(ctx context.Context, list *listquery.Query) ([]JobStatus, int64, error) {
+	query := list.Filter(s.db.WithContext(ctx).Model(&models.AsyncJob{}))
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count jobs: %v", err)
+	}
+
+	var jobs []models.AsyncJob
+	if err := list.Paginate(list.Order(query.Omit("artifact"))).Find(&jobs).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to fetch jobs: %v", err)
+	}
+	statuses := make([]JobStatus, len(jobs))
+	for i, job := range jobs {
+		statuses[i] = *jobStatus(job)
+	}
+	return statuses, total, nil
+}
+
+// Artifact returns a finished job with the file it produced
+func (s *JobService) Artifact(ctx context.Context, jobID uuid.UUID) (*models.AsyncJob, error) {
+	var job models.AsyncJob
+	if err := s.db.WithContext(ctx).Where("id = ?", jobID).First(&job).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrJobNotFound
+		}
+		return nil, fmt.Errorf("failed to fetch job: %v", err)
+	}
+	if job.Status == JobStatusQueued || job.Status == JobStatusRunning {
+		return nil, ErrJobNotFinished
+	}
+	if job.ArtifactName == "" {
+		return nil, ErrJobNoArtifact
+	}
+	return &job, nil
+}
+
+// FailInterrupted marks the jobs a previous run of the server left queued or
+// running as failed, since nothing will finish them. Call it at startup,
+// before any job is started.
+func (s *JobService) FailInterrupted(ctx context.Context, now time.Time) (int64, error) {
+	result := s.db.WithContext(ctx).Model(&models.AsyncJob{}).
+		Where("status IN ?", []string{JobStatusQueued, JobStatusRunning}).
+		Updates(map[string]interface{}{
+			"status":      JobStatusFailed,
+			"error":       "interrupted by a server restart",
+			"finished_at": now,
+		})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to fail interrupted jobs: %v", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// run waits for a free worker, then does a job's work and records how it went
+func (s *JobService) run(job models.AsyncJob, work JobWork) {
+	defer s.running.Done()
+	s.slots <- struct{}{}
+	defer func() { <-s.slots }()
+
+	ctx := context.Background()
+	started := time.Now()
+	job.Status = JobStatusRunning
+	job.StartedAt = &started
+	s.save(ctx, &job, map[string]interface{}{"status": job.Status, "started_at": started})
+
+	tracker := s.track(&job)
+	output, err := doJobWork(withJobTracker(ctx, tracker), work)
+	tracker.stop()
+
+	finished := time.Now()
+	job.FinishedAt = &finished
+	updates := map[string]interface{}{"finished_at": finished}
+	if err == nil && output != nil && output.Result != nil {
+		encoded, encodeErr := json.Marshal(output.Result)
+		if encodeErr != nil {
+			err = fmt.Errorf("failed to encode job result: %v", encodeErr)
+		} else {
+			job.Result = datatypes.JSON(encoded)
+			updates["result"] = job.Result
+		}
+	}
+	if err != nil {
+		job.Status = JobStatusFailed
+		job.Error = err.Error()
+		updates["error"] = job.Error
+	} else {
+		job.Status = JobStatusSucceeded
+		job.Progress = 100
+		if job.Total > 0 {
+			job.Processed = job.Total
+		}
+		updates["progress"] = job.Progress
+		updates["processed"] = job.Processed
+		if output != nil && output.ArtifactName != "" {
+			job.ArtifactName = output.ArtifactName
+			job.ArtifactType = output.ArtifactType
+			updates["artifact"] = output.Artifact
+			updates["artifact_name"] = job.ArtifactName
+			updates["artifact_type"] = job.ArtifactType
+		}
+	}
+	updates["status"] = job.Status
+	s.save(ctx, &job, updates)
+}
+
+// doJobWork runs a job's work, turning a panic into the job failing
+func doJobWork(ctx context.Context, work JobWork) (output *JobOutput, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+	return work(ctx)
+}
+
+// save records a change to a running job and tells the admin who started it
+func (s *JobService) save(ctx context.Context, job *models.AsyncJob, updates map[string]interface{}) {
+	if err := s.db.WithContext(ctx).Model(&models.AsyncJob{}).Where("id = ?", job.ID).Updates(updates).Error; err != nil {
+		log.Printf("Failed to update job %s: %v", job.ID, err)
+	}
+	if s.notifier != nil && job.CreatedBy != nil {
+		s.notifier.NotifyUser(*job.CreatedBy, JobProgressMessage, jobStatus(*job))
+	}
+}
+
+func jobStatus(job models.AsyncJob) *JobStatus {
+	status := &JobStatus{AsyncJob: job}
+	if job.ArtifactName != "" && job.Status == JobStatusSucceeded {
+		status.ArtifactURL = fmt.Sprintf("/api/v1/admin/jobs/%s/artifact", job.ID)
+	}
+	return status
+}
+
+// jobTracker records the progress of the job running in a context. Progress
+// is sent straight away but saved in the background, so work reporting it
+// from inside a transaction doesn't wait on another connection.
+type jobTracker struct {
+	service *JobService
+	mu      sync.Mutex
+	job     *models.AsyncJob
+	dirty   chan struct{}
+	done    chan struct{}
+}
+
+type jobTrackerKey struct{}
+
+func (s *JobService) track(job *models.AsyncJob) *jobTracker {
+	tracker := &jobTracker{
+		service: s,
+		job:     job,
+		dirty:   make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+	go tracker.flush()
+	return tracker
+}
+
+// flush saves the latest progress each time it changes until stop
+func (t *jobTracker) flush() {
+	defer close(t.done)
+	for range t.dirty {
+		t.mu.Lock()
+		jobID := t.job.ID
+		updates := map[string]interface{}{
+			"progress":  t.job.Progress,
+			"processed": t.job.Processed,
+			"total":     t.job.Total,
+		}
+		t.mu.Unlock()
+		if err := t.service.db.Model(&models.AsyncJob{}).Where("id = ?", jobID).Updates(updates).Error; err != nil {
+			log.Printf("Failed to save progress of job %s: %v", jobID, err)
+		}
+	}
+}
+
+// stop waits for the last progress to be saved
+func (t *jobTracker) stop() {
+	close(t.dirty)
+	<-t.done
+}
+
+func withJobTracker(ctx context.Context, tracker *jobTracker) context.Context {
+	return context.WithValue(ctx, jobTrackerKey{}, tracker)
+}
+
+// reportJobProgress records that done of total items of the job running in
+// ctx have been processed. Progress is sent to the admin who started the job
+// and saved each time the percent done changes; outside a job it does
+// nothing.
+func reportJobProgress(ctx context.Context, done, total int) {
+	tracker, ok := ctx.Value(jobTrackerKey{}).(*jobTracker)
+	if !ok || total <= 0 {
+		return
+	}
+	if done > total {
+		done = total
+	}
+
+	tracker.mu.Lock()
+	job := tracker.job
+	percent := done * 100 / total
+	changed := percent != job.Progress || total != job.Total
+	job.Progress, job.Processed, job.Total = percent, done, total
+	snapshot := *job
+	tracker.mu.Unlock()
+	if !changed {
+		return
+	}
+
+	select {
+	case tracker.dirty <- struct{}{}:
+	default: // a save is already pending and will pick this up
+	}
+	if notifier := tracker.service.notifier; notifier != nil && snapshot.CreatedBy != nil {
+		notifier.NotifyUser(*snapshot.CreatedBy, JobProgressMessage, jobStatus(snapshot))
+	}
+}
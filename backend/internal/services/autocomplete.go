@@ -0,0 +1,295 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Autocomplete suggestion kinds
+const (
+	SuggestionProduct  = "product"
+	SuggestionCategory = "category"
+	SuggestionQuery    = "query"
+)
+
+const (
+	// maxTrieNodeEntries caps the suggestions kept per prefix, so a lookup never
+	// walks more than this many entries however short the prefix is
+	maxTrieNodeEntries = 20
+	// popularQueryWindow is how far back search analytics count towards popular queries
+	popularQueryWindow = 30 * 24 * time.Hour
+	// maxPopularQueries is how many popular queries the index holds
+	maxPopularQueries = 1000
+)
+
+// AutocompleteSuggestion is a single type-ahead suggestion
+type AutocompleteSuggestion struct {
+	Kind string     `json:"kind"`
+	Text string     `json:"text"`
+	ID   *uuid.UUID `json:"id,omitempty"`
+	Slug string     `json:"slug,omitempty"`
+}
+
+// AutocompleteResult groups the suggestions for a prefix by kind
+type AutocompleteResult struct {
+	Query      string                   `json:"query"`
+	Products   []AutocompleteSuggestion `json:"products"`
+	Categories []AutocompleteSuggestion `json:"categories"`
+	Queries    []AutocompleteSuggestion `json:"queries"`
+}
+
+// AutocompleteRefreshIntervalFromEnv reads AUTOCOMPLETE_REFRESH_SECONDS (default 5 minutes)
+func AutocompleteRefreshIntervalFromEnv() time.Duration {
+	return time.Duration(envInt("AUTOCOMPLETE_REFRESH_SECONDS", 300)) * time.Second
+}
+
+// AutocompleteIndex answers type-ahead lookups from in-memory prefix tries of
+// product names, category names and popular search queries. Lookups never
+// touch the database; the tries are rebuilt by Refresh and swapped in whole.
+type AutocompleteIndex struct {
+	db *gorm.DB
+
+	mu         sync.RWMutex
+	products   *prefixTrie
+	categories *prefixTrie
+	queries    *prefixTrie
+}
+
+// NewAutocompleteIndex creates an empty index. Call Refresh or ScheduleRefresh to fill it.
+func NewAutocompleteIndex(db *gorm.DB) *AutocompleteIndex {
+	return &AutocompleteIndex{
+		db:         db,
+		products:   newPrefixTrie(),
+		categories: newPrefixTrie(),
+		queries:    newPrefixTrie(),
+	}
+}
+
+// Complete returns up to limit suggestions of each kind whose words start with prefix
+func (idx *AutocompleteIndex) Complete(prefix string, limit int) *AutocompleteResult {
+	key := normalizeSuggestion(prefix)
+	result := &AutocompleteResult{
+		Query:      prefix,
+		Products:   []AutocompleteSuggestion{},
+		Categories: []AutocompleteSuggestion{},
+		Queries:    []AutocompleteSuggestion{},
+	}
+	if key == "" || limit <= 0 {
+		return result
+	}
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	result.Products = idx.products.lookup(key, limit)
+	result.Categories = idx.categories.lookup(key, limit)
+	result.Queries = idx.queries.lookup(key, limit)
+	return result
+}
+
+// Refresh rebuilds the index from active products, active categories and the
+// last 30 days of successful searches
+func (idx *AutocompleteIndex) Refresh(ctx context.Context) error {
+	db := idx.db.WithContext(ctx)
+
+	var products []struct {
+		ID         uuid.UUID
+		Name       string
+		Popularity int
+	}
+	if err := db.Model(&models.Product{}).Scopes(publishedAt(time.Now())).
+		Select("id, name, popularity").Scan(&products).Error; err != nil {
+		return fmt.Errorf("failed to fetch products for autocomplete: %v", err)
+	}
+	productTrie := newPrefixTrie()
+	for _, product := range products {
+		id := product.ID
+		productTrie.add(AutocompleteSuggestion{Kind: SuggestionProduct, Text: product.Name, ID: &id}, float64(product.Popularity))
+	}
+
+	var categories []models.Category
+	if err := db.Where("is_active = ?", true).Find(&categories).Error; err != nil {
+		return fmt.Errorf("failed to fetch categories for autocomplete: %v", err)
+	}
+	var counts []struct {
+		CategoryID uuid.UUID
+		Count      int
+	}
+	if err := db.Model(&models.Product{}).Scopes(publishedAt(time.Now())).
+		Select("category_id, COUNT(*) AS count").Group("category_id").Scan(&counts).Error; err != nil {
+		return fmt.Errorf("failed to count category products: %v", err)
+	}
+	productCounts := make(map[uuid.UUID]int, len(counts))
+	for _, count := range counts {
+		productCounts[count.CategoryID] = count.Count
+	}
+	categoryTrie := newPrefixTrie()
+	for _, category := range categories {
+		id := category.ID
+		categoryTrie.add(AutocompleteSuggestion{Kind: SuggestionCategory, Text: category.Name, ID: &id, Slug: category.Slug}, float64(productCounts[category.ID]))
+	}
+
+	// Search analytics come from the SQL migrations and may be missing
+	queryTrie := newPrefixTrie()
+	if db.Migrator().HasTable("search_analytics") {
+		var queries []struct {
+			Query string
+			Count int
+		}
+		if err := db.Table("search_analytics").
+			Select("LOWER(query) AS query, COUNT(*) AS count").
+			Where("result_count > 0 AND created_at > ?", time.Now().Add(-popularQueryWindow)).
+			Group("LOWER(query)").
+			Order("count DESC").
+			Limit(maxPopularQueries).
+			Scan(&queries).Error; err != nil {
+			return fmt.Errorf("failed to fetch popular queries: %v", err)
+		}
+		for _, query := range queries {
+			queryTrie.add(AutocompleteSuggestion{Kind: SuggestionQuery, Text: strings.TrimSpace(query.Query)}, float64(query.Count))
+		}
+	}
+
+	productTrie.finish()
+	categoryTrie.finish()
+	queryTrie.finish()
+
+	idx.mu.Lock()
+	idx.products, idx.categories, idx.queries = productTrie, categoryTrie, queryTrie
+	idx.mu.Unlock()
+	return nil
+}
+
+// ScheduleRefresh builds the index right away and rebuilds it every interval
+func (idx *AutocompleteIndex) ScheduleRefresh(ctx context.Context, interval time.Duration) {
+	go func() {
+		if err := idx.Refresh(ctx); err != nil {
+			log.Printf("Failed to build autocomplete index: %v", err)
+		}
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := idx.Refresh(ctx); err != nil {
+					log.Printf("Failed to refresh autocomplete index: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// prefixTrie maps every word-start prefix of its suggestions to the best
+// scoring suggestions under it, so "mu" finds "Ceramic Mug"
+type prefixTrie struct {
+	root        *trieNode
+	suggestions []AutocompleteSuggestion
+	scores      []float64
+}
+
+type trieNode struct {
+	children map[rune]*trieNode
+	entries  []int // indexes into suggestions, best first once finished
+}
+
+func newPrefixTrie() *prefixTrie {
+	return &prefixTrie{root: &trieNode{children: map[rune]*trieNode{}}}
+}
+
+func (t *prefixTrie) add(suggestion AutocompleteSuggestion, score float64) {
+	text := normalizeSuggestion(suggestion.Text)
+	if text == "" {
+		return
+	}
+	entry := len(t.suggestions)
+	t.suggestions = append(t.suggestions, suggestion)
+	t.scores = append(t.scores, score)
+
+	// Index the whole text from each word onwards
+	words := strings.Fields(text)
+	for i := range words {
+		node := t.root
+		for _, r := range strings.Join(words[i:], " ") {
+			child, ok := node.children[r]
+			if !ok {
+				child = &trieNode{children: map[rune]*trieNode{}}
+				node.children[r] = child
+			}
+			node = child
+			// Entries are added one at a time, so a repeat can only be the last one
+			if n := len(node.entries); n == 0 || node.entries[n-1] != entry {
+				node.entries = append(node.entries, entry)
+			}
+		}
+	}
+}
+
+// finish orders every node's entries by score and drops all but the best
+func (t *prefixTrie) finish() {
+	nodes := []*trieNode{t.root}
+	for len(nodes) > 0 {
+		node := nodes[len(nodes)-1]
+		nodes = nodes[:len(nodes)-1]
+
+		entries := node.entries
+		sort.SliceStable(entries, func(i, j int) bool {
+			a, b := entries[i], entries[j]
+			if t.scores[a] != t.scores[b] {
+				return t.scores[a] > t.scores[b]
+			}
+			return t.suggestions[a].Text < t.suggestions[b].Text
+		})
+		if len(entries) > maxTrieNodeEntries {
+			node.entries = entries[:maxTrieNodeEntries]
+		}
+
+		for _, child := range node.children {
+			nodes = append(nodes, child)
+		}
+	}
+}
+
+func (t *prefixTrie) lookup(prefix string, limit int) []AutocompleteSuggestion {
+	node := t.root
+	for _, r := range prefix {
+		child, ok := node.children[r]
+		if !ok {
+			return []AutocompleteSuggestion{}
+		}
+		node = child
+	}
+
+	suggestions := []AutocompleteSuggestion{}
+	seen := make(map[string]bool)
+	for _, entry := range node.entries {
+		if len(suggestions) == limit {
+			break
+		}
+		// Products often share a name across variants of the catalog; suggest it once
+		suggestion := t.suggestions[entry]
+		key := normalizeSuggestion(suggestion.Text)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		suggestions = append(suggestions, suggestion)
+	}
+	return suggestions
+}
+
+// normalizeSuggestion lower-cases text and collapses its whitespace
+func normalizeSuggestion(text string) string {
+	return strings.Join(strings.Fields(strings.ToLower(text)), " ")
+}
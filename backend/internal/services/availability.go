@@ -0,0 +1,157 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Product availability statuses
+const (
+	AvailabilityInStock    = "in_stock"
+	AvailabilityLowStock   = "low_stock"
+	AvailabilityOutOfStock = "out_of_stock"
+)
+
+// ProductAvailability is a product's stock status without the rest of the product
+type ProductAvailability struct {
+	ProductID uuid.UUID `json:"product_id"`
+	Status    string    `json:"status"`
+	InStock   bool      `json:"in_stock"`
+	LowStock  bool      `json:"low_stock"`
+}
+
+// AvailabilityCacheTTLFromEnv reads AVAILABILITY_CACHE_SECONDS (default 15 seconds)
+func AvailabilityCacheTTLFromEnv() time.Duration {
+	return time.Duration(envInt("AVAILABILITY_CACHE_SECONDS", 15)) * time.Second
+}
+
+type cachedAvailability struct {
+	availability ProductAvailability
+	expiresAt    time.Time
+}
+
+// AvailabilityService answers batch stock status lookups for storefront
+// polling. Statuses are cached per product for a short TTL so product grids
+// refreshing every few seconds only reach the database for expired entries.
+type AvailabilityService struct {
+	db  *gorm.DB
+	ttl time.Duration
+
+	mu      sync.RWMutex
+	entries map[uuid.UUID]cachedAvailability
+}
+
+// NewAvailabilityService creates a new AvailabilityService
+func NewAvailabilityService(db *gorm.DB, ttl time.Duration) *AvailabilityService {
+	return &AvailabilityService{
+		db:      db,
+		ttl:     ttl,
+		entries: make(map[uuid.UUID]cachedAvailability),
+	}
+}
+
+// GetAvailability returns the stock status of the given products, in the
+// order asked for. Unknown and unpublished products are left out.
+func (s *AvailabilityService) GetAvailability(ctx context.Context, ids []uuid.UUID) ([]ProductAvailability, error) {
+	now := time.Now()
+	found := make(map[uuid.UUID]ProductAvailability, len(ids))
+	var missing []uuid.UUID
+
+	s.mu.RLock()
+	for _, id := range ids {
+		if entry, ok := s.entries[id]; ok && now.Before(entry.expiresAt) {
+			found[id] = entry.availability
+		} else {
+			missing = append(missing, id)
+		}
+	}
+	s.mu.RUnlock()
+
+	if len(missing) > 0 {
+		loaded, err := s.load(ctx, missing, now)
+		if err != nil {
+			return nil, err
+		}
+
+		s.mu.Lock()
+		for id, entry := range s.entries {
+			if !now.Before(entry.expiresAt) {
+				delete(s.entries, id)
+			}
+		}
+		for _, availability := range loaded {
+			s.entries[availability.ProductID] = cachedAvailability{availability: availability, expiresAt: now.Add(s.ttl)}
+			found[availability.ProductID] = availability
+		}
+		s.mu.Unlock()
+	}
+
+	result := make([]ProductAvailability, 0, len(found))
+	seen := make(map[uuid.UUID]bool, len(ids))
+	for _, id := range ids {
+		if availability, ok := found[id]; ok && !seen[id] {
+			seen[id] = true
+			result = append(result, availability)
+		}
+	}
+	return result, nil
+}
+
+// load reads the stock status of published products from their inventory.
+// Products without inventory records aren't tracked and are always in stock.
+func (s *AvailabilityService) load(ctx context.Context, ids []uuid.UUID, now time.Time) ([]ProductAvailability, error) {
+	db := s.db.WithContext(ctx)
+
+	var productIDs []uuid.UUID
+	if err := db.Model(&models.Product{}).Scopes(publishedAt(now)).Where("id IN ?", ids).Pluck("id", &productIDs).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch products: %v", err)
+	}
+	if len(productIDs) == 0 {
+		return nil, nil
+	}
+
+	var rows []struct {
+		ProductID uuid.UUID
+		Available int
+		Threshold int
+	}
+	if err := db.Model(&models.Inventory{}).
+		Select("product_id, SUM(quantity_available - quantity_reserved) AS available, MAX(low_stock_threshold) AS threshold").
+		Where("product_id IN ?", productIDs).
+		Group("product_id").
+		Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch stock levels: %v", err)
+	}
+	stock := make(map[uuid.UUID]int, len(rows))
+	thresholds := make(map[uuid.UUID]int, len(rows))
+	for _, row := range rows {
+		stock[row.ProductID] = row.Available
+		thresholds[row.ProductID] = row.Threshold
+	}
+
+	availability := make([]ProductAvailability, len(productIDs))
+	for i, id := range productIDs {
+		status := AvailabilityInStock
+		if available, tracked := stock[id]; tracked {
+			switch {
+			case available <= 0:
+				status = AvailabilityOutOfStock
+			case available <= thresholds[id]:
+				status = AvailabilityLowStock
+			}
+		}
+		availability[i] = ProductAvailability{
+			ProductID: id,
+			Status:    status,
+			InStock:   status != AvailabilityOutOfStock,
+			LowStock:  status == AvailabilityLowStock,
+		}
+	}
+	return availability, nil
+}
@@ -0,0 +1,295 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// brandCacheTTL is how long the brand names used to spot brands in queries
+// are kept in memory; admin changes reload them at once
+const brandCacheTTL = time.Minute
+
+// Brand errors
+var (
+	ErrBrandNotFound = errors.New("brand not found")
+	ErrBrandExists   = errors.New("a brand with this name or slug already exists")
+)
+
+// BrandRequest is the payload for creating or updating a brand
+type BrandRequest struct {
+	Name        string `json:"name" binding:"required"`
+	Slug        string `json:"slug"` // generated from the name when empty
+	Description string `json:"description"`
+	LogoURL     string `json:"logo_url"`
+	IsActive    *bool  `json:"is_active"`
+}
+
+// BrandListing is a brand with the number of products it has on sale
+type BrandListing struct {
+	models.Brand
+	ProductCount int64 `json:"product_count"`
+}
+
+// BrandService manages brands and recognizes brand names in search queries
+// and chat messages
+type BrandService struct {
+	db *gorm.DB
+
+	mu       sync.RWMutex
+	loadedAt time.Time
+	brands   []models.Brand // active brands, longest name first
+}
+
+// NewBrandService creates a new BrandService
+func NewBrandService(db *gorm.DB) *BrandService {
+	return &BrandService{db: db}
+}
+
+// ListBrands returns the active brands with their published product counts,
+// or every brand for admins
+func (s *BrandService) ListBrands(ctx context.Context, includeInactive bool) ([]BrandListing, error) {
+	db := s.db.WithContext(ctx)
+
+	query := db.Order("name ASC")
+	if !includeInactive {
+		query = query.Where("is_active = ?", true)
+	}
+	var brands []models.Brand
+	if err := query.Find(&brands).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch brands: %v", err)
+	}
+
+	var counts []struct {
+		BrandID uuid.UUID
+		Count   int64
+	}
+	if err := db.Model(&models.Product{}).
+		Scopes(publishedAt(time.Now())).
+		Select("brand_id, COUNT(*) AS count").
+		Where("brand_id IS NOT NULL").
+		Group("brand_id").
+		Scan(&counts).Error; err != nil {
+		return nil, fmt.Errorf("failed to count brand products: %v", err)
+	}
+	byBrand := make(map[uuid.UUID]int64, len(counts))
+	for _, count := range counts {
+		byBrand[count.BrandID] = count.Count
+	}
+
+	listings := make([]BrandListing, len(brands))
+	for i, brand := range brands {
+		listings[i] = BrandListing{Brand: brand, ProductCount: byBrand[brand.ID]}
+	}
+	return listings, nil
+}
+
+// GetBrandBySlug returns an active brand
+func (s *BrandService) GetBrandBySlug(ctx context.Context, slug string) (*models.Brand, error) {
+	var brand models.Brand
+	if err := s.db.WithContext(ctx).Where("slug = ? AND is_active = ?", slug, true).First(&brand).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrBrandNotFound
+		}
+		return nil, fmt.Errorf("failed to fetch brand: %v", err)
+	}
+	return &brand, nil
+}
+
+// DetectBrand finds an active brand named in a query or chat message, as
+// whole words, and returns it with the rest of the text. The longest name
+// wins so "Sony Ericsson" isn't read as "Sony". Lookup failures are logged
+// and treated as no brand.
+func (s *BrandService) DetectBrand(ctx context.Context, text string) (*models.Brand, string) {
+	brands, err := s.load(ctx)
+	if err != nil {
+		log.Printf("Failed to load brands: %v", err)
+		return nil, text
+	}
+
+	padded := " " + wordPadded(text) + " "
+	for i := range brands {
+		name := wordPadded(brands[i].Name)
+		if name == "" {
+			continue
+		}
+		if index := strings.Index(padded, " "+name+" "); index >= 0 {
+			rest := padded[:index] + padded[index+len(name)+1:]
+			return &brands[i], strings.Join(strings.Fields(rest), " ")
+		}
+	}
+	return nil, text
+}
+
+func (s *BrandService) load(ctx context.Context) ([]models.Brand, error) {
+	s.mu.RLock()
+	if s.brands != nil && time.Since(s.loadedAt) < brandCacheTTL {
+		brands := s.brands
+		s.mu.RUnlock()
+		return brands, nil
+	}
+	s.mu.RUnlock()
+
+	var brands []models.Brand
+	if err := s.db.WithContext(ctx).Where("is_active = ?", true).Find(&brands).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch brands: %v", err)
+	}
+	for i := 1; i < len(brands); i++ {
+		for j := i; j > 0 && len(brands[j].Name) > len(brands[j-1].Name); j-- {
+			brands[j], brands[j-1] = brands[j-1], brands[j]
+		}
+	}
+
+	s.mu.Lock()
+	s.brands = brands
+	s.loadedAt = time.Now()
+	s.mu.Unlock()
+	return brands, nil
+}
+
+func (s *BrandService) invalidate() {
+	s.mu.Lock()
+	s.brands = nil
+	s.mu.Unlock()
+}
+
+// CreateBrand adds a brand
+func (s *BrandService) CreateBrand(ctx context.Context, req BrandRequest) (*models.Brand, error) {
+	brand := &models.Brand{ID: uuid.New(), IsActive: true}
+	if err := applyBrandRequest(brand, req); err != nil {
+		return nil, err
+	}
+
+	db := s.db.WithContext(ctx)
+	if err := checkBrandUnique(db, brand); err != nil {
+		return nil, err
+	}
+	if err := db.Create(brand).Error; err != nil {
+		return nil, fmt.Errorf("failed to create brand: %v", err)
+	}
+	s.invalidate()
+	return brand, nil
+}
+
+// UpdateBrand replaces a brand's details
+func (s *BrandService) UpdateBrand(ctx context.Context, id uuid.UUID, req BrandRequest) (*models.Brand, error) {
+	db := s.db.WithContext(ctx)
+
+	var brand models.Brand
+	if err := db.Where("id = ?", id).First(&brand).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrBrandNotFound
+		}
+		return nil, fmt.Errorf("failed to fetch brand: %v", err)
+	}
+	if err := applyBrandRequest(&brand, req); err != nil {
+		return nil, err
+	}
+	if err := checkBrandUnique(db, &brand); err != nil {
+		return nil, err
+	}
+	if err := db.Save(&brand).Error; err != nil {
+		return nil, fmt.Errorf("failed to update brand: %v", err)
+	}
+	s.invalidate()
+	return &brand, nil
+}
+
+// DeleteBrand removes a brand, leaving its products without one
+func (s *BrandService) DeleteBrand(ctx context.Context, id uuid.UUID) error {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Product{}).Where("brand_id = ?", id).Update("brand_id", nil).Error; err != nil {
+			return fmt.Errorf("failed to unlink brand products: %v", err)
+		}
+		result := tx.Where("id = ?", id).Delete(&models.Brand{})
+		if result.Error != nil {
+			return fmt.Errorf("failed to delete brand: %v", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return ErrBrandNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	s.invalidate()
+	return nil
+}
+
+// applyBrandRequest normalizes and validates a request onto a brand
+func applyBrandRequest(brand *models.Brand, req BrandRequest) error {
+	name := strings.Join(strings.Fields(req.Name), " ")
+	if name == "" {
+		return errors.New("name is required")
+	}
+	slug := brandSlug(req.Slug)
+	if slug == "" {
+		slug = brandSlug(name)
+	}
+	if slug == "" {
+		return errors.New("slug must contain letters or digits")
+	}
+
+	brand.Name = name
+	brand.Slug = slug
+	brand.Description = req.Description
+	brand.LogoURL = req.LogoURL
+	if req.IsActive != nil {
+		brand.IsActive = *req.IsActive
+	}
+	return nil
+}
+
+// checkBrandUnique rejects a brand whose name or slug another brand has
+func checkBrandUnique(db *gorm.DB, brand *models.Brand) error {
+	var count int64
+	if err := db.Model(&models.Brand{}).
+		Where("(LOWER(name) = ? OR slug = ?) AND id != ?", strings.ToLower(brand.Name), brand.Slug, brand.ID).
+		Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to check brand: %v", err)
+	}
+	if count > 0 {
+		return ErrBrandExists
+	}
+	return nil
+}
+
+// brandSlug turns a name into a URL slug, e.g. "Bang & Olufsen" -> "bang-olufsen"
+func brandSlug(name string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), "-")
+}
+
+// productBrand returns the name of a product's brand, falling back to the
+// brand kept in metadata by products that aren't linked to one
+func productBrand(product models.Product) string {
+	if product.Brand != nil {
+		return product.Brand.Name
+	}
+	return metadataString(productMetadata(product), "brand")
+}
+
+// brandMentioned reports whether text names a brand as whole words
+func brandMentioned(text, brand string) bool {
+	name := wordPadded(brand)
+	return name != "" && strings.Contains(" "+wordPadded(text)+" ", " "+name+" ")
+}
+
+// wordPadded lower-cases text and keeps only its words, separated by single
+// spaces, so phrases can be matched as whole words
+func wordPadded(text string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ")
+}
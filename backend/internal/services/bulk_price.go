@@ -0,0 +1,286 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Bulk price rule actions
+const (
+	PriceActionPercent = "percent"    // change the price by Value percent, e.g. 10 or -15
+	PriceActionAmount  = "amount"     // change the price by Value
+	PriceActionSet     = "set_price"  // set the price to Value
+	PriceActionMargin  = "set_margin" // price so the margin over metadata "cost" is Value percent
+)
+
+// PriceSourceBulk is the price history source for bulk price updates
+const PriceSourceBulk = "bulk_price"
+
+// BulkPriceRule changes the price of the products in a category (including
+// its subcategories), of a brand, or both
+type BulkPriceRule struct {
+	CategoryID *uuid.UUID `json:"category_id"`
+	Brand      string     `json:"brand"`
+	Action     string     `json:"action" binding:"required,oneof=percent amount set_price set_margin"`
+	Value      float64    `json:"value"`
+}
+
+// BulkPriceRequest is the payload for POST /admin/products/bulk-price. Rules
+// are applied in order, so a product matched by several rules gets each
+// change in turn. With Preview set nothing is saved.
+type BulkPriceRequest struct {
+	Rules   []BulkPriceRule `json:"rules" binding:"required,min=1,dive"`
+	Preview bool            `json:"preview"`
+	Reason  string          `json:"reason"`
+}
+
+// BulkPriceChange is a product whose price a bulk update changes
+type BulkPriceChange struct {
+	ProductID uuid.UUID `json:"product_id"`
+	SKU       string    `json:"sku"`
+	Name      string    `json:"name"`
+	OldPrice  float64   `json:"old_price"`
+	NewPrice  float64   `json:"new_price"`
+	Rules     []int     `json:"rules"` // indexes of the rules that matched
+}
+
+// BulkPriceSkip is a matched product a rule couldn't be applied to
+type BulkPriceSkip struct {
+	ProductID uuid.UUID `json:"product_id"`
+	SKU       string    `json:"sku"`
+	Rule      int       `json:"rule"`
+	Reason    string    `json:"reason"`
+}
+
+// BulkPriceResult reports the products a bulk price update changes, with
+// their before and after prices
+type BulkPriceResult struct {
+	Preview  bool              `json:"preview"`
+	Affected int               `json:"affected"`
+	Changes  []BulkPriceChange `json:"changes"`
+	Skipped  []BulkPriceSkip   `json:"skipped"`
+}
+
+// BulkUpdatePrices applies price rules to the catalog. Unless previewing, all
+// changes are saved in one transaction with a price history entry per product.
+func (s *AdminProductService) BulkUpdatePrices(ctx context.Context, req BulkPriceRequest, changedBy *uuid.UUID) (*BulkPriceResult, error) {
+	for i, rule := range req.Rules {
+		if err := validatePriceRule(rule); err != nil {
+			return nil, fmt.Errorf("rule %d: %v", i, err)
+		}
+	}
+
+	result := &BulkPriceResult{
+		Preview: req.Preview,
+		Changes: []BulkPriceChange{},
+		Skipped: []BulkPriceSkip{},
+	}
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var products []models.Product
+		if err := tx.Order("sku ASC").Preload("Brand").Find(&products).Error; err != nil {
+			return fmt.Errorf("failed to fetch products: %v", err)
+		}
+
+		var categories []models.Category
+		if err := tx.Find(&categories).Error; err != nil {
+			return fmt.Errorf("failed to fetch categories: %v", err)
+		}
+		ruleCategories := make([]map[uuid.UUID]bool, len(req.Rules))
+		for i, rule := range req.Rules {
+			if rule.CategoryID == nil {
+				continue
+			}
+			if ruleCategories[i] = categoryTree(categories, *rule.CategoryID); ruleCategories[i] == nil {
+				return fmt.Errorf("rule %d: %w", i, ErrCategoryNotFound)
+			}
+		}
+
+		for _, product := range products {
+			metadata := productMetadata(product)
+			price := product.Price
+			change := BulkPriceChange{ProductID: product.ID, SKU: product.SKU, Name: product.Name, OldPrice: product.Price}
+
+			for i, rule := range req.Rules {
+				if ruleCategories[i] != nil && !ruleCategories[i][product.CategoryID] {
+					continue
+				}
+				if rule.Brand != "" && !strings.EqualFold(productBrand(product), rule.Brand) {
+					continue
+				}
+
+				newPrice, err := applyPriceRule(rule, price, metadata)
+				if err != nil {
+					result.Skipped = append(result.Skipped, BulkPriceSkip{ProductID: product.ID, SKU: product.SKU, Rule: i, Reason: err.Error()})
+					continue
+				}
+				price = newPrice
+				change.Rules = append(change.Rules, i)
+			}
+
+			if price == product.Price {
+				continue
+			}
+			change.NewPrice = price
+			result.Changes = append(result.Changes, change)
+		}
+		result.Affected = len(result.Changes)
+
+		if req.Preview {
+			return nil
+		}
+
+		now := time.Now()
+		for i, change := range result.Changes {
+			if err := tx.Model(&models.Product{}).Where("id = ?", change.ProductID).
+				Updates(map[string]interface{}{"price": change.NewPrice, "updated_at": now}).Error; err != nil {
+				return fmt.Errorf("failed to update price of %s: %v", change.SKU, err)
+			}
+			history := models.PriceHistory{
+				ID:        uuid.New(),
+				ProductID: change.ProductID,
+				OldPrice:  change.OldPrice,
+				NewPrice:  change.NewPrice,
+				Source:    PriceSourceBulk,
+				Reason:    req.Reason,
+				ChangedBy: changedBy,
+				CreatedAt: now,
+			}
+			if err := tx.Create(&history).Error; err != nil {
+				return fmt.Errorf("failed to record price history: %v", err)
+			}
+			if err := recordProductRevision(tx, change.ProductID, RevisionSourceBulkPrice, changedBy); err != nil {
+				return err
+			}
+			reportJobProgress(ctx, i+1, len(result.Changes))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// GetPriceHistory returns a product's price changes, newest first
+func (s *AdminProductService) GetPriceHistory(ctx context.Context, productID uuid.UUID) ([]models.PriceHistory, error) {
+	var history []models.PriceHistory
+	if err := s.db.WithContext(ctx).Where("product_id = ?", productID).Order("created_at DESC").Find(&history).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch price history: %v", err)
+	}
+	return history, nil
+}
+
+func validatePriceRule(rule BulkPriceRule) error {
+	if rule.CategoryID == nil && rule.Brand == "" {
+		return errors.New("a category_id or brand is required")
+	}
+	switch rule.Action {
+	case PriceActionPercent:
+		if rule.Value <= -100 {
+			return errors.New("a percent change must be above -100")
+		}
+	case PriceActionSet:
+		if rule.Value <= 0 {
+			return errors.New("price must be positive")
+		}
+	case PriceActionMargin:
+		if rule.Value < 0 || rule.Value >= 100 {
+			return errors.New("margin must be at least 0 and below 100 percent")
+		}
+	case PriceActionAmount:
+	default:
+		return fmt.Errorf("unknown action %q", rule.Action)
+	}
+	return nil
+}
+
+// applyPriceRule returns price after rule, rounded to the cent
+func applyPriceRule(rule BulkPriceRule, price float64, metadata map[string]interface{}) (float64, error) {
+	switch rule.Action {
+	case PriceActionPercent:
+		price = price * (1 + rule.Value/100)
+	case PriceActionAmount:
+		price += rule.Value
+	case PriceActionSet:
+		price = rule.Value
+	case PriceActionMargin:
+		cost, ok := metadataNumber(metadata, "cost")
+		if !ok || cost <= 0 {
+			return 0, errors.New("product has no cost in its metadata")
+		}
+		price = cost / (1 - rule.Value/100)
+	}
+
+	price = roundCents(price)
+	if price <= 0 {
+		return 0, errors.New("new price would not be positive")
+	}
+	return price, nil
+}
+
+// categoryTree returns the IDs of a category and all its descendants, or nil
+// when the category doesn't exist
+func categoryTree(categories []models.Category, rootID uuid.UUID) map[uuid.UUID]bool {
+	children := make(map[uuid.UUID][]uuid.UUID)
+	found := false
+	for _, category := range categories {
+		if category.ID == rootID {
+			found = true
+		}
+		if category.ParentID != nil {
+			children[*category.ParentID] = append(children[*category.ParentID], category.ID)
+		}
+	}
+	if !found {
+		return nil
+	}
+
+	tree := map[uuid.UUID]bool{rootID: true}
+	queue := []uuid.UUID{rootID}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		for _, child := range children[id] {
+			if !tree[child] {
+				tree[child] = true
+				queue = append(queue, child)
+			}
+		}
+	}
+	return tree
+}
+
+func productMetadata(product models.Product) map[string]interface{} {
+	metadata := map[string]interface{}{}
+	if len(product.Metadata) > 0 {
+		_ = json.Unmarshal(product.Metadata, &metadata)
+	}
+	return metadata
+}
+
+func metadataString(metadata map[string]interface{}, key string) string {
+	value, _ := metadata[key].(string)
+	return value
+}
+
+// metadataNumber reads a number stored in metadata either as a JSON number or a string
+func metadataNumber(metadata map[string]interface{}, key string) (float64, bool) {
+	switch value := metadata[key].(type) {
+	case float64:
+		return value, true
+	case string:
+		n, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		return n, err == nil
+	}
+	return 0, false
+}
@@ -0,0 +1,299 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Escalation statuses
+const (
+	EscalationStatusPending  = "pending"
+	EscalationStatusResolved = "resolved"
+)
+
+// ErrEscalationNotFound is returned for unknown or already resolved escalations
+var ErrEscalationNotFound = errors.New("escalation not found")
+
+// clockLayout is the format of StoreHours times
+const clockLayout = "15:04"
+
+// StoreHoursRequest is one weekday's hours in a PUT /admin/store-hours request
+type StoreHoursRequest struct {
+	Weekday        int    `json:"weekday" binding:"min=0,max=6"`
+	Opens          string `json:"opens"`
+	Closes         string `json:"closes"`
+	ShippingCutoff string `json:"shipping_cutoff"` // defaults to closing time
+	Closed         bool   `json:"closed"`
+}
+
+// StoreAvailability says whether a store is open and what a customer can
+// expect from it right now
+type StoreAvailability struct {
+	Store           string     `json:"store"`
+	Open            bool       `json:"open"`
+	HoursConfigured bool       `json:"hours_configured"` // without hours the store counts as always open
+	ClosesAt        *time.Time `json:"closes_at,omitempty"`
+	OpensAt         *time.Time `json:"opens_at,omitempty"`        // next opening while closed
+	NextShipDate    *time.Time `json:"next_ship_date,omitempty"`  // day an order placed now ships
+	ShippingCutoff  *time.Time `json:"shipping_cutoff,omitempty"` // today's cutoff, while it's still ahead
+}
+
+// BusinessHoursService manages store opening hours and the queue of messages
+// that arrive while nobody is around to answer them
+type BusinessHoursService struct {
+	db       *gorm.DB
+	location *time.Location
+}
+
+// NewBusinessHoursService creates a new BusinessHoursService. Hours are read
+// in the given time zone.
+func NewBusinessHoursService(db *gorm.DB, location *time.Location) *BusinessHoursService {
+	return &BusinessHoursService{
+		db:       db,
+		location: location,
+	}
+}
+
+// StoreLocationFromEnv reads the store's time zone from STORE_TIMEZONE (e.g.
+// "America/New_York"), defaulting to the server's local time
+func StoreLocationFromEnv() *time.Location {
+	name := os.Getenv("STORE_TIMEZONE")
+	if name == "" {
+		return time.Local
+	}
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		log.Printf("Warning: invalid STORE_TIMEZONE %q, using local time: %v", name, err)
+		return time.Local
+	}
+	return location
+}
+
+// GetHours returns a store's weekly hours, Sunday first
+func (s *BusinessHoursService) GetHours(ctx context.Context, store string) ([]models.StoreHours, error) {
+	var hours []models.StoreHours
+	if err := s.db.WithContext(ctx).Where("store = ?", store).Order("weekday").Find(&hours).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch store hours: %v", err)
+	}
+	return hours, nil
+}
+
+// SetHours replaces a store's weekly hours. Weekdays left out are closed; an
+// empty week removes the hours so the store counts as always open.
+func (s *BusinessHoursService) SetHours(ctx context.Context, store string, req []StoreHoursRequest) ([]models.StoreHours, error) {
+	if store == "" {
+		store = DefaultStoreVariant
+	}
+
+	hours := make([]models.StoreHours, 0, len(req))
+	seen := map[int]bool{}
+	for _, day := range req {
+		if day.Weekday < 0 || day.Weekday > 6 {
+			return nil, fmt.Errorf("invalid weekday %d", day.Weekday)
+		}
+		if seen[day.Weekday] {
+			return nil, fmt.Errorf("weekday %d is listed twice", day.Weekday)
+		}
+		seen[day.Weekday] = true
+
+		row := models.StoreHours{
+			ID:      uuid.New(),
+			Store:   store,
+			Weekday: day.Weekday,
+			Closed:  day.Closed,
+		}
+		if !day.Closed {
+			opens, err := parseClock(day.Opens)
+			if err != nil {
+				return nil, fmt.Errorf("invalid opening time for weekday %d", day.Weekday)
+			}
+			closes, err := parseClock(day.Closes)
+			if err != nil || closes <= opens {
+				return nil, fmt.Errorf("invalid closing time for weekday %d", day.Weekday)
+			}
+			row.Opens, row.Closes, row.ShippingCutoff = day.Opens, day.Closes, day.Closes
+			if day.ShippingCutoff != "" {
+				if _, err := parseClock(day.ShippingCutoff); err != nil {
+					return nil, fmt.Errorf("invalid shipping cutoff for weekday %d", day.Weekday)
+				}
+				row.ShippingCutoff = day.ShippingCutoff
+			}
+		}
+		hours = append(hours, row)
+	}
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("store = ?", store).Delete(&models.StoreHours{}).Error; err != nil {
+			return fmt.Errorf("failed to clear store hours: %v", err)
+		}
+		if len(hours) == 0 {
+			return nil
+		}
+		if err := tx.Create(&hours).Error; err != nil {
+			return fmt.Errorf("failed to save store hours: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetHours(ctx, store)
+}
+
+// Availability reports whether a store is open at now, when it next opens or
+// closes, and when an order placed now would ship
+func (s *BusinessHoursService) Availability(ctx context.Context, store string, now time.Time) (*StoreAvailability, error) {
+	hours, err := s.GetHours(ctx, store)
+	if err != nil {
+		return nil, err
+	}
+	availability := &StoreAvailability{Store: store, Open: true}
+	if len(hours) == 0 {
+		return availability, nil
+	}
+	availability.HoursConfigured = true
+	availability.Open = false
+
+	week := make(map[time.Weekday]models.StoreHours, len(hours))
+	for _, day := range hours {
+		if !day.Closed {
+			week[time.Weekday(day.Weekday)] = day
+		}
+	}
+
+	local := now.In(s.location)
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, s.location)
+	for offset := 0; offset <= 7; offset++ {
+		date := midnight.AddDate(0, 0, offset)
+		day, ok := week[date.Weekday()]
+		if !ok {
+			continue
+		}
+		opens, closes, cutoff := s.at(date, day.Opens), s.at(date, day.Closes), s.at(date, day.ShippingCutoff)
+
+		if offset == 0 && !local.Before(opens) && local.Before(closes) {
+			availability.Open = true
+			availability.ClosesAt = &closes
+		}
+		if availability.OpensAt == nil && !availability.Open && opens.After(local) {
+			availability.OpensAt = &opens
+		}
+		if availability.NextShipDate == nil && (offset > 0 || local.Before(cutoff)) {
+			shipDate := date
+			availability.NextShipDate = &shipDate
+			if offset == 0 {
+				availability.ShippingCutoff = &cutoff
+			}
+		}
+	}
+	return availability, nil
+}
+
+// Escalate queues a customer's message for follow-up
+func (s *BusinessHoursService) Escalate(ctx context.Context, store, sessionID string, userID *uuid.UUID, message string, followUpAt *time.Time) (*models.EscalationMessage, error) {
+	escalation := &models.EscalationMessage{
+		ID:         uuid.New(),
+		Store:      store,
+		SessionID:  sessionID,
+		UserID:     userID,
+		Message:    message,
+		Status:     EscalationStatusPending,
+		FollowUpAt: followUpAt,
+	}
+	if err := s.db.WithContext(ctx).Create(escalation).Error; err != nil {
+		return nil, fmt.Errorf("failed to queue escalation: %v", err)
+	}
+	return escalation, nil
+}
+
+// ListEscalations returns queued messages, oldest first, optionally by status
+func (s *BusinessHoursService) ListEscalations(ctx context.Context, status string) ([]models.EscalationMessage, error) {
+	query := s.db.WithContext(ctx).Order("created_at ASC")
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var escalations []models.EscalationMessage
+	if err := query.Find(&escalations).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch escalations: %v", err)
+	}
+	return escalations, nil
+}
+
+// ResolveEscalation marks a queued message as followed up
+func (s *BusinessHoursService) ResolveEscalation(ctx context.Context, id uuid.UUID, resolvedBy *uuid.UUID, notes string) (*models.EscalationMessage, error) {
+	db := s.db.WithContext(ctx)
+	now := time.Now()
+	result := db.Model(&models.EscalationMessage{}).
+		Where("id = ? AND status = ?", id, EscalationStatusPending).
+		Updates(map[string]interface{}{
+			"status":      EscalationStatusResolved,
+			"resolved_by": resolvedBy,
+			"resolved_at": now,
+			"notes":       notes,
+		})
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to resolve escalation: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrEscalationNotFound
+	}
+
+	var escalation models.EscalationMessage
+	if err := db.First(&escalation, "id = ?", id).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch escalation: %v", err)
+	}
+	return &escalation, nil
+}
+
+// at returns a "15:04" time on the given day in the store's time zone
+func (s *BusinessHoursService) at(date time.Time, clock string) time.Time {
+	minutes, _ := parseClock(clock)
+	return time.Date(date.Year(), date.Month(), date.Day(), minutes/60, minutes%60, 0, 0, s.location)
+}
+
+// parseClock returns the minutes past midnight of a "15:04" time
+func parseClock(clock string) (int, error) {
+	parsed, err := time.Parse(clockLayout, clock)
+	if err != nil {
+		return 0, err
+	}
+	return parsed.Hour()*60 + parsed.Minute(), nil
+}
+
+var humanRequestPattern = regexp.MustCompile(`\b(human|real person|agent|representative|customer (service|support)|support team|(talk|speak|chat) (to|with) (someone|somebody|a person|staff))\b`)
+
+// wantsHuman reports whether a lowercased message asks for a person rather
+// than the assistant
+func wantsHuman(messageLower string) bool {
+	return humanRequestPattern.MatchString(messageLower)
+}
+
+// storeHoursPrompt tells the assistant whether staff are around and when an
+// order placed now would ship
+func storeHoursPrompt(availability *StoreAvailability) string {
+	prompt := "Store hours: the store is open and staff are available."
+	if !availability.Open {
+		prompt = "Store hours: the store is closed right now and no staff are available. Never offer to connect the customer with a person; say their question will be followed up when the store reopens"
+		if availability.OpensAt != nil {
+			prompt += " " + availability.OpensAt.Format("Monday at 3:04 PM")
+		}
+		prompt += "."
+	}
+
+	switch {
+	case availability.ShippingCutoff != nil:
+		prompt += fmt.Sprintf(" Orders placed before %s today ship today.", availability.ShippingCutoff.Format("3:04 PM"))
+	case availability.NextShipDate != nil:
+		prompt += fmt.Sprintf(" Orders placed now ship on %s; mention this when the customer asks about delivery or is about to check out.", availability.NextShipDate.Format("Monday, January 2"))
+	}
+	return prompt
+}
@@ -0,0 +1,510 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// CampaignMessage is the WebSocket message type of a broadcast campaign
+const CampaignMessage = "campaign"
+
+// Campaign statuses
+const (
+	CampaignStatusScheduled = "scheduled"
+	CampaignStatusSending   = "sending"
+	CampaignStatusSent      = "sent"
+	CampaignStatusCancelled = "cancelled"
+)
+
+// Campaign delivery statuses
+const (
+	CampaignDeliveryDelivered = "delivered"
+	CampaignDeliveryThrottled = "throttled"
+)
+
+// Campaign errors
+var (
+	ErrCampaignNotFound     = errors.New("campaign not found")
+	ErrCampaignNotScheduled = errors.New("only scheduled campaigns can be cancelled")
+	ErrCampaignNotSendable  = errors.New("only scheduled campaigns can be sent")
+)
+
+// SessionBroadcaster is a SessionNotifier that can also list the sessions
+// with an open chat connection
+type SessionBroadcaster interface {
+	SessionNotifier
+	ConnectedSessions() []string
+}
+
+// CampaignConfig controls how fast campaigns go out and how often a session
+// may receive one
+type CampaignConfig struct {
+	SendRate      int           // notices per second
+	MinInterval   time.Duration // least time between two campaigns to the same session
+	SweepInterval time.Duration // how often due campaigns are looked for
+}
+
+// CampaignConfigFromEnv reads CAMPAIGN_SEND_RATE (default 50 a second),
+// CAMPAIGN_MIN_INTERVAL_MINUTES (default 60) and CAMPAIGN_SWEEP_SECONDS
+// (default 30)
+func CampaignConfigFromEnv() CampaignConfig {
+	config := CampaignConfig{
+		SendRate:      envInt("CAMPAIGN_SEND_RATE", 50),
+		MinInterval:   time.Duration(envInt("CAMPAIGN_MIN_INTERVAL_MINUTES", 60)) * time.Minute,
+		SweepInterval: time.Duration(envInt("CAMPAIGN_SWEEP_SECONDS", 30)) * time.Second,
+	}
+	if config.SendRate <= 0 {
+		config.SendRate = 50
+	}
+	if config.MinInterval < 0 {
+		config.MinInterval = time.Hour
+	}
+	if config.SweepInterval <= 0 {
+		config.SweepInterval = 30 * time.Second
+	}
+	return config
+}
+
+// CampaignRequest is the payload for creating or previewing a campaign
+type CampaignRequest struct {
+	Name        string     `json:"name" binding:"required"`
+	Title       string     `json:"title" binding:"required"`
+	Message     string     `json:"message" binding:"required"`
+	LinkURL     string     `json:"link_url"`
+	Segment     string     `json:"segment"`      // segment slug; empty sends to everyone connected
+	ScheduledAt *time.Time `json:"scheduled_at"` // defaults to now
+}
+
+// CampaignNotice is what connected clients receive
+type CampaignNotice struct {
+	CampaignID uuid.UUID `json:"campaign_id"`
+	Title      string    `json:"title"`
+	Message    string    `json:"message"`
+	LinkURL    string    `json:"link_url,omitempty"`
+}
+
+// CampaignPreview shows a campaign as clients will receive it and who it
+// would reach if it went out now
+type CampaignPreview struct {
+	Notice    CampaignNotice `json:"notice"`
+	Audience  int            `json:"audience"`  // connected sessions in the target
+	Throttled int            `json:"throttled"` // of those, sessions that had a campaign too recently
+}
+
+// CampaignStats reports a campaign's delivery
+type CampaignStats struct {
+	Campaign  models.BroadcastCampaign `json:"campaign"`
+	ClickRate float64                  `json:"click_rate"` // clicks per delivered notice
+}
+
+// campaignRecipient is a connected session in a campaign's audience
+type campaignRecipient struct {
+	SessionID string
+	UserID    *uuid.UUID
+}
+
+// CampaignService schedules promotional broadcasts to shoppers' open chat
+// connections
+type CampaignService struct {
+	db     *gorm.DB
+	config CampaignConfig
+}
+
+// NewCampaignService creates a new CampaignService
+func NewCampaignService(db *gorm.DB, config CampaignConfig) *CampaignService {
+	return &CampaignService{
+		db:     db,
+		config: config,
+	}
+}
+
+// CreateCampaign schedules a campaign
+func (s *CampaignService) CreateCampaign(ctx context.Context, createdBy *uuid.UUID, req CampaignRequest) (*models.BroadcastCampaign, error) {
+	campaign, err := s.buildCampaign(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	campaign.CreatedBy = createdBy
+	if err := s.db.WithContext(ctx).Create(campaign).Error; err != nil {
+		return nil, fmt.Errorf("failed to create campaign: %v", err)
+	}
+	return campaign, nil
+}
+
+// ListCampaigns returns campaigns, latest scheduled first, optionally by status
+func (s *CampaignService) ListCampaigns(ctx context.Context, status string) ([]models.BroadcastCampaign, error) {
+	query := s.db.WithContext(ctx).Preload("Segment").Order("scheduled_at DESC")
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var campaigns []models.BroadcastCampaign
+	if err := query.Find(&campaigns).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch campaigns: %v", err)
+	}
+	return campaigns, nil
+}
+
+// GetStats returns a campaign with its delivery counts
+func (s *CampaignService) GetStats(ctx context.Context, campaignID uuid.UUID) (*CampaignStats, error) {
+	campaign, err := s.campaign(s.db.WithContext(ctx).Preload("Segment"), campaignID)
+	if err != nil {
+		return nil, err
+	}
+	stats := &CampaignStats{Campaign: *campaign}
+	if campaign.Delivered > 0 {
+		stats.ClickRate = float64(campaign.Clicked) / float64(campaign.Delivered)
+	}
+	return stats, nil
+}
+
+// CancelCampaign stops a campaign that hasn't gone out yet
+func (s *CampaignService) CancelCampaign(ctx context.Context, campaignID uuid.UUID) (*models.BroadcastCampaign, error) {
+	db := s.db.WithContext(ctx)
+	result := db.Model(&models.BroadcastCampaign{}).
+		Where("id = ? AND status = ?", campaignID, CampaignStatusScheduled).
+		Update("status", CampaignStatusCancelled)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to cancel campaign: %v", result.Error)
+	}
+	campaign, err := s.campaign(db, campaignID)
+	if err != nil {
+		return nil, err
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrCampaignNotScheduled
+	}
+	return campaign, nil
+}
+
+// Preview shows how a campaign would look and how many connected sessions it
+// would reach right now, without saving or sending it
+func (s *CampaignService) Preview(ctx context.Context, req CampaignRequest, broadcaster SessionBroadcaster) (*CampaignPreview, error) {
+	campaign, err := s.buildCampaign(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	recipients, err := s.audience(ctx, campaign, broadcaster.ConnectedSessions())
+	if err != nil {
+		return nil, err
+	}
+	throttled, err := s.recentlyNotified(ctx, recipients, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return &CampaignPreview{
+		Notice:    campaignNotice(campaign),
+		Audience:  len(recipients),
+		Throttled: len(throttled),
+	}, nil
+}
+
+// RecordClick counts a session opening a campaign's link. Each session is
+// counted once.
+func (s *CampaignService) RecordClick(ctx context.Context, campaignID uuid.UUID, sessionID string) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.CampaignDelivery{}).
+			Where("campaign_id = ? AND session_id = ? AND status = ? AND clicked_at IS NULL", campaignID, sessionID, CampaignDeliveryDelivered).
+			Update("clicked_at", time.Now())
+		if result.Error != nil {
+			return fmt.Errorf("failed to record click: %v", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		if err := tx.Model(&models.BroadcastCampaign{}).Where("id = ?", campaignID).
+			Update("clicked", gorm.Expr("clicked + 1")).Error; err != nil {
+			return fmt.Errorf("failed to count click: %v", err)
+		}
+		return nil
+	})
+}
+
+// DispatchDue sends every scheduled campaign whose time has come and returns
+// how many went out
+func (s *CampaignService) DispatchDue(ctx context.Context, now time.Time, broadcaster SessionBroadcaster) (int, error) {
+	db := s.db.WithContext(ctx)
+	var due []models.BroadcastCampaign
+	if err := db.Where("status = ? AND scheduled_at <= ?", CampaignStatusScheduled, now).
+		Order("scheduled_at ASC").
+		Find(&due).Error; err != nil {
+		return 0, fmt.Errorf("failed to fetch due campaigns: %v", err)
+	}
+
+	sent := 0
+	for i := range due {
+		claimed, err := s.claim(db, due[i].ID)
+		if err != nil {
+			return sent, err
+		}
+		if !claimed {
+			continue
+		}
+		if err := s.send(ctx, &due[i], now, broadcaster); err != nil {
+			return sent, err
+		}
+		sent++
+	}
+	return sent, nil
+}
+
+// ClaimCampaign marks a scheduled campaign as sending so the scheduler leaves
+// it alone, for sending it right away with SendCampaign
+func (s *CampaignService) ClaimCampaign(ctx context.Context, campaignID uuid.UUID) (*models.BroadcastCampaign, error) {
+	db := s.db.WithContext(ctx)
+	campaign, err := s.campaign(db, campaignID)
+	if err != nil {
+		return nil, err
+	}
+	claimed, err := s.claim(db, campaignID)
+	if err != nil {
+		return nil, err
+	}
+	if !claimed {
+		return nil, ErrCampaignNotSendable
+	}
+	campaign.Status = CampaignStatusSending
+	return campaign, nil
+}
+
+// SendCampaign sends a campaign claimed with ClaimCampaign now, reporting
+// progress when run as a background job
+func (s *CampaignService) SendCampaign(ctx context.Context, campaign *models.BroadcastCampaign, broadcaster SessionBroadcaster) (*CampaignStats, error) {
+	if err := s.send(ctx, campaign, time.Now(), broadcaster); err != nil {
+		return nil, err
+	}
+	return s.GetStats(ctx, campaign.ID)
+}
+
+// claim moves a campaign from scheduled to sending, reporting whether it was
+// still scheduled, so a second instance doesn't send it too
+func (s *CampaignService) claim(db *gorm.DB, campaignID uuid.UUID) (bool, error) {
+	result := db.Model(&models.BroadcastCampaign{}).
+		Where("id = ? AND status = ?", campaignID, CampaignStatusScheduled).
+		Update("status", CampaignStatusSending)
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to claim campaign: %v", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// ScheduleDispatch sends due campaigns every SweepInterval until ctx is done
+func (s *CampaignService) ScheduleDispatch(ctx context.Context, broadcaster SessionBroadcaster) {
+	go func() {
+		ticker := time.NewTicker(s.config.SweepInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				sent, err := s.DispatchDue(ctx, now, broadcaster)
+				if err != nil {
+					log.Printf("Failed to dispatch campaigns: %v", err)
+					continue
+				}
+				if sent > 0 {
+					log.Printf("Dispatched %d broadcast campaigns", sent)
+				}
+			}
+		}
+	}()
+}
+
+// send pushes a claimed campaign to its audience at no more than SendRate
+// notices a second, skipping sessions that had a campaign within MinInterval
+func (s *CampaignService) send(ctx context.Context, campaign *models.BroadcastCampaign, now time.Time, broadcaster SessionBroadcaster) error {
+	recipients, err := s.audience(ctx, campaign, broadcaster.ConnectedSessions())
+	if err != nil {
+		return err
+	}
+	throttled, err := s.recentlyNotified(ctx, recipients, now)
+	if err != nil {
+		return err
+	}
+
+	notice := campaignNotice(campaign)
+	deliveries := make([]models.CampaignDelivery, 0, len(recipients))
+	notified := 0
+	for i, recipient := range recipients {
+		reportJobProgress(ctx, i, len(recipients))
+		delivery := models.CampaignDelivery{
+			ID:          uuid.New(),
+			CampaignID:  campaign.ID,
+			SessionID:   recipient.SessionID,
+			UserID:      recipient.UserID,
+			Status:      CampaignDeliveryDelivered,
+			DeliveredAt: now,
+		}
+		if throttled[recipient.SessionID] {
+			delivery.Status = CampaignDeliveryThrottled
+			deliveries = append(deliveries, delivery)
+			continue
+		}
+
+		if notified > 0 && notified%s.config.SendRate == 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Second):
+			}
+		}
+		broadcaster.NotifySession(recipient.SessionID, CampaignMessage, notice)
+		notified++
+		deliveries = append(deliveries, delivery)
+	}
+
+	db := s.db.WithContext(ctx)
+	if len(deliveries) > 0 {
+		if err := db.CreateInBatches(&deliveries, 500).Error; err != nil {
+			return fmt.Errorf("failed to record campaign deliveries: %v", err)
+		}
+	}
+
+	sentAt := time.Now()
+	campaign.Status = CampaignStatusSent
+	campaign.Targeted = len(recipients)
+	campaign.Delivered = notified
+	campaign.Throttled = len(recipients) - notified
+	campaign.SentAt = &sentAt
+	if err := db.Model(campaign).Updates(map[string]interface{}{
+		"status":    campaign.Status,
+		"targeted":  campaign.Targeted,
+		"delivered": campaign.Delivered,
+		"throttled": campaign.Throttled,
+		"sent_at":   sentAt,
+	}).Error; err != nil {
+		return fmt.Errorf("failed to update campaign: %v", err)
+	}
+	return nil
+}
+
+// audience returns the connected sessions a campaign targets. Segment
+// campaigns reach the signed-in sessions of the segment's members.
+func (s *CampaignService) audience(ctx context.Context, campaign *models.BroadcastCampaign, sessionIDs []string) ([]campaignRecipient, error) {
+	if len(sessionIDs) == 0 {
+		return nil, nil
+	}
+
+	var sessions []models.ChatSession
+	if err := s.db.WithContext(ctx).Select("session_id", "user_id").
+		Where("session_id IN ?", sessionIDs).
+		Find(&sessions).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch chat sessions: %v", err)
+	}
+	users := make(map[string]*uuid.UUID, len(sessions))
+	for _, session := range sessions {
+		users[session.SessionID] = session.UserID
+	}
+
+	members := map[uuid.UUID]bool{}
+	if campaign.SegmentID != nil {
+		var userIDs []uuid.UUID
+		if err := s.db.WithContext(ctx).Model(&models.SegmentMembership{}).
+			Where("segment_id = ?", *campaign.SegmentID).
+			Pluck("user_id", &userIDs).Error; err != nil {
+			return nil, fmt.Errorf("failed to fetch segment members: %v", err)
+		}
+		for _, userID := range userIDs {
+			members[userID] = true
+		}
+	}
+
+	recipients := make([]campaignRecipient, 0, len(sessionIDs))
+	for _, sessionID := range sessionIDs {
+		userID := users[sessionID]
+		if campaign.SegmentID != nil && (userID == nil || !members[*userID]) {
+			continue
+		}
+		recipients = append(recipients, campaignRecipient{SessionID: sessionID, UserID: userID})
+	}
+	return recipients, nil
+}
+
+// recentlyNotified returns the recipients that were sent a campaign within
+// MinInterval of now
+func (s *CampaignService) recentlyNotified(ctx context.Context, recipients []campaignRecipient, now time.Time) (map[string]bool, error) {
+	throttled := map[string]bool{}
+	if len(recipients) == 0 || s.config.MinInterval == 0 {
+		return throttled, nil
+	}
+
+	sessionIDs := make([]string, len(recipients))
+	for i, recipient := range recipients {
+		sessionIDs[i] = recipient.SessionID
+	}
+	var recent []string
+	if err := s.db.WithContext(ctx).Model(&models.CampaignDelivery{}).
+		Where("session_id IN ? AND status = ? AND delivered_at > ?", sessionIDs, CampaignDeliveryDelivered, now.Add(-s.config.MinInterval)).
+		Distinct("session_id").
+		Pluck("session_id", &recent).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch recent deliveries: %v", err)
+	}
+	for _, sessionID := range recent {
+		throttled[sessionID] = true
+	}
+	return throttled, nil
+}
+
+// buildCampaign validates a request into an unsaved campaign
+func (s *CampaignService) buildCampaign(ctx context.Context, req CampaignRequest) (*models.BroadcastCampaign, error) {
+	campaign := &models.BroadcastCampaign{
+		ID:          uuid.New(),
+		Name:        strings.TrimSpace(req.Name),
+		Title:       strings.TrimSpace(req.Title),
+		Message:     strings.TrimSpace(req.Message),
+		LinkURL:     strings.TrimSpace(req.LinkURL),
+		ScheduledAt: time.Now(),
+		Status:      CampaignStatusScheduled,
+	}
+	if campaign.Name == "" || campaign.Title == "" || campaign.Message == "" {
+		return nil, errors.New("name, title and message are required")
+	}
+	if req.ScheduledAt != nil {
+		if req.ScheduledAt.Before(time.Now().Add(-time.Minute)) {
+			return nil, errors.New("scheduled_at is in the past")
+		}
+		campaign.ScheduledAt = *req.ScheduledAt
+	}
+
+	if req.Segment != "" {
+		var segment models.Segment
+		if err := s.db.WithContext(ctx).Where("slug = ?", req.Segment).First(&segment).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, errors.New("segment not found")
+			}
+			return nil, fmt.Errorf("failed to fetch segment: %v", err)
+		}
+		campaign.SegmentID = &segment.ID
+		campaign.Segment = &segment
+	}
+	return campaign, nil
+}
+
+func (s *CampaignService) campaign(db *gorm.DB, campaignID uuid.UUID) (*models.BroadcastCampaign, error) {
+	var campaign models.BroadcastCampaign
+	if err := db.Where("id = ?", campaignID).First(&campaign).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCampaignNotFound
+		}
+		return nil, fmt.Errorf("failed to fetch campaign: %v", err)
+	}
+	return &campaign, nil
+}
+
+func campaignNotice(campaign *models.BroadcastCampaign) CampaignNotice {
+	return CampaignNotice{
+		CampaignID: campaign.ID,
+		Title:      campaign.Title,
+		Message:    campaign.Message,
+		LinkURL:    campaign.LinkURL,
+	}
+}
@@ -0,0 +1,399 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Cart reservation WebSocket message types
+const (
+	ReservationExpiringMessage = "reservation_expiring"
+	ReservationExpiredMessage  = "reservation_expired"
+)
+
+// SessionNotifier pushes server-initiated messages to a session's open chat connections
+type SessionNotifier interface {
+	NotifySession(sessionID, messageType string, data interface{})
+}
+
+// CartReservationConfig controls the optional reserve-on-add mode
+type CartReservationConfig struct {
+	Enabled       bool
+	TTL           time.Duration
+	WarnBefore    time.Duration
+	SweepInterval time.Duration
+}
+
+// CartReservationConfigFromEnv reads CART_RESERVATIONS_ENABLED (default off),
+// CART_RESERVATION_TTL_MINUTES (15), CART_RESERVATION_WARNING_SECONDS (120)
+// and CART_RESERVATION_SWEEP_SECONDS (30)
+func CartReservationConfigFromEnv() CartReservationConfig {
+	enabled, _ := strconv.ParseBool(os.Getenv("CART_RESERVATIONS_ENABLED"))
+	return CartReservationConfig{
+		Enabled:       enabled,
+		TTL:           time.Duration(envInt("CART_RESERVATION_TTL_MINUTES", 15)) * time.Minute,
+		WarnBefore:    time.Duration(envInt("CART_RESERVATION_WARNING_SECONDS", 120)) * time.Second,
+		SweepInterval: time.Duration(envInt("CART_RESERVATION_SWEEP_SECONDS", 30)) * time.Second,
+	}
+}
+
+// CartReservationNotice is sent to a session when its held items are about to
+// expire or have been released
+type CartReservationNotice struct {
+	ExpiresAt        time.Time   `json:"expires_at"`
+	SecondsRemaining int         `json:"seconds_remaining"`
+	ProductIDs       []uuid.UUID `json:"product_ids"`
+}
+
+// CartReservationSweep summarises one sweep of cart reservations
+type CartReservationSweep struct {
+	Warned  int `json:"warned"`
+	Expired int `json:"expired"`
+}
+
+// CartReservationService holds stock for items in a cart so it can't be
+// bought by another shopper before checkout. Holds are extended on cart and
+// chat activity and released when they lapse.
+type CartReservationService struct {
+	db     *gorm.DB
+	config CartReservationConfig
+}
+
+// NewCartReservationService creates a new CartReservationService
+func NewCartReservationService(db *gorm.DB, config CartReservationConfig) *CartReservationService {
+	return &CartReservationService{
+		db:     db,
+		config: config,
+	}
+}
+
+// Enabled reports whether carts reserve stock when items are added
+func (s *CartReservationService) Enabled() bool {
+	return s.config.Enabled
+}
+
+// Hold sets the session's reservation for a product to quantity, releasing it
+// when quantity is zero, and extends the session's other holds. Products
+// without an inventory record are not tracked.
+func (s *CartReservationService) Hold(ctx context.Context, sessionID string, userID *uuid.UUID, productID uuid.UUID, variantID *uuid.UUID, quantity int) error {
+	if !s.config.Enabled {
+		return nil
+	}
+
+	now := time.Now()
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// The inventory row is locked until the hold is saved, so shoppers
+		// holding the last units at once can't both see them as available
+		inventory, err := findInventory(tx.Clauses(clause.Locking{Strength: "UPDATE"}), productID, variantID)
+		if err != nil {
+			return err
+		}
+		if inventory == nil {
+			return s.extend(tx, sessionID, now)
+		}
+
+		var reservation models.InventoryReservation
+		err = tx.Where("inventory_id = ? AND session_id = ? AND status = ?", inventory.ID, sessionID, "active").First(&reservation).Error
+		if err != nil && err != gorm.ErrRecordNotFound {
+			return fmt.Errorf("failed to fetch reservation: %v", err)
+		}
+		held := 0
+		if err == nil {
+			held = reservation.QuantityReserved
+		}
+
+		if quantity > 0 {
+			heldByOthers, err := heldByOtherSessions(tx, inventory.ID, sessionID, userID, now)
+			if err != nil {
+				return err
+			}
+			if available := inventory.QuantityAvailable - heldByOthers; available < quantity {
+				return fmt.Errorf("insufficient inventory: available %d, requested %d", available, quantity)
+			}
+		}
+
+		switch {
+		case quantity == 0 && held > 0:
+			reservation.Status = "released"
+			if err := tx.Save(&reservation).Error; err != nil {
+				return fmt.Errorf("failed to release reservation: %v", err)
+			}
+		case quantity > 0 && held > 0:
+			reservation.QuantityReserved = quantity
+			if err := tx.Save(&reservation).Error; err != nil {
+				return fmt.Errorf("failed to update reservation: %v", err)
+			}
+		case quantity > 0:
+			reservation = models.InventoryReservation{
+				ID:               uuid.New(),
+				InventoryID:      inventory.ID,
+				SessionID:        sessionID,
+				UserID:           userID,
+				QuantityReserved: quantity,
+				ExpiresAt:        now.Add(s.config.TTL),
+				Status:           "active",
+				CreatedAt:        now,
+			}
+			if err := tx.Create(&reservation).Error; err != nil {
+				return fmt.Errorf("failed to create reservation: %v", err)
+			}
+		}
+
+		if err := adjustReserved(tx, inventory.ID, quantity-held); err != nil {
+			return err
+		}
+		return s.extend(tx, sessionID, now)
+	})
+}
+
+// Extend pushes back the expiry of every hold in the session
+func (s *CartReservationService) Extend(ctx context.Context, sessionID string) error {
+	if !s.config.Enabled {
+		return nil
+	}
+	return s.extend(s.db.WithContext(ctx), sessionID, time.Now())
+}
+
+func (s *CartReservationService) extend(db *gorm.DB, sessionID string, now time.Time) error {
+	err := db.Model(&models.InventoryReservation{}).
+		Where("session_id = ? AND status = ? AND expires_at > ?", sessionID, "active", now).
+		Updates(map[string]interface{}{
+			"expires_at":         now.Add(s.config.TTL),
+			"expiry_notified_at": nil,
+		}).Error
+	if err != nil {
+		return fmt.Errorf("failed to extend reservations: %v", err)
+	}
+	return nil
+}
+
+// ReservedUntil returns when the session's holds expire, or nil if it has none
+func (s *CartReservationService) ReservedUntil(ctx context.Context, sessionID string) (*time.Time, error) {
+	var reservation models.InventoryReservation
+	err := s.db.WithContext(ctx).
+		Where("session_id = ? AND status = ? AND expires_at > ?", sessionID, "active", time.Now()).
+		Order("expires_at ASC").
+		First(&reservation).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to fetch reservations: %v", err)
+	}
+	return &reservation.ExpiresAt, nil
+}
+
+// ReleaseSession releases every hold in the session
+func (s *CartReservationService) ReleaseSession(ctx context.Context, sessionID string) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		_, err := settleReservations(tx, tx.Where("session_id = ? AND status = ?", sessionID, "active"), "released")
+		return err
+	})
+}
+
+// Sweep warns sessions whose holds expire within the warning window and
+// releases holds that have lapsed, notifying each affected session
+func (s *CartReservationService) Sweep(ctx context.Context, now time.Time, notifier SessionNotifier) (*CartReservationSweep, error) {
+	result := &CartReservationSweep{}
+	db := s.db.WithContext(ctx)
+
+	var expiring []models.InventoryReservation
+	err := db.Preload("Inventory").
+		Where("status = ? AND expires_at > ? AND expires_at <= ? AND expiry_notified_at IS NULL", "active", now, now.Add(s.config.WarnBefore)).
+		Find(&expiring).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to find expiring reservations: %v", err)
+	}
+	if len(expiring) > 0 {
+		ids := make([]uuid.UUID, len(expiring))
+		for i, reservation := range expiring {
+			ids[i] = reservation.ID
+		}
+		if err := db.Model(&models.InventoryReservation{}).Where("id IN ?", ids).Update("expiry_notified_at", now).Error; err != nil {
+			return nil, fmt.Errorf("failed to mark reservations notified: %v", err)
+		}
+		result.Warned = len(expiring)
+		notifyReservations(notifier, ReservationExpiringMessage, expiring, now)
+	}
+
+	var expired []models.InventoryReservation
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		expired, err = settleReservations(tx, tx.Where("status = ? AND expires_at <= ?", "active", now), "expired")
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	result.Expired = len(expired)
+	notifyReservations(notifier, ReservationExpiredMessage, expired, now)
+
+	return result, nil
+}
+
+// ScheduleSweeps runs Sweep every SweepInterval until ctx is done
+func (s *CartReservationService) ScheduleSweeps(ctx context.Context, notifier SessionNotifier) {
+	go func() {
+		ticker := time.NewTicker(s.config.SweepInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				result, err := s.Sweep(ctx, now, notifier)
+				if err != nil {
+					log.Printf("Failed to sweep cart reservations: %v", err)
+					continue
+				}
+				if result.Expired > 0 {
+					log.Printf("Cart reservations: warned %d, expired %d", result.Warned, result.Expired)
+				}
+			}
+		}
+	}()
+}
+
+// consumeForOrder settles the shopper's holds once their order has reserved its own stock
+func (s *CartReservationService) consumeForOrder(tx *gorm.DB, sessionID string, userID *uuid.UUID) error {
+	query := tx.Where("status = ?", "active")
+	if userID != nil && *userID != uuid.Nil {
+		query = query.Where("session_id = ? OR user_id = ?", sessionID, *userID)
+	} else {
+		query = query.Where("session_id = ?", sessionID)
+	}
+	_, err := settleReservations(tx, query, "confirmed")
+	return err
+}
+
+// checkUnheld verifies enough stock remains once other shoppers' holds are set aside
+func (s *CartReservationService) checkUnheld(tx *gorm.DB, sessionID string, userID *uuid.UUID, productID uuid.UUID, variantID *uuid.UUID, quantity int) error {
+	inventory, err := findInventory(tx, productID, variantID)
+	if err != nil || inventory == nil {
+		return err
+	}
+
+	heldByOthers, err := heldByOtherSessions(tx, inventory.ID, sessionID, userID, time.Now())
+	if err != nil {
+		return err
+	}
+	if available := inventory.QuantityAvailable - heldByOthers; heldByOthers > 0 && available < quantity {
+		return &InventoryConflictError{
+			ProductID: productID,
+			VariantID: variantID,
+			Requested: quantity,
+			Available: available,
+		}
+	}
+	return nil
+}
+
+// settleReservations moves the matched active reservations to status and
+// returns their quantities to the inventory records
+func settleReservations(tx *gorm.DB, query *gorm.DB, status string) ([]models.InventoryReservation, error) {
+	var reservations []models.InventoryReservation
+	if err := query.Preload("Inventory").Find(&reservations).Error; err != nil {
+		return nil, fmt.Errorf("failed to find reservations: %v", err)
+	}
+
+	for _, reservation := range reservations {
+		if err := tx.Model(&models.InventoryReservation{}).Where("id = ?", reservation.ID).Update("status", status).Error; err != nil {
+			return nil, fmt.Errorf("failed to update reservation: %v", err)
+		}
+		if err := adjustReserved(tx, reservation.InventoryID, -reservation.QuantityReserved); err != nil {
+			return nil, err
+		}
+	}
+	return reservations, nil
+}
+
+// notifyReservations sends one notice per session for the given reservations
+func notifyReservations(notifier SessionNotifier, messageType string, reservations []models.InventoryReservation, now time.Time) {
+	if notifier == nil {
+		return
+	}
+
+	notices := map[string]*CartReservationNotice{}
+	for _, reservation := range reservations {
+		notice, ok := notices[reservation.SessionID]
+		if !ok {
+			notice = &CartReservationNotice{ExpiresAt: reservation.ExpiresAt, ProductIDs: []uuid.UUID{}}
+			notices[reservation.SessionID] = notice
+		}
+		if reservation.ExpiresAt.Before(notice.ExpiresAt) {
+			notice.ExpiresAt = reservation.ExpiresAt
+		}
+		notice.ProductIDs = append(notice.ProductIDs, reservation.Inventory.ProductID)
+	}
+
+	for sessionID, notice := range notices {
+		if remaining := notice.ExpiresAt.Sub(now); remaining > 0 {
+			notice.SecondsRemaining = int(remaining.Seconds())
+		}
+		notifier.NotifySession(sessionID, messageType, notice)
+	}
+}
+
+// findInventory returns the inventory record for a product or variant, or nil if there is none
+func findInventory(tx *gorm.DB, productID uuid.UUID, variantID *uuid.UUID) (*models.Inventory, error) {
+	var inventory models.Inventory
+	query := tx.Where("product_id = ?", productID)
+	if variantID != nil {
+		query = query.Where("variant_id = ?", *variantID)
+	} else {
+		query = query.Where("variant_id IS NULL")
+	}
+
+	if err := query.First(&inventory).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find inventory: %v", err)
+	}
+	return &inventory, nil
+}
+
+// heldByOtherSessions sums the live holds on an inventory record that belong to other shoppers
+func heldByOtherSessions(tx *gorm.DB, inventoryID uuid.UUID, sessionID string, userID *uuid.UUID, now time.Time) (int, error) {
+	query := tx.Model(&models.InventoryReservation{}).
+		Where("inventory_id = ? AND status = ? AND expires_at > ? AND session_id <> ?", inventoryID, "active", now, sessionID)
+	if userID != nil && *userID != uuid.Nil {
+		query = query.Where("(user_id IS NULL OR user_id <> ?)", *userID)
+	}
+
+	var held int
+	if err := query.Select("COALESCE(SUM(quantity_reserved), 0)").Scan(&held).Error; err != nil {
+		return 0, fmt.Errorf("failed to sum reservations: %v", err)
+	}
+	return held, nil
+}
+
+// adjustReserved adds delta to an inventory record's reserved quantity, never going below zero
+func adjustReserved(tx *gorm.DB, inventoryID uuid.UUID, delta int) error {
+	if delta == 0 {
+		return nil
+	}
+
+	var inventory models.Inventory
+	if err := tx.Where("id = ?", inventoryID).First(&inventory).Error; err != nil {
+		return fmt.Errorf("failed to find inventory: %v", err)
+	}
+	inventory.QuantityReserved += delta
+	if inventory.QuantityReserved < 0 {
+		inventory.QuantityReserved = 0
+	}
+	if err := tx.Model(&inventory).Update("quantity_reserved", inventory.QuantityReserved).Error; err != nil {
+		return fmt.Errorf("failed to update reserved quantity: %v", err)
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package services
 
 import (
 	"chat-ecommerce-backend/internal/models"
+	"context"
 	"encoding/json"
 	"fmt"
 	"time"
@@ -13,16 +14,38 @@ import (
 
 // ShoppingCartService handles shopping cart business logic
 type ShoppingCartService struct {
-	db *gorm.DB
+	db           *gorm.DB
+	pricing      *CustomerGroupService
+	reservations *CartReservationService
+	gifts        GiftOptionsConfig
+	taxes        TaxConfig
+	events       *EventStream
 }
 
 // NewShoppingCartService creates a new ShoppingCartService
 func NewShoppingCartService(db *gorm.DB) *ShoppingCartService {
 	return &ShoppingCartService{
-		db: db,
+		db:           db,
+		pricing:      NewCustomerGroupService(db),
+		reservations: NewCartReservationService(db, CartReservationConfigFromEnv()),
+		gifts:        GiftOptionsConfigFromEnv(),
+		taxes:        TaxConfigFromEnv(),
 	}
 }
 
+// WithPricingRules reprices cart lines through the given rules, so a cart
+// read after an admin edits a discount shows the new total
+func (s *ShoppingCartService) WithPricingRules(rules *PricingRuleService) *ShoppingCartService {
+	s.pricing.WithPricingRules(rules)
+	return s
+}
+
+// WithEventStream publishes products added to carts to the analytics stream
+func (s *ShoppingCartService) WithEventStream(events *EventStream) *ShoppingCartService {
+	s.events = events
+	return s
+}
+
 // CartItem represents an item in the shopping cart
 type CartItem struct {
 	ProductID   uuid.UUID  `json:"product_id"`
@@ -53,10 +76,16 @@ type CartResponse struct {
 	Items          []CartItem `json:"items"`
 	Subtotal       float64    `json:"subtotal"`
 	TaxAmount      float64    `json:"tax_amount"`
+	TaxRate        float64    `json:"tax_rate,omitempty"`
+	TaxIncluded    bool       `json:"tax_included"` // item prices and the subtotal already include TaxAmount
 	ShippingAmount float64    `json:"shipping_amount"`
 	TotalAmount    float64    `json:"total_amount"`
 	Currency       string     `json:"currency"`
 	ItemCount      int        `json:"item_count"`
+	ReservedUntil  *time.Time `json:"reserved_until,omitempty"`
+	GiftWrap       bool       `json:"gift_wrap"`
+	GiftWrapAmount float64    `json:"gift_wrap_amount"`
+	GiftMessage    string     `json:"gift_message,omitempty"`
 }
 
 // GetCart retrieves the shopping cart for a user or session
@@ -79,6 +108,7 @@ func (s *ShoppingCartService) GetCart(sessionID string, userID *uuid.UUID) (*Car
 				TotalAmount:    0,
 				Currency:       "USD",
 				ItemCount:      0,
+				TaxIncluded:    s.taxes.PricesIncludeTax,
 			}, nil
 		}
 		return nil, fmt.Errorf("failed to fetch cart: %w", err)
@@ -98,14 +128,32 @@ func (s *ShoppingCartService) GetCart(sessionID string, userID *uuid.UUID) (*Car
 		itemCount += item.Quantity
 	}
 
+	// Report when the cart's held stock is released
+	var reservedUntil *time.Time
+	if s.reservations.Enabled() {
+		until, err := s.reservations.ReservedUntil(context.Background(), cart.SessionID)
+		if err != nil {
+			return nil, err
+		}
+		reservedUntil = until
+	}
+
+	// Gift wrap is charged on top of the items
+	giftWrapAmount := s.gifts.wrapAmount(GiftOptions{GiftWrap: cart.GiftWrap})
+
 	return &CartResponse{
 		Items:          items,
 		Subtotal:       cart.Subtotal,
 		TaxAmount:      cart.TaxAmount,
+		TaxIncluded:    s.taxes.PricesIncludeTax,
 		ShippingAmount: cart.ShippingAmount,
-		TotalAmount:    cart.TotalAmount,
+		TotalAmount:    cart.TotalAmount + giftWrapAmount,
 		Currency:       cart.Currency,
 		ItemCount:      itemCount,
+		ReservedUntil:  reservedUntil,
+		GiftWrap:       cart.GiftWrap,
+		GiftWrapAmount: giftWrapAmount,
+		GiftMessage:    cart.GiftMessage,
 	}, nil
 }
 
@@ -119,7 +167,7 @@ func (s *ShoppingCartService) AddToCart(sessionID string, userID *uuid.UUID, req
 
 	// Get product details
 	var product models.Product
-	if err := s.db.Where("id = ? AND status = ?", req.ProductID, "active").First(&product).Error; err != nil {
+	if err := s.db.Where("id = ?", req.ProductID).Scopes(publishedAt(time.Now())).First(&product).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return fmt.Errorf("product not found or inactive")
 		}
@@ -153,8 +201,11 @@ func (s *ShoppingCartService) AddToCart(sessionID string, userID *uuid.UUID, req
 		}
 	}
 
-	// Calculate unit price
-	unitPrice := product.Price
+	// Calculate unit price for the customer's group
+	unitPrice, err := s.pricing.UnitPrice(context.Background(), userID, &product)
+	if err != nil {
+		return err
+	}
 	if req.VariantID != nil {
 		var variant models.ProductVariant
 		if err := s.db.Where("id = ?", *req.VariantID).First(&variant).Error; err == nil {
@@ -164,6 +215,7 @@ func (s *ShoppingCartService) AddToCart(sessionID string, userID *uuid.UUID, req
 
 	// Check if item already exists in cart
 	itemFound := false
+	quantity := req.Quantity
 	for i, item := range items {
 		if item.ProductID == req.ProductID &&
 			((req.VariantID == nil && item.VariantID == nil) ||
@@ -171,11 +223,17 @@ func (s *ShoppingCartService) AddToCart(sessionID string, userID *uuid.UUID, req
 			// Update existing item
 			items[i].Quantity += req.Quantity
 			items[i].TotalPrice = float64(items[i].Quantity) * items[i].UnitPrice
+			quantity = items[i].Quantity
 			itemFound = true
 			break
 		}
 	}
 
+	// Hold the stock for this cart when reservations are enabled
+	if err := s.reservations.Hold(context.Background(), cart.SessionID, userID, req.ProductID, req.VariantID, quantity); err != nil {
+		return err
+	}
+
 	// Add new item if not found
 	if !itemFound {
 		newItem := CartItem{
@@ -220,6 +278,8 @@ func (s *ShoppingCartService) AddToCart(sessionID string, userID *uuid.UUID, req
 		return fmt.Errorf("failed to update cart: %w", err)
 	}
 
+	s.events.CartAdded(cart.SessionID, userID, req.ProductID, req.Quantity, unitPrice)
+
 	return nil
 }
 
@@ -262,6 +322,11 @@ func (s *ShoppingCartService) UpdateCartItem(sessionID string, userID *uuid.UUID
 		return fmt.Errorf("item not found in cart")
 	}
 
+	// Resize or release the held stock
+	if err := s.reservations.Hold(context.Background(), cart.SessionID, userID, req.ProductID, req.VariantID, req.Quantity); err != nil {
+		return err
+	}
+
 	// Calculate totals
 	subtotal := 0.0
 	for _, item := range items {
@@ -323,9 +388,18 @@ func (s *ShoppingCartService) ClearCart(sessionID string, userID *uuid.UUID) err
 		return fmt.Errorf("failed to clear cart: %w", err)
 	}
 
+	if err := s.reservations.ReleaseSession(context.Background(), cart.SessionID); err != nil {
+		return err
+	}
+
 	return nil
 }
 
+// ExtendReservations keeps the session's held stock while the shopper is active
+func (s *ShoppingCartService) ExtendReservations(ctx context.Context, sessionID string) error {
+	return s.reservations.Extend(ctx, sessionID)
+}
+
 // getOrCreateCart gets an existing cart or creates a new one
 func (s *ShoppingCartService) getOrCreateCart(sessionID string, userID *uuid.UUID) (*models.ShoppingCart, error) {
 	var cart models.ShoppingCart
@@ -360,27 +434,37 @@ func (s *ShoppingCartService) getOrCreateCart(sessionID string, userID *uuid.UUI
 	return &cart, nil
 }
 
-// CalculateCartTotals calculates tax and shipping for the cart
-func (s *ShoppingCartService) CalculateCartTotals(cart *CartResponse) (*CartResponse, error) {
-	// TODO: Implement tax calculation based on location
+// CalculateCartTotals calculates tax and shipping for the cart. Tax is at the
+// rate of the customer's country, from the request's store locale, and is
+// extracted from tax-inclusive prices rather than added to them.
+func (s *ShoppingCartService) CalculateCartTotals(ctx context.Context, cart *CartResponse) (*CartResponse, error) {
 	// TODO: Implement shipping calculation based on weight/distance
+	var country string
+	if locale, ok := StoreLocaleFromContext(ctx); ok {
+		country = locale.Country
+	}
+	tax := s.taxes.Breakdown(cart.Subtotal, country)
 
-	// For now, just return the cart with basic calculations
-	taxAmount := cart.Subtotal * 0.08 // 8% tax
 	shippingAmount := 0.0
 	if cart.Subtotal > 0 && cart.Subtotal < 50 {
 		shippingAmount = 5.99 // Standard shipping
 	}
 
-	totalAmount := cart.Subtotal + taxAmount + shippingAmount
+	giftWrapAmount := s.gifts.wrapAmount(GiftOptions{GiftWrap: cart.GiftWrap})
+	totalAmount := tax.Gross + shippingAmount + giftWrapAmount
 
 	return &CartResponse{
 		Items:          cart.Items,
 		Subtotal:       cart.Subtotal,
-		TaxAmount:      taxAmount,
+		TaxAmount:      tax.Tax,
+		TaxRate:        tax.Rate,
+		TaxIncluded:    tax.Included,
 		ShippingAmount: shippingAmount,
 		TotalAmount:    totalAmount,
 		Currency:       cart.Currency,
 		ItemCount:      cart.ItemCount,
+		GiftWrap:       cart.GiftWrap,
+		GiftWrapAmount: giftWrapAmount,
+		GiftMessage:    cart.GiftMessage,
 	}, nil
 }
@@ -0,0 +1,237 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrInvalidShareToken is returned for share tokens that are malformed or not signed by this server
+	ErrInvalidShareToken = errors.New("invalid share token")
+	// ErrShareTokenExpired is returned for share tokens past their expiry
+	ErrShareTokenExpired = errors.New("share link has expired")
+)
+
+// CartShareResponse is the link handed out by ShareCart
+type CartShareResponse struct {
+	Token     string    `json:"token"`
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// CartCloneResponse is the recipient's cart after cloning a shared cart
+type CartCloneResponse struct {
+	Cart    *CartResponse `json:"cart"`
+	Skipped []CartItem    `json:"skipped"` // items no longer available in the requested quantity
+}
+
+// cartShareSecretFromEnv returns CART_SHARE_SECRET, falling back to JWT_SECRET
+func cartShareSecretFromEnv() []byte {
+	if secret := os.Getenv("CART_SHARE_SECRET"); secret != "" {
+		return []byte(secret)
+	}
+	return []byte(os.Getenv("JWT_SECRET"))
+}
+
+// cartShareBaseURLFromEnv returns CART_SHARE_BASE_URL, or the local storefront's shared cart page
+func cartShareBaseURLFromEnv() string {
+	if baseURL := os.Getenv("CART_SHARE_BASE_URL"); baseURL != "" {
+		return strings.TrimRight(baseURL, "/")
+	}
+	return "http://localhost:3000/cart/shared"
+}
+
+// ShareCart creates a signed, expiring link to the current cart. The link
+// points at the live cart, so later changes by the owner are visible to recipients.
+func (s *ShoppingCartService) ShareCart(sessionID string, userID *uuid.UUID) (*CartShareResponse, error) {
+	secret := cartShareSecretFromEnv()
+	if len(secret) == 0 {
+		return nil, fmt.Errorf("cart sharing is not configured")
+	}
+
+	cart, err := s.getOrCreateCart(sessionID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(cart.Items) == 0 || string(cart.Items) == "[]" {
+		return nil, fmt.Errorf("cannot share an empty cart")
+	}
+
+	expiresAt := time.Now().Add(time.Duration(envInt("CART_SHARE_TTL_HOURS", 168)) * time.Hour).Truncate(time.Second)
+	token := signCartShareToken(secret, cart.ID, expiresAt)
+
+	return &CartShareResponse{
+		Token:     token,
+		URL:       cartShareBaseURLFromEnv() + "/" + token,
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// GetSharedCart returns a read-only view of a shared cart
+func (s *ShoppingCartService) GetSharedCart(token string) (*CartResponse, error) {
+	cart, err := s.sharedCart(token)
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := cartItems(cart)
+	if err != nil {
+		return nil, err
+	}
+
+	itemCount := 0
+	for _, item := range items {
+		itemCount += item.Quantity
+	}
+
+	return &CartResponse{
+		Items:          items,
+		Subtotal:       cart.Subtotal,
+		TaxAmount:      cart.TaxAmount,
+		ShippingAmount: cart.ShippingAmount,
+		TotalAmount:    cart.TotalAmount,
+		Currency:       cart.Currency,
+		ItemCount:      itemCount,
+	}, nil
+}
+
+// CloneSharedCart adds the items of a shared cart to the recipient's own cart.
+// Items are re-added at current prices, and items that can no longer be added
+// are returned as skipped rather than failing the whole clone.
+func (s *ShoppingCartService) CloneSharedCart(token, sessionID string, userID *uuid.UUID) (*CartCloneResponse, error) {
+	source, err := s.sharedCart(token)
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := s.getOrCreateCart(sessionID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if target.ID == source.ID {
+		return nil, fmt.Errorf("cannot clone your own cart")
+	}
+
+	items, err := cartItems(source)
+	if err != nil {
+		return nil, err
+	}
+
+	skipped := []CartItem{}
+	for _, item := range items {
+		err := s.AddToCart(sessionID, userID, AddToCartRequest{
+			ProductID: item.ProductID,
+			VariantID: item.VariantID,
+			Quantity:  item.Quantity,
+		})
+		if err != nil {
+			skipped = append(skipped, item)
+		}
+	}
+
+	cart, err := s.GetCart(sessionID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CartCloneResponse{
+		Cart:    cart,
+		Skipped: skipped,
+	}, nil
+}
+
+// sharedCart verifies a share token and loads the cart it points at
+func (s *ShoppingCartService) sharedCart(token string) (*models.ShoppingCart, error) {
+	cartID, err := verifyCartShareToken(cartShareSecretFromEnv(), token, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	var cart models.ShoppingCart
+	if err := s.db.Where("id = ?", cartID).First(&cart).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("shared cart not found")
+		}
+		return nil, fmt.Errorf("failed to fetch shared cart: %w", err)
+	}
+
+	return &cart, nil
+}
+
+// cartItems decodes the items stored on a cart
+func cartItems(cart *models.ShoppingCart) ([]CartItem, error) {
+	items := []CartItem{}
+	if cart.Items != nil {
+		if err := json.Unmarshal(cart.Items, &items); err != nil {
+			return nil, fmt.Errorf("failed to parse cart items: %w", err)
+		}
+	}
+	return items, nil
+}
+
+// signCartShareToken encodes the cart ID and expiry as "<cart id>.<unix expiry>"
+// followed by an HMAC-SHA256 signature, all base64url encoded
+func signCartShareToken(secret []byte, cartID uuid.UUID, expiresAt time.Time) string {
+	payload := cartID.String() + "." + strconv.FormatInt(expiresAt.Unix(), 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." +
+		base64.RawURLEncoding.EncodeToString(cartShareSignature(secret, payload))
+}
+
+// verifyCartShareToken checks a share token's signature and expiry and returns its cart ID
+func verifyCartShareToken(secret []byte, token string, now time.Time) (uuid.UUID, error) {
+	if len(secret) == 0 {
+		return uuid.Nil, ErrInvalidShareToken
+	}
+
+	encodedPayload, encodedSignature, found := strings.Cut(token, ".")
+	if !found {
+		return uuid.Nil, ErrInvalidShareToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return uuid.Nil, ErrInvalidShareToken
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(encodedSignature)
+	if err != nil {
+		return uuid.Nil, ErrInvalidShareToken
+	}
+	if !hmac.Equal(signature, cartShareSignature(secret, string(payload))) {
+		return uuid.Nil, ErrInvalidShareToken
+	}
+
+	rawID, rawExpiry, found := strings.Cut(string(payload), ".")
+	if !found {
+		return uuid.Nil, ErrInvalidShareToken
+	}
+	cartID, err := uuid.Parse(rawID)
+	if err != nil {
+		return uuid.Nil, ErrInvalidShareToken
+	}
+	expiry, err := strconv.ParseInt(rawExpiry, 10, 64)
+	if err != nil {
+		return uuid.Nil, ErrInvalidShareToken
+	}
+	if now.Unix() >= expiry {
+		return uuid.Nil, ErrShareTokenExpired
+	}
+
+	return cartID, nil
+}
+
+func cartShareSignature(secret []byte, payload string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("cart-share:" + payload))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,340 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Catalog lint severities, most serious first
+const (
+	LintSeverityError   = "error"   // shoppers can't buy the product or reach the category
+	LintSeverityWarning = "warning" // the product sells, but poorly presented
+	LintSeverityInfo    = "info"    // worth tidying up
+)
+
+// Catalog lint rules
+const (
+	LintMissingImage      = "missing_image"
+	LintEmptyDescription  = "empty_description"
+	LintNoInventory       = "no_inventory"
+	LintNoDefaultVariant  = "no_default_variant"
+	LintOrphanedCategory  = "orphaned_category"
+	LintEmptyCategory     = "empty_category"
+	LintSKUFormat         = "sku_format"
+	LintMetadataSchema    = "metadata_schema"
+	defaultSKUPattern     = `^[A-Z0-9]+(-[A-Z0-9]+)*$`
+	minLintDescriptionLen = 20 // shorter descriptions say too little to sell the product
+)
+
+// lintSeverityRank orders severities for sorting and filtering
+var lintSeverityRank = map[string]int{LintSeverityError: 0, LintSeverityWarning: 1, LintSeverityInfo: 2}
+
+// CatalogLintConfig holds the format product and variant SKUs must follow
+type CatalogLintConfig struct {
+	SKUPattern *regexp.Regexp
+}
+
+// CatalogLintConfigFromEnv reads CATALOG_SKU_PATTERN, a regular expression
+// SKUs must match. An invalid pattern falls back to the default: uppercase
+// letters and digits in hyphen separated groups, e.g. TSHIRT-BLK-M.
+func CatalogLintConfigFromEnv() CatalogLintConfig {
+	pattern := defaultSKUPattern
+	if value := os.Getenv("CATALOG_SKU_PATTERN"); value != "" {
+		if _, err := regexp.Compile(value); err != nil {
+			log.Printf("Warning: invalid CATALOG_SKU_PATTERN %q, using the default: %v", value, err)
+		} else {
+			pattern = value
+		}
+	}
+	return CatalogLintConfig{SKUPattern: regexp.MustCompile(pattern)}
+}
+
+// CatalogLintFilter narrows the catalog lint report
+type CatalogLintFilter struct {
+	Severity string // least severe issue reported, every issue when empty
+	Rule     string // only this rule's issues when set
+}
+
+// CatalogLintIssue is a data quality problem in a product, variant or category
+type CatalogLintIssue struct {
+	Rule       string     `json:"rule"`
+	Severity   string     `json:"severity"`
+	Resource   string     `json:"resource"` // "product", "variant" or "category"
+	ResourceID uuid.UUID  `json:"resource_id"`
+	ProductID  *uuid.UUID `json:"product_id,omitempty"` // the product a variant belongs to
+	Name       string     `json:"name"`
+	SKU        string     `json:"sku,omitempty"`
+	Message    string     `json:"message"`
+	Link       string     `json:"link"` // the admin API record to fix it in
+}
+
+// CatalogLintReport lists the catalog's data quality issues, most severe first
+type CatalogLintReport struct {
+	GeneratedAt time.Time          `json:"generated_at"`
+	Products    int                `json:"products"` // products checked
+	Categories  int                `json:"categories"`
+	Severities  map[string]int     `json:"severities"` // issues per severity
+	Rules       map[string]int     `json:"rules"`      // issues per rule
+	Issues      []CatalogLintIssue `json:"issues"`
+}
+
+// CatalogLintService checks the catalog for data that hurts how products
+// sell: products without images, descriptions or stock, variants without a
+// default, categories cut off from the tree and badly formatted SKUs
+type CatalogLintService struct {
+	db     *gorm.DB
+	config CatalogLintConfig
+}
+
+// NewCatalogLintService creates a new CatalogLintService
+func NewCatalogLintService(db *gorm.DB, config CatalogLintConfig) *CatalogLintService {
+	if config.SKUPattern == nil {
+		config.SKUPattern = regexp.MustCompile(defaultSKUPattern)
+	}
+	return &CatalogLintService{db: db, config: config}
+}
+
+// ValidLintSeverity reports whether severity is a catalog lint severity
+func ValidLintSeverity(severity string) bool {
+	_, ok := lintSeverityRank[severity]
+	return ok
+}
+
+// Lint checks the products shoppers can see or that are scheduled to go
+// live, and every category. Products taken down aren't checked.
+func (s *CatalogLintService) Lint(ctx context.Context, filter CatalogLintFilter, now time.Time) (*CatalogLintReport, error) {
+	if filter.Severity != "" && !ValidLintSeverity(filter.Severity) {
+		return nil, fmt.Errorf("invalid severity %q", filter.Severity)
+	}
+	db := s.db.WithContext(ctx)
+
+	var products []models.Product
+	if err := db.Select("id", "name", "description", "sku", "status", "category_id", "metadata").
+		Where("status = ? OR publish_at IS NOT NULL", "active").
+		Order("name ASC").
+		Find(&products).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch products: %v", err)
+	}
+	var categories []models.Category
+	if err := db.Select("id", "name", "parent_id", "is_active").Order("name ASC").Find(&categories).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch categories: %v", err)
+	}
+	schemas, err := loadCategorySchemas(db)
+	if err != nil {
+		return nil, err
+	}
+
+	productIDs := make([]uuid.UUID, len(products))
+	for i, product := range products {
+		productIDs[i] = product.ID
+	}
+	withImages, err := s.productsWith(db, &models.ProductImage{}, productIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch product images: %v", err)
+	}
+	withInventory, err := s.productsWith(db, &models.Inventory{}, productIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch inventory: %v", err)
+	}
+	var variants []models.ProductVariant
+	if len(productIDs) > 0 {
+		if err := db.Select("id", "product_id", "variant_name", "variant_value", "sku_suffix", "is_default").
+			Where("product_id IN ?", productIDs).
+			Find(&variants).Error; err != nil {
+			return nil, fmt.Errorf("failed to fetch variants: %v", err)
+		}
+	}
+	variantsOf := make(map[uuid.UUID][]models.ProductVariant)
+	for _, variant := range variants {
+		variantsOf[variant.ProductID] = append(variantsOf[variant.ProductID], variant)
+	}
+
+	var issues []CatalogLintIssue
+	for _, product := range products {
+		issues = append(issues, s.lintProduct(product, withImages[product.ID], withInventory[product.ID], variantsOf[product.ID], schemas.schema(product.CategoryID))...)
+	}
+	issues = append(issues, s.lintCategories(db, categories)...)
+
+	report := &CatalogLintReport{
+		GeneratedAt: now,
+		Products:    len(products),
+		Categories:  len(categories),
+		Severities:  map[string]int{},
+		Rules:       map[string]int{},
+		Issues:      []CatalogLintIssue{},
+	}
+	for _, issue := range issues {
+		if filter.Rule != "" && issue.Rule != filter.Rule {
+			continue
+		}
+		if filter.Severity != "" && lintSeverityRank[issue.Severity] > lintSeverityRank[filter.Severity] {
+			continue
+		}
+		report.Issues = append(report.Issues, issue)
+		report.Severities[issue.Severity]++
+		report.Rules[issue.Rule]++
+	}
+	sort.SliceStable(report.Issues, func(i, j int) bool {
+		return lintSeverityRank[report.Issues[i].Severity] < lintSeverityRank[report.Issues[j].Severity]
+	})
+	return report, nil
+}
+
+// productsWith returns which of the products have a row in model's table
+func (s *CatalogLintService) productsWith(db *gorm.DB, model interface{}, productIDs []uuid.UUID) (map[uuid.UUID]bool, error) {
+	found := make(map[uuid.UUID]bool)
+	if len(productIDs) == 0 {
+		return found, nil
+	}
+	var ids []uuid.UUID
+	if err := db.Model(model).Distinct("product_id").Where("product_id IN ?", productIDs).Pluck("product_id", &ids).Error; err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		found[id] = true
+	}
+	return found, nil
+}
+
+// lintProduct checks a product and its variants, and its metadata against
+// its category's schema when it has one
+func (s *CatalogLintService) lintProduct(product models.Product, hasImage, hasInventory bool, variants []models.ProductVariant, schema *MetadataSchema) []CatalogLintIssue {
+	issue := func(rule, severity, message string) CatalogLintIssue {
+		return CatalogLintIssue{
+			Rule:       rule,
+			Severity:   severity,
+			Resource:   "product",
+			ResourceID: product.ID,
+			Name:       product.Name,
+			SKU:        product.SKU,
+			Message:    message,
+			Link:       productLintLink(product.ID),
+		}
+	}
+
+	var issues []CatalogLintIssue
+	if !hasInventory {
+		issues = append(issues, issue(LintNoInventory, LintSeverityError, "No inventory record, so the product can't be added to carts."))
+	}
+	if !s.config.SKUPattern.MatchString(product.SKU) {
+		issues = append(issues, issue(LintSKUFormat, LintSeverityError, fmt.Sprintf("SKU %q doesn't match the SKU format %s.", product.SKU, s.config.SKUPattern)))
+	}
+	if !hasImage {
+		issues = append(issues, issue(LintMissingImage, LintSeverityWarning, "The product has no images."))
+	}
+	if description := strings.TrimSpace(product.Description); description == "" {
+		issues = append(issues, issue(LintEmptyDescription, LintSeverityWarning, "The description is empty."))
+	} else if len([]rune(description)) < minLintDescriptionLen {
+		issues = append(issues, issue(LintEmptyDescription, LintSeverityInfo, fmt.Sprintf("The description is under %d characters.", minLintDescriptionLen)))
+	}
+	if schema != nil {
+		for _, violation := range schema.Validate(productMetadata(product)) {
+			issues = append(issues, issue(LintMetadataSchema, LintSeverityWarning, fmt.Sprintf("Metadata attribute %s %s under the category's schema.", violation.Attribute, violation.Message)))
+		}
+	}
+
+	if len(variants) > 0 {
+		defaults := 0
+		for _, variant := range variants {
+			if variant.IsDefault {
+				defaults++
+			}
+		}
+		switch {
+		case defaults == 0:
+			issues = append(issues, issue(LintNoDefaultVariant, LintSeverityWarning, "None of the variants is marked as the default."))
+		case defaults > 1:
+			issues = append(issues, issue(LintNoDefaultVariant, LintSeverityWarning, fmt.Sprintf("%d variants are marked as the default; only one should be.", defaults)))
+		}
+	}
+
+	for _, variant := range variants {
+		if variant.SKUSuffix == "" {
+			continue
+		}
+		sku := product.SKU + variant.SKUSuffix
+		if s.config.SKUPattern.MatchString(sku) {
+			continue
+		}
+		productID := product.ID
+		issues = append(issues, CatalogLintIssue{
+			Rule:       LintSKUFormat,
+			Severity:   LintSeverityError,
+			Resource:   "variant",
+			ResourceID: variant.ID,
+			ProductID:  &productID,
+			Name:       fmt.Sprintf("%s (%s: %s)", product.Name, variant.VariantName, variant.VariantValue),
+			SKU:        sku,
+			Message:    fmt.Sprintf("Variant SKU %q doesn't match the SKU format %s.", sku, s.config.SKUPattern),
+			Link:       productLintLink(product.ID),
+		})
+	}
+	return issues
+}
+
+// lintCategories finds categories whose parent no longer exists, which drop
+// out of the category tree, and active categories with nothing in them
+func (s *CatalogLintService) lintCategories(db *gorm.DB, categories []models.Category) []CatalogLintIssue {
+	exists := make(map[uuid.UUID]bool, len(categories))
+	hasChildren := make(map[uuid.UUID]bool)
+	for _, category := range categories {
+		exists[category.ID] = true
+		if category.ParentID != nil {
+			hasChildren[*category.ParentID] = true
+		}
+	}
+
+	var counts []struct {
+		CategoryID uuid.UUID
+		Products   int
+	}
+	countErr := db.Model(&models.Product{}).
+		Select("category_id, COUNT(*) AS products").
+		Where("status = ? OR publish_at IS NOT NULL", "active").
+		Group("category_id").
+		Scan(&counts).Error
+	if countErr != nil {
+		log.Printf("Warning: failed to count category products for the catalog lint: %v", countErr)
+	}
+	hasProducts := make(map[uuid.UUID]bool, len(counts))
+	for _, count := range counts {
+		hasProducts[count.CategoryID] = count.Products > 0
+	}
+
+	var issues []CatalogLintIssue
+	for _, category := range categories {
+		issue := CatalogLintIssue{
+			Resource:   "category",
+			ResourceID: category.ID,
+			Name:       category.Name,
+			Link:       "/api/v1/admin/categories/" + category.ID.String(),
+		}
+		switch {
+		case category.ParentID != nil && !exists[*category.ParentID]:
+			issue.Rule, issue.Severity = LintOrphanedCategory, LintSeverityError
+			issue.Message = "The parent category no longer exists, so the category is missing from the category tree."
+		case category.IsActive && countErr == nil && !hasProducts[category.ID] && !hasChildren[category.ID]:
+			issue.Rule, issue.Severity = LintEmptyCategory, LintSeverityInfo
+			issue.Message = "The category has no products or subcategories."
+		default:
+			continue
+		}
+		issues = append(issues, issue)
+	}
+	return issues
+}
+
+// productLintLink is the admin API record a product's issues are fixed in
+func productLintLink(productID uuid.UUID) string {
+	return "/api/v1/admin/products/" + productID.String()
+}
@@ -0,0 +1,580 @@
+package services
+
+import (
+	"bytes"
+	"chat-ecommerce-backend/internal/models"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// variantOptionSeparator joins the dimensions of a variant matrix cell, so a
+// size×color cell is stored as VariantName "Size / Color" and VariantValue "M / Red"
+const variantOptionSeparator = " / "
+
+// defaultImportWarehouse is used for variant stock rows without a warehouse
+const defaultImportWarehouse = "main"
+
+var (
+	categoryCSVHeader = []string{"id", "slug", "name", "description", "parent_slug", "sort_order", "is_active"}
+	variantCSVHeader  = []string{"product_sku", "options", "sku_suffix", "price_modifier", "is_default", "warehouse_location", "quantity"}
+)
+
+// CatalogImportResponse represents the result of a category or variant import
+type CatalogImportResponse struct {
+	TotalProcessed int                  `json:"total_processed"`
+	Created        int                  `json:"created"`
+	Updated        int                  `json:"updated"`
+	Errors         []CatalogImportError `json:"errors"`
+}
+
+// CatalogImportError represents a rejected row; Row is 1-based and excludes the header
+type CatalogImportError struct {
+	Row   int    `json:"row"`
+	Key   string `json:"key"`
+	Error string `json:"error"`
+}
+
+func (r *CatalogImportResponse) fail(row int, key string, err error) {
+	r.Errors = append(r.Errors, CatalogImportError{Row: row, Key: key, Error: err.Error()})
+}
+
+// ExportCategories exports the category tree to CSV. Parents are referenced
+// by slug and written before their children, so the file imports in order.
+func (s *AdminProductService) ExportCategories(ctx context.Context) ([]byte, error) {
+	var categories []models.Category
+	if err := s.db.WithContext(ctx).Order("sort_order ASC, name ASC").Find(&categories).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch categories: %v", err)
+	}
+
+	byID := make(map[uuid.UUID]models.Category, len(categories))
+	children := make(map[uuid.UUID][]models.Category)
+	var roots []models.Category
+	for _, category := range categories {
+		byID[category.ID] = category
+	}
+	for _, category := range categories {
+		if category.ParentID == nil {
+			roots = append(roots, category)
+			continue
+		}
+		if _, ok := byID[*category.ParentID]; !ok {
+			// Dangling parent: export as a root rather than dropping the row
+			roots = append(roots, category)
+			continue
+		}
+		children[*category.ParentID] = append(children[*category.ParentID], category)
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write(categoryCSVHeader)
+
+	var write func(category models.Category)
+	write = func(category models.Category) {
+		parentSlug := ""
+		if category.ParentID != nil {
+			parentSlug = byID[*category.ParentID].Slug
+		}
+		w.Write([]string{
+			category.ID.String(),
+			category.Slug,
+			category.Name,
+			category.Description,
+			parentSlug,
+			strconv.Itoa(category.SortOrder),
+			strconv.FormatBool(category.IsActive),
+		})
+		for _, child := range children[category.ID] {
+			write(child)
+		}
+	}
+	for _, root := range roots {
+		write(root)
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, fmt.Errorf("failed to write categories CSV: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// ImportCategories creates or updates categories from a CSV produced by
+// ExportCategories. Rows are matched by slug; rows may appear in any order as
+// long as every parent_slug is in the file or already exists.
+func (s *AdminProductService) ImportCategories(ctx context.Context, r io.Reader) (*CatalogImportResponse, error) {
+	rows, err := readImportCSV(r, "slug", "name")
+	if err != nil {
+		return nil, err
+	}
+
+	response := &CatalogImportResponse{
+		TotalProcessed: len(rows),
+		Errors:         []CatalogImportError{},
+	}
+	db := s.db.WithContext(ctx)
+
+	// Rows whose parent is still waiting to be imported are retried on the next pass
+	pendingSlugs := make(map[string]bool, len(rows))
+	for _, row := range rows {
+		pendingSlugs[row.get("slug")] = true
+	}
+
+	pending := rows
+	for len(pending) > 0 {
+		var deferred []csvRow
+		for _, row := range pending {
+			if parent := row.get("parent_slug"); parent != "" && parent != row.get("slug") && pendingSlugs[parent] {
+				deferred = append(deferred, row)
+				continue
+			}
+
+			created, err := importCategoryRow(db, row)
+			delete(pendingSlugs, row.get("slug"))
+			if err != nil {
+				response.fail(row.line, row.get("slug"), err)
+				continue
+			}
+			if created {
+				response.Created++
+			} else {
+				response.Updated++
+			}
+		}
+
+		if len(deferred) == len(pending) {
+			for _, row := range deferred {
+				response.fail(row.line, row.get("slug"), fmt.Errorf("parent %q is part of a cycle: %w", row.get("parent_slug"), ErrCategoryCycle))
+			}
+			break
+		}
+		pending = deferred
+	}
+
+	return response, nil
+}
+
+// importCategoryRow upserts a single category and reports whether it was created
+func importCategoryRow(db *gorm.DB, row csvRow) (bool, error) {
+	slug := row.get("slug")
+	if slug == "" || row.get("name") == "" {
+		return false, errors.New("slug and name are required")
+	}
+
+	sortOrder, err := row.intOr("sort_order", 0)
+	if err != nil {
+		return false, err
+	}
+	isActive, err := row.boolOr("is_active", true)
+	if err != nil {
+		return false, err
+	}
+
+	var parentID *uuid.UUID
+	if parentSlug := row.get("parent_slug"); parentSlug != "" {
+		var parent models.Category
+		if err := db.Where("slug = ?", parentSlug).First(&parent).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return false, fmt.Errorf("parent %q: %w", parentSlug, ErrCategoryNotFound)
+			}
+			return false, fmt.Errorf("failed to fetch parent category: %v", err)
+		}
+		parentID = &parent.ID
+	}
+
+	var category models.Category
+	err = db.Where("slug = ?", slug).First(&category).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, fmt.Errorf("failed to fetch category: %v", err)
+	}
+
+	if err == nil {
+		if parentID != nil {
+			if *parentID == category.ID {
+				return false, ErrCategoryCycle
+			}
+			descendant, err := isDescendant(db, *parentID, category.ID)
+			if err != nil {
+				return false, err
+			}
+			if descendant {
+				return false, ErrCategoryCycle
+			}
+		}
+
+		updates := map[string]interface{}{
+			"name":        row.get("name"),
+			"description": row.get("description"),
+			"parent_id":   parentID,
+			"sort_order":  sortOrder,
+			"is_active":   isActive,
+		}
+		if err := db.Model(&category).Updates(updates).Error; err != nil {
+			return false, fmt.Errorf("failed to update category: %v", err)
+		}
+		return false, nil
+	}
+
+	// Keep the exported ID when it is free so product category_id values stay valid
+	id := uuid.New()
+	if exported, err := uuid.Parse(row.get("id")); err == nil {
+		var count int64
+		if err := db.Model(&models.Category{}).Where("id = ?", exported).Count(&count).Error; err != nil {
+			return false, fmt.Errorf("failed to check category id: %v", err)
+		}
+		if count == 0 {
+			id = exported
+		}
+	}
+
+	category = models.Category{
+		ID:          id,
+		Name:        row.get("name"),
+		Description: row.get("description"),
+		ParentID:    parentID,
+		Slug:        slug,
+		SortOrder:   sortOrder,
+		IsActive:    isActive,
+		CreatedAt:   time.Now(),
+	}
+	// Select all columns so an explicit is_active=false is not replaced by the default
+	if err := db.Select("*").Create(&category).Error; err != nil {
+		return false, fmt.Errorf("failed to create category: %v", err)
+	}
+	return true, nil
+}
+
+// ExportVariantMatrix exports product variants to CSV, one row per variant
+// and warehouse. Options are written as "Size:M;Color:Red".
+func (s *AdminProductService) ExportVariantMatrix(ctx context.Context, filters ProductFilters) ([]byte, error) {
+	var products []models.Product
+
+	query := s.db.WithContext(ctx).Preload("Variants").Preload("Inventory").Order("sku ASC")
+	if filters.Status != "" {
+		query = query.Where("status = ?", filters.Status)
+	}
+	if filters.CategoryID != uuid.Nil {
+		query = query.Where("category_id = ?", filters.CategoryID)
+	}
+	if err := query.Find(&products).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch products: %v", err)
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write(variantCSVHeader)
+
+	for _, product := range products {
+		for _, variant := range product.Variants {
+			base := []string{
+				product.SKU,
+				formatVariantOptions(variant.VariantName, variant.VariantValue),
+				variant.SKUSuffix,
+				strconv.FormatFloat(variant.PriceModifier, 'f', 2, 64),
+				strconv.FormatBool(variant.IsDefault),
+			}
+
+			stocked := false
+			for _, inv := range product.Inventory {
+				if inv.VariantID == nil || *inv.VariantID != variant.ID {
+					continue
+				}
+				stocked = true
+				w.Write(append(base, inv.WarehouseLocation, strconv.Itoa(inv.QuantityAvailable)))
+			}
+			if !stocked {
+				w.Write(append(base, "", ""))
+			}
+		}
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, fmt.Errorf("failed to write variants CSV: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// ImportVariantMatrix creates or updates product variants from a CSV produced
+// by ExportVariantMatrix. Variants are matched by product SKU and options;
+// a quantity sets the variant's stock in the row's warehouse.
+func (s *AdminProductService) ImportVariantMatrix(ctx context.Context, r io.Reader) (*CatalogImportResponse, error) {
+	rows, err := readImportCSV(r, "product_sku", "options")
+	if err != nil {
+		return nil, err
+	}
+
+	response := &CatalogImportResponse{
+		TotalProcessed: len(rows),
+		Errors:         []CatalogImportError{},
+	}
+	db := s.db.WithContext(ctx)
+	products := make(map[string]*models.Product)
+	var touched []uuid.UUID
+	changed := make(map[uuid.UUID]bool)
+
+	for _, row := range rows {
+		sku := row.get("product_sku")
+		key := sku + " " + row.get("options")
+
+		product, ok := products[sku]
+		if !ok {
+			var found models.Product
+			if err := db.Where("sku = ?", sku).First(&found).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					response.fail(row.line, key, fmt.Errorf("product with SKU %q not found", sku))
+				} else {
+					response.fail(row.line, key, fmt.Errorf("failed to fetch product: %v", err))
+				}
+				continue
+			}
+			product = &found
+			products[sku] = product
+		}
+
+		created, err := importVariantRow(db, product, row)
+		if err != nil {
+			response.fail(row.line, key, err)
+			continue
+		}
+		if created {
+			response.Created++
+		} else {
+			response.Updated++
+		}
+		if !changed[product.ID] {
+			changed[product.ID] = true
+			touched = append(touched, product.ID)
+		}
+	}
+
+	// One revision per product, once all of its rows are in
+	for _, productID := range touched {
+		if err := recordProductRevision(db, productID, RevisionSourceVariantImport, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	return response, nil
+}
+
+// importVariantRow upserts a single variant and its stock, and reports whether the variant was created
+func importVariantRow(db *gorm.DB, product *models.Product, row csvRow) (bool, error) {
+	name, value, err := parseVariantOptions(row.get("options"))
+	if err != nil {
+		return false, err
+	}
+	priceModifier, err := row.floatOr("price_modifier", 0)
+	if err != nil {
+		return false, err
+	}
+	isDefault, err := row.boolOr("is_default", false)
+	if err != nil {
+		return false, err
+	}
+
+	created := false
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var variant models.ProductVariant
+		err := tx.Where("product_id = ? AND variant_name = ? AND variant_value = ?", product.ID, name, value).First(&variant).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			variant = models.ProductVariant{
+				ID:            uuid.New(),
+				ProductID:     product.ID,
+				VariantName:   name,
+				VariantValue:  value,
+				PriceModifier: priceModifier,
+				SKUSuffix:     row.get("sku_suffix"),
+				IsDefault:     isDefault,
+				CreatedAt:     time.Now(),
+			}
+			if err := tx.Create(&variant).Error; err != nil {
+				return fmt.Errorf("failed to create variant: %v", err)
+			}
+			created = true
+		case err != nil:
+			return fmt.Errorf("failed to fetch variant: %v", err)
+		default:
+			updates := map[string]interface{}{
+				"price_modifier": priceModifier,
+				"sku_suffix":     row.get("sku_suffix"),
+				"is_default":     isDefault,
+			}
+			if err := tx.Model(&variant).Updates(updates).Error; err != nil {
+				return fmt.Errorf("failed to update variant: %v", err)
+			}
+		}
+
+		if row.get("quantity") == "" {
+			return nil
+		}
+		quantity, err := row.intOr("quantity", 0)
+		if err != nil {
+			return err
+		}
+		if quantity < 0 {
+			return errors.New("quantity cannot be negative")
+		}
+		warehouse := row.get("warehouse_location")
+		if warehouse == "" {
+			warehouse = defaultImportWarehouse
+		}
+
+		var inventory models.Inventory
+		err = tx.Where("product_id = ? AND variant_id = ? AND warehouse_location = ?", product.ID, variant.ID, warehouse).First(&inventory).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			inventory = models.Inventory{
+				ID:                uuid.New(),
+				ProductID:         product.ID,
+				VariantID:         &variant.ID,
+				WarehouseLocation: warehouse,
+				QuantityAvailable: quantity,
+				LowStockThreshold: 10,
+				ReorderPoint:      5,
+			}
+			if err := tx.Create(&inventory).Error; err != nil {
+				return fmt.Errorf("failed to create inventory: %v", err)
+			}
+		case err != nil:
+			return fmt.Errorf("failed to fetch inventory: %v", err)
+		default:
+			if err := tx.Model(&inventory).Update("quantity_available", quantity).Error; err != nil {
+				return fmt.Errorf("failed to update inventory: %v", err)
+			}
+		}
+		return nil
+	})
+	return created, err
+}
+
+// formatVariantOptions renders a variant as "Size:M;Color:Red"
+func formatVariantOptions(name, value string) string {
+	names := strings.Split(name, variantOptionSeparator)
+	values := strings.Split(value, variantOptionSeparator)
+	if len(names) != len(values) {
+		return name + ":" + value
+	}
+
+	options := make([]string, len(names))
+	for i := range names {
+		options[i] = names[i] + ":" + values[i]
+	}
+	return strings.Join(options, ";")
+}
+
+// parseVariantOptions turns "Size:M;Color:Red" into the stored name and value
+func parseVariantOptions(options string) (string, string, error) {
+	var names, values []string
+	for _, option := range strings.Split(options, ";") {
+		option = strings.TrimSpace(option)
+		if option == "" {
+			continue
+		}
+		parts := strings.SplitN(option, ":", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" || strings.TrimSpace(parts[1]) == "" {
+			return "", "", fmt.Errorf("invalid variant option %q, expected name:value", option)
+		}
+		names = append(names, strings.TrimSpace(parts[0]))
+		values = append(values, strings.TrimSpace(parts[1]))
+	}
+	if len(names) == 0 {
+		return "", "", errors.New("variant options are required")
+	}
+	return strings.Join(names, variantOptionSeparator), strings.Join(values, variantOptionSeparator), nil
+}
+
+// csvRow is a data row of an import file with columns looked up by header name
+type csvRow struct {
+	line    int
+	columns map[string]int
+	values  []string
+}
+
+func (r csvRow) get(column string) string {
+	i, ok := r.columns[column]
+	if !ok || i >= len(r.values) {
+		return ""
+	}
+	return strings.TrimSpace(r.values[i])
+}
+
+func (r csvRow) intOr(column string, fallback int) (int, error) {
+	v := r.get(column)
+	if v == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q", column, v)
+	}
+	return n, nil
+}
+
+func (r csvRow) floatOr(column string, fallback float64) (float64, error) {
+	v := r.get(column)
+	if v == "" {
+		return fallback, nil
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q", column, v)
+	}
+	return f, nil
+}
+
+func (r csvRow) boolOr(column string, fallback bool) (bool, error) {
+	v := r.get(column)
+	if v == "" {
+		return fallback, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s %q", column, v)
+	}
+	return b, nil
+}
+
+// readImportCSV parses an import file and checks the header has the required columns
+func readImportCSV(r io.Reader, required ...string) ([]csvRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %v", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	for _, name := range required {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("CSV is missing the %q column", name)
+		}
+	}
+
+	var rows []csvRow
+	for line := 1; ; line++ {
+		values, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CSV row %d: %v", line, err)
+		}
+		rows = append(rows, csvRow{line: line, columns: columns, values: values})
+	}
+	return rows, nil
+}
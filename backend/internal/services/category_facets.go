@@ -0,0 +1,191 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/pkg/listquery"
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MetadataFilterPrefix starts the names of product list filters on metadata
+// attributes, e.g. filter[attr_wattage][gte]=60
+const MetadataFilterPrefix = "attr_"
+
+// FacetValue is one value of a text or boolean facet and the number of
+// products having it
+type FacetValue struct {
+	Value interface{} `json:"value"`
+	Count int         `json:"count"`
+}
+
+// CategoryFacet is a metadata attribute shoppers can narrow a category's
+// products by. Numbers report their range, other types their values.
+type CategoryFacet struct {
+	Attribute string       `json:"attribute"`
+	Label     string       `json:"label"`
+	Type      string       `json:"type"`
+	Unit      string       `json:"unit,omitempty"`
+	Filter    string       `json:"filter"` // the product list filter, e.g. attr_wattage
+	Values    []FacetValue `json:"values,omitempty"`
+	Min       *float64     `json:"min,omitempty"`
+	Max       *float64     `json:"max,omitempty"`
+	Count     int          `json:"count"` // products with a valid value
+}
+
+// CategoryFacets returns the facets of a category's schema with the values
+// of the published active products in it and its subcategories
+func (s *ProductService) CategoryFacets(ctx context.Context, categoryID uuid.UUID) ([]CategoryFacet, error) {
+	db := s.db.WithContext(ctx)
+	if _, err := findCategory(db, categoryID); err != nil {
+		return nil, err
+	}
+	schemas, err := loadCategorySchemas(db)
+	if err != nil {
+		return nil, err
+	}
+	schema := schemas.schema(categoryID)
+	if schema == nil {
+		return []CategoryFacet{}, nil
+	}
+
+	var names []string
+	for name, property := range schema.Properties {
+		if property.Facet {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	if len(names) == 0 {
+		return []CategoryFacet{}, nil
+	}
+
+	var products []models.Product
+	if err := db.Select("id", "category_id", "metadata").
+		Where("category_id IN ? AND status = ?", schemas.subtree(categoryID), "active").
+		Scopes(withinPublishWindow(time.Now())).
+		Find(&products).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch products: %v", err)
+	}
+
+	facets := make([]CategoryFacet, 0, len(names))
+	for _, name := range names {
+		property := schema.Properties[name]
+		facet := CategoryFacet{
+			Attribute: name,
+			Label:     property.Label(name),
+			Type:      property.Type,
+			Unit:      property.Unit,
+			Filter:    MetadataFilterPrefix + name,
+		}
+		counts := map[string]*FacetValue{}
+		for _, product := range products {
+			value, ok := productMetadata(product)[name]
+			if !ok || value == nil || len(property.validate(name, value)) > 0 {
+				continue
+			}
+			facet.Count++
+			if number, ok := metadataFloat(value); ok {
+				if facet.Min == nil || number < *facet.Min {
+					facet.Min = &number
+				}
+				if facet.Max == nil || number > *facet.Max {
+					facet.Max = &number
+				}
+				continue
+			}
+			key := fmt.Sprint(value)
+			if counts[key] == nil {
+				counts[key] = &FacetValue{Value: value}
+			}
+			counts[key].Count++
+		}
+		for _, value := range counts {
+			facet.Values = append(facet.Values, *value)
+		}
+		sort.Slice(facet.Values, func(i, j int) bool {
+			if facet.Values[i].Count != facet.Values[j].Count {
+				return facet.Values[i].Count > facet.Values[j].Count
+			}
+			return fmt.Sprint(facet.Values[i].Value) < fmt.Sprint(facet.Values[j].Value)
+		})
+		facets = append(facets, facet)
+	}
+	return facets, nil
+}
+
+// ListSchema is ProductListSchema with a filter for each metadata
+// attribute a category schema offers as a facet. Attributes declared with
+// different types in different categories can't be filtered on. When the
+// schemas can't be read, ProductListSchema is returned.
+func (s *ProductService) ListSchema(ctx context.Context) *listquery.Schema {
+	schemas, err := loadCategorySchemas(s.db.WithContext(ctx))
+	if err != nil {
+		log.Printf("Warning: failed to load category metadata schemas: %v", err)
+		return ProductListSchema
+	}
+
+	types := map[string]string{}
+	for _, schema := range schemas.own {
+		if schema == nil {
+			continue
+		}
+		for name, property := range schema.Properties {
+			if !property.Facet {
+				continue
+			}
+			if declared, ok := types[name]; ok && declared != property.Type {
+				types[name] = ""
+				continue
+			}
+			types[name] = property.Type
+		}
+	}
+	if len(types) == 0 {
+		return ProductListSchema
+	}
+
+	extended := *ProductListSchema
+	extended.Filters = make(map[string]listquery.Field, len(ProductListSchema.Filters)+len(types))
+	for name, field := range ProductListSchema.Filters {
+		extended.Filters[name] = field
+	}
+	dialect := s.db.Dialector.Name()
+	for name, attributeType := range types {
+		if field, ok := metadataFilter(dialect, name, attributeType); ok {
+			extended.Filters[MetadataFilterPrefix+name] = field
+		}
+	}
+	return &extended
+}
+
+// metadataFilter is the list filter on a metadata attribute. Numbers and
+// booleans are only compared when the stored value has the attribute's
+// type, so products with other metadata don't break the query. Names are
+// checked against metadataAttributeName before they're put in SQL.
+func metadataFilter(dialect, name, attributeType string) (listquery.Field, bool) {
+	if !metadataAttributeName.MatchString(name) {
+		return listquery.Field{}, false
+	}
+	text := fmt.Sprintf("metadata->>'%s'", name)
+	typed := func(postgresType, postgresCast string, sqliteTypes string) string {
+		if dialect == "postgres" {
+			return fmt.Sprintf("(CASE WHEN jsonb_typeof(metadata->'%s') = '%s' THEN CAST(%s AS %s) END)", name, postgresType, text, postgresCast)
+		}
+		return fmt.Sprintf("(CASE WHEN json_type(metadata, '$.%s') IN (%s) THEN %s END)", name, sqliteTypes, text)
+	}
+
+	switch attributeType {
+	case MetadataString:
+		return listquery.Column(text, listquery.String, listquery.Eq, listquery.Ne, listquery.In, listquery.Contains), true
+	case MetadataNumber, MetadataInteger:
+		return listquery.Column(typed("number", "NUMERIC", "'integer', 'real'"), listquery.Number), true
+	case MetadataBoolean:
+		return listquery.Column(typed("boolean", "BOOLEAN", "'true', 'false'"), listquery.Bool), true
+	}
+	return listquery.Field{}, false
+}
@@ -0,0 +1,101 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ChatAnalyticsService records and aggregates chat turn analytics and the
+// chat sessions' steps towards an order
+type ChatAnalyticsService struct {
+	db                *gorm.DB
+	misses            *SearchMissService
+	attributionWindow time.Duration
+}
+
+// NewChatAnalyticsService creates a new ChatAnalyticsService
+func NewChatAnalyticsService(db *gorm.DB) *ChatAnalyticsService {
+	return &ChatAnalyticsService{
+		db:                db,
+		misses:            NewSearchMissService(db),
+		attributionWindow: ChatAttributionWindowFromEnv(),
+	}
+}
+
+// ModelRoutingStat aggregates chat turns by tier, model and intent
+type ModelRoutingStat struct {
+	ModelTier        string  `json:"model_tier"`
+	Model            string  `json:"model"`
+	Intent           string  `json:"intent"`
+	Turns            int64   `json:"turns"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	AvgLatencyMs     float64 `json:"avg_latency_ms"`
+}
+
+// ModelRoutingReport summarizes routing decisions over a time window
+type ModelRoutingReport struct {
+	Since        time.Time          `json:"since"`
+	TotalTurns   int64              `json:"total_turns"`
+	EconomyShare float64            `json:"economy_share"` // fraction of LLM turns served by the economy tier
+	Stats        []ModelRoutingStat `json:"stats"`
+}
+
+// RecordTurn stores analytics for one assistant turn
+func (s *ChatAnalyticsService) RecordTurn(ctx context.Context, turn *models.ChatAnalytics) error {
+	if turn.ID == uuid.Nil {
+		turn.ID = uuid.New()
+	}
+	if turn.CreatedAt.IsZero() {
+		turn.CreatedAt = time.Now()
+	}
+	if err := s.db.WithContext(ctx).Create(turn).Error; err != nil {
+		return fmt.Errorf("failed to record chat analytics: %v", err)
+	}
+	return nil
+}
+
+// GetModelRoutingReport aggregates routing decisions since the given time
+func (s *ChatAnalyticsService) GetModelRoutingReport(ctx context.Context, since time.Time) (*ModelRoutingReport, error) {
+	var stats []ModelRoutingStat
+	err := s.db.WithContext(ctx).
+		Model(&models.ChatAnalytics{}).
+		Select("model_tier, model, intent, COUNT(*) AS turns, "+
+			"COALESCE(SUM(prompt_tokens), 0) AS prompt_tokens, "+
+			"COALESCE(SUM(completion_tokens), 0) AS completion_tokens, "+
+			"COALESCE(AVG(latency_ms), 0) AS avg_latency_ms").
+		Where("created_at > ?", since).
+		Group("model_tier, model, intent").
+		Order("turns DESC").
+		Scan(&stats).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate chat analytics: %v", err)
+	}
+
+	report := &ModelRoutingReport{
+		Since: since,
+		Stats: stats,
+	}
+
+	var llmTurns, economyTurns int64
+	for _, stat := range stats {
+		report.TotalTurns += stat.Turns
+		if stat.ModelTier == ModelTierFallback {
+			continue
+		}
+		llmTurns += stat.Turns
+		if stat.ModelTier == ModelTierEconomy {
+			economyTurns += stat.Turns
+		}
+	}
+	if llmTurns > 0 {
+		report.EconomyShare = float64(economyTurns) / float64(llmTurns)
+	}
+
+	return report, nil
+}
@@ -0,0 +1,370 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Chat checkout steps, kept in the session context under "checkout"
+const (
+	ChatCheckoutConfirm = "confirm" // totals quoted for the address, waiting for the shopper to confirm them
+	ChatCheckoutPayment = "payment" // order placed, waiting for the shopper to pay
+)
+
+const (
+	// chatCheckoutQuoteTTL is how long a quoted total can be confirmed
+	chatCheckoutQuoteTTL = 30 * time.Minute
+	// chatCheckoutPaymentMethod is paid through the payment intent shown in the chat
+	chatCheckoutPaymentMethod = "card"
+)
+
+// Chat checkout errors
+var (
+	ErrChatCheckoutUnavailable = errors.New("chat checkout is not available")
+	ErrChatCheckoutSignIn      = errors.New("sign in to check out in the chat")
+	ErrChatCheckoutNotQuoted   = errors.New("no total has been quoted for the order")
+	ErrChatCheckoutStale       = errors.New("the cart or prices changed since the total was quoted")
+)
+
+// ChatCheckout is a checkout in progress in a chat session
+type ChatCheckout struct {
+	Step            string                 `json:"step"`
+	ShippingAddress map[string]interface{} `json:"shipping_address"`
+	Quote           *OrderQuote            `json:"quote,omitempty"`
+	QuotedAt        time.Time              `json:"quoted_at"`
+	CartSignature   string                 `json:"cart_signature"` // the cart lines the quote is for
+	OrderID         *uuid.UUID             `json:"order_id,omitempty"`
+	OrderNumber     string                 `json:"order_number,omitempty"`
+	PaymentIntentID string                 `json:"payment_intent_id,omitempty"`
+}
+
+// ChatOrderPlaced is the payload of a place_order action: the order and the
+// payment the chat widget collects
+type ChatOrderPlaced struct {
+	OrderID     uuid.UUID              `json:"order_id"`
+	OrderNumber string                 `json:"order_number"`
+	TotalAmount float64                `json:"total_amount"`
+	Currency    string                 `json:"currency"`
+	Payment     *PaymentIntentResponse `json:"payment"`
+}
+
+// chatCheckoutTools take the shopper through checkout in the chat. They are
+// only offered when the chat service can place orders.
+var chatCheckoutTools = []LLMTool{
+	{
+		Name:        "set_shipping_address",
+		Description: "Check out the customer's cart in the chat, shipped to the address they gave. The order total for it is added to your message for them to confirm.",
+		Parameters: toolSchema(map[string]interface{}{
+			"name":        map[string]interface{}{"type": "string", "description": "Who the order is addressed to"},
+			"street":      map[string]interface{}{"type": "string", "description": "Street address, with the apartment or suite"},
+			"city":        map[string]interface{}{"type": "string"},
+			"state":       map[string]interface{}{"type": "string", "description": "State, province or region; empty when there is none"},
+			"postal_code": map[string]interface{}{"type": "string"},
+			"country":     map[string]interface{}{"type": "string", "description": "Two-letter country code, e.g. US"},
+		}),
+	},
+	{
+		Name:        "place_order",
+		Description: "Place the order at the total added to your last message. Only call it when the customer's latest message confirms that total. The payment form is shown below your message.",
+		Parameters:  toolSchema(map[string]interface{}{}),
+	},
+}
+
+// addressToolArgs are the arguments of set_shipping_address
+type addressToolArgs struct {
+	Name       string `json:"name"`
+	Street     string `json:"street"`
+	City       string `json:"city"`
+	State      string `json:"state"`
+	PostalCode string `json:"postal_code"`
+	Country    string `json:"country"`
+}
+
+// validate trims the address and checks it has enough to ship to
+func (a *addressToolArgs) validate() error {
+	for _, field := range []*string{&a.Name, &a.Street, &a.City, &a.State, &a.PostalCode, &a.Country} {
+		*field = strings.TrimSpace(*field)
+	}
+	a.Country = strings.ToUpper(a.Country)
+
+	var missing []string
+	if a.Street == "" {
+		missing = append(missing, "street")
+	}
+	if a.City == "" {
+		missing = append(missing, "city")
+	}
+	if a.PostalCode == "" {
+		missing = append(missing, "postal_code")
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("address is missing %s", strings.Join(missing, ", "))
+	}
+	if len(a.Country) != 2 {
+		return fmt.Errorf("invalid country %q", a.Country)
+	}
+	return nil
+}
+
+// WithCheckout lets the assistant check the shopper out in the chat: take
+// their address, quote the totals and, once they confirm, place the order
+// and start its payment
+func (s *ChatService) WithCheckout(orders *OrderService, payments *PaymentService) *ChatService {
+	s.orders = orders
+	s.payments = payments
+	return s
+}
+
+// tools returns the tools offered to the model
+func (s *ChatService) tools() []LLMTool {
+	if s.orders == nil || s.payments == nil {
+		return chatTools
+	}
+	return append(append([]LLMTool{}, chatTools...), chatCheckoutTools...)
+}
+
+// quoteCheckout works out the order total for the cart shipped to address
+// and keeps it for the shopper to confirm
+func (s *ChatService) quoteCheckout(ctx context.Context, sessionID string, userID *uuid.UUID, address map[string]interface{}, now time.Time) (*ChatCheckout, error) {
+	if s.orders == nil || s.payments == nil {
+		return nil, ErrChatCheckoutUnavailable
+	}
+	if userID == nil {
+		return nil, ErrChatCheckoutSignIn
+	}
+	cart, err := s.cartService.GetCart(sessionID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(cart.Items) == 0 {
+		return nil, ErrEmptyCart
+	}
+
+	quote, err := s.orders.QuoteOrder(ctx, chatOrderRequest(sessionID, *userID, cart, address))
+	if err != nil {
+		return nil, err
+	}
+	checkout := &ChatCheckout{
+		Step:            ChatCheckoutConfirm,
+		ShippingAddress: address,
+		Quote:           quote,
+		QuotedAt:        now,
+		CartSignature:   cartSignature(cart),
+	}
+	if err := setSessionContextKey(ctx, s.db, sessionID, "checkout", checkout); err != nil {
+		return nil, err
+	}
+	return checkout, nil
+}
+
+// placeChatOrder places the order the shopper confirmed and creates its
+// payment. The cart is emptied as the order now holds its items and stock.
+func (s *ChatService) placeChatOrder(ctx context.Context, sessionID string, userID *uuid.UUID, now time.Time) (*ChatOrderPlaced, error) {
+	if s.orders == nil || s.payments == nil {
+		return nil, ErrChatCheckoutUnavailable
+	}
+	if userID == nil {
+		return nil, ErrChatCheckoutSignIn
+	}
+	checkout := s.sessionCheckout(ctx, sessionID)
+	if checkout == nil || checkout.Step != ChatCheckoutConfirm || checkout.Quote == nil {
+		return nil, ErrChatCheckoutNotQuoted
+	}
+	cart, err := s.cartService.GetCart(sessionID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(cart.Items) == 0 {
+		return nil, ErrEmptyCart
+	}
+	if now.Sub(checkout.QuotedAt) > chatCheckoutQuoteTTL || cartSignature(cart) != checkout.CartSignature {
+		return nil, ErrChatCheckoutStale
+	}
+
+	order, err := s.orders.CreateOrder(ctx, chatOrderRequest(sessionID, *userID, cart, checkout.ShippingAddress))
+	if err != nil {
+		return nil, err
+	}
+
+	// Prices can change between the quote and the order; the shopper is
+	// only charged what they agreed to
+	if roundCents(order.TotalAmount) != roundCents(checkout.Quote.TotalAmount) {
+		s.cancelChatOrder(ctx, order.ID)
+		return nil, ErrChatCheckoutStale
+	}
+
+	payment, err := s.payments.CreatePaymentIntent(&CreatePaymentIntentRequest{
+		OrderID:     order.ID,
+		Amount:      int64(order.TotalAmount*100 + 0.5),
+		Currency:    strings.ToLower(order.Currency),
+		Description: "Order " + order.OrderNumber,
+		Metadata:    map[string]string{"order_number": order.OrderNumber, "source": "chat"},
+	})
+	if err != nil {
+		s.cancelChatOrder(ctx, order.ID)
+		return nil, fmt.Errorf("failed to create payment: %v", err)
+	}
+	if _, err := s.orders.UpdatePaymentStatus(ctx, order.ID, "processing", payment.ID); err != nil {
+		return nil, err
+	}
+	if err := s.orders.UpdatePaymentProvider(ctx, order.ID, payment.Provider); err != nil {
+		return nil, err
+	}
+
+	if err := s.cartService.ClearCart(sessionID, userID); err != nil {
+		log.Printf("Warning: failed to clear cart after chat order %s: %v", order.OrderNumber, err)
+	}
+	checkout.Step = ChatCheckoutPayment
+	checkout.OrderID = &order.ID
+	checkout.OrderNumber = order.OrderNumber
+	checkout.PaymentIntentID = payment.ID
+	if err := setSessionContextKey(ctx, s.db, sessionID, "checkout", checkout); err != nil {
+		log.Printf("Warning: failed to save chat checkout: %v", err)
+	}
+
+	return &ChatOrderPlaced{
+		OrderID:     order.ID,
+		OrderNumber: order.OrderNumber,
+		TotalAmount: order.TotalAmount,
+		Currency:    order.Currency,
+		Payment:     payment,
+	}, nil
+}
+
+// cancelChatOrder cancels an order the chat couldn't finish placing, releasing its stock
+func (s *ChatService) cancelChatOrder(ctx context.Context, orderID uuid.UUID) {
+	if _, err := s.orders.CancelOrder(ctx, orderID); err != nil {
+		log.Printf("Warning: failed to cancel chat order %s: %v", orderID, err)
+	}
+}
+
+// chatOrderRequest is the order for a cart shipped and billed to address
+func chatOrderRequest(sessionID string, userID uuid.UUID, cart *CartResponse, address map[string]interface{}) *CreateOrderRequest {
+	req := &CreateOrderRequest{
+		UserID:          userID,
+		SessionID:       sessionID,
+		ShippingAddress: address,
+		BillingAddress:  address,
+		PaymentMethod:   chatCheckoutPaymentMethod,
+		Notes:           "chat checkout",
+	}
+	for _, item := range cart.Items {
+		req.Items = append(req.Items, OrderItemRequest{
+			ProductID: item.ProductID,
+			VariantID: item.VariantID,
+			Quantity:  item.Quantity,
+		})
+	}
+	return req
+}
+
+// cartSignature identifies a cart's lines and prices, to tell whether it
+// changed after a total was quoted
+func cartSignature(cart *CartResponse) string {
+	lines := make([]string, 0, len(cart.Items)+1)
+	for _, item := range cart.Items {
+		variant := ""
+		if item.VariantID != nil {
+			variant = item.VariantID.String()
+		}
+		lines = append(lines, fmt.Sprintf("%s/%s x%d", item.ProductID, variant, item.Quantity))
+	}
+	sort.Strings(lines)
+	lines = append(lines, fmt.Sprintf("gift=%t", cart.GiftWrap))
+	return strings.Join(lines, ";")
+}
+
+// sessionCheckout returns the checkout kept in a session's context, or nil
+func (s *ChatService) sessionCheckout(ctx context.Context, sessionID string) *ChatCheckout {
+	var session models.ChatSession
+	if err := s.db.WithContext(ctx).Select("id", "context").Where("session_id = ?", sessionID).First(&session).Error; err != nil {
+		return nil
+	}
+	if len(session.Context) == 0 {
+		return nil
+	}
+	var contextMap struct {
+		Checkout *ChatCheckout `json:"checkout"`
+	}
+	if err := json.Unmarshal(session.Context, &contextMap); err != nil {
+		return nil
+	}
+	return contextMap.Checkout
+}
+
+// checkoutQuoteMessage tells the shopper the total to confirm
+func checkoutQuoteMessage(quote *OrderQuote) string {
+	parts := []string{fmt.Sprintf("$%.2f for the items", quote.Subtotal), fmt.Sprintf("$%.2f shipping", quote.ShippingAmount)}
+	if quote.GiftWrapAmount > 0 {
+		parts = append(parts, fmt.Sprintf("$%.2f gift wrap", quote.GiftWrapAmount))
+	}
+	tax := fmt.Sprintf("$%.2f tax", quote.TaxAmount)
+	if quote.TaxIncluded {
+		tax = fmt.Sprintf("including $%.2f tax", quote.TaxAmount)
+	}
+	return fmt.Sprintf("Your order comes to $%.2f: %s, %s. Shall I place it?", quote.TotalAmount, strings.Join(parts, ", "), tax)
+}
+
+// orderPlacedMessage tells the shopper their order is placed and how to pay
+func orderPlacedMessage(placed *ChatOrderPlaced) string {
+	if placed.Payment != nil && placed.Payment.ApprovalURL != "" {
+		return fmt.Sprintf("Order %s is placed. Approve the $%.2f payment here to complete it: %s", placed.OrderNumber, placed.TotalAmount, placed.Payment.ApprovalURL)
+	}
+	return fmt.Sprintf("Order %s is placed. Enter your payment details below to pay the $%.2f and complete it.", placed.OrderNumber, placed.TotalAmount)
+}
+
+// checkoutFailure tells the shopper why a checkout step didn't go through,
+// or returns "" for other actions
+func checkoutFailure(actionType string, err error) string {
+	if actionType != "set_shipping_address" && actionType != "place_order" {
+		return ""
+	}
+	var violations *OrderValidationError
+	var conflict *InventoryConflictError
+	switch {
+	case errors.Is(err, ErrChatCheckoutSignIn):
+		return "Please sign in first, and I'll place your order for you here."
+	case errors.Is(err, ErrEmptyCart):
+		return "Your cart is empty, so there's nothing to check out yet."
+	case errors.Is(err, ErrChatCheckoutNotQuoted):
+		return "I need your shipping address to work out the total before I can place the order."
+	case errors.Is(err, ErrChatCheckoutStale):
+		return "Your cart or its prices changed since I gave you the total, so I haven't placed the order. Let me work out the new total first."
+	case errors.As(err, &violations):
+		messages := make([]string, len(violations.Violations))
+		for i, violation := range violations.Violations {
+			messages[i] = violation.Message
+		}
+		return "I can't place this order: " + strings.Join(messages, " ")
+	case errors.As(err, &conflict):
+		return "Some items in your cart aren't in stock in the quantity you asked for any more. Could you adjust your cart?"
+	}
+	return "Sorry, I couldn't complete that checkout step. Please try again, or check out on the checkout page."
+}
+
+// checkoutPrompt tells the assistant how to check out in the chat and where
+// the shopper is in it
+func checkoutPrompt(checkout *ChatCheckout) string {
+	prompt := `You can check the customer out in the chat. When they want to buy their cart, ask for the shipping address (name, street, city, state, postal code and country) and call set_shipping_address; the order total is added to your message. Call place_order only when the customer's latest message confirms that total, and never guess an address. They pay with the form shown below your message. Use checkout instead when they'd rather pay on the checkout page.`
+	if checkout == nil {
+		return prompt
+	}
+	switch checkout.Step {
+	case ChatCheckoutConfirm:
+		if checkout.Quote != nil {
+			prompt += fmt.Sprintf("\n\nThe customer was quoted $%.2f for their order and hasn't confirmed it yet.", checkout.Quote.TotalAmount)
+		}
+	case ChatCheckoutPayment:
+		prompt += fmt.Sprintf("\n\nOrder %s was placed in this chat and is waiting for the customer's payment.", checkout.OrderNumber)
+	}
+	return prompt
+}
@@ -0,0 +1,230 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Chat event types, in funnel order
+const (
+	ChatEventMessage           = "message"
+	ChatEventSuggestionShown   = "suggestion_shown"
+	ChatEventSuggestionClicked = "suggestion_clicked"
+	ChatEventAddToCart         = "add_to_cart"
+	ChatEventOrder             = "order"
+)
+
+// chatFunnelStages are the funnel's stages in order
+var chatFunnelStages = []string{
+	ChatEventMessage,
+	ChatEventSuggestionShown,
+	ChatEventSuggestionClicked,
+	ChatEventAddToCart,
+	ChatEventOrder,
+}
+
+// ErrInvalidChatEvent is returned for chat events the storefront can't report
+var ErrInvalidChatEvent = errors.New("invalid chat event")
+
+// ChatAttributionWindowFromEnv reads CHAT_ORDER_ATTRIBUTION_HOURS, how long
+// after a shopper's last chat message their orders count as chat orders (72)
+func ChatAttributionWindowFromEnv() time.Duration {
+	return time.Duration(envInt("CHAT_ORDER_ATTRIBUTION_HOURS", 72)) * time.Hour
+}
+
+// ChatFunnelStage is how many chat sessions reached a stage of the funnel
+type ChatFunnelStage struct {
+	Stage             string  `json:"stage"`
+	Sessions          int64   `json:"sessions"` // sessions with at least one event of the stage
+	Events            int64   `json:"events"`
+	Conversion        float64 `json:"conversion"`         // share of the previous stage's sessions
+	OverallConversion float64 `json:"overall_conversion"` // share of the sessions that chatted
+}
+
+// ChatFunnel is the chat conversion funnel over a period. To is exclusive.
+type ChatFunnel struct {
+	From              time.Time         `json:"from"`
+	To                time.Time         `json:"to"`
+	Stages            []ChatFunnelStage `json:"stages"`
+	Revenue           float64           `json:"revenue"` // total of the orders attributed to chat
+	AverageOrderValue float64           `json:"average_order_value"`
+}
+
+// RecordEvent stores a chat event
+func (s *ChatAnalyticsService) RecordEvent(ctx context.Context, event *models.ChatEvent) error {
+	if event.ID == uuid.Nil {
+		event.ID = uuid.New()
+	}
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+	if err := s.db.WithContext(ctx).Create(event).Error; err != nil {
+		return fmt.Errorf("failed to record chat event: %v", err)
+	}
+	return nil
+}
+
+// TrackSuggestionEvent records a suggestion clicked, or added to the cart
+// from its card, as reported by the storefront
+func (s *ChatAnalyticsService) TrackSuggestionEvent(ctx context.Context, sessionID string, userID *uuid.UUID, eventType string, productID uuid.UUID) error {
+	if eventType != ChatEventSuggestionClicked && eventType != ChatEventAddToCart {
+		return fmt.Errorf("%w: type must be %s or %s", ErrInvalidChatEvent, ChatEventSuggestionClicked, ChatEventAddToCart)
+	}
+	var count int64
+	if err := s.db.WithContext(ctx).Model(&models.Product{}).Where("id = ?", productID).Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to check product: %v", err)
+	}
+	if count == 0 {
+		return fmt.Errorf("%w: product not found", ErrInvalidChatEvent)
+	}
+
+	event := &models.ChatEvent{SessionID: sessionID, UserID: userID, Type: eventType, ProductID: &productID}
+	if eventType == ChatEventAddToCart {
+		event.Quantity = 1
+	}
+	return s.RecordEvent(ctx, event)
+}
+
+// AttributeOrder records an order as a chat order when its session, or its
+// customer in any session, chatted within the attribution window before it
+// was placed. The event is recorded against the session that chatted.
+func (s *ChatAnalyticsService) AttributeOrder(ctx context.Context, order *models.Order) error {
+	query := s.db.WithContext(ctx).
+		Where("type = ? AND created_at >= ? AND created_at <= ?", ChatEventMessage, order.CreatedAt.Add(-s.attributionWindow), order.CreatedAt)
+	if order.UserID != uuid.Nil {
+		query = query.Where("session_id = ? OR user_id = ?", order.SessionID, order.UserID)
+	} else {
+		query = query.Where("session_id = ?", order.SessionID)
+	}
+
+	var last models.ChatEvent
+	if err := query.Order("created_at DESC").First(&last).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return fmt.Errorf("failed to find chat for order: %v", err)
+	}
+
+	orderID := order.ID
+	var userID *uuid.UUID
+	if order.UserID != uuid.Nil {
+		userID = &order.UserID
+	}
+	return s.RecordEvent(ctx, &models.ChatEvent{
+		SessionID: last.SessionID,
+		UserID:    userID,
+		Type:      ChatEventOrder,
+		OrderID:   &orderID,
+		Amount:    order.TotalAmount,
+	})
+}
+
+// Funnel counts the chat sessions reaching each stage, from chatting to
+// ordering, in the period
+func (s *ChatAnalyticsService) Funnel(ctx context.Context, from, to time.Time) (*ChatFunnel, error) {
+	var rows []struct {
+		Type     string
+		Sessions int64
+		Events   int64
+		Amount   float64
+	}
+	err := s.db.WithContext(ctx).
+		Model(&models.ChatEvent{}).
+		Select("type, COUNT(DISTINCT session_id) AS sessions, COUNT(*) AS events, COALESCE(SUM(amount), 0) AS amount").
+		Where("created_at >= ? AND created_at < ?", from, to).
+		Group("type").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate chat events: %v", err)
+	}
+
+	byType := make(map[string]ChatFunnelStage, len(rows))
+	funnel := &ChatFunnel{From: from, To: to, Stages: make([]ChatFunnelStage, 0, len(chatFunnelStages))}
+	for _, row := range rows {
+		byType[row.Type] = ChatFunnelStage{Sessions: row.Sessions, Events: row.Events}
+		if row.Type == ChatEventOrder {
+			funnel.Revenue = roundCents(row.Amount)
+			if row.Events > 0 {
+				funnel.AverageOrderValue = roundCents(row.Amount / float64(row.Events))
+			}
+		}
+	}
+
+	chatted := byType[ChatEventMessage].Sessions
+	var previous int64
+	for i, stageType := range chatFunnelStages {
+		stage := byType[stageType]
+		stage.Stage = stageType
+		if i > 0 && previous > 0 {
+			stage.Conversion = roundTo(float64(stage.Sessions)/float64(previous), 4)
+		} else if i == 0 && stage.Sessions > 0 {
+			stage.Conversion = 1
+		}
+		if chatted > 0 {
+			stage.OverallConversion = roundTo(float64(stage.Sessions)/float64(chatted), 4)
+		}
+		previous = stage.Sessions
+		funnel.Stages = append(funnel.Stages, stage)
+	}
+	return funnel, nil
+}
+
+// UnansweredQueries returns the chat messages that found no products in the
+// period, most frequent first
+func (s *ChatAnalyticsService) UnansweredQueries(ctx context.Context, from, to time.Time, limit int) ([]SearchMissSummary, error) {
+	return s.misses.MissReport(ctx, SearchMissFilter{From: from, To: to, Source: MissSourceChat, Limit: limit})
+}
+
+// TrackSuggestionEvent records a suggestion the shopper clicked, or added to
+// the cart from its card
+func (s *ChatService) TrackSuggestionEvent(ctx context.Context, sessionID string, userID *uuid.UUID, eventType string, productID uuid.UUID) error {
+	return s.analytics.TrackSuggestionEvent(ctx, sessionID, userID, eventType, productID)
+}
+
+// recordEvent stores a chat event; failures are logged and do not affect the reply
+func (s *ChatService) recordEvent(ctx context.Context, event *models.ChatEvent) {
+	if err := s.analytics.RecordEvent(ctx, event); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
+
+// recordSuggestionsShown records each product suggested to the shopper
+func (s *ChatService) recordSuggestionsShown(ctx context.Context, sessionID string, userID *uuid.UUID, suggestions []ProductSuggestion) {
+	for _, suggestion := range suggestions {
+		if suggestion.Product == nil {
+			continue
+		}
+		productID := suggestion.Product.ID
+		s.recordEvent(ctx, &models.ChatEvent{SessionID: sessionID, UserID: userID, Type: ChatEventSuggestionShown, ProductID: &productID})
+	}
+	s.events.SuggestionsShown(sessionID, userID, suggestions)
+}
+
+// recordCartActions records the products the assistant added to the cart
+func (s *ChatService) recordCartActions(ctx context.Context, sessionID string, userID *uuid.UUID, actions []ChatAction) {
+	for _, action := range actions {
+		if action.Type != "add_to_cart" {
+			continue
+		}
+		productIDStr, _ := action.Payload["product_id"].(string)
+		productID, err := uuid.Parse(productIDStr)
+		if err != nil {
+			continue
+		}
+		quantity := 1
+		switch q := action.Payload["quantity"].(type) {
+		case int:
+			quantity = q
+		case float64:
+			quantity = int(q)
+		}
+		s.recordEvent(ctx, &models.ChatEvent{SessionID: sessionID, UserID: userID, Type: ChatEventAddToCart, ProductID: &productID, Quantity: quantity})
+	}
+}
@@ -0,0 +1,310 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"chat-ecommerce-backend/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Chat session statuses while shoppers are handed to a person
+const (
+	ChatSessionActive     = "active"      // the assistant answers
+	ChatSessionNeedsAgent = "needs_agent" // waiting for an admin to join
+	ChatSessionWithAgent  = "with_agent"  // an admin answers instead of the assistant
+)
+
+// ChatRoleAgent is the role of messages an admin sends in a shopper's chat
+const ChatRoleAgent = "agent"
+
+// Why a chat was handed to a person
+const (
+	HandoffReasonRequested  = "requested"  // the shopper asked for a person
+	HandoffReasonFrustrated = "frustrated" // the shopper sounded frustrated
+)
+
+// Handoff messages on the admin channel
+const (
+	AdminChatHandoffMessage = "chat_handoff" // a chat needs an agent, was joined or was handed back
+	AdminChatMessage        = "chat_message" // a shopper wrote to, or an agent answered, a handed-off chat
+)
+
+// Handoff messages sent to the shopper's chat connections
+const (
+	ChatAgentMessage       = "agent_message" // an agent's reply
+	ChatAgentStatusMessage = "agent_status"  // an agent joined or left the chat
+)
+
+var (
+	ErrHandoffNotFound = errors.New("chat session not found")
+	ErrHandoffTaken    = errors.New("another agent has joined this chat")
+	ErrNotSessionAgent = errors.New("you haven't joined this chat")
+)
+
+var frustrationPattern = regexp.MustCompile(`\b(frustrat\w*|annoy\w*|angry|furious|useless|ridiculous|waste of (my )?time|(not|isn'?t|aren'?t) helping|(doesn'?t|don'?t|didn'?t) help|not what i (asked|want\w*)|stupid|wtf)\b`)
+
+// detectFrustration reports whether a lowercased message sounds like the
+// shopper is fed up with the assistant
+func detectFrustration(messageLower string) bool {
+	return frustrationPattern.MatchString(messageLower)
+}
+
+// ChatHandoff is a chat handed to a person, as admins and the shopper see it
+type ChatHandoff struct {
+	SessionID   string     `json:"session_id"`
+	UserID      *uuid.UUID `json:"user_id,omitempty"`
+	Status      string     `json:"status"`           // needs_agent, with_agent, or active once handed back
+	Reason      string     `json:"reason,omitempty"` // requested or frustrated
+	AgentID     *uuid.UUID `json:"agent_id,omitempty"`
+	RequestedAt *time.Time `json:"requested_at,omitempty"`
+	LastActive  time.Time  `json:"last_active"`
+}
+
+func newChatHandoff(session *models.ChatSession) *ChatHandoff {
+	return &ChatHandoff{
+		SessionID:   session.SessionID,
+		UserID:      session.UserID,
+		Status:      session.Status,
+		Reason:      session.HandoffReason,
+		AgentID:     session.AgentID,
+		RequestedAt: session.HandoffAt,
+		LastActive:  session.LastActivity,
+	}
+}
+
+// WithHandoff lets shoppers who ask for a person, or sound frustrated, be
+// handed to an admin. Admins are alerted on the admin channel and their
+// replies are pushed to the shopper's chat connections.
+func (s *ChatService) WithHandoff(admins AdminNotifier, sessions SessionNotifier) *ChatService {
+	s.admins = admins
+	s.sessions = sessions
+	return s
+}
+
+// handoffSession returns the chat session when it's handed to a person, or
+// nil while the assistant answers
+func (s *ChatService) handoffSession(ctx context.Context, sessionID string) *models.ChatSession {
+	var session models.ChatSession
+	if err := s.db.WithContext(ctx).Where("session_id = ?", sessionID).First(&session).Error; err != nil {
+		return nil
+	}
+	if session.Status != ChatSessionNeedsAgent && session.Status != ChatSessionWithAgent {
+		return nil
+	}
+	return &session
+}
+
+// handoffReason tells why a message should be handed to a person, or
+// returns "" when the assistant should answer
+func handoffReason(messageLower string) string {
+	switch {
+	case wantsHuman(messageLower):
+		return HandoffReasonRequested
+	case detectFrustration(messageLower):
+		return HandoffReasonFrustrated
+	}
+	return ""
+}
+
+// requestHandoff flags the session as needing an agent, alerts the admins
+// and tells the shopper someone is on the way
+func (s *ChatService) requestHandoff(ctx context.Context, sessionID string, userID *uuid.UUID, message, reason string, intent *MessageIntent, language string) (*ChatResponse, error) {
+	now := time.Now()
+	result := s.db.WithContext(ctx).Model(&models.ChatSession{}).
+		Where("session_id = ? AND (status = ? OR status = '' OR status IS NULL)", sessionID, ChatSessionActive).
+		Updates(map[string]interface{}{
+			"status":         ChatSessionNeedsAgent,
+			"handoff_reason": reason,
+			"handoff_at":     now,
+			"agent_id":       nil,
+		})
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to request an agent: %v", result.Error)
+	}
+
+	if err := s.saveMessage(ctx, sessionID, userID, "user", message, nil); err != nil {
+		log.Printf("Warning: failed to save user message: %v", err)
+	}
+	reply := "I've asked a member of our team to join this chat. They'll be with you shortly, and I'm happy to keep helping in the meantime."
+	if reason == HandoffReasonFrustrated {
+		reply = "I'm sorry this hasn't been helpful. " + reply
+	}
+	if err := s.saveMessage(ctx, sessionID, userID, "assistant", reply, map[string]interface{}{
+		"handoff_reason": reason,
+	}); err != nil {
+		log.Printf("Warning: failed to save assistant message: %v", err)
+	}
+
+	handoff := s.handoffSession(ctx, sessionID)
+	var notice *ChatHandoff
+	if handoff != nil {
+		notice = newChatHandoff(handoff)
+		s.admins.NotifyAdmins(AdminChatHandoffMessage, notice)
+	}
+
+	return &ChatResponse{
+		Message:  reply,
+		Intent:   intent,
+		Language: language,
+		Handoff:  notice,
+		Context: map[string]interface{}{
+			"session_id": sessionID,
+			"user_id":    userID,
+			"store_open": true,
+		},
+	}, nil
+}
+
+// forwardToAgent passes a shopper's message to the agent who joined the
+// chat instead of answering it
+func (s *ChatService) forwardToAgent(ctx context.Context, session *models.ChatSession, userID *uuid.UUID, message string) (*ChatResponse, error) {
+	saved, err := s.storeMessage(ctx, session.SessionID, userID, "user", message, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save message: %v", err)
+	}
+	s.admins.NotifyAdmins(AdminChatMessage, chatMessageFromModel(saved))
+
+	return &ChatResponse{
+		Handoff: newChatHandoff(session),
+		Context: map[string]interface{}{
+			"session_id": session.SessionID,
+			"user_id":    userID,
+		},
+	}, nil
+}
+
+// ListHandoffs returns the chats waiting for or with an agent, the longest
+// waiting first. status narrows them to needs_agent or with_agent.
+func (s *ChatService) ListHandoffs(ctx context.Context, status string) ([]ChatHandoff, error) {
+	query := s.db.WithContext(ctx).Model(&models.ChatSession{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	} else {
+		query = query.Where("status IN ?", []string{ChatSessionNeedsAgent, ChatSessionWithAgent})
+	}
+
+	var sessions []models.ChatSession
+	if err := query.Order("handoff_at ASC").Find(&sessions).Error; err != nil {
+		return nil, fmt.Errorf("failed to list chat handoffs: %v", err)
+	}
+
+	handoffs := make([]ChatHandoff, 0, len(sessions))
+	for i := range sessions {
+		handoffs = append(handoffs, *newChatHandoff(&sessions[i]))
+	}
+	return handoffs, nil
+}
+
+// JoinSession puts an admin in charge of a chat: the assistant stops
+// answering and the shopper's messages go to the admin. An admin can join any
+// chat another admin hasn't.
+func (s *ChatService) JoinSession(ctx context.Context, sessionID string, agentID uuid.UUID) (*ChatHandoff, error) {
+	db := s.db.WithContext(ctx)
+	result := db.Model(&models.ChatSession{}).
+		Where("session_id = ? AND (agent_id IS NULL OR agent_id = ?)", sessionID, agentID).
+		Updates(map[string]interface{}{
+			"status":   ChatSessionWithAgent,
+			"agent_id": agentID,
+		})
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to join chat: %v", result.Error)
+	}
+
+	var session models.ChatSession
+	if err := db.Where("session_id = ?", sessionID).First(&session).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrHandoffNotFound
+		}
+		return nil, err
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrHandoffTaken
+	}
+	if session.HandoffAt == nil {
+		now := time.Now()
+		session.HandoffAt = &now
+		db.Model(&session).Update("handoff_at", now)
+	}
+
+	handoff := newChatHandoff(&session)
+	s.notifyHandoff(handoff)
+	return handoff, nil
+}
+
+// SendAgentMessage sends an admin's reply to the shopper of a chat they joined
+func (s *ChatService) SendAgentMessage(ctx context.Context, sessionID string, agentID uuid.UUID, content string) (*ChatMessageService, error) {
+	var session models.ChatSession
+	if err := s.db.WithContext(ctx).Where("session_id = ?", sessionID).First(&session).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrHandoffNotFound
+		}
+		return nil, err
+	}
+	if session.Status != ChatSessionWithAgent || session.AgentID == nil || *session.AgentID != agentID {
+		return nil, ErrNotSessionAgent
+	}
+
+	saved, err := s.storeMessage(ctx, sessionID, session.UserID, ChatRoleAgent, strings.TrimSpace(content), map[string]interface{}{
+		"agent_id": agentID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to save agent message: %v", err)
+	}
+
+	message := chatMessageFromModel(saved)
+	if s.sessions != nil {
+		s.sessions.NotifySession(sessionID, ChatAgentMessage, message)
+	}
+	if s.admins != nil {
+		s.admins.NotifyAdmins(AdminChatMessage, message)
+	}
+	return &message, nil
+}
+
+// ReleaseSession hands a chat back to the assistant
+func (s *ChatService) ReleaseSession(ctx context.Context, sessionID string) (*ChatHandoff, error) {
+	db := s.db.WithContext(ctx)
+	var session models.ChatSession
+	if err := db.Where("session_id = ?", sessionID).First(&session).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrHandoffNotFound
+		}
+		return nil, err
+	}
+
+	err := db.Model(&session).Updates(map[string]interface{}{
+		"status":         ChatSessionActive,
+		"agent_id":       nil,
+		"handoff_reason": "",
+		"handoff_at":     nil,
+	}).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to release chat: %v", err)
+	}
+
+	handoff := newChatHandoff(&session)
+	handoff.Status = ChatSessionActive
+	handoff.Reason = ""
+	handoff.AgentID = nil
+	handoff.RequestedAt = nil
+	s.notifyHandoff(handoff)
+	return handoff, nil
+}
+
+// notifyHandoff tells the admins and the shopper that an agent joined or left
+func (s *ChatService) notifyHandoff(handoff *ChatHandoff) {
+	if s.admins != nil {
+		s.admins.NotifyAdmins(AdminChatHandoffMessage, handoff)
+	}
+	if s.sessions != nil {
+		s.sessions.NotifySession(handoff.SessionID, ChatAgentStatusMessage, handoff)
+	}
+}
@@ -0,0 +1,242 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+)
+
+// Chat languages the assistant detects and answers in
+const (
+	ChatLanguageEnglish    = "en"
+	ChatLanguageSpanish    = "es"
+	ChatLanguagePortuguese = "pt"
+	ChatLanguageFrench     = "fr"
+	ChatLanguageGerman     = "de"
+	ChatLanguageItalian    = "it"
+)
+
+// chatLanguageNames names the chat languages for the system prompt
+var chatLanguageNames = map[string]string{
+	ChatLanguageEnglish:    "English",
+	ChatLanguageSpanish:    "Spanish",
+	ChatLanguagePortuguese: "Portuguese",
+	ChatLanguageFrench:     "French",
+	ChatLanguageGerman:     "German",
+	ChatLanguageItalian:    "Italian",
+}
+
+// languageMarkers are common words that give a message's language away.
+// Words shared by several languages count for each of them.
+var languageMarkers = map[string][]string{
+	ChatLanguageEnglish: {
+		"the", "and", "is", "are", "you", "i", "i'm", "my", "for", "with", "what", "do", "have", "want", "looking",
+		"show", "me", "please", "need", "can", "how", "this", "it", "to", "of", "some", "any", "thanks", "hello", "hi",
+	},
+	ChatLanguageSpanish: {
+		"el", "los", "las", "una", "quiero", "busco", "tienes", "tienen", "hola", "gracias", "para", "por", "favor",
+		"necesito", "muéstrame", "cuánto", "cuesta", "con", "mi", "está", "y", "también", "precio", "carrito",
+		"pedido", "dónde", "cómo", "qué", "algo", "unos", "unas", "zapatos",
+	},
+	ChatLanguagePortuguese: {
+		"olá", "obrigado", "obrigada", "você", "quero", "preciso", "tem", "uma", "um", "não", "para", "com", "meu",
+		"minha", "isso", "está", "carrinho", "pedido", "onde", "quanto", "custa", "procuro", "estou", "do", "da",
+		"dos", "das", "os", "e", "também", "sapatos", "algum", "alguma",
+	},
+	ChatLanguageFrench: {
+		"le", "les", "des", "une", "je", "vous", "bonjour", "merci", "cherche", "avec", "pour", "est", "mon", "ma",
+		"mes", "panier", "commande", "où", "combien", "coûte", "voudrais", "veux", "besoin", "et", "au", "aux",
+		"aussi", "chaussures", "quelque", "chose", "s'il", "plaît",
+	},
+	ChatLanguageGerman: {
+		"der", "die", "das", "und", "ich", "ist", "nicht", "ein", "eine", "suche", "möchte", "hallo", "danke",
+		"bitte", "mit", "für", "mein", "meine", "warenkorb", "bestellung", "wo", "wie", "viel", "kostet", "haben",
+		"sie", "zu", "auch", "schuhe", "etwas", "einen",
+	},
+	ChatLanguageItalian: {
+		"il", "lo", "gli", "della", "sono", "cerco", "vorrei", "ciao", "grazie", "per", "con", "mio", "mia",
+		"carrello", "ordine", "dove", "quanto", "costa", "voglio", "ho", "bisogno", "che", "non", "anche",
+		"scarpe", "qualcosa", "degli", "è",
+	},
+}
+
+// languageWords maps each marker word to the languages it counts for
+var languageWords = func() map[string][]string {
+	words := map[string][]string{}
+	for language, markers := range languageMarkers {
+		for _, word := range markers {
+			words[word] = append(words[word], language)
+		}
+	}
+	return words
+}()
+
+// languageLetters are letters only some of the languages use
+var languageLetters = map[rune][]string{
+	'ñ': {ChatLanguageSpanish}, '¿': {ChatLanguageSpanish}, '¡': {ChatLanguageSpanish},
+	'ã': {ChatLanguagePortuguese}, 'õ': {ChatLanguagePortuguese},
+	'ß': {ChatLanguageGerman}, 'ä': {ChatLanguageGerman}, 'ö': {ChatLanguageGerman}, 'ü': {ChatLanguageGerman},
+	'è': {ChatLanguageFrench, ChatLanguageItalian}, 'ê': {ChatLanguageFrench, ChatLanguagePortuguese},
+	'œ': {ChatLanguageFrench}, 'ç': {ChatLanguageFrench, ChatLanguagePortuguese},
+}
+
+// languageWordPattern splits a message into words, keeping apostrophes
+var languageWordPattern = regexp.MustCompile(`[\p{L}']+`)
+
+// minLanguageScore is how much evidence a language needs before a message is
+// taken to be written in it
+const minLanguageScore = 2
+
+// DetectLanguage returns the chat language a message is written in, or ""
+// when it's too short or too mixed to tell
+func DetectLanguage(message string) string {
+	message = strings.ToLower(message)
+	scores := map[string]int{}
+	for _, word := range languageWordPattern.FindAllString(message, -1) {
+		for _, language := range languageWords[strings.Trim(word, "'")] {
+			scores[language]++
+		}
+	}
+	for _, r := range message {
+		for _, language := range languageLetters[r] {
+			scores[language]++
+		}
+	}
+
+	best, bestScore, runnerUp := "", 0, 0
+	for language, score := range scores {
+		switch {
+		case score > bestScore:
+			best, bestScore, runnerUp = language, score, bestScore
+		case score > runnerUp:
+			runnerUp = score
+		}
+	}
+	if bestScore < minLanguageScore || bestScore == runnerUp {
+		return ""
+	}
+	return best
+}
+
+// chatLanguage returns the language to answer a message in: the one it's
+// written in, or the one detected earlier in the session when it can't be
+// told. A newly detected language is stored on the session.
+func (s *ChatService) chatLanguage(ctx context.Context, sessionID, message string) string {
+	var session models.ChatSession
+	if err := s.db.WithContext(ctx).Select("id", "locale").Where("session_id = ?", sessionID).First(&session).Error; err != nil {
+		// Sessions are created before their first message; without one
+		// there's nothing to remember the language on
+		return DetectLanguage(message)
+	}
+
+	detected := DetectLanguage(message)
+	if detected == "" || detected == session.Locale {
+		return session.Locale
+	}
+	if err := s.db.WithContext(ctx).Model(&models.ChatSession{}).Where("id = ?", session.ID).Update("locale", detected).Error; err != nil {
+		log.Printf("Warning: failed to store chat session locale: %v", err)
+	}
+	return detected
+}
+
+// languagePrompt tells the model which language to answer in. English, the
+// prompt's own language, needs no instruction.
+func languagePrompt(language string) string {
+	name, ok := chatLanguageNames[language]
+	if !ok || language == ChatLanguageEnglish {
+		return ""
+	}
+	return fmt.Sprintf("Language: the customer writes in %s. Always reply in %s, even though these instructions are in English. Keep product names, brands, SKUs and prices exactly as they appear in the catalog.", name, name)
+}
+
+// suggestionReasons translates the reasons given with product suggestions.
+// A %s stands for a product or brand name.
+var suggestionReasons = map[string]map[string]string{
+	"Directly mentioned": {
+		ChatLanguageSpanish: "Lo mencionaste", ChatLanguagePortuguese: "Você mencionou", ChatLanguageFrench: "Vous l'avez mentionné",
+		ChatLanguageGerman: "Direkt erwähnt", ChatLanguageItalian: "Citato direttamente",
+	},
+	"From %s, as you asked": {
+		ChatLanguageSpanish: "De %s, como pediste", ChatLanguagePortuguese: "Da %s, como você pediu", ChatLanguageFrench: "De %s, comme demandé",
+		ChatLanguageGerman: "Von %s, wie gewünscht", ChatLanguageItalian: "Di %s, come richiesto",
+	},
+	"Matches your category interest": {
+		ChatLanguageSpanish: "De la categoría que buscas", ChatLanguagePortuguese: "Da categoria que você procura", ChatLanguageFrench: "Dans la catégorie qui vous intéresse",
+		ChatLanguageGerman: "Aus der gesuchten Kategorie", ChatLanguageItalian: "Della categoria che cerchi",
+	},
+	"Great for entertainment": {
+		ChatLanguageSpanish: "Ideal para entretenerte", ChatLanguagePortuguese: "Ótimo para se divertir", ChatLanguageFrench: "Idéal pour se divertir",
+		ChatLanguageGerman: "Ideal zur Unterhaltung", ChatLanguageItalian: "Ottimo per divertirsi",
+	},
+	"Perfect for travel": {
+		ChatLanguageSpanish: "Perfecto para viajar", ChatLanguagePortuguese: "Perfeito para viajar", ChatLanguageFrench: "Parfait pour voyager",
+		ChatLanguageGerman: "Perfekt für Reisen", ChatLanguageItalian: "Perfetto per viaggiare",
+	},
+	"Perfect for commuting": {
+		ChatLanguageSpanish: "Perfecto para tus trayectos", ChatLanguagePortuguese: "Perfeito para o trajeto diário", ChatLanguageFrench: "Parfait pour les trajets",
+		ChatLanguageGerman: "Perfekt für den Arbeitsweg", ChatLanguageItalian: "Perfetto per gli spostamenti",
+	},
+	"Great for listening": {
+		ChatLanguageSpanish: "Ideal para escuchar música", ChatLanguagePortuguese: "Ótimo para ouvir música", ChatLanguageFrench: "Idéal pour écouter de la musique",
+		ChatLanguageGerman: "Ideal zum Musikhören", ChatLanguageItalian: "Ottimo per ascoltare musica",
+	},
+	"Great for reading": {
+		ChatLanguageSpanish: "Ideal para leer", ChatLanguagePortuguese: "Ótimo para ler", ChatLanguageFrench: "Idéal pour lire",
+		ChatLanguageGerman: "Ideal zum Lesen", ChatLanguageItalian: "Ottimo per leggere",
+	},
+	"Good value option": {
+		ChatLanguageSpanish: "Buena relación calidad-precio", ChatLanguagePortuguese: "Ótimo custo-benefício", ChatLanguageFrench: "Bon rapport qualité-prix",
+		ChatLanguageGerman: "Gutes Preis-Leistungs-Verhältnis", ChatLanguageItalian: "Ottimo rapporto qualità-prezzo",
+	},
+	"Recommended product": {
+		ChatLanguageSpanish: "Producto recomendado", ChatLanguagePortuguese: "Produto recomendado", ChatLanguageFrench: "Produit recommandé",
+		ChatLanguageGerman: "Empfohlenes Produkt", ChatLanguageItalian: "Prodotto consigliato",
+	},
+	"Related to your search": {
+		ChatLanguageSpanish: "Relacionado con tu búsqueda", ChatLanguagePortuguese: "Relacionado à sua busca", ChatLanguageFrench: "En lien avec votre recherche",
+		ChatLanguageGerman: "Passend zu Ihrer Suche", ChatLanguageItalian: "Legato alla tua ricerca",
+	},
+	"Featured for your search": {
+		ChatLanguageSpanish: "Destacado para tu búsqueda", ChatLanguagePortuguese: "Destaque para sua busca", ChatLanguageFrench: "Mis en avant pour votre recherche",
+		ChatLanguageGerman: "Empfohlen für Ihre Suche", ChatLanguageItalian: "In evidenza per la tua ricerca",
+	},
+	"Search result": {
+		ChatLanguageSpanish: "Resultado de búsqueda", ChatLanguagePortuguese: "Resultado da busca", ChatLanguageFrench: "Résultat de recherche",
+		ChatLanguageGerman: "Suchergebnis", ChatLanguageItalian: "Risultato della ricerca",
+	},
+	"Featured product": {
+		ChatLanguageSpanish: "Producto destacado", ChatLanguagePortuguese: "Produto em destaque", ChatLanguageFrench: "Produit vedette",
+		ChatLanguageGerman: "Hervorgehobenes Produkt", ChatLanguageItalian: "Prodotto in evidenza",
+	},
+}
+
+// translateReason translates a suggestion reason, leaving reasons without a
+// translation in English
+func translateReason(language, reason string) string {
+	if language == "" || language == ChatLanguageEnglish {
+		return reason
+	}
+	if translated, ok := suggestionReasons[reason][language]; ok {
+		return translated
+	}
+	for format, translations := range suggestionReasons {
+		prefix, suffix, templated := strings.Cut(format, "%s")
+		translated, ok := translations[language]
+		if !templated || !ok || len(reason) < len(prefix)+len(suffix) ||
+			!strings.HasPrefix(reason, prefix) || !strings.HasSuffix(reason, suffix) {
+			continue
+		}
+		return fmt.Sprintf(translated, reason[len(prefix):len(reason)-len(suffix)])
+	}
+	return reason
+}
+
+// localizeSuggestions translates the suggestions' reasons into the language
+func localizeSuggestions(language string, suggestions []ProductSuggestion) {
+	for i := range suggestions {
+		suggestions[i].Reason = translateReason(language, suggestions[i].Reason)
+	}
+}
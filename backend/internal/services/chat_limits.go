@@ -0,0 +1,200 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ChatLimitRate is the limit of shoppers sending too many messages in a minute
+const ChatLimitRate = "chat_rate_limited"
+
+// ChatRateLimitReply is sent when a shopper reaches the rate limit
+const ChatRateLimitReply = "You're sending messages faster than I can keep up! Give me a moment and try again in %d seconds."
+
+// ChatLimitConfig controls how much shoppers may chat
+type ChatLimitConfig struct {
+	SessionPerMinute int // messages a chat session may send a minute; 0 for no limit
+	UserPerMinute    int // messages a signed in shopper may send a minute across their sessions; 0 for no limit
+	DailyTokens      int // language model tokens a shopper may use a day; 0 for no budget
+}
+
+// ChatLimitConfigFromEnv allows CHAT_SESSION_RATE_PER_MINUTE (10) messages a
+// minute per chat session, CHAT_USER_RATE_PER_MINUTE (20) per signed in
+// shopper and CHAT_DAILY_TOKEN_BUDGET (200000) tokens a UTC day per shopper,
+// the anonymous ones counted by session
+func ChatLimitConfigFromEnv() ChatLimitConfig {
+	return ChatLimitConfig{
+		SessionPerMinute: envInt("CHAT_SESSION_RATE_PER_MINUTE", 10),
+		UserPerMinute:    envInt("CHAT_USER_RATE_PER_MINUTE", 20),
+		DailyTokens:      envInt("CHAT_DAILY_TOKEN_BUDGET", 200000),
+	}
+}
+
+// ChatLimitError is returned when a shopper reached a chat limit. Its
+// Message is the friendly reply to show them.
+type ChatLimitError struct {
+	Limit      string // ChatLimitRate
+	RetryAfter time.Duration
+}
+
+func (e *ChatLimitError) Error() string {
+	return fmt.Sprintf("chat rate limit exceeded, retry in %s", e.RetryAfter)
+}
+
+// Message is the reply telling the shopper to slow down
+func (e *ChatLimitError) Message() string {
+	return fmt.Sprintf(ChatRateLimitReply, e.RetryAfterSeconds())
+}
+
+// RetryAfterSeconds is RetryAfter rounded up to whole seconds, for Retry-After
+func (e *ChatLimitError) RetryAfterSeconds() int {
+	return int((e.RetryAfter + time.Second - 1) / time.Second)
+}
+
+// ChatLimiter throttles chat messages and keeps each shopper to a daily
+// token budget. Message rates are counted in memory, per API instance; the
+// tokens used are kept in the database, where every instance adds to them.
+type ChatLimiter struct {
+	db       *gorm.DB
+	config   ChatLimitConfig
+	sessions *RequestThrottle
+	users    *RequestThrottle
+}
+
+// NewChatLimiter creates a new ChatLimiter
+func NewChatLimiter(db *gorm.DB, config ChatLimitConfig) *ChatLimiter {
+	return &ChatLimiter{
+		db:       db,
+		config:   config,
+		sessions: NewRequestThrottle(config.SessionPerMinute, time.Minute),
+		users:    NewRequestThrottle(config.UserPerMinute, time.Minute),
+	}
+}
+
+// chatShopper is who a chat's tokens are counted against
+func chatShopper(sessionID string, userID *uuid.UUID) string {
+	if userID != nil {
+		return "user:" + userID.String()
+	}
+	return "session:" + sessionID
+}
+
+// chatDay is the UTC date budgets are counted by
+func chatDay(now time.Time) string {
+	return now.UTC().Format("2006-01-02")
+}
+
+// Allow counts a message and returns a *ChatLimitError when the session or
+// shopper is sending too fast, or ErrLLMBudgetExhausted when they spent the
+// day's budget. When the budget can't be checked the message is allowed.
+func (l *ChatLimiter) Allow(ctx context.Context, sessionID string, userID *uuid.UUID, now time.Time) error {
+	if allowed, retry := l.sessions.Allow(sessionID, now); !allowed {
+		return &ChatLimitError{Limit: ChatLimitRate, RetryAfter: retry}
+	}
+	if userID != nil {
+		if allowed, retry := l.users.Allow(userID.String(), now); !allowed {
+			return &ChatLimitError{Limit: ChatLimitRate, RetryAfter: retry}
+		}
+	}
+
+	if l.config.DailyTokens <= 0 {
+		return nil
+	}
+	spent, err := l.TokensUsed(ctx, sessionID, userID, now)
+	if err != nil {
+		log.Printf("Warning: failed to check chat token budget: %v", err)
+		return nil
+	}
+	if spent >= l.config.DailyTokens {
+		return fmt.Errorf("%w: %d of the day's %d chat tokens used", ErrLLMBudgetExhausted, spent, l.config.DailyTokens)
+	}
+	return nil
+}
+
+// TokensUsed returns the tokens a shopper's chat used on now's UTC day
+func (l *ChatLimiter) TokensUsed(ctx context.Context, sessionID string, userID *uuid.UUID, now time.Time) (int, error) {
+	var usage models.ChatTokenUsage
+	err := l.db.WithContext(ctx).
+		Where("shopper = ? AND day = ?", chatShopper(sessionID, userID), chatDay(now)).
+		First(&usage).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get chat token usage: %v", err)
+	}
+	return usage.Tokens, nil
+}
+
+// Record adds the tokens a reply used to the shopper's day
+func (l *ChatLimiter) Record(ctx context.Context, sessionID string, userID *uuid.UUID, tokens int, now time.Time) error {
+	usage := models.ChatTokenUsage{
+		ID:        uuid.New(),
+		Shopper:   chatShopper(sessionID, userID),
+		Day:       chatDay(now),
+		Messages:  1,
+		Tokens:    tokens,
+		UpdatedAt: now,
+	}
+	err := l.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "shopper"}, {Name: "day"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"messages":   gorm.Expr("chat_token_usages.messages + excluded.messages"),
+			"tokens":     gorm.Expr("chat_token_usages.tokens + excluded.tokens"),
+			"updated_at": now,
+		}),
+	}).Create(&usage).Error
+	if err != nil {
+		return fmt.Errorf("failed to record chat token usage: %v", err)
+	}
+	return nil
+}
+
+// WithLimits throttles shoppers' messages and keeps them to a daily token
+// budget. Messages sent too fast return a *ChatLimitError, and ones over the
+// budget are answered by the fallback responder; neither reaches the model.
+func (s *ChatService) WithLimits(limits *ChatLimiter) *ChatService {
+	s.limits = limits
+	return s
+}
+
+// allowMessage checks a message against the chat limits. Shoppers sending
+// too fast get a *ChatLimitError; ones over their daily budget are let
+// through with overBudget set, for the fallback responder to answer.
+func (s *ChatService) allowMessage(ctx context.Context, sessionID string, userID *uuid.UUID) (overBudget bool, err error) {
+	if s.limits == nil {
+		return false, nil
+	}
+	err = s.limits.Allow(ctx, sessionID, userID, time.Now())
+	if errors.Is(err, ErrLLMBudgetExhausted) {
+		log.Printf("Warning: %v", err)
+		return true, nil
+	}
+	return false, err
+}
+
+// recordTokens counts a reply's tokens against the shopper's budget.
+// Providers that don't report usage are charged an estimate.
+func (s *ChatService) recordTokens(ctx context.Context, sessionID string, userID *uuid.UUID, request LLMRequest, response *LLMResponse) {
+	if s.limits == nil {
+		return
+	}
+	tokens := response.Usage.TotalTokens
+	if tokens == 0 {
+		for _, message := range request.Messages {
+			tokens += EstimateTokens(message.Content)
+		}
+		tokens += EstimateTokens(response.Content)
+	}
+	if err := s.limits.Record(ctx, sessionID, userID, tokens, time.Now()); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
@@ -0,0 +1,281 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sashabaranov/go-openai"
+)
+
+// Moderation stages: the shopper's message or the assistant's reply
+const (
+	ModerationStageInput  = "input"
+	ModerationStageOutput = "output"
+)
+
+// What moderation did with a message
+const (
+	ModerationFlagged = "flagged" // kept for admin review; the message went through
+	ModerationBlocked = "blocked" // refused or replaced
+	ModerationSkipped = "skipped" // the moderation provider failed, so the message went through unchecked
+)
+
+// Replies sent in place of blocked messages
+const (
+	ModerationInputReply  = "Sorry, I can't help with that. I'm here to help you find products and manage your order — what can I look for?"
+	ModerationOutputReply = "Sorry, I can't answer that. Could you ask me another way?"
+)
+
+// ModerationVerdict is a moderation provider's judgement of a text
+type ModerationVerdict struct {
+	Flagged    bool               `json:"flagged"`
+	Categories []string           `json:"categories"` // the categories flagged, e.g. harassment or violence
+	Scores     map[string]float64 `json:"scores"`     // the flagged categories' scores
+}
+
+// ContentModerator judges whether text breaks the content policy
+type ContentModerator interface {
+	Moderate(ctx context.Context, text string) (*ModerationVerdict, error)
+}
+
+// OpenAIModerator implements ContentModerator with OpenAI's moderation endpoint
+type OpenAIModerator struct {
+	client *openai.Client
+	model  string
+}
+
+// NewOpenAIModerator creates a new OpenAIModerator
+func NewOpenAIModerator(apiKey, model string) *OpenAIModerator {
+	return &OpenAIModerator{client: openai.NewClient(apiKey), model: model}
+}
+
+// Moderate sends the text to OpenAI's moderation endpoint
+func (m *OpenAIModerator) Moderate(ctx context.Context, text string) (*ModerationVerdict, error) {
+	response, err := m.client.Moderations(ctx, openai.ModerationRequest{Input: text, Model: m.model})
+	if err != nil {
+		return nil, fmt.Errorf("moderation request failed: %v", err)
+	}
+	if len(response.Results) == 0 {
+		return nil, fmt.Errorf("moderation returned no result")
+	}
+	result := response.Results[0]
+
+	// The categories and scores are structs tagged with the category names
+	var categories map[string]bool
+	var scores map[string]float64
+	if err := remarshal(result.Categories, &categories); err != nil {
+		return nil, err
+	}
+	if err := remarshal(result.CategoryScores, &scores); err != nil {
+		return nil, err
+	}
+	verdict := &ModerationVerdict{Flagged: result.Flagged, Scores: map[string]float64{}}
+	for category, flagged := range categories {
+		if flagged {
+			verdict.Categories = append(verdict.Categories, category)
+			verdict.Scores[category] = scores[category]
+		}
+	}
+	sort.Strings(verdict.Categories)
+	return verdict, nil
+}
+
+func remarshal(from, to interface{}) error {
+	encoded, err := json.Marshal(from)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(encoded, to)
+}
+
+// KeywordModerator implements ContentModerator with word lists, for stores
+// without a moderation provider and for tests
+type KeywordModerator struct {
+	patterns map[string]*regexp.Regexp
+}
+
+// NewKeywordModerator flags texts containing any of a category's words or
+// phrases, matched whole and ignoring case
+func NewKeywordModerator(categories map[string][]string) *KeywordModerator {
+	patterns := make(map[string]*regexp.Regexp, len(categories))
+	for category, words := range categories {
+		quoted := make([]string, 0, len(words))
+		for _, word := range words {
+			if word = strings.TrimSpace(word); word != "" {
+				quoted = append(quoted, regexp.QuoteMeta(word))
+			}
+		}
+		if len(quoted) > 0 {
+			patterns[category] = regexp.MustCompile(`(?i)\b(` + strings.Join(quoted, "|") + `)\b`)
+		}
+	}
+	return &KeywordModerator{patterns: patterns}
+}
+
+// Moderate flags the categories whose words appear in the text
+func (m *KeywordModerator) Moderate(ctx context.Context, text string) (*ModerationVerdict, error) {
+	verdict := &ModerationVerdict{Scores: map[string]float64{}}
+	for category, pattern := range m.patterns {
+		if pattern.MatchString(text) {
+			verdict.Flagged = true
+			verdict.Categories = append(verdict.Categories, category)
+			verdict.Scores[category] = 1
+		}
+	}
+	sort.Strings(verdict.Categories)
+	return verdict, nil
+}
+
+// ModerationConfig controls chat moderation
+type ModerationConfig struct {
+	Provider  string          // openai, keywords, or none to turn moderation off
+	Model     string          // moderation model
+	FlagOnly  map[string]bool // categories recorded for review without blocking the message
+	Blocklist []string        // words the keywords provider flags
+}
+
+// ModerationConfigFromEnv reads CHAT_MODERATION_PROVIDER (openai when
+// OPENAI_API_KEY is set, none otherwise), CHAT_MODERATION_MODEL (default
+// omni-moderation-latest), CHAT_MODERATION_FLAG_ONLY, a comma separated
+// list of categories that are only flagged for review, and
+// CHAT_MODERATION_BLOCKLIST, the comma separated words the keywords provider
+// flags as "blocklist". Messages in any other flagged category are blocked.
+func ModerationConfigFromEnv() ModerationConfig {
+	config := ModerationConfig{
+		Provider: strings.ToLower(strings.TrimSpace(os.Getenv("CHAT_MODERATION_PROVIDER"))),
+		Model:    strings.TrimSpace(os.Getenv("CHAT_MODERATION_MODEL")),
+		FlagOnly: map[string]bool{},
+	}
+	if config.Provider == "" {
+		config.Provider = "none"
+		if os.Getenv("OPENAI_API_KEY") != "" {
+			config.Provider = "openai"
+		}
+	}
+	if config.Model == "" {
+		config.Model = openai.ModerationOmniLatest
+	}
+	for _, category := range strings.Split(os.Getenv("CHAT_MODERATION_FLAG_ONLY"), ",") {
+		if category = strings.ToLower(strings.TrimSpace(category)); category != "" {
+			config.FlagOnly[category] = true
+		}
+	}
+	for _, word := range strings.Split(os.Getenv("CHAT_MODERATION_BLOCKLIST"), ",") {
+		if word = strings.TrimSpace(word); word != "" {
+			config.Blocklist = append(config.Blocklist, word)
+		}
+	}
+	return config
+}
+
+// ModeratorFromConfig returns the configured moderator, or nil when
+// moderation is turned off
+func ModeratorFromConfig(config ModerationConfig) ContentModerator {
+	switch config.Provider {
+	case "openai":
+		return NewOpenAIModerator(os.Getenv("OPENAI_API_KEY"), config.Model)
+	case "keywords":
+		return NewKeywordModerator(map[string][]string{"blocklist": config.Blocklist})
+	default:
+		return nil
+	}
+}
+
+// ChatModeration is what moderation found in a chat message. It is kept in
+// the message's metadata under "moderation" for admins to review; messages
+// that passed moderation have none.
+type ChatModeration struct {
+	Stage      string             `json:"stage"`
+	Action     string             `json:"action"`
+	Categories []string           `json:"categories,omitempty"`
+	Scores     map[string]float64 `json:"scores,omitempty"`
+	Error      string             `json:"error,omitempty"` // why the message went unchecked
+	CheckedAt  time.Time          `json:"checked_at"`
+}
+
+// Blocked reports whether the message was refused or replaced
+func (m *ChatModeration) Blocked() bool {
+	return m != nil && m.Action == ModerationBlocked
+}
+
+// WithModeration checks shoppers' messages and the assistant's replies
+// against the content policy. Flagged messages are blocked unless all their
+// categories are in config.FlagOnly, and either way recorded for review.
+func (s *ChatService) WithModeration(moderator ContentModerator, config ModerationConfig) *ChatService {
+	s.moderator = moderator
+	s.moderation = config
+	return s
+}
+
+// moderate checks a message, returning nil when it passed or there is no
+// moderator. When the provider fails the message goes through unchecked.
+func (s *ChatService) moderate(ctx context.Context, stage, text string) *ChatModeration {
+	if s.moderator == nil || strings.TrimSpace(text) == "" {
+		return nil
+	}
+	verdict, err := s.moderator.Moderate(ctx, text)
+	if err != nil {
+		log.Printf("Warning: chat %s moderation failed: %v", stage, err)
+		return &ChatModeration{Stage: stage, Action: ModerationSkipped, Error: err.Error(), CheckedAt: time.Now()}
+	}
+	if !verdict.Flagged {
+		return nil
+	}
+
+	moderation := &ChatModeration{
+		Stage:      stage,
+		Action:     ModerationFlagged,
+		Categories: verdict.Categories,
+		Scores:     verdict.Scores,
+		CheckedAt:  time.Now(),
+	}
+	for _, category := range verdict.Categories {
+		if !s.moderation.FlagOnly[category] {
+			moderation.Action = ModerationBlocked
+			break
+		}
+	}
+	// Flagged without a category is blocked too
+	if len(verdict.Categories) == 0 {
+		moderation.Action = ModerationBlocked
+	}
+	log.Printf("Chat moderation: %s %s %v", stage, moderation.Action, moderation.Categories)
+	return moderation
+}
+
+// moderationMetadata is the metadata of a user message with its moderation
+func moderationMetadata(moderation *ChatModeration) map[string]interface{} {
+	if moderation == nil {
+		return nil
+	}
+	return map[string]interface{}{"moderation": moderation}
+}
+
+// refuseModerated answers a shopper's message that moderation blocked
+// without sending it to the model
+func (s *ChatService) refuseModerated(ctx context.Context, sessionID string, userID *uuid.UUID, message string, moderation *ChatModeration) (*ChatResponse, error) {
+	if err := s.saveMessage(ctx, sessionID, userID, "user", message, moderationMetadata(moderation)); err != nil {
+		log.Printf("Warning: failed to save user message: %v", err)
+	}
+	if err := s.saveMessage(ctx, sessionID, userID, "assistant", ModerationInputReply, nil); err != nil {
+		log.Printf("Warning: failed to save assistant message: %v", err)
+	}
+	s.rememberTurn(ctx, sessionID, nil, time.Now())
+
+	return &ChatResponse{
+		Message: ModerationInputReply,
+		Context: map[string]interface{}{
+			"session_id": sessionID,
+			"user_id":    userID,
+			"moderation": moderation.Action,
+		},
+	}, nil
+}
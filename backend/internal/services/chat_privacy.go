@@ -0,0 +1,237 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// chatPurgeBatch is how many chat sessions a purge deletes at a time
+const chatPurgeBatch = 50
+
+// chatExportLineWidth is how many characters of a message fit on a line of
+// the PDF export
+const chatExportLineWidth = 94
+
+// ChatHistoryExport is everything a user said to the assistant and agents,
+// and what they answered
+type ChatHistoryExport struct {
+	UserID     uuid.UUID           `json:"user_id"`
+	ExportedAt time.Time           `json:"exported_at"`
+	Sessions   []ChatSessionExport `json:"sessions"`
+}
+
+// ChatSessionExport is one conversation in a chat history export
+type ChatSessionExport struct {
+	SessionID    string              `json:"session_id"`
+	Status       string              `json:"status"`
+	Locale       string              `json:"locale,omitempty"`
+	CreatedAt    time.Time           `json:"created_at"`
+	LastActivity time.Time           `json:"last_activity"`
+	Messages     []ChatMessageExport `json:"messages"`
+}
+
+// ChatMessageExport is one message in a chat history export
+type ChatMessageExport struct {
+	Role      string          `json:"role"`
+	Content   string          `json:"content"`
+	Metadata  json.RawMessage `json:"metadata,omitempty"` // suggestions, actions and the like sent with it
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// ChatPurgeResult is what deleting a user's chat history removed
+type ChatPurgeResult struct {
+	Sessions int64 `json:"sessions"` // conversations deleted with their messages
+	Messages int64 `json:"messages"`
+	Scrubbed int64 `json:"scrubbed"` // messages the user sent in conversations that aren't theirs, blanked
+}
+
+// ExportUserHistory returns every conversation of the user, oldest first
+func (s *ChatService) ExportUserHistory(ctx context.Context, userID uuid.UUID, now time.Time) (*ChatHistoryExport, error) {
+	db := s.db.WithContext(ctx)
+	var sessions []models.ChatSession
+	if err := db.Where("user_id = ?", userID).Order("created_at ASC, id ASC").Find(&sessions).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch chat sessions: %v", err)
+	}
+
+	export := &ChatHistoryExport{UserID: userID, ExportedAt: now, Sessions: make([]ChatSessionExport, len(sessions))}
+	index := make(map[uuid.UUID]int, len(sessions))
+	ids := make([]uuid.UUID, len(sessions))
+	for i, session := range sessions {
+		index[session.ID] = i
+		ids[i] = session.ID
+		export.Sessions[i] = ChatSessionExport{
+			SessionID:    session.SessionID,
+			Status:       session.Status,
+			Locale:       session.Locale,
+			CreatedAt:    session.CreatedAt,
+			LastActivity: session.LastActivity,
+			Messages:     []ChatMessageExport{},
+		}
+	}
+	if len(ids) == 0 {
+		return export, nil
+	}
+
+	var messages []models.ChatMessage
+	if err := db.Where("chat_session_id IN ?", ids).Order("created_at ASC, id ASC").Find(&messages).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch chat messages: %v", err)
+	}
+	for _, message := range messages {
+		session := &export.Sessions[index[message.ChatSessionID]]
+		exported := ChatMessageExport{Role: message.Role, Content: message.Content, CreatedAt: message.CreatedAt}
+		if len(message.Metadata) > 0 && string(message.Metadata) != "null" {
+			exported.Metadata = json.RawMessage(message.Metadata)
+		}
+		session.Messages = append(session.Messages, exported)
+	}
+	return export, nil
+}
+
+// RenderChatHistoryPDF renders a chat history export as a printable PDF
+// document, one conversation after another
+func RenderChatHistoryPDF(export *ChatHistoryExport) []byte {
+	lines := []pdfLine{
+		{Text: "Chat history", Heading: true},
+		{},
+		{Text: fmt.Sprintf("Exported:      %s", export.ExportedAt.UTC().Format("2006-01-02 15:04 MST"))},
+		{Text: fmt.Sprintf("Conversations: %d", len(export.Sessions))},
+	}
+	for _, session := range export.Sessions {
+		lines = append(lines,
+			pdfLine{},
+			pdfLine{Text: "Conversation of " + session.CreatedAt.UTC().Format("2006-01-02 15:04"), Heading: true},
+		)
+		for _, message := range session.Messages {
+			lines = append(lines, pdfLine{}, pdfLine{Text: fmt.Sprintf("%s, %s", message.Role, message.CreatedAt.UTC().Format("2006-01-02 15:04"))})
+			for _, paragraph := range strings.Split(message.Content, "\n") {
+				for _, line := range wrapText(paragraph, chatExportLineWidth) {
+					lines = append(lines, pdfLine{Text: "  " + line})
+				}
+			}
+		}
+	}
+	return renderPDF(lines)
+}
+
+// wrapText breaks text into lines of at most width runes, between words
+// where it can
+func wrapText(text string, width int) []string {
+	var lines []string
+	var line []rune
+	for _, word := range strings.Fields(text) {
+		runes := []rune(word)
+		for len(runes) > width {
+			if len(line) > 0 {
+				lines = append(lines, string(line))
+				line = nil
+			}
+			lines = append(lines, string(runes[:width]))
+			runes = runes[width:]
+		}
+		if len(line) > 0 && len(line)+1+len(runes) > width {
+			lines = append(lines, string(line))
+			line = nil
+		}
+		if len(line) > 0 {
+			line = append(line, ' ')
+		}
+		line = append(line, runes...)
+	}
+	if len(line) > 0 || len(lines) == 0 {
+		lines = append(lines, string(line))
+	}
+	return lines
+}
+
+// PurgeUserHistory deletes the user's conversations and every message in
+// them, a batch at a time, reporting progress to the job it runs in. Messages
+// the user sent in conversations that aren't theirs are blanked, metadata
+// and all, and the chat analytics and storefront events kept for reports no
+// longer point at the user.
+func (s *ChatService) PurgeUserHistory(ctx context.Context, userID uuid.UUID) (*ChatPurgeResult, error) {
+	db := s.db.WithContext(ctx)
+	var sessions []models.ChatSession
+	if err := db.Select("id", "session_id").Where("user_id = ?", userID).Find(&sessions).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch chat sessions: %v", err)
+	}
+
+	result := &ChatPurgeResult{}
+	total := len(sessions) + 1
+	for start := 0; start < len(sessions); start += chatPurgeBatch {
+		end := start + chatPurgeBatch
+		if end > len(sessions) {
+			end = len(sessions)
+		}
+		ids := make([]uuid.UUID, 0, end-start)
+		sessionIDs := make([]string, 0, end-start)
+		for _, session := range sessions[start:end] {
+			ids = append(ids, session.ID)
+			sessionIDs = append(sessionIDs, session.SessionID)
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			deleted := tx.Where("chat_session_id IN ?", ids).Delete(&models.ChatMessage{})
+			if deleted.Error != nil {
+				return deleted.Error
+			}
+			result.Messages += deleted.RowsAffected
+			if err := tx.Where("session_id IN ?", sessionIDs).Delete(&models.EscalationMessage{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("chat_session_id IN ?", sessionIDs).Delete(&models.SessionLink{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&models.StorefrontEvent{}).Where("chat_session_id IN ?", sessionIDs).
+				Updates(map[string]interface{}{"chat_session_id": "", "user_id": nil}).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&models.SearchMiss{}).Where("session_id IN ?", sessionIDs).
+				Updates(map[string]interface{}{"session_id": "", "user_id": nil}).Error; err != nil {
+				return err
+			}
+			deleted = tx.Where("id IN ?", ids).Delete(&models.ChatSession{})
+			if deleted.Error != nil {
+				return deleted.Error
+			}
+			result.Sessions += deleted.RowsAffected
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to delete chat sessions: %v", err)
+		}
+		reportJobProgress(ctx, end, total)
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		scrubbed := tx.Model(&models.ChatMessage{}).Where("user_id = ?", userID).
+			Updates(map[string]interface{}{"content": "", "metadata": nil, "user_id": nil})
+		if scrubbed.Error != nil {
+			return scrubbed.Error
+		}
+		result.Scrubbed = scrubbed.RowsAffected
+		if err := tx.Where("user_id = ?", userID).Delete(&models.EscalationMessage{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("shopper = ?", "user:"+userID.String()).Delete(&models.ChatTokenUsage{}).Error; err != nil {
+			return err
+		}
+		for _, record := range []interface{}{&models.ChatEvent{}, &models.ChatAnalytics{}, &models.SearchMiss{}, &models.StorefrontEvent{}, &models.SessionLink{}} {
+			if err := tx.Model(record).Where("user_id = ?", userID).Update("user_id", nil).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scrub chat messages: %v", err)
+	}
+	reportJobProgress(ctx, total, total)
+	return result, nil
+}
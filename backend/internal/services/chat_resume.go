@@ -0,0 +1,284 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+)
+
+// chatResumeIdleAfter is how long a conversation must have been idle before
+// resuming it rebuilds the assistant's context and greets the shopper again
+const chatResumeIdleAfter = 30 * time.Minute
+
+// chatResumeSummaryTurns is how many of the shopper's last messages the
+// resume summary recalls
+const chatResumeSummaryTurns = 3
+
+// ChatResumeContext is what the assistant remembers of a conversation picked
+// up again after a break. It's kept in the session context and quoted in the
+// system prompt of later turns.
+type ChatResumeContext struct {
+	Summary          string                 `json:"summary"`
+	Interests        []string               `json:"interests,omitempty"`       // categories of the products shown, most shown first
+	RecentProducts   []string               `json:"recent_products,omitempty"` // products shown, latest first
+	Preferences      map[string]interface{} `json:"preferences,omitempty"`     // the user's saved preferences
+	RestoredItems    []string               `json:"restored_items,omitempty"`
+	UnavailableItems []string               `json:"unavailable_items,omitempty"`
+	ResumedAt        time.Time              `json:"resumed_at"`
+}
+
+// chatCartSnapshot is the cart as it was after a conversation's last turn
+type chatCartSnapshot struct {
+	Items   []CartItem `json:"items"`
+	SavedAt time.Time  `json:"saved_at"`
+}
+
+// chatSuggestionMetadata reads the products suggested in an assistant message
+type chatSuggestionMetadata struct {
+	Suggestions []struct {
+		Product *struct {
+			ID uuid.UUID `json:"id"`
+		} `json:"product"`
+	} `json:"suggestions"`
+}
+
+// rememberTurn records a conversation's activity and the cart it left, so
+// the cart can be offered again when the conversation is resumed
+func (s *ChatService) rememberTurn(ctx context.Context, sessionID string, cart *CartResponse, now time.Time) {
+	updates := map[string]interface{}{"last_activity": now}
+	if cart != nil {
+		snapshot, err := json.Marshal(chatCartSnapshot{Items: cart.Items, SavedAt: now})
+		if err == nil {
+			updates["cart_state"] = datatypes.JSON(snapshot)
+		}
+	}
+	err := s.db.WithContext(ctx).Model(&models.ChatSession{}).Where("session_id = ?", sessionID).Updates(updates).Error
+	if err != nil {
+		log.Printf("Warning: failed to record chat activity: %v", err)
+	}
+}
+
+// rebuildResumeContext recalls what an idle conversation was about, puts
+// back the cart it left when the shopper's cart is now empty, and greets
+// them with where they left off. The greeting is saved as an assistant
+// message and the recalled context in the session.
+func (s *ChatService) rebuildResumeContext(ctx context.Context, session *models.ChatSession, userID uuid.UUID, now time.Time) (*ChatResumeContext, string, *CartResponse, error) {
+	history, err := s.GetConversationHistory(ctx, session.SessionID, 20)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("failed to fetch chat history: %v", err)
+	}
+
+	resume := &ChatResumeContext{
+		Summary:   resumeSummary(history),
+		ResumedAt: now,
+	}
+	resume.Interests, resume.RecentProducts = s.discussedInterests(ctx, history)
+
+	var user models.User
+	if err := s.db.WithContext(ctx).Select("id", "preferences").Where("id = ?", userID).First(&user).Error; err == nil && len(user.Preferences) > 0 {
+		if err := json.Unmarshal(user.Preferences, &resume.Preferences); err != nil {
+			log.Printf("Warning: failed to parse preferences of user %s: %v", userID, err)
+		}
+	}
+
+	cart, err := s.restoreCart(ctx, session, userID, resume)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	if err := setSessionContextKey(ctx, s.db, session.SessionID, "resume", resume); err != nil {
+		return nil, "", nil, fmt.Errorf("failed to save chat context: %v", err)
+	}
+
+	greeting := resumeGreeting(resume, cart)
+	if err := s.saveMessage(ctx, session.SessionID, &userID, "assistant", greeting, map[string]interface{}{"resumed": true}); err != nil {
+		return nil, "", nil, fmt.Errorf("failed to save greeting: %v", err)
+	}
+	return resume, greeting, cart, nil
+}
+
+// restoreCart puts back the items a conversation's cart held when the
+// shopper's cart is empty now and they haven't ordered since. Items that
+// can no longer be bought are named in resume instead.
+func (s *ChatService) restoreCart(ctx context.Context, session *models.ChatSession, userID uuid.UUID, resume *ChatResumeContext) (*CartResponse, error) {
+	cart, err := s.cartService.GetCart(session.SessionID, &userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshot chatCartSnapshot
+	if len(session.CartState) == 0 || json.Unmarshal(session.CartState, &snapshot) != nil || len(snapshot.Items) == 0 || cart.ItemCount > 0 {
+		return cart, nil
+	}
+
+	// A cart that was checked out since isn't wanted again
+	var ordered int64
+	err = s.db.WithContext(ctx).Model(&models.Order{}).
+		Where("user_id = ? AND created_at >= ?", userID, snapshot.SavedAt).
+		Count(&ordered).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to check orders: %v", err)
+	}
+	if ordered > 0 {
+		return cart, nil
+	}
+
+	for _, item := range snapshot.Items {
+		err := s.cartService.AddToCart(session.SessionID, &userID, AddToCartRequest{
+			ProductID: item.ProductID,
+			VariantID: item.VariantID,
+			Quantity:  item.Quantity,
+		})
+		if err != nil {
+			resume.UnavailableItems = append(resume.UnavailableItems, item.ProductName)
+			continue
+		}
+		resume.RestoredItems = append(resume.RestoredItems, item.ProductName)
+	}
+	if len(resume.RestoredItems) == 0 {
+		return cart, nil
+	}
+	return s.cartService.GetCart(session.SessionID, &userID)
+}
+
+// discussedInterests returns the categories of the products suggested in a
+// conversation, most suggested first, and the products, latest first
+func (s *ChatService) discussedInterests(ctx context.Context, history []ChatMessageService) ([]string, []string) {
+	var productIDs []uuid.UUID
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Role != "assistant" || history[i].Metadata == nil {
+			continue
+		}
+		raw, err := json.Marshal(history[i].Metadata)
+		if err != nil {
+			continue
+		}
+		var metadata chatSuggestionMetadata
+		if json.Unmarshal(raw, &metadata) != nil {
+			continue
+		}
+		for _, suggestion := range metadata.Suggestions {
+			if suggestion.Product != nil && suggestion.Product.ID != uuid.Nil {
+				productIDs = append(productIDs, suggestion.Product.ID)
+			}
+		}
+	}
+	if len(productIDs) == 0 {
+		return nil, nil
+	}
+
+	var products []models.Product
+	if err := s.db.WithContext(ctx).Preload("Category").Where("id IN ?", productIDs).Find(&products).Error; err != nil {
+		log.Printf("Warning: failed to fetch discussed products: %v", err)
+		return nil, nil
+	}
+	byID := make(map[uuid.UUID]models.Product, len(products))
+	for _, product := range products {
+		byID[product.ID] = product
+	}
+
+	counts := map[string]int{}
+	var categories, names []string
+	seen := map[uuid.UUID]bool{}
+	for _, id := range productIDs {
+		product, ok := byID[id]
+		if !ok {
+			continue
+		}
+		if category := product.Category.Name; category != "" {
+			if counts[category] == 0 {
+				categories = append(categories, category)
+			}
+			counts[category]++
+		}
+		if !seen[id] && len(names) < 5 {
+			seen[id] = true
+			names = append(names, product.Name)
+		}
+	}
+	sort.SliceStable(categories, func(i, j int) bool { return counts[categories[i]] > counts[categories[j]] })
+	if len(categories) > 3 {
+		categories = categories[:3]
+	}
+	return categories, names
+}
+
+// resumeSummary recalls the shopper's last few messages
+func resumeSummary(history []ChatMessageService) string {
+	var asked []string
+	for i := len(history) - 1; i >= 0 && len(asked) < chatResumeSummaryTurns; i-- {
+		if history[i].Role == "user" {
+			asked = append([]string{fmt.Sprintf("%q", truncateRunes(history[i].Content, 80))}, asked...)
+		}
+	}
+	if len(asked) == 0 {
+		return ""
+	}
+	return "The customer last asked: " + strings.Join(asked, "; ")
+}
+
+// resumeGreeting welcomes a shopper back to where they left off
+func resumeGreeting(resume *ChatResumeContext, cart *CartResponse) string {
+	greeting := "Welcome back!"
+	switch {
+	case len(resume.Interests) > 0:
+		greeting += fmt.Sprintf(" Last time you were looking at %s.", strings.ToLower(resume.Interests[0]))
+	case len(resume.RecentProducts) > 0:
+		greeting += fmt.Sprintf(" Last time you were looking at the %s.", resume.RecentProducts[0])
+	}
+
+	switch {
+	case len(resume.RestoredItems) == 1:
+		greeting += fmt.Sprintf(" I've put the %s from your last visit back in your cart.", resume.RestoredItems[0])
+	case len(resume.RestoredItems) > 1:
+		greeting += fmt.Sprintf(" I've put the %d items from your last visit back in your cart.", len(resume.RestoredItems))
+	case cart != nil && cart.ItemCount == 1:
+		greeting += " Your cart still has 1 item."
+	case cart != nil && cart.ItemCount > 1:
+		greeting += fmt.Sprintf(" Your cart still has %d items.", cart.ItemCount)
+	}
+	if len(resume.UnavailableItems) > 0 {
+		greeting += fmt.Sprintf(" Sorry, %s is no longer available.", strings.Join(resume.UnavailableItems, ", "))
+	}
+
+	return greeting + " Would you like to pick up where we left off?"
+}
+
+// sessionResumeContext returns the recalled context of a resumed
+// conversation, or nil if it wasn't resumed
+func (s *ChatService) sessionResumeContext(ctx context.Context, sessionID string) *ChatResumeContext {
+	var session models.ChatSession
+	if err := s.db.WithContext(ctx).Select("id", "context").Where("session_id = ?", sessionID).First(&session).Error; err != nil || len(session.Context) == 0 {
+		return nil
+	}
+	var contextMap struct {
+		Resume *ChatResumeContext `json:"resume"`
+	}
+	if err := json.Unmarshal(session.Context, &contextMap); err != nil {
+		return nil
+	}
+	return contextMap.Resume
+}
+
+// resumePrompt tells the assistant what was discussed before the break
+func (s *ChatService) resumePrompt(resume *ChatResumeContext) string {
+	prompt := `This customer came back to an earlier conversation. What was discussed before (JSON):
+` + "```earlier-conversation\n" + s.sanitizer.QuoteData(map[string]interface{}{
+		"summary":           s.cleanData(resume.Summary),
+		"interests":         resume.Interests,
+		"recent_products":   resume.RecentProducts,
+		"saved_preferences": resume.Preferences,
+		"restored_to_cart":  resume.RestoredItems,
+		"no_longer_sold":    resume.UnavailableItems,
+	}) + "\n```"
+	return prompt + `
+Use it to continue naturally, without repeating the welcome back greeting they already received.`
+}
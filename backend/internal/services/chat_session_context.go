@@ -0,0 +1,45 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// setSessionContextKey keeps value under key in a chat session's context,
+// leaving the other keys as they are; a nil value removes the key. The
+// session row is locked while the context is rewritten, so memory, checkout,
+// resume and model override updates made at once don't undo each other.
+// Sessions that don't exist return gorm.ErrRecordNotFound.
+func setSessionContextKey(ctx context.Context, db *gorm.DB, sessionID, key string, value interface{}) error {
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var session models.ChatSession
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id", "context").Where("session_id = ?", sessionID).First(&session).Error; err != nil {
+			return err
+		}
+
+		contextMap := map[string]interface{}{}
+		if len(session.Context) > 0 {
+			if err := json.Unmarshal(session.Context, &contextMap); err != nil {
+				contextMap = map[string]interface{}{}
+			}
+		}
+		if value == nil {
+			delete(contextMap, key)
+		} else {
+			contextMap[key] = value
+		}
+
+		contextJSON, err := json.Marshal(contextMap)
+		if err != nil {
+			return fmt.Errorf("failed to encode chat context: %v", err)
+		}
+		return tx.Model(&session).Update("context", datatypes.JSON(contextJSON)).Error
+	})
+}
@@ -0,0 +1,233 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// chatSessionTTL is how long a chat session stays active after it's used
+const chatSessionTTL = 24 * time.Hour
+
+// chatPreviewLength caps the message text shown in session previews
+const chatPreviewLength = 140
+
+// resumedHistoryLimit is how many messages are returned when a session is resumed
+const resumedHistoryLimit = 50
+
+// ChatSessionPreview summarises one of a user's past conversations
+type ChatSessionPreview struct {
+	SessionID     string    `json:"session_id"`
+	Title         string    `json:"title"` // the shopper's first message
+	LastMessage   string    `json:"last_message"`
+	LastRole      string    `json:"last_role"`
+	MessageCount  int       `json:"message_count"`
+	Status        string    `json:"status"`
+	StartedAt     time.Time `json:"started_at"`
+	LastMessageAt time.Time `json:"last_message_at"`
+}
+
+// ChatSessionListResponse is a page of a user's past conversations, most
+// recent first
+type ChatSessionListResponse struct {
+	Sessions    []ChatSessionPreview `json:"sessions"`
+	Total       int64                `json:"total"`
+	Page        int                  `json:"page"`
+	Limit       int                  `json:"limit"`
+	TotalPages  int                  `json:"total_pages"`
+	HasNext     bool                 `json:"has_next"`
+	HasPrevious bool                 `json:"has_previous"`
+}
+
+// ResumedChatSession is a past conversation reopened for the user, with
+// its latest messages in order so the client can show them before connecting.
+// Conversations that had gone idle also come with a welcome back greeting,
+// already among the messages, and what the assistant recalled of them.
+type ResumedChatSession struct {
+	SessionID string               `json:"session_id"`
+	Messages  []ChatMessageService `json:"messages"`
+	ExpiresAt time.Time            `json:"expires_at"`
+	Greeting  string               `json:"greeting,omitempty"`
+	Context   *ChatResumeContext   `json:"context,omitempty"`
+	Cart      *CartResponse        `json:"cart,omitempty"`
+}
+
+// chatSessionStats is a session with its message count
+type chatSessionStats struct {
+	ChatSessionID uuid.UUID
+	SessionID     string
+	Status        string
+	CreatedAt     time.Time
+	MessageCount  int
+}
+
+// ListUserSessions lists the conversations a signed in user has had, on any
+// device, with a preview of each. Sessions without messages are left out.
+func (s *ChatService) ListUserSessions(ctx context.Context, userID uuid.UUID, page, limit int) (*ChatSessionListResponse, error) {
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 50 {
+		limit = 20
+	}
+
+	db := s.db.WithContext(ctx)
+	stats := db.Model(&models.ChatMessage{}).
+		Select("chat_session_id, COUNT(*) AS message_count, MAX(created_at) AS last_message_at").
+		Group("chat_session_id")
+	query := db.Table("chat_sessions").
+		Joins("JOIN (?) AS stats ON stats.chat_session_id = chat_sessions.id", stats).
+		Where("chat_sessions.user_id = ?", userID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, fmt.Errorf("failed to count chat sessions: %v", err)
+	}
+
+	var rows []chatSessionStats
+	err := query.
+		Select("chat_sessions.id AS chat_session_id, chat_sessions.session_id, chat_sessions.status, chat_sessions.created_at, stats.message_count").
+		Order("stats.last_message_at DESC, chat_sessions.id ASC").
+		Offset((page - 1) * limit).
+		Limit(limit).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch chat sessions: %v", err)
+	}
+
+	ids := make([]uuid.UUID, len(rows))
+	for i, row := range rows {
+		ids[i] = row.ChatSessionID
+	}
+	firstQuestions, err := s.sessionMessages(ctx, ids, "MIN", "user")
+	if err != nil {
+		return nil, err
+	}
+	lastMessages, err := s.sessionMessages(ctx, ids, "MAX", "")
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]ChatSessionPreview, 0, len(rows))
+	for _, row := range rows {
+		preview := ChatSessionPreview{
+			SessionID:    row.SessionID,
+			Title:        "Conversation",
+			MessageCount: row.MessageCount,
+			Status:       row.Status,
+			StartedAt:    row.CreatedAt,
+		}
+		if first, ok := firstQuestions[row.ChatSessionID]; ok {
+			preview.Title = truncateRunes(first.Content, chatPreviewLength)
+		}
+		if last, ok := lastMessages[row.ChatSessionID]; ok {
+			preview.LastMessage = truncateRunes(last.Content, chatPreviewLength)
+			preview.LastRole = last.Role
+			preview.LastMessageAt = last.CreatedAt
+		}
+		sessions = append(sessions, preview)
+	}
+
+	totalPages := int((total + int64(limit) - 1) / int64(limit))
+	return &ChatSessionListResponse{
+		Sessions:    sessions,
+		Total:       total,
+		Page:        page,
+		Limit:       limit,
+		TotalPages:  totalPages,
+		HasNext:     page < totalPages,
+		HasPrevious: page > 1,
+	}, nil
+}
+
+// sessionMessages returns the first (MIN) or last (MAX) message of each
+// session, optionally only counting messages with a role
+func (s *ChatService) sessionMessages(ctx context.Context, chatSessionIDs []uuid.UUID, pick, role string) (map[uuid.UUID]models.ChatMessage, error) {
+	byID := map[uuid.UUID]models.ChatMessage{}
+	if len(chatSessionIDs) == 0 {
+		return byID, nil
+	}
+
+	latest := s.db.Table("chat_messages AS m").
+		Select(pick + "(m.created_at)").
+		Where("m.chat_session_id = chat_messages.chat_session_id")
+	query := s.db.WithContext(ctx).Where("chat_session_id IN ?", chatSessionIDs)
+	if role != "" {
+		latest = latest.Where("m.role = ?", role)
+		query = query.Where("role = ?", role)
+	}
+
+	var messages []models.ChatMessage
+	if err := query.Where("created_at = (?)", latest).Order("created_at ASC").Find(&messages).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch chat previews: %v", err)
+	}
+	for _, message := range messages {
+		if _, seen := byID[message.ChatSessionID]; !seen {
+			byID[message.ChatSessionID] = message
+		}
+	}
+	return byID, nil
+}
+
+// ResumeUserSession reopens one of the user's past conversations, wherever
+// it was started, so it can be continued over the chat socket with its ID.
+// A conversation idle for chatResumeIdleAfter or longer has its context
+// rebuilt and its cart restored first.
+func (s *ChatService) ResumeUserSession(ctx context.Context, userID uuid.UUID, sessionID string, now time.Time) (*ResumedChatSession, error) {
+	var session models.ChatSession
+	err := s.db.WithContext(ctx).Where("session_id = ? AND user_id = ?", sessionID, userID).First(&session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrChatSessionForbidden
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch chat session: %v", err)
+	}
+
+	idle := now.After(session.ExpiresAt) || now.Sub(session.LastActivity) >= chatResumeIdleAfter
+	expiresAt := now.Add(chatSessionTTL)
+	err = s.db.WithContext(ctx).Model(&session).Updates(map[string]interface{}{
+		"status":        "active",
+		"last_activity": now,
+		"expires_at":    expiresAt,
+	}).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to resume chat session: %v", err)
+	}
+
+	resumed := &ResumedChatSession{
+		SessionID: sessionID,
+		ExpiresAt: expiresAt,
+	}
+	if idle {
+		resumed.Context, resumed.Greeting, resumed.Cart, err = s.rebuildResumeContext(ctx, &session, userID, now)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	resumed.Messages, err = s.GetConversationHistory(ctx, sessionID, resumedHistoryLimit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch chat history: %v", err)
+	}
+	if resumed.Messages == nil {
+		resumed.Messages = []ChatMessageService{}
+	}
+	return resumed, nil
+}
+
+// ClaimSession gives an anonymous session to the user who signed in while
+// using it, so it's listed with their other conversations
+func (s *ChatService) ClaimSession(ctx context.Context, sessionID string, userID uuid.UUID) error {
+	err := s.db.WithContext(ctx).Model(&models.ChatSession{}).
+		Where("session_id = ? AND user_id IS NULL", sessionID).
+		Update("user_id", userID).Error
+	if err != nil {
+		return fmt.Errorf("failed to claim chat session: %v", err)
+	}
+	return nil
+}
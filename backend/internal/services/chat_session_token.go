@@ -0,0 +1,135 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ChatSessionCookie holds an anonymous shopper's chat session token, which
+// proves the session is theirs
+const ChatSessionCookie = "chat_session"
+
+// chatSessionTokenPrefix starts every issued token, so they are easy to
+// tell apart from the free-form IDs clients used to make up
+const chatSessionTokenPrefix = "cs_"
+
+// chatSessionRandomBytes is the unguessable part of a token
+const chatSessionRandomBytes = 24
+
+// ErrChatSessionForbidden is returned when a requester doesn't own a chat session
+var ErrChatSessionForbidden = errors.New("chat session not found")
+
+var (
+	ephemeralChatSecretOnce sync.Once
+	ephemeralChatSecret     []byte
+)
+
+// ChatHistoryThrottleFromEnv allows CHAT_HISTORY_RATE_PER_MINUTE (default
+// 30) chat history reads per client address a minute
+func ChatHistoryThrottleFromEnv() *RequestThrottle {
+	return NewRequestThrottle(envInt("CHAT_HISTORY_RATE_PER_MINUTE", 30), time.Minute)
+}
+
+// ChatSessionTokens issues and checks chat session IDs. An ID is a random
+// value with an HMAC-SHA256 signature, so IDs can't be guessed and made-up
+// ones are rejected without a database lookup.
+type ChatSessionTokens struct {
+	secret []byte
+}
+
+// NewChatSessionTokens creates ChatSessionTokens signing with secret
+func NewChatSessionTokens(secret []byte) *ChatSessionTokens {
+	return &ChatSessionTokens{secret: secret}
+}
+
+// ChatSessionTokensFromEnv signs with CHAT_SESSION_SECRET, falling back to
+// JWT_SECRET. Without either, a random secret is used, and sessions don't
+// survive a restart.
+func ChatSessionTokensFromEnv() *ChatSessionTokens {
+	if secret := os.Getenv("CHAT_SESSION_SECRET"); secret != "" {
+		return NewChatSessionTokens([]byte(secret))
+	}
+	if secret := os.Getenv("JWT_SECRET"); secret != "" {
+		return NewChatSessionTokens([]byte(secret))
+	}
+
+	ephemeralChatSecretOnce.Do(func() {
+		ephemeralChatSecret = make([]byte, 32)
+		if _, err := rand.Read(ephemeralChatSecret); err != nil {
+			log.Fatalf("Failed to generate chat session secret: %v", err)
+		}
+		log.Println("Warning: CHAT_SESSION_SECRET is not set, chat sessions won't survive a restart")
+	})
+	return NewChatSessionTokens(ephemeralChatSecret)
+}
+
+// Issue returns a new session ID
+func (t *ChatSessionTokens) Issue() (string, error) {
+	random := make([]byte, chatSessionRandomBytes)
+	if _, err := rand.Read(random); err != nil {
+		return "", fmt.Errorf("failed to generate chat session: %v", err)
+	}
+	payload := chatSessionTokenPrefix + base64.RawURLEncoding.EncodeToString(random)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(t.signature(payload)), nil
+}
+
+// Valid reports whether a session ID was issued by this server
+func (t *ChatSessionTokens) Valid(token string) bool {
+	payload, encodedSignature, found := strings.Cut(token, ".")
+	if !found || !strings.HasPrefix(payload, chatSessionTokenPrefix) {
+		return false
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(encodedSignature)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal(signature, t.signature(payload))
+}
+
+func (t *ChatSessionTokens) signature(payload string) []byte {
+	mac := hmac.New(sha256.New, t.secret)
+	mac.Write([]byte("chat-session:" + payload))
+	return mac.Sum(nil)
+}
+
+// AuthorizeSession checks a requester may use a chat session. Sessions
+// started while signed in belong to that user; anonymous ones to whoever
+// holds the session cookie. Sessions that don't exist yet belong to the
+// cookie holder too. Any failure is ErrChatSessionForbidden, so requesters
+// can't tell someone else's session from one that doesn't exist.
+func (s *ChatService) AuthorizeSession(ctx context.Context, tokens *ChatSessionTokens, sessionID string, userID *uuid.UUID, cookieToken string) error {
+	if !tokens.Valid(sessionID) {
+		return ErrChatSessionForbidden
+	}
+
+	var session models.ChatSession
+	err := s.db.WithContext(ctx).Select("id", "user_id").Where("session_id = ?", sessionID).First(&session).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("failed to fetch chat session: %v", err)
+	}
+
+	if err == nil && session.UserID != nil {
+		if userID == nil || *userID != *session.UserID {
+			return ErrChatSessionForbidden
+		}
+		return nil
+	}
+	if !hmac.Equal([]byte(cookieToken), []byte(sessionID)) {
+		return ErrChatSessionForbidden
+	}
+	return nil
+}
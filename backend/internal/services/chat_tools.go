@@ -0,0 +1,231 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Chat tool limits
+const (
+	maxChatToolQuantity = 99
+	chatSearchLimit     = 5
+)
+
+// chatTools are the functions the assistant calls to act on the cart. OpenAI
+// strict mode requires every property to be listed as required.
+var chatTools = []LLMTool{
+	{
+		Name:        "add_to_cart",
+		Description: "Add a product from the available products to the customer's cart.",
+		Parameters: toolSchema(map[string]interface{}{
+			"product_id": map[string]interface{}{"type": "string", "description": "The id of the product"},
+			"quantity":   map[string]interface{}{"type": "integer", "description": "How many to add, 1 unless the customer said otherwise"},
+		}),
+	},
+	{
+		Name:        "remove_from_cart",
+		Description: "Remove a product from the customer's cart.",
+		Parameters: toolSchema(map[string]interface{}{
+			"product_id": map[string]interface{}{"type": "string", "description": "The id of the product"},
+		}),
+	},
+	{
+		Name:        "search_products",
+		Description: "Search the catalog when none of the available products fit what the customer asks for. The results are shown to the customer as product cards.",
+		Parameters: toolSchema(map[string]interface{}{
+			"query": map[string]interface{}{"type": "string", "description": "What the customer is looking for, e.g. waterproof hiking boots"},
+		}),
+	},
+	{
+		Name:        "set_gift_options",
+		Description: "Wrap the order as a gift or set its gift message. Send both fields, keeping the current choice for the one the customer didn't mention.",
+		Parameters: toolSchema(map[string]interface{}{
+			"gift_wrap":    map[string]interface{}{"type": "boolean"},
+			"gift_message": map[string]interface{}{"type": "string", "description": "Empty for no message"},
+		}),
+	},
+	{
+		Name:        "share_cart",
+		Description: "Create a link the customer can send others to see their cart. The link is added to your message automatically.",
+		Parameters:  toolSchema(map[string]interface{}{}),
+	},
+	{
+		Name:        "checkout",
+		Description: "Take the customer to checkout when they are ready to pay for their cart.",
+		Parameters:  toolSchema(map[string]interface{}{}),
+	},
+}
+
+// toolSchema is the JSON schema of an object with the given properties, all required
+func toolSchema(properties map[string]interface{}) map[string]interface{} {
+	required := make([]string, 0, len(properties))
+	for name := range properties {
+		required = append(required, name)
+	}
+	sort.Strings(required)
+	return map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"required":             required,
+		"additionalProperties": false,
+	}
+}
+
+// Arguments of the chat tools
+type (
+	cartItemToolArgs struct {
+		ProductID string `json:"product_id"`
+		Quantity  *int   `json:"quantity,omitempty"`
+	}
+	searchToolArgs struct {
+		Query string `json:"query"`
+	}
+	giftOptionsToolArgs struct {
+		GiftWrap    bool   `json:"gift_wrap"`
+		GiftMessage string `json:"gift_message"`
+	}
+	noToolArgs struct{}
+)
+
+// toolActions turns the assistant's tool calls into actions. Calls to unknown
+// tools or with invalid arguments are dropped, so executeAction only sees
+// well-formed actions.
+func toolActions(calls []LLMToolCall) []ChatAction {
+	var actions []ChatAction
+	for _, call := range calls {
+		action, err := toolAction(call)
+		if err != nil {
+			log.Printf("Warning: rejected %s tool call: %v", call.Name, err)
+			continue
+		}
+		actions = append(actions, action)
+	}
+	return actions
+}
+
+// toolAction validates a tool call's arguments and returns its action
+func toolAction(call LLMToolCall) (ChatAction, error) {
+	var args interface{}
+	switch call.Name {
+	case "add_to_cart", "remove_from_cart":
+		var item cartItemToolArgs
+		if err := decodeToolArgs(call.Arguments, &item); err != nil {
+			return ChatAction{}, err
+		}
+		if _, err := uuid.Parse(item.ProductID); err != nil {
+			return ChatAction{}, fmt.Errorf("invalid product_id %q", item.ProductID)
+		}
+		if call.Name == "add_to_cart" {
+			if item.Quantity == nil {
+				one := 1
+				item.Quantity = &one
+			}
+			if *item.Quantity < 1 || *item.Quantity > maxChatToolQuantity {
+				return ChatAction{}, fmt.Errorf("quantity must be between 1 and %d", maxChatToolQuantity)
+			}
+		} else {
+			item.Quantity = nil
+		}
+		args = item
+
+	case "search_products":
+		var search searchToolArgs
+		if err := decodeToolArgs(call.Arguments, &search); err != nil {
+			return ChatAction{}, err
+		}
+		search.Query = strings.TrimSpace(search.Query)
+		if search.Query == "" {
+			return ChatAction{}, fmt.Errorf("query is required")
+		}
+		args = search
+
+	case "set_gift_options":
+		var gift giftOptionsToolArgs
+		if err := decodeToolArgs(call.Arguments, &gift); err != nil {
+			return ChatAction{}, err
+		}
+		args = gift
+
+	case "set_shipping_address":
+		var address addressToolArgs
+		if err := decodeToolArgs(call.Arguments, &address); err != nil {
+			return ChatAction{}, err
+		}
+		if err := address.validate(); err != nil {
+			return ChatAction{}, err
+		}
+		args = address
+
+	case "share_cart", "checkout", "place_order":
+		var none noToolArgs
+		if err := decodeToolArgs(call.Arguments, &none); err != nil {
+			return ChatAction{}, err
+		}
+		args = none
+
+	default:
+		return ChatAction{}, fmt.Errorf("unknown tool")
+	}
+
+	// Actions keep their JSON payload, which the storefront and saved
+	// messages already read
+	encoded, err := json.Marshal(args)
+	if err != nil {
+		return ChatAction{}, err
+	}
+	payload := map[string]interface{}{}
+	if err := json.Unmarshal(encoded, &payload); err != nil {
+		return ChatAction{}, err
+	}
+	return ChatAction{Type: call.Name, Payload: payload}, nil
+}
+
+// decodeToolArgs decodes a tool call's JSON arguments into v, refusing
+// fields the tool doesn't take
+func decodeToolArgs(arguments string, v interface{}) error {
+	if strings.TrimSpace(arguments) == "" {
+		arguments = "{}"
+	}
+	decoder := json.NewDecoder(bytes.NewReader([]byte(arguments)))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return fmt.Errorf("invalid arguments: %v", err)
+	}
+	return nil
+}
+
+// toolConfirmation is said for the actions taken when the model called tools
+// without writing a reply
+func toolConfirmation(actions []ChatAction) string {
+	var sentences []string
+	for _, action := range actions {
+		switch action.Type {
+		case "add_to_cart":
+			sentences = append(sentences, "I've added that to your cart.")
+		case "remove_from_cart":
+			sentences = append(sentences, "I've removed that from your cart.")
+		case "search_products":
+			sentences = append(sentences, "Here's what I found for you!")
+		case "set_gift_options":
+			sentences = append(sentences, "I've updated your gift options.")
+		case "share_cart":
+			sentences = append(sentences, "I've created a link to share your cart.")
+		case "checkout":
+			sentences = append(sentences, "Taking you to checkout now.")
+		case "set_shipping_address":
+			sentences = append(sentences, "I've worked out your order total.")
+		case "place_order":
+			sentences = append(sentences, "I've placed your order.")
+		}
+	}
+	if len(sentences) == 0 {
+		return "Sorry, I couldn't do that. Could you try asking another way?"
+	}
+	return strings.Join(sentences, " ")
+}
@@ -0,0 +1,92 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CheckoutConflictMessage is the WebSocket message type sent to a session that
+// lost the race for the last units of a product
+const CheckoutConflictMessage = "checkout_conflict"
+
+// InventoryConflictError is returned when an order asks for more units than
+// are left, typically because another checkout got there first
+type InventoryConflictError struct {
+	ProductID uuid.UUID
+	VariantID *uuid.UUID
+	Requested int
+	Available int
+}
+
+func (e *InventoryConflictError) Error() string {
+	return fmt.Sprintf("insufficient inventory for product %s: available %d, requested %d", e.ProductID, e.Available, e.Requested)
+}
+
+// CheckoutConflict describes a lost checkout race and what the shopper could buy instead
+type CheckoutConflict struct {
+	ProductID    uuid.UUID           `json:"product_id"`
+	VariantID    *uuid.UUID          `json:"variant_id,omitempty"`
+	ProductName  string              `json:"product_name"`
+	Requested    int                 `json:"requested"`
+	Available    int                 `json:"available"`
+	Message      string              `json:"message"`
+	Alternatives []ProductSuggestion `json:"alternatives"`
+}
+
+// CheckoutConflict builds the conflict report for an inventory conflict,
+// suggesting in-stock products from the same category
+func (s *OrderService) CheckoutConflict(ctx context.Context, userID *uuid.UUID, conflict *InventoryConflictError, limit int) (*CheckoutConflict, error) {
+	db := s.db.WithContext(ctx)
+
+	var product models.Product
+	if err := db.Where("id = ?", conflict.ProductID).First(&product).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch product: %v", err)
+	}
+
+	available := conflict.Available
+	if available < 0 {
+		available = 0
+	}
+	report := &CheckoutConflict{
+		ProductID:    conflict.ProductID,
+		VariantID:    conflict.VariantID,
+		ProductName:  product.Name,
+		Requested:    conflict.Requested,
+		Available:    available,
+		Alternatives: []ProductSuggestion{},
+	}
+	if available == 0 {
+		report.Message = fmt.Sprintf("Sorry, %s just sold out.", product.Name)
+	} else {
+		report.Message = fmt.Sprintf("Sorry, only %d of %s are left.", available, product.Name)
+	}
+
+	var alternatives []models.Product
+	err := db.Where("category_id = ? AND id <> ?", product.CategoryID, product.ID).
+		Where("id IN (?)", db.Model(&models.Inventory{}).Select("product_id").
+			Where("variant_id IS NULL AND quantity_available >= ?", conflict.Requested)).
+		Scopes(publishedAt(time.Now())).
+		Preload("Images").
+		Order("popularity DESC, created_at DESC").
+		Limit(limit).
+		Find(&alternatives).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch alternatives: %v", err)
+	}
+	if err := s.pricing.PriceProducts(ctx, userID, alternatives); err != nil {
+		return nil, err
+	}
+
+	for i := range alternatives {
+		report.Alternatives = append(report.Alternatives, ProductSuggestion{
+			Product:    &alternatives[i],
+			Reason:     fmt.Sprintf("In stock alternative to %s", product.Name),
+			Confidence: 0.7,
+		})
+	}
+	return report, nil
+}
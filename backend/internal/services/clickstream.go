@@ -0,0 +1,339 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Storefront clickstream event types
+const (
+	ClickstreamPageView             = "page_view"
+	ClickstreamSuggestionImpression = "suggestion_impression"
+	ClickstreamSuggestionClick      = "suggestion_click"
+)
+
+// ErrInvalidClickstream is returned for event batches the storefront can't send
+var ErrInvalidClickstream = errors.New("invalid clickstream batch")
+
+// ClickstreamConfig limits what a batch may hold
+type ClickstreamConfig struct {
+	MaxBatch int           // events per batch
+	MaxAge   time.Duration // events older than this are rejected
+}
+
+// ClickstreamConfigFromEnv reads CLICKSTREAM_MAX_BATCH (100) and
+// CLICKSTREAM_MAX_AGE_HOURS (24)
+func ClickstreamConfigFromEnv() ClickstreamConfig {
+	return ClickstreamConfig{
+		MaxBatch: envInt("CLICKSTREAM_MAX_BATCH", 100),
+		MaxAge:   time.Duration(envInt("CLICKSTREAM_MAX_AGE_HOURS", 24)) * time.Hour,
+	}
+}
+
+// ClickstreamEvent is one event of a batch
+type ClickstreamEvent struct {
+	Type       string     `json:"type"` // page_view, suggestion_impression or suggestion_click
+	ProductID  *uuid.UUID `json:"product_id,omitempty"`
+	Path       string     `json:"path,omitempty"`
+	Placement  string     `json:"placement,omitempty"` // where a suggestion was shown, e.g. chat or product_page
+	Position   int        `json:"position,omitempty"`
+	OccurredAt *time.Time `json:"occurred_at,omitempty"` // when the shopper did it; defaults to when it arrived
+}
+
+// ClickstreamBatch is a batch of events from one storefront session. The
+// chat session, when set, must already be proven to be the sender's.
+type ClickstreamBatch struct {
+	SessionID     string
+	ChatSessionID string
+	UserID        *uuid.UUID
+	Events        []ClickstreamEvent
+}
+
+// ClickstreamResult is what was made of a batch
+type ClickstreamResult struct {
+	Accepted      int      `json:"accepted"`
+	Rejected      int      `json:"rejected"`
+	Errors        []string `json:"errors,omitempty"` // why each rejected event was rejected
+	ChatSessionID string   `json:"chat_session_id,omitempty"`
+	Variant       string   `json:"variant,omitempty"` // the chat experiment variant the events count towards
+}
+
+// ClickstreamService stores storefront clickstream events, stitched to the
+// shopper's chat session, for recommendations and chat experiments
+type ClickstreamService struct {
+	db       *gorm.DB
+	settings *LLMSettingsService
+	config   ClickstreamConfig
+}
+
+// NewClickstreamService creates a new ClickstreamService
+func NewClickstreamService(db *gorm.DB, config ClickstreamConfig) *ClickstreamService {
+	if config.MaxBatch <= 0 {
+		config.MaxBatch = 100
+	}
+	if config.MaxAge <= 0 {
+		config.MaxAge = 24 * time.Hour
+	}
+	return &ClickstreamService{
+		db:       db,
+		settings: NewLLMSettingsService(db),
+		config:   config,
+	}
+}
+
+// Ingest stores a batch of events. Events that aren't valid are rejected one
+// by one; the rest of the batch is kept.
+func (s *ClickstreamService) Ingest(ctx context.Context, batch ClickstreamBatch, now time.Time) (*ClickstreamResult, error) {
+	batch.SessionID = strings.TrimSpace(batch.SessionID)
+	if batch.SessionID == "" {
+		return nil, fmt.Errorf("%w: session_id is required", ErrInvalidClickstream)
+	}
+	if len(batch.SessionID) > 100 {
+		return nil, fmt.Errorf("%w: session_id is too long", ErrInvalidClickstream)
+	}
+	if len(batch.Events) == 0 || len(batch.Events) > s.config.MaxBatch {
+		return nil, fmt.Errorf("%w: a batch holds 1 to %d events", ErrInvalidClickstream, s.config.MaxBatch)
+	}
+
+	result := &ClickstreamResult{}
+	chatSessionID, err := s.stitch(ctx, batch)
+	if err != nil {
+		return nil, err
+	}
+	if chatSessionID != "" {
+		result.ChatSessionID = chatSessionID
+		result.Variant = s.settings.ResolveConfig(ctx, chatSessionID).Variant
+	}
+
+	var events []models.StorefrontEvent
+	for i, event := range batch.Events {
+		if err := s.validate(event, now); err != nil {
+			result.Rejected++
+			result.Errors = append(result.Errors, fmt.Sprintf("event %d: %v", i, err))
+			continue
+		}
+		occurredAt := now
+		if event.OccurredAt != nil && !event.OccurredAt.After(now) {
+			occurredAt = *event.OccurredAt
+		}
+		events = append(events, models.StorefrontEvent{
+			ID:            uuid.New(),
+			SessionID:     batch.SessionID,
+			ChatSessionID: chatSessionID,
+			UserID:        batch.UserID,
+			Type:          event.Type,
+			ProductID:     event.ProductID,
+			Path:          truncateRunes(event.Path, 500),
+			Placement:     truncateRunes(event.Placement, 50),
+			Position:      event.Position,
+			Variant:       result.Variant,
+			OccurredAt:    occurredAt,
+			CreatedAt:     now,
+		})
+	}
+	if len(events) > 0 {
+		if err := s.db.WithContext(ctx).Create(&events).Error; err != nil {
+			return nil, fmt.Errorf("failed to store clickstream events: %v", err)
+		}
+	}
+	result.Accepted = len(events)
+	return result, nil
+}
+
+// validate checks an event can be stored
+func (s *ClickstreamService) validate(event ClickstreamEvent, now time.Time) error {
+	switch event.Type {
+	case ClickstreamPageView:
+		if event.Path == "" && event.ProductID == nil {
+			return errors.New("a page view needs a path or product_id")
+		}
+	case ClickstreamSuggestionImpression, ClickstreamSuggestionClick:
+		if event.ProductID == nil {
+			return fmt.Errorf("a %s needs a product_id", event.Type)
+		}
+	default:
+		return fmt.Errorf("type must be %s, %s or %s", ClickstreamPageView, ClickstreamSuggestionImpression, ClickstreamSuggestionClick)
+	}
+	if event.OccurredAt != nil && now.Sub(*event.OccurredAt) > s.config.MaxAge {
+		return errors.New("occurred_at is too old")
+	}
+	return nil
+}
+
+// stitch finds the chat session a storefront session belongs to: the one the
+// batch names, else the one it was linked to before, else the signed-in
+// shopper's latest chat
+func (s *ClickstreamService) stitch(ctx context.Context, batch ClickstreamBatch) (string, error) {
+	db := s.db.WithContext(ctx)
+	if batch.ChatSessionID != "" {
+		return batch.ChatSessionID, s.Link(ctx, batch.SessionID, batch.ChatSessionID, batch.UserID)
+	}
+
+	var link models.SessionLink
+	err := db.Where("session_id = ?", batch.SessionID).First(&link).Error
+	if err == nil {
+		return link.ChatSessionID, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", fmt.Errorf("failed to fetch session link: %v", err)
+	}
+	if batch.UserID == nil {
+		return "", nil
+	}
+
+	var chat models.ChatSession
+	err = db.Select("session_id").Where("user_id = ?", *batch.UserID).Order("last_activity DESC").First(&chat).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch chat session: %v", err)
+	}
+	return chat.SessionID, s.Link(ctx, batch.SessionID, chat.SessionID, batch.UserID)
+}
+
+// Link stitches a storefront session to a chat session the caller has proven
+// is the shopper's. Events the storefront session already reported count
+// towards the chat session too.
+func (s *ClickstreamService) Link(ctx context.Context, sessionID, chatSessionID string, userID *uuid.UUID) error {
+	if sessionID == "" || len(sessionID) > 100 || chatSessionID == "" {
+		return nil
+	}
+	db := s.db.WithContext(ctx)
+
+	var link models.SessionLink
+	err := db.Where("session_id = ?", sessionID).First(&link).Error
+	if err == nil && link.ChatSessionID == chatSessionID {
+		return nil
+	}
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("failed to fetch session link: %v", err)
+	}
+
+	err = db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "session_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"chat_session_id", "user_id", "updated_at"}),
+	}).Create(&models.SessionLink{
+		ID:            uuid.New(),
+		SessionID:     sessionID,
+		ChatSessionID: chatSessionID,
+		UserID:        userID,
+	}).Error
+	if err != nil {
+		return fmt.Errorf("failed to link sessions: %v", err)
+	}
+
+	variant := s.settings.ResolveConfig(ctx, chatSessionID).Variant
+	err = db.Model(&models.StorefrontEvent{}).
+		Where("session_id = ? AND (chat_session_id = '' OR chat_session_id IS NULL)", sessionID).
+		Updates(map[string]interface{}{"chat_session_id": chatSessionID, "variant": variant}).Error
+	if err != nil {
+		return fmt.Errorf("failed to stitch earlier events: %v", err)
+	}
+	return nil
+}
+
+// RecentlyViewed returns the products a chat session's shopper viewed or
+// clicked on the storefront since the given time, most recent first
+func (s *ClickstreamService) RecentlyViewed(ctx context.Context, chatSessionID string, since time.Time, limit int) ([]uuid.UUID, error) {
+	var events []models.StorefrontEvent
+	err := s.db.WithContext(ctx).Select("product_id", "occurred_at").
+		Where("chat_session_id = ? AND product_id IS NOT NULL AND type IN ? AND occurred_at >= ?",
+			chatSessionID, []string{ClickstreamPageView, ClickstreamSuggestionClick}, since).
+		Order("occurred_at DESC").Limit(limit * 5).Find(&events).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch viewed products: %v", err)
+	}
+
+	seen := make(map[uuid.UUID]bool)
+	var products []uuid.UUID
+	for _, event := range events {
+		if seen[*event.ProductID] {
+			continue
+		}
+		seen[*event.ProductID] = true
+		products = append(products, *event.ProductID)
+		if len(products) == limit {
+			break
+		}
+	}
+	return products, nil
+}
+
+// ExperimentVariantStats is how shoppers in a chat experiment variant
+// engaged with the storefront
+type ExperimentVariantStats struct {
+	Variant          string  `json:"variant"`
+	Sessions         int64   `json:"sessions"` // chat sessions with storefront events
+	PageViews        int64   `json:"page_views"`
+	Impressions      int64   `json:"impressions"`
+	Clicks           int64   `json:"clicks"`
+	ClickThroughRate float64 `json:"click_through_rate"` // clicks per suggestion impression
+}
+
+// ExperimentReport compares the chat experiment variants by the storefront
+// events of their shoppers between from and to (exclusive)
+func (s *ClickstreamService) ExperimentReport(ctx context.Context, from, to time.Time) ([]ExperimentVariantStats, error) {
+	var rows []struct {
+		Variant string
+		Type    string
+		Events  int64
+	}
+	err := s.db.WithContext(ctx).Model(&models.StorefrontEvent{}).
+		Select("variant, type, COUNT(*) AS events").
+		Where("variant <> '' AND occurred_at >= ? AND occurred_at < ?", from, to).
+		Group("variant, type").Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to report experiments: %v", err)
+	}
+
+	var sessions []struct {
+		Variant  string
+		Sessions int64
+	}
+	err = s.db.WithContext(ctx).Model(&models.StorefrontEvent{}).
+		Select("variant, COUNT(DISTINCT chat_session_id) AS sessions").
+		Where("variant <> '' AND occurred_at >= ? AND occurred_at < ?", from, to).
+		Group("variant").Scan(&sessions).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to report experiments: %v", err)
+	}
+
+	byVariant := make(map[string]*ExperimentVariantStats)
+	for _, row := range sessions {
+		byVariant[row.Variant] = &ExperimentVariantStats{Variant: row.Variant, Sessions: row.Sessions}
+	}
+	for _, row := range rows {
+		stats := byVariant[row.Variant]
+		if stats == nil {
+			continue
+		}
+		switch row.Type {
+		case ClickstreamPageView:
+			stats.PageViews = row.Events
+		case ClickstreamSuggestionImpression:
+			stats.Impressions = row.Events
+		case ClickstreamSuggestionClick:
+			stats.Clicks = row.Events
+		}
+	}
+
+	report := make([]ExperimentVariantStats, 0, len(byVariant))
+	for _, stats := range byVariant {
+		if stats.Impressions > 0 {
+			stats.ClickThroughRate = roundTo(float64(stats.Clicks)/float64(stats.Impressions), 4)
+		}
+		report = append(report, *stats)
+	}
+	sort.Slice(report, func(i, j int) bool { return report[i].Variant < report[j].Variant })
+	return report, nil
+}
@@ -0,0 +1,199 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Consent purposes
+const (
+	ConsentMarketingEmail   = "marketing_email"
+	ConsentMarketingSMS     = "marketing_sms"
+	ConsentAnalyticsCookies = "analytics_cookies"
+)
+
+// consentPurposes lists every purpose, in the order they're shown
+var consentPurposes = []string{ConsentMarketingEmail, ConsentMarketingSMS, ConsentAnalyticsCookies}
+
+// consentSources are the places a user can give or withdraw consent
+var consentSources = map[string]bool{
+	"signup":           true,
+	"account_settings": true,
+	"cookie_banner":    true,
+	"checkout":         true,
+	"unsubscribe_link": true,
+}
+
+// Consent errors
+var (
+	ErrUnknownConsentPurpose = errors.New("unknown consent purpose")
+	ErrUnknownConsentSource  = errors.New("unknown consent source")
+)
+
+// ConsentStatus is a user's current choice for a purpose. Purposes the user
+// has never been asked about are not granted.
+type ConsentStatus struct {
+	Purpose   string     `json:"purpose"`
+	Granted   bool       `json:"granted"`
+	Source    string     `json:"source,omitempty"`
+	UpdatedAt *time.Time `json:"updated_at"`
+}
+
+// UpdateConsentRequest is the payload for PUT /user/consents. Purposes left
+// out keep their current choice.
+type UpdateConsentRequest struct {
+	Consents map[string]bool `json:"consents" binding:"required"`
+	Source   string          `json:"source"` // defaults to account_settings
+}
+
+// ConsentService records users' consent to marketing and analytics
+type ConsentService struct {
+	db *gorm.DB
+}
+
+// NewConsentService creates a new ConsentService
+func NewConsentService(db *gorm.DB) *ConsentService {
+	return &ConsentService{db: db}
+}
+
+// GetConsents returns the user's current choice for every purpose
+func (s *ConsentService) GetConsents(ctx context.Context, userID uuid.UUID) ([]ConsentStatus, error) {
+	latest, err := s.latest(s.db.WithContext(ctx), []uuid.UUID{userID}, consentPurposes)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]ConsentStatus, 0, len(consentPurposes))
+	for _, purpose := range consentPurposes {
+		status := ConsentStatus{Purpose: purpose}
+		if record, ok := latest[userID][purpose]; ok {
+			createdAt := record.CreatedAt
+			status.Granted = record.Granted
+			status.Source = record.Source
+			status.UpdatedAt = &createdAt
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// UpdateConsents records the user's choices. Only changes are recorded, so
+// the history shows when and where each choice was made.
+func (s *ConsentService) UpdateConsents(ctx context.Context, userID uuid.UUID, req UpdateConsentRequest, ipAddress, userAgent string) ([]ConsentStatus, error) {
+	source := req.Source
+	if source == "" {
+		source = "account_settings"
+	}
+	if !consentSources[source] {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownConsentSource, source)
+	}
+	for purpose := range req.Consents {
+		if !isConsentPurpose(purpose) {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownConsentPurpose, purpose)
+		}
+	}
+	if len(userAgent) > 255 {
+		userAgent = userAgent[:255]
+	}
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		latest, err := s.latest(tx, []uuid.UUID{userID}, consentPurposes)
+		if err != nil {
+			return err
+		}
+
+		// Go through purposes in a fixed order so records are written the same way every time
+		for _, purpose := range consentPurposes {
+			granted, ok := req.Consents[purpose]
+			if !ok {
+				continue
+			}
+			if current, exists := latest[userID][purpose]; exists && current.Granted == granted {
+				continue
+			}
+			record := models.ConsentRecord{
+				ID:        uuid.New(),
+				UserID:    userID,
+				Purpose:   purpose,
+				Granted:   granted,
+				Source:    source,
+				IPAddress: ipAddress,
+				UserAgent: userAgent,
+			}
+			if err := tx.Create(&record).Error; err != nil {
+				return fmt.Errorf("failed to record consent: %v", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetConsents(ctx, userID)
+}
+
+// History returns every consent change of a user, newest first
+func (s *ConsentService) History(ctx context.Context, userID uuid.UUID) ([]models.ConsentRecord, error) {
+	var records []models.ConsentRecord
+	if err := s.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Order("created_at DESC").
+		Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch consent history: %v", err)
+	}
+	return records, nil
+}
+
+// ConsentedUsers returns which of the users have granted the purpose
+func (s *ConsentService) ConsentedUsers(ctx context.Context, userIDs []uuid.UUID, purpose string) (map[uuid.UUID]bool, error) {
+	consented := make(map[uuid.UUID]bool)
+	if len(userIDs) == 0 {
+		return consented, nil
+	}
+
+	latest, err := s.latest(s.db.WithContext(ctx), userIDs, []string{purpose})
+	if err != nil {
+		return nil, err
+	}
+	for userID, records := range latest {
+		if records[purpose].Granted {
+			consented[userID] = true
+		}
+	}
+	return consented, nil
+}
+
+// latest returns each user's newest record per purpose
+func (s *ConsentService) latest(db *gorm.DB, userIDs []uuid.UUID, purposes []string) (map[uuid.UUID]map[string]models.ConsentRecord, error) {
+	var records []models.ConsentRecord
+	if err := db.
+		Where("user_id IN ? AND purpose IN ?", userIDs, purposes).
+		Order("created_at ASC").
+		Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch consent: %v", err)
+	}
+
+	latest := make(map[uuid.UUID]map[string]models.ConsentRecord)
+	for _, record := range records {
+		if latest[record.UserID] == nil {
+			latest[record.UserID] = make(map[string]models.ConsentRecord)
+		}
+		latest[record.UserID][record.Purpose] = record
+	}
+	return latest, nil
+}
+
+func isConsentPurpose(purpose string) bool {
+	for _, known := range consentPurposes {
+		if purpose == known {
+			return true
+		}
+	}
+	return false
+}
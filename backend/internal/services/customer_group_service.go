@@ -0,0 +1,394 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrCustomerGroupNotFound is returned when no customer group has the requested slug
+var ErrCustomerGroupNotFound = errors.New("customer group not found")
+
+// CustomerGroupService manages customer groups and prices products for them,
+// applying the pricing rules on top of the group's price
+type CustomerGroupService struct {
+	db    *gorm.DB
+	rules *PricingRuleService
+}
+
+// NewCustomerGroupService creates a new CustomerGroupService
+func NewCustomerGroupService(db *gorm.DB) *CustomerGroupService {
+	return &CustomerGroupService{
+		db:    db,
+		rules: NewPricingRuleService(db, PricingRuleConfigFromEnv()),
+	}
+}
+
+// WithPricingRules applies the given rules on top of group prices in quotes,
+// instead of a cache of its own that wouldn't see rules admins just saved
+func (s *CustomerGroupService) WithPricingRules(rules *PricingRuleService) *CustomerGroupService {
+	s.rules = rules
+	return s
+}
+
+// CustomerGroupRequest creates or updates a customer group
+type CustomerGroupRequest struct {
+	Name              string  `json:"name" binding:"required"`
+	Description       string  `json:"description"`
+	AdjustmentPercent float64 `json:"adjustment_percent" binding:"min=-100"`
+	IsDefault         bool    `json:"is_default"`
+}
+
+// GroupPriceRequest sets a group's fixed price for a product
+type GroupPriceRequest struct {
+	ProductID uuid.UUID `json:"product_id" binding:"required"`
+	Price     float64   `json:"price" binding:"min=0"`
+}
+
+// PriceQuote is a shopper's price for a product and the adjustments that
+// took it there from the catalog price
+type PriceQuote struct {
+	UnitPrice   float64                  `json:"unit_price"`
+	Adjustments []models.PriceAdjustment `json:"adjustments"`
+}
+
+// groupPricing is a resolved customer group with its price list
+type groupPricing struct {
+	group  *models.CustomerGroup
+	prices map[uuid.UUID]float64
+}
+
+// price returns the group's price for a product at the given list price
+func (p *groupPricing) price(productID uuid.UUID, listPrice float64) float64 {
+	if p == nil {
+		return listPrice
+	}
+	if price, ok := p.prices[productID]; ok {
+		return price
+	}
+	if p.group.AdjustmentPercent == 0 {
+		return listPrice
+	}
+	return roundCents(listPrice * (1 + p.group.AdjustmentPercent/100))
+}
+
+// ListGroups returns all customer groups
+func (s *CustomerGroupService) ListGroups(ctx context.Context) ([]models.CustomerGroup, error) {
+	var groups []models.CustomerGroup
+	if err := s.db.WithContext(ctx).Order("name ASC").Find(&groups).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch customer groups: %v", err)
+	}
+	return groups, nil
+}
+
+// UpsertGroup creates or updates the customer group with the given slug.
+// Making a group the default clears the flag on every other group.
+func (s *CustomerGroupService) UpsertGroup(ctx context.Context, slug string, req CustomerGroupRequest) (*models.CustomerGroup, error) {
+	var group models.CustomerGroup
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("slug = ?", slug).First(&group).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("failed to fetch customer group: %v", err)
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			group = models.CustomerGroup{ID: uuid.New(), Slug: slug, CreatedAt: time.Now()}
+		}
+
+		group.Name = req.Name
+		group.Description = req.Description
+		group.AdjustmentPercent = req.AdjustmentPercent
+		group.IsDefault = req.IsDefault
+		group.UpdatedAt = time.Now()
+
+		if req.IsDefault {
+			if err := tx.Model(&models.CustomerGroup{}).Where("id <> ?", group.ID).Update("is_default", false).Error; err != nil {
+				return fmt.Errorf("failed to clear default customer group: %v", err)
+			}
+		}
+		if err := tx.Save(&group).Error; err != nil {
+			return fmt.Errorf("failed to save customer group: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+// DeleteGroup removes a customer group and its price list. Its members fall back to the default group.
+func (s *CustomerGroupService) DeleteGroup(ctx context.Context, slug string) error {
+	group, err := s.groupBySlug(ctx, slug)
+	if err != nil {
+		return err
+	}
+
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{}).Where("customer_group_id = ?", group.ID).Update("customer_group_id", nil).Error; err != nil {
+			return fmt.Errorf("failed to remove customer group members: %v", err)
+		}
+		if err := tx.Where("customer_group_id = ?", group.ID).Delete(&models.GroupPrice{}).Error; err != nil {
+			return fmt.Errorf("failed to delete group prices: %v", err)
+		}
+		if err := tx.Delete(group).Error; err != nil {
+			return fmt.Errorf("failed to delete customer group: %v", err)
+		}
+		return nil
+	})
+}
+
+// GetGroupPrices returns a customer group's price list
+func (s *CustomerGroupService) GetGroupPrices(ctx context.Context, slug string) ([]models.GroupPrice, error) {
+	group, err := s.groupBySlug(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+
+	var prices []models.GroupPrice
+	if err := s.db.WithContext(ctx).Where("customer_group_id = ?", group.ID).Find(&prices).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch group prices: %v", err)
+	}
+	return prices, nil
+}
+
+// SetGroupPrices adds or replaces entries in a customer group's price list
+func (s *CustomerGroupService) SetGroupPrices(ctx context.Context, slug string, req []GroupPriceRequest) ([]models.GroupPrice, error) {
+	group, err := s.groupBySlug(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	prices := make([]models.GroupPrice, 0, len(req))
+	for _, entry := range req {
+		prices = append(prices, models.GroupPrice{
+			ID:              uuid.New(),
+			CustomerGroupID: group.ID,
+			ProductID:       entry.ProductID,
+			Price:           entry.Price,
+			CreatedAt:       now,
+			UpdatedAt:       now,
+		})
+	}
+	if len(prices) == 0 {
+		return prices, nil
+	}
+
+	err = s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "customer_group_id"}, {Name: "product_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"price", "updated_at"}),
+	}).Create(&prices).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to save group prices: %v", err)
+	}
+	return prices, nil
+}
+
+// DeleteGroupPrice removes a product from a customer group's price list
+func (s *CustomerGroupService) DeleteGroupPrice(ctx context.Context, slug string, productID uuid.UUID) error {
+	group, err := s.groupBySlug(ctx, slug)
+	if err != nil {
+		return err
+	}
+
+	if err := s.db.WithContext(ctx).Where("customer_group_id = ? AND product_id = ?", group.ID, productID).Delete(&models.GroupPrice{}).Error; err != nil {
+		return fmt.Errorf("failed to delete group price: %v", err)
+	}
+	return nil
+}
+
+// AssignUser moves a user into a customer group
+func (s *CustomerGroupService) AssignUser(ctx context.Context, slug string, userID uuid.UUID) error {
+	group, err := s.groupBySlug(ctx, slug)
+	if err != nil {
+		return err
+	}
+	return s.setUserGroup(ctx, userID, &group.ID)
+}
+
+// RemoveUser takes a user out of a customer group, back to the default group
+func (s *CustomerGroupService) RemoveUser(ctx context.Context, slug string, userID uuid.UUID) error {
+	group, err := s.groupBySlug(ctx, slug)
+	if err != nil {
+		return err
+	}
+
+	result := s.db.WithContext(ctx).Model(&models.User{}).
+		Where("id = ? AND customer_group_id = ?", userID, group.ID).
+		Update("customer_group_id", nil)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update user: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("user is not in customer group %s", slug)
+	}
+	return nil
+}
+
+// ResolveGroup returns the customer group that prices the catalog for a
+// user: their own group, or the default group for guests and customers
+// without one. It returns nil when there is no applicable group.
+func (s *CustomerGroupService) ResolveGroup(ctx context.Context, userID *uuid.UUID) (*models.CustomerGroup, error) {
+	var group models.CustomerGroup
+	if userID != nil {
+		err := s.db.WithContext(ctx).
+			Joins("JOIN users ON users.customer_group_id = customer_groups.id").
+			Where("users.id = ?", *userID).
+			First(&group).Error
+		if err == nil {
+			return &group, nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("failed to fetch customer group: %v", err)
+		}
+	}
+
+	err := s.db.WithContext(ctx).Where("is_default = ?", true).First(&group).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch default customer group: %v", err)
+	}
+	return &group, nil
+}
+
+// PriceProducts replaces each product's price with the user's price: their
+// group's price with the pricing rules applied. Adjusted products keep their
+// catalog price in ListPrice and list the adjustments made.
+func (s *CustomerGroupService) PriceProducts(ctx context.Context, userID *uuid.UUID, products []models.Product) error {
+	if len(products) == 0 {
+		return nil
+	}
+
+	quotes, err := s.quote(ctx, userID, products, time.Now())
+	if err != nil {
+		return err
+	}
+
+	for i := range products {
+		listPrice := products[i].Price
+		if quotes[i].UnitPrice != listPrice {
+			products[i].Price = quotes[i].UnitPrice
+			products[i].ListPrice = &listPrice
+		}
+		products[i].PriceAdjustments = quotes[i].Adjustments
+	}
+	return nil
+}
+
+// UnitPrice returns the user's price for a product
+func (s *CustomerGroupService) UnitPrice(ctx context.Context, userID *uuid.UUID, product *models.Product) (float64, error) {
+	quote, err := s.QuotePrice(ctx, userID, product)
+	if err != nil {
+		return 0, err
+	}
+	return quote.UnitPrice, nil
+}
+
+// QuotePrice returns the user's price for a product with the adjustments
+// that make it up, for order lines to record
+func (s *CustomerGroupService) QuotePrice(ctx context.Context, userID *uuid.UUID, product *models.Product) (*PriceQuote, error) {
+	quotes, err := s.quote(ctx, userID, []models.Product{*product}, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return &quotes[0], nil
+}
+
+// quote prices products at the user's group price, then through the
+// pricing rules
+func (s *CustomerGroupService) quote(ctx context.Context, userID *uuid.UUID, products []models.Product, now time.Time) ([]PriceQuote, error) {
+	group, err := s.ResolveGroup(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.quoteAs(ctx, group, userID != nil && *userID != uuid.Nil, products, now)
+}
+
+// quoteAs prices products for a shopper in the given group, or in none when
+// it's nil
+func (s *CustomerGroupService) quoteAs(ctx context.Context, group *models.CustomerGroup, signedIn bool, products []models.Product, now time.Time) ([]PriceQuote, error) {
+	productIDs := make([]uuid.UUID, len(products))
+	for i := range products {
+		productIDs[i] = products[i].ID
+	}
+	pricing, err := s.pricingFor(ctx, group, productIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	shopper := pricingShopper{signedIn: signedIn}
+	quotes := make([]PriceQuote, len(products))
+	for i := range products {
+		listPrice := products[i].Price
+		price := pricing.price(products[i].ID, listPrice)
+		quotes[i].UnitPrice = price
+		if price != listPrice {
+			groupID := pricing.group.ID
+			quotes[i].Adjustments = append(quotes[i].Adjustments, models.PriceAdjustment{
+				Source:      PriceSourceCustomerGroup,
+				ID:          &groupID,
+				Name:        pricing.group.Name,
+				PriceBefore: listPrice,
+				PriceAfter:  price,
+			})
+		}
+	}
+	if pricing != nil {
+		shopper.groupID = &pricing.group.ID
+	}
+
+	if err := s.rules.apply(ctx, shopper, products, quotes, now); err != nil {
+		return nil, err
+	}
+	return quotes, nil
+}
+
+// pricingFor loads the group's price list entries for the given products
+func (s *CustomerGroupService) pricingFor(ctx context.Context, group *models.CustomerGroup, productIDs []uuid.UUID) (*groupPricing, error) {
+	if group == nil {
+		return nil, nil
+	}
+
+	var entries []models.GroupPrice
+	if err := s.db.WithContext(ctx).
+		Where("customer_group_id = ? AND product_id IN ?", group.ID, productIDs).
+		Find(&entries).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch group prices: %v", err)
+	}
+
+	pricing := &groupPricing{group: group, prices: make(map[uuid.UUID]float64, len(entries))}
+	for _, entry := range entries {
+		pricing.prices[entry.ProductID] = entry.Price
+	}
+	return pricing, nil
+}
+
+func (s *CustomerGroupService) setUserGroup(ctx context.Context, userID uuid.UUID, groupID *uuid.UUID) error {
+	result := s.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", userID).Update("customer_group_id", groupID)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update user: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("user not found")
+	}
+	return nil
+}
+
+func (s *CustomerGroupService) groupBySlug(ctx context.Context, slug string) (*models.CustomerGroup, error) {
+	var group models.CustomerGroup
+	if err := s.db.WithContext(ctx).Where("slug = ?", slug).First(&group).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCustomerGroupNotFound
+		}
+		return nil, fmt.Errorf("failed to fetch customer group: %v", err)
+	}
+	return &group, nil
+}
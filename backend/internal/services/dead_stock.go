@@ -0,0 +1,257 @@
+package services
+
+import (
+	"bytes"
+	"chat-ecommerce-backend/internal/models"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Suggested actions for dead stock
+const (
+	DeadStockActionDiscount = "discount"
+	DeadStockActionBundle   = "bundle"
+)
+
+const (
+	defaultDeadStockDays = 90
+	// deadStockBundleUnits is the most units that are moved in bundles; more
+	// than that needs a markdown
+	deadStockBundleUnits = 10
+	// each period of DeadStockFilter.Days without a sale adds this much to the
+	// suggested markdown, up to maxDeadStockDiscount
+	deadStockDiscountStep = 10
+	maxDeadStockDiscount  = 50
+)
+
+// DeadStockFilter selects products for the dead stock report
+type DeadStockFilter struct {
+	Days     int // days without a sale, 90 by default
+	MinStock int // least stock on hand, 1 by default
+}
+
+// DeadStockItem is a product with stock on hand that hasn't sold in a while
+type DeadStockItem struct {
+	ProductID         uuid.UUID  `json:"product_id"`
+	Name              string     `json:"name"`
+	SKU               string     `json:"sku"`
+	Status            string     `json:"status"`
+	Price             float64    `json:"price"`
+	StockOnHand       int        `json:"stock_on_hand"`
+	StockValue        float64    `json:"stock_value"`
+	LastSoldAt        *time.Time `json:"last_sold_at"` // nil if the product never sold
+	DaysWithoutSale   int        `json:"days_without_sale"`
+	AgeBucket         string     `json:"age_bucket"`
+	SuggestedAction   string     `json:"suggested_action"`
+	SuggestedDiscount int        `json:"suggested_discount_percent,omitempty"`
+}
+
+// DeadStockReport lists dead stock, the stock tying up the most money first
+type DeadStockReport struct {
+	Days        int             `json:"days"`
+	GeneratedAt time.Time       `json:"generated_at"`
+	Products    int             `json:"products"`
+	Units       int             `json:"units"`
+	StockValue  float64         `json:"stock_value"`
+	Buckets     map[string]int  `json:"buckets"` // products per age bucket
+	Items       []DeadStockItem `json:"items"`
+}
+
+// GetDeadStockReport finds products with stock on hand and no sales in the
+// last filter.Days days, and suggests how to move them. A product's age runs
+// from its last sale, or from when it was added if it never sold.
+func (s *InventoryService) GetDeadStockReport(ctx context.Context, filter DeadStockFilter, now time.Time) (*DeadStockReport, error) {
+	if filter.Days == 0 {
+		filter.Days = defaultDeadStockDays
+	}
+	if filter.MinStock == 0 {
+		filter.MinStock = 1
+	}
+	if filter.Days < 0 || filter.MinStock < 0 {
+		return nil, errors.New("days and min_stock must be positive")
+	}
+	db := s.db.WithContext(ctx)
+
+	var stock []struct {
+		ProductID uuid.UUID
+		OnHand    int
+	}
+	if err := db.Model(&models.Inventory{}).
+		Select("product_id, COALESCE(SUM(quantity_available + quantity_reserved), 0) AS on_hand").
+		Group("product_id").
+		Having("COALESCE(SUM(quantity_available + quantity_reserved), 0) >= ?", filter.MinStock).
+		Scan(&stock).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch inventory: %v", err)
+	}
+
+	report := &DeadStockReport{
+		Days:        filter.Days,
+		GeneratedAt: now,
+		Buckets:     map[string]int{},
+		Items:       []DeadStockItem{},
+	}
+	if len(stock) == 0 {
+		return report, nil
+	}
+
+	productIDs := make([]uuid.UUID, len(stock))
+	for i, item := range stock {
+		productIDs[i] = item.ProductID
+	}
+
+	// Cancelled orders and unpaid offline orders aren't sales
+	var sales []struct {
+		ProductID  uuid.UUID
+		LastSoldAt time.Time
+	}
+	if err := db.Table("order_items").
+		Select("order_items.product_id, MAX(orders.created_at) AS last_sold_at").
+		Joins("JOIN orders ON orders.id = order_items.order_id").
+		Where("order_items.product_id IN ?", productIDs).
+		Where("orders.status NOT IN ?", []string{"cancelled", OrderStatusAwaitingPayment}).
+		Group("order_items.product_id").
+		Scan(&sales).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch sales: %v", err)
+	}
+	lastSold := make(map[uuid.UUID]time.Time, len(sales))
+	for _, sale := range sales {
+		lastSold[sale.ProductID] = sale.LastSoldAt
+	}
+
+	var products []models.Product
+	if err := db.Select("id", "name", "sku", "status", "price", "created_at").
+		Where("id IN ?", productIDs).
+		Find(&products).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch products: %v", err)
+	}
+	onHand := make(map[uuid.UUID]int, len(stock))
+	for _, item := range stock {
+		onHand[item.ProductID] = item.OnHand
+	}
+
+	cutoff := now.AddDate(0, 0, -filter.Days)
+	for _, product := range products {
+		since := product.CreatedAt
+		item := DeadStockItem{
+			ProductID:   product.ID,
+			Name:        product.Name,
+			SKU:         product.SKU,
+			Status:      product.Status,
+			Price:       product.Price,
+			StockOnHand: onHand[product.ID],
+		}
+		if soldAt, ok := lastSold[product.ID]; ok {
+			since = soldAt
+			item.LastSoldAt = &soldAt
+		}
+		if since.After(cutoff) {
+			continue
+		}
+
+		item.StockValue = roundCents(product.Price * float64(item.StockOnHand))
+		item.DaysWithoutSale = int(now.Sub(since).Hours() / 24)
+		item.AgeBucket = deadStockBucket(item.DaysWithoutSale)
+		item.SuggestedAction, item.SuggestedDiscount = deadStockAction(item, filter.Days)
+
+		report.Items = append(report.Items, item)
+		report.Units += item.StockOnHand
+		report.StockValue += item.StockValue
+		report.Buckets[item.AgeBucket]++
+	}
+
+	sort.Slice(report.Items, func(i, j int) bool {
+		if report.Items[i].StockValue != report.Items[j].StockValue {
+			return report.Items[i].StockValue > report.Items[j].StockValue
+		}
+		return report.Items[i].DaysWithoutSale > report.Items[j].DaysWithoutSale
+	})
+	report.Products = len(report.Items)
+	report.StockValue = roundCents(report.StockValue)
+	return report, nil
+}
+
+var deadStockCSVHeader = []string{
+	"product_id", "sku", "name", "status", "price", "stock_on_hand", "stock_value",
+	"last_sold_at", "days_without_sale", "age_bucket", "suggested_action", "suggested_discount_percent",
+}
+
+// ExportDeadStockCSV exports the dead stock report to CSV for merchandising review
+func (s *InventoryService) ExportDeadStockCSV(ctx context.Context, filter DeadStockFilter, now time.Time) ([]byte, error) {
+	report, err := s.GetDeadStockReport(ctx, filter, now)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write(deadStockCSVHeader)
+	for _, item := range report.Items {
+		lastSold := ""
+		if item.LastSoldAt != nil {
+			lastSold = item.LastSoldAt.Format("2006-01-02")
+		}
+		discount := ""
+		if item.SuggestedDiscount > 0 {
+			discount = strconv.Itoa(item.SuggestedDiscount)
+		}
+		w.Write([]string{
+			item.ProductID.String(),
+			item.SKU,
+			item.Name,
+			item.Status,
+			strconv.FormatFloat(item.Price, 'f', 2, 64),
+			strconv.Itoa(item.StockOnHand),
+			strconv.FormatFloat(item.StockValue, 'f', 2, 64),
+			lastSold,
+			strconv.Itoa(item.DaysWithoutSale),
+			item.AgeBucket,
+			item.SuggestedAction,
+			discount,
+		})
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, fmt.Errorf("failed to write dead stock CSV: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// deadStockBucket groups days without a sale into aging buckets
+func deadStockBucket(days int) string {
+	switch {
+	case days < 60:
+		return "0-59"
+	case days < 90:
+		return "60-89"
+	case days < 180:
+		return "90-179"
+	case days < 365:
+		return "180-364"
+	default:
+		return "365+"
+	}
+}
+
+// deadStockAction suggests bundling the last few units with products that
+// sell, and marking down larger stock more the longer it has sat
+func deadStockAction(item DeadStockItem, days int) (string, int) {
+	if item.StockOnHand <= deadStockBundleUnits {
+		return DeadStockActionBundle, 0
+	}
+	discount := item.DaysWithoutSale / days * deadStockDiscountStep
+	if discount < deadStockDiscountStep {
+		discount = deadStockDiscountStep
+	}
+	if discount > maxDeadStockDiscount {
+		discount = maxDeadStockDiscount
+	}
+	return DeadStockActionDiscount, discount
+}
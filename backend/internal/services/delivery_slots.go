@@ -0,0 +1,273 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// deliveryDateLayout is the format of delivery dates in requests and slots
+const deliveryDateLayout = "2006-01-02"
+
+// ErrDeliveryDateUnavailable is returned for delivery dates no carrier can make
+var ErrDeliveryDateUnavailable = errors.New("delivery date is not available")
+
+// CarrierSchedule is a carrier's transit time and the weekdays it delivers on
+type CarrierSchedule struct {
+	Name         string
+	TransitDays  int // delivery days between handing a parcel over and delivering it
+	DeliveryDays map[time.Weekday]bool
+}
+
+// delivers reports whether the carrier delivers on the day
+func (c CarrierSchedule) delivers(day time.Time) bool {
+	return c.DeliveryDays[day.Weekday()]
+}
+
+// arrival is the day a parcel shipped on shipDate is delivered
+func (c CarrierSchedule) arrival(shipDate time.Time) time.Time {
+	day := shipDate
+	for transit := c.TransitDays; transit > 0 || !c.delivers(day); {
+		day = day.AddDate(0, 0, 1)
+		if c.delivers(day) {
+			transit--
+		}
+	}
+	return day
+}
+
+// DeliveryConfig lists the carriers and how far ahead delivery dates are offered
+type DeliveryConfig struct {
+	Carriers   []CarrierSchedule
+	WindowDays int
+}
+
+// DeliveryConfigFromEnv reads the carriers from DELIVERY_CARRIERS as
+// name:transit_days:weekdays entries (e.g. "standard:3:mon-fri,express:1:mon-sat";
+// "standard:3:mon-fri" by default) and offers dates DELIVERY_WINDOW_DAYS (14) ahead
+func DeliveryConfigFromEnv() DeliveryConfig {
+	config := DeliveryConfig{WindowDays: envInt("DELIVERY_WINDOW_DAYS", 14)}
+
+	spec := os.Getenv("DELIVERY_CARRIERS")
+	if spec == "" {
+		spec = "standard:3:mon-fri"
+	}
+	for _, entry := range strings.Split(spec, ",") {
+		carrier, err := parseCarrierSchedule(entry)
+		if err != nil {
+			log.Printf("Warning: ignoring DELIVERY_CARRIERS entry %q: %v", entry, err)
+			continue
+		}
+		config.Carriers = append(config.Carriers, carrier)
+	}
+	return config
+}
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// parseCarrierSchedule parses a "name:transit_days:weekdays" entry, where
+// weekdays are ranges or single days joined by "+", such as "mon-fri" or
+// "mon+wed+fri"
+func parseCarrierSchedule(entry string) (CarrierSchedule, error) {
+	parts := strings.Split(strings.TrimSpace(entry), ":")
+	if len(parts) != 3 || parts[0] == "" {
+		return CarrierSchedule{}, errors.New("expected name:transit_days:weekdays")
+	}
+	transit, err := strconv.Atoi(parts[1])
+	if err != nil || transit < 0 {
+		return CarrierSchedule{}, fmt.Errorf("invalid transit days %q", parts[1])
+	}
+
+	carrier := CarrierSchedule{Name: parts[0], TransitDays: transit, DeliveryDays: map[time.Weekday]bool{}}
+	for _, span := range strings.Split(strings.ToLower(parts[2]), "+") {
+		bounds := strings.SplitN(span, "-", 2)
+		from, ok := weekdayNames[bounds[0]]
+		if !ok {
+			return CarrierSchedule{}, fmt.Errorf("invalid weekday %q", bounds[0])
+		}
+		to := from
+		if len(bounds) == 2 {
+			if to, ok = weekdayNames[bounds[1]]; !ok {
+				return CarrierSchedule{}, fmt.Errorf("invalid weekday %q", bounds[1])
+			}
+		}
+		for day := from; ; day = (day + 1) % 7 {
+			carrier.DeliveryDays[day] = true
+			if day == to {
+				break
+			}
+		}
+	}
+	return carrier, nil
+}
+
+// DeliverySlot is a day an order can be delivered on with one carrier
+type DeliverySlot struct {
+	Date    string `json:"date"`
+	Carrier string `json:"carrier"`
+	ShipBy  string `json:"ship_by"` // last warehouse ship day that still arrives on Date
+}
+
+// DeliveryScheduleService offers delivery dates from the warehouse's shipping
+// cutoffs, taken from the store hours, and the carriers' schedules
+type DeliveryScheduleService struct {
+	hours  *BusinessHoursService
+	config DeliveryConfig
+}
+
+// NewDeliveryScheduleService creates a new DeliveryScheduleService
+func NewDeliveryScheduleService(hours *BusinessHoursService, config DeliveryConfig) *DeliveryScheduleService {
+	return &DeliveryScheduleService{
+		hours:  hours,
+		config: config,
+	}
+}
+
+// Slots lists the delivery dates of an order placed at now, earliest first.
+// The earliest date of each carrier is the arrival of the first shipment
+// after the cutoff; later dates are held back and shipped just in time.
+func (s *DeliveryScheduleService) Slots(ctx context.Context, now time.Time) ([]DeliverySlot, error) {
+	shipDates, err := s.shipDates(ctx, now)
+	if err != nil {
+		return nil, err
+	}
+
+	slots := []DeliverySlot{}
+	if len(shipDates) == 0 {
+		return slots, nil
+	}
+	first := shipDates[0]
+	last := first.AddDate(0, 0, s.config.WindowDays)
+	for day := first; !day.After(last); day = day.AddDate(0, 0, 1) {
+		for _, carrier := range s.config.Carriers {
+			if !carrier.delivers(day) {
+				continue
+			}
+			// Ship on the last ship day that still arrives in time
+			var shipBy *time.Time
+			for i := range shipDates {
+				if carrier.arrival(shipDates[i]).After(day) {
+					break
+				}
+				shipBy = &shipDates[i]
+			}
+			if shipBy == nil {
+				continue
+			}
+			slots = append(slots, DeliverySlot{
+				Date:    day.Format(deliveryDateLayout),
+				Carrier: carrier.Name,
+				ShipBy:  shipBy.Format(deliveryDateLayout),
+			})
+		}
+	}
+	return slots, nil
+}
+
+// Slot finds the slot for a "2006-01-02" date, with the given carrier or the
+// first one delivering that day
+func (s *DeliveryScheduleService) Slot(ctx context.Context, date, carrier string, now time.Time) (*DeliverySlot, error) {
+	if _, err := time.Parse(deliveryDateLayout, date); err != nil {
+		return nil, fmt.Errorf("invalid delivery date %q, expected YYYY-MM-DD", date)
+	}
+	slots, err := s.Slots(ctx, now)
+	if err != nil {
+		return nil, err
+	}
+	for i := range slots {
+		if slots[i].Date == date && (carrier == "" || strings.EqualFold(slots[i].Carrier, carrier)) {
+			return &slots[i], nil
+		}
+	}
+	return nil, ErrDeliveryDateUnavailable
+}
+
+// Date returns a slot's "2006-01-02" date as midnight in the store's time zone
+func (s *DeliveryScheduleService) Date(value string) *time.Time {
+	date, err := time.ParseInLocation(deliveryDateLayout, value, s.hours.location)
+	if err != nil {
+		return nil
+	}
+	return &date
+}
+
+// shipDates lists the days the warehouse ships on, from the first one an
+// order placed at now makes, through the end of the delivery window. Without
+// store hours it ships every day.
+func (s *DeliveryScheduleService) shipDates(ctx context.Context, now time.Time) ([]time.Time, error) {
+	availability, err := s.hours.Availability(ctx, DefaultStoreVariant, now)
+	if err != nil {
+		return nil, err
+	}
+	hours, err := s.hours.GetHours(ctx, DefaultStoreVariant)
+	if err != nil {
+		return nil, err
+	}
+	open := map[time.Weekday]bool{}
+	for _, day := range hours {
+		if !day.Closed {
+			open[time.Weekday(day.Weekday)] = true
+		}
+	}
+
+	local := now.In(s.hours.location)
+	first := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, s.hours.location)
+	if availability.HoursConfigured {
+		if availability.NextShipDate == nil {
+			return nil, nil
+		}
+		first = *availability.NextShipDate
+	}
+
+	var dates []time.Time
+	for offset := 0; offset <= s.config.WindowDays; offset++ {
+		day := first.AddDate(0, 0, offset)
+		if offset == 0 || !availability.HoursConfigured || open[day.Weekday()] {
+			dates = append(dates, day)
+		}
+	}
+	return dates, nil
+}
+
+// deliveryPrompt tells the assistant the earliest delivery date of each carrier
+func deliveryPrompt(slots []DeliverySlot) string {
+	seen := map[string]bool{}
+	var options []string
+	for _, slot := range slots {
+		if seen[slot.Carrier] {
+			continue
+		}
+		seen[slot.Carrier] = true
+		date, err := time.Parse(deliveryDateLayout, slot.Date)
+		if err != nil {
+			continue
+		}
+		options = append(options, fmt.Sprintf("%s by %s", slot.Carrier, date.Format("Monday, January 2")))
+	}
+	if len(options) == 0 {
+		return ""
+	}
+	return "Delivery: orders placed now can arrive with " + strings.Join(options, ", or ") +
+		". Customers may pick a later delivery date at checkout; mention this when they ask when an order will arrive."
+}
+
+// deliveryConfirmation describes an order's delivery date for the shopper
+func deliveryConfirmation(order *models.Order) string {
+	if order.DeliveryDate == nil {
+		return ""
+	}
+	message := "It's scheduled to arrive on " + order.DeliveryDate.Format("Monday, January 2")
+	if order.DeliveryCarrier != "" {
+		message += " by " + order.DeliveryCarrier
+	}
+	return message + "."
+}
@@ -0,0 +1,300 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Payment retry statuses
+const (
+	PaymentRetryRetrying  = "retrying"
+	PaymentRetryRecovered = "recovered"
+	PaymentRetryCancelled = "cancelled"
+)
+
+// PaymentRetryMessage is the WebSocket message type sent when a payment fails,
+// is retried, or the order is cancelled for non-payment
+const PaymentRetryMessage = "payment_retry"
+
+// PaymentRetrier confirms a failed payment again with the provider that took it
+type PaymentRetrier interface {
+	RetryPayment(provider, paymentIntentID string) (*PaymentStatus, error)
+}
+
+// DunningConfig controls how failed payments are retried
+type DunningConfig struct {
+	MaxAttempts   int
+	RetryInterval time.Duration // doubled after each failure
+	SweepInterval time.Duration
+	PayNowBaseURL string
+}
+
+// DunningConfigFromEnv reads DUNNING_MAX_ATTEMPTS (3), DUNNING_RETRY_HOURS (24),
+// DUNNING_SWEEP_MINUTES (15) and PAY_NOW_BASE_URL
+func DunningConfigFromEnv() DunningConfig {
+	baseURL := os.Getenv("PAY_NOW_BASE_URL")
+	if baseURL == "" {
+		baseURL = "http://localhost:3000/orders/pay"
+	}
+	return DunningConfig{
+		MaxAttempts:   envInt("DUNNING_MAX_ATTEMPTS", 3),
+		RetryInterval: time.Duration(envInt("DUNNING_RETRY_HOURS", 24)) * time.Hour,
+		SweepInterval: time.Duration(envInt("DUNNING_SWEEP_MINUTES", 15)) * time.Minute,
+		PayNowBaseURL: strings.TrimRight(baseURL, "/"),
+	}
+}
+
+// DunningNotice tells the shopper a payment failed and how to fix it
+type DunningNotice struct {
+	OrderID     uuid.UUID  `json:"order_id"`
+	OrderNumber string     `json:"order_number"`
+	Status      string     `json:"status"`
+	Attempts    int        `json:"attempts"`
+	MaxAttempts int        `json:"max_attempts"`
+	NextRetryAt *time.Time `json:"next_retry_at,omitempty"`
+	PayNowURL   string     `json:"pay_now_url,omitempty"`
+	Message     string     `json:"message"`
+}
+
+// DunningSweep summarises one run of due payment retries
+type DunningSweep struct {
+	Retried   int `json:"retried"`
+	Recovered int `json:"recovered"`
+	Cancelled int `json:"cancelled"`
+}
+
+// DunningService retries failed order payments, tells shoppers how to pay,
+// and cancels orders that still haven't been paid after MaxAttempts failures
+type DunningService struct {
+	db       *gorm.DB
+	orders   *OrderService
+	retrier  PaymentRetrier
+	notifier SessionNotifier
+	config   DunningConfig
+}
+
+// NewDunningService creates a new DunningService
+func NewDunningService(db *gorm.DB, retrier PaymentRetrier, notifier SessionNotifier, config DunningConfig) *DunningService {
+	return &DunningService{
+		db:       db,
+		orders:   NewOrderService(db),
+		retrier:  retrier,
+		notifier: notifier,
+		config:   config,
+	}
+}
+
+// PayNowURL returns the storefront link where the shopper can pay for an order
+func (s *DunningService) PayNowURL(order *Order) string {
+	return s.config.PayNowBaseURL + "/" + order.OrderNumber
+}
+
+// RecordFailure counts a failed payment for an order. The next retry is
+// scheduled with exponential backoff, or the order is cancelled and its stock
+// released once MaxAttempts is reached.
+func (s *DunningService) RecordFailure(ctx context.Context, orderID uuid.UUID, paymentIntentID, reason string) (*models.PaymentRetry, error) {
+	now := time.Now()
+
+	var order Order
+	if err := s.db.WithContext(ctx).Where("id = ?", orderID).First(&order).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("order not found")
+		}
+		return nil, fmt.Errorf("failed to fetch order: %v", err)
+	}
+
+	var retry models.PaymentRetry
+	err := s.db.WithContext(ctx).Where("order_id = ?", orderID).First(&retry).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		retry = models.PaymentRetry{
+			ID:        uuid.New(),
+			OrderID:   orderID,
+			Status:    PaymentRetryRetrying,
+			CreatedAt: now,
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to fetch payment retry: %v", err)
+	}
+	if retry.Status != PaymentRetryRetrying {
+		return &retry, nil
+	}
+
+	retry.Attempts++
+	retry.LastError = reason
+	retry.UpdatedAt = now
+	if paymentIntentID != "" {
+		retry.PaymentIntentID = paymentIntentID
+	}
+
+	if retry.Attempts >= s.config.MaxAttempts {
+		retry.Status = PaymentRetryCancelled
+		retry.NextRetryAt = nil
+	} else {
+		next := now.Add(s.config.RetryInterval * time.Duration(1<<(retry.Attempts-1)))
+		retry.NextRetryAt = &next
+	}
+
+	if err := s.db.WithContext(ctx).Save(&retry).Error; err != nil {
+		return nil, fmt.Errorf("failed to save payment retry: %v", err)
+	}
+
+	paymentStatus := "retrying"
+	if retry.Status == PaymentRetryCancelled {
+		paymentStatus = "failed"
+		if _, err := s.orders.CancelOrder(ctx, orderID); err != nil {
+			return nil, fmt.Errorf("failed to cancel unpaid order: %v", err)
+		}
+	}
+	if _, err := s.orders.UpdatePaymentStatus(ctx, orderID, paymentStatus, retry.PaymentIntentID); err != nil {
+		return nil, err
+	}
+
+	s.notify(&order, &retry)
+	return &retry, nil
+}
+
+// RecordSuccess closes the order's dunning once its payment goes through
+func (s *DunningService) RecordSuccess(ctx context.Context, orderID uuid.UUID) error {
+	err := s.db.WithContext(ctx).Model(&models.PaymentRetry{}).
+		Where("order_id = ? AND status = ?", orderID, PaymentRetryRetrying).
+		Updates(map[string]interface{}{
+			"status":        PaymentRetryRecovered,
+			"next_retry_at": nil,
+			"updated_at":    time.Now(),
+		}).Error
+	if err != nil {
+		return fmt.Errorf("failed to update payment retry: %v", err)
+	}
+	return nil
+}
+
+// RunDueRetries retries every payment whose next retry is due
+func (s *DunningService) RunDueRetries(ctx context.Context, now time.Time) (*DunningSweep, error) {
+	var due []models.PaymentRetry
+	if err := s.db.WithContext(ctx).
+		Where("status = ? AND next_retry_at <= ?", PaymentRetryRetrying, now).
+		Find(&due).Error; err != nil {
+		return nil, fmt.Errorf("failed to find due payment retries: %v", err)
+	}
+
+	result := &DunningSweep{}
+	for _, retry := range due {
+		result.Retried++
+
+		var order Order
+		if err := s.db.WithContext(ctx).Select("id", "payment_provider").Where("id = ?", retry.OrderID).First(&order).Error; err != nil {
+			return nil, fmt.Errorf("failed to fetch order: %v", err)
+		}
+
+		status, err := s.retrier.RetryPayment(order.PaymentProvider, retry.PaymentIntentID)
+		if err == nil && status.Status == "succeeded" {
+			if _, err := s.orders.UpdatePaymentStatus(ctx, retry.OrderID, "paid", retry.PaymentIntentID); err != nil {
+				return nil, err
+			}
+			if err := s.RecordSuccess(ctx, retry.OrderID); err != nil {
+				return nil, err
+			}
+			result.Recovered++
+			continue
+		}
+
+		reason := "payment was declined"
+		if err != nil {
+			reason = err.Error()
+		} else if status.Status != "" {
+			reason = "payment status: " + status.Status
+		}
+		updated, err := s.RecordFailure(ctx, retry.OrderID, retry.PaymentIntentID, reason)
+		if err != nil {
+			return nil, err
+		}
+		if updated.Status == PaymentRetryCancelled {
+			result.Cancelled++
+		}
+	}
+	return result, nil
+}
+
+// ScheduleRetries runs RunDueRetries every SweepInterval until ctx is done
+func (s *DunningService) ScheduleRetries(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(s.config.SweepInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				result, err := s.RunDueRetries(ctx, now)
+				if err != nil {
+					log.Printf("Failed to retry payments: %v", err)
+					continue
+				}
+				if result.Retried > 0 {
+					log.Printf("Payment retries: retried %d, recovered %d, cancelled %d", result.Retried, result.Recovered, result.Cancelled)
+				}
+			}
+		}
+	}()
+}
+
+// ProcessPaymentEvent feeds Stripe payment_intent webhooks into dunning
+func (s *DunningService) ProcessPaymentEvent(ctx context.Context, eventType string, eventData map[string]interface{}) error {
+	data, _ := eventData["data"].(map[string]interface{})
+	intent, _ := data["object"].(map[string]interface{})
+	metadata, _ := intent["metadata"].(map[string]interface{})
+	orderIDStr, _ := metadata["order_id"].(string)
+	orderID, err := uuid.Parse(orderIDStr)
+	if err != nil {
+		return nil // not one of our orders
+	}
+	paymentIntentID, _ := intent["id"].(string)
+
+	switch eventType {
+	case "payment_intent.payment_failed":
+		reason := "payment failed"
+		if lastError, ok := intent["last_payment_error"].(map[string]interface{}); ok {
+			if message, ok := lastError["message"].(string); ok && message != "" {
+				reason = message
+			}
+		}
+		_, err := s.RecordFailure(ctx, orderID, paymentIntentID, reason)
+		return err
+	case "payment_intent.succeeded":
+		return s.RecordSuccess(ctx, orderID)
+	}
+	return nil
+}
+
+// notify tells the order's shopper about a failed payment
+func (s *DunningService) notify(order *Order, retry *models.PaymentRetry) {
+	if s.notifier == nil {
+		return
+	}
+
+	notice := &DunningNotice{
+		OrderID:     order.ID,
+		OrderNumber: order.OrderNumber,
+		Status:      retry.Status,
+		Attempts:    retry.Attempts,
+		MaxAttempts: s.config.MaxAttempts,
+		NextRetryAt: retry.NextRetryAt,
+	}
+	if retry.Status == PaymentRetryCancelled {
+		notice.Message = fmt.Sprintf("We couldn't take payment for order %s after %d attempts, so it has been cancelled.", order.OrderNumber, retry.Attempts)
+	} else {
+		notice.PayNowURL = s.PayNowURL(order)
+		notice.Message = fmt.Sprintf("Payment for order %s failed. We'll try again automatically, or you can pay now: %s", order.OrderNumber, notice.PayNowURL)
+	}
+	s.notifier.NotifySession(order.SessionID, PaymentRetryMessage, notice)
+}
@@ -0,0 +1,484 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"chat-ecommerce-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// Domain event types published to the analytics stream
+const (
+	DomainEventProductViewed   = "product_viewed"
+	DomainEventSuggestionShown = "suggestion_shown"
+	DomainEventCartAdd         = "cart_add"
+	DomainEventOrderCreated    = "order_created"
+)
+
+// Where domain events are published and how they are encoded
+const (
+	EventSinkKafka   = "kafka"   // through a Kafka REST Proxy
+	EventSinkKinesis = "kinesis" // with Kinesis PutRecords
+
+	EventFormatJSON = "json"
+	EventFormatAvro = "avro"
+)
+
+// DomainEventSchemaVersion is bumped whenever DomainEventAvroSchema changes
+const DomainEventSchemaVersion = 1
+
+// DomainEventAvroSchema is the Avro schema of published events. Every field
+// has a value, so the JSON format is the same record with occurred_at as an
+// RFC 3339 timestamp instead of milliseconds.
+const DomainEventAvroSchema = `{
+  "type": "record",
+  "name": "DomainEvent",
+  "namespace": "com.chatcommerce.events",
+  "doc": "A storefront or chat event for analytics pipelines. Fields that don't apply to the event type are empty or zero.",
+  "fields": [
+    {"name": "id", "type": "string", "doc": "Unique per event, for deduplication"},
+    {"name": "type", "type": "string", "doc": "product_viewed, suggestion_shown, cart_add or order_created"},
+    {"name": "schema_version", "type": "int"},
+    {"name": "occurred_at", "type": {"type": "long", "logicalType": "timestamp-millis"}},
+    {"name": "session_id", "type": "string", "default": "", "doc": "The shopper's cart or chat session"},
+    {"name": "user_id", "type": "string", "default": ""},
+    {"name": "product_id", "type": "string", "default": ""},
+    {"name": "order_id", "type": "string", "default": ""},
+    {"name": "quantity", "type": "int", "default": 0},
+    {"name": "amount", "type": "double", "default": 0, "doc": "The line total of a cart_add, the total of an order_created"},
+    {"name": "currency", "type": "string", "default": ""}
+  ]
+}`
+
+// DomainEvent is an event published to the analytics stream
+type DomainEvent struct {
+	ID            string    `json:"id"`
+	Type          string    `json:"type"`
+	SchemaVersion int       `json:"schema_version"`
+	OccurredAt    time.Time `json:"occurred_at"`
+	SessionID     string    `json:"session_id"`
+	UserID        string    `json:"user_id"`
+	ProductID     string    `json:"product_id"`
+	OrderID       string    `json:"order_id"`
+	Quantity      int       `json:"quantity"`
+	Amount        float64   `json:"amount"`
+	Currency      string    `json:"currency"`
+}
+
+// avroValue is the event as an Avro JSON record, occurred_at in milliseconds
+func (e DomainEvent) avroValue() map[string]interface{} {
+	return map[string]interface{}{
+		"id":             e.ID,
+		"type":           e.Type,
+		"schema_version": e.SchemaVersion,
+		"occurred_at":    e.OccurredAt.UnixMilli(),
+		"session_id":     e.SessionID,
+		"user_id":        e.UserID,
+		"product_id":     e.ProductID,
+		"order_id":       e.OrderID,
+		"quantity":       e.Quantity,
+		"amount":         e.Amount,
+		"currency":       e.Currency,
+	}
+}
+
+// avroBinary encodes the event in Avro's binary encoding, fields in schema order
+func (e DomainEvent) avroBinary() []byte {
+	var b []byte
+	writeLong := func(v int64) {
+		b = binary.AppendVarint(b, v) // zig-zag, as Avro encodes int and long
+	}
+	writeString := func(v string) {
+		writeLong(int64(len(v)))
+		b = append(b, v...)
+	}
+	writeString(e.ID)
+	writeString(e.Type)
+	writeLong(int64(e.SchemaVersion))
+	writeLong(e.OccurredAt.UnixMilli())
+	writeString(e.SessionID)
+	writeString(e.UserID)
+	writeString(e.ProductID)
+	writeString(e.OrderID)
+	writeLong(int64(e.Quantity))
+	b = binary.LittleEndian.AppendUint64(b, math.Float64bits(e.Amount))
+	writeString(e.Currency)
+	return b
+}
+
+// partitionKey keeps a session's events in order on one partition or shard
+func (e DomainEvent) partitionKey() string {
+	if e.SessionID != "" {
+		return e.SessionID
+	}
+	return e.ID
+}
+
+// EventStreamConfig holds where domain events are published
+type EventStreamConfig struct {
+	Sink          string // kafka or kinesis; empty disables the stream
+	Format        string // json or avro
+	URL           string // the Kafka REST Proxy, or a Kinesis endpoint other than AWS's
+	Topic         string // the Kafka topic or Kinesis stream
+	Username      string // REST Proxy basic auth
+	Password      string
+	Region        string // Kinesis, with the AWS_* credentials
+	AccessKey     string
+	SecretKey     string
+	SessionToken  string
+	BufferSize    int // events waiting to be published; more are dropped
+	BatchSize     int
+	FlushInterval time.Duration
+}
+
+// EventStreamConfigFromEnv reads EVENT_STREAM_SINK, EVENT_STREAM_FORMAT
+// (json), EVENT_STREAM_URL, EVENT_STREAM_TOPIC (commerce-events),
+// EVENT_STREAM_USERNAME, EVENT_STREAM_PASSWORD, EVENT_STREAM_BUFFER (10000),
+// EVENT_STREAM_BATCH_SIZE (100), EVENT_STREAM_FLUSH_SECONDS (2) and, for
+// Kinesis, AWS_REGION, AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and
+// AWS_SESSION_TOKEN
+func EventStreamConfigFromEnv() EventStreamConfig {
+	format := strings.ToLower(os.Getenv("EVENT_STREAM_FORMAT"))
+	if format == "" {
+		format = EventFormatJSON
+	}
+	topic := os.Getenv("EVENT_STREAM_TOPIC")
+	if topic == "" {
+		topic = "commerce-events"
+	}
+	return EventStreamConfig{
+		Sink:          strings.ToLower(os.Getenv("EVENT_STREAM_SINK")),
+		Format:        format,
+		URL:           strings.TrimRight(os.Getenv("EVENT_STREAM_URL"), "/"),
+		Topic:         topic,
+		Username:      os.Getenv("EVENT_STREAM_USERNAME"),
+		Password:      os.Getenv("EVENT_STREAM_PASSWORD"),
+		Region:        os.Getenv("AWS_REGION"),
+		AccessKey:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretKey:     os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:  os.Getenv("AWS_SESSION_TOKEN"),
+		BufferSize:    envInt("EVENT_STREAM_BUFFER", 10000),
+		BatchSize:     envInt("EVENT_STREAM_BATCH_SIZE", 100),
+		FlushInterval: time.Duration(envInt("EVENT_STREAM_FLUSH_SECONDS", 2)) * time.Second,
+	}
+}
+
+// EventSink publishes a batch of domain events
+type EventSink interface {
+	Publish(ctx context.Context, events []DomainEvent) error
+}
+
+// EventStreamStats counts what happened to the events since the server started
+type EventStreamStats struct {
+	Enabled   bool   `json:"enabled"`
+	Sink      string `json:"sink,omitempty"`
+	Format    string `json:"format,omitempty"`
+	Topic     string `json:"topic,omitempty"`
+	Queued    int    `json:"queued"`
+	Published int64  `json:"published"`
+	Failed    int64  `json:"failed"`  // in batches the sink refused
+	Dropped   int64  `json:"dropped"` // the buffer was full
+}
+
+// EventStream publishes domain events to Kafka or Kinesis for analytics
+// pipelines. Events are queued and published in batches in the background,
+// so a slow or failing sink never holds up shoppers; events that don't fit
+// in the buffer are dropped. A nil or disabled stream ignores events.
+type EventStream struct {
+	config EventStreamConfig
+	sink   EventSink
+	queue  chan DomainEvent
+
+	published atomic.Int64
+	failed    atomic.Int64
+	dropped   atomic.Int64
+}
+
+// NewEventStream creates a new EventStream publishing to the configured sink
+func NewEventStream(config EventStreamConfig) *EventStream {
+	if config.BufferSize <= 0 {
+		config.BufferSize = 10000
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 100
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = 2 * time.Second
+	}
+	if config.Format != EventFormatAvro {
+		config.Format = EventFormatJSON
+	}
+
+	s := &EventStream{config: config, queue: make(chan DomainEvent, config.BufferSize)}
+	client := &http.Client{Timeout: 30 * time.Second}
+	switch config.Sink {
+	case EventSinkKafka:
+		s.sink = &kafkaRESTSink{config: config, client: client}
+	case EventSinkKinesis:
+		s.sink = &kinesisSink{config: config, client: client}
+	case "":
+	default:
+		log.Printf("Warning: unknown EVENT_STREAM_SINK %q, domain events aren't published", config.Sink)
+	}
+	return s
+}
+
+// WithSink replaces the configured sink
+func (s *EventStream) WithSink(sink EventSink) *EventStream {
+	s.sink = sink
+	return s
+}
+
+// Enabled reports whether events are published
+func (s *EventStream) Enabled() bool {
+	return s != nil && s.sink != nil
+}
+
+// Publish queues an event, filling in its ID, schema version and time
+func (s *EventStream) Publish(event DomainEvent) {
+	if !s.Enabled() {
+		return
+	}
+	if event.ID == "" {
+		event.ID = uuid.New().String()
+	}
+	event.SchemaVersion = DomainEventSchemaVersion
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now()
+	}
+	event.OccurredAt = event.OccurredAt.UTC()
+
+	select {
+	case s.queue <- event:
+	default:
+		s.dropped.Add(1)
+	}
+}
+
+// ProductViewed publishes a shopper opening a product's page
+func (s *EventStream) ProductViewed(sessionID string, userID *uuid.UUID, product *models.Product) {
+	s.Publish(DomainEvent{
+		Type:      DomainEventProductViewed,
+		SessionID: sessionID,
+		UserID:    userIDString(userID),
+		ProductID: product.ID.String(),
+		Amount:    product.Price,
+	})
+}
+
+// SuggestionsShown publishes each product the assistant suggested
+func (s *EventStream) SuggestionsShown(sessionID string, userID *uuid.UUID, suggestions []ProductSuggestion) {
+	for _, suggestion := range suggestions {
+		if suggestion.Product == nil {
+			continue
+		}
+		s.Publish(DomainEvent{
+			Type:      DomainEventSuggestionShown,
+			SessionID: sessionID,
+			UserID:    userIDString(userID),
+			ProductID: suggestion.Product.ID.String(),
+			Amount:    suggestion.Product.Price,
+		})
+	}
+}
+
+// CartAdded publishes a product added to a cart, from the storefront or chat
+func (s *EventStream) CartAdded(sessionID string, userID *uuid.UUID, productID uuid.UUID, quantity int, unitPrice float64) {
+	s.Publish(DomainEvent{
+		Type:      DomainEventCartAdd,
+		SessionID: sessionID,
+		UserID:    userIDString(userID),
+		ProductID: productID.String(),
+		Quantity:  quantity,
+		Amount:    roundCents(unitPrice * float64(quantity)),
+	})
+}
+
+// OrderCreated publishes a placed order
+func (s *EventStream) OrderCreated(order *Order) {
+	quantity := 0
+	for _, item := range order.Items {
+		quantity += item.Quantity
+	}
+	s.Publish(DomainEvent{
+		Type:      DomainEventOrderCreated,
+		SessionID: order.SessionID,
+		UserID:    order.UserID.String(),
+		OrderID:   order.ID.String(),
+		Quantity:  quantity,
+		Amount:    order.TotalAmount,
+		Currency:  order.Currency,
+	})
+}
+
+func userIDString(userID *uuid.UUID) string {
+	if userID == nil {
+		return ""
+	}
+	return userID.String()
+}
+
+// Flush publishes the queued events in batches. A batch the sink refuses is
+// counted as failed and not retried.
+func (s *EventStream) Flush(ctx context.Context) (int, error) {
+	if !s.Enabled() {
+		return 0, nil
+	}
+
+	published := 0
+	var lastErr error
+	for {
+		batch := s.nextBatch()
+		if len(batch) == 0 {
+			return published, lastErr
+		}
+		if err := s.sink.Publish(ctx, batch); err != nil {
+			s.failed.Add(int64(len(batch)))
+			lastErr = fmt.Errorf("failed to publish %d domain events: %v", len(batch), err)
+			log.Printf("Warning: %v", lastErr)
+			if ctx.Err() != nil {
+				return published, lastErr
+			}
+			continue
+		}
+		s.published.Add(int64(len(batch)))
+		published += len(batch)
+	}
+}
+
+// nextBatch takes up to a batch of events off the queue
+func (s *EventStream) nextBatch() []DomainEvent {
+	var batch []DomainEvent
+	for len(batch) < s.config.BatchSize {
+		select {
+		case event := <-s.queue:
+			batch = append(batch, event)
+		default:
+			return batch
+		}
+	}
+	return batch
+}
+
+// SchedulePublishing flushes the queue every flush interval until ctx is
+// done, then publishes what's left
+func (s *EventStream) SchedulePublishing(ctx context.Context) {
+	if !s.Enabled() {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(s.config.FlushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				flushCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+				s.Flush(flushCtx)
+				cancel()
+				return
+			case <-ticker.C:
+				s.Flush(ctx)
+			}
+		}
+	}()
+}
+
+// Stats reports the stream's configuration and counts
+func (s *EventStream) Stats() EventStreamStats {
+	if !s.Enabled() {
+		return EventStreamStats{}
+	}
+	return EventStreamStats{
+		Enabled:   true,
+		Sink:      s.config.Sink,
+		Format:    s.config.Format,
+		Topic:     s.config.Topic,
+		Queued:    len(s.queue),
+		Published: s.published.Load(),
+		Failed:    s.failed.Load(),
+		Dropped:   s.dropped.Load(),
+	}
+}
+
+// kafkaRESTSink produces events to a Kafka topic through a Kafka REST Proxy
+// (v2 API), keyed by session. Avro events are registered with the proxy's
+// schema registry under DomainEventAvroSchema.
+type kafkaRESTSink struct {
+	config EventStreamConfig
+	client *http.Client
+}
+
+func (k *kafkaRESTSink) Publish(ctx context.Context, events []DomainEvent) error {
+	if k.config.URL == "" {
+		return fmt.Errorf("EVENT_STREAM_URL must be set")
+	}
+
+	records := make([]map[string]interface{}, 0, len(events))
+	body := map[string]interface{}{}
+	contentType := "application/vnd.kafka.json.v2+json"
+	for _, event := range events {
+		var value interface{} = event
+		if k.config.Format == EventFormatAvro {
+			value = event.avroValue()
+		}
+		records = append(records, map[string]interface{}{"key": event.partitionKey(), "value": value})
+	}
+	if k.config.Format == EventFormatAvro {
+		contentType = "application/vnd.kafka.avro.v2+json"
+		body["key_schema"] = `"string"`
+		body["value_schema"] = DomainEventAvroSchema
+	}
+	body["records"] = records
+
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, k.config.URL+"/topics/"+k.config.Topic, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Accept", "application/vnd.kafka.v2+json")
+	if k.config.Username != "" {
+		req.SetBasicAuth(k.config.Username, k.config.Password)
+	}
+
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("Kafka REST Proxy returned %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+
+	// The proxy answers 200 with an error per record it couldn't produce
+	var produced struct {
+		Offsets []struct {
+			Error string `json:"error"`
+		} `json:"offsets"`
+	}
+	if json.Unmarshal(respBody, &produced) == nil {
+		for _, offset := range produced.Offsets {
+			if offset.Error != "" {
+				return fmt.Errorf("Kafka REST Proxy refused a record: %s", offset.Error)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// kinesisMaxRecords is the most records PutRecords takes in one call
+const kinesisMaxRecords = 500
+
+// kinesisSink puts events on a Kinesis data stream, partitioned by session,
+// signing requests with AWS Signature Version 4
+type kinesisSink struct {
+	config EventStreamConfig
+	client *http.Client
+}
+
+type kinesisRecord struct {
+	Data         []byte `json:"Data"` // base64 in JSON, as Kinesis expects
+	PartitionKey string `json:"PartitionKey"`
+}
+
+func (k *kinesisSink) Publish(ctx context.Context, events []DomainEvent) error {
+	if k.config.Region == "" || k.config.AccessKey == "" || k.config.SecretKey == "" {
+		return errors.New("AWS_REGION, AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set")
+	}
+
+	records := make([]kinesisRecord, 0, len(events))
+	for _, event := range events {
+		data := event.avroBinary()
+		if k.config.Format != EventFormatAvro {
+			encoded, err := json.Marshal(event)
+			if err != nil {
+				return err
+			}
+			data = encoded
+		}
+		records = append(records, kinesisRecord{Data: data, PartitionKey: event.partitionKey()})
+	}
+
+	for start := 0; start < len(records); start += kinesisMaxRecords {
+		end := start + kinesisMaxRecords
+		if end > len(records) {
+			end = len(records)
+		}
+		// Records Kinesis throttled are tried once more
+		failed, err := k.putRecords(ctx, records[start:end])
+		if err == nil && len(failed) > 0 {
+			failed, err = k.putRecords(ctx, failed)
+		}
+		if err != nil {
+			return err
+		}
+		if len(failed) > 0 {
+			return fmt.Errorf("Kinesis refused %d records", len(failed))
+		}
+	}
+	return nil
+}
+
+// putRecords sends records to the stream and returns the ones Kinesis refused
+func (k *kinesisSink) putRecords(ctx context.Context, records []kinesisRecord) ([]kinesisRecord, error) {
+	payload, err := json.Marshal(map[string]interface{}{
+		"StreamName": k.config.Topic,
+		"Records":    records,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := k.config.URL
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://kinesis.%s.amazonaws.com", k.config.Region)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint+"/", bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "Kinesis_20131202.PutRecords")
+	k.sign(req, payload, time.Now().UTC())
+
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024*1024))
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("Kinesis returned %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var result struct {
+		FailedRecordCount int `json:"FailedRecordCount"`
+		Records           []struct {
+			ErrorCode string `json:"ErrorCode"`
+		} `json:"Records"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse Kinesis response: %v", err)
+	}
+	if result.FailedRecordCount == 0 {
+		return nil, nil
+	}
+	var failed []kinesisRecord
+	for i, record := range result.Records {
+		if record.ErrorCode != "" && i < len(records) {
+			failed = append(failed, records[i])
+		}
+	}
+	return failed, nil
+}
+
+// sign adds AWS Signature Version 4 headers for the Kinesis service
+func (k *kinesisSink) sign(req *http.Request, payload []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(payload)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	if k.config.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", k.config.SessionToken)
+	}
+
+	headers := map[string]string{
+		"content-type": req.Header.Get("Content-Type"),
+		"host":         req.URL.Host,
+		"x-amz-date":   amzDate,
+		"x-amz-target": req.Header.Get("X-Amz-Target"),
+	}
+	names := []string{"content-type", "host", "x-amz-date"}
+	if k.config.SessionToken != "" {
+		headers["x-amz-security-token"] = k.config.SessionToken
+		names = append(names, "x-amz-security-token")
+	}
+	names = append(names, "x-amz-target")
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(headers[name]) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + k.config.Region + "/kinesis/aws4_request"
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+k.config.SecretKey), date)
+	key = hmacSHA256(key, k.config.Region)
+	key = hmacSHA256(key, "kinesis")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		k.config.AccessKey, scope, signedHeaders, signature))
+}
+
+// canonicalQuery encodes query parameters sorted by name, as SigV4 wants
+func canonicalQuery(values url.Values) string {
+	return strings.ReplaceAll(values.Encode(), "+", "%20")
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
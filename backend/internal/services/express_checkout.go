@@ -0,0 +1,350 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Wallets accepted by express checkout
+const (
+	WalletApplePay  = "apple_pay"
+	WalletGooglePay = "google_pay"
+)
+
+var (
+	// ErrEmptyCart is returned when express checkout is started with an empty cart
+	ErrEmptyCart = errors.New("cart is empty")
+	// ErrWalletPaymentDeclined is returned when the wallet payment doesn't go through
+	ErrWalletPaymentDeclined = errors.New("wallet payment was declined")
+	// ErrApplePayNotConfigured is returned when merchant validation isn't set up
+	ErrApplePayNotConfigured = errors.New("apple pay is not configured")
+)
+
+// WalletPaymentProvider is a PaymentProvider that can charge an Apple Pay or
+// Google Pay payment token in one step
+type WalletPaymentProvider interface {
+	PaymentProvider
+	ChargeWallet(req *WalletChargeRequest) (*PaymentStatus, error)
+}
+
+// WalletChargeRequest charges a wallet payment token for an order
+type WalletChargeRequest struct {
+	OrderID         uuid.UUID
+	OrderNumber     string
+	Amount          int64 // minor units
+	Currency        string
+	Wallet          string
+	PaymentToken    string
+	ShippingContact WalletContact
+}
+
+// WalletContact is a contact returned by the wallet payment sheet, in Apple
+// Pay's ApplePayPaymentContact shape. Google Pay addresses are mapped to it by
+// the storefront (address1..3 become addressLines).
+type WalletContact struct {
+	GivenName          string   `json:"givenName"`
+	FamilyName         string   `json:"familyName"`
+	EmailAddress       string   `json:"emailAddress"`
+	PhoneNumber        string   `json:"phoneNumber"`
+	AddressLines       []string `json:"addressLines"`
+	Locality           string   `json:"locality"`
+	AdministrativeArea string   `json:"administrativeArea"`
+	PostalCode         string   `json:"postalCode"`
+	CountryCode        string   `json:"countryCode"`
+}
+
+// Name returns the contact's full name
+func (c WalletContact) Name() string {
+	return strings.TrimSpace(c.GivenName + " " + c.FamilyName)
+}
+
+// Address converts the contact to the order address format
+func (c WalletContact) Address() map[string]interface{} {
+	return map[string]interface{}{
+		"name":        c.Name(),
+		"email":       c.EmailAddress,
+		"phone":       c.PhoneNumber,
+		"street":      strings.Join(c.AddressLines, ", "),
+		"city":        c.Locality,
+		"state":       c.AdministrativeArea,
+		"postal_code": c.PostalCode,
+		"country":     strings.ToUpper(c.CountryCode),
+	}
+}
+
+// validateShipping checks the contact has enough to ship to
+func (c WalletContact) validateShipping() error {
+	var missing []string
+	if len(c.AddressLines) == 0 || strings.TrimSpace(c.AddressLines[0]) == "" {
+		missing = append(missing, "addressLines")
+	}
+	if c.Locality == "" {
+		missing = append(missing, "locality")
+	}
+	if c.PostalCode == "" {
+		missing = append(missing, "postalCode")
+	}
+	if c.CountryCode == "" {
+		missing = append(missing, "countryCode")
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("shipping contact is missing %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// ExpressCheckoutRequest turns the shopper's cart into a paid order using a
+// wallet payment token and the shipping contact from the payment sheet
+type ExpressCheckoutRequest struct {
+	Wallet          string         `json:"wallet" binding:"required,oneof=apple_pay google_pay"`
+	PaymentToken    string         `json:"payment_token" binding:"required"`
+	ShippingContact WalletContact  `json:"shipping_contact" binding:"required"`
+	BillingContact  *WalletContact `json:"billing_contact"`
+	Provider        string         `json:"provider"` // Optional; routed by currency when empty
+}
+
+// ExpressCheckoutResult is the order created by express checkout and its payment
+type ExpressCheckoutResult struct {
+	Order   *Order         `json:"order"`
+	Payment *PaymentStatus `json:"payment_status"`
+}
+
+// ApplePayConfig holds the merchant identity used to validate Apple Pay sessions
+type ApplePayConfig struct {
+	MerchantID  string
+	DisplayName string
+	Domain      string
+	CertFile    string
+	KeyFile     string
+}
+
+// ApplePayConfigFromEnv reads APPLE_PAY_MERCHANT_ID, APPLE_PAY_DISPLAY_NAME,
+// APPLE_PAY_DOMAIN, APPLE_PAY_CERT_FILE and APPLE_PAY_KEY_FILE
+func ApplePayConfigFromEnv() ApplePayConfig {
+	return ApplePayConfig{
+		MerchantID:  os.Getenv("APPLE_PAY_MERCHANT_ID"),
+		DisplayName: os.Getenv("APPLE_PAY_DISPLAY_NAME"),
+		Domain:      os.Getenv("APPLE_PAY_DOMAIN"),
+		CertFile:    os.Getenv("APPLE_PAY_CERT_FILE"),
+		KeyFile:     os.Getenv("APPLE_PAY_KEY_FILE"),
+	}
+}
+
+// Enabled reports whether merchant validation is configured
+func (c ApplePayConfig) Enabled() bool {
+	return c.MerchantID != "" && c.Domain != "" && c.CertFile != "" && c.KeyFile != ""
+}
+
+// ExpressCheckoutService handles Apple Pay and Google Pay express checkout
+type ExpressCheckoutService struct {
+	db       *gorm.DB
+	carts    *ShoppingCartService
+	orders   *OrderService
+	payments *PaymentService
+	applePay ApplePayConfig
+}
+
+// NewExpressCheckoutService creates a new ExpressCheckoutService. Wallet
+// orders are placed through orders, so they go by the same flash sale
+// waiting room, purchase limits and pricing rules as any other checkout.
+func NewExpressCheckoutService(db *gorm.DB, carts *ShoppingCartService, orders *OrderService, payments *PaymentService, applePay ApplePayConfig) *ExpressCheckoutService {
+	return &ExpressCheckoutService{
+		db:       db,
+		carts:    carts,
+		orders:   orders,
+		payments: payments,
+		applePay: applePay,
+	}
+}
+
+// ValidateMerchant requests an Apple Pay merchant session for the validation
+// URL Apple Pay JS passes to onvalidatemerchant. The session is returned to
+// the browser as-is.
+func (s *ExpressCheckoutService) ValidateMerchant(ctx context.Context, validationURL string) (json.RawMessage, error) {
+	if !s.applePay.Enabled() {
+		return nil, ErrApplePayNotConfigured
+	}
+
+	// Only ever call Apple: the URL comes from the browser
+	parsed, err := url.Parse(validationURL)
+	if err != nil || parsed.Scheme != "https" || !strings.HasSuffix(parsed.Hostname(), ".apple.com") {
+		return nil, errors.New("invalid apple pay validation URL")
+	}
+
+	cert, err := tls.LoadX509KeyPair(s.applePay.CertFile, s.applePay.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load apple pay merchant certificate: %v", err)
+	}
+	client := &http.Client{
+		Timeout: 30 * time.Second,
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12},
+		},
+	}
+
+	displayName := s.applePay.DisplayName
+	if displayName == "" {
+		displayName = s.applePay.Domain
+	}
+	payload, err := json.Marshal(map[string]string{
+		"merchantIdentifier": s.applePay.MerchantID,
+		"displayName":        displayName,
+		"initiative":         "web",
+		"initiativeContext":  s.applePay.Domain,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, parsed.String(), bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to validate apple pay merchant: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read apple pay merchant session: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("apple pay merchant validation failed with status %d", resp.StatusCode)
+	}
+	return json.RawMessage(body), nil
+}
+
+// Checkout creates an order from the shopper's cart, addressed to the wallet's
+// shipping contact, and charges the wallet payment token. If the charge fails
+// the order is cancelled and its stock released.
+func (s *ExpressCheckoutService) Checkout(ctx context.Context, sessionID string, userID uuid.UUID, req *ExpressCheckoutRequest) (*ExpressCheckoutResult, error) {
+	if err := req.ShippingContact.validateShipping(); err != nil {
+		return nil, err
+	}
+
+	cart, err := s.carts.GetCart(sessionID, &userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(cart.Items) == 0 {
+		return nil, ErrEmptyCart
+	}
+
+	// Pick the provider before creating the order so a missing wallet fails early
+	provider, err := s.payments.WalletProvider(cart.Currency, req.Provider, req.Wallet)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]OrderItemRequest, 0, len(cart.Items))
+	for _, item := range cart.Items {
+		items = append(items, OrderItemRequest{
+			ProductID: item.ProductID,
+			VariantID: item.VariantID,
+			Quantity:  item.Quantity,
+		})
+	}
+
+	billing := req.ShippingContact
+	if req.BillingContact != nil {
+		billing = *req.BillingContact
+	}
+
+	order, err := s.orders.CreateOrder(ctx, &CreateOrderRequest{
+		UserID:          userID,
+		SessionID:       sessionID,
+		Items:           items,
+		ShippingAddress: req.ShippingContact.Address(),
+		BillingAddress:  billing.Address(),
+		PaymentMethod:   req.Wallet,
+		Notes:           "express checkout",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	status, chargeErr := provider.ChargeWallet(&WalletChargeRequest{
+		OrderID:         order.ID,
+		OrderNumber:     order.OrderNumber,
+		Amount:          int64(order.TotalAmount*100 + 0.5),
+		Currency:        strings.ToLower(order.Currency),
+		Wallet:          req.Wallet,
+		PaymentToken:    req.PaymentToken,
+		ShippingContact: req.ShippingContact,
+	})
+	if err := s.orders.UpdatePaymentProvider(ctx, order.ID, provider.Name()); err != nil {
+		return nil, err
+	}
+
+	if chargeErr != nil || (status.Status != "succeeded" && status.Status != "processing") {
+		if _, err := s.orders.CancelOrder(ctx, order.ID); err != nil {
+			return nil, fmt.Errorf("failed to cancel order after declined payment: %v", err)
+		}
+		paymentIntentID := ""
+		if status != nil {
+			paymentIntentID = status.PaymentIntentID
+		}
+		if _, err := s.orders.UpdatePaymentStatus(ctx, order.ID, "failed", paymentIntentID); err != nil {
+			return nil, err
+		}
+		if chargeErr != nil {
+			return nil, fmt.Errorf("%w: %v", ErrWalletPaymentDeclined, chargeErr)
+		}
+		return nil, fmt.Errorf("%w: payment status %s", ErrWalletPaymentDeclined, status.Status)
+	}
+
+	paymentStatus := "paid"
+	if status.Status == "processing" {
+		paymentStatus = "processing"
+	}
+	if _, err := s.orders.UpdatePaymentStatus(ctx, order.ID, paymentStatus, status.PaymentIntentID); err != nil {
+		return nil, err
+	}
+
+	if err := s.carts.ClearCart(sessionID, &userID); err != nil {
+		return nil, err
+	}
+
+	order, err = s.orders.GetOrderByID(ctx, order.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &ExpressCheckoutResult{Order: order, Payment: status}, nil
+}
+
+// WalletProvider returns the provider routed for currency if it can charge
+// the given wallet
+func (s *PaymentService) WalletProvider(currency, preferred, wallet string) (WalletPaymentProvider, error) {
+	provider, err := s.registry.Route(currency, preferred)
+	if err != nil {
+		return nil, err
+	}
+
+	walletProvider, ok := provider.(WalletPaymentProvider)
+	if !ok {
+		return nil, fmt.Errorf("payment provider %q does not support wallet payments", provider.Name())
+	}
+	for _, method := range provider.Capabilities().Methods {
+		if method.ID == wallet && method.Enabled {
+			return walletProvider, nil
+		}
+	}
+	return nil, fmt.Errorf("%s is not enabled for payment provider %q", wallet, provider.Name())
+}
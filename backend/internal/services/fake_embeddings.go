@@ -0,0 +1,66 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+// FakeEmbeddings is a deterministic EmbeddingProvider for tests. Each group
+// of words is one dimension, so texts sharing words of a group point the
+// same way whatever words they use, e.g. "sneakers" and "running shoes".
+type FakeEmbeddings struct {
+	mu     sync.Mutex
+	groups [][]string
+	err    error
+	calls  int
+}
+
+// NewFakeEmbeddings creates a FakeEmbeddings with a dimension per group of
+// words meaning the same thing
+func NewFakeEmbeddings(groups ...[]string) *FakeEmbeddings {
+	return &FakeEmbeddings{groups: groups}
+}
+
+// Fail makes every later call return err, or succeed again with nil
+func (f *FakeEmbeddings) Fail(err error) *FakeEmbeddings {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.err = err
+	return f
+}
+
+// Calls returns how many times Embed was called
+func (f *FakeEmbeddings) Calls() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls
+}
+
+// Embed counts the words of each group in each text
+func (f *FakeEmbeddings) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		padded := " " + wordPadded(text) + " "
+		vector := make([]float32, len(f.groups))
+		for dim, group := range f.groups {
+			for _, word := range group {
+				vector[dim] += float32(strings.Count(padded, " "+wordPadded(word)+" "))
+			}
+		}
+		vectors[i] = vector
+	}
+	return vectors, nil
+}
+
+// Model names the fake model
+func (f *FakeEmbeddings) Model() string {
+	return "fake-embeddings"
+}
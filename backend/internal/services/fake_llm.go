@@ -0,0 +1,234 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// FakeLLMResponse is a scripted reply returned by FakeLLM
+type FakeLLMResponse struct {
+	Content   string
+	Chunks    []string // Optional streaming chunks; defaults to word-by-word
+	ToolCalls []LLMToolCall
+	Err       error
+}
+
+// FakeToolCall builds a tool call with args encoded as its JSON arguments
+func FakeToolCall(name string, args interface{}) LLMToolCall {
+	arguments, _ := json.Marshal(args)
+	return LLMToolCall{ID: fmt.Sprintf("call_%s", name), Name: name, Arguments: string(arguments)}
+}
+
+// FakeLLM is a deterministic LLMProvider for tests. It returns scripted
+// responses in order, or the first rule whose substring matches the last
+// user message, and keeps token accounting for every call.
+type FakeLLM struct {
+	mu        sync.Mutex
+	script    []FakeLLMResponse
+	rules     []fakeLLMRule
+	fallback  FakeLLMResponse
+	requests  []LLMRequest
+	usage     LLMUsage
+	callCount int
+}
+
+type fakeLLMRule struct {
+	match    string
+	response FakeLLMResponse
+}
+
+// NewFakeLLM creates a FakeLLM that replies with the given responses in order
+func NewFakeLLM(responses ...string) *FakeLLM {
+	f := &FakeLLM{
+		fallback: FakeLLMResponse{Content: "I'm here to help you shop!"},
+	}
+	for _, r := range responses {
+		f.script = append(f.script, FakeLLMResponse{Content: r})
+	}
+	return f
+}
+
+// Enqueue appends scripted responses to be returned in order
+func (f *FakeLLM) Enqueue(responses ...FakeLLMResponse) *FakeLLM {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.script = append(f.script, responses...)
+	return f
+}
+
+// When registers a response returned whenever the last user message contains match.
+// Scripted responses take precedence over rules.
+func (f *FakeLLM) When(match string, response FakeLLMResponse) *FakeLLM {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.rules = append(f.rules, fakeLLMRule{match: strings.ToLower(match), response: response})
+	return f
+}
+
+// Fallback sets the response used when the script is exhausted and no rule matches
+func (f *FakeLLM) Fallback(response FakeLLMResponse) *FakeLLM {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.fallback = response
+	return f
+}
+
+// Complete returns the next scripted response
+func (f *FakeLLM) Complete(ctx context.Context, req LLMRequest) (*LLMResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	response := f.next(req)
+	if response.Err != nil {
+		return nil, response.Err
+	}
+
+	result := f.account(req, response.Content)
+	result.ToolCalls = response.ToolCalls
+	return result, nil
+}
+
+// Stream delivers the next scripted response chunk by chunk
+func (f *FakeLLM) Stream(ctx context.Context, req LLMRequest, onDelta func(delta string) error) (*LLMResponse, error) {
+	response := f.next(req)
+	if response.Err != nil {
+		return nil, response.Err
+	}
+
+	chunks := response.Chunks
+	if len(chunks) == 0 {
+		chunks = splitIntoChunks(response.Content)
+	}
+
+	var content strings.Builder
+	for _, chunk := range chunks {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if err := onDelta(chunk); err != nil {
+			return nil, err
+		}
+		content.WriteString(chunk)
+	}
+
+	result := f.account(req, content.String())
+	result.ToolCalls = response.ToolCalls
+	return result, nil
+}
+
+// Requests returns a copy of every request received so far
+func (f *FakeLLM) Requests() []LLMRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	requests := make([]LLMRequest, len(f.requests))
+	copy(requests, f.requests)
+	return requests
+}
+
+// LastRequest returns the most recent request, or an error if none was made
+func (f *FakeLLM) LastRequest() (LLMRequest, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.requests) == 0 {
+		return LLMRequest{}, errors.New("no requests received")
+	}
+	return f.requests[len(f.requests)-1], nil
+}
+
+// Usage returns the cumulative token usage across all calls
+func (f *FakeLLM) Usage() LLMUsage {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.usage
+}
+
+// CallCount returns the number of completion calls made
+func (f *FakeLLM) CallCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.callCount
+}
+
+// next records the request and picks the response to return
+func (f *FakeLLM) next(req LLMRequest) FakeLLMResponse {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.requests = append(f.requests, req)
+	f.callCount++
+
+	if len(f.script) > 0 {
+		response := f.script[0]
+		f.script = f.script[1:]
+		return response
+	}
+
+	lastUser := ""
+	for i := len(req.Messages) - 1; i >= 0; i-- {
+		if req.Messages[i].Role == "user" {
+			lastUser = strings.ToLower(req.Messages[i].Content)
+			break
+		}
+	}
+	for _, rule := range f.rules {
+		if strings.Contains(lastUser, rule.match) {
+			return rule.response
+		}
+	}
+
+	return f.fallback
+}
+
+// account computes token usage for a completed call and adds it to the totals
+func (f *FakeLLM) account(req LLMRequest, content string) *LLMResponse {
+	promptTokens := 0
+	for _, msg := range req.Messages {
+		promptTokens += EstimateTokens(msg.Content)
+	}
+	usage := LLMUsage{
+		PromptTokens:     promptTokens,
+		CompletionTokens: EstimateTokens(content),
+	}
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+
+	f.mu.Lock()
+	f.usage.PromptTokens += usage.PromptTokens
+	f.usage.CompletionTokens += usage.CompletionTokens
+	f.usage.TotalTokens += usage.TotalTokens
+	f.mu.Unlock()
+
+	return &LLMResponse{
+		Content: content,
+		Model:   req.Model,
+		Usage:   usage,
+	}
+}
+
+// EstimateTokens approximates the token count of text (roughly 4 characters per token)
+func EstimateTokens(text string) int {
+	if text == "" {
+		return 0
+	}
+	return (len(text) + 3) / 4
+}
+
+// splitIntoChunks splits content into word-sized chunks that concatenate back to content
+func splitIntoChunks(content string) []string {
+	var chunks []string
+	start := 0
+	for i := 1; i < len(content); i++ {
+		if content[i] == ' ' {
+			chunks = append(chunks, content[start:i])
+			start = i
+		}
+	}
+	if start < len(content) {
+		chunks = append(chunks, content[start:])
+	}
+	return chunks
+}
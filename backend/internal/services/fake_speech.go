@@ -0,0 +1,70 @@
+package services
+
+import (
+	"context"
+	"sync"
+)
+
+// FakeSpeech is a deterministic SpeechProvider for tests. It returns scripted
+// transcripts in order and "reads aloud" text as its bytes.
+type FakeSpeech struct {
+	mu          sync.Mutex
+	transcripts []string
+	err         error
+	heard       []ChatAudio
+	spoken      []string
+}
+
+// NewFakeSpeech creates a FakeSpeech that hears the given transcripts in order
+func NewFakeSpeech(transcripts ...string) *FakeSpeech {
+	return &FakeSpeech{transcripts: transcripts}
+}
+
+// Fail makes every later call return err, or succeed again with nil
+func (f *FakeSpeech) Fail(err error) *FakeSpeech {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.err = err
+	return f
+}
+
+// Heard returns the recordings transcribed so far
+func (f *FakeSpeech) Heard() []ChatAudio {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]ChatAudio(nil), f.heard...)
+}
+
+// Spoken returns the texts read aloud so far
+func (f *FakeSpeech) Spoken() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.spoken...)
+}
+
+// Transcribe returns the next scripted transcript, or "" once they run out
+func (f *FakeSpeech) Transcribe(ctx context.Context, audio ChatAudio) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.heard = append(f.heard, audio)
+	if f.err != nil {
+		return "", f.err
+	}
+	if len(f.transcripts) == 0 {
+		return "", nil
+	}
+	transcript := f.transcripts[0]
+	f.transcripts = f.transcripts[1:]
+	return transcript, nil
+}
+
+// Synthesize returns the text as the audio
+func (f *FakeSpeech) Synthesize(ctx context.Context, text string) (*SpokenReply, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.spoken = append(f.spoken, text)
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &SpokenReply{Data: []byte(text), ContentType: "audio/mpeg"}, nil
+}
@@ -0,0 +1,246 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// ErrLLMBudgetExhausted is returned when the LLM token budget has been used up
+var ErrLLMBudgetExhausted = errors.New("LLM budget exhausted")
+
+// Fallback intents handled by the default rules
+const (
+	FallbackIntentGreeting    = "greeting"
+	FallbackIntentAddToCart   = "add_to_cart"
+	FallbackIntentCartSummary = "cart_summary"
+	FallbackIntentSearch      = "search"
+)
+
+// Reasons for serving a fallback response
+const (
+	FallbackReasonUnavailable     = "llm_unavailable"
+	FallbackReasonBudgetExhausted = "llm_budget_exhausted"
+)
+
+// FallbackRequest is the input to a fallback rule
+type FallbackRequest struct {
+	SessionID string
+	UserID    *uuid.UUID
+	Message   string
+	Intent    *MessageIntent // what the message is about
+	Cart      *CartResponse
+	Products  []models.Product
+	Segments  []models.Segment // customer segments, highest priority first
+}
+
+// FallbackRule answers messages matching an intent without calling the LLM.
+// Respond may return a nil response to let the next rule handle the message.
+type FallbackRule struct {
+	Intent  string
+	Match   func(message string) bool
+	Respond func(ctx context.Context, req *FallbackRequest) (*ChatResponse, error)
+}
+
+// FallbackResponder serves chat traffic from an ordered list of rules
+type FallbackResponder struct {
+	rules []FallbackRule
+}
+
+// NewFallbackResponder creates a new FallbackResponder
+func NewFallbackResponder(rules ...FallbackRule) *FallbackResponder {
+	return &FallbackResponder{
+		rules: rules,
+	}
+}
+
+// Respond returns the response of the first matching rule and its intent
+func (r *FallbackResponder) Respond(ctx context.Context, req *FallbackRequest) (*ChatResponse, string, error) {
+	message := strings.ToLower(strings.TrimSpace(req.Message))
+	for _, rule := range r.rules {
+		if rule.Match != nil && !rule.Match(message) {
+			continue
+		}
+		response, err := rule.Respond(ctx, req)
+		if err != nil {
+			return nil, rule.Intent, err
+		}
+		if response != nil {
+			return response, rule.Intent, nil
+		}
+	}
+	return nil, "", errors.New("no fallback rule matched")
+}
+
+// fallbackReason maps an LLM error to a fallback reason, or "" if the error
+// should not be answered by the fallback responder
+func fallbackReason(err error) string {
+	switch {
+	case errors.Is(err, ErrLLMBudgetExhausted):
+		return FallbackReasonBudgetExhausted
+	case errors.Is(err, ErrLLMUnavailable):
+		return FallbackReasonUnavailable
+	default:
+		return ""
+	}
+}
+
+// defaultFallbackRules returns the greeting, add-to-cart, cart summary and
+// search rules, in that order
+func (s *ChatService) defaultFallbackRules() []FallbackRule {
+	return []FallbackRule{
+		{
+			Intent:  FallbackIntentGreeting,
+			Match:   isGreeting,
+			Respond: s.fallbackGreeting,
+		},
+		{
+			Intent:  FallbackIntentAddToCart,
+			Match:   func(message string) bool { return containsWord(message, "add") },
+			Respond: s.fallbackAddToCart,
+		},
+		{
+			Intent:  FallbackIntentCartSummary,
+			Match:   isCartQuestion,
+			Respond: s.fallbackCartSummary,
+		},
+		{
+			Intent:  FallbackIntentSearch,
+			Respond: s.fallbackSearch,
+		},
+	}
+}
+
+var (
+	greetingWords   = []string{"hi", "hello", "hey", "hiya", "howdy", "good morning", "good afternoon", "good evening"}
+	wordPattern     = regexp.MustCompile(`[a-z0-9']+`)
+	quantityPattern = regexp.MustCompile(`\b(\d{1,3})\b`)
+)
+
+func isGreeting(message string) bool {
+	words := wordPattern.FindAllString(message, -1)
+	if len(words) == 0 || len(words) > 4 {
+		return false
+	}
+	joined := strings.Join(words, " ")
+	for _, greeting := range greetingWords {
+		if joined == greeting || strings.HasPrefix(joined, greeting+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+func isCartQuestion(message string) bool {
+	return containsWord(message, "cart") || containsWord(message, "basket")
+}
+
+func containsWord(message, word string) bool {
+	for _, w := range wordPattern.FindAllString(message, -1) {
+		if w == word {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *ChatService) fallbackGreeting(ctx context.Context, req *FallbackRequest) (*ChatResponse, error) {
+	greeting := "Hello!"
+	for _, segment := range req.Segments {
+		if segment.Greeting != "" {
+			greeting = segment.Greeting
+			break
+		}
+	}
+	return &ChatResponse{
+		Message: greeting + " Our assistant is running in limited mode right now, but I can still search for products, add items to your cart, or show your cart.",
+	}, nil
+}
+
+func (s *ChatService) fallbackAddToCart(ctx context.Context, req *FallbackRequest) (*ChatResponse, error) {
+	product := matchProductByName(strings.ToLower(req.Message), req.Products)
+	if product == nil {
+		return nil, nil
+	}
+
+	quantity := 1
+	if match := quantityPattern.FindStringSubmatch(req.Message); match != nil {
+		if q, err := strconv.Atoi(match[1]); err == nil && q > 0 {
+			quantity = q
+		}
+	}
+
+	err := s.cartService.AddToCart(req.SessionID, req.UserID, AddToCartRequest{
+		ProductID: product.ID,
+		Quantity:  quantity,
+	})
+	if err != nil {
+		return &ChatResponse{
+			Message: fmt.Sprintf("Sorry, I couldn't add %s to your cart: %v", product.Name, err),
+		}, nil
+	}
+
+	return &ChatResponse{
+		Message: fmt.Sprintf("Added %d x %s to your cart.", quantity, product.Name),
+		Actions: []ChatAction{
+			{
+				Type: "add_to_cart",
+				Payload: map[string]interface{}{
+					"product_id": product.ID.String(),
+					"quantity":   quantity,
+				},
+			},
+		},
+	}, nil
+}
+
+func (s *ChatService) fallbackCartSummary(ctx context.Context, req *FallbackRequest) (*ChatResponse, error) {
+	cart := req.Cart
+	if cart == nil || len(cart.Items) == 0 {
+		return &ChatResponse{Message: "Your cart is empty."}, nil
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "You have %d item(s) in your cart:", cart.ItemCount)
+	for _, item := range cart.Items {
+		fmt.Fprintf(&b, "\n- %s (Qty: %d, $%.2f)", item.ProductName, item.Quantity, item.TotalPrice)
+	}
+	fmt.Fprintf(&b, "\nTotal: $%.2f", cart.TotalAmount)
+
+	return &ChatResponse{Message: b.String()}, nil
+}
+
+func (s *ChatService) fallbackSearch(ctx context.Context, req *FallbackRequest) (*ChatResponse, error) {
+	var suggestions []ProductSuggestion
+	if req.Products != nil {
+		suggestions = s.generateRelevantSuggestions(ctx, req.Message, req.Intent, req.Products)
+	}
+
+	return &ChatResponse{
+		Message:     AssistantBusyMessage,
+		Suggestions: suggestions,
+	}, nil
+}
+
+// matchProductByName returns the product with the longest name fully contained
+// in the message, so "add the wireless headphones" prefers "Wireless Headphones"
+// over "Headphones"
+func matchProductByName(message string, products []models.Product) *models.Product {
+	var best *models.Product
+	for i := range products {
+		name := strings.ToLower(products[i].Name)
+		if name == "" || !strings.Contains(message, name) {
+			continue
+		}
+		if best == nil || len(name) > len(best.Name) {
+			best = &products[i]
+		}
+	}
+	return best
+}
@@ -0,0 +1,624 @@
+package services
+
+import (
+	"bytes"
+	"chat-ecommerce-backend/internal/models"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Payment ledger transaction types
+const (
+	TransactionCharge = "charge"
+	TransactionRefund = "refund"
+)
+
+// Reconciliation discrepancy kinds
+const (
+	DiscrepancyAmountMismatch  = "amount_mismatch"
+	DiscrepancyFeeMismatch     = "fee_mismatch"
+	DiscrepancyMissingInLedger = "missing_in_ledger"
+	DiscrepancyMissingInReport = "missing_in_report"
+)
+
+// PaymentFee is a provider's processing fee: a percentage of the amount plus
+// a fixed amount per charge
+type PaymentFee struct {
+	Percent float64
+	Fixed   float64
+}
+
+// Estimate returns the fee charged on amount, rounded to the cent
+func (f PaymentFee) Estimate(amount float64) float64 {
+	return roundCents(amount*f.Percent/100 + f.Fixed)
+}
+
+// PaymentFeesFromEnv reads provider fees from PAYMENT_FEES, e.g.
+// "stripe=2.9%+0.30,paypal=3.49%+0.49". Providers that aren't listed, such as
+// offline payments, are recorded without fees.
+func PaymentFeesFromEnv() map[string]PaymentFee {
+	fees := map[string]PaymentFee{
+		PaymentProviderStripe: {Percent: 2.9, Fixed: 0.30},
+		PaymentProviderPayPal: {Percent: 3.49, Fixed: 0.49},
+	}
+
+	for _, entry := range strings.Split(os.Getenv("PAYMENT_FEES"), ",") {
+		provider, rate, ok := strings.Cut(entry, "=")
+		if !ok {
+			continue
+		}
+		percent, fixed, _ := strings.Cut(rate, "+")
+		fee := PaymentFee{}
+		var err error
+		if fee.Percent, err = strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(percent), "%"), 64); err != nil {
+			log.Printf("Invalid PAYMENT_FEES entry %q ignored", entry)
+			continue
+		}
+		if fixed = strings.TrimSpace(fixed); fixed != "" {
+			if fee.Fixed, err = strconv.ParseFloat(fixed, 64); err != nil {
+				log.Printf("Invalid PAYMENT_FEES entry %q ignored", entry)
+				continue
+			}
+		}
+		fees[strings.TrimSpace(strings.ToLower(provider))] = fee
+	}
+	return fees
+}
+
+// FinanceService records the payment ledger and reports on settlements
+type FinanceService struct {
+	db   *gorm.DB
+	fees map[string]PaymentFee
+}
+
+// NewFinanceService creates a new FinanceService
+func NewFinanceService(db *gorm.DB) *FinanceService {
+	return &FinanceService{
+		db:   db,
+		fees: PaymentFeesFromEnv(),
+	}
+}
+
+// WithFees replaces the provider fees read from the environment
+func (s *FinanceService) WithFees(fees map[string]PaymentFee) *FinanceService {
+	s.fees = fees
+	return s
+}
+
+// RecordCharge adds the charge for a paid order to the ledger. Orders are
+// only charged once, so recording the same order again does nothing.
+func (s *FinanceService) RecordCharge(ctx context.Context, order *Order) error {
+	db := s.db.WithContext(ctx)
+
+	var count int64
+	if err := db.Model(&models.PaymentTransaction{}).
+		Where("order_id = ? AND type = ?", order.ID, TransactionCharge).
+		Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to check payment ledger: %v", err)
+	}
+	if count > 0 {
+		return nil
+	}
+
+	provider := ledgerProvider(order)
+	transaction := models.PaymentTransaction{
+		ID:              uuid.New(),
+		OrderID:         order.ID,
+		Provider:        provider,
+		PaymentIntentID: order.PaymentIntentID,
+		Type:            TransactionCharge,
+		Amount:          order.TotalAmount,
+		Fee:             s.fees[provider].Estimate(order.TotalAmount),
+		Currency:        order.Currency,
+		OccurredAt:      time.Now(),
+	}
+	if err := db.Create(&transaction).Error; err != nil {
+		return fmt.Errorf("failed to record charge: %v", err)
+	}
+	return nil
+}
+
+// RecordRefund adds a refund of an order's payment to the ledger. A zero
+// amount refunds whatever is left of the charge. Providers keep their fee on
+// refunds, so none is recorded.
+func (s *FinanceService) RecordRefund(ctx context.Context, order *Order, amount float64) error {
+	db := s.db.WithContext(ctx)
+
+	if amount <= 0 {
+		var totals struct {
+			Charged  float64
+			Refunded float64
+		}
+		if err := db.Model(&models.PaymentTransaction{}).
+			Select("COALESCE(SUM(CASE WHEN type = ? THEN amount ELSE 0 END), 0) AS charged, COALESCE(SUM(CASE WHEN type = ? THEN amount ELSE 0 END), 0) AS refunded", TransactionCharge, TransactionRefund).
+			Where("order_id = ?", order.ID).
+			Scan(&totals).Error; err != nil {
+			return fmt.Errorf("failed to check payment ledger: %v", err)
+		}
+		amount = roundCents(totals.Charged - totals.Refunded)
+		if amount <= 0 {
+			return nil
+		}
+	}
+
+	transaction := models.PaymentTransaction{
+		ID:              uuid.New(),
+		OrderID:         order.ID,
+		Provider:        ledgerProvider(order),
+		PaymentIntentID: order.PaymentIntentID,
+		Type:            TransactionRefund,
+		Amount:          amount,
+		Currency:        order.Currency,
+		OccurredAt:      time.Now(),
+	}
+	if err := db.Create(&transaction).Error; err != nil {
+		return fmt.Errorf("failed to record refund: %v", err)
+	}
+	return nil
+}
+
+// ledgerProvider returns the provider an order's money went through
+func ledgerProvider(order *Order) string {
+	if order.PaymentProvider == "" {
+		return "unknown"
+	}
+	return order.PaymentProvider
+}
+
+// SettlementFilter selects ledger transactions for settlement reports. To is
+// exclusive.
+type SettlementFilter struct {
+	From     time.Time
+	To       time.Time
+	Provider string
+}
+
+// SettlementSummary totals one day of a provider's transactions in one currency
+type SettlementSummary struct {
+	Date     string  `json:"date"`
+	Provider string  `json:"provider"`
+	Currency string  `json:"currency"`
+	Charges  int     `json:"charges"`
+	Refunds  int     `json:"refunds"`
+	Gross    float64 `json:"gross"`
+	Refunded float64 `json:"refunded"`
+	Fees     float64 `json:"fees"`
+	Net      float64 `json:"net"`
+}
+
+// SettlementSummaries totals the ledger per day (UTC), provider and currency.
+// Net is gross less refunds and fees.
+func (s *FinanceService) SettlementSummaries(ctx context.Context, filter SettlementFilter) ([]SettlementSummary, error) {
+	query := s.db.WithContext(ctx).Where("occurred_at >= ? AND occurred_at < ?", filter.From, filter.To)
+	if filter.Provider != "" {
+		query = query.Where("provider = ?", filter.Provider)
+	}
+
+	var transactions []models.PaymentTransaction
+	if err := query.Order("occurred_at ASC").Find(&transactions).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch payment transactions: %v", err)
+	}
+
+	byKey := make(map[string]*SettlementSummary)
+	for _, transaction := range transactions {
+		date := transaction.OccurredAt.UTC().Format("2006-01-02")
+		key := date + "|" + transaction.Provider + "|" + transaction.Currency
+		summary, ok := byKey[key]
+		if !ok {
+			summary = &SettlementSummary{Date: date, Provider: transaction.Provider, Currency: transaction.Currency}
+			byKey[key] = summary
+		}
+
+		switch transaction.Type {
+		case TransactionCharge:
+			summary.Charges++
+			summary.Gross += transaction.Amount
+		case TransactionRefund:
+			summary.Refunds++
+			summary.Refunded += transaction.Amount
+		}
+		summary.Fees += transaction.Fee
+	}
+
+	summaries := make([]SettlementSummary, 0, len(byKey))
+	for _, summary := range byKey {
+		summary.Gross = roundCents(summary.Gross)
+		summary.Refunded = roundCents(summary.Refunded)
+		summary.Fees = roundCents(summary.Fees)
+		summary.Net = roundCents(summary.Gross - summary.Refunded - summary.Fees)
+		summaries = append(summaries, *summary)
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		a, b := summaries[i], summaries[j]
+		if a.Date != b.Date {
+			return a.Date < b.Date
+		}
+		if a.Provider != b.Provider {
+			return a.Provider < b.Provider
+		}
+		return a.Currency < b.Currency
+	})
+	return summaries, nil
+}
+
+var settlementCSVHeader = []string{"date", "provider", "currency", "charges", "refunds", "gross", "refunded", "fees", "net"}
+
+// ExportSettlementsCSV exports the settlement summaries to CSV
+func (s *FinanceService) ExportSettlementsCSV(ctx context.Context, filter SettlementFilter) ([]byte, error) {
+	summaries, err := s.SettlementSummaries(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write(settlementCSVHeader)
+	for _, summary := range summaries {
+		w.Write([]string{
+			summary.Date,
+			summary.Provider,
+			summary.Currency,
+			strconv.Itoa(summary.Charges),
+			strconv.Itoa(summary.Refunds),
+			strconv.FormatFloat(summary.Gross, 'f', 2, 64),
+			strconv.FormatFloat(summary.Refunded, 'f', 2, 64),
+			strconv.FormatFloat(summary.Fees, 'f', 2, 64),
+			strconv.FormatFloat(summary.Net, 'f', 2, 64),
+		})
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, fmt.Errorf("failed to write settlements CSV: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// Payout report columns, by the names providers use for them
+var (
+	payoutIDColumns     = []string{"payment_intent_id", "payment_intent", "source_id", "transaction_id", "transaction id", "reference"}
+	payoutTypeColumns   = []string{"type", "reporting_category", "transaction_type", "transaction event code"}
+	payoutAmountColumns = []string{"amount", "gross"}
+	payoutFeeColumns    = []string{"fee"}
+	payoutDateColumns   = []string{"created", "created_utc", "date", "available_on"}
+)
+
+// ReconciliationDiscrepancy is a payment whose payout report and ledger
+// entries disagree. Line is the report row, 0 for payments only in the ledger.
+type ReconciliationDiscrepancy struct {
+	Kind            string  `json:"kind"`
+	PaymentIntentID string  `json:"payment_intent_id"`
+	Type            string  `json:"type"`
+	ReportAmount    float64 `json:"report_amount"`
+	LedgerAmount    float64 `json:"ledger_amount"`
+	ReportFee       float64 `json:"report_fee"`
+	LedgerFee       float64 `json:"ledger_fee"`
+	Line            int     `json:"line,omitempty"`
+}
+
+// payoutEntry totals a payment's charges or refunds on one side of a reconciliation
+type payoutEntry struct {
+	amount float64
+	fee    float64
+	line   int
+}
+
+// Reconcile matches a provider payout report against the ledger. The report
+// is a CSV with a payment ID, type (charge or refund), amount and fee column
+// per row; Stripe balance reports and PayPal activity exports work as is.
+// Payments in the ledger but not the report are looked for within the dates
+// the report covers.
+func (s *FinanceService) Reconcile(ctx context.Context, provider, fileName string, r io.Reader, createdBy *uuid.UUID) (*models.PayoutReconciliation, error) {
+	if provider == "" {
+		return nil, errors.New("provider is required")
+	}
+
+	rows, err := readImportCSV(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) > 0 {
+		if _, ok := firstColumn(rows[0], payoutIDColumns); !ok {
+			return nil, errors.New("payout report is missing a payment ID column")
+		}
+		if _, ok := firstColumn(rows[0], payoutAmountColumns); !ok {
+			return nil, errors.New("payout report is missing an amount column")
+		}
+	}
+
+	report := make(map[string]*payoutEntry)
+	var periodStart, periodEnd *time.Time
+	var discrepancies []ReconciliationDiscrepancy
+	for _, row := range rows {
+		id := payoutValue(row, payoutIDColumns)
+		transactionType := payoutTransactionType(payoutValue(row, payoutTypeColumns))
+		if id == "" || transactionType == "" {
+			// Payouts, adjustments and other balance movements aren't payments
+			continue
+		}
+		amount, err := parsePayoutAmount(payoutValue(row, payoutAmountColumns))
+		if err != nil {
+			return nil, fmt.Errorf("row %d: invalid amount: %v", row.line, err)
+		}
+		fee, err := parsePayoutAmount(payoutValue(row, payoutFeeColumns))
+		if err != nil {
+			return nil, fmt.Errorf("row %d: invalid fee: %v", row.line, err)
+		}
+
+		if date, ok := parsePayoutDate(payoutValue(row, payoutDateColumns)); ok {
+			if periodStart == nil || date.Before(*periodStart) {
+				periodStart = &date
+			}
+			if periodEnd == nil || date.After(*periodEnd) {
+				periodEnd = &date
+			}
+		}
+
+		key := id + "|" + transactionType
+		entry, ok := report[key]
+		if !ok {
+			entry = &payoutEntry{line: row.line}
+			report[key] = entry
+		}
+		entry.amount += amount
+		entry.fee += fee
+	}
+
+	db := s.db.WithContext(ctx)
+
+	// Ledger entries for the reported payments, wherever they fall
+	ids := make([]string, 0, len(report))
+	for key := range report {
+		id, _, _ := strings.Cut(key, "|")
+		ids = append(ids, id)
+	}
+	var transactions []models.PaymentTransaction
+	if len(ids) > 0 {
+		if err := db.Where("provider = ? AND payment_intent_id IN ?", provider, ids).Find(&transactions).Error; err != nil {
+			return nil, fmt.Errorf("failed to fetch payment transactions: %v", err)
+		}
+	}
+	// ...and everything else the provider settled in the period the report covers
+	if periodStart != nil {
+		var inPeriod []models.PaymentTransaction
+		end := periodEnd.Add(24 * time.Hour)
+		if err := db.Where("provider = ? AND occurred_at >= ? AND occurred_at < ?", provider, *periodStart, end).
+			Find(&inPeriod).Error; err != nil {
+			return nil, fmt.Errorf("failed to fetch payment transactions: %v", err)
+		}
+		transactions = append(transactions, inPeriod...)
+	}
+
+	ledger := make(map[string]*payoutEntry)
+	seen := make(map[uuid.UUID]bool, len(transactions))
+	for _, transaction := range transactions {
+		if seen[transaction.ID] {
+			continue
+		}
+		seen[transaction.ID] = true
+
+		key := transaction.PaymentIntentID + "|" + transaction.Type
+		entry, ok := ledger[key]
+		if !ok {
+			entry = &payoutEntry{}
+			ledger[key] = entry
+		}
+		entry.amount += transaction.Amount
+		entry.fee += transaction.Fee
+	}
+
+	reconciliation := &models.PayoutReconciliation{
+		ID:          uuid.New(),
+		Provider:    provider,
+		FileName:    fileName,
+		PeriodStart: periodStart,
+		PeriodEnd:   periodEnd,
+		Rows:        len(rows),
+		CreatedBy:   createdBy,
+	}
+
+	for _, key := range sortedKeys(report) {
+		id, transactionType, _ := strings.Cut(key, "|")
+		reported := report[key]
+		reconciliation.ReportNet += signedNet(transactionType, reported)
+
+		recorded, ok := ledger[key]
+		if !ok {
+			reconciliation.MissingInLedger++
+			discrepancies = append(discrepancies, ReconciliationDiscrepancy{
+				Kind:            DiscrepancyMissingInLedger,
+				PaymentIntentID: id,
+				Type:            transactionType,
+				ReportAmount:    roundCents(reported.amount),
+				ReportFee:       roundCents(reported.fee),
+				Line:            reported.line,
+			})
+			continue
+		}
+
+		discrepancy := ReconciliationDiscrepancy{
+			PaymentIntentID: id,
+			Type:            transactionType,
+			ReportAmount:    roundCents(reported.amount),
+			LedgerAmount:    roundCents(recorded.amount),
+			ReportFee:       roundCents(reported.fee),
+			LedgerFee:       roundCents(recorded.fee),
+			Line:            reported.line,
+		}
+		switch {
+		case discrepancy.ReportAmount != discrepancy.LedgerAmount:
+			discrepancy.Kind = DiscrepancyAmountMismatch
+		case discrepancy.ReportFee != discrepancy.LedgerFee:
+			discrepancy.Kind = DiscrepancyFeeMismatch
+		default:
+			reconciliation.Matched++
+			continue
+		}
+		reconciliation.Mismatched++
+		discrepancies = append(discrepancies, discrepancy)
+	}
+
+	for _, key := range sortedKeys(ledger) {
+		recorded := ledger[key]
+		id, transactionType, _ := strings.Cut(key, "|")
+		reconciliation.LedgerNet += signedNet(transactionType, recorded)
+		if _, ok := report[key]; ok {
+			continue
+		}
+		reconciliation.MissingInReport++
+		discrepancies = append(discrepancies, ReconciliationDiscrepancy{
+			Kind:            DiscrepancyMissingInReport,
+			PaymentIntentID: id,
+			Type:            transactionType,
+			LedgerAmount:    roundCents(recorded.amount),
+			LedgerFee:       roundCents(recorded.fee),
+		})
+	}
+	reconciliation.ReportNet = roundCents(reconciliation.ReportNet)
+	reconciliation.LedgerNet = roundCents(reconciliation.LedgerNet)
+
+	if discrepancies == nil {
+		discrepancies = []ReconciliationDiscrepancy{}
+	}
+	discrepanciesJSON, err := json.Marshal(discrepancies)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode discrepancies: %v", err)
+	}
+	reconciliation.Discrepancies = discrepanciesJSON
+
+	if err := db.Create(reconciliation).Error; err != nil {
+		return nil, fmt.Errorf("failed to save reconciliation: %v", err)
+	}
+	return reconciliation, nil
+}
+
+// ListReconciliations returns past reconciliations, newest first, optionally
+// for one provider
+func (s *FinanceService) ListReconciliations(ctx context.Context, provider string) ([]models.PayoutReconciliation, error) {
+	query := s.db.WithContext(ctx).Order("created_at DESC")
+	if provider != "" {
+		query = query.Where("provider = ?", provider)
+	}
+
+	var reconciliations []models.PayoutReconciliation
+	if err := query.Find(&reconciliations).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch reconciliations: %v", err)
+	}
+	return reconciliations, nil
+}
+
+// GetReconciliation returns a reconciliation by ID
+func (s *FinanceService) GetReconciliation(ctx context.Context, id uuid.UUID) (*models.PayoutReconciliation, error) {
+	var reconciliation models.PayoutReconciliation
+	if err := s.db.WithContext(ctx).Where("id = ?", id).First(&reconciliation).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("reconciliation not found")
+		}
+		return nil, fmt.Errorf("failed to fetch reconciliation: %v", err)
+	}
+	return &reconciliation, nil
+}
+
+// firstColumn returns the first of columns present in the row's header
+func firstColumn(row csvRow, columns []string) (string, bool) {
+	for _, column := range columns {
+		if _, ok := row.columns[column]; ok {
+			return column, true
+		}
+	}
+	return "", false
+}
+
+// payoutValue returns the row's value in the first of columns it has
+func payoutValue(row csvRow, columns []string) string {
+	column, ok := firstColumn(row, columns)
+	if !ok {
+		return ""
+	}
+	return row.get(column)
+}
+
+// payoutTransactionType maps a report's transaction type to a ledger type, or
+// "" for balance movements that aren't payments. Reports without a type
+// column are taken to list charges.
+func payoutTransactionType(value string) string {
+	switch strings.ToLower(value) {
+	case "", "charge", "payment", "sale", "capture", "t0006":
+		return TransactionCharge
+	case "refund", "payment_refund", "t1107":
+		return TransactionRefund
+	}
+	return ""
+}
+
+// parsePayoutAmount parses a report amount such as "-1,234.50". Refunds and
+// fees are negative in some reports, so the sign is dropped.
+func parsePayoutAmount(value string) (float64, error) {
+	value = strings.ReplaceAll(value, ",", "")
+	if value == "" {
+		return 0, nil
+	}
+	amount, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, err
+	}
+	return math.Abs(amount), nil
+}
+
+// parsePayoutDate parses the date formats providers use in payout reports
+func parsePayoutDate(value string) (time.Time, bool) {
+	if value == "" {
+		return time.Time{}, false
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02", "01/02/2006"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.UTC().Truncate(24 * time.Hour), true
+		}
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0).UTC().Truncate(24 * time.Hour), true
+	}
+	return time.Time{}, false
+}
+
+// signedNet is what an entry adds to a payout: charges less fees, or minus a refund
+func signedNet(transactionType string, entry *payoutEntry) float64 {
+	if transactionType == TransactionRefund {
+		return -entry.amount - entry.fee
+	}
+	return entry.amount - entry.fee
+}
+
+func sortedKeys(entries map[string]*payoutEntry) []string {
+	keys := make([]string, 0, len(entries))
+	for key := range entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// RecordRefund adds a refund of the order paid by paymentIntentID to the
+// payment ledger. amount is in the smallest currency unit; zero refunds what
+// is left of the charge.
+func (s *OrderService) RecordRefund(ctx context.Context, paymentIntentID string, amount int64) error {
+	order, err := s.GetOrderByPaymentIntentID(ctx, paymentIntentID)
+	if err != nil {
+		return err
+	}
+	return s.finance.RecordRefund(ctx, order, float64(amount)/100)
+}
@@ -0,0 +1,693 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ViolationFlashSaleLimit is the violation of an order buying more of a flash
+// sale product than each shopper may
+const ViolationFlashSaleLimit = "flash_sale_limit"
+
+// flashSaleCacheTTL is how long the planned flash sale is reused before
+// reading it again, like the maintenance state
+const flashSaleCacheTTL = 5 * time.Second
+
+// waitingRoomPollInterval is how often shoppers in the waiting room are asked
+// to check their place in line at most
+const waitingRoomPollInterval = 5 * time.Second
+
+var (
+	// ErrNoFlashSale is returned when ending a flash sale that isn't planned
+	ErrNoFlashSale = errors.New("no flash sale is planned")
+	// ErrInvalidFlashSale is returned when a flash sale can't be planned as asked
+	ErrInvalidFlashSale = errors.New("invalid flash sale")
+	// ErrWaitingRoomTicketNotFound is returned for tickets the waiting room
+	// doesn't know, or that belong to a sale that is over
+	ErrWaitingRoomTicketNotFound = errors.New("waiting room ticket not found")
+	// ErrNotAdmitted is returned when a flash sale checkout comes from a
+	// shopper the waiting room hasn't let through
+	ErrNotAdmitted = errors.New("the flash sale waiting room hasn't admitted this shopper yet")
+)
+
+// FlashSaleConfig is how flash sales are prepared for and let in
+type FlashSaleConfig struct {
+	// WarmLead is how long before a sale starts its products' caches are warmed
+	WarmLead time.Duration
+	// WarmInterval is how often the warm caches are refreshed until the sale ends
+	WarmInterval time.Duration
+	// AdmissionTTL is how long a shopper let through the waiting room has to check out
+	AdmissionTTL time.Duration
+}
+
+// FlashSaleConfigFromEnv reads FLASH_SALE_WARM_LEAD_MINUTES (default 10),
+// FLASH_SALE_WARM_SECONDS (default 10) and FLASH_SALE_ADMISSION_MINUTES
+// (default 10)
+func FlashSaleConfigFromEnv() FlashSaleConfig {
+	return FlashSaleConfig{
+		WarmLead:     time.Duration(envInt("FLASH_SALE_WARM_LEAD_MINUTES", 10)) * time.Minute,
+		WarmInterval: time.Duration(envInt("FLASH_SALE_WARM_SECONDS", 10)) * time.Second,
+		AdmissionTTL: time.Duration(envInt("FLASH_SALE_ADMISSION_MINUTES", 10)) * time.Minute,
+	}
+}
+
+// FlashSaleRequest plans a flash sale in a PUT /admin/flash-sale request
+type FlashSaleRequest struct {
+	Name            string      `json:"name" binding:"required"`
+	ProductIDs      []uuid.UUID `json:"product_ids" binding:"required,min=1"`
+	StartsAt        time.Time   `json:"starts_at" binding:"required"`
+	DurationMinutes int         `json:"duration_minutes" binding:"required,min=1"`
+	PerUserLimit    int         `json:"per_user_limit" binding:"min=0"`   // units of each product a shopper may buy, 0 for no limit
+	AdmitPerMinute  int         `json:"admit_per_minute" binding:"min=0"` // 0 lets every shopper check out right away
+}
+
+// FlashSaleStatus is the flash sale storefronts are told about
+type FlashSaleStatus struct {
+	Planned           bool              `json:"planned"`
+	Active            bool              `json:"active"` // checkouts go through the waiting room
+	Sale              *models.FlashSale `json:"sale,omitempty"`
+	SecondsUntilStart int               `json:"seconds_until_start"`
+	WaitingRoom       bool              `json:"waiting_room"`
+	Waiting           int               `json:"waiting"` // shoppers in line and not let through yet
+}
+
+// WaitingRoomTicket is a shopper's place in the flash sale waiting room
+type WaitingRoomTicket struct {
+	Token                string     `json:"token"`
+	SaleID               uuid.UUID  `json:"sale_id"`
+	Position             int        `json:"position"` // place in line, 0 once admitted
+	Admitted             bool       `json:"admitted"`
+	AdmittedUntil        *time.Time `json:"admitted_until,omitempty"` // checkout must be placed by then
+	EstimatedWaitSeconds int        `json:"estimated_wait_seconds"`
+	RetryAfterSeconds    int        `json:"retry_after_seconds,omitempty"` // when to check again
+}
+
+// WaitingRoomError is returned when a flash sale checkout comes from a
+// shopper the waiting room hasn't let through, with their place in line
+// when they joined it
+type WaitingRoomError struct {
+	Ticket *WaitingRoomTicket
+}
+
+func (e *WaitingRoomError) Error() string {
+	if e.Ticket == nil {
+		return ErrNotAdmitted.Error() + ": join the waiting room first"
+	}
+	return fmt.Sprintf("%s: %d shoppers ahead", ErrNotAdmitted, e.Ticket.Position-1)
+}
+
+func (e *WaitingRoomError) Unwrap() error {
+	return ErrNotAdmitted
+}
+
+// RetryAfterSeconds is when the shopper should check their place again, for Retry-After
+func (e *WaitingRoomError) RetryAfterSeconds() int {
+	if e.Ticket == nil || e.Ticket.RetryAfterSeconds == 0 {
+		return int(waitingRoomPollInterval / time.Second)
+	}
+	return e.Ticket.RetryAfterSeconds
+}
+
+// waitingRoom is the line of shoppers for one flash sale. Shoppers are let
+// through in the order they joined, AdmitPerMinute of them a minute from the
+// start of the sale.
+type waitingRoom struct {
+	saleID   uuid.UUID
+	joined   int // tickets handed out, the number of the last one
+	tickets  map[string]*queueTicket
+	shoppers map[string]*queueTicket
+}
+
+// queueTicket is a shopper's ticket, numbered from 1 in the order they joined
+type queueTicket struct {
+	token    string
+	shopper  string
+	number   int
+	joinedAt time.Time
+}
+
+// FlashSaleService runs drop days. Ahead of a sale the products on sale are
+// loaded into memory and their stock statuses warmed, so the spike of product
+// page views reads neither from the database; while it runs checkouts of them
+// are let in through a virtual waiting room and each shopper may only buy a
+// few. The waiting room is kept in memory, per API instance, so the load
+// balancer should keep shoppers on the instance they queued on.
+type FlashSaleService struct {
+	db           *gorm.DB
+	config       FlashSaleConfig
+	availability *AvailabilityService
+
+	mu       sync.Mutex
+	current  *models.FlashSale
+	loadedAt time.Time
+	room     *waitingRoom
+	products map[uuid.UUID]models.Product
+	warmedAt time.Time
+}
+
+// NewFlashSaleService creates a new FlashSaleService
+func NewFlashSaleService(db *gorm.DB, config FlashSaleConfig) *FlashSaleService {
+	return &FlashSaleService{
+		db:     db,
+		config: config,
+	}
+}
+
+// WithAvailability warms the stock statuses storefronts poll for alongside
+// the products
+func (s *FlashSaleService) WithAvailability(availability *AvailabilityService) *FlashSaleService {
+	s.availability = availability
+	return s
+}
+
+// Status reports the planned or running flash sale at now
+func (s *FlashSaleService) Status(ctx context.Context, now time.Time) (*FlashSaleStatus, error) {
+	sale, err := s.sale(ctx, now)
+	if err != nil {
+		return nil, err
+	}
+	if sale == nil {
+		return &FlashSaleStatus{}, nil
+	}
+
+	status := &FlashSaleStatus{
+		Planned:     true,
+		Active:      flashSaleRunning(sale, now),
+		Sale:        sale,
+		WaitingRoom: sale.AdmitPerMinute > 0,
+	}
+	if now.Before(sale.StartsAt) {
+		status.SecondsUntilStart = int(sale.StartsAt.Sub(now).Round(time.Second).Seconds())
+	}
+	if status.WaitingRoom {
+		s.mu.Lock()
+		if room := s.roomFor(sale); room != nil {
+			status.Waiting = room.joined - admittedCount(sale, now)
+			if status.Waiting < 0 {
+				status.Waiting = 0
+			}
+		}
+		s.mu.Unlock()
+	}
+	return status, nil
+}
+
+// Plan schedules a flash sale, replacing any planned one, and warms its
+// products' caches when it starts soon
+func (s *FlashSaleService) Plan(ctx context.Context, req FlashSaleRequest, adminID *uuid.UUID, now time.Time) (*FlashSaleStatus, error) {
+	if req.DurationMinutes <= 0 || req.PerUserLimit < 0 || req.AdmitPerMinute < 0 {
+		return nil, fmt.Errorf("%w: duration_minutes must be positive, per_user_limit and admit_per_minute not negative", ErrInvalidFlashSale)
+	}
+	var ids []uuid.UUID
+	seen := map[uuid.UUID]bool{}
+	for _, id := range req.ProductIDs {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("%w: product_ids is required", ErrInvalidFlashSale)
+	}
+	endsAt := req.StartsAt.Add(time.Duration(req.DurationMinutes) * time.Minute)
+	if !endsAt.After(now) {
+		return nil, fmt.Errorf("%w: the sale would be over already", ErrInvalidFlashSale)
+	}
+
+	var found int64
+	if err := s.db.WithContext(ctx).Model(&models.Product{}).Where("id IN ?", ids).Count(&found).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch products: %v", err)
+	}
+	if int(found) != len(ids) {
+		return nil, fmt.Errorf("%w: %d of the products don't exist", ErrInvalidFlashSale, len(ids)-int(found))
+	}
+	encoded, err := json.Marshal(ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode product IDs: %v", err)
+	}
+
+	sale := &models.FlashSale{
+		ID:             uuid.New(),
+		Name:           req.Name,
+		ProductIDs:     datatypes.JSON(encoded),
+		StartsAt:       req.StartsAt,
+		EndsAt:         endsAt,
+		PerUserLimit:   req.PerUserLimit,
+		AdmitPerMinute: req.AdmitPerMinute,
+		CreatedBy:      adminID,
+	}
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.FlashSale{}).Where("ended_at IS NULL").Update("ended_at", now).Error; err != nil {
+			return fmt.Errorf("failed to end planned flash sale: %v", err)
+		}
+		if err := tx.Create(sale).Error; err != nil {
+			return fmt.Errorf("failed to plan flash sale: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.remember(sale, now)
+	if err := s.Warm(ctx, now); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	return s.Status(ctx, now)
+}
+
+// End ends the planned or running flash sale, emptying its waiting room
+func (s *FlashSaleService) End(ctx context.Context, now time.Time) error {
+	result := s.db.WithContext(ctx).Model(&models.FlashSale{}).Where("ended_at IS NULL").Update("ended_at", now)
+	if result.Error != nil {
+		return fmt.Errorf("failed to end flash sale: %v", result.Error)
+	}
+	s.remember(nil, now)
+	if result.RowsAffected == 0 {
+		return ErrNoFlashSale
+	}
+	return nil
+}
+
+// Warm loads the sale's products into memory and warms their stock statuses
+// when the sale starts within the warm lead or is running, and drops them
+// otherwise
+func (s *FlashSaleService) Warm(ctx context.Context, now time.Time) error {
+	sale, err := s.sale(ctx, now)
+	if err != nil {
+		return err
+	}
+	if sale == nil || now.Before(sale.StartsAt.Add(-s.config.WarmLead)) || !now.Before(sale.EndsAt) {
+		s.mu.Lock()
+		s.products = nil
+		s.mu.Unlock()
+		return nil
+	}
+
+	ids := flashSaleProductIDs(sale)
+	var products []models.Product
+	if err := s.db.WithContext(ctx).Where("id IN ?", ids).
+		Scopes(withinPublishWindow(now)).
+		Preload("Category").
+		Preload("Brand").
+		Preload("Variants").
+		Preload("Inventory").
+		Find(&products).Error; err != nil {
+		return fmt.Errorf("failed to warm flash sale products: %v", err)
+	}
+	warmed := make(map[uuid.UUID]models.Product, len(products))
+	for _, product := range products {
+		warmed[product.ID] = product
+	}
+
+	s.mu.Lock()
+	s.products = warmed
+	s.warmedAt = now
+	s.mu.Unlock()
+
+	if s.availability != nil {
+		if _, err := s.availability.GetAvailability(ctx, ids); err != nil {
+			return fmt.Errorf("failed to warm flash sale availability: %v", err)
+		}
+	}
+	return nil
+}
+
+// Product returns a flash sale product from the warm cache, a copy the
+// caller may price. Products that aren't on sale, or aren't warm, aren't found.
+func (s *FlashSaleService) Product(id uuid.UUID, now time.Time) (*models.Product, bool) {
+	if s == nil {
+		return nil, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// A cache that missed a few refreshes is left for the database to answer
+	if s.products == nil || now.Sub(s.warmedAt) > 3*s.config.WarmInterval {
+		return nil, false
+	}
+	product, ok := s.products[id]
+	if !ok {
+		return nil, false
+	}
+	return &product, true
+}
+
+// ScheduleWarming keeps the caches of the upcoming or running sale warm
+// every WarmInterval until ctx is cancelled
+func (s *FlashSaleService) ScheduleWarming(ctx context.Context) {
+	if s.config.WarmInterval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(s.config.WarmInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := s.Warm(ctx, time.Now()); err != nil {
+					log.Printf("Warning: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// Join puts the shopper in the waiting room of the planned or running sale,
+// or returns the ticket they already hold. Shoppers whose time to check out
+// ran out join again at the back of the line.
+func (s *FlashSaleService) Join(ctx context.Context, sessionID string, userID *uuid.UUID, now time.Time) (*WaitingRoomTicket, error) {
+	sale, err := s.sale(ctx, now)
+	if err != nil {
+		return nil, err
+	}
+	if sale == nil {
+		return nil, ErrNoFlashSale
+	}
+
+	shopper := chatShopper(sessionID, userID)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	room := s.roomFor(sale)
+	if room == nil {
+		room = &waitingRoom{saleID: sale.ID, tickets: map[string]*queueTicket{}, shoppers: map[string]*queueTicket{}}
+		s.room = room
+	}
+	if held, ok := room.shoppers[shopper]; ok {
+		if ticket := s.ticket(sale, held, now); ticket != nil {
+			return ticket, nil
+		}
+		delete(room.tickets, held.token)
+	}
+
+	token, err := waitingRoomToken()
+	if err != nil {
+		return nil, err
+	}
+	room.joined++
+	held := &queueTicket{token: token, shopper: shopper, number: room.joined, joinedAt: now}
+	room.tickets[token] = held
+	room.shoppers[shopper] = held
+	return s.ticket(sale, held, now), nil
+}
+
+// Ticket returns the place in line of a waiting room ticket
+func (s *FlashSaleService) Ticket(ctx context.Context, token string, now time.Time) (*WaitingRoomTicket, error) {
+	sale, err := s.sale(ctx, now)
+	if err != nil {
+		return nil, err
+	}
+	if sale == nil {
+		return nil, ErrWaitingRoomTicketNotFound
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	room := s.roomFor(sale)
+	if room == nil {
+		return nil, ErrWaitingRoomTicketNotFound
+	}
+	held, ok := room.tickets[token]
+	if !ok {
+		return nil, ErrWaitingRoomTicketNotFound
+	}
+	ticket := s.ticket(sale, held, now)
+	if ticket == nil {
+		return nil, fmt.Errorf("%w: the time to check out ran out, join again", ErrWaitingRoomTicketNotFound)
+	}
+	return ticket, nil
+}
+
+// checkoutSale returns the running flash sale when the order buys any of its
+// products, after checking the waiting room let the shopper through
+func (s *FlashSaleService) checkoutSale(ctx context.Context, req *CreateOrderRequest, now time.Time) (*models.FlashSale, error) {
+	if s == nil {
+		return nil, nil
+	}
+	sale, err := s.sale(ctx, now)
+	if err != nil {
+		return nil, err
+	}
+	if sale == nil || !flashSaleRunning(sale, now) || !orderBuysAny(req, flashSaleProductIDs(sale)) {
+		return nil, nil
+	}
+	if sale.AdmitPerMinute == 0 {
+		return sale, nil
+	}
+
+	var userID *uuid.UUID
+	if req.UserID != uuid.Nil {
+		userID = &req.UserID
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	room := s.roomFor(sale)
+	if room == nil {
+		return nil, &WaitingRoomError{}
+	}
+	held, ok := room.shoppers[chatShopper(req.SessionID, userID)]
+	if !ok {
+		return nil, &WaitingRoomError{}
+	}
+	ticket := s.ticket(sale, held, now)
+	if ticket == nil {
+		return nil, &WaitingRoomError{}
+	}
+	if !ticket.Admitted {
+		return nil, &WaitingRoomError{Ticket: ticket}
+	}
+	return sale, nil
+}
+
+// checkPurchaseLimit returns an *OrderValidationError when the order would
+// take the shopper past the sale's limit of any product, counting what they
+// ordered since the sale started
+func (s *FlashSaleService) checkPurchaseLimit(tx *gorm.DB, sale *models.FlashSale, req *CreateOrderRequest) error {
+	if sale == nil || sale.PerUserLimit == 0 {
+		return nil
+	}
+
+	onSale := map[uuid.UUID]bool{}
+	for _, id := range flashSaleProductIDs(sale) {
+		onSale[id] = true
+	}
+	requested := map[uuid.UUID]int{}
+	var ids []uuid.UUID
+	for _, item := range req.Items {
+		if !onSale[item.ProductID] {
+			continue
+		}
+		if _, seen := requested[item.ProductID]; !seen {
+			ids = append(ids, item.ProductID)
+		}
+		requested[item.ProductID] += item.Quantity
+	}
+
+	// The shopper's other orders wait here until this one commits, so they
+	// count it rather than both fitting under the limit
+	var userID *uuid.UUID
+	if req.UserID != uuid.Nil {
+		userID = &req.UserID
+	}
+	shopper := models.FlashSaleShopper{FlashSaleID: sale.ID, Shopper: chatShopper(req.SessionID, userID)}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&shopper).Error; err != nil {
+		return fmt.Errorf("failed to record flash sale shopper: %v", err)
+	}
+	var locked models.FlashSaleShopper
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("flash_sale_id = ? AND shopper = ?", shopper.FlashSaleID, shopper.Shopper).
+		First(&locked).Error; err != nil {
+		return fmt.Errorf("failed to lock flash sale shopper: %v", err)
+	}
+
+	query := tx.Table("order_items").
+		Select("order_items.product_id, SUM(order_items.quantity) AS quantity").
+		Joins("JOIN orders ON orders.id = order_items.order_id").
+		Where("order_items.product_id IN ?", ids).
+		Where("orders.created_at >= ? AND orders.status <> ?", sale.StartsAt, "cancelled")
+	if req.UserID != uuid.Nil {
+		query = query.Where("orders.user_id = ?", req.UserID)
+	} else {
+		query = query.Where("orders.session_id = ?", req.SessionID)
+	}
+	var rows []struct {
+		ProductID uuid.UUID
+		Quantity  int
+	}
+	if err := query.Group("order_items.product_id").Scan(&rows).Error; err != nil {
+		return fmt.Errorf("failed to count flash sale purchases: %v", err)
+	}
+	bought := make(map[uuid.UUID]int, len(rows))
+	for _, row := range rows {
+		bought[row.ProductID] = row.Quantity
+	}
+
+	violations := []OrderViolation{}
+	for _, id := range ids {
+		if bought[id]+requested[id] <= sale.PerUserLimit {
+			continue
+		}
+		left := sale.PerUserLimit - bought[id]
+		if left < 0 {
+			left = 0
+		}
+		productID := id
+		violations = append(violations, OrderViolation{
+			Code:      ViolationFlashSaleLimit,
+			Message:   fmt.Sprintf("%s allows %d of each product per shopper, %d more can be ordered", sale.Name, sale.PerUserLimit, left),
+			RuleID:    sale.ID,
+			ProductID: &productID,
+		})
+	}
+	if len(violations) > 0 {
+		return &OrderValidationError{Violations: violations}
+	}
+	return nil
+}
+
+// ticket describes a held ticket at now, or returns nil once the shopper's
+// time to check out ran out. The caller holds s.mu.
+func (s *FlashSaleService) ticket(sale *models.FlashSale, held *queueTicket, now time.Time) *WaitingRoomTicket {
+	ticket := &WaitingRoomTicket{Token: held.token, SaleID: sale.ID}
+	admittedAt := ticketAdmittedAt(sale, held)
+	if !now.Before(admittedAt) {
+		until := admittedAt.Add(s.config.AdmissionTTL)
+		if !now.Before(until) || !now.Before(sale.EndsAt) {
+			return nil
+		}
+		ticket.Admitted = true
+		ticket.AdmittedUntil = &until
+		return ticket
+	}
+
+	// Rounding can leave the count a shopper short of the ticket for a moment
+	ticket.Position = held.number - admittedCount(sale, now)
+	if ticket.Position < 1 {
+		ticket.Position = 1
+	}
+	wait := admittedAt.Sub(now)
+	ticket.EstimatedWaitSeconds = int((wait + time.Second - 1) / time.Second)
+	if wait > waitingRoomPollInterval {
+		wait = waitingRoomPollInterval
+	}
+	ticket.RetryAfterSeconds = int((wait + time.Second - 1) / time.Second)
+	return ticket
+}
+
+// sale returns the flash sale that hasn't been ended, or nil
+func (s *FlashSaleService) sale(ctx context.Context, now time.Time) (*models.FlashSale, error) {
+	s.mu.Lock()
+	if !s.loadedAt.IsZero() && now.Sub(s.loadedAt) < flashSaleCacheTTL {
+		current := s.current
+		s.mu.Unlock()
+		return current, nil
+	}
+	s.mu.Unlock()
+
+	var sale models.FlashSale
+	err := s.db.WithContext(ctx).Where("ended_at IS NULL AND ends_at > ?", now).Order("created_at DESC").First(&sale).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to fetch flash sale: %v", err)
+	}
+
+	var current *models.FlashSale
+	if err == nil {
+		current = &sale
+	}
+	s.remember(current, now)
+	return current, nil
+}
+
+// remember caches the current flash sale, dropping the waiting room and warm
+// products of one that was replaced or ended
+func (s *FlashSaleService) remember(sale *models.FlashSale, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sale == nil || s.current == nil || s.current.ID != sale.ID {
+		s.products = nil
+	}
+	s.current = sale
+	s.loadedAt = now
+	s.roomFor(sale)
+}
+
+// roomFor returns the waiting room of the sale, dropping one left from
+// another sale. The caller holds s.mu.
+func (s *FlashSaleService) roomFor(sale *models.FlashSale) *waitingRoom {
+	if s.room != nil && (sale == nil || s.room.saleID != sale.ID) {
+		s.room = nil
+	}
+	return s.room
+}
+
+// flashSaleRunning reports whether the sale is on at now
+func flashSaleRunning(sale *models.FlashSale, now time.Time) bool {
+	return !now.Before(sale.StartsAt) && now.Before(sale.EndsAt)
+}
+
+// flashSaleProductIDs returns the IDs of the products on sale
+func flashSaleProductIDs(sale *models.FlashSale) []uuid.UUID {
+	var ids []uuid.UUID
+	if err := json.Unmarshal(sale.ProductIDs, &ids); err != nil {
+		log.Printf("Warning: flash sale %s has invalid product IDs: %v", sale.ID, err)
+	}
+	return ids
+}
+
+// admittedCount is how many shoppers the waiting room let through by now:
+// AdmitPerMinute at the start, and as many more every minute after
+func admittedCount(sale *models.FlashSale, now time.Time) int {
+	if now.Before(sale.StartsAt) {
+		return 0
+	}
+	return int(float64(sale.AdmitPerMinute) * (1 + now.Sub(sale.StartsAt).Minutes()))
+}
+
+// ticketAdmittedAt is when the waiting room lets a ticket through, the
+// inverse of admittedCount, and never before the shopper joined
+func ticketAdmittedAt(sale *models.FlashSale, held *queueTicket) time.Time {
+	admittedAt := sale.StartsAt
+	if sale.AdmitPerMinute > 0 && held.number > sale.AdmitPerMinute {
+		minutes := float64(held.number-sale.AdmitPerMinute) / float64(sale.AdmitPerMinute)
+		admittedAt = admittedAt.Add(time.Duration(minutes * float64(time.Minute)))
+	}
+	if held.joinedAt.After(admittedAt) {
+		return held.joinedAt
+	}
+	return admittedAt
+}
+
+// orderBuysAny reports whether the order has any of the products
+func orderBuysAny(req *CreateOrderRequest, ids []uuid.UUID) bool {
+	for _, item := range req.Items {
+		for _, id := range ids {
+			if item.ProductID == id {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// waitingRoomToken returns a random, unguessable ticket token
+func waitingRoomToken() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate waiting room token: %v", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
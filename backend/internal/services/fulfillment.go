@@ -0,0 +1,247 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Fulfillment statuses
+const (
+	FulfillmentPending     = "pending"
+	FulfillmentBackordered = "backordered"
+	FulfillmentShipped     = "shipped"
+	FulfillmentDelivered   = "delivered"
+	FulfillmentCancelled   = "cancelled"
+)
+
+// FulfillmentUpdateMessage is the WebSocket message type sent when an order is
+// split or one of its fulfillments changes status
+const FulfillmentUpdateMessage = "fulfillment_update"
+
+// ErrFulfillmentNotFound is returned when an order has no such fulfillment
+var ErrFulfillmentNotFound = errors.New("fulfillment not found")
+
+// fulfillmentTransitions lists the statuses each fulfillment status may move to
+var fulfillmentTransitions = map[string][]string{
+	FulfillmentBackordered: {FulfillmentPending, FulfillmentCancelled},
+	FulfillmentPending:     {FulfillmentShipped, FulfillmentCancelled},
+	FulfillmentShipped:     {FulfillmentDelivered},
+}
+
+// Fulfillment represents a shipment of order items (alias for models.Fulfillment)
+type Fulfillment = models.Fulfillment
+
+// UpdateFulfillmentRequest moves a fulfillment to a new status
+type UpdateFulfillmentRequest struct {
+	Status         string `json:"status" binding:"required"`
+	Carrier        string `json:"carrier"`
+	TrackingNumber string `json:"tracking_number"`
+}
+
+// FulfillmentNotice tells the shopper about a change to how their order ships
+type FulfillmentNotice struct {
+	OrderID     uuid.UUID    `json:"order_id"`
+	OrderNumber string       `json:"order_number"`
+	OrderStatus string       `json:"order_status"`
+	Message     string       `json:"message"`
+	Fulfillment *Fulfillment `json:"fulfillment"`
+}
+
+// NewFulfillmentNotice describes a fulfillment change for the order's shopper
+func NewFulfillmentNotice(order *Order, fulfillment *Fulfillment) *FulfillmentNotice {
+	var message string
+	switch fulfillment.Status {
+	case FulfillmentBackordered:
+		message = fmt.Sprintf("Some items in order %s are backordered and will ship separately.", order.OrderNumber)
+	case FulfillmentPending:
+		message = fmt.Sprintf("Backordered items in order %s are back in stock and being prepared.", order.OrderNumber)
+	case FulfillmentShipped:
+		message = fmt.Sprintf("Shipment %d of order %s is on its way.", fulfillment.Sequence, order.OrderNumber)
+		if fulfillment.TrackingNumber != "" {
+			message += fmt.Sprintf(" Tracking number: %s.", fulfillment.TrackingNumber)
+		}
+	case FulfillmentDelivered:
+		message = fmt.Sprintf("Shipment %d of order %s has been delivered.", fulfillment.Sequence, order.OrderNumber)
+	case FulfillmentCancelled:
+		message = fmt.Sprintf("Shipment %d of order %s has been cancelled.", fulfillment.Sequence, order.OrderNumber)
+	}
+
+	return &FulfillmentNotice{
+		OrderID:     order.ID,
+		OrderNumber: order.OrderNumber,
+		OrderStatus: order.Status,
+		Message:     message,
+		Fulfillment: fulfillment,
+	}
+}
+
+// BackorderedFulfillment returns the order's backordered fulfillment, if any
+func BackorderedFulfillment(order *Order) *Fulfillment {
+	for i := range order.Fulfillments {
+		if order.Fulfillments[i].Status == FulfillmentBackordered {
+			return &order.Fulfillments[i]
+		}
+	}
+	return nil
+}
+
+// UpdateFulfillment moves one of an order's fulfillments to a new status.
+// Releasing a backorder reserves its stock; cancelling a pending shipment
+// returns it. The order status follows its fulfillments.
+func (s *OrderService) UpdateFulfillment(ctx context.Context, orderID, fulfillmentID uuid.UUID, req UpdateFulfillmentRequest) (*Order, *Fulfillment, error) {
+	now := time.Now()
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var fulfillment Fulfillment
+		if err := tx.Preload("Items").Where("id = ? AND order_id = ?", fulfillmentID, orderID).First(&fulfillment).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrFulfillmentNotFound
+			}
+			return fmt.Errorf("failed to fetch fulfillment: %v", err)
+		}
+
+		if !canTransitionFulfillment(fulfillment.Status, req.Status) {
+			return fmt.Errorf("cannot move fulfillment from %s to %s", fulfillment.Status, req.Status)
+		}
+
+		switch req.Status {
+		case FulfillmentPending:
+			for _, item := range fulfillment.Items {
+				if err := s.checkInventory(tx, item.ProductID, item.VariantID, item.Quantity); err != nil {
+					return err
+				}
+			}
+			if err := s.reserveInventory(tx, fulfillment.Items); err != nil {
+				return err
+			}
+		case FulfillmentShipped:
+			fulfillment.ShippedAt = &now
+		case FulfillmentDelivered:
+			fulfillment.DeliveredAt = &now
+		case FulfillmentCancelled:
+			if fulfillment.Status == FulfillmentPending {
+				if err := s.releaseInventory(tx, fulfillment.Items); err != nil {
+					return err
+				}
+			}
+		}
+
+		fulfillment.Status = req.Status
+		if req.Carrier != "" {
+			fulfillment.Carrier = req.Carrier
+		}
+		if req.TrackingNumber != "" {
+			fulfillment.TrackingNumber = req.TrackingNumber
+		}
+		fulfillment.UpdatedAt = now
+		if err := tx.Omit("Items").Save(&fulfillment).Error; err != nil {
+			return fmt.Errorf("failed to update fulfillment: %v", err)
+		}
+
+		return s.rollUpOrderStatus(tx, orderID, now)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	order, err := s.GetOrderByID(ctx, orderID)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := range order.Fulfillments {
+		if order.Fulfillments[i].ID == fulfillmentID {
+			return order, &order.Fulfillments[i], nil
+		}
+	}
+	return nil, nil, ErrFulfillmentNotFound
+}
+
+// splitFulfillments creates the order's fulfillments: one for the items that
+// can ship now and, if needed, a backordered one for the rest
+func (s *OrderService) splitFulfillments(tx *gorm.DB, orderID uuid.UUID, inStock, backordered []OrderItem, now time.Time) error {
+	groups := []struct {
+		status string
+		items  []OrderItem
+	}{
+		{FulfillmentPending, inStock},
+		{FulfillmentBackordered, backordered},
+	}
+
+	sequence := 0
+	for _, group := range groups {
+		if len(group.items) == 0 {
+			continue
+		}
+		sequence++
+		fulfillment := Fulfillment{
+			ID:        uuid.New(),
+			OrderID:   orderID,
+			Sequence:  sequence,
+			Status:    group.status,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		if err := tx.Create(&fulfillment).Error; err != nil {
+			return errors.New("failed to create fulfillment")
+		}
+		for i := range group.items {
+			group.items[i].FulfillmentID = &fulfillment.ID
+		}
+	}
+	return nil
+}
+
+// rollUpOrderStatus sets the order status from its fulfillments
+func (s *OrderService) rollUpOrderStatus(tx *gorm.DB, orderID uuid.UUID, now time.Time) error {
+	var fulfillments []Fulfillment
+	if err := tx.Where("order_id = ?", orderID).Find(&fulfillments).Error; err != nil {
+		return fmt.Errorf("failed to fetch fulfillments: %v", err)
+	}
+
+	active, shipped, delivered := 0, 0, 0
+	for _, fulfillment := range fulfillments {
+		switch fulfillment.Status {
+		case FulfillmentCancelled:
+			continue
+		case FulfillmentShipped:
+			shipped++
+		case FulfillmentDelivered:
+			delivered++
+		}
+		active++
+	}
+
+	var status string
+	switch {
+	case active == 0:
+		status = "cancelled"
+	case delivered == active:
+		status = "delivered"
+	case shipped+delivered == active:
+		status = "shipped"
+	case shipped+delivered > 0:
+		status = "partially_shipped"
+	default:
+		return nil
+	}
+
+	if err := tx.Model(&Order{}).Where("id = ?", orderID).Updates(map[string]interface{}{"status": status, "updated_at": now}).Error; err != nil {
+		return fmt.Errorf("failed to update order status: %v", err)
+	}
+	return nil
+}
+
+func canTransitionFulfillment(from, to string) bool {
+	for _, next := range fulfillmentTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
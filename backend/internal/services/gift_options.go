@@ -0,0 +1,99 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ErrGiftMessageTooLong is returned for gift messages over the configured length
+var ErrGiftMessageTooLong = errors.New("gift message is too long")
+
+// GiftOptionsConfig prices gift wrapping and limits gift messages
+type GiftOptionsConfig struct {
+	WrapFee          float64
+	MaxMessageLength int
+}
+
+// GiftOptionsConfigFromEnv charges GIFT_WRAP_FEE_CENTS (499) for gift wrap and
+// allows gift messages of up to GIFT_MESSAGE_MAX_LENGTH (250) characters
+func GiftOptionsConfigFromEnv() GiftOptionsConfig {
+	return GiftOptionsConfig{
+		WrapFee:          float64(envInt("GIFT_WRAP_FEE_CENTS", 499)) / 100,
+		MaxMessageLength: envInt("GIFT_MESSAGE_MAX_LENGTH", 250),
+	}
+}
+
+// GiftOptions are the gift wrap and message chosen for a cart or order
+type GiftOptions struct {
+	GiftWrap    bool   `json:"gift_wrap"`
+	GiftMessage string `json:"gift_message"`
+}
+
+// normalize trims the message and checks it against the configured length
+func (c GiftOptionsConfig) normalize(options GiftOptions) (GiftOptions, error) {
+	options.GiftMessage = strings.TrimSpace(options.GiftMessage)
+	if c.MaxMessageLength > 0 && utf8.RuneCountInString(options.GiftMessage) > c.MaxMessageLength {
+		return options, fmt.Errorf("%w: at most %d characters", ErrGiftMessageTooLong, c.MaxMessageLength)
+	}
+	return options, nil
+}
+
+// wrapAmount is the fee charged for the options
+func (c GiftOptionsConfig) wrapAmount(options GiftOptions) float64 {
+	if !options.GiftWrap {
+		return 0
+	}
+	return c.WrapFee
+}
+
+// SetGiftOptions stores the gift wrap and message on the cart. Checkout
+// carries them over to the order unless the checkout request sets its own.
+func (s *ShoppingCartService) SetGiftOptions(sessionID string, userID *uuid.UUID, options GiftOptions) (*CartResponse, error) {
+	options, err := s.gifts.normalize(options)
+	if err != nil {
+		return nil, err
+	}
+
+	cart, err := s.getOrCreateCart(sessionID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Model(cart).Updates(map[string]interface{}{
+		"gift_wrap":    options.GiftWrap,
+		"gift_message": options.GiftMessage,
+		"updated_at":   time.Now(),
+	}).Error; err != nil {
+		return nil, fmt.Errorf("failed to update gift options: %w", err)
+	}
+
+	return s.GetCart(sessionID, userID)
+}
+
+// orderGiftOptions returns the gift options an order is placed with: the
+// request's when it sets them, otherwise whatever the shopper chose on the cart
+func (s *OrderService) orderGiftOptions(tx *gorm.DB, req *CreateOrderRequest) (GiftOptions, error) {
+	if req.Gift != nil {
+		return s.gifts.normalize(*req.Gift)
+	}
+
+	var cart models.ShoppingCart
+	query := tx.Select("gift_wrap", "gift_message").Where("session_id = ?", req.SessionID)
+	if req.UserID != uuid.Nil {
+		query = query.Or("user_id = ?", req.UserID)
+	}
+	if err := query.First(&cart).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return GiftOptions{}, nil
+		}
+		return GiftOptions{}, fmt.Errorf("failed to fetch cart gift options: %v", err)
+	}
+	return GiftOptions{GiftWrap: cart.GiftWrap, GiftMessage: cart.GiftMessage}, nil
+}
@@ -0,0 +1,74 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/internal/services/search"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Strategies for rows that look like duplicates of an existing product
+const (
+	DuplicateStrategyFlag  = "flag"  // import the row and report the probable duplicate
+	DuplicateStrategySkip  = "skip"  // report the probable duplicate and do not import the row
+	DuplicateStrategyMerge = "merge" // update the existing product with the row, keeping its SKU
+)
+
+const (
+	// duplicateNameThreshold is the minimum name similarity for a probable duplicate
+	duplicateNameThreshold = 0.8
+	// duplicatePriceTolerance is the relative price difference allowed for a probable duplicate
+	duplicatePriceTolerance = 0.15
+)
+
+// BulkImportDuplicate describes an import row that probably duplicates an existing product
+type BulkImportDuplicate struct {
+	Index            int     `json:"index"`
+	SKU              string  `json:"sku"`
+	Name             string  `json:"name"`
+	MatchedProductID string  `json:"matched_product_id"`
+	MatchedSKU       string  `json:"matched_sku"`
+	MatchedName      string  `json:"matched_name"`
+	Similarity       float64 `json:"similarity"`
+	Action           string  `json:"action"` // "flagged", "skipped" or "merged"
+}
+
+// findProbableDuplicate returns the existing product in the same category,
+// at a similar price and with the most similar name, if any passes the threshold
+func (s *AdminProductService) findProbableDuplicate(req AdminProductRequest) (*models.Product, float64, error) {
+	var candidates []models.Product
+	err := s.db.Where("category_id = ? AND sku <> ? AND price BETWEEN ? AND ?",
+		req.CategoryID, req.SKU,
+		req.Price*(1-duplicatePriceTolerance), req.Price*(1+duplicatePriceTolerance)).
+		Find(&candidates).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to fetch duplicate candidates: %v", err)
+	}
+
+	var best *models.Product
+	bestScore := 0.0
+	for i := range candidates {
+		score := nameSimilarity(req.Name, candidates[i].Name)
+		if score >= duplicateNameThreshold && score > bestScore {
+			best = &candidates[i]
+			bestScore = score
+		}
+	}
+	return best, bestScore, nil
+}
+
+// nameSimilarity compares product names ignoring case, punctuation and word order
+func nameSimilarity(a, b string) float64 {
+	fuzzy := search.NewFuzzyService(nil)
+	return fuzzy.CalculateSimilarity(normalizeProductName(a), normalizeProductName(b))
+}
+
+func normalizeProductName(name string) string {
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	sort.Strings(words)
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,283 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Forecasting methods
+const (
+	ForecastMovingAverage        = "moving_average"
+	ForecastExponentialSmoothing = "exponential_smoothing"
+)
+
+const (
+	// forecastHistoryDays is how far back sales are read: a full year, so the
+	// coming weeks can be compared with the same weeks last year
+	forecastHistoryDays = 365
+	// forecastSmoothingWeeks of weekly sales feed exponential smoothing;
+	// products with less history use a moving average of what they have
+	forecastSmoothingWeeks = 13
+	forecastSmoothingAlpha = 0.3
+	// seasonal factors are clamped so one unusual week last year can't
+	// double or wipe out a forecast
+	minSeasonalFactor = 0.5
+	maxSeasonalFactor = 2.0
+)
+
+// forecastHorizons are the day counts demand is forecast over
+var forecastHorizons = []int{30, 60, 90}
+
+// ForecastConfig controls reorder suggestions and when the nightly job runs
+type ForecastConfig struct {
+	LeadTimeDays    int // days between placing a purchase order and receiving stock
+	SafetyStockDays int // extra days of demand to keep on hand
+	Hour            int // hour of day the job runs
+}
+
+// ForecastConfigFromEnv reads FORECAST_LEAD_TIME_DAYS (default 14),
+// FORECAST_SAFETY_STOCK_DAYS (default 7) and FORECAST_HOUR (default 3)
+func ForecastConfigFromEnv() ForecastConfig {
+	config := ForecastConfig{
+		LeadTimeDays:    envInt("FORECAST_LEAD_TIME_DAYS", 14),
+		SafetyStockDays: envInt("FORECAST_SAFETY_STOCK_DAYS", 7),
+		Hour:            envInt("FORECAST_HOUR", 3),
+	}
+	if config.LeadTimeDays < 0 {
+		config.LeadTimeDays = 14
+	}
+	if config.SafetyStockDays < 0 {
+		config.SafetyStockDays = 7
+	}
+	if config.Hour < 0 || config.Hour > 23 {
+		config.Hour = 3
+	}
+	return config
+}
+
+// ForecastRunResult reports a forecasting run
+type ForecastRunResult struct {
+	Products        int       `json:"products"`
+	ReorderProducts int       `json:"reorder_products"`
+	ComputedAt      time.Time `json:"computed_at"`
+}
+
+// InventoryForecastService forecasts product demand from past orders and
+// suggests how much stock to reorder
+type InventoryForecastService struct {
+	db     *gorm.DB
+	config ForecastConfig
+}
+
+// NewInventoryForecastService creates a new InventoryForecastService
+func NewInventoryForecastService(db *gorm.DB, config ForecastConfig) *InventoryForecastService {
+	return &InventoryForecastService{
+		db:     db,
+		config: config,
+	}
+}
+
+// ScheduleForecasts recomputes forecasts every day at the configured hour
+// until ctx is cancelled
+func (s *InventoryForecastService) ScheduleForecasts(ctx context.Context) {
+	go func() {
+		for {
+			timer := time.NewTimer(time.Until(nextDailyRun(time.Now(), s.config.Hour)))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case now := <-timer.C:
+				result, err := s.RunForecasts(ctx, now)
+				if err != nil {
+					log.Printf("Failed to forecast inventory demand: %v", err)
+					continue
+				}
+				log.Printf("Forecast demand for %d products, %d need reordering", result.Products, result.ReorderProducts)
+			}
+		}
+	}()
+}
+
+// RunForecasts recomputes the 30, 60 and 90 day demand forecast and reorder
+// quantity of every stocked product from the orders of the past year
+func (s *InventoryForecastService) RunForecasts(ctx context.Context, now time.Time) (*ForecastRunResult, error) {
+	db := s.db.WithContext(ctx)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	since := today.AddDate(0, 0, -forecastHistoryDays)
+
+	var stock []struct {
+		ProductID uuid.UUID
+		Available int
+	}
+	if err := db.Model(&models.Inventory{}).
+		Select("product_id, COALESCE(SUM(quantity_available), 0) AS available").
+		Group("product_id").
+		Scan(&stock).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch inventory: %v", err)
+	}
+
+	// Cancelled orders and unpaid offline orders never shipped, so they
+	// aren't demand
+	var sales []struct {
+		ProductID uuid.UUID
+		Quantity  int
+		CreatedAt time.Time
+	}
+	if err := db.Table("order_items").
+		Select("order_items.product_id, order_items.quantity, orders.created_at").
+		Joins("JOIN orders ON orders.id = order_items.order_id").
+		Where("orders.created_at >= ? AND orders.created_at < ?", since, today).
+		Where("orders.status NOT IN ?", []string{"cancelled", OrderStatusAwaitingPayment}).
+		Scan(&sales).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch sales: %v", err)
+	}
+
+	// Daily units sold per product, oldest day first
+	daily := make(map[uuid.UUID][]float64)
+	firstSale := make(map[uuid.UUID]int)
+	for _, sale := range sales {
+		day := int(sale.CreatedAt.Sub(since).Hours() / 24)
+		if day < 0 || day >= forecastHistoryDays {
+			continue
+		}
+		series, ok := daily[sale.ProductID]
+		if !ok {
+			series = make([]float64, forecastHistoryDays)
+			daily[sale.ProductID] = series
+			firstSale[sale.ProductID] = day
+		}
+		series[day] += float64(sale.Quantity)
+		if day < firstSale[sale.ProductID] {
+			firstSale[sale.ProductID] = day
+		}
+	}
+
+	result := &ForecastRunResult{ComputedAt: now}
+	forecasts := make([]models.DemandForecast, 0, len(stock))
+	for _, item := range stock {
+		forecast := s.forecast(daily[item.ProductID], firstSale[item.ProductID])
+		forecast.ID = uuid.New()
+		forecast.ProductID = item.ProductID
+		forecast.QuantityAvailable = item.Available
+		forecast.ReorderQuantity = s.reorderQuantity(forecast, item.Available)
+		forecast.ComputedAt = now
+		if forecast.ReorderQuantity > 0 {
+			result.ReorderProducts++
+		}
+		forecasts = append(forecasts, forecast)
+	}
+	result.Products = len(forecasts)
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("1 = 1").Delete(&models.DemandForecast{}).Error; err != nil {
+			return fmt.Errorf("failed to clear forecasts: %v", err)
+		}
+		if len(forecasts) > 0 {
+			if err := tx.CreateInBatches(&forecasts, 500).Error; err != nil {
+				return fmt.Errorf("failed to save forecasts: %v", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ListForecasts returns the latest forecasts, products to reorder first. With
+// reorderOnly, products with enough stock are left out.
+func (s *InventoryForecastService) ListForecasts(ctx context.Context, reorderOnly bool) ([]models.DemandForecast, error) {
+	query := s.db.WithContext(ctx).Preload("Product").Order("reorder_quantity DESC, forecast30 DESC")
+	if reorderOnly {
+		query = query.Where("reorder_quantity > ?", 0)
+	}
+	var forecasts []models.DemandForecast
+	if err := query.Find(&forecasts).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch forecasts: %v", err)
+	}
+	return forecasts, nil
+}
+
+// forecast projects demand from a product's daily sales. series is nil for
+// products that haven't sold in the past year.
+func (s *InventoryForecastService) forecast(series []float64, firstSale int) models.DemandForecast {
+	forecast := models.DemandForecast{Method: ForecastMovingAverage, SeasonalFactor: 1}
+	if series == nil {
+		return forecast
+	}
+
+	// Weekly totals of the most recent weeks, oldest first
+	weeks := make([]float64, 0, forecastSmoothingWeeks)
+	for end := len(series); end-7 >= 0 && len(weeks) < forecastSmoothingWeeks; end -= 7 {
+		weeks = append([]float64{sum(series[end-7 : end])}, weeks...)
+	}
+	historyDays := len(series) - firstSale
+	if historyDays >= len(weeks)*7 {
+		forecast.Method = ForecastExponentialSmoothing
+		level := weeks[0]
+		for _, week := range weeks[1:] {
+			level = forecastSmoothingAlpha*week + (1-forecastSmoothingAlpha)*level
+		}
+		forecast.DailyDemand = level / 7
+	} else {
+		forecast.DailyDemand = sum(series[firstSale:]) / float64(historyDays)
+	}
+
+	factors := s.seasonalFactors(series, firstSale)
+	forecast.SeasonalFactor = roundTo(factors[0], 3)
+	forecast.Forecast30 = int(math.Round(forecast.DailyDemand * 30 * factors[0]))
+	forecast.Forecast60 = int(math.Round(forecast.DailyDemand * 60 * factors[1]))
+	forecast.Forecast90 = int(math.Round(forecast.DailyDemand * 90 * factors[2]))
+	forecast.DailyDemand = roundTo(forecast.DailyDemand, 4)
+	return forecast
+}
+
+// seasonalFactors compares sales in the days following this date last year
+// with the yearly average, for each horizon. Products that weren't selling a
+// year ago have no seasonality.
+func (s *InventoryForecastService) seasonalFactors(series []float64, firstSale int) []float64 {
+	factors := make([]float64, len(forecastHorizons))
+	yearly := sum(series) / float64(len(series))
+	for i, days := range forecastHorizons {
+		factors[i] = 1
+		if firstSale >= forecastHorizons[0] || yearly == 0 {
+			continue
+		}
+		factor := sum(series[:days]) / float64(days) / yearly
+		factors[i] = math.Max(minSeasonalFactor, math.Min(maxSeasonalFactor, factor))
+	}
+	return factors
+}
+
+// reorderQuantity is the stock needed to cover demand until a new delivery
+// arrives plus the safety stock, less what is on hand
+func (s *InventoryForecastService) reorderQuantity(forecast models.DemandForecast, available int) int {
+	coverDays := float64(s.config.LeadTimeDays + s.config.SafetyStockDays)
+	needed := int(math.Ceil(forecast.DailyDemand * forecast.SeasonalFactor * coverDays))
+	if needed <= available {
+		return 0
+	}
+	return needed - available
+}
+
+func sum(values []float64) float64 {
+	total := 0.0
+	for _, value := range values {
+		total += value
+	}
+	return total
+}
+
+func roundTo(value float64, places int) float64 {
+	scale := math.Pow(10, float64(places))
+	return math.Round(value*scale) / scale
+}
@@ -2,6 +2,8 @@ package services
 
 import (
 	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/pkg/listquery"
+	"context"
 	"fmt"
 	"log"
 	"time"
@@ -22,6 +24,12 @@ func NewInventoryService(db *gorm.DB) *InventoryService {
 	}
 }
 
+// withTx runs fn in a single transaction bound to ctx. The transaction is
+// rolled back if fn returns an error or panics, and committed otherwise.
+func (s *InventoryService) withTx(ctx context.Context, fn func(tx *gorm.DB) error) error {
+	return s.db.WithContext(ctx).Transaction(fn)
+}
+
 // InventoryUpdateRequest represents a request to update inventory
 type InventoryUpdateRequest struct {
 	ProductID uuid.UUID  `json:"product_id" binding:"required"`
@@ -29,6 +37,10 @@ type InventoryUpdateRequest struct {
 	Quantity  int        `json:"quantity" binding:"required"`
 	Location  string     `json:"location"`
 	Operation string     `json:"operation" binding:"required"` // "add", "subtract", "set"
+
+	// ExpectedUpdatedAt is the updated_at of the stock level the admin's
+	// edit started from; a "set" over a newer one is reported as a conflict
+	ExpectedUpdatedAt *time.Time `json:"expected_updated_at,omitempty"`
 }
 
 // InventoryReservationRequest represents a request to reserve inventory
@@ -64,13 +76,41 @@ type InventoryReport struct {
 	OverstockItems    int64 `json:"overstock_items"`
 	ReservedQuantity  int   `json:"reserved_quantity"`
 	AvailableQuantity int   `json:"available_quantity"`
+
+	// Demand forecasts from the last forecasting run
+	ForecastedAt       *time.Time              `json:"forecasted_at"`
+	Forecast30         int                     `json:"forecast_30"`
+	Forecast60         int                     `json:"forecast_60"`
+	Forecast90         int                     `json:"forecast_90"`
+	ReorderSuggestions []models.DemandForecast `json:"reorder_suggestions"`
+}
+
+// InventoryUpdatedAt returns when the stock level of a product or variant
+// last changed, or nil when it has none
+func (s *InventoryService) InventoryUpdatedAt(ctx context.Context, productID uuid.UUID, variantID *uuid.UUID) (*time.Time, error) {
+	var inventory models.Inventory
+	query := s.db.WithContext(ctx).Select("updated_at").Where("product_id = ?", productID)
+	if variantID != nil {
+		query = query.Where("variant_id = ?", *variantID)
+	} else {
+		query = query.Where("variant_id IS NULL")
+	}
+	if err := query.First(&inventory).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find inventory: %v", err)
+	}
+	return &inventory.UpdatedAt, nil
 }
 
 // UpdateInventory updates inventory levels
-func (s *InventoryService) UpdateInventory(req InventoryUpdateRequest) error {
+func (s *InventoryService) UpdateInventory(ctx context.Context, req InventoryUpdateRequest) error {
+	db := s.db.WithContext(ctx)
+
 	// Find existing inventory record
 	var inventory models.Inventory
-	query := s.db.Where("product_id = ?", req.ProductID)
+	query := db.Where("product_id = ?", req.ProductID)
 	if req.VariantID != nil {
 		query = query.Where("variant_id = ?", *req.VariantID)
 	} else {
@@ -114,195 +154,160 @@ func (s *InventoryService) UpdateInventory(req InventoryUpdateRequest) error {
 	}
 
 	// Save inventory
-	if err := s.db.Save(&inventory).Error; err != nil {
+	if err := db.Save(&inventory).Error; err != nil {
 		return fmt.Errorf("failed to save inventory: %v", err)
 	}
 
-	// Check for alerts
-	go s.checkInventoryAlerts(inventory)
+	// Check for alerts (detached from request cancellation)
+	go s.checkInventoryAlerts(context.WithoutCancel(ctx), inventory)
 
 	return nil
 }
 
 // ReserveInventory reserves inventory for a session
-func (s *InventoryService) ReserveInventory(req InventoryReservationRequest) error {
-	// Start transaction
-	tx := s.db.Begin()
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
+func (s *InventoryService) ReserveInventory(ctx context.Context, req InventoryReservationRequest) error {
+	return s.withTx(ctx, func(tx *gorm.DB) error {
+		// Find inventory record
+		var inventory models.Inventory
+		query := tx.Where("product_id = ?", req.ProductID)
+		if req.VariantID != nil {
+			query = query.Where("variant_id = ?", *req.VariantID)
+		} else {
+			query = query.Where("variant_id IS NULL")
 		}
-	}()
 
-	// Find inventory record
-	var inventory models.Inventory
-	query := tx.Where("product_id = ?", req.ProductID)
-	if req.VariantID != nil {
-		query = query.Where("variant_id = ?", *req.VariantID)
-	} else {
-		query = query.Where("variant_id IS NULL")
-	}
-
-	err := query.First(&inventory).Error
-	if err != nil {
-		tx.Rollback()
-		return fmt.Errorf("inventory not found: %v", err)
-	}
-
-	// Check if enough quantity is available
-	availableQuantity := inventory.QuantityAvailable - inventory.QuantityReserved
-	if availableQuantity < req.Quantity {
-		tx.Rollback()
-		return fmt.Errorf("insufficient inventory: available %d, requested %d", availableQuantity, req.Quantity)
-	}
+		if err := query.First(&inventory).Error; err != nil {
+			return fmt.Errorf("inventory not found: %v", err)
+		}
 
-	// Create reservation
-	reservation := models.InventoryReservation{
-		InventoryID:      inventory.ID,
-		QuantityReserved: req.Quantity,
-		SessionID:        req.SessionID,
-		ExpiresAt:        req.ExpiresAt,
-		Status:           "active",
-	}
+		// Check if enough quantity is available
+		availableQuantity := inventory.QuantityAvailable - inventory.QuantityReserved
+		if availableQuantity < req.Quantity {
+			return fmt.Errorf("insufficient inventory: available %d, requested %d", availableQuantity, req.Quantity)
+		}
 
-	if err := tx.Create(&reservation).Error; err != nil {
-		tx.Rollback()
-		return fmt.Errorf("failed to create reservation: %v", err)
-	}
+		// Create reservation
+		reservation := models.InventoryReservation{
+			InventoryID:      inventory.ID,
+			QuantityReserved: req.Quantity,
+			SessionID:        req.SessionID,
+			ExpiresAt:        req.ExpiresAt,
+			Status:           "active",
+		}
 
-	// Update reserved quantity
-	inventory.QuantityReserved += req.Quantity
-	if err := tx.Save(&inventory).Error; err != nil {
-		tx.Rollback()
-		return fmt.Errorf("failed to update reserved quantity: %v", err)
-	}
+		if err := tx.Create(&reservation).Error; err != nil {
+			return fmt.Errorf("failed to create reservation: %v", err)
+		}
 
-	// Commit transaction
-	if err := tx.Commit().Error; err != nil {
-		return fmt.Errorf("failed to commit transaction: %v", err)
-	}
+		// Update reserved quantity
+		inventory.QuantityReserved += req.Quantity
+		if err := tx.Save(&inventory).Error; err != nil {
+			return fmt.Errorf("failed to update reserved quantity: %v", err)
+		}
 
-	return nil
+		return nil
+	})
 }
 
 // ReleaseInventory releases reserved inventory
-func (s *InventoryService) ReleaseInventory(sessionID string) error {
-	// Start transaction
-	tx := s.db.Begin()
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
-		}
-	}()
-
-	// Find active reservations for session
-	var reservations []models.InventoryReservation
-	if err := tx.Where("session_id = ? AND status = ?", sessionID, "active").Find(&reservations).Error; err != nil {
-		tx.Rollback()
-		return fmt.Errorf("failed to find reservations: %v", err)
-	}
-
-	// Release each reservation
-	for _, reservation := range reservations {
-		// Update reservation status
-		reservation.Status = "released"
-		if err := tx.Save(&reservation).Error; err != nil {
-			tx.Rollback()
-			return fmt.Errorf("failed to update reservation: %v", err)
+func (s *InventoryService) ReleaseInventory(ctx context.Context, sessionID string) error {
+	return s.withTx(ctx, func(tx *gorm.DB) error {
+		// Find active reservations for session
+		var reservations []models.InventoryReservation
+		if err := tx.Where("session_id = ? AND status = ?", sessionID, "active").Find(&reservations).Error; err != nil {
+			return fmt.Errorf("failed to find reservations: %v", err)
 		}
 
-		// Update inventory reserved quantity
-		var inventory models.Inventory
-		if err := tx.Where("id = ?", reservation.InventoryID).First(&inventory).Error; err != nil {
-			tx.Rollback()
-			return fmt.Errorf("failed to find inventory: %v", err)
-		}
+		// Release each reservation
+		for _, reservation := range reservations {
+			// Update reservation status
+			reservation.Status = "released"
+			if err := tx.Save(&reservation).Error; err != nil {
+				return fmt.Errorf("failed to update reservation: %v", err)
+			}
 
-		inventory.QuantityReserved -= reservation.QuantityReserved
-		if inventory.QuantityReserved < 0 {
-			inventory.QuantityReserved = 0
-		}
+			// Update inventory reserved quantity
+			var inventory models.Inventory
+			if err := tx.Where("id = ?", reservation.InventoryID).First(&inventory).Error; err != nil {
+				return fmt.Errorf("failed to find inventory: %v", err)
+			}
 
-		if err := tx.Save(&inventory).Error; err != nil {
-			tx.Rollback()
-			return fmt.Errorf("failed to update inventory: %v", err)
-		}
-	}
+			inventory.QuantityReserved -= reservation.QuantityReserved
+			if inventory.QuantityReserved < 0 {
+				inventory.QuantityReserved = 0
+			}
 
-	// Commit transaction
-	if err := tx.Commit().Error; err != nil {
-		return fmt.Errorf("failed to commit transaction: %v", err)
-	}
+			if err := tx.Save(&inventory).Error; err != nil {
+				return fmt.Errorf("failed to update inventory: %v", err)
+			}
+		}
 
-	return nil
+		return nil
+	})
 }
 
 // ConfirmInventory confirms reserved inventory (converts reservation to actual deduction)
-func (s *InventoryService) ConfirmInventory(sessionID string) error {
-	// Start transaction
-	tx := s.db.Begin()
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
-		}
-	}()
-
-	// Find active reservations for session
-	var reservations []models.InventoryReservation
-	if err := tx.Where("session_id = ? AND status = ?", sessionID, "active").Find(&reservations).Error; err != nil {
-		tx.Rollback()
-		return fmt.Errorf("failed to find reservations: %v", err)
-	}
-
-	// Confirm each reservation
-	for _, reservation := range reservations {
-		// Update reservation status
-		reservation.Status = "confirmed"
-		if err := tx.Save(&reservation).Error; err != nil {
-			tx.Rollback()
-			return fmt.Errorf("failed to update reservation: %v", err)
+func (s *InventoryService) ConfirmInventory(ctx context.Context, sessionID string) error {
+	var confirmed []models.Inventory
+
+	err := s.withTx(ctx, func(tx *gorm.DB) error {
+		// Find active reservations for session
+		var reservations []models.InventoryReservation
+		if err := tx.Where("session_id = ? AND status = ?", sessionID, "active").Find(&reservations).Error; err != nil {
+			return fmt.Errorf("failed to find reservations: %v", err)
 		}
 
-		// Update inventory quantities
-		var inventory models.Inventory
-		if err := tx.Where("id = ?", reservation.InventoryID).First(&inventory).Error; err != nil {
-			tx.Rollback()
-			return fmt.Errorf("failed to find inventory: %v", err)
-		}
+		// Confirm each reservation
+		for _, reservation := range reservations {
+			// Update reservation status
+			reservation.Status = "confirmed"
+			if err := tx.Save(&reservation).Error; err != nil {
+				return fmt.Errorf("failed to update reservation: %v", err)
+			}
 
-		// Deduct from quantity and reserved
-		inventory.QuantityAvailable -= reservation.QuantityReserved
-		inventory.QuantityReserved -= reservation.QuantityReserved
+			// Update inventory quantities
+			var inventory models.Inventory
+			if err := tx.Where("id = ?", reservation.InventoryID).First(&inventory).Error; err != nil {
+				return fmt.Errorf("failed to find inventory: %v", err)
+			}
 
-		if inventory.QuantityAvailable < 0 {
-			inventory.QuantityAvailable = 0
-		}
-		if inventory.QuantityReserved < 0 {
-			inventory.QuantityReserved = 0
-		}
+			// Deduct from quantity and reserved
+			inventory.QuantityAvailable -= reservation.QuantityReserved
+			inventory.QuantityReserved -= reservation.QuantityReserved
 
-		if err := tx.Save(&inventory).Error; err != nil {
-			tx.Rollback()
-			return fmt.Errorf("failed to update inventory: %v", err)
+			if inventory.QuantityAvailable < 0 {
+				inventory.QuantityAvailable = 0
+			}
+			if inventory.QuantityReserved < 0 {
+				inventory.QuantityReserved = 0
+			}
+
+			if err := tx.Save(&inventory).Error; err != nil {
+				return fmt.Errorf("failed to update inventory: %v", err)
+			}
+
+			confirmed = append(confirmed, inventory)
 		}
 
-		// Check for alerts
-		go s.checkInventoryAlerts(inventory)
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
-	// Commit transaction
-	if err := tx.Commit().Error; err != nil {
-		return fmt.Errorf("failed to commit transaction: %v", err)
+	// Check for alerts once the deduction is committed
+	for _, inventory := range confirmed {
+		go s.checkInventoryAlerts(context.WithoutCancel(ctx), inventory)
 	}
 
 	return nil
 }
 
 // GetInventoryLevels returns current inventory levels
-func (s *InventoryService) GetInventoryLevels(productID *uuid.UUID, variantID *uuid.UUID) ([]models.Inventory, error) {
+func (s *InventoryService) GetInventoryLevels(ctx context.Context, productID *uuid.UUID, variantID *uuid.UUID) ([]models.Inventory, error) {
 	var inventory []models.Inventory
 
-	query := s.db.Preload("Product").Preload("Variant")
+	query := s.db.WithContext(ctx).Preload("Product").Preload("Variant")
 
 	if productID != nil {
 		query = query.Where("product_id = ?", *productID)
@@ -319,31 +324,57 @@ func (s *InventoryService) GetInventoryLevels(productID *uuid.UUID, variantID *u
 	return inventory, nil
 }
 
-// GetInventoryAlerts returns current inventory alerts
-func (s *InventoryService) GetInventoryAlerts(isRead *bool) ([]InventoryAlert, error) {
+// InventoryAlertListSchema is what the inventory alert list can be filtered
+// and sorted by
+var InventoryAlertListSchema = &listquery.Schema{
+	Filters: map[string]listquery.Field{
+		"is_read":          listquery.Column("inventory_alerts.is_read", listquery.Bool),
+		"alert_type":       listquery.Column("inventory_alerts.alert_type", listquery.String).OneOf("low_stock", "out_of_stock", "overstock"),
+		"product_id":       listquery.Column("inventory_alerts.product_id", listquery.UUID),
+		"location":         listquery.Column("inventory_alerts.location", listquery.String),
+		"current_quantity": listquery.Column("inventory_alerts.current_quantity", listquery.Number),
+		"created_at":       listquery.Column("inventory_alerts.created_at", listquery.Time),
+	},
+	Sorts: map[string]string{
+		"created_at":       "inventory_alerts.created_at",
+		"current_quantity": "inventory_alerts.current_quantity",
+		"product_name":     "products.name",
+	},
+	DefaultSort: "-created_at",
+	TieBreaker:  "inventory_alerts.id",
+	DefaultSize: 50,
+	MaxSize:     200,
+	Aliases: map[string]string{
+		"is_read": "is_read",
+	},
+}
+
+// GetInventoryAlerts returns a page of inventory alerts and how many match
+func (s *InventoryService) GetInventoryAlerts(ctx context.Context, list *listquery.Query) ([]InventoryAlert, int64, error) {
 	var alerts []InventoryAlert
+	var total int64
 
-	query := s.db.Table("inventory_alerts").
-		Select("inventory_alerts.*, products.name as product_name, product_variants.variant_name, product_variants.variant_value").
+	query := list.Filter(s.db.WithContext(ctx).Table("inventory_alerts").
 		Joins("LEFT JOIN products ON inventory_alerts.product_id = products.id").
-		Joins("LEFT JOIN product_variants ON inventory_alerts.variant_id = product_variants.id")
+		Joins("LEFT JOIN product_variants ON inventory_alerts.variant_id = product_variants.id"))
 
-	if isRead != nil {
-		query = query.Where("is_read = ?", *isRead)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count inventory alerts: %v", err)
 	}
 
-	query = query.Order("created_at DESC")
-
-	if err := query.Find(&alerts).Error; err != nil {
-		return nil, fmt.Errorf("failed to get inventory alerts: %v", err)
+	err := list.Paginate(list.Order(query)).
+		Select("inventory_alerts.*, products.name as product_name, product_variants.variant_name, product_variants.variant_value").
+		Find(&alerts).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get inventory alerts: %v", err)
 	}
 
-	return alerts, nil
+	return alerts, total, nil
 }
 
 // MarkAlertAsRead marks an alert as read
-func (s *InventoryService) MarkAlertAsRead(alertID uuid.UUID) error {
-	if err := s.db.Model(&models.InventoryAlert{}).
+func (s *InventoryService) MarkAlertAsRead(ctx context.Context, alertID uuid.UUID) error {
+	if err := s.db.WithContext(ctx).Model(&models.InventoryAlert{}).
 		Where("id = ?", alertID).
 		Update("is_read", true).Error; err != nil {
 		return fmt.Errorf("failed to mark alert as read: %v", err)
@@ -353,25 +384,26 @@ func (s *InventoryService) MarkAlertAsRead(alertID uuid.UUID) error {
 }
 
 // GetInventoryReport returns inventory reporting data
-func (s *InventoryService) GetInventoryReport() (*InventoryReport, error) {
+func (s *InventoryService) GetInventoryReport(ctx context.Context) (*InventoryReport, error) {
 	report := &InventoryReport{}
+	db := s.db.WithContext(ctx)
 
 	// Total products with inventory
-	if err := s.db.Model(&models.Inventory{}).
+	if err := db.Model(&models.Inventory{}).
 		Select("COUNT(DISTINCT product_id)").
 		Scan(&report.TotalProducts).Error; err != nil {
 		return nil, fmt.Errorf("failed to count products: %v", err)
 	}
 
 	// Total quantity
-	if err := s.db.Model(&models.Inventory{}).
+	if err := db.Model(&models.Inventory{}).
 		Select("COALESCE(SUM(quantity_available), 0)").
 		Scan(&report.TotalQuantity).Error; err != nil {
 		return nil, fmt.Errorf("failed to sum quantity: %v", err)
 	}
 
 	// Reserved quantity
-	if err := s.db.Model(&models.Inventory{}).
+	if err := db.Model(&models.Inventory{}).
 		Select("COALESCE(SUM(quantity_reserved), 0)").
 		Scan(&report.ReservedQuantity).Error; err != nil {
 		return nil, fmt.Errorf("failed to sum reserved: %v", err)
@@ -381,52 +413,78 @@ func (s *InventoryService) GetInventoryReport() (*InventoryReport, error) {
 	report.AvailableQuantity = report.TotalQuantity - report.ReservedQuantity
 
 	// Low stock items (quantity < 10)
-	if err := s.db.Model(&models.Inventory{}).
+	if err := db.Model(&models.Inventory{}).
 		Where("quantity_available < ?", 10).
 		Count(&report.LowStockItems).Error; err != nil {
 		return nil, fmt.Errorf("failed to count low stock items: %v", err)
 	}
 
 	// Out of stock items
-	if err := s.db.Model(&models.Inventory{}).
+	if err := db.Model(&models.Inventory{}).
 		Where("quantity_available = ?", 0).
 		Count(&report.OutOfStockItems).Error; err != nil {
 		return nil, fmt.Errorf("failed to count out of stock items: %v", err)
 	}
 
 	// Overstock items (quantity > 100)
-	if err := s.db.Model(&models.Inventory{}).
+	if err := db.Model(&models.Inventory{}).
 		Where("quantity_available > ?", 100).
 		Count(&report.OverstockItems).Error; err != nil {
 		return nil, fmt.Errorf("failed to count overstock items: %v", err)
 	}
 
+	// Forecast demand and the products that need reordering to meet it
+	var forecast struct {
+		ComputedAt *time.Time
+		Forecast30 int
+		Forecast60 int
+		Forecast90 int
+	}
+	if err := db.Model(&models.DemandForecast{}).
+		Select("MAX(computed_at) AS computed_at, COALESCE(SUM(forecast30), 0) AS forecast30, COALESCE(SUM(forecast60), 0) AS forecast60, COALESCE(SUM(forecast90), 0) AS forecast90").
+		Scan(&forecast).Error; err != nil {
+		return nil, fmt.Errorf("failed to sum demand forecasts: %v", err)
+	}
+	report.ForecastedAt = forecast.ComputedAt
+	report.Forecast30 = forecast.Forecast30
+	report.Forecast60 = forecast.Forecast60
+	report.Forecast90 = forecast.Forecast90
+
+	if err := db.Preload("Product").
+		Where("reorder_quantity > ?", 0).
+		Order("reorder_quantity DESC").
+		Find(&report.ReorderSuggestions).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch reorder suggestions: %v", err)
+	}
+
 	return report, nil
 }
 
 // checkInventoryAlerts checks if inventory levels trigger alerts
-func (s *InventoryService) checkInventoryAlerts(inventory models.Inventory) {
+func (s *InventoryService) checkInventoryAlerts(ctx context.Context, inventory models.Inventory) {
 	// Check for low stock alert (quantity < 10)
 	if inventory.QuantityAvailable < 10 && inventory.QuantityAvailable > 0 {
-		s.createAlert(inventory, "low_stock", 10)
+		s.createAlert(ctx, inventory, "low_stock", 10)
 	}
 
 	// Check for out of stock alert (quantity = 0)
 	if inventory.QuantityAvailable == 0 {
-		s.createAlert(inventory, "out_of_stock", 0)
+		s.createAlert(ctx, inventory, "out_of_stock", 0)
 	}
 
 	// Check for overstock alert (quantity > 100)
 	if inventory.QuantityAvailable > 100 {
-		s.createAlert(inventory, "overstock", 100)
+		s.createAlert(ctx, inventory, "overstock", 100)
 	}
 }
 
 // createAlert creates an inventory alert
-func (s *InventoryService) createAlert(inventory models.Inventory, alertType string, threshold int) {
+func (s *InventoryService) createAlert(ctx context.Context, inventory models.Inventory, alertType string, threshold int) {
+	db := s.db.WithContext(ctx)
+
 	// Check if alert already exists
 	var existingAlert models.InventoryAlert
-	err := s.db.Where("product_id = ? AND variant_id = ? AND alert_type = ? AND is_read = ?",
+	err := db.Where("product_id = ? AND variant_id = ? AND alert_type = ? AND is_read = ?",
 		inventory.ProductID, inventory.VariantID, alertType, false).
 		First(&existingAlert).Error
 
@@ -434,7 +492,7 @@ func (s *InventoryService) createAlert(inventory models.Inventory, alertType str
 		// Alert already exists, update it
 		existingAlert.CurrentQuantity = inventory.QuantityAvailable
 		existingAlert.CreatedAt = time.Now()
-		s.db.Save(&existingAlert)
+		db.Save(&existingAlert)
 		return
 	}
 
@@ -449,24 +507,24 @@ func (s *InventoryService) createAlert(inventory models.Inventory, alertType str
 		IsRead:          false,
 	}
 
-	if err := s.db.Create(&alert).Error; err != nil {
+	if err := db.Create(&alert).Error; err != nil {
 		log.Printf("Failed to create inventory alert: %v", err)
 	}
 }
 
 // CleanupExpiredReservations removes expired inventory reservations
-func (s *InventoryService) CleanupExpiredReservations() error {
+func (s *InventoryService) CleanupExpiredReservations(ctx context.Context) error {
 	now := time.Now()
 
 	// Find expired reservations
 	var expiredReservations []models.InventoryReservation
-	if err := s.db.Where("expires_at < ? AND status = ?", now, "active").Find(&expiredReservations).Error; err != nil {
+	if err := s.db.WithContext(ctx).Where("expires_at < ? AND status = ?", now, "active").Find(&expiredReservations).Error; err != nil {
 		return fmt.Errorf("failed to find expired reservations: %v", err)
 	}
 
 	// Release expired reservations
 	for _, reservation := range expiredReservations {
-		if err := s.ReleaseInventory(reservation.SessionID); err != nil {
+		if err := s.ReleaseInventory(ctx, reservation.SessionID); err != nil {
 			log.Printf("Failed to release expired reservation %s: %v", reservation.ID, err)
 		}
 	}
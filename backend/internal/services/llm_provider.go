@@ -0,0 +1,220 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// LLMMessage represents a single message sent to a language model
+type LLMMessage struct {
+	Role    string   `json:"role"` // "system", "user", "assistant"
+	Content string   `json:"content"`
+	Images  []string `json:"images,omitempty"` // image URLs or data URLs, for vision models
+}
+
+// LLMTool is a function the model may call instead of, or as well as,
+// answering in text. Parameters is the JSON schema of its arguments.
+type LLMTool struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	Parameters  map[string]interface{} `json:"parameters"`
+}
+
+// LLMToolCall is a call the model made to one of the request's tools, with
+// its arguments as a JSON object
+type LLMToolCall struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// LLMRequest represents a completion request to a language model provider
+type LLMRequest struct {
+	Model       string       `json:"model"`
+	Messages    []LLMMessage `json:"messages"`
+	MaxTokens   int          `json:"max_tokens"`
+	Temperature float32      `json:"temperature"`
+	Tools       []LLMTool    `json:"tools,omitempty"`
+}
+
+// LLMUsage represents token accounting for a completion
+type LLMUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// LLMResponse represents a completion returned by a language model provider
+type LLMResponse struct {
+	Content   string        `json:"content"`
+	ToolCalls []LLMToolCall `json:"tool_calls,omitempty"`
+	Model     string        `json:"model"`
+	Usage     LLMUsage      `json:"usage"`
+}
+
+// LLMProvider is implemented by language model backends used by the chat service
+type LLMProvider interface {
+	// Complete returns the full completion for the request
+	Complete(ctx context.Context, req LLMRequest) (*LLMResponse, error)
+	// Stream calls onDelta for each content chunk and returns the assembled completion
+	Stream(ctx context.Context, req LLMRequest, onDelta func(delta string) error) (*LLMResponse, error)
+}
+
+// OpenAIProvider implements LLMProvider using the OpenAI API
+type OpenAIProvider struct {
+	client *openai.Client
+}
+
+// NewOpenAIProvider creates a new OpenAIProvider
+func NewOpenAIProvider(apiKey string) *OpenAIProvider {
+	return &OpenAIProvider{
+		client: openai.NewClient(apiKey),
+	}
+}
+
+// Complete sends a chat completion request to OpenAI
+func (p *OpenAIProvider) Complete(ctx context.Context, req LLMRequest) (*LLMResponse, error) {
+	response, err := p.client.CreateChatCompletion(ctx, toOpenAIRequest(req))
+	if err != nil {
+		return nil, err
+	}
+	if len(response.Choices) == 0 {
+		return nil, errors.New("no choices returned from OpenAI")
+	}
+
+	return &LLMResponse{
+		Content:   response.Choices[0].Message.Content,
+		ToolCalls: fromOpenAIToolCalls(response.Choices[0].Message.ToolCalls),
+		Model:     response.Model,
+		Usage: LLMUsage{
+			PromptTokens:     response.Usage.PromptTokens,
+			CompletionTokens: response.Usage.CompletionTokens,
+			TotalTokens:      response.Usage.TotalTokens,
+		},
+	}, nil
+}
+
+// Stream sends a streaming chat completion request to OpenAI
+func (p *OpenAIProvider) Stream(ctx context.Context, req LLMRequest, onDelta func(delta string) error) (*LLMResponse, error) {
+	openaiReq := toOpenAIRequest(req)
+	openaiReq.Stream = true
+	// Usage arrives in a last chunk without choices
+	openaiReq.StreamOptions = &openai.StreamOptions{IncludeUsage: true}
+
+	stream, err := p.client.CreateChatCompletionStream(ctx, openaiReq)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+
+	result := &LLMResponse{Model: req.Model}
+	// Tool calls arrive in pieces, the arguments a few characters at a time
+	var toolCalls []openai.ToolCall
+	for {
+		chunk, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read stream: %w", err)
+		}
+		if chunk.Usage != nil {
+			result.Usage = LLMUsage{
+				PromptTokens:     chunk.Usage.PromptTokens,
+				CompletionTokens: chunk.Usage.CompletionTokens,
+				TotalTokens:      chunk.Usage.TotalTokens,
+			}
+		}
+		if len(chunk.Choices) == 0 {
+			continue
+		}
+
+		for _, call := range chunk.Choices[0].Delta.ToolCalls {
+			index := len(toolCalls)
+			if call.Index != nil {
+				index = *call.Index
+			}
+			for len(toolCalls) <= index {
+				toolCalls = append(toolCalls, openai.ToolCall{})
+			}
+			if call.ID != "" {
+				toolCalls[index].ID = call.ID
+			}
+			toolCalls[index].Function.Name += call.Function.Name
+			toolCalls[index].Function.Arguments += call.Function.Arguments
+		}
+
+		delta := chunk.Choices[0].Delta.Content
+		if delta == "" {
+			continue
+		}
+		result.Content += delta
+		if err := onDelta(delta); err != nil {
+			return nil, err
+		}
+	}
+	result.ToolCalls = fromOpenAIToolCalls(toolCalls)
+
+	return result, nil
+}
+
+// toOpenAIRequest converts an LLMRequest to the OpenAI request format
+func toOpenAIRequest(req LLMRequest) openai.ChatCompletionRequest {
+	messages := make([]openai.ChatCompletionMessage, 0, len(req.Messages))
+	for _, msg := range req.Messages {
+		if len(msg.Images) > 0 {
+			// Images go with the text as parts of one message
+			parts := []openai.ChatMessagePart{{Type: openai.ChatMessagePartTypeText, Text: msg.Content}}
+			for _, image := range msg.Images {
+				parts = append(parts, openai.ChatMessagePart{
+					Type:     openai.ChatMessagePartTypeImageURL,
+					ImageURL: &openai.ChatMessageImageURL{URL: image, Detail: openai.ImageURLDetailLow},
+				})
+			}
+			messages = append(messages, openai.ChatCompletionMessage{Role: msg.Role, MultiContent: parts})
+			continue
+		}
+		messages = append(messages, openai.ChatCompletionMessage{
+			Role:    msg.Role,
+			Content: msg.Content,
+		})
+	}
+
+	var tools []openai.Tool
+	for _, tool := range req.Tools {
+		tools = append(tools, openai.Tool{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Strict:      true,
+				Parameters:  tool.Parameters,
+			},
+		})
+	}
+
+	return openai.ChatCompletionRequest{
+		Model:       req.Model,
+		Messages:    messages,
+		MaxTokens:   req.MaxTokens,
+		Temperature: req.Temperature,
+		Tools:       tools,
+	}
+}
+
+// fromOpenAIToolCalls converts OpenAI tool calls to LLMToolCalls
+func fromOpenAIToolCalls(calls []openai.ToolCall) []LLMToolCall {
+	var toolCalls []LLMToolCall
+	for _, call := range calls {
+		toolCalls = append(toolCalls, LLMToolCall{
+			ID:        call.ID,
+			Name:      call.Function.Name,
+			Arguments: call.Function.Arguments,
+		})
+	}
+	return toolCalls
+}
@@ -0,0 +1,288 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/sashabaranov/go-openai"
+	"gorm.io/gorm"
+)
+
+// DefaultStoreVariant is the StoreSettings variant holding store-wide settings
+const DefaultStoreVariant = "default"
+
+// Sources of a resolved LLM configuration
+const (
+	LLMConfigSourceEnvironment     = "environment"
+	LLMConfigSourceStore           = "store"
+	LLMConfigSourceExperiment      = "experiment"
+	LLMConfigSourceSessionOverride = "session_override"
+)
+
+// llmOverrideContextKey is the ChatSession.Context key holding an admin override
+const llmOverrideContextKey = "llm_override"
+
+// LLMConfig is the model configuration used for a chat turn
+type LLMConfig struct {
+	Model       string  `json:"model"`
+	Temperature float32 `json:"temperature"`
+	MaxTokens   int     `json:"max_tokens"`
+	Variant     string  `json:"variant"`
+	Source      string  `json:"source"`
+}
+
+// LLMSettingsRequest represents the request to configure a store or experiment variant
+type LLMSettingsRequest struct {
+	Model          string   `json:"model"`
+	Temperature    *float32 `json:"temperature" binding:"omitempty,min=0,max=2"`
+	MaxTokens      *int     `json:"max_tokens" binding:"omitempty,min=1,max=4096"`
+	TrafficPercent int      `json:"traffic_percent" binding:"min=0,max=100"`
+	IsActive       *bool    `json:"is_active"`
+}
+
+// LLMOverrideRequest represents an admin override of the model settings for one session
+type LLMOverrideRequest struct {
+	Model       string   `json:"model"`
+	Temperature *float32 `json:"temperature" binding:"omitempty,min=0,max=2"`
+	MaxTokens   *int     `json:"max_tokens" binding:"omitempty,min=1,max=4096"`
+}
+
+// LLMSettingsService resolves and manages per-store, per-experiment and
+// per-session LLM settings
+type LLMSettingsService struct {
+	db *gorm.DB
+}
+
+// NewLLMSettingsService creates a new LLMSettingsService
+func NewLLMSettingsService(db *gorm.DB) *LLMSettingsService {
+	return &LLMSettingsService{
+		db: db,
+	}
+}
+
+// DefaultLLMConfig returns the configuration from OPENAI_MODEL, OPENAI_TEMPERATURE
+// and OPENAI_MAX_TOKENS, falling back to GPT-4 at 0.7 with 500 tokens
+func DefaultLLMConfig() LLMConfig {
+	config := LLMConfig{
+		Model:       openai.GPT4,
+		Temperature: 0.7,
+		MaxTokens:   500,
+		Variant:     DefaultStoreVariant,
+		Source:      LLMConfigSourceEnvironment,
+	}
+	if model := os.Getenv("OPENAI_MODEL"); model != "" {
+		config.Model = model
+	}
+	if temperature, err := strconv.ParseFloat(os.Getenv("OPENAI_TEMPERATURE"), 32); err == nil {
+		config.Temperature = float32(temperature)
+	}
+	if maxTokens := envInt("OPENAI_MAX_TOKENS", 0); maxTokens > 0 {
+		config.MaxTokens = maxTokens
+	}
+	return config
+}
+
+// ResolveConfig returns the configuration for a session. A session override
+// wins over the session's experiment variant, which wins over the store
+// default and then the environment.
+func (s *LLMSettingsService) ResolveConfig(ctx context.Context, sessionID string) LLMConfig {
+	config := DefaultLLMConfig()
+	db := s.db.WithContext(ctx)
+
+	var settings []models.StoreSettings
+	if err := db.Where("is_active = ?", true).Order("variant").Find(&settings).Error; err == nil {
+		for _, setting := range settings {
+			if setting.Variant == DefaultStoreVariant {
+				applyStoreSettings(&config, setting)
+				config.Source = LLMConfigSourceStore
+			}
+		}
+		if variant := assignVariant(sessionID, settings); variant != nil {
+			applyStoreSettings(&config, *variant)
+			config.Variant = variant.Variant
+			config.Source = LLMConfigSourceExperiment
+		}
+	}
+
+	if override, err := s.GetSessionOverride(ctx, sessionID); err == nil && override != nil {
+		if override.Model != "" {
+			config.Model = override.Model
+		}
+		if override.Temperature != nil {
+			config.Temperature = *override.Temperature
+		}
+		if override.MaxTokens != nil {
+			config.MaxTokens = *override.MaxTokens
+		}
+		config.Source = LLMConfigSourceSessionOverride
+	}
+
+	return config
+}
+
+func applyStoreSettings(config *LLMConfig, setting models.StoreSettings) {
+	if setting.LLMModel != "" {
+		config.Model = setting.LLMModel
+	}
+	if setting.LLMTemperature != nil {
+		config.Temperature = *setting.LLMTemperature
+	}
+	if setting.LLMMaxTokens != nil {
+		config.MaxTokens = *setting.LLMMaxTokens
+	}
+}
+
+// assignVariant deterministically buckets a session into an experiment
+// variant by its traffic percentage, or returns nil for the store default
+func assignVariant(sessionID string, settings []models.StoreSettings) *models.StoreSettings {
+	hash := fnv.New32a()
+	hash.Write([]byte(sessionID))
+	bucket := int(hash.Sum32() % 100)
+
+	cumulative := 0
+	for i := range settings {
+		if settings[i].Variant == DefaultStoreVariant || settings[i].TrafficPercent <= 0 {
+			continue
+		}
+		cumulative += settings[i].TrafficPercent
+		if bucket < cumulative {
+			return &settings[i]
+		}
+	}
+	return nil
+}
+
+// ListSettings returns the store default and all experiment variants
+func (s *LLMSettingsService) ListSettings(ctx context.Context) ([]models.StoreSettings, error) {
+	var settings []models.StoreSettings
+	if err := s.db.WithContext(ctx).Order("variant").Find(&settings).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch store settings: %v", err)
+	}
+	return settings, nil
+}
+
+// UpsertSettings creates or updates the settings for a variant
+func (s *LLMSettingsService) UpsertSettings(ctx context.Context, variant string, req LLMSettingsRequest) (*models.StoreSettings, error) {
+	if variant == "" {
+		return nil, errors.New("variant is required")
+	}
+
+	db := s.db.WithContext(ctx)
+	if variant != DefaultStoreVariant && req.TrafficPercent > 0 {
+		var allocated int64
+		err := db.Model(&models.StoreSettings{}).
+			Where("variant <> ? AND variant <> ? AND is_active = ?", variant, DefaultStoreVariant, true).
+			Select("COALESCE(SUM(traffic_percent), 0)").
+			Scan(&allocated).Error
+		if err != nil {
+			return nil, fmt.Errorf("failed to check traffic allocation: %v", err)
+		}
+		if int(allocated)+req.TrafficPercent > 100 {
+			return nil, fmt.Errorf("experiment traffic would exceed 100%% (%d%% already allocated)", allocated)
+		}
+	}
+
+	var settings models.StoreSettings
+	err := db.Where("variant = ?", variant).First(&settings).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to fetch store settings: %v", err)
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		settings = models.StoreSettings{ID: uuid.New(), Variant: variant, IsActive: true}
+	}
+
+	settings.LLMModel = req.Model
+	settings.LLMTemperature = req.Temperature
+	settings.LLMMaxTokens = req.MaxTokens
+	settings.TrafficPercent = req.TrafficPercent
+	if variant == DefaultStoreVariant {
+		settings.TrafficPercent = 0
+	}
+	if req.IsActive != nil {
+		settings.IsActive = *req.IsActive
+	}
+
+	if err := db.Save(&settings).Error; err != nil {
+		return nil, fmt.Errorf("failed to save store settings: %v", err)
+	}
+	return &settings, nil
+}
+
+// DeleteSettings removes the settings for a variant
+func (s *LLMSettingsService) DeleteSettings(ctx context.Context, variant string) error {
+	result := s.db.WithContext(ctx).Where("variant = ?", variant).Delete(&models.StoreSettings{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete store settings: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("store settings not found")
+	}
+	return nil
+}
+
+// GetSessionOverride returns the admin override for a session, or nil if none is set
+func (s *LLMSettingsService) GetSessionOverride(ctx context.Context, sessionID string) (*LLMOverrideRequest, error) {
+	sessionContext, err := s.sessionContext(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, ok := sessionContext[llmOverrideContextKey]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	var override LLMOverrideRequest
+	if err := json.Unmarshal(data, &override); err != nil {
+		return nil, fmt.Errorf("failed to parse session override: %v", err)
+	}
+	return &override, nil
+}
+
+// SetSessionOverride stores an admin override for a session; a nil override clears it
+func (s *LLMSettingsService) SetSessionOverride(ctx context.Context, sessionID string, override *LLMOverrideRequest) error {
+	var value interface{}
+	if override != nil {
+		value = override
+	}
+	err := setSessionContextKey(ctx, s.db, sessionID, llmOverrideContextKey, value)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return errors.New("chat session not found")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update chat session: %v", err)
+	}
+	return nil
+}
+
+// sessionContext loads the Context map of a chat session
+func (s *LLMSettingsService) sessionContext(ctx context.Context, sessionID string) (map[string]interface{}, error) {
+	var session models.ChatSession
+	if err := s.db.WithContext(ctx).Where("session_id = ?", sessionID).First(&session).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("chat session not found")
+		}
+		return nil, fmt.Errorf("failed to fetch chat session: %v", err)
+	}
+
+	var sessionContext map[string]interface{}
+	if len(session.Context) > 0 {
+		if err := json.Unmarshal(session.Context, &sessionContext); err != nil {
+			return nil, fmt.Errorf("failed to parse chat session context: %v", err)
+		}
+	}
+	if sessionContext == nil {
+		sessionContext = map[string]interface{}{}
+	}
+	return sessionContext, nil
+}
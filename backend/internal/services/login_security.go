@@ -0,0 +1,381 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	authmodels "chat-ecommerce-backend/internal/models/auth"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Account states set by login security
+const (
+	AccountStateActive = "active"
+	AccountStateLocked = "locked"
+)
+
+// Security notification types and channels
+const (
+	SecurityNotificationAccountLocked   = "account_locked"
+	SecurityNotificationAccountUnlocked = "account_unlocked"
+	SecurityNotificationNewSignIn       = "new_sign_in"
+
+	SecurityChannelSession = "session"
+	SecurityChannelEmail   = "email"
+)
+
+// accountUnlockTTL is how long an emailed unlock link works
+const accountUnlockTTL = 24 * time.Hour
+
+// newSignInLookback is how far back a successful sign-in from the same IP
+// address makes a sign-in familiar rather than one to warn about
+const newSignInLookback = 90 * 24 * time.Hour
+
+// maxSecurityNotifications caps the notifications listed for a user
+const maxSecurityNotifications = 50
+
+// Login security errors
+var (
+	ErrAccountLocked        = errors.New("account is locked after too many failed sign-in attempts")
+	ErrTooManyLoginAttempts = errors.New("too many failed sign-in attempts from this address; try again later")
+	ErrInvalidUnlockToken   = errors.New("the unlock link is invalid or has expired")
+)
+
+// LoginSecurityConfig sets when failed sign-ins lock an account or throttle
+// an IP address
+type LoginSecurityConfig struct {
+	MaxFailedAttempts int           // consecutive failures before an account locks
+	LockoutDuration   time.Duration // how long a locked account stays locked
+	MaxIPFailures     int           // failures from one IP address within IPWindow before it's throttled
+	IPWindow          time.Duration
+}
+
+// DefaultLoginSecurityConfig returns the default configuration
+func DefaultLoginSecurityConfig() LoginSecurityConfig {
+	return LoginSecurityConfig{
+		MaxFailedAttempts: 5,
+		LockoutDuration:   15 * time.Minute,
+		MaxIPFailures:     20,
+		IPWindow:          15 * time.Minute,
+	}
+}
+
+// LoginSecurityConfigFromEnv returns the default configuration overridden by
+// LOGIN_MAX_FAILED_ATTEMPTS, LOGIN_LOCKOUT_MINUTES, LOGIN_IP_MAX_FAILED_ATTEMPTS
+// and LOGIN_IP_WINDOW_MINUTES
+func LoginSecurityConfigFromEnv() LoginSecurityConfig {
+	config := DefaultLoginSecurityConfig()
+	if attempts := envInt("LOGIN_MAX_FAILED_ATTEMPTS", 0); attempts > 0 {
+		config.MaxFailedAttempts = attempts
+	}
+	if minutes := envInt("LOGIN_LOCKOUT_MINUTES", 0); minutes > 0 {
+		config.LockoutDuration = time.Duration(minutes) * time.Minute
+	}
+	if failures := envInt("LOGIN_IP_MAX_FAILED_ATTEMPTS", 0); failures > 0 {
+		config.MaxIPFailures = failures
+	}
+	if minutes := envInt("LOGIN_IP_WINDOW_MINUTES", 0); minutes > 0 {
+		config.IPWindow = time.Duration(minutes) * time.Minute
+	}
+	return config
+}
+
+// accountUnlockBaseURLFromEnv returns ACCOUNT_UNLOCK_BASE_URL, or the local storefront's unlock page
+func accountUnlockBaseURLFromEnv() string {
+	if baseURL := os.Getenv("ACCOUNT_UNLOCK_BASE_URL"); baseURL != "" {
+		return strings.TrimRight(baseURL, "/")
+	}
+	return "http://localhost:3000/unlock-account"
+}
+
+// LoginSecurityService tracks sign-in attempts, locks accounts and throttles
+// IP addresses after repeated failures, and notifies users of security events
+type LoginSecurityService struct {
+	db            *gorm.DB
+	config        LoginSecurityConfig
+	unlockBaseURL string
+}
+
+// NewLoginSecurityService creates a new LoginSecurityService
+func NewLoginSecurityService(db *gorm.DB) *LoginSecurityService {
+	return &LoginSecurityService{
+		db:            db,
+		config:        LoginSecurityConfigFromEnv(),
+		unlockBaseURL: accountUnlockBaseURLFromEnv(),
+	}
+}
+
+// WithConfig replaces the lockout and throttling thresholds
+func (s *LoginSecurityService) WithConfig(config LoginSecurityConfig) *LoginSecurityService {
+	s.config = config
+	return s
+}
+
+// CheckIP returns ErrTooManyLoginAttempts when an IP address has failed to
+// sign in too often recently
+func (s *LoginSecurityService) CheckIP(ctx context.Context, ipAddress string) error {
+	if ipAddress == "" {
+		return nil
+	}
+
+	var failures int64
+	if err := s.db.WithContext(ctx).Model(&models.LoginAttempt{}).
+		Where("ip_address = ? AND success = ? AND created_at > ?", ipAddress, false, time.Now().Add(-s.config.IPWindow)).
+		Count(&failures).Error; err != nil {
+		return fmt.Errorf("failed to count sign-in attempts: %v", err)
+	}
+	if int(failures) >= s.config.MaxIPFailures {
+		return ErrTooManyLoginAttempts
+	}
+	return nil
+}
+
+// CheckLocked returns ErrAccountLocked, with when the lockout ends, while the
+// user's account is locked
+func (s *LoginSecurityService) CheckLocked(user *models.User) error {
+	if !user.IsLocked() {
+		return nil
+	}
+	minutes := int(time.Until(*user.LockoutUntil).Minutes()) + 1
+	return fmt.Errorf("%w; try again in %d minutes or use the unlock link we emailed you", ErrAccountLocked, minutes)
+}
+
+// RecordFailure records a failed sign-in. user is nil when no account has
+// the email. An account that reaches the failure threshold is locked, and
+// the user is emailed an unlock link and warned in their other sessions; the
+// returned error is then ErrAccountLocked.
+func (s *LoginSecurityService) RecordFailure(ctx context.Context, user *models.User, email, ipAddress string) error {
+	attempt := &models.LoginAttempt{
+		ID:        uuid.New(),
+		Email:     strings.ToLower(strings.TrimSpace(email)),
+		IPAddress: ipAddress,
+	}
+	if user == nil {
+		if err := s.db.WithContext(ctx).Create(attempt).Error; err != nil {
+			return fmt.Errorf("failed to record sign-in attempt: %v", err)
+		}
+		return nil
+	}
+	attempt.UserID = &user.ID
+
+	var locked bool
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(attempt).Error; err != nil {
+			return fmt.Errorf("failed to record sign-in attempt: %v", err)
+		}
+
+		// A lockout that has run out starts the count again
+		failures := user.FailedLoginAttempts
+		if user.AccountState == AccountStateLocked {
+			failures = 0
+		}
+		failures++
+
+		updates := map[string]interface{}{"failed_login_attempts": failures, "account_state": AccountStateActive}
+		if failures >= s.config.MaxFailedAttempts {
+			lockoutUntil := time.Now().Add(s.config.LockoutDuration)
+			updates["account_state"] = AccountStateLocked
+			updates["lockout_until"] = lockoutUntil
+			user.LockoutUntil = &lockoutUntil
+			locked = true
+		}
+		if err := tx.Model(&models.User{}).Where("id = ?", user.ID).Updates(updates).Error; err != nil {
+			return fmt.Errorf("failed to update failed sign-in count: %v", err)
+		}
+		user.FailedLoginAttempts = failures
+		if locked {
+			user.AccountState = AccountStateLocked
+		}
+
+		if locked {
+			return s.notifyLocked(tx, user, ipAddress)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if locked {
+		return s.CheckLocked(user)
+	}
+	return nil
+}
+
+// RecordSuccess records a successful sign-in, clears the failure count and
+// warns the user's other sessions when the sign-in comes from an IP address
+// they haven't signed in from recently
+func (s *LoginSecurityService) RecordSuccess(ctx context.Context, user *models.User, ipAddress string) error {
+	now := time.Now()
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if ipAddress != "" {
+			var earlier, fromIP int64
+			if err := tx.Model(&models.LoginAttempt{}).
+				Where("user_id = ? AND success = ?", user.ID, true).
+				Count(&earlier).Error; err != nil {
+				return fmt.Errorf("failed to count sign-ins: %v", err)
+			}
+			if err := tx.Model(&models.LoginAttempt{}).
+				Where("user_id = ? AND success = ? AND ip_address = ? AND created_at > ?", user.ID, true, ipAddress, now.Add(-newSignInLookback)).
+				Count(&fromIP).Error; err != nil {
+				return fmt.Errorf("failed to count sign-ins: %v", err)
+			}
+			if earlier > 0 && fromIP == 0 {
+				if err := s.notify(tx, &models.SecurityNotification{
+					UserID:    user.ID,
+					Type:      SecurityNotificationNewSignIn,
+					Channel:   SecurityChannelSession,
+					Message:   fmt.Sprintf("Your account was signed in to from a new IP address (%s). If this wasn't you, change your password.", ipAddress),
+					IPAddress: ipAddress,
+				}); err != nil {
+					return err
+				}
+			}
+		}
+
+		if err := tx.Create(&models.LoginAttempt{
+			ID:        uuid.New(),
+			Email:     strings.ToLower(user.Email),
+			UserID:    &user.ID,
+			IPAddress: ipAddress,
+			Success:   true,
+		}).Error; err != nil {
+			return fmt.Errorf("failed to record sign-in attempt: %v", err)
+		}
+		if err := tx.Model(&models.User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+			"failed_login_attempts": 0,
+			"account_state":         AccountStateActive,
+			"lockout_until":         nil,
+			"last_login_at":         now,
+		}).Error; err != nil {
+			return fmt.Errorf("failed to update last sign-in: %v", err)
+		}
+		user.FailedLoginAttempts = 0
+		user.AccountState = AccountStateActive
+		user.LockoutUntil = nil
+		user.LastLoginAt = &now
+		return nil
+	})
+}
+
+// UnlockAccount unlocks an account with the token from an emailed unlock link
+func (s *LoginSecurityService) UnlockAccount(ctx context.Context, token string) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var unlock authmodels.AccountUnlockToken
+		if err := tx.Where("token = ?", hashResetToken(token)).First(&unlock).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidUnlockToken
+			}
+			return fmt.Errorf("failed to find unlock link: %v", err)
+		}
+		if !unlock.IsValid() {
+			return ErrInvalidUnlockToken
+		}
+
+		unlock.Used = true
+		if err := tx.Save(&unlock).Error; err != nil {
+			return fmt.Errorf("failed to update unlock link: %v", err)
+		}
+		if err := tx.Model(&models.User{}).Where("id = ?", unlock.UserID).Updates(map[string]interface{}{
+			"failed_login_attempts": 0,
+			"account_state":         AccountStateActive,
+			"lockout_until":         nil,
+		}).Error; err != nil {
+			return fmt.Errorf("failed to unlock account: %v", err)
+		}
+		return s.notify(tx, &models.SecurityNotification{
+			UserID:  unlock.UserID,
+			Type:    SecurityNotificationAccountUnlocked,
+			Channel: SecurityChannelSession,
+			Message: "Your account was unlocked with the link we emailed you.",
+		})
+	})
+}
+
+// ListNotifications returns the security notifications shown to a user's
+// signed-in sessions, newest first
+func (s *LoginSecurityService) ListNotifications(ctx context.Context, userID uuid.UUID, unreadOnly bool) ([]models.SecurityNotification, error) {
+	query := s.db.WithContext(ctx).
+		Where("user_id = ? AND channel = ?", userID, SecurityChannelSession).
+		Order("created_at DESC").
+		Limit(maxSecurityNotifications)
+	if unreadOnly {
+		query = query.Where("read_at IS NULL")
+	}
+
+	var notifications []models.SecurityNotification
+	if err := query.Find(&notifications).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch security notifications: %v", err)
+	}
+	return notifications, nil
+}
+
+// MarkNotificationsRead marks all of a user's security notifications as read
+func (s *LoginSecurityService) MarkNotificationsRead(ctx context.Context, userID uuid.UUID) error {
+	if err := s.db.WithContext(ctx).Model(&models.SecurityNotification{}).
+		Where("user_id = ? AND channel = ? AND read_at IS NULL", userID, SecurityChannelSession).
+		Update("read_at", time.Now()).Error; err != nil {
+		return fmt.Errorf("failed to mark security notifications as read: %v", err)
+	}
+	return nil
+}
+
+// notifyLocked issues an unlock link, queues the email carrying it and warns
+// the user's other sessions
+func (s *LoginSecurityService) notifyLocked(tx *gorm.DB, user *models.User, ipAddress string) error {
+	token, err := newResetToken()
+	if err != nil {
+		return err
+	}
+	if err := tx.Model(&authmodels.AccountUnlockToken{}).
+		Where("user_id = ? AND used = ?", user.ID, false).
+		Update("used", true).Error; err != nil {
+		return fmt.Errorf("failed to expire unlock links: %v", err)
+	}
+	if err := tx.Create(&authmodels.AccountUnlockToken{
+		ID:        uuid.New(),
+		UserID:    user.ID,
+		Token:     hashResetToken(token),
+		ExpiresAt: time.Now().Add(accountUnlockTTL),
+	}).Error; err != nil {
+		return fmt.Errorf("failed to save unlock link: %v", err)
+	}
+
+	until := user.LockoutUntil.Format(time.RFC1123)
+	if err := s.notify(tx, &models.SecurityNotification{
+		UserID:    user.ID,
+		Type:      SecurityNotificationAccountLocked,
+		Channel:   SecurityChannelEmail,
+		Recipient: user.Email,
+		Subject:   "Your account has been locked",
+		Message: fmt.Sprintf("Your account was locked after %d failed sign-in attempts, the last from %s. It unlocks at %s, or you can unlock it now at %s?token=%s\n\nIf this wasn't you, change your password once you're back in.",
+			user.FailedLoginAttempts, ipAddress, until, s.unlockBaseURL, token),
+		IPAddress: ipAddress,
+	}); err != nil {
+		return err
+	}
+	return s.notify(tx, &models.SecurityNotification{
+		UserID:    user.ID,
+		Type:      SecurityNotificationAccountLocked,
+		Channel:   SecurityChannelSession,
+		Message:   fmt.Sprintf("Someone failed to sign in to your account %d times from %s, so it's locked to new sign-ins until %s.", user.FailedLoginAttempts, ipAddress, until),
+		IPAddress: ipAddress,
+	})
+}
+
+func (s *LoginSecurityService) notify(tx *gorm.DB, notification *models.SecurityNotification) error {
+	notification.ID = uuid.New()
+	if err := tx.Create(notification).Error; err != nil {
+		return fmt.Errorf("failed to create security notification: %v", err)
+	}
+	if notification.Channel == SecurityChannelEmail {
+		log.Printf("Queued %s email for user %s", notification.Type, notification.UserID)
+	}
+	return nil
+}
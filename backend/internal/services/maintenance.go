@@ -0,0 +1,222 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// MaintenanceNotification is the WebSocket message type of maintenance notices
+const MaintenanceNotification = "system_notification"
+
+// maintenanceCacheTTL is how long the maintenance state is reused before
+// reading it again, so every API instance picks up changes within seconds
+// without a query per request
+const maintenanceCacheTTL = 5 * time.Second
+
+// defaultMaintenanceRetryAfter is sent as Retry-After when a maintenance has
+// no expected end, or overruns it
+const defaultMaintenanceRetryAfter = 5 * time.Minute
+
+// ErrNoMaintenance is returned when ending a maintenance that isn't planned
+var ErrNoMaintenance = errors.New("no maintenance is planned")
+
+// MaintenanceRequest plans a maintenance in a PUT /admin/maintenance request
+type MaintenanceRequest struct {
+	Message         string `json:"message"`
+	StartsInMinutes int    `json:"starts_in_minutes" binding:"min=0"` // 0 starts it right away
+	DurationMinutes int    `json:"duration_minutes" binding:"min=0"`  // expected length, 0 if unknown
+}
+
+// MaintenanceStatus is the maintenance clients are told about
+type MaintenanceStatus struct {
+	Planned           bool       `json:"planned"`
+	Active            bool       `json:"active"` // writes are refused
+	Message           string     `json:"message,omitempty"`
+	StartsAt          *time.Time `json:"starts_at,omitempty"`
+	ExpectedEnd       *time.Time `json:"expected_end,omitempty"`
+	SecondsUntilStart int        `json:"seconds_until_start"` // countdown for the banner
+	RetryAfterSeconds int        `json:"retry_after_seconds,omitempty"`
+}
+
+// MaintenanceNotice is the system notification shoppers get about a maintenance
+type MaintenanceNotice struct {
+	Kind        string             `json:"kind"` // "maintenance", or "maintenance_ended" once it's over
+	Maintenance *MaintenanceStatus `json:"maintenance"`
+}
+
+// NewMaintenanceNotice describes a maintenance for connected shoppers
+func NewMaintenanceNotice(status *MaintenanceStatus) MaintenanceNotice {
+	kind := "maintenance"
+	if !status.Planned {
+		kind = "maintenance_ended"
+	}
+	return MaintenanceNotice{Kind: kind, Maintenance: status}
+}
+
+// MaintenanceService plans API maintenance. While a maintenance is in
+// progress the API only serves reads, and connected shoppers are told ahead
+// of time so they can finish their checkout.
+type MaintenanceService struct {
+	db *gorm.DB
+
+	mu       sync.Mutex
+	current  *models.MaintenanceWindow
+	loadedAt time.Time
+}
+
+// NewMaintenanceService creates a new MaintenanceService
+func NewMaintenanceService(db *gorm.DB) *MaintenanceService {
+	return &MaintenanceService{
+		db: db,
+	}
+}
+
+// Status reports the planned or running maintenance at now
+func (s *MaintenanceService) Status(ctx context.Context, now time.Time) (*MaintenanceStatus, error) {
+	window, err := s.window(ctx, now)
+	if err != nil {
+		return nil, err
+	}
+	return maintenanceStatus(window, now), nil
+}
+
+// RefusesWrites reports whether writes are refused at now and, if so, how
+// long clients should wait before retrying. The state is cached for a few
+// seconds; when it can't be read the API stays open.
+func (s *MaintenanceService) RefusesWrites(ctx context.Context, now time.Time) (bool, time.Duration) {
+	window, err := s.window(ctx, now)
+	if err != nil || window == nil || now.Before(window.StartsAt) {
+		return false, 0
+	}
+	return true, maintenanceRetryAfter(window, now)
+}
+
+// Plan schedules a maintenance, replacing any planned one
+func (s *MaintenanceService) Plan(ctx context.Context, req MaintenanceRequest, adminID *uuid.UUID, now time.Time) (*MaintenanceStatus, error) {
+	if req.StartsInMinutes < 0 || req.DurationMinutes < 0 {
+		return nil, errors.New("starts_in_minutes and duration_minutes must not be negative")
+	}
+
+	window := &models.MaintenanceWindow{
+		ID:        uuid.New(),
+		Message:   req.Message,
+		StartsAt:  now.Add(time.Duration(req.StartsInMinutes) * time.Minute),
+		CreatedBy: adminID,
+	}
+	if window.Message == "" {
+		window.Message = "We're performing scheduled maintenance. Browsing still works, but carts and checkout are paused."
+	}
+	if req.DurationMinutes > 0 {
+		expectedEnd := window.StartsAt.Add(time.Duration(req.DurationMinutes) * time.Minute)
+		window.ExpectedEnd = &expectedEnd
+	}
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.MaintenanceWindow{}).Where("ended_at IS NULL").Update("ended_at", now).Error; err != nil {
+			return fmt.Errorf("failed to end planned maintenance: %v", err)
+		}
+		if err := tx.Create(window).Error; err != nil {
+			return fmt.Errorf("failed to plan maintenance: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.remember(window, now)
+	return maintenanceStatus(window, now), nil
+}
+
+// End ends the planned or running maintenance
+func (s *MaintenanceService) End(ctx context.Context, now time.Time) error {
+	result := s.db.WithContext(ctx).Model(&models.MaintenanceWindow{}).Where("ended_at IS NULL").Update("ended_at", now)
+	if result.Error != nil {
+		return fmt.Errorf("failed to end maintenance: %v", result.Error)
+	}
+	s.remember(nil, now)
+	if result.RowsAffected == 0 {
+		return ErrNoMaintenance
+	}
+	return nil
+}
+
+// window returns the maintenance that hasn't been ended, or nil
+func (s *MaintenanceService) window(ctx context.Context, now time.Time) (*models.MaintenanceWindow, error) {
+	s.mu.Lock()
+	if !s.loadedAt.IsZero() && now.Sub(s.loadedAt) < maintenanceCacheTTL {
+		current := s.current
+		s.mu.Unlock()
+		return current, nil
+	}
+	s.mu.Unlock()
+
+	var window models.MaintenanceWindow
+	err := s.db.WithContext(ctx).Where("ended_at IS NULL").Order("created_at DESC").First(&window).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to fetch maintenance: %v", err)
+	}
+
+	var current *models.MaintenanceWindow
+	if err == nil {
+		current = &window
+	}
+	s.remember(current, now)
+	return current, nil
+}
+
+// remember caches the current maintenance
+func (s *MaintenanceService) remember(window *models.MaintenanceWindow, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.current = window
+	s.loadedAt = now
+}
+
+// maintenanceStatus describes a maintenance, or the lack of one, at now
+func maintenanceStatus(window *models.MaintenanceWindow, now time.Time) *MaintenanceStatus {
+	if window == nil {
+		return &MaintenanceStatus{}
+	}
+	startsAt := window.StartsAt
+	status := &MaintenanceStatus{
+		Planned:     true,
+		Active:      !now.Before(startsAt),
+		Message:     window.Message,
+		StartsAt:    &startsAt,
+		ExpectedEnd: window.ExpectedEnd,
+	}
+	if status.Active {
+		status.RetryAfterSeconds = int(maintenanceRetryAfter(window, now).Seconds())
+	} else {
+		status.SecondsUntilStart = int(startsAt.Sub(now).Round(time.Second).Seconds())
+	}
+	return status
+}
+
+// maintenanceRetryAfter is how long refused clients should wait: until the
+// expected end, or a few minutes when that is unknown or past
+func maintenanceRetryAfter(window *models.MaintenanceWindow, now time.Time) time.Duration {
+	if window.ExpectedEnd != nil && window.ExpectedEnd.After(now) {
+		return window.ExpectedEnd.Sub(now).Round(time.Second)
+	}
+	return defaultMaintenanceRetryAfter
+}
+
+// BroadcastMaintenance tells every connected chat session about the
+// maintenance, or that it's over, and returns how many sessions were told
+func BroadcastMaintenance(broadcaster SessionBroadcaster, status *MaintenanceStatus) int {
+	notice := NewMaintenanceNotice(status)
+	sessions := broadcaster.ConnectedSessions()
+	for _, sessionID := range sessions {
+		broadcaster.NotifySession(sessionID, MaintenanceNotification, notice)
+	}
+	return len(sessions)
+}
@@ -0,0 +1,170 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Outbound message channels and categories
+const (
+	MessageChannelEmail = "email"
+	MessageChannelSMS   = "sms"
+
+	MessageCategoryMarketing     = "marketing"
+	MessageCategoryTransactional = "transactional"
+)
+
+// Outbound message statuses
+const (
+	OutboundStatusPending    = "pending"
+	OutboundStatusSuppressed = "suppressed"
+)
+
+// ErrNoMarketingConsent is returned when a marketing message is suppressed
+// because the user hasn't consented to marketing on that channel
+var ErrNoMarketingConsent = errors.New("the user hasn't consented to marketing on this channel")
+
+// OutboundMessageRequest is an email or SMS to send to a user
+type OutboundMessageRequest struct {
+	UserID   uuid.UUID
+	Channel  string // email or sms
+	Category string // marketing or transactional
+	Subject  string // email only
+	Body     string
+}
+
+// DispatchResult reports a bulk send
+type DispatchResult struct {
+	Queued     int `json:"queued"`
+	Suppressed int `json:"suppressed"`
+}
+
+// MessageDispatcher queues emails and SMS for the mailer and SMS gateway.
+// Every marketing send goes through it, so users who haven't consented on a
+// channel never receive marketing there.
+type MessageDispatcher struct {
+	db       *gorm.DB
+	consents *ConsentService
+}
+
+// NewMessageDispatcher creates a new MessageDispatcher
+func NewMessageDispatcher(db *gorm.DB) *MessageDispatcher {
+	return &MessageDispatcher{
+		db:       db,
+		consents: NewConsentService(db),
+	}
+}
+
+// Dispatch queues one message. A marketing message to a user without
+// consent is recorded as suppressed and ErrNoMarketingConsent is returned.
+func (d *MessageDispatcher) Dispatch(ctx context.Context, req OutboundMessageRequest) (*models.OutboundMessage, error) {
+	messages, err := d.dispatch(ctx, []uuid.UUID{req.UserID}, req)
+	if err != nil {
+		return nil, err
+	}
+	if len(messages) == 0 {
+		return nil, ErrUserNotFound
+	}
+	if messages[0].Status == OutboundStatusSuppressed && messages[0].Reason == "no_consent" {
+		return &messages[0], ErrNoMarketingConsent
+	}
+	return &messages[0], nil
+}
+
+// DispatchToUsers queues the same message for several users, skipping
+// marketing to those without consent
+func (d *MessageDispatcher) DispatchToUsers(ctx context.Context, userIDs []uuid.UUID, req OutboundMessageRequest) (*DispatchResult, error) {
+	messages, err := d.dispatch(ctx, userIDs, req)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &DispatchResult{}
+	for _, message := range messages {
+		if message.Status == OutboundStatusSuppressed {
+			result.Suppressed++
+		} else {
+			result.Queued++
+		}
+	}
+	return result, nil
+}
+
+func (d *MessageDispatcher) dispatch(ctx context.Context, userIDs []uuid.UUID, req OutboundMessageRequest) ([]models.OutboundMessage, error) {
+	purpose, err := messagePurpose(req)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(req.Body) == "" {
+		return nil, errors.New("message body is required")
+	}
+
+	var users []models.User
+	if err := d.db.WithContext(ctx).Select("id", "email", "phone", "status").Where("id IN ?", userIDs).Find(&users).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch users: %v", err)
+	}
+	consented := map[uuid.UUID]bool{}
+	if purpose != "" {
+		if consented, err = d.consents.ConsentedUsers(ctx, userIDs, purpose); err != nil {
+			return nil, err
+		}
+	}
+
+	messages := make([]models.OutboundMessage, 0, len(users))
+	for _, user := range users {
+		message := models.OutboundMessage{
+			ID:        uuid.New(),
+			UserID:    user.ID,
+			Channel:   req.Channel,
+			Category:  req.Category,
+			Recipient: user.Email,
+			Subject:   req.Subject,
+			Body:      req.Body,
+			Status:    OutboundStatusPending,
+		}
+		if req.Channel == MessageChannelSMS {
+			message.Recipient = user.Phone
+		}
+
+		switch {
+		case purpose != "" && !consented[user.ID]:
+			message.Status, message.Reason = OutboundStatusSuppressed, "no_consent"
+		case purpose != "" && user.Status != UserStatusActive:
+			message.Status, message.Reason = OutboundStatusSuppressed, "inactive_account"
+		case message.Recipient == "":
+			message.Status, message.Reason = OutboundStatusSuppressed, "no_"+req.Channel+"_address"
+		}
+		messages = append(messages, message)
+	}
+
+	if len(messages) > 0 {
+		if err := d.db.WithContext(ctx).CreateInBatches(&messages, 500).Error; err != nil {
+			return nil, fmt.Errorf("failed to queue messages: %v", err)
+		}
+	}
+	return messages, nil
+}
+
+// messagePurpose returns the consent a message needs, or "" for transactional messages
+func messagePurpose(req OutboundMessageRequest) (string, error) {
+	if req.Channel != MessageChannelEmail && req.Channel != MessageChannelSMS {
+		return "", fmt.Errorf("unknown message channel: %s", req.Channel)
+	}
+	switch req.Category {
+	case MessageCategoryTransactional:
+		return "", nil
+	case MessageCategoryMarketing:
+		if req.Channel == MessageChannelSMS {
+			return ConsentMarketingSMS, nil
+		}
+		return ConsentMarketingEmail, nil
+	default:
+		return "", fmt.Errorf("unknown message category: %s", req.Category)
+	}
+}
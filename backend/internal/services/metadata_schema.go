@@ -0,0 +1,652 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// Metadata attribute types
+const (
+	MetadataString  = "string"
+	MetadataNumber  = "number"
+	MetadataInteger = "integer"
+	MetadataBoolean = "boolean"
+	MetadataArray   = "array"
+)
+
+// ErrInvalidMetadataSchema is returned when an admin saves a metadata schema
+// this store can't check products against
+var ErrInvalidMetadataSchema = errors.New("invalid metadata schema")
+
+// metadataAttributeName is what attribute names look like, so they can be
+// used as filter names and in SQL
+var metadataAttributeName = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// maxMetadataSchemaDepth caps the categories walked up to find inherited
+// schemas, in case the tree has a cycle
+const maxMetadataSchemaDepth = 32
+
+// MetadataSchema is the JSON schema a category's product metadata must
+// match, e.g. electronics requiring wattage and connectivity. It supports a
+// subset of JSON Schema: an object whose properties are strings, numbers,
+// integers, booleans or arrays of those, checked with required, enum,
+// minimum, maximum, minLength, maxLength and pattern. A category's products
+// also match its ancestors' schemas, the nearest category's property winning.
+type MetadataSchema struct {
+	Type                 string                       `json:"type,omitempty"` // "object"
+	Properties           map[string]*MetadataProperty `json:"properties"`
+	Required             []string                     `json:"required,omitempty"`
+	AdditionalProperties *bool                        `json:"additionalProperties,omitempty"` // false refuses metadata keys without a property
+}
+
+// MetadataProperty is one attribute of a metadata schema. The x-unit and
+// x-facet extensions say how it's shown and whether shoppers can filter by it.
+type MetadataProperty struct {
+	Type        string            `json:"type"`
+	Title       string            `json:"title,omitempty"`
+	Description string            `json:"description,omitempty"`
+	Enum        []interface{}     `json:"enum,omitempty"`
+	Minimum     *float64          `json:"minimum,omitempty"`
+	Maximum     *float64          `json:"maximum,omitempty"`
+	MinLength   *int              `json:"minLength,omitempty"`
+	MaxLength   *int              `json:"maxLength,omitempty"`
+	Pattern     string            `json:"pattern,omitempty"`
+	Items       *MetadataProperty `json:"items,omitempty"`   // the elements of an array
+	Unit        string            `json:"x-unit,omitempty"`  // shown after numbers, e.g. W
+	Facet       bool              `json:"x-facet,omitempty"` // offered as a search filter and facet
+
+	pattern *regexp.Regexp
+}
+
+// MetadataViolation is one way product metadata breaks its category's schema
+type MetadataViolation struct {
+	Attribute string `json:"attribute"`
+	Message   string `json:"message"`
+}
+
+// MetadataValidationError is returned when product metadata doesn't match
+// its category's schema
+type MetadataValidationError struct {
+	Violations []MetadataViolation `json:"violations"`
+}
+
+func (e *MetadataValidationError) Error() string {
+	messages := make([]string, len(e.Violations))
+	for i, violation := range e.Violations {
+		messages[i] = violation.Attribute + " " + violation.Message
+	}
+	return "metadata doesn't match the category's schema: " + strings.Join(messages, "; ")
+}
+
+// Label is how the attribute is named to shoppers
+func (p *MetadataProperty) Label(name string) string {
+	if p.Title != "" {
+		return p.Title
+	}
+	return strings.ReplaceAll(name, "_", " ")
+}
+
+// Check validates the schema itself, compiling its patterns
+func (s *MetadataSchema) Check() error {
+	if s.Type != "" && s.Type != "object" {
+		return fmt.Errorf("%w: type must be object", ErrInvalidMetadataSchema)
+	}
+	for name, property := range s.Properties {
+		if !metadataAttributeName.MatchString(name) {
+			return fmt.Errorf("%w: attribute %q must be lowercase letters, digits and underscores", ErrInvalidMetadataSchema, name)
+		}
+		if property == nil {
+			return fmt.Errorf("%w: %s has no definition", ErrInvalidMetadataSchema, name)
+		}
+		if err := property.check(name, false); err != nil {
+			return err
+		}
+	}
+	for _, name := range s.Required {
+		if _, ok := s.Properties[name]; !ok {
+			return fmt.Errorf("%w: required attribute %s isn't a property", ErrInvalidMetadataSchema, name)
+		}
+	}
+	return nil
+}
+
+func (p *MetadataProperty) check(name string, item bool) error {
+	switch p.Type {
+	case MetadataString, MetadataNumber, MetadataInteger, MetadataBoolean:
+		if p.Items != nil {
+			return fmt.Errorf("%w: %s only arrays have items", ErrInvalidMetadataSchema, name)
+		}
+	case MetadataArray:
+		if item {
+			return fmt.Errorf("%w: %s arrays can't hold arrays", ErrInvalidMetadataSchema, name)
+		}
+		if p.Items == nil {
+			return fmt.Errorf("%w: %s needs items", ErrInvalidMetadataSchema, name)
+		}
+		if p.Facet {
+			return fmt.Errorf("%w: %s arrays can't be facets", ErrInvalidMetadataSchema, name)
+		}
+		if err := p.Items.check(name+" items", true); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("%w: %s type must be string, number, integer, boolean or array", ErrInvalidMetadataSchema, name)
+	}
+
+	if p.Pattern != "" {
+		pattern, err := regexp.Compile(p.Pattern)
+		if err != nil {
+			return fmt.Errorf("%w: %s pattern: %v", ErrInvalidMetadataSchema, name, err)
+		}
+		p.pattern = pattern
+	}
+	if p.Minimum != nil && p.Maximum != nil && *p.Minimum > *p.Maximum {
+		return fmt.Errorf("%w: %s minimum is above its maximum", ErrInvalidMetadataSchema, name)
+	}
+	if p.MinLength != nil && p.MaxLength != nil && *p.MinLength > *p.MaxLength {
+		return fmt.Errorf("%w: %s minLength is above its maxLength", ErrInvalidMetadataSchema, name)
+	}
+	for _, value := range p.Enum {
+		if message := p.checkType(value); message != "" {
+			return fmt.Errorf("%w: %s enum value %v %s", ErrInvalidMetadataSchema, name, value, message)
+		}
+	}
+	return nil
+}
+
+// Validate returns the ways metadata breaks the schema
+func (s *MetadataSchema) Validate(metadata map[string]interface{}) []MetadataViolation {
+	var violations []MetadataViolation
+	for _, name := range s.Required {
+		if value, ok := metadata[name]; !ok || value == nil {
+			violations = append(violations, MetadataViolation{Attribute: name, Message: "is required"})
+		}
+	}
+
+	names := make([]string, 0, len(metadata))
+	for name := range metadata {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := metadata[name]
+		property, ok := s.Properties[name]
+		if !ok {
+			if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+				violations = append(violations, MetadataViolation{Attribute: name, Message: "isn't an attribute of this category"})
+			}
+			continue
+		}
+		if value == nil {
+			continue
+		}
+		violations = append(violations, property.validate(name, value)...)
+	}
+	return violations
+}
+
+func (p *MetadataProperty) validate(name string, value interface{}) []MetadataViolation {
+	fail := func(format string, args ...interface{}) []MetadataViolation {
+		return []MetadataViolation{{Attribute: name, Message: fmt.Sprintf(format, args...)}}
+	}
+	if message := p.checkType(value); message != "" {
+		return fail("%s", message)
+	}
+
+	switch p.Type {
+	case MetadataArray:
+		var violations []MetadataViolation
+		for i, item := range value.([]interface{}) {
+			violations = append(violations, p.Items.validate(fmt.Sprintf("%s[%d]", name, i), item)...)
+		}
+		return violations
+	case MetadataString:
+		text := value.(string)
+		length := len([]rune(text))
+		if p.MinLength != nil && length < *p.MinLength {
+			return fail("must be at least %d characters", *p.MinLength)
+		}
+		if p.MaxLength != nil && length > *p.MaxLength {
+			return fail("must be at most %d characters", *p.MaxLength)
+		}
+		if p.pattern != nil && !p.pattern.MatchString(text) {
+			return fail("must match %s", p.Pattern)
+		}
+	case MetadataNumber, MetadataInteger:
+		number, _ := metadataFloat(value)
+		if p.Minimum != nil && number < *p.Minimum {
+			return fail("must be at least %s", formatMetadataNumber(*p.Minimum))
+		}
+		if p.Maximum != nil && number > *p.Maximum {
+			return fail("must be at most %s", formatMetadataNumber(*p.Maximum))
+		}
+	}
+
+	if len(p.Enum) > 0 && !p.allowed(value) {
+		options := make([]string, len(p.Enum))
+		for i, option := range p.Enum {
+			options[i] = fmt.Sprint(option)
+		}
+		return fail("must be one of %s", strings.Join(options, ", "))
+	}
+	return nil
+}
+
+// checkType describes how value isn't of the property's type, or is empty
+// when it is
+func (p *MetadataProperty) checkType(value interface{}) string {
+	switch p.Type {
+	case MetadataString:
+		if _, ok := value.(string); !ok {
+			return "must be text"
+		}
+	case MetadataNumber:
+		if _, ok := metadataFloat(value); !ok {
+			return "must be a number"
+		}
+	case MetadataInteger:
+		if number, ok := metadataFloat(value); !ok || number != math.Trunc(number) {
+			return "must be a whole number"
+		}
+	case MetadataBoolean:
+		if _, ok := value.(bool); !ok {
+			return "must be true or false"
+		}
+	case MetadataArray:
+		if _, ok := value.([]interface{}); !ok {
+			return "must be a list"
+		}
+	}
+	return ""
+}
+
+func (p *MetadataProperty) allowed(value interface{}) bool {
+	for _, option := range p.Enum {
+		if a, ok := metadataFloat(option); ok {
+			if b, ok := metadataFloat(value); ok && a == b {
+				return true
+			}
+			continue
+		}
+		if option == value {
+			return true
+		}
+	}
+	return false
+}
+
+// metadataFloat reads a JSON number
+func metadataFloat(value interface{}) (float64, bool) {
+	switch number := value.(type) {
+	case float64:
+		return number, true
+	case float32:
+		return float64(number), true
+	case int:
+		return float64(number), true
+	case int64:
+		return float64(number), true
+	case json.Number:
+		f, err := number.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func formatMetadataNumber(number float64) string {
+	return strconv.FormatFloat(number, 'f', -1, 64)
+}
+
+// parseMetadataSchema reads a category's stored schema, nil when it has none
+func parseMetadataSchema(raw datatypes.JSON) (*MetadataSchema, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	var schema MetadataSchema
+	if err := json.Unmarshal(raw, &schema); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidMetadataSchema, err)
+	}
+	if err := schema.Check(); err != nil {
+		return nil, err
+	}
+	return &schema, nil
+}
+
+// mergeMetadataSchemas returns the schema of a category with schema child
+// under one with schema parent
+func mergeMetadataSchemas(parent, child *MetadataSchema) *MetadataSchema {
+	if parent == nil {
+		return child
+	}
+	if child == nil {
+		return parent
+	}
+	merged := &MetadataSchema{
+		Type:                 "object",
+		Properties:           make(map[string]*MetadataProperty, len(parent.Properties)+len(child.Properties)),
+		AdditionalProperties: parent.AdditionalProperties,
+	}
+	for name, property := range parent.Properties {
+		merged.Properties[name] = property
+	}
+	for name, property := range child.Properties {
+		merged.Properties[name] = property
+	}
+	if child.AdditionalProperties != nil {
+		merged.AdditionalProperties = child.AdditionalProperties
+	}
+	required := map[string]bool{}
+	for _, name := range append(append([]string{}, parent.Required...), child.Required...) {
+		if !required[name] {
+			required[name] = true
+			merged.Required = append(merged.Required, name)
+		}
+	}
+	return merged
+}
+
+// categoryMetadataSchema returns the schema a category's products match:
+// its own merged over its ancestors', or nil when none of them has one
+func categoryMetadataSchema(db *gorm.DB, categoryID uuid.UUID) (*MetadataSchema, error) {
+	var chain []*MetadataSchema
+	next := &categoryID
+	for depth := 0; next != nil && depth < maxMetadataSchemaDepth; depth++ {
+		var category models.Category
+		err := db.Select("id", "parent_id", "metadata_schema").First(&category, "id = ?", *next).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get category schema: %v", err)
+		}
+		schema, err := parseMetadataSchema(category.MetadataSchema)
+		if err != nil {
+			return nil, err
+		}
+		if schema != nil {
+			chain = append(chain, schema)
+		}
+		next = category.ParentID
+	}
+
+	var effective *MetadataSchema
+	for i := len(chain) - 1; i >= 0; i-- {
+		effective = mergeMetadataSchemas(effective, chain[i])
+	}
+	return effective, nil
+}
+
+// validateProductMetadata checks a product's metadata against its
+// category's schema
+func validateProductMetadata(db *gorm.DB, categoryID uuid.UUID, metadata map[string]interface{}) error {
+	schema, err := categoryMetadataSchema(db, categoryID)
+	if err != nil || schema == nil {
+		return err
+	}
+	if violations := schema.Validate(metadata); len(violations) > 0 {
+		return &MetadataValidationError{Violations: violations}
+	}
+	return nil
+}
+
+// categorySchemas is every category's own metadata schema, for working out
+// many categories' schemas at once
+type categorySchemas struct {
+	categories []models.Category
+	parents    map[uuid.UUID]*uuid.UUID
+	own        map[uuid.UUID]*MetadataSchema
+	effective  map[uuid.UUID]*MetadataSchema
+}
+
+// loadCategorySchemas reads the category tree. Categories with a schema
+// that can't be read are treated as having none.
+func loadCategorySchemas(db *gorm.DB) (*categorySchemas, error) {
+	var categories []models.Category
+	if err := db.Select("id", "parent_id", "metadata_schema").Find(&categories).Error; err != nil {
+		return nil, fmt.Errorf("failed to get categories: %v", err)
+	}
+	schemas := &categorySchemas{
+		categories: categories,
+		parents:    make(map[uuid.UUID]*uuid.UUID, len(categories)),
+		own:        make(map[uuid.UUID]*MetadataSchema, len(categories)),
+		effective:  make(map[uuid.UUID]*MetadataSchema, len(categories)),
+	}
+	for _, category := range categories {
+		schemas.parents[category.ID] = category.ParentID
+		if schema, err := parseMetadataSchema(category.MetadataSchema); err == nil {
+			schemas.own[category.ID] = schema
+		}
+	}
+	return schemas, nil
+}
+
+// schema returns the schema a category's products match
+func (c *categorySchemas) schema(id uuid.UUID) *MetadataSchema {
+	if schema, ok := c.effective[id]; ok {
+		return schema
+	}
+	var chain []*MetadataSchema
+	next := &id
+	for depth := 0; next != nil && depth < maxMetadataSchemaDepth; depth++ {
+		if _, ok := c.parents[*next]; !ok {
+			break
+		}
+		if schema := c.own[*next]; schema != nil {
+			chain = append(chain, schema)
+		}
+		next = c.parents[*next]
+	}
+	var effective *MetadataSchema
+	for i := len(chain) - 1; i >= 0; i-- {
+		effective = mergeMetadataSchemas(effective, chain[i])
+	}
+	c.effective[id] = effective
+	return effective
+}
+
+// subtree returns a category and all its descendants
+func (c *categorySchemas) subtree(id uuid.UUID) []uuid.UUID {
+	var ids []uuid.UUID
+	for categoryID := range categoryTree(c.categories, id) {
+		ids = append(ids, categoryID)
+	}
+	return ids
+}
+
+// ProductMetadataViolations lists how a product's metadata breaks its
+// category's schema
+type ProductMetadataViolations struct {
+	ProductID  uuid.UUID           `json:"product_id"`
+	Name       string              `json:"name"`
+	SKU        string              `json:"sku"`
+	Violations []MetadataViolation `json:"violations"`
+}
+
+// CategoryMetadataSchema is a category's own metadata schema, the one its
+// products match once its ancestors' are merged in, and the products that
+// don't match it
+type CategoryMetadataSchema struct {
+	CategoryID uuid.UUID                   `json:"category_id"`
+	Schema     *MetadataSchema             `json:"schema"`
+	Effective  *MetadataSchema             `json:"effective"`
+	Products   []ProductMetadataViolations `json:"products"` // products in the category or below it that need fixing
+}
+
+// CategoryMetadataSchemaRequest sets a category's metadata schema; a null
+// schema removes it
+type CategoryMetadataSchemaRequest struct {
+	Schema *MetadataSchema `json:"schema"`
+}
+
+// GetCategoryMetadataSchema returns a category's metadata schema
+func (s *AdminProductService) GetCategoryMetadataSchema(ctx context.Context, categoryID uuid.UUID) (*CategoryMetadataSchema, error) {
+	db := s.db.WithContext(ctx)
+	category, err := findCategory(db, categoryID)
+	if err != nil {
+		return nil, err
+	}
+	schema, err := parseMetadataSchema(category.MetadataSchema)
+	if err != nil {
+		return nil, err
+	}
+	return s.categoryMetadataSchema(db, categoryID, schema)
+}
+
+// SetCategoryMetadataSchema replaces a category's metadata schema, nil
+// removing it. Products already in the category keep their metadata; the
+// ones that don't match are listed so admins can fix them, and can't be
+// saved until they are.
+func (s *AdminProductService) SetCategoryMetadataSchema(ctx context.Context, categoryID uuid.UUID, schema *MetadataSchema) (*CategoryMetadataSchema, error) {
+	var raw datatypes.JSON
+	if schema != nil {
+		if schema.Type == "" {
+			schema.Type = "object"
+		}
+		if err := schema.Check(); err != nil {
+			return nil, err
+		}
+		encoded, err := json.Marshal(schema)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode metadata schema: %v", err)
+		}
+		raw = datatypes.JSON(encoded)
+	}
+
+	db := s.db.WithContext(ctx)
+	if _, err := findCategory(db, categoryID); err != nil {
+		return nil, err
+	}
+	if err := db.Model(&models.Category{}).Where("id = ?", categoryID).
+		Updates(map[string]interface{}{"metadata_schema": raw, "updated_at": time.Now()}).Error; err != nil {
+		return nil, fmt.Errorf("failed to save metadata schema: %v", err)
+	}
+	return s.categoryMetadataSchema(db, categoryID, schema)
+}
+
+// categoryMetadataSchema describes a category's schema and the products in
+// its subtree that don't match theirs
+func (s *AdminProductService) categoryMetadataSchema(db *gorm.DB, categoryID uuid.UUID, schema *MetadataSchema) (*CategoryMetadataSchema, error) {
+	schemas, err := loadCategorySchemas(db)
+	if err != nil {
+		return nil, err
+	}
+	result := &CategoryMetadataSchema{
+		CategoryID: categoryID,
+		Schema:     schema,
+		Effective:  schemas.schema(categoryID),
+		Products:   []ProductMetadataViolations{},
+	}
+
+	var products []models.Product
+	if err := db.Select("id", "name", "sku", "category_id", "metadata").
+		Where("category_id IN ?", schemas.subtree(categoryID)).
+		Order("name ASC").
+		Find(&products).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch products: %v", err)
+	}
+	for _, product := range products {
+		productSchema := schemas.schema(product.CategoryID)
+		if productSchema == nil {
+			continue
+		}
+		if violations := productSchema.Validate(productMetadata(product)); len(violations) > 0 {
+			result.Products = append(result.Products, ProductMetadataViolations{
+				ProductID:  product.ID,
+				Name:       product.Name,
+				SKU:        product.SKU,
+				Violations: violations,
+			})
+		}
+	}
+	return result, nil
+}
+
+// ProductAttribute is a product's value for one of its category's schema
+// attributes
+type ProductAttribute struct {
+	Name  string      `json:"name"`
+	Label string      `json:"label"`
+	Value interface{} `json:"value"`
+	Unit  string      `json:"unit,omitempty"`
+}
+
+// Display is the attribute's value as shown to shoppers, e.g. 60 W
+func (a ProductAttribute) Display() string {
+	var text string
+	switch value := a.Value.(type) {
+	case []interface{}:
+		items := make([]string, len(value))
+		for i, item := range value {
+			items[i] = fmt.Sprint(item)
+		}
+		text = strings.Join(items, ", ")
+	case bool:
+		text = "no"
+		if value {
+			text = "yes"
+		}
+	default:
+		if number, ok := metadataFloat(value); ok {
+			text = formatMetadataNumber(number)
+		} else {
+			text = fmt.Sprint(value)
+		}
+	}
+	if a.Unit != "" {
+		text += " " + a.Unit
+	}
+	return text
+}
+
+// ProductAttributes returns the products' values for their categories'
+// schema attributes that match the schema, by product. Metadata keys
+// outside the schemas aren't attributes.
+func (s *ProductService) ProductAttributes(ctx context.Context, products []models.Product) (map[uuid.UUID][]ProductAttribute, error) {
+	attributes := make(map[uuid.UUID][]ProductAttribute)
+	if len(products) == 0 {
+		return attributes, nil
+	}
+	schemas, err := loadCategorySchemas(s.db.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	for _, product := range products {
+		schema := schemas.schema(product.CategoryID)
+		if schema == nil {
+			continue
+		}
+		metadata := productMetadata(product)
+		names := make([]string, 0, len(schema.Properties))
+		for name := range schema.Properties {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			property := schema.Properties[name]
+			value, ok := metadata[name]
+			if !ok || value == nil || len(property.validate(name, value)) > 0 {
+				continue
+			}
+			attributes[product.ID] = append(attributes[product.ID], ProductAttribute{
+				Name:  name,
+				Label: property.Label(name),
+				Value: value,
+				Unit:  property.Unit,
+			})
+		}
+	}
+	return attributes, nil
+}
@@ -0,0 +1,101 @@
+package services
+
+import (
+	"os"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// Model tiers chosen by ModelRouter
+const (
+	ModelTierEconomy  = "economy"
+	ModelTierPremium  = "premium"
+	ModelTierFallback = "fallback"
+)
+
+// Chat intents recognised by ModelRouter
+const (
+	ChatIntentGreeting      = "greeting"
+	ChatIntentOrderStatus   = "order_status"
+	ChatIntentCartSummary   = "cart_summary"
+	ChatIntentProductAdvice = "product_advice"
+)
+
+// ModelRoute is the model selected for a chat turn
+type ModelRoute struct {
+	Intent string `json:"intent"`
+	Tier   string `json:"tier"`
+	Model  string `json:"model"`
+}
+
+// ModelRouter sends simple intents to a cheaper model and everything else to
+// the configured premium model
+type ModelRouter struct {
+	economyModel string
+}
+
+// NewModelRouter creates a new ModelRouter. An empty economyModel disables routing.
+func NewModelRouter(economyModel string) *ModelRouter {
+	return &ModelRouter{
+		economyModel: economyModel,
+	}
+}
+
+// ModelRouterFromEnv creates a ModelRouter using OPENAI_ECONOMY_MODEL, or
+// gpt-4o-mini when it is unset. Set it to "off" to disable routing.
+func ModelRouterFromEnv() *ModelRouter {
+	model := os.Getenv("OPENAI_ECONOMY_MODEL")
+	switch model {
+	case "":
+		model = openai.GPT4oMini
+	case "off":
+		model = ""
+	}
+	return NewModelRouter(model)
+}
+
+// Route picks the model for a message. Session overrides set by an admin are
+// always honoured so debugging sees the exact model requested.
+func (r *ModelRouter) Route(message string, config LLMConfig) ModelRoute {
+	intent := classifySimpleIntent(message)
+	route := ModelRoute{
+		Intent: intent,
+		Tier:   ModelTierPremium,
+		Model:  config.Model,
+	}
+
+	if intent != ChatIntentProductAdvice && r.economyModel != "" && config.Source != LLMConfigSourceSessionOverride {
+		route.Tier = ModelTierEconomy
+		route.Model = r.economyModel
+	}
+	return route
+}
+
+// classifySimpleIntent recognises greetings, order status and cart questions;
+// anything else is treated as product advice
+func classifySimpleIntent(message string) string {
+	message = strings.ToLower(message)
+	words := wordPattern.FindAllString(message, -1)
+	has := func(candidates ...string) bool {
+		for _, w := range words {
+			for _, c := range candidates {
+				if w == c {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	switch {
+	case isGreeting(message):
+		return ChatIntentGreeting
+	case has("order", "orders", "package", "shipment") && has("status", "where", "track", "tracking", "shipped", "arrive", "delivery", "delivered"):
+		return ChatIntentOrderStatus
+	case has("cart", "basket") && !has("add", "remove", "recommend", "suggest"):
+		return ChatIntentCartSummary
+	default:
+		return ChatIntentProductAdvice
+	}
+}
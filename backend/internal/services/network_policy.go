@@ -0,0 +1,348 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Network policies
+const (
+	NetworkPolicyAdmin   = "admin"
+	NetworkPolicyWebhook = "webhook"
+)
+
+// Reasons a network policy blocks a request
+const (
+	BlockReasonDenylisted        = "denylisted"
+	BlockReasonNotAllowlisted    = "not_allowlisted"
+	BlockReasonClientCertificate = "client_certificate"
+)
+
+// blockedAuditInterval is how often the same address is recorded for the
+// same policy, so a scanner hammering the admin API can't flood the audit log
+const blockedAuditInterval = time.Minute
+
+// maxBlockedRequests caps the blocked requests listed at once
+const maxBlockedRequests = 500
+
+// NetworkPolicy restricts who may call a group of routes: by client address,
+// and optionally by a client certificate signed by a partner's CA (mTLS)
+type NetworkPolicy struct {
+	Name        string
+	Allow       []*net.IPNet   // empty allows every address that isn't denied
+	Deny        []*net.IPNet   // takes precedence over Allow
+	ClientCAs   *x509.CertPool // nil when no client certificate is required
+	ClientNames []string       // accepted certificate common or DNS names; empty accepts any the CAs signed
+
+	// CertHeader is the header a TLS-terminating proxy forwards the client
+	// certificate in, URL-escaped PEM as nginx's $ssl_client_escaped_cert.
+	// It is only read from the Proxies, which must overwrite it.
+	CertHeader string
+	Proxies    []*net.IPNet
+}
+
+// NetworkPolicyFromEnv reads the policy for name from <PREFIX>_IP_ALLOWLIST,
+// <PREFIX>_IP_DENYLIST, <PREFIX>_CLIENT_CA_FILE and <PREFIX>_CLIENT_CERT_NAMES,
+// plus the shared CLIENT_CERT_HEADER and TRUSTED_PROXIES. Invalid settings
+// are errors rather than ignored, so a typo can't open the admin API.
+func NetworkPolicyFromEnv(name, prefix string) (*NetworkPolicy, error) {
+	policy := &NetworkPolicy{
+		Name:        name,
+		ClientNames: splitList(os.Getenv(prefix + "_CLIENT_CERT_NAMES")),
+		CertHeader:  os.Getenv("CLIENT_CERT_HEADER"),
+	}
+
+	var err error
+	if policy.Allow, err = ParseIPNets(os.Getenv(prefix + "_IP_ALLOWLIST")); err != nil {
+		return nil, fmt.Errorf("invalid %s_IP_ALLOWLIST: %v", prefix, err)
+	}
+	if policy.Deny, err = ParseIPNets(os.Getenv(prefix + "_IP_DENYLIST")); err != nil {
+		return nil, fmt.Errorf("invalid %s_IP_DENYLIST: %v", prefix, err)
+	}
+	if policy.Proxies, err = ParseIPNets(os.Getenv("TRUSTED_PROXIES")); err != nil {
+		return nil, fmt.Errorf("invalid TRUSTED_PROXIES: %v", err)
+	}
+
+	if caFile := os.Getenv(prefix + "_CLIENT_CA_FILE"); caFile != "" {
+		data, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s_CLIENT_CA_FILE: %v", prefix, err)
+		}
+		policy.ClientCAs = x509.NewCertPool()
+		if !policy.ClientCAs.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("%s_CLIENT_CA_FILE has no PEM certificates", prefix)
+		}
+	}
+	return policy, nil
+}
+
+// ParseIPNets parses comma separated addresses and CIDR ranges
+func ParseIPNets(value string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, entry := range splitList(value) {
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("%q is not an address or CIDR range", entry)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an address or CIDR range", entry)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+// splitList splits a comma separated setting, dropping blank entries
+func splitList(value string) []string {
+	var entries []string
+	for _, entry := range strings.Split(value, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// containsIP reports whether any of nets contains ip
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Restricted reports whether the policy restricts anything at all
+func (p *NetworkPolicy) Restricted() bool {
+	return len(p.Allow) > 0 || len(p.Deny) > 0 || p.ClientCAs != nil
+}
+
+// Check returns why a request from clientIP is refused, with details for
+// the audit log, or "" when it's let through
+func (p *NetworkPolicy) Check(r *http.Request, clientIP string) (string, string) {
+	ip := net.ParseIP(clientIP)
+	if ip != nil && containsIP(p.Deny, ip) {
+		return BlockReasonDenylisted, ""
+	}
+	if len(p.Allow) > 0 && (ip == nil || !containsIP(p.Allow, ip)) {
+		return BlockReasonNotAllowlisted, ""
+	}
+	if p.ClientCAs != nil {
+		if err := p.verifyClientCertificate(r); err != nil {
+			return BlockReasonClientCertificate, err.Error()
+		}
+	}
+	return "", ""
+}
+
+// NetworkPolicySummary describes a policy for admins
+type NetworkPolicySummary struct {
+	Name              string   `json:"name"`
+	Allow             []string `json:"allow"`
+	Deny              []string `json:"deny"`
+	ClientCertificate bool     `json:"client_certificate_required"`
+	ClientNames       []string `json:"client_names,omitempty"`
+}
+
+// Summary describes the policy
+func (p *NetworkPolicy) Summary() NetworkPolicySummary {
+	summary := NetworkPolicySummary{
+		Name:              p.Name,
+		Allow:             []string{},
+		Deny:              []string{},
+		ClientCertificate: p.ClientCAs != nil,
+		ClientNames:       p.ClientNames,
+	}
+	for _, n := range p.Allow {
+		summary.Allow = append(summary.Allow, n.String())
+	}
+	for _, n := range p.Deny {
+		summary.Deny = append(summary.Deny, n.String())
+	}
+	return summary
+}
+
+// verifyClientCertificate checks the request's client certificate chains to
+// the policy's CAs and, when names are configured, carries one of them
+func (p *NetworkPolicy) verifyClientCertificate(r *http.Request) error {
+	certs, err := p.clientCertificates(r)
+	if err != nil {
+		return err
+	}
+	if len(certs) == 0 {
+		return fmt.Errorf("no client certificate")
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	leaf := certs[0]
+	if _, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         p.ClientCAs,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}); err != nil {
+		return fmt.Errorf("client certificate not trusted: %v", err)
+	}
+
+	if len(p.ClientNames) == 0 {
+		return nil
+	}
+	for _, name := range p.ClientNames {
+		if leaf.Subject.CommonName == name {
+			return nil
+		}
+		for _, dnsName := range leaf.DNSNames {
+			if dnsName == name {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("client certificate %q is not an accepted partner", leaf.Subject.CommonName)
+}
+
+// clientCertificates returns the certificates presented over TLS to this
+// server or, behind a trusted proxy, forwarded in CertHeader
+func (p *NetworkPolicy) clientCertificates(r *http.Request) ([]*x509.Certificate, error) {
+	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		return r.TLS.PeerCertificates, nil
+	}
+	if p.CertHeader == "" || r.Header.Get(p.CertHeader) == "" {
+		return nil, nil
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if peer := net.ParseIP(host); peer == nil || !containsIP(p.Proxies, peer) {
+		return nil, fmt.Errorf("client certificate header from untrusted address %s", host)
+	}
+
+	data, err := url.PathUnescape(r.Header.Get(p.CertHeader))
+	if err != nil {
+		return nil, fmt.Errorf("malformed client certificate header")
+	}
+	var certs []*x509.Certificate
+	rest := []byte(data)
+	for {
+		var block *pem.Block
+		if block, rest = pem.Decode(rest); block == nil {
+			break
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("malformed client certificate: %v", err)
+		}
+		certs = append(certs, cert)
+	}
+	return certs, nil
+}
+
+// BlockedAttempt describes a refused request for the audit log
+type BlockedAttempt struct {
+	Policy    string
+	Reason    string
+	Detail    string
+	IPAddress string
+	Method    string
+	Path      string
+	UserAgent string
+}
+
+// NetworkPolicyService keeps the audit log of requests refused by network
+// policies
+type NetworkPolicyService struct {
+	db *gorm.DB
+
+	mu         sync.Mutex
+	lastLogged map[string]time.Time // policy and address -> last audit record
+}
+
+// NewNetworkPolicyService creates a new NetworkPolicyService
+func NewNetworkPolicyService(db *gorm.DB) *NetworkPolicyService {
+	return &NetworkPolicyService{
+		db:         db,
+		lastLogged: map[string]time.Time{},
+	}
+}
+
+// RecordBlocked writes a refused request to the audit log. Repeats from the
+// same address against the same policy are recorded once a minute. Returns
+// whether a record was written.
+func (s *NetworkPolicyService) RecordBlocked(ctx context.Context, req BlockedAttempt, now time.Time) bool {
+	key := req.Policy + "|" + req.IPAddress
+	s.mu.Lock()
+	if last, ok := s.lastLogged[key]; ok && now.Sub(last) < blockedAuditInterval {
+		s.mu.Unlock()
+		return false
+	}
+	s.lastLogged[key] = now
+	if len(s.lastLogged) > 10000 {
+		for k, last := range s.lastLogged {
+			if now.Sub(last) >= blockedAuditInterval {
+				delete(s.lastLogged, k)
+			}
+		}
+	}
+	s.mu.Unlock()
+
+	log.Printf("Network policy %s blocked %s %s from %s: %s %s", req.Policy, req.Method, req.Path, req.IPAddress, req.Reason, req.Detail)
+	record := &models.BlockedRequest{
+		ID:        uuid.New(),
+		Policy:    req.Policy,
+		Reason:    req.Reason,
+		Detail:    truncateRunes(req.Detail, 255),
+		IPAddress: req.IPAddress,
+		Method:    req.Method,
+		Path:      truncateRunes(req.Path, 255),
+		UserAgent: truncateRunes(req.UserAgent, 255),
+		CreatedAt: now,
+	}
+	if err := s.db.WithContext(ctx).Create(record).Error; err != nil {
+		log.Printf("Failed to record blocked request: %v", err)
+	}
+	return true
+}
+
+// BlockedRequests lists the refused requests since a time, newest first,
+// optionally for one policy
+func (s *NetworkPolicyService) BlockedRequests(ctx context.Context, policy string, since time.Time, limit int) ([]models.BlockedRequest, error) {
+	if limit <= 0 || limit > maxBlockedRequests {
+		limit = maxBlockedRequests
+	}
+	query := s.db.WithContext(ctx).Where("created_at >= ?", since)
+	if policy != "" {
+		query = query.Where("policy = ?", policy)
+	}
+	blocked := []models.BlockedRequest{}
+	if err := query.Order("created_at DESC").Limit(limit).Find(&blocked).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch blocked requests: %v", err)
+	}
+	return blocked, nil
+}
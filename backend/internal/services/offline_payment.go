@@ -0,0 +1,220 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Offline payment methods
+const (
+	PaymentMethodCOD          = "cod"
+	PaymentMethodBankTransfer = "bank_transfer"
+)
+
+// PaymentProviderOffline is the payment provider recorded on orders paid offline
+const PaymentProviderOffline = "offline"
+
+// OrderStatusAwaitingPayment is the status of an offline order until it is paid
+const OrderStatusAwaitingPayment = "awaiting_payment"
+
+// OfflinePaymentMethod is a payment settled outside the store, such as cash on
+// delivery. Orders hold their stock for ReservationTTL while awaiting payment.
+type OfflinePaymentMethod struct {
+	ID             string
+	Name           string
+	Description    string
+	Instructions   string
+	ReservationTTL time.Duration
+}
+
+// OfflinePaymentConfig lists the enabled offline payment methods by ID
+type OfflinePaymentConfig struct {
+	Methods       map[string]OfflinePaymentMethod
+	SweepInterval time.Duration
+}
+
+// OfflinePaymentConfigFromEnv enables the methods in OFFLINE_PAYMENT_METHODS
+// (e.g. "cod,bank_transfer"; none by default). Stock is held for
+// COD_RESERVATION_HOURS (336) or BANK_TRANSFER_RESERVATION_HOURS (72), and
+// BANK_TRANSFER_INSTRUCTIONS is shown to the shopper.
+func OfflinePaymentConfigFromEnv() OfflinePaymentConfig {
+	config := OfflinePaymentConfig{
+		Methods:       make(map[string]OfflinePaymentMethod),
+		SweepInterval: time.Duration(envInt("OFFLINE_PAYMENT_SWEEP_MINUTES", 30)) * time.Minute,
+	}
+
+	for _, id := range strings.Split(os.Getenv("OFFLINE_PAYMENT_METHODS"), ",") {
+		switch strings.TrimSpace(strings.ToLower(id)) {
+		case PaymentMethodCOD:
+			config.Methods[PaymentMethodCOD] = OfflinePaymentMethod{
+				ID:             PaymentMethodCOD,
+				Name:           "Cash on Delivery",
+				Description:    "Pay the courier when your order arrives",
+				Instructions:   "Please have the exact amount ready when your order is delivered.",
+				ReservationTTL: time.Duration(envInt("COD_RESERVATION_HOURS", 336)) * time.Hour,
+			}
+		case PaymentMethodBankTransfer:
+			instructions := os.Getenv("BANK_TRANSFER_INSTRUCTIONS")
+			if instructions == "" {
+				instructions = "Transfer the order total to our bank account using your order number as the reference."
+			}
+			config.Methods[PaymentMethodBankTransfer] = OfflinePaymentMethod{
+				ID:             PaymentMethodBankTransfer,
+				Name:           "Bank Transfer",
+				Description:    "Pay by bank transfer; your order ships once the money arrives",
+				Instructions:   instructions,
+				ReservationTTL: time.Duration(envInt("BANK_TRANSFER_RESERVATION_HOURS", 72)) * time.Hour,
+			}
+		}
+	}
+	return config
+}
+
+// Method returns the enabled offline method with the given ID
+func (c OfflinePaymentConfig) Method(id string) (OfflinePaymentMethod, bool) {
+	method, ok := c.Methods[id]
+	return method, ok
+}
+
+// PaymentMethods lists the enabled offline methods for GET /payments/methods
+func (c OfflinePaymentConfig) PaymentMethods() []PaymentMethodInfo {
+	methods := []PaymentMethodInfo{}
+	for _, id := range []string{PaymentMethodCOD, PaymentMethodBankTransfer} {
+		method, ok := c.Methods[id]
+		if !ok {
+			continue
+		}
+		methods = append(methods, PaymentMethodInfo{
+			ID:           method.ID,
+			Name:         method.Name,
+			Description:  method.Description,
+			Instructions: method.Instructions,
+			Provider:     PaymentProviderOffline,
+			Enabled:      true,
+		})
+	}
+	return methods
+}
+
+// isOfflinePaymentMethod reports whether id names an offline method, enabled or not
+func isOfflinePaymentMethod(id string) bool {
+	return id == PaymentMethodCOD || id == PaymentMethodBankTransfer
+}
+
+// MarkOrderPaidRequest records an offline payment received by the store
+type MarkOrderPaidRequest struct {
+	Reference string `json:"reference"` // e.g. the bank transfer or courier receipt reference
+}
+
+// OfflinePaymentInstructions returns how to pay an order awaiting offline
+// payment, or "" for other orders
+func (s *OrderService) OfflinePaymentInstructions(order *Order) string {
+	if order.PaymentProvider != PaymentProviderOffline || order.PaymentStatus == "paid" {
+		return ""
+	}
+	method, ok := s.offline.Method(order.PaymentMethod)
+	if !ok {
+		return ""
+	}
+	return method.Instructions
+}
+
+// MarkOrderPaid records that an order awaiting offline payment has been paid.
+// Orders still awaiting payment move on to fulfillment as pending.
+func (s *OrderService) MarkOrderPaid(ctx context.Context, orderID uuid.UUID, req MarkOrderPaidRequest) (*Order, error) {
+	order, err := s.GetOrderByID(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if order.PaymentProvider != PaymentProviderOffline {
+		return nil, errors.New("only orders paid offline can be marked as paid")
+	}
+	if order.PaymentStatus == "paid" {
+		return nil, errors.New("order is already paid")
+	}
+	if order.Status == "cancelled" {
+		return nil, errors.New("cannot mark a cancelled order as paid")
+	}
+
+	updates := map[string]interface{}{
+		"payment_status":    "paid",
+		"payment_reference": req.Reference,
+		"payment_due_at":    nil,
+		"updated_at":        time.Now(),
+	}
+	if order.Status == OrderStatusAwaitingPayment {
+		updates["status"] = "pending"
+	}
+	if err := s.db.WithContext(ctx).Model(&Order{}).Where("id = ?", orderID).Updates(updates).Error; err != nil {
+		return nil, fmt.Errorf("failed to mark order as paid: %v", err)
+	}
+
+	order, err = s.GetOrderByID(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.finance.RecordCharge(ctx, order); err != nil {
+		log.Printf("Failed to record charge for order %s: %v", order.ID, err)
+	}
+	if err := s.exports.Enqueue(ctx, order.ID); err != nil {
+		log.Printf("Failed to schedule export of order %s: %v", order.ID, err)
+	}
+	return order, nil
+}
+
+// ExpireUnpaidOrders cancels orders still awaiting offline payment after their
+// payment deadline, releasing their stock. It returns how many were cancelled.
+func (s *OrderService) ExpireUnpaidOrders(ctx context.Context, now time.Time) (int, error) {
+	var orderIDs []uuid.UUID
+	if err := s.db.WithContext(ctx).Model(&Order{}).
+		Where("status = ? AND payment_status <> ? AND payment_due_at <= ?", OrderStatusAwaitingPayment, "paid", now).
+		Pluck("id", &orderIDs).Error; err != nil {
+		return 0, fmt.Errorf("failed to find unpaid orders: %v", err)
+	}
+
+	for _, orderID := range orderIDs {
+		if _, err := s.CancelOrder(ctx, orderID); err != nil {
+			return 0, err
+		}
+		if _, err := s.UpdatePaymentStatus(ctx, orderID, "expired", ""); err != nil {
+			return 0, err
+		}
+	}
+	return len(orderIDs), nil
+}
+
+// ScheduleOfflinePaymentExpiry runs ExpireUnpaidOrders every SweepInterval
+// until ctx is done
+func (s *OrderService) ScheduleOfflinePaymentExpiry(ctx context.Context) {
+	if len(s.offline.Methods) == 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(s.offline.SweepInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				expired, err := s.ExpireUnpaidOrders(ctx, now)
+				if err != nil {
+					log.Printf("Failed to expire unpaid orders: %v", err)
+					continue
+				}
+				if expired > 0 {
+					log.Printf("Cancelled %d orders whose offline payment never arrived", expired)
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,209 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Checkout pricing rules that order totals are recomputed with
+const (
+	orderTaxRate        = 0.08 // 8% tax, charged before orders recorded their rate
+	orderShippingAmount = 9.99 // Fixed shipping, charged by CreateOrder and QuoteOrder
+)
+
+// zeroDecimalCurrencies are the currencies without minor units
+var zeroDecimalCurrencies = map[string]bool{"huf": true, "jpy": true, "krw": true, "twd": true}
+
+// OrderTotals are the amounts an order is charged
+type OrderTotals struct {
+	Subtotal       float64 `json:"subtotal"`
+	DiscountAmount float64 `json:"discount_amount"`
+	TaxAmount      float64 `json:"tax_amount"`
+	ShippingAmount float64 `json:"shipping_amount"`
+	GiftWrapAmount float64 `json:"gift_wrap_amount"`
+	TotalAmount    float64 `json:"total_amount"`
+}
+
+// RecalculatedItem compares the stored total of a line with its quantity
+// times unit price
+type RecalculatedItem struct {
+	ItemID        uuid.UUID `json:"item_id"`
+	ProductID     uuid.UUID `json:"product_id"`
+	Quantity      int       `json:"quantity"`
+	UnitPrice     float64   `json:"unit_price"`
+	StoredTotal   float64   `json:"stored_total"`
+	ComputedTotal float64   `json:"computed_total"`
+}
+
+// TotalsDiscrepancy is an amount whose stored value differs from the
+// recomputed one
+type TotalsDiscrepancy struct {
+	Field      string     `json:"field"`
+	ItemID     *uuid.UUID `json:"item_id,omitempty"`
+	Stored     float64    `json:"stored"`
+	Computed   float64    `json:"computed"`
+	Difference float64    `json:"difference"` // stored minus computed
+}
+
+// OrderRecalculation is the result of recomputing an order's totals. The
+// order itself is never changed.
+type OrderRecalculation struct {
+	OrderID       uuid.UUID           `json:"order_id"`
+	OrderNumber   string              `json:"order_number"`
+	Currency      string              `json:"currency"`
+	QuoteID       *uuid.UUID          `json:"quote_id,omitempty"` // set when the order was converted from a quote
+	Stored        OrderTotals         `json:"stored"`
+	Computed      OrderTotals         `json:"computed"`
+	Items         []RecalculatedItem  `json:"items"`
+	Discrepancies []TotalsDiscrepancy `json:"discrepancies"`
+	Balanced      bool                `json:"balanced"`
+	CheckedAt     time.Time           `json:"checked_at"`
+}
+
+// RecalculateOrder recomputes an order's totals from its line items with the
+// checkout tax and shipping rules and reports every amount that differs from
+// what was stored. Amounts are compared in the currency's minor units, so
+// float rounding never shows up as a discrepancy. Checkout applies no
+// promotions, so the expected discount is always zero. Tax is recomputed at
+// the rate the order recorded, extracted from the subtotal when its prices
+// included tax. Orders converted from a quote are checked against the
+// shipping the quote was approved with. The gift wrap fee is the one charged
+// at checkout, and only on gift-wrapped orders.
+func (s *OrderService) RecalculateOrder(ctx context.Context, orderID uuid.UUID) (*OrderRecalculation, error) {
+	var order Order
+	if err := s.db.WithContext(ctx).Preload("Items", func(db *gorm.DB) *gorm.DB {
+		return db.Order("created_at ASC")
+	}).Where("id = ?", orderID).First(&order).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("order not found")
+		}
+		return nil, errors.New("failed to retrieve order")
+	}
+
+	var quote models.Quote
+	err := s.db.WithContext(ctx).Select("id", "shipping_amount").Where("order_id = ?", order.ID).First(&quote).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to fetch quote: %v", err)
+	}
+	fromQuote := err == nil
+
+	money := newMinorUnits(order.Currency)
+	result := &OrderRecalculation{
+		OrderID:     order.ID,
+		OrderNumber: order.OrderNumber,
+		Currency:    order.Currency,
+		Stored: OrderTotals{
+			Subtotal:       order.Subtotal,
+			TaxAmount:      order.TaxAmount,
+			ShippingAmount: order.ShippingAmount,
+			GiftWrapAmount: order.GiftWrapAmount,
+			TotalAmount:    order.TotalAmount,
+		},
+		Items:         make([]RecalculatedItem, 0, len(order.Items)),
+		Discrepancies: []TotalsDiscrepancy{},
+		CheckedAt:     time.Now(),
+	}
+	if fromQuote {
+		result.QuoteID = &quote.ID
+	}
+
+	var subtotal int64
+	for _, item := range order.Items {
+		itemID := item.ID
+		computed := money.of(item.UnitPrice) * int64(item.Quantity)
+		subtotal += computed
+
+		result.Items = append(result.Items, RecalculatedItem{
+			ItemID:        item.ID,
+			ProductID:     item.ProductID,
+			Quantity:      item.Quantity,
+			UnitPrice:     item.UnitPrice,
+			StoredTotal:   item.TotalPrice,
+			ComputedTotal: money.amount(computed),
+		})
+		result.compare(money, "item_total", &itemID, money.of(item.TotalPrice), computed)
+	}
+
+	shipping := money.of(orderShippingAmount)
+	if fromQuote {
+		shipping = money.of(quote.ShippingAmount)
+	}
+	var giftWrap int64
+	if order.GiftWrap {
+		giftWrap = money.of(order.GiftWrapAmount)
+	}
+	var discount int64 // checkout has no promotions
+	taxRate := orderTaxRate
+	if order.TaxRate != nil {
+		taxRate = *order.TaxRate
+	}
+	taxed := subtotal - discount
+	var tax, total int64
+	if order.TaxIncluded {
+		tax = int64(math.Round(float64(taxed) - float64(taxed)/(1+taxRate)))
+		total = taxed + shipping + giftWrap
+	} else {
+		tax = int64(math.Round(float64(taxed) * taxRate))
+		total = taxed + tax + shipping + giftWrap
+	}
+
+	result.Computed = OrderTotals{
+		Subtotal:       money.amount(subtotal),
+		DiscountAmount: money.amount(discount),
+		TaxAmount:      money.amount(tax),
+		ShippingAmount: money.amount(shipping),
+		GiftWrapAmount: money.amount(giftWrap),
+		TotalAmount:    money.amount(total),
+	}
+	result.compare(money, "subtotal", nil, money.of(order.Subtotal), subtotal)
+	result.compare(money, "tax_amount", nil, money.of(order.TaxAmount), tax)
+	result.compare(money, "shipping_amount", nil, money.of(order.ShippingAmount), shipping)
+	result.compare(money, "gift_wrap_amount", nil, money.of(order.GiftWrapAmount), giftWrap)
+	result.compare(money, "total_amount", nil, money.of(order.TotalAmount), total)
+
+	result.Balanced = len(result.Discrepancies) == 0
+	return result, nil
+}
+
+// compare records a discrepancy when a stored amount differs from the
+// computed one
+func (r *OrderRecalculation) compare(money minorUnits, field string, itemID *uuid.UUID, stored, computed int64) {
+	if stored == computed {
+		return
+	}
+	r.Discrepancies = append(r.Discrepancies, TotalsDiscrepancy{
+		Field:      field,
+		ItemID:     itemID,
+		Stored:     money.amount(stored),
+		Computed:   money.amount(computed),
+		Difference: money.amount(stored - computed),
+	})
+}
+
+// minorUnits converts amounts of a currency to and from its smallest unit
+type minorUnits struct {
+	scale float64
+}
+
+func newMinorUnits(currency string) minorUnits {
+	if zeroDecimalCurrencies[strings.ToLower(currency)] {
+		return minorUnits{scale: 1}
+	}
+	return minorUnits{scale: 100}
+}
+
+func (m minorUnits) of(amount float64) int64 {
+	return int64(math.Round(amount * m.scale))
+}
+
+func (m minorUnits) amount(units int64) float64 {
+	return float64(units) / m.scale
+}
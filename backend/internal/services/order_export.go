@@ -0,0 +1,566 @@
+package services
+
+import (
+	"bytes"
+	"chat-ecommerce-backend/internal/models"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Order export statuses
+const (
+	OrderExportPending  = "pending"
+	OrderExportExported = "exported"
+	OrderExportFailed   = "failed"
+)
+
+// Order export transports
+const (
+	OrderExportTransportHTTP      = "http"
+	OrderExportTransportSFTP      = "sftp"
+	OrderExportTransportDirectory = "directory"
+)
+
+// Order export formats
+const (
+	OrderExportFormatJSON = "json"
+	OrderExportFormatCSV  = "csv"
+)
+
+var (
+	// ErrOrderExportDisabled is returned when no ERP export transport is configured
+	ErrOrderExportDisabled = errors.New("order export is not configured")
+	// ErrOrderNotExportable is returned for orders that haven't been paid
+	ErrOrderNotExportable = errors.New("only paid orders can be exported")
+	// ErrOrderExportNotFound is returned for orders that were never exported
+	ErrOrderExportNotFound = errors.New("order export not found")
+)
+
+// OrderExportConfig controls how paid orders are pushed to the ERP
+type OrderExportConfig struct {
+	Transport     string // http, sftp or directory; empty disables exports
+	Format        string // json or csv
+	URL           string // the ERP endpoint orders are POSTed to
+	Token         string // sent as a bearer token with HTTP exports
+	SFTPAddress   string // host:port
+	SFTPUser      string
+	SFTPPassword  string
+	SFTPHostKey   string // the server's public key, in authorized_keys format
+	Directory     string // where files are written, on the SFTP server or locally
+	MaxAttempts   int
+	RetryInterval time.Duration // doubled after each failure
+	SweepInterval time.Duration
+}
+
+// OrderExportConfigFromEnv reads ERP_EXPORT_TRANSPORT, ERP_EXPORT_FORMAT (json),
+// ERP_EXPORT_URL, ERP_EXPORT_TOKEN, ERP_EXPORT_SFTP_ADDR, ERP_EXPORT_SFTP_USER,
+// ERP_EXPORT_SFTP_PASSWORD, ERP_EXPORT_SFTP_HOST_KEY, ERP_EXPORT_DIR,
+// ERP_EXPORT_MAX_ATTEMPTS (5), ERP_EXPORT_RETRY_MINUTES (5) and
+// ERP_EXPORT_SWEEP_MINUTES (1)
+func OrderExportConfigFromEnv() OrderExportConfig {
+	format := strings.ToLower(os.Getenv("ERP_EXPORT_FORMAT"))
+	if format == "" {
+		format = OrderExportFormatJSON
+	}
+	return OrderExportConfig{
+		Transport:     strings.ToLower(os.Getenv("ERP_EXPORT_TRANSPORT")),
+		Format:        format,
+		URL:           os.Getenv("ERP_EXPORT_URL"),
+		Token:         os.Getenv("ERP_EXPORT_TOKEN"),
+		SFTPAddress:   os.Getenv("ERP_EXPORT_SFTP_ADDR"),
+		SFTPUser:      os.Getenv("ERP_EXPORT_SFTP_USER"),
+		SFTPPassword:  os.Getenv("ERP_EXPORT_SFTP_PASSWORD"),
+		SFTPHostKey:   os.Getenv("ERP_EXPORT_SFTP_HOST_KEY"),
+		Directory:     os.Getenv("ERP_EXPORT_DIR"),
+		MaxAttempts:   envInt("ERP_EXPORT_MAX_ATTEMPTS", 5),
+		RetryInterval: time.Duration(envInt("ERP_EXPORT_RETRY_MINUTES", 5)) * time.Minute,
+		SweepInterval: time.Duration(envInt("ERP_EXPORT_SWEEP_MINUTES", 1)) * time.Minute,
+	}
+}
+
+// Enabled reports whether paid orders are exported
+func (c OrderExportConfig) Enabled() bool {
+	return c.Transport != ""
+}
+
+// OrderExportTransport delivers an exported order document to the ERP. It
+// returns the ERP's reference for the order, or where the file was written.
+type OrderExportTransport interface {
+	Send(ctx context.Context, filename, contentType string, document []byte) (string, error)
+}
+
+// ExportedOrder is the document sent to the ERP for an order
+type ExportedOrder struct {
+	OrderID          uuid.UUID           `json:"order_id"`
+	OrderNumber      string              `json:"order_number"`
+	PlacedAt         time.Time           `json:"placed_at"`
+	CustomerEmail    string              `json:"customer_email"`
+	Currency         string              `json:"currency"`
+	Subtotal         float64             `json:"subtotal"`
+	TaxAmount        float64             `json:"tax_amount"`
+	TaxIncluded      bool                `json:"tax_included"`
+	ShippingAmount   float64             `json:"shipping_amount"`
+	GiftWrapAmount   float64             `json:"gift_wrap_amount"`
+	TotalAmount      float64             `json:"total_amount"`
+	PaymentMethod    string              `json:"payment_method"`
+	PaymentReference string              `json:"payment_reference,omitempty"`
+	ShippingAddress  json.RawMessage     `json:"shipping_address"`
+	BillingAddress   json.RawMessage     `json:"billing_address"`
+	DeliveryDate     string              `json:"delivery_date,omitempty"`
+	DeliveryCarrier  string              `json:"delivery_carrier,omitempty"`
+	GiftMessage      string              `json:"gift_message,omitempty"`
+	Lines            []ExportedOrderLine `json:"lines"`
+}
+
+// ExportedOrderLine is one line of an exported order
+type ExportedOrderLine struct {
+	SKU        string  `json:"sku"`
+	Name       string  `json:"name"`
+	Quantity   int     `json:"quantity"`
+	UnitPrice  float64 `json:"unit_price"`
+	TotalPrice float64 `json:"total_price"`
+}
+
+// OrderExportSweep summarises one run of due order exports
+type OrderExportSweep struct {
+	Attempted int `json:"attempted"`
+	Exported  int `json:"exported"`
+	Failed    int `json:"failed"` // out of attempts
+}
+
+// OrderExportService pushes paid orders to the ERP or fulfillment system,
+// tracking each order's export and retrying failures with backoff
+type OrderExportService struct {
+	db        *gorm.DB
+	config    OrderExportConfig
+	transport OrderExportTransport
+}
+
+// NewOrderExportService creates a new OrderExportService sending orders with
+// the configured transport
+func NewOrderExportService(db *gorm.DB, config OrderExportConfig) *OrderExportService {
+	s := &OrderExportService{db: db, config: config}
+	switch config.Transport {
+	case OrderExportTransportHTTP:
+		s.transport = &httpOrderExport{url: config.URL, token: config.Token, client: &http.Client{Timeout: 30 * time.Second}}
+	case OrderExportTransportSFTP:
+		s.transport = &sftpOrderExport{
+			address:  config.SFTPAddress,
+			user:     config.SFTPUser,
+			password: config.SFTPPassword,
+			hostKey:  config.SFTPHostKey,
+			dir:      config.Directory,
+		}
+	case OrderExportTransportDirectory:
+		s.transport = &directoryOrderExport{dir: config.Directory}
+	}
+	return s
+}
+
+// WithTransport replaces the transport orders are sent with
+func (s *OrderExportService) WithTransport(transport OrderExportTransport) *OrderExportService {
+	s.transport = transport
+	return s
+}
+
+// Enabled reports whether paid orders are exported
+func (s *OrderExportService) Enabled() bool {
+	return s.transport != nil
+}
+
+// Enqueue schedules a newly paid order for export. Orders already exported
+// are left alone.
+func (s *OrderExportService) Enqueue(ctx context.Context, orderID uuid.UUID) error {
+	if !s.Enabled() {
+		return nil
+	}
+
+	now := time.Now()
+	var export models.OrderExport
+	err := s.db.WithContext(ctx).Where("order_id = ?", orderID).First(&export).Error
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("failed to fetch order export: %v", err)
+	}
+
+	export = models.OrderExport{
+		ID:            uuid.New(),
+		OrderID:       orderID,
+		Status:        OrderExportPending,
+		Transport:     s.config.Transport,
+		Format:        s.format(),
+		NextAttemptAt: &now,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	if err := s.db.WithContext(ctx).Create(&export).Error; err != nil {
+		return fmt.Errorf("failed to schedule order export: %v", err)
+	}
+	return nil
+}
+
+// GetExport returns an order's export
+func (s *OrderExportService) GetExport(ctx context.Context, orderID uuid.UUID) (*models.OrderExport, error) {
+	var export models.OrderExport
+	if err := s.db.WithContext(ctx).Where("order_id = ?", orderID).First(&export).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrOrderExportNotFound
+		}
+		return nil, fmt.Errorf("failed to fetch order export: %v", err)
+	}
+	return &export, nil
+}
+
+// ListExports returns order exports with a status, or all of them, most
+// recently updated first
+func (s *OrderExportService) ListExports(ctx context.Context, status string, limit int) ([]models.OrderExport, error) {
+	query := s.db.WithContext(ctx).Order("updated_at DESC")
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	exports := []models.OrderExport{}
+	if err := query.Find(&exports).Error; err != nil {
+		return nil, fmt.Errorf("failed to list order exports: %v", err)
+	}
+	return exports, nil
+}
+
+// Reexport sends a paid order to the ERP again now, whatever its export's
+// status, starting its attempts over
+func (s *OrderExportService) Reexport(ctx context.Context, orderID uuid.UUID) (*models.OrderExport, error) {
+	if !s.Enabled() {
+		return nil, ErrOrderExportDisabled
+	}
+
+	var order Order
+	if err := s.db.WithContext(ctx).Select("id", "payment_status").Where("id = ?", orderID).First(&order).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("order not found")
+		}
+		return nil, fmt.Errorf("failed to fetch order: %v", err)
+	}
+	if order.PaymentStatus != "paid" {
+		return nil, ErrOrderNotExportable
+	}
+
+	if err := s.Enqueue(ctx, orderID); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	err := s.db.WithContext(ctx).Model(&models.OrderExport{}).Where("order_id = ?", orderID).
+		Updates(map[string]interface{}{
+			"status":          OrderExportPending,
+			"attempts":        0,
+			"next_attempt_at": now,
+			"updated_at":      now,
+		}).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to reset order export: %v", err)
+	}
+
+	export, err := s.GetExport(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.attempt(ctx, export, now); err != nil {
+		return nil, err
+	}
+	return export, nil
+}
+
+// RunDueExports sends every pending export whose next attempt is due
+func (s *OrderExportService) RunDueExports(ctx context.Context, now time.Time) (*OrderExportSweep, error) {
+	result := &OrderExportSweep{}
+	if !s.Enabled() {
+		return result, nil
+	}
+
+	var due []models.OrderExport
+	if err := s.db.WithContext(ctx).
+		Where("status = ? AND next_attempt_at <= ?", OrderExportPending, now).
+		Order("next_attempt_at").
+		Find(&due).Error; err != nil {
+		return nil, fmt.Errorf("failed to find due order exports: %v", err)
+	}
+
+	for i := range due {
+		export := &due[i]
+		result.Attempted++
+		if err := s.attempt(ctx, export, now); err != nil {
+			return nil, err
+		}
+		switch export.Status {
+		case OrderExportExported:
+			result.Exported++
+		case OrderExportFailed:
+			result.Failed++
+		}
+	}
+	return result, nil
+}
+
+// ScheduleExports runs RunDueExports every SweepInterval until ctx is done
+func (s *OrderExportService) ScheduleExports(ctx context.Context) {
+	if !s.Enabled() {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(s.config.SweepInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				result, err := s.RunDueExports(ctx, now)
+				if err != nil {
+					log.Printf("Failed to export orders: %v", err)
+					continue
+				}
+				if result.Attempted > 0 {
+					log.Printf("Order exports: attempted %d, exported %d, failed %d", result.Attempted, result.Exported, result.Failed)
+				}
+			}
+		}
+	}()
+}
+
+// attempt sends an order once and records the outcome on its export. Only
+// failures to record the outcome are returned; a failed send schedules the
+// next attempt with exponential backoff, or fails the export once it has
+// used MaxAttempts.
+func (s *OrderExportService) attempt(ctx context.Context, export *models.OrderExport, now time.Time) error {
+	export.Attempts++
+	export.Transport = s.config.Transport
+	export.Format = s.format()
+	export.UpdatedAt = now
+
+	reference, sendErr := s.send(ctx, export.OrderID)
+	if sendErr == nil {
+		export.Status = OrderExportExported
+		export.Reference = reference
+		export.LastError = ""
+		export.NextAttemptAt = nil
+		export.ExportedAt = &now
+	} else {
+		export.LastError = sendErr.Error()
+		if export.Attempts >= s.config.MaxAttempts {
+			export.Status = OrderExportFailed
+			export.NextAttemptAt = nil
+		} else {
+			next := now.Add(s.config.RetryInterval * time.Duration(1<<(export.Attempts-1)))
+			export.NextAttemptAt = &next
+		}
+	}
+
+	if err := s.db.WithContext(ctx).Save(export).Error; err != nil {
+		return fmt.Errorf("failed to save order export: %v", err)
+	}
+	return nil
+}
+
+// send renders an order in the configured format and hands it to the transport
+func (s *OrderExportService) send(ctx context.Context, orderID uuid.UUID) (string, error) {
+	var order Order
+	if err := s.db.WithContext(ctx).
+		Preload("User").
+		Preload("Items.Product").
+		Where("id = ?", orderID).
+		First(&order).Error; err != nil {
+		return "", fmt.Errorf("failed to fetch order: %v", err)
+	}
+
+	exported := exportedOrder(&order)
+	filename := "order-" + order.OrderNumber
+	var document []byte
+	var contentType string
+	var err error
+	if s.format() == OrderExportFormatCSV {
+		document, err = exportedOrderCSV(exported)
+		filename += ".csv"
+		contentType = "text/csv"
+	} else {
+		document, err = json.Marshal(exported)
+		filename += ".json"
+		contentType = "application/json"
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to render order: %v", err)
+	}
+	return s.transport.Send(ctx, filename, contentType, document)
+}
+
+// format returns the configured document format, JSON unless CSV was asked for
+func (s *OrderExportService) format() string {
+	if s.config.Format == OrderExportFormatCSV {
+		return OrderExportFormatCSV
+	}
+	return OrderExportFormatJSON
+}
+
+// exportedOrder builds the document sent to the ERP for an order
+func exportedOrder(order *Order) *ExportedOrder {
+	exported := &ExportedOrder{
+		OrderID:          order.ID,
+		OrderNumber:      order.OrderNumber,
+		PlacedAt:         order.CreatedAt,
+		CustomerEmail:    order.User.Email,
+		Currency:         order.Currency,
+		Subtotal:         order.Subtotal,
+		TaxAmount:        order.TaxAmount,
+		TaxIncluded:      order.TaxIncluded,
+		ShippingAmount:   order.ShippingAmount,
+		GiftWrapAmount:   order.GiftWrapAmount,
+		TotalAmount:      order.TotalAmount,
+		PaymentMethod:    order.PaymentMethod,
+		PaymentReference: order.PaymentReference,
+		ShippingAddress:  json.RawMessage(order.ShippingAddress),
+		BillingAddress:   json.RawMessage(order.BillingAddress),
+		DeliveryCarrier:  order.DeliveryCarrier,
+		GiftMessage:      order.GiftMessage,
+		Lines:            make([]ExportedOrderLine, len(order.Items)),
+	}
+	if len(exported.ShippingAddress) == 0 {
+		exported.ShippingAddress = json.RawMessage("{}")
+	}
+	if len(exported.BillingAddress) == 0 {
+		exported.BillingAddress = json.RawMessage("{}")
+	}
+	if order.DeliveryDate != nil {
+		exported.DeliveryDate = order.DeliveryDate.Format("2006-01-02")
+	}
+	for i, item := range order.Items {
+		exported.Lines[i] = ExportedOrderLine{
+			SKU:        item.Product.SKU,
+			Name:       item.Product.Name,
+			Quantity:   item.Quantity,
+			UnitPrice:  item.UnitPrice,
+			TotalPrice: item.TotalPrice,
+		}
+	}
+	return exported
+}
+
+// exportedOrderCSV renders an order as CSV, one row per line with the
+// order's details repeated on each
+func exportedOrderCSV(order *ExportedOrder) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.Write([]string{
+		"order_number", "placed_at", "customer_email", "currency", "sku", "name", "quantity", "unit_price", "line_total",
+		"subtotal", "tax_amount", "shipping_amount", "gift_wrap_amount", "total_amount", "payment_method", "payment_reference",
+		"delivery_date", "shipping_address",
+	}); err != nil {
+		return nil, err
+	}
+	money := func(amount float64) string { return strconv.FormatFloat(amount, 'f', 2, 64) }
+	for _, line := range order.Lines {
+		if err := w.Write([]string{
+			order.OrderNumber, order.PlacedAt.UTC().Format(time.RFC3339), order.CustomerEmail, order.Currency,
+			line.SKU, line.Name, strconv.Itoa(line.Quantity), money(line.UnitPrice), money(line.TotalPrice),
+			money(order.Subtotal), money(order.TaxAmount), money(order.ShippingAmount), money(order.GiftWrapAmount),
+			money(order.TotalAmount), order.PaymentMethod, order.PaymentReference, order.DeliveryDate, string(order.ShippingAddress),
+		}); err != nil {
+			return nil, err
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// httpOrderExport POSTs orders to the ERP's endpoint
+type httpOrderExport struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+func (t *httpOrderExport) Send(ctx context.Context, filename, contentType string, document []byte) (string, error) {
+	if t.url == "" {
+		return "", errors.New("ERP_EXPORT_URL is not set")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, bytes.NewReader(document))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", contentType)
+	// The ERP can drop repeats of an order that was received but not acknowledged
+	req.Header.Set("Idempotency-Key", strings.TrimSuffix(filename, filepath.Ext(filename)))
+	if t.token != "" {
+		req.Header.Set("Authorization", "Bearer "+t.token)
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to send order to ERP: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", fmt.Errorf("ERP answered with status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	// The ERP's ID for the order, when it sends one back
+	var reply struct {
+		ID        string `json:"id"`
+		Reference string `json:"reference"`
+	}
+	if json.Unmarshal(body, &reply) == nil {
+		if reply.Reference != "" {
+			return reply.Reference, nil
+		}
+		return reply.ID, nil
+	}
+	return "", nil
+}
+
+// directoryOrderExport writes orders as files to a local directory, such as
+// a share the ERP picks them up from
+type directoryOrderExport struct {
+	dir string
+}
+
+func (t *directoryOrderExport) Send(ctx context.Context, filename, contentType string, document []byte) (string, error) {
+	if t.dir == "" {
+		return "", errors.New("ERP_EXPORT_DIR is not set")
+	}
+	if err := os.MkdirAll(t.dir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create export directory: %v", err)
+	}
+
+	// Write under a temporary name so the ERP never picks up half a file
+	path := filepath.Join(t.dir, filename)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, document, 0o644); err != nil {
+		return "", fmt.Errorf("failed to write order file: %v", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return "", fmt.Errorf("failed to write order file: %v", err)
+	}
+	return path, nil
+}
@@ -0,0 +1,253 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"path"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// SFTP version 3 packet types and open flags used to upload a file
+const (
+	sftpInit    = 1
+	sftpVersion = 2
+	sftpOpen    = 3
+	sftpClose   = 4
+	sftpWrite   = 6
+	sftpRemove  = 13
+	sftpRename  = 18
+	sftpStatus  = 101
+	sftpHandle  = 102
+
+	sftpOpenWrite    = 0x02
+	sftpOpenCreate   = 0x08
+	sftpOpenTruncate = 0x10
+
+	sftpChunkSize = 32 * 1024
+)
+
+// sftpOrderExport uploads orders as files to the ERP's SFTP server
+type sftpOrderExport struct {
+	address  string
+	user     string
+	password string
+	hostKey  string
+	dir      string
+}
+
+func (t *sftpOrderExport) Send(ctx context.Context, filename, contentType string, document []byte) (string, error) {
+	if t.address == "" || t.hostKey == "" {
+		return "", errors.New("ERP_EXPORT_SFTP_ADDR and ERP_EXPORT_SFTP_HOST_KEY must be set")
+	}
+	hostKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(t.hostKey))
+	if err != nil {
+		return "", fmt.Errorf("invalid ERP_EXPORT_SFTP_HOST_KEY: %v", err)
+	}
+
+	dialer := net.Dialer{Timeout: 30 * time.Second}
+	conn, err := dialer.DialContext(ctx, "tcp", t.address)
+	if err != nil {
+		return "", fmt.Errorf("failed to connect to SFTP server: %v", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	} else {
+		conn.SetDeadline(time.Now().Add(2 * time.Minute))
+	}
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, t.address, &ssh.ClientConfig{
+		User:            t.user,
+		Auth:            []ssh.AuthMethod{ssh.Password(t.password)},
+		HostKeyCallback: ssh.FixedHostKey(hostKey),
+		Timeout:         30 * time.Second,
+	})
+	if err != nil {
+		conn.Close()
+		return "", fmt.Errorf("failed to sign in to SFTP server: %v", err)
+	}
+	client := ssh.NewClient(sshConn, chans, reqs)
+	defer client.Close()
+
+	session, err := client.NewSession()
+	if err != nil {
+		return "", fmt.Errorf("failed to open SFTP session: %v", err)
+	}
+	defer session.Close()
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		return "", err
+	}
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		return "", err
+	}
+	if err := session.RequestSubsystem("sftp"); err != nil {
+		return "", fmt.Errorf("failed to start SFTP: %v", err)
+	}
+
+	sftp := &sftpConn{w: stdin, r: bufio.NewReader(stdout)}
+	remote := path.Join(t.dir, filename)
+	if err := sftp.upload(remote, document); err != nil {
+		return "", fmt.Errorf("failed to upload order file: %v", err)
+	}
+	return remote, nil
+}
+
+// sftpConn speaks just enough SFTP version 3 to upload a file
+type sftpConn struct {
+	w      io.Writer
+	r      io.Reader
+	nextID uint32
+}
+
+// upload writes data under a temporary name and renames it into place, so the
+// ERP never picks up half a file
+func (c *sftpConn) upload(remote string, data []byte) error {
+	if err := c.send(sftpInit, uint32(3)); err != nil {
+		return err
+	}
+	kind, _, err := c.receive()
+	if err != nil {
+		return err
+	}
+	if kind != sftpVersion {
+		return fmt.Errorf("unexpected SFTP packet %d", kind)
+	}
+
+	tmp := remote + ".tmp"
+	handle, err := c.open(tmp)
+	if err != nil {
+		return err
+	}
+	for offset := 0; offset < len(data); offset += sftpChunkSize {
+		end := offset + sftpChunkSize
+		if end > len(data) {
+			end = len(data)
+		}
+		if err := c.request(sftpWrite, handle, uint64(offset), data[offset:end]); err != nil {
+			c.request(sftpClose, handle)
+			return err
+		}
+	}
+	if err := c.request(sftpClose, handle); err != nil {
+		return err
+	}
+
+	// Renaming doesn't overwrite in SFTP version 3: replace an earlier export
+	c.request(sftpRemove, remote)
+	return c.request(sftpRename, tmp, remote)
+}
+
+// open opens a remote file for writing, creating or truncating it
+func (c *sftpConn) open(remote string) (string, error) {
+	id := c.id()
+	if err := c.send(sftpOpen, id, remote, uint32(sftpOpenWrite|sftpOpenCreate|sftpOpenTruncate), uint32(0)); err != nil {
+		return "", err
+	}
+	kind, payload, err := c.receive()
+	if err != nil {
+		return "", err
+	}
+	switch kind {
+	case sftpHandle:
+		if len(payload) < 4 {
+			return "", errors.New("short SFTP handle")
+		}
+		handle, _, err := sftpString(payload[4:])
+		return handle, err
+	case sftpStatus:
+		return "", sftpStatusError(payload)
+	}
+	return "", fmt.Errorf("unexpected SFTP packet %d", kind)
+}
+
+// request sends a request answered with a status and returns its error, if any
+func (c *sftpConn) request(kind byte, fields ...interface{}) error {
+	if err := c.send(kind, append([]interface{}{c.id()}, fields...)...); err != nil {
+		return err
+	}
+	reply, payload, err := c.receive()
+	if err != nil {
+		return err
+	}
+	if reply != sftpStatus {
+		return fmt.Errorf("unexpected SFTP packet %d", reply)
+	}
+	return sftpStatusError(payload)
+}
+
+func (c *sftpConn) id() uint32 {
+	c.nextID++
+	return c.nextID
+}
+
+// send writes a packet of uint32, uint64, string and []byte fields
+func (c *sftpConn) send(kind byte, fields ...interface{}) error {
+	body := []byte{kind}
+	for _, field := range fields {
+		switch v := field.(type) {
+		case uint32:
+			body = binary.BigEndian.AppendUint32(body, v)
+		case uint64:
+			body = binary.BigEndian.AppendUint64(body, v)
+		case string:
+			body = binary.BigEndian.AppendUint32(body, uint32(len(v)))
+			body = append(body, v...)
+		case []byte:
+			body = binary.BigEndian.AppendUint32(body, uint32(len(v)))
+			body = append(body, v...)
+		}
+	}
+	packet := binary.BigEndian.AppendUint32(nil, uint32(len(body)))
+	_, err := c.w.Write(append(packet, body...))
+	return err
+}
+
+// receive reads a packet, returning its type and the payload after it
+func (c *sftpConn) receive() (byte, []byte, error) {
+	var size [4]byte
+	if _, err := io.ReadFull(c.r, size[:]); err != nil {
+		return 0, nil, err
+	}
+	length := binary.BigEndian.Uint32(size[:])
+	if length == 0 || length > 256*1024 {
+		return 0, nil, fmt.Errorf("invalid SFTP packet length %d", length)
+	}
+	packet := make([]byte, length)
+	if _, err := io.ReadFull(c.r, packet); err != nil {
+		return 0, nil, err
+	}
+	return packet[0], packet[1:], nil
+}
+
+// sftpStatusError turns a status payload (request ID, code, message) into an
+// error, or nil for SSH_FX_OK
+func sftpStatusError(payload []byte) error {
+	if len(payload) < 8 {
+		return errors.New("short SFTP status")
+	}
+	code := binary.BigEndian.Uint32(payload[4:8])
+	if code == 0 {
+		return nil
+	}
+	message, _, _ := sftpString(payload[8:])
+	return fmt.Errorf("SFTP error %d: %s", code, message)
+}
+
+// sftpString reads a length-prefixed string
+func sftpString(b []byte) (string, []byte, error) {
+	if len(b) < 4 {
+		return "", nil, errors.New("short SFTP string")
+	}
+	n := binary.BigEndian.Uint32(b[:4])
+	if uint32(len(b)-4) < n {
+		return "", nil, errors.New("short SFTP string")
+	}
+	return string(b[4 : 4+n]), b[4+n:], nil
+}
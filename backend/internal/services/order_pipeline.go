@@ -0,0 +1,534 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// OrderSubmissionMessage is the WebSocket message type of order pipeline progress
+const OrderSubmissionMessage = "order_submission"
+
+// Order submission statuses
+const (
+	OrderSubmissionAccepted   = "accepted"
+	OrderSubmissionProcessing = "processing"
+	OrderSubmissionCompleted  = "completed"
+	OrderSubmissionFailed     = "failed"
+)
+
+// Order pipeline stages, in the order they run
+const (
+	OrderStageReserving = "reserving_inventory" // creating the order, which reserves its stock
+	OrderStagePayment   = "payment_intent"
+	OrderStageNotifying = "notifying" // confirmation email and chat messages
+)
+
+// orderSubmissionLease is how long a worker holds a submission before
+// another may take it over, as when the instance running it went away
+const orderSubmissionLease = 2 * time.Minute
+
+// ErrOrderSubmissionNotFound is returned for submissions that don't exist
+var ErrOrderSubmissionNotFound = errors.New("order submission not found")
+
+// OrderPipelineConfig controls the workers behind accepted checkouts
+type OrderPipelineConfig struct {
+	Workers       int
+	MaxAttempts   int           // attempts at the payment and notifying stages before the submission fails
+	RetryInterval time.Duration // first retry delay, doubled each attempt; also how often missed submissions are picked up
+}
+
+// OrderPipelineConfigFromEnv reads ORDER_PIPELINE_WORKERS (default 4),
+// ORDER_PIPELINE_MAX_ATTEMPTS (default 5) and ORDER_PIPELINE_RETRY_SECONDS
+// (default 5)
+func OrderPipelineConfigFromEnv() OrderPipelineConfig {
+	config := OrderPipelineConfig{
+		Workers:       envInt("ORDER_PIPELINE_WORKERS", 4),
+		MaxAttempts:   envInt("ORDER_PIPELINE_MAX_ATTEMPTS", 5),
+		RetryInterval: time.Duration(envInt("ORDER_PIPELINE_RETRY_SECONDS", 5)) * time.Second,
+	}
+	if config.Workers <= 0 {
+		config.Workers = 4
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = 1
+	}
+	return config
+}
+
+// OrderPipelineNotifier tells shoppers how their checkout is going on every
+// connection of their account, and confirms orders in their chat session
+type OrderPipelineNotifier interface {
+	SessionNotifier
+	UserNotifier
+}
+
+// OrderPipeline moves the heavy work of a checkout behind a queue. Submit
+// records the checkout and answers at once; workers then create the order,
+// reserving its stock, start its payment, and queue the confirmation email
+// and chat messages. Submissions are kept in the database, so a restart or
+// another instance picks up where a worker left off, and transient failures
+// are retried with backoff.
+type OrderPipeline struct {
+	db       *gorm.DB
+	orders   *OrderService
+	payments *PaymentService
+	messages *MessageDispatcher
+	notifier OrderPipelineNotifier
+	config   OrderPipelineConfig
+	queue    chan uuid.UUID
+}
+
+// NewOrderPipeline creates a new OrderPipeline
+func NewOrderPipeline(db *gorm.DB, orders *OrderService, payments *PaymentService, config OrderPipelineConfig) *OrderPipeline {
+	return &OrderPipeline{
+		db:       db,
+		orders:   orders,
+		payments: payments,
+		messages: NewMessageDispatcher(db),
+		config:   config,
+		queue:    make(chan uuid.UUID, 1024),
+	}
+}
+
+// WithNotifier pushes each submission's progress to the shopper
+func (p *OrderPipeline) WithNotifier(notifier OrderPipelineNotifier) *OrderPipeline {
+	p.notifier = notifier
+	return p
+}
+
+// Submit accepts a checkout for the workers. A checkout sent again with the
+// same idempotency key, as by a double click, gets the submission it was
+// accepted as instead of placing a second order. Shoppers the flash sale
+// waiting room hasn't let through are turned away at once with a
+// *WaitingRoomError rather than after queueing, as are delivery dates that
+// can't be had. Both are settled here for good: the worker placing the order
+// later, maybe on another instance, goes by what was accepted.
+func (p *OrderPipeline) Submit(ctx context.Context, req *CreateOrderRequest, idempotencyKey string, now time.Time) (*models.OrderSubmission, error) {
+	if idempotencyKey != "" {
+		if submission, err := p.submissionByKey(ctx, req.UserID, idempotencyKey); err != nil || submission != nil {
+			return submission, err
+		}
+	}
+	slot, err := p.orders.deliverySlot(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	sale, err := p.orders.flashSales.checkoutSale(ctx, req, now)
+	if err != nil {
+		return nil, err
+	}
+	encoded, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode order request: %v", err)
+	}
+
+	submission := &models.OrderSubmission{
+		ID:        uuid.New(),
+		UserID:    req.UserID,
+		SessionID: req.SessionID,
+		Request:   datatypes.JSON(encoded),
+		Status:    OrderSubmissionAccepted,
+	}
+	if sale != nil {
+		submission.FlashSaleID = &sale.ID
+	}
+	if slot != nil {
+		if submission.DeliverySlot, err = json.Marshal(slot); err != nil {
+			return nil, fmt.Errorf("failed to encode delivery slot: %v", err)
+		}
+	}
+	if idempotencyKey != "" {
+		submission.IdempotencyKey = &idempotencyKey
+	}
+	if err := p.db.WithContext(ctx).Create(submission).Error; err != nil {
+		// The same checkout sent at once is accepted by whichever came first
+		if idempotencyKey != "" {
+			if accepted, findErr := p.submissionByKey(ctx, req.UserID, idempotencyKey); findErr == nil && accepted != nil {
+				return accepted, nil
+			}
+		}
+		return nil, fmt.Errorf("failed to accept order: %v", err)
+	}
+
+	// A full queue is drained by the sweep instead
+	select {
+	case p.queue <- submission.ID:
+	default:
+	}
+	return submission, nil
+}
+
+// GetSubmission returns a submission of the user
+func (p *OrderPipeline) GetSubmission(ctx context.Context, id, userID uuid.UUID) (*models.OrderSubmission, error) {
+	var submission models.OrderSubmission
+	err := p.db.WithContext(ctx).Where("id = ? AND user_id = ?", id, userID).First(&submission).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrOrderSubmissionNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch order submission: %v", err)
+	}
+	return &submission, nil
+}
+
+// submissionByKey returns the user's submission accepted with the
+// idempotency key, or nil when there is none
+func (p *OrderPipeline) submissionByKey(ctx context.Context, userID uuid.UUID, key string) (*models.OrderSubmission, error) {
+	var submission models.OrderSubmission
+	err := p.db.WithContext(ctx).Where("user_id = ? AND idempotency_key = ?", userID, key).First(&submission).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch order submission: %v", err)
+	}
+	return &submission, nil
+}
+
+// Process runs a submission's remaining stages, unless another worker holds
+// it or its next attempt isn't due. It returns the submission as it was left,
+// or nil when it wasn't taken.
+func (p *OrderPipeline) Process(ctx context.Context, id uuid.UUID, now time.Time) (*models.OrderSubmission, error) {
+	lockedUntil := now.Add(orderSubmissionLease)
+	claimed := p.db.WithContext(ctx).Model(&models.OrderSubmission{}).
+		Where("id = ? AND status IN ?", id, []string{OrderSubmissionAccepted, OrderSubmissionProcessing}).
+		Where("next_attempt_at IS NULL OR next_attempt_at <= ?", now).
+		Where("locked_until IS NULL OR locked_until <= ?", now).
+		Updates(map[string]interface{}{
+			"status":       OrderSubmissionProcessing,
+			"locked_until": lockedUntil,
+			"attempts":     gorm.Expr("attempts + 1"),
+		})
+	if claimed.Error != nil {
+		return nil, fmt.Errorf("failed to claim order submission: %v", claimed.Error)
+	}
+	if claimed.RowsAffected == 0 {
+		return nil, nil
+	}
+
+	var submission models.OrderSubmission
+	if err := p.db.WithContext(ctx).First(&submission, "id = ?", id).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch order submission: %v", err)
+	}
+	var req CreateOrderRequest
+	if err := json.Unmarshal(submission.Request, &req); err != nil {
+		return &submission, p.fail(ctx, &submission, fmt.Errorf("failed to decode order request: %v", err))
+	}
+
+	// Creating the order reserves its stock. Failures the shopper has to fix,
+	// such as sold out items or broken order rules, aren't retried. The order
+	// records the submission that placed it, so an attempt after it was placed
+	// but not recorded here gets that order back rather than a second one.
+	if submission.OrderID == nil {
+		p.advance(ctx, &submission, OrderStageReserving)
+		req.SubmissionID = &submission.ID
+		order, err := p.placeOrder(ctx, &submission, &req)
+		if err != nil {
+			if isOrderRejection(err) {
+				return &submission, p.fail(ctx, &submission, err)
+			}
+			return &submission, p.retry(ctx, &submission, err, now)
+		}
+		submission.OrderID = &order.ID
+		if err := p.save(ctx, &submission, map[string]interface{}{"order_id": order.ID}); err != nil {
+			return &submission, err
+		}
+	}
+
+	order, err := p.orders.GetOrderByID(ctx, *submission.OrderID)
+	if err != nil {
+		return &submission, p.retry(ctx, &submission, err, now)
+	}
+
+	if submission.PaymentIntentID == "" && needsPaymentIntent(order) {
+		p.advance(ctx, &submission, OrderStagePayment)
+		if err := p.startPayment(ctx, &submission, order); err != nil {
+			return &submission, p.retry(ctx, &submission, err, now)
+		}
+	}
+
+	p.advance(ctx, &submission, OrderStageNotifying)
+	if err := p.confirmOnce(ctx, &submission, order, now); err != nil {
+		return &submission, p.retry(ctx, &submission, err, now)
+	}
+
+	submission.Status = OrderSubmissionCompleted
+	submission.Stage = ""
+	submission.Error = ""
+	submission.FinishedAt = &now
+	submission.LockedUntil = nil
+	err = p.save(ctx, &submission, map[string]interface{}{
+		"status": submission.Status, "stage": "", "error": "", "finished_at": now, "locked_until": nil,
+	})
+	return &submission, err
+}
+
+// RunDue processes every submission waiting for a worker: accepted ones the
+// queue missed, retries that are due and ones a worker gave up on
+func (p *OrderPipeline) RunDue(ctx context.Context, now time.Time) (int, error) {
+	ids, err := p.due(ctx, now)
+	if err != nil {
+		return 0, err
+	}
+	processed := 0
+	for _, id := range ids {
+		submission, err := p.Process(ctx, id, now)
+		if err != nil {
+			return processed, err
+		}
+		if submission != nil {
+			processed++
+		}
+	}
+	return processed, nil
+}
+
+// Start runs the workers, and queues the submissions waiting for one every
+// RetryInterval, until ctx is cancelled
+func (p *OrderPipeline) Start(ctx context.Context) {
+	for i := 0; i < p.config.Workers; i++ {
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case id := <-p.queue:
+					if _, err := p.Process(ctx, id, time.Now()); err != nil {
+						log.Printf("Failed to process order submission %s: %v", id, err)
+					}
+				}
+			}
+		}()
+	}
+	if p.config.RetryInterval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(p.config.RetryInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				ids, err := p.due(ctx, now)
+				if err != nil {
+					log.Printf("Failed to find due order submissions: %v", err)
+					continue
+				}
+				for _, id := range ids {
+					select {
+					case p.queue <- id:
+					default:
+					}
+				}
+			}
+		}
+	}()
+}
+
+// due returns the submissions a worker may take at now, oldest first.
+// Accepted ones are left to the queue for a moment before the sweep takes them.
+func (p *OrderPipeline) due(ctx context.Context, now time.Time) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	err := p.db.WithContext(ctx).Model(&models.OrderSubmission{}).
+		Where("(status = ? AND created_at <= ?) OR status = ?", OrderSubmissionAccepted, now.Add(-p.config.RetryInterval), OrderSubmissionProcessing).
+		Where("next_attempt_at IS NULL OR next_attempt_at <= ?", now).
+		Where("locked_until IS NULL OR locked_until <= ?", now).
+		Order("created_at ASC").
+		Limit(100).
+		Pluck("id", &ids).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to find due order submissions: %v", err)
+	}
+	return ids, nil
+}
+
+// placeOrder creates the submission's order with the flash sale admission
+// and delivery slot it was accepted with, rather than checking them again
+func (p *OrderPipeline) placeOrder(ctx context.Context, submission *models.OrderSubmission, req *CreateOrderRequest) (*Order, error) {
+	var sale *models.FlashSale
+	if submission.FlashSaleID != nil {
+		sale = &models.FlashSale{}
+		if err := p.db.WithContext(ctx).First(sale, "id = ?", *submission.FlashSaleID).Error; err != nil {
+			return nil, fmt.Errorf("failed to fetch flash sale: %v", err)
+		}
+	}
+	var slot *DeliverySlot
+	if len(submission.DeliverySlot) > 0 {
+		slot = &DeliverySlot{}
+		if err := json.Unmarshal(submission.DeliverySlot, slot); err != nil {
+			return nil, fmt.Errorf("failed to decode delivery slot: %v", err)
+		}
+	}
+	return p.orders.placeOrder(ctx, req, sale, slot)
+}
+
+// startPayment creates the order's payment intent and records it on the
+// order, as POST /payments/create-intent does. The submission is the
+// intent's idempotency key, so an attempt after one was created but not
+// recorded gets that intent back.
+func (p *OrderPipeline) startPayment(ctx context.Context, submission *models.OrderSubmission, order *Order) error {
+	intent, err := p.payments.CreatePaymentIntent(&CreatePaymentIntentRequest{
+		OrderID:        order.ID,
+		Amount:         int64(math.Round(order.TotalAmount * 100)),
+		Currency:       order.Currency,
+		Description:    "Order " + order.OrderNumber,
+		Metadata:       map[string]string{"order_number": order.OrderNumber},
+		IdempotencyKey: "order-submission-" + submission.ID.String(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create payment intent: %v", err)
+	}
+	if _, err := p.orders.UpdatePaymentStatus(ctx, order.ID, "processing", intent.ID); err != nil {
+		return err
+	}
+	if err := p.orders.UpdatePaymentProvider(ctx, order.ID, intent.Provider); err != nil {
+		return err
+	}
+
+	submission.PaymentIntentID = intent.ID
+	submission.PaymentProvider = intent.Provider
+	submission.ClientSecret = intent.ClientSecret
+	if err := p.db.WithContext(ctx).Model(submission).Updates(map[string]interface{}{
+		"payment_intent_id": submission.PaymentIntentID,
+		"payment_provider":  submission.PaymentProvider,
+		"client_secret":     submission.ClientSecret,
+	}).Error; err != nil {
+		return fmt.Errorf("failed to save payment intent: %v", err)
+	}
+	return nil
+}
+
+// confirmOnce confirms the order unless an earlier attempt did. The
+// submission is marked before the messages go out and unmarked when they
+// couldn't, so retries after a failure to record the outcome don't send
+// them twice.
+func (p *OrderPipeline) confirmOnce(ctx context.Context, submission *models.OrderSubmission, order *Order, now time.Time) error {
+	marked := p.db.WithContext(ctx).Model(&models.OrderSubmission{}).
+		Where("id = ? AND confirmed_at IS NULL", submission.ID).
+		Update("confirmed_at", now)
+	if marked.Error != nil {
+		return fmt.Errorf("failed to mark order confirmation: %v", marked.Error)
+	}
+	if marked.RowsAffected == 0 {
+		return nil
+	}
+	if err := p.confirm(ctx, order); err != nil {
+		if unmarkErr := p.db.WithContext(ctx).Model(&models.OrderSubmission{}).
+			Where("id = ?", submission.ID).Update("confirmed_at", nil).Error; unmarkErr != nil {
+			log.Printf("Failed to unmark confirmation of order submission %s: %v", submission.ID, unmarkErr)
+		}
+		return err
+	}
+	submission.ConfirmedAt = &now
+	return nil
+}
+
+// confirm queues the confirmation email, then confirms the order, and when
+// part of it ships later, in the shopper's chat
+func (p *OrderPipeline) confirm(ctx context.Context, order *Order) error {
+	body := fmt.Sprintf("Thanks for your order %s of %.2f %s. We'll let you know when it ships.",
+		order.OrderNumber, order.TotalAmount, order.Currency)
+	if instructions := p.orders.OfflinePaymentInstructions(order); instructions != "" {
+		body += "\n\nHow to pay: " + instructions
+	}
+	_, err := p.messages.Dispatch(ctx, OutboundMessageRequest{
+		UserID:   order.UserID,
+		Channel:  MessageChannelEmail,
+		Category: MessageCategoryTransactional,
+		Subject:  "Order " + order.OrderNumber + " confirmed",
+		Body:     body,
+	})
+	if err != nil && !errors.Is(err, ErrUserNotFound) {
+		return fmt.Errorf("failed to queue confirmation email: %v", err)
+	}
+
+	if p.notifier != nil {
+		p.notifier.NotifySession(order.SessionID, OrderConfirmationMessage, NewOrderConfirmation(order))
+		if backordered := BackorderedFulfillment(order); backordered != nil {
+			p.notifier.NotifySession(order.SessionID, FulfillmentUpdateMessage, NewFulfillmentNotice(order, backordered))
+		}
+	}
+	return nil
+}
+
+// advance records the stage a submission moved on to and tells the shopper.
+// A stage that couldn't be recorded only leaves the shopper behind, so the
+// work goes on.
+func (p *OrderPipeline) advance(ctx context.Context, submission *models.OrderSubmission, stage string) {
+	submission.Stage = stage
+	_ = p.save(ctx, submission, map[string]interface{}{"stage": stage})
+}
+
+// retry schedules another attempt with exponential backoff, or fails the
+// submission once it used MaxAttempts. Only failures to record it are returned.
+func (p *OrderPipeline) retry(ctx context.Context, submission *models.OrderSubmission, cause error, now time.Time) error {
+	if submission.Attempts >= p.config.MaxAttempts {
+		return p.fail(ctx, submission, cause)
+	}
+	next := now.Add(p.config.RetryInterval * time.Duration(1<<(submission.Attempts-1)))
+	submission.Error = cause.Error()
+	submission.NextAttemptAt = &next
+	submission.LockedUntil = nil
+	return p.save(ctx, submission, map[string]interface{}{"error": submission.Error, "next_attempt_at": next, "locked_until": nil})
+}
+
+// fail ends a submission, keeping the stage it failed at and the order rules
+// it broke. Only failures to record it are returned.
+func (p *OrderPipeline) fail(ctx context.Context, submission *models.OrderSubmission, cause error) error {
+	finished := time.Now()
+	submission.Status = OrderSubmissionFailed
+	submission.Error = cause.Error()
+	submission.FinishedAt = &finished
+	submission.LockedUntil = nil
+	updates := map[string]interface{}{
+		"status": submission.Status, "error": submission.Error, "finished_at": finished, "locked_until": nil,
+	}
+
+	var validationErr *OrderValidationError
+	if errors.As(cause, &validationErr) {
+		if encoded, err := json.Marshal(validationErr.Violations); err == nil {
+			submission.Violations = datatypes.JSON(encoded)
+			updates["violations"] = submission.Violations
+		}
+	}
+	return p.save(ctx, submission, updates)
+}
+
+// save records a change to a submission and tells the shopper
+func (p *OrderPipeline) save(ctx context.Context, submission *models.OrderSubmission, updates map[string]interface{}) error {
+	if err := p.db.WithContext(ctx).Model(&models.OrderSubmission{}).Where("id = ?", submission.ID).Updates(updates).Error; err != nil {
+		log.Printf("Failed to update order submission %s: %v", submission.ID, err)
+		return fmt.Errorf("failed to update order submission: %v", err)
+	}
+	if p.notifier != nil {
+		p.notifier.NotifyUser(submission.UserID, OrderSubmissionMessage, submission)
+	}
+	return nil
+}
+
+// isOrderRejection reports whether an order couldn't be created for reasons
+// the shopper has to fix, rather than ones another attempt may get past
+func isOrderRejection(err error) bool {
+	var validationErr *OrderValidationError
+	var conflict *InventoryConflictError
+	return errors.As(err, &validationErr) || errors.As(err, &conflict)
+}
+
+// needsPaymentIntent reports whether the order is paid through a payment
+// provider: offline payments and free orders aren't
+func needsPaymentIntent(order *Order) bool {
+	return order.PaymentProvider != PaymentProviderOffline && order.PaymentStatus != "paid" && order.TotalAmount > 0
+}
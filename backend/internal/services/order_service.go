@@ -2,9 +2,13 @@ package services
 
 import (
 	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/pkg/listquery"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -14,16 +18,71 @@ import (
 
 // OrderService handles order-related business logic
 type OrderService struct {
-	db *gorm.DB
+	db           *gorm.DB
+	pricing      *CustomerGroupService
+	validator    *OrderValidator
+	reservations *CartReservationService
+	offline      OfflinePaymentConfig
+	finance      *FinanceService
+	gifts        GiftOptionsConfig
+	deliveries   *DeliveryScheduleService
+	taxes        TaxConfig
+	chatEvents   *ChatAnalyticsService
+	exports      *OrderExportService
+	events       *EventStream
+	flashSales   *FlashSaleService
 }
 
 // NewOrderService creates a new OrderService
 func NewOrderService(db *gorm.DB) *OrderService {
 	return &OrderService{
-		db: db,
+		db:           db,
+		pricing:      NewCustomerGroupService(db),
+		validator:    NewOrderValidator(db),
+		reservations: NewCartReservationService(db, CartReservationConfigFromEnv()),
+		offline:      OfflinePaymentConfigFromEnv(),
+		finance:      NewFinanceService(db),
+		gifts:        GiftOptionsConfigFromEnv(),
+		deliveries:   NewDeliveryScheduleService(NewBusinessHoursService(db, StoreLocationFromEnv()), DeliveryConfigFromEnv()),
+		taxes:        TaxConfigFromEnv(),
+		chatEvents:   NewChatAnalyticsService(db),
+		exports:      NewOrderExportService(db, OrderExportConfigFromEnv()),
 	}
 }
 
+// WithPricingRules prices order lines through the given rules and records
+// the ones that applied on each line
+func (s *OrderService) WithPricingRules(rules *PricingRuleService) *OrderService {
+	s.pricing.WithPricingRules(rules)
+	return s
+}
+
+// WithOrderExports replaces the ERP export read from the environment that
+// paid orders are scheduled on
+func (s *OrderService) WithOrderExports(exports *OrderExportService) *OrderService {
+	s.exports = exports
+	return s
+}
+
+// WithEventStream publishes placed orders to the analytics stream
+func (s *OrderService) WithEventStream(events *EventStream) *OrderService {
+	s.events = events
+	return s
+}
+
+// WithFlashSales admits checkouts of flash sale products through the sale's
+// waiting room and holds shoppers to its purchase limit
+func (s *OrderService) WithFlashSales(flashSales *FlashSaleService) *OrderService {
+	s.flashSales = flashSales
+	return s
+}
+
+// WithOfflinePayments replaces the offline payment methods read from the environment
+func (s *OrderService) WithOfflinePayments(config OfflinePaymentConfig) *OrderService {
+	s.offline = config
+	return s
+}
+
 // CreateOrderRequest represents the request payload for creating an order
 type CreateOrderRequest struct {
 	UserID          uuid.UUID              `json:"user_id"`
@@ -33,6 +92,11 @@ type CreateOrderRequest struct {
 	BillingAddress  map[string]interface{} `json:"billing_address" binding:"required"`
 	PaymentMethod   string                 `json:"payment_method" binding:"required"`
 	Notes           string                 `json:"notes"`
+	AllowBackorder  bool                   `json:"allow_backorder"` // split out items that aren't in stock instead of failing
+	Gift            *GiftOptions           `json:"gift"`            // defaults to the gift options chosen on the cart
+	DeliveryDate    string                 `json:"delivery_date"`   // preferred delivery day, YYYY-MM-DD, from GET /delivery-slots
+	DeliveryCarrier string                 `json:"delivery_carrier"`
+	SubmissionID    *uuid.UUID             `json:"-"` // the order pipeline submission placing the order, which gets at most one
 }
 
 // OrderItemRequest represents an item in the order request
@@ -48,6 +112,37 @@ type UpdateOrderStatusRequest struct {
 	Notes  string `json:"notes"`
 }
 
+// OrderConfirmationMessage is the WebSocket message type sent to the
+// shopper's chat when their order is placed
+const OrderConfirmationMessage = "order_confirmation"
+
+// OrderConfirmation tells the shopper their order went through and when it
+// should arrive
+type OrderConfirmation struct {
+	OrderID         uuid.UUID  `json:"order_id"`
+	OrderNumber     string     `json:"order_number"`
+	Message         string     `json:"message"`
+	TotalAmount     float64    `json:"total_amount"`
+	DeliveryDate    *time.Time `json:"delivery_date,omitempty"`
+	DeliveryCarrier string     `json:"delivery_carrier,omitempty"`
+}
+
+// NewOrderConfirmation describes a newly placed order for the shopper's chat
+func NewOrderConfirmation(order *Order) *OrderConfirmation {
+	message := fmt.Sprintf("Thanks! Order %s is confirmed.", order.OrderNumber)
+	if delivery := deliveryConfirmation(order); delivery != "" {
+		message += " " + delivery
+	}
+	return &OrderConfirmation{
+		OrderID:         order.ID,
+		OrderNumber:     order.OrderNumber,
+		Message:         message,
+		TotalAmount:     order.TotalAmount,
+		DeliveryDate:    order.DeliveryDate,
+		DeliveryCarrier: order.DeliveryCarrier,
+	}
+}
+
 // Order represents an order in the system (alias for models.Order)
 type Order = models.Order
 
@@ -55,9 +150,50 @@ type Order = models.Order
 type OrderItem = models.OrderItem
 
 // CreateOrder creates a new order
-func (s *OrderService) CreateOrder(req *CreateOrderRequest) (*Order, error) {
+func (s *OrderService) CreateOrder(ctx context.Context, req *CreateOrderRequest) (*Order, error) {
+	// Check the preferred delivery date against today's slots
+	slot, err := s.deliverySlot(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Checkouts of a running flash sale's products need the waiting room to
+	// have let the shopper through
+	sale, err := s.flashSales.checkoutSale(ctx, req, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return s.placeOrder(ctx, req, sale, slot)
+}
+
+// deliverySlot returns the slot of the request's preferred delivery date,
+// or nil when it has none
+func (s *OrderService) deliverySlot(ctx context.Context, req *CreateOrderRequest) (*DeliverySlot, error) {
+	if req.DeliveryDate == "" {
+		return nil, nil
+	}
+	return s.deliveries.Slot(ctx, req.DeliveryDate, req.DeliveryCarrier, time.Now())
+}
+
+// placeOrder creates the order of a checkout whose flash sale admission and
+// delivery slot were settled: sale is the flash sale it buys from, if any,
+// whose purchase limits still apply
+func (s *OrderService) placeOrder(ctx context.Context, req *CreateOrderRequest, sale *models.FlashSale, slot *DeliverySlot) (*Order, error) {
+	// A submission retried after its order was placed gets that order back
+	if req.SubmissionID != nil {
+		var placed Order
+		err := s.db.WithContext(ctx).Preload("Items").Preload("Items.Product").Scopes(withFulfillments).
+			Where("submission_id = ?", *req.SubmissionID).First(&placed).Error
+		if err == nil {
+			return &placed, nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("failed to check for a placed order: %v", err)
+		}
+	}
+
 	// Start transaction
-	tx := s.db.Begin()
+	tx := s.db.WithContext(ctx).Begin()
 	defer func() {
 		if r := recover(); r != nil {
 			tx.Rollback()
@@ -67,9 +203,27 @@ func (s *OrderService) CreateOrder(req *CreateOrderRequest) (*Order, error) {
 	// Generate order number
 	orderNumber := s.generateOrderNumber()
 
+	// Offline payments leave the order awaiting payment, holding its stock
+	// until the method's payment deadline
+	status := "pending"
+	var paymentProvider string
+	var paymentDueAt *time.Time
+	if isOfflinePaymentMethod(req.PaymentMethod) {
+		method, ok := s.offline.Method(req.PaymentMethod)
+		if !ok {
+			tx.Rollback()
+			return nil, fmt.Errorf("payment method %s is not available", req.PaymentMethod)
+		}
+		dueAt := time.Now().Add(method.ReservationTTL)
+		status = OrderStatusAwaitingPayment
+		paymentProvider = PaymentProviderOffline
+		paymentDueAt = &dueAt
+	}
+
 	// Calculate totals
 	var subtotal float64
 	var orderItems []OrderItem
+	var backorderedItems []OrderItem
 
 	for _, itemReq := range req.Items {
 		// Get product details
@@ -79,36 +233,92 @@ func (s *OrderService) CreateOrder(req *CreateOrderRequest) (*Order, error) {
 			return nil, fmt.Errorf("product not found: %v", err)
 		}
 
-		// Check inventory
-		if err := s.checkInventory(tx, itemReq.ProductID, itemReq.VariantID, itemReq.Quantity); err != nil {
+		// Check inventory, keeping only what can ship now when backorders are allowed
+		inStock, err := s.stockToShip(tx, req, itemReq)
+		if err != nil {
 			tx.Rollback()
 			return nil, err
 		}
 
-		// Calculate item total
-		unitPrice := product.Price
+		// Calculate item total at the customer's price, recording the group
+		// price and pricing rules it was reached with
+		quote, err := s.pricing.QuotePrice(ctx, &req.UserID, &product)
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		unitPrice := quote.UnitPrice
 		totalPrice := unitPrice * float64(itemReq.Quantity)
 		subtotal += totalPrice
 
+		var adjustments datatypes.JSON
+		if len(quote.Adjustments) > 0 {
+			encoded, err := json.Marshal(quote.Adjustments)
+			if err != nil {
+				tx.Rollback()
+				return nil, fmt.Errorf("failed to encode price adjustments: %v", err)
+			}
+			adjustments = datatypes.JSON(encoded)
+		}
+
 		// Create order item
 		orderItem := OrderItem{
-			ID:         uuid.New(),
-			OrderID:    uuid.New(), // Will be updated after order creation
-			ProductID:  itemReq.ProductID,
-			VariantID:  itemReq.VariantID,
-			Quantity:   itemReq.Quantity,
-			UnitPrice:  unitPrice,
-			TotalPrice: totalPrice,
-			CreatedAt:  time.Now(),
+			ID:               uuid.New(),
+			OrderID:          uuid.New(), // Will be updated after order creation
+			ProductID:        itemReq.ProductID,
+			VariantID:        itemReq.VariantID,
+			Quantity:         itemReq.Quantity,
+			UnitPrice:        unitPrice,
+			TotalPrice:       totalPrice,
+			PriceAdjustments: adjustments,
+			CreatedAt:        time.Now(),
+		}
+
+		// Split off the units that have to wait for stock
+		if backordered := itemReq.Quantity - inStock; backordered > 0 {
+			backorderItem := orderItem
+			backorderItem.ID = uuid.New()
+			backorderItem.Quantity = backordered
+			backorderItem.TotalPrice = unitPrice * float64(backordered)
+			backorderedItems = append(backorderedItems, backorderItem)
+
+			orderItem.Quantity = inStock
+			orderItem.TotalPrice = unitPrice * float64(inStock)
+		}
+		if orderItem.Quantity > 0 {
+			orderItems = append(orderItems, orderItem)
 		}
+	}
 
-		orderItems = append(orderItems, orderItem)
+	// Check the order against the configured order rules
+	if err := s.validator.Validate(ctx, OrderValidationInput{
+		Items:           req.Items,
+		Subtotal:        subtotal,
+		ShippingCountry: ShippingCountry(req.ShippingAddress),
+	}); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	// Each shopper may only buy a few of a flash sale's products
+	if err := s.flashSales.checkPurchaseLimit(tx, sale, req); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	// Gift wrap is charged as a flat fee on top of the items
+	gift, err := s.orderGiftOptions(tx, req)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
 	}
+	giftWrapAmount := s.gifts.wrapAmount(gift)
 
-	// Calculate tax and shipping (simplified)
-	taxAmount := subtotal * 0.08 // 8% tax
-	shippingAmount := 9.99       // Fixed shipping
-	totalAmount := subtotal + taxAmount + shippingAmount
+	// Tax at the destination's rate, extracted from tax-inclusive prices
+	// rather than added to them
+	tax := s.taxes.Breakdown(subtotal, ShippingCountry(req.ShippingAddress))
+	shippingAmount := orderShippingAmount
+	totalAmount := tax.Gross + shippingAmount + giftWrapAmount
 
 	// Marshal addresses to JSON
 	shippingJSON, err := json.Marshal(req.ShippingAddress)
@@ -129,32 +339,54 @@ func (s *OrderService) CreateOrder(req *CreateOrderRequest) (*Order, error) {
 		OrderNumber:     orderNumber,
 		UserID:          req.UserID,
 		SessionID:       req.SessionID,
-		Status:          "pending",
+		Status:          status,
 		Subtotal:        subtotal,
-		TaxAmount:       taxAmount,
+		TaxAmount:       tax.Tax,
+		TaxRate:         &tax.Rate,
+		TaxIncluded:     tax.Included,
 		ShippingAmount:  shippingAmount,
 		TotalAmount:     totalAmount,
 		Currency:        "USD",
 		PaymentStatus:   "pending",
+		PaymentMethod:   req.PaymentMethod,
+		PaymentProvider: paymentProvider,
+		PaymentDueAt:    paymentDueAt,
+		GiftWrap:        gift.GiftWrap,
+		GiftWrapAmount:  giftWrapAmount,
+		GiftMessage:     gift.GiftMessage,
 		ShippingAddress: datatypes.JSON(shippingJSON),
 		BillingAddress:  datatypes.JSON(billingJSON),
+		SubmissionID:    req.SubmissionID,
 		CreatedAt:       time.Now(),
 		UpdatedAt:       time.Now(),
 	}
 
+	if slot != nil {
+		order.DeliveryDate = s.deliveries.Date(slot.Date)
+		order.DeliveryCarrier = slot.Carrier
+		order.ShipBy = s.deliveries.Date(slot.ShipBy)
+	}
+
 	// Save order
 	if err := tx.Create(order).Error; err != nil {
 		tx.Rollback()
 		return nil, errors.New("failed to create order")
 	}
 
+	// Ship what's in stock now and the backordered items later
+	if err := s.splitFulfillments(tx, order.ID, orderItems, backorderedItems, order.CreatedAt); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
 	// Update order items with order ID
-	for i := range orderItems {
-		orderItems[i].OrderID = order.ID
+	allItems := append(append([]OrderItem{}, orderItems...), backorderedItems...)
+	for i := range allItems {
+		allItems[i].OrderID = order.ID
 	}
 
 	// Save order items
-	if err := tx.Create(&orderItems).Error; err != nil {
+	if err := tx.Create(&allItems).Error; err != nil {
 		tx.Rollback()
 		return nil, errors.New("failed to create order items")
 	}
@@ -165,23 +397,146 @@ func (s *OrderService) CreateOrder(req *CreateOrderRequest) (*Order, error) {
 		return nil, err
 	}
 
+	// The order now holds the stock, so the shopper's cart holds are settled
+	if err := s.reservations.consumeForOrder(tx, req.SessionID, &req.UserID); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
 	// Commit transaction
 	if err := tx.Commit().Error; err != nil {
 		return nil, errors.New("failed to commit order transaction")
 	}
 
 	// Load order with items
-	if err := s.db.Preload("Items").Preload("Items.Product").First(order, order.ID).Error; err != nil {
+	if err := s.db.WithContext(ctx).Preload("Items").Preload("Items.Product").Scopes(withFulfillments).First(order, order.ID).Error; err != nil {
 		return nil, errors.New("failed to load order details")
 	}
 
+	// Orders placed after chatting count towards the chat funnel
+	if err := s.chatEvents.AttributeOrder(ctx, order); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	s.events.OrderCreated(order)
+
 	return order, nil
 }
 
+// stockToShip returns how many of the requested units can ship now. Without
+// AllowBackorder the order fails unless all of them can.
+func (s *OrderService) stockToShip(tx *gorm.DB, req *CreateOrderRequest, itemReq OrderItemRequest) (int, error) {
+	err := s.checkInventory(tx, itemReq.ProductID, itemReq.VariantID, itemReq.Quantity)
+	if err == nil {
+		// Stock held in other shoppers' carts can't be sold
+		err = s.reservations.checkUnheld(tx, req.SessionID, &req.UserID, itemReq.ProductID, itemReq.VariantID, itemReq.Quantity)
+	}
+	if err == nil {
+		return itemReq.Quantity, nil
+	}
+
+	var conflict *InventoryConflictError
+	if !req.AllowBackorder || !errors.As(err, &conflict) {
+		return 0, err
+	}
+	if conflict.Available < 0 {
+		return 0, nil
+	}
+	return conflict.Available, nil
+}
+
+// withFulfillments preloads an order's fulfillments with their items
+func withFulfillments(db *gorm.DB) *gorm.DB {
+	return db.Preload("Fulfillments", func(db *gorm.DB) *gorm.DB {
+		return db.Order("sequence ASC")
+	}).Preload("Fulfillments.Items")
+}
+
+// OrderQuote is what an order will cost, worked out as CreateOrder does
+type OrderQuote struct {
+	Subtotal       float64 `json:"subtotal"`
+	TaxAmount      float64 `json:"tax_amount"`
+	TaxRate        float64 `json:"tax_rate"`
+	TaxIncluded    bool    `json:"tax_included"` // the subtotal already includes TaxAmount
+	ShippingAmount float64 `json:"shipping_amount"`
+	GiftWrapAmount float64 `json:"gift_wrap_amount"`
+	TotalAmount    float64 `json:"total_amount"`
+	Currency       string  `json:"currency"`
+}
+
+// QuoteOrder works out an order's totals for its shipping address, checking
+// its stock and the order rules, without creating it
+func (s *OrderService) QuoteOrder(ctx context.Context, req *CreateOrderRequest) (*OrderQuote, error) {
+	db := s.db.WithContext(ctx)
+
+	var subtotal float64
+	for _, itemReq := range req.Items {
+		var product models.Product
+		if err := db.Where("id = ?", itemReq.ProductID).First(&product).Error; err != nil {
+			return nil, fmt.Errorf("product not found: %v", err)
+		}
+		if _, err := s.stockToShip(db, req, itemReq); err != nil {
+			return nil, err
+		}
+		unitPrice, err := s.pricing.UnitPrice(ctx, &req.UserID, &product)
+		if err != nil {
+			return nil, err
+		}
+		subtotal += unitPrice * float64(itemReq.Quantity)
+	}
+
+	if err := s.validator.Validate(ctx, OrderValidationInput{
+		Items:           req.Items,
+		Subtotal:        subtotal,
+		ShippingCountry: ShippingCountry(req.ShippingAddress),
+	}); err != nil {
+		return nil, err
+	}
+
+	gift, err := s.orderGiftOptions(db, req)
+	if err != nil {
+		return nil, err
+	}
+	giftWrapAmount := s.gifts.wrapAmount(gift)
+	tax := s.taxes.Breakdown(subtotal, ShippingCountry(req.ShippingAddress))
+
+	return &OrderQuote{
+		Subtotal:       subtotal,
+		TaxAmount:      tax.Tax,
+		TaxRate:        tax.Rate,
+		TaxIncluded:    tax.Included,
+		ShippingAmount: orderShippingAmount,
+		GiftWrapAmount: giftWrapAmount,
+		TotalAmount:    tax.Gross + orderShippingAmount + giftWrapAmount,
+		Currency:       "USD",
+	}, nil
+}
+
+// ValidateOrder checks an order against the order rules without creating it
+func (s *OrderService) ValidateOrder(ctx context.Context, req *CreateOrderRequest) error {
+	var subtotal float64
+	for _, itemReq := range req.Items {
+		var product models.Product
+		if err := s.db.WithContext(ctx).Where("id = ?", itemReq.ProductID).First(&product).Error; err != nil {
+			return fmt.Errorf("product not found: %v", err)
+		}
+		unitPrice, err := s.pricing.UnitPrice(ctx, &req.UserID, &product)
+		if err != nil {
+			return err
+		}
+		subtotal += unitPrice * float64(itemReq.Quantity)
+	}
+
+	return s.validator.Validate(ctx, OrderValidationInput{
+		Items:           req.Items,
+		Subtotal:        subtotal,
+		ShippingCountry: ShippingCountry(req.ShippingAddress),
+	})
+}
+
 // GetOrderByID retrieves an order by ID
-func (s *OrderService) GetOrderByID(orderID uuid.UUID) (*Order, error) {
+func (s *OrderService) GetOrderByID(ctx context.Context, orderID uuid.UUID) (*Order, error) {
 	var order Order
-	if err := s.db.Preload("Items").Preload("Items.Product").Preload("Items.Variant").Where("id = ?", orderID).First(&order).Error; err != nil {
+	if err := s.db.WithContext(ctx).Preload("Items").Preload("Items.Product").Preload("Items.Variant").Scopes(withFulfillments).Where("id = ?", orderID).First(&order).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, errors.New("order not found")
 		}
@@ -192,9 +547,9 @@ func (s *OrderService) GetOrderByID(orderID uuid.UUID) (*Order, error) {
 }
 
 // GetOrderByNumber retrieves an order by order number
-func (s *OrderService) GetOrderByNumber(orderNumber string) (*Order, error) {
+func (s *OrderService) GetOrderByNumber(ctx context.Context, orderNumber string) (*Order, error) {
 	var order Order
-	if err := s.db.Preload("Items").Preload("Items.Product").Preload("Items.Variant").Where("order_number = ?", orderNumber).First(&order).Error; err != nil {
+	if err := s.db.WithContext(ctx).Preload("Items").Preload("Items.Product").Preload("Items.Variant").Scopes(withFulfillments).Where("order_number = ?", orderNumber).First(&order).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, errors.New("order not found")
 		}
@@ -204,23 +559,41 @@ func (s *OrderService) GetOrderByNumber(orderNumber string) (*Order, error) {
 	return &order, nil
 }
 
-// GetUserOrders retrieves orders for a specific user
-func (s *OrderService) GetUserOrders(userID uuid.UUID, page, limit int) ([]Order, int64, error) {
+// UserOrderListSchema is what a customer's order list can be filtered and
+// sorted by
+var UserOrderListSchema = &listquery.Schema{
+	Filters: map[string]listquery.Field{
+		"status":         listquery.Column("status", listquery.String),
+		"payment_status": listquery.Column("payment_status", listquery.String),
+		"order_number":   listquery.Column("order_number", listquery.String),
+		"total_amount":   listquery.Column("total_amount", listquery.Number),
+		"created_at":     listquery.Column("created_at", listquery.Time),
+	},
+	Sorts: map[string]string{
+		"created_at":   "created_at",
+		"total_amount": "total_amount",
+		"status":       "status",
+	},
+	DefaultSort: "-created_at",
+	TieBreaker:  "id",
+	DefaultSize: 10,
+	MaxSize:     100,
+}
+
+// GetUserOrders retrieves a page of a user's orders
+func (s *OrderService) GetUserOrders(ctx context.Context, userID uuid.UUID, list *listquery.Query) ([]Order, int64, error) {
 	var orders []Order
 	var total int64
 
 	// Count total orders
-	if err := s.db.Model(&Order{}).Where("user_id = ?", userID).Count(&total).Error; err != nil {
+	query := list.Filter(s.db.WithContext(ctx).Model(&Order{}).Where("user_id = ?", userID))
+	if err := query.Count(&total).Error; err != nil {
 		return nil, 0, errors.New("failed to count orders")
 	}
 
 	// Get orders with pagination
-	offset := (page - 1) * limit
-	if err := s.db.Preload("Items").Preload("Items.Product").
-		Where("user_id = ?", userID).
-		Order("created_at DESC").
-		Offset(offset).
-		Limit(limit).
+	if err := list.Paginate(list.Order(query)).
+		Preload("Items").Preload("Items.Product").Scopes(withFulfillments).
 		Find(&orders).Error; err != nil {
 		return nil, 0, errors.New("failed to retrieve orders")
 	}
@@ -228,10 +601,42 @@ func (s *OrderService) GetUserOrders(userID uuid.UUID, page, limit int) ([]Order
 	return orders, total, nil
 }
 
+// OrderSalesSummary counts the orders placed in a period and their revenue
+type OrderSalesSummary struct {
+	From              time.Time `json:"from"`
+	To                time.Time `json:"to"`
+	Orders            int64     `json:"orders"`
+	Revenue           float64   `json:"revenue"`
+	AverageOrderValue float64   `json:"average_order_value"`
+}
+
+// SalesSummary counts the orders placed in [from, to) and sums their totals.
+// Cancelled orders are left out.
+func (s *OrderService) SalesSummary(ctx context.Context, from, to time.Time) (*OrderSalesSummary, error) {
+	summary := &OrderSalesSummary{From: from, To: to}
+	var totals struct {
+		Orders  int64
+		Revenue float64
+	}
+	if err := s.db.WithContext(ctx).Model(&Order{}).
+		Select("COUNT(*) AS orders, COALESCE(SUM(total_amount), 0) AS revenue").
+		Where("created_at >= ? AND created_at < ? AND status <> ?", from, to, "cancelled").
+		Scan(&totals).Error; err != nil {
+		return nil, fmt.Errorf("failed to summarize orders: %v", err)
+	}
+
+	summary.Orders = totals.Orders
+	summary.Revenue = roundCents(totals.Revenue)
+	if totals.Orders > 0 {
+		summary.AverageOrderValue = roundCents(totals.Revenue / float64(totals.Orders))
+	}
+	return summary, nil
+}
+
 // UpdateOrderStatus updates the status of an order
-func (s *OrderService) UpdateOrderStatus(orderID uuid.UUID, req *UpdateOrderStatusRequest) (*Order, error) {
+func (s *OrderService) UpdateOrderStatus(ctx context.Context, orderID uuid.UUID, req *UpdateOrderStatusRequest) (*Order, error) {
 	var order Order
-	if err := s.db.Where("id = ?", orderID).First(&order).Error; err != nil {
+	if err := s.db.WithContext(ctx).Where("id = ?", orderID).First(&order).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, errors.New("order not found")
 		}
@@ -242,12 +647,12 @@ func (s *OrderService) UpdateOrderStatus(orderID uuid.UUID, req *UpdateOrderStat
 	order.Status = req.Status
 	order.UpdatedAt = time.Now()
 
-	if err := s.db.Save(&order).Error; err != nil {
+	if err := s.db.WithContext(ctx).Save(&order).Error; err != nil {
 		return nil, errors.New("failed to update order status")
 	}
 
 	// Load updated order with items
-	if err := s.db.Preload("Items").Preload("Items.Product").First(&order, order.ID).Error; err != nil {
+	if err := s.db.WithContext(ctx).Preload("Items").Preload("Items.Product").First(&order, order.ID).Error; err != nil {
 		return nil, errors.New("failed to load updated order")
 	}
 
@@ -255,9 +660,9 @@ func (s *OrderService) UpdateOrderStatus(orderID uuid.UUID, req *UpdateOrderStat
 }
 
 // UpdatePaymentStatus updates the payment status of an order
-func (s *OrderService) UpdatePaymentStatus(orderID uuid.UUID, paymentStatus string, paymentIntentID string) (*Order, error) {
+func (s *OrderService) UpdatePaymentStatus(ctx context.Context, orderID uuid.UUID, paymentStatus string, paymentIntentID string) (*Order, error) {
 	var order Order
-	if err := s.db.Where("id = ?", orderID).First(&order).Error; err != nil {
+	if err := s.db.WithContext(ctx).Where("id = ?", orderID).First(&order).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, errors.New("order not found")
 		}
@@ -265,23 +670,58 @@ func (s *OrderService) UpdatePaymentStatus(orderID uuid.UUID, paymentStatus stri
 	}
 
 	// Update payment status
+	newlyPaid := paymentStatus == "paid" && order.PaymentStatus != "paid"
 	order.PaymentStatus = paymentStatus
 	if paymentIntentID != "" {
 		order.PaymentIntentID = paymentIntentID
 	}
 	order.UpdatedAt = time.Now()
 
-	if err := s.db.Save(&order).Error; err != nil {
+	if err := s.db.WithContext(ctx).Save(&order).Error; err != nil {
 		return nil, errors.New("failed to update payment status")
 	}
 
+	if newlyPaid {
+		if err := s.finance.RecordCharge(ctx, &order); err != nil {
+			log.Printf("Failed to record charge for order %s: %v", order.ID, err)
+		}
+		if err := s.exports.Enqueue(ctx, order.ID); err != nil {
+			log.Printf("Failed to schedule export of order %s: %v", order.ID, err)
+		}
+	}
+
+	return &order, nil
+}
+
+// UpdatePaymentProvider records which payment provider is taking an order's payment
+func (s *OrderService) UpdatePaymentProvider(ctx context.Context, orderID uuid.UUID, provider string) error {
+	result := s.db.WithContext(ctx).Model(&Order{}).Where("id = ?", orderID).
+		Updates(map[string]interface{}{"payment_provider": provider, "updated_at": time.Now()})
+	if result.Error != nil {
+		return errors.New("failed to update payment provider")
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("order not found")
+	}
+	return nil
+}
+
+// GetOrderByPaymentIntentID retrieves the order paid by a payment intent
+func (s *OrderService) GetOrderByPaymentIntentID(ctx context.Context, paymentIntentID string) (*Order, error) {
+	var order Order
+	if err := s.db.WithContext(ctx).Where("payment_intent_id = ?", paymentIntentID).First(&order).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("order not found")
+		}
+		return nil, errors.New("failed to fetch order")
+	}
 	return &order, nil
 }
 
 // CancelOrder cancels an order and releases inventory
-func (s *OrderService) CancelOrder(orderID uuid.UUID) (*Order, error) {
+func (s *OrderService) CancelOrder(ctx context.Context, orderID uuid.UUID) (*Order, error) {
 	// Start transaction
-	tx := s.db.Begin()
+	tx := s.db.WithContext(ctx).Begin()
 	defer func() {
 		if r := recover(); r != nil {
 			tx.Rollback()
@@ -289,7 +729,7 @@ func (s *OrderService) CancelOrder(orderID uuid.UUID) (*Order, error) {
 	}()
 
 	var order Order
-	if err := tx.Preload("Items").Where("id = ?", orderID).First(&order).Error; err != nil {
+	if err := tx.Preload("Items").Preload("Fulfillments").Where("id = ?", orderID).First(&order).Error; err != nil {
 		tx.Rollback()
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, errors.New("order not found")
@@ -298,17 +738,38 @@ func (s *OrderService) CancelOrder(orderID uuid.UUID) (*Order, error) {
 	}
 
 	// Check if order can be cancelled
-	if order.Status == "shipped" || order.Status == "delivered" {
+	if order.Status == "shipped" || order.Status == "delivered" || order.Status == "partially_shipped" {
 		tx.Rollback()
 		return nil, errors.New("cannot cancel shipped or delivered orders")
 	}
 
-	// Release inventory
-	if err := s.releaseInventory(tx, order.Items); err != nil {
+	// Release inventory, skipping backordered items that never reserved any
+	unreserved := map[uuid.UUID]bool{}
+	for _, fulfillment := range order.Fulfillments {
+		if fulfillment.Status == FulfillmentBackordered || fulfillment.Status == FulfillmentCancelled {
+			unreserved[fulfillment.ID] = true
+		}
+	}
+	var reservedItems []OrderItem
+	for _, item := range order.Items {
+		if item.FulfillmentID == nil || !unreserved[*item.FulfillmentID] {
+			reservedItems = append(reservedItems, item)
+		}
+	}
+	if err := s.releaseInventory(tx, reservedItems); err != nil {
 		tx.Rollback()
 		return nil, err
 	}
 
+	if err := tx.Model(&Fulfillment{}).Where("order_id = ?", order.ID).
+		Updates(map[string]interface{}{"status": FulfillmentCancelled, "updated_at": time.Now()}).Error; err != nil {
+		tx.Rollback()
+		return nil, errors.New("failed to cancel fulfillments")
+	}
+	for i := range order.Fulfillments {
+		order.Fulfillments[i].Status = FulfillmentCancelled
+	}
+
 	// Update order status
 	order.Status = "cancelled"
 	order.UpdatedAt = time.Now()
@@ -328,7 +789,7 @@ func (s *OrderService) CancelOrder(orderID uuid.UUID) (*Order, error) {
 
 // generateOrderNumber generates a unique order number
 func (s *OrderService) generateOrderNumber() string {
-	return fmt.Sprintf("ORD-%d", time.Now().Unix())
+	return fmt.Sprintf("ORD-%d-%s", time.Now().Unix(), strings.ToUpper(uuid.NewString()[:6]))
 }
 
 // checkInventory verifies inventory availability
@@ -350,7 +811,12 @@ func (s *OrderService) checkInventory(tx *gorm.DB, productID uuid.UUID, variantI
 	}
 
 	if inventory.QuantityAvailable < quantity {
-		return errors.New("insufficient inventory")
+		return &InventoryConflictError{
+			ProductID: productID,
+			VariantID: variantID,
+			Requested: quantity,
+			Available: inventory.QuantityAvailable,
+		}
 	}
 
 	return nil
@@ -372,13 +838,29 @@ func (s *OrderService) reserveInventory(tx *gorm.DB, orderItems []OrderItem) err
 			return fmt.Errorf("inventory not found for product %s", item.ProductID)
 		}
 
-		// Update inventory
-		inventory.QuantityAvailable -= item.Quantity
-		inventory.QuantityReserved += item.Quantity
-
-		if err := tx.Save(&inventory).Error; err != nil {
+		// Update inventory only if the stock is still there, so a concurrent
+		// checkout that took the last units is reported as a conflict
+		result := tx.Model(&models.Inventory{}).
+			Where("id = ? AND quantity_available >= ?", inventory.ID, item.Quantity).
+			Updates(map[string]interface{}{
+				"quantity_available": gorm.Expr("quantity_available - ?", item.Quantity),
+				"quantity_reserved":  gorm.Expr("quantity_reserved + ?", item.Quantity),
+			})
+		if result.Error != nil {
 			return fmt.Errorf("failed to reserve inventory for product %s", item.ProductID)
 		}
+		if result.RowsAffected == 0 {
+			var current models.Inventory
+			if err := tx.Where("id = ?", inventory.ID).First(&current).Error; err != nil {
+				current = inventory
+			}
+			return &InventoryConflictError{
+				ProductID: item.ProductID,
+				VariantID: item.VariantID,
+				Requested: item.Quantity,
+				Available: current.QuantityAvailable,
+			}
+		}
 	}
 
 	return nil
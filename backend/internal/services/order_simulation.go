@@ -0,0 +1,193 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrInvalidOrderSimulation is returned when a simulated cart can't be priced
+var ErrInvalidOrderSimulation = errors.New("invalid order simulation")
+
+// OrderSimulationRequest is a hypothetical cart to price
+type OrderSimulationRequest struct {
+	Items           []OrderItemRequest `json:"items" binding:"required"`
+	UserID          *uuid.UUID         `json:"user_id"`          // price as this customer; a guest when empty
+	CustomerGroup   string             `json:"customer_group"`   // price as a signed-in member of this group instead
+	ShippingCountry string             `json:"shipping_country"` // tax and order rules for this destination
+	GiftWrap        bool               `json:"gift_wrap"`
+	At              *time.Time         `json:"at"` // price at this moment instead of now, for scheduled and time-of-day rules
+}
+
+// SimulatedOrderLine is how one line of a simulated cart is priced
+type SimulatedOrderLine struct {
+	ProductID   uuid.UUID                `json:"product_id"`
+	VariantID   *uuid.UUID               `json:"variant_id,omitempty"`
+	Name        string                   `json:"name"`
+	Quantity    int                      `json:"quantity"`
+	ListPrice   float64                  `json:"list_price"` // the catalog price
+	UnitPrice   float64                  `json:"unit_price"`
+	TotalPrice  float64                  `json:"total_price"`
+	Adjustments []models.PriceAdjustment `json:"adjustments"` // group price and pricing rules, in the order applied
+	InStock     int                      `json:"in_stock"`    // units that could ship now
+	StockIssue  string                   `json:"stock_issue,omitempty"`
+}
+
+// OrderSimulation is what checkout would charge for a cart. Nothing is
+// created or reserved to work it out.
+type OrderSimulation struct {
+	CustomerGroup   *models.CustomerGroup `json:"customer_group"` // nil when no group prices the shopper
+	SignedIn        bool                  `json:"signed_in"`
+	PricedAt        time.Time             `json:"priced_at"`
+	Lines           []SimulatedOrderLine  `json:"lines"`
+	ListSubtotal    float64               `json:"list_subtotal"` // the lines at catalog prices
+	Subtotal        float64               `json:"subtotal"`
+	DiscountAmount  float64               `json:"discount_amount"` // list subtotal minus subtotal
+	ShippingCountry string                `json:"shipping_country"`
+	Tax             TaxBreakdown          `json:"tax"`
+	ShippingAmount  float64               `json:"shipping_amount"`
+	GiftWrapAmount  float64               `json:"gift_wrap_amount"`
+	TotalAmount     float64               `json:"total_amount"`
+	Currency        string                `json:"currency"`
+	Violations      []OrderViolation      `json:"violations"` // order rules checkout would refuse the cart for
+}
+
+// SimulateOrder prices a hypothetical cart the way CreateOrder would: each
+// line at the shopper's group price taken through the pricing rules, then
+// tax, shipping and gift wrap on the subtotal. Stock shortfalls and broken
+// order rules are reported instead of failing the simulation.
+func (s *OrderService) SimulateOrder(ctx context.Context, req *OrderSimulationRequest) (*OrderSimulation, error) {
+	if len(req.Items) == 0 {
+		return nil, fmt.Errorf("%w: no items", ErrInvalidOrderSimulation)
+	}
+	db := s.db.WithContext(ctx)
+
+	now := time.Now()
+	if req.At != nil {
+		now = *req.At
+	}
+
+	// The shopper's own group, or the group asked for
+	signedIn := req.UserID != nil && *req.UserID != uuid.Nil
+	if signedIn {
+		var count int64
+		if err := db.Model(&models.User{}).Where("id = ?", *req.UserID).Count(&count).Error; err != nil {
+			return nil, fmt.Errorf("failed to check user: %v", err)
+		}
+		if count == 0 {
+			return nil, fmt.Errorf("%w: user not found", ErrInvalidOrderSimulation)
+		}
+	}
+	var group *models.CustomerGroup
+	var err error
+	if req.CustomerGroup != "" {
+		group, err = s.pricing.groupBySlug(ctx, req.CustomerGroup)
+		signedIn = true
+	} else {
+		group, err = s.pricing.ResolveGroup(ctx, req.UserID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	productIDs := make([]uuid.UUID, len(req.Items))
+	for i, item := range req.Items {
+		if item.Quantity < 1 {
+			return nil, fmt.Errorf("%w: quantity must be at least 1", ErrInvalidOrderSimulation)
+		}
+		productIDs[i] = item.ProductID
+	}
+	var found []models.Product
+	if err := db.Where("id IN ?", productIDs).Find(&found).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch products: %v", err)
+	}
+	byID := make(map[uuid.UUID]models.Product, len(found))
+	for _, product := range found {
+		byID[product.ID] = product
+	}
+	products := make([]models.Product, len(req.Items))
+	for i, item := range req.Items {
+		product, ok := byID[item.ProductID]
+		if !ok {
+			return nil, fmt.Errorf("%w: product %s not found", ErrInvalidOrderSimulation, item.ProductID)
+		}
+		products[i] = product
+	}
+
+	quotes, err := s.pricing.quoteAs(ctx, group, signedIn, products, now)
+	if err != nil {
+		return nil, err
+	}
+
+	simulation := &OrderSimulation{
+		CustomerGroup:   group,
+		SignedIn:        signedIn,
+		PricedAt:        now,
+		Lines:           make([]SimulatedOrderLine, len(req.Items)),
+		ShippingCountry: strings.ToUpper(strings.TrimSpace(req.ShippingCountry)),
+		Currency:        "USD",
+		Violations:      []OrderViolation{},
+	}
+
+	// Check stock as checkout would, counting what could ship now
+	stockReq := &CreateOrderRequest{AllowBackorder: true}
+	if req.UserID != nil {
+		stockReq.UserID = *req.UserID
+	}
+	for i, item := range req.Items {
+		line := SimulatedOrderLine{
+			ProductID:   item.ProductID,
+			VariantID:   item.VariantID,
+			Name:        products[i].Name,
+			Quantity:    item.Quantity,
+			ListPrice:   products[i].Price,
+			UnitPrice:   quotes[i].UnitPrice,
+			TotalPrice:  quotes[i].UnitPrice * float64(item.Quantity),
+			Adjustments: quotes[i].Adjustments,
+		}
+		if line.Adjustments == nil {
+			line.Adjustments = []models.PriceAdjustment{}
+		}
+
+		inStock, err := s.stockToShip(db, stockReq, item)
+		switch {
+		case err != nil:
+			line.StockIssue = err.Error()
+		case inStock < item.Quantity:
+			line.InStock = inStock
+			line.StockIssue = fmt.Sprintf("only %d of %d can ship now", inStock, item.Quantity)
+		default:
+			line.InStock = inStock
+		}
+
+		simulation.Lines[i] = line
+		simulation.ListSubtotal += line.ListPrice * float64(item.Quantity)
+		simulation.Subtotal += line.TotalPrice
+	}
+	simulation.ListSubtotal = roundCents(simulation.ListSubtotal)
+	simulation.Subtotal = roundCents(simulation.Subtotal)
+	simulation.DiscountAmount = roundCents(simulation.ListSubtotal - simulation.Subtotal)
+
+	err = s.validator.Validate(ctx, OrderValidationInput{
+		Items:           req.Items,
+		Subtotal:        simulation.Subtotal,
+		ShippingCountry: simulation.ShippingCountry,
+	})
+	var validationErr *OrderValidationError
+	if errors.As(err, &validationErr) {
+		simulation.Violations = validationErr.Violations
+	} else if err != nil {
+		return nil, err
+	}
+
+	simulation.Tax = s.taxes.Breakdown(simulation.Subtotal, simulation.ShippingCountry)
+	simulation.ShippingAmount = orderShippingAmount
+	simulation.GiftWrapAmount = s.gifts.wrapAmount(GiftOptions{GiftWrap: req.GiftWrap})
+	simulation.TotalAmount = roundCents(simulation.Tax.Gross + simulation.ShippingAmount + simulation.GiftWrapAmount)
+	return simulation, nil
+}
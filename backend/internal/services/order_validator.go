@@ -0,0 +1,274 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// Order rule types
+const (
+	OrderRuleMinOrderTotal       = "min_order_total"
+	OrderRuleRestrictedCountries = "restricted_shipping_countries"
+	OrderRuleQuantityMultiple    = "quantity_multiple"
+)
+
+// Violation codes returned by the OrderValidator
+const (
+	ViolationMinOrderTotal      = "min_order_total"
+	ViolationShippingRestricted = "shipping_country_restricted"
+	ViolationQuantityMultiple   = "quantity_multiple"
+)
+
+// ErrOrderRuleNotFound is returned when an order rule does not exist
+var ErrOrderRuleNotFound = errors.New("order rule not found")
+
+// OrderViolation is one failed order rule
+type OrderViolation struct {
+	Code      string     `json:"code"`
+	Message   string     `json:"message"`
+	RuleID    uuid.UUID  `json:"rule_id"`
+	ProductID *uuid.UUID `json:"product_id,omitempty"`
+}
+
+// OrderValidationError is returned when an order breaks one or more rules
+type OrderValidationError struct {
+	Violations []OrderViolation `json:"violations"`
+}
+
+func (e *OrderValidationError) Error() string {
+	messages := make([]string, len(e.Violations))
+	for i, violation := range e.Violations {
+		messages[i] = violation.Message
+	}
+	return "order validation failed: " + strings.Join(messages, "; ")
+}
+
+// OrderValidationInput is an order about to be created
+type OrderValidationInput struct {
+	Items           []OrderItemRequest
+	Subtotal        float64
+	ShippingCountry string
+}
+
+// OrderRuleRequest creates or updates an order rule
+type OrderRuleRequest struct {
+	Type      string     `json:"type" binding:"required"`
+	ProductID *uuid.UUID `json:"product_id"`
+	MinTotal  float64    `json:"min_total" binding:"min=0"`
+	Multiple  int        `json:"multiple" binding:"min=0"`
+	Countries []string   `json:"countries"`
+	Message   string     `json:"message"`
+	IsActive  *bool      `json:"is_active"`
+}
+
+// OrderValidator evaluates the configured order rules before an order is created
+type OrderValidator struct {
+	db *gorm.DB
+}
+
+// NewOrderValidator creates a new OrderValidator
+func NewOrderValidator(db *gorm.DB) *OrderValidator {
+	return &OrderValidator{
+		db: db,
+	}
+}
+
+// ShippingCountry reads the country code from a shipping address
+func ShippingCountry(address map[string]interface{}) string {
+	country, _ := address["country"].(string)
+	return strings.ToUpper(strings.TrimSpace(country))
+}
+
+// Validate checks an order against every active rule and returns an
+// *OrderValidationError listing all violations, or nil if the order passes
+func (v *OrderValidator) Validate(ctx context.Context, input OrderValidationInput) error {
+	var rules []models.OrderRule
+	if err := v.db.WithContext(ctx).Where("is_active = ?", true).Order("created_at ASC").Find(&rules).Error; err != nil {
+		return fmt.Errorf("failed to fetch order rules: %v", err)
+	}
+
+	quantities := map[uuid.UUID]int{}
+	for _, item := range input.Items {
+		quantities[item.ProductID] += item.Quantity
+	}
+
+	violations := []OrderViolation{}
+	for _, rule := range rules {
+		if rule.ProductID != nil {
+			if _, ordered := quantities[*rule.ProductID]; !ordered {
+				continue
+			}
+		}
+
+		switch rule.Type {
+		case OrderRuleMinOrderTotal:
+			if input.Subtotal < rule.MinTotal {
+				violations = append(violations, ruleViolation(rule, ViolationMinOrderTotal,
+					fmt.Sprintf("order total must be at least %.2f", rule.MinTotal)))
+			}
+
+		case OrderRuleRestrictedCountries:
+			if input.ShippingCountry == "" {
+				continue
+			}
+			var countries []string
+			if len(rule.Countries) > 0 {
+				if err := json.Unmarshal(rule.Countries, &countries); err != nil {
+					return fmt.Errorf("failed to parse countries of order rule %s: %v", rule.ID, err)
+				}
+			}
+			for _, country := range countries {
+				if strings.EqualFold(country, input.ShippingCountry) {
+					violations = append(violations, ruleViolation(rule, ViolationShippingRestricted,
+						fmt.Sprintf("cannot ship to %s", input.ShippingCountry)))
+					break
+				}
+			}
+
+		case OrderRuleQuantityMultiple:
+			if rule.Multiple <= 1 {
+				continue
+			}
+			for productID, quantity := range quantities {
+				if rule.ProductID != nil && productID != *rule.ProductID {
+					continue
+				}
+				if quantity%rule.Multiple != 0 {
+					id := productID
+					violation := ruleViolation(rule, ViolationQuantityMultiple,
+						fmt.Sprintf("quantity must be a multiple of %d", rule.Multiple))
+					violation.ProductID = &id
+					violations = append(violations, violation)
+				}
+			}
+		}
+	}
+
+	if len(violations) > 0 {
+		return &OrderValidationError{Violations: violations}
+	}
+	return nil
+}
+
+// ruleViolation builds a violation, preferring the rule's own message
+func ruleViolation(rule models.OrderRule, code, message string) OrderViolation {
+	if rule.Message != "" {
+		message = rule.Message
+	}
+	return OrderViolation{
+		Code:      code,
+		Message:   message,
+		RuleID:    rule.ID,
+		ProductID: rule.ProductID,
+	}
+}
+
+// ListRules returns all order rules
+func (v *OrderValidator) ListRules(ctx context.Context) ([]models.OrderRule, error) {
+	var rules []models.OrderRule
+	if err := v.db.WithContext(ctx).Order("created_at ASC").Find(&rules).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch order rules: %v", err)
+	}
+	return rules, nil
+}
+
+// CreateRule adds an order rule
+func (v *OrderValidator) CreateRule(ctx context.Context, req OrderRuleRequest) (*models.OrderRule, error) {
+	rule := &models.OrderRule{
+		ID:        uuid.New(),
+		IsActive:  true,
+		CreatedAt: time.Now(),
+	}
+	if err := applyOrderRuleRequest(rule, req); err != nil {
+		return nil, err
+	}
+
+	if err := v.db.WithContext(ctx).Create(rule).Error; err != nil {
+		return nil, fmt.Errorf("failed to create order rule: %v", err)
+	}
+	return rule, nil
+}
+
+// UpdateRule replaces an order rule's settings
+func (v *OrderValidator) UpdateRule(ctx context.Context, id uuid.UUID, req OrderRuleRequest) (*models.OrderRule, error) {
+	var rule models.OrderRule
+	if err := v.db.WithContext(ctx).Where("id = ?", id).First(&rule).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrOrderRuleNotFound
+		}
+		return nil, fmt.Errorf("failed to fetch order rule: %v", err)
+	}
+	if err := applyOrderRuleRequest(&rule, req); err != nil {
+		return nil, err
+	}
+
+	if err := v.db.WithContext(ctx).Save(&rule).Error; err != nil {
+		return nil, fmt.Errorf("failed to update order rule: %v", err)
+	}
+	return &rule, nil
+}
+
+// DeleteRule removes an order rule
+func (v *OrderValidator) DeleteRule(ctx context.Context, id uuid.UUID) error {
+	result := v.db.WithContext(ctx).Where("id = ?", id).Delete(&models.OrderRule{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete order rule: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrOrderRuleNotFound
+	}
+	return nil
+}
+
+// applyOrderRuleRequest validates a rule request and copies it onto the rule
+func applyOrderRuleRequest(rule *models.OrderRule, req OrderRuleRequest) error {
+	switch req.Type {
+	case OrderRuleMinOrderTotal:
+		if req.MinTotal <= 0 {
+			return fmt.Errorf("min_total must be greater than zero")
+		}
+	case OrderRuleRestrictedCountries:
+		if req.ProductID == nil {
+			return fmt.Errorf("product_id is required for %s rules", req.Type)
+		}
+		if len(req.Countries) == 0 {
+			return fmt.Errorf("countries must not be empty")
+		}
+	case OrderRuleQuantityMultiple:
+		if req.Multiple < 2 {
+			return fmt.Errorf("multiple must be at least 2")
+		}
+	default:
+		return fmt.Errorf("unknown order rule type: %s", req.Type)
+	}
+
+	countries := make([]string, len(req.Countries))
+	for i, country := range req.Countries {
+		countries[i] = strings.ToUpper(strings.TrimSpace(country))
+	}
+	countriesJSON, err := json.Marshal(countries)
+	if err != nil {
+		return fmt.Errorf("failed to marshal countries: %v", err)
+	}
+
+	rule.Type = req.Type
+	rule.ProductID = req.ProductID
+	rule.MinTotal = req.MinTotal
+	rule.Multiple = req.Multiple
+	rule.Countries = datatypes.JSON(countriesJSON)
+	rule.Message = req.Message
+	if req.IsActive != nil {
+		rule.IsActive = *req.IsActive
+	}
+	rule.UpdatedAt = time.Now()
+	return nil
+}
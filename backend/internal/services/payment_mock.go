@@ -0,0 +1,255 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MockDeclinedWalletToken is a wallet payment token MockPaymentProvider declines
+const MockDeclinedWalletToken = "tok_mock_declined"
+
+// MockPaymentProvider keeps payments in memory for development and tests.
+// Confirming a payment succeeds unless its order was told to fail with
+// FailOrder or it was charged with MockDeclinedWalletToken.
+type MockPaymentProvider struct {
+	mu       sync.Mutex
+	payments map[string]*mockPayment
+	failing  map[uuid.UUID]bool
+	// intents maps idempotency keys to the payments created under them
+	intents map[string]string
+}
+
+type mockPayment struct {
+	status   PaymentStatus
+	orderID  uuid.UUID
+	refunded int64
+}
+
+// NewMockPaymentProvider creates an empty MockPaymentProvider
+func NewMockPaymentProvider() *MockPaymentProvider {
+	return &MockPaymentProvider{
+		payments: make(map[string]*mockPayment),
+		failing:  make(map[uuid.UUID]bool),
+		intents:  make(map[string]string),
+	}
+}
+
+// FailOrder makes confirmations and retries for an order's payments fail
+func (p *MockPaymentProvider) FailOrder(orderID uuid.UUID, fail bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.failing[orderID] = fail
+}
+
+// Name returns "mock"
+func (p *MockPaymentProvider) Name() string {
+	return PaymentProviderMock
+}
+
+// Capabilities describes the mock provider, which supports everything
+func (p *MockPaymentProvider) Capabilities() PaymentCapabilities {
+	return PaymentCapabilities{
+		Methods: []PaymentMethodInfo{
+			{
+				ID:          "mock",
+				Name:        "Test Payment",
+				Description: "Simulated payment for development",
+				Provider:    PaymentProviderMock,
+				Enabled:     true,
+			},
+			{
+				ID:          WalletApplePay,
+				Name:        "Apple Pay (test)",
+				Description: "Simulated Apple Pay for development",
+				Provider:    PaymentProviderMock,
+				Enabled:     true,
+			},
+			{
+				ID:          WalletGooglePay,
+				Name:        "Google Pay (test)",
+				Description: "Simulated Google Pay for development",
+				Provider:    PaymentProviderMock,
+				Enabled:     true,
+			},
+		},
+		Cancel:         true,
+		Refunds:        true,
+		PartialRefunds: true,
+		Retry:          true,
+	}
+}
+
+// CreatePaymentIntent records a payment awaiting confirmation. A request
+// repeating an earlier one's idempotency key gets that payment back.
+func (p *MockPaymentProvider) CreatePaymentIntent(req *CreatePaymentIntentRequest) (*PaymentIntentResponse, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if id, ok := p.intents[req.IdempotencyKey]; ok && req.IdempotencyKey != "" {
+		payment := p.payments[id].status
+		return &PaymentIntentResponse{
+			ID:           id,
+			Provider:     PaymentProviderMock,
+			ClientSecret: id + "_secret",
+			Status:       payment.Status,
+			Amount:       payment.Amount,
+			Currency:     payment.Currency,
+			Description:  payment.Description,
+			CreatedAt:    payment.CreatedAt,
+		}, nil
+	}
+
+	now := time.Now().Unix()
+	id := "mock_pi_" + uuid.New().String()
+	if req.IdempotencyKey != "" {
+		p.intents[req.IdempotencyKey] = id
+	}
+	p.payments[id] = &mockPayment{
+		orderID: req.OrderID,
+		status: PaymentStatus{
+			PaymentIntentID: id,
+			Provider:        PaymentProviderMock,
+			Status:          "requires_confirmation",
+			Amount:          req.Amount,
+			Currency:        req.Currency,
+			Description:     req.Description,
+			CreatedAt:       now,
+			UpdatedAt:       now,
+		},
+	}
+
+	return &PaymentIntentResponse{
+		ID:           id,
+		Provider:     PaymentProviderMock,
+		ClientSecret: id + "_secret",
+		Status:       "requires_confirmation",
+		Amount:       req.Amount,
+		Currency:     req.Currency,
+		Description:  req.Description,
+		CreatedAt:    now,
+	}, nil
+}
+
+// ChargeWallet creates and settles a wallet payment in one step
+func (p *MockPaymentProvider) ChargeWallet(req *WalletChargeRequest) (*PaymentStatus, error) {
+	if req.PaymentToken == MockDeclinedWalletToken {
+		p.FailOrder(req.OrderID, true)
+	}
+	intent, err := p.CreatePaymentIntent(&CreatePaymentIntentRequest{
+		OrderID:     req.OrderID,
+		Amount:      req.Amount,
+		Currency:    req.Currency,
+		Description: "Order " + req.OrderNumber,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return p.RetryPayment(intent.ID)
+}
+
+// ConfirmPayment settles the payment, or fails it if its order is failing
+func (p *MockPaymentProvider) ConfirmPayment(req *ConfirmPaymentRequest) (*PaymentStatus, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	payment, err := p.find(req.PaymentIntentID)
+	if err != nil {
+		return nil, err
+	}
+	if payment.orderID != req.OrderID {
+		return nil, errors.New("payment intent does not belong to this order")
+	}
+	return p.settle(payment), nil
+}
+
+// RetryPayment confirms a payment again
+func (p *MockPaymentProvider) RetryPayment(paymentIntentID string) (*PaymentStatus, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	payment, err := p.find(paymentIntentID)
+	if err != nil {
+		return nil, err
+	}
+	return p.settle(payment), nil
+}
+
+// GetPaymentStatus returns the payment's current status
+func (p *MockPaymentProvider) GetPaymentStatus(paymentIntentID string) (*PaymentStatus, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	payment, err := p.find(paymentIntentID)
+	if err != nil {
+		return nil, err
+	}
+	status := payment.status
+	return &status, nil
+}
+
+// CancelPaymentIntent cancels a payment that hasn't succeeded
+func (p *MockPaymentProvider) CancelPaymentIntent(paymentIntentID string) (*PaymentStatus, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	payment, err := p.find(paymentIntentID)
+	if err != nil {
+		return nil, err
+	}
+	if payment.status.Status == "succeeded" {
+		return nil, errors.New("failed to cancel payment intent: payment already succeeded")
+	}
+	p.setStatus(payment, "canceled")
+	status := payment.status
+	return &status, nil
+}
+
+// RefundPayment refunds all or part of a successful payment
+func (p *MockPaymentProvider) RefundPayment(paymentIntentID string, amount int64, reason string) (*PaymentStatus, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	payment, err := p.find(paymentIntentID)
+	if err != nil {
+		return nil, err
+	}
+	if payment.status.Status != "succeeded" {
+		return nil, errors.New("failed to process refund: payment has not succeeded")
+	}
+	if amount <= 0 {
+		amount = payment.status.Amount - payment.refunded
+	}
+	if payment.refunded+amount > payment.status.Amount {
+		return nil, errors.New("failed to process refund: amount exceeds the payment")
+	}
+	payment.refunded += amount
+	status := payment.status
+	return &status, nil
+}
+
+func (p *MockPaymentProvider) find(paymentIntentID string) (*mockPayment, error) {
+	payment, ok := p.payments[paymentIntentID]
+	if !ok {
+		return nil, fmt.Errorf("failed to retrieve payment intent: %s not found", paymentIntentID)
+	}
+	return payment, nil
+}
+
+func (p *MockPaymentProvider) settle(payment *mockPayment) *PaymentStatus {
+	if p.failing[payment.orderID] {
+		p.setStatus(payment, "requires_payment_method")
+	} else {
+		p.setStatus(payment, "succeeded")
+	}
+	status := payment.status
+	return &status
+}
+
+func (p *MockPaymentProvider) setStatus(payment *mockPayment, status string) {
+	payment.status.Status = status
+	payment.status.UpdatedAt = time.Now().Unix()
+}
@@ -0,0 +1,377 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// paypalCurrencies are the currencies PayPal Checkout accepts
+var paypalCurrencies = []string{
+	"aud", "brl", "cad", "chf", "czk", "dkk", "eur", "gbp", "hkd", "huf", "ils",
+	"jpy", "mxn", "nok", "nzd", "php", "pln", "sek", "sgd", "thb", "twd", "usd",
+}
+
+// paypalZeroDecimal are the PayPal currencies without minor units
+var paypalZeroDecimal = map[string]bool{"huf": true, "jpy": true, "twd": true}
+
+// PayPalProvider takes payments with the PayPal Orders v2 REST API. The
+// shopper approves the order at ApprovalURL; confirming it captures the funds.
+type PayPalProvider struct {
+	clientID     string
+	clientSecret string
+	baseURL      string
+	client       *http.Client
+
+	mu          sync.Mutex
+	accessToken string
+	tokenExpiry time.Time
+}
+
+// NewPayPalProvider creates a PayPalProvider for the given API base URL
+func NewPayPalProvider(clientID, clientSecret, baseURL string) *PayPalProvider {
+	return &PayPalProvider{
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		baseURL:      strings.TrimRight(baseURL, "/"),
+		client:       &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// PayPalProviderFromEnv creates a PayPalProvider from PAYPAL_CLIENT_ID,
+// PAYPAL_CLIENT_SECRET and PAYPAL_API_BASE (the sandbox by default)
+func PayPalProviderFromEnv() (*PayPalProvider, error) {
+	clientID := os.Getenv("PAYPAL_CLIENT_ID")
+	clientSecret := os.Getenv("PAYPAL_CLIENT_SECRET")
+	if clientID == "" || clientSecret == "" {
+		return nil, errors.New("PAYPAL_CLIENT_ID and PAYPAL_CLIENT_SECRET are required")
+	}
+	baseURL := os.Getenv("PAYPAL_API_BASE")
+	if baseURL == "" {
+		baseURL = "https://api-m.sandbox.paypal.com"
+	}
+	return NewPayPalProvider(clientID, clientSecret, baseURL), nil
+}
+
+// Name returns "paypal"
+func (p *PayPalProvider) Name() string {
+	return PaymentProviderPayPal
+}
+
+// Capabilities describes PayPal Checkout. Orders can't be cancelled or
+// charged again without the shopper, so neither is supported.
+func (p *PayPalProvider) Capabilities() PaymentCapabilities {
+	return PaymentCapabilities{
+		Methods: []PaymentMethodInfo{
+			{
+				ID:          "paypal",
+				Name:        "PayPal",
+				Description: "Pay with your PayPal account",
+				Provider:    PaymentProviderPayPal,
+				Enabled:     true,
+			},
+		},
+		Currencies:     paypalCurrencies,
+		Refunds:        true,
+		PartialRefunds: true,
+	}
+}
+
+type paypalAmount struct {
+	CurrencyCode string `json:"currency_code"`
+	Value        string `json:"value"`
+}
+
+type paypalLink struct {
+	Href string `json:"href"`
+	Rel  string `json:"rel"`
+}
+
+type paypalOrder struct {
+	ID            string `json:"id"`
+	Status        string `json:"status"`
+	CreateTime    string `json:"create_time"`
+	UpdateTime    string `json:"update_time"`
+	PurchaseUnits []struct {
+		CustomID    string       `json:"custom_id"`
+		Description string       `json:"description"`
+		Amount      paypalAmount `json:"amount"`
+		Payments    struct {
+			Captures []struct {
+				ID     string       `json:"id"`
+				Status string       `json:"status"`
+				Amount paypalAmount `json:"amount"`
+			} `json:"captures"`
+		} `json:"payments"`
+	} `json:"purchase_units"`
+	Links []paypalLink `json:"links"`
+}
+
+// CreatePaymentIntent creates a PayPal order for the shopper to approve
+func (p *PayPalProvider) CreatePaymentIntent(req *CreatePaymentIntentRequest) (*PaymentIntentResponse, error) {
+	unit := map[string]interface{}{
+		"custom_id": req.OrderID.String(),
+		"amount":    paypalAmountFromMinor(req.Amount, req.Currency),
+	}
+	if req.Description != "" {
+		unit["description"] = req.Description
+	}
+	if reference, ok := req.Metadata["order_number"]; ok {
+		unit["invoice_id"] = reference
+	}
+
+	var order paypalOrder
+	body := map[string]interface{}{
+		"intent":         "CAPTURE",
+		"purchase_units": []interface{}{unit},
+	}
+	if err := p.doWithRequestID(http.MethodPost, "/v2/checkout/orders", req.IdempotencyKey, body, &order); err != nil {
+		return nil, fmt.Errorf("failed to create payment intent: %v", err)
+	}
+
+	status := p.status(&order)
+	response := &PaymentIntentResponse{
+		ID:          order.ID,
+		Provider:    PaymentProviderPayPal,
+		Status:      status.Status,
+		Amount:      req.Amount,
+		Currency:    strings.ToLower(req.Currency),
+		Description: req.Description,
+		CreatedAt:   status.CreatedAt,
+	}
+	for _, link := range order.Links {
+		if link.Rel == "approve" || link.Rel == "payer-action" {
+			response.ApprovalURL = link.Href
+		}
+	}
+
+	return response, nil
+}
+
+// ConfirmPayment captures an approved PayPal order
+func (p *PayPalProvider) ConfirmPayment(req *ConfirmPaymentRequest) (*PaymentStatus, error) {
+	order, err := p.getOrder(req.PaymentIntentID)
+	if err != nil {
+		return nil, err
+	}
+	if len(order.PurchaseUnits) == 0 || order.PurchaseUnits[0].CustomID != req.OrderID.String() {
+		return nil, errors.New("payment intent does not belong to this order")
+	}
+
+	if order.Status == "APPROVED" {
+		if err := p.do(http.MethodPost, "/v2/checkout/orders/"+order.ID+"/capture", map[string]interface{}{}, order); err != nil {
+			return nil, fmt.Errorf("failed to capture payment: %v", err)
+		}
+	}
+
+	return p.status(order), nil
+}
+
+// RetryPayment is not supported: PayPal needs the shopper to approve again
+func (p *PayPalProvider) RetryPayment(paymentIntentID string) (*PaymentStatus, error) {
+	return nil, errors.New("paypal payments can't be retried without the shopper")
+}
+
+// GetPaymentStatus retrieves the status of a PayPal order
+func (p *PayPalProvider) GetPaymentStatus(paymentIntentID string) (*PaymentStatus, error) {
+	order, err := p.getOrder(paymentIntentID)
+	if err != nil {
+		return nil, err
+	}
+	return p.status(order), nil
+}
+
+// CancelPaymentIntent is not supported: unapproved PayPal orders just expire
+func (p *PayPalProvider) CancelPaymentIntent(paymentIntentID string) (*PaymentStatus, error) {
+	return nil, errors.New("paypal payments can't be cancelled; unapproved orders expire on their own")
+}
+
+// RefundPayment refunds all or part of a captured PayPal order
+func (p *PayPalProvider) RefundPayment(paymentIntentID string, amount int64, reason string) (*PaymentStatus, error) {
+	order, err := p.getOrder(paymentIntentID)
+	if err != nil {
+		return nil, err
+	}
+	if len(order.PurchaseUnits) == 0 || len(order.PurchaseUnits[0].Payments.Captures) == 0 {
+		return nil, errors.New("failed to process refund: payment has not been captured")
+	}
+	capture := order.PurchaseUnits[0].Payments.Captures[0]
+
+	body := map[string]interface{}{"note_to_payer": reason}
+	if amount > 0 {
+		body["amount"] = paypalAmountFromMinor(amount, capture.Amount.CurrencyCode)
+	}
+	if err := p.do(http.MethodPost, "/v2/payments/captures/"+capture.ID+"/refund", body, nil); err != nil {
+		return nil, fmt.Errorf("failed to process refund: %v", err)
+	}
+
+	return p.GetPaymentStatus(paymentIntentID)
+}
+
+func (p *PayPalProvider) getOrder(orderID string) (*paypalOrder, error) {
+	var order paypalOrder
+	if err := p.do(http.MethodGet, "/v2/checkout/orders/"+orderID, nil, &order); err != nil {
+		return nil, fmt.Errorf("failed to retrieve payment intent: %v", err)
+	}
+	return &order, nil
+}
+
+// status maps a PayPal order to a PaymentStatus using Stripe's vocabulary
+func (p *PayPalProvider) status(order *paypalOrder) *PaymentStatus {
+	status := &PaymentStatus{
+		PaymentIntentID: order.ID,
+		Provider:        PaymentProviderPayPal,
+		CreatedAt:       paypalTime(order.CreateTime),
+		UpdatedAt:       paypalTime(order.UpdateTime),
+	}
+	if status.UpdatedAt == 0 {
+		status.UpdatedAt = status.CreatedAt
+	}
+	if len(order.PurchaseUnits) > 0 {
+		unit := order.PurchaseUnits[0]
+		status.Amount = paypalAmountToMinor(unit.Amount)
+		status.Currency = strings.ToLower(unit.Amount.CurrencyCode)
+		status.Description = unit.Description
+	}
+
+	switch order.Status {
+	case "CREATED", "SAVED", "PAYER_ACTION_REQUIRED":
+		status.Status = "requires_payment_method"
+	case "APPROVED":
+		status.Status = "requires_confirmation"
+	case "COMPLETED":
+		status.Status = "succeeded"
+	case "VOIDED":
+		status.Status = "canceled"
+	default:
+		status.Status = strings.ToLower(order.Status)
+	}
+	return status
+}
+
+// do sends an authenticated JSON request to the PayPal API
+func (p *PayPalProvider) do(method, path string, body, out interface{}) error {
+	return p.doWithRequestID(method, path, "", body, out)
+}
+
+// doWithRequestID sends a request like do, under a PayPal-Request-Id when
+// requestID is set so PayPal answers a repeat with the original result
+func (p *PayPalProvider) doWithRequestID(method, path, requestID string, body, out interface{}) error {
+	token, err := p.token()
+	if err != nil {
+		return err
+	}
+
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequest(method, p.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Prefer", "return=representation")
+	if requestID != "" {
+		req.Header.Set("PayPal-Request-Id", requestID)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("paypal returned %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// token returns a cached OAuth access token, fetching a new one when it expires
+func (p *PayPalProvider) token() (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.accessToken != "" && time.Now().Before(p.tokenExpiry) {
+		return p.accessToken, nil
+	}
+
+	req, err := http.NewRequest(http.MethodPost, p.baseURL+"/v1/oauth2/token", strings.NewReader("grant_type=client_credentials"))
+	if err != nil {
+		return "", err
+	}
+	req.SetBasicAuth(p.clientID, p.clientSecret)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to authenticate with paypal: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to authenticate with paypal: status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("failed to authenticate with paypal: %v", err)
+	}
+
+	p.accessToken = result.AccessToken
+	// Refresh a minute early so in-flight requests don't use an expired token
+	p.tokenExpiry = time.Now().Add(time.Duration(result.ExpiresIn)*time.Second - time.Minute)
+	return p.accessToken, nil
+}
+
+// paypalAmountFromMinor converts an amount in minor units (cents) to PayPal's
+// decimal string
+func paypalAmountFromMinor(amount int64, currency string) paypalAmount {
+	currency = strings.ToLower(currency)
+	value := strconv.FormatInt(amount, 10)
+	if !paypalZeroDecimal[currency] {
+		value = fmt.Sprintf("%d.%02d", amount/100, amount%100)
+	}
+	return paypalAmount{CurrencyCode: strings.ToUpper(currency), Value: value}
+}
+
+// paypalAmountToMinor converts PayPal's decimal string to minor units
+func paypalAmountToMinor(amount paypalAmount) int64 {
+	value, err := strconv.ParseFloat(amount.Value, 64)
+	if err != nil {
+		return 0
+	}
+	if paypalZeroDecimal[strings.ToLower(amount.CurrencyCode)] {
+		return int64(value)
+	}
+	return int64(value*100 + 0.5)
+}
+
+func paypalTime(value string) int64 {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return 0
+	}
+	return t.Unix()
+}
@@ -0,0 +1,227 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Payment provider names
+const (
+	PaymentProviderStripe = "stripe"
+	PaymentProviderPayPal = "paypal"
+	PaymentProviderMock   = "mock"
+)
+
+// PaymentProvider creates and manages payments with one payment gateway.
+// Statuses use Stripe's payment intent vocabulary (requires_payment_method,
+// requires_confirmation, processing, succeeded, canceled) whatever the gateway.
+type PaymentProvider interface {
+	Name() string
+	Capabilities() PaymentCapabilities
+	CreatePaymentIntent(req *CreatePaymentIntentRequest) (*PaymentIntentResponse, error)
+	ConfirmPayment(req *ConfirmPaymentRequest) (*PaymentStatus, error)
+	RetryPayment(paymentIntentID string) (*PaymentStatus, error)
+	GetPaymentStatus(paymentIntentID string) (*PaymentStatus, error)
+	CancelPaymentIntent(paymentIntentID string) (*PaymentStatus, error)
+	RefundPayment(paymentIntentID string, amount int64, reason string) (*PaymentStatus, error)
+}
+
+// PaymentMethodInfo describes a way to pay offered by a provider
+type PaymentMethodInfo struct {
+	ID           string `json:"id"`
+	Name         string `json:"name"`
+	Description  string `json:"description"`
+	Instructions string `json:"instructions,omitempty"`
+	Provider     string `json:"provider"`
+	Enabled      bool   `json:"enabled"`
+}
+
+// PaymentCapabilities describes what a provider supports
+type PaymentCapabilities struct {
+	Methods        []PaymentMethodInfo `json:"methods"`
+	Currencies     []string            `json:"currencies"` // empty means any currency
+	Cancel         bool                `json:"cancel"`
+	Refunds        bool                `json:"refunds"`
+	PartialRefunds bool                `json:"partial_refunds"`
+	Retry          bool                `json:"retry"`
+}
+
+// SupportsCurrency reports whether the provider accepts payments in currency
+func (c PaymentCapabilities) SupportsCurrency(currency string) bool {
+	if len(c.Currencies) == 0 {
+		return true
+	}
+	for _, supported := range c.Currencies {
+		if strings.EqualFold(supported, currency) {
+			return true
+		}
+	}
+	return false
+}
+
+// PaymentRegistry holds the enabled payment providers and picks one for each
+// payment: an explicitly requested provider, then the provider routed for the
+// currency, then the store default
+type PaymentRegistry struct {
+	providers       map[string]PaymentProvider
+	defaultProvider string
+	currencyRoutes  map[string]string
+}
+
+// NewPaymentRegistry creates a PaymentRegistry. currencyRoutes maps lower-case
+// currency codes to provider names.
+func NewPaymentRegistry(defaultProvider string, currencyRoutes map[string]string, providers ...PaymentProvider) *PaymentRegistry {
+	r := &PaymentRegistry{
+		providers:       make(map[string]PaymentProvider),
+		defaultProvider: defaultProvider,
+		currencyRoutes:  make(map[string]string),
+	}
+	for currency, provider := range currencyRoutes {
+		r.currencyRoutes[strings.ToLower(currency)] = provider
+	}
+	for _, provider := range providers {
+		r.Register(provider)
+	}
+	return r
+}
+
+// PaymentRegistryFromEnv builds the registry from PAYMENT_PROVIDERS (comma
+// separated, default "stripe"), PAYMENT_DEFAULT_PROVIDER (the first enabled
+// provider) and PAYMENT_CURRENCY_PROVIDERS (e.g. "eur=paypal,gbp=stripe")
+func PaymentRegistryFromEnv() *PaymentRegistry {
+	enabled := os.Getenv("PAYMENT_PROVIDERS")
+	if enabled == "" {
+		enabled = PaymentProviderStripe
+	}
+
+	var providers []PaymentProvider
+	for _, name := range strings.Split(enabled, ",") {
+		switch strings.TrimSpace(strings.ToLower(name)) {
+		case PaymentProviderStripe:
+			providers = append(providers, StripeProviderFromEnv())
+		case PaymentProviderPayPal:
+			provider, err := PayPalProviderFromEnv()
+			if err != nil {
+				log.Printf("PayPal payments disabled: %v", err)
+				continue
+			}
+			providers = append(providers, provider)
+		case PaymentProviderMock:
+			providers = append(providers, NewMockPaymentProvider())
+		case "":
+		default:
+			log.Printf("Unknown payment provider %q ignored", name)
+		}
+	}
+
+	defaultProvider := strings.ToLower(os.Getenv("PAYMENT_DEFAULT_PROVIDER"))
+	if defaultProvider == "" && len(providers) > 0 {
+		defaultProvider = providers[0].Name()
+	}
+
+	routes := make(map[string]string)
+	for _, route := range strings.Split(os.Getenv("PAYMENT_CURRENCY_PROVIDERS"), ",") {
+		currency, provider, ok := strings.Cut(route, "=")
+		if !ok {
+			continue
+		}
+		routes[strings.TrimSpace(currency)] = strings.TrimSpace(strings.ToLower(provider))
+	}
+
+	return NewPaymentRegistry(defaultProvider, routes, providers...)
+}
+
+// Register adds or replaces a provider
+func (r *PaymentRegistry) Register(provider PaymentProvider) {
+	r.providers[provider.Name()] = provider
+}
+
+// Provider returns the named provider. An empty name means the default.
+func (r *PaymentRegistry) Provider(name string) (PaymentProvider, error) {
+	if name == "" {
+		name = r.defaultProvider
+	}
+	provider, ok := r.providers[name]
+	if !ok {
+		return nil, fmt.Errorf("payment provider %q is not enabled", name)
+	}
+	return provider, nil
+}
+
+// Route picks the provider for a payment in currency. A preferred provider is
+// used when it is enabled and accepts the currency.
+func (r *PaymentRegistry) Route(currency, preferred string) (PaymentProvider, error) {
+	candidates := []string{preferred, r.currencyRoutes[strings.ToLower(currency)], r.defaultProvider}
+	if preferred != "" {
+		candidates = candidates[:1]
+	}
+
+	for _, name := range candidates {
+		if name == "" {
+			continue
+		}
+		provider, err := r.Provider(name)
+		if err != nil {
+			if preferred != "" {
+				return nil, err
+			}
+			continue
+		}
+		if !provider.Capabilities().SupportsCurrency(currency) {
+			if preferred != "" {
+				return nil, fmt.Errorf("payment provider %q does not accept %s", name, strings.ToUpper(currency))
+			}
+			continue
+		}
+		return provider, nil
+	}
+
+	// Fall back to any provider that takes the currency
+	for _, name := range r.names() {
+		if provider := r.providers[name]; provider.Capabilities().SupportsCurrency(currency) {
+			return provider, nil
+		}
+	}
+	return nil, fmt.Errorf("no payment provider accepts %s", strings.ToUpper(currency))
+}
+
+// Methods lists the payment methods offered by the enabled providers. With a
+// currency, providers that don't accept it are left out.
+func (r *PaymentRegistry) Methods(currency string) []PaymentMethodInfo {
+	methods := []PaymentMethodInfo{}
+	for _, name := range r.names() {
+		capabilities := r.providers[name].Capabilities()
+		if currency != "" && !capabilities.SupportsCurrency(currency) {
+			continue
+		}
+		methods = append(methods, capabilities.Methods...)
+	}
+	return methods
+}
+
+// Capabilities returns the capabilities of every enabled provider by name
+func (r *PaymentRegistry) Capabilities() map[string]PaymentCapabilities {
+	capabilities := make(map[string]PaymentCapabilities, len(r.providers))
+	for name, provider := range r.providers {
+		capabilities[name] = provider.Capabilities()
+	}
+	return capabilities
+}
+
+// names returns the provider names with the default first, then alphabetically
+func (r *PaymentRegistry) names() []string {
+	names := make([]string, 0, len(r.providers))
+	for name := range r.providers {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if (names[i] == r.defaultProvider) != (names[j] == r.defaultProvider) {
+			return names[i] == r.defaultProvider
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
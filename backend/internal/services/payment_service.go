@@ -3,29 +3,27 @@ package services
 import (
 	"errors"
 	"fmt"
-	"os"
 
 	"github.com/google/uuid"
-	"github.com/stripe/stripe-go/v78"
-	"github.com/stripe/stripe-go/v78/paymentintent"
-	"github.com/stripe/stripe-go/v78/refund"
 )
 
-// PaymentService handles payment processing with Stripe
+// PaymentService handles payment processing, routing each payment to one of
+// the enabled payment providers
 type PaymentService struct {
-	stripeKey string
+	registry *PaymentRegistry
+	offline  OfflinePaymentConfig
 }
 
-// NewPaymentService creates a new PaymentService
+// NewPaymentService creates a new PaymentService with the providers
+// configured in the environment
 func NewPaymentService() *PaymentService {
-	stripeKey := os.Getenv("STRIPE_SECRET_KEY")
-	if stripeKey == "" {
-		stripeKey = "sk_test_..." // Default test key for development
-	}
-	stripe.Key = stripeKey
+	return NewPaymentServiceWithRegistry(PaymentRegistryFromEnv()).WithOfflinePayments(OfflinePaymentConfigFromEnv())
+}
 
+// NewPaymentServiceWithRegistry creates a PaymentService using the given providers
+func NewPaymentServiceWithRegistry(registry *PaymentRegistry) *PaymentService {
 	return &PaymentService{
-		stripeKey: stripeKey,
+		registry: registry,
 	}
 }
 
@@ -34,14 +32,21 @@ type CreatePaymentIntentRequest struct {
 	OrderID     uuid.UUID         `json:"order_id" binding:"required"`
 	Amount      int64             `json:"amount" binding:"required,min=1"`
 	Currency    string            `json:"currency" binding:"required"`
+	Provider    string            `json:"provider"` // Optional; routed by currency when empty
 	Description string            `json:"description"`
 	Metadata    map[string]string `json:"metadata"`
+
+	// IdempotencyKey makes the provider answer a repeat of the request with
+	// the intent it created the first time
+	IdempotencyKey string `json:"-"`
 }
 
 // PaymentIntentResponse represents the response from creating a payment intent
 type PaymentIntentResponse struct {
 	ID           string `json:"id"`
+	Provider     string `json:"provider"`
 	ClientSecret string `json:"client_secret"`
+	ApprovalURL  string `json:"approval_url,omitempty"` // Where the shopper approves redirect-based payments
 	Status       string `json:"status"`
 	Amount       int64  `json:"amount"`
 	Currency     string `json:"currency"`
@@ -58,6 +63,7 @@ type ConfirmPaymentRequest struct {
 // PaymentStatus represents the status of a payment
 type PaymentStatus struct {
 	PaymentIntentID string `json:"payment_intent_id"`
+	Provider        string `json:"provider"`
 	Status          string `json:"status"`
 	Amount          int64  `json:"amount"`
 	Currency        string `json:"currency"`
@@ -66,159 +72,76 @@ type PaymentStatus struct {
 	UpdatedAt       int64  `json:"updated_at"`
 }
 
-// CreatePaymentIntent creates a new payment intent with Stripe
+// CreatePaymentIntent creates a payment with the requested provider, or the
+// one routed for the payment's currency
 func (s *PaymentService) CreatePaymentIntent(req *CreatePaymentIntentRequest) (*PaymentIntentResponse, error) {
-	// Prepare metadata
-	metadata := map[string]string{
-		"order_id": req.OrderID.String(),
-	}
-
-	// Add additional metadata
-	for key, value := range req.Metadata {
-		metadata[key] = value
-	}
-
-	// Create payment intent parameters
-	params := &stripe.PaymentIntentParams{
-		Amount:   stripe.Int64(req.Amount),
-		Currency: stripe.String(req.Currency),
-		Metadata: metadata,
-	}
-
-	// Add description if provided
-	if req.Description != "" {
-		params.Description = stripe.String(req.Description)
-	}
-
-	// Create the payment intent
-	pi, err := paymentintent.New(params)
+	provider, err := s.registry.Route(req.Currency, req.Provider)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create payment intent: %v", err)
+		return nil, err
 	}
-
-	// Return response
-	response := &PaymentIntentResponse{
-		ID:           pi.ID,
-		ClientSecret: pi.ClientSecret,
-		Status:       string(pi.Status),
-		Amount:       pi.Amount,
-		Currency:     string(pi.Currency),
-		Description:  pi.Description,
-		CreatedAt:    pi.Created,
-	}
-
-	return response, nil
+	return provider.CreatePaymentIntent(req)
 }
 
-// ConfirmPayment confirms a payment intent
-func (s *PaymentService) ConfirmPayment(req *ConfirmPaymentRequest) (*PaymentStatus, error) {
-	// Retrieve the payment intent
-	pi, err := paymentintent.Get(req.PaymentIntentID, nil)
+// ConfirmPayment confirms a payment with the provider that created it
+func (s *PaymentService) ConfirmPayment(providerName string, req *ConfirmPaymentRequest) (*PaymentStatus, error) {
+	provider, err := s.registry.Provider(providerName)
 	if err != nil {
-		return nil, fmt.Errorf("failed to retrieve payment intent: %v", err)
-	}
-
-	// Check if payment intent belongs to the order
-	orderID, exists := pi.Metadata["order_id"]
-	if !exists || orderID != req.OrderID.String() {
-		return nil, errors.New("payment intent does not belong to this order")
-	}
-
-	// Return payment status
-	status := &PaymentStatus{
-		PaymentIntentID: pi.ID,
-		Status:          string(pi.Status),
-		Amount:          pi.Amount,
-		Currency:        string(pi.Currency),
-		Description:     pi.Description,
-		CreatedAt:       pi.Created,
-		UpdatedAt:       pi.Created,
+		return nil, err
 	}
-
-	return status, nil
+	return provider.ConfirmPayment(req)
 }
 
-// GetPaymentStatus retrieves the status of a payment intent
-func (s *PaymentService) GetPaymentStatus(paymentIntentID string) (*PaymentStatus, error) {
-	// Retrieve the payment intent
-	pi, err := paymentintent.Get(paymentIntentID, nil)
+// RetryPayment confirms a failed payment again with its saved payment method
+func (s *PaymentService) RetryPayment(providerName, paymentIntentID string) (*PaymentStatus, error) {
+	provider, err := s.registry.Provider(providerName)
 	if err != nil {
-		return nil, fmt.Errorf("failed to retrieve payment intent: %v", err)
-	}
-
-	// Return payment status
-	status := &PaymentStatus{
-		PaymentIntentID: pi.ID,
-		Status:          string(pi.Status),
-		Amount:          pi.Amount,
-		Currency:        string(pi.Currency),
-		Description:     pi.Description,
-		CreatedAt:       pi.Created,
-		UpdatedAt:       pi.Created,
+		return nil, err
 	}
-
-	return status, nil
+	return provider.RetryPayment(paymentIntentID)
 }
 
-// CancelPaymentIntent cancels a payment intent
-func (s *PaymentService) CancelPaymentIntent(paymentIntentID string) (*PaymentStatus, error) {
-	// Cancel the payment intent
-	pi, err := paymentintent.Cancel(paymentIntentID, nil)
+// GetPaymentStatus retrieves the status of a payment
+func (s *PaymentService) GetPaymentStatus(providerName, paymentIntentID string) (*PaymentStatus, error) {
+	provider, err := s.registry.Provider(providerName)
 	if err != nil {
-		return nil, fmt.Errorf("failed to cancel payment intent: %v", err)
+		return nil, err
 	}
-
-	// Return payment status
-	status := &PaymentStatus{
-		PaymentIntentID: pi.ID,
-		Status:          string(pi.Status),
-		Amount:          pi.Amount,
-		Currency:        string(pi.Currency),
-		Description:     pi.Description,
-		CreatedAt:       pi.Created,
-		UpdatedAt:       pi.Created,
-	}
-
-	return status, nil
+	return provider.GetPaymentStatus(paymentIntentID)
 }
 
-// RefundPayment processes a refund for a payment intent
-func (s *PaymentService) RefundPayment(paymentIntentID string, amount int64, reason string) (*PaymentStatus, error) {
-	// Create refund parameters
-	params := &stripe.RefundParams{
-		PaymentIntent: stripe.String(paymentIntentID),
-		Reason:        stripe.String(reason),
-	}
-
-	// Add amount if specified (partial refund)
-	if amount > 0 {
-		params.Amount = stripe.Int64(amount)
-	}
-
-	// Process the refund
-	_, err := refund.New(params)
+// CancelPaymentIntent cancels a payment
+func (s *PaymentService) CancelPaymentIntent(providerName, paymentIntentID string) (*PaymentStatus, error) {
+	provider, err := s.registry.Provider(providerName)
 	if err != nil {
-		return nil, fmt.Errorf("failed to process refund: %v", err)
+		return nil, err
 	}
+	return provider.CancelPaymentIntent(paymentIntentID)
+}
 
-	// Retrieve updated payment intent
-	pi, err := paymentintent.Get(paymentIntentID, nil)
+// RefundPayment processes a refund for a payment
+func (s *PaymentService) RefundPayment(providerName, paymentIntentID string, amount int64, reason string) (*PaymentStatus, error) {
+	provider, err := s.registry.Provider(providerName)
 	if err != nil {
-		return nil, fmt.Errorf("failed to retrieve updated payment intent: %v", err)
+		return nil, err
 	}
+	return provider.RefundPayment(paymentIntentID, amount, reason)
+}
 
-	// Return payment status
-	status := &PaymentStatus{
-		PaymentIntentID: pi.ID,
-		Status:          string(pi.Status),
-		Amount:          pi.Amount,
-		Currency:        string(pi.Currency),
-		Description:     pi.Description,
-		CreatedAt:       pi.Created,
-		UpdatedAt:       pi.Created,
-	}
+// WithOfflinePayments lists the given offline payment methods next to the providers' methods
+func (s *PaymentService) WithOfflinePayments(config OfflinePaymentConfig) *PaymentService {
+	s.offline = config
+	return s
+}
+
+// PaymentMethods lists the payment methods available for a currency, or for
+// any currency when it is empty. Offline methods are listed last.
+func (s *PaymentService) PaymentMethods(currency string) []PaymentMethodInfo {
+	return append(s.registry.Methods(currency), s.offline.PaymentMethods()...)
+}
 
-	return status, nil
+// ProviderCapabilities returns what each enabled provider supports
+func (s *PaymentService) ProviderCapabilities() map[string]PaymentCapabilities {
+	return s.registry.Capabilities()
 }
 
 // ValidateWebhookSignature validates a Stripe webhook signature
@@ -0,0 +1,286 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/stripe/stripe-go/v78"
+	"github.com/stripe/stripe-go/v78/paymentintent"
+	"github.com/stripe/stripe-go/v78/paymentmethod"
+	"github.com/stripe/stripe-go/v78/refund"
+)
+
+// StripeProvider takes payments with Stripe payment intents
+type StripeProvider struct {
+	stripeKey string
+	applePay  bool
+	googlePay bool
+}
+
+// NewStripeProvider creates a StripeProvider using the given secret key
+func NewStripeProvider(stripeKey string) *StripeProvider {
+	stripe.Key = stripeKey
+	return &StripeProvider{
+		stripeKey: stripeKey,
+	}
+}
+
+// StripeProviderFromEnv creates a StripeProvider using STRIPE_SECRET_KEY.
+// Apple Pay is offered once APPLE_PAY_MERCHANT_ID is set and Google Pay when
+// GOOGLE_PAY_ENABLED is true.
+func StripeProviderFromEnv() *StripeProvider {
+	stripeKey := os.Getenv("STRIPE_SECRET_KEY")
+	if stripeKey == "" {
+		stripeKey = "sk_test_..." // Default test key for development
+	}
+	googlePay, _ := strconv.ParseBool(os.Getenv("GOOGLE_PAY_ENABLED"))
+	return NewStripeProvider(stripeKey).WithWallets(os.Getenv("APPLE_PAY_MERCHANT_ID") != "", googlePay)
+}
+
+// WithWallets enables Apple Pay and Google Pay express checkout
+func (p *StripeProvider) WithWallets(applePay, googlePay bool) *StripeProvider {
+	p.applePay = applePay
+	p.googlePay = googlePay
+	return p
+}
+
+// Name returns "stripe"
+func (p *StripeProvider) Name() string {
+	return PaymentProviderStripe
+}
+
+// Capabilities describes Stripe card payments
+func (p *StripeProvider) Capabilities() PaymentCapabilities {
+	return PaymentCapabilities{
+		Methods: []PaymentMethodInfo{
+			{
+				ID:          "card",
+				Name:        "Credit/Debit Card",
+				Description: "Pay with Visa, Mastercard, American Express",
+				Provider:    PaymentProviderStripe,
+				Enabled:     true,
+			},
+			{
+				ID:          WalletApplePay,
+				Name:        "Apple Pay",
+				Description: "Pay with Apple Pay",
+				Provider:    PaymentProviderStripe,
+				Enabled:     p.applePay,
+			},
+			{
+				ID:          WalletGooglePay,
+				Name:        "Google Pay",
+				Description: "Pay with Google Pay",
+				Provider:    PaymentProviderStripe,
+				Enabled:     p.googlePay,
+			},
+		},
+		Cancel:         true,
+		Refunds:        true,
+		PartialRefunds: true,
+		Retry:          true,
+	}
+}
+
+// CreatePaymentIntent creates a new payment intent with Stripe
+func (p *StripeProvider) CreatePaymentIntent(req *CreatePaymentIntentRequest) (*PaymentIntentResponse, error) {
+	// Prepare metadata
+	metadata := map[string]string{
+		"order_id": req.OrderID.String(),
+	}
+
+	// Add additional metadata
+	for key, value := range req.Metadata {
+		metadata[key] = value
+	}
+
+	// Create payment intent parameters
+	params := &stripe.PaymentIntentParams{
+		Amount:   stripe.Int64(req.Amount),
+		Currency: stripe.String(req.Currency),
+		Metadata: metadata,
+	}
+
+	// Add description if provided
+	if req.Description != "" {
+		params.Description = stripe.String(req.Description)
+	}
+
+	if req.IdempotencyKey != "" {
+		params.SetIdempotencyKey(req.IdempotencyKey)
+	}
+
+	// Create the payment intent
+	pi, err := paymentintent.New(params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create payment intent: %v", err)
+	}
+
+	// Return response
+	response := &PaymentIntentResponse{
+		ID:           pi.ID,
+		Provider:     PaymentProviderStripe,
+		ClientSecret: pi.ClientSecret,
+		Status:       string(pi.Status),
+		Amount:       pi.Amount,
+		Currency:     string(pi.Currency),
+		Description:  pi.Description,
+		CreatedAt:    pi.Created,
+	}
+
+	return response, nil
+}
+
+// ConfirmPayment confirms a payment intent
+func (p *StripeProvider) ConfirmPayment(req *ConfirmPaymentRequest) (*PaymentStatus, error) {
+	// Retrieve the payment intent
+	pi, err := paymentintent.Get(req.PaymentIntentID, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve payment intent: %v", err)
+	}
+
+	// Check if payment intent belongs to the order
+	orderID, exists := pi.Metadata["order_id"]
+	if !exists || orderID != req.OrderID.String() {
+		return nil, errors.New("payment intent does not belong to this order")
+	}
+
+	return stripePaymentStatus(pi), nil
+}
+
+// RetryPayment confirms a payment intent again with its saved payment method
+func (p *StripeProvider) RetryPayment(paymentIntentID string) (*PaymentStatus, error) {
+	pi, err := paymentintent.Confirm(paymentIntentID, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retry payment intent: %v", err)
+	}
+
+	return stripePaymentStatus(pi), nil
+}
+
+// GetPaymentStatus retrieves the status of a payment intent
+func (p *StripeProvider) GetPaymentStatus(paymentIntentID string) (*PaymentStatus, error) {
+	// Retrieve the payment intent
+	pi, err := paymentintent.Get(paymentIntentID, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve payment intent: %v", err)
+	}
+
+	return stripePaymentStatus(pi), nil
+}
+
+// CancelPaymentIntent cancels a payment intent
+func (p *StripeProvider) CancelPaymentIntent(paymentIntentID string) (*PaymentStatus, error) {
+	// Cancel the payment intent
+	pi, err := paymentintent.Cancel(paymentIntentID, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to cancel payment intent: %v", err)
+	}
+
+	return stripePaymentStatus(pi), nil
+}
+
+// RefundPayment processes a refund for a payment intent
+func (p *StripeProvider) RefundPayment(paymentIntentID string, amount int64, reason string) (*PaymentStatus, error) {
+	// Create refund parameters
+	params := &stripe.RefundParams{
+		PaymentIntent: stripe.String(paymentIntentID),
+		Reason:        stripe.String(reason),
+	}
+
+	// Add amount if specified (partial refund)
+	if amount > 0 {
+		params.Amount = stripe.Int64(amount)
+	}
+
+	// Process the refund
+	_, err := refund.New(params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to process refund: %v", err)
+	}
+
+	// Retrieve updated payment intent
+	pi, err := paymentintent.Get(paymentIntentID, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve updated payment intent: %v", err)
+	}
+
+	return stripePaymentStatus(pi), nil
+}
+
+// ChargeWallet creates and confirms a payment intent for an Apple Pay or
+// Google Pay payment. The token is a Stripe PaymentMethod (pm_...) or a
+// legacy card token (tok_...) from Stripe.js.
+func (p *StripeProvider) ChargeWallet(req *WalletChargeRequest) (*PaymentStatus, error) {
+	paymentMethodID := req.PaymentToken
+	if strings.HasPrefix(paymentMethodID, "tok_") {
+		pm, err := paymentmethod.New(&stripe.PaymentMethodParams{
+			Type: stripe.String("card"),
+			Card: &stripe.PaymentMethodCardParams{Token: stripe.String(req.PaymentToken)},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create wallet payment method: %v", err)
+		}
+		paymentMethodID = pm.ID
+	}
+
+	contact := req.ShippingContact
+	address := &stripe.AddressParams{
+		City:       stripe.String(contact.Locality),
+		Country:    stripe.String(strings.ToUpper(contact.CountryCode)),
+		PostalCode: stripe.String(contact.PostalCode),
+		State:      stripe.String(contact.AdministrativeArea),
+	}
+	if len(contact.AddressLines) > 0 {
+		address.Line1 = stripe.String(contact.AddressLines[0])
+	}
+	if len(contact.AddressLines) > 1 {
+		address.Line2 = stripe.String(strings.Join(contact.AddressLines[1:], ", "))
+	}
+
+	params := &stripe.PaymentIntentParams{
+		Amount:             stripe.Int64(req.Amount),
+		Currency:           stripe.String(req.Currency),
+		PaymentMethod:      stripe.String(paymentMethodID),
+		PaymentMethodTypes: stripe.StringSlice([]string{"card"}),
+		Confirm:            stripe.Bool(true),
+		Description:        stripe.String("Order " + req.OrderNumber),
+		Metadata: map[string]string{
+			"order_id":     req.OrderID.String(),
+			"order_number": req.OrderNumber,
+			"wallet":       req.Wallet,
+		},
+		Shipping: &stripe.ShippingDetailsParams{
+			Name:    stripe.String(contact.Name()),
+			Phone:   stripe.String(contact.PhoneNumber),
+			Address: address,
+		},
+	}
+	if contact.EmailAddress != "" {
+		params.ReceiptEmail = stripe.String(contact.EmailAddress)
+	}
+
+	pi, err := paymentintent.New(params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to charge wallet payment: %v", err)
+	}
+
+	return stripePaymentStatus(pi), nil
+}
+
+// stripePaymentStatus converts a Stripe payment intent to a PaymentStatus
+func stripePaymentStatus(pi *stripe.PaymentIntent) *PaymentStatus {
+	return &PaymentStatus{
+		PaymentIntentID: pi.ID,
+		Provider:        PaymentProviderStripe,
+		Status:          string(pi.Status),
+		Amount:          pi.Amount,
+		Currency:        string(pi.Currency),
+		Description:     pi.Description,
+		CreatedAt:       pi.Created,
+		UpdatedAt:       pi.Created,
+	}
+}
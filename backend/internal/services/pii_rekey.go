@@ -0,0 +1,144 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// defaultPIIRekeyBatch is how many rows are read at a time when re-encrypting
+const defaultPIIRekeyBatch = 500
+
+// ErrNoPIIKeyring is returned when re-encrypting without PII_ENCRYPTION_KEYS
+var ErrNoPIIKeyring = errors.New("PII_ENCRYPTION_KEYS is not set")
+
+// piiTables lists the columns stored with the pii serializer, per table
+var piiTables = []struct {
+	table   string
+	columns []string
+	model   func() interface{}
+}{
+	{"users", []string{"phone", "date_of_birth"}, func() interface{} { return &models.User{} }},
+	{"orders", []string{"shipping_address", "billing_address"}, func() interface{} { return &models.Order{} }},
+}
+
+// PIIRekeyResult counts the rows of a table that were checked and rewritten
+type PIIRekeyResult struct {
+	Table   string `json:"table"`
+	Scanned int    `json:"scanned"`
+	Rekeyed int    `json:"rekeyed"`
+}
+
+// PIIRekeyService re-encrypts personal data with the current key. It
+// encrypts rows written before encryption was turned on and rows encrypted
+// with an older key, so the older key can be dropped afterwards.
+type PIIRekeyService struct {
+	db        *gorm.DB
+	batchSize int
+}
+
+// NewPIIRekeyService creates a new PIIRekeyService
+func NewPIIRekeyService(db *gorm.DB, batchSize int) *PIIRekeyService {
+	if batchSize <= 0 {
+		batchSize = defaultPIIRekeyBatch
+	}
+	return &PIIRekeyService{
+		db:        db,
+		batchSize: batchSize,
+	}
+}
+
+// Rekey re-encrypts every personal data column that isn't encrypted with the
+// current key. It can be stopped and run again at any time.
+func (s *PIIRekeyService) Rekey(ctx context.Context) ([]PIIRekeyResult, error) {
+	keyring := models.PIIKeyring()
+	if keyring == nil {
+		return nil, ErrNoPIIKeyring
+	}
+
+	results := make([]PIIRekeyResult, 0, len(piiTables))
+	for _, t := range piiTables {
+		result := PIIRekeyResult{Table: t.table}
+		lastID := ""
+		for {
+			ids, stale, err := s.staleRows(ctx, t.table, t.columns, lastID)
+			if err != nil {
+				return results, err
+			}
+			if len(ids) == 0 {
+				break
+			}
+			result.Scanned += len(ids)
+			lastID = ids[len(ids)-1]
+
+			for _, id := range stale {
+				if err := s.rewrite(ctx, t.model(), t.columns, id); err != nil {
+					return results, err
+				}
+				result.Rekeyed++
+			}
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// staleRows reads the next batch of rows after lastID, returning their IDs
+// and the IDs of the rows with a column not encrypted with the current key
+func (s *PIIRekeyService) staleRows(ctx context.Context, table string, columns []string, lastID string) ([]string, []string, error) {
+	keyring := models.PIIKeyring()
+	query := s.db.WithContext(ctx).Table(table).
+		Select(append([]string{"id"}, columns...)).
+		Order("id").
+		Limit(s.batchSize)
+	if lastID != "" {
+		query = query.Where("id > ?", lastID)
+	}
+	rows, err := query.Rows()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read %s: %v", table, err)
+	}
+	defer rows.Close()
+
+	var ids, stale []string
+	for rows.Next() {
+		var id string
+		values := make([]sql.NullString, len(columns))
+		dest := []interface{}{&id}
+		for i := range values {
+			dest = append(dest, &values[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, nil, fmt.Errorf("failed to read %s: %v", table, err)
+		}
+		ids = append(ids, id)
+		for _, value := range values {
+			if value.Valid && keyring.NeedsRotation(value.String) {
+				stale = append(stale, id)
+				break
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to read %s: %v", table, err)
+	}
+	return ids, stale, nil
+}
+
+// rewrite loads a row, decrypting it with whichever key it was written with,
+// and saves its personal data columns again with the current key
+func (s *PIIRekeyService) rewrite(ctx context.Context, record interface{}, columns []string, id string) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(record, "id = ?", id).Error; err != nil {
+			return fmt.Errorf("failed to load %s: %v", id, err)
+		}
+		if err := tx.Model(record).Select(columns).UpdateColumns(record).Error; err != nil {
+			return fmt.Errorf("failed to re-encrypt %s: %v", id, err)
+		}
+		return nil
+	})
+}
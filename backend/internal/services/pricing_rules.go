@@ -0,0 +1,506 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// Pricing rule types
+const (
+	PricingRuleTimeOfDay      = "time_of_day"
+	PricingRuleStockClearance = "stock_clearance"
+	PricingRuleMember         = "member"
+)
+
+// Price adjustment sources
+const (
+	PriceSourceCustomerGroup = "customer_group"
+	PriceSourcePricingRule   = "pricing_rule"
+)
+
+// Pricing rule errors
+var (
+	ErrPricingRuleNotFound = errors.New("pricing rule not found")
+	ErrInvalidPricingRule  = errors.New("invalid pricing rule")
+)
+
+// PricingRuleConfig controls how pricing rules are evaluated
+type PricingRuleConfig struct {
+	// CacheTTL is how long the active rules and products' days of stock are
+	// reused before being read again; 0 reads them for every price
+	CacheTTL time.Duration
+}
+
+// PricingRuleConfigFromEnv reads PRICING_RULES_CACHE_SECONDS (default 30 seconds)
+func PricingRuleConfigFromEnv() PricingRuleConfig {
+	return PricingRuleConfig{
+		CacheTTL: time.Duration(envInt("PRICING_RULES_CACHE_SECONDS", 30)) * time.Second,
+	}
+}
+
+// PricingRuleRequest is the payload for creating or replacing a pricing rule
+type PricingRuleRequest struct {
+	Name            string     `json:"name" binding:"required,max=100"`
+	Type            string     `json:"type" binding:"required,oneof=time_of_day stock_clearance member"`
+	DiscountPercent float64    `json:"discount_percent" binding:"gt=0,lte=100"`
+	Priority        int        `json:"priority"`
+	Exclusive       bool       `json:"exclusive"`
+	ProductID       *uuid.UUID `json:"product_id"`
+	CategoryID      *uuid.UUID `json:"category_id"`
+	StartTime       string     `json:"start_time"`                                // time_of_day, "15:04" in the store's timezone
+	EndTime         string     `json:"end_time"`                                  // time_of_day
+	Weekdays        []int      `json:"weekdays" binding:"max=7,dive,min=0,max=6"` // time_of_day, 0 is Sunday
+	MinDaysOfStock  int        `json:"min_days_of_stock" binding:"min=0"`         // stock_clearance
+	CustomerGroupID *uuid.UUID `json:"customer_group_id"`                         // member; empty for every signed-in customer
+	StartsAt        *time.Time `json:"starts_at"`
+	EndsAt          *time.Time `json:"ends_at"`
+	IsActive        *bool      `json:"is_active"` // defaults to true
+}
+
+// PricingRuleService manages pricing rules and applies them to prices when
+// they are read, so discounts follow the clock, stock levels and who is
+// shopping without rewriting the catalog
+type PricingRuleService struct {
+	db       *gorm.DB
+	config   PricingRuleConfig
+	location *time.Location
+
+	mu       sync.RWMutex
+	loadedAt time.Time
+	rules    []activePricingRule // nil until loaded
+	stock    map[uuid.UUID]cachedDaysOfStock
+}
+
+// NewPricingRuleService creates a new PricingRuleService
+func NewPricingRuleService(db *gorm.DB, config PricingRuleConfig) *PricingRuleService {
+	return &PricingRuleService{
+		db:       db,
+		config:   config,
+		location: StoreLocationFromEnv(),
+		stock:    make(map[uuid.UUID]cachedDaysOfStock),
+	}
+}
+
+// activePricingRule is an active rule with its schedule and scope resolved
+type activePricingRule struct {
+	rule       models.PricingRule
+	weekdays   map[time.Weekday]bool // empty is every day
+	start, end int                   // minutes past midnight
+	categories map[uuid.UUID]bool    // the rule's category and its subcategories
+}
+
+// cachedDaysOfStock is how many days a product's stock lasts at its forecast
+// demand; known is false when it has no forecast
+type cachedDaysOfStock struct {
+	days      float64
+	known     bool
+	expiresAt time.Time
+}
+
+// pricingShopper is who a price is quoted for
+type pricingShopper struct {
+	signedIn bool
+	groupID  *uuid.UUID // the customer group pricing them
+}
+
+// ListRules returns every pricing rule in the order they apply
+func (s *PricingRuleService) ListRules(ctx context.Context) ([]models.PricingRule, error) {
+	var rules []models.PricingRule
+	if err := s.db.WithContext(ctx).Order("priority, created_at").Find(&rules).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch pricing rules: %v", err)
+	}
+	return rules, nil
+}
+
+// GetRule returns a pricing rule
+func (s *PricingRuleService) GetRule(ctx context.Context, id uuid.UUID) (*models.PricingRule, error) {
+	return findPricingRule(s.db.WithContext(ctx), id)
+}
+
+// CreateRule creates a pricing rule
+func (s *PricingRuleService) CreateRule(ctx context.Context, createdBy *uuid.UUID, req PricingRuleRequest) (*models.PricingRule, error) {
+	db := s.db.WithContext(ctx)
+	rule := &models.PricingRule{ID: uuid.New(), CreatedBy: createdBy}
+	if err := applyPricingRuleRequest(db, rule, req); err != nil {
+		return nil, err
+	}
+	if err := db.Create(rule).Error; err != nil {
+		return nil, fmt.Errorf("failed to create pricing rule: %v", err)
+	}
+	s.invalidate()
+	return rule, nil
+}
+
+// UpdateRule replaces a pricing rule. Orders already placed keep the
+// adjustments they were priced with.
+func (s *PricingRuleService) UpdateRule(ctx context.Context, id uuid.UUID, req PricingRuleRequest) (*models.PricingRule, error) {
+	db := s.db.WithContext(ctx)
+	rule, err := findPricingRule(db, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyPricingRuleRequest(db, rule, req); err != nil {
+		return nil, err
+	}
+	if err := db.Save(rule).Error; err != nil {
+		return nil, fmt.Errorf("failed to update pricing rule: %v", err)
+	}
+	s.invalidate()
+	return rule, nil
+}
+
+// DeleteRule deletes a pricing rule
+func (s *PricingRuleService) DeleteRule(ctx context.Context, id uuid.UUID) error {
+	result := s.db.WithContext(ctx).Delete(&models.PricingRule{}, "id = ?", id)
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete pricing rule: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrPricingRuleNotFound
+	}
+	s.invalidate()
+	return nil
+}
+
+func findPricingRule(tx *gorm.DB, id uuid.UUID) (*models.PricingRule, error) {
+	var rule models.PricingRule
+	if err := tx.Where("id = ?", id).First(&rule).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPricingRuleNotFound
+		}
+		return nil, fmt.Errorf("failed to fetch pricing rule: %v", err)
+	}
+	return &rule, nil
+}
+
+func applyPricingRuleRequest(db *gorm.DB, rule *models.PricingRule, req PricingRuleRequest) error {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidPricingRule)
+	}
+	if req.StartsAt != nil && req.EndsAt != nil && !req.EndsAt.After(*req.StartsAt) {
+		return fmt.Errorf("%w: ends_at must be after starts_at", ErrInvalidPricingRule)
+	}
+
+	// Only the fields of the rule's type are kept
+	rule.StartTime, rule.EndTime, rule.Weekdays = "", "", nil
+	rule.MinDaysOfStock = 0
+	rule.CustomerGroupID = nil
+	switch req.Type {
+	case PricingRuleTimeOfDay:
+		start, err := parseClock(req.StartTime)
+		if err != nil {
+			return fmt.Errorf("%w: start_time must be HH:MM", ErrInvalidPricingRule)
+		}
+		end, err := parseClock(req.EndTime)
+		if err != nil {
+			return fmt.Errorf("%w: end_time must be HH:MM", ErrInvalidPricingRule)
+		}
+		if start == end {
+			return fmt.Errorf("%w: start_time and end_time must differ", ErrInvalidPricingRule)
+		}
+		rule.StartTime, rule.EndTime = req.StartTime, req.EndTime
+		if len(req.Weekdays) > 0 {
+			encoded, err := json.Marshal(req.Weekdays)
+			if err != nil {
+				return fmt.Errorf("failed to encode weekdays: %v", err)
+			}
+			rule.Weekdays = datatypes.JSON(encoded)
+		}
+	case PricingRuleStockClearance:
+		if req.MinDaysOfStock < 1 {
+			return fmt.Errorf("%w: min_days_of_stock must be at least 1", ErrInvalidPricingRule)
+		}
+		rule.MinDaysOfStock = req.MinDaysOfStock
+	case PricingRuleMember:
+		if req.CustomerGroupID != nil {
+			if err := checkPricingRuleReference(db, &models.CustomerGroup{}, *req.CustomerGroupID, "customer group"); err != nil {
+				return err
+			}
+		}
+		rule.CustomerGroupID = req.CustomerGroupID
+	default:
+		return fmt.Errorf("%w: unknown type %q", ErrInvalidPricingRule, req.Type)
+	}
+
+	if req.ProductID != nil {
+		if err := checkPricingRuleReference(db, &models.Product{}, *req.ProductID, "product"); err != nil {
+			return err
+		}
+	}
+	if req.CategoryID != nil {
+		if err := checkPricingRuleReference(db, &models.Category{}, *req.CategoryID, "category"); err != nil {
+			return err
+		}
+	}
+
+	rule.Name = name
+	rule.Type = req.Type
+	rule.DiscountPercent = req.DiscountPercent
+	rule.Priority = req.Priority
+	rule.Exclusive = req.Exclusive
+	rule.ProductID = req.ProductID
+	rule.CategoryID = req.CategoryID
+	rule.StartsAt = req.StartsAt
+	rule.EndsAt = req.EndsAt
+	rule.IsActive = req.IsActive == nil || *req.IsActive
+	return nil
+}
+
+// checkPricingRuleReference makes sure a rule's product, category or
+// customer group exists
+func checkPricingRuleReference(db *gorm.DB, model interface{}, id uuid.UUID, what string) error {
+	var count int64
+	if err := db.Model(model).Where("id = ?", id).Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to check %s: %v", what, err)
+	}
+	if count == 0 {
+		return fmt.Errorf("%w: %s not found", ErrInvalidPricingRule, what)
+	}
+	return nil
+}
+
+// invalidate drops the cached rules so admin changes apply at once
+func (s *PricingRuleService) invalidate() {
+	s.mu.Lock()
+	s.rules = nil
+	s.mu.Unlock()
+}
+
+// apply takes each quote through the rules that match its product and the
+// shopper, in priority order. Each rule discounts the price left by the
+// ones before it and records an adjustment; an exclusive rule is the last
+// to apply.
+func (s *PricingRuleService) apply(ctx context.Context, shopper pricingShopper, products []models.Product, quotes []PriceQuote, now time.Time) error {
+	rules, err := s.activeRules(ctx, now)
+	if err != nil || len(rules) == 0 {
+		return err
+	}
+
+	var days map[uuid.UUID]float64
+	for _, rule := range rules {
+		if rule.rule.Type == PricingRuleStockClearance {
+			productIDs := make([]uuid.UUID, len(products))
+			for i := range products {
+				productIDs[i] = products[i].ID
+			}
+			if days, err = s.daysOfStock(ctx, productIDs, now); err != nil {
+				return err
+			}
+			break
+		}
+	}
+
+	local := now.In(s.location)
+	for i := range products {
+		for _, rule := range rules {
+			if !rule.matches(shopper, &products[i], days, local) {
+				continue
+			}
+			before := quotes[i].UnitPrice
+			after := math.Max(0, roundCents(before*(1-rule.rule.DiscountPercent/100)))
+			ruleID := rule.rule.ID
+			quotes[i].UnitPrice = after
+			quotes[i].Adjustments = append(quotes[i].Adjustments, models.PriceAdjustment{
+				Source:      PriceSourcePricingRule,
+				ID:          &ruleID,
+				Name:        rule.rule.Name,
+				Type:        rule.rule.Type,
+				Percent:     rule.rule.DiscountPercent,
+				PriceBefore: before,
+				PriceAfter:  after,
+			})
+			if rule.rule.Exclusive {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// matches reports whether the rule applies to a product for the shopper at
+// the given store-local time
+func (r *activePricingRule) matches(shopper pricingShopper, product *models.Product, days map[uuid.UUID]float64, local time.Time) bool {
+	rule := &r.rule
+	if rule.StartsAt != nil && local.Before(*rule.StartsAt) {
+		return false
+	}
+	if rule.EndsAt != nil && !local.Before(*rule.EndsAt) {
+		return false
+	}
+	if rule.ProductID != nil && *rule.ProductID != product.ID {
+		return false
+	}
+	if rule.CategoryID != nil && !r.categories[product.CategoryID] {
+		return false
+	}
+
+	switch rule.Type {
+	case PricingRuleTimeOfDay:
+		if len(r.weekdays) > 0 && !r.weekdays[local.Weekday()] {
+			return false
+		}
+		minute := local.Hour()*60 + local.Minute()
+		if r.start < r.end {
+			return minute >= r.start && minute < r.end
+		}
+		// The window runs past midnight
+		return minute >= r.start || minute < r.end
+	case PricingRuleStockClearance:
+		remaining, known := days[product.ID]
+		return known && remaining > float64(rule.MinDaysOfStock)
+	case PricingRuleMember:
+		if !shopper.signedIn {
+			return false
+		}
+		return rule.CustomerGroupID == nil || (shopper.groupID != nil && *shopper.groupID == *rule.CustomerGroupID)
+	default:
+		return false
+	}
+}
+
+// activeRules returns the active rules in priority order, cached for the
+// configured TTL. Rules outside their starts_at/ends_at window are kept and
+// skipped when matched, so the cache doesn't go stale when one begins.
+func (s *PricingRuleService) activeRules(ctx context.Context, now time.Time) ([]activePricingRule, error) {
+	s.mu.RLock()
+	if s.rules != nil && now.Sub(s.loadedAt) < s.config.CacheTTL {
+		rules := s.rules
+		s.mu.RUnlock()
+		return rules, nil
+	}
+	s.mu.RUnlock()
+
+	db := s.db.WithContext(ctx)
+	var stored []models.PricingRule
+	if err := db.Where("is_active = ?", true).Order("priority, created_at").Find(&stored).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch pricing rules: %v", err)
+	}
+
+	var categories []models.Category
+	for _, rule := range stored {
+		if rule.CategoryID != nil {
+			if err := db.Select("id", "parent_id").Find(&categories).Error; err != nil {
+				return nil, fmt.Errorf("failed to fetch categories: %v", err)
+			}
+			break
+		}
+	}
+
+	rules := make([]activePricingRule, 0, len(stored))
+	for _, rule := range stored {
+		active := activePricingRule{rule: rule, weekdays: make(map[time.Weekday]bool)}
+		if rule.Type == PricingRuleTimeOfDay {
+			var err error
+			if active.start, err = parseClock(rule.StartTime); err != nil {
+				continue
+			}
+			if active.end, err = parseClock(rule.EndTime); err != nil {
+				continue
+			}
+			var weekdays []int
+			if len(rule.Weekdays) > 0 {
+				_ = json.Unmarshal(rule.Weekdays, &weekdays)
+			}
+			for _, weekday := range weekdays {
+				active.weekdays[time.Weekday(weekday)] = true
+			}
+		}
+		if rule.CategoryID != nil {
+			// A deleted category leaves the rule matching nothing
+			active.categories = categoryTree(categories, *rule.CategoryID)
+		}
+		rules = append(rules, active)
+	}
+
+	s.mu.Lock()
+	s.rules, s.loadedAt = rules, now
+	s.mu.Unlock()
+	return rules, nil
+}
+
+// daysOfStock returns how many days each product's available stock lasts
+// at its forecast daily demand. Products without a forecast are left out;
+// stocked products nobody buys last forever.
+func (s *PricingRuleService) daysOfStock(ctx context.Context, productIDs []uuid.UUID, now time.Time) (map[uuid.UUID]float64, error) {
+	days := make(map[uuid.UUID]float64, len(productIDs))
+	var missing []uuid.UUID
+
+	s.mu.RLock()
+	for _, id := range productIDs {
+		if entry, ok := s.stock[id]; ok && now.Before(entry.expiresAt) {
+			if entry.known {
+				days[id] = entry.days
+			}
+		} else {
+			missing = append(missing, id)
+		}
+	}
+	s.mu.RUnlock()
+	if len(missing) == 0 {
+		return days, nil
+	}
+
+	db := s.db.WithContext(ctx)
+	var forecasts []models.DemandForecast
+	if err := db.Select("product_id", "daily_demand").Where("product_id IN ?", missing).Find(&forecasts).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch demand forecasts: %v", err)
+	}
+	var rows []struct {
+		ProductID uuid.UUID
+		Available int
+	}
+	if err := db.Model(&models.Inventory{}).
+		Select("product_id, SUM(quantity_available - quantity_reserved) AS available").
+		Where("product_id IN ?", missing).
+		Group("product_id").
+		Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch stock levels: %v", err)
+	}
+	available := make(map[uuid.UUID]int, len(rows))
+	for _, row := range rows {
+		available[row.ProductID] = row.Available
+	}
+
+	loaded := make(map[uuid.UUID]cachedDaysOfStock, len(missing))
+	for _, id := range missing {
+		loaded[id] = cachedDaysOfStock{expiresAt: now.Add(s.config.CacheTTL)}
+	}
+	for _, forecast := range forecasts {
+		stock := float64(available[forecast.ProductID])
+		remaining := 0.0
+		switch {
+		case forecast.DailyDemand > 0:
+			remaining = math.Max(0, stock/forecast.DailyDemand)
+		case stock > 0:
+			remaining = math.Inf(1)
+		}
+		loaded[forecast.ProductID] = cachedDaysOfStock{days: remaining, known: true, expiresAt: now.Add(s.config.CacheTTL)}
+		days[forecast.ProductID] = remaining
+	}
+
+	if s.config.CacheTTL > 0 {
+		s.mu.Lock()
+		for id, entry := range s.stock {
+			if !now.Before(entry.expiresAt) {
+				delete(s.stock, id)
+			}
+		}
+		for id, entry := range loaded {
+			s.stock[id] = entry
+		}
+		s.mu.Unlock()
+	}
+	return days, nil
+}
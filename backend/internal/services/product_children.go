@@ -0,0 +1,252 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ErrVariantInUse is returned when removing a variant that orders refer to
+var ErrVariantInUse = errors.New("variant is referenced by orders and cannot be removed")
+
+// upsertVariants updates the product's variants that match a requested one by
+// name and value, creates the rest and deletes only those listed in remove.
+// Variants that aren't mentioned keep their IDs, so carts and orders that
+// refer to them stay valid.
+func upsertVariants(tx *gorm.DB, productID uuid.UUID, existing []models.ProductVariant, requested []ProductVariantRequest, remove []uuid.UUID) ([]models.ProductVariant, error) {
+	removed := idSet(remove)
+	var variants []models.ProductVariant
+	owned := make([]uuid.UUID, 0, len(existing))
+	for _, variant := range existing {
+		owned = append(owned, variant.ID)
+		if !removed[variant.ID] {
+			variants = append(variants, variant)
+		}
+	}
+	if err := checkChildIDs(owned, remove, "variant"); err != nil {
+		return nil, err
+	}
+	if len(remove) > 0 {
+		var ordered int64
+		if err := tx.Model(&models.OrderItem{}).Where("variant_id IN ?", remove).Count(&ordered).Error; err != nil {
+			return nil, fmt.Errorf("failed to check variant orders: %v", err)
+		}
+		if ordered > 0 {
+			return nil, ErrVariantInUse
+		}
+		if err := tx.Where("product_id = ? AND variant_id IN ?", productID, remove).Delete(&models.Inventory{}).Error; err != nil {
+			return nil, fmt.Errorf("failed to delete variant inventory: %v", err)
+		}
+		if err := tx.Where("product_id = ? AND id IN ?", productID, remove).Delete(&models.ProductVariant{}).Error; err != nil {
+			return nil, fmt.Errorf("failed to delete variants: %v", err)
+		}
+	}
+
+	for _, variantReq := range requested {
+		i := -1
+		for j, variant := range variants {
+			if strings.EqualFold(variant.VariantName, variantReq.VariantName) && strings.EqualFold(variant.VariantValue, variantReq.VariantValue) {
+				i = j
+				break
+			}
+		}
+
+		if i < 0 {
+			variant := models.ProductVariant{
+				ProductID:     productID,
+				VariantName:   variantReq.VariantName,
+				VariantValue:  variantReq.VariantValue,
+				PriceModifier: variantReq.PriceModifier,
+				SKUSuffix:     variantReq.SKUSuffix,
+				IsDefault:     variantReq.IsDefault,
+			}
+			if err := tx.Create(&variant).Error; err != nil {
+				return nil, fmt.Errorf("failed to create variant: %v", err)
+			}
+			variants = append(variants, variant)
+			continue
+		}
+
+		variant := &variants[i]
+		updates := map[string]interface{}{
+			"variant_name":   variantReq.VariantName,
+			"variant_value":  variantReq.VariantValue,
+			"price_modifier": variantReq.PriceModifier,
+			"sku_suffix":     variantReq.SKUSuffix,
+			"is_default":     variantReq.IsDefault,
+		}
+		if err := tx.Model(&models.ProductVariant{}).Where("id = ?", variant.ID).Updates(updates).Error; err != nil {
+			return nil, fmt.Errorf("failed to update variant: %v", err)
+		}
+		variant.VariantName = variantReq.VariantName
+		variant.VariantValue = variantReq.VariantValue
+		variant.PriceModifier = variantReq.PriceModifier
+		variant.SKUSuffix = variantReq.SKUSuffix
+		variant.IsDefault = variantReq.IsDefault
+	}
+	return variants, nil
+}
+
+// upsertImages updates the product's images that match a requested one by
+// URL, creates the rest and deletes only those listed in remove
+func upsertImages(tx *gorm.DB, productID uuid.UUID, existing []models.ProductImage, requested []ProductImageRequest, remove []uuid.UUID) ([]models.ProductImage, error) {
+	removed := idSet(remove)
+	var images []models.ProductImage
+	owned := make([]uuid.UUID, 0, len(existing))
+	for _, image := range existing {
+		owned = append(owned, image.ID)
+		if !removed[image.ID] {
+			images = append(images, image)
+		}
+	}
+	if err := checkChildIDs(owned, remove, "image"); err != nil {
+		return nil, err
+	}
+	if len(remove) > 0 {
+		if err := tx.Where("product_id = ? AND id IN ?", productID, remove).Delete(&models.ProductImage{}).Error; err != nil {
+			return nil, fmt.Errorf("failed to delete images: %v", err)
+		}
+	}
+
+	for i, imageReq := range requested {
+		sortOrder := imageReq.SortOrder
+		if sortOrder == 0 {
+			sortOrder = i + 1
+		}
+
+		j := -1
+		for k, image := range images {
+			if image.URL == imageReq.URL {
+				j = k
+				break
+			}
+		}
+
+		if j < 0 {
+			image := models.ProductImage{
+				ProductID: productID,
+				URL:       imageReq.URL,
+				AltText:   imageReq.AltText,
+				IsPrimary: imageReq.IsPrimary,
+				SortOrder: sortOrder,
+			}
+			if err := tx.Create(&image).Error; err != nil {
+				return nil, fmt.Errorf("failed to create image: %v", err)
+			}
+			images = append(images, image)
+			continue
+		}
+
+		image := &images[j]
+		updates := map[string]interface{}{
+			"alt_text":   imageReq.AltText,
+			"is_primary": imageReq.IsPrimary,
+			"sort_order": sortOrder,
+		}
+		if err := tx.Model(&models.ProductImage{}).Where("id = ?", image.ID).Updates(updates).Error; err != nil {
+			return nil, fmt.Errorf("failed to update image: %v", err)
+		}
+		image.AltText = imageReq.AltText
+		image.IsPrimary = imageReq.IsPrimary
+		image.SortOrder = sortOrder
+	}
+	return images, nil
+}
+
+// upsertInventory updates the product's stock rows that match a requested one
+// by variant and location, creates the rest and deletes only those listed in
+// remove. Rows of variants removed in the same update are already gone.
+func upsertInventory(tx *gorm.DB, productID uuid.UUID, existing []models.Inventory, requested []InventoryRequest, remove, removedVariants []uuid.UUID) ([]models.Inventory, error) {
+	removed := idSet(remove)
+	removedVariant := idSet(removedVariants)
+	var inventory []models.Inventory
+	owned := make([]uuid.UUID, 0, len(existing))
+	for _, item := range existing {
+		owned = append(owned, item.ID)
+		if removed[item.ID] || (item.VariantID != nil && removedVariant[*item.VariantID]) {
+			continue
+		}
+		inventory = append(inventory, item)
+	}
+	if err := checkChildIDs(owned, remove, "inventory"); err != nil {
+		return nil, err
+	}
+	if len(remove) > 0 {
+		if err := tx.Where("product_id = ? AND id IN ?", productID, remove).Delete(&models.Inventory{}).Error; err != nil {
+			return nil, fmt.Errorf("failed to delete inventory: %v", err)
+		}
+	}
+
+	for _, invReq := range requested {
+		j := -1
+		for k, item := range inventory {
+			if sameVariant(item.VariantID, invReq.VariantID) && item.WarehouseLocation == invReq.Location {
+				j = k
+				break
+			}
+		}
+
+		if j < 0 {
+			item := models.Inventory{
+				ProductID:         productID,
+				VariantID:         invReq.VariantID,
+				QuantityAvailable: invReq.Quantity,
+				WarehouseLocation: invReq.Location,
+				QuantityReserved:  invReq.Reserved,
+			}
+			if err := tx.Create(&item).Error; err != nil {
+				return nil, fmt.Errorf("failed to create inventory: %v", err)
+			}
+			inventory = append(inventory, item)
+			continue
+		}
+
+		item := &inventory[j]
+		updates := map[string]interface{}{
+			"quantity_available": invReq.Quantity,
+			"quantity_reserved":  invReq.Reserved,
+		}
+		if err := tx.Model(&models.Inventory{}).Where("id = ?", item.ID).Updates(updates).Error; err != nil {
+			return nil, fmt.Errorf("failed to update inventory: %v", err)
+		}
+		item.QuantityAvailable = invReq.Quantity
+		item.QuantityReserved = invReq.Reserved
+	}
+	return inventory, nil
+}
+
+// checkChildIDs rejects removal of IDs that aren't among the product's children
+func checkChildIDs(owned, remove []uuid.UUID, kind string) error {
+	for _, removeID := range remove {
+		found := false
+		for _, id := range owned {
+			if id == removeID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s %s does not belong to this product", kind, removeID)
+		}
+	}
+	return nil
+}
+
+func idSet(ids []uuid.UUID) map[uuid.UUID]bool {
+	set := make(map[uuid.UUID]bool, len(ids))
+	for _, id := range ids {
+		set[id] = true
+	}
+	return set
+}
+
+func sameVariant(a, b *uuid.UUID) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
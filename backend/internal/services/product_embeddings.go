@@ -0,0 +1,425 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sashabaranov/go-openai"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// embeddingBatchSize is how many texts are embedded in one provider call
+const embeddingBatchSize = 64
+
+// embeddingTextLength caps the product text sent to the provider
+const embeddingTextLength = 2000
+
+// ErrEmbeddingsUnavailable is returned when semantic search can't be used,
+// e.g. without pgvector or an embeddings provider
+var ErrEmbeddingsUnavailable = errors.New("product embeddings are not available")
+
+// EmbeddingProvider turns texts into embedding vectors
+type EmbeddingProvider interface {
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+	// Model names the embedding model; vectors of different models aren't compared
+	Model() string
+}
+
+// OpenAIEmbeddings implements EmbeddingProvider using the OpenAI API
+type OpenAIEmbeddings struct {
+	client *openai.Client
+	model  string
+}
+
+// NewOpenAIEmbeddings creates a new OpenAIEmbeddings
+func NewOpenAIEmbeddings(apiKey, model string) *OpenAIEmbeddings {
+	return &OpenAIEmbeddings{client: openai.NewClient(apiKey), model: model}
+}
+
+// Embed embeds the texts in order
+func (p *OpenAIEmbeddings) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	response, err := p.client.CreateEmbeddings(ctx, openai.EmbeddingRequestStrings{
+		Input: texts,
+		Model: openai.EmbeddingModel(p.model),
+	})
+	if err != nil {
+		return nil, err
+	}
+	vectors := make([][]float32, len(texts))
+	for _, data := range response.Data {
+		if data.Index < 0 || data.Index >= len(texts) {
+			return nil, fmt.Errorf("embedding index %d out of range", data.Index)
+		}
+		vectors[data.Index] = data.Embedding
+	}
+	for i, vector := range vectors {
+		if vector == nil {
+			return nil, fmt.Errorf("no embedding returned for text %d", i)
+		}
+	}
+	return vectors, nil
+}
+
+// Model returns the OpenAI embedding model
+func (p *OpenAIEmbeddings) Model() string {
+	return p.model
+}
+
+// EmbeddingConfig controls semantic product search
+type EmbeddingConfig struct {
+	Provider        string        // openai, or none to keep keyword ranking
+	Model           string        // embedding model
+	MinSimilarity   float64       // least cosine similarity for a product to be suggested
+	ReindexInterval time.Duration // how often changed products are embedded again
+}
+
+// EmbeddingConfigFromEnv reads EMBEDDINGS_PROVIDER (openai when
+// OPENAI_API_KEY is set, none otherwise), EMBEDDINGS_MODEL (default
+// text-embedding-3-small), EMBEDDINGS_MIN_SIMILARITY (default 0.3) and
+// EMBEDDINGS_REINDEX_MINUTES (default 60)
+func EmbeddingConfigFromEnv() EmbeddingConfig {
+	config := EmbeddingConfig{
+		Provider:        strings.ToLower(strings.TrimSpace(os.Getenv("EMBEDDINGS_PROVIDER"))),
+		Model:           strings.TrimSpace(os.Getenv("EMBEDDINGS_MODEL")),
+		MinSimilarity:   0.3,
+		ReindexInterval: time.Duration(envInt("EMBEDDINGS_REINDEX_MINUTES", 60)) * time.Minute,
+	}
+	if config.Provider == "" {
+		config.Provider = "none"
+		if os.Getenv("OPENAI_API_KEY") != "" {
+			config.Provider = "openai"
+		}
+	}
+	if config.Model == "" {
+		config.Model = string(openai.SmallEmbedding3)
+	}
+	if similarity, err := strconv.ParseFloat(os.Getenv("EMBEDDINGS_MIN_SIMILARITY"), 64); err == nil && similarity > 0 && similarity < 1 {
+		config.MinSimilarity = similarity
+	}
+	if config.ReindexInterval <= 0 {
+		config.ReindexInterval = time.Hour
+	}
+	return config
+}
+
+// EmbeddingProviderFromConfig returns the configured provider, or nil when
+// semantic search is turned off
+func EmbeddingProviderFromConfig(config EmbeddingConfig) EmbeddingProvider {
+	switch config.Provider {
+	case "openai":
+		return NewOpenAIEmbeddings(os.Getenv("OPENAI_API_KEY"), config.Model)
+	default:
+		return nil
+	}
+}
+
+// ProductEmbeddingService indexes products as embeddings and ranks them by
+// how close they are in meaning to a shopper's message
+type ProductEmbeddingService struct {
+	db       *gorm.DB
+	provider EmbeddingProvider
+	config   EmbeddingConfig
+
+	tableOnce sync.Once
+	hasTable  bool
+}
+
+// NewProductEmbeddingService creates a new ProductEmbeddingService
+func NewProductEmbeddingService(db *gorm.DB, provider EmbeddingProvider, config EmbeddingConfig) *ProductEmbeddingService {
+	if config.MinSimilarity <= 0 {
+		config.MinSimilarity = 0.3
+	}
+	return &ProductEmbeddingService{db: db, provider: provider, config: config}
+}
+
+// Available reports whether products can be ranked semantically: there is a
+// provider and somewhere to keep the vectors
+func (s *ProductEmbeddingService) Available() bool {
+	if s == nil || s.provider == nil {
+		return false
+	}
+	s.tableOnce.Do(func() {
+		s.hasTable = s.db.Migrator().HasTable(&models.ProductEmbedding{})
+	})
+	return s.hasTable
+}
+
+// MinSimilarity is the least similarity of a product worth suggesting
+func (s *ProductEmbeddingService) MinSimilarity() float64 {
+	return s.config.MinSimilarity
+}
+
+// IndexProducts embeds the published products that are new or changed since
+// they were last embedded and returns how many were
+func (s *ProductEmbeddingService) IndexProducts(ctx context.Context) (int, error) {
+	if !s.Available() {
+		return 0, ErrEmbeddingsUnavailable
+	}
+
+	var products []models.Product
+	if err := s.db.WithContext(ctx).Scopes(publishedAt(time.Now())).
+		Preload("Category").Preload("Brand").
+		Order("id ASC").
+		Find(&products).Error; err != nil {
+		return 0, fmt.Errorf("failed to fetch products to embed: %v", err)
+	}
+	return s.embedProducts(ctx, products)
+}
+
+// ScheduleIndexing embeds new and changed products right away and again
+// every ReindexInterval until ctx is done
+func (s *ProductEmbeddingService) ScheduleIndexing(ctx context.Context) {
+	if !s.Available() {
+		log.Printf("Semantic product search is off, chat suggestions use keyword ranking")
+		return
+	}
+	go func() {
+		index := func() {
+			indexed, err := s.IndexProducts(ctx)
+			if err != nil {
+				log.Printf("Failed to index product embeddings: %v", err)
+				return
+			}
+			if indexed > 0 {
+				log.Printf("Embedded %d products for semantic search", indexed)
+			}
+		}
+		index()
+
+		ticker := time.NewTicker(s.config.ReindexInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				index()
+			}
+		}
+	}()
+}
+
+// Rank returns the cosine similarity of each product to the message.
+// Products that haven't been embedded yet are embedded along the way.
+func (s *ProductEmbeddingService) Rank(ctx context.Context, message string, products []models.Product) (map[uuid.UUID]float64, error) {
+	if !s.Available() {
+		return nil, ErrEmbeddingsUnavailable
+	}
+
+	ids := make([]uuid.UUID, len(products))
+	for i, product := range products {
+		ids[i] = product.ID
+	}
+	var stored []models.ProductEmbedding
+	if len(ids) > 0 {
+		if err := s.db.WithContext(ctx).Where("product_id IN ? AND model = ?", ids, s.provider.Model()).
+			Find(&stored).Error; err != nil {
+			return nil, fmt.Errorf("failed to fetch product embeddings: %v", err)
+		}
+	}
+	vectors := make(map[uuid.UUID]models.Vector, len(stored))
+	for _, embedding := range stored {
+		vectors[embedding.ProductID] = embedding.Embedding
+	}
+
+	var missing []models.Product
+	for _, product := range products {
+		if _, ok := vectors[product.ID]; !ok {
+			missing = append(missing, product)
+		}
+	}
+
+	// The message and any products not indexed yet go in one call
+	texts := []string{truncateRunes(strings.TrimSpace(message), embeddingTextLength)}
+	for _, product := range missing {
+		texts = append(texts, productEmbeddingText(product))
+	}
+	embedded, err := s.provider.Embed(ctx, texts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed message: %v", err)
+	}
+	if len(embedded) != len(texts) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(embedded))
+	}
+	query := embedded[0]
+	if len(missing) > 0 {
+		if err := s.save(ctx, missing, embedded[1:]); err != nil {
+			log.Printf("Failed to save product embeddings: %v", err)
+		}
+		for i, product := range missing {
+			vectors[product.ID] = embedded[i+1]
+		}
+	}
+
+	scores := make(map[uuid.UUID]float64, len(products))
+	for id, vector := range vectors {
+		scores[id] = cosineSimilarity(query, vector)
+	}
+	return scores, nil
+}
+
+// Nearest returns up to limit published products closest in meaning to the
+// message, using pgvector's cosine distance over the whole catalog
+func (s *ProductEmbeddingService) Nearest(ctx context.Context, message string, limit int) ([]models.Product, error) {
+	if !s.Available() || s.db.Dialector.Name() != "postgres" {
+		return nil, ErrEmbeddingsUnavailable
+	}
+
+	embedded, err := s.provider.Embed(ctx, []string{truncateRunes(strings.TrimSpace(message), embeddingTextLength)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed message: %v", err)
+	}
+	if len(embedded) != 1 {
+		return nil, fmt.Errorf("expected 1 embedding, got %d", len(embedded))
+	}
+
+	var ids []uuid.UUID
+	err = s.db.WithContext(ctx).Model(&models.ProductEmbedding{}).
+		Where("model = ?", s.provider.Model()).
+		Order(clause.Expr{SQL: "embedding <=> ?::vector", Vars: []interface{}{models.Vector(embedded[0]).String()}}).
+		Limit(limit).
+		Pluck("product_id", &ids).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to search product embeddings: %v", err)
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	var products []models.Product
+	if err := s.db.WithContext(ctx).Scopes(publishedAt(time.Now())).
+		Preload("Category").Preload("Images").
+		Where("id IN ?", ids).
+		Find(&products).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch products: %v", err)
+	}
+	return products, nil
+}
+
+// embedProducts embeds the products whose text changed since they were last
+// embedded with the provider's model
+func (s *ProductEmbeddingService) embedProducts(ctx context.Context, products []models.Product) (int, error) {
+	if len(products) == 0 {
+		return 0, nil
+	}
+	ids := make([]uuid.UUID, len(products))
+	for i, product := range products {
+		ids[i] = product.ID
+	}
+	var stored []models.ProductEmbedding
+	if err := s.db.WithContext(ctx).Select("product_id", "model", "content_hash").
+		Where("product_id IN ?", ids).Find(&stored).Error; err != nil {
+		return 0, fmt.Errorf("failed to fetch product embeddings: %v", err)
+	}
+	current := make(map[uuid.UUID]string, len(stored))
+	for _, embedding := range stored {
+		if embedding.Model == s.provider.Model() {
+			current[embedding.ProductID] = embedding.ContentHash
+		}
+	}
+
+	var changed []models.Product
+	for _, product := range products {
+		if current[product.ID] != contentHash(productEmbeddingText(product)) {
+			changed = append(changed, product)
+		}
+	}
+
+	for start := 0; start < len(changed); start += embeddingBatchSize {
+		end := start + embeddingBatchSize
+		if end > len(changed) {
+			end = len(changed)
+		}
+		batch := changed[start:end]
+		texts := make([]string, len(batch))
+		for i, product := range batch {
+			texts[i] = productEmbeddingText(product)
+		}
+		vectors, err := s.provider.Embed(ctx, texts)
+		if err != nil {
+			return start, fmt.Errorf("failed to embed products: %v", err)
+		}
+		if len(vectors) != len(batch) {
+			return start, fmt.Errorf("expected %d embeddings, got %d", len(batch), len(vectors))
+		}
+		if err := s.save(ctx, batch, vectors); err != nil {
+			return start, err
+		}
+	}
+	return len(changed), nil
+}
+
+// save stores the products' embeddings, replacing earlier ones
+func (s *ProductEmbeddingService) save(ctx context.Context, products []models.Product, vectors [][]float32) error {
+	now := time.Now()
+	embeddings := make([]models.ProductEmbedding, len(products))
+	for i, product := range products {
+		embeddings[i] = models.ProductEmbedding{
+			ProductID:   product.ID,
+			Model:       s.provider.Model(),
+			ContentHash: contentHash(productEmbeddingText(product)),
+			Embedding:   vectors[i],
+			UpdatedAt:   now,
+		}
+	}
+	err := s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "product_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"model", "content_hash", "embedding", "updated_at"}),
+	}).Create(&embeddings).Error
+	if err != nil {
+		return fmt.Errorf("failed to save product embeddings: %v", err)
+	}
+	return nil
+}
+
+// productEmbeddingText is what a product is embedded as: its name, category,
+// brand and description
+func productEmbeddingText(product models.Product) string {
+	parts := []string{product.Name}
+	if product.Category.Name != "" {
+		parts = append(parts, "Category: "+product.Category.Name)
+	}
+	if brand := productBrand(product); brand != "" {
+		parts = append(parts, "Brand: "+brand)
+	}
+	if description := strings.Join(strings.Fields(product.Description), " "); description != "" {
+		parts = append(parts, description)
+	}
+	return truncateRunes(strings.Join(parts, ". "), embeddingTextLength)
+}
+
+func contentHash(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:])
+}
+
+// cosineSimilarity is 1 for vectors pointing the same way and 0 for
+// unrelated ones; vectors of different lengths aren't comparable
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
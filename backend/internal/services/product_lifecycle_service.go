@@ -0,0 +1,205 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ProductLifecycleService publishes and unpublishes products at their scheduled times
+type ProductLifecycleService struct {
+	db *gorm.DB
+}
+
+// NewProductLifecycleService creates a new ProductLifecycleService
+func NewProductLifecycleService(db *gorm.DB) *ProductLifecycleService {
+	return &ProductLifecycleService{
+		db: db,
+	}
+}
+
+// ProductScheduleRequest sets or clears a product's visibility window
+type ProductScheduleRequest struct {
+	PublishAt   *time.Time `json:"publish_at"`
+	UnpublishAt *time.Time `json:"unpublish_at"`
+}
+
+// LifecycleRunResult lists the products changed by a scheduler run
+type LifecycleRunResult struct {
+	RanAt       time.Time   `json:"ran_at"`
+	Published   []uuid.UUID `json:"published"`
+	Unpublished []uuid.UUID `json:"unpublished"`
+}
+
+// ProductSchedulerIntervalFromEnv returns PRODUCT_SCHEDULER_INTERVAL_SECONDS, or one minute
+func ProductSchedulerIntervalFromEnv() time.Duration {
+	return time.Duration(envInt("PRODUCT_SCHEDULER_INTERVAL_SECONDS", 60)) * time.Second
+}
+
+// withinPublishWindow limits a product query to products inside their visibility window,
+// so scheduled changes take effect even before the scheduler's next run
+func withinPublishWindow(now time.Time) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("publish_at IS NULL OR publish_at <= ?", now).
+			Where("unpublish_at IS NULL OR unpublish_at > ?", now)
+	}
+}
+
+// publishedAt limits a product query to active products inside their visibility window
+func publishedAt(now time.Time) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("status = ?", "active").Scopes(withinPublishWindow(now))
+	}
+}
+
+// applyPublishWindow validates a visibility window and sets it on the product.
+// A product scheduled to go live later is kept inactive until the scheduler publishes it.
+func applyPublishWindow(product *models.Product, publishAt, unpublishAt *time.Time, now time.Time) error {
+	if publishAt != nil && unpublishAt != nil && !unpublishAt.After(*publishAt) {
+		return errors.New("unpublish_at must be after publish_at")
+	}
+
+	product.PublishAt = publishAt
+	product.UnpublishAt = unpublishAt
+	if publishAt != nil && publishAt.After(now) {
+		product.Status = "inactive"
+	}
+	return nil
+}
+
+// SetSchedule sets or clears the publish and unpublish times of a product
+func (s *ProductLifecycleService) SetSchedule(ctx context.Context, productID uuid.UUID, req ProductScheduleRequest) (*models.Product, error) {
+	var product models.Product
+	if err := s.db.WithContext(ctx).Where("id = ?", productID).First(&product).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("product not found")
+		}
+		return nil, fmt.Errorf("failed to fetch product: %v", err)
+	}
+
+	if err := applyPublishWindow(&product, req.PublishAt, req.UnpublishAt, time.Now()); err != nil {
+		return nil, err
+	}
+
+	updates := map[string]interface{}{
+		"status":       product.Status,
+		"publish_at":   product.PublishAt,
+		"unpublish_at": product.UnpublishAt,
+	}
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&product).Updates(updates).Error; err != nil {
+			return fmt.Errorf("failed to update product schedule: %v", err)
+		}
+		return recordProductRevision(tx, product.ID, RevisionSourceSchedule, nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &product, nil
+}
+
+// GetScheduledProducts returns products with a pending publish or unpublish time
+func (s *ProductLifecycleService) GetScheduledProducts(ctx context.Context) ([]models.Product, error) {
+	var products []models.Product
+	err := s.db.WithContext(ctx).
+		Where("publish_at IS NOT NULL OR unpublish_at IS NOT NULL").
+		Order("COALESCE(publish_at, unpublish_at) ASC").
+		Find(&products).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch scheduled products: %v", err)
+	}
+	return products, nil
+}
+
+// RunScheduledTransitions flips the status of products whose publish or
+// unpublish time has passed. Times are cleared once applied, so a product
+// deactivated by hand afterwards is not published again.
+func (s *ProductLifecycleService) RunScheduledTransitions(ctx context.Context, now time.Time) (*LifecycleRunResult, error) {
+	result := &LifecycleRunResult{
+		RanAt:       now,
+		Published:   []uuid.UUID{},
+		Unpublished: []uuid.UUID{},
+	}
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Unpublish first: a window that has fully passed ends with the product down
+		if err := tx.Model(&models.Product{}).
+			Where("unpublish_at <= ?", now).
+			Pluck("id", &result.Unpublished).Error; err != nil {
+			return fmt.Errorf("failed to fetch products to unpublish: %v", err)
+		}
+		if len(result.Unpublished) > 0 {
+			updates := map[string]interface{}{
+				"status":       "inactive",
+				"publish_at":   nil,
+				"unpublish_at": nil,
+			}
+			if err := tx.Model(&models.Product{}).Where("id IN ?", result.Unpublished).Updates(updates).Error; err != nil {
+				return fmt.Errorf("failed to unpublish products: %v", err)
+			}
+		}
+
+		if err := tx.Model(&models.Product{}).
+			Where("publish_at <= ?", now).
+			Pluck("id", &result.Published).Error; err != nil {
+			return fmt.Errorf("failed to fetch products to publish: %v", err)
+		}
+		if len(result.Published) > 0 {
+			updates := map[string]interface{}{
+				"status":     "active",
+				"publish_at": nil,
+			}
+			if err := tx.Model(&models.Product{}).Where("id IN ?", result.Published).Updates(updates).Error; err != nil {
+				return fmt.Errorf("failed to publish products: %v", err)
+			}
+		}
+
+		recorded := make(map[uuid.UUID]bool)
+		for _, id := range append(append([]uuid.UUID{}, result.Unpublished...), result.Published...) {
+			if recorded[id] {
+				continue
+			}
+			recorded[id] = true
+			if err := recordProductRevision(tx, id, RevisionSourceSchedule, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// ScheduleTransitions runs RunScheduledTransitions every interval until ctx is done
+func (s *ProductLifecycleService) ScheduleTransitions(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				result, err := s.RunScheduledTransitions(ctx, now)
+				if err != nil {
+					log.Printf("Failed to run product schedule: %v", err)
+					continue
+				}
+				if len(result.Published) > 0 || len(result.Unpublished) > 0 {
+					log.Printf("Product schedule: published %d, unpublished %d", len(result.Published), len(result.Unpublished))
+				}
+			}
+		}
+	}()
+}
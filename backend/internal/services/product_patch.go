@@ -0,0 +1,177 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// ErrProductNotFound is returned when a product doesn't exist
+var ErrProductNotFound = errors.New("product not found")
+
+// AdminProductPatch is the payload for PATCH /admin/products/:id. Only the
+// fields present in the body are changed, so a price-only update can't blank
+// the rest of the product. Metadata keys are merged into the existing
+// metadata; a key set to null is removed. Children are upserted as in
+// UpdateProduct, and only deleted when listed in the Remove* fields.
+type AdminProductPatch struct {
+	Name        *string                 `json:"name"`
+	Description *string                 `json:"description"`
+	Price       *float64                `json:"price"`
+	CategoryID  *uuid.UUID              `json:"category_id"`
+	BrandID     *uuid.UUID              `json:"brand_id"` // the nil UUID removes the brand
+	SKU         *string                 `json:"sku"`
+	Status      *string                 `json:"status"`
+	Metadata    map[string]interface{}  `json:"metadata"`
+	Images      []ProductImageRequest   `json:"images" binding:"omitempty,dive"`
+	Variants    []ProductVariantRequest `json:"variants" binding:"omitempty,dive"`
+	Inventory   []InventoryRequest      `json:"inventory" binding:"omitempty,dive"`
+
+	RemoveVariantIDs   []uuid.UUID `json:"remove_variant_ids"`
+	RemoveImageIDs     []uuid.UUID `json:"remove_image_ids"`
+	RemoveInventoryIDs []uuid.UUID `json:"remove_inventory_ids"`
+
+	// ExpectedUpdatedAt is the updated_at of the product the admin's edit
+	// started from; saving over a newer version is reported as a conflict
+	ExpectedUpdatedAt *time.Time `json:"expected_updated_at,omitempty"`
+}
+
+// PatchProduct applies a sparse update to a product
+func (s *AdminProductService) PatchProduct(ctx context.Context, id uuid.UUID, patch AdminProductPatch) (*AdminProductResponse, error) {
+	// The write that lost a race for the SKU is explained once the
+	// transaction has rolled back
+	var updateErr error
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var product models.Product
+		if err := tx.Preload("Variants").Preload("Images").Preload("Inventory").First(&product, "id = ?", id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrProductNotFound
+			}
+			return fmt.Errorf("failed to fetch product: %v", err)
+		}
+
+		updates, err := productPatchUpdates(&product, patch)
+		if err != nil {
+			return err
+		}
+		if patch.SKU != nil && *patch.SKU != product.SKU {
+			if err := s.checkSKU(tx, *patch.SKU, id); err != nil {
+				return err
+			}
+		}
+		if patch.CategoryID != nil || len(patch.Metadata) > 0 {
+			if err := validatePatchedMetadata(tx, &product, patch, updates); err != nil {
+				return err
+			}
+		}
+		if len(updates) > 0 {
+			updates["updated_at"] = time.Now()
+			if err := tx.Model(&models.Product{}).Where("id = ?", id).Updates(updates).Error; err != nil {
+				updateErr = fmt.Errorf("failed to update product: %v", err)
+				return updateErr
+			}
+		}
+
+		if _, err := upsertVariants(tx, id, product.Variants, patch.Variants, patch.RemoveVariantIDs); err != nil {
+			return err
+		}
+		if _, err := upsertImages(tx, id, product.Images, patch.Images, patch.RemoveImageIDs); err != nil {
+			return err
+		}
+		if _, err := upsertInventory(tx, id, product.Inventory, patch.Inventory, patch.RemoveInventoryIDs, patch.RemoveVariantIDs); err != nil {
+			return err
+		}
+		return recordProductRevision(tx, id, RevisionSourcePatch, nil)
+	})
+	if err != nil {
+		if updateErr != nil && patch.SKU != nil {
+			return nil, lostSKURace(s.db, *patch.SKU, id, err)
+		}
+		return nil, err
+	}
+
+	return s.GetProductWithDetails(id)
+}
+
+// productPatchUpdates returns the product columns a patch changes
+func productPatchUpdates(product *models.Product, patch AdminProductPatch) (map[string]interface{}, error) {
+	updates := map[string]interface{}{}
+
+	if patch.Name != nil {
+		if strings.TrimSpace(*patch.Name) == "" {
+			return nil, errors.New("name cannot be empty")
+		}
+		updates["name"] = *patch.Name
+	}
+	if patch.Description != nil {
+		updates["description"] = *patch.Description
+	}
+	if patch.Price != nil {
+		if *patch.Price < 0 {
+			return nil, errors.New("price cannot be negative")
+		}
+		updates["price"] = *patch.Price
+	}
+	if patch.CategoryID != nil {
+		updates["category_id"] = *patch.CategoryID
+	}
+	if patch.BrandID != nil {
+		if *patch.BrandID == uuid.Nil {
+			updates["brand_id"] = nil
+		} else {
+			updates["brand_id"] = *patch.BrandID
+		}
+	}
+	if patch.SKU != nil {
+		if strings.TrimSpace(*patch.SKU) == "" {
+			return nil, errors.New("sku cannot be empty")
+		}
+		updates["sku"] = *patch.SKU
+	}
+	if patch.Status != nil {
+		if strings.TrimSpace(*patch.Status) == "" {
+			return nil, errors.New("status cannot be empty")
+		}
+		updates["status"] = *patch.Status
+	}
+
+	if len(patch.Metadata) > 0 {
+		metadata := productMetadata(*product)
+		for key, value := range patch.Metadata {
+			if value == nil {
+				delete(metadata, key)
+				continue
+			}
+			metadata[key] = value
+		}
+		metadataBytes, err := json.Marshal(metadata)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal metadata: %v", err)
+		}
+		updates["metadata"] = datatypes.JSON(metadataBytes)
+	}
+
+	return updates, nil
+}
+
+// validatePatchedMetadata checks the product's metadata, with the patch
+// merged in, against the schema of the category it ends up in
+func validatePatchedMetadata(tx *gorm.DB, product *models.Product, patch AdminProductPatch, updates map[string]interface{}) error {
+	categoryID := product.CategoryID
+	if patch.CategoryID != nil {
+		categoryID = *patch.CategoryID
+	}
+	metadata := productMetadata(*product)
+	if raw, ok := updates["metadata"].(datatypes.JSON); ok {
+		metadata = productMetadata(models.Product{Metadata: raw})
+	}
+	return validateProductMetadata(tx, categoryID, metadata)
+}
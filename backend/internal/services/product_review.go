@@ -0,0 +1,225 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Product change request statuses
+const (
+	ChangeStatusDraft    = "draft"
+	ChangeStatusPending  = "pending"
+	ChangeStatusApproved = "approved"
+	ChangeStatusRejected = "rejected"
+)
+
+// Product change kinds, matching PUT and PATCH /admin/products/:id
+const (
+	ChangeKindUpdate = "update"
+	ChangeKindPatch  = "patch"
+)
+
+// Product review errors
+var (
+	ErrChangeRequestNotFound = errors.New("change request not found")
+	ErrChangeNotReviewable   = errors.New("only pending change requests can be reviewed")
+	ErrReviewCommentRequired = errors.New("a comment is required when rejecting a change")
+)
+
+// ProductReviewConfig lists the senior admins whose product edits go live
+// directly and who review everyone else's
+type ProductReviewConfig struct {
+	SeniorAdmins map[string]bool // by lower-case email
+}
+
+// ProductReviewConfigFromEnv reads SENIOR_ADMIN_EMAILS (comma separated).
+// Without it every admin publishes directly and no review is required.
+func ProductReviewConfigFromEnv() ProductReviewConfig {
+	config := ProductReviewConfig{SeniorAdmins: make(map[string]bool)}
+	for _, email := range strings.Split(os.Getenv("SENIOR_ADMIN_EMAILS"), ",") {
+		if email = strings.TrimSpace(strings.ToLower(email)); email != "" {
+			config.SeniorAdmins[email] = true
+		}
+	}
+	return config
+}
+
+// CanPublish reports whether an admin's product edits skip review
+func (c ProductReviewConfig) CanPublish(email string) bool {
+	return len(c.SeniorAdmins) == 0 || c.SeniorAdmins[strings.ToLower(email)]
+}
+
+// WithProductReview replaces the review configuration read from the environment
+func (s *AdminProductService) WithProductReview(config ProductReviewConfig) *AdminProductService {
+	s.review = config
+	return s
+}
+
+// CanPublish reports whether an admin may edit the live catalog and review
+// other admins' changes
+func (s *AdminProductService) CanPublish(email string) bool {
+	return s.review.CanPublish(email)
+}
+
+// SubmitProductChange records an edit for review instead of applying it.
+// changes is an AdminProductRequest for ChangeKindUpdate or an
+// AdminProductPatch for ChangeKindPatch. Drafts aren't reviewed until submitted.
+func (s *AdminProductService) SubmitProductChange(ctx context.Context, productID uuid.UUID, kind string, changes interface{}, submittedBy *uuid.UUID, email string, draft bool) (*models.ProductChangeRequest, error) {
+	db := s.db.WithContext(ctx)
+
+	var count int64
+	if err := db.Model(&models.Product{}).Where("id = ?", productID).Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch product: %v", err)
+	}
+	if count == 0 {
+		return nil, ErrProductNotFound
+	}
+
+	changesJSON, err := json.Marshal(changes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal changes: %v", err)
+	}
+
+	status := ChangeStatusPending
+	if draft {
+		status = ChangeStatusDraft
+	}
+	change := &models.ProductChangeRequest{
+		ID:               uuid.New(),
+		ProductID:        productID,
+		Kind:             kind,
+		Status:           status,
+		Changes:          changesJSON,
+		SubmittedBy:      submittedBy,
+		SubmittedByEmail: email,
+	}
+	if err := db.Create(change).Error; err != nil {
+		return nil, fmt.Errorf("failed to save change request: %v", err)
+	}
+	return change, nil
+}
+
+// ListProductChanges returns change requests, newest first, optionally
+// filtered by status and product
+func (s *AdminProductService) ListProductChanges(ctx context.Context, status string, productID *uuid.UUID) ([]models.ProductChangeRequest, error) {
+	query := s.db.WithContext(ctx).Order("created_at DESC")
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if productID != nil {
+		query = query.Where("product_id = ?", *productID)
+	}
+
+	var changes []models.ProductChangeRequest
+	if err := query.Find(&changes).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch change requests: %v", err)
+	}
+	return changes, nil
+}
+
+// GetProductChange returns a change request by ID
+func (s *AdminProductService) GetProductChange(ctx context.Context, id uuid.UUID) (*models.ProductChangeRequest, error) {
+	var change models.ProductChangeRequest
+	if err := s.db.WithContext(ctx).Where("id = ?", id).First(&change).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrChangeRequestNotFound
+		}
+		return nil, fmt.Errorf("failed to fetch change request: %v", err)
+	}
+	return &change, nil
+}
+
+// SubmitDraftChange sends a draft change request for review
+func (s *AdminProductService) SubmitDraftChange(ctx context.Context, id uuid.UUID) (*models.ProductChangeRequest, error) {
+	change, err := s.GetProductChange(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if change.Status != ChangeStatusDraft {
+		return nil, errors.New("only drafts can be submitted for review")
+	}
+
+	change.Status = ChangeStatusPending
+	if err := s.db.WithContext(ctx).Model(change).Updates(map[string]interface{}{"status": change.Status, "updated_at": time.Now()}).Error; err != nil {
+		return nil, fmt.Errorf("failed to submit change request: %v", err)
+	}
+	return change, nil
+}
+
+// ApproveProductChange applies a pending change to the live catalog
+func (s *AdminProductService) ApproveProductChange(ctx context.Context, id uuid.UUID, reviewer *uuid.UUID, comment string) (*models.ProductChangeRequest, error) {
+	change, err := s.GetProductChange(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if change.Status != ChangeStatusPending {
+		return nil, ErrChangeNotReviewable
+	}
+
+	switch change.Kind {
+	case ChangeKindUpdate:
+		var req AdminProductRequest
+		if err := json.Unmarshal(change.Changes, &req); err != nil {
+			return nil, fmt.Errorf("failed to read changes: %v", err)
+		}
+		if _, err := s.UpdateProduct(change.ProductID, req); err != nil {
+			return nil, err
+		}
+	case ChangeKindPatch:
+		var patch AdminProductPatch
+		if err := json.Unmarshal(change.Changes, &patch); err != nil {
+			return nil, fmt.Errorf("failed to read changes: %v", err)
+		}
+		if _, err := s.PatchProduct(ctx, change.ProductID, patch); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown change kind %q", change.Kind)
+	}
+
+	return s.reviewProductChange(ctx, change, ChangeStatusApproved, reviewer, comment)
+}
+
+// RejectProductChange turns down a pending change, leaving the catalog as is
+func (s *AdminProductService) RejectProductChange(ctx context.Context, id uuid.UUID, reviewer *uuid.UUID, comment string) (*models.ProductChangeRequest, error) {
+	if strings.TrimSpace(comment) == "" {
+		return nil, ErrReviewCommentRequired
+	}
+	change, err := s.GetProductChange(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if change.Status != ChangeStatusPending {
+		return nil, ErrChangeNotReviewable
+	}
+	return s.reviewProductChange(ctx, change, ChangeStatusRejected, reviewer, comment)
+}
+
+func (s *AdminProductService) reviewProductChange(ctx context.Context, change *models.ProductChangeRequest, status string, reviewer *uuid.UUID, comment string) (*models.ProductChangeRequest, error) {
+	now := time.Now()
+	change.Status = status
+	change.ReviewedBy = reviewer
+	change.ReviewComment = comment
+	change.ReviewedAt = &now
+
+	updates := map[string]interface{}{
+		"status":         status,
+		"reviewed_by":    reviewer,
+		"review_comment": comment,
+		"reviewed_at":    now,
+		"updated_at":     now,
+	}
+	if err := s.db.WithContext(ctx).Model(&models.ProductChangeRequest{}).Where("id = ?", change.ID).Updates(updates).Error; err != nil {
+		return nil, fmt.Errorf("failed to save review: %v", err)
+	}
+	return change, nil
+}
@@ -0,0 +1,334 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// Product revision sources: what changed the product
+const (
+	RevisionSourceCreate        = "create"
+	RevisionSourceUpdate        = "update"
+	RevisionSourcePatch         = "patch"
+	RevisionSourceBulkImport    = "bulk_import"
+	RevisionSourceVariantImport = "variant_import"
+	RevisionSourceBulkPrice     = PriceSourceBulk
+	RevisionSourceSchedule      = "schedule"
+	RevisionSourceStorefrontAPI = "api"
+	RevisionSourceRollback      = "rollback"
+)
+
+// ErrRevisionNotFound is returned when a product has no revision with the requested version
+var ErrRevisionNotFound = errors.New("revision not found")
+
+// ProductSnapshot is the product data kept in a revision. Stock levels aren't
+// part of it, so rolling back never changes inventory.
+type ProductSnapshot struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Price       float64           `json:"price"`
+	CategoryID  uuid.UUID         `json:"category_id"`
+	BrandID     *uuid.UUID        `json:"brand_id"`
+	SKU         string            `json:"sku"`
+	Status      string            `json:"status"`
+	Metadata    datatypes.JSON    `json:"metadata"`
+	PublishAt   *time.Time        `json:"publish_at"`
+	UnpublishAt *time.Time        `json:"unpublish_at"`
+	Variants    []VariantSnapshot `json:"variants"`
+	Images      []ImageSnapshot   `json:"images"`
+}
+
+// VariantSnapshot is a variant as kept in a product revision
+type VariantSnapshot struct {
+	ID            uuid.UUID `json:"id"`
+	VariantName   string    `json:"variant_name"`
+	VariantValue  string    `json:"variant_value"`
+	PriceModifier float64   `json:"price_modifier"`
+	SKUSuffix     string    `json:"sku_suffix"`
+	IsDefault     bool      `json:"is_default"`
+}
+
+// ImageSnapshot is an image as kept in a product revision
+type ImageSnapshot struct {
+	ID        uuid.UUID `json:"id"`
+	URL       string    `json:"url"`
+	AltText   string    `json:"alt_text"`
+	IsPrimary bool      `json:"is_primary"`
+	SortOrder int       `json:"sort_order"`
+}
+
+// recordProductRevision saves the product's current data as its next revision
+func recordProductRevision(tx *gorm.DB, productID uuid.UUID, source string, changedBy *uuid.UUID) error {
+	var product models.Product
+	if err := tx.Preload("Variants").Preload("Images").First(&product, "id = ?", productID).Error; err != nil {
+		return fmt.Errorf("failed to fetch product for revision: %v", err)
+	}
+
+	snapshot := ProductSnapshot{
+		Name:        product.Name,
+		Description: product.Description,
+		Price:       product.Price,
+		CategoryID:  product.CategoryID,
+		BrandID:     product.BrandID,
+		SKU:         product.SKU,
+		Status:      product.Status,
+		Metadata:    product.Metadata,
+		PublishAt:   product.PublishAt,
+		UnpublishAt: product.UnpublishAt,
+		Variants:    []VariantSnapshot{},
+		Images:      []ImageSnapshot{},
+	}
+	for _, variant := range product.Variants {
+		snapshot.Variants = append(snapshot.Variants, VariantSnapshot{
+			ID:            variant.ID,
+			VariantName:   variant.VariantName,
+			VariantValue:  variant.VariantValue,
+			PriceModifier: variant.PriceModifier,
+			SKUSuffix:     variant.SKUSuffix,
+			IsDefault:     variant.IsDefault,
+		})
+	}
+	for _, image := range product.Images {
+		snapshot.Images = append(snapshot.Images, ImageSnapshot{
+			ID:        image.ID,
+			URL:       image.URL,
+			AltText:   image.AltText,
+			IsPrimary: image.IsPrimary,
+			SortOrder: image.SortOrder,
+		})
+	}
+	snapshotJSON, err := json.Marshal(snapshot)
+	if err != nil {
+		return fmt.Errorf("failed to marshal product revision: %v", err)
+	}
+
+	var latest int
+	if err := tx.Model(&models.ProductRevision{}).Where("product_id = ?", productID).
+		Select("COALESCE(MAX(version), 0)").Scan(&latest).Error; err != nil {
+		return fmt.Errorf("failed to fetch latest product revision: %v", err)
+	}
+
+	revision := models.ProductRevision{
+		ID:        uuid.New(),
+		ProductID: productID,
+		Version:   latest + 1,
+		Source:    source,
+		Snapshot:  snapshotJSON,
+		ChangedBy: changedBy,
+		CreatedAt: time.Now(),
+	}
+	if err := tx.Create(&revision).Error; err != nil {
+		return fmt.Errorf("failed to record product revision: %v", err)
+	}
+	return nil
+}
+
+// ListProductRevisions returns a product's revisions, newest first
+func (s *AdminProductService) ListProductRevisions(ctx context.Context, productID uuid.UUID) ([]models.ProductRevision, error) {
+	var revisions []models.ProductRevision
+	if err := s.db.WithContext(ctx).Where("product_id = ?", productID).Order("version DESC").Find(&revisions).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch product revisions: %v", err)
+	}
+	return revisions, nil
+}
+
+// GetProductRevision returns one revision of a product
+func (s *AdminProductService) GetProductRevision(ctx context.Context, productID uuid.UUID, version int) (*models.ProductRevision, error) {
+	return findProductRevision(s.db.WithContext(ctx), productID, version)
+}
+
+func findProductRevision(db *gorm.DB, productID uuid.UUID, version int) (*models.ProductRevision, error) {
+	var revision models.ProductRevision
+	if err := db.Where("product_id = ? AND version = ?", productID, version).First(&revision).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRevisionNotFound
+		}
+		return nil, fmt.Errorf("failed to fetch product revision: %v", err)
+	}
+	return &revision, nil
+}
+
+// RollbackProduct restores a product to the data of one of its revisions.
+// Variants and images get their old IDs back; ones added since are removed,
+// except variants that orders refer to. The rollback is itself recorded as a
+// new revision, so it can be undone the same way.
+func (s *AdminProductService) RollbackProduct(ctx context.Context, productID uuid.UUID, version int, changedBy *uuid.UUID) (*AdminProductResponse, error) {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		revision, err := findProductRevision(tx, productID, version)
+		if err != nil {
+			return err
+		}
+		var snapshot ProductSnapshot
+		if err := json.Unmarshal(revision.Snapshot, &snapshot); err != nil {
+			return fmt.Errorf("failed to read product revision: %v", err)
+		}
+
+		var product models.Product
+		if err := tx.Preload("Variants").Preload("Images").First(&product, "id = ?", productID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrProductNotFound
+			}
+			return fmt.Errorf("failed to fetch product: %v", err)
+		}
+
+		updates := map[string]interface{}{
+			"name":         snapshot.Name,
+			"description":  snapshot.Description,
+			"price":        snapshot.Price,
+			"category_id":  snapshot.CategoryID,
+			"brand_id":     snapshot.BrandID,
+			"sku":          snapshot.SKU,
+			"status":       snapshot.Status,
+			"metadata":     snapshot.Metadata,
+			"publish_at":   snapshot.PublishAt,
+			"unpublish_at": snapshot.UnpublishAt,
+			"updated_at":   time.Now(),
+		}
+		if err := tx.Model(&models.Product{}).Where("id = ?", productID).Updates(updates).Error; err != nil {
+			return fmt.Errorf("failed to restore product: %v", err)
+		}
+
+		if err := restoreVariants(tx, productID, product.Variants, snapshot.Variants); err != nil {
+			return err
+		}
+		if err := restoreImages(tx, productID, product.Images, snapshot.Images); err != nil {
+			return err
+		}
+
+		return recordProductRevision(tx, productID, RevisionSourceRollback, changedBy)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetProductWithDetails(productID)
+}
+
+func restoreVariants(tx *gorm.DB, productID uuid.UUID, current []models.ProductVariant, snapshot []VariantSnapshot) error {
+	existing := make(map[uuid.UUID]bool, len(current))
+	for _, variant := range current {
+		existing[variant.ID] = true
+	}
+
+	kept := make(map[uuid.UUID]bool, len(snapshot))
+	for _, saved := range snapshot {
+		kept[saved.ID] = true
+		if existing[saved.ID] {
+			updates := map[string]interface{}{
+				"variant_name":   saved.VariantName,
+				"variant_value":  saved.VariantValue,
+				"price_modifier": saved.PriceModifier,
+				"sku_suffix":     saved.SKUSuffix,
+				"is_default":     saved.IsDefault,
+			}
+			if err := tx.Model(&models.ProductVariant{}).Where("id = ?", saved.ID).Updates(updates).Error; err != nil {
+				return fmt.Errorf("failed to restore variant: %v", err)
+			}
+			continue
+		}
+
+		variant := models.ProductVariant{
+			ID:            saved.ID,
+			ProductID:     productID,
+			VariantName:   saved.VariantName,
+			VariantValue:  saved.VariantValue,
+			PriceModifier: saved.PriceModifier,
+			SKUSuffix:     saved.SKUSuffix,
+			IsDefault:     saved.IsDefault,
+		}
+		if err := tx.Create(&variant).Error; err != nil {
+			return fmt.Errorf("failed to restore variant: %v", err)
+		}
+	}
+
+	var removed []uuid.UUID
+	for _, variant := range current {
+		if !kept[variant.ID] {
+			removed = append(removed, variant.ID)
+		}
+	}
+	if len(removed) == 0 {
+		return nil
+	}
+
+	// Variants that have been ordered since stay, so the orders keep their variant
+	var ordered []uuid.UUID
+	if err := tx.Model(&models.OrderItem{}).Where("variant_id IN ?", removed).Distinct().Pluck("variant_id", &ordered).Error; err != nil {
+		return fmt.Errorf("failed to check variant orders: %v", err)
+	}
+	inUse := idSet(ordered)
+	var deletable []uuid.UUID
+	for _, id := range removed {
+		if !inUse[id] {
+			deletable = append(deletable, id)
+		}
+	}
+	if len(deletable) == 0 {
+		return nil
+	}
+	if err := tx.Where("product_id = ? AND variant_id IN ?", productID, deletable).Delete(&models.Inventory{}).Error; err != nil {
+		return fmt.Errorf("failed to delete variant inventory: %v", err)
+	}
+	if err := tx.Where("product_id = ? AND id IN ?", productID, deletable).Delete(&models.ProductVariant{}).Error; err != nil {
+		return fmt.Errorf("failed to delete variants: %v", err)
+	}
+	return nil
+}
+
+func restoreImages(tx *gorm.DB, productID uuid.UUID, current []models.ProductImage, snapshot []ImageSnapshot) error {
+	existing := make(map[uuid.UUID]bool, len(current))
+	for _, image := range current {
+		existing[image.ID] = true
+	}
+
+	kept := make(map[uuid.UUID]bool, len(snapshot))
+	for _, saved := range snapshot {
+		kept[saved.ID] = true
+		if existing[saved.ID] {
+			updates := map[string]interface{}{
+				"url":        saved.URL,
+				"alt_text":   saved.AltText,
+				"is_primary": saved.IsPrimary,
+				"sort_order": saved.SortOrder,
+			}
+			if err := tx.Model(&models.ProductImage{}).Where("id = ?", saved.ID).Updates(updates).Error; err != nil {
+				return fmt.Errorf("failed to restore image: %v", err)
+			}
+			continue
+		}
+
+		image := models.ProductImage{
+			ID:        saved.ID,
+			ProductID: productID,
+			URL:       saved.URL,
+			AltText:   saved.AltText,
+			IsPrimary: saved.IsPrimary,
+			SortOrder: saved.SortOrder,
+		}
+		if err := tx.Create(&image).Error; err != nil {
+			return fmt.Errorf("failed to restore image: %v", err)
+		}
+	}
+
+	var removed []uuid.UUID
+	for _, image := range current {
+		if !kept[image.ID] {
+			removed = append(removed, image.ID)
+		}
+	}
+	if len(removed) == 0 {
+		return nil
+	}
+	if err := tx.Where("product_id = ? AND id IN ?", productID, removed).Delete(&models.ProductImage{}).Error; err != nil {
+		return fmt.Errorf("failed to delete images: %v", err)
+	}
+	return nil
+}
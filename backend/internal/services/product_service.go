@@ -2,8 +2,11 @@ package services
 
 import (
 	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/pkg/listquery"
+	"context"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/lib/pq"
@@ -27,22 +30,63 @@ type CreateProductRequest struct {
 // Product represents a product in the catalog (alias for models.Product)
 type Product = models.Product
 
+// searchCandidateFactor is how many more products a search fetches than it
+// returns, for ranking to choose from
+const searchCandidateFactor = 3
+
 // ProductService handles product-related business logic
 type ProductService struct {
-	db *gorm.DB
+	db       *gorm.DB
+	pricing  *CustomerGroupService
+	synonyms *SynonymService
+	misses   *SearchMissService
+	ranking  *SearchRankingService
+	brands   *BrandService
 }
 
 // NewProductService creates a new ProductService
 func NewProductService(db *gorm.DB) *ProductService {
 	return &ProductService{
-		db: db,
+		db:       db,
+		pricing:  NewCustomerGroupService(db),
+		synonyms: NewSynonymService(db),
+		misses:   NewSearchMissService(db),
+		ranking:  NewSearchRankingService(db),
+		brands:   NewBrandService(db),
 	}
 }
 
+// WithSynonyms shares a synonyms dictionary with the admin endpoints that manage it,
+// so their changes apply to searches at once
+func (s *ProductService) WithSynonyms(synonyms *SynonymService) *ProductService {
+	s.synonyms = synonyms
+	return s
+}
+
+// WithBrands shares the brand list with the admin endpoints that manage it,
+// so new brands are recognized in searches at once
+func (s *ProductService) WithBrands(brands *BrandService) *ProductService {
+	s.brands = brands
+	return s
+}
+
+// WithPricingRules shows catalog prices with the given rules applied, such
+// as a happy hour discount while it runs
+func (s *ProductService) WithPricingRules(rules *PricingRuleService) *ProductService {
+	s.pricing.WithPricingRules(rules)
+	return s
+}
+
+// ApplyCustomerPricing prices products for the user's customer group
+func (s *ProductService) ApplyCustomerPricing(ctx context.Context, userID *uuid.UUID, products []models.Product) error {
+	return s.pricing.PriceProducts(ctx, userID, products)
+}
+
 // ProductFilters represents search and filter parameters
 type ProductFilters struct {
 	Search     string    `json:"search"`
 	CategoryID uuid.UUID `json:"category_id"`
+	BrandID    uuid.UUID `json:"brand_id"`
 	MinPrice   float64   `json:"min_price"`
 	MaxPrice   float64   `json:"max_price"`
 	Status     string    `json:"status"`
@@ -51,6 +95,61 @@ type ProductFilters struct {
 	Limit      int       `json:"limit"`
 	SortBy     string    `json:"sort_by"`
 	SortOrder  string    `json:"sort_order"`
+
+	// IncludeUnpublished also returns products outside their visibility window (admin listings)
+	IncludeUnpublished bool `json:"-"`
+
+	// List is a list request's filters, sort and page. The fields above
+	// narrow it further; without it they also set the page and sort.
+	List *listquery.Query `json:"-"`
+}
+
+// ProductListSchema is what product lists can be filtered and sorted by.
+// The aliases keep the storefront's original parameters working.
+var ProductListSchema = &listquery.Schema{
+	Filters: map[string]listquery.Field{
+		"search":      listquery.Search("name", "description"),
+		"name":        listquery.Column("name", listquery.String, listquery.Contains, listquery.Eq),
+		"sku":         listquery.Column("sku", listquery.String),
+		"category_id": listquery.Column("category_id", listquery.UUID),
+		"brand_id":    listquery.Column("brand_id", listquery.UUID),
+		"price":       listquery.Column("price", listquery.Number),
+		"status":      listquery.Column("status", listquery.String),
+		"popularity":  listquery.Column("popularity", listquery.Number),
+		"created_at":  listquery.Column("created_at", listquery.Time),
+		"updated_at":  listquery.Column("updated_at", listquery.Time),
+	},
+	Sorts: map[string]string{
+		"created_at": "created_at",
+		"updated_at": "updated_at",
+		"name":       "name",
+		"price":      "price",
+		"popularity": "popularity",
+		"sku":        "sku",
+	},
+	DefaultSort: "-created_at",
+	TieBreaker:  "id",
+	DefaultSize: 10,
+	MaxSize:     100,
+	Aliases: map[string]string{
+		"search":      "search",
+		"category_id": "category_id",
+		"brand_id":    "brand_id",
+		"min_price":   "price[gte]",
+		"max_price":   "price[lte]",
+		"status":      "status",
+	},
+}
+
+// list returns the filters' list query, or one paging and sorting as the
+// fields ask
+func (f ProductFilters) list() *listquery.Query {
+	if f.List != nil {
+		return f.List
+	}
+	return listquery.New(ProductListSchema).
+		SetPage(f.Page, f.Limit).
+		SortBy(f.SortBy, !strings.EqualFold(f.SortOrder, "asc"))
 }
 
 // ProductListResponse represents paginated product list response
@@ -81,6 +180,10 @@ func (s *ProductService) GetProducts(filters ProductFilters) (*ProductListRespon
 		query = query.Where("category_id = ?", filters.CategoryID)
 	}
 
+	if filters.BrandID != uuid.Nil {
+		query = query.Where("brand_id = ?", filters.BrandID)
+	}
+
 	if filters.MinPrice > 0 {
 		query = query.Where("price >= ?", filters.MinPrice)
 	}
@@ -97,51 +200,38 @@ func (s *ProductService) GetProducts(filters ProductFilters) (*ProductListRespon
 		query = query.Where("tags && ?", filters.Tags)
 	}
 
-	// Count total records
-	if err := query.Count(&total).Error; err != nil {
-		return nil, fmt.Errorf("failed to count products: %w", err)
+	if !filters.IncludeUnpublished {
+		query = query.Scopes(withinPublishWindow(time.Now()))
 	}
 
-	// Apply pagination
-	offset := (filters.Page - 1) * filters.Limit
-	if offset < 0 {
-		offset = 0
-	}
+	list := filters.list()
+	query = list.Filter(query)
 
-	// Apply sorting
-	sortBy := filters.SortBy
-	if sortBy == "" {
-		sortBy = "created_at"
-	}
-	sortOrder := filters.SortOrder
-	if sortOrder == "" {
-		sortOrder = "desc"
+	// Count total records
+	if err := query.Count(&total).Error; err != nil {
+		return nil, fmt.Errorf("failed to count products: %w", err)
 	}
-	orderClause := fmt.Sprintf("%s %s", sortBy, sortOrder)
-	query = query.Order(orderClause)
 
-	// Execute query with pagination
-	if err := query.Offset(offset).Limit(filters.Limit).
+	// Execute query with sorting and pagination
+	if err := list.Paginate(list.Order(query)).
 		Preload("Category").
+		Preload("Brand").
 		Preload("Variants").
+		Preload("Images").
 		Preload("Inventory").
 		Find(&products).Error; err != nil {
 		return nil, fmt.Errorf("failed to fetch products: %w", err)
 	}
 
-	// Calculate pagination info
-	totalPages := int((total + int64(filters.Limit) - 1) / int64(filters.Limit))
-	hasNext := filters.Page < totalPages
-	hasPrevious := filters.Page > 1
-
+	page := list.PageInfo(total)
 	return &ProductListResponse{
 		Products:    products,
 		Total:       total,
-		Page:        filters.Page,
-		Limit:       filters.Limit,
-		TotalPages:  totalPages,
-		HasNext:     hasNext,
-		HasPrevious: hasPrevious,
+		Page:        page.Page,
+		Limit:       page.Size,
+		TotalPages:  page.TotalPages,
+		HasNext:     page.HasNext,
+		HasPrevious: page.HasPrevious,
 	}, nil
 }
 
@@ -150,7 +240,9 @@ func (s *ProductService) GetProductByID(id uuid.UUID) (*models.Product, error) {
 	var product models.Product
 
 	if err := s.db.Where("id = ?", id).
+		Scopes(withinPublishWindow(time.Now())).
 		Preload("Category").
+		Preload("Brand").
 		Preload("Variants").
 		Preload("Inventory").
 		First(&product).Error; err != nil {
@@ -168,7 +260,9 @@ func (s *ProductService) GetProductBySKU(sku string) (*models.Product, error) {
 	var product models.Product
 
 	if err := s.db.Where("sku = ?", sku).
+		Scopes(withinPublishWindow(time.Now())).
 		Preload("Category").
+		Preload("Brand").
 		Preload("Variants").
 		Preload("Inventory").
 		First(&product).Error; err != nil {
@@ -197,10 +291,17 @@ func (s *ProductService) CreateProduct(product *models.Product) error {
 		return fmt.Errorf("product SKU is required")
 	}
 
-	// Check if SKU already exists
-	var existingProduct models.Product
-	if err := s.db.Where("sku = ?", product.SKU).First(&existingProduct).Error; err == nil {
-		return fmt.Errorf("product with SKU %s already exists", product.SKU)
+	// Check if SKU already exists, in any case
+	conflict, err := skuConflict(s.db, product.SKU, uuid.Nil)
+	if err != nil {
+		return err
+	}
+	if conflict != nil {
+		return conflict
+	}
+
+	if err := validateProductMetadata(s.db, product.CategoryID, productMetadata(*product)); err != nil {
+		return err
 	}
 
 	// Set default values
@@ -213,11 +314,12 @@ func (s *ProductService) CreateProduct(product *models.Product) error {
 		product.ID = uuid.New()
 	}
 
-	if err := s.db.Create(product).Error; err != nil {
-		return fmt.Errorf("failed to create product: %w", err)
-	}
-
-	return nil
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(product).Error; err != nil {
+			return fmt.Errorf("failed to create product: %w", err)
+		}
+		return recordProductRevision(tx, product.ID, RevisionSourceStorefrontAPI, nil)
+	})
 }
 
 // UpdateProduct updates an existing product
@@ -240,17 +342,41 @@ func (s *ProductService) UpdateProduct(id uuid.UUID, updates map[string]interfac
 	}
 	if sku, ok := updates["sku"].(string); ok && sku != "" {
 		// Check if SKU already exists for a different product
-		var existingProduct models.Product
-		if err := s.db.Where("sku = ? AND id != ?", sku, id).First(&existingProduct).Error; err == nil {
-			return fmt.Errorf("product with SKU %s already exists", sku)
+		conflict, err := skuConflict(s.db, sku, id)
+		if err != nil {
+			return err
+		}
+		if conflict != nil {
+			return conflict
 		}
 	}
 
-	if err := s.db.Model(&product).Updates(updates).Error; err != nil {
-		return fmt.Errorf("failed to update product: %w", err)
+	// Metadata must match the schema of the category the product ends up in
+	_, metadataChanged := updates["metadata"]
+	if value, ok := updates["category_id"]; ok || metadataChanged {
+		categoryID := product.CategoryID
+		if value != nil {
+			parsed, err := uuid.Parse(fmt.Sprint(value))
+			if err != nil {
+				return fmt.Errorf("invalid category_id: %v", err)
+			}
+			categoryID = parsed
+		}
+		metadata := productMetadata(product)
+		if metadataChanged {
+			metadata, _ = updates["metadata"].(map[string]interface{})
+		}
+		if err := validateProductMetadata(s.db, categoryID, metadata); err != nil {
+			return err
+		}
 	}
 
-	return nil
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&product).Updates(updates).Error; err != nil {
+			return fmt.Errorf("failed to update product: %w", err)
+		}
+		return recordProductRevision(tx, product.ID, RevisionSourceStorefrontAPI, nil)
+	})
 }
 
 // DeleteProduct soft deletes a product
@@ -265,11 +391,12 @@ func (s *ProductService) DeleteProduct(id uuid.UUID) error {
 	}
 
 	// Soft delete by setting status to inactive
-	if err := s.db.Model(&product).Update("status", "inactive").Error; err != nil {
-		return fmt.Errorf("failed to delete product: %w", err)
-	}
-
-	return nil
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&product).Update("status", "inactive").Error; err != nil {
+			return fmt.Errorf("failed to delete product: %w", err)
+		}
+		return recordProductRevision(tx, product.ID, RevisionSourceStorefrontAPI, nil)
+	})
 }
 
 // GetCategories retrieves all active categories
@@ -301,7 +428,7 @@ func (s *ProductService) GetCategoryByID(id uuid.UUID) (*models.Category, error)
 	return &category, nil
 }
 
-// GetCategoryBySlug retrieves a category by slug
+// GetCategoryBySlug retrieves a category by slug, following redirects left by merged categories
 func (s *ProductService) GetCategoryBySlug(slug string) (*models.Category, error) {
 	var category models.Category
 
@@ -309,6 +436,10 @@ func (s *ProductService) GetCategoryBySlug(slug string) (*models.Category, error
 		Preload("Products").
 		First(&category).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
+			var redirect models.CategorySlugRedirect
+			if s.db.Where("from_slug = ?", slug).First(&redirect).Error == nil {
+				return s.GetCategoryByID(redirect.CategoryID)
+			}
 			return nil, fmt.Errorf("category not found")
 		}
 		return nil, fmt.Errorf("failed to fetch category: %w", err)
@@ -317,38 +448,86 @@ func (s *ProductService) GetCategoryBySlug(slug string) (*models.Category, error
 	return &category, nil
 }
 
-// SearchProducts performs full-text search on products
+// SearchProducts performs full-text search on products. Misspelled words are
+// corrected and synonyms searched for too, so "earbds" finds headphones. A
+// brand named in the query narrows the search to it, so "sony headphones"
+// finds Sony's headphones rather than anything mentioning Sony.
 func (s *ProductService) SearchProducts(query string, limit int) ([]models.Product, error) {
+	return s.SearchBrandProducts(query, uuid.Nil, limit)
+}
+
+// SearchBrandProducts searches one brand's products, or every product when
+// brandID is nil
+func (s *ProductService) SearchBrandProducts(query string, brandID uuid.UUID, limit int) ([]models.Product, error) {
+	ctx := context.Background()
+	if brandID == uuid.Nil {
+		if brand, rest := s.brands.DetectBrand(ctx, query); brand != nil {
+			brandID = brand.ID
+			query = rest
+		}
+	}
+
 	var products []models.Product
+	search := s.db.Scopes(publishedAt(time.Now()))
+	if brandID != uuid.Nil {
+		search = search.Where("brand_id = ?", brandID)
+	}
 
-	if query == "" {
+	rewrite := s.synonyms.Rewrite(ctx, query)
+	if len(rewrite.Queries) > 0 {
+		match := s.db.Session(&gorm.Session{NewDB: true})
+		for _, q := range rewrite.Queries {
+			searchTerm := "%" + q + "%"
+			match = match.Or("LOWER(name) LIKE ? OR LOWER(description) LIKE ?", searchTerm, searchTerm)
+		}
+		search = search.Where(match)
+	} else if brandID == uuid.Nil {
 		return products, nil
 	}
 
-	searchTerm := "%" + strings.ToLower(query) + "%"
-
-	if err := s.db.Where("LOWER(name) LIKE ? OR LOWER(description) LIKE ? OR ? = ANY(tags)",
-		searchTerm, searchTerm, strings.ToLower(query)).
-		Where("status = ?", "active").
-		Limit(limit).
+	// Fetch more candidates than asked for so boosts can bring up products
+	// the database would have cut off
+	if err := search.
+		Limit(limit * searchCandidateFactor).
 		Preload("Category").
+		Preload("Brand").
 		Preload("Variants").
+		Preload("Images").
+		Preload("Inventory").
 		Find(&products).Error; err != nil {
 		return nil, fmt.Errorf("failed to search products: %w", err)
 	}
 
-	return products, nil
+	// Name matches rank above description matches, then boosts, pins and
+	// low-stock demotion apply
+	relevance := func(product models.Product) float64 {
+		name := strings.ToLower(product.Name)
+		for _, q := range rewrite.Queries {
+			if strings.Contains(name, q) {
+				return 2
+			}
+		}
+		return 1
+	}
+	return s.ranking.RankProducts(ctx, append([]string{rewrite.Original}, rewrite.Queries...), products, relevance, limit)
+}
+
+// RecordSearchMiss records a storefront search that found no products
+func (s *ProductService) RecordSearchMiss(ctx context.Context, query, sessionID string, userID *uuid.UUID) {
+	s.misses.RecordMiss(ctx, query, MissSourceSearch, sessionID, userID)
 }
 
 // GetFeaturedProducts retrieves featured products
 func (s *ProductService) GetFeaturedProducts(limit int) ([]models.Product, error) {
 	var products []models.Product
 
-	if err := s.db.Where("status = ?", "active").
+	if err := s.db.Scopes(publishedAt(time.Now())).
 		Order("created_at DESC").
 		Limit(limit).
 		Preload("Category").
 		Preload("Variants").
+		Preload("Images").
+		Preload("Inventory").
 		Find(&products).Error; err != nil {
 		return nil, fmt.Errorf("failed to fetch featured products: %w", err)
 	}
@@ -366,8 +545,8 @@ func (s *ProductService) GetRelatedProducts(productID uuid.UUID, limit int) ([]m
 	var relatedProducts []models.Product
 
 	// Find products in the same category
-	if err := s.db.Where("category_id = ? AND id != ? AND status = ?",
-		product.CategoryID, productID, "active").
+	if err := s.db.Where("category_id = ? AND id != ?", product.CategoryID, productID).
+		Scopes(publishedAt(time.Now())).
 		Limit(limit).
 		Preload("Category").
 		Preload("Variants").
@@ -0,0 +1,295 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ProductSignalsMessage is the product channel message carrying a product's
+// viewer, purchase and stock hints
+const ProductSignalsMessage = "product_signals"
+
+var ErrInvalidSocialProofSettings = errors.New("invalid social proof settings")
+
+// ProductSignalsConfig configures the product channels
+type ProductSignalsConfig struct {
+	ViewerTTL    time.Duration // a viewer not heard from in this long stops counting
+	PushInterval time.Duration // how often subscribers get changed hints
+}
+
+// ProductSignalsConfigFromEnv reads PRODUCT_VIEWER_TTL_SECONDS (90) and
+// PRODUCT_SIGNALS_PUSH_SECONDS (10)
+func ProductSignalsConfigFromEnv() ProductSignalsConfig {
+	return ProductSignalsConfig{
+		ViewerTTL:    time.Duration(envInt("PRODUCT_VIEWER_TTL_SECONDS", 90)) * time.Second,
+		PushInterval: time.Duration(envInt("PRODUCT_SIGNALS_PUSH_SECONDS", 10)) * time.Second,
+	}
+}
+
+// defaultSocialProofSettings are the hints shown until an admin changes them
+func defaultSocialProofSettings() models.SocialProofSettings {
+	return models.SocialProofSettings{
+		Store:               DefaultStoreVariant,
+		ShowViewers:         true,
+		ShowPurchases:       true,
+		ShowLowStock:        true,
+		MinViewers:          2,
+		MinPurchases:        3,
+		PurchaseWindowHours: 24,
+		LowStockThreshold:   5,
+	}
+}
+
+// SocialProofSettingsRequest changes the hints shown; fields left out are kept
+type SocialProofSettingsRequest struct {
+	ShowViewers         *bool `json:"show_viewers"`
+	ShowPurchases       *bool `json:"show_purchases"`
+	ShowLowStock        *bool `json:"show_low_stock"`
+	MinViewers          *int  `json:"min_viewers"`
+	MinPurchases        *int  `json:"min_purchases"`
+	PurchaseWindowHours *int  `json:"purchase_window_hours"`
+	LowStockThreshold   *int  `json:"low_stock_threshold"`
+}
+
+// ProductSignals are the social proof and scarcity hints for a product. A
+// hint that's off, or under its minimum, is left out.
+type ProductSignals struct {
+	ProductID           uuid.UUID `json:"product_id"`
+	Viewers             int       `json:"viewers,omitempty"`               // shoppers viewing the product now
+	RecentPurchases     int       `json:"recent_purchases,omitempty"`      // orders of it in the purchase window
+	PurchaseWindowHours int       `json:"purchase_window_hours,omitempty"` // set with recent_purchases
+	LowStock            int       `json:"low_stock,omitempty"`             // units left, when few are
+}
+
+// ProductSignalsService counts the shoppers viewing each product and works
+// out the hints shown on it. Viewers are counted in memory by a hash of their
+// storefront session, so each instance counts its own connections and nothing
+// identifying is kept.
+type ProductSignalsService struct {
+	db     *gorm.DB
+	config ProductSignalsConfig
+
+	mu      sync.Mutex
+	viewers map[uuid.UUID]map[string]time.Time // product -> viewer hash -> last seen
+}
+
+// NewProductSignalsService creates a new ProductSignalsService
+func NewProductSignalsService(db *gorm.DB, config ProductSignalsConfig) *ProductSignalsService {
+	if config.ViewerTTL <= 0 {
+		config.ViewerTTL = 90 * time.Second
+	}
+	if config.PushInterval <= 0 {
+		config.PushInterval = 10 * time.Second
+	}
+	return &ProductSignalsService{
+		db:      db,
+		config:  config,
+		viewers: make(map[uuid.UUID]map[string]time.Time),
+	}
+}
+
+// Config returns the product channel configuration
+func (s *ProductSignalsService) Config() ProductSignalsConfig {
+	return s.config
+}
+
+// ViewerKey turns a storefront session into the key a viewer is counted by
+func ViewerKey(sessionID string) string {
+	sum := sha256.Sum256([]byte(sessionID))
+	return hex.EncodeToString(sum[:12])
+}
+
+// View counts a viewer as looking at a product, or keeps them counted
+func (s *ProductSignalsService) View(productID uuid.UUID, viewer string, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.viewers[productID] == nil {
+		s.viewers[productID] = make(map[string]time.Time)
+	}
+	s.viewers[productID][viewer] = now
+}
+
+// Leave stops counting a viewer on a product
+func (s *ProductSignalsService) Leave(productID uuid.UUID, viewer string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.viewers[productID], viewer)
+	if len(s.viewers[productID]) == 0 {
+		delete(s.viewers, productID)
+	}
+}
+
+// viewerCount returns how many viewers a product has, forgetting the ones
+// not heard from within the TTL
+func (s *ProductSignalsService) viewerCount(productID uuid.UUID, now time.Time) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for viewer, seen := range s.viewers[productID] {
+		if now.Sub(seen) > s.config.ViewerTTL {
+			delete(s.viewers[productID], viewer)
+		}
+	}
+	if len(s.viewers[productID]) == 0 {
+		delete(s.viewers, productID)
+	}
+	return len(s.viewers[productID])
+}
+
+// Signals returns the hints for a product
+func (s *ProductSignalsService) Signals(ctx context.Context, productID uuid.UUID, now time.Time) (*ProductSignals, error) {
+	signals, err := s.Snapshot(ctx, []uuid.UUID{productID}, now)
+	if err != nil {
+		return nil, err
+	}
+	return &signals[0], nil
+}
+
+// Snapshot returns the hints for each of the products, in order
+func (s *ProductSignalsService) Snapshot(ctx context.Context, productIDs []uuid.UUID, now time.Time) ([]ProductSignals, error) {
+	settings, err := s.GetSettings(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	signals := make([]ProductSignals, len(productIDs))
+	for i, id := range productIDs {
+		signals[i].ProductID = id
+		if settings.ShowViewers {
+			if viewers := s.viewerCount(id, now); viewers >= settings.MinViewers {
+				signals[i].Viewers = viewers
+			}
+		}
+	}
+	if len(productIDs) == 0 || (!settings.ShowPurchases && !settings.ShowLowStock) {
+		return signals, nil
+	}
+
+	db := s.db.WithContext(ctx)
+	purchases := make(map[uuid.UUID]int)
+	if settings.ShowPurchases {
+		var rows []struct {
+			ProductID uuid.UUID
+			Purchases int
+		}
+		err := db.Model(&models.OrderItem{}).
+			Select("order_items.product_id, COUNT(DISTINCT order_items.order_id) AS purchases").
+			Joins("JOIN orders ON orders.id = order_items.order_id").
+			Where("order_items.product_id IN ? AND orders.created_at >= ? AND orders.status <> ?",
+				productIDs, now.Add(-time.Duration(settings.PurchaseWindowHours)*time.Hour), "cancelled").
+			Group("order_items.product_id").Scan(&rows).Error
+		if err != nil {
+			return nil, fmt.Errorf("failed to count recent purchases: %v", err)
+		}
+		for _, row := range rows {
+			purchases[row.ProductID] = row.Purchases
+		}
+	}
+
+	stock := make(map[uuid.UUID]int)
+	if settings.ShowLowStock {
+		var rows []struct {
+			ProductID uuid.UUID
+			Units     int
+		}
+		err := db.Model(&models.Inventory{}).
+			Select("product_id, SUM(quantity_available - quantity_reserved) AS units").
+			Where("product_id IN ?", productIDs).
+			Group("product_id").Scan(&rows).Error
+		if err != nil {
+			return nil, fmt.Errorf("failed to count stock: %v", err)
+		}
+		for _, row := range rows {
+			stock[row.ProductID] = row.Units
+		}
+	}
+
+	for i := range signals {
+		id := signals[i].ProductID
+		if orders := purchases[id]; orders > 0 && orders >= settings.MinPurchases {
+			signals[i].RecentPurchases = orders
+			signals[i].PurchaseWindowHours = settings.PurchaseWindowHours
+		}
+		if left, tracked := stock[id]; tracked && left > 0 && left <= settings.LowStockThreshold {
+			signals[i].LowStock = left
+		}
+	}
+	return signals, nil
+}
+
+// GetSettings returns the store's social proof settings, or the defaults
+// before an admin changed them
+func (s *ProductSignalsService) GetSettings(ctx context.Context) (*models.SocialProofSettings, error) {
+	var settings models.SocialProofSettings
+	err := s.db.WithContext(ctx).Where("store = ?", DefaultStoreVariant).First(&settings).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		settings = defaultSocialProofSettings()
+		return &settings, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch social proof settings: %v", err)
+	}
+	return &settings, nil
+}
+
+// UpdateSettings changes the store's social proof settings
+func (s *ProductSignalsService) UpdateSettings(ctx context.Context, req SocialProofSettingsRequest, updatedBy *uuid.UUID) (*models.SocialProofSettings, error) {
+	settings, err := s.GetSettings(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.ShowViewers != nil {
+		settings.ShowViewers = *req.ShowViewers
+	}
+	if req.ShowPurchases != nil {
+		settings.ShowPurchases = *req.ShowPurchases
+	}
+	if req.ShowLowStock != nil {
+		settings.ShowLowStock = *req.ShowLowStock
+	}
+	if req.MinViewers != nil {
+		settings.MinViewers = *req.MinViewers
+	}
+	if req.MinPurchases != nil {
+		settings.MinPurchases = *req.MinPurchases
+	}
+	if req.PurchaseWindowHours != nil {
+		settings.PurchaseWindowHours = *req.PurchaseWindowHours
+	}
+	if req.LowStockThreshold != nil {
+		settings.LowStockThreshold = *req.LowStockThreshold
+	}
+
+	// A single viewer or buyer is never pointed at
+	switch {
+	case settings.MinViewers < 2:
+		return nil, fmt.Errorf("%w: min_viewers must be at least 2", ErrInvalidSocialProofSettings)
+	case settings.MinPurchases < 2:
+		return nil, fmt.Errorf("%w: min_purchases must be at least 2", ErrInvalidSocialProofSettings)
+	case settings.PurchaseWindowHours < 1 || settings.PurchaseWindowHours > 24*30:
+		return nil, fmt.Errorf("%w: purchase_window_hours must be between 1 and 720", ErrInvalidSocialProofSettings)
+	case settings.LowStockThreshold < 0:
+		return nil, fmt.Errorf("%w: low_stock_threshold can't be negative", ErrInvalidSocialProofSettings)
+	}
+
+	settings.UpdatedBy = updatedBy
+	if settings.ID == uuid.Nil {
+		settings.ID = uuid.New()
+		err = s.db.WithContext(ctx).Create(settings).Error
+	} else {
+		err = s.db.WithContext(ctx).Save(settings).Error
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to save social proof settings: %v", err)
+	}
+	return settings, nil
+}
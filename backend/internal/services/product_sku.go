@@ -0,0 +1,133 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// DefaultReservedSKUPattern matches the SKU prefixes kept for internal use
+const DefaultReservedSKUPattern = `^(SYS|TMP|INTERNAL)-`
+
+var (
+	// ErrSKURequired is returned when a product is saved without a SKU
+	ErrSKURequired = errors.New("sku is required")
+	// ErrSKUReserved is returned when a SKU matches the reserved SKU pattern
+	ErrSKUReserved = errors.New("sku is reserved")
+)
+
+// SKUConflictCode is the error code of a 409 for a SKU already in use
+const SKUConflictCode = "sku_conflict"
+
+// SKUConflictError is returned when another product already has a SKU,
+// compared ignoring case
+type SKUConflictError struct {
+	SKU                  string    `json:"sku"`
+	ConflictingSKU       string    `json:"conflicting_sku"`
+	ConflictingProductID uuid.UUID `json:"conflicting_product_id"`
+	ConflictingName      string    `json:"conflicting_name"`
+}
+
+func (e *SKUConflictError) Error() string {
+	return fmt.Sprintf("sku %s is already used by product %s (%s)", e.SKU, e.ConflictingName, e.ConflictingSKU)
+}
+
+// SKUConfig controls which SKUs admins may give products
+type SKUConfig struct {
+	Reserved *regexp.Regexp // SKUs matching it are refused; nil reserves none
+}
+
+// SKUConfigFromEnv reads RESERVED_SKU_PATTERN, a regular expression matched
+// ignoring case (default ^(SYS|TMP|INTERNAL)-). Set it to "none" to allow any
+// SKU. An invalid pattern keeps the default.
+func SKUConfigFromEnv() SKUConfig {
+	pattern := strings.TrimSpace(os.Getenv("RESERVED_SKU_PATTERN"))
+	if strings.EqualFold(pattern, "none") {
+		return SKUConfig{}
+	}
+	if pattern != "" {
+		reserved, err := regexp.Compile("(?i)" + pattern)
+		if err == nil {
+			return SKUConfig{Reserved: reserved}
+		}
+		log.Printf("Warning: invalid RESERVED_SKU_PATTERN %q, using the default: %v", pattern, err)
+	}
+	return SKUConfig{Reserved: regexp.MustCompile("(?i)" + DefaultReservedSKUPattern)}
+}
+
+// WithSKUConfig replaces the SKU rules read from the environment
+func (s *AdminProductService) WithSKUConfig(config SKUConfig) *AdminProductService {
+	s.skus = config
+	return s
+}
+
+// checkSKU validates a SKU given to a product, which is uuid.Nil for a new
+// one. Unique index errors only say which column clashed, so the conflicting
+// product is looked up first and reported by ID.
+func (s *AdminProductService) checkSKU(tx *gorm.DB, sku string, productID uuid.UUID) error {
+	if strings.TrimSpace(sku) == "" {
+		return ErrSKURequired
+	}
+	if s.skus.Reserved != nil && s.skus.Reserved.MatchString(sku) {
+		return fmt.Errorf("%w: %s matches %s", ErrSKUReserved, sku, strings.TrimPrefix(s.skus.Reserved.String(), "(?i)"))
+	}
+	conflict, err := skuConflict(tx, sku, productID)
+	if err != nil {
+		return err
+	}
+	if conflict != nil {
+		return conflict
+	}
+	return nil
+}
+
+// skuConflict returns the conflict with another product using sku in any
+// case, or nil when there is none
+func skuConflict(db *gorm.DB, sku string, productID uuid.UUID) (*SKUConflictError, error) {
+	var existing models.Product
+	query := db.Select("id", "sku", "name").Where("LOWER(sku) = LOWER(?)", sku)
+	if productID != uuid.Nil {
+		query = query.Where("id <> ?", productID)
+	}
+	if err := query.First(&existing).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to check sku: %v", err)
+	}
+	return &SKUConflictError{
+		SKU:                  sku,
+		ConflictingSKU:       existing.SKU,
+		ConflictingProductID: existing.ID,
+		ConflictingName:      existing.Name,
+	}, nil
+}
+
+// lostSKURace explains a failed product write: when another admin saved the
+// same SKU in any case between the check and the write, the unique index on
+// LOWER(sku) refused it and the product that won is reported instead of the
+// database error
+func lostSKURace(db *gorm.DB, sku string, productID uuid.UUID, err error) error {
+	if !isSKUIndexViolation(err) {
+		return err
+	}
+	if conflict, _ := skuConflict(db, sku, productID); conflict != nil {
+		return conflict
+	}
+	return err
+}
+
+// isSKUIndexViolation reports whether err is a unique violation of a product
+// SKU index. PostgreSQL names the index; SQLite names the case-insensitive
+// one, or the column for an exact match.
+func isSKUIndexViolation(err error) bool {
+	message := err.Error()
+	return strings.Contains(message, "idx_products_sku") || strings.Contains(message, "products.sku")
+}
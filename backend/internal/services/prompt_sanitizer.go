@@ -0,0 +1,84 @@
+package services
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+)
+
+// Flags reported by PromptSanitizer.SanitizeInput
+const (
+	PromptFlagInstructionOverride = "instruction_override"
+	PromptFlagRoleSpoofing        = "role_spoofing"
+	PromptFlagProfanity           = "profanity"
+)
+
+// profanityGuidance is added to the prompt for messages with profanity, whose
+// text is passed on as the shopper wrote it
+const profanityGuidance = "The shopper's next message contains strong language. Stay calm and courteous, don't repeat or mirror it, and keep helping with their request."
+
+// promptPattern is a suspicious input pattern and the flag it raises
+type promptPattern struct {
+	flag    string
+	pattern *regexp.Regexp
+}
+
+// PromptSanitizer neutralizes user text and catalog data before it is placed
+// in an LLM prompt
+type PromptSanitizer struct {
+	patterns  []promptPattern
+	profanity *regexp.Regexp
+}
+
+// NewPromptSanitizer creates a new PromptSanitizer with the default rules
+func NewPromptSanitizer() *PromptSanitizer {
+	return &PromptSanitizer{
+		patterns: []promptPattern{
+			{PromptFlagInstructionOverride, regexp.MustCompile(`(?i)\b(ignore|disregard|forget|override|bypass)\s+(all\s+|any\s+|the\s+|your\s+|my\s+)*(previous|prior|above|earlier|system|original)?\s*(instructions?|prompts?|rules|directions|guidelines)\b`)},
+			{PromptFlagInstructionOverride, regexp.MustCompile(`(?i)\b(you\s+are\s+now|from\s+now\s+on\s+you\s+are|act\s+as\s+if\s+you\s+have\s+no|pretend\s+(that\s+)?you\s+are)\b`)},
+			{PromptFlagInstructionOverride, regexp.MustCompile(`(?i)\b(reveal|print|show|repeat)\s+(me\s+)?(your|the)\s+(system\s+)?(prompt|instructions)\b`)},
+			{PromptFlagRoleSpoofing, regexp.MustCompile(`(?im)^\s*(system|assistant|developer)\s*:`)},
+			{PromptFlagRoleSpoofing, regexp.MustCompile(`(?i)<\|?\s*(im_start|im_end|system|endoftext)\s*\|?>|\[/?(INST|SYS)\]|<</?SYS>>|#{2,}\s*(system|instruction)s?\b`)},
+		},
+		profanity: regexp.MustCompile(`(?i)\b(fuck\w*|shit\w*|bitch\w*|asshole\w*|bastard\w*|cunt\w*|dick(head)?s?|motherfuck\w*)\b`),
+	}
+}
+
+// SanitizeInput removes instruction-override and role-spoofing attempts from
+// user text. It returns the cleaned text and the flags that were raised.
+// Profanity is flagged but left in, as masking it changes what the shopper
+// asked for.
+func (p *PromptSanitizer) SanitizeInput(text string) (string, []string) {
+	var flags []string
+	seen := map[string]bool{}
+	raise := func(flag string) {
+		if !seen[flag] {
+			seen[flag] = true
+			flags = append(flags, flag)
+		}
+	}
+
+	for _, rule := range p.patterns {
+		if rule.pattern.MatchString(text) {
+			raise(rule.flag)
+			text = rule.pattern.ReplaceAllString(text, "[removed]")
+		}
+	}
+
+	if p.profanity.MatchString(text) {
+		raise(PromptFlagProfanity)
+	}
+
+	return strings.TrimSpace(text), flags
+}
+
+// QuoteData renders a value as a JSON data block so the model reads catalog
+// fields as quoted data rather than instructions
+func (p *PromptSanitizer) QuoteData(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return `""`
+	}
+	// Catalog text must not be able to open or close the surrounding block
+	return strings.ReplaceAll(string(data), "```", "'''")
+}
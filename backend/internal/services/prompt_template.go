@@ -0,0 +1,277 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// The assistant's persona and tone when no prompt template is active
+const (
+	DefaultAssistantPersona = "You are a helpful shopping assistant for an e-commerce store. Your role is to help users find products, manage their cart, and complete purchases through natural conversation."
+	DefaultAssistantTone    = "Be friendly, helpful, and conversational. Always confirm actions taken and provide next steps."
+)
+
+// maxPromptCategories caps the categories listed in the system prompt
+const maxPromptCategories = 50
+
+// Prompt template errors
+var (
+	ErrPromptTemplateNotFound     = errors.New("prompt template not found")
+	ErrPromptTemplateNameTaken    = errors.New("a prompt template with this name already exists")
+	ErrPromptTemplateNameRequired = errors.New("prompt template name is required")
+)
+
+// PromptTemplateRequest is the payload for creating or replacing a prompt
+// template. Empty fields keep the built-in prompt's wording for that part.
+type PromptTemplateRequest struct {
+	Name               string   `json:"name" binding:"required,max=100"`
+	Persona            string   `json:"persona" binding:"max=4000"`
+	Tone               string   `json:"tone" binding:"max=1000"`
+	BannedTopics       []string `json:"banned_topics" binding:"max=50,dive,max=200"`
+	ActionInstructions string   `json:"action_instructions" binding:"max=4000"`
+	IsActive           bool     `json:"is_active"` // activating a template deactivates the others
+}
+
+// PromptTemplateService manages the prompt templates store owners shape
+// the chat assistant with
+type PromptTemplateService struct {
+	db *gorm.DB
+}
+
+// NewPromptTemplateService creates a new PromptTemplateService
+func NewPromptTemplateService(db *gorm.DB) *PromptTemplateService {
+	return &PromptTemplateService{
+		db: db,
+	}
+}
+
+// ListTemplates returns every prompt template by name
+func (s *PromptTemplateService) ListTemplates(ctx context.Context) ([]models.PromptTemplate, error) {
+	var templates []models.PromptTemplate
+	if err := s.db.WithContext(ctx).Order("name").Find(&templates).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch prompt templates: %v", err)
+	}
+	return templates, nil
+}
+
+// GetTemplate returns a prompt template
+func (s *PromptTemplateService) GetTemplate(ctx context.Context, id uuid.UUID) (*models.PromptTemplate, error) {
+	return findPromptTemplate(s.db.WithContext(ctx), id)
+}
+
+// ActiveTemplate returns the template the assistant uses, or nil when it
+// uses the built-in prompt
+func (s *PromptTemplateService) ActiveTemplate(ctx context.Context) (*models.PromptTemplate, error) {
+	var template models.PromptTemplate
+	err := s.db.WithContext(ctx).Where("is_active = ?", true).Order("updated_at DESC").First(&template).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch the active prompt template: %v", err)
+	}
+	return &template, nil
+}
+
+// CreateTemplate creates a prompt template
+func (s *PromptTemplateService) CreateTemplate(ctx context.Context, createdBy *uuid.UUID, req PromptTemplateRequest) (*models.PromptTemplate, error) {
+	template := &models.PromptTemplate{ID: uuid.New(), CreatedBy: createdBy}
+	if err := applyPromptTemplateRequest(template, req); err != nil {
+		return nil, err
+	}
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := checkPromptTemplateName(tx, template.Name, uuid.Nil); err != nil {
+			return err
+		}
+		if err := tx.Create(template).Error; err != nil {
+			return fmt.Errorf("failed to create prompt template: %v", err)
+		}
+		return deactivateOtherPromptTemplates(tx, template)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return template, nil
+}
+
+// UpdateTemplate replaces a prompt template
+func (s *PromptTemplateService) UpdateTemplate(ctx context.Context, id uuid.UUID, req PromptTemplateRequest) (*models.PromptTemplate, error) {
+	var template *models.PromptTemplate
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		template, err = findPromptTemplate(tx, id)
+		if err != nil {
+			return err
+		}
+		if err := applyPromptTemplateRequest(template, req); err != nil {
+			return err
+		}
+		if err := checkPromptTemplateName(tx, template.Name, id); err != nil {
+			return err
+		}
+		// Save writes is_active even when it's false
+		if err := tx.Save(template).Error; err != nil {
+			return fmt.Errorf("failed to update prompt template: %v", err)
+		}
+		return deactivateOtherPromptTemplates(tx, template)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return template, nil
+}
+
+// DeleteTemplate deletes a prompt template. Deleting the active one returns
+// the assistant to the built-in prompt.
+func (s *PromptTemplateService) DeleteTemplate(ctx context.Context, id uuid.UUID) error {
+	result := s.db.WithContext(ctx).Delete(&models.PromptTemplate{}, "id = ?", id)
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete prompt template: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrPromptTemplateNotFound
+	}
+	return nil
+}
+
+func findPromptTemplate(tx *gorm.DB, id uuid.UUID) (*models.PromptTemplate, error) {
+	var template models.PromptTemplate
+	if err := tx.Where("id = ?", id).First(&template).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPromptTemplateNotFound
+		}
+		return nil, fmt.Errorf("failed to fetch prompt template: %v", err)
+	}
+	return &template, nil
+}
+
+func applyPromptTemplateRequest(template *models.PromptTemplate, req PromptTemplateRequest) error {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return ErrPromptTemplateNameRequired
+	}
+
+	topics := []string{}
+	for _, topic := range req.BannedTopics {
+		if topic = strings.TrimSpace(topic); topic != "" {
+			topics = append(topics, topic)
+		}
+	}
+	encoded, err := json.Marshal(topics)
+	if err != nil {
+		return fmt.Errorf("failed to encode banned topics: %v", err)
+	}
+
+	template.Name = name
+	template.Persona = strings.TrimSpace(req.Persona)
+	template.Tone = strings.TrimSpace(req.Tone)
+	template.BannedTopics = datatypes.JSON(encoded)
+	template.ActionInstructions = strings.TrimSpace(req.ActionInstructions)
+	template.IsActive = req.IsActive
+	return nil
+}
+
+func checkPromptTemplateName(tx *gorm.DB, name string, id uuid.UUID) error {
+	var count int64
+	if err := tx.Model(&models.PromptTemplate{}).Where("LOWER(name) = LOWER(?) AND id <> ?", name, id).Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to check prompt template name: %v", err)
+	}
+	if count > 0 {
+		return ErrPromptTemplateNameTaken
+	}
+	return nil
+}
+
+// deactivateOtherPromptTemplates keeps a single template active
+func deactivateOtherPromptTemplates(tx *gorm.DB, template *models.PromptTemplate) error {
+	if !template.IsActive {
+		return nil
+	}
+	if err := tx.Model(&models.PromptTemplate{}).
+		Where("id <> ? AND is_active = ?", template.ID, true).
+		Update("is_active", false).Error; err != nil {
+		return fmt.Errorf("failed to deactivate prompt templates: %v", err)
+	}
+	return nil
+}
+
+// promptTemplateTopics returns a template's banned topics
+func promptTemplateTopics(template *models.PromptTemplate) []string {
+	var topics []string
+	if len(template.BannedTopics) > 0 {
+		_ = json.Unmarshal(template.BannedTopics, &topics)
+	}
+	return topics
+}
+
+// promptPersona opens the system prompt with who the assistant is
+func promptPersona(template *models.PromptTemplate) string {
+	if template != nil && template.Persona != "" {
+		return template.Persona
+	}
+	return DefaultAssistantPersona
+}
+
+// promptTone closes the system prompt with how the assistant speaks
+func promptTone(template *models.PromptTemplate) string {
+	if template != nil && template.Tone != "" {
+		return template.Tone
+	}
+	return DefaultAssistantTone
+}
+
+// promptStoreRules is what the template adds to the built-in instructions:
+// the topics to decline and the store's own guidance on actions
+func promptStoreRules(template *models.PromptTemplate) string {
+	if template == nil {
+		return ""
+	}
+	var rules string
+	if topics := promptTemplateTopics(template); len(topics) > 0 {
+		rules += "\n\nDon't discuss these topics. If the customer raises one, politely say you can't help with it and steer back to shopping:"
+		for _, topic := range topics {
+			rules += "\n- " + topic
+		}
+	}
+	if template.ActionInstructions != "" {
+		rules += "\n\nStore guidance on taking actions:\n" + template.ActionInstructions
+	}
+	return rules
+}
+
+// promptTemplate returns the active prompt template, or nil for the
+// built-in prompt when none is active or it can't be read
+func (s *ChatService) promptTemplate(ctx context.Context) *models.PromptTemplate {
+	template, err := s.prompts.ActiveTemplate(ctx)
+	if err != nil {
+		log.Printf("Warning: %v", err)
+		return nil
+	}
+	return template
+}
+
+// promptCategories returns the store's active top-level categories for the
+// assistant to describe the catalog with
+func (s *ChatService) promptCategories(ctx context.Context) []models.Category {
+	var categories []models.Category
+	if err := s.db.WithContext(ctx).
+		Select("id", "name", "description").
+		Where("parent_id IS NULL AND is_active = ?", true).
+		Order("sort_order, name").
+		Limit(maxPromptCategories).
+		Find(&categories).Error; err != nil {
+		log.Printf("Warning: failed to get categories: %v", err)
+		return nil
+	}
+	return categories
+}
@@ -0,0 +1,140 @@
+package services
+
+import (
+	"bytes"
+	"chat-ecommerce-backend/internal/models"
+	"fmt"
+	"strings"
+)
+
+// pdfLine is one line of text on a generated PDF page
+type pdfLine struct {
+	Text    string
+	Heading bool
+}
+
+const (
+	pdfPageWidth    = 612 // US Letter, in points
+	pdfPageHeight   = 792
+	pdfMargin       = 50
+	pdfLineHeight   = 14
+	pdfLinesPerPage = (pdfPageHeight - 2*pdfMargin) / pdfLineHeight
+)
+
+// RenderQuotePDF renders a quote as a printable PDF document
+func RenderQuotePDF(quote *models.Quote) []byte {
+	lines := []pdfLine{
+		{Text: "Quote " + quote.QuoteNumber, Heading: true},
+		{},
+		{Text: fmt.Sprintf("Status:      %s", quote.Status)},
+		{Text: fmt.Sprintf("Issued:      %s", quote.CreatedAt.Format("2006-01-02"))},
+	}
+	if quote.ValidUntil != nil {
+		lines = append(lines, pdfLine{Text: fmt.Sprintf("Valid until: %s", quote.ValidUntil.Format("2006-01-02"))})
+	}
+	lines = append(lines,
+		pdfLine{},
+		pdfLine{Text: fmt.Sprintf("%-32s %-14s %5s %10s %10s %11s", "Item", "SKU", "Qty", "List", "Price", "Total")},
+		pdfLine{Text: strings.Repeat("-", 87)},
+	)
+	for _, item := range quote.Items {
+		lines = append(lines, pdfLine{Text: fmt.Sprintf("%-32s %-14s %5d %10.2f %10.2f %11.2f",
+			truncateRunes(item.ProductName, 32), truncateRunes(item.SKU, 14), item.Quantity, item.ListPrice, item.UnitPrice, item.TotalPrice)})
+	}
+	lines = append(lines,
+		pdfLine{Text: strings.Repeat("-", 87)},
+		pdfLine{Text: fmt.Sprintf("%75s %11.2f", "Subtotal", quote.Subtotal)},
+		pdfLine{Text: fmt.Sprintf("%75s %11.2f", "Tax", quote.TaxAmount)},
+		pdfLine{Text: fmt.Sprintf("%75s %11.2f", "Shipping", quote.ShippingAmount)},
+		pdfLine{Text: fmt.Sprintf("%75s %11.2f", "Total ("+quote.Currency+")", quote.TotalAmount)},
+	)
+	if quote.AdminNotes != "" {
+		lines = append(lines, pdfLine{}, pdfLine{Text: "Notes", Heading: true})
+		for _, note := range strings.Split(quote.AdminNotes, "\n") {
+			lines = append(lines, pdfLine{Text: note})
+		}
+	}
+
+	return renderPDF(lines)
+}
+
+// renderPDF lays out lines of text on as many pages as needed. Headings use
+// Helvetica Bold and body text uses Courier so that table columns line up.
+func renderPDF(lines []pdfLine) []byte {
+	var pages [][]pdfLine
+	for len(lines) > pdfLinesPerPage {
+		pages = append(pages, lines[:pdfLinesPerPage])
+		lines = lines[pdfLinesPerPage:]
+	}
+	pages = append(pages, lines)
+
+	// Objects 1-4 are the catalog, page tree and fonts; each page then adds a page and a content stream
+	objects := make([]string, 4+2*len(pages))
+	kids := make([]string, len(pages))
+	for i, page := range pages {
+		pageObj, contentObj := 5+2*i, 6+2*i
+		kids[i] = fmt.Sprintf("%d 0 R", pageObj)
+
+		var content bytes.Buffer
+		y := pdfPageHeight - pdfMargin
+		for _, line := range page {
+			font := "/F2 9"
+			if line.Heading {
+				font = "/F1 14"
+			}
+			fmt.Fprintf(&content, "BT %s Tf %d %d Td (%s) Tj ET\n", font, pdfMargin, y, pdfEscape(line.Text))
+			y -= pdfLineHeight
+		}
+
+		objects[pageObj-1] = fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			pdfPageWidth, pdfPageHeight, contentObj)
+		objects[contentObj-1] = fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String())
+	}
+	objects[0] = "<< /Type /Catalog /Pages 2 0 R >>"
+	objects[1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages))
+	objects[2] = "<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>"
+	objects[3] = "<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>"
+
+	var out bytes.Buffer
+	out.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = out.Len()
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+
+	return out.Bytes()
+}
+
+// pdfEscape escapes a string for a PDF literal, replacing characters the
+// standard fonts cannot show
+func pdfEscape(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 32 || r > 126:
+			b.WriteByte('?')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// truncateRunes shortens text to at most n runes
+func truncateRunes(text string, n int) string {
+	runes := []rune(text)
+	if len(runes) <= n {
+		return text
+	}
+	return string(runes[:n])
+}
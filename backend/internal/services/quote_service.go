@@ -0,0 +1,452 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// Quote statuses
+const (
+	QuoteStatusRequested = "requested"
+	QuoteStatusApproved  = "approved"
+	QuoteStatusRejected  = "rejected"
+	QuoteStatusAccepted  = "accepted"
+	QuoteStatusConverted = "converted"
+	QuoteStatusExpired   = "expired"
+)
+
+// ErrQuoteNotFound is returned when a quote does not exist or belongs to another user
+var ErrQuoteNotFound = errors.New("quote not found")
+
+// QuoteService turns B2B carts into quotes and quotes into orders
+type QuoteService struct {
+	db     *gorm.DB
+	carts  *ShoppingCartService
+	orders *OrderService
+}
+
+// NewQuoteService creates a new QuoteService
+func NewQuoteService(db *gorm.DB) *QuoteService {
+	return &QuoteService{
+		db:     db,
+		carts:  NewShoppingCartService(db),
+		orders: NewOrderService(db),
+	}
+}
+
+// CreateQuoteRequest asks for a quote on the current cart
+type CreateQuoteRequest struct {
+	Notes string `json:"notes"`
+}
+
+// QuoteItemPrice sets the negotiated price of one quote line
+type QuoteItemPrice struct {
+	ItemID    uuid.UUID `json:"item_id" binding:"required"`
+	UnitPrice float64   `json:"unit_price" binding:"min=0"`
+}
+
+// UpdateQuoteRequest is an admin's negotiation of a requested quote
+type UpdateQuoteRequest struct {
+	Items          []QuoteItemPrice `json:"items"`
+	ShippingAmount *float64         `json:"shipping_amount"`
+	ValidUntil     *time.Time       `json:"valid_until"`
+	AdminNotes     *string          `json:"admin_notes"`
+}
+
+// RejectQuoteRequest records why a quote was declined
+type RejectQuoteRequest struct {
+	Reason string `json:"reason"`
+}
+
+// ConvertQuoteRequest carries the checkout details for turning a quote into an order
+type ConvertQuoteRequest struct {
+	ShippingAddress map[string]interface{} `json:"shipping_address" binding:"required"`
+	BillingAddress  map[string]interface{} `json:"billing_address" binding:"required"`
+	PaymentMethod   string                 `json:"payment_method" binding:"required"`
+}
+
+// QuoteValidityFromEnv returns QUOTE_VALIDITY_DAYS, or 30 days
+func QuoteValidityFromEnv() time.Duration {
+	return time.Duration(envInt("QUOTE_VALIDITY_DAYS", 30)) * 24 * time.Hour
+}
+
+// CreateFromCart converts the user's cart into a quote at list prices
+func (s *QuoteService) CreateFromCart(ctx context.Context, sessionID string, userID uuid.UUID, req CreateQuoteRequest) (*models.Quote, error) {
+	cart, err := s.carts.GetCart(sessionID, &userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(cart.Items) == 0 {
+		return nil, fmt.Errorf("cannot request a quote for an empty cart")
+	}
+
+	now := time.Now()
+	quote := &models.Quote{
+		ID:            uuid.New(),
+		QuoteNumber:   fmt.Sprintf("QTE-%d", now.UnixNano()/int64(time.Millisecond)),
+		UserID:        userID,
+		SessionID:     sessionID,
+		Status:        QuoteStatusRequested,
+		Currency:      cart.Currency,
+		CustomerNotes: req.Notes,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	shipping := 9.99 // Fixed shipping, as in CreateOrder
+	for _, item := range cart.Items {
+		quote.Items = append(quote.Items, models.QuoteItem{
+			ID:          uuid.New(),
+			QuoteID:     quote.ID,
+			ProductID:   item.ProductID,
+			VariantID:   item.VariantID,
+			ProductName: item.ProductName,
+			SKU:         item.SKU,
+			Quantity:    item.Quantity,
+			ListPrice:   item.UnitPrice,
+			UnitPrice:   item.UnitPrice,
+		})
+	}
+	recalculateQuote(quote, &shipping)
+
+	if err := s.db.WithContext(ctx).Create(quote).Error; err != nil {
+		return nil, fmt.Errorf("failed to create quote: %v", err)
+	}
+
+	return quote, nil
+}
+
+// ListQuotes returns quotes, newest first, optionally filtered by user and status
+func (s *QuoteService) ListQuotes(ctx context.Context, userID *uuid.UUID, status string) ([]models.Quote, error) {
+	query := s.db.WithContext(ctx).Preload("Items")
+	if userID != nil {
+		query = query.Where("user_id = ?", *userID)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var quotes []models.Quote
+	if err := query.Order("created_at DESC").Find(&quotes).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch quotes: %v", err)
+	}
+	for i := range quotes {
+		expireQuote(&quotes[i], time.Now())
+	}
+	return quotes, nil
+}
+
+// GetQuote returns a quote. When userID is set, quotes of other users are not found.
+func (s *QuoteService) GetQuote(ctx context.Context, quoteID uuid.UUID, userID *uuid.UUID) (*models.Quote, error) {
+	query := s.db.WithContext(ctx).Preload("Items").Where("id = ?", quoteID)
+	if userID != nil {
+		query = query.Where("user_id = ?", *userID)
+	}
+
+	var quote models.Quote
+	if err := query.First(&quote).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrQuoteNotFound
+		}
+		return nil, fmt.Errorf("failed to fetch quote: %v", err)
+	}
+	expireQuote(&quote, time.Now())
+	return &quote, nil
+}
+
+// UpdateQuote sets negotiated prices, shipping, validity and notes on a requested quote
+func (s *QuoteService) UpdateQuote(ctx context.Context, quoteID uuid.UUID, req UpdateQuoteRequest) (*models.Quote, error) {
+	quote, err := s.GetQuote(ctx, quoteID, nil)
+	if err != nil {
+		return nil, err
+	}
+	if quote.Status != QuoteStatusRequested {
+		return nil, fmt.Errorf("only requested quotes can be changed, quote is %s", quote.Status)
+	}
+
+	prices := map[uuid.UUID]float64{}
+	for _, price := range req.Items {
+		prices[price.ItemID] = price.UnitPrice
+	}
+	for i := range quote.Items {
+		if price, ok := prices[quote.Items[i].ID]; ok {
+			quote.Items[i].UnitPrice = price
+			delete(prices, quote.Items[i].ID)
+		}
+	}
+	for itemID := range prices {
+		return nil, fmt.Errorf("quote item %s not found", itemID)
+	}
+
+	recalculateQuote(quote, req.ShippingAmount)
+	if req.ValidUntil != nil {
+		quote.ValidUntil = req.ValidUntil
+	}
+	if req.AdminNotes != nil {
+		quote.AdminNotes = *req.AdminNotes
+	}
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, item := range quote.Items {
+			updates := map[string]interface{}{"unit_price": item.UnitPrice, "total_price": item.TotalPrice}
+			if err := tx.Model(&models.QuoteItem{}).Where("id = ?", item.ID).Updates(updates).Error; err != nil {
+				return fmt.Errorf("failed to update quote item: %v", err)
+			}
+		}
+		return tx.Model(quote).Updates(map[string]interface{}{
+			"subtotal":        quote.Subtotal,
+			"tax_amount":      quote.TaxAmount,
+			"shipping_amount": quote.ShippingAmount,
+			"total_amount":    quote.TotalAmount,
+			"valid_until":     quote.ValidUntil,
+			"admin_notes":     quote.AdminNotes,
+			"updated_at":      time.Now(),
+		}).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update quote: %v", err)
+	}
+
+	return quote, nil
+}
+
+// ApproveQuote sends a requested quote to the customer. Quotes without a
+// validity date are valid for QUOTE_VALIDITY_DAYS from approval.
+func (s *QuoteService) ApproveQuote(ctx context.Context, quoteID uuid.UUID, adminID *uuid.UUID) (*models.Quote, error) {
+	quote, err := s.GetQuote(ctx, quoteID, nil)
+	if err != nil {
+		return nil, err
+	}
+	if quote.Status != QuoteStatusRequested {
+		return nil, fmt.Errorf("only requested quotes can be approved, quote is %s", quote.Status)
+	}
+
+	now := time.Now()
+	if quote.ValidUntil == nil {
+		validUntil := now.Add(QuoteValidityFromEnv())
+		quote.ValidUntil = &validUntil
+	} else if !quote.ValidUntil.After(now) {
+		return nil, fmt.Errorf("valid_until must be in the future")
+	}
+
+	quote.Status = QuoteStatusApproved
+	quote.ApprovedBy = adminID
+	quote.ApprovedAt = &now
+	if err := s.db.WithContext(ctx).Model(quote).Updates(map[string]interface{}{
+		"status":      quote.Status,
+		"valid_until": quote.ValidUntil,
+		"approved_by": quote.ApprovedBy,
+		"approved_at": quote.ApprovedAt,
+		"updated_at":  now,
+	}).Error; err != nil {
+		return nil, fmt.Errorf("failed to approve quote: %v", err)
+	}
+
+	return quote, nil
+}
+
+// RejectQuote declines a requested quote
+func (s *QuoteService) RejectQuote(ctx context.Context, quoteID uuid.UUID, req RejectQuoteRequest) (*models.Quote, error) {
+	quote, err := s.GetQuote(ctx, quoteID, nil)
+	if err != nil {
+		return nil, err
+	}
+	if quote.Status != QuoteStatusRequested {
+		return nil, fmt.Errorf("only requested quotes can be rejected, quote is %s", quote.Status)
+	}
+
+	quote.Status = QuoteStatusRejected
+	quote.RejectionReason = req.Reason
+	if err := s.db.WithContext(ctx).Model(quote).Updates(map[string]interface{}{
+		"status":           quote.Status,
+		"rejection_reason": quote.RejectionReason,
+		"updated_at":       time.Now(),
+	}).Error; err != nil {
+		return nil, fmt.Errorf("failed to reject quote: %v", err)
+	}
+
+	return quote, nil
+}
+
+// AcceptQuote records the customer's acceptance of an approved quote
+func (s *QuoteService) AcceptQuote(ctx context.Context, quoteID, userID uuid.UUID) (*models.Quote, error) {
+	quote, err := s.GetQuote(ctx, quoteID, &userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.persistExpiry(ctx, quote); err != nil {
+		return nil, err
+	}
+	if quote.Status != QuoteStatusApproved {
+		return nil, fmt.Errorf("only approved quotes can be accepted, quote is %s", quote.Status)
+	}
+
+	quote.Status = QuoteStatusAccepted
+	if err := s.db.WithContext(ctx).Model(quote).Updates(map[string]interface{}{
+		"status":     quote.Status,
+		"updated_at": time.Now(),
+	}).Error; err != nil {
+		return nil, fmt.Errorf("failed to accept quote: %v", err)
+	}
+
+	return quote, nil
+}
+
+// ConvertToOrder places an order for an accepted quote at its negotiated prices
+func (s *QuoteService) ConvertToOrder(ctx context.Context, quoteID, userID uuid.UUID, req ConvertQuoteRequest) (*Order, error) {
+	quote, err := s.GetQuote(ctx, quoteID, &userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.persistExpiry(ctx, quote); err != nil {
+		return nil, err
+	}
+	if quote.Status != QuoteStatusAccepted {
+		return nil, fmt.Errorf("only accepted quotes can be converted, quote is %s", quote.Status)
+	}
+
+	items := make([]OrderItemRequest, len(quote.Items))
+	for i, item := range quote.Items {
+		items[i] = OrderItemRequest{ProductID: item.ProductID, VariantID: item.VariantID, Quantity: item.Quantity}
+	}
+	if err := s.orders.validator.Validate(ctx, OrderValidationInput{
+		Items:           items,
+		Subtotal:        quote.Subtotal,
+		ShippingCountry: ShippingCountry(req.ShippingAddress),
+	}); err != nil {
+		return nil, err
+	}
+
+	shippingJSON, err := json.Marshal(req.ShippingAddress)
+	if err != nil {
+		return nil, errors.New("failed to marshal shipping address")
+	}
+	billingJSON, err := json.Marshal(req.BillingAddress)
+	if err != nil {
+		return nil, errors.New("failed to marshal billing address")
+	}
+
+	now := time.Now()
+	order := &Order{
+		ID:              uuid.New(),
+		OrderNumber:     s.orders.generateOrderNumber(),
+		UserID:          quote.UserID,
+		SessionID:       quote.SessionID,
+		Status:          "pending",
+		Subtotal:        quote.Subtotal,
+		TaxAmount:       quote.TaxAmount,
+		ShippingAmount:  quote.ShippingAmount,
+		TotalAmount:     quote.TotalAmount,
+		Currency:        quote.Currency,
+		PaymentStatus:   "pending",
+		ShippingAddress: datatypes.JSON(shippingJSON),
+		BillingAddress:  datatypes.JSON(billingJSON),
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+	orderItems := make([]OrderItem, 0, len(quote.Items))
+	for _, item := range quote.Items {
+		orderItems = append(orderItems, OrderItem{
+			ID:         uuid.New(),
+			OrderID:    order.ID,
+			ProductID:  item.ProductID,
+			VariantID:  item.VariantID,
+			Quantity:   item.Quantity,
+			UnitPrice:  item.UnitPrice,
+			TotalPrice: item.TotalPrice,
+			CreatedAt:  now,
+		})
+	}
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, item := range orderItems {
+			if err := s.orders.checkInventory(tx, item.ProductID, item.VariantID, item.Quantity); err != nil {
+				return err
+			}
+		}
+		if err := tx.Create(order).Error; err != nil {
+			return errors.New("failed to create order")
+		}
+		if err := tx.Create(&orderItems).Error; err != nil {
+			return errors.New("failed to create order items")
+		}
+		if err := s.orders.reserveInventory(tx, orderItems); err != nil {
+			return err
+		}
+
+		// Guard against converting the same quote twice concurrently
+		result := tx.Model(&models.Quote{}).
+			Where("id = ? AND status = ?", quote.ID, QuoteStatusAccepted).
+			Updates(map[string]interface{}{
+				"status":     QuoteStatusConverted,
+				"order_id":   order.ID,
+				"updated_at": now,
+			})
+		if result.Error != nil {
+			return fmt.Errorf("failed to update quote: %v", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("quote has already been converted")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.orders.GetOrderByID(ctx, order.ID)
+}
+
+// persistExpiry saves the expired status of a quote whose validity has passed
+func (s *QuoteService) persistExpiry(ctx context.Context, quote *models.Quote) error {
+	if quote.Status != QuoteStatusExpired {
+		return nil
+	}
+	err := s.db.WithContext(ctx).Model(&models.Quote{}).
+		Where("id = ? AND status IN ?", quote.ID, []string{QuoteStatusApproved, QuoteStatusAccepted}).
+		Update("status", QuoteStatusExpired).Error
+	if err != nil {
+		return fmt.Errorf("failed to expire quote: %v", err)
+	}
+	return nil
+}
+
+// expireQuote marks an approved or accepted quote as expired once its validity has passed
+func expireQuote(quote *models.Quote, now time.Time) {
+	if quote.ValidUntil == nil || quote.ValidUntil.After(now) {
+		return
+	}
+	if quote.Status == QuoteStatusApproved || quote.Status == QuoteStatusAccepted {
+		quote.Status = QuoteStatusExpired
+	}
+}
+
+// recalculateQuote recomputes line and quote totals with the same tax rate as
+// checkout. A nil shipping keeps the current shipping amount.
+func recalculateQuote(quote *models.Quote, shipping *float64) {
+	subtotal := 0.0
+	for i := range quote.Items {
+		quote.Items[i].TotalPrice = roundCents(quote.Items[i].UnitPrice * float64(quote.Items[i].Quantity))
+		subtotal += quote.Items[i].TotalPrice
+	}
+
+	if shipping != nil {
+		quote.ShippingAmount = *shipping
+	}
+
+	quote.Subtotal = roundCents(subtotal)
+	quote.TaxAmount = roundCents(subtotal * 0.08) // 8% tax, as in CreateOrder
+	quote.TotalAmount = roundCents(quote.Subtotal + quote.TaxAmount + quote.ShippingAmount)
+}
+
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
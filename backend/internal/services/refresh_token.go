@@ -0,0 +1,211 @@
+package services
+
+import (
+	authmodels "chat-ecommerce-backend/internal/models/auth"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Refresh token errors
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token was already used; this sign-in has been ended on all devices")
+
+	// errRotationLost rolls back a rotation that lost a race for the same token
+	errRotationLost = errors.New("refresh token was rotated concurrently")
+)
+
+// IssuedRefreshToken is a new refresh token. Token is only available here;
+// just its hash is stored.
+type IssuedRefreshToken struct {
+	Token     string    `json:"refresh_token"`
+	FamilyID  uuid.UUID `json:"-"`
+	ExpiresAt time.Time `json:"refresh_expires_at"`
+
+	id uuid.UUID
+}
+
+// RefreshTokenService issues one-time-use refresh tokens and rotates them.
+// The tokens of one sign-in form a family; presenting a spent token is taken
+// as theft and revokes the family, which also rejects its access tokens.
+type RefreshTokenService struct {
+	db  *gorm.DB
+	ttl time.Duration
+}
+
+// NewRefreshTokenService creates a new RefreshTokenService. Tokens last
+// JWT_REFRESH_EXPIRES_IN, 168h by default.
+func NewRefreshTokenService(db *gorm.DB) *RefreshTokenService {
+	ttl, err := time.ParseDuration(os.Getenv("JWT_REFRESH_EXPIRES_IN"))
+	if err != nil || ttl <= 0 {
+		ttl = 7 * 24 * time.Hour
+	}
+	return &RefreshTokenService{
+		db:  db,
+		ttl: ttl,
+	}
+}
+
+// WithTTL replaces how long a refresh token stays valid
+func (s *RefreshTokenService) WithTTL(ttl time.Duration) *RefreshTokenService {
+	s.ttl = ttl
+	return s
+}
+
+// Issue starts a new token family for a sign-in
+func (s *RefreshTokenService) Issue(ctx context.Context, userID uuid.UUID, ipAddress, userAgent string) (*IssuedRefreshToken, error) {
+	return s.issue(s.db.WithContext(ctx), userID, uuid.New(), ipAddress, userAgent)
+}
+
+// Rotate exchanges a refresh token for a new one in the same family and
+// returns the user it belongs to. A token can be exchanged once; using it
+// again revokes the family and returns ErrRefreshTokenReused.
+func (s *RefreshTokenService) Rotate(ctx context.Context, token, ipAddress, userAgent string) (uuid.UUID, *IssuedRefreshToken, error) {
+	var current authmodels.RefreshToken
+	var issued *IssuedRefreshToken
+	reused := false
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("token = ?", hashResetToken(token)).First(&current).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidRefreshToken
+			}
+			return fmt.Errorf("failed to find refresh token: %v", err)
+		}
+		if current.RevokedAt != nil || current.IsExpired() {
+			return ErrInvalidRefreshToken
+		}
+		if current.UsedAt != nil {
+			reused = true
+			return nil
+		}
+
+		next, err := s.issue(tx, current.UserID, current.FamilyID, ipAddress, userAgent)
+		if err != nil {
+			return err
+		}
+		// Only one concurrent exchange of the same token can win
+		result := tx.Model(&authmodels.RefreshToken{}).
+			Where("id = ? AND used_at IS NULL", current.ID).
+			Updates(map[string]interface{}{"used_at": time.Now(), "replaced_by": next.id})
+		if result.Error != nil {
+			return fmt.Errorf("failed to update refresh token: %v", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			reused = true
+			return errRotationLost
+		}
+		issued = next
+		return nil
+	})
+	if errors.Is(err, errRotationLost) {
+		err = nil
+	}
+	if err != nil {
+		return uuid.Nil, nil, err
+	}
+
+	if reused {
+		log.Printf("Refresh token reuse for user %s; revoking token family %s", current.UserID, current.FamilyID)
+		if err := s.revokeFamily(ctx, current.FamilyID); err != nil {
+			return uuid.Nil, nil, err
+		}
+		return uuid.Nil, nil, ErrRefreshTokenReused
+	}
+	return current.UserID, issued, nil
+}
+
+// Revoke ends the sign-in a refresh token belongs to, e.g. on logout
+func (s *RefreshTokenService) Revoke(ctx context.Context, token string) error {
+	var current authmodels.RefreshToken
+	if err := s.db.WithContext(ctx).Where("token = ?", hashResetToken(token)).First(&current).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidRefreshToken
+		}
+		return fmt.Errorf("failed to find refresh token: %v", err)
+	}
+	return s.revokeFamily(ctx, current.FamilyID)
+}
+
+// RevokeAll ends every sign-in of a user and returns how many were active
+func (s *RefreshTokenService) RevokeAll(ctx context.Context, userID uuid.UUID) (int64, error) {
+	var families int64
+	if err := s.db.WithContext(ctx).Model(&authmodels.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL AND used_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Distinct("family_id").Count(&families).Error; err != nil {
+		return 0, fmt.Errorf("failed to count sessions: %v", err)
+	}
+	if err := revokeUserRefreshTokens(s.db.WithContext(ctx), []uuid.UUID{userID}); err != nil {
+		return 0, err
+	}
+	return families, nil
+}
+
+// IsRevoked reports whether the sign-in an access token was issued for has
+// been revoked. Tokens issued before rotation have no session and pass; a
+// failed lookup is logged and lets the request through.
+func (s *RefreshTokenService) IsRevoked(ctx context.Context, sessionID string) bool {
+	familyID, err := uuid.Parse(sessionID)
+	if err != nil {
+		return false
+	}
+
+	var revoked int64
+	if err := s.db.WithContext(ctx).Model(&authmodels.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NOT NULL", familyID).
+		Count(&revoked).Error; err != nil {
+		log.Printf("Failed to check token revocation: %v", err)
+		return false
+	}
+	return revoked > 0
+}
+
+func (s *RefreshTokenService) issue(tx *gorm.DB, userID, familyID uuid.UUID, ipAddress, userAgent string) (*IssuedRefreshToken, error) {
+	token, err := newResetToken()
+	if err != nil {
+		return nil, err
+	}
+	if len(userAgent) > 255 {
+		userAgent = userAgent[:255]
+	}
+
+	record := authmodels.RefreshToken{
+		ID:        uuid.New(),
+		UserID:    userID,
+		FamilyID:  familyID,
+		Token:     hashResetToken(token),
+		IPAddress: ipAddress,
+		UserAgent: userAgent,
+		ExpiresAt: time.Now().Add(s.ttl),
+	}
+	if err := tx.Create(&record).Error; err != nil {
+		return nil, fmt.Errorf("failed to save refresh token: %v", err)
+	}
+	return &IssuedRefreshToken{Token: token, FamilyID: familyID, ExpiresAt: record.ExpiresAt, id: record.ID}, nil
+}
+
+func (s *RefreshTokenService) revokeFamily(ctx context.Context, familyID uuid.UUID) error {
+	if err := s.db.WithContext(ctx).Model(&authmodels.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now()).Error; err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens: %v", err)
+	}
+	return nil
+}
+
+// revokeUserRefreshTokens ends every sign-in of the users, e.g. when they
+// are suspended
+func revokeUserRefreshTokens(tx *gorm.DB, userIDs []uuid.UUID) error {
+	if err := tx.Model(&authmodels.RefreshToken{}).
+		Where("user_id IN ? AND revoked_at IS NULL", userIDs).
+		Update("revoked_at", time.Now()).Error; err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package services
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RequestThrottle allows each key, such as a client address, a number of
+// requests per window. Counts are kept in memory, so each API instance
+// throttles on its own.
+type RequestThrottle struct {
+	limit  int
+	window time.Duration
+
+	mu      sync.Mutex
+	windows map[string]*throttleWindow
+}
+
+// throttleWindow counts a key's requests since start
+type throttleWindow struct {
+	start time.Time
+	count int
+}
+
+// ThrottleStatus is where a key stands in its current window
+type ThrottleStatus struct {
+	Limit     int
+	Remaining int
+	Reset     time.Duration // until the window ends and the count starts over
+}
+
+// WriteHeaders sets the X-RateLimit-Limit, X-RateLimit-Remaining and
+// X-RateLimit-Reset (seconds) headers clients throttle themselves by
+func (s ThrottleStatus) WriteHeaders(h http.Header) {
+	h.Set("X-RateLimit-Limit", strconv.Itoa(s.Limit))
+	h.Set("X-RateLimit-Remaining", strconv.Itoa(s.Remaining))
+	h.Set("X-RateLimit-Reset", strconv.Itoa(int((s.Reset+time.Second-1)/time.Second)))
+}
+
+// APIRateThrottleFromEnv allows API_RATE_PER_MINUTE (default 600) requests
+// per client address a minute across the whole API
+func APIRateThrottleFromEnv() *RequestThrottle {
+	return NewRequestThrottle(envInt("API_RATE_PER_MINUTE", 600), time.Minute)
+}
+
+// NewRequestThrottle allows limit requests per key per window. A limit of 0
+// or less allows everything.
+func NewRequestThrottle(limit int, window time.Duration) *RequestThrottle {
+	return &RequestThrottle{
+		limit:   limit,
+		window:  window,
+		windows: map[string]*throttleWindow{},
+	}
+}
+
+// Allow counts a request for key and reports whether it's within the limit
+// and, if not, how long until the key may try again
+func (t *RequestThrottle) Allow(key string, now time.Time) (bool, time.Duration) {
+	status, allowed := t.Take(key, now)
+	if allowed {
+		return true, 0
+	}
+	return false, status.Reset
+}
+
+// Take counts a request for key and returns the key's standing after it and
+// whether it's within the limit. Without a limit the status is empty.
+func (t *RequestThrottle) Take(key string, now time.Time) (ThrottleStatus, bool) {
+	if t.limit <= 0 {
+		return ThrottleStatus{}, true
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	w, ok := t.windows[key]
+	if !ok || now.Sub(w.start) >= t.window {
+		if len(t.windows) > 10000 {
+			t.prune(now)
+		}
+		w = &throttleWindow{start: now}
+		t.windows[key] = w
+	}
+	w.count++
+	status := ThrottleStatus{
+		Limit:     t.limit,
+		Remaining: t.limit - w.count,
+		Reset:     w.start.Add(t.window).Sub(now),
+	}
+	if status.Remaining < 0 {
+		status.Remaining = 0
+	}
+	return status, w.count <= t.limit
+}
+
+// Limited reports whether the throttle has a limit at all
+func (t *RequestThrottle) Limited() bool {
+	return t.limit > 0
+}
+
+// prune drops the windows that have ended
+func (t *RequestThrottle) prune(now time.Time) {
+	for key, w := range t.windows {
+		if now.Sub(w.start) >= t.window {
+			delete(t.windows, key)
+		}
+	}
+}
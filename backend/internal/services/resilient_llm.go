@@ -0,0 +1,296 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// ErrLLMUnavailable is returned when the provider is degraded: retries were
+// exhausted or the circuit breaker is open
+var ErrLLMUnavailable = errors.New("assistant is temporarily unavailable")
+
+// Circuit breaker states
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half_open"
+)
+
+// ResilientLLMConfig configures timeouts, retries and the circuit breaker
+type ResilientLLMConfig struct {
+	Timeout          time.Duration // per attempt
+	MaxRetries       int           // attempts after the first one
+	BaseBackoff      time.Duration
+	MaxBackoff       time.Duration
+	FailureThreshold int           // consecutive failures before the circuit opens
+	Cooldown         time.Duration // how long the circuit stays open before a trial call
+}
+
+// DefaultResilientLLMConfig returns the default configuration
+func DefaultResilientLLMConfig() ResilientLLMConfig {
+	return ResilientLLMConfig{
+		Timeout:          20 * time.Second,
+		MaxRetries:       2,
+		BaseBackoff:      250 * time.Millisecond,
+		MaxBackoff:       2 * time.Second,
+		FailureThreshold: 5,
+		Cooldown:         30 * time.Second,
+	}
+}
+
+// ResilientLLMConfigFromEnv returns the default configuration overridden by
+// OPENAI_TIMEOUT_MS, OPENAI_MAX_RETRIES, OPENAI_BREAKER_THRESHOLD and
+// OPENAI_BREAKER_COOLDOWN_MS
+func ResilientLLMConfigFromEnv() ResilientLLMConfig {
+	config := DefaultResilientLLMConfig()
+	if ms := envInt("OPENAI_TIMEOUT_MS", 0); ms > 0 {
+		config.Timeout = time.Duration(ms) * time.Millisecond
+	}
+	if retries := envInt("OPENAI_MAX_RETRIES", -1); retries >= 0 {
+		config.MaxRetries = retries
+	}
+	if threshold := envInt("OPENAI_BREAKER_THRESHOLD", 0); threshold > 0 {
+		config.FailureThreshold = threshold
+	}
+	if ms := envInt("OPENAI_BREAKER_COOLDOWN_MS", 0); ms > 0 {
+		config.Cooldown = time.Duration(ms) * time.Millisecond
+	}
+	return config
+}
+
+func envInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+// ResilientLLM wraps an LLMProvider with per-attempt timeouts, retries with
+// jittered exponential backoff and a circuit breaker
+type ResilientLLM struct {
+	provider LLMProvider
+	config   ResilientLLMConfig
+
+	mu       sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+	trial    bool // a half-open trial call is in flight
+}
+
+// NewResilientLLM creates a new ResilientLLM
+func NewResilientLLM(provider LLMProvider, config ResilientLLMConfig) *ResilientLLM {
+	if config.FailureThreshold <= 0 {
+		config.FailureThreshold = 1
+	}
+	return &ResilientLLM{
+		provider: provider,
+		config:   config,
+		state:    CircuitClosed,
+	}
+}
+
+// State returns the current circuit breaker state
+func (r *ResilientLLM) State() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.state == CircuitOpen && time.Since(r.openedAt) >= r.config.Cooldown {
+		return CircuitHalfOpen
+	}
+	return r.state
+}
+
+// Complete calls the wrapped provider's Complete
+func (r *ResilientLLM) Complete(ctx context.Context, req LLMRequest) (*LLMResponse, error) {
+	return r.call(ctx, func(ctx context.Context) (*LLMResponse, bool, error) {
+		response, err := r.provider.Complete(ctx, req)
+		return response, true, err
+	})
+}
+
+// Stream calls the wrapped provider's Stream. A failed attempt is only retried
+// if no content has been delivered to onDelta yet.
+func (r *ResilientLLM) Stream(ctx context.Context, req LLMRequest, onDelta func(delta string) error) (*LLMResponse, error) {
+	return r.call(ctx, func(ctx context.Context) (*LLMResponse, bool, error) {
+		delivered := false
+		response, err := r.provider.Stream(ctx, req, func(delta string) error {
+			delivered = true
+			if err := onDelta(delta); err != nil {
+				return consumerError{err}
+			}
+			return nil
+		})
+		return response, !delivered, err
+	})
+}
+
+// call runs attempt until it succeeds, fails permanently or retries are exhausted
+func (r *ResilientLLM) call(ctx context.Context, attempt func(ctx context.Context) (*LLMResponse, bool, error)) (*LLMResponse, error) {
+	if err := r.allow(); err != nil {
+		return nil, err
+	}
+
+	var lastErr error
+	for i := 0; i <= r.config.MaxRetries; i++ {
+		if i > 0 {
+			if err := sleepContext(ctx, r.backoff(i)); err != nil {
+				r.release()
+				return nil, err
+			}
+		}
+
+		attemptCtx, cancel := ctx, context.CancelFunc(func() {})
+		if r.config.Timeout > 0 {
+			attemptCtx, cancel = context.WithTimeout(ctx, r.config.Timeout)
+		}
+		response, retryable, err := attempt(attemptCtx)
+		cancel()
+
+		if err == nil {
+			r.recordSuccess()
+			return response, nil
+		}
+		lastErr = err
+
+		// The caller gave up; this says nothing about the provider's health
+		if ctx.Err() != nil {
+			r.release()
+			return nil, ctx.Err()
+		}
+		// The caller's onDelta failed; this says nothing about the provider either
+		var consumerErr consumerError
+		if errors.As(err, &consumerErr) {
+			r.release()
+			return nil, consumerErr.err
+		}
+		if !isRetryableLLMError(err) {
+			r.release()
+			return nil, err
+		}
+		if !retryable || i == r.config.MaxRetries {
+			break
+		}
+		log.Printf("Warning: LLM attempt %d failed, retrying: %v", i+1, err)
+	}
+
+	r.recordFailure()
+	return nil, fmt.Errorf("%w: %v", ErrLLMUnavailable, lastErr)
+}
+
+// consumerError marks an error returned by a Stream caller's onDelta
+type consumerError struct {
+	err error
+}
+
+func (e consumerError) Error() string {
+	return e.err.Error()
+}
+
+// allow reports whether a call may go through the circuit
+func (r *ResilientLLM) allow() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch r.state {
+	case CircuitOpen:
+		if time.Since(r.openedAt) < r.config.Cooldown {
+			return fmt.Errorf("%w: circuit breaker open", ErrLLMUnavailable)
+		}
+		r.state = CircuitHalfOpen
+		r.trial = true
+		return nil
+	case CircuitHalfOpen:
+		if r.trial {
+			return fmt.Errorf("%w: circuit breaker half-open", ErrLLMUnavailable)
+		}
+		r.trial = true
+	}
+	return nil
+}
+
+func (r *ResilientLLM) recordSuccess() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.state != CircuitClosed {
+		log.Printf("LLM circuit breaker closed")
+	}
+	r.state = CircuitClosed
+	r.failures = 0
+	r.trial = false
+}
+
+func (r *ResilientLLM) recordFailure() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failures++
+	r.trial = false
+	if r.state == CircuitHalfOpen || r.failures >= r.config.FailureThreshold {
+		if r.state != CircuitOpen {
+			log.Printf("LLM circuit breaker opened after %d consecutive failures", r.failures)
+		}
+		r.state = CircuitOpen
+		r.openedAt = time.Now()
+	}
+}
+
+// release ends a half-open trial without a verdict on provider health
+func (r *ResilientLLM) release() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.trial = false
+}
+
+// backoff returns a full-jitter exponential delay for the given retry
+func (r *ResilientLLM) backoff(retry int) time.Duration {
+	if r.config.BaseBackoff <= 0 {
+		return 0
+	}
+	delay := r.config.BaseBackoff << (retry - 1)
+	if r.config.MaxBackoff > 0 && (delay > r.config.MaxBackoff || delay <= 0) {
+		delay = r.config.MaxBackoff
+	}
+	return time.Duration(rand.Int63n(int64(delay)) + 1)
+}
+
+// isRetryableLLMError reports whether err may succeed on retry. Client errors
+// such as invalid requests or bad credentials are not retried.
+func isRetryableLLMError(err error) bool {
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) {
+		return isRetryableStatus(apiErr.HTTPStatusCode)
+	}
+	var reqErr *openai.RequestError
+	if errors.As(err, &reqErr) {
+		return isRetryableStatus(reqErr.HTTPStatusCode)
+	}
+	return true
+}
+
+func isRetryableStatus(status int) bool {
+	return status == 0 ||
+		status == http.StatusRequestTimeout ||
+		status == http.StatusTooManyRequests ||
+		status >= http.StatusInternalServerError
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,168 @@
+package services
+
+import (
+	"bytes"
+	"chat-ecommerce-backend/internal/models"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Where a search miss came from
+const (
+	MissSourceSearch = "search"
+	MissSourceChat   = "chat"
+)
+
+// maxMissQueryLength matches the query column of search_misses
+const maxMissQueryLength = 500
+
+// SearchMissFilter selects the misses in a report. To is exclusive.
+type SearchMissFilter struct {
+	From   time.Time
+	To     time.Time
+	Source string
+	Limit  int
+}
+
+// SearchMissSummary is how often a query found nothing in a period
+type SearchMissSummary struct {
+	Query        string    `json:"query"`
+	Misses       int       `json:"misses"`
+	SearchMisses int       `json:"search_misses"`
+	ChatMisses   int       `json:"chat_misses"`
+	Sessions     int       `json:"sessions"` // distinct sessions, misses without a session count as one each
+	FirstSeen    time.Time `json:"first_seen"`
+	LastSeen     time.Time `json:"last_seen"`
+}
+
+var searchMissCSVHeader = []string{"query", "misses", "search_misses", "chat_misses", "sessions", "first_seen", "last_seen"}
+
+// SearchMissService records searches and chat queries that found no products
+// and reports the most common ones
+type SearchMissService struct {
+	db *gorm.DB
+}
+
+// NewSearchMissService creates a new SearchMissService
+func NewSearchMissService(db *gorm.DB) *SearchMissService {
+	return &SearchMissService{db: db}
+}
+
+// RecordMiss saves a query that found nothing. Failures are logged, never
+// returned, so a miss can't break the search that produced it.
+func (s *SearchMissService) RecordMiss(ctx context.Context, query, source, sessionID string, userID *uuid.UUID) {
+	query = strings.Join(strings.Fields(strings.ToLower(query)), " ")
+	if query == "" {
+		return
+	}
+	if runes := []rune(query); len(runes) > maxMissQueryLength {
+		query = string(runes[:maxMissQueryLength])
+	}
+
+	miss := models.SearchMiss{
+		ID:        uuid.New(),
+		Query:     query,
+		Source:    source,
+		SessionID: sessionID,
+		UserID:    userID,
+		CreatedAt: time.Now(),
+	}
+	if err := s.db.WithContext(ctx).Create(&miss).Error; err != nil {
+		log.Printf("Warning: failed to record search miss: %v", err)
+	}
+}
+
+// MissReport returns the queries that found nothing in the period, most
+// frequent first
+func (s *SearchMissService) MissReport(ctx context.Context, filter SearchMissFilter) ([]SearchMissSummary, error) {
+	query := s.db.WithContext(ctx).Where("created_at >= ? AND created_at < ?", filter.From, filter.To)
+	if filter.Source != "" {
+		query = query.Where("source = ?", filter.Source)
+	}
+
+	var misses []models.SearchMiss
+	if err := query.Order("created_at ASC").Find(&misses).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch search misses: %v", err)
+	}
+
+	byQuery := make(map[string]*SearchMissSummary)
+	sessions := make(map[string]map[string]bool)
+	for _, miss := range misses {
+		summary, ok := byQuery[miss.Query]
+		if !ok {
+			summary = &SearchMissSummary{Query: miss.Query, FirstSeen: miss.CreatedAt}
+			byQuery[miss.Query] = summary
+			sessions[miss.Query] = make(map[string]bool)
+		}
+
+		summary.Misses++
+		switch miss.Source {
+		case MissSourceSearch:
+			summary.SearchMisses++
+		case MissSourceChat:
+			summary.ChatMisses++
+		}
+		summary.LastSeen = miss.CreatedAt
+
+		sessionKey := miss.SessionID
+		if sessionKey == "" {
+			sessionKey = miss.ID.String()
+		}
+		sessions[miss.Query][sessionKey] = true
+	}
+
+	summaries := make([]SearchMissSummary, 0, len(byQuery))
+	for _, summary := range byQuery {
+		summary.Sessions = len(sessions[summary.Query])
+		summaries = append(summaries, *summary)
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		a, b := summaries[i], summaries[j]
+		if a.Misses != b.Misses {
+			return a.Misses > b.Misses
+		}
+		return a.Query < b.Query
+	})
+	if filter.Limit > 0 && len(summaries) > filter.Limit {
+		summaries = summaries[:filter.Limit]
+	}
+	return summaries, nil
+}
+
+// ExportMissReportCSV exports the miss report to CSV
+func (s *SearchMissService) ExportMissReportCSV(ctx context.Context, filter SearchMissFilter) ([]byte, error) {
+	summaries, err := s.MissReport(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write(searchMissCSVHeader)
+	for _, summary := range summaries {
+		w.Write([]string{
+			summary.Query,
+			strconv.Itoa(summary.Misses),
+			strconv.Itoa(summary.SearchMisses),
+			strconv.Itoa(summary.ChatMisses),
+			strconv.Itoa(summary.Sessions),
+			summary.FirstSeen.UTC().Format(time.RFC3339),
+			summary.LastSeen.UTC().Format(time.RFC3339),
+		})
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, fmt.Errorf("failed to write search misses CSV: %v", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,454 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// What a search boost applies to
+const (
+	BoostKindCategory = "category"
+	BoostKindBrand    = "brand"
+)
+
+// Search ranking errors
+var (
+	ErrBoostNotFound = errors.New("search boost not found")
+	ErrPinNotFound   = errors.New("pinned search result not found")
+)
+
+// SearchBoostRequest is the payload for setting a category or brand boost
+type SearchBoostRequest struct {
+	Kind   string  `json:"kind" binding:"required,oneof=category brand"`
+	Target string  `json:"target" binding:"required"`
+	Factor float64 `json:"factor" binding:"required,gt=0"`
+}
+
+// PinRequest is the payload for pinning a product to a search query
+type PinRequest struct {
+	Query     string    `json:"query" binding:"required"`
+	ProductID uuid.UUID `json:"product_id" binding:"required"`
+	Position  int       `json:"position" binding:"required,min=1"`
+}
+
+// SearchRankingConfig controls how low-stock products are demoted
+type SearchRankingConfig struct {
+	LowStockThreshold int     // products with this many units or fewer are demoted
+	LowStockFactor    float64 // multiplies a low-stock product's score
+}
+
+// SearchRankingConfigFromEnv reads SEARCH_LOW_STOCK_THRESHOLD (default 5) and
+// SEARCH_LOW_STOCK_FACTOR_PERCENT (default 50, 100 disables demotion)
+func SearchRankingConfigFromEnv() SearchRankingConfig {
+	return SearchRankingConfig{
+		LowStockThreshold: envInt("SEARCH_LOW_STOCK_THRESHOLD", 5),
+		LowStockFactor:    float64(envInt("SEARCH_LOW_STOCK_FACTOR_PERCENT", 50)) / 100,
+	}
+}
+
+// SearchRankingService applies the admin's ranking controls to search results
+// and chat suggestions: category and brand boosts, products pinned to queries
+// and the demotion of products that are running out
+type SearchRankingService struct {
+	db     *gorm.DB
+	config SearchRankingConfig
+}
+
+// NewSearchRankingService creates a new SearchRankingService
+func NewSearchRankingService(db *gorm.DB) *SearchRankingService {
+	return &SearchRankingService{
+		db:     db,
+		config: SearchRankingConfigFromEnv(),
+	}
+}
+
+// WithConfig overrides the low-stock demotion settings
+func (s *SearchRankingService) WithConfig(config SearchRankingConfig) *SearchRankingService {
+	s.config = config
+	return s
+}
+
+// Factors returns how much each product's score is scaled by: its category's
+// boost (or the nearest boosted parent category's), its brand's boost and the
+// low-stock demotion. Products without boosts or stock concerns get 1.
+func (s *SearchRankingService) Factors(ctx context.Context, products []models.Product) (map[uuid.UUID]float64, error) {
+	factors := make(map[uuid.UUID]float64, len(products))
+	if len(products) == 0 {
+		return factors, nil
+	}
+	db := s.db.WithContext(ctx)
+
+	var boosts []models.SearchBoost
+	if err := db.Find(&boosts).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch search boosts: %v", err)
+	}
+	categoryBoosts := make(map[uuid.UUID]float64)
+	brandBoosts := make(map[string]float64)
+	for _, boost := range boosts {
+		switch boost.Kind {
+		case BoostKindCategory:
+			if id, err := uuid.Parse(boost.Target); err == nil {
+				categoryBoosts[id] = boost.Factor
+			}
+		case BoostKindBrand:
+			brandBoosts[boost.Target] = boost.Factor
+		}
+	}
+
+	parents := make(map[uuid.UUID]uuid.UUID)
+	if len(categoryBoosts) > 0 {
+		var categories []models.Category
+		if err := db.Select("id", "parent_id").Find(&categories).Error; err != nil {
+			return nil, fmt.Errorf("failed to fetch categories: %v", err)
+		}
+		for _, category := range categories {
+			if category.ParentID != nil {
+				parents[category.ID] = *category.ParentID
+			}
+		}
+	}
+
+	stock, err := s.stockLevels(ctx, products)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, product := range products {
+		factor := 1.0
+
+		// The most specific boosted category wins; parents is bounded so a
+		// corrupt cycle can't loop forever
+		categoryID := product.CategoryID
+		for depth := 0; depth <= len(parents); depth++ {
+			if boost, ok := categoryBoosts[categoryID]; ok {
+				factor *= boost
+				break
+			}
+			parent, ok := parents[categoryID]
+			if !ok {
+				break
+			}
+			categoryID = parent
+		}
+
+		brand := strings.ToLower(strings.TrimSpace(productBrand(product)))
+		if boost, ok := brandBoosts[brand]; ok && brand != "" {
+			factor *= boost
+		}
+
+		if available, tracked := stock[product.ID]; tracked && available <= s.config.LowStockThreshold {
+			factor *= s.config.LowStockFactor
+		}
+
+		factors[product.ID] = factor
+	}
+	return factors, nil
+}
+
+// stockLevels returns the units available to sell per product. Products
+// without inventory records aren't tracked and are left out.
+func (s *SearchRankingService) stockLevels(ctx context.Context, products []models.Product) (map[uuid.UUID]int, error) {
+	ids := make([]uuid.UUID, len(products))
+	for i, product := range products {
+		ids[i] = product.ID
+	}
+
+	var rows []struct {
+		ProductID uuid.UUID
+		Available int
+	}
+	if err := s.db.WithContext(ctx).Model(&models.Inventory{}).
+		Select("product_id, SUM(quantity_available - quantity_reserved) AS available").
+		Where("product_id IN ?", ids).
+		Group("product_id").
+		Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch stock levels: %v", err)
+	}
+
+	stock := make(map[uuid.UUID]int, len(rows))
+	for _, row := range rows {
+		stock[row.ProductID] = row.Available
+	}
+	return stock, nil
+}
+
+// RankProducts orders search results by relevance scaled by their ranking
+// factors, puts the products pinned to any of the queries at their positions
+// and returns at most limit products
+func (s *SearchRankingService) RankProducts(ctx context.Context, queries []string, products []models.Product, relevance func(models.Product) float64, limit int) ([]models.Product, error) {
+	factors, err := s.Factors(ctx, products)
+	if err != nil {
+		return nil, err
+	}
+
+	ranked := make([]models.Product, len(products))
+	copy(ranked, products)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return relevance(ranked[i])*factors[ranked[i].ID] > relevance(ranked[j])*factors[ranked[j].ID]
+	})
+
+	pins, err := s.pinsFor(ctx, queries)
+	if err != nil {
+		return nil, err
+	}
+	if len(pins) > 0 {
+		pinned, err := s.pinnedProducts(ctx, pins, ranked)
+		if err != nil {
+			return nil, err
+		}
+		ranked = applyPins(ranked, pins, pinned)
+	}
+
+	if limit > 0 && len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+	return ranked, nil
+}
+
+// PinnedForText returns the products pinned to queries that appear as whole
+// words in a chat message, in pin position order
+func (s *SearchRankingService) PinnedForText(ctx context.Context, text string) ([]models.Product, error) {
+	padded := " " + strings.Join(strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ") + " "
+
+	var pins []models.PinnedSearchResult
+	if err := s.db.WithContext(ctx).Order("position ASC").Find(&pins).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch pinned search results: %v", err)
+	}
+
+	var matched []models.PinnedSearchResult
+	for _, pin := range pins {
+		if strings.Contains(padded, " "+pin.Query+" ") {
+			matched = append(matched, pin)
+		}
+	}
+	if len(matched) == 0 {
+		return nil, nil
+	}
+
+	pinned, err := s.pinnedProducts(ctx, matched, nil)
+	if err != nil {
+		return nil, err
+	}
+	return applyPins(nil, matched, pinned), nil
+}
+
+// pinsFor returns the pins of the given queries, lowest position first
+func (s *SearchRankingService) pinsFor(ctx context.Context, queries []string) ([]models.PinnedSearchResult, error) {
+	normalized := make([]string, 0, len(queries))
+	for _, query := range queries {
+		if query = normalizePinQuery(query); query != "" {
+			normalized = append(normalized, query)
+		}
+	}
+	if len(normalized) == 0 {
+		return nil, nil
+	}
+
+	var pins []models.PinnedSearchResult
+	if err := s.db.WithContext(ctx).Where("query IN ?", normalized).Order("position ASC").Find(&pins).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch pinned search results: %v", err)
+	}
+	return pins, nil
+}
+
+// pinnedProducts returns the published pinned products, reusing the ones
+// already in the results
+func (s *SearchRankingService) pinnedProducts(ctx context.Context, pins []models.PinnedSearchResult, results []models.Product) (map[uuid.UUID]models.Product, error) {
+	products := make(map[uuid.UUID]models.Product)
+	for _, product := range results {
+		products[product.ID] = product
+	}
+
+	var missing []uuid.UUID
+	for _, pin := range pins {
+		if _, ok := products[pin.ProductID]; !ok {
+			missing = append(missing, pin.ProductID)
+		}
+	}
+	if len(missing) == 0 {
+		return products, nil
+	}
+
+	var loaded []models.Product
+	if err := s.db.WithContext(ctx).Where("id IN ?", missing).
+		Scopes(publishedAt(time.Now())).
+		Preload("Category").
+		Preload("Brand").
+		Preload("Variants").
+		Preload("Images").
+		Preload("Inventory").
+		Find(&loaded).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch pinned products: %v", err)
+	}
+	for _, product := range loaded {
+		products[product.ID] = product
+	}
+	return products, nil
+}
+
+// applyPins moves pinned products to their positions, in position order so
+// later pins don't shift earlier ones. Pins of unpublished products are skipped.
+func applyPins(ranked []models.Product, pins []models.PinnedSearchResult, pinned map[uuid.UUID]models.Product) []models.Product {
+	for _, pin := range pins {
+		product, ok := pinned[pin.ProductID]
+		if !ok {
+			continue
+		}
+
+		rest := make([]models.Product, 0, len(ranked)+1)
+		for _, p := range ranked {
+			if p.ID != pin.ProductID {
+				rest = append(rest, p)
+			}
+		}
+
+		position := pin.Position - 1
+		if position > len(rest) {
+			position = len(rest)
+		}
+		ranked = append(rest[:position], append([]models.Product{product}, rest[position:]...)...)
+	}
+	return ranked
+}
+
+func normalizePinQuery(query string) string {
+	return strings.Join(strings.Fields(strings.ToLower(query)), " ")
+}
+
+// ListBoosts returns the category and brand boosts
+func (s *SearchRankingService) ListBoosts(ctx context.Context) ([]models.SearchBoost, error) {
+	var boosts []models.SearchBoost
+	if err := s.db.WithContext(ctx).Order("kind ASC, target ASC").Find(&boosts).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch search boosts: %v", err)
+	}
+	return boosts, nil
+}
+
+// SetBoost creates or replaces the boost of a category or brand
+func (s *SearchRankingService) SetBoost(ctx context.Context, req SearchBoostRequest) (*models.SearchBoost, error) {
+	db := s.db.WithContext(ctx)
+
+	target := strings.ToLower(strings.TrimSpace(req.Target))
+	switch req.Kind {
+	case BoostKindCategory:
+		categoryID, err := uuid.Parse(target)
+		if err != nil {
+			return nil, errors.New("category boosts target a category ID")
+		}
+		var count int64
+		if err := db.Model(&models.Category{}).Where("id = ?", categoryID).Count(&count).Error; err != nil {
+			return nil, fmt.Errorf("failed to check category: %v", err)
+		}
+		if count == 0 {
+			return nil, ErrCategoryNotFound
+		}
+		target = categoryID.String()
+	case BoostKindBrand:
+		if target == "" {
+			return nil, errors.New("brand is required")
+		}
+	default:
+		return nil, fmt.Errorf("unknown boost kind %q", req.Kind)
+	}
+
+	var boost models.SearchBoost
+	err := db.Where("kind = ? AND target = ?", req.Kind, target).First(&boost).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		boost = models.SearchBoost{ID: uuid.New(), Kind: req.Kind, Target: target, Factor: req.Factor}
+		if err := db.Create(&boost).Error; err != nil {
+			return nil, fmt.Errorf("failed to create search boost: %v", err)
+		}
+	case err != nil:
+		return nil, fmt.Errorf("failed to fetch search boost: %v", err)
+	default:
+		boost.Factor = req.Factor
+		if err := db.Save(&boost).Error; err != nil {
+			return nil, fmt.Errorf("failed to update search boost: %v", err)
+		}
+	}
+	return &boost, nil
+}
+
+// DeleteBoost removes a boost
+func (s *SearchRankingService) DeleteBoost(ctx context.Context, id uuid.UUID) error {
+	result := s.db.WithContext(ctx).Where("id = ?", id).Delete(&models.SearchBoost{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete search boost: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrBoostNotFound
+	}
+	return nil
+}
+
+// ListPins returns the pinned results, of one query when it isn't empty
+func (s *SearchRankingService) ListPins(ctx context.Context, query string) ([]models.PinnedSearchResult, error) {
+	db := s.db.WithContext(ctx).Preload("Product")
+	if query = normalizePinQuery(query); query != "" {
+		db = db.Where("query = ?", query)
+	}
+
+	var pins []models.PinnedSearchResult
+	if err := db.Order("query ASC, position ASC").Find(&pins).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch pinned search results: %v", err)
+	}
+	return pins, nil
+}
+
+// PinProduct pins a product to a query, moving it when it is already pinned there
+func (s *SearchRankingService) PinProduct(ctx context.Context, req PinRequest) (*models.PinnedSearchResult, error) {
+	db := s.db.WithContext(ctx)
+
+	query := normalizePinQuery(req.Query)
+	if query == "" {
+		return nil, errors.New("query is required")
+	}
+	var count int64
+	if err := db.Model(&models.Product{}).Where("id = ?", req.ProductID).Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("failed to check product: %v", err)
+	}
+	if count == 0 {
+		return nil, ErrProductNotFound
+	}
+
+	var pin models.PinnedSearchResult
+	err := db.Where("query = ? AND product_id = ?", query, req.ProductID).First(&pin).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		pin = models.PinnedSearchResult{ID: uuid.New(), Query: query, ProductID: req.ProductID, Position: req.Position}
+		if err := db.Omit("Product").Create(&pin).Error; err != nil {
+			return nil, fmt.Errorf("failed to pin product: %v", err)
+		}
+	case err != nil:
+		return nil, fmt.Errorf("failed to fetch pinned search result: %v", err)
+	default:
+		if err := db.Model(&pin).Update("position", req.Position).Error; err != nil {
+			return nil, fmt.Errorf("failed to update pinned search result: %v", err)
+		}
+	}
+	return &pin, nil
+}
+
+// UnpinProduct removes a pinned result
+func (s *SearchRankingService) UnpinProduct(ctx context.Context, id uuid.UUID) error {
+	result := s.db.WithContext(ctx).Where("id = ?", id).Delete(&models.PinnedSearchResult{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete pinned search result: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrPinNotFound
+	}
+	return nil
+}
@@ -0,0 +1,377 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// SegmentRules selects customers by their paid, non-cancelled orders. Zero
+// values are ignored, so empty rules match every customer with an order.
+type SegmentRules struct {
+	MinOrders            int         `json:"min_orders,omitempty"`
+	MinTotalSpent        float64     `json:"min_total_spent,omitempty"`
+	MinAverageOrderValue float64     `json:"min_average_order_value,omitempty"`
+	MaxAverageOrderValue float64     `json:"max_average_order_value,omitempty"`
+	CategoryIDs          []uuid.UUID `json:"category_ids,omitempty"` // bought from at least one of these categories
+	WithinDays           int         `json:"within_days,omitempty"`  // only count orders this recent; 0 counts all orders
+}
+
+// SegmentRequest represents the request to create or update a segment
+type SegmentRequest struct {
+	Name             string       `json:"name" binding:"required"`
+	Description      string       `json:"description"`
+	Rules            SegmentRules `json:"rules"`
+	Greeting         string       `json:"greeting"`
+	PromotionMessage string       `json:"promotion_message"`
+	Priority         int          `json:"priority"`
+	IsActive         *bool        `json:"is_active"`
+}
+
+// SegmentEvaluationResult reports the membership computed for each segment
+type SegmentEvaluationResult struct {
+	EvaluatedAt time.Time      `json:"evaluated_at"`
+	Members     map[string]int `json:"members"` // segment slug to member count
+}
+
+// customerStats aggregates a customer's qualifying orders
+type customerStats struct {
+	Orders     int
+	TotalSpent float64
+	Categories map[uuid.UUID]bool
+}
+
+// matches reports whether a customer's order history satisfies the rules
+func (r SegmentRules) matches(stats customerStats) bool {
+	if stats.Orders == 0 {
+		return false
+	}
+	aov := stats.TotalSpent / float64(stats.Orders)
+
+	if r.MinOrders > 0 && stats.Orders < r.MinOrders {
+		return false
+	}
+	if r.MinTotalSpent > 0 && stats.TotalSpent < r.MinTotalSpent {
+		return false
+	}
+	if r.MinAverageOrderValue > 0 && aov < r.MinAverageOrderValue {
+		return false
+	}
+	if r.MaxAverageOrderValue > 0 && aov > r.MaxAverageOrderValue {
+		return false
+	}
+	if len(r.CategoryIDs) > 0 {
+		for _, id := range r.CategoryIDs {
+			if stats.Categories[id] {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// SegmentService manages customer segments and evaluates their membership
+type SegmentService struct {
+	db *gorm.DB
+}
+
+// NewSegmentService creates a new SegmentService
+func NewSegmentService(db *gorm.DB) *SegmentService {
+	return &SegmentService{
+		db: db,
+	}
+}
+
+// SegmentEvaluationHourFromEnv returns SEGMENT_EVALUATION_HOUR, the local hour
+// of the nightly evaluation, or 2 for 02:00
+func SegmentEvaluationHourFromEnv() int {
+	hour := envInt("SEGMENT_EVALUATION_HOUR", 2)
+	if hour < 0 || hour > 23 {
+		return 2
+	}
+	return hour
+}
+
+// ListSegments returns all segments, highest priority first
+func (s *SegmentService) ListSegments(ctx context.Context) ([]models.Segment, error) {
+	var segments []models.Segment
+	if err := s.db.WithContext(ctx).Order("priority DESC, slug ASC").Find(&segments).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch segments: %v", err)
+	}
+	return segments, nil
+}
+
+// UpsertSegment creates or updates the segment with the given slug. Membership
+// is refreshed at the next evaluation.
+func (s *SegmentService) UpsertSegment(ctx context.Context, slug string, req SegmentRequest) (*models.Segment, error) {
+	if slug == "" {
+		return nil, errors.New("slug is required")
+	}
+
+	rules, err := json.Marshal(req.Rules)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal segment rules: %v", err)
+	}
+
+	db := s.db.WithContext(ctx)
+	var segment models.Segment
+	err = db.Where("slug = ?", slug).First(&segment).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to fetch segment: %v", err)
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		segment = models.Segment{ID: uuid.New(), Slug: slug, IsActive: true}
+	}
+
+	segment.Name = req.Name
+	segment.Description = req.Description
+	segment.Rules = datatypes.JSON(rules)
+	segment.Greeting = req.Greeting
+	segment.PromotionMessage = req.PromotionMessage
+	segment.Priority = req.Priority
+	if req.IsActive != nil {
+		segment.IsActive = *req.IsActive
+	}
+
+	active := segment.IsActive
+	if err := db.Save(&segment).Error; err != nil {
+		return nil, fmt.Errorf("failed to save segment: %v", err)
+	}
+	// Creating an inactive segment fills is_active with the column default
+	if segment.IsActive != active {
+		if err := db.Model(&segment).Update("is_active", active).Error; err != nil {
+			return nil, fmt.Errorf("failed to save segment: %v", err)
+		}
+	}
+	return &segment, nil
+}
+
+// DeleteSegment deletes a segment and its memberships
+func (s *SegmentService) DeleteSegment(ctx context.Context, slug string) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var segment models.Segment
+		if err := tx.Where("slug = ?", slug).First(&segment).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("segment not found")
+			}
+			return fmt.Errorf("failed to fetch segment: %v", err)
+		}
+		if err := tx.Where("segment_id = ?", segment.ID).Delete(&models.SegmentMembership{}).Error; err != nil {
+			return fmt.Errorf("failed to delete segment memberships: %v", err)
+		}
+		if err := tx.Delete(&segment).Error; err != nil {
+			return fmt.Errorf("failed to delete segment: %v", err)
+		}
+		return nil
+	})
+}
+
+// GetSegmentMembers returns the IDs of the users in a segment
+func (s *SegmentService) GetSegmentMembers(ctx context.Context, slug string) ([]uuid.UUID, error) {
+	var segment models.Segment
+	if err := s.db.WithContext(ctx).Where("slug = ?", slug).First(&segment).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("segment not found")
+		}
+		return nil, fmt.Errorf("failed to fetch segment: %v", err)
+	}
+
+	userIDs := []uuid.UUID{}
+	if err := s.db.WithContext(ctx).Model(&models.SegmentMembership{}).
+		Where("segment_id = ?", segment.ID).
+		Pluck("user_id", &userIDs).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch segment members: %v", err)
+	}
+	return userIDs, nil
+}
+
+// GetUserSegments returns the active segments a user belongs to, highest priority first
+func (s *SegmentService) GetUserSegments(ctx context.Context, userID uuid.UUID) ([]models.Segment, error) {
+	var segments []models.Segment
+	err := s.db.WithContext(ctx).
+		Joins("JOIN segment_memberships ON segment_memberships.segment_id = segments.id").
+		Where("segment_memberships.user_id = ? AND segments.is_active = ?", userID, true).
+		Order("segments.priority DESC, segments.slug ASC").
+		Find(&segments).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch user segments: %v", err)
+	}
+	return segments, nil
+}
+
+// EvaluateSegments recomputes the membership of every segment from order history
+func (s *SegmentService) EvaluateSegments(ctx context.Context, now time.Time) (*SegmentEvaluationResult, error) {
+	db := s.db.WithContext(ctx)
+
+	var segments []models.Segment
+	if err := db.Find(&segments).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch segments: %v", err)
+	}
+
+	result := &SegmentEvaluationResult{
+		EvaluatedAt: now,
+		Members:     make(map[string]int, len(segments)),
+	}
+
+	// Segments sharing an order window share one aggregation
+	statsByWindow := make(map[int]map[uuid.UUID]*customerStats)
+	for _, segment := range segments {
+		var members []uuid.UUID
+		if segment.IsActive {
+			var rules SegmentRules
+			if len(segment.Rules) > 0 {
+				if err := json.Unmarshal(segment.Rules, &rules); err != nil {
+					return nil, fmt.Errorf("invalid rules for segment %s: %v", segment.Slug, err)
+				}
+			}
+
+			stats, ok := statsByWindow[rules.WithinDays]
+			if !ok {
+				var err error
+				stats, err = s.customerStats(db, rules.WithinDays, now)
+				if err != nil {
+					return nil, err
+				}
+				statsByWindow[rules.WithinDays] = stats
+			}
+
+			for userID, customer := range stats {
+				if rules.matches(*customer) {
+					members = append(members, userID)
+				}
+			}
+		}
+
+		if err := s.replaceMembers(db, &segment, members, now); err != nil {
+			return nil, err
+		}
+		result.Members[segment.Slug] = len(members)
+	}
+
+	return result, nil
+}
+
+// customerStats aggregates qualifying orders per customer, optionally limited to the last withinDays
+func (s *SegmentService) customerStats(db *gorm.DB, withinDays int, now time.Time) (map[uuid.UUID]*customerStats, error) {
+	qualifying := func(query *gorm.DB) *gorm.DB {
+		query = query.Where("orders.payment_status = ? AND orders.status <> ?", "paid", "cancelled")
+		if withinDays > 0 {
+			query = query.Where("orders.created_at >= ?", now.AddDate(0, 0, -withinDays))
+		}
+		return query
+	}
+
+	var totals []struct {
+		UserID     uuid.UUID
+		Orders     int
+		TotalSpent float64
+	}
+	if err := db.Model(&models.Order{}).
+		Select("orders.user_id, COUNT(*) AS orders, COALESCE(SUM(orders.total_amount), 0) AS total_spent").
+		Scopes(qualifying).
+		Group("orders.user_id").
+		Scan(&totals).Error; err != nil {
+		return nil, fmt.Errorf("failed to aggregate orders: %v", err)
+	}
+
+	stats := make(map[uuid.UUID]*customerStats, len(totals))
+	for _, total := range totals {
+		stats[total.UserID] = &customerStats{
+			Orders:     total.Orders,
+			TotalSpent: total.TotalSpent,
+			Categories: make(map[uuid.UUID]bool),
+		}
+	}
+
+	var purchases []struct {
+		UserID     uuid.UUID
+		CategoryID uuid.UUID
+	}
+	if err := db.Table("order_items").
+		Select("DISTINCT orders.user_id, products.category_id").
+		Joins("JOIN orders ON orders.id = order_items.order_id").
+		Joins("JOIN products ON products.id = order_items.product_id").
+		Scopes(qualifying).
+		Scan(&purchases).Error; err != nil {
+		return nil, fmt.Errorf("failed to aggregate purchased categories: %v", err)
+	}
+	for _, purchase := range purchases {
+		if customer, ok := stats[purchase.UserID]; ok {
+			customer.Categories[purchase.CategoryID] = true
+		}
+	}
+
+	return stats, nil
+}
+
+// replaceMembers swaps a segment's memberships for the newly evaluated set
+func (s *SegmentService) replaceMembers(db *gorm.DB, segment *models.Segment, userIDs []uuid.UUID, now time.Time) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("segment_id = ?", segment.ID).Delete(&models.SegmentMembership{}).Error; err != nil {
+			return fmt.Errorf("failed to clear segment memberships: %v", err)
+		}
+
+		memberships := make([]models.SegmentMembership, 0, len(userIDs))
+		for _, userID := range userIDs {
+			memberships = append(memberships, models.SegmentMembership{
+				ID:          uuid.New(),
+				SegmentID:   segment.ID,
+				UserID:      userID,
+				EvaluatedAt: now,
+			})
+		}
+		if len(memberships) > 0 {
+			if err := tx.CreateInBatches(memberships, 500).Error; err != nil {
+				return fmt.Errorf("failed to save segment memberships: %v", err)
+			}
+		}
+
+		updates := map[string]interface{}{
+			"member_count":      len(memberships),
+			"last_evaluated_at": now,
+		}
+		if err := tx.Model(segment).Updates(updates).Error; err != nil {
+			return fmt.Errorf("failed to update segment: %v", err)
+		}
+		return nil
+	})
+}
+
+// ScheduleNightlyEvaluation evaluates segments every day at the given local hour until ctx is done
+func (s *SegmentService) ScheduleNightlyEvaluation(ctx context.Context, hour int) {
+	go func() {
+		for {
+			timer := time.NewTimer(time.Until(nextDailyRun(time.Now(), hour)))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case now := <-timer.C:
+				result, err := s.EvaluateSegments(ctx, now)
+				if err != nil {
+					log.Printf("Failed to evaluate customer segments: %v", err)
+					continue
+				}
+				log.Printf("Evaluated customer segments: %v", result.Members)
+			}
+		}
+	}()
+}
+
+// nextDailyRun returns the next time after now at the given hour
+func nextDailyRun(now time.Time, hour int) time.Time {
+	next := time.Date(now.Year(), now.Month(), now.Day(), hour, 0, 0, 0, now.Location())
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
@@ -0,0 +1,287 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Where a StoreLocale's country came from
+const (
+	LocaleSourceDefault    = "default"
+	LocaleSourceGeoIP      = "geoip"
+	LocaleSourcePreference = "preference"
+)
+
+// Tax display modes
+const (
+	TaxDisplayInclusive = "inclusive"
+	TaxDisplayExclusive = "exclusive"
+)
+
+// StoreLocale is what a customer's country means for the store: the currency
+// they expect, whether they expect prices with tax and whether we ship there
+type StoreLocale struct {
+	Country        string `json:"country"`
+	Currency       string `json:"currency"`
+	TaxInclusive   bool   `json:"tax_inclusive"`
+	ShipsToCountry bool   `json:"ships_to_country"`
+	StoreCurrency  string `json:"store_currency"` // currency the catalog is priced and charged in
+	Source         string `json:"source"`
+}
+
+// regionDefaults are a country's currency and whether its shoppers expect
+// prices including tax
+type regionDefaults struct {
+	Currency     string
+	TaxInclusive bool
+}
+
+// countryRegions lists the countries the store knows. Anything else falls
+// back to the store currency with prices before tax.
+var countryRegions = map[string]regionDefaults{
+	"US": {"USD", false}, "CA": {"CAD", false},
+	"MX": {"MXN", true}, "BR": {"BRL", true},
+	"GB": {"GBP", true}, "IE": {"EUR", true}, "DE": {"EUR", true}, "FR": {"EUR", true},
+	"ES": {"EUR", true}, "IT": {"EUR", true}, "NL": {"EUR", true}, "BE": {"EUR", true},
+	"AT": {"EUR", true}, "PT": {"EUR", true}, "FI": {"EUR", true}, "CH": {"CHF", true},
+	"SE": {"SEK", true}, "NO": {"NOK", true}, "DK": {"DKK", true}, "PL": {"PLN", true},
+	"AU": {"AUD", true}, "NZ": {"NZD", true}, "JP": {"JPY", true}, "IN": {"INR", true},
+}
+
+// geoIPRange maps a network to the country its addresses are in
+type geoIPRange struct {
+	network *net.IPNet
+	country string
+}
+
+// LocaleConfig configures how customers' countries are detected and what the
+// store does for them
+type LocaleConfig struct {
+	DefaultCountry    string
+	StoreCurrency     string
+	ShippingCountries map[string]bool // empty ships everywhere
+	CountryHeaders    []string        // country headers set by a trusted CDN or proxy
+	Ranges            []geoIPRange
+}
+
+// LocaleConfigFromEnv reads the locale settings: STORE_DEFAULT_COUNTRY ("US")
+// for customers that can't be placed, STORE_CURRENCY ("USD"), SHIPPING_COUNTRIES
+// as a comma separated list of country codes (empty ships everywhere),
+// GEOIP_COUNTRY_HEADERS as the headers a trusted CDN sets to the visitor's
+// country (e.g. "CF-IPCountry,CloudFront-Viewer-Country") and GEOIP_RANGES as
+// cidr=country entries (e.g. "81.2.69.0/24=GB,2001:db8::/32=DE")
+func LocaleConfigFromEnv() LocaleConfig {
+	config := LocaleConfig{
+		DefaultCountry:    strings.ToUpper(os.Getenv("STORE_DEFAULT_COUNTRY")),
+		StoreCurrency:     strings.ToUpper(os.Getenv("STORE_CURRENCY")),
+		ShippingCountries: map[string]bool{},
+	}
+	if config.DefaultCountry == "" {
+		config.DefaultCountry = "US"
+	}
+	if config.StoreCurrency == "" {
+		config.StoreCurrency = "USD"
+	}
+	for _, country := range strings.Split(os.Getenv("SHIPPING_COUNTRIES"), ",") {
+		if country = normalizeCountry(country); country != "" {
+			config.ShippingCountries[country] = true
+		}
+	}
+	for _, header := range strings.Split(os.Getenv("GEOIP_COUNTRY_HEADERS"), ",") {
+		if header = strings.TrimSpace(header); header != "" {
+			config.CountryHeaders = append(config.CountryHeaders, header)
+		}
+	}
+	for _, entry := range strings.Split(os.Getenv("GEOIP_RANGES"), ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		geoRange, err := parseGeoIPRange(entry)
+		if err != nil {
+			log.Printf("Warning: ignoring GEOIP_RANGES entry %q: %v", entry, err)
+			continue
+		}
+		config.Ranges = append(config.Ranges, geoRange)
+	}
+	return config
+}
+
+// parseGeoIPRange parses a "cidr=country" entry
+func parseGeoIPRange(entry string) (geoIPRange, error) {
+	cidr, country, found := strings.Cut(strings.TrimSpace(entry), "=")
+	if !found {
+		return geoIPRange{}, errors.New("expected cidr=country")
+	}
+	_, network, err := net.ParseCIDR(strings.TrimSpace(cidr))
+	if err != nil {
+		return geoIPRange{}, err
+	}
+	code := normalizeCountry(country)
+	if code == "" {
+		return geoIPRange{}, fmt.Errorf("invalid country %q", country)
+	}
+	return geoIPRange{network: network, country: code}, nil
+}
+
+// normalizeCountry upper-cases a two letter country code, returning "" for
+// anything else, including the "XX" and "T1" codes CDNs send for unknown and Tor
+func normalizeCountry(value string) string {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	if len(value) != 2 || value == "XX" || value == "T1" {
+		return ""
+	}
+	for _, r := range value {
+		if r < 'A' || r > 'Z' {
+			return ""
+		}
+	}
+	return value
+}
+
+// StoreLocaleService works out a customer's store locale from where their
+// request comes from, letting signed in customers override it
+type StoreLocaleService struct {
+	db     *gorm.DB
+	config LocaleConfig
+}
+
+// NewStoreLocaleService creates a new StoreLocaleService
+func NewStoreLocaleService(db *gorm.DB, config LocaleConfig) *StoreLocaleService {
+	return &StoreLocaleService{
+		db:     db,
+		config: config,
+	}
+}
+
+// localePreferences are the User.Preferences keys overriding the detected locale
+type localePreferences struct {
+	Country    string `json:"country"`
+	Currency   string `json:"currency"`
+	TaxDisplay string `json:"tax_display"` // TaxDisplayInclusive or TaxDisplayExclusive
+}
+
+// Resolve returns the locale of a request from clientIP with the given
+// headers. The trusted country headers win over the configured ranges; a
+// signed in customer's country, currency and tax_display preferences win over both.
+func (s *StoreLocaleService) Resolve(ctx context.Context, clientIP string, header http.Header, userID *uuid.UUID) StoreLocale {
+	country, source := s.config.DefaultCountry, LocaleSourceDefault
+	if detected := s.detectCountry(clientIP, header); detected != "" {
+		country, source = detected, LocaleSourceGeoIP
+	}
+
+	var preferences localePreferences
+	if userID != nil {
+		var err error
+		if preferences, err = s.userPreferences(ctx, *userID); err != nil {
+			log.Printf("Warning: failed to get locale preferences: %v", err)
+		}
+		if preferred := normalizeCountry(preferences.Country); preferred != "" {
+			country, source = preferred, LocaleSourcePreference
+		}
+	}
+
+	locale := s.localeFor(country)
+	locale.Source = source
+	if currency := strings.ToUpper(strings.TrimSpace(preferences.Currency)); len(currency) == 3 {
+		locale.Currency = currency
+	}
+	switch preferences.TaxDisplay {
+	case TaxDisplayInclusive:
+		locale.TaxInclusive = true
+	case TaxDisplayExclusive:
+		locale.TaxInclusive = false
+	}
+	return locale
+}
+
+// localeFor is the default locale of a country
+func (s *StoreLocaleService) localeFor(country string) StoreLocale {
+	locale := StoreLocale{
+		Country:        country,
+		Currency:       s.config.StoreCurrency,
+		StoreCurrency:  s.config.StoreCurrency,
+		ShipsToCountry: len(s.config.ShippingCountries) == 0 || s.config.ShippingCountries[country],
+	}
+	if region, ok := countryRegions[country]; ok {
+		locale.Currency = region.Currency
+		locale.TaxInclusive = region.TaxInclusive
+	}
+	return locale
+}
+
+// detectCountry places a request by the first trusted country header that is
+// set, then by the configured address ranges
+func (s *StoreLocaleService) detectCountry(clientIP string, header http.Header) string {
+	for _, name := range s.config.CountryHeaders {
+		if country := normalizeCountry(header.Get(name)); country != "" {
+			return country
+		}
+	}
+	ip := net.ParseIP(clientIP)
+	if ip == nil {
+		return ""
+	}
+	for _, geoRange := range s.config.Ranges {
+		if geoRange.network.Contains(ip) {
+			return geoRange.country
+		}
+	}
+	return ""
+}
+
+// userPreferences reads a customer's locale preferences
+func (s *StoreLocaleService) userPreferences(ctx context.Context, userID uuid.UUID) (localePreferences, error) {
+	var user models.User
+	if err := s.db.WithContext(ctx).Select("preferences").First(&user, "id = ?", userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return localePreferences{}, nil
+		}
+		return localePreferences{}, fmt.Errorf("failed to fetch user: %v", err)
+	}
+
+	var preferences localePreferences
+	if len(user.Preferences) > 0 {
+		if err := json.Unmarshal(user.Preferences, &preferences); err != nil {
+			return localePreferences{}, fmt.Errorf("failed to parse preferences: %v", err)
+		}
+	}
+	return preferences, nil
+}
+
+type storeLocaleKey struct{}
+
+// WithStoreLocale returns a context carrying the request's locale
+func WithStoreLocale(ctx context.Context, locale StoreLocale) context.Context {
+	return context.WithValue(ctx, storeLocaleKey{}, locale)
+}
+
+// StoreLocaleFromContext returns the locale set by WithStoreLocale
+func StoreLocaleFromContext(ctx context.Context) (StoreLocale, bool) {
+	locale, ok := ctx.Value(storeLocaleKey{}).(StoreLocale)
+	return locale, ok
+}
+
+// localePrompt tells the assistant where the customer is and what that means
+// for prices and shipping
+func localePrompt(locale StoreLocale) string {
+	prompt := fmt.Sprintf("Customer locale: the customer is in %s and uses %s.", locale.Country, locale.Currency)
+	if locale.Currency != locale.StoreCurrency {
+		prompt += fmt.Sprintf(" Catalog prices are in %s and orders are charged in %s; quote %s prices and never convert them yourself.",
+			locale.StoreCurrency, locale.StoreCurrency, locale.StoreCurrency)
+	}
+	if !locale.ShipsToCountry {
+		prompt += fmt.Sprintf(" The store does not ship to %s: say so before they add items to their cart or check out.", locale.Country)
+	}
+	return prompt
+}
@@ -0,0 +1,329 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/internal/services/search"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	// synonymCacheTTL is how long the synonyms and the spelling vocabulary are
+	// kept in memory before being reloaded; admin changes reload them at once
+	synonymCacheTTL = time.Minute
+	// minCorrectableLength is the shortest word spelling correction is tried on,
+	// shorter words have too many close neighbours
+	minCorrectableLength = 5
+)
+
+// Synonym errors
+var (
+	ErrSynonymNotFound = errors.New("synonym not found")
+	ErrSynonymExists   = errors.New("a synonym entry for this term already exists")
+)
+
+// SynonymRequest is the payload for creating or updating a synonym entry
+type SynonymRequest struct {
+	Term     string   `json:"term" binding:"required"`
+	Synonyms []string `json:"synonyms" binding:"required,min=1"`
+}
+
+// QueryRewrite is a search query after spelling correction and synonym expansion
+type QueryRewrite struct {
+	Original  string   `json:"original"`
+	Corrected string   `json:"corrected"` // same as Original when nothing was misspelled
+	Queries   []string `json:"queries"`   // the queries to search for, the corrected one first
+}
+
+// SynonymService rewrites search queries using the admin-managed synonyms
+// dictionary and corrects misspelled words against the catalog's vocabulary
+type SynonymService struct {
+	db          *gorm.DB
+	levenshtein *search.LevenshteinService
+
+	mu         sync.RWMutex
+	loadedAt   time.Time
+	synonyms   map[string][]string
+	vocabulary map[string]int // word -> number of products and categories using it
+}
+
+// NewSynonymService creates a new SynonymService
+func NewSynonymService(db *gorm.DB) *SynonymService {
+	return &SynonymService{
+		db:          db,
+		levenshtein: search.NewLevenshteinService(),
+	}
+}
+
+// Rewrite corrects a query's spelling and expands it with synonyms. When the
+// dictionary can't be loaded the query is searched as is.
+func (s *SynonymService) Rewrite(ctx context.Context, query string) *QueryRewrite {
+	original := strings.Join(strings.Fields(strings.ToLower(query)), " ")
+	rewrite := &QueryRewrite{Original: original, Corrected: original, Queries: []string{}}
+	if original == "" {
+		return rewrite
+	}
+
+	synonyms, vocabulary, err := s.load(ctx)
+	if err != nil {
+		log.Printf("Failed to load search synonyms: %v", err)
+		rewrite.Queries = append(rewrite.Queries, original)
+		return rewrite
+	}
+
+	words := strings.Fields(original)
+	for i, word := range words {
+		words[i] = s.correct(word, synonyms, vocabulary)
+	}
+	rewrite.Corrected = strings.Join(words, " ")
+
+	seen := make(map[string]bool)
+	add := func(q string) {
+		if !seen[q] {
+			seen[q] = true
+			rewrite.Queries = append(rewrite.Queries, q)
+		}
+	}
+	add(rewrite.Corrected)
+	add(original)
+
+	padded := " " + rewrite.Corrected + " "
+	for term, replacements := range synonyms {
+		if !strings.Contains(padded, " "+term+" ") {
+			continue
+		}
+		for _, replacement := range replacements {
+			add(strings.TrimSpace(strings.Replace(padded, " "+term+" ", " "+replacement+" ", 1)))
+		}
+	}
+	return rewrite
+}
+
+// ExpandText appends spelling corrections and synonyms to free text such as a
+// chat message, so keyword matching also finds products named differently
+func (s *SynonymService) ExpandText(ctx context.Context, text string) string {
+	rewrite := s.Rewrite(ctx, text)
+
+	present := make(map[string]bool)
+	for _, word := range strings.Fields(rewrite.Original) {
+		present[word] = true
+	}
+	var extra []string
+	for _, q := range rewrite.Queries {
+		for _, word := range strings.Fields(q) {
+			if !present[word] {
+				present[word] = true
+				extra = append(extra, word)
+			}
+		}
+	}
+	if len(extra) == 0 {
+		return text
+	}
+	return text + " " + strings.Join(extra, " ")
+}
+
+// correct returns the closest catalog word to a word that isn't in the
+// catalog, or the word itself when nothing is close enough
+func (s *SynonymService) correct(word string, synonyms map[string][]string, vocabulary map[string]int) string {
+	if len([]rune(word)) < minCorrectableLength || vocabulary[word] > 0 || strings.IndexFunc(word, isWordSeparator) >= 0 {
+		return word
+	}
+	if _, ok := synonyms[word]; ok {
+		return word
+	}
+
+	maxDistance := 1
+	if len([]rune(word)) >= 8 {
+		maxDistance = 2
+	}
+	best, bestDistance, bestCount := word, maxDistance+1, 0
+	for candidate, count := range vocabulary {
+		if diff := len(candidate) - len(word); diff > maxDistance || -diff > maxDistance {
+			continue
+		}
+		distance := s.levenshtein.CalculateDistance(word, candidate)
+		if distance < bestDistance ||
+			(distance == bestDistance && (count > bestCount || (count == bestCount && candidate < best))) {
+			best, bestDistance, bestCount = candidate, distance, count
+		}
+	}
+	if bestDistance > maxDistance {
+		return word
+	}
+	return best
+}
+
+// load returns the cached synonyms and vocabulary, reloading them when stale
+func (s *SynonymService) load(ctx context.Context) (map[string][]string, map[string]int, error) {
+	s.mu.RLock()
+	if s.synonyms != nil && time.Since(s.loadedAt) < synonymCacheTTL {
+		synonyms, vocabulary := s.synonyms, s.vocabulary
+		s.mu.RUnlock()
+		return synonyms, vocabulary, nil
+	}
+	s.mu.RUnlock()
+
+	db := s.db.WithContext(ctx)
+
+	var entries []models.SearchSynonym
+	if err := db.Find(&entries).Error; err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch synonyms: %v", err)
+	}
+	synonyms := make(map[string][]string, len(entries))
+	vocabulary := make(map[string]int)
+	for _, entry := range entries {
+		var replacements []string
+		if err := json.Unmarshal(entry.Synonyms, &replacements); err != nil {
+			return nil, nil, fmt.Errorf("failed to read synonyms of %q: %v", entry.Term, err)
+		}
+		synonyms[entry.Term] = replacements
+		for _, term := range append([]string{entry.Term}, replacements...) {
+			for _, word := range strings.Fields(term) {
+				vocabulary[word]++
+			}
+		}
+	}
+
+	var names []string
+	if err := db.Model(&models.Product{}).Scopes(publishedAt(time.Now())).Pluck("name", &names).Error; err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch product names: %v", err)
+	}
+	var categoryNames []string
+	if err := db.Model(&models.Category{}).Where("is_active = ?", true).Pluck("name", &categoryNames).Error; err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch category names: %v", err)
+	}
+	for _, name := range append(names, categoryNames...) {
+		for _, word := range strings.FieldsFunc(strings.ToLower(name), isWordSeparator) {
+			vocabulary[word]++
+		}
+	}
+
+	s.mu.Lock()
+	s.synonyms, s.vocabulary, s.loadedAt = synonyms, vocabulary, time.Now()
+	s.mu.Unlock()
+	return synonyms, vocabulary, nil
+}
+
+// invalidate makes the next rewrite reload the dictionary
+func (s *SynonymService) invalidate() {
+	s.mu.Lock()
+	s.synonyms = nil
+	s.mu.Unlock()
+}
+
+// ListSynonyms returns the synonyms dictionary ordered by term
+func (s *SynonymService) ListSynonyms(ctx context.Context) ([]models.SearchSynonym, error) {
+	var entries []models.SearchSynonym
+	if err := s.db.WithContext(ctx).Order("term ASC").Find(&entries).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch synonyms: %v", err)
+	}
+	return entries, nil
+}
+
+// CreateSynonym adds a term to the synonyms dictionary
+func (s *SynonymService) CreateSynonym(ctx context.Context, req SynonymRequest) (*models.SearchSynonym, error) {
+	entry := &models.SearchSynonym{ID: uuid.New()}
+	if err := applySynonymRequest(entry, req); err != nil {
+		return nil, err
+	}
+
+	db := s.db.WithContext(ctx)
+	var count int64
+	if err := db.Model(&models.SearchSynonym{}).Where("term = ?", entry.Term).Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("failed to check synonym: %v", err)
+	}
+	if count > 0 {
+		return nil, ErrSynonymExists
+	}
+	if err := db.Create(entry).Error; err != nil {
+		return nil, fmt.Errorf("failed to create synonym: %v", err)
+	}
+	s.invalidate()
+	return entry, nil
+}
+
+// UpdateSynonym replaces a synonym entry's term and synonyms
+func (s *SynonymService) UpdateSynonym(ctx context.Context, id uuid.UUID, req SynonymRequest) (*models.SearchSynonym, error) {
+	db := s.db.WithContext(ctx)
+
+	var entry models.SearchSynonym
+	if err := db.Where("id = ?", id).First(&entry).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSynonymNotFound
+		}
+		return nil, fmt.Errorf("failed to fetch synonym: %v", err)
+	}
+	if err := applySynonymRequest(&entry, req); err != nil {
+		return nil, err
+	}
+
+	var count int64
+	if err := db.Model(&models.SearchSynonym{}).Where("term = ? AND id != ?", entry.Term, id).Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("failed to check synonym: %v", err)
+	}
+	if count > 0 {
+		return nil, ErrSynonymExists
+	}
+	if err := db.Save(&entry).Error; err != nil {
+		return nil, fmt.Errorf("failed to update synonym: %v", err)
+	}
+	s.invalidate()
+	return &entry, nil
+}
+
+// DeleteSynonym removes a synonym entry
+func (s *SynonymService) DeleteSynonym(ctx context.Context, id uuid.UUID) error {
+	result := s.db.WithContext(ctx).Where("id = ?", id).Delete(&models.SearchSynonym{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete synonym: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrSynonymNotFound
+	}
+	s.invalidate()
+	return nil
+}
+
+// applySynonymRequest normalizes and validates a request onto an entry
+func applySynonymRequest(entry *models.SearchSynonym, req SynonymRequest) error {
+	term := strings.Join(strings.Fields(strings.ToLower(req.Term)), " ")
+	if term == "" {
+		return errors.New("term is required")
+	}
+
+	synonyms := []string{}
+	seen := map[string]bool{term: true}
+	for _, synonym := range req.Synonyms {
+		synonym = strings.Join(strings.Fields(strings.ToLower(synonym)), " ")
+		if synonym == "" || seen[synonym] {
+			continue
+		}
+		seen[synonym] = true
+		synonyms = append(synonyms, synonym)
+	}
+	if len(synonyms) == 0 {
+		return errors.New("at least one synonym different from the term is required")
+	}
+
+	synonymsJSON, err := json.Marshal(synonyms)
+	if err != nil {
+		return fmt.Errorf("failed to marshal synonyms: %v", err)
+	}
+	entry.Term = term
+	entry.Synonyms = synonymsJSON
+	return nil
+}
+
+func isWordSeparator(r rune) bool {
+	return r == ' ' || r == '-' || r == '/' || r == ',' || r == '(' || r == ')' || r == '.'
+}
@@ -0,0 +1,120 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// TaxConfig holds the store's tax rates and whether catalog prices include
+// tax, as VAT-style stores price them
+type TaxConfig struct {
+	DefaultRate      float64
+	Rates            map[string]float64 // by destination country code
+	PricesIncludeTax bool
+}
+
+// TaxConfigFromEnv taxes at TAX_RATE_PERCENT (8) unless TAX_RATES has a rate
+// for the destination country as country=percent entries (e.g. "GB=20,DE=19").
+// PRICES_INCLUDE_TAX=true means catalog prices already include the tax.
+func TaxConfigFromEnv() TaxConfig {
+	config := TaxConfig{DefaultRate: 0.08, Rates: map[string]float64{}}
+	if value := os.Getenv("TAX_RATE_PERCENT"); value != "" {
+		percent, err := strconv.ParseFloat(value, 64)
+		if err != nil || percent < 0 {
+			log.Printf("Warning: invalid TAX_RATE_PERCENT %q, using 8%%", value)
+		} else {
+			config.DefaultRate = percent / 100
+		}
+	}
+	for _, entry := range strings.Split(os.Getenv("TAX_RATES"), ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		country, percent, err := parseTaxRate(entry)
+		if err != nil {
+			log.Printf("Warning: ignoring TAX_RATES entry %q: %v", entry, err)
+			continue
+		}
+		config.Rates[country] = percent / 100
+	}
+	config.PricesIncludeTax, _ = strconv.ParseBool(os.Getenv("PRICES_INCLUDE_TAX"))
+	return config
+}
+
+// parseTaxRate parses a "country=percent" entry
+func parseTaxRate(entry string) (string, float64, error) {
+	country, value, found := strings.Cut(strings.TrimSpace(entry), "=")
+	if !found {
+		return "", 0, fmt.Errorf("expected country=percent")
+	}
+	code := normalizeCountry(country)
+	if code == "" {
+		return "", 0, fmt.Errorf("invalid country %q", country)
+	}
+	percent, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(value), "%"), 64)
+	if err != nil || percent < 0 {
+		return "", 0, fmt.Errorf("invalid percent %q", value)
+	}
+	return code, percent, nil
+}
+
+// Rate is the tax rate charged on orders shipped to the country
+func (c TaxConfig) Rate(country string) float64 {
+	if rate, ok := c.Rates[strings.ToUpper(country)]; ok {
+		return rate
+	}
+	return c.DefaultRate
+}
+
+// TaxBreakdown splits an amount of catalog prices into its tax and the
+// amount before tax
+type TaxBreakdown struct {
+	Rate     float64 `json:"tax_rate"`
+	Included bool    `json:"tax_included"` // the amount already includes Tax
+	Net      float64 `json:"net_amount"`
+	Tax      float64 `json:"tax_amount"`
+	Gross    float64 `json:"gross_amount"`
+}
+
+// Breakdown works out the tax on an amount of catalog prices for the
+// country. With tax-inclusive prices the tax is extracted from the amount
+// rather than added to it.
+func (c TaxConfig) Breakdown(amount float64, country string) TaxBreakdown {
+	rate := c.Rate(country)
+	breakdown := TaxBreakdown{Rate: rate, Included: c.PricesIncludeTax}
+	if c.PricesIncludeTax {
+		breakdown.Gross = amount
+		breakdown.Tax = roundCents(amount - amount/(1+rate))
+		breakdown.Net = amount - breakdown.Tax
+	} else {
+		breakdown.Net = amount
+		breakdown.Tax = roundCents(amount * rate)
+		breakdown.Gross = amount + breakdown.Tax
+	}
+	return breakdown
+}
+
+// DisplayPrice converts a catalog price to a price with tax (inclusive) or
+// before tax for a customer in the country
+func (c TaxConfig) DisplayPrice(price float64, country string, inclusive bool) float64 {
+	switch rate := c.Rate(country); {
+	case inclusive && !c.PricesIncludeTax:
+		return roundCents(price * (1 + rate))
+	case !inclusive && c.PricesIncludeTax:
+		return roundCents(price / (1 + rate))
+	}
+	return price
+}
+
+// taxPrompt tells the assistant how the prices it is given treat tax
+func taxPrompt(rate float64, inclusive bool) string {
+	percent := strconv.FormatFloat(math.Round(rate*10000)/100, 'f', -1, 64) + "%"
+	if inclusive {
+		return "Tax: product and cart item prices include " + percent + " tax. When you quote a price, say that it includes tax."
+	}
+	return "Tax: product and cart item prices are before tax; " + percent + " tax is added at checkout. When you quote a price, say that tax is extra."
+}
@@ -2,6 +2,8 @@ package services
 
 import (
 	"chat-ecommerce-backend/internal/models"
+	authmodels "chat-ecommerce-backend/internal/models/auth"
+	"context"
 	"encoding/json"
 	"errors"
 	"time"
@@ -14,16 +16,24 @@ import (
 
 // UserService handles user-related business logic
 type UserService struct {
-	db *gorm.DB
+	db       *gorm.DB
+	security *LoginSecurityService
 }
 
 // NewUserService creates a new UserService
 func NewUserService(db *gorm.DB) *UserService {
 	return &UserService{
-		db: db,
+		db:       db,
+		security: NewLoginSecurityService(db),
 	}
 }
 
+// WithLoginSecurity replaces the service that tracks failed sign-ins
+func (s *UserService) WithLoginSecurity(security *LoginSecurityService) *UserService {
+	s.security = security
+	return s
+}
+
 // RegisterRequest represents user registration data
 type RegisterRequest struct {
 	Email     string `json:"email" binding:"required,email"`
@@ -35,8 +45,9 @@ type RegisterRequest struct {
 
 // LoginRequest represents user login data
 type LoginRequest struct {
-	Email    string `json:"email" binding:"required,email"`
-	Password string `json:"password" binding:"required"`
+	Email     string `json:"email" binding:"required,email"`
+	Password  string `json:"password" binding:"required"`
+	IPAddress string `json:"-"` // set by the handler, for throttling and new sign-in warnings
 }
 
 // UpdateProfileRequest represents user profile update data
@@ -87,18 +98,35 @@ func (s *UserService) Register(req *RegisterRequest) (*User, error) {
 	return user, nil
 }
 
-// Login authenticates a user and returns user data
+// Login authenticates a user and returns user data. Repeated failures lock
+// the account or throttle the IP address.
 func (s *UserService) Login(req *LoginRequest) (*User, error) {
+	ctx := context.Background()
+	if err := s.security.CheckIP(ctx, req.IPAddress); err != nil {
+		return nil, err
+	}
+
 	var user User
 	if err := s.db.Where("email = ?", req.Email).First(&user).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
+			if err := s.security.RecordFailure(ctx, nil, req.Email, req.IPAddress); err != nil {
+				return nil, err
+			}
 			return nil, errors.New("invalid email or password")
 		}
 		return nil, errors.New("failed to find user")
 	}
 
+	// Locked accounts can't sign in, even with the right password
+	if err := s.security.CheckLocked(&user); err != nil {
+		return nil, err
+	}
+
 	// Check password
 	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
+		if err := s.security.RecordFailure(ctx, &user, req.Email, req.IPAddress); err != nil {
+			return nil, err
+		}
 		return nil, errors.New("invalid email or password")
 	}
 
@@ -107,6 +135,15 @@ func (s *UserService) Login(req *LoginRequest) (*User, error) {
 		return nil, errors.New("account is not active")
 	}
 
+	// Admins can require a new password, e.g. after a suspected compromise
+	if user.PasswordResetRequired {
+		return nil, ErrPasswordResetRequired
+	}
+
+	if err := s.security.RecordSuccess(ctx, &user, req.IPAddress); err != nil {
+		return nil, err
+	}
+
 	// Clear password hash from response
 	user.PasswordHash = ""
 	return &user, nil
@@ -212,6 +249,60 @@ func (s *UserService) ChangePassword(userID uuid.UUID, currentPassword, newPassw
 	return nil
 }
 
+// CheckCanSignIn returns an error when a user may no longer get tokens,
+// because their account was suspended or deleted or needs a new password
+func (s *UserService) CheckCanSignIn(userID uuid.UUID) error {
+	var user User
+	if err := s.db.Select("status", "password_reset_required").Where("id = ?", userID).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrUserNotFound
+		}
+		return errors.New("failed to find user")
+	}
+	if user.Status != UserStatusActive {
+		return errors.New("account is not active")
+	}
+	if user.PasswordResetRequired {
+		return ErrPasswordResetRequired
+	}
+	return nil
+}
+
+// ResetPassword sets a new password using a reset link's token and lets the
+// user sign in again
+func (s *UserService) ResetPassword(token, newPassword string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var reset authmodels.PasswordResetToken
+		if err := tx.Where("token = ?", hashResetToken(token)).First(&reset).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidResetToken
+			}
+			return errors.New("failed to find reset link")
+		}
+		if !reset.IsValid() {
+			return ErrInvalidResetToken
+		}
+
+		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+		if err != nil {
+			return errors.New("failed to hash new password")
+		}
+
+		reset.MarkAsUsed()
+		if err := tx.Save(&reset).Error; err != nil {
+			return errors.New("failed to update reset link")
+		}
+		if err := tx.Model(&User{}).Where("id = ?", reset.UserID).Updates(map[string]interface{}{
+			"password_hash":           string(hashedPassword),
+			"password_reset_required": false,
+			"updated_at":              time.Now(),
+		}).Error; err != nil {
+			return errors.New("failed to update password")
+		}
+		return nil
+	})
+}
+
 // DeleteUser soft deletes a user account
 func (s *UserService) DeleteUser(userID uuid.UUID) error {
 	var user User
@@ -0,0 +1,326 @@
+package services
+
+import (
+	"bytes"
+	"chat-ecommerce-backend/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sashabaranov/go-openai"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidChatAudio   = errors.New("invalid audio")
+	ErrChatAudioTooLarge  = errors.New("audio is too large")
+	ErrVoiceUnavailable   = errors.New("voice messages are not available")
+	ErrSpeechUnavailable  = errors.New("spoken replies are not available")
+	ErrVoiceReplyNotFound = errors.New("reply not found")
+)
+
+// chatAudioFormats are the recording formats speech to text reads, by the
+// sniffed content type, as the file extension it's sent with
+var chatAudioFormats = map[string]string{
+	"audio/mpeg":      "mp3",
+	"audio/wave":      "wav",
+	"audio/flac":      "flac",
+	"application/ogg": "ogg",
+	"video/webm":      "webm", // what browsers record
+	"video/mp4":       "m4a",  // what Safari records
+}
+
+// maxSpokenReplyRunes is the longest reply read aloud; longer ones are cut
+const maxSpokenReplyRunes = 4000
+
+// ChatAudio is a voice message a shopper recorded
+type ChatAudio struct {
+	Data        []byte
+	ContentType string // as sniffed from Data
+}
+
+// filename names the recording by its format, which is how speech to text
+// tells formats apart
+func (a ChatAudio) filename() string {
+	return "voice." + chatAudioFormats[a.ContentType]
+}
+
+// SpokenReply is a reply read aloud
+type SpokenReply struct {
+	Data        []byte
+	ContentType string
+}
+
+// SpeechProvider turns speech into text and text into speech
+type SpeechProvider interface {
+	// Transcribe returns what was said in the recording
+	Transcribe(ctx context.Context, audio ChatAudio) (string, error)
+	// Synthesize reads text aloud
+	Synthesize(ctx context.Context, text string) (*SpokenReply, error)
+}
+
+// VoiceConfig configures voice messages
+type VoiceConfig struct {
+	STTModel      string // "" turns voice messages off
+	TTSModel      string // "" turns spoken replies off
+	TTSVoice      string
+	MaxAudioBytes int
+	Timeout       time.Duration
+}
+
+// VoiceConfigFromEnv reads STT_MODEL (whisper-1), TTS_MODEL (tts-1) and
+// TTS_VOICE (alloy), either model "off" to turn it off, CHAT_AUDIO_MAX_KB
+// (10240) and VOICE_TIMEOUT_MS (30000)
+func VoiceConfigFromEnv() VoiceConfig {
+	model := func(key, fallback string) string {
+		switch value := os.Getenv(key); value {
+		case "":
+			return fallback
+		case "off":
+			return ""
+		default:
+			return value
+		}
+	}
+	voice := os.Getenv("TTS_VOICE")
+	if voice == "" {
+		voice = string(openai.VoiceAlloy)
+	}
+	return VoiceConfig{
+		STTModel:      model("STT_MODEL", openai.Whisper1),
+		TTSModel:      model("TTS_MODEL", string(openai.TTSModel1)),
+		TTSVoice:      voice,
+		MaxAudioBytes: envInt("CHAT_AUDIO_MAX_KB", 10240) * 1024,
+		Timeout:       time.Duration(envInt("VOICE_TIMEOUT_MS", 30000)) * time.Millisecond,
+	}
+}
+
+// Validate checks a recording can be transcribed, sniffing its format rather
+// than trusting what the browser said
+func (c VoiceConfig) Validate(audio *ChatAudio) error {
+	if len(audio.Data) == 0 {
+		return fmt.Errorf("%w: attach a recording as audio", ErrInvalidChatAudio)
+	}
+	if c.MaxAudioBytes > 0 && len(audio.Data) > c.MaxAudioBytes {
+		return fmt.Errorf("%w: recordings can be up to %d KB", ErrChatAudioTooLarge, c.MaxAudioBytes/1024)
+	}
+	audio.ContentType = sniffAudio(audio.Data)
+	if _, ok := chatAudioFormats[audio.ContentType]; !ok {
+		return fmt.Errorf("%w: recordings must be WebM, Ogg, MP3, M4A, WAV or FLAC", ErrInvalidChatAudio)
+	}
+	return nil
+}
+
+// sniffAudio returns the content type of a recording, adding the formats
+// http.DetectContentType misses: FLAC, and MP3 without an ID3 tag
+func sniffAudio(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte("fLaC")):
+		return "audio/flac"
+	case len(data) > 1 && data[0] == 0xFF && data[1]&0xE0 == 0xE0:
+		return "audio/mpeg"
+	}
+	return http.DetectContentType(data)
+}
+
+// OpenAISpeech implements SpeechProvider with OpenAI's Whisper and text to
+// speech models
+type OpenAISpeech struct {
+	client *openai.Client
+	config VoiceConfig
+}
+
+// NewOpenAISpeech creates a new OpenAISpeech
+func NewOpenAISpeech(apiKey string, config VoiceConfig) *OpenAISpeech {
+	return &OpenAISpeech{
+		client: openai.NewClient(apiKey),
+		config: config,
+	}
+}
+
+// Transcribe sends the recording to the speech to text model
+func (p *OpenAISpeech) Transcribe(ctx context.Context, audio ChatAudio) (string, error) {
+	response, err := p.client.CreateTranscription(ctx, openai.AudioRequest{
+		Model:    p.config.STTModel,
+		FilePath: audio.filename(),
+		Reader:   bytes.NewReader(audio.Data),
+		Format:   openai.AudioResponseFormatJSON,
+	})
+	if err != nil {
+		return "", err
+	}
+	return response.Text, nil
+}
+
+// Synthesize reads the text aloud as MP3
+func (p *OpenAISpeech) Synthesize(ctx context.Context, text string) (*SpokenReply, error) {
+	response, err := p.client.CreateSpeech(ctx, openai.CreateSpeechRequest{
+		Model:          openai.SpeechModel(p.config.TTSModel),
+		Input:          text,
+		Voice:          openai.SpeechVoice(p.config.TTSVoice),
+		ResponseFormat: openai.SpeechResponseFormatMp3,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer response.Close()
+
+	data, err := io.ReadAll(response)
+	if err != nil {
+		return nil, err
+	}
+	return &SpokenReply{Data: data, ContentType: "audio/mpeg"}, nil
+}
+
+// VoiceService transcribes voice messages and reads replies aloud
+type VoiceService struct {
+	speech SpeechProvider
+	config VoiceConfig
+}
+
+// NewVoiceService creates a new VoiceService
+func NewVoiceService(speech SpeechProvider, config VoiceConfig) *VoiceService {
+	return &VoiceService{
+		speech: speech,
+		config: config,
+	}
+}
+
+// Available reports whether voice messages can be transcribed
+func (v *VoiceService) Available() bool {
+	return v != nil && v.speech != nil && v.config.STTModel != ""
+}
+
+// CanSpeak reports whether replies can be read aloud
+func (v *VoiceService) CanSpeak() bool {
+	return v != nil && v.speech != nil && v.config.TTSModel != ""
+}
+
+// Transcribe returns what the shopper said
+func (v *VoiceService) Transcribe(ctx context.Context, audio ChatAudio) (string, error) {
+	if !v.Available() {
+		return "", ErrVoiceUnavailable
+	}
+	if v.config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, v.config.Timeout)
+		defer cancel()
+	}
+	transcript, err := v.speech.Transcribe(ctx, audio)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(transcript), nil
+}
+
+// Speak reads a reply aloud, cutting very long ones short
+func (v *VoiceService) Speak(ctx context.Context, text string) (*SpokenReply, error) {
+	if !v.CanSpeak() {
+		return nil, ErrSpeechUnavailable
+	}
+	if v.config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, v.config.Timeout)
+		defer cancel()
+	}
+	if spoken := truncateRunes(text, maxSpokenReplyRunes); spoken != text {
+		text = spoken + "…"
+	}
+	return v.speech.Synthesize(ctx, text)
+}
+
+// VoiceResponse is the answer to a voice message
+type VoiceResponse struct {
+	*ChatResponse
+	Transcript string     // what the shopper said
+	ReplyID    *uuid.UUID // the reply's message, which can be read aloud
+}
+
+// WithVoice lets shoppers send voice messages and hear replies
+func (s *ChatService) WithVoice(voice *VoiceService) *ChatService {
+	s.voice = voice
+	return s
+}
+
+// CanSpeak reports whether replies to voice messages can be read aloud
+func (s *ChatService) CanSpeak() bool {
+	return s.voice.CanSpeak()
+}
+
+// ProcessVoiceMessage transcribes a voice message and answers the transcript
+// as a typed message
+func (s *ChatService) ProcessVoiceMessage(ctx context.Context, sessionID string, userID *uuid.UUID, audio ChatAudio) (*VoiceResponse, error) {
+	if !s.voice.Available() {
+		return nil, ErrVoiceUnavailable
+	}
+	if err := s.voice.config.Validate(&audio); err != nil {
+		return nil, err
+	}
+	// Limited shoppers are told to slow down before paying for the transcript
+	overBudget, err := s.allowMessage(ctx, sessionID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	transcript, err := s.voice.Transcribe(ctx, audio)
+	if err != nil {
+		return nil, fmt.Errorf("failed to transcribe voice message: %v", err)
+	}
+	if transcript == "" {
+		return nil, fmt.Errorf("%w: no speech was heard", ErrInvalidChatAudio)
+	}
+
+	started := time.Now()
+	response, err := s.answer(ctx, sessionID, userID, transcript, overBudget, nil)
+	if err != nil {
+		return nil, err
+	}
+	if response.Context == nil {
+		response.Context = map[string]interface{}{}
+	}
+	response.Context["transcript"] = transcript
+
+	voice := &VoiceResponse{ChatResponse: response, Transcript: transcript}
+	var reply models.ChatMessage
+	err = s.db.WithContext(ctx).
+		Where("session_id = ? AND role = ? AND created_at >= ?", sessionID, "assistant", started).
+		Order("created_at DESC").First(&reply).Error
+	switch {
+	case err == nil:
+		voice.ReplyID = &reply.ID
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return nil, fmt.Errorf("failed to find reply: %v", err)
+	}
+	return voice, nil
+}
+
+// SpeakReply reads one of the assistant's or an agent's replies in the
+// session aloud
+func (s *ChatService) SpeakReply(ctx context.Context, sessionID string, messageID uuid.UUID) (*SpokenReply, error) {
+	if !s.voice.CanSpeak() {
+		return nil, ErrSpeechUnavailable
+	}
+
+	var reply models.ChatMessage
+	err := s.db.WithContext(ctx).
+		Where("id = ? AND session_id = ? AND role IN ?", messageID, sessionID, []string{"assistant", "agent"}).
+		First(&reply).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrVoiceReplyNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch reply: %v", err)
+	}
+
+	spoken, err := s.voice.Speak(ctx, reply.Content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read reply aloud: %v", err)
+	}
+	return spoken, nil
+}
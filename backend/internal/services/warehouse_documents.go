@@ -0,0 +1,394 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// UnassignedLocation groups pick list lines whose stock has no warehouse location
+const UnassignedLocation = "UNASSIGNED"
+
+// PickListFilter narrows a pick list to some warehouse locations or orders
+type PickListFilter struct {
+	Locations []string
+	OrderIDs  []uuid.UUID
+}
+
+// PickListOrder is one shipment a pick list line is picked for
+type PickListOrder struct {
+	OrderID       uuid.UUID `json:"order_id"`
+	OrderNumber   string    `json:"order_number"`
+	FulfillmentID uuid.UUID `json:"fulfillment_id"`
+	Quantity      int       `json:"quantity"`
+}
+
+// PickListLine is the total quantity of one product or variant to pick
+type PickListLine struct {
+	ProductID   uuid.UUID       `json:"product_id"`
+	VariantID   *uuid.UUID      `json:"variant_id,omitempty"`
+	SKU         string          `json:"sku"`
+	ProductName string          `json:"product_name"`
+	Variant     string          `json:"variant,omitempty"`
+	Quantity    int             `json:"quantity"`
+	Orders      []PickListOrder `json:"orders"`
+}
+
+// PickListLocation is the part of a pick list picked at one warehouse location
+type PickListLocation struct {
+	WarehouseLocation string         `json:"warehouse_location"`
+	Units             int            `json:"units"`
+	Lines             []PickListLine `json:"lines"`
+}
+
+// PickList lists what to pick for the shipments that are ready to go, walked
+// one warehouse location at a time
+type PickList struct {
+	GeneratedAt  time.Time          `json:"generated_at"`
+	Fulfillments int                `json:"fulfillments"`
+	Units        int                `json:"units"`
+	Locations    []PickListLocation `json:"locations"`
+}
+
+// PackingSlipItem is one line packed into a shipment
+type PackingSlipItem struct {
+	SKU               string   `json:"sku"`
+	ProductName       string   `json:"product_name"`
+	Variant           string   `json:"variant,omitempty"`
+	WarehouseLocation string   `json:"warehouse_location"`
+	Quantity          int      `json:"quantity"`
+	UnitPrice         *float64 `json:"unit_price,omitempty"`
+	TotalPrice        *float64 `json:"total_price,omitempty"`
+}
+
+// PackingSlip is the document packed with one shipment of an order. Gift
+// orders carry the gift message instead of any prices.
+type PackingSlip struct {
+	OrderID         uuid.UUID              `json:"order_id"`
+	OrderNumber     string                 `json:"order_number"`
+	OrderDate       time.Time              `json:"order_date"`
+	FulfillmentID   uuid.UUID              `json:"fulfillment_id"`
+	Shipment        int                    `json:"shipment"`
+	Shipments       int                    `json:"shipments"`
+	ShippingAddress map[string]interface{} `json:"shipping_address"`
+	Gift            bool                   `json:"gift"`
+	GiftWrap        bool                   `json:"gift_wrap"`
+	GiftMessage     string                 `json:"gift_message,omitempty"`
+	Items           []PackingSlipItem      `json:"items"`
+	Subtotal        *float64               `json:"subtotal,omitempty"`
+	Currency        string                 `json:"currency,omitempty"`
+}
+
+// PickList gathers the items of every pending shipment by the warehouse
+// location their stock is kept at. Orders waiting on a bank transfer are left
+// out until they are paid; cash on delivery orders ship straight away.
+func (s *OrderService) PickList(ctx context.Context, filter PickListFilter) (*PickList, error) {
+	query := s.db.WithContext(ctx).
+		Joins("JOIN orders ON orders.id = fulfillments.order_id").
+		Where("fulfillments.status = ?", FulfillmentPending).
+		Where("orders.status <> ?", "cancelled").
+		Where("orders.status <> ? OR orders.payment_method = ?", OrderStatusAwaitingPayment, PaymentMethodCOD).
+		Preload("Items.Product").Preload("Items.Variant").
+		Order("orders.created_at ASC, fulfillments.sequence ASC")
+	if len(filter.OrderIDs) > 0 {
+		query = query.Where("fulfillments.order_id IN ?", filter.OrderIDs)
+	}
+
+	var fulfillments []Fulfillment
+	if err := query.Find(&fulfillments).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch pending fulfillments: %v", err)
+	}
+
+	orderNumbers, err := s.orderNumbers(ctx, fulfillments)
+	if err != nil {
+		return nil, err
+	}
+	var items []OrderItem
+	for _, fulfillment := range fulfillments {
+		items = append(items, fulfillment.Items...)
+	}
+	locations, err := s.warehouseLocations(ctx, items)
+	if err != nil {
+		return nil, err
+	}
+
+	wanted := map[string]bool{}
+	for _, location := range filter.Locations {
+		wanted[strings.ToUpper(strings.TrimSpace(location))] = true
+	}
+
+	byLocation := map[string]*PickListLocation{}
+	lineIndex := map[string]int{}
+	list := &PickList{GeneratedAt: time.Now(), Locations: []PickListLocation{}}
+	picked := map[uuid.UUID]bool{}
+	for _, fulfillment := range fulfillments {
+		for _, item := range fulfillment.Items {
+			location := locations[stockKey(item.ProductID, item.VariantID)]
+			if len(wanted) > 0 && !wanted[strings.ToUpper(location)] {
+				continue
+			}
+
+			group, ok := byLocation[location]
+			if !ok {
+				group = &PickListLocation{WarehouseLocation: location}
+				byLocation[location] = group
+			}
+			key := location + "/" + stockKey(item.ProductID, item.VariantID)
+			index, ok := lineIndex[key]
+			if !ok {
+				group.Lines = append(group.Lines, PickListLine{
+					ProductID:   item.ProductID,
+					VariantID:   item.VariantID,
+					SKU:         itemSKU(item),
+					ProductName: item.Product.Name,
+					Variant:     variantLabel(item.Variant),
+				})
+				index = len(group.Lines) - 1
+				lineIndex[key] = index
+			}
+
+			line := &group.Lines[index]
+			line.Quantity += item.Quantity
+			line.Orders = append(line.Orders, PickListOrder{
+				OrderID:       fulfillment.OrderID,
+				OrderNumber:   orderNumbers[fulfillment.OrderID],
+				FulfillmentID: fulfillment.ID,
+				Quantity:      item.Quantity,
+			})
+			group.Units += item.Quantity
+			list.Units += item.Quantity
+			picked[fulfillment.ID] = true
+		}
+	}
+	list.Fulfillments = len(picked)
+
+	// Walk the warehouse in location order, then by SKU within a location
+	for _, group := range byLocation {
+		sort.Slice(group.Lines, func(i, j int) bool { return group.Lines[i].SKU < group.Lines[j].SKU })
+		list.Locations = append(list.Locations, *group)
+	}
+	sort.Slice(list.Locations, func(i, j int) bool {
+		a, b := list.Locations[i].WarehouseLocation, list.Locations[j].WarehouseLocation
+		if (a == UnassignedLocation) != (b == UnassignedLocation) {
+			return b == UnassignedLocation
+		}
+		return a < b
+	})
+	return list, nil
+}
+
+// PackingSlip builds the packing slip of one of an order's shipments
+func (s *OrderService) PackingSlip(ctx context.Context, orderID, fulfillmentID uuid.UUID) (*PackingSlip, error) {
+	var order Order
+	if err := s.db.WithContext(ctx).Preload("Fulfillments").Where("id = ?", orderID).First(&order).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("order not found")
+		}
+		return nil, errors.New("failed to retrieve order")
+	}
+
+	var fulfillment Fulfillment
+	if err := s.db.WithContext(ctx).Preload("Items", func(db *gorm.DB) *gorm.DB {
+		return db.Order("created_at ASC")
+	}).Preload("Items.Product").Preload("Items.Variant").
+		Where("id = ? AND order_id = ?", fulfillmentID, orderID).First(&fulfillment).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrFulfillmentNotFound
+		}
+		return nil, fmt.Errorf("failed to fetch fulfillment: %v", err)
+	}
+
+	locations, err := s.warehouseLocations(ctx, fulfillment.Items)
+	if err != nil {
+		return nil, err
+	}
+
+	var address map[string]interface{}
+	if len(order.ShippingAddress) > 0 {
+		if err := json.Unmarshal(order.ShippingAddress, &address); err != nil {
+			return nil, fmt.Errorf("failed to parse shipping address: %v", err)
+		}
+	}
+
+	shipments := 0
+	for _, other := range order.Fulfillments {
+		if other.Status != FulfillmentCancelled {
+			shipments++
+		}
+	}
+
+	gift := order.GiftWrap || order.GiftMessage != ""
+	slip := &PackingSlip{
+		OrderID:         order.ID,
+		OrderNumber:     order.OrderNumber,
+		OrderDate:       order.CreatedAt,
+		FulfillmentID:   fulfillment.ID,
+		Shipment:        fulfillment.Sequence,
+		Shipments:       shipments,
+		ShippingAddress: address,
+		Gift:            gift,
+		GiftWrap:        order.GiftWrap,
+		GiftMessage:     order.GiftMessage,
+		Items:           make([]PackingSlipItem, 0, len(fulfillment.Items)),
+	}
+
+	var subtotal float64
+	for _, item := range fulfillment.Items {
+		line := PackingSlipItem{
+			SKU:               itemSKU(item),
+			ProductName:       item.Product.Name,
+			Variant:           variantLabel(item.Variant),
+			WarehouseLocation: locations[stockKey(item.ProductID, item.VariantID)],
+			Quantity:          item.Quantity,
+		}
+		if !gift {
+			unitPrice, totalPrice := item.UnitPrice, item.TotalPrice
+			line.UnitPrice, line.TotalPrice = &unitPrice, &totalPrice
+			subtotal += item.TotalPrice
+		}
+		slip.Items = append(slip.Items, line)
+	}
+	if !gift {
+		subtotal = roundCents(subtotal)
+		slip.Subtotal = &subtotal
+		slip.Currency = order.Currency
+	}
+	return slip, nil
+}
+
+// RenderPackingSlipPDF renders a packing slip as a printable PDF document
+func RenderPackingSlipPDF(slip *PackingSlip) []byte {
+	lines := []pdfLine{
+		{Text: "Packing Slip " + slip.OrderNumber, Heading: true},
+		{},
+		{Text: fmt.Sprintf("Order date:  %s", slip.OrderDate.Format("2006-01-02"))},
+		{Text: fmt.Sprintf("Shipment:    %d of %d", slip.Shipment, slip.Shipments)},
+		{},
+		{Text: "Ship to", Heading: true},
+	}
+	for _, field := range []string{"name", "line1", "line2", "city", "state", "postal_code", "country"} {
+		if value, ok := slip.ShippingAddress[field].(string); ok && value != "" {
+			lines = append(lines, pdfLine{Text: value})
+		}
+	}
+	lines = append(lines, pdfLine{})
+
+	if slip.Gift {
+		lines = append(lines,
+			pdfLine{Text: fmt.Sprintf("%-32s %-14s %-18s %5s", "Item", "SKU", "Location", "Qty")},
+			pdfLine{Text: strings.Repeat("-", 72)},
+		)
+		for _, item := range slip.Items {
+			lines = append(lines, pdfLine{Text: fmt.Sprintf("%-32s %-14s %-18s %5d",
+				truncateRunes(packingSlipItemName(item), 32), truncateRunes(item.SKU, 14), truncateRunes(item.WarehouseLocation, 18), item.Quantity)})
+		}
+		if slip.GiftMessage != "" {
+			lines = append(lines, pdfLine{}, pdfLine{Text: "Gift message", Heading: true})
+			for _, line := range strings.Split(slip.GiftMessage, "\n") {
+				lines = append(lines, pdfLine{Text: line})
+			}
+		}
+		return renderPDF(lines)
+	}
+
+	lines = append(lines,
+		pdfLine{Text: fmt.Sprintf("%-32s %-14s %-12s %5s %9s %10s", "Item", "SKU", "Location", "Qty", "Price", "Total")},
+		pdfLine{Text: strings.Repeat("-", 87)},
+	)
+	for _, item := range slip.Items {
+		lines = append(lines, pdfLine{Text: fmt.Sprintf("%-32s %-14s %-12s %5d %9.2f %10.2f",
+			truncateRunes(packingSlipItemName(item), 32), truncateRunes(item.SKU, 14), truncateRunes(item.WarehouseLocation, 12), item.Quantity, *item.UnitPrice, *item.TotalPrice)})
+	}
+	lines = append(lines,
+		pdfLine{Text: strings.Repeat("-", 87)},
+		pdfLine{Text: fmt.Sprintf("%76s %10.2f", "Subtotal ("+slip.Currency+")", *slip.Subtotal)},
+	)
+	return renderPDF(lines)
+}
+
+// orderNumbers maps the orders of the fulfillments to their order numbers
+func (s *OrderService) orderNumbers(ctx context.Context, fulfillments []Fulfillment) (map[uuid.UUID]string, error) {
+	numbers := map[uuid.UUID]string{}
+	if len(fulfillments) == 0 {
+		return numbers, nil
+	}
+	ids := make([]uuid.UUID, 0, len(fulfillments))
+	for _, fulfillment := range fulfillments {
+		ids = append(ids, fulfillment.OrderID)
+	}
+
+	var orders []Order
+	if err := s.db.WithContext(ctx).Select("id", "order_number").Where("id IN ?", ids).Find(&orders).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch orders: %v", err)
+	}
+	for _, order := range orders {
+		numbers[order.ID] = order.OrderNumber
+	}
+	return numbers, nil
+}
+
+// warehouseLocations looks up where the stock of each item is kept, keyed by
+// stockKey. Items without an inventory record are UnassignedLocation.
+func (s *OrderService) warehouseLocations(ctx context.Context, items []OrderItem) (map[string]string, error) {
+	locations := map[string]string{}
+	if len(items) == 0 {
+		return locations, nil
+	}
+	productIDs := make([]uuid.UUID, 0, len(items))
+	for _, item := range items {
+		productIDs = append(productIDs, item.ProductID)
+		locations[stockKey(item.ProductID, item.VariantID)] = UnassignedLocation
+	}
+
+	var inventories []models.Inventory
+	if err := s.db.WithContext(ctx).Select("product_id", "variant_id", "warehouse_location").
+		Where("product_id IN ?", productIDs).Find(&inventories).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch inventory locations: %v", err)
+	}
+	for _, inventory := range inventories {
+		key := stockKey(inventory.ProductID, inventory.VariantID)
+		if _, ok := locations[key]; ok && strings.TrimSpace(inventory.WarehouseLocation) != "" {
+			locations[key] = strings.TrimSpace(inventory.WarehouseLocation)
+		}
+	}
+	return locations, nil
+}
+
+// stockKey identifies a product or one of its variants
+func stockKey(productID uuid.UUID, variantID *uuid.UUID) string {
+	if variantID == nil {
+		return productID.String()
+	}
+	return productID.String() + ":" + variantID.String()
+}
+
+// itemSKU is the SKU of an order item, including its variant's suffix
+func itemSKU(item OrderItem) string {
+	if item.Variant != nil && item.Variant.SKUSuffix != "" {
+		return item.Product.SKU + item.Variant.SKUSuffix
+	}
+	return item.Product.SKU
+}
+
+// variantLabel describes a variant as "Name: Value"
+func variantLabel(variant *models.ProductVariant) string {
+	if variant == nil {
+		return ""
+	}
+	return variant.VariantName + ": " + variant.VariantValue
+}
+
+func packingSlipItemName(item PackingSlipItem) string {
+	if item.Variant == "" {
+		return item.ProductName
+	}
+	return item.ProductName + " (" + item.Variant + ")"
+}
@@ -0,0 +1,51 @@
+package tsgen
+
+// Event is a message type the chat WebSocket sends and the Go type of its data
+type Event struct {
+	Type string // the message type, or the Go string constant holding it, e.g. services.CampaignMessage
+	Data string // the Go type of the data, e.g. handlers.ChatMessage or []services.ChatAction
+}
+
+// Contract is what Generate turns into TypeScript. Go types are written as
+// package.Name with the package looked up in Packages.
+type Contract struct {
+	Packages map[string]string // package name -> directory under the module root
+	Envelope string            // the WebSocket message, its type and data fields set per event
+	Events   []Event
+	Types    []string // other types the frontend uses, e.g. REST bodies
+}
+
+// ChatContract is the chat WebSocket and REST contract of the storefront
+var ChatContract = Contract{
+	Packages: map[string]string{
+		"dto":      "internal/dto",
+		"handlers": "internal/handlers",
+		"services": "internal/services",
+	},
+	Envelope: "handlers.WebSocketMessage",
+	Events: []Event{
+		{Type: "message", Data: "handlers.ChatMessage"},
+		{Type: "chat_response", Data: "handlers.ChatDelta"},
+		{Type: "typing", Data: "handlers.TypingIndicator"},
+		{Type: "error", Data: "handlers.ChatError"},
+		{Type: "actions", Data: "[]services.ChatAction"},
+		// Products are trimmed to the connection's ?fields= when it has any
+		{Type: "suggestions", Data: "[]dto.ProductSuggestionDTO"},
+		{Type: "services.MaintenanceNotification", Data: "services.MaintenanceNotice"},
+		{Type: "services.JobProgressMessage", Data: "services.JobStatus"},
+		{Type: "services.ReservationExpiringMessage", Data: "services.CartReservationNotice"},
+		{Type: "services.ReservationExpiredMessage", Data: "services.CartReservationNotice"},
+		{Type: "services.CheckoutConflictMessage", Data: "services.CheckoutConflict"},
+		{Type: "services.OrderConfirmationMessage", Data: "services.OrderConfirmation"},
+		{Type: "services.FulfillmentUpdateMessage", Data: "services.FulfillmentNotice"},
+		{Type: "services.CampaignMessage", Data: "services.CampaignNotice"},
+		{Type: "services.PaymentRetryMessage", Data: "services.DunningNotice"},
+		{Type: "services.ChatAgentMessage", Data: "services.ChatMessageService"},
+		{Type: "services.ChatAgentStatusMessage", Data: "services.ChatHandoff"},
+	},
+	Types: []string{
+		"handlers.ChatRequest",
+		"handlers.ChatResponse",
+		"services.ProductSignals",
+	},
+}
@@ -0,0 +1,575 @@
+// Package tsgen writes TypeScript types for the JSON the API sends, read from
+// the Go source of the structs it is encoded from. It parses the source
+// instead of importing the packages, so it runs without the rest of the
+// build.
+package tsgen
+
+import (
+	"bufio"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Header starts every generated file
+const Header = "// Code generated by go run ./cmd/tsgen. DO NOT EDIT.\n"
+
+// basicTypes are the TypeScript types of Go's predeclared types
+var basicTypes = map[string]string{
+	"string": "string", "bool": "boolean", "any": "unknown",
+	"int": "number", "int8": "number", "int16": "number", "int32": "number", "int64": "number",
+	"uint": "number", "uint8": "number", "uint16": "number", "uint32": "number", "uint64": "number",
+	"float32": "number", "float64": "number", "byte": "number", "rune": "number",
+}
+
+// externalTypes are the TypeScript types of types from outside the module,
+// by import path and name, following how they encode to JSON
+var externalTypes = map[string]string{
+	"time.Time":                   "string",
+	"time.Duration":               "number",
+	"encoding/json.RawMessage":    "unknown",
+	"github.com/google/uuid.UUID": "string",
+	"gorm.io/datatypes.JSON":      "unknown",
+	"gorm.io/datatypes.JSONMap":   "Record<string, unknown>",
+	"gorm.io/gorm.DeletedAt":      "string | null",
+}
+
+// pkg is a parsed package of the module
+type pkg struct {
+	name   string
+	types  map[string]*typeDecl
+	consts map[string]string // string constants
+}
+
+// source is where a type expression appears: its package and the imports of its file
+type source struct {
+	pkg     *pkg
+	imports map[string]string // package name -> import path
+}
+
+// typeDecl is a type declared in the module
+type typeDecl struct {
+	src  *source
+	spec *ast.TypeSpec
+	doc  *ast.CommentGroup
+}
+
+// field is a JSON field of a struct
+type field struct {
+	name     string
+	ts       string
+	optional bool
+	comment  string
+}
+
+type generator struct {
+	root   string
+	module string
+	pkgs   map[string]*pkg // by import path
+	names  map[*typeDecl]string
+	taken  map[string]bool
+	queue  []*typeDecl // structs to write as interfaces, in the order they were first used
+}
+
+// Generate returns the TypeScript of the contract, reading the Go source of
+// the module at root
+func Generate(root string, contract Contract) ([]byte, error) {
+	module, err := modulePath(root)
+	if err != nil {
+		return nil, err
+	}
+	g := &generator{
+		root:   root,
+		module: module,
+		pkgs:   map[string]*pkg{},
+		names:  map[*typeDecl]string{},
+		taken:  map[string]bool{},
+	}
+	src := &source{imports: map[string]string{}}
+	for name, dir := range contract.Packages {
+		src.imports[name] = module + "/" + filepath.ToSlash(dir)
+	}
+
+	var b strings.Builder
+	b.WriteString(Header)
+	b.WriteString("//\n// The chat WebSocket messages and chat REST bodies, generated from the Go\n// structs they are encoded from.\n")
+
+	// The envelope takes the message type and data as type parameters
+	envelope, err := g.parseDecl(src, contract.Envelope)
+	if err != nil {
+		return nil, err
+	}
+	st, ok := envelope.spec.Type.(*ast.StructType)
+	if !ok {
+		return nil, fmt.Errorf("envelope %s isn't a struct", contract.Envelope)
+	}
+	envelopeName := envelope.spec.Name.Name
+	g.taken[envelopeName] = true
+	fields, err := g.fields(envelope.src, st)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", contract.Envelope, err)
+	}
+	for i := range fields {
+		switch fields[i].name {
+		case "type":
+			fields[i].ts = "T"
+		case "data":
+			fields[i].ts = "D"
+		}
+		fields[i].comment = ""
+	}
+	writeInterface(&b, envelopeName+"<T extends string = string, D = unknown>", envelope.doc, fields)
+
+	b.WriteString("\n// ServerMessage is any message the server sends on the chat WebSocket\nexport type ServerMessage =")
+	for _, event := range contract.Events {
+		messageType, err := g.messageType(src, event.Type)
+		if err != nil {
+			return nil, err
+		}
+		data, err := g.parseType(src, event.Data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", event.Data, err)
+		}
+		fmt.Fprintf(&b, "\n  | %s<%s, %s>", envelopeName, quote(messageType), data)
+	}
+	b.WriteString(";\n\nexport type ServerMessageType = ServerMessage['type'];\n")
+
+	for _, name := range contract.Types {
+		if _, err := g.parseType(src, name); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	// Writing an interface can use more structs, which join the queue
+	for i := 0; i < len(g.queue); i++ {
+		decl := g.queue[i]
+		fields, err := g.fields(decl.src, decl.spec.Type.(*ast.StructType))
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %w", decl.src.pkg.name, decl.spec.Name.Name, err)
+		}
+		writeInterface(&b, g.names[decl], decl.doc, fields)
+	}
+	return []byte(b.String()), nil
+}
+
+// parseType returns the TypeScript of a Go type written in the contract
+func (g *generator) parseType(src *source, goType string) (string, error) {
+	expr, err := parser.ParseExpr(goType)
+	if err != nil {
+		return "", err
+	}
+	return g.tsType(src, expr)
+}
+
+// parseDecl returns the declaration of a package.Name written in the contract
+func (g *generator) parseDecl(src *source, name string) (*typeDecl, error) {
+	expr, err := parser.ParseExpr(name)
+	if err != nil {
+		return nil, err
+	}
+	decl, external, err := g.resolve(src, expr)
+	if err != nil {
+		return nil, err
+	}
+	if decl == nil {
+		return nil, fmt.Errorf("%s isn't declared in the module (%s)", name, external)
+	}
+	return decl, nil
+}
+
+// messageType returns an event's message type, looking up Go constants
+func (g *generator) messageType(src *source, messageType string) (string, error) {
+	if !strings.Contains(messageType, ".") {
+		return messageType, nil
+	}
+	pkgName, name, _ := strings.Cut(messageType, ".")
+	path, ok := src.imports[pkgName]
+	if !ok {
+		return "", fmt.Errorf("unknown package %s", pkgName)
+	}
+	p, err := g.load(path)
+	if err != nil {
+		return "", err
+	}
+	value, ok := p.consts[name]
+	if !ok {
+		return "", fmt.Errorf("%s isn't a string constant", messageType)
+	}
+	return value, nil
+}
+
+// tsType returns the TypeScript type a Go type encodes to
+func (g *generator) tsType(src *source, expr ast.Expr) (string, error) {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		if ts, ok := basicTypes[t.Name]; ok {
+			return ts, nil
+		}
+	case *ast.StarExpr:
+		return g.tsType(src, t.X)
+	case *ast.ArrayType:
+		if ident, ok := t.Elt.(*ast.Ident); ok && (ident.Name == "byte" || ident.Name == "uint8") {
+			return "string", nil // base64
+		}
+		elem, err := g.tsType(src, t.Elt)
+		if err != nil {
+			return "", err
+		}
+		if strings.Contains(elem, "|") {
+			elem = "(" + elem + ")"
+		}
+		return elem + "[]", nil
+	case *ast.MapType:
+		value, err := g.tsType(src, t.Value)
+		if err != nil {
+			return "", err
+		}
+		return "Record<string, " + value + ">", nil
+	case *ast.InterfaceType:
+		return "unknown", nil
+	case *ast.StructType:
+		fields, err := g.fields(src, t)
+		if err != nil {
+			return "", err
+		}
+		parts := make([]string, len(fields))
+		for i, f := range fields {
+			parts[i] = property(f)
+		}
+		return "{ " + strings.Join(parts, " ") + " }", nil
+	}
+
+	decl, external, err := g.resolve(src, expr)
+	if err != nil {
+		return "", err
+	}
+	if decl == nil {
+		ts, ok := externalTypes[external]
+		if !ok {
+			return "", fmt.Errorf("no TypeScript type for %s", external)
+		}
+		return ts, nil
+	}
+	return g.named(decl)
+}
+
+// named returns the TypeScript of a declared type. Structs become
+// interfaces; other types are written out.
+func (g *generator) named(decl *typeDecl) (string, error) {
+	if name, ok := g.names[decl]; ok {
+		return name, nil
+	}
+	if decl.spec.TypeParams != nil {
+		return "", fmt.Errorf("generic type %s isn't supported", decl.spec.Name.Name)
+	}
+	if _, ok := decl.spec.Type.(*ast.StructType); !ok || decl.spec.Assign.IsValid() {
+		return g.tsType(decl.src, decl.spec.Type)
+	}
+
+	// Names used by another package get the package as a prefix
+	name := decl.spec.Name.Name
+	if g.taken[name] {
+		name = exported(decl.src.pkg.name) + name
+	}
+	g.names[decl] = name
+	g.taken[name] = true
+	g.queue = append(g.queue, decl)
+	return name, nil
+}
+
+// fields returns the JSON fields of a struct in encoding/json's order, the
+// fields of embedded structs where they are embedded unless the struct has a
+// field of the same name
+func (g *generator) fields(src *source, st *ast.StructType) ([]field, error) {
+	var fields []field
+	promoted := map[int][]field{} // by position of the embedded struct
+	own := map[string]bool{}
+	for _, f := range st.Fields.List {
+		name, options := jsonTag(f.Tag)
+		if name == "-" && options == "" {
+			continue
+		}
+		if len(f.Names) == 0 && name == "" {
+			embedded, err := g.embeddedFields(src, f.Type)
+			if err != nil {
+				return nil, err
+			}
+			promoted[len(fields)] = append(promoted[len(fields)], embedded...)
+			continue
+		}
+
+		names := f.Names
+		if len(names) == 0 {
+			names = []*ast.Ident{ast.NewIdent(typeName(f.Type))}
+		}
+		for _, ident := range names {
+			if !ident.IsExported() {
+				continue
+			}
+			out := field{name: ident.Name, comment: strings.TrimSpace(f.Comment.Text())}
+			if name != "" {
+				out.name = name
+			}
+			ts, err := g.tsType(src, f.Type)
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %w", ident.Name, err)
+			}
+			if hasOption(options, "string") {
+				ts = "string"
+			}
+			out.optional = hasOption(options, "omitempty") || hasOption(options, "omitzero")
+			if _, pointer := f.Type.(*ast.StarExpr); pointer && !out.optional {
+				ts += " | null"
+			}
+			out.ts = ts
+			fields = append(fields, out)
+			own[out.name] = true
+		}
+	}
+	if len(promoted) == 0 {
+		return fields, nil
+	}
+
+	all := make([]field, 0, len(fields))
+	seen := map[string]bool{}
+	for i := 0; i <= len(fields); i++ {
+		for _, f := range promoted[i] {
+			if !own[f.name] && !seen[f.name] {
+				all = append(all, f)
+				seen[f.name] = true
+			}
+		}
+		if i < len(fields) {
+			all = append(all, fields[i])
+		}
+	}
+	return all, nil
+}
+
+// embeddedFields returns the fields an embedded struct promotes
+func (g *generator) embeddedFields(src *source, expr ast.Expr) ([]field, error) {
+	if star, ok := expr.(*ast.StarExpr); ok {
+		expr = star.X
+	}
+	decl, external, err := g.resolve(src, expr)
+	if err != nil {
+		return nil, err
+	}
+	if decl == nil {
+		return nil, fmt.Errorf("can't promote the fields of %s", external)
+	}
+	st, ok := decl.spec.Type.(*ast.StructType)
+	if !ok {
+		return g.embeddedFields(decl.src, decl.spec.Type)
+	}
+	return g.fields(decl.src, st)
+}
+
+// resolve finds the declaration a type name refers to, or for types from
+// outside the module their import path and name
+func (g *generator) resolve(src *source, expr ast.Expr) (*typeDecl, string, error) {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		if src.pkg == nil {
+			return nil, "", fmt.Errorf("unknown type %s", t.Name)
+		}
+		decl, ok := src.pkg.types[t.Name]
+		if !ok {
+			return nil, "", fmt.Errorf("unknown type %s.%s", src.pkg.name, t.Name)
+		}
+		return decl, "", nil
+	case *ast.SelectorExpr:
+		pkgIdent, ok := t.X.(*ast.Ident)
+		if !ok {
+			break
+		}
+		path, ok := src.imports[pkgIdent.Name]
+		if !ok {
+			return nil, "", fmt.Errorf("unknown package %s", pkgIdent.Name)
+		}
+		if !strings.HasPrefix(path, g.module+"/") {
+			return nil, path + "." + t.Sel.Name, nil
+		}
+		p, err := g.load(path)
+		if err != nil {
+			return nil, "", err
+		}
+		decl, ok := p.types[t.Sel.Name]
+		if !ok {
+			return nil, "", fmt.Errorf("unknown type %s.%s", p.name, t.Sel.Name)
+		}
+		return decl, "", nil
+	}
+	return nil, "", fmt.Errorf("no TypeScript type for %T", expr)
+}
+
+// load parses a package of the module
+func (g *generator) load(path string) (*pkg, error) {
+	if p, ok := g.pkgs[path]; ok {
+		return p, nil
+	}
+	dir := filepath.Join(g.root, filepath.FromSlash(strings.TrimPrefix(path, g.module+"/")))
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &pkg{types: map[string]*typeDecl{}, consts: map[string]string{}}
+	fset := token.NewFileSet()
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".go") || strings.HasSuffix(entry.Name(), "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(fset, filepath.Join(dir, entry.Name()), nil, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		p.name = file.Name.Name
+		src := &source{pkg: p, imports: map[string]string{}}
+		for _, spec := range file.Imports {
+			importPath, _ := strconv.Unquote(spec.Path.Value)
+			name := importPath[strings.LastIndex(importPath, "/")+1:]
+			if spec.Name != nil {
+				name = spec.Name.Name
+			}
+			src.imports[name] = importPath
+		}
+
+		for _, d := range file.Decls {
+			gen, ok := d.(*ast.GenDecl)
+			if !ok {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				switch spec := spec.(type) {
+				case *ast.TypeSpec:
+					doc := spec.Doc
+					if doc == nil && len(gen.Specs) == 1 {
+						doc = gen.Doc
+					}
+					p.types[spec.Name.Name] = &typeDecl{src: src, spec: spec, doc: doc}
+				case *ast.ValueSpec:
+					if gen.Tok != token.CONST {
+						continue
+					}
+					for i, name := range spec.Names {
+						if i >= len(spec.Values) {
+							break
+						}
+						if lit, ok := spec.Values[i].(*ast.BasicLit); ok && lit.Kind == token.STRING {
+							p.consts[name.Name], _ = strconv.Unquote(lit.Value)
+						}
+					}
+				}
+			}
+		}
+	}
+	g.pkgs[path] = p
+	return p, nil
+}
+
+// writeInterface writes an exported interface with the Go type's doc comment
+func writeInterface(b *strings.Builder, name string, doc *ast.CommentGroup, fields []field) {
+	b.WriteString("\n")
+	if text := strings.TrimSpace(doc.Text()); text != "" {
+		for _, line := range strings.Split(text, "\n") {
+			b.WriteString(strings.TrimRight("// "+line, " ") + "\n")
+		}
+	}
+	fmt.Fprintf(b, "export interface %s {\n", name)
+	for _, f := range fields {
+		b.WriteString("  " + property(f))
+		if f.comment != "" {
+			b.WriteString(" // " + strings.ReplaceAll(f.comment, "\n", " "))
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("}\n")
+}
+
+// property is a field as an interface member
+func property(f field) string {
+	name := f.name
+	if !isIdentifier(name) {
+		name = quote(name)
+	}
+	if f.optional {
+		name += "?"
+	}
+	return name + ": " + f.ts + ";"
+}
+
+// jsonTag returns the name and options of a field's json tag
+func jsonTag(tag *ast.BasicLit) (string, string) {
+	if tag == nil {
+		return "", ""
+	}
+	raw, _ := strconv.Unquote(tag.Value)
+	name, options, _ := strings.Cut(reflect.StructTag(raw).Get("json"), ",")
+	return name, options
+}
+
+func hasOption(options, option string) bool {
+	for _, o := range strings.Split(options, ",") {
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
+
+// typeName is the name of an embedded field's type
+func typeName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return typeName(t.X)
+	case *ast.SelectorExpr:
+		return t.Sel.Name
+	case *ast.Ident:
+		return t.Name
+	}
+	return ""
+}
+
+func isIdentifier(name string) bool {
+	for i, r := range name {
+		if !(r == '_' || r == '$' || unicode.IsLetter(r) || (i > 0 && unicode.IsDigit(r))) {
+			return false
+		}
+	}
+	return name != ""
+}
+
+func quote(s string) string {
+	return "'" + strings.ReplaceAll(strings.ReplaceAll(s, `\`, `\\`), "'", `\'`) + "'"
+}
+
+func exported(name string) string {
+	if name == "" {
+		return name
+	}
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
+// modulePath reads the module path from root's go.mod
+func modulePath(root string) (string, error) {
+	file, err := os.Open(filepath.Join(root, "go.mod"))
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if module, ok := strings.CutPrefix(strings.TrimSpace(scanner.Text()), "module "); ok {
+			return strings.Trim(strings.TrimSpace(module), `"`), nil
+		}
+	}
+	return "", fmt.Errorf("no module path in %s", filepath.Join(root, "go.mod"))
+}
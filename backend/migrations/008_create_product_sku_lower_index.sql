@@ -0,0 +1,4 @@
+-- Migration: Create case-insensitive product SKU index
+-- Description: SKUs differing only in case are the same SKU
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_products_sku_lower ON products(LOWER(sku));
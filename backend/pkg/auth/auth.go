@@ -16,6 +16,7 @@ type Claims struct {
 	Email     string `json:"email"`
 	FirstName string `json:"first_name"`
 	LastName  string `json:"last_name"`
+	SessionID string `json:"sid,omitempty"` // refresh token family the token was issued for
 	jwt.RegisteredClaims
 }
 
@@ -34,7 +35,7 @@ func CheckPasswordHash(password, hash string) bool {
 // GenerateToken generates a JWT token
 func GenerateToken(userID, email, firstName, lastName, secretKey string) (string, error) {
 	expirationTime := time.Now().Add(24 * time.Hour)
-	
+
 	claims := &Claims{
 		UserID:    userID,
 		Email:     email,
@@ -56,7 +57,7 @@ func GenerateToken(userID, email, firstName, lastName, secretKey string) (string
 // GenerateRefreshToken generates a refresh token
 func GenerateRefreshToken(userID, secretKey string) (string, error) {
 	expirationTime := time.Now().Add(7 * 24 * time.Hour) // 7 days
-	
+
 	claims := &Claims{
 		UserID: userID,
 		RegisteredClaims: jwt.RegisteredClaims{
@@ -75,7 +76,7 @@ func GenerateRefreshToken(userID, secretKey string) (string, error) {
 // ValidateToken validates a JWT token
 func ValidateToken(tokenString string) (*Claims, error) {
 	secretKey := getSecretKey()
-	
+
 	claims := &Claims{}
 	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
 		return []byte(secretKey), nil
@@ -0,0 +1,13 @@
+package auth
+
+// Cookie session mode. Browser clients that sign in with the X-Client-Type:
+// web header get their tokens in httpOnly cookies instead of the response
+// body, and must echo the CSRF cookie in the CSRF header on unsafe requests.
+const (
+	ClientTypeHeader   = "X-Client-Type"
+	ClientTypeWeb      = "web"
+	AccessTokenCookie  = "access_token"
+	RefreshTokenCookie = "refresh_token"
+	CSRFCookie         = "csrf_token"
+	CSRFHeader         = "X-CSRF-Token"
+)
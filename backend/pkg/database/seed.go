@@ -16,9 +16,11 @@ func MigrateDatabase(db *gorm.DB) error {
 
 	err := db.AutoMigrate(
 		&models.Category{},
+		&models.Brand{},
 		&models.Product{},
 		&models.ProductVariant{},
 		&models.ProductImage{},
+		&models.CategorySlugRedirect{},
 		&models.Inventory{},
 		&models.InventoryReservation{},
 		&models.User{},
@@ -27,21 +29,78 @@ func MigrateDatabase(db *gorm.DB) error {
 		&models.ShoppingCart{},
 		&models.Order{},
 		&models.OrderItem{},
+		&models.StoreSettings{},
+		&models.ChatAnalytics{},
+		&models.ChatEvent{},
+		&models.StorefrontEvent{},
+		&models.SessionLink{},
+		&models.SocialProofSettings{},
+		&models.FlashSale{},
+		&models.FlashSaleShopper{},
+		&models.ChatTokenUsage{},
+		&models.PromptTemplate{},
+		&models.PricingRule{},
+		&models.Segment{},
+		&models.SegmentMembership{},
+		&models.Quote{},
+		&models.QuoteItem{},
+		&models.CustomerGroup{},
+		&models.GroupPrice{},
+		&models.OrderRule{},
+		&models.Fulfillment{},
+		&models.PaymentRetry{},
+		&models.OrderExport{},
+		&models.OrderSubmission{},
+		&models.PaymentTransaction{},
+		&models.PayoutReconciliation{},
+		&models.AccountingExport{},
+		&models.PriceHistory{},
+		&models.ProductChangeRequest{},
+		&models.ProductRevision{},
+		&models.SearchSynonym{},
+		&models.SearchMiss{},
+		&models.SearchBoost{},
+		&models.PinnedSearchResult{},
+		&models.ProductQuestion{},
+		&models.LoginAttempt{},
+		&models.SecurityNotification{},
+		&models.ConsentRecord{},
+		&models.OutboundMessage{},
+		&models.DemandForecast{},
+		&models.AdminAssistantAction{},
+		&models.BroadcastCampaign{},
+		&models.CampaignDelivery{},
+		&models.StoreHours{},
+		&models.EscalationMessage{},
+		&models.APIUsageBucket{},
+		&models.MaintenanceWindow{},
+		&models.BlockedRequest{},
+		&models.AsyncJob{},
 	)
 
 	if err != nil {
 		return err
 	}
 
-	// Run auth-specific migrations (Session and PasswordResetToken only, User already migrated above)
+	// Run auth-specific migrations (Session, PasswordResetToken, AccountUnlockToken and RefreshToken only, User already migrated above)
 	err = db.AutoMigrate(
 		&authmodels.Session{},
 		&authmodels.PasswordResetToken{},
+		&authmodels.AccountUnlockToken{},
+		&authmodels.RefreshToken{},
 	)
 	if err != nil {
 		return err
 	}
 
+	// Semantic product search stores vectors with pgvector; without the
+	// extension the chat keeps ranking suggestions by keywords
+	if err := db.Exec("CREATE EXTENSION IF NOT EXISTS vector").Error; err != nil {
+		log.Printf("pgvector is not available, semantic product search is disabled: %v", err)
+	} else if err := db.AutoMigrate(&models.ProductEmbedding{}); err != nil {
+		return err
+	}
+
 	log.Println("Database migrations completed successfully")
 	return nil
 }
@@ -50,6 +109,10 @@ func MigrateDatabase(db *gorm.DB) error {
 func SeedDatabase(db *gorm.DB) error {
 	log.Println("Seeding database...")
 
+	if err := seedCustomerGroups(db); err != nil {
+		return err
+	}
+
 	// Check if products already exist
 	var productCount int64
 	db.Model(&models.Product{}).Count(&productCount)
@@ -317,3 +380,20 @@ func SeedDatabase(db *gorm.DB) error {
 	log.Println("Database seeded successfully")
 	return nil
 }
+
+// seedCustomerGroups creates the standard customer groups if they are missing
+func seedCustomerGroups(db *gorm.DB) error {
+	groups := []models.CustomerGroup{
+		{Slug: "retail", Name: "Retail", Description: "Default pricing for guests and consumers", IsDefault: true},
+		{Slug: "wholesale", Name: "Wholesale", Description: "Trade customers buying in volume", AdjustmentPercent: -15},
+		{Slug: "vip", Name: "VIP", Description: "Loyal customers", AdjustmentPercent: -5},
+	}
+
+	for _, group := range groups {
+		group.ID = uuid.New()
+		if err := db.Where(models.CustomerGroup{Slug: group.Slug}).FirstOrCreate(&group).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// prefix marks encrypted values, followed by the key ID and the base64
+// nonce and ciphertext: "pii:v1:<key id>:<data>"
+const prefix = "pii:v1:"
+
+// ErrUnknownKey is returned for values encrypted with a key that isn't in the keyring
+var ErrUnknownKey = errors.New("value was encrypted with an unknown key")
+
+// Keyring encrypts with its current key and decrypts with any of its keys,
+// so keys can be rotated by adding a new current key, re-encrypting the
+// stored values and then dropping the old key
+type Keyring struct {
+	current string
+	keys    map[string]cipher.AEAD
+}
+
+// NewKeyring builds a keyring from comma separated id:base64key entries of
+// 32 byte AES-256 keys. The first entry is the current key.
+func NewKeyring(spec string) (*Keyring, error) {
+	keyring := &Keyring{keys: map[string]cipher.AEAD{}}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		id, encoded, found := strings.Cut(entry, ":")
+		if !found || id == "" {
+			return nil, fmt.Errorf("invalid key entry %q, expected id:base64key", entry)
+		}
+		if _, exists := keyring.keys[id]; exists {
+			return nil, fmt.Errorf("duplicate key id %q", id)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("key %q must be 32 bytes, base64 encoded", id)
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key %q: %v", id, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key %q: %v", id, err)
+		}
+		keyring.keys[id] = aead
+		if keyring.current == "" {
+			keyring.current = id
+		}
+	}
+	if keyring.current == "" {
+		return nil, errors.New("no keys given")
+	}
+	return keyring, nil
+}
+
+// KeyringFromEnv builds the keyring from PII_ENCRYPTION_KEYS, returning nil
+// when it isn't set
+func KeyringFromEnv() (*Keyring, error) {
+	spec := os.Getenv("PII_ENCRYPTION_KEYS")
+	if strings.TrimSpace(spec) == "" {
+		return nil, nil
+	}
+	return NewKeyring(spec)
+}
+
+// CurrentKeyID returns the ID of the key new values are encrypted with
+func (k *Keyring) CurrentKeyID() string {
+	return k.current
+}
+
+// Encrypt seals plaintext with the current key. The associated data, such
+// as the column name, must be passed again to decrypt.
+func (k *Keyring) Encrypt(plaintext, associatedData []byte) (string, error) {
+	aead := k.keys[k.current]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %v", err)
+	}
+	sealed := aead.Seal(nonce, nonce, plaintext, associatedData)
+	return prefix + k.current + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt opens a value returned by Encrypt
+func (k *Keyring) Decrypt(value string, associatedData []byte) ([]byte, error) {
+	id, data, err := split(value)
+	if err != nil {
+		return nil, err
+	}
+	aead, ok := k.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownKey, id)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(data)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return nil, errors.New("malformed encrypted value")
+	}
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], associatedData)
+	if err != nil {
+		return nil, errors.New("failed to decrypt value")
+	}
+	return plaintext, nil
+}
+
+// NeedsRotation reports whether a stored value isn't encrypted with the
+// current key. Empty values have nothing to encrypt.
+func (k *Keyring) NeedsRotation(value string) bool {
+	if value == "" {
+		return false
+	}
+	id, _, err := split(value)
+	return err != nil || id != k.current
+}
+
+// IsEncrypted reports whether a stored value was produced by Encrypt
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+// split returns the key ID and data of an encrypted value
+func split(value string) (string, string, error) {
+	if !IsEncrypted(value) {
+		return "", "", errors.New("value is not encrypted")
+	}
+	id, data, found := strings.Cut(strings.TrimPrefix(value, prefix), ":")
+	if !found {
+		return "", "", errors.New("malformed encrypted value")
+	}
+	return id, data, nil
+}
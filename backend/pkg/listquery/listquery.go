@@ -0,0 +1,522 @@
+// Package listquery reads the query string shared by the API's list
+// endpoints:
+//
+//	filter[status]=active              equals
+//	filter[price][gte]=10              ne, gt, gte, lt, lte
+//	filter[status][in]=active,draft    any of the values
+//	filter[name][contains]=lamp        case-insensitive substring
+//	sort=-created_at,name              "-" sorts descending
+//	page[number]=2&page[size]=20
+//
+// Each endpoint declares the fields it can be filtered and sorted by in a
+// Schema. Anything else is rejected, and columns only come from the schema
+// while values are always bound parameters, so a request can't inject SQL.
+package listquery
+
+import (
+	"chat-ecommerce-backend/pkg/validation"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Type is the kind of value a field holds
+type Type int
+
+const (
+	String Type = iota
+	Number
+	Bool
+	Time // RFC 3339 timestamps or dates like 2006-01-02
+	UUID
+)
+
+// Op is a filter operator
+type Op string
+
+const (
+	Eq       Op = "eq"
+	Ne       Op = "ne"
+	Gt       Op = "gt"
+	Gte      Op = "gte"
+	Lt       Op = "lt"
+	Lte      Op = "lte"
+	In       Op = "in"
+	Contains Op = "contains"
+)
+
+// maxInValues caps the values of an in filter
+const maxInValues = 100
+
+// dateLayout is the layout of date-only time values
+const dateLayout = "2006-01-02"
+
+// defaultOps are the operators a field accepts when it doesn't list its own
+var defaultOps = map[Type][]Op{
+	String: {Eq, Ne, In},
+	Number: {Eq, Ne, Gt, Gte, Lt, Lte, In},
+	Bool:   {Eq},
+	Time:   {Gte, Lte, Gt, Lt},
+	UUID:   {Eq, Ne, In},
+}
+
+var (
+	filterParam = regexp.MustCompile(`^filter\[([a-z0-9_]+)\](?:\[([a-z]+)\])?$`)
+	aliasTarget = regexp.MustCompile(`^([a-z0-9_]+)(?:\[([a-z]+)\])?$`)
+)
+
+// Field is a filterable field of a list
+type Field struct {
+	Columns []string // several columns are matched together, any of them matching
+	Type    Type
+	Ops     []Op     // accepted operators, the first being used when none is given
+	Values  []string // when set, the only accepted values
+}
+
+// Column is a field on a single column. Without ops it accepts the
+// defaults for its type.
+func Column(column string, t Type, ops ...Op) Field {
+	return Field{Columns: []string{column}, Type: t, Ops: ops}
+}
+
+// Search is a text field matching a substring of any of the columns
+func Search(columns ...string) Field {
+	return Field{Columns: columns, Type: String, Ops: []Op{Contains}}
+}
+
+// OneOf limits a field to the given values
+func (f Field) OneOf(values ...string) Field {
+	f.Values = values
+	return f
+}
+
+func (f Field) ops() []Op {
+	if len(f.Ops) > 0 {
+		return f.Ops
+	}
+	return defaultOps[f.Type]
+}
+
+func (f Field) allows(op Op) bool {
+	for _, allowed := range f.ops() {
+		if allowed == op {
+			return true
+		}
+	}
+	return false
+}
+
+// Schema declares what a list can be filtered and sorted by
+type Schema struct {
+	Filters     map[string]Field
+	Sorts       map[string]string // sort key to column
+	DefaultSort string            // e.g. "-created_at"
+	TieBreaker  string            // column sorted on last, so rows with equal keys don't move between pages
+	DefaultSize int
+	MaxSize     int
+
+	// Aliases keep the parameters a list had before this package working,
+	// mapping them to a filter, e.g. "min_price": "price[gte]"
+	Aliases map[string]string
+}
+
+func (s *Schema) defaultSize() int {
+	if s.DefaultSize > 0 {
+		return s.DefaultSize
+	}
+	return 20
+}
+
+func (s *Schema) maxSize() int {
+	if s.MaxSize > 0 {
+		return s.MaxSize
+	}
+	return 100
+}
+
+// Condition is one filter of a query
+type Condition struct {
+	Field string
+	Op    Op
+	Value interface{} // []interface{} for In
+}
+
+// Sort is one sort key of a query
+type Sort struct {
+	Key  string
+	Desc bool
+}
+
+// Query is a validated list request
+type Query struct {
+	schema     *Schema
+	Conditions []Condition
+	Sorts      []Sort
+	Page       int
+	Size       int
+}
+
+// PageInfo describes the page of a list that was returned
+type PageInfo struct {
+	Page        int   `json:"page"`
+	Size        int   `json:"size"`
+	Total       int64 `json:"total"`
+	TotalPages  int   `json:"total_pages"`
+	HasNext     bool  `json:"has_next"`
+	HasPrevious bool  `json:"has_previous"`
+}
+
+// New returns the schema's first page in its default order, unfiltered
+func New(schema *Schema) *Query {
+	q := &Query{schema: schema, Page: 1, Size: schema.defaultSize()}
+	q.Sorts = parseDefaultSort(schema)
+	return q
+}
+
+func parseDefaultSort(schema *Schema) []Sort {
+	var sorts []Sort
+	for _, key := range strings.Split(schema.DefaultSort, ",") {
+		key = strings.TrimSpace(key)
+		desc := strings.HasPrefix(key, "-")
+		key = strings.TrimPrefix(key, "-")
+		if _, ok := schema.Sorts[key]; ok {
+			sorts = append(sorts, Sort{Key: key, Desc: desc})
+		}
+	}
+	return sorts
+}
+
+// Parse reads a list request's query string. Parameters unrelated to
+// listing are ignored; everything wrong with the listing ones is returned
+// together as validation.ValidationErrors.
+//
+// The older page, limit, sort_by and sort_order parameters are read when
+// the newer ones are absent.
+func Parse(schema *Schema, values url.Values) (*Query, error) {
+	q := New(schema)
+	var errs validation.ValidationErrors
+	fail := func(param, format string, args ...interface{}) {
+		errs = append(errs, validation.ValidationError{Field: param, Message: fmt.Sprintf(format, args...)})
+	}
+
+	params := make([]string, 0, len(values))
+	for param := range values {
+		params = append(params, param)
+	}
+	sort.Strings(params) // report errors in a stable order
+
+	for _, param := range params {
+		var name, op string
+		if m := filterParam.FindStringSubmatch(param); m != nil {
+			name, op = m[1], m[2]
+		} else if alias, ok := schema.Aliases[param]; ok {
+			m := aliasTarget.FindStringSubmatch(alias)
+			if m == nil {
+				fail(param, "is not a valid alias")
+				continue
+			}
+			name, op = m[1], m[2]
+		} else {
+			if strings.HasPrefix(param, "filter[") {
+				fail(param, "is not a valid filter, use filter[field] or filter[field][operator]")
+			}
+			continue
+		}
+
+		raw := strings.TrimSpace(values.Get(param))
+		if raw == "" {
+			continue
+		}
+		condition, err := schema.condition(name, Op(op), raw)
+		if err != nil {
+			fail(param, "%v", err)
+			continue
+		}
+		q.Conditions = append(q.Conditions, condition)
+	}
+
+	sortParam, sortName := values.Get("sort"), "sort"
+	if sortParam == "" && values.Get("sort_by") != "" {
+		sortParam, sortName = values.Get("sort_by"), "sort_by"
+		if !strings.EqualFold(values.Get("sort_order"), "asc") {
+			sortParam = "-" + sortParam
+		}
+	}
+	if sortParam != "" {
+		q.Sorts = nil
+		seen := map[string]bool{}
+		for _, key := range strings.Split(sortParam, ",") {
+			key = strings.TrimSpace(key)
+			desc := strings.HasPrefix(key, "-")
+			key = strings.TrimPrefix(key, "-")
+			if _, ok := schema.Sorts[key]; !ok {
+				fail(sortName, "can't sort by %q, use one of %s", key, strings.Join(sortedKeys(schema.Sorts), ", "))
+				continue
+			}
+			if !seen[key] {
+				seen[key] = true
+				q.Sorts = append(q.Sorts, Sort{Key: key, Desc: desc})
+			}
+		}
+	}
+
+	for _, p := range []struct {
+		params []string
+		target *int
+	}{
+		{[]string{"page[number]", "page"}, &q.Page},
+		{[]string{"page[size]", "limit"}, &q.Size},
+	} {
+		for _, param := range p.params {
+			raw := values.Get(param)
+			if raw == "" {
+				continue
+			}
+			n, err := strconv.Atoi(raw)
+			if err != nil || n < 1 {
+				fail(param, "must be a whole number of at least 1")
+			} else {
+				*p.target = n
+			}
+			break
+		}
+	}
+	if q.Size > schema.maxSize() {
+		q.Size = schema.maxSize()
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return q, nil
+}
+
+// condition validates a filter value for a field
+func (s *Schema) condition(name string, op Op, raw string) (Condition, error) {
+	field, ok := s.Filters[name]
+	if !ok {
+		return Condition{}, fmt.Errorf("can't filter by %q, use one of %s", name, strings.Join(sortedKeys(s.Filters), ", "))
+	}
+	if op == "" {
+		op = field.ops()[0]
+	}
+	if !field.allows(op) {
+		return Condition{}, fmt.Errorf("%s can't be filtered with %q, use one of %s", name, op, joinOps(field.ops()))
+	}
+
+	if op == In {
+		parts := strings.Split(raw, ",")
+		if len(parts) > maxInValues {
+			return Condition{}, fmt.Errorf("can list at most %d values", maxInValues)
+		}
+		values := make([]interface{}, 0, len(parts))
+		for _, part := range parts {
+			value, err := field.parse(strings.TrimSpace(part))
+			if err != nil {
+				return Condition{}, err
+			}
+			values = append(values, value)
+		}
+		return Condition{Field: name, Op: In, Value: values}, nil
+	}
+	if op == Contains {
+		return Condition{Field: name, Op: Contains, Value: likePattern(raw)}, nil
+	}
+
+	value, err := field.parse(raw)
+	if err != nil {
+		return Condition{}, err
+	}
+
+	// A date covers the whole day: "up to the 31st" includes the 31st
+	if date, ok := value.(time.Time); ok && len(raw) == len(dateLayout) {
+		switch op {
+		case Lte:
+			op, value = Lt, date.AddDate(0, 0, 1)
+		case Gt:
+			op, value = Gte, date.AddDate(0, 0, 1)
+		}
+	}
+	return Condition{Field: name, Op: op, Value: value}, nil
+}
+
+// parse converts a raw value to the field's type
+func (f Field) parse(raw string) (interface{}, error) {
+	if len(f.Values) > 0 {
+		allowed := false
+		for _, value := range f.Values {
+			allowed = allowed || value == raw
+		}
+		if !allowed {
+			return nil, fmt.Errorf("%q isn't one of %s", raw, strings.Join(f.Values, ", "))
+		}
+	}
+
+	switch f.Type {
+	case Number:
+		n, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q isn't a number", raw)
+		}
+		return n, nil
+	case Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%q isn't true or false", raw)
+		}
+		return b, nil
+	case Time:
+		if t, err := time.Parse(time.RFC3339, raw); err == nil {
+			return t, nil
+		}
+		t, err := time.Parse(dateLayout, raw)
+		if err != nil {
+			return nil, fmt.Errorf("%q isn't a date like 2006-01-02 or an RFC 3339 time", raw)
+		}
+		return t, nil
+	case UUID:
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%q isn't a valid ID", raw)
+		}
+		return id, nil
+	default:
+		return raw, nil
+	}
+}
+
+// Where adds a filter from code rather than the request, such as an
+// endpoint's own fixed filter. It panics if the schema doesn't declare the
+// field and operator, which is a programming error.
+func (q *Query) Where(field string, op Op, value interface{}) *Query {
+	f, ok := q.schema.Filters[field]
+	if !ok || !f.allows(op) {
+		panic(fmt.Sprintf("listquery: %s doesn't accept %s", field, op))
+	}
+	if text, ok := value.(string); ok && op == Contains {
+		value = likePattern(text)
+	}
+	q.Conditions = append(q.Conditions, Condition{Field: field, Op: op, Value: value})
+	return q
+}
+
+// SortBy replaces the query's order with key, keeping the current order
+// when the schema can't sort by key
+func (q *Query) SortBy(key string, desc bool) *Query {
+	if _, ok := q.schema.Sorts[key]; ok {
+		q.Sorts = []Sort{{Key: key, Desc: desc}}
+	}
+	return q
+}
+
+// SetPage sets the page and its size, keeping the defaults for values
+// below 1 and capping the size at the schema's maximum
+func (q *Query) SetPage(page, size int) *Query {
+	if page >= 1 {
+		q.Page = page
+	}
+	if size >= 1 {
+		q.Size = size
+	}
+	if q.Size > q.schema.maxSize() {
+		q.Size = q.schema.maxSize()
+	}
+	return q
+}
+
+// Filter narrows db to the rows matching every condition
+func (q *Query) Filter(db *gorm.DB) *gorm.DB {
+	for _, c := range q.Conditions {
+		field := q.schema.Filters[c.Field]
+		clauses := make([]string, 0, len(field.Columns))
+		args := make([]interface{}, 0, len(field.Columns))
+		for _, column := range field.Columns {
+			switch c.Op {
+			case Contains:
+				clauses = append(clauses, fmt.Sprintf(`LOWER(%s) LIKE ? ESCAPE '\'`, column))
+			case In:
+				clauses = append(clauses, column+" IN ?")
+			default:
+				clauses = append(clauses, column+" "+sqlOperators[c.Op]+" ?")
+			}
+			args = append(args, c.Value)
+		}
+		db = db.Where("("+strings.Join(clauses, " OR ")+")", args...)
+	}
+	return db
+}
+
+// sqlOperators are the comparison operators' SQL
+var sqlOperators = map[Op]string{Eq: "=", Ne: "<>", Gt: ">", Gte: ">=", Lt: "<", Lte: "<="}
+
+// Order sorts db by the query's sort keys, then the schema's tie breaker
+func (q *Query) Order(db *gorm.DB) *gorm.DB {
+	tieBroken := false
+	for _, s := range q.Sorts {
+		column := q.schema.Sorts[s.Key]
+		direction := "ASC"
+		if s.Desc {
+			direction = "DESC"
+		}
+		db = db.Order(column + " " + direction)
+		tieBroken = tieBroken || column == q.schema.TieBreaker
+	}
+	if q.schema.TieBreaker != "" && !tieBroken {
+		db = db.Order(q.schema.TieBreaker + " ASC")
+	}
+	return db
+}
+
+// Paginate limits db to the query's page
+func (q *Query) Paginate(db *gorm.DB) *gorm.DB {
+	return db.Offset(q.Offset()).Limit(q.Size)
+}
+
+// Offset is the number of rows before the query's page
+func (q *Query) Offset() int {
+	return (q.Page - 1) * q.Size
+}
+
+// PageInfo describes the query's page of a list of total rows
+func (q *Query) PageInfo(total int64) PageInfo {
+	totalPages := int((total + int64(q.Size) - 1) / int64(q.Size))
+	return PageInfo{
+		Page:        q.Page,
+		Size:        q.Size,
+		Total:       total,
+		TotalPages:  totalPages,
+		HasNext:     q.Page < totalPages,
+		HasPrevious: q.Page > 1,
+	}
+}
+
+// likePattern matches text anywhere in a lowercased column, escaping the
+// LIKE wildcards so they're matched literally
+func likePattern(text string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(strings.ToLower(text))
+	return "%" + escaped + "%"
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func joinOps(ops []Op) string {
+	names := make([]string, len(ops))
+	for i, op := range ops {
+		names[i] = string(op)
+	}
+	return strings.Join(names, ", ")
+}
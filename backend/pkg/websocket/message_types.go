@@ -79,6 +79,8 @@ type WebSocketMessage struct {
 	Metadata    map[string]interface{} `json:"metadata,omitempty"`
 	RequiresAck bool                   `json:"requires_ack,omitempty"`
 	AckID       string                 `json:"ack_id,omitempty"`
+	Nonce       string                 `json:"nonce,omitempty"`     // required on critical mutations, see ReplayGuard
+	Signature   string                 `json:"signature,omitempty"` // hex HMAC of a critical mutation, see SignMessage
 }
 
 // NewWebSocketMessage creates a new WebSocket message
@@ -328,6 +330,26 @@ func (mb *MessageBuilder) RequireAck(ackID string) *MessageBuilder {
 	return mb
 }
 
+// WithNonce gives the message a fresh nonce and timestamp, as critical
+// mutations need
+func (mb *MessageBuilder) WithNonce() *MessageBuilder {
+	nonce, err := NewNonce()
+	if err == nil {
+		mb.msg.Nonce = nonce
+	}
+	mb.msg.Timestamp = time.Now()
+	return mb
+}
+
+// Signed signs the message for a session with its signing key. Call it last,
+// after the data is set.
+func (mb *MessageBuilder) Signed(key []byte, sessionID string) *MessageBuilder {
+	if signature, err := SignMessage(key, sessionID, mb.msg); err == nil {
+		mb.msg.Signature = signature
+	}
+	return mb
+}
+
 // Build returns the constructed message
 func (mb *MessageBuilder) Build() *WebSocketMessage {
 	return mb.msg
@@ -353,6 +375,11 @@ func (mf *MessageFactory) CreateChatMessage(content string, messageType string,
 
 // CreateCartUpdate creates a cart update message
 func (mf *MessageFactory) CreateCartUpdate(sessionID string, userID *uuid.UUID, items []CartItemData, total float64) *WebSocketMessage {
+	// Always send an array so clients never receive "items": null
+	if items == nil {
+		items = []CartItemData{}
+	}
+
 	data := map[string]interface{}{
 		"session_id": sessionID,
 		"items":      items,
@@ -0,0 +1,173 @@
+package websocket
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultReplayWindow is how far a mutation's timestamp may be from the
+// server's clock, and how long its nonce is remembered
+const DefaultReplayWindow = 30 * time.Second
+
+// Nonce length limits, in characters
+const (
+	minNonceLength = 16
+	maxNonceLength = 128
+)
+
+// CriticalMutationTypes are the inbound messages that change state and must
+// not be replayed
+var CriticalMutationTypes = map[MessageType]bool{
+	MessageTypeCartAdd:    true,
+	MessageTypeCartRemove: true,
+	MessageTypeCartClear:  true,
+}
+
+// ReplayError is a critical mutation the ReplayGuard refused
+type ReplayError struct {
+	Code   string // "missing_nonce", "stale_message", "replayed_message" or "invalid_signature"
+	Reason string
+}
+
+// Error implements the error interface
+func (e *ReplayError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Reason)
+}
+
+// ReplayGuard refuses critical mutations that were already received, whose
+// timestamp is outside the window, and, when signing is on, whose HMAC
+// doesn't match the session's key. A message captured from a connection
+// can't be sent again to flood the cart.
+type ReplayGuard struct {
+	window           time.Duration
+	secret           []byte // derives each session's signing key; nil turns signing off
+	requireSignature bool
+	critical         map[MessageType]bool
+
+	seen      map[string]time.Time // session and nonce -> when it's forgotten
+	lastPrune time.Time
+	mu        sync.Mutex
+}
+
+// NewReplayGuard creates a replay guard. With a secret, signed mutations are
+// checked and each session is given its signing key on auth; requireSignature
+// also refuses unsigned ones.
+func NewReplayGuard(window time.Duration, secret []byte, requireSignature bool) *ReplayGuard {
+	if window <= 0 {
+		window = DefaultReplayWindow
+	}
+	critical := make(map[MessageType]bool, len(CriticalMutationTypes))
+	for msgType := range CriticalMutationTypes {
+		critical[msgType] = true
+	}
+	return &ReplayGuard{
+		window:           window,
+		secret:           secret,
+		requireSignature: requireSignature && len(secret) > 0,
+		critical:         critical,
+		seen:             make(map[string]time.Time),
+	}
+}
+
+// WithCriticalTypes also guards the given message types, for protocols whose
+// mutations aren't the CriticalMutationTypes
+func (g *ReplayGuard) WithCriticalTypes(types ...MessageType) *ReplayGuard {
+	for _, msgType := range types {
+		g.critical[msgType] = true
+	}
+	return g
+}
+
+// Signing reports whether the guard checks signatures
+func (g *ReplayGuard) Signing() bool {
+	return len(g.secret) > 0
+}
+
+// SigningKey returns the hex HMAC key a session signs its mutations with
+func (g *ReplayGuard) SigningKey(sessionID string) string {
+	mac := hmac.New(sha256.New, g.secret)
+	mac.Write([]byte(sessionID))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Check refuses a replayed, stale, unsigned or forged critical mutation from
+// a session's connection. Other messages always pass.
+func (g *ReplayGuard) Check(sessionID string, msg *WebSocketMessage, now time.Time) error {
+	if !g.critical[msg.Type] {
+		return nil
+	}
+	if len(msg.Nonce) < minNonceLength || len(msg.Nonce) > maxNonceLength {
+		return &ReplayError{Code: "missing_nonce", Reason: fmt.Sprintf("nonce must be %d to %d characters", minNonceLength, maxNonceLength)}
+	}
+	if msg.Timestamp.IsZero() {
+		return &ReplayError{Code: "stale_message", Reason: "timestamp is required"}
+	}
+	if skew := now.Sub(msg.Timestamp); skew > g.window || skew < -g.window {
+		return &ReplayError{Code: "stale_message", Reason: fmt.Sprintf("timestamp is more than %s from the server's clock", g.window)}
+	}
+
+	if g.Signing() && (msg.Signature != "" || g.requireSignature) {
+		key, _ := hex.DecodeString(g.SigningKey(sessionID))
+		expected, err := SignMessage(key, sessionID, msg)
+		if err != nil {
+			return err
+		}
+		if !hmac.Equal([]byte(expected), []byte(msg.Signature)) {
+			return &ReplayError{Code: "invalid_signature", Reason: "signature doesn't match the message"}
+		}
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.prune(now)
+	key := sessionID + "\x00" + msg.Nonce
+	if _, ok := g.seen[key]; ok {
+		return &ReplayError{Code: "replayed_message", Reason: "nonce was already used"}
+	}
+	// A nonce is remembered for as long as its timestamp is accepted
+	g.seen[key] = msg.Timestamp.Add(g.window)
+	return nil
+}
+
+// prune forgets nonces whose messages are stale anyway, at most once per window
+func (g *ReplayGuard) prune(now time.Time) {
+	if now.Sub(g.lastPrune) < g.window {
+		return
+	}
+	for key, forgetAt := range g.seen {
+		if now.After(forgetAt) {
+			delete(g.seen, key)
+		}
+	}
+	g.lastPrune = now
+}
+
+// SignMessage returns the hex HMAC-SHA256 of a mutation for a session: its
+// type, nonce, timestamp in Unix milliseconds, session ID and data as JSON
+// with sorted keys, each on its own line
+func SignMessage(key []byte, sessionID string, msg *WebSocketMessage) (string, error) {
+	data, err := json.Marshal(msg.Data)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode message data: %w", err)
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(string(msg.Type) + "\n" + msg.Nonce + "\n" + strconv.FormatInt(msg.Timestamp.UnixMilli(), 10) + "\n" + sessionID + "\n"))
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// NewNonce returns a random nonce for a mutation
+func NewNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %v", err)
+	}
+	return hex.EncodeToString(b), nil
+}
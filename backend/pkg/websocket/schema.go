@@ -0,0 +1,288 @@
+package websocket
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"math"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+//go:embed schemas/*.json
+var schemaFiles embed.FS
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// AllMessageTypes lists every message type known to the protocol
+var AllMessageTypes = []MessageType{
+	MessageTypeConnect, MessageTypeDisconnect, MessageTypePing, MessageTypePong,
+	MessageTypeAuth, MessageTypeAuthSuccess, MessageTypeAuthError,
+	MessageTypeChatMessage, MessageTypeChatResponse, MessageTypeChatTyping,
+	MessageTypeCartUpdate, MessageTypeCartSync, MessageTypeCartAdd, MessageTypeCartRemove, MessageTypeCartClear,
+	MessageTypeInventoryUpdate, MessageTypeInventoryAlert, MessageTypeInventorySync,
+	MessageTypeOrderUpdate, MessageTypeOrderStatus, MessageTypeOrderCreated, MessageTypeOrderCompleted,
+	MessageTypeNotification, MessageTypeSystemAlert, MessageTypeUserAlert,
+	MessageTypeError, MessageTypeValidationError,
+}
+
+// Schema is the subset of JSON Schema (draft-07) used to describe message contracts
+type Schema struct {
+	Title      string             `json:"title,omitempty"`
+	Type       string             `json:"type,omitempty"`
+	Required   []string           `json:"required,omitempty"`
+	Properties map[string]*Schema `json:"properties,omitempty"`
+	Items      *Schema            `json:"items,omitempty"`
+	Enum       []interface{}      `json:"enum,omitempty"`
+	AnyOf      []*Schema          `json:"anyOf,omitempty"`
+	Format     string             `json:"format,omitempty"` // "uuid", "date-time"
+	MinLength  *int               `json:"minLength,omitempty"`
+	Minimum    *float64           `json:"minimum,omitempty"`
+	Maximum    *float64           `json:"maximum,omitempty"`
+}
+
+// SchemaValidationError lists every violation found while validating a message
+type SchemaValidationError struct {
+	MessageType MessageType
+	Errors      []string
+}
+
+// Error implements the error interface
+func (e *SchemaValidationError) Error() string {
+	return fmt.Sprintf("message %q failed schema validation: %s", e.MessageType, strings.Join(e.Errors, "; "))
+}
+
+// SchemaRegistry holds the JSON Schema for the envelope and each message type's data
+type SchemaRegistry struct {
+	envelope *Schema
+	schemas  map[MessageType]*Schema
+}
+
+// NewSchemaRegistry creates a registry loaded with the embedded message schemas
+func NewSchemaRegistry() (*SchemaRegistry, error) {
+	registry := &SchemaRegistry{
+		schemas: make(map[MessageType]*Schema),
+	}
+
+	entries, err := schemaFiles.ReadDir("schemas")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schemas: %w", err)
+	}
+
+	for _, entry := range entries {
+		raw, err := schemaFiles.ReadFile(path.Join("schemas", entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read schema %s: %w", entry.Name(), err)
+		}
+
+		var schema Schema
+		if err := json.Unmarshal(raw, &schema); err != nil {
+			return nil, fmt.Errorf("failed to parse schema %s: %w", entry.Name(), err)
+		}
+
+		name := strings.TrimSuffix(entry.Name(), ".json")
+		if name == "envelope" {
+			registry.envelope = &schema
+			continue
+		}
+		registry.schemas[MessageType(name)] = &schema
+	}
+
+	return registry, nil
+}
+
+// Register sets or replaces the data schema for a message type
+func (r *SchemaRegistry) Register(msgType MessageType, schema *Schema) {
+	r.schemas[msgType] = schema
+}
+
+// Schema returns the data schema for a message type
+func (r *SchemaRegistry) Schema(msgType MessageType) (*Schema, bool) {
+	schema, ok := r.schemas[msgType]
+	return schema, ok
+}
+
+// Types returns all message types with a registered schema, sorted
+func (r *SchemaRegistry) Types() []MessageType {
+	types := make([]MessageType, 0, len(r.schemas))
+	for t := range r.schemas {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+	return types
+}
+
+// Validate checks a message against the envelope schema and its type's data schema
+func (r *SchemaRegistry) Validate(msg *WebSocketMessage) error {
+	raw, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+	return r.ValidateJSON(raw)
+}
+
+// ValidateJSON checks raw message JSON against the envelope schema and its type's data schema
+func (r *SchemaRegistry) ValidateJSON(raw []byte) error {
+	var doc map[string]interface{}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return &SchemaValidationError{Errors: []string{fmt.Sprintf("invalid JSON: %v", err)}}
+	}
+
+	msgType, _ := doc["type"].(string)
+	var errs []string
+	if r.envelope != nil {
+		errs = append(errs, validateValue(r.envelope, doc, "")...)
+	}
+
+	schema, ok := r.schemas[MessageType(msgType)]
+	if !ok {
+		errs = append(errs, fmt.Sprintf("type: no schema registered for %q", msgType))
+	} else if data, exists := doc["data"]; exists {
+		errs = append(errs, validateValue(schema, data, "data")...)
+	}
+
+	if len(errs) > 0 {
+		return &SchemaValidationError{MessageType: MessageType(msgType), Errors: errs}
+	}
+	return nil
+}
+
+// validateValue validates a decoded JSON value against a schema, returning all violations
+func validateValue(schema *Schema, value interface{}, at string) []string {
+	var errs []string
+	fail := func(format string, args ...interface{}) {
+		field := at
+		if field == "" {
+			field = "(root)"
+		}
+		errs = append(errs, field+": "+fmt.Sprintf(format, args...))
+	}
+
+	if schema.Type != "" && !matchesType(schema.Type, value) {
+		fail("expected %s, got %s", schema.Type, jsonTypeName(value))
+		return errs
+	}
+
+	if len(schema.Enum) > 0 {
+		found := false
+		for _, allowed := range schema.Enum {
+			if allowed == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			fail("value %v is not one of %v", value, schema.Enum)
+		}
+	}
+
+	switch v := value.(type) {
+	case string:
+		if schema.MinLength != nil && len(v) < *schema.MinLength {
+			fail("length must be at least %d", *schema.MinLength)
+		}
+		switch schema.Format {
+		case "uuid":
+			if !uuidPattern.MatchString(v) {
+				fail("%q is not a valid uuid", v)
+			}
+		case "date-time":
+			if _, err := time.Parse(time.RFC3339Nano, v); err != nil {
+				fail("%q is not a valid date-time", v)
+			}
+		}
+	case float64:
+		if schema.Minimum != nil && v < *schema.Minimum {
+			fail("must be >= %v", *schema.Minimum)
+		}
+		if schema.Maximum != nil && v > *schema.Maximum {
+			fail("must be <= %v", *schema.Maximum)
+		}
+	case []interface{}:
+		if schema.Items != nil {
+			for i, item := range v {
+				errs = append(errs, validateValue(schema.Items, item, fmt.Sprintf("%s[%d]", at, i))...)
+			}
+		}
+	case map[string]interface{}:
+		for _, name := range schema.Required {
+			if _, ok := v[name]; !ok {
+				fail("missing required property %q", name)
+			}
+		}
+		for name, propSchema := range schema.Properties {
+			if propValue, ok := v[name]; ok {
+				errs = append(errs, validateValue(propSchema, propValue, joinPath(at, name))...)
+			}
+		}
+	}
+
+	if len(schema.AnyOf) > 0 {
+		matched := false
+		for _, option := range schema.AnyOf {
+			if len(validateValue(option, value, at)) == 0 {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			fail("does not match any allowed shape")
+		}
+	}
+
+	return errs
+}
+
+func matchesType(schemaType string, value interface{}) bool {
+	switch schemaType {
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "integer":
+		f, ok := value.(float64)
+		return ok && f == math.Trunc(f)
+	case "null":
+		return value == nil
+	}
+	return true
+}
+
+func jsonTypeName(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func joinPath(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "." + name
+}
@@ -30,6 +30,10 @@ type WebSocketService struct {
 	writeBufferSize int
 	checkOrigin     func(r *http.Request) bool
 
+	// Optional inbound message validation
+	schemaRegistry *SchemaRegistry
+	replayGuard    *ReplayGuard
+
 	// Context for cancellation
 	ctx    context.Context
 	cancel context.CancelFunc
@@ -168,6 +172,31 @@ func (ws *WebSocketService) processMessage(client *ClientInfo, message *WebSocke
 	// Update client activity
 	client.UpdateActivity()
 
+	// Reject malformed messages when schema validation is enabled
+	ws.mu.RLock()
+	registry := ws.schemaRegistry
+	guard := ws.replayGuard
+	ws.mu.RUnlock()
+	if registry != nil {
+		if err := registry.Validate(message); err != nil {
+			ws.sendValidationError(client, err)
+			return
+		}
+	}
+
+	// Refuse replayed, stale and forged cart mutations
+	if guard != nil {
+		if err := guard.Check(client.SessionID, message, time.Now()); err != nil {
+			if replayErr, ok := err.(*ReplayError); ok {
+				log.Printf("Refused %s from client %s: %v", message.Type, client.ID, err)
+				ws.sendError(client, replayErr.Code, replayErr.Reason)
+			} else {
+				ws.sendError(client, "invalid_message", err.Error())
+			}
+			return
+		}
+	}
+
 	// Process message based on type
 	switch message.Type {
 	case MessageTypeAuth:
@@ -223,15 +252,24 @@ func (ws *WebSocketService) handleAuthMessage(client *ClientInfo, message *WebSo
 	// Authenticate client
 	client.Authenticate(*authResult.UserID, authResult.AuthLevel, authResult.Permissions)
 
-	// Send success response
-	successMsg := NewMessageBuilder(MessageTypeAuthSuccess).
-		WithSession(authResult.SessionID).
+	// Send success response. The session is the connection's, which its
+	// mutations are checked and signed against.
+	builder := NewMessageBuilder(MessageTypeAuthSuccess).
+		WithSession(client.SessionID).
 		WithUser(*authResult.UserID).
-		WithDataField("session_id", authResult.SessionID).
+		WithDataField("session_id", client.SessionID).
 		WithDataField("user_id", authResult.UserID).
 		WithDataField("auth_level", authResult.AuthLevel).
-		WithDataField("permissions", authResult.Permissions).
-		Build()
+		WithDataField("permissions", authResult.Permissions)
+
+	// The connection signs its cart mutations with a key of that session
+	ws.mu.RLock()
+	guard := ws.replayGuard
+	ws.mu.RUnlock()
+	if guard != nil && guard.Signing() {
+		builder.WithDataField("signing_key", guard.SigningKey(client.SessionID))
+	}
+	successMsg := builder.Build()
 
 	client.SendMessage(successMsg)
 
@@ -315,6 +353,41 @@ func (ws *WebSocketService) sendError(client *ClientInfo, code, message string)
 	ws.stats.incrementErrorCount()
 }
 
+// sendValidationError sends a validation error message to a client
+func (ws *WebSocketService) sendValidationError(client *ClientInfo, err error) {
+	details := []string{err.Error()}
+	if validationErr, ok := err.(*SchemaValidationError); ok {
+		details = validationErr.Errors
+	}
+
+	errorMsg := NewMessageBuilder(MessageTypeValidationError).
+		WithPriority(PriorityHigh).
+		WithSession(client.SessionID).
+		WithDataField("code", "invalid_message").
+		WithDataField("message", "Message does not match its schema").
+		WithDataField("errors", details).
+		WithDataField("timestamp", time.Now()).
+		Build()
+
+	client.SendMessage(errorMsg)
+	ws.stats.incrementErrorCount()
+}
+
+// EnableSchemaValidation rejects inbound messages that do not match their registered schema
+func (ws *WebSocketService) EnableSchemaValidation(registry *SchemaRegistry) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	ws.schemaRegistry = registry
+}
+
+// EnableReplayProtection refuses critical mutations the guard rejects:
+// cart messages without a fresh nonce and timestamp, or with a bad signature
+func (ws *WebSocketService) EnableReplayProtection(guard *ReplayGuard) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	ws.replayGuard = guard
+}
+
 // BroadcastToSession broadcasts a message to all clients in a session
 func (ws *WebSocketService) BroadcastToSession(sessionID string, message *WebSocketMessage) error {
 	return ws.clientManager.BroadcastToSession(sessionID, message)
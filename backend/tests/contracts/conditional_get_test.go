@@ -0,0 +1,58 @@
+package contracts
+
+import (
+	"chat-ecommerce-backend/internal/handlers"
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"chat-ecommerce-backend/tests/testutil/factories"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCatalogConditionalGet(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	product := f.Product()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	productHandler := handlers.NewProductHandler(services.NewProductService(db))
+	router.GET("/api/v1/products/:id", productHandler.GetProductByID)
+	router.GET("/api/v1/categories", productHandler.GetCategories)
+
+	get := func(path, etag string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", path, nil)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	path := "/api/v1/products/" + product.ID.String()
+	w := get(path, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	etag := w.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+	assert.Equal(t, "public, max-age=60", w.Header().Get("Cache-Control"))
+
+	w = get(path, etag)
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Empty(t, w.Body.Bytes())
+
+	require.NoError(t, db.Model(&models.Product{}).Where("id = ?", product.ID).Update("name", "Renamed").Error)
+	w = get(path, etag)
+	assert.Equal(t, http.StatusOK, w.Code, "updates change the ETag")
+	assert.NotEqual(t, etag, w.Header().Get("ETag"))
+
+	w = get("/api/v1/categories", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, http.StatusNotModified, get("/api/v1/categories", w.Header().Get("ETag")).Code)
+}
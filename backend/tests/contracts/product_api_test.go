@@ -4,6 +4,8 @@ import (
 	"chat-ecommerce-backend/internal/handlers"
 	"chat-ecommerce-backend/internal/models"
 	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"chat-ecommerce-backend/tests/testutil/factories"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
@@ -13,7 +15,6 @@ import (
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
-	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
@@ -27,23 +28,14 @@ type ProductAPIContractTestSuite struct {
 }
 
 func (suite *ProductAPIContractTestSuite) SetupSuite() {
-	// Setup in-memory SQLite database for testing
-	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
-	if err != nil {
-		suite.T().Fatal("Failed to connect to test database:", err)
-	}
-
-	// Auto-migrate the database
-	err = db.AutoMigrate(
+	// Setup test database (SQLite by default, PostgreSQL with TEST_DB_DRIVER=postgres)
+	db := testutil.NewTestDB(suite.T(),
 		&models.Category{},
 		&models.Product{},
 		&models.ProductVariant{},
 		&models.ProductImage{},
 		&models.Inventory{},
 	)
-	if err != nil {
-		suite.T().Fatal("Failed to migrate test database:", err)
-	}
 
 	suite.db = db
 	suite.productService = services.NewProductService(db)
@@ -58,53 +50,30 @@ func (suite *ProductAPIContractTestSuite) SetupSuite() {
 }
 
 func (suite *ProductAPIContractTestSuite) setupTestData() {
-	// Create test category
-	suite.testCategory = &models.Category{
-		ID:   uuid.New(),
-		Name: "Electronics",
-		Slug: "electronics",
-	}
-	suite.db.Create(suite.testCategory)
-
-	// Create test product
-	suite.testProduct = &models.Product{
-		ID:          uuid.New(),
-		Name:        "Test Product",
-		Description: "A test product for API contract testing",
-		Price:       99.99,
-		CategoryID:  suite.testCategory.ID,
-		SKU:         "TEST-001",
-		Status:      "active",
-	}
-	suite.db.Create(suite.testProduct)
-
-	// Create product variant
-	variant := &models.ProductVariant{
-		ID:            uuid.New(),
-		ProductID:     suite.testProduct.ID,
-		VariantName:   "Color",
-		VariantValue:  "Red",
-		PriceModifier: 10.0,
-	}
-	suite.db.Create(variant)
-
-	// Create product image
-	image := &models.ProductImage{
-		ID:        uuid.New(),
-		ProductID: suite.testProduct.ID,
-		URL:       "https://example.com/image.jpg",
-		AltText:   "Test Product Image",
-		SortOrder: 1,
-	}
-	suite.db.Create(image)
-
-	// Create inventory
-	inventory := &models.Inventory{
-		ID:        uuid.New(),
-		ProductID: suite.testProduct.ID,
-		Quantity:  100,
-	}
-	suite.db.Create(inventory)
+	f := factories.New(suite.T(), suite.db)
+
+	suite.testCategory = f.Category(func(c *models.Category) {
+		c.Name = "Electronics"
+		c.Slug = "electronics"
+	})
+
+	suite.testProduct = f.Product(func(p *models.Product) {
+		p.Name = "Test Product"
+		p.Description = "A test product for API contract testing"
+		p.CategoryID = suite.testCategory.ID
+		p.SKU = "TEST-001"
+	})
+
+	f.Variant(suite.testProduct, func(v *models.ProductVariant) {
+		v.VariantValue = "Red"
+		v.PriceModifier = 10.0
+	})
+	f.Image(suite.testProduct, func(i *models.ProductImage) {
+		i.URL = "https://example.com/image.jpg"
+		i.AltText = "Test Product Image"
+		i.SortOrder = 1
+	})
+	f.Inventory(suite.testProduct)
 }
 
 func (suite *ProductAPIContractTestSuite) setupRoutes() {
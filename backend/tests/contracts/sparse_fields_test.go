@@ -0,0 +1,61 @@
+package contracts
+
+import (
+	"chat-ecommerce-backend/internal/handlers"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"chat-ecommerce-backend/tests/testutil/factories"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProductSparseFieldsets(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	product := f.Product()
+	f.Variant(product)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	productHandler := handlers.NewProductHandler(services.NewProductService(db))
+	router.GET("/api/v1/products/", productHandler.GetProducts)
+	router.GET("/api/v1/products/:id", productHandler.GetProductByID)
+
+	get := func(path string) map[string]interface{} {
+		req, _ := http.NewRequest("GET", path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var body map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		return body
+	}
+
+	body := get("/api/v1/products/" + product.ID.String() + "?fields=name,price,category.name")
+	assert.Len(t, body, 4, "only the requested fields and the ID are returned")
+	assert.Equal(t, product.ID.String(), body["id"])
+	assert.Equal(t, product.Name, body["name"])
+	category := body["category"].(map[string]interface{})
+	assert.Contains(t, category, "name")
+	assert.Contains(t, category, "id")
+	assert.NotContains(t, category, "description")
+
+	body = get("/api/v1/products/?fields=name,variants")
+	assert.Contains(t, body, "total", "the listing envelope is kept")
+	products := body["products"].([]interface{})
+	require.Len(t, products, 1)
+	listed := products[0].(map[string]interface{})
+	assert.Len(t, listed, 3)
+	variants := listed["variants"].([]interface{})
+	assert.Contains(t, variants[0], "variant_value", "a field without a sub-selection is kept whole")
+
+	body = get("/api/v1/products/" + product.ID.String())
+	assert.Contains(t, body, "description", "everything is returned without ?fields=")
+}
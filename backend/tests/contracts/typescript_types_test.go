@@ -0,0 +1,50 @@
+package contracts
+
+import (
+	"chat-ecommerce-backend/internal/tsgen"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestTypeScriptTypes_UpToDate fails when a chat message struct changed
+// without regenerating the frontend's types
+func TestTypeScriptTypes_UpToDate(t *testing.T) {
+	generated, err := tsgen.Generate("../..", tsgen.ChatContract)
+	require.NoError(t, err)
+
+	committed, err := os.ReadFile("../../../frontend/src/types/generated/chat.ts")
+	require.NoError(t, err)
+	assert.Equal(t, string(generated), string(committed), "run go run ./cmd/tsgen")
+}
+
+// TestTypeScriptTypes_Contract checks the generated union and how Go fields map to TypeScript
+func TestTypeScriptTypes_Contract(t *testing.T) {
+	generated, err := tsgen.Generate("../..", tsgen.ChatContract)
+	require.NoError(t, err)
+	ts := string(generated)
+
+	// Message types come from the Go constants
+	assert.Contains(t, ts, "| WebSocketMessage<'chat_response', ChatDelta>")
+	assert.Contains(t, ts, "| WebSocketMessage<'system_notification', MaintenanceNotice>")
+	assert.Contains(t, ts, "| WebSocketMessage<'payment_retry', DunningNotice>")
+	assert.Contains(t, ts, "| WebSocketMessage<'suggestions', ProductSuggestionDTO[]>")
+
+	// omitempty is optional, a pointer without it is nullable and uuids
+	// and times are strings
+	assert.Contains(t, ts, "  user_id?: string;\n")
+	assert.Contains(t, ts, "  next_retry_at?: string;\n")
+	assert.Contains(t, ts, "  maintenance: MaintenanceStatus | null;\n")
+	assert.Contains(t, ts, "  product_ids: string[];\n")
+
+	// Embedded structs are flattened and fields tagged "-" left out
+	assert.Contains(t, ts, "export interface JobStatus {\n  id: string;\n")
+	assert.Contains(t, ts, "  artifact_url?: string;\n")
+	assert.NotContains(t, ts, "  artifact: string;")
+
+	// A name already used by another package gets the package as a prefix
+	assert.Contains(t, ts, "export interface ChatMessage {\n  id: string;\n  session_id: string;\n")
+	assert.Contains(t, ts, "export interface ModelsChatMessage {")
+}
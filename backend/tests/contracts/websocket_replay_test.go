@@ -0,0 +1,97 @@
+package contracts
+
+import (
+	"chat-ecommerce-backend/pkg/websocket"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func cartAdd(productID uuid.UUID, quantity int) *websocket.MessageBuilder {
+	return websocket.NewMessageBuilder(websocket.MessageTypeCartAdd).
+		WithDataField("product_id", productID.String()).
+		WithDataField("quantity", quantity).
+		WithNonce()
+}
+
+func replayCode(t *testing.T, err error) string {
+	t.Helper()
+	var replayErr *websocket.ReplayError
+	require.ErrorAs(t, err, &replayErr)
+	return replayErr.Code
+}
+
+// TestReplayGuard_RefusesReplayedMutations checks nonces and timestamps on cart mutations
+func TestReplayGuard_RefusesReplayedMutations(t *testing.T) {
+	guard := websocket.NewReplayGuard(30*time.Second, nil, false)
+	productID := uuid.New()
+	now := time.Now()
+
+	msg := cartAdd(productID, 1).Build()
+	require.NoError(t, guard.Check("session-1", msg, now))
+	assert.Equal(t, "replayed_message", replayCode(t, guard.Check("session-1", msg, now.Add(time.Second))))
+
+	// The same add with a new nonce is a new mutation, and nonces are per session
+	require.NoError(t, guard.Check("session-1", cartAdd(productID, 1).Build(), now))
+	require.NoError(t, guard.Check("session-2", msg, now))
+
+	unsigned := cartAdd(productID, 1).Build()
+	unsigned.Nonce = ""
+	assert.Equal(t, "missing_nonce", replayCode(t, guard.Check("session-1", unsigned, now)))
+
+	stale := cartAdd(productID, 1).Build()
+	stale.Timestamp = now.Add(-time.Minute)
+	assert.Equal(t, "stale_message", replayCode(t, guard.Check("session-1", stale, now)))
+	future := cartAdd(productID, 1).Build()
+	future.Timestamp = now.Add(time.Minute)
+	assert.Equal(t, "stale_message", replayCode(t, guard.Check("session-1", future, now)))
+
+	// A replay after the window is refused as stale even once its nonce is forgotten
+	assert.Equal(t, "stale_message", replayCode(t, guard.Check("session-1", msg, now.Add(2*time.Minute))))
+
+	// Messages that don't change state need no nonce
+	assert.NoError(t, guard.Check("session-1", websocket.NewMessageBuilder(websocket.MessageTypePing).Build(), now))
+}
+
+// TestReplayGuard_Signatures checks the optional HMAC over cart mutations
+func TestReplayGuard_Signatures(t *testing.T) {
+	guard := websocket.NewReplayGuard(30*time.Second, []byte("replay-test-secret"), true)
+	require.True(t, guard.Signing())
+	key, err := hex.DecodeString(guard.SigningKey("session-1"))
+	require.NoError(t, err)
+	productID := uuid.New()
+	now := time.Now()
+
+	signed := cartAdd(productID, 2).Signed(key, "session-1").Build()
+	require.NoError(t, guard.Check("session-1", signed, now))
+
+	unsigned := cartAdd(productID, 2).Build()
+	assert.Equal(t, "invalid_signature", replayCode(t, guard.Check("session-1", unsigned, now)))
+
+	// Changing the quantity after signing breaks the signature
+	tampered := cartAdd(productID, 2).Signed(key, "session-1").Build()
+	tampered.Data["quantity"] = 50
+	assert.Equal(t, "invalid_signature", replayCode(t, guard.Check("session-1", tampered, now)))
+
+	// Another session's key doesn't sign for this one
+	otherKey, err := hex.DecodeString(guard.SigningKey("session-2"))
+	require.NoError(t, err)
+	other := cartAdd(productID, 2).Signed(otherKey, "session-2").Build()
+	assert.Equal(t, "invalid_signature", replayCode(t, guard.Check("session-1", other, now)))
+
+	// A forged message doesn't use up the nonce it copied
+	forged := cartAdd(productID, 2).Build()
+	forged.Signature = signed.Signature
+	retry := *forged
+	assert.Equal(t, "invalid_signature", replayCode(t, guard.Check("session-1", forged, now)))
+	retry.Signature, err = websocket.SignMessage(key, "session-1", &retry)
+	require.NoError(t, err)
+	assert.NoError(t, guard.Check("session-1", &retry, now))
+
+	// Signed mutations still match the envelope schema
+	assert.NoError(t, loadSchemaRegistry(t).Validate(signed))
+}
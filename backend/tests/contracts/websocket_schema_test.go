@@ -0,0 +1,130 @@
+package contracts
+
+import (
+	"chat-ecommerce-backend/pkg/websocket"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func loadSchemaRegistry(t *testing.T) *websocket.SchemaRegistry {
+	registry, err := websocket.NewSchemaRegistry()
+	require.NoError(t, err)
+	return registry
+}
+
+// TestWebSocketSchemas_CoverAllMessageTypes ensures every MessageType has a schema
+func TestWebSocketSchemas_CoverAllMessageTypes(t *testing.T) {
+	registry := loadSchemaRegistry(t)
+
+	for _, msgType := range websocket.AllMessageTypes {
+		_, ok := registry.Schema(msgType)
+		assert.True(t, ok, "missing schema for %s", msgType)
+	}
+	assert.Len(t, registry.Types(), len(websocket.AllMessageTypes))
+}
+
+// TestWebSocketSchemas_FactoryMessages validates every message produced by MessageFactory
+func TestWebSocketSchemas_FactoryMessages(t *testing.T) {
+	registry := loadSchemaRegistry(t)
+	factory := websocket.NewMessageFactory()
+	userID := uuid.New()
+	sessionID := "session-123"
+
+	items := []websocket.CartItemData{
+		{ProductID: uuid.New(), Quantity: 2, Price: 19.99, Name: "T-Shirt"},
+	}
+
+	messages := map[string]*websocket.WebSocketMessage{
+		"CreateChatMessage":            factory.CreateChatMessage("Hello there", "user", sessionID),
+		"CreateCartUpdate":             factory.CreateCartUpdate(sessionID, &userID, items, 39.98),
+		"CreateCartUpdate/anonymous":   factory.CreateCartUpdate(sessionID, nil, nil, 0),
+		"CreateInventoryUpdate":        factory.CreateInventoryUpdate(uuid.New(), 10, 2, 8, "main"),
+		"CreateNotification":           factory.CreateNotification("Order shipped", "Your order is on its way", "info", websocket.PriorityNormal),
+		"CreateError":                  factory.CreateError("not_found", "Product not found", sessionID, &userID),
+		"CreatePing":                   factory.CreatePing(1),
+		"CreateCartUpdateMessage":      websocket.CreateCartUpdateMessage(map[string]interface{}{"items": items}, sessionID, nil),
+		"CreateInventoryUpdateMessage": websocket.CreateInventoryUpdateMessage(map[string]interface{}{"available": 3}, sessionID, nil),
+		"CreateNotificationMessage":    websocket.CreateNotificationMessage(websocket.NotificationData{Title: "Hi"}, sessionID, nil),
+	}
+
+	for name, msg := range messages {
+		t.Run(name, func(t *testing.T) {
+			assert.NoError(t, registry.Validate(msg))
+		})
+	}
+}
+
+// TestWebSocketSchemas_BuilderMessages validates messages assembled with MessageBuilder
+func TestWebSocketSchemas_BuilderMessages(t *testing.T) {
+	registry := loadSchemaRegistry(t)
+
+	messages := map[string]*websocket.WebSocketMessage{
+		"auth_success": websocket.NewMessageBuilder(websocket.MessageTypeAuthSuccess).
+			WithSession("session-123").
+			WithDataField("session_id", "session-123").
+			WithDataField("user_id", uuid.New()).
+			WithDataField("permissions", []string{"chat_access"}).
+			Build(),
+		"pong": websocket.NewMessageBuilder(websocket.MessageTypePong).
+			WithDataField("timestamp", time.Now()).
+			WithDataField("sequence", 3).
+			WithDataField("latency_ms", int64(12)).
+			Build(),
+		"cart_add": websocket.NewMessageBuilder(websocket.MessageTypeCartAdd).
+			WithUser(uuid.New()).
+			WithDataField("product_id", uuid.New().String()).
+			WithDataField("quantity", 1).
+			Build(),
+		"validation_error": websocket.NewMessageBuilder(websocket.MessageTypeValidationError).
+			WithDataField("code", "invalid_message").
+			WithDataField("message", "bad").
+			WithDataField("errors", []string{"data.quantity: must be >= 1"}).
+			Build(),
+	}
+
+	for name, msg := range messages {
+		t.Run(name, func(t *testing.T) {
+			assert.NoError(t, registry.Validate(msg))
+		})
+	}
+}
+
+// TestWebSocketSchemas_RejectsMalformed checks that invalid inbound messages are rejected
+func TestWebSocketSchemas_RejectsMalformed(t *testing.T) {
+	registry := loadSchemaRegistry(t)
+
+	tests := []struct {
+		name string
+		json string
+	}{
+		{"invalid json", `{"type": "chat_message"`},
+		{"missing data", `{"type": "chat_message"}`},
+		{"unknown type", `{"type": "launch_rockets", "data": {}}`},
+		{"empty chat content", `{"type": "chat_message", "data": {"content": ""}}`},
+		{"bad message_type", `{"type": "chat_message", "data": {"content": "hi", "message_type": "robot"}}`},
+		{"cart_add zero quantity", `{"type": "cart_add", "data": {"product_id": "` + uuid.New().String() + `", "quantity": 0}}`},
+		{"cart_add fractional quantity", `{"type": "cart_add", "data": {"product_id": "` + uuid.New().String() + `", "quantity": 1.5}}`},
+		{"cart_add bad uuid", `{"type": "cart_add", "data": {"product_id": "abc", "quantity": 1}}`},
+		{"cart_update no items", `{"type": "cart_update", "data": {"session_id": "s"}}`},
+		{"auth missing token", `{"type": "auth", "data": {}}`},
+		{"bad priority", `{"type": "ping", "priority": 9, "data": {}}`},
+		{"bad timestamp", `{"type": "ping", "timestamp": "yesterday", "data": {}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := registry.ValidateJSON([]byte(tt.json))
+			assert.Error(t, err)
+			_, ok := err.(*websocket.SchemaValidationError)
+			assert.True(t, ok)
+		})
+	}
+
+	// A well-formed inbound message passes
+	valid := `{"type": "cart_add", "data": {"product_id": "` + uuid.New().String() + `", "quantity": 2}}`
+	assert.NoError(t, registry.ValidateJSON([]byte(valid)))
+}
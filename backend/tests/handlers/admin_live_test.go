@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"bytes"
+	"chat-ecommerce-backend/internal/handlers"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"chat-ecommerce-backend/tests/testutil/factories"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// adminEvent is a message received on the admin channel
+type adminEvent struct {
+	Type string `json:"type"`
+	Data struct {
+		Resource struct {
+			Kind string `json:"kind"`
+			ID   string `json:"id"`
+		} `json:"resource"`
+		Editor struct {
+			Email string `json:"email"`
+		} `json:"editor"`
+		Released      bool `json:"released"`
+		OverwrittenBy *struct {
+			Email string `json:"email"`
+		} `json:"overwritten_by"`
+		Admins []struct {
+			Email string `json:"email"`
+		} `json:"admins"`
+	} `json:"data"`
+}
+
+// nextAdminEvent reads admin channel messages until one of the given type
+func nextAdminEvent(t *testing.T, conn *websocket.Conn, messageType string) adminEvent {
+	t.Helper()
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+	for {
+		var event adminEvent
+		require.NoError(t, conn.ReadJSON(&event))
+		if event.Type == messageType {
+			return event
+		}
+	}
+}
+
+func TestAdminLiveHandler_EditLocksAndConflicts(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	product := f.Product()
+
+	live := handlers.NewAdminLiveHandler(services.NewAdminPresence(services.AdminPresenceConfig{LockTTL: time.Minute}))
+	adminHandler := handlers.NewAdminHandler(services.NewAdminProductService(db), services.NewProductService(db)).WithLiveEdits(live)
+
+	// X-Test-User and X-Test-Email stand in for the signed in admin
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		if userID := c.GetHeader("X-Test-User"); userID != "" {
+			c.Set("user_id", userID)
+			c.Set("user_email", c.GetHeader("X-Test-Email"))
+		}
+	})
+	r.GET("/api/v1/admin/ws", live.HandleWebSocket)
+	r.PATCH("/api/v1/admin/products/:id", adminHandler.PatchProduct)
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	alice := http.Header{"X-Test-User": {uuid.NewString()}, "X-Test-Email": {"alice@example.com"}}
+	bob := http.Header{"X-Test-User": {uuid.NewString()}, "X-Test-Email": {"bob@example.com"}}
+	dial := func(header http.Header) *websocket.Conn {
+		header = header.Clone()
+		header.Set("Origin", server.URL)
+		conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/api/v1/admin/ws", header)
+		require.NoError(t, err)
+		return conn
+	}
+	patch := func(header http.Header, body map[string]interface{}) map[string]interface{} {
+		data, err := json.Marshal(body)
+		require.NoError(t, err)
+		req, err := http.NewRequest(http.MethodPatch, server.URL+"/api/v1/admin/products/"+product.ID.String(), bytes.NewReader(data))
+		require.NoError(t, err)
+		for key, values := range header {
+			req.Header[key] = values
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var response map[string]interface{}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&response))
+		return response
+	}
+
+	aliceConn := dial(alice)
+	defer aliceConn.Close()
+	bobConn := dial(bob)
+	defer bobConn.Close()
+	presence := nextAdminEvent(t, bobConn, services.AdminPresenceMessage)
+	assert.Len(t, presence.Data.Admins, 2)
+
+	// Alice opens the product; Bob is told and can't take the lock from her
+	editProduct := map[string]interface{}{"type": "edit_start", "data": map[string]interface{}{"kind": "product", "id": product.ID}}
+	require.NoError(t, aliceConn.WriteJSON(editProduct))
+	lock := nextAdminEvent(t, bobConn, services.AdminEditLockMessage)
+	assert.Equal(t, "alice@example.com", lock.Data.Editor.Email)
+	assert.Equal(t, product.ID.String(), lock.Data.Resource.ID)
+
+	require.NoError(t, bobConn.WriteJSON(editProduct))
+	held := nextAdminEvent(t, bobConn, services.AdminEditLockMessage)
+	assert.Equal(t, "alice@example.com", held.Data.Editor.Email, "the lock stays with Alice")
+
+	// Both loaded the same version; Alice saves first without a conflict
+	loaded := product.UpdatedAt
+	saved := patch(alice, map[string]interface{}{"name": "Alice's name", "expected_updated_at": loaded})
+	assert.NotContains(t, saved, "conflict")
+	assert.Equal(t, "alice@example.com", nextAdminEvent(t, bobConn, services.AdminRecordSavedMessage).Data.Editor.Email)
+
+	// Bob's save still wins, but he and Alice are told it replaced her change
+	overwritten := patch(bob, map[string]interface{}{"name": "Bob's name", "expected_updated_at": loaded})
+	require.Contains(t, overwritten, "conflict")
+	assert.Contains(t, overwritten["conflict"].(map[string]interface{})["message"], "alice@example.com changed this product")
+	conflict := nextAdminEvent(t, aliceConn, services.AdminEditConflictMessage)
+	assert.Equal(t, "bob@example.com", conflict.Data.Editor.Email)
+	require.NotNil(t, conflict.Data.OverwrittenBy)
+	assert.Equal(t, "alice@example.com", conflict.Data.OverwrittenBy.Email)
+
+	var name string
+	require.NoError(t, db.Table("products").Select("name").Where("id = ?", product.ID).Scan(&name).Error)
+	assert.Equal(t, "Bob's name", name, "last write wins")
+
+	// Alice leaving gives up her lock
+	require.NoError(t, aliceConn.Close())
+	released := nextAdminEvent(t, bobConn, services.AdminEditLockMessage)
+	assert.True(t, released.Data.Released)
+	assert.Equal(t, "alice@example.com", released.Data.Editor.Email)
+}
+
+func TestAdminLiveHandler_RefusesOtherOrigins(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	live := handlers.NewAdminLiveHandler(services.NewAdminPresence(services.AdminPresenceConfig{LockTTL: time.Minute})).
+		WithAllowedOrigins([]string{"https://admin.example.com"})
+
+	// X-Test-User stands in for an admin signed in with a session cookie
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("user_id", c.GetHeader("X-Test-User"))
+	})
+	r.GET("/api/v1/admin/ws", live.HandleWebSocket)
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	dial := func(header http.Header) (*websocket.Conn, int) {
+		header.Set("X-Test-User", uuid.NewString())
+		conn, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/api/v1/admin/ws", header)
+		if err != nil {
+			require.NotNil(t, resp, err)
+			return nil, resp.StatusCode
+		}
+		conn.Close()
+		return conn, resp.StatusCode
+	}
+
+	_, status := dial(http.Header{"Origin": {"https://evil.example.com"}})
+	assert.Equal(t, http.StatusForbidden, status, "a page on another site can't ride the admin's cookie")
+	_, status = dial(http.Header{})
+	assert.Equal(t, http.StatusForbidden, status, "cookie sign-ins must say where they come from")
+
+	conn, _ := dial(http.Header{"Origin": {"https://admin.example.com"}})
+	assert.NotNil(t, conn, "origins on the allowlist connect")
+	conn, _ = dial(http.Header{"Origin": {server.URL}})
+	assert.NotNil(t, conn, "the API's own origin connects")
+	conn, _ = dial(http.Header{"Authorization": {"Bearer token"}})
+	assert.NotNil(t, conn, "bearer token clients needn't send an Origin")
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"bytes"
+	"chat-ecommerce-backend/internal/handlers"
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"chat-ecommerce-backend/tests/testutil/factories"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminHandler_CreateProductSKUErrors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("RESERVED_SKU_PATTERN", "")
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	category := f.Category()
+	existing := f.Product(func(p *models.Product) { p.SKU = "MUG-RED" })
+
+	adminHandler := handlers.NewAdminHandler(services.NewAdminProductService(db), services.NewProductService(db))
+	r := gin.New()
+	r.POST("/api/v1/admin/products", adminHandler.CreateProduct)
+
+	create := func(sku string) (int, map[string]interface{}) {
+		body, err := json.Marshal(map[string]interface{}{
+			"name":        "Mug",
+			"description": "Stoneware mug",
+			"price":       12,
+			"category_id": category.ID,
+			"sku":         sku,
+		})
+		require.NoError(t, err)
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/admin/products", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		var response map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return w.Code, response
+	}
+
+	status, response := create("mug-red")
+	assert.Equal(t, http.StatusConflict, status)
+	assert.Equal(t, services.SKUConflictCode, response["code"])
+	conflict := response["conflict"].(map[string]interface{})
+	assert.Equal(t, existing.ID.String(), conflict["conflicting_product_id"])
+	assert.Equal(t, "MUG-RED", conflict["conflicting_sku"])
+
+	status, response = create("SYS-MUG")
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "sku", response["field"])
+
+	status, _ = create("MUG-BLUE")
+	assert.Equal(t, http.StatusCreated, status)
+}
+
+func TestAdminHandler_CategoryMetadataSchema(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("RESERVED_SKU_PATTERN", "")
+	db := testutil.NewTestDB(t)
+	category := factories.New(t, db).Category()
+
+	adminHandler := handlers.NewAdminHandler(services.NewAdminProductService(db), services.NewProductService(db))
+	r := gin.New()
+	r.PUT("/api/v1/admin/categories/:id/metadata-schema", adminHandler.UpdateCategoryMetadataSchema)
+	r.POST("/api/v1/admin/products", adminHandler.CreateProduct)
+
+	send := func(method, path string, payload interface{}) (int, map[string]interface{}) {
+		body, err := json.Marshal(payload)
+		require.NoError(t, err)
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		var response map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return w.Code, response
+	}
+	schemaPath := "/api/v1/admin/categories/" + category.ID.String() + "/metadata-schema"
+
+	status, _ := send(http.MethodPut, schemaPath, map[string]interface{}{
+		"schema": map[string]interface{}{"properties": map[string]interface{}{"wattage": map[string]interface{}{"type": "decimal"}}},
+	})
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	status, _ = send(http.MethodPut, schemaPath, map[string]interface{}{
+		"schema": map[string]interface{}{
+			"properties": map[string]interface{}{"wattage": map[string]interface{}{"type": "number"}},
+			"required":   []string{"wattage"},
+		},
+	})
+	require.Equal(t, http.StatusOK, status)
+
+	status, response := send(http.MethodPost, "/api/v1/admin/products", map[string]interface{}{
+		"name":        "Desk lamp",
+		"description": "Adjustable desk lamp",
+		"price":       30,
+		"category_id": category.ID,
+		"sku":         "LAMP-1",
+		"metadata":    map[string]interface{}{"wattage": "bright"},
+	})
+	assert.Equal(t, http.StatusUnprocessableEntity, status)
+	assert.Equal(t, "metadata", response["field"])
+	violations := response["violations"].([]interface{})
+	require.Len(t, violations, 1)
+	assert.Equal(t, "wattage", violations[0].(map[string]interface{})["attribute"])
+}
@@ -4,40 +4,17 @@ import (
 	"chat-ecommerce-backend/internal/handlers"
 	"chat-ecommerce-backend/internal/models"
 	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
 	"encoding/json"
 	"testing"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
-	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
-func setupChatTestDB(t *testing.T) *gorm.DB {
-	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
-	if err != nil {
-		t.Fatal("Failed to connect to test database:", err)
-	}
-
-	// Auto-migrate all required models
-	err = db.AutoMigrate(
-		&models.ChatSession{},
-		&models.ChatMessage{},
-		&models.Product{},
-		&models.Category{},
-		&models.Inventory{},
-		&models.User{},
-		&models.ShoppingCart{},
-	)
-	if err != nil {
-		t.Fatal("Failed to migrate test database:", err)
-	}
-
-	return db
-}
-
 func setupChatHandler(t *testing.T) (*handlers.ChatHandler, *gorm.DB) {
-	db := setupChatTestDB(t)
+	db := testutil.NewTestDB(t)
 
 	productService := services.NewProductService(db)
 	cartService := services.NewShoppingCartService(db)
@@ -51,7 +28,7 @@ func setupChatHandler(t *testing.T) (*handlers.ChatHandler, *gorm.DB) {
 // sent via WebSocket include complete product data with category
 func TestWebSocketMessage_ProductSuggestionSerialization(t *testing.T) {
 	// Setup test database
-	db := setupChatTestDB(t)
+	db := testutil.NewTestDB(t)
 
 	// Create a test category
 	categoryID := uuid.New()
@@ -211,7 +188,7 @@ func TestWebSocketMessage_ProductSuggestionSerialization(t *testing.T) {
 // product fields are present in WebSocket messages
 func TestWebSocketMessage_CompleteProductDataFields(t *testing.T) {
 	// Setup test database
-	db := setupChatTestDB(t)
+	db := testutil.NewTestDB(t)
 
 	// Create category
 	categoryID := uuid.New()
@@ -303,7 +280,7 @@ func TestWebSocketMessage_CompleteProductDataFields(t *testing.T) {
 // T084: Test WebSocket message size stays under 50KB for product list
 func TestWebSocketMessage_MessageSizeLimit(t *testing.T) {
 	// Setup test database
-	db := setupChatTestDB(t)
+	db := testutil.NewTestDB(t)
 
 	// Create category
 	categoryID := uuid.New()
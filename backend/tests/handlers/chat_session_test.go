@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"chat-ecommerce-backend/internal/handlers"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// chatSessionRouter serves the chat session routes, treating X-Test-User as
+// the signed in user
+func chatSessionRouter(t *testing.T) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	db := testutil.NewTestDB(t)
+	productService := services.NewProductService(db)
+	cartService := services.NewShoppingCartService(db)
+	handler := handlers.NewChatHandler(services.NewChatService(db, productService, cartService))
+
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		if userID := c.GetHeader("X-Test-User"); userID != "" {
+			c.Set("user_id", userID)
+		}
+	})
+	r.POST("/api/v1/chat/session", handler.StartChatSession)
+	r.GET("/api/v1/chat/session/:session_id", handler.GetChatSession)
+	r.GET("/api/v1/chat/history/:session_id", handler.GetChatHistory)
+	return r
+}
+
+// startChatSession starts a session and returns its ID and cookie
+func startChatSession(t *testing.T, r *gin.Engine, userID string) (string, *http.Cookie) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/chat/session", nil)
+	if userID != "" {
+		req.Header.Set("X-Test-User", userID)
+	}
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	var body struct {
+		Data struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == services.ChatSessionCookie {
+			return body.Data.ID, cookie
+		}
+	}
+	t.Fatal("no chat session cookie was set")
+	return "", nil
+}
+
+func TestChatSession_Ownership(t *testing.T) {
+	t.Setenv("CHAT_SESSION_SECRET", "chat-session-test-secret")
+	t.Setenv("CHAT_HISTORY_RATE_PER_MINUTE", "100")
+	r := chatSessionRouter(t)
+
+	get := func(path, userID string, cookie *http.Cookie) int {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if userID != "" {
+			req.Header.Set("X-Test-User", userID)
+		}
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	sessionID, cookie := startChatSession(t, r, "")
+	assert.True(t, strings.HasPrefix(sessionID, "cs_"))
+	assert.True(t, cookie.HttpOnly)
+	assert.Equal(t, sessionID, cookie.Value)
+
+	// Anonymous sessions belong to whoever holds the cookie
+	assert.Equal(t, http.StatusOK, get("/api/v1/chat/history/"+sessionID, "", cookie))
+	assert.Equal(t, http.StatusOK, get("/api/v1/chat/session/"+sessionID, "", cookie))
+	assert.Equal(t, http.StatusNotFound, get("/api/v1/chat/history/"+sessionID, "", nil), "knowing the ID isn't enough")
+
+	// Made-up and tampered IDs are turned away like unknown ones
+	assert.Equal(t, http.StatusNotFound, get("/api/v1/chat/history/session_123", "", &http.Cookie{Name: services.ChatSessionCookie, Value: "session_123"}))
+	tampered := sessionID[:len(sessionID)-2] + "AA"
+	assert.Equal(t, http.StatusNotFound, get("/api/v1/chat/history/"+tampered, "", &http.Cookie{Name: services.ChatSessionCookie, Value: tampered}))
+
+	// Sessions started while signed in belong to the user
+	owner, stranger := uuid.New().String(), uuid.New().String()
+	userSession, userCookie := startChatSession(t, r, owner)
+	assert.Equal(t, http.StatusOK, get("/api/v1/chat/history/"+userSession, owner, nil))
+	assert.Equal(t, http.StatusNotFound, get("/api/v1/chat/history/"+userSession, stranger, userCookie))
+	assert.Equal(t, http.StatusNotFound, get("/api/v1/chat/history/"+userSession, "", userCookie))
+}
+
+func TestChatSession_HistoryThrottle(t *testing.T) {
+	t.Setenv("CHAT_SESSION_SECRET", "chat-session-test-secret")
+	t.Setenv("CHAT_HISTORY_RATE_PER_MINUTE", "3")
+	r := chatSessionRouter(t)
+
+	guess := func(remoteAddr string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/chat/history/cs_guess."+uuid.New().String(), nil)
+		req.RemoteAddr = remoteAddr
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusNotFound, guess("203.0.113.9:1234").Code)
+	}
+	throttled := guess("203.0.113.9:1234")
+	assert.Equal(t, http.StatusTooManyRequests, throttled.Code)
+	assert.NotEmpty(t, throttled.Header().Get("Retry-After"))
+	assert.Equal(t, "0", throttled.Header().Get("X-RateLimit-Remaining"))
+	assert.Equal(t, http.StatusNotFound, guess("198.51.100.2:1234").Code, "other addresses aren't affected")
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"chat-ecommerce-backend/internal/handlers"
+	"chat-ecommerce-backend/internal/services"
+	wsproto "chat-ecommerce-backend/pkg/websocket"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// chatFrame is a frame the chat WebSocket sends
+type chatFrame struct {
+	Type string                 `json:"type"`
+	Data map[string]interface{} `json:"data"`
+}
+
+// dialChatSocket connects to the chat WebSocket and reads the welcome message
+func dialChatSocket(t *testing.T, serverURL string) *websocket.Conn {
+	t.Helper()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(serverURL, "http")+"/api/v1/chat/ws", nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	nextChatFrame(t, conn, "message")
+	return conn
+}
+
+// nextChatFrame reads frames until one of the given type
+func nextChatFrame(t *testing.T, conn *websocket.Conn, frameType string) chatFrame {
+	t.Helper()
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	for {
+		var frame chatFrame
+		require.NoError(t, conn.ReadJSON(&frame))
+		if frame.Type == frameType {
+			return frame
+		}
+	}
+}
+
+func TestChatHandler_RefusesReplayedMessages(t *testing.T) {
+	fake := services.NewFakeLLM("Here you go!")
+	server := chatStreamServer(t, fake, func(h *handlers.ChatHandler) {
+		h.WithReplayGuard(wsproto.NewReplayGuard(wsproto.DefaultReplayWindow, nil, false))
+	})
+	conn := dialChatSocket(t, server.URL)
+
+	frame, err := json.Marshal(map[string]interface{}{
+		"type":      "message",
+		"data":      map[string]interface{}{"content": "add the headphones to my cart"},
+		"nonce":     "0123456789abcdef0123456789abcdef",
+		"timestamp": time.Now(),
+	})
+	require.NoError(t, err)
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, frame))
+	reply := nextChatFrame(t, conn, "message")
+	assert.Equal(t, "assistant", reply.Data["role"])
+
+	// The same frame captured and sent again
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, frame))
+	refused := nextChatFrame(t, conn, "error")
+	assert.Equal(t, "replayed_message", refused.Data["code"])
+
+	require.NoError(t, conn.WriteJSON(map[string]interface{}{"type": "message", "data": map[string]interface{}{"content": "hi"}}))
+	refused = nextChatFrame(t, conn, "error")
+	assert.Equal(t, "missing_nonce", refused.Data["code"])
+
+	require.NoError(t, conn.WriteJSON(map[string]interface{}{"type": "typing", "data": map[string]interface{}{"is_typing": true}}))
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, frame))
+	refused = nextChatFrame(t, conn, "error")
+	assert.Equal(t, "replayed_message", refused.Data["code"], "typing indicators needn't be stamped")
+	assert.Len(t, fake.Requests(), 1, "only the first message reached the assistant")
+}
+
+func TestChatHandler_RejectsMalformedFrames(t *testing.T) {
+	fake := services.NewFakeLLM("Here you go!")
+	registry, err := wsproto.NewSchemaRegistry()
+	require.NoError(t, err)
+	server := chatStreamServer(t, fake, func(h *handlers.ChatHandler) {
+		h.WithSchemaValidation(registry)
+	})
+	conn := dialChatSocket(t, server.URL)
+
+	malformed := []map[string]interface{}{
+		{"type": "message", "data": map[string]interface{}{"text": "hi"}},
+		{"type": "message", "data": map[string]interface{}{"content": 42}},
+		{"type": "message", "data": "hi"},
+		{"type": "typing", "data": map[string]interface{}{"is_typing": "yes"}},
+		{"type": "cart_dump", "data": map[string]interface{}{}},
+	}
+	for _, frame := range malformed {
+		require.NoError(t, conn.WriteJSON(frame))
+		refused := nextChatFrame(t, conn, "error")
+		assert.Equal(t, "invalid_message", refused.Data["code"], "%v", frame)
+	}
+	assert.Zero(t, fake.CallCount(), "malformed messages don't reach the assistant")
+
+	require.NoError(t, conn.WriteJSON(map[string]interface{}{"type": "message", "data": map[string]interface{}{"content": "hi"}}))
+	reply := nextChatFrame(t, conn, "message")
+	assert.Equal(t, "assistant", reply.Data["role"], "well-formed messages are answered")
+}
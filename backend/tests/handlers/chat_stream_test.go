@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	chatcommerce "chat-ecommerce-backend/clients/go"
+	"chat-ecommerce-backend/internal/handlers"
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"chat-ecommerce-backend/tests/testutil/factories"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// chatStreamServer serves the chat routes with the assistant replying fake's
+// answers, after options configure the handler
+func chatStreamServer(t *testing.T, fake *services.FakeLLM, options ...func(*handlers.ChatHandler)) *httptest.Server {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("CHAT_SESSION_SECRET", "chat-session-test-secret")
+	db := testutil.NewTestDB(t)
+	factories.New(t, db).StockedProduct(10, func(p *models.Product) { p.Name = "Wireless Headphones" })
+	productService := services.NewProductService(db)
+	handler := handlers.NewChatHandler(services.NewChatServiceWithProvider(db, fake, productService, services.NewShoppingCartService(db)))
+	for _, option := range options {
+		option(handler)
+	}
+
+	r := gin.New()
+	r.GET("/api/v1/chat/ws", handler.HandleWebSocket)
+	r.GET("/api/v1/chat/stream", handler.StreamChatMessage)
+	server := httptest.NewServer(r)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestChatHandler_StreamChatMessageSSE(t *testing.T) {
+	fake := services.NewFakeLLM().Enqueue(services.FakeLLMResponse{
+		Content: "Here are some headphones you might like!",
+		Chunks:  []string{"Here are ", "some headphones ", "you might like!"},
+	})
+	server := chatStreamServer(t, fake)
+
+	resp, err := http.Get(server.URL + "/api/v1/chat/stream?message=show+me+wireless+headphones")
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream"))
+	stream := string(body)
+	assert.Contains(t, stream, "event:session")
+	assert.Contains(t, stream, `"delta":"some headphones "`)
+	assert.Less(t, strings.Index(stream, "event:delta"), strings.Index(stream, "event:message"), "deltas come before the final message")
+
+	client := chatcommerce.New(server.URL)
+	var deltas []string
+	fake.Enqueue(services.FakeLLMResponse{Content: "Great choice!", Chunks: []string{"Great ", "choice!"}})
+	reply, err := client.StreamChatMessage(context.Background(), "", "show me wireless headphones", func(delta string) {
+		deltas = append(deltas, delta)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Great ", "choice!"}, deltas)
+	assert.Equal(t, "Great choice!", reply.Message)
+	assert.NotEmpty(t, reply.SessionID)
+	require.NotEmpty(t, reply.Suggestions, "suggestions arrive with the final message")
+	assert.Equal(t, "Wireless Headphones", reply.Suggestions[0].Product.Name)
+
+	// The conversation continues on the same session
+	fake.Enqueue(services.FakeLLMResponse{Content: "You're welcome!"})
+	next, err := client.StreamChatMessage(context.Background(), reply.SessionID, "thanks", nil)
+	require.NoError(t, err)
+	assert.Equal(t, reply.SessionID, next.SessionID)
+
+	missing, err := http.Get(server.URL + "/api/v1/chat/stream")
+	require.NoError(t, err)
+	missing.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, missing.StatusCode)
+}
+
+func TestChatHandler_StreamWebSocketDeltas(t *testing.T) {
+	fake := services.NewFakeLLM().Enqueue(services.FakeLLMResponse{
+		Content: "Here are some headphones!",
+		Chunks:  []string{"Here are ", "some ", "headphones!"},
+	})
+	server := chatStreamServer(t, fake)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := chatcommerce.New(server.URL).DialChat(ctx, "")
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Next() // welcome message
+	require.NoError(t, err)
+	require.NoError(t, conn.Send("show me wireless headphones"))
+
+	var streamed strings.Builder
+	var streamedID string
+	for {
+		event, err := conn.Next()
+		require.NoError(t, err)
+		if event.Type == chatcommerce.ChatEventDelta {
+			id, delta, err := event.Delta()
+			require.NoError(t, err)
+			streamedID = id
+			streamed.WriteString(delta)
+			continue
+		}
+		if event.Type != chatcommerce.ChatEventMessage {
+			continue
+		}
+		message, err := event.Message()
+		require.NoError(t, err)
+		assert.Equal(t, "Here are some headphones!", streamed.String())
+		assert.Equal(t, streamedID, message.ID, "the final message replaces the streamed one")
+		assert.Equal(t, "Here are some headphones!", message.Content)
+		return
+	}
+}
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	chatcommerce "chat-ecommerce-backend/clients/go"
+	"chat-ecommerce-backend/internal/handlers"
+	"chat-ecommerce-backend/internal/middleware"
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"chat-ecommerce-backend/tests/testutil/factories"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// authMiddlewareSecret is the key middleware.AuthMiddleware checks tokens with
+const authMiddlewareSecret = "your-super-secret-jwt-key-change-this-in-production"
+
+func TestGoClient(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("CHAT_SESSION_SECRET", "chat-session-test-secret")
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	user := f.User()
+	cheap := f.StockedProduct(10, func(p *models.Product) { p.Name = "Canvas Tote"; p.Price = 20 })
+	f.StockedProduct(10, func(p *models.Product) { p.Name = "Leather Bag"; p.Price = 80 })
+
+	productService := services.NewProductService(db)
+	cartService := services.NewShoppingCartService(db)
+	userHandler := handlers.NewUserHandler(services.NewUserService(db), services.NewRefreshTokenService(db), authMiddlewareSecret)
+	productHandler := handlers.NewProductHandler(productService)
+	cartHandler := handlers.NewCartHandler(cartService)
+	chatHandler := handlers.NewChatHandler(services.NewChatService(db, productService, cartService))
+
+	r := gin.New()
+	r.Use(middleware.RateLimitHeadersMiddleware(services.NewRequestThrottle(100, time.Minute)))
+	v1 := r.Group("/api/v1")
+	v1.POST("/auth/login", userHandler.Login)
+	v1.POST("/auth/refresh", userHandler.RefreshToken)
+	v1.GET("/user/profile", middleware.AuthMiddleware(), userHandler.GetProfile)
+	v1.GET("/products/", productHandler.GetProducts)
+	cart := v1.Group("/cart", middleware.OptionalAuthMiddleware(nil))
+	cart.GET("/", cartHandler.GetCart)
+	cart.POST("/add", cartHandler.AddToCart)
+	chat := v1.Group("/chat", middleware.OptionalAuthMiddleware(nil))
+	chat.POST("/session", chatHandler.StartChatSession)
+	chat.GET("/session/:session_id", chatHandler.GetChatSession)
+
+	var busy atomic.Int32
+	v1.GET("/busy", func(c *gin.Context) {
+		if busy.Add(1) == 1 {
+			c.Header("Retry-After", "1")
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "slow down"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": "done"})
+	})
+
+	server := httptest.NewServer(r)
+	defer server.Close()
+	ctx := context.Background()
+
+	t.Run("signs in and calls protected endpoints", func(t *testing.T) {
+		var saved chatcommerce.Tokens
+		client := chatcommerce.New(server.URL, chatcommerce.WithTokenCallback(func(tokens chatcommerce.Tokens) { saved = tokens }))
+		_, err := client.GetProfile(ctx)
+		assert.True(t, chatcommerce.IsUnauthorized(err))
+
+		_, err = client.Login(ctx, user.Email, factories.DefaultPassword)
+		require.NoError(t, err)
+		assert.NotEmpty(t, saved.AccessToken)
+
+		profile, err := client.GetProfile(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, user.ID, profile.ID)
+
+		// An expired access token is refreshed once and the call retried
+		expired := chatcommerce.New(server.URL, chatcommerce.WithTokens(chatcommerce.Tokens{
+			AccessToken:  "expired",
+			RefreshToken: client.Tokens().RefreshToken,
+		}))
+		profile, err = expired.GetProfile(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, user.ID, profile.ID)
+		assert.NotEqual(t, "expired", expired.Tokens().AccessToken)
+	})
+
+	t.Run("lists products with list params", func(t *testing.T) {
+		client := chatcommerce.New(server.URL)
+		list, err := client.ListProducts(ctx, chatcommerce.NewListParams().Filter("price", "lte", "50"))
+		require.NoError(t, err)
+		require.Len(t, list.Products, 1)
+		assert.Equal(t, "Canvas Tote", list.Products[0].Name)
+
+		limit := client.RateLimit()
+		assert.Equal(t, 100, limit.Limit)
+		assert.Less(t, limit.Remaining, 100)
+		assert.True(t, limit.Reset.After(time.Now()))
+	})
+
+	t.Run("keeps the cart session", func(t *testing.T) {
+		client := chatcommerce.New(server.URL)
+		require.NoError(t, client.AddToCart(ctx, cheap.ID, nil, 2))
+		got, err := client.GetCart(ctx)
+		require.NoError(t, err)
+		require.Len(t, got.Items, 1)
+		assert.Equal(t, 2, got.Items[0].Quantity)
+
+		other, err := chatcommerce.New(server.URL).GetCart(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, other.Items, "each client has its own session")
+	})
+
+	t.Run("proves guest chat sessions with the cookie jar", func(t *testing.T) {
+		client := chatcommerce.New(server.URL)
+		session, err := client.StartChatSession(ctx)
+		require.NoError(t, err)
+		got, err := client.GetChatSession(ctx, session.SessionID)
+		require.NoError(t, err)
+		assert.Equal(t, session.SessionID, got.SessionID)
+
+		_, err = chatcommerce.New(server.URL).GetChatSession(ctx, session.SessionID)
+		assert.Error(t, err, "another shopper can't read the session")
+	})
+
+	t.Run("retries throttled requests after Retry-After", func(t *testing.T) {
+		client := chatcommerce.New(server.URL)
+		var data string
+		start := time.Now()
+		require.NoError(t, client.Do(ctx, http.MethodGet, "/api/v1/busy", nil, nil, &struct {
+			Data *string `json:"data"`
+		}{&data}))
+		assert.Equal(t, "done", data)
+		assert.GreaterOrEqual(t, time.Since(start), time.Second)
+
+		busy.Store(0)
+		err := chatcommerce.New(server.URL, chatcommerce.WithRetries(0, 0)).Do(ctx, http.MethodGet, "/api/v1/busy", nil, nil, nil)
+		assert.True(t, chatcommerce.IsRateLimited(err))
+	})
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"chat-ecommerce-backend/internal/middleware"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// maintenanceRecorder records maintenance notices instead of writing to sockets
+type maintenanceRecorder struct {
+	notices map[string][]interface{}
+}
+
+func (r *maintenanceRecorder) NotifySession(sessionID, messageType string, data interface{}) {
+	if messageType == services.MaintenanceNotification {
+		r.notices[sessionID] = append(r.notices[sessionID], data)
+	}
+}
+
+func (r *maintenanceRecorder) ConnectedSessions() []string {
+	return []string{"shopper-1", "shopper-2"}
+}
+
+func TestMaintenanceMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := testutil.NewTestDB(t)
+	ctx := context.Background()
+	maintenance := services.NewMaintenanceService(db)
+
+	r := gin.New()
+	r.Use(middleware.MaintenanceMiddleware(maintenance, "/api/v1/admin"))
+	r.GET("/api/v1/products", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+	r.POST("/api/v1/cart/add", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+	r.DELETE("/api/v1/admin/maintenance", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	send := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w
+	}
+
+	// During the countdown everything still works and shoppers are warned
+	recorder := &maintenanceRecorder{notices: map[string][]interface{}{}}
+	status, err := maintenance.Plan(ctx, services.MaintenanceRequest{StartsInMinutes: 10, DurationMinutes: 30}, nil, time.Now())
+	require.NoError(t, err)
+	assert.True(t, status.Planned)
+	assert.False(t, status.Active)
+	assert.InDelta(t, 600, status.SecondsUntilStart, 1)
+	assert.Equal(t, 2, services.BroadcastMaintenance(recorder, status))
+	assert.Len(t, recorder.notices["shopper-1"], 1)
+	assert.Equal(t, http.StatusNoContent, send(http.MethodPost, "/api/v1/cart/add").Code)
+
+	// Once it starts, writes are refused until the expected end
+	_, err = maintenance.Plan(ctx, services.MaintenanceRequest{DurationMinutes: 30}, nil, time.Now())
+	require.NoError(t, err)
+	refused := send(http.MethodPost, "/api/v1/cart/add")
+	assert.Equal(t, http.StatusServiceUnavailable, refused.Code)
+	assert.Equal(t, "1800", refused.Header().Get("Retry-After"))
+	assert.Equal(t, http.StatusNoContent, send(http.MethodGet, "/api/v1/products").Code, "the catalog stays readable")
+	assert.Equal(t, http.StatusNoContent, send(http.MethodDelete, "/api/v1/admin/maintenance").Code, "admins can still end it")
+
+	require.NoError(t, maintenance.End(ctx, time.Now()))
+	assert.Equal(t, http.StatusNoContent, send(http.MethodPost, "/api/v1/cart/add").Code)
+	assert.ErrorIs(t, maintenance.End(ctx, time.Now()), services.ErrNoMaintenance)
+}
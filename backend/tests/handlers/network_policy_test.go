@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"chat-ecommerce-backend/internal/middleware"
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// issueCertificate signs a certificate for name with parent, or self-signs a
+// CA when parent is nil
+func issueCertificate(t *testing.T, name string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage = x509.KeyUsageCertSign
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert, key
+}
+
+func TestNetworkPolicyMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := testutil.NewTestDB(t)
+	policies := services.NewNetworkPolicyService(db)
+
+	t.Setenv("ADMIN_IP_ALLOWLIST", "10.0.0.0/8, 192.0.2.7")
+	t.Setenv("ADMIN_IP_DENYLIST", "10.9.9.9")
+	adminPolicy, err := services.NetworkPolicyFromEnv(services.NetworkPolicyAdmin, "ADMIN")
+	require.NoError(t, err)
+
+	r := gin.New()
+	require.NoError(t, r.SetTrustedProxies(nil))
+	r.GET("/api/v1/admin/orders", middleware.NetworkPolicyMiddleware(policies, adminPolicy), func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	send := func(remoteAddr string) int {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/admin/orders", nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("X-Forwarded-For", "10.0.0.1") // ignored without trusted proxies
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusNoContent, send("10.1.2.3:5000"))
+	assert.Equal(t, http.StatusNoContent, send("192.0.2.7:5000"))
+	assert.Equal(t, http.StatusForbidden, send("203.0.113.5:5000"))
+	assert.Equal(t, http.StatusForbidden, send("203.0.113.5:5001"), "repeats are refused too")
+	assert.Equal(t, http.StatusForbidden, send("10.9.9.9:5000"), "the denylist wins over the allowlist")
+
+	blocked, err := policies.BlockedRequests(context.Background(), services.NetworkPolicyAdmin, time.Now().Add(-time.Hour), 0)
+	require.NoError(t, err)
+	require.Len(t, blocked, 2, "repeats from one address are recorded once a minute")
+	reasons := map[string]string{}
+	for _, b := range blocked {
+		reasons[b.IPAddress] = b.Reason
+	}
+	assert.Equal(t, services.BlockReasonNotAllowlisted, reasons["203.0.113.5"])
+	assert.Equal(t, services.BlockReasonDenylisted, reasons["10.9.9.9"])
+
+	_, err = services.ParseIPNets("10.0.0.0/8,not-an-address")
+	assert.Error(t, err, "typos fail loudly rather than opening the policy")
+}
+
+func TestNetworkPolicy_ForgedForwardedFor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := testutil.NewTestDB(t)
+	policies := services.NewNetworkPolicyService(db)
+
+	t.Setenv("ADMIN_IP_ALLOWLIST", "10.0.0.0/8")
+	adminPolicy, err := services.NetworkPolicyFromEnv(services.NetworkPolicyAdmin, "ADMIN")
+	require.NoError(t, err)
+
+	send := func(r *gin.Engine, remoteAddr, forwardedFor string) int {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/admin/orders", nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+	router := func(proxies string) *gin.Engine {
+		r := gin.New()
+		require.NoError(t, middleware.TrustProxies(r, proxies, adminPolicy))
+		r.GET("/api/v1/admin/orders", middleware.NetworkPolicyMiddleware(policies, adminPolicy), func(c *gin.Context) { c.Status(http.StatusNoContent) })
+		return r
+	}
+
+	// Without TRUSTED_PROXIES the header is the client's word and ignored
+	direct := router("")
+	assert.Equal(t, http.StatusForbidden, send(direct, "203.0.113.5:5000", "10.0.0.1"), "a forged X-Forwarded-For gets nowhere")
+	assert.Equal(t, http.StatusNoContent, send(direct, "10.1.2.3:5000", "203.0.113.5"))
+
+	// Behind a trusted proxy it names the client
+	proxied := router("172.16.0.10, ")
+	assert.Equal(t, http.StatusNoContent, send(proxied, "172.16.0.10:5000", "10.0.0.1"))
+	assert.Equal(t, http.StatusForbidden, send(proxied, "172.16.0.10:5000", "203.0.113.5"))
+	assert.Equal(t, http.StatusForbidden, send(proxied, "203.0.113.9:5000", "10.0.0.1"), "only the proxy is believed")
+
+	assert.Error(t, middleware.TrustProxies(gin.New(), "not-a-proxy", adminPolicy))
+}
+
+func TestNetworkPolicy_ClientCertificate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := testutil.NewTestDB(t)
+	policies := services.NewNetworkPolicyService(db)
+
+	ca, caKey := issueCertificate(t, "Partner CA", nil, nil)
+	partner, _ := issueCertificate(t, "erp.partner.example", ca, caKey)
+	rogueCA, rogueKey := issueCertificate(t, "Rogue CA", nil, nil)
+	rogue, _ := issueCertificate(t, "erp.partner.example", rogueCA, rogueKey)
+	other, _ := issueCertificate(t, "someone-else.example", ca, caKey)
+
+	policy := &services.NetworkPolicy{
+		Name:        services.NetworkPolicyWebhook,
+		ClientCAs:   x509.NewCertPool(),
+		ClientNames: []string{"erp.partner.example"},
+		CertHeader:  "X-Client-Cert",
+	}
+	policy.ClientCAs.AddCert(ca)
+	policy.Proxies, _ = services.ParseIPNets("172.16.0.10")
+
+	r := gin.New()
+	r.POST("/api/v1/payments/webhook", middleware.NetworkPolicyMiddleware(policies, policy), func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	send := func(remoteAddr string, cert *x509.Certificate) int {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/payments/webhook", nil)
+		req.RemoteAddr = remoteAddr
+		if cert != nil {
+			encoded := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+			req.Header.Set("X-Client-Cert", url.PathEscape(string(encoded)))
+		}
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusNoContent, send("172.16.0.10:4000", partner))
+	assert.Equal(t, http.StatusForbidden, send("172.16.0.10:4001", nil), "a certificate is required")
+	assert.Equal(t, http.StatusForbidden, send("172.16.0.10:4002", rogue), "it must be signed by the partner CA")
+	assert.Equal(t, http.StatusForbidden, send("172.16.0.10:4003", other), "and name an accepted partner")
+	assert.Equal(t, http.StatusForbidden, send("198.51.100.4:4000", partner), "the header is only taken from the proxy")
+
+	var records []models.BlockedRequest
+	require.NoError(t, db.Find(&records).Error)
+	require.Len(t, records, 2, "one record per address and minute")
+	for _, record := range records {
+		assert.Equal(t, services.BlockReasonClientCertificate, record.Reason)
+		assert.NotEmpty(t, record.Detail)
+	}
+}
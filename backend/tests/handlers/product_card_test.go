@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"chat-ecommerce-backend/internal/handlers"
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"chat-ecommerce-backend/tests/testutil/factories"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestChatSuggestions_ProductCards checks that chat suggestions carry compact
+// product cards instead of whole products
+func TestChatSuggestions_ProductCards(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+
+	category := f.Category(func(c *models.Category) { c.Name = "Audio" })
+	product := f.Product(func(p *models.Product) {
+		p.Name = "Wireless Headphones"
+		p.CategoryID = category.ID
+	})
+	f.Image(product, func(i *models.ProductImage) { i.URL = "https://example.com/side.jpg" })
+	f.Image(product, func(i *models.ProductImage) {
+		i.URL = "https://example.com/front.jpg"
+		i.IsPrimary = true
+	})
+	f.Variant(product, func(v *models.ProductVariant) { v.VariantValue = "Black" })
+	f.Variant(product, func(v *models.ProductVariant) { v.VariantValue = "White" })
+	f.Inventory(product, func(i *models.Inventory) {
+		i.QuantityAvailable = 2
+		i.QuantityReserved = 2
+	})
+
+	productService := services.NewProductService(db)
+	chatService := services.NewChatService(db, productService, services.NewShoppingCartService(db))
+	handler := handlers.NewChatHandler(chatService)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/v1/chat/search", handler.SearchProducts)
+
+	req, _ := http.NewRequest("GET", "/api/v1/chat/search?q=headphones", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		Data []struct {
+			Product map[string]interface{} `json:"product"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Len(t, response.Data, 1)
+
+	card := response.Data[0].Product
+	assert.Equal(t, product.ID.String(), card["id"])
+	assert.Equal(t, "https://example.com/front.jpg", card["image_url"], "the primary image is used")
+	assert.Equal(t, "Audio", card["category_name"])
+	assert.Equal(t, false, card["in_stock"], "reserved units aren't in stock")
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"name": "Color", "values": []interface{}{"Black", "White"}},
+	}, card["variants"])
+
+	// Internal fields stay out of the payload
+	assert.NotContains(t, card, "sku")
+	assert.NotContains(t, card, "metadata")
+	assert.NotContains(t, card, "inventory")
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"bytes"
+	"chat-ecommerce-backend/internal/handlers"
+	"chat-ecommerce-backend/internal/middleware"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimitHeaders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.RateLimitHeadersMiddleware(services.NewRequestThrottle(2, time.Minute)))
+	r.GET("/api/v1/products", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"success": true})
+	})
+
+	get := func(remoteAddr string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/products", nil)
+		req.RemoteAddr = remoteAddr
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	first := get("203.0.113.9:1234")
+	assert.Equal(t, "2", first.Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, "1", first.Header().Get("X-RateLimit-Remaining"))
+	assert.Equal(t, "60", first.Header().Get("X-RateLimit-Reset"))
+
+	assert.Equal(t, "0", get("203.0.113.9:1234").Header().Get("X-RateLimit-Remaining"))
+	over := get("203.0.113.9:1234")
+	assert.Equal(t, http.StatusOK, over.Code, "the limit is only advertised")
+	assert.Equal(t, "0", over.Header().Get("X-RateLimit-Remaining"))
+	assert.Equal(t, "1", get("198.51.100.2:1234").Header().Get("X-RateLimit-Remaining"), "addresses are counted apart")
+
+	// Without a limit no headers are sent
+	unlimited := gin.New()
+	unlimited.Use(middleware.RateLimitHeadersMiddleware(services.NewRequestThrottle(0, time.Minute)))
+	unlimited.GET("/health", func(c *gin.Context) { c.Status(http.StatusOK) })
+	w := httptest.NewRecorder()
+	unlimited.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+	assert.Empty(t, w.Header().Get("X-RateLimit-Limit"))
+}
+
+func TestRateLimitHeaders_EndpointThrottle(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("CHAT_SESSION_SECRET", "chat-session-test-secret")
+	t.Setenv("CHAT_HISTORY_RATE_PER_MINUTE", "2")
+	db := testutil.NewTestDB(t)
+	handler := handlers.NewChatHandler(services.NewChatService(db, services.NewProductService(db), services.NewShoppingCartService(db)))
+
+	r := gin.New()
+	r.Use(middleware.RateLimitHeadersMiddleware(services.NewRequestThrottle(100, time.Minute)))
+	r.GET("/api/v1/chat/history/:session_id", handler.GetChatHistory)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/chat/history/cs_guess."+uuid.New().String(), nil))
+	assert.Equal(t, "2", w.Header().Get("X-RateLimit-Limit"), "chat history reports its own, stricter limit")
+	assert.Equal(t, "1", w.Header().Get("X-RateLimit-Remaining"))
+}
+
+func TestChatLimit_SendMessage(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("CHAT_SESSION_SECRET", "chat-session-test-secret")
+	db := testutil.NewTestDB(t)
+	limits := services.NewChatLimiter(db, services.ChatLimitConfig{UserPerMinute: 1})
+	chatService := services.NewChatServiceWithProvider(db, services.NewFakeLLM().Fallback(services.FakeLLMResponse{Content: "Sure!"}), services.NewProductService(db), services.NewShoppingCartService(db)).
+		WithLimits(limits)
+	handler := handlers.NewChatHandler(chatService)
+
+	userID := uuid.NewString()
+	r := gin.New()
+	r.Use(func(c *gin.Context) { c.Set("user_id", userID) })
+	r.POST("/api/v1/chat/message", handler.SendMessage)
+
+	send := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/chat/message", bytes.NewBufferString(`{"message": "show me boots"}`))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, send().Code)
+	limited := send()
+	assert.Equal(t, http.StatusTooManyRequests, limited.Code)
+	assert.NotEmpty(t, limited.Header().Get("Retry-After"))
+	var body map[string]interface{}
+	require.NoError(t, json.Unmarshal(limited.Body.Bytes(), &body))
+	assert.Equal(t, services.ChatLimitRate, body["code"])
+	assert.Contains(t, body["error"], "faster than I can keep up")
+}
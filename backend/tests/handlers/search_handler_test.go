@@ -5,6 +5,7 @@ import (
 	"chat-ecommerce-backend/internal/dto"
 	searchhandlers "chat-ecommerce-backend/internal/handlers/search"
 	searchservices "chat-ecommerce-backend/internal/services/search"
+	"chat-ecommerce-backend/tests/testutil"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
@@ -12,15 +13,13 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
-	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
 func setupTestHandler(t *testing.T) (*searchhandlers.Handler, *gorm.DB) {
-	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
-	if err != nil {
-		t.Fatal("Failed to connect to test database:", err)
-	}
+	// Searches use PostgreSQL full-text queries
+	testutil.RequirePostgres(t)
+	db := testutil.NewTestDB(t)
 
 	searchService := searchservices.NewService(db)
 	handler := searchhandlers.NewHandler(searchService)
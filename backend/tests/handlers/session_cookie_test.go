@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"bytes"
+	"chat-ecommerce-backend/internal/handlers"
+	"chat-ecommerce-backend/internal/middleware"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/pkg/auth"
+	"chat-ecommerce-backend/tests/testutil"
+	"chat-ecommerce-backend/tests/testutil/factories"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCSRFMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.CSRFMiddleware())
+	r.POST("/change", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+	r.GET("/read", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	send := func(method, path string, cookies map[string]string, headers map[string]string) int {
+		req := httptest.NewRequest(method, path, nil)
+		for name, value := range cookies {
+			req.AddCookie(&http.Cookie{Name: name, Value: value})
+		}
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+	session := map[string]string{auth.AccessTokenCookie: "jwt", auth.CSRFCookie: "csrf-123"}
+
+	assert.Equal(t, http.StatusNoContent, send(http.MethodPost, "/change", nil, nil), "no cookie session, nothing to forge")
+	assert.Equal(t, http.StatusNoContent, send(http.MethodGet, "/read", session, nil), "safe methods pass")
+	assert.Equal(t, http.StatusForbidden, send(http.MethodPost, "/change", session, nil))
+	assert.Equal(t, http.StatusForbidden, send(http.MethodPost, "/change", session, map[string]string{auth.CSRFHeader: "wrong"}))
+	assert.Equal(t, http.StatusNoContent, send(http.MethodPost, "/change", session, map[string]string{auth.CSRFHeader: "csrf-123"}))
+	assert.Equal(t, http.StatusNoContent, send(http.MethodPost, "/change", session, map[string]string{"Authorization": "Bearer jwt"}), "bearer clients aren't affected")
+}
+
+func TestUserHandler_CookieSessionLogin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	user := f.User()
+	handler := handlers.NewUserHandler(services.NewUserService(db), services.NewRefreshTokenService(db), "test-secret")
+
+	login := func(clientType string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(map[string]string{"email": user.Email, "password": factories.DefaultPassword})
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/login", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		if clientType != "" {
+			req.Header.Set(auth.ClientTypeHeader, clientType)
+		}
+		w := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(w)
+		ctx.Request = req
+		handler.Login(ctx)
+		return w
+	}
+
+	// API and mobile clients get their tokens in the body
+	w := login("")
+	require.Equal(t, http.StatusOK, w.Code)
+	var response map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.NotEmpty(t, response["token"])
+	assert.NotEmpty(t, response["refresh_token"])
+	assert.Empty(t, w.Result().Cookies())
+
+	// Browser clients get httpOnly cookies instead
+	w = login(auth.ClientTypeWeb)
+	require.Equal(t, http.StatusOK, w.Code)
+	response = map[string]interface{}{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Nil(t, response["token"])
+	assert.Nil(t, response["refresh_token"])
+
+	cookies := map[string]*http.Cookie{}
+	for _, cookie := range w.Result().Cookies() {
+		cookies[cookie.Name] = cookie
+	}
+	require.Contains(t, cookies, auth.AccessTokenCookie)
+	require.Contains(t, cookies, auth.RefreshTokenCookie)
+	require.Contains(t, cookies, auth.CSRFCookie)
+	assert.True(t, cookies[auth.AccessTokenCookie].HttpOnly)
+	assert.True(t, cookies[auth.RefreshTokenCookie].HttpOnly)
+	assert.False(t, cookies[auth.CSRFCookie].HttpOnly, "the storefront reads the CSRF token to echo it")
+}
+
+func TestOptionalAuthMiddleware_RevokedSignIn(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	user := f.User()
+	refreshTokens := services.NewRefreshTokenService(db)
+	// The secret auth.ValidateToken checks tokens with
+	handler := handlers.NewUserHandler(services.NewUserService(db), refreshTokens, "your-super-secret-jwt-key-change-this-in-production")
+
+	body, _ := json.Marshal(map[string]string{"email": user.Email, "password": factories.DefaultPassword})
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/login", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	ctx.Request = req
+	handler.Login(ctx)
+	require.Equal(t, http.StatusOK, w.Code)
+	var tokens struct {
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &tokens))
+
+	r := gin.New()
+	r.GET("/cart", middleware.OptionalAuthMiddleware(refreshTokens), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"user_id": c.GetString("user_id"), "session_id": c.GetString("session_id")})
+	})
+	whoAmI := func() map[string]string {
+		req := httptest.NewRequest(http.MethodGet, "/cart", nil)
+		req.Header.Set("Authorization", "Bearer "+tokens.Token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		var seen map[string]string
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &seen))
+		return seen
+	}
+
+	seen := whoAmI()
+	assert.Equal(t, user.ID.String(), seen["user_id"])
+	assert.NotEmpty(t, seen["session_id"], "the sign-in is known, so it can be checked for revocation")
+
+	require.NoError(t, refreshTokens.Revoke(context.Background(), tokens.RefreshToken))
+	seen = whoAmI()
+	assert.Empty(t, seen["user_id"], "a logged out token is treated as anonymous")
+	assert.Empty(t, seen["session_id"])
+}
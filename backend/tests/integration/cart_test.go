@@ -6,6 +6,8 @@ import (
 	"chat-ecommerce-backend/internal/middleware"
 	"chat-ecommerce-backend/internal/models"
 	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"chat-ecommerce-backend/tests/testutil/factories"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -17,7 +19,6 @@ import (
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
-	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
@@ -32,14 +33,8 @@ type CartIntegrationTestSuite struct {
 }
 
 func (suite *CartIntegrationTestSuite) SetupSuite() {
-	// Setup in-memory SQLite database for testing
-	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
-	if err != nil {
-		suite.T().Fatal("Failed to connect to test database:", err)
-	}
-
-	// Auto-migrate the database
-	err = db.AutoMigrate(
+	// Setup test database (SQLite by default, PostgreSQL with TEST_DB_DRIVER=postgres)
+	db := testutil.NewTestDB(suite.T(),
 		&models.User{},
 		&models.Category{},
 		&models.Product{},
@@ -49,9 +44,6 @@ func (suite *CartIntegrationTestSuite) SetupSuite() {
 		&models.ShoppingCart{},
 		&models.CartItem{},
 	)
-	if err != nil {
-		suite.T().Fatal("Failed to migrate test database:", err)
-	}
 
 	suite.db = db
 	suite.cartService = services.NewShoppingCartService(db)
@@ -99,33 +91,7 @@ func (suite *CartIntegrationTestSuite) TearDownSuite() {
 }
 
 func (suite *CartIntegrationTestSuite) createTestProduct() *models.Product {
-	category := &models.Category{
-		ID:   uuid.New(),
-		Name: "Test Category",
-		Slug: "test-category",
-	}
-	suite.db.Create(category)
-
-	product := &models.Product{
-		ID:          uuid.New(),
-		Name:        "Test Product",
-		Description: "Test Product Description",
-		Price:       99.99,
-		CategoryID:  category.ID,
-		SKU:         "TEST-001",
-		Status:      "active",
-	}
-	suite.db.Create(product)
-
-	// Create inventory
-	inventory := &models.Inventory{
-		ID:        uuid.New(),
-		ProductID: product.ID,
-		Quantity:  100,
-	}
-	suite.db.Create(inventory)
-
-	return product
+	return factories.New(suite.T(), suite.db).StockedProduct(100)
 }
 
 func (suite *CartIntegrationTestSuite) TestAddToCart() {
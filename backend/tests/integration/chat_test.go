@@ -5,19 +5,19 @@ import (
 	"chat-ecommerce-backend/internal/handlers"
 	"chat-ecommerce-backend/internal/models"
 	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"chat-ecommerce-backend/tests/testutil/factories"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
-	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
-	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
@@ -34,12 +34,8 @@ type ChatIntegrationTestSuite struct {
 }
 
 func (suite *ChatIntegrationTestSuite) SetupSuite() {
-	// Setup test database
-	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
-	suite.Require().NoError(err)
-
-	// Auto-migrate
-	err = db.AutoMigrate(
+	// Setup test database (SQLite by default, PostgreSQL with TEST_DB_DRIVER=postgres)
+	db := testutil.NewTestDB(suite.T(),
 		&models.ChatSession{},
 		&models.ChatMessage{},
 		&models.Product{},
@@ -47,7 +43,6 @@ func (suite *ChatIntegrationTestSuite) SetupSuite() {
 		&models.ShoppingCart{},
 		&models.CartItem{},
 	)
-	suite.Require().NoError(err)
 
 	suite.db = db
 
@@ -63,47 +58,45 @@ func (suite *ChatIntegrationTestSuite) SetupSuite() {
 	// Setup router
 	suite.setupRoutes()
 
-	// Generate test session and user
-	suite.sessionID = fmt.Sprintf("test-session-%d", time.Now().Unix())
+	// Start a test session; its cookie proves the anonymous shopper owns it
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, httptest.NewRequest("POST", "/api/v1/chat/session", nil))
+	suite.Require().Equal(http.StatusCreated, w.Code)
+	var started struct {
+		Data struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &started))
+	suite.sessionID = started.Data.ID
 	suite.userID = uuid.New()
 }
 
-func (suite *ChatIntegrationTestSuite) setupTestData() {
-	// Create test category
-	category := models.Category{
-		ID:          uuid.New(),
-		Name:        "Test Category",
-		Description: "Test category for chat tests",
-		Slug:        "test-category",
-		IsActive:    true,
-	}
-	suite.db.Create(&category)
-
-	// Create test products
-	products := []models.Product{
-		{
-			ID:          uuid.New(),
-			Name:        "Test Product 1",
-			Description: "First test product",
-			Price:       99.99,
-			CategoryID:  category.ID,
-			SKU:         "TEST-001",
-			Status:      "active",
-		},
-		{
-			ID:          uuid.New(),
-			Name:        "Test Product 2",
-			Description: "Second test product",
-			Price:       149.99,
-			CategoryID:  category.ID,
-			SKU:         "TEST-002",
-			Status:      "active",
-		},
-	}
+// withSession adds the session cookie to a request
+func (suite *ChatIntegrationTestSuite) withSession(req *http.Request) *http.Request {
+	req.AddCookie(&http.Cookie{Name: services.ChatSessionCookie, Value: suite.sessionID})
+	return req
+}
 
-	for _, product := range products {
-		suite.db.Create(&product)
-	}
+func (suite *ChatIntegrationTestSuite) setupTestData() {
+	f := factories.New(suite.T(), suite.db)
+
+	category := f.Category(func(c *models.Category) {
+		c.Name = "Test Category"
+		c.Description = "Test category for chat tests"
+	})
+
+	f.Product(func(p *models.Product) {
+		p.Name = "Test Product 1"
+		p.Description = "First test product"
+		p.CategoryID = category.ID
+	})
+	f.Product(func(p *models.Product) {
+		p.Name = "Test Product 2"
+		p.Description = "Second test product"
+		p.Price = 149.99
+		p.CategoryID = category.ID
+	})
 }
 
 func (suite *ChatIntegrationTestSuite) setupRoutes() {
@@ -120,6 +113,7 @@ func (suite *ChatIntegrationTestSuite) setupRoutes() {
 			chat.GET("/history/:session_id", suite.chatHandler.GetChatHistory)
 			chat.GET("/suggestions", suite.chatHandler.GetProductSuggestions)
 			chat.GET("/search", suite.chatHandler.SearchProducts)
+			chat.POST("/session", suite.chatHandler.StartChatSession)
 			chat.GET("/session/:session_id", suite.chatHandler.GetChatSession)
 		}
 	}
@@ -140,7 +134,7 @@ func (suite *ChatIntegrationTestSuite) TestSendMessage() {
 	}
 
 	jsonBody, _ := json.Marshal(reqBody)
-	req := httptest.NewRequest("POST", "/api/v1/chat/message", bytes.NewBuffer(jsonBody))
+	req := suite.withSession(httptest.NewRequest("POST", "/api/v1/chat/message", bytes.NewBuffer(jsonBody)))
 	req.Header.Set("Content-Type", "application/json")
 
 	w := httptest.NewRecorder()
@@ -163,7 +157,7 @@ func (suite *ChatIntegrationTestSuite) TestGetChatHistory() {
 	}
 
 	jsonBody, _ := json.Marshal(reqBody)
-	req := httptest.NewRequest("POST", "/api/v1/chat/message", bytes.NewBuffer(jsonBody))
+	req := suite.withSession(httptest.NewRequest("POST", "/api/v1/chat/message", bytes.NewBuffer(jsonBody)))
 	req.Header.Set("Content-Type", "application/json")
 
 	w := httptest.NewRecorder()
@@ -171,7 +165,7 @@ func (suite *ChatIntegrationTestSuite) TestGetChatHistory() {
 	assert.Equal(suite.T(), http.StatusOK, w.Code)
 
 	// Now get chat history
-	req = httptest.NewRequest("GET", fmt.Sprintf("/api/v1/chat/history/%s", suite.sessionID), nil)
+	req = suite.withSession(httptest.NewRequest("GET", fmt.Sprintf("/api/v1/chat/history/%s", suite.sessionID), nil))
 	w = httptest.NewRecorder()
 	suite.router.ServeHTTP(w, req)
 
@@ -185,7 +179,7 @@ func (suite *ChatIntegrationTestSuite) TestGetChatHistory() {
 }
 
 func (suite *ChatIntegrationTestSuite) TestGetProductSuggestions() {
-	req := httptest.NewRequest("GET", fmt.Sprintf("/api/v1/chat/suggestions?session_id=%s", suite.sessionID), nil)
+	req := suite.withSession(httptest.NewRequest("GET", fmt.Sprintf("/api/v1/chat/suggestions?session_id=%s", suite.sessionID), nil))
 	w := httptest.NewRecorder()
 	suite.router.ServeHTTP(w, req)
 
@@ -213,7 +207,7 @@ func (suite *ChatIntegrationTestSuite) TestSearchProducts() {
 }
 
 func (suite *ChatIntegrationTestSuite) TestGetChatSession() {
-	req := httptest.NewRequest("GET", fmt.Sprintf("/api/v1/chat/session/%s", suite.sessionID), nil)
+	req := suite.withSession(httptest.NewRequest("GET", fmt.Sprintf("/api/v1/chat/session/%s", suite.sessionID), nil))
 	w := httptest.NewRecorder()
 	suite.router.ServeHTTP(w, req)
 
@@ -232,7 +226,7 @@ func (suite *ChatIntegrationTestSuite) TestWebSocketConnection() {
 	defer server.Close()
 
 	wsURL := fmt.Sprintf("ws://%s/api/v1/chat/ws?session_id=%s", server.URL[7:], suite.sessionID)
-	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, http.Header{"Cookie": {services.ChatSessionCookie + "=" + suite.sessionID}})
 	suite.Require().NoError(err)
 	defer conn.Close()
 
@@ -264,7 +258,7 @@ func (suite *ChatIntegrationTestSuite) TestWebSocketTypingIndicator() {
 	defer server.Close()
 
 	wsURL := fmt.Sprintf("ws://%s/api/v1/chat/ws?session_id=%s", server.URL[7:], suite.sessionID)
-	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, http.Header{"Cookie": {services.ChatSessionCookie + "=" + suite.sessionID}})
 	suite.Require().NoError(err)
 	defer conn.Close()
 
@@ -6,6 +6,7 @@ import (
 	searchhandlers "chat-ecommerce-backend/internal/handlers/search"
 	"chat-ecommerce-backend/internal/models"
 	searchservices "chat-ecommerce-backend/internal/services/search"
+	"chat-ecommerce-backend/tests/testutil"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
@@ -15,7 +16,6 @@ import (
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
-	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
@@ -31,14 +31,8 @@ type SearchIntegrationTestSuite struct {
 }
 
 func (suite *SearchIntegrationTestSuite) SetupSuite() {
-	// Setup in-memory SQLite database for testing
-	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
-	if err != nil {
-		suite.T().Fatal("Failed to connect to test database:", err)
-	}
-
-	// Auto-migrate the database
-	err = db.AutoMigrate(
+	// Setup test database (SQLite by default, PostgreSQL with TEST_DB_DRIVER=postgres)
+	db := testutil.NewTestDB(suite.T(),
 		&models.User{},
 		&models.Category{},
 		&models.Product{},
@@ -46,9 +40,6 @@ func (suite *SearchIntegrationTestSuite) SetupSuite() {
 		&models.ProductImage{},
 		&models.Inventory{},
 	)
-	if err != nil {
-		suite.T().Fatal("Failed to migrate test database:", err)
-	}
 
 	suite.db = db
 	suite.searchService = searchservices.NewService(db)
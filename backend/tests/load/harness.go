@@ -0,0 +1,341 @@
+package load
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// Config describes a load run against a running backend instance
+type Config struct {
+	BaseURL           string
+	Clients           int
+	MessagesPerClient int
+	CartOpsPerClient  int
+	ProductID         string
+	ResponseTimeout   time.Duration
+	MaxP95            time.Duration
+}
+
+// ConfigFromEnv builds a Config from LOAD_TEST_* environment variables.
+// The second return value is false when LOAD_TEST_BASE_URL is not set,
+// in which case load tests should be skipped.
+func ConfigFromEnv() (Config, bool) {
+	cfg := Config{
+		BaseURL:           strings.TrimRight(os.Getenv("LOAD_TEST_BASE_URL"), "/"),
+		Clients:           envInt("LOAD_TEST_CLIENTS", 10),
+		MessagesPerClient: envInt("LOAD_TEST_MESSAGES", 5),
+		CartOpsPerClient:  envInt("LOAD_TEST_CART_OPS", 2),
+		ProductID:         os.Getenv("LOAD_TEST_PRODUCT_ID"),
+		ResponseTimeout:   time.Duration(envInt("LOAD_TEST_TIMEOUT_MS", 30000)) * time.Millisecond,
+		MaxP95:            time.Duration(envInt("LOAD_TEST_P95_MS", 5000)) * time.Millisecond,
+	}
+	return cfg, cfg.BaseURL != ""
+}
+
+func envInt(key string, fallback int) int {
+	if v := os.Getenv(key); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return n
+		}
+	}
+	return fallback
+}
+
+// Result aggregates latencies and delivery counts for a load run
+type Result struct {
+	ChatLatencies []time.Duration
+	CartLatencies []time.Duration
+	ChatSent      int
+	ChatReceived  int
+	CartSent      int
+	CartSucceeded int
+	Errors        []string
+}
+
+// Lost returns the number of chat messages that never got an assistant reply
+func (r *Result) Lost() int {
+	return r.ChatSent - r.ChatReceived
+}
+
+// ChatP95 returns the 95th percentile chat round-trip latency
+func (r *Result) ChatP95() time.Duration {
+	return Percentile(r.ChatLatencies, 95)
+}
+
+// CartP95 returns the 95th percentile cart request latency
+func (r *Result) CartP95() time.Duration {
+	return Percentile(r.CartLatencies, 95)
+}
+
+// Percentile returns the p-th percentile of the given durations
+func Percentile(durations []time.Duration, p float64) time.Duration {
+	if len(durations) == 0 {
+		return 0
+	}
+	sorted := make([]time.Duration, len(durations))
+	copy(sorted, durations)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	idx := int(float64(len(sorted))*p/100+0.5) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	if idx >= len(sorted) {
+		idx = len(sorted) - 1
+	}
+	return sorted[idx]
+}
+
+// Summary returns a one-line human readable report
+func (r *Result) Summary() string {
+	return fmt.Sprintf("chat sent=%d received=%d lost=%d p95=%s | cart sent=%d ok=%d p95=%s | errors=%d",
+		r.ChatSent, r.ChatReceived, r.Lost(), r.ChatP95(),
+		r.CartSent, r.CartSucceeded, r.CartP95(), len(r.Errors))
+}
+
+// wsMessage mirrors the envelope used by the chat WebSocket endpoint. Data
+// isn't always an object, as with suggestions, so it's decoded as needed.
+type wsMessage struct {
+	Type      string          `json:"type"`
+	Data      json.RawMessage `json:"data"`
+	SessionID string          `json:"session_id"`
+	Nonce     string          `json:"nonce,omitempty"`
+	Timestamp *time.Time      `json:"timestamp,omitempty"`
+}
+
+// field returns a string field of the message's data, or "" when it has none
+func (m *wsMessage) field(name string) string {
+	var data map[string]interface{}
+	json.Unmarshal(m.Data, &data)
+	value, _ := data[name].(string)
+	return value
+}
+
+var chatPrompts = []string{
+	"Hi, I'm looking for headphones",
+	"Show me something under $100",
+	"What laptops do you have?",
+	"Do you have any running shoes?",
+	"What's in my cart?",
+}
+
+// Run simulates cfg.Clients concurrent shoppers. Each client opens a chat
+// WebSocket, sends its messages sequentially waiting for the assistant reply,
+// and interleaves cart additions over HTTP using the session the server
+// issued with the chat handshake.
+func Run(cfg Config) *Result {
+	result := &Result{}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	productID := cfg.ProductID
+	if productID == "" && cfg.CartOpsPerClient > 0 {
+		id, err := firstProductID(cfg.BaseURL)
+		if err != nil {
+			result.Errors = append(result.Errors, err.Error())
+		}
+		productID = id
+	}
+
+	for i := 0; i < cfg.Clients; i++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			local := runClient(cfg, productID)
+
+			mu.Lock()
+			defer mu.Unlock()
+			result.ChatLatencies = append(result.ChatLatencies, local.ChatLatencies...)
+			result.CartLatencies = append(result.CartLatencies, local.CartLatencies...)
+			result.ChatSent += local.ChatSent
+			result.ChatReceived += local.ChatReceived
+			result.CartSent += local.CartSent
+			result.CartSucceeded += local.CartSucceeded
+			for _, e := range local.Errors {
+				result.Errors = append(result.Errors, fmt.Sprintf("client %d: %s", n, e))
+			}
+		}(i)
+	}
+
+	wg.Wait()
+	return result
+}
+
+func runClient(cfg Config, productID string) *Result {
+	result := &Result{}
+
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL(cfg.BaseURL), nil)
+	if err != nil {
+		result.Errors = append(result.Errors, fmt.Sprintf("dial: %v", err))
+		result.ChatSent = cfg.MessagesPerClient
+		return result
+	}
+	defer conn.Close()
+
+	// The welcome message names the signed session the server started for
+	// this shopper, which the chat and cart calls go on with
+	conn.SetReadDeadline(time.Now().Add(cfg.ResponseTimeout))
+	var welcome wsMessage
+	if err := conn.ReadJSON(&welcome); err != nil || welcome.SessionID == "" {
+		result.Errors = append(result.Errors, fmt.Sprintf("welcome: no session issued: %v", err))
+		result.ChatSent = cfg.MessagesPerClient
+		return result
+	}
+	sessionID := welcome.SessionID
+
+	client := &http.Client{Timeout: cfg.ResponseTimeout}
+
+	for i := 0; i < cfg.MessagesPerClient; i++ {
+		result.ChatSent++
+		latency, err := chatRoundTrip(conn, sessionID, chatPrompts[i%len(chatPrompts)], cfg.ResponseTimeout)
+		if err != nil {
+			result.Errors = append(result.Errors, err.Error())
+			if websocket.IsUnexpectedCloseError(err) || isTimeout(err) {
+				result.ChatSent += cfg.MessagesPerClient - i - 1
+				break
+			}
+			continue
+		}
+		result.ChatReceived++
+		result.ChatLatencies = append(result.ChatLatencies, latency)
+
+		if productID != "" && i < cfg.CartOpsPerClient {
+			result.CartSent++
+			latency, err := addToCart(client, cfg.BaseURL, sessionID, productID)
+			if err != nil {
+				result.Errors = append(result.Errors, err.Error())
+				continue
+			}
+			result.CartSucceeded++
+			result.CartLatencies = append(result.CartLatencies, latency)
+		}
+	}
+
+	return result
+}
+
+// chatRoundTrip sends one chat message and waits for the assistant reply,
+// skipping typing indicators and auxiliary action/suggestion frames.
+func chatRoundTrip(conn *websocket.Conn, sessionID, content string, timeout time.Duration) (time.Duration, error) {
+	start := time.Now()
+	// Chat messages carry a fresh nonce and timestamp, or they're refused as replays
+	data, err := json.Marshal(map[string]interface{}{"content": content})
+	if err != nil {
+		return 0, err
+	}
+	msg := wsMessage{
+		Type:      "message",
+		Data:      data,
+		SessionID: sessionID,
+		Nonce:     strings.ReplaceAll(uuid.NewString(), "-", ""),
+		Timestamp: &start,
+	}
+	if err := conn.WriteJSON(msg); err != nil {
+		return 0, fmt.Errorf("write: %w", err)
+	}
+
+	conn.SetReadDeadline(start.Add(timeout))
+	for {
+		var reply wsMessage
+		if err := conn.ReadJSON(&reply); err != nil {
+			return 0, fmt.Errorf("read: %w", err)
+		}
+		switch reply.Type {
+		case "message":
+			if reply.field("role") == "assistant" {
+				return time.Since(start), nil
+			}
+		case "error":
+			return 0, fmt.Errorf("server error: %s", reply.field("message"))
+		}
+	}
+}
+
+func addToCart(client *http.Client, baseURL, sessionID, productID string) (time.Duration, error) {
+	body, _ := json.Marshal(map[string]interface{}{
+		"product_id": productID,
+		"quantity":   1,
+	})
+	req, err := http.NewRequest(http.MethodPost, baseURL+"/api/v1/cart/add", bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Session-ID", sessionID)
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("cart add: %w", err)
+	}
+	defer resp.Body.Close()
+	latency := time.Since(start)
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("cart add: unexpected status %d", resp.StatusCode)
+	}
+	return latency, nil
+}
+
+func firstProductID(baseURL string) (string, error) {
+	resp, err := http.Get(baseURL + "/api/v1/products?limit=1")
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch product for cart ops: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var list struct {
+		Products []struct {
+			ID string `json:"id"`
+		} `json:"products"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return "", fmt.Errorf("failed to decode product list: %w", err)
+	}
+	if len(list.Products) == 0 {
+		return "", fmt.Errorf("no products available for cart ops")
+	}
+	return list.Products[0].ID, nil
+}
+
+func wsURL(baseURL string) string {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return baseURL
+	}
+	switch u.Scheme {
+	case "https":
+		u.Scheme = "wss"
+	default:
+		u.Scheme = "ws"
+	}
+	u.Path = strings.TrimRight(u.Path, "/") + "/api/v1/chat/ws"
+	return u.String()
+}
+
+func isTimeout(err error) bool {
+	type timeout interface{ Timeout() bool }
+	for err != nil {
+		if t, ok := err.(timeout); ok && t.Timeout() {
+			return true
+		}
+		u, ok := err.(interface{ Unwrap() error })
+		if !ok {
+			return false
+		}
+		err = u.Unwrap()
+	}
+	return false
+}
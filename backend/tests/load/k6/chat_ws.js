@@ -0,0 +1,82 @@
+// k6 scenario for the chat WebSocket and cart endpoints.
+// Run via `go test ./tests/load -run TestK6Scripts` or directly:
+//   BASE_URL=http://localhost:8080 k6 run tests/load/k6/chat_ws.js
+import http from 'k6/http';
+import ws from 'k6/ws';
+import { check } from 'k6';
+import { Counter, Trend } from 'k6/metrics';
+
+const BASE_URL = __ENV.BASE_URL || 'http://localhost:8080';
+const WS_URL = BASE_URL.replace(/^http/, 'ws') + '/api/v1/chat/ws';
+
+const chatLatency = new Trend('chat_latency', true);
+const chatLost = new Counter('chat_lost');
+
+export const options = {
+  vus: Number(__ENV.VUS || 10),
+  duration: __ENV.DURATION || '30s',
+  thresholds: {
+    chat_latency: ['p(95)<5000'],
+    chat_lost: ['count==0'],
+    http_req_failed: ['rate<0.01'],
+  },
+};
+
+export function setup() {
+  const res = http.get(`${BASE_URL}/api/v1/products?limit=1`);
+  const products = res.json('products') || [];
+  return { productId: products.length > 0 ? products[0].id : null };
+}
+
+export default function (data) {
+  // The server issues the signed session; its cookie, kept in the VU's jar,
+  // proves the WebSocket is this shopper's
+  const session = http.post(`${BASE_URL}/api/v1/chat/session`);
+  check(session, { 'chat session started': (r) => r.status === 201 });
+  const sessionId = session.json('data.session_id');
+
+  if (data.productId) {
+    const res = http.post(
+      `${BASE_URL}/api/v1/cart/add`,
+      JSON.stringify({ product_id: data.productId, quantity: 1 }),
+      { headers: { 'Content-Type': 'application/json', 'X-Session-ID': sessionId } },
+    );
+    check(res, { 'cart add 200': (r) => r.status === 200 });
+  }
+
+  const res = ws.connect(`${WS_URL}?session_id=${sessionId}`, {}, (socket) => {
+    let sentAt = 0;
+    let pending = false;
+
+    socket.on('message', (raw) => {
+      const msg = JSON.parse(raw);
+      if (msg.type === 'message' && msg.data && msg.data.role === 'assistant') {
+        if (pending) {
+          chatLatency.add(Date.now() - sentAt);
+          pending = false;
+          socket.close();
+          return;
+        }
+        sentAt = Date.now();
+        pending = true;
+        // Chat messages carry a fresh nonce and timestamp, or they're refused as replays
+        socket.send(JSON.stringify({
+          type: 'message',
+          session_id: sessionId,
+          nonce: `k6-${__VU}-${__ITER}-${sentAt}-${Math.random().toString(36).slice(2)}`,
+          timestamp: new Date(sentAt).toISOString(),
+          data: { content: 'Show me some headphones' },
+        }));
+      }
+    });
+
+    socket.setTimeout(() => {
+      if (pending) {
+        chatLost.add(1);
+      }
+      socket.close();
+    }, 30000);
+  });
+
+  check(res, { 'ws upgraded': (r) => r && r.status === 101 });
+}
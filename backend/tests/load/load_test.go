@@ -0,0 +1,86 @@
+package load
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestPercentile checks the percentile helper used for latency assertions
+func TestPercentile(t *testing.T) {
+	var durations []time.Duration
+	for i := 1; i <= 100; i++ {
+		durations = append(durations, time.Duration(i)*time.Millisecond)
+	}
+
+	assert.Equal(t, 95*time.Millisecond, Percentile(durations, 95))
+	assert.Equal(t, 50*time.Millisecond, Percentile(durations, 50))
+	assert.Equal(t, 100*time.Millisecond, Percentile(durations, 100))
+	assert.Equal(t, time.Duration(0), Percentile(nil, 95))
+}
+
+// TestWebSocketChatLoad runs the soak scenario against LOAD_TEST_BASE_URL.
+// It asserts that no chat message is lost and p95 stays under LOAD_TEST_P95_MS.
+func TestWebSocketChatLoad(t *testing.T) {
+	cfg, ok := ConfigFromEnv()
+	if !ok {
+		t.Skip("LOAD_TEST_BASE_URL not set, skipping load test")
+	}
+
+	result := Run(cfg)
+	t.Log(result.Summary())
+	for _, e := range result.Errors {
+		t.Log(e)
+	}
+
+	assert.Equal(t, cfg.Clients*cfg.MessagesPerClient, result.ChatSent)
+	assert.Zero(t, result.Lost(), "chat messages lost")
+	assert.Equal(t, result.CartSent, result.CartSucceeded, "cart operations failed")
+	assert.LessOrEqual(t, result.ChatP95(), cfg.MaxP95, "chat p95 latency too high")
+	assert.LessOrEqual(t, result.CartP95(), cfg.MaxP95, "cart p95 latency too high")
+}
+
+// BenchmarkWebSocketChat measures a single client's chat round trip
+func BenchmarkWebSocketChat(b *testing.B) {
+	cfg, ok := ConfigFromEnv()
+	if !ok {
+		b.Skip("LOAD_TEST_BASE_URL not set, skipping load benchmark")
+	}
+	cfg.Clients = 1
+	cfg.MessagesPerClient = b.N
+	cfg.CartOpsPerClient = 0
+
+	b.ResetTimer()
+	result := Run(cfg)
+	b.StopTimer()
+
+	b.ReportMetric(float64(result.ChatP95().Milliseconds()), "p95-ms")
+	b.ReportMetric(float64(result.Lost()), "lost")
+}
+
+// TestK6Scripts runs the k6 scenarios when k6 is installed
+func TestK6Scripts(t *testing.T) {
+	cfg, ok := ConfigFromEnv()
+	if !ok {
+		t.Skip("LOAD_TEST_BASE_URL not set, skipping k6 scenarios")
+	}
+	k6, err := exec.LookPath("k6")
+	if err != nil {
+		t.Skip("k6 not found on PATH")
+	}
+
+	scripts, _ := filepath.Glob(filepath.Join("k6", "*.js"))
+	for _, script := range scripts {
+		t.Run(filepath.Base(script), func(t *testing.T) {
+			cmd := exec.Command(k6, "run", "--quiet", script)
+			cmd.Env = append(os.Environ(), "BASE_URL="+cfg.BaseURL)
+			output, err := cmd.CombinedOutput()
+			t.Log(string(output))
+			assert.NoError(t, err, "k6 thresholds failed")
+		})
+	}
+}
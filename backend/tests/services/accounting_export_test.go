@@ -0,0 +1,103 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"chat-ecommerce-backend/tests/testutil/factories"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccountingExport_JournalsAndHistory(t *testing.T) {
+	t.Setenv("ACCOUNTING_ACCOUNTS", "sales=Sales,clearing.stripe=Stripe Clearing,bogus=1")
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	ctx := context.Background()
+	finance := services.NewFinanceService(db).WithFees(map[string]services.PaymentFee{
+		services.PaymentProviderStripe: {Percent: 2.9, Fixed: 0.30},
+	})
+	config := services.AccountingExportConfig{Format: services.AccountingFormatXero, Accounts: services.AccountingAccountsFromEnv()}
+	accounting := services.NewAccountingExportService(db, config)
+
+	// $100 of goods, $8 tax and $9.99 shipping, partly refunded
+	product := f.Product(func(p *models.Product) { p.Price = 50 })
+	order := f.Order(f.User(), []factories.OrderLine{{Product: product, Quantity: 2}}, func(o *models.Order) {
+		o.PaymentStatus = "paid"
+		o.PaymentProvider = services.PaymentProviderStripe
+	})
+	require.NoError(t, finance.RecordCharge(ctx, order))
+	require.NoError(t, finance.RecordRefund(ctx, order, 59))
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	journals, err := accounting.Journals(ctx, today, today.AddDate(0, 0, 1))
+	require.NoError(t, err)
+	require.Len(t, journals, 2)
+
+	lines := func(journal services.AccountingJournal) map[string][2]float64 {
+		byAccount := make(map[string][2]float64)
+		var debits, credits float64
+		for _, line := range journal.Lines {
+			amounts := byAccount[line.Account]
+			amounts[0] += line.Debit
+			amounts[1] += line.Credit
+			byAccount[line.Account] = amounts
+			debits += line.Debit
+			credits += line.Credit
+		}
+		assert.InDelta(t, debits, credits, 0.001, "journal %s balances", journal.Number)
+		return byAccount
+	}
+
+	charge := lines(journals[0])
+	assert.Equal(t, order.OrderNumber, journals[0].Number)
+	assert.Equal(t, [2]float64{117.99, 3.72}, charge["Stripe Clearing"])
+	assert.Equal(t, [2]float64{0, 100}, charge["Sales"])
+	assert.Equal(t, [2]float64{0, 8}, charge["2200"])
+	assert.Equal(t, [2]float64{0, 9.99}, charge["4100"])
+	assert.Equal(t, [2]float64{3.72, 0}, charge["6100"])
+	assert.NotContains(t, charge, "4200", "no gift wrap, no line")
+
+	refund := lines(journals[1])
+	assert.True(t, strings.HasPrefix(journals[1].Number, order.OrderNumber+"-R"))
+	assert.Equal(t, [2]float64{55, 0}, refund["4900"])
+	assert.Equal(t, [2]float64{4, 0}, refund["2200"])
+	assert.Equal(t, [2]float64{0, 59}, refund["Stripe Clearing"])
+
+	// QuickBooks puts debits and credits in their own columns
+	export, err := accounting.Export(ctx, services.AccountingFormatQuickBooks, today, today.AddDate(0, 0, 1), services.AccountingExportManual, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, export.Orders)
+	assert.Equal(t, 1, export.Refunds)
+	assert.Equal(t, 180.71, export.Debits)
+	assert.Contains(t, export.Content, "Journal No,Journal Date,Currency Code,Account,Debits,Credits")
+	assert.Contains(t, export.Content, ",USD,Sales,,100.00,")
+
+	_, err = accounting.Export(ctx, "sage", today, today.AddDate(0, 0, 1), services.AccountingExportManual, nil)
+	assert.ErrorIs(t, err, services.ErrInvalidAccountingExport)
+
+	// The daily export covers the previous day once
+	daily, err := accounting.RunDailyExport(ctx, today.AddDate(0, 0, 1).Add(3*time.Hour))
+	require.NoError(t, err)
+	require.NotNil(t, daily)
+	assert.Equal(t, services.AccountingExportScheduled, daily.Source)
+	assert.Equal(t, today, daily.PeriodStart.UTC())
+	assert.Contains(t, daily.Content, "*Narration,*Date,Description,*AccountCode,*TaxRate,*Amount")
+	assert.Contains(t, daily.Content, ",Sales,Tax Exempt,-100.00")
+	again, err := accounting.RunDailyExport(ctx, today.AddDate(0, 0, 1).Add(4*time.Hour))
+	require.NoError(t, err)
+	assert.Nil(t, again)
+
+	history, err := accounting.ListExports(ctx)
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	assert.Empty(t, history[0].Content, "the history leaves the files out")
+	stored, err := accounting.GetExport(ctx, daily.ID)
+	require.NoError(t, err)
+	assert.Equal(t, daily.Content, stored.Content)
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/pkg/listquery"
+	"chat-ecommerce-backend/tests/testutil"
+	"chat-ecommerce-backend/tests/testutil/factories"
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminAssistantService(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	ctx := context.Background()
+	headphones := f.StockedProduct(12, func(p *models.Product) { p.Name = "Wireless Headphones" })
+	f.Order(f.User(), []factories.OrderLine{{Product: headphones, Quantity: 2}})
+
+	config := services.AdminAssistantConfig{
+		Roles: map[string]string{
+			"ops@example.com":    services.AdminAssistantRoleInventoryManager,
+			"intern@example.com": services.AdminAssistantRoleNone,
+		},
+		DefaultRole: services.AdminAssistantRoleViewer,
+	}
+	llm := services.NewFakeLLM().
+		When("orders today", services.FakeLLMResponse{Content: `{"tool": "orders_summary", "arguments": {"period": "today"}}`}).
+		When("stock to 40", services.FakeLLMResponse{Content: `{"tool": "set_stock", "arguments": {"product": "wireless headphones", "quantity": 40}}`})
+	assistant := services.NewAdminAssistantService(db, config).WithLLM(llm)
+
+	ops := services.AdminStaff{ID: uuid.New(), Email: "ops@example.com"}
+	viewer := services.AdminStaff{ID: uuid.New(), Email: "analyst@example.com"}
+	intern := services.AdminStaff{ID: uuid.New(), Email: "intern@example.com"}
+
+	reply, err := assistant.Ask(ctx, viewer, "How many orders today?")
+	require.NoError(t, err)
+	assert.Equal(t, services.AssistantActionExecuted, reply.Status)
+	assert.Equal(t, "orders_summary", reply.Tool)
+	summary, ok := reply.Data.(*services.OrderSalesSummary)
+	require.True(t, ok)
+	assert.Equal(t, int64(1), summary.Orders)
+
+	_, err = assistant.Ask(ctx, viewer, "Set Wireless Headphones stock to 40")
+	assert.ErrorIs(t, err, services.ErrAssistantToolDenied, "viewers can't change stock")
+	_, err = assistant.Ask(ctx, intern, "How many orders today?")
+	assert.ErrorIs(t, err, services.ErrAssistantNotAllowed)
+
+	// Changes wait for the same admin to confirm them
+	reply, err = assistant.Ask(ctx, ops, "Set Wireless Headphones stock to 40")
+	require.NoError(t, err)
+	assert.True(t, reply.RequiresConfirmation)
+	assert.Equal(t, services.AssistantActionPending, reply.Status)
+
+	var inventory models.Inventory
+	require.NoError(t, db.Where("product_id = ?", headphones.ID).First(&inventory).Error)
+	assert.Equal(t, 12, inventory.QuantityAvailable, "nothing changes before confirmation")
+
+	_, err = assistant.Confirm(ctx, viewer, reply.ActionID)
+	assert.ErrorIs(t, err, services.ErrAssistantActionNotFound, "only the admin who asked can confirm")
+
+	confirmed, err := assistant.Confirm(ctx, ops, reply.ActionID)
+	require.NoError(t, err)
+	assert.Equal(t, services.AssistantActionExecuted, confirmed.Status)
+	require.NoError(t, db.Where("product_id = ?", headphones.ID).First(&inventory).Error)
+	assert.Equal(t, 40, inventory.QuantityAvailable)
+
+	_, err = assistant.Confirm(ctx, ops, reply.ActionID)
+	assert.ErrorIs(t, err, services.ErrAssistantActionNotPending, "a change is applied once")
+
+	// Every request is audited, including denied ones
+	actions, _, err := assistant.ListActions(ctx, listquery.New(services.AssistantActionListSchema))
+	require.NoError(t, err)
+	statuses := map[string]int{}
+	for _, action := range actions {
+		statuses[action.Status]++
+	}
+	assert.Equal(t, 2, statuses[services.AssistantActionDenied])
+	assert.Equal(t, 2, statuses[services.AssistantActionExecuted])
+}
+
+func TestAdminAssistantService_FallsBackToCommands(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	ctx := context.Background()
+	headphones := f.StockedProduct(12, func(p *models.Product) { p.Name = "Wireless Headphones" })
+
+	llm := services.NewFakeLLM().Fallback(services.FakeLLMResponse{Err: services.ErrLLMUnavailable})
+	assistant := services.NewAdminAssistantService(db, services.AdminAssistantConfig{DefaultRole: services.AdminAssistantRoleViewer}).WithLLM(llm)
+
+	reply, err := assistant.Ask(ctx, services.AdminStaff{ID: uuid.New(), Email: "admin@example.com"}, "What's the stock of Wireless Headphones?")
+	require.NoError(t, err)
+	assert.Equal(t, "stock_level", reply.Tool)
+	assert.Contains(t, reply.Message, headphones.SKU)
+	assert.Contains(t, reply.Message, "12 available")
+}
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"chat-ecommerce-backend/tests/testutil/factories"
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdminProductService_MergeCategories(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	service := services.NewAdminProductService(db)
+	ctx := context.Background()
+
+	source := f.Category(func(c *models.Category) { c.Slug = "headphones" })
+	target := f.Category(func(c *models.Category) { c.Slug = "audio" })
+	child := f.Category(func(c *models.Category) { c.ParentID = &source.ID })
+	f.Product(func(p *models.Product) { p.CategoryID = source.ID })
+	f.Product(func(p *models.Product) { p.CategoryID = source.ID })
+
+	result, err := service.MergeCategories(ctx, source.ID, target.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), result.ProductsMoved)
+	assert.Equal(t, int64(1), result.ChildrenMoved)
+	assert.Equal(t, []string{"headphones"}, result.RedirectedSlugs)
+
+	var count int64
+	db.Model(&models.Product{}).Where("category_id = ?", target.ID).Count(&count)
+	assert.Equal(t, int64(2), count)
+
+	var movedChild models.Category
+	assert.NoError(t, db.First(&movedChild, "id = ?", child.ID).Error)
+	assert.Equal(t, target.ID, *movedChild.ParentID)
+
+	db.Model(&models.Category{}).Where("id = ?", source.ID).Count(&count)
+	assert.Equal(t, int64(0), count)
+
+	// The old slug now resolves to the merged category
+	category, err := services.NewProductService(db).GetCategoryBySlug("headphones")
+	assert.NoError(t, err)
+	assert.Equal(t, target.ID, category.ID)
+
+	// Redirects follow further merges
+	final := f.Category()
+	result, err = service.MergeCategories(ctx, target.ID, final.ID)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"headphones", "audio"}, result.RedirectedSlugs)
+	category, err = services.NewProductService(db).GetCategoryBySlug("headphones")
+	assert.NoError(t, err)
+	assert.Equal(t, final.ID, category.ID)
+}
+
+func TestAdminProductService_MergeCategories_RejectsDescendant(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	service := services.NewAdminProductService(db)
+
+	parent := f.Category()
+	child := f.Category(func(c *models.Category) { c.ParentID = &parent.ID })
+
+	_, err := service.MergeCategories(context.Background(), parent.ID, child.ID)
+	assert.ErrorIs(t, err, services.ErrCategoryCycle)
+
+	_, err = service.MergeCategories(context.Background(), parent.ID, parent.ID)
+	assert.Error(t, err)
+}
+
+func TestAdminProductService_MoveCategory(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	service := services.NewAdminProductService(db)
+	ctx := context.Background()
+
+	root := f.Category()
+	middle := f.Category(func(c *models.Category) { c.ParentID = &root.ID })
+	leaf := f.Category(func(c *models.Category) { c.ParentID = &middle.ID })
+	other := f.Category()
+
+	// Moving a subtree keeps its children attached
+	moved, err := service.MoveCategory(ctx, middle.ID, &other.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, other.ID, *moved.ParentID)
+
+	var reloaded models.Category
+	assert.NoError(t, db.First(&reloaded, "id = ?", leaf.ID).Error)
+	assert.Equal(t, middle.ID, *reloaded.ParentID)
+
+	// A category cannot move under itself or its own descendants
+	_, err = service.MoveCategory(ctx, middle.ID, &middle.ID)
+	assert.ErrorIs(t, err, services.ErrCategoryCycle)
+	_, err = service.MoveCategory(ctx, other.ID, &leaf.ID)
+	assert.ErrorIs(t, err, services.ErrCategoryCycle)
+
+	// A nil parent moves the category to the root
+	moved, err = service.MoveCategory(ctx, middle.ID, nil)
+	assert.NoError(t, err)
+	assert.Nil(t, moved.ParentID)
+
+	missing := uuid.New()
+	_, err = service.MoveCategory(ctx, middle.ID, &missing)
+	assert.ErrorIs(t, err, services.ErrCategoryNotFound)
+}
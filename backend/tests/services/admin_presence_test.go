@@ -0,0 +1,81 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/services"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminPresence_Locks(t *testing.T) {
+	presence := services.NewAdminPresence(services.AdminPresenceConfig{LockTTL: time.Minute})
+	alice := services.AdminEditor{UserID: uuid.New(), Email: "alice@example.com"}
+	bob := services.AdminEditor{UserID: uuid.New(), Email: "bob@example.com"}
+	variantID := uuid.New()
+	stock := services.EditResource{Kind: services.EditResourceInventory, ID: uuid.New(), VariantID: &variantID}
+	now := time.Now()
+
+	lock, acquired := presence.Lock(stock, alice, now)
+	require.True(t, acquired)
+	assert.Equal(t, now.Add(time.Minute), lock.ExpiresAt)
+
+	held, acquired := presence.Lock(stock, bob, now.Add(30*time.Second))
+	assert.False(t, acquired)
+	assert.Equal(t, alice, held.Editor)
+
+	// Refreshing keeps the lock past its first expiry
+	refreshed, acquired := presence.Lock(stock, alice, now.Add(50*time.Second))
+	require.True(t, acquired)
+	assert.Equal(t, now, refreshed.Since)
+	_, acquired = presence.Lock(stock, bob, now.Add(90*time.Second))
+	assert.False(t, acquired)
+
+	// An abandoned lock lapses and can be taken
+	assert.Empty(t, presence.Locks(now.Add(3*time.Minute)))
+	taken, acquired := presence.Lock(stock, bob, now.Add(3*time.Minute))
+	require.True(t, acquired)
+	assert.Equal(t, bob, taken.Editor)
+
+	// The same product without the variant is another record
+	_, acquired = presence.Lock(services.EditResource{Kind: services.EditResourceInventory, ID: stock.ID}, alice, now.Add(3*time.Minute))
+	assert.True(t, acquired)
+
+	assert.Nil(t, presence.Unlock(stock, alice), "only the holder can give a lock up")
+	released := presence.Unlock(stock, bob)
+	require.NotNil(t, released)
+	assert.True(t, released.Released)
+	assert.Len(t, presence.UnlockAll(alice), 1)
+	assert.Empty(t, presence.Locks(now.Add(3*time.Minute)))
+}
+
+func TestAdminPresence_Saved(t *testing.T) {
+	presence := services.NewAdminPresence(services.AdminPresenceConfig{})
+	alice := services.AdminEditor{UserID: uuid.New(), Email: "alice@example.com"}
+	bob := services.AdminEditor{UserID: uuid.New(), Email: "bob@example.com"}
+	product := services.EditResource{Kind: services.EditResourceProduct, ID: uuid.New()}
+	loaded := time.Now().Add(-time.Hour)
+	changed := loaded.Add(time.Minute)
+
+	saved, conflict := presence.Saved(product, alice, &loaded, &loaded, time.Now())
+	assert.Nil(t, conflict)
+	assert.Equal(t, alice, saved.Editor)
+
+	// Bob's copy predates Alice's save
+	_, conflict = presence.Saved(product, bob, &loaded, &changed, time.Now())
+	require.NotNil(t, conflict)
+	assert.Equal(t, bob, conflict.Editor)
+	assert.Equal(t, &alice, conflict.OverwrittenBy)
+	assert.Equal(t, changed, conflict.OverwrittenAt)
+
+	// Without the version the edit started from nothing can be detected
+	_, conflict = presence.Saved(product, alice, nil, &changed, time.Now())
+	assert.Nil(t, conflict)
+
+	// Versions are compared at the database's precision
+	rounded := changed.Truncate(time.Microsecond)
+	_, conflict = presence.Saved(product, alice, &rounded, &changed, time.Now())
+	assert.Nil(t, conflict)
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"chat-ecommerce-backend/tests/testutil/factories"
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// skuRequest is a minimal product request with the given SKU
+func skuRequest(category *models.Category, sku string) services.AdminProductRequest {
+	return services.AdminProductRequest{
+		Name:        "Tee " + sku,
+		Description: "Cotton tee",
+		Price:       20,
+		CategoryID:  category.ID,
+		SKU:         sku,
+		Status:      "active",
+	}
+}
+
+func TestAdminProductService_CreateProductSKUConflict(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	svc := services.NewAdminProductService(db).WithSKUConfig(services.SKUConfig{})
+	category := f.Category()
+
+	existing := f.Product(func(p *models.Product) { p.SKU = "TEE-BLK-M" })
+
+	// SKUs differing only in case are the same SKU
+	_, err := svc.CreateProduct(skuRequest(category, "tee-blk-m"))
+	var conflict *services.SKUConflictError
+	require.True(t, errors.As(err, &conflict), "got %v", err)
+	assert.Equal(t, existing.ID, conflict.ConflictingProductID)
+	assert.Equal(t, "TEE-BLK-M", conflict.ConflictingSKU)
+	assert.Equal(t, "tee-blk-m", conflict.SKU)
+
+	var count int64
+	require.NoError(t, db.Model(&models.Product{}).Count(&count).Error)
+	assert.Equal(t, int64(1), count)
+
+	// Renaming another product onto the SKU is refused the same way
+	other, err := svc.CreateProduct(skuRequest(category, "TEE-BLK-L"))
+	require.NoError(t, err)
+	_, err = svc.UpdateProduct(other.Product.ID, skuRequest(category, "Tee-Blk-M"))
+	require.True(t, errors.As(err, &conflict), "got %v", err)
+	assert.Equal(t, existing.ID, conflict.ConflictingProductID)
+
+	sku := "tee-blk-m"
+	_, err = svc.PatchProduct(context.Background(), other.Product.ID, services.AdminProductPatch{SKU: &sku})
+	require.True(t, errors.As(err, &conflict), "got %v", err)
+
+	// A product keeps its own SKU, or changes its case
+	sku = "tee-blk-l"
+	_, err = svc.PatchProduct(context.Background(), other.Product.ID, services.AdminProductPatch{SKU: &sku})
+	assert.NoError(t, err)
+}
+
+func TestAdminProductService_CreateProductReservedSKU(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	category := f.Category()
+
+	t.Setenv("RESERVED_SKU_PATTERN", "")
+	svc := services.NewAdminProductService(db)
+	_, err := svc.CreateProduct(skuRequest(category, "tmp-123"))
+	assert.ErrorIs(t, err, services.ErrSKUReserved, "reserved prefixes match in any case")
+
+	_, err = svc.CreateProduct(skuRequest(category, " "))
+	assert.ErrorIs(t, err, services.ErrSKURequired)
+
+	svc.WithSKUConfig(services.SKUConfig{Reserved: regexp.MustCompile(`(?i)^GIFT-`)})
+	_, err = svc.CreateProduct(skuRequest(category, "GIFT-WRAP"))
+	assert.ErrorIs(t, err, services.ErrSKUReserved)
+	_, err = svc.CreateProduct(skuRequest(category, "TMP-123"))
+	assert.NoError(t, err)
+
+	t.Setenv("RESERVED_SKU_PATTERN", "none")
+	assert.Nil(t, services.SKUConfigFromEnv().Reserved)
+}
+
+func TestProductSKU_UniqueInAnyCase(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	f.Product(func(p *models.Product) { p.SKU = "TEE-BLK-M" })
+
+	// A write that got past the check, as one racing another admin's would
+	product := f.Product()
+	err := db.Model(product).Update("sku", "tee-blk-m").Error
+	require.Error(t, err, "the index refuses SKUs differing only in case")
+}
@@ -0,0 +1,86 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"chat-ecommerce-backend/tests/testutil/factories"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminProductService_UpdateProductPreservesChildIDs(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	svc := services.NewAdminProductService(db)
+
+	category := f.Category()
+	created, err := svc.CreateProduct(services.AdminProductRequest{
+		Name:        "Tee",
+		Description: "Cotton tee",
+		Price:       20,
+		CategoryID:  category.ID,
+		SKU:         "TEE-1",
+		Status:      "active",
+		Variants: []services.ProductVariantRequest{
+			{VariantName: "Size", VariantValue: "M"},
+			{VariantName: "Size", VariantValue: "L", PriceModifier: 2},
+		},
+		Images: []services.ProductImageRequest{{URL: "https://cdn.example.com/tee.jpg", IsPrimary: true}},
+	})
+	require.NoError(t, err)
+	medium, large := created.Variants[0], created.Variants[1]
+	image := created.Images[0]
+
+	req := services.AdminProductRequest{
+		Name:        "Tee",
+		Description: "Organic cotton tee",
+		Price:       22,
+		CategoryID:  category.ID,
+		SKU:         "TEE-1",
+		Status:      "active",
+		Variants: []services.ProductVariantRequest{
+			{VariantName: "Size", VariantValue: "L", PriceModifier: 3},
+			{VariantName: "Size", VariantValue: "XL", PriceModifier: 4},
+		},
+		Images: []services.ProductImageRequest{{URL: "https://cdn.example.com/tee.jpg", AltText: "Front"}},
+	}
+	updated, err := svc.UpdateProduct(created.Product.ID, req)
+	require.NoError(t, err)
+
+	byValue := map[string]models.ProductVariant{}
+	for _, variant := range updated.Variants {
+		byValue[variant.VariantValue] = variant
+	}
+	require.Len(t, byValue, 3, "variants missing from the request are kept")
+	assert.Equal(t, medium.ID, byValue["M"].ID)
+	assert.Equal(t, large.ID, byValue["L"].ID, "matched variants keep their IDs")
+	assert.Equal(t, 3.0, byValue["L"].PriceModifier)
+	require.Len(t, updated.Images, 1)
+	assert.Equal(t, image.ID, updated.Images[0].ID)
+	assert.Equal(t, "Front", updated.Images[0].AltText)
+
+	// Only explicitly removed children are deleted, unless orders refer to them
+	f.Order(f.User(), []factories.OrderLine{{Product: created.Product}})
+	require.NoError(t, db.Model(&models.OrderItem{}).Where("product_id = ?", created.Product.ID).Update("variant_id", large.ID).Error)
+
+	req.Variants = nil
+	req.RemoveVariantIDs = []uuid.UUID{large.ID}
+	_, err = svc.UpdateProduct(created.Product.ID, req)
+	assert.ErrorIs(t, err, services.ErrVariantInUse)
+
+	req.RemoveVariantIDs = []uuid.UUID{medium.ID}
+	updated, err = svc.UpdateProduct(created.Product.ID, req)
+	require.NoError(t, err)
+	assert.Len(t, updated.Variants, 2)
+	for _, variant := range updated.Variants {
+		assert.NotEqual(t, medium.ID, variant.ID)
+	}
+
+	req.RemoveVariantIDs = []uuid.UUID{uuid.New()}
+	_, err = svc.UpdateProduct(created.Product.ID, req)
+	assert.Error(t, err, "only the product's own children can be removed")
+}
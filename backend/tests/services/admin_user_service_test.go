@@ -0,0 +1,109 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"chat-ecommerce-backend/tests/testutil/factories"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminUserService_ListUsers(t *testing.T) {
+	// SQLite returns MAX() of a timestamp as text
+	testutil.RequirePostgres(t)
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	svc := services.NewAdminUserService(db)
+	ctx := context.Background()
+
+	product := f.Product(func(p *models.Product) { p.Price = 50 })
+	alice := f.User(func(u *models.User) {
+		u.Email = "alice@example.com"
+		u.CreatedAt = time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	})
+	f.User(func(u *models.User) {
+		u.Email = "bob@example.com"
+		u.Status = services.UserStatusSuspended
+		u.CreatedAt = time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	})
+
+	paid := func(o *models.Order) { o.PaymentStatus = "paid"; o.Status = "confirmed" }
+	f.Order(alice, []factories.OrderLine{{Product: product, Quantity: 2}}, paid)
+	f.Order(alice, []factories.OrderLine{{Product: product}}, paid)
+	f.Order(alice, []factories.OrderLine{{Product: product}}) // unpaid orders don't count
+
+	result, err := svc.ListUsers(ctx, services.AdminUserFilters{Search: "ALICE"})
+	require.NoError(t, err)
+	require.Len(t, result.Users, 1)
+	assert.Equal(t, alice.ID, result.Users[0].ID)
+	assert.Equal(t, 2, result.Users[0].OrderCount)
+	assert.InDelta(t, 117.99+63.99, result.Users[0].TotalSpent, 0.01)
+	assert.NotNil(t, result.Users[0].LastOrderAt)
+
+	result, err = svc.ListUsers(ctx, services.AdminUserFilters{Status: services.UserStatusSuspended})
+	require.NoError(t, err)
+	require.Len(t, result.Users, 1)
+	assert.Equal(t, "bob@example.com", result.Users[0].Email)
+
+	from := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	result, err = svc.ListUsers(ctx, services.AdminUserFilters{SignedUpFrom: &from})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), result.Total, "filters by signup date")
+
+	csvData, err := svc.ExportCustomersCSV(ctx, services.AdminUserFilters{SortBy: "total_spent"})
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(csvData)), "\n")
+	require.Len(t, lines, 3)
+	assert.True(t, strings.HasPrefix(lines[0], "id,email,"))
+	assert.Contains(t, lines[1], "alice@example.com", "sorted by total spent, highest first")
+}
+
+func TestAdminUserService_SuspendAndReset(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	svc := services.NewAdminUserService(db)
+	users := services.NewUserService(db)
+	ctx := context.Background()
+
+	user := f.User()
+	deleted := f.User(func(u *models.User) { u.Status = services.UserStatusDeleted })
+	missing := uuid.New()
+
+	result, err := svc.SuspendUsers(ctx, services.BulkUserRequest{UserIDs: []uuid.UUID{user.ID, deleted.ID, missing}})
+	require.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{user.ID}, result.Updated)
+	assert.Equal(t, []uuid.UUID{deleted.ID}, result.Skipped)
+	assert.Equal(t, []uuid.UUID{missing}, result.NotFound)
+
+	login := &services.LoginRequest{Email: user.Email, Password: factories.DefaultPassword}
+	_, err = users.Login(login)
+	assert.Error(t, err, "suspended users can't sign in")
+	assert.Error(t, users.CheckCanSignIn(user.ID))
+
+	_, err = svc.ReactivateUsers(ctx, services.BulkUserRequest{UserIDs: []uuid.UUID{user.ID}})
+	require.NoError(t, err)
+	_, err = users.Login(login)
+	require.NoError(t, err)
+
+	reset, err := svc.ForcePasswordReset(ctx, services.BulkUserRequest{UserIDs: []uuid.UUID{user.ID}})
+	require.NoError(t, err)
+	require.Len(t, reset.Links, 1)
+	assert.Contains(t, reset.Links[0].ResetURL, reset.Links[0].Token)
+
+	_, err = users.Login(login)
+	assert.ErrorIs(t, err, services.ErrPasswordResetRequired)
+
+	assert.ErrorIs(t, users.ResetPassword("not-a-token", "n3w-password"), services.ErrInvalidResetToken)
+	require.NoError(t, users.ResetPassword(reset.Links[0].Token, "n3w-password"))
+	assert.ErrorIs(t, users.ResetPassword(reset.Links[0].Token, "another-one"), services.ErrInvalidResetToken, "reset links work once")
+
+	_, err = users.Login(&services.LoginRequest{Email: user.Email, Password: "n3w-password"})
+	require.NoError(t, err)
+}
@@ -0,0 +1,77 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIUsageService_Usage(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	ctx := context.Background()
+	usage := services.NewAPIUsageService(db, services.APIUsageConfig{Retention: 24 * time.Hour, ConsumerQuota: 3, TopConsumerCount: 10})
+
+	minute := time.Now().UTC().Truncate(time.Minute).Add(-10 * time.Minute)
+	usage.Record("GET", "/api/v1/products/:id", "ip:203.0.113.9", 200, 20*time.Millisecond, minute)
+	usage.Record("GET", "/api/v1/products/:id", "ip:203.0.113.9", 404, 10*time.Millisecond, minute.Add(10*time.Second))
+	usage.Record("POST", "/api/v1/auth/login", "ip:203.0.113.9", 401, 30*time.Millisecond, minute.Add(20*time.Second))
+	require.NoError(t, usage.Flush(ctx))
+
+	// A later flush adds to the stored minute rather than replacing it
+	usage.Record("GET", "/api/v1/products/:id", "ip:203.0.113.9", 500, 90*time.Millisecond, minute.Add(30*time.Second))
+	usage.Record("GET", "/api/v1/products/:id", "user:42", 200, 30*time.Millisecond, minute.Add(time.Minute))
+	usage.Record("GET", "", "ip:198.51.100.1", 404, time.Millisecond, minute.Add(time.Minute))
+
+	report, err := usage.Usage(ctx, services.APIUsageFilter{Since: minute.Add(-time.Hour), Until: minute.Add(time.Hour)})
+	require.NoError(t, err)
+	assert.EqualValues(t, 6, report.Requests)
+	assert.EqualValues(t, 1, report.ServerErrors)
+
+	require.NotEmpty(t, report.Routes)
+	product := report.Routes[0]
+	assert.Equal(t, "/api/v1/products/:id", product.Route)
+	assert.EqualValues(t, 4, product.Requests)
+	assert.EqualValues(t, 1, product.ClientErrors)
+	assert.EqualValues(t, 1, product.ServerErrors)
+	assert.Equal(t, 0.25, product.ErrorRate)
+	assert.Equal(t, 37.5, product.AvgLatencyMs)
+	assert.Equal(t, 90.0, product.MaxLatencyMs)
+
+	routes := map[string]bool{}
+	for _, route := range report.Routes {
+		routes[route.Route] = true
+	}
+	assert.True(t, routes[services.UnmatchedRoute], "unknown paths are counted under one route")
+
+	require.NotEmpty(t, report.TopConsumers)
+	scanner := report.TopConsumers[0]
+	assert.Equal(t, "ip:203.0.113.9", scanner.Consumer)
+	assert.EqualValues(t, 4, scanner.Requests)
+	assert.EqualValues(t, 4, scanner.PeakPerMinute)
+	assert.True(t, scanner.OverQuota)
+	require.Len(t, report.OverQuota, 1)
+	assert.Equal(t, "ip:203.0.113.9", report.OverQuota[0].Consumer)
+
+	require.Len(t, report.Timeline, 2)
+	assert.EqualValues(t, 4, report.Timeline[0].Requests)
+	assert.EqualValues(t, 2, report.Timeline[1].Requests)
+
+	// Filters narrow the report to a consumer
+	report, err = usage.Usage(ctx, services.APIUsageFilter{Since: minute.Add(-time.Hour), Until: minute.Add(time.Hour), Consumer: "user:42"})
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, report.Requests)
+
+	// Buckets past the retention window are dropped
+	pruned, err := usage.Prune(ctx, minute.Add(24*time.Hour+30*time.Second))
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, pruned)
+	var remaining int64
+	require.NoError(t, db.Model(&models.APIUsageBucket{}).Count(&remaining).Error)
+	assert.EqualValues(t, 2, remaining)
+}
@@ -0,0 +1,189 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/pkg/listquery"
+	"chat-ecommerce-backend/tests/testutil"
+	"chat-ecommerce-backend/tests/testutil/factories"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// jobEvents records the job progress sent to each admin
+type jobEvents struct {
+	mu     sync.Mutex
+	events map[uuid.UUID][]services.JobStatus
+}
+
+func (e *jobEvents) NotifyUser(userID uuid.UUID, messageType string, data interface{}) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if messageType == services.JobProgressMessage {
+		e.events[userID] = append(e.events[userID], *data.(*services.JobStatus))
+	}
+}
+
+func TestJobService_BulkImportReportsProgress(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	ctx := context.Background()
+	events := &jobEvents{events: map[uuid.UUID][]services.JobStatus{}}
+	jobs := services.NewJobService(db, services.JobConfig{Workers: 1}).WithNotifier(events)
+	adminService := services.NewAdminProductService(db)
+
+	category := f.Category()
+	existing := f.Product()
+	req := services.BulkImportRequest{}
+	for i := 0; i < 120; i++ {
+		req.Products = append(req.Products, services.AdminProductRequest{
+			Name:        fmt.Sprintf("Imported %d", i),
+			Description: "Bulk imported product",
+			Price:       10,
+			CategoryID:  category.ID,
+			SKU:         fmt.Sprintf("IMPORT-%03d", i),
+			Status:      "active",
+		})
+	}
+	req.Products[110].SKU = existing.SKU
+
+	admin := f.User()
+	job, err := jobs.Start(ctx, services.JobKindProductImport, &admin.ID, map[string]int{"products": len(req.Products)},
+		func(ctx context.Context) (*services.JobOutput, error) {
+			response, err := adminService.BulkImportProductsInBatches(ctx, req)
+			if err != nil {
+				return nil, err
+			}
+			return &services.JobOutput{Result: response}, nil
+		})
+	require.NoError(t, err)
+	assert.Equal(t, services.JobStatusQueued, job.Status)
+	jobs.Wait()
+
+	finished, err := jobs.GetJob(ctx, job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, services.JobStatusSucceeded, finished.Status)
+	assert.Equal(t, 100, finished.Progress)
+	assert.Equal(t, 120, finished.Processed)
+	assert.Empty(t, finished.ArtifactURL, "imports don't produce a file")
+
+	var result services.BulkImportResponse
+	require.NoError(t, json.Unmarshal(finished.Result, &result))
+	assert.Equal(t, 119, result.Created)
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, 110, result.Errors[0].Index, "indexes count from the start of the whole import")
+
+	sent := events.events[admin.ID]
+	require.NotEmpty(t, sent)
+	assert.Equal(t, services.JobStatusRunning, sent[0].Status)
+	var progress []int
+	for _, event := range sent {
+		progress = append(progress, event.Progress)
+	}
+	assert.Contains(t, progress, 41, "progress after the first batch of 50")
+	assert.Equal(t, services.JobStatusSucceeded, sent[len(sent)-1].Status)
+}
+
+func TestJobService_ArtifactsAndFailures(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	ctx := context.Background()
+	jobs := services.NewJobService(db, services.JobConfig{Workers: 2})
+
+	export, err := jobs.Start(ctx, services.JobKindProductExport, nil, nil, func(ctx context.Context) (*services.JobOutput, error) {
+		return &services.JobOutput{Artifact: []byte("ID,Name\n"), ArtifactName: "products.csv", ArtifactType: "text/csv"}, nil
+	})
+	require.NoError(t, err)
+	failing, err := jobs.Start(ctx, services.JobKindBulkPrice, nil, nil, func(ctx context.Context) (*services.JobOutput, error) {
+		return nil, errors.New("rule 0: category not found")
+	})
+	require.NoError(t, err)
+	panicking, err := jobs.Start(ctx, services.JobKindBulkPrice, nil, nil, func(ctx context.Context) (*services.JobOutput, error) {
+		panic("boom")
+	})
+	require.NoError(t, err)
+	jobs.Wait()
+
+	done, err := jobs.GetJob(ctx, export.ID)
+	require.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf("/api/v1/admin/jobs/%s/artifact", export.ID), done.ArtifactURL)
+	artifact, err := jobs.Artifact(ctx, export.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "ID,Name\n", string(artifact.Artifact))
+	assert.Equal(t, "text/csv", artifact.ArtifactType)
+
+	failed, err := jobs.GetJob(ctx, failing.ID)
+	require.NoError(t, err)
+	assert.Equal(t, services.JobStatusFailed, failed.Status)
+	assert.Equal(t, "rule 0: category not found", failed.Error)
+	_, err = jobs.Artifact(ctx, failing.ID)
+	assert.ErrorIs(t, err, services.ErrJobNoArtifact)
+
+	crashed, err := jobs.GetJob(ctx, panicking.ID)
+	require.NoError(t, err)
+	assert.Equal(t, services.JobStatusFailed, crashed.Status)
+	assert.Contains(t, crashed.Error, "boom")
+
+	_, err = jobs.GetJob(ctx, uuid.New())
+	assert.ErrorIs(t, err, services.ErrJobNotFound)
+
+	list, total, err := jobs.ListJobs(ctx, listquery.New(services.JobListSchema).Where("status", listquery.Eq, services.JobStatusFailed))
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+	assert.Len(t, list, 2)
+
+	// Jobs a previous run left unfinished are failed at startup
+	stale := models.AsyncJob{ID: uuid.New(), Kind: services.JobKindProductImport, Status: services.JobStatusRunning}
+	require.NoError(t, db.Create(&stale).Error)
+	interrupted, err := jobs.FailInterrupted(ctx, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), interrupted)
+	_, err = jobs.Artifact(ctx, stale.ID)
+	assert.ErrorIs(t, err, services.ErrJobNoArtifact)
+}
+
+func TestJobService_CampaignSend(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	ctx := context.Background()
+	now := time.Now()
+	jobs := services.NewJobService(db, services.JobConfig{Workers: 1})
+	campaigns := services.NewCampaignService(db, services.CampaignConfig{SendRate: 100})
+
+	broadcaster := &fakeBroadcaster{sessions: []string{"one", "two"}, notified: map[string]int{}}
+	for _, sessionID := range broadcaster.sessions {
+		require.NoError(t, db.Create(&models.ChatSession{ID: uuid.New(), SessionID: sessionID, LastActivity: now, ExpiresAt: now.Add(time.Hour)}).Error)
+	}
+	later := now.Add(24 * time.Hour)
+	campaign, err := campaigns.CreateCampaign(ctx, nil, services.CampaignRequest{Name: "Sale", Title: "Sale", Message: "20% off", ScheduledAt: &later})
+	require.NoError(t, err)
+
+	claimed, err := campaigns.ClaimCampaign(ctx, campaign.ID)
+	require.NoError(t, err)
+	_, err = campaigns.ClaimCampaign(ctx, campaign.ID)
+	assert.ErrorIs(t, err, services.ErrCampaignNotSendable, "a campaign is only sent once")
+
+	job, err := jobs.Start(ctx, services.JobKindCampaignSend, nil, nil, func(ctx context.Context) (*services.JobOutput, error) {
+		stats, err := campaigns.SendCampaign(ctx, claimed, broadcaster)
+		if err != nil {
+			return nil, err
+		}
+		return &services.JobOutput{Result: stats}, nil
+	})
+	require.NoError(t, err)
+	jobs.Wait()
+
+	finished, err := jobs.GetJob(ctx, job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, services.JobStatusSucceeded, finished.Status)
+	assert.Equal(t, 2, finished.Total)
+	assert.Equal(t, 1, broadcaster.notified["one"])
+	assert.Equal(t, 1, broadcaster.notified["two"])
+}
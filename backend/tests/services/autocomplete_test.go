@@ -0,0 +1,55 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"chat-ecommerce-backend/tests/testutil/factories"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAutocompleteIndex_Complete(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+
+	category := f.Category()
+	f.Product(func(p *models.Product) {
+		p.Name = "Ceramic Mug"
+		p.CategoryID = category.ID
+		p.Popularity = 5
+	})
+	f.Product(func(p *models.Product) {
+		p.Name = "Travel Mug"
+		p.CategoryID = category.ID
+		p.Popularity = 50
+	})
+	f.Product(func(p *models.Product) {
+		p.Name = "Mug Warmer"
+		p.Status = "inactive"
+	})
+
+	index := services.NewAutocompleteIndex(db)
+	assert.Empty(t, index.Complete("mu", 5).Products, "an index that hasn't been built suggests nothing")
+
+	require.NoError(t, index.Refresh(context.Background()))
+
+	result := index.Complete("  MU", 5)
+	require.Len(t, result.Products, 2, "inactive products aren't suggested")
+	assert.Equal(t, "Travel Mug", result.Products[0].Text, "more popular products first")
+	assert.Equal(t, "Ceramic Mug", result.Products[1].Text, "later words in a name match too")
+
+	result = index.Complete("ceramic m", 5)
+	require.Len(t, result.Products, 1)
+	assert.Equal(t, services.SuggestionProduct, result.Products[0].Kind)
+
+	assert.Len(t, index.Complete("mu", 1).Products, 1)
+	assert.Empty(t, index.Complete("xyz", 5).Products)
+
+	result = index.Complete(category.Name[:3], 5)
+	require.NotEmpty(t, result.Categories)
+	assert.Equal(t, category.Slug, result.Categories[0].Slug)
+}
@@ -0,0 +1,53 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"chat-ecommerce-backend/tests/testutil/factories"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAvailabilityService_GetAvailability(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	svc := services.NewAvailabilityService(db, time.Minute)
+	ctx := context.Background()
+
+	inStock := f.StockedProduct(50)
+	lowStock := f.StockedProduct(8)
+	soldOut := f.Product()
+	f.Inventory(soldOut, func(i *models.Inventory) {
+		i.QuantityAvailable = 3
+		i.QuantityReserved = 3
+	})
+	untracked := f.Product()
+	draft := f.Product(func(p *models.Product) { p.Status = "draft" })
+
+	availability, err := svc.GetAvailability(ctx, []uuid.UUID{soldOut.ID, inStock.ID, lowStock.ID, untracked.ID, draft.ID, uuid.New()})
+	require.NoError(t, err)
+	require.Len(t, availability, 4, "unknown and unpublished products are left out")
+	assert.Equal(t, soldOut.ID, availability[0].ProductID, "results keep the requested order")
+	assert.Equal(t, services.AvailabilityOutOfStock, availability[0].Status, "reserved units aren't available")
+	assert.False(t, availability[0].InStock)
+	assert.Equal(t, services.AvailabilityInStock, availability[1].Status)
+	assert.Equal(t, services.AvailabilityLowStock, availability[2].Status)
+	assert.True(t, availability[2].LowStock)
+	assert.Equal(t, services.AvailabilityInStock, availability[3].Status, "untracked products are always in stock")
+
+	// Cached statuses are served until they expire
+	require.NoError(t, db.Model(&models.Inventory{}).Where("product_id = ?", inStock.ID).Update("quantity_available", 0).Error)
+	availability, err = svc.GetAvailability(ctx, []uuid.UUID{inStock.ID})
+	require.NoError(t, err)
+	assert.Equal(t, services.AvailabilityInStock, availability[0].Status)
+
+	availability, err = services.NewAvailabilityService(db, time.Minute).GetAvailability(ctx, []uuid.UUID{inStock.ID})
+	require.NoError(t, err)
+	assert.Equal(t, services.AvailabilityOutOfStock, availability[0].Status)
+}
@@ -0,0 +1,118 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"chat-ecommerce-backend/tests/testutil/factories"
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBrandService_CRUD(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	svc := services.NewBrandService(db)
+	ctx := context.Background()
+
+	sony, err := svc.CreateBrand(ctx, services.BrandRequest{Name: "  Sony  "})
+	require.NoError(t, err)
+	assert.Equal(t, "Sony", sony.Name)
+	assert.Equal(t, "sony", sony.Slug)
+
+	_, err = svc.CreateBrand(ctx, services.BrandRequest{Name: "SONY"})
+	assert.ErrorIs(t, err, services.ErrBrandExists)
+
+	bo, err := svc.CreateBrand(ctx, services.BrandRequest{Name: "Bang & Olufsen"})
+	require.NoError(t, err)
+	assert.Equal(t, "bang-olufsen", bo.Slug)
+
+	f.Product(func(p *models.Product) { p.BrandID = &sony.ID })
+	f.Product(func(p *models.Product) { p.BrandID = &sony.ID })
+
+	inactive := false
+	_, err = svc.UpdateBrand(ctx, bo.ID, services.BrandRequest{Name: "Bang & Olufsen", IsActive: &inactive})
+	require.NoError(t, err)
+
+	brands, err := svc.ListBrands(ctx, false)
+	require.NoError(t, err)
+	require.Len(t, brands, 1, "inactive brands aren't listed publicly")
+	assert.Equal(t, int64(2), brands[0].ProductCount)
+
+	_, err = svc.GetBrandBySlug(ctx, "bang-olufsen")
+	assert.ErrorIs(t, err, services.ErrBrandNotFound)
+
+	require.NoError(t, svc.DeleteBrand(ctx, sony.ID))
+	var linked int64
+	require.NoError(t, db.Model(&models.Product{}).Where("brand_id IS NOT NULL").Count(&linked).Error)
+	assert.Zero(t, linked, "deleting a brand unlinks its products")
+	assert.ErrorIs(t, svc.DeleteBrand(ctx, uuid.New()), services.ErrBrandNotFound)
+}
+
+func TestBrandService_DetectBrand(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	svc := services.NewBrandService(db)
+	ctx := context.Background()
+
+	_, err := svc.CreateBrand(ctx, services.BrandRequest{Name: "Sony"})
+	require.NoError(t, err)
+	_, err = svc.CreateBrand(ctx, services.BrandRequest{Name: "Sony Ericsson"})
+	require.NoError(t, err)
+	_, err = svc.CreateBrand(ctx, services.BrandRequest{Name: "LG"})
+	require.NoError(t, err)
+
+	brand, rest := svc.DetectBrand(ctx, "Show me Sony headphones!")
+	require.NotNil(t, brand)
+	assert.Equal(t, "Sony", brand.Name)
+	assert.Equal(t, "show me headphones", rest)
+
+	brand, _ = svc.DetectBrand(ctx, "any sony ericsson phones?")
+	require.NotNil(t, brand)
+	assert.Equal(t, "Sony Ericsson", brand.Name, "the longest brand name wins")
+
+	brand, rest = svc.DetectBrand(ctx, "a bulging bag")
+	assert.Nil(t, brand, "brands match whole words only")
+	assert.Equal(t, "a bulging bag", rest)
+}
+
+func TestProductService_SearchByBrand(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	brands := services.NewBrandService(db)
+	svc := services.NewProductService(db).WithBrands(brands)
+	ctx := context.Background()
+
+	sony, err := brands.CreateBrand(ctx, services.BrandRequest{Name: "Sony"})
+	require.NoError(t, err)
+	bose, err := brands.CreateBrand(ctx, services.BrandRequest{Name: "Bose"})
+	require.NoError(t, err)
+
+	sonyHeadphones := f.Product(func(p *models.Product) {
+		p.Name = "WH-1000 Headphones"
+		p.BrandID = &sony.ID
+	})
+	f.Product(func(p *models.Product) {
+		p.Name = "QuietComfort Headphones"
+		p.BrandID = &bose.ID
+	})
+
+	products, err := svc.SearchProducts("sony headphones", 10)
+	require.NoError(t, err)
+	require.Len(t, products, 1, "a brand named in the query narrows the search")
+	assert.Equal(t, sonyHeadphones.ID, products[0].ID)
+	require.NotNil(t, products[0].Brand)
+	assert.Equal(t, "Sony", products[0].Brand.Name)
+
+	products, err = svc.SearchBrandProducts("headphones", bose.ID, 10)
+	require.NoError(t, err)
+	require.Len(t, products, 1)
+	assert.Equal(t, bose.ID, *products[0].BrandID)
+
+	list, err := svc.GetProducts(services.ProductFilters{BrandID: sony.ID, Page: 1, Limit: 10})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), list.Total)
+}
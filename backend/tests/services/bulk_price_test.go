@@ -0,0 +1,89 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"chat-ecommerce-backend/tests/testutil/factories"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/datatypes"
+)
+
+func TestAdminProductService_BulkUpdatePrices(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	svc := services.NewAdminProductService(db)
+	ctx := context.Background()
+
+	shoes := f.Category()
+	running := f.Category(func(c *models.Category) { c.ParentID = &shoes.ID })
+	other := f.Category()
+
+	sneaker := f.Product(func(p *models.Product) {
+		p.CategoryID = running.ID
+		p.Price = 100
+		p.Metadata = datatypes.JSON(`{"brand": "Acme", "cost": 60}`)
+	})
+	boot := f.Product(func(p *models.Product) {
+		p.CategoryID = shoes.ID
+		p.Price = 80
+		p.Metadata = datatypes.JSON(`{"brand": "Other"}`)
+	})
+	hat := f.Product(func(p *models.Product) {
+		p.CategoryID = other.ID
+		p.Price = 20
+		p.Metadata = datatypes.JSON(`{"brand": "Acme"}`)
+	})
+
+	req := services.BulkPriceRequest{
+		Rules: []services.BulkPriceRule{
+			{CategoryID: &shoes.ID, Action: services.PriceActionPercent, Value: 10},
+			{Brand: "acme", Action: services.PriceActionMargin, Value: 50},
+		},
+		Preview: true,
+		Reason:  "spring pricing",
+	}
+
+	preview, err := svc.BulkUpdatePrices(ctx, req, nil)
+	require.NoError(t, err)
+	assert.True(t, preview.Preview)
+	require.Equal(t, 2, preview.Affected)
+
+	prices := map[string][2]float64{}
+	for _, change := range preview.Changes {
+		prices[change.SKU] = [2]float64{change.OldPrice, change.NewPrice}
+	}
+	assert.Equal(t, [2]float64{100, 120}, prices[sneaker.SKU], "the margin rule runs after the category rule")
+	assert.Equal(t, [2]float64{80, 88}, prices[boot.SKU], "subcategories are included")
+	require.Len(t, preview.Skipped, 1)
+	assert.Equal(t, hat.SKU, preview.Skipped[0].SKU, "products without a cost can't be priced by margin")
+
+	var unchanged models.Product
+	require.NoError(t, db.First(&unchanged, "id = ?", sneaker.ID).Error)
+	assert.Equal(t, 100.0, unchanged.Price, "previews don't save anything")
+
+	req.Preview = false
+	result, err := svc.BulkUpdatePrices(ctx, req, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.Affected)
+
+	var updated models.Product
+	require.NoError(t, db.First(&updated, "id = ?", boot.ID).Error)
+	assert.Equal(t, 88.0, updated.Price)
+
+	history, err := svc.GetPriceHistory(ctx, sneaker.ID)
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.Equal(t, 100.0, history[0].OldPrice)
+	assert.Equal(t, 120.0, history[0].NewPrice)
+	assert.Equal(t, "spring pricing", history[0].Reason)
+
+	_, err = svc.BulkUpdatePrices(ctx, services.BulkPriceRequest{
+		Rules: []services.BulkPriceRule{{Action: services.PriceActionPercent, Value: 5}},
+	}, nil)
+	assert.Error(t, err, "rules must select a category or brand")
+}
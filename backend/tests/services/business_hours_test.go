@@ -0,0 +1,98 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBusinessHoursService_Availability(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	ctx := context.Background()
+	hours := services.NewBusinessHoursService(db, time.UTC)
+
+	availability, err := hours.Availability(ctx, services.DefaultStoreVariant, time.Now())
+	require.NoError(t, err)
+	assert.True(t, availability.Open, "stores without hours are always open")
+
+	var week []services.StoreHoursRequest
+	for weekday := time.Monday; weekday <= time.Friday; weekday++ {
+		week = append(week, services.StoreHoursRequest{Weekday: int(weekday), Opens: "09:00", Closes: "17:00", ShippingCutoff: "14:00"})
+	}
+	_, err = hours.SetHours(ctx, "", week)
+	require.NoError(t, err)
+	_, err = hours.SetHours(ctx, "", []services.StoreHoursRequest{{Weekday: 1, Opens: "17:00", Closes: "09:00"}})
+	assert.Error(t, err, "closing must come after opening")
+
+	friday := func(hour int) time.Time { return time.Date(2026, 10, 16, hour, 0, 0, 0, time.UTC) }
+	monday := time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+
+	availability, err = hours.Availability(ctx, services.DefaultStoreVariant, friday(10))
+	require.NoError(t, err)
+	assert.True(t, availability.Open)
+	require.NotNil(t, availability.ShippingCutoff)
+	assert.Equal(t, friday(14), *availability.ShippingCutoff)
+
+	availability, err = hours.Availability(ctx, services.DefaultStoreVariant, friday(15))
+	require.NoError(t, err)
+	assert.True(t, availability.Open)
+	require.NotNil(t, availability.NextShipDate)
+	assert.Equal(t, monday, *availability.NextShipDate, "orders after the cutoff ship next business day")
+
+	availability, err = hours.Availability(ctx, services.DefaultStoreVariant, friday(18))
+	require.NoError(t, err)
+	assert.False(t, availability.Open)
+	require.NotNil(t, availability.OpensAt)
+	assert.Equal(t, monday.Add(9*time.Hour), *availability.OpensAt)
+}
+
+func TestChatService_EscalatesAfterHours(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	ctx := context.Background()
+	hours := services.NewBusinessHoursService(db, time.UTC)
+
+	// Closed all week, so it's after hours whenever the test runs
+	var week []services.StoreHoursRequest
+	for weekday := 0; weekday < 7; weekday++ {
+		week = append(week, services.StoreHoursRequest{Weekday: weekday, Closed: true})
+	}
+	_, err := hours.SetHours(ctx, services.DefaultStoreVariant, week)
+	require.NoError(t, err)
+
+	llm := services.NewFakeLLM().Fallback(services.FakeLLMResponse{Content: "Happy to help!"})
+	chat := services.NewChatServiceWithProvider(db, llm, services.NewProductService(db), services.NewShoppingCartService(db))
+
+	response, err := chat.ProcessMessage(ctx, "after-hours", nil, "Can I talk to a human about my order?")
+	require.NoError(t, err)
+	assert.Contains(t, response.Message, "offline")
+	assert.Equal(t, false, response.Context["store_open"])
+	assert.Equal(t, 0, llm.CallCount(), "nobody can take over, so the assistant doesn't pretend to")
+
+	escalations, err := hours.ListEscalations(ctx, services.EscalationStatusPending)
+	require.NoError(t, err)
+	require.Len(t, escalations, 1)
+	assert.Equal(t, "after-hours", escalations[0].SessionID)
+
+	resolved, err := hours.ResolveEscalation(ctx, escalations[0].ID, nil, "called back")
+	require.NoError(t, err)
+	assert.Equal(t, services.EscalationStatusResolved, resolved.Status)
+	_, err = hours.ResolveEscalation(ctx, escalations[0].ID, nil, "")
+	assert.ErrorIs(t, err, services.ErrEscalationNotFound)
+
+	// Other questions still go to the assistant, told the store is closed
+	_, err = chat.ProcessMessage(ctx, "after-hours", nil, "Do you have headphones?")
+	require.NoError(t, err)
+	request, err := llm.LastRequest()
+	require.NoError(t, err)
+	assert.Contains(t, request.Messages[0].Content, "the store is closed right now")
+
+	var count int64
+	require.NoError(t, db.Model(&models.EscalationMessage{}).Count(&count).Error)
+	assert.Equal(t, int64(1), count)
+}
@@ -0,0 +1,94 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"chat-ecommerce-backend/tests/testutil/factories"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeBroadcaster records notices instead of writing to sockets
+type fakeBroadcaster struct {
+	sessions []string
+	notified map[string]int
+}
+
+func (b *fakeBroadcaster) NotifySession(sessionID, messageType string, data interface{}) {
+	if messageType == services.CampaignMessage {
+		b.notified[sessionID]++
+	}
+}
+
+func (b *fakeBroadcaster) ConnectedSessions() []string {
+	return b.sessions
+}
+
+func TestCampaignService(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	ctx := context.Background()
+	now := time.Now()
+
+	member := f.User()
+	segment := models.Segment{ID: uuid.New(), Slug: "vip", Name: "VIP"}
+	require.NoError(t, db.Create(&segment).Error)
+	require.NoError(t, db.Create(&models.SegmentMembership{ID: uuid.New(), SegmentID: segment.ID, UserID: member.ID, EvaluatedAt: now}).Error)
+	require.NoError(t, db.Create(&models.ChatSession{ID: uuid.New(), SessionID: "member", UserID: &member.ID, LastActivity: now, ExpiresAt: now.Add(time.Hour)}).Error)
+	require.NoError(t, db.Create(&models.ChatSession{ID: uuid.New(), SessionID: "guest", LastActivity: now, ExpiresAt: now.Add(time.Hour)}).Error)
+
+	broadcaster := &fakeBroadcaster{sessions: []string{"member", "guest"}, notified: map[string]int{}}
+	campaigns := services.NewCampaignService(db, services.CampaignConfig{SendRate: 10, MinInterval: time.Hour, SweepInterval: time.Minute})
+
+	later := now.Add(time.Hour)
+	vipSale, err := campaigns.CreateCampaign(ctx, nil, services.CampaignRequest{
+		Name: "VIP flash sale", Title: "Flash sale", Message: "20% off for the next hour", Segment: "vip", ScheduledAt: &later,
+	})
+	require.NoError(t, err)
+
+	preview, err := campaigns.Preview(ctx, services.CampaignRequest{Name: "VIP flash sale", Title: "Flash sale", Message: "20% off", Segment: "vip"}, broadcaster)
+	require.NoError(t, err)
+	assert.Equal(t, 1, preview.Audience, "segment campaigns reach members only")
+	assert.Empty(t, broadcaster.notified, "previews aren't sent")
+
+	sent, err := campaigns.DispatchDue(ctx, now, broadcaster)
+	require.NoError(t, err)
+	assert.Equal(t, 0, sent, "campaigns wait for their time")
+
+	sent, err = campaigns.DispatchDue(ctx, later, broadcaster)
+	require.NoError(t, err)
+	assert.Equal(t, 1, sent)
+	assert.Equal(t, map[string]int{"member": 1}, broadcaster.notified)
+
+	// A campaign to everyone skips the member, who just had one
+	everyone, err := campaigns.CreateCampaign(ctx, nil, services.CampaignRequest{Name: "Sitewide", Title: "Sale", Message: "Sale starts now"})
+	require.NoError(t, err)
+	_, err = campaigns.DispatchDue(ctx, later.Add(time.Minute), broadcaster)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"member": 1, "guest": 1}, broadcaster.notified)
+
+	stats, err := campaigns.GetStats(ctx, everyone.ID)
+	require.NoError(t, err)
+	assert.Equal(t, services.CampaignStatusSent, stats.Campaign.Status)
+	assert.Equal(t, 2, stats.Campaign.Targeted)
+	assert.Equal(t, 1, stats.Campaign.Delivered)
+	assert.Equal(t, 1, stats.Campaign.Throttled)
+
+	// Clicks count once per session, and only for delivered notices
+	require.NoError(t, campaigns.RecordClick(ctx, vipSale.ID, "member"))
+	require.NoError(t, campaigns.RecordClick(ctx, vipSale.ID, "member"))
+	require.NoError(t, campaigns.RecordClick(ctx, vipSale.ID, "guest"))
+	stats, err = campaigns.GetStats(ctx, vipSale.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, stats.Campaign.Clicked)
+	assert.Equal(t, 1.0, stats.ClickRate)
+
+	_, err = campaigns.CancelCampaign(ctx, vipSale.ID)
+	assert.ErrorIs(t, err, services.ErrCampaignNotScheduled, "sent campaigns can't be cancelled")
+}
@@ -0,0 +1,171 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"chat-ecommerce-backend/tests/testutil/factories"
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordedNotice struct {
+	sessionID   string
+	messageType string
+	notice      *services.CartReservationNotice
+}
+
+type recordingNotifier struct {
+	notices []recordedNotice
+}
+
+func (n *recordingNotifier) NotifySession(sessionID, messageType string, data interface{}) {
+	notice, _ := data.(*services.CartReservationNotice)
+	n.notices = append(n.notices, recordedNotice{sessionID, messageType, notice})
+}
+
+func reservationConfig() services.CartReservationConfig {
+	return services.CartReservationConfig{
+		Enabled:       true,
+		TTL:           15 * time.Minute,
+		WarnBefore:    2 * time.Minute,
+		SweepInterval: time.Minute,
+	}
+}
+
+func TestCartReservations_HoldBlocksOtherShoppers(t *testing.T) {
+	t.Setenv("CART_RESERVATIONS_ENABLED", "true")
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	carts := services.NewShoppingCartService(db)
+
+	product := f.StockedProduct(3)
+	require.NoError(t, carts.AddToCart("first", nil, services.AddToCartRequest{ProductID: product.ID, Quantity: 2}))
+
+	cart, err := carts.GetCart("first", nil)
+	require.NoError(t, err)
+	require.NotNil(t, cart.ReservedUntil)
+
+	err = carts.AddToCart("second", nil, services.AddToCartRequest{ProductID: product.ID, Quantity: 2})
+	assert.ErrorContains(t, err, "insufficient inventory")
+	require.NoError(t, carts.AddToCart("second", nil, services.AddToCartRequest{ProductID: product.ID, Quantity: 1}))
+
+	// Checking out stock held by another cart fails
+	buyer := f.User()
+	_, err = services.NewOrderService(db).CreateOrder(context.Background(), &services.CreateOrderRequest{
+		UserID:          buyer.ID,
+		SessionID:       "second",
+		Items:           []services.OrderItemRequest{{ProductID: product.ID, Quantity: 2}},
+		ShippingAddress: map[string]interface{}{"country": "US"},
+		BillingAddress:  map[string]interface{}{"country": "US"},
+		PaymentMethod:   "card",
+	})
+	var conflict *services.InventoryConflictError
+	require.ErrorAs(t, err, &conflict)
+	assert.Equal(t, 1, conflict.Available)
+
+	// Clearing the first cart frees its stock
+	require.NoError(t, carts.ClearCart("first", nil))
+	require.NoError(t, carts.UpdateCartItem("second", nil, services.UpdateCartItemRequest{ProductID: product.ID, Quantity: 3}))
+
+	var inventory models.Inventory
+	require.NoError(t, db.Where("product_id = ?", product.ID).First(&inventory).Error)
+	assert.Equal(t, 3, inventory.QuantityReserved)
+}
+
+func TestCartReservations_ConcurrentHoldsDontOversell(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	reservations := services.NewCartReservationService(db, reservationConfig())
+	product := f.StockedProduct(3)
+
+	// Ten shoppers reach for the last three units at once
+	var wg sync.WaitGroup
+	errs := make([]error, 10)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = reservations.Hold(context.Background(), fmt.Sprintf("shopper-%d", i), nil, product.ID, nil, 1)
+		}(i)
+	}
+	wg.Wait()
+
+	held := 0
+	for _, err := range errs {
+		if err == nil {
+			held++
+		} else {
+			assert.ErrorContains(t, err, "insufficient inventory")
+		}
+	}
+	assert.Equal(t, 3, held, "each unit is held once")
+
+	var inventory models.Inventory
+	require.NoError(t, db.Where("product_id = ?", product.ID).First(&inventory).Error)
+	assert.Equal(t, 3, inventory.QuantityReserved)
+}
+
+func TestCartReservations_SweepWarnsThenExpires(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	reservations := services.NewCartReservationService(db, reservationConfig())
+	notifier := &recordingNotifier{}
+	ctx := context.Background()
+
+	product := f.StockedProduct(5)
+	require.NoError(t, reservations.Hold(ctx, "shopper", nil, product.ID, nil, 2))
+
+	// Nothing to do while the hold is fresh
+	result, err := reservations.Sweep(ctx, time.Now(), notifier)
+	require.NoError(t, err)
+	assert.Zero(t, result.Warned)
+	assert.Empty(t, notifier.notices)
+
+	// One warning inside the window, however often the sweep runs
+	soon := time.Now().Add(14 * time.Minute)
+	for i := 0; i < 2; i++ {
+		result, err = reservations.Sweep(ctx, soon, notifier)
+		require.NoError(t, err)
+	}
+	require.Len(t, notifier.notices, 1)
+	assert.Equal(t, "shopper", notifier.notices[0].sessionID)
+	assert.Equal(t, services.ReservationExpiringMessage, notifier.notices[0].messageType)
+	assert.Equal(t, []uuid.UUID{product.ID}, notifier.notices[0].notice.ProductIDs)
+	assert.Positive(t, notifier.notices[0].notice.SecondsRemaining)
+
+	result, err = reservations.Sweep(ctx, time.Now().Add(16*time.Minute), notifier)
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Expired)
+	require.Len(t, notifier.notices, 2)
+	assert.Equal(t, services.ReservationExpiredMessage, notifier.notices[1].messageType)
+
+	var inventory models.Inventory
+	require.NoError(t, db.Where("product_id = ?", product.ID).First(&inventory).Error)
+	assert.Zero(t, inventory.QuantityReserved)
+
+	until, err := reservations.ReservedUntil(ctx, "shopper")
+	require.NoError(t, err)
+	assert.Nil(t, until)
+}
+
+func TestCartReservations_DisabledByDefault(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	carts := services.NewShoppingCartService(db)
+
+	product := f.StockedProduct(2)
+	require.NoError(t, carts.AddToCart("first", nil, services.AddToCartRequest{ProductID: product.ID, Quantity: 2}))
+	require.NoError(t, carts.AddToCart("second", nil, services.AddToCartRequest{ProductID: product.ID, Quantity: 2}))
+
+	var reservations int64
+	db.Model(&models.InventoryReservation{}).Count(&reservations)
+	assert.Zero(t, reservations)
+}
@@ -0,0 +1,102 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"chat-ecommerce-backend/tests/testutil/factories"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShoppingCartService_ShareAndClone(t *testing.T) {
+	t.Setenv("CART_SHARE_SECRET", "test-share-secret")
+	t.Setenv("CART_SHARE_BASE_URL", "https://shop.example/cart/shared/")
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	service := services.NewShoppingCartService(db)
+
+	camera := f.StockedProduct(10, func(p *models.Product) { p.Price = 300 })
+	lens := f.StockedProduct(1, func(p *models.Product) { p.Price = 120 })
+
+	_, err := service.ShareCart("owner", nil)
+	assert.Error(t, err, "empty carts cannot be shared")
+
+	require.NoError(t, service.AddToCart("owner", nil, services.AddToCartRequest{ProductID: camera.ID, Quantity: 2}))
+	require.NoError(t, service.AddToCart("owner", nil, services.AddToCartRequest{ProductID: lens.ID, Quantity: 1}))
+
+	share, err := service.ShareCart("owner", nil)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(share.URL, "https://shop.example/cart/shared/"+share.Token))
+
+	shared, err := service.GetSharedCart(share.Token)
+	require.NoError(t, err)
+	assert.Equal(t, 3, shared.ItemCount)
+
+	_, err = service.CloneSharedCart(share.Token, "owner", nil)
+	assert.Error(t, err, "owners cannot clone their own cart")
+
+	// The lens sells out before the recipient clones the cart
+	require.NoError(t, db.Model(&models.Inventory{}).Where("product_id = ?", lens.ID).Update("quantity_available", 0).Error)
+
+	result, err := service.CloneSharedCart(share.Token, "recipient", nil)
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.Cart.ItemCount)
+	require.Len(t, result.Skipped, 1)
+	assert.Equal(t, lens.ID, result.Skipped[0].ProductID)
+
+	// The shared cart itself is untouched
+	shared, err = service.GetSharedCart(share.Token)
+	require.NoError(t, err)
+	assert.Equal(t, 3, shared.ItemCount)
+}
+
+func TestShoppingCartService_GetSharedCart_RejectsBadTokens(t *testing.T) {
+	t.Setenv("CART_SHARE_SECRET", "test-share-secret")
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	service := services.NewShoppingCartService(db)
+
+	product := f.StockedProduct(5)
+	require.NoError(t, service.AddToCart("owner", nil, services.AddToCartRequest{ProductID: product.ID, Quantity: 1}))
+	share, err := service.ShareCart("owner", nil)
+	require.NoError(t, err)
+
+	_, err = service.GetSharedCart(share.Token + "x")
+	assert.ErrorIs(t, err, services.ErrInvalidShareToken)
+	_, err = service.GetSharedCart("not-a-token")
+	assert.ErrorIs(t, err, services.ErrInvalidShareToken)
+
+	// Tokens signed with a different secret are rejected
+	t.Setenv("CART_SHARE_SECRET", "rotated-secret")
+	_, err = service.GetSharedCart(share.Token)
+	assert.ErrorIs(t, err, services.ErrInvalidShareToken)
+
+	t.Setenv("CART_SHARE_SECRET", "test-share-secret")
+	t.Setenv("CART_SHARE_TTL_HOURS", "0")
+	expired, err := service.ShareCart("owner", nil)
+	require.NoError(t, err)
+	_, err = service.GetSharedCart(expired.Token)
+	assert.ErrorIs(t, err, services.ErrShareTokenExpired)
+}
+
+func TestChatService_ShareCartAction(t *testing.T) {
+	t.Setenv("CART_SHARE_SECRET", "test-share-secret")
+	fake := services.NewFakeLLM().Enqueue(services.FakeLLMResponse{
+		Content:   "Sure, you can send this to a friend.",
+		ToolCalls: []services.LLMToolCall{services.FakeToolCall("share_cart", map[string]interface{}{})},
+	})
+	service, db, product := setupFakeLLMChat(t, fake)
+	require.NoError(t, services.NewShoppingCartService(db).AddToCart("chat-share", nil, services.AddToCartRequest{ProductID: product.ID, Quantity: 1}))
+
+	response, err := service.ProcessMessage(context.Background(), "chat-share", nil, "Can I share my cart?")
+	require.NoError(t, err)
+	require.Len(t, response.Actions, 1)
+	url, ok := response.Actions[0].Payload["url"].(string)
+	require.True(t, ok)
+	assert.Contains(t, response.Message, "Here's a link to share your cart: "+url)
+}
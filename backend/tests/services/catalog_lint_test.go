@@ -0,0 +1,103 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"chat-ecommerce-backend/tests/testutil/factories"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// lintRules returns the rules reported for a record
+func lintRules(report *services.CatalogLintReport, id uuid.UUID) []string {
+	var rules []string
+	for _, issue := range report.Issues {
+		if issue.ResourceID == id {
+			rules = append(rules, issue.Rule)
+		}
+	}
+	return rules
+}
+
+func TestCatalogLint_Report(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	lint := services.NewCatalogLintService(db, services.CatalogLintConfig{})
+	ctx := context.Background()
+
+	category := f.Category()
+	clean := f.StockedProduct(5, func(p *models.Product) { p.CategoryID = category.ID })
+	f.Image(clean)
+	defaultVariant := f.Variant(clean, func(v *models.ProductVariant) { v.IsDefault = true })
+	f.Variant(clean)
+
+	bare := f.Product(func(p *models.Product) {
+		p.CategoryID = category.ID
+		p.Description = " "
+		p.SKU = "bad sku"
+	})
+	badVariant := f.Variant(bare, func(v *models.ProductVariant) { v.SKUSuffix = "/x" })
+
+	missingParent := uuid.New()
+	orphan := f.Category(func(c *models.Category) { c.ParentID = &missingParent })
+	empty := f.Category()
+
+	report, err := lint.Lint(ctx, services.CatalogLintFilter{}, time.Now())
+	require.NoError(t, err)
+
+	assert.Empty(t, lintRules(report, clean.ID), "a complete product has no issues")
+	assert.Empty(t, lintRules(report, defaultVariant.ID))
+	assert.ElementsMatch(t, []string{
+		services.LintNoInventory, services.LintSKUFormat, services.LintMissingImage,
+		services.LintEmptyDescription, services.LintNoDefaultVariant,
+	}, lintRules(report, bare.ID))
+	assert.Equal(t, []string{services.LintSKUFormat}, lintRules(report, badVariant.ID))
+	assert.Equal(t, []string{services.LintOrphanedCategory}, lintRules(report, orphan.ID))
+	assert.Equal(t, []string{services.LintEmptyCategory}, lintRules(report, empty.ID))
+
+	// Errors come first, with a link to the record to fix
+	require.NotEmpty(t, report.Issues)
+	assert.Equal(t, services.LintSeverityError, report.Issues[0].Severity)
+	for _, issue := range report.Issues {
+		if issue.ResourceID == badVariant.ID {
+			assert.Equal(t, "/api/v1/admin/products/"+bare.ID.String(), issue.Link)
+			assert.Equal(t, &bare.ID, issue.ProductID)
+		}
+	}
+	assert.Equal(t, 2, report.Rules[services.LintSKUFormat])
+
+	// Filtering keeps the more severe issues
+	severe, err := lint.Lint(ctx, services.CatalogLintFilter{Severity: services.LintSeverityError}, time.Now())
+	require.NoError(t, err)
+	for _, issue := range severe.Issues {
+		assert.Equal(t, services.LintSeverityError, issue.Severity)
+	}
+	assert.Equal(t, report.Severities[services.LintSeverityError], len(severe.Issues))
+
+	images, err := lint.Lint(ctx, services.CatalogLintFilter{Rule: services.LintMissingImage}, time.Now())
+	require.NoError(t, err)
+	require.Len(t, images.Issues, 1)
+	assert.Equal(t, bare.ID, images.Issues[0].ResourceID)
+
+	_, err = lint.Lint(ctx, services.CatalogLintFilter{Severity: "critical"}, time.Now())
+	assert.Error(t, err)
+}
+
+func TestCatalogLint_SKUPattern(t *testing.T) {
+	t.Setenv("CATALOG_SKU_PATTERN", `^[a-z]+$`)
+	config := services.CatalogLintConfigFromEnv()
+	assert.True(t, config.SKUPattern.MatchString("shirt"))
+	assert.False(t, config.SKUPattern.MatchString("TEST-001"))
+
+	// An invalid pattern keeps the default
+	t.Setenv("CATALOG_SKU_PATTERN", `[`)
+	config = services.CatalogLintConfigFromEnv()
+	assert.True(t, config.SKUPattern.MatchString("TSHIRT-BLK-M"))
+	assert.False(t, config.SKUPattern.MatchString("tshirt blk"))
+}
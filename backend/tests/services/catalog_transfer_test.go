@@ -0,0 +1,130 @@
+package services
+
+import (
+	"bytes"
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"chat-ecommerce-backend/tests/testutil/factories"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminProductService_CategoriesRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	source := testutil.NewTestDB(t)
+	f := factories.New(t, source)
+
+	root := f.Category(func(c *models.Category) { c.Slug = "electronics" })
+	audio := f.Category(func(c *models.Category) { c.Slug = "audio"; c.ParentID = &root.ID })
+	f.Category(func(c *models.Category) { c.Slug = "headphones"; c.ParentID = &audio.ID; c.SortOrder = 3 })
+
+	csvData, err := services.NewAdminProductService(source).ExportCategories(ctx)
+	require.NoError(t, err)
+
+	target := testutil.NewTestDB(t)
+	response, err := services.NewAdminProductService(target).ImportCategories(ctx, bytes.NewReader(csvData))
+	require.NoError(t, err)
+	assert.Empty(t, response.Errors)
+	assert.Equal(t, 3, response.Created)
+
+	var headphones, imported models.Category
+	require.NoError(t, target.First(&headphones, "slug = ?", "headphones").Error)
+	require.NoError(t, target.First(&imported, "slug = ?", "audio").Error)
+	assert.Equal(t, audio.ID, imported.ID, "exported IDs are kept")
+	require.NotNil(t, headphones.ParentID)
+	assert.Equal(t, imported.ID, *headphones.ParentID)
+	assert.Equal(t, 3, headphones.SortOrder)
+
+	// Importing the same file again updates in place
+	response, err = services.NewAdminProductService(target).ImportCategories(ctx, bytes.NewReader(csvData))
+	require.NoError(t, err)
+	assert.Equal(t, 0, response.Created)
+	assert.Equal(t, 3, response.Updated)
+}
+
+func TestAdminProductService_ImportCategories_OutOfOrderAndCycles(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	service := services.NewAdminProductService(db)
+
+	file := strings.Join([]string{
+		"slug,name,parent_slug",
+		"shirts,Shirts,apparel",
+		"apparel,Apparel,",
+		"loop-a,Loop A,loop-b",
+		"loop-b,Loop B,loop-a",
+		"orphan,Orphan,missing",
+	}, "\n")
+
+	response, err := service.ImportCategories(context.Background(), strings.NewReader(file))
+	require.NoError(t, err)
+	assert.Equal(t, 2, response.Created)
+	require.Len(t, response.Errors, 3)
+
+	keys := []string{}
+	for _, e := range response.Errors {
+		keys = append(keys, e.Key)
+	}
+	assert.ElementsMatch(t, []string{"loop-a", "loop-b", "orphan"}, keys)
+
+	var shirts, apparel models.Category
+	require.NoError(t, db.First(&shirts, "slug = ?", "shirts").Error)
+	require.NoError(t, db.First(&apparel, "slug = ?", "apparel").Error)
+	assert.Equal(t, apparel.ID, *shirts.ParentID)
+}
+
+func TestAdminProductService_VariantMatrixRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	service := services.NewAdminProductService(db)
+
+	product := f.Product(func(p *models.Product) { p.SKU = "TEE-1" })
+
+	file := strings.Join([]string{
+		"product_sku,options,sku_suffix,price_modifier,is_default,warehouse_location,quantity",
+		"TEE-1,Size:M;Color:Red,M-RED,0,true,,12",
+		"TEE-1,Size:M;Color:Blue,M-BLU,0,false,,4",
+		"TEE-1,Size:L;Color:Red,L-RED,2.50,false,,0",
+		"TEE-1,Size:L;Color:Blue,L-BLU,2.50,false,,",
+		"NOPE-9,Size:S,S,0,false,,1",
+	}, "\n")
+
+	response, err := service.ImportVariantMatrix(ctx, strings.NewReader(file))
+	require.NoError(t, err)
+	assert.Equal(t, 4, response.Created)
+	require.Len(t, response.Errors, 1)
+	assert.Equal(t, 5, response.Errors[0].Row)
+
+	var variant models.ProductVariant
+	require.NoError(t, db.First(&variant, "product_id = ? AND variant_value = ?", product.ID, "L / Red").Error)
+	assert.Equal(t, "Size / Color", variant.VariantName)
+	assert.InDelta(t, 2.50, variant.PriceModifier, 0.001)
+
+	var stock models.Inventory
+	require.NoError(t, db.First(&stock, "variant_id = ?", variant.ID).Error)
+	assert.Equal(t, "main", stock.WarehouseLocation)
+	assert.Equal(t, 0, stock.QuantityAvailable)
+
+	csvData, err := service.ExportVariantMatrix(ctx, services.ProductFilters{})
+	require.NoError(t, err)
+	assert.Contains(t, string(csvData), "TEE-1,Size:M;Color:Red,M-RED,0.00,true,main,12")
+	assert.Contains(t, string(csvData), "TEE-1,Size:L;Color:Blue,L-BLU,2.50,false,,")
+
+	// Re-importing the export only updates
+	response, err = service.ImportVariantMatrix(ctx, bytes.NewReader(csvData))
+	require.NoError(t, err)
+	assert.Empty(t, response.Errors)
+	assert.Equal(t, 0, response.Created)
+	assert.Equal(t, 4, response.Updated)
+
+	var count int64
+	db.Model(&models.ProductVariant{}).Where("product_id = ?", product.ID).Count(&count)
+	assert.Equal(t, int64(4), count)
+	db.Model(&models.Inventory{}).Where("product_id = ? AND variant_id IS NOT NULL", product.ID).Count(&count)
+	assert.Equal(t, int64(3), count)
+}
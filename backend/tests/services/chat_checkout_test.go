@@ -0,0 +1,162 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"chat-ecommerce-backend/tests/testutil/factories"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// chatAddress is the set_shipping_address arguments of a US address
+func chatAddress() map[string]interface{} {
+	return map[string]interface{}{
+		"name":        "Ada Lovelace",
+		"street":      "1 Infinite Loop",
+		"city":        "Cupertino",
+		"state":       "CA",
+		"postal_code": "95014",
+		"country":     "us",
+	}
+}
+
+// setupChatCheckout returns a chat service that can check out, with a
+// signed in shopper's session and two of a product in their cart
+func setupChatCheckout(t *testing.T, fake *services.FakeLLM, sessionID string) (*services.ChatService, *gorm.DB, *models.User, *models.Product) {
+	t.Helper()
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	carts := services.NewShoppingCartService(db)
+	payments := services.NewPaymentServiceWithRegistry(services.NewPaymentRegistry(services.PaymentProviderMock, nil, services.NewMockPaymentProvider()))
+	service := services.NewChatServiceWithProvider(db, fake, services.NewProductService(db), carts).
+		WithCheckout(services.NewOrderService(db), payments)
+
+	user := f.User()
+	product := f.StockedProduct(5)
+	_, err := service.GetChatSession(context.Background(), sessionID, &user.ID)
+	require.NoError(t, err)
+	require.NoError(t, carts.AddToCart(sessionID, &user.ID, services.AddToCartRequest{ProductID: product.ID, Quantity: 2}))
+	return service, db, user, product
+}
+
+func TestChatService_CheckoutInChat(t *testing.T) {
+	fake := services.NewFakeLLM().Enqueue(
+		services.FakeLLMResponse{
+			ToolCalls: []services.LLMToolCall{services.FakeToolCall("set_shipping_address", chatAddress())},
+		},
+		services.FakeLLMResponse{
+			Content:   "Great, placing it now.",
+			ToolCalls: []services.LLMToolCall{services.FakeToolCall("place_order", map[string]interface{}{})},
+		},
+	)
+	service, db, user, _ := setupChatCheckout(t, fake, "chat-checkout")
+	ctx := context.Background()
+
+	response, err := service.ProcessMessage(ctx, "chat-checkout", &user.ID, "Ship it to 1 Infinite Loop, Cupertino CA 95014")
+	require.NoError(t, err)
+	require.Len(t, response.Actions, 1)
+	quote, ok := response.Actions[0].Payload["quote"].(*services.OrderQuote)
+	require.True(t, ok)
+	assert.Equal(t, services.ChatCheckoutConfirm, response.Actions[0].Payload["step"])
+	assert.Greater(t, quote.TotalAmount, quote.Subtotal)
+	assert.Contains(t, response.Message, "Shall I place it?")
+
+	// The next turn's prompt knows a total is waiting to be confirmed
+	response, err = service.ProcessMessage(ctx, "chat-checkout", &user.ID, "Yes, place the order")
+	require.NoError(t, err)
+	req, err := fake.LastRequest()
+	require.NoError(t, err)
+	assert.Contains(t, req.Messages[0].Content, "hasn't confirmed it yet")
+
+	require.Len(t, response.Actions, 1)
+	payload := response.Actions[0].Payload
+	payment, ok := payload["payment"].(*services.PaymentIntentResponse)
+	require.True(t, ok)
+	assert.Equal(t, services.PaymentProviderMock, payment.Provider)
+	assert.NotEmpty(t, payment.ClientSecret)
+	assert.Contains(t, response.Message, "Order "+payload["order_number"].(string)+" is placed")
+
+	var order models.Order
+	require.NoError(t, db.Where("order_number = ?", payload["order_number"]).First(&order).Error)
+	assert.Equal(t, user.ID, order.UserID)
+	assert.Equal(t, "processing", order.PaymentStatus)
+	assert.Equal(t, payment.ID, order.PaymentIntentID)
+	assert.InDelta(t, quote.TotalAmount, order.TotalAmount, 0.001)
+	var shipping map[string]interface{}
+	require.NoError(t, json.Unmarshal(order.ShippingAddress, &shipping))
+	assert.Equal(t, "US", shipping["country"])
+
+	cart, err := services.NewShoppingCartService(db).GetCart("chat-checkout", &user.ID)
+	require.NoError(t, err)
+	assert.Empty(t, cart.Items, "the order holds the items now")
+}
+
+func TestChatService_CheckoutNeedsConfirmedTotal(t *testing.T) {
+	fake := services.NewFakeLLM().Enqueue(
+		services.FakeLLMResponse{
+			Content:   "Placing your order.",
+			ToolCalls: []services.LLMToolCall{services.FakeToolCall("place_order", map[string]interface{}{})},
+		},
+		services.FakeLLMResponse{
+			ToolCalls: []services.LLMToolCall{services.FakeToolCall("set_shipping_address", chatAddress())},
+		},
+		services.FakeLLMResponse{
+			ToolCalls: []services.LLMToolCall{services.FakeToolCall("place_order", map[string]interface{}{})},
+		},
+	)
+	service, db, user, product := setupChatCheckout(t, fake, "chat-unconfirmed")
+	ctx := context.Background()
+
+	// No order is placed before the shopper has seen a total
+	response, err := service.ProcessMessage(ctx, "chat-unconfirmed", &user.ID, "just buy it")
+	require.NoError(t, err)
+	assert.Empty(t, response.Actions)
+	assert.Contains(t, response.Message, "I need your shipping address")
+
+	_, err = service.ProcessMessage(ctx, "chat-unconfirmed", &user.ID, "ship to Cupertino")
+	require.NoError(t, err)
+
+	// Adding to the cart after the quote makes the total out of date
+	require.NoError(t, services.NewShoppingCartService(db).AddToCart("chat-unconfirmed", &user.ID, services.AddToCartRequest{ProductID: product.ID, Quantity: 1}))
+	response, err = service.ProcessMessage(ctx, "chat-unconfirmed", &user.ID, "yes")
+	require.NoError(t, err)
+	assert.Empty(t, response.Actions)
+	assert.Contains(t, response.Message, "changed since I gave you the total")
+
+	var orders int64
+	require.NoError(t, db.Model(&models.Order{}).Count(&orders).Error)
+	assert.Zero(t, orders)
+}
+
+func TestChatService_CheckoutToolsNeedOrders(t *testing.T) {
+	fake := services.NewFakeLLM("Hello!", "Hello!")
+	service, db, user, _ := setupChatCheckout(t, fake, "chat-tools")
+
+	_, err := service.ProcessMessage(context.Background(), "chat-tools", &user.ID, "hi")
+	require.NoError(t, err)
+	req, err := fake.LastRequest()
+	require.NoError(t, err)
+	var names []string
+	for _, tool := range req.Tools {
+		names = append(names, tool.Name)
+	}
+	assert.Contains(t, names, "set_shipping_address")
+	assert.Contains(t, names, "place_order")
+
+	// Without an order service the assistant sends shoppers to the checkout page
+	plain := services.NewChatServiceWithProvider(db, fake, services.NewProductService(db), services.NewShoppingCartService(db))
+	_, err = plain.ProcessMessage(context.Background(), "chat-tools", &user.ID, "hi")
+	require.NoError(t, err)
+	req, err = fake.LastRequest()
+	require.NoError(t, err)
+	for _, tool := range req.Tools {
+		assert.NotEqual(t, "place_order", tool.Name)
+	}
+	assert.NotContains(t, req.Messages[0].Content, "set_shipping_address")
+}
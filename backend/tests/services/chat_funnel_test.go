@@ -0,0 +1,94 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"chat-ecommerce-backend/tests/testutil/factories"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChatAnalytics_Funnel(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	ctx := context.Background()
+	lamp := f.StockedProduct(5, func(p *models.Product) { p.Name = "Desk Lamp"; p.Price = 40 })
+
+	fake := services.NewFakeLLM().Fallback(services.FakeLLMResponse{Content: "Here is a lamp you may like."})
+	chat := services.NewChatServiceWithProvider(db, fake, services.NewProductService(db), services.NewShoppingCartService(db))
+	for _, sessionID := range []string{"funnel-a", "funnel-b"} {
+		_, err := chat.GetChatSession(ctx, sessionID, nil)
+		require.NoError(t, err)
+		_, err = chat.ProcessMessage(ctx, sessionID, nil, "Show me a desk lamp")
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, chat.TrackSuggestionEvent(ctx, "funnel-a", nil, services.ChatEventSuggestionClicked, lamp.ID))
+	require.NoError(t, chat.TrackSuggestionEvent(ctx, "funnel-a", nil, services.ChatEventAddToCart, lamp.ID))
+
+	// Orders from a session that chatted are attributed to chat
+	_, err := services.NewOrderService(db).CreateOrder(ctx, &services.CreateOrderRequest{
+		UserID:          f.User().ID,
+		SessionID:       "funnel-a",
+		Items:           []services.OrderItemRequest{{ProductID: lamp.ID, Quantity: 1}},
+		ShippingAddress: map[string]interface{}{"country": "US"},
+		BillingAddress:  map[string]interface{}{"country": "US"},
+		PaymentMethod:   "card",
+	})
+	require.NoError(t, err)
+	_, err = services.NewOrderService(db).CreateOrder(ctx, &services.CreateOrderRequest{
+		UserID:          f.User().ID,
+		SessionID:       "no-chat",
+		Items:           []services.OrderItemRequest{{ProductID: lamp.ID, Quantity: 1}},
+		ShippingAddress: map[string]interface{}{"country": "US"},
+		BillingAddress:  map[string]interface{}{"country": "US"},
+		PaymentMethod:   "card",
+	})
+	require.NoError(t, err)
+
+	analytics := services.NewChatAnalyticsService(db)
+	funnel, err := analytics.Funnel(ctx, time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	require.Len(t, funnel.Stages, 5)
+	stages := make(map[string]services.ChatFunnelStage)
+	for _, stage := range funnel.Stages {
+		stages[stage.Stage] = stage
+	}
+	assert.Equal(t, services.ChatEventMessage, funnel.Stages[0].Stage)
+	assert.Equal(t, int64(2), stages[services.ChatEventMessage].Sessions)
+	assert.Equal(t, int64(2), stages[services.ChatEventSuggestionShown].Sessions)
+	assert.Equal(t, int64(1), stages[services.ChatEventSuggestionClicked].Sessions)
+	assert.Equal(t, 0.5, stages[services.ChatEventSuggestionClicked].Conversion)
+	assert.Equal(t, int64(1), stages[services.ChatEventAddToCart].Sessions)
+	assert.Equal(t, int64(1), stages[services.ChatEventOrder].Events)
+	assert.Equal(t, 0.5, stages[services.ChatEventOrder].OverallConversion)
+	assert.Greater(t, funnel.Revenue, 0.0)
+	assert.Equal(t, funnel.Revenue, funnel.AverageOrderValue)
+
+	// Only clicks and add to carts of real products are reported by the storefront
+	err = chat.TrackSuggestionEvent(ctx, "funnel-a", nil, services.ChatEventOrder, lamp.ID)
+	assert.ErrorIs(t, err, services.ErrInvalidChatEvent)
+	err = chat.TrackSuggestionEvent(ctx, "funnel-a", nil, services.ChatEventSuggestionClicked, uuid.New())
+	assert.ErrorIs(t, err, services.ErrInvalidChatEvent)
+}
+
+func TestChatAnalytics_UnansweredQueries(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	ctx := context.Background()
+	misses := services.NewSearchMissService(db)
+	misses.RecordMiss(ctx, "vintage typewriter", services.MissSourceChat, "s1", nil)
+	misses.RecordMiss(ctx, "vintage typewriter", services.MissSourceChat, "s2", nil)
+	misses.RecordMiss(ctx, "pogo stick", services.MissSourceSearch, "s3", nil)
+
+	queries, err := services.NewChatAnalyticsService(db).UnansweredQueries(ctx, time.Now().Add(-time.Hour), time.Now().Add(time.Hour), 10)
+	require.NoError(t, err)
+	require.Len(t, queries, 1)
+	assert.Equal(t, "vintage typewriter", queries[0].Query)
+	assert.Equal(t, 2, queries[0].ChatMisses)
+}
@@ -0,0 +1,146 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type handoffNotice struct {
+	sessionID   string
+	messageType string
+	data        interface{}
+}
+
+// handoffNotifier records what the admin channel and the shopper's chat are sent
+type handoffNotifier struct {
+	admins   []handoffNotice
+	sessions []handoffNotice
+}
+
+func (n *handoffNotifier) NotifyAdmins(messageType string, data interface{}) {
+	n.admins = append(n.admins, handoffNotice{messageType: messageType, data: data})
+}
+
+func (n *handoffNotifier) NotifySession(sessionID, messageType string, data interface{}) {
+	n.sessions = append(n.sessions, handoffNotice{sessionID, messageType, data})
+}
+
+func TestChatHandoff_AgentJoinsAndReplies(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	ctx := context.Background()
+	notifier := &handoffNotifier{}
+	llm := services.NewFakeLLM().Fallback(services.FakeLLMResponse{Content: "Happy to help!"})
+	chat := services.NewChatServiceWithProvider(db, llm, services.NewProductService(db), services.NewShoppingCartService(db)).
+		WithHandoff(notifier, notifier)
+	_, err := chat.GetChatSession(ctx, "handoff", nil)
+	require.NoError(t, err)
+
+	// Asking for a person flags the chat and alerts the admins
+	response, err := chat.ProcessMessage(ctx, "handoff", nil, "Can I speak to a real person please?")
+	require.NoError(t, err)
+	assert.Contains(t, response.Message, "asked a member of our team")
+	require.NotNil(t, response.Handoff)
+	assert.Equal(t, services.ChatSessionNeedsAgent, response.Handoff.Status)
+	assert.Equal(t, services.HandoffReasonRequested, response.Handoff.Reason)
+	assert.Zero(t, llm.CallCount())
+	require.Len(t, notifier.admins, 1)
+	assert.Equal(t, services.AdminChatHandoffMessage, notifier.admins[0].messageType)
+
+	waiting, err := chat.ListHandoffs(ctx, services.ChatSessionNeedsAgent)
+	require.NoError(t, err)
+	require.Len(t, waiting, 1)
+	assert.Equal(t, "handoff", waiting[0].SessionID)
+	assert.NotNil(t, waiting[0].RequestedAt)
+
+	// The assistant keeps helping until someone joins
+	response, err = chat.ProcessMessage(ctx, "handoff", nil, "Do you have headphones?")
+	require.NoError(t, err)
+	assert.Equal(t, "Happy to help!", response.Message)
+
+	agent, other := uuid.New(), uuid.New()
+	_, err = chat.SendAgentMessage(ctx, "handoff", agent, "Hi!")
+	assert.ErrorIs(t, err, services.ErrNotSessionAgent)
+
+	joined, err := chat.JoinSession(ctx, "handoff", agent)
+	require.NoError(t, err)
+	assert.Equal(t, services.ChatSessionWithAgent, joined.Status)
+	assert.Equal(t, &agent, joined.AgentID)
+	_, err = chat.JoinSession(ctx, "handoff", other)
+	assert.ErrorIs(t, err, services.ErrHandoffTaken)
+	_, err = chat.JoinSession(ctx, "missing", agent)
+	assert.ErrorIs(t, err, services.ErrHandoffNotFound)
+	last := notifier.sessions[len(notifier.sessions)-1]
+	assert.Equal(t, services.ChatAgentStatusMessage, last.messageType)
+
+	// With an agent in the chat, the shopper's messages go to them
+	calls := llm.CallCount()
+	response, err = chat.ProcessMessage(ctx, "handoff", nil, "Where is my order?")
+	require.NoError(t, err)
+	assert.Empty(t, response.Message)
+	assert.Equal(t, calls, llm.CallCount())
+	forwarded := notifier.admins[len(notifier.admins)-1]
+	assert.Equal(t, services.AdminChatMessage, forwarded.messageType)
+	assert.Equal(t, "Where is my order?", forwarded.data.(services.ChatMessageService).Content)
+
+	reply, err := chat.SendAgentMessage(ctx, "handoff", agent, "It ships tomorrow.")
+	require.NoError(t, err)
+	assert.Equal(t, services.ChatRoleAgent, reply.Role)
+	last = notifier.sessions[len(notifier.sessions)-1]
+	assert.Equal(t, services.ChatAgentMessage, last.messageType)
+	assert.Equal(t, "handoff", last.sessionID)
+	_, err = chat.SendAgentMessage(ctx, "handoff", other, "Me too")
+	assert.ErrorIs(t, err, services.ErrNotSessionAgent)
+
+	history, err := chat.GetConversationHistory(ctx, "handoff", 20)
+	require.NoError(t, err)
+	assert.Equal(t, "It ships tomorrow.", history[len(history)-1].Content)
+
+	// Handed back, the assistant answers again and sees the agent's replies
+	released, err := chat.ReleaseSession(ctx, "handoff")
+	require.NoError(t, err)
+	assert.Equal(t, services.ChatSessionActive, released.Status)
+	_, err = chat.ProcessMessage(ctx, "handoff", nil, "Thanks, anything else on sale?")
+	require.NoError(t, err)
+	request, err := llm.LastRequest()
+	require.NoError(t, err)
+	var sawAgent bool
+	for _, message := range request.Messages {
+		if message.Role == "assistant" && message.Content == "It ships tomorrow." {
+			sawAgent = true
+		}
+	}
+	assert.True(t, sawAgent, "the agent's reply is part of the conversation")
+
+	handoffs, err := chat.ListHandoffs(ctx, "")
+	require.NoError(t, err)
+	assert.Empty(t, handoffs)
+}
+
+func TestChatHandoff_FrustratedShopper(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	ctx := context.Background()
+	notifier := &handoffNotifier{}
+	llm := services.NewFakeLLM()
+	chat := services.NewChatServiceWithProvider(db, llm, services.NewProductService(db), services.NewShoppingCartService(db))
+	_, err := chat.GetChatSession(ctx, "frustrated", nil)
+	require.NoError(t, err)
+
+	// Without anyone to hand to, the assistant answers as before
+	response, err := chat.ProcessMessage(ctx, "frustrated", nil, "This is useless, you're not helping")
+	require.NoError(t, err)
+	assert.Nil(t, response.Handoff)
+
+	chat.WithHandoff(notifier, notifier)
+	response, err = chat.ProcessMessage(ctx, "frustrated", nil, "This is useless, you're not helping")
+	require.NoError(t, err)
+	assert.Contains(t, response.Message, "sorry")
+	require.NotNil(t, response.Handoff)
+	assert.Equal(t, services.HandoffReasonFrustrated, response.Handoff.Reason)
+	require.Len(t, notifier.admins, 1)
+}
@@ -0,0 +1,67 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"chat-ecommerce-backend/tests/testutil/factories"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectLanguage(t *testing.T) {
+	for message, language := range map[string]string{
+		"Show me some wireless headphones please":   services.ChatLanguageEnglish,
+		"Hola, busco zapatos para correr":           services.ChatLanguageSpanish,
+		"¿Cuánto cuesta el envío?":                  services.ChatLanguageSpanish,
+		"Olá, quero um presente para minha mãe":     services.ChatLanguagePortuguese,
+		"Bonjour, je cherche des chaussures":        services.ChatLanguageFrench,
+		"Ich suche eine Jacke für den Winter":       services.ChatLanguageGerman,
+		"Ciao, vorrei delle scarpe per la palestra": services.ChatLanguageItalian,
+		"ok":              "",
+		"Sony WH-1000XM5": "",
+	} {
+		assert.Equal(t, language, services.DetectLanguage(message), message)
+	}
+}
+
+func TestChatService_AnswersInShoppersLanguage(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	ctx := context.Background()
+	f.StockedProduct(5, func(p *models.Product) { p.Name = "Zapatos Running" })
+
+	fake := services.NewFakeLLM().Fallback(services.FakeLLMResponse{Content: "¡Claro! Aquí tienes algunas opciones."})
+	service := services.NewChatServiceWithProvider(db, fake, services.NewProductService(db), services.NewShoppingCartService(db))
+	_, err := service.GetChatSession(ctx, "idiomas", nil)
+	require.NoError(t, err)
+
+	response, err := service.ProcessMessage(ctx, "idiomas", nil, "Hola, quiero zapatos running por favor")
+	require.NoError(t, err)
+	assert.Equal(t, services.ChatLanguageSpanish, response.Language)
+	req, err := fake.LastRequest()
+	require.NoError(t, err)
+	assert.Contains(t, req.Messages[0].Content, "Always reply in Spanish")
+	require.NotEmpty(t, response.Suggestions)
+	assert.Equal(t, "Lo mencionaste", response.Suggestions[0].Reason)
+
+	var session models.ChatSession
+	require.NoError(t, db.Where("session_id = ?", "idiomas").First(&session).Error)
+	assert.Equal(t, services.ChatLanguageSpanish, session.Locale)
+
+	// Messages too short to tell keep the session's language
+	response, err = service.ProcessMessage(ctx, "idiomas", nil, "ok")
+	require.NoError(t, err)
+	assert.Equal(t, services.ChatLanguageSpanish, response.Language)
+
+	// Switching language switches the answers
+	response, err = service.ProcessMessage(ctx, "idiomas", nil, "Can you show me the running shoes in English?")
+	require.NoError(t, err)
+	assert.Equal(t, services.ChatLanguageEnglish, response.Language)
+	req, err = fake.LastRequest()
+	require.NoError(t, err)
+	assert.NotContains(t, req.Messages[0].Content, "Always reply in")
+}
@@ -0,0 +1,80 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"chat-ecommerce-backend/tests/testutil/factories"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChatService_RateLimit(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	user := factories.New(t, db).User()
+	fake := services.NewFakeLLM().Fallback(services.FakeLLMResponse{Content: "Sure!"})
+	limits := services.NewChatLimiter(db, services.ChatLimitConfig{SessionPerMinute: 2, UserPerMinute: 3})
+	service := services.NewChatServiceWithProvider(db, fake, services.NewProductService(db), services.NewShoppingCartService(db)).
+		WithLimits(limits)
+	ctx := context.Background()
+	for _, sessionID := range []string{"limited-a", "limited-b"} {
+		_, err := service.GetChatSession(ctx, sessionID, &user.ID)
+		require.NoError(t, err)
+	}
+
+	for i := 0; i < 2; i++ {
+		_, err := service.ProcessMessage(ctx, "limited-a", &user.ID, "show me boots")
+		require.NoError(t, err)
+	}
+	_, err := service.ProcessMessage(ctx, "limited-a", &user.ID, "and sandals?")
+	var limitErr *services.ChatLimitError
+	require.True(t, errors.As(err, &limitErr), "got %v", err)
+	assert.Equal(t, services.ChatLimitRate, limitErr.Limit)
+	assert.InDelta(t, 60, limitErr.RetryAfterSeconds(), 1)
+	assert.Contains(t, limitErr.Message(), "try again in")
+	assert.Equal(t, 2, fake.CallCount(), "limited messages never reach the model")
+
+	// The shopper's other sessions share their own limit
+	_, err = service.ProcessMessage(ctx, "limited-b", &user.ID, "show me boots")
+	require.NoError(t, err)
+	_, err = service.ProcessMessage(ctx, "limited-b", &user.ID, "show me boots")
+	require.True(t, errors.As(err, &limitErr), "got %v", err)
+
+	// Each reply's tokens are counted against the shopper's day
+	var usage models.ChatTokenUsage
+	require.NoError(t, db.Where("shopper = ?", "user:"+user.ID.String()).First(&usage).Error)
+	assert.Equal(t, 3, usage.Messages)
+	assert.Equal(t, fake.Usage().TotalTokens, usage.Tokens)
+}
+
+func TestChatService_DailyTokenBudget(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	fake := services.NewFakeLLM().Fallback(services.FakeLLMResponse{Content: "Here are some boots we have in stock right now."})
+	limits := services.NewChatLimiter(db, services.ChatLimitConfig{DailyTokens: 1})
+	service := services.NewChatServiceWithProvider(db, fake, services.NewProductService(db), services.NewShoppingCartService(db)).
+		WithLimits(limits)
+	ctx := context.Background()
+	_, err := service.GetChatSession(ctx, "budget", nil)
+	require.NoError(t, err)
+
+	_, err = service.ProcessMessage(ctx, "budget", nil, "show me boots")
+	require.NoError(t, err)
+	spent, err := limits.TokensUsed(ctx, "budget", nil, time.Now())
+	require.NoError(t, err)
+	assert.Greater(t, spent, 1, "anonymous shoppers are counted by session")
+
+	response, err := service.ProcessMessage(ctx, "budget", nil, "any in red?")
+	require.NoError(t, err)
+	assert.Equal(t, services.FallbackReasonBudgetExhausted, response.Context["fallback_reason"], "the fallback responder answers instead")
+	assert.Equal(t, 1, fake.CallCount())
+	assert.ErrorIs(t, limits.Allow(ctx, "budget", nil, time.Now()), services.ErrLLMBudgetExhausted)
+
+	// The budget starts over the next UTC day
+	tomorrow := time.Now().UTC().Add(24 * time.Hour)
+	assert.NoError(t, limits.Allow(ctx, "budget", nil, tomorrow))
+}
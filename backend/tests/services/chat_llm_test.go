@@ -0,0 +1,164 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func setupFakeLLMChat(t *testing.T, fake services.LLMProvider) (*services.ChatService, *gorm.DB, models.Product) {
+	db := testutil.NewTestDB(t)
+
+	category := models.Category{ID: uuid.New(), Name: "Electronics", Slug: "electronics", IsActive: true}
+	assert.NoError(t, db.Create(&category).Error)
+
+	product := models.Product{
+		ID:          uuid.New(),
+		Name:        "Wireless Headphones",
+		Description: "Noise cancelling wireless headphones",
+		Price:       99.99,
+		CategoryID:  category.ID,
+		SKU:         "WH-001",
+		Status:      "active",
+	}
+	assert.NoError(t, db.Create(&product).Error)
+
+	productService := services.NewProductService(db)
+	cartService := services.NewShoppingCartService(db)
+	chatService := services.NewChatServiceWithProvider(db, fake, productService, cartService)
+
+	return chatService, db, product
+}
+
+func TestChatService_ProcessMessage_FakeLLM(t *testing.T) {
+	fake := services.NewFakeLLM("I found some great headphones for you!")
+	service, _, _ := setupFakeLLMChat(t, fake)
+
+	response, err := service.ProcessMessage(context.Background(), "fake-session-1", nil, "Show me wireless headphones")
+	assert.NoError(t, err)
+	assert.Equal(t, "I found some great headphones for you!", response.Message)
+	assert.NotEmpty(t, response.Suggestions)
+	assert.Equal(t, "Wireless Headphones", response.Suggestions[0].Product.Name)
+
+	// The request sent to the provider includes the system prompt and user message
+	req, err := fake.LastRequest()
+	assert.NoError(t, err)
+	assert.Equal(t, "system", req.Messages[0].Role)
+	assert.Contains(t, req.Messages[0].Content, "Wireless Headphones")
+	assert.Equal(t, "Show me wireless headphones", req.Messages[len(req.Messages)-1].Content)
+
+	// Token accounting is tracked across calls
+	usage := fake.Usage()
+	assert.Equal(t, 1, fake.CallCount())
+	assert.Greater(t, usage.PromptTokens, 0)
+	assert.Greater(t, usage.CompletionTokens, 0)
+	assert.Equal(t, usage.PromptTokens+usage.CompletionTokens, usage.TotalTokens)
+}
+
+func TestChatService_ProcessMessage_FakeLLMAction(t *testing.T) {
+	fake := services.NewFakeLLM()
+	service, db, product := setupFakeLLMChat(t, fake)
+	fake.When("add", services.FakeLLMResponse{
+		Content:   "Added it to your cart!",
+		ToolCalls: []services.LLMToolCall{services.FakeToolCall("add_to_cart", map[string]interface{}{"product_id": product.ID, "quantity": 2})},
+	})
+
+	// Messages are only kept for started sessions
+	_, err := service.GetChatSession(context.Background(), "fake-session-2", nil)
+	assert.NoError(t, err)
+
+	response, err := service.ProcessMessage(context.Background(), "fake-session-2", nil, "Please add the headphones")
+	assert.NoError(t, err)
+	assert.Len(t, response.Actions, 1)
+	assert.Equal(t, "add_to_cart", response.Actions[0].Type)
+
+	cart, err := services.NewShoppingCartService(db).GetCart("fake-session-2", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, cart.ItemCount)
+
+	// Conversation history is replayed on the next turn
+	_, err = service.ProcessMessage(context.Background(), "fake-session-2", nil, "thanks")
+	assert.NoError(t, err)
+	req, _ := fake.LastRequest()
+	assert.Len(t, req.Messages, 4)
+}
+
+func TestChatService_ProcessMessage_FakeLLMError(t *testing.T) {
+	fake := services.NewFakeLLM().Enqueue(services.FakeLLMResponse{Err: errors.New("provider unavailable")})
+	service, _, _ := setupFakeLLMChat(t, fake)
+
+	_, err := service.ProcessMessage(context.Background(), "fake-session-3", nil, "hello")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "provider unavailable")
+}
+
+func TestFakeLLM_Stream(t *testing.T) {
+	fake := services.NewFakeLLM().Enqueue(
+		services.FakeLLMResponse{Content: "Here are some options for you"},
+		services.FakeLLMResponse{Content: "ignored", Chunks: []string{"Hel", "lo"}},
+	)
+	req := services.LLMRequest{
+		Model:    "fake",
+		Messages: []services.LLMMessage{{Role: "user", Content: "hi"}},
+	}
+
+	var chunks []string
+	response, err := fake.Stream(context.Background(), req, func(delta string) error {
+		chunks = append(chunks, delta)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "Here are some options for you", strings.Join(chunks, ""))
+	assert.Equal(t, "Here are some options for you", response.Content)
+	assert.Greater(t, len(chunks), 1)
+
+	chunks = nil
+	response, err = fake.Stream(context.Background(), req, func(delta string) error {
+		chunks = append(chunks, delta)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Hel", "lo"}, chunks)
+	assert.Equal(t, "Hello", response.Content)
+
+	// Callback errors abort the stream
+	_, err = fake.Stream(context.Background(), req, func(delta string) error {
+		return errors.New("client gone")
+	})
+	assert.Error(t, err)
+}
+
+func TestChatService_StreamMessage(t *testing.T) {
+	fake := services.NewFakeLLM().Enqueue(
+		services.FakeLLMResponse{Content: "I found some great headphones!", Chunks: []string{"I found ", "some great ", "headphones!"}},
+		services.FakeLLMResponse{Err: errors.New("provider unavailable")},
+	)
+	service, db, _ := setupFakeLLMChat(t, fake)
+	_, err := service.GetChatSession(context.Background(), "stream-session", nil)
+	assert.NoError(t, err)
+
+	var deltas []string
+	response, err := service.StreamMessage(context.Background(), "stream-session", nil, "Show me wireless headphones", func(delta string) error {
+		deltas = append(deltas, delta)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"I found ", "some great ", "headphones!"}, deltas)
+	assert.Equal(t, "I found some great headphones!", response.Message)
+	assert.NotEmpty(t, response.Suggestions)
+
+	var saved models.ChatMessage
+	assert.NoError(t, db.Where("session_id = ? AND role = ?", "stream-session", "assistant").First(&saved).Error)
+	assert.Equal(t, "I found some great headphones!", saved.Content)
+
+	_, err = service.StreamMessage(context.Background(), "stream-session", nil, "hello", func(string) error { return nil })
+	assert.Error(t, err)
+}
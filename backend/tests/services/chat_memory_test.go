@@ -0,0 +1,148 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"chat-ecommerce-backend/tests/testutil/factories"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// sessionMemory reads the memory kept in a session's context
+func sessionMemory(t *testing.T, db *gorm.DB, sessionID string) services.ChatMemory {
+	t.Helper()
+	var session models.ChatSession
+	require.NoError(t, db.Where("session_id = ?", sessionID).First(&session).Error)
+	var contextMap struct {
+		Memory services.ChatMemory `json:"memory"`
+	}
+	require.NoError(t, json.Unmarshal(session.Context, &contextMap))
+	return contextMap.Memory
+}
+
+// olderTurns returns alternating shopper and assistant messages
+func olderTurns(n int) []string {
+	contents := make([]string, n)
+	for i := range contents {
+		contents[i] = fmt.Sprintf("Earlier message %d about trail running shoes", i)
+	}
+	return contents
+}
+
+func TestChatService_MemoryRemembersPreferences(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	f.Category(func(c *models.Category) { c.Name = "Clothing" })
+	f.Category(func(c *models.Category) { c.Name = "Electronics" })
+
+	fake := services.NewFakeLLM("Here are some shirts.", "These fit your budget.")
+	service := services.NewChatServiceWithProvider(db, fake, services.NewProductService(db), services.NewShoppingCartService(db))
+	ctx := context.Background()
+
+	chatWith(t, db, service, "cs_memory", nil, time.Now())
+	_, err := service.ProcessMessage(ctx, "cs_memory", nil, "I love clothing, I wear size M and my budget is under $80")
+	require.NoError(t, err)
+
+	memory := sessionMemory(t, db, "cs_memory")
+	assert.Equal(t, []string{"M"}, memory.Preferences.Sizes)
+	require.NotNil(t, memory.Preferences.BudgetMax)
+	assert.Equal(t, 80.0, *memory.Preferences.BudgetMax)
+	assert.Equal(t, []string{"Clothing"}, memory.Preferences.LikedCategories)
+	assert.Empty(t, memory.Summary, "short conversations aren't summarized")
+
+	// The next turn's prompt carries the preferences, and newer ones come first
+	_, err = service.ProcessMessage(ctx, "cs_memory", nil, "Actually size L, and show me electronics too")
+	require.NoError(t, err)
+	req, err := fake.LastRequest()
+	require.NoError(t, err)
+	assert.Contains(t, req.Messages[0].Content, "```conversation-memory")
+	assert.Contains(t, req.Messages[0].Content, `"budget_max":80`)
+
+	memory = sessionMemory(t, db, "cs_memory")
+	assert.Equal(t, []string{"L", "M"}, memory.Preferences.Sizes)
+	assert.Equal(t, []string{"Electronics", "Clothing"}, memory.Preferences.LikedCategories)
+	assert.Equal(t, 80.0, *memory.Preferences.BudgetMax, "a budget is kept until another is given")
+}
+
+func TestChatService_MemorySummarizesOlderTurns(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	fake := services.NewFakeLLM().
+		Fallback(services.FakeLLMResponse{Content: "Those come in blue."}).
+		When(`"messages"`, services.FakeLLMResponse{Content: "The customer is looking for trail running shoes."})
+	service := services.NewChatServiceWithProvider(db, fake, services.NewProductService(db), services.NewShoppingCartService(db))
+	ctx := context.Background()
+
+	chatWith(t, db, service, "cs_long", nil, time.Now().Add(-time.Hour), olderTurns(18)...)
+	_, err := service.ProcessMessage(ctx, "cs_long", nil, "Do they come in blue?")
+	require.NoError(t, err)
+
+	// Everything but the history sent to the model is summarized
+	memory := sessionMemory(t, db, "cs_long")
+	assert.Equal(t, "The customer is looking for trail running shoes.", memory.Summary)
+	assert.Equal(t, 10, memory.SummarizedCount)
+
+	// The summary reaches the next turn's prompt, and a fresh batch hasn't built up yet
+	_, err = service.ProcessMessage(ctx, "cs_long", nil, "Thanks")
+	require.NoError(t, err)
+	req, err := fake.LastRequest()
+	require.NoError(t, err)
+	assert.Contains(t, req.Messages[0].Content, "looking for trail running shoes")
+	assert.Equal(t, 10, sessionMemory(t, db, "cs_long").SummarizedCount)
+}
+
+func TestChatService_MemorySummaryWithoutModel(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	fake := services.NewFakeLLM("Those come in blue.").
+		When(`"messages"`, services.FakeLLMResponse{Err: errors.New("provider unavailable")})
+	service := services.NewChatServiceWithProvider(db, fake, services.NewProductService(db), services.NewShoppingCartService(db))
+
+	chatWith(t, db, service, "cs_offline", nil, time.Now().Add(-time.Hour), olderTurns(18)...)
+	_, err := service.ProcessMessage(context.Background(), "cs_offline", nil, "Do they come in blue?")
+	require.NoError(t, err)
+
+	// The shopper's older questions are kept word for word instead
+	memory := sessionMemory(t, db, "cs_offline")
+	assert.Equal(t, 10, memory.SummarizedCount)
+	assert.Contains(t, memory.Summary, "Earlier message 0 about trail running shoes")
+	assert.NotContains(t, memory.Summary, "Earlier message 1 ", "assistant replies aren't quoted")
+}
+
+func TestChatService_MemoryCarriesToNewSession(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	f.Category(func(c *models.Category) { c.Name = "Shoes" })
+	user := f.User()
+
+	fake := services.NewFakeLLM("Noted.", "Here are some sneakers.")
+	service := services.NewChatServiceWithProvider(db, fake, services.NewProductService(db), services.NewShoppingCartService(db))
+	ctx := context.Background()
+
+	chatWith(t, db, service, "cs_first", &user.ID, time.Now())
+	_, err := service.ProcessMessage(ctx, "cs_first", &user.ID, "I take a 9.5 in shoes")
+	require.NoError(t, err)
+	chatWith(t, db, service, "cs_second", &user.ID, time.Now())
+	_, err = service.ProcessMessage(ctx, "cs_second", &user.ID, "Show me sneakers")
+	require.NoError(t, err)
+
+	req, err := fake.LastRequest()
+	require.NoError(t, err)
+	assert.Contains(t, req.Messages[0].Content, `"sizes":["9.5"]`)
+	assert.Equal(t, []string{"9.5"}, sessionMemory(t, db, "cs_second").Preferences.Sizes)
+
+	// Anonymous shoppers start fresh
+	chatWith(t, db, service, "cs_guest", nil, time.Now())
+	_, err = service.ProcessMessage(ctx, "cs_guest", nil, "Show me sneakers")
+	require.NoError(t, err)
+	req, err = fake.LastRequest()
+	require.NoError(t, err)
+	assert.NotContains(t, req.Messages[0].Content, "```conversation-memory")
+}
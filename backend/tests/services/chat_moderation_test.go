@@ -0,0 +1,137 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// failingModerator is a moderation provider that is down
+type failingModerator struct{}
+
+func (failingModerator) Moderate(ctx context.Context, text string) (*services.ModerationVerdict, error) {
+	return nil, errors.New("moderation unavailable")
+}
+
+// messageModeration returns the moderation recorded on a session's messages, oldest first
+func messageModeration(t *testing.T, db *gorm.DB, sessionID string) []*services.ChatModeration {
+	t.Helper()
+	var messages []models.ChatMessage
+	require.NoError(t, db.Where("session_id = ?", sessionID).Order("created_at ASC").Find(&messages).Error)
+	moderation := make([]*services.ChatModeration, len(messages))
+	for i, message := range messages {
+		var metadata struct {
+			Moderation *services.ChatModeration `json:"moderation"`
+		}
+		if len(message.Metadata) > 0 {
+			require.NoError(t, json.Unmarshal(message.Metadata, &metadata))
+		}
+		moderation[i] = metadata.Moderation
+	}
+	return moderation
+}
+
+func TestChatService_ModerationBlocksInput(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	fake := services.NewFakeLLM("Here you go!")
+	moderator := services.NewKeywordModerator(map[string][]string{"violence": {"hurt someone"}, "harassment": {"idiot"}})
+	config := services.ModerationConfig{FlagOnly: map[string]bool{"harassment": true}}
+	service := services.NewChatServiceWithProvider(db, fake, services.NewProductService(db), services.NewShoppingCartService(db)).
+		WithModeration(moderator, config)
+	ctx := context.Background()
+	_, err := service.GetChatSession(ctx, "moderated", nil)
+	require.NoError(t, err)
+
+	response, err := service.ProcessMessage(ctx, "moderated", nil, "How do I hurt someone with this knife?")
+	require.NoError(t, err)
+	assert.Equal(t, services.ModerationInputReply, response.Message)
+	assert.Equal(t, services.ModerationBlocked, response.Context["moderation"])
+	assert.Zero(t, fake.CallCount(), "blocked messages never reach the model")
+
+	// Flag-only categories go through, recorded for review
+	response, err = service.ProcessMessage(ctx, "moderated", nil, "you idiot, show me boots")
+	require.NoError(t, err)
+	assert.Equal(t, "Here you go!", response.Message)
+
+	moderation := messageModeration(t, db, "moderated")
+	require.Len(t, moderation, 4)
+	require.NotNil(t, moderation[0])
+	assert.Equal(t, services.ModerationStageInput, moderation[0].Stage)
+	assert.Equal(t, services.ModerationBlocked, moderation[0].Action)
+	assert.Equal(t, []string{"violence"}, moderation[0].Categories)
+	assert.Nil(t, moderation[1], "the refusal itself isn't moderated")
+	require.NotNil(t, moderation[2])
+	assert.Equal(t, services.ModerationFlagged, moderation[2].Action)
+	assert.Nil(t, moderation[3], "clean replies carry no moderation")
+}
+
+func TestChatService_ModerationReplacesOutput(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	fake := services.NewFakeLLM().Enqueue(services.FakeLLMResponse{
+		Content:   "Buy this, you idiot.",
+		ToolCalls: []services.LLMToolCall{services.FakeToolCall("share_cart", map[string]interface{}{})},
+	})
+	moderator := services.NewKeywordModerator(map[string][]string{"harassment": {"idiot"}})
+	service := services.NewChatServiceWithProvider(db, fake, services.NewProductService(db), services.NewShoppingCartService(db)).
+		WithModeration(moderator, services.ModerationConfig{})
+	ctx := context.Background()
+	_, err := service.GetChatSession(ctx, "moderated-reply", nil)
+	require.NoError(t, err)
+
+	response, err := service.ProcessMessage(ctx, "moderated-reply", nil, "share my cart")
+	require.NoError(t, err)
+	assert.Equal(t, services.ModerationOutputReply, response.Message)
+	assert.Empty(t, response.Actions, "a blocked reply's tool calls are dropped")
+
+	moderation := messageModeration(t, db, "moderated-reply")
+	require.Len(t, moderation, 2)
+	assert.Nil(t, moderation[0])
+	require.NotNil(t, moderation[1])
+	assert.Equal(t, services.ModerationStageOutput, moderation[1].Stage)
+	assert.Equal(t, services.ModerationBlocked, moderation[1].Action)
+}
+
+func TestChatService_ModerationFailsOpen(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	fake := services.NewFakeLLM("Happy to help!")
+	service := services.NewChatServiceWithProvider(db, fake, services.NewProductService(db), services.NewShoppingCartService(db)).
+		WithModeration(failingModerator{}, services.ModerationConfig{})
+	ctx := context.Background()
+	_, err := service.GetChatSession(ctx, "moderation-down", nil)
+	require.NoError(t, err)
+
+	response, err := service.ProcessMessage(ctx, "moderation-down", nil, "show me boots")
+	require.NoError(t, err)
+	assert.Equal(t, "Happy to help!", response.Message)
+
+	moderation := messageModeration(t, db, "moderation-down")
+	require.Len(t, moderation, 2)
+	require.NotNil(t, moderation[0])
+	assert.Equal(t, services.ModerationSkipped, moderation[0].Action)
+	assert.Contains(t, moderation[0].Error, "moderation unavailable")
+}
+
+func TestModerationConfigFromEnv(t *testing.T) {
+	t.Setenv("OPENAI_API_KEY", "")
+	t.Setenv("CHAT_MODERATION_PROVIDER", "")
+	assert.Nil(t, services.ModeratorFromConfig(services.ModerationConfigFromEnv()), "moderation is off without a provider")
+
+	t.Setenv("CHAT_MODERATION_PROVIDER", "keywords")
+	t.Setenv("CHAT_MODERATION_BLOCKLIST", "scam, counterfeit")
+	t.Setenv("CHAT_MODERATION_FLAG_ONLY", " Harassment ")
+	config := services.ModerationConfigFromEnv()
+	assert.True(t, config.FlagOnly["harassment"])
+
+	verdict, err := services.ModeratorFromConfig(config).Moderate(context.Background(), "Is this a Counterfeit?")
+	require.NoError(t, err)
+	assert.True(t, verdict.Flagged)
+	assert.Equal(t, []string{"blocklist"}, verdict.Categories)
+}
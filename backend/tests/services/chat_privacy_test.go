@@ -0,0 +1,122 @@
+package services
+
+import (
+	"bytes"
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"chat-ecommerce-backend/tests/testutil/factories"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/datatypes"
+)
+
+// chatPrivacyFixture creates a conversation of the user and one of another
+// shopper the user also wrote in
+func chatPrivacyFixture(t *testing.T, f *factories.Factory, user *models.User) (mine, theirs models.ChatSession) {
+	t.Helper()
+	db := f.DB()
+	now := time.Now()
+	mine = models.ChatSession{ID: uuid.New(), SessionID: "mine", UserID: &user.ID, Status: "active", LastActivity: now, CreatedAt: now.Add(-time.Hour)}
+	theirs = models.ChatSession{ID: uuid.New(), SessionID: "theirs", Status: "active", LastActivity: now}
+	require.NoError(t, db.Create(&mine).Error)
+	require.NoError(t, db.Create(&theirs).Error)
+
+	messages := []models.ChatMessage{
+		{ID: uuid.New(), ChatSessionID: mine.ID, SessionID: "mine", UserID: &user.ID, Role: "user", Content: "Do you have red sneakers?", CreatedAt: now.Add(-time.Minute)},
+		{ID: uuid.New(), ChatSessionID: mine.ID, SessionID: "mine", Role: "assistant", Content: "Yes, we have two pairs.",
+			Metadata: datatypes.JSON(`{"suggestions":["sneaker-1"]}`), CreatedAt: now},
+		{ID: uuid.New(), ChatSessionID: theirs.ID, SessionID: "theirs", UserID: &user.ID, Role: "user", Content: "My address is 1 Main St",
+			Metadata: datatypes.JSON(`{"address":"1 Main St"}`), CreatedAt: now},
+		{ID: uuid.New(), ChatSessionID: theirs.ID, SessionID: "theirs", Role: "assistant", Content: "Thanks!", CreatedAt: now},
+	}
+	require.NoError(t, db.Create(&messages).Error)
+	return mine, theirs
+}
+
+func TestChatService_ExportUserHistory(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	ctx := context.Background()
+	chat := services.NewChatServiceWithProvider(db, services.NewFakeLLM(), services.NewProductService(db), services.NewShoppingCartService(db))
+	user := f.User()
+	chatPrivacyFixture(t, f, user)
+
+	export, err := chat.ExportUserHistory(ctx, user.ID, time.Now())
+	require.NoError(t, err)
+	require.Len(t, export.Sessions, 1, "only the user's conversations are exported")
+	session := export.Sessions[0]
+	assert.Equal(t, "mine", session.SessionID)
+	require.Len(t, session.Messages, 2)
+	assert.Equal(t, "Do you have red sneakers?", session.Messages[0].Content)
+	assert.Empty(t, session.Messages[0].Metadata)
+	assert.JSONEq(t, `{"suggestions":["sneaker-1"]}`, string(session.Messages[1].Metadata))
+
+	encoded, err := json.Marshal(export)
+	require.NoError(t, err)
+	assert.Contains(t, string(encoded), `"role":"assistant"`)
+
+	pdf := services.RenderChatHistoryPDF(export)
+	assert.True(t, bytes.HasPrefix(pdf, []byte("%PDF-1.4")))
+	assert.Contains(t, string(pdf), "Do you have red sneakers?")
+}
+
+func TestChatService_PurgeUserHistoryJob(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	ctx := context.Background()
+	chat := services.NewChatServiceWithProvider(db, services.NewFakeLLM(), services.NewProductService(db), services.NewShoppingCartService(db))
+	jobs := services.NewJobService(db, services.JobConfig{Workers: 1})
+	user := f.User()
+	mine, theirs := chatPrivacyFixture(t, f, user)
+	require.NoError(t, db.Create(&models.ChatEvent{ID: uuid.New(), SessionID: "mine", UserID: &user.ID, Type: "message"}).Error)
+	require.NoError(t, db.Create(&models.SessionLink{ID: uuid.New(), SessionID: "storefront", ChatSessionID: "mine", UserID: &user.ID}).Error)
+
+	job, err := jobs.Start(ctx, services.JobKindChatPurge, &user.ID, nil, func(ctx context.Context) (*services.JobOutput, error) {
+		result, err := chat.PurgeUserHistory(ctx, user.ID)
+		if err != nil {
+			return nil, err
+		}
+		return &services.JobOutput{Result: result}, nil
+	})
+	require.NoError(t, err)
+	jobs.Wait()
+
+	finished, err := jobs.GetJob(ctx, job.ID)
+	require.NoError(t, err)
+	require.Equal(t, services.JobStatusSucceeded, finished.Status, finished.Error)
+	assert.Equal(t, 100, finished.Progress)
+	var result services.ChatPurgeResult
+	require.NoError(t, json.Unmarshal(finished.Result, &result))
+	assert.Equal(t, services.ChatPurgeResult{Sessions: 1, Messages: 2, Scrubbed: 1}, result)
+
+	var count int64
+	db.Model(&models.ChatSession{}).Where("id = ?", mine.ID).Count(&count)
+	assert.Zero(t, count)
+	db.Model(&models.ChatMessage{}).Where("chat_session_id = ?", mine.ID).Count(&count)
+	assert.Zero(t, count)
+	db.Model(&models.SessionLink{}).Count(&count)
+	assert.Zero(t, count)
+
+	var scrubbed models.ChatMessage
+	require.NoError(t, db.Where("chat_session_id = ? AND role = ?", theirs.ID, "user").First(&scrubbed).Error)
+	assert.Empty(t, scrubbed.Content)
+	assert.Empty(t, scrubbed.Metadata)
+	assert.Nil(t, scrubbed.UserID)
+	db.Model(&models.ChatMessage{}).Where("chat_session_id = ? AND content = ?", theirs.ID, "Thanks!").Count(&count)
+	assert.Equal(t, int64(1), count, "the other shopper's conversation is kept")
+
+	var event models.ChatEvent
+	require.NoError(t, db.First(&event).Error)
+	assert.Nil(t, event.UserID, "analytics are kept without pointing at the user")
+
+	export, err := chat.ExportUserHistory(ctx, user.ID, time.Now())
+	require.NoError(t, err)
+	assert.Empty(t, export.Sessions)
+}
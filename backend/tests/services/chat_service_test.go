@@ -3,35 +3,17 @@ package services
 import (
 	"chat-ecommerce-backend/internal/models"
 	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"context"
 	"encoding/json"
 	"testing"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
-	"gorm.io/driver/sqlite"
-	"gorm.io/gorm"
 )
 
-func setupTestDB(t *testing.T) *gorm.DB {
-	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
-	if err != nil {
-		t.Fatal("Failed to connect to test database:", err)
-	}
-
-	// Auto-migrate the database
-	err = db.AutoMigrate(
-		&models.ChatSession{},
-		&models.ChatMessage{},
-	)
-	if err != nil {
-		t.Fatal("Failed to migrate test database:", err)
-	}
-
-	return db
-}
-
 func TestChatService_GetChatSession(t *testing.T) {
-	db := setupTestDB(t)
+	db := testutil.NewTestDB(t)
 
 	// Create mock services
 	productService := services.NewProductService(db)
@@ -42,21 +24,21 @@ func TestChatService_GetChatSession(t *testing.T) {
 	userID := uuid.New()
 
 	// Test creating new session
-	session, err := service.GetChatSession(sessionID, &userID)
+	session, err := service.GetChatSession(context.Background(), sessionID, &userID)
 	assert.NoError(t, err)
 	assert.NotNil(t, session)
 	assert.Equal(t, sessionID, session.ID)
 	assert.Equal(t, &userID, session.UserID)
 
 	// Test retrieving existing session
-	session2, err := service.GetChatSession(sessionID, &userID)
+	session2, err := service.GetChatSession(context.Background(), sessionID, &userID)
 	assert.NoError(t, err)
 	assert.NotNil(t, session2)
 	assert.Equal(t, sessionID, session2.ID)
 }
 
 func TestChatService_SearchProducts(t *testing.T) {
-	db := setupTestDB(t)
+	db := testutil.NewTestDB(t)
 
 	// Create mock services
 	productService := services.NewProductService(db)
@@ -64,7 +46,7 @@ func TestChatService_SearchProducts(t *testing.T) {
 	service := services.NewChatService(db, productService, cartService)
 
 	// Test search products
-	suggestions, err := service.SearchProducts("test", 5)
+	suggestions, err := service.SearchProducts(context.Background(), "test", 5)
 	assert.NoError(t, err)
 	assert.NotNil(t, suggestions)
 	// Should return empty array since no products in test DB
@@ -72,7 +54,7 @@ func TestChatService_SearchProducts(t *testing.T) {
 }
 
 func TestChatService_GetProductRecommendations(t *testing.T) {
-	db := setupTestDB(t)
+	db := testutil.NewTestDB(t)
 
 	// Create mock services
 	productService := services.NewProductService(db)
@@ -83,7 +65,7 @@ func TestChatService_GetProductRecommendations(t *testing.T) {
 	userID := uuid.New()
 
 	// Test getting recommendations
-	suggestions, err := service.GetProductRecommendations(sessionID, &userID, 5)
+	suggestions, err := service.GetProductRecommendations(context.Background(), sessionID, &userID, 5)
 	assert.NoError(t, err)
 	assert.NotNil(t, suggestions)
 	// Should return empty array since no products in test DB
@@ -91,7 +73,7 @@ func TestChatService_GetProductRecommendations(t *testing.T) {
 }
 
 func TestChatService_GetConversationHistory(t *testing.T) {
-	db := setupTestDB(t)
+	db := testutil.NewTestDB(t)
 
 	// Create mock services
 	productService := services.NewProductService(db)
@@ -101,7 +83,7 @@ func TestChatService_GetConversationHistory(t *testing.T) {
 	sessionID := "test-session-history"
 
 	// Get conversation history (should be empty)
-	history, err := service.GetConversationHistory(sessionID, 10)
+	history, err := service.GetConversationHistory(context.Background(), sessionID, 10)
 	assert.NoError(t, err)
 	assert.NotNil(t, history)
 	assert.Len(t, history, 0)
@@ -109,7 +91,7 @@ func TestChatService_GetConversationHistory(t *testing.T) {
 
 func TestProductSuggestion_JSONSerialization(t *testing.T) {
 	// Setup test database with products
-	db := setupTestDB(t)
+	db := testutil.NewTestDB(t)
 
 	// Migrate Product, Category, and Inventory models
 	err := db.AutoMigrate(&models.Product{}, &models.Category{}, &models.Inventory{})
@@ -0,0 +1,181 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"chat-ecommerce-backend/tests/testutil/factories"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// chatWith starts a session and stores its messages a minute apart from start
+func chatWith(t *testing.T, db *gorm.DB, service *services.ChatService, sessionID string, userID *uuid.UUID, start time.Time, contents ...string) {
+	t.Helper()
+	_, err := service.GetChatSession(context.Background(), sessionID, userID)
+	require.NoError(t, err)
+
+	var session models.ChatSession
+	require.NoError(t, db.Where("session_id = ?", sessionID).First(&session).Error)
+	for i, content := range contents {
+		role := "user"
+		if i%2 == 1 {
+			role = "assistant"
+		}
+		require.NoError(t, db.Create(&models.ChatMessage{
+			ID:            uuid.New(),
+			ChatSessionID: session.ID,
+			SessionID:     sessionID,
+			UserID:        userID,
+			Role:          role,
+			Content:       content,
+			CreatedAt:     start.Add(time.Duration(i) * time.Minute),
+		}).Error)
+	}
+}
+
+func TestChatService_ListUserSessions(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	service := services.NewChatService(db, services.NewProductService(db), services.NewShoppingCartService(db))
+	ctx := context.Background()
+
+	user := f.User()
+	other := f.User()
+	start := time.Now().Add(-72 * time.Hour).UTC()
+
+	chatWith(t, db, service, "cs_laptop", &user.ID, start, "Looking for a laptop", "Here are some laptops", "Something lighter?")
+	chatWith(t, db, service, "cs_shoes", &user.ID, start.Add(24*time.Hour), "Running shoes under $100", "These fit your budget")
+	chatWith(t, db, service, "cs_empty", &user.ID, start)
+	chatWith(t, db, service, "cs_other", &other.ID, start, "Not yours")
+
+	page, err := service.ListUserSessions(ctx, user.ID, 1, 10)
+	require.NoError(t, err)
+	require.Len(t, page.Sessions, 2, "empty sessions and other users' sessions are left out")
+	assert.Equal(t, int64(2), page.Total)
+
+	recent := page.Sessions[0]
+	assert.Equal(t, "cs_shoes", recent.SessionID, "most recently used first")
+	assert.Equal(t, "Running shoes under $100", recent.Title)
+	assert.Equal(t, "These fit your budget", recent.LastMessage)
+	assert.Equal(t, "assistant", recent.LastRole)
+	assert.Equal(t, 2, recent.MessageCount)
+
+	older := page.Sessions[1]
+	assert.Equal(t, "Looking for a laptop", older.Title)
+	assert.Equal(t, "Something lighter?", older.LastMessage)
+	assert.Equal(t, 3, older.MessageCount)
+
+	second, err := service.ListUserSessions(ctx, user.ID, 2, 1)
+	require.NoError(t, err)
+	require.Len(t, second.Sessions, 1)
+	assert.Equal(t, "cs_laptop", second.Sessions[0].SessionID)
+	assert.True(t, second.HasPrevious)
+	assert.False(t, second.HasNext)
+}
+
+func TestChatService_ResumeUserSession(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	service := services.NewChatService(db, services.NewProductService(db), services.NewShoppingCartService(db))
+	ctx := context.Background()
+
+	user := f.User()
+	start := time.Now().Add(-72 * time.Hour).UTC()
+	chatWith(t, db, service, "cs_old", &user.ID, start, "Do you sell tents?", "Yes, three models")
+	require.NoError(t, db.Model(&models.ChatSession{}).Where("session_id = ?", "cs_old").
+		Updates(map[string]interface{}{"status": "expired", "expires_at": start}).Error)
+
+	now := time.Now()
+	resumed, err := service.ResumeUserSession(ctx, user.ID, "cs_old", now)
+	require.NoError(t, err)
+	require.Len(t, resumed.Messages, 3, "the history and a welcome back greeting")
+	assert.Equal(t, "Do you sell tents?", resumed.Messages[0].Content)
+	assert.Equal(t, resumed.Greeting, resumed.Messages[2].Content)
+
+	var session models.ChatSession
+	require.NoError(t, db.Where("session_id = ?", "cs_old").First(&session).Error)
+	assert.Equal(t, "active", session.Status)
+	assert.True(t, session.ExpiresAt.After(now))
+
+	_, err = service.ResumeUserSession(ctx, f.User().ID, "cs_old", now)
+	assert.ErrorIs(t, err, services.ErrChatSessionForbidden, "other users can't resume it")
+
+	// Anonymous sessions join the account of the shopper who signs in
+	chatWith(t, db, service, "cs_anon", nil, start, "Hi")
+	require.NoError(t, service.ClaimSession(ctx, "cs_anon", user.ID))
+	page, err := service.ListUserSessions(ctx, user.ID, 1, 10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), page.Total)
+	require.NoError(t, service.ClaimSession(ctx, "cs_anon", f.User().ID))
+	page, err = service.ListUserSessions(ctx, user.ID, 1, 10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), page.Total, "claimed sessions can't be taken over")
+}
+
+func TestChatService_ResumeRestoresContext(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	electronics := f.Category(func(c *models.Category) { c.Name = "Electronics" })
+	headphones := f.StockedProduct(10, func(p *models.Product) {
+		p.Name = "Wireless Headphones"
+		p.CategoryID = electronics.ID
+	})
+
+	fake := services.NewFakeLLM("I found some great headphones for you!")
+	fake.When("add", services.FakeLLMResponse{
+		Content:   "Added it to your cart!",
+		ToolCalls: []services.LLMToolCall{services.FakeToolCall("add_to_cart", map[string]interface{}{"product_id": headphones.ID, "quantity": 2})},
+	})
+	cartService := services.NewShoppingCartService(db)
+	service := services.NewChatServiceWithProvider(db, fake, services.NewProductService(db), cartService)
+	ctx := context.Background()
+
+	user := f.User()
+	_, err := service.GetChatSession(ctx, "cs_resume", &user.ID)
+	require.NoError(t, err)
+	_, err = service.ProcessMessage(ctx, "cs_resume", &user.ID, "Show me wireless headphones")
+	require.NoError(t, err)
+	_, err = service.ProcessMessage(ctx, "cs_resume", &user.ID, "Please add the headphones")
+	require.NoError(t, err)
+
+	// The cart was emptied while the shopper was away
+	require.NoError(t, cartService.ClearCart("cs_resume", &user.ID))
+
+	resumed, err := service.ResumeUserSession(ctx, user.ID, "cs_resume", time.Now().Add(2*time.Hour))
+	require.NoError(t, err)
+	assert.Contains(t, resumed.Greeting, "Last time you were looking at electronics.")
+	assert.Contains(t, resumed.Greeting, "I've put the Wireless Headphones from your last visit back in your cart.")
+	assert.Equal(t, resumed.Greeting, resumed.Messages[len(resumed.Messages)-1].Content)
+	require.NotNil(t, resumed.Cart)
+	assert.Equal(t, 2, resumed.Cart.ItemCount)
+	require.NotNil(t, resumed.Context)
+	assert.Contains(t, resumed.Context.Summary, "Please add the headphones")
+	assert.Equal(t, []string{"Wireless Headphones"}, resumed.Context.RecentProducts)
+
+	// Later turns recall the earlier conversation
+	_, err = service.ProcessMessage(ctx, "cs_resume", &user.ID, "What else goes with them?")
+	require.NoError(t, err)
+	req, err := fake.LastRequest()
+	require.NoError(t, err)
+	assert.Contains(t, req.Messages[0].Content, "earlier-conversation")
+	assert.Contains(t, req.Messages[0].Content, "Show me wireless headphones")
+
+	again, err := service.ResumeUserSession(ctx, user.ID, "cs_resume", time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	assert.Empty(t, again.Greeting, "conversations still underway aren't greeted again")
+
+	// A cart that was checked out isn't put back
+	require.NoError(t, cartService.ClearCart("cs_resume", &user.ID))
+	f.Order(user, []factories.OrderLine{{Product: headphones, Quantity: 2}})
+	later, err := service.ResumeUserSession(ctx, user.ID, "cs_resume", time.Now().Add(3*time.Hour))
+	require.NoError(t, err)
+	assert.NotContains(t, later.Greeting, "back in your cart")
+	assert.Equal(t, 0, later.Cart.ItemCount)
+}
@@ -0,0 +1,109 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"chat-ecommerce-backend/tests/testutil/factories"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChatService_ToolDefinitions(t *testing.T) {
+	fake := services.NewFakeLLM("Hello!")
+	service, _, _ := setupFakeLLMChat(t, fake)
+
+	_, err := service.ProcessMessage(context.Background(), "tools-session", nil, "hi")
+	require.NoError(t, err)
+	req, err := fake.LastRequest()
+	require.NoError(t, err)
+
+	var names []string
+	for _, tool := range req.Tools {
+		names = append(names, tool.Name)
+		assert.Equal(t, "object", tool.Parameters["type"])
+		assert.Equal(t, false, tool.Parameters["additionalProperties"])
+	}
+	assert.ElementsMatch(t, []string{"add_to_cart", "remove_from_cart", "search_products", "set_gift_options", "share_cart", "checkout"}, names)
+	assert.NotContains(t, req.Messages[0].Content, `{"type": "add_to_cart"`, "actions aren't asked for as text anymore")
+}
+
+func TestChatService_ToolCallValidation(t *testing.T) {
+	fake := services.NewFakeLLM()
+	service, db, product := setupFakeLLMChat(t, fake)
+	fake.Enqueue(services.FakeLLMResponse{
+		ToolCalls: []services.LLMToolCall{
+			services.FakeToolCall("add_to_cart", map[string]interface{}{"product_id": "headphones", "quantity": 1}),
+			services.FakeToolCall("add_to_cart", map[string]interface{}{"product_id": product.ID, "quantity": 0}),
+			services.FakeToolCall("add_to_cart", map[string]interface{}{"product_id": product.ID, "quantity": 1, "discount": 50}),
+			services.FakeToolCall("apply_discount", map[string]interface{}{"percent": 50}),
+			{Name: "remove_from_cart", Arguments: `{"product_id": `},
+		},
+	})
+
+	response, err := service.ProcessMessage(context.Background(), "invalid-tools", nil, "add the headphones")
+	require.NoError(t, err)
+	assert.Empty(t, response.Actions)
+	assert.Equal(t, "Sorry, I couldn't do that. Could you try asking another way?", response.Message)
+
+	cart, err := services.NewShoppingCartService(db).GetCart("invalid-tools", nil)
+	require.NoError(t, err)
+	assert.Zero(t, cart.ItemCount)
+
+	// A valid call without a reply is confirmed for the customer
+	fake.Enqueue(services.FakeLLMResponse{
+		ToolCalls: []services.LLMToolCall{services.FakeToolCall("add_to_cart", map[string]interface{}{"product_id": product.ID, "quantity": 3})},
+	})
+	response, err = service.ProcessMessage(context.Background(), "invalid-tools", nil, "add three headphones")
+	require.NoError(t, err)
+	require.Len(t, response.Actions, 1)
+	assert.Equal(t, float64(3), response.Actions[0].Payload["quantity"])
+	assert.Equal(t, "I've added that to your cart.", response.Message)
+}
+
+func TestChatService_SearchAndCheckoutTools(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	boots := f.StockedProduct(10, func(p *models.Product) {
+		p.Name = "Waterproof Hiking Boots"
+	})
+
+	fake := services.NewFakeLLM().Enqueue(
+		services.FakeLLMResponse{
+			Content:   "Let me look for boots.",
+			ToolCalls: []services.LLMToolCall{services.FakeToolCall("search_products", map[string]string{"query": "hiking boots"})},
+		},
+		services.FakeLLMResponse{
+			ToolCalls: []services.LLMToolCall{services.FakeToolCall("checkout", map[string]interface{}{})},
+		},
+		services.FakeLLMResponse{
+			ToolCalls: []services.LLMToolCall{services.FakeToolCall("checkout", map[string]interface{}{})},
+		},
+	)
+	cartService := services.NewShoppingCartService(db)
+	service := services.NewChatServiceWithProvider(db, fake, services.NewProductService(db), cartService)
+	ctx := context.Background()
+
+	response, err := service.ProcessMessage(ctx, "tool-search", nil, "I need something for the mountains")
+	require.NoError(t, err)
+	require.Len(t, response.Actions, 1)
+	assert.Equal(t, []string{boots.ID.String()}, response.Actions[0].Payload["product_ids"])
+	require.Len(t, response.Suggestions, 1, "search results are shown as the suggestions")
+	assert.Equal(t, boots.ID, response.Suggestions[0].Product.ID)
+
+	// Checkout needs something in the cart
+	response, err = service.ProcessMessage(ctx, "tool-search", nil, "checkout please")
+	require.NoError(t, err)
+	assert.Empty(t, response.Actions)
+
+	require.NoError(t, cartService.AddToCart("tool-search", nil, services.AddToCartRequest{ProductID: boots.ID, Quantity: 2}))
+	response, err = service.ProcessMessage(ctx, "tool-search", nil, "checkout please")
+	require.NoError(t, err)
+	require.Len(t, response.Actions, 1)
+	assert.Equal(t, "/checkout", response.Actions[0].Payload["path"])
+	assert.Equal(t, 2, response.Actions[0].Payload["item_count"])
+	assert.Equal(t, "Taking you to checkout now.", response.Message)
+}
@@ -0,0 +1,59 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"chat-ecommerce-backend/tests/testutil/factories"
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrderService_LastUnitConflict(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	orders := services.NewOrderService(db)
+	ctx := context.Background()
+
+	category := f.Category()
+	inCategory := func(p *models.Product) { p.CategoryID = category.ID }
+	lastOne := f.StockedProduct(1, inCategory)
+	alternative := f.StockedProduct(5, inCategory)
+	f.StockedProduct(0, inCategory) // sold out, never suggested
+
+	order := func(sessionID string) (*services.Order, error) {
+		return orders.CreateOrder(ctx, &services.CreateOrderRequest{
+			UserID:          f.User().ID,
+			SessionID:       sessionID,
+			Items:           []services.OrderItemRequest{{ProductID: lastOne.ID, Quantity: 1}},
+			ShippingAddress: map[string]interface{}{"country": "US"},
+			BillingAddress:  map[string]interface{}{"country": "US"},
+			PaymentMethod:   "card",
+		})
+	}
+
+	_, err := order("winner")
+	require.NoError(t, err)
+
+	_, err = order("loser")
+	var conflictErr *services.InventoryConflictError
+	require.ErrorAs(t, err, &conflictErr)
+	assert.Equal(t, lastOne.ID, conflictErr.ProductID)
+	assert.Equal(t, 1, conflictErr.Requested)
+	assert.Zero(t, conflictErr.Available)
+
+	conflict, err := orders.CheckoutConflict(ctx, nil, conflictErr, 4)
+	require.NoError(t, err)
+	assert.Equal(t, lastOne.Name, conflict.ProductName)
+	assert.Contains(t, conflict.Message, "sold out")
+
+	suggested := []uuid.UUID{}
+	for _, suggestion := range conflict.Alternatives {
+		suggested = append(suggested, suggestion.Product.ID)
+	}
+	assert.Equal(t, []uuid.UUID{alternative.ID}, suggested)
+}
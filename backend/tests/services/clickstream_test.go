@@ -0,0 +1,129 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"chat-ecommerce-backend/tests/testutil/factories"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClickstreamService_IngestRejectsInvalidEvents(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	clickstream := services.NewClickstreamService(db, services.ClickstreamConfig{MaxBatch: 3})
+	ctx := context.Background()
+	now := time.Now()
+
+	_, err := clickstream.Ingest(ctx, services.ClickstreamBatch{Events: []services.ClickstreamEvent{{Type: services.ClickstreamPageView, Path: "/"}}}, now)
+	assert.ErrorIs(t, err, services.ErrInvalidClickstream)
+	_, err = clickstream.Ingest(ctx, services.ClickstreamBatch{SessionID: "store-1", Events: make([]services.ClickstreamEvent, 4)}, now)
+	assert.ErrorIs(t, err, services.ErrInvalidClickstream)
+
+	product := f.Product()
+	stale := now.Add(-48 * time.Hour)
+	future := now.Add(time.Hour)
+	result, err := clickstream.Ingest(ctx, services.ClickstreamBatch{
+		SessionID: "store-1",
+		Events: []services.ClickstreamEvent{
+			{Type: services.ClickstreamPageView, Path: "/products", OccurredAt: &future},
+			{Type: services.ClickstreamSuggestionClick},
+			{Type: services.ClickstreamSuggestionImpression, ProductID: &product.ID, OccurredAt: &stale},
+		},
+	}, now)
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Accepted)
+	assert.Equal(t, 2, result.Rejected)
+	assert.Len(t, result.Errors, 2)
+	assert.Empty(t, result.ChatSessionID, "anonymous sessions aren't stitched until a chat request links them")
+
+	var event models.StorefrontEvent
+	require.NoError(t, db.Where("session_id = ?", "store-1").First(&event).Error)
+	assert.WithinDuration(t, now, event.OccurredAt, time.Second, "future timestamps are clamped")
+}
+
+func TestClickstreamService_StitchesSessionsForExperiments(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	clickstream := services.NewClickstreamService(db, services.ClickstreamConfig{})
+	ctx := context.Background()
+	now := time.Now()
+
+	_, err := services.NewLLMSettingsService(db).UpsertSettings(ctx, "concise", services.LLMSettingsRequest{TrafficPercent: 100})
+	require.NoError(t, err)
+	product := f.Product()
+
+	// Events before the chat is known are stitched once it is
+	_, err = clickstream.Ingest(ctx, services.ClickstreamBatch{
+		SessionID: "store-2",
+		Events:    []services.ClickstreamEvent{{Type: services.ClickstreamSuggestionImpression, ProductID: &product.ID, Placement: "chat"}},
+	}, now)
+	require.NoError(t, err)
+	require.NoError(t, clickstream.Link(ctx, "store-2", "chat-2", nil))
+
+	result, err := clickstream.Ingest(ctx, services.ClickstreamBatch{
+		SessionID: "store-2",
+		Events: []services.ClickstreamEvent{
+			{Type: services.ClickstreamSuggestionClick, ProductID: &product.ID, Placement: "chat"},
+			{Type: services.ClickstreamPageView, ProductID: &product.ID, Path: "/products/" + product.ID.String()},
+		},
+	}, now)
+	require.NoError(t, err)
+	assert.Equal(t, "chat-2", result.ChatSessionID)
+	assert.Equal(t, "concise", result.Variant)
+
+	// A signed-in shopper's events go to their latest chat
+	user := f.User()
+	require.NoError(t, db.Create(&models.ChatSession{ID: uuid.New(), SessionID: "chat-3", UserID: &user.ID, Status: "active", LastActivity: now}).Error)
+	result, err = clickstream.Ingest(ctx, services.ClickstreamBatch{
+		SessionID: "store-3",
+		UserID:    &user.ID,
+		Events:    []services.ClickstreamEvent{{Type: services.ClickstreamSuggestionImpression, ProductID: &product.ID}},
+	}, now)
+	require.NoError(t, err)
+	assert.Equal(t, "chat-3", result.ChatSessionID)
+
+	report, err := clickstream.ExperimentReport(ctx, now.Add(-time.Hour), now.Add(time.Hour))
+	require.NoError(t, err)
+	require.Len(t, report, 1)
+	assert.Equal(t, "concise", report[0].Variant)
+	assert.Equal(t, int64(2), report[0].Sessions)
+	assert.Equal(t, int64(1), report[0].PageViews)
+	assert.Equal(t, int64(2), report[0].Impressions)
+	assert.Equal(t, int64(1), report[0].Clicks)
+	assert.Equal(t, 0.5, report[0].ClickThroughRate)
+}
+
+func TestChatService_RecommendsProductsRelatedToViews(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	ctx := context.Background()
+	clickstream := services.NewClickstreamService(db, services.ClickstreamConfig{})
+	productService := services.NewProductService(db)
+	chat := services.NewChatServiceWithProvider(db, services.NewFakeLLM(), productService, services.NewShoppingCartService(db)).
+		WithClickstream(clickstream)
+
+	category := f.Category()
+	viewed := f.Product(func(p *models.Product) { p.CategoryID = category.ID })
+	related := f.Product(func(p *models.Product) { p.CategoryID = category.ID })
+	f.Product()
+
+	require.NoError(t, clickstream.Link(ctx, "store-4", "chat-4", nil))
+	_, err := clickstream.Ingest(ctx, services.ClickstreamBatch{
+		SessionID: "store-4",
+		Events:    []services.ClickstreamEvent{{Type: services.ClickstreamPageView, ProductID: &viewed.ID}},
+	}, time.Now())
+	require.NoError(t, err)
+
+	suggestions, err := chat.GetProductRecommendations(ctx, "chat-4", nil, 1)
+	require.NoError(t, err)
+	require.Len(t, suggestions, 1)
+	assert.Equal(t, related.ID, suggestions[0].Product.ID)
+	assert.Equal(t, "Related to a product you viewed", suggestions[0].Reason)
+}
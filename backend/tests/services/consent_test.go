@@ -0,0 +1,96 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"chat-ecommerce-backend/tests/testutil/factories"
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConsentService_RecordsChanges(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	svc := services.NewConsentService(db)
+	ctx := context.Background()
+
+	user := f.User()
+	consents, err := svc.GetConsents(ctx, user.ID)
+	require.NoError(t, err)
+	require.Len(t, consents, 3)
+	for _, consent := range consents {
+		assert.False(t, consent.Granted, "nothing is granted until the user opts in")
+	}
+
+	_, err = svc.UpdateConsents(ctx, user.ID, services.UpdateConsentRequest{Consents: map[string]bool{"newsletter": true}}, "", "")
+	assert.ErrorIs(t, err, services.ErrUnknownConsentPurpose)
+	_, err = svc.UpdateConsents(ctx, user.ID, services.UpdateConsentRequest{Consents: map[string]bool{services.ConsentMarketingEmail: true}, Source: "somewhere"}, "", "")
+	assert.ErrorIs(t, err, services.ErrUnknownConsentSource)
+
+	consents, err = svc.UpdateConsents(ctx, user.ID, services.UpdateConsentRequest{
+		Consents: map[string]bool{services.ConsentMarketingEmail: true, services.ConsentAnalyticsCookies: false},
+		Source:   "cookie_banner",
+	}, "10.0.0.1", "test")
+	require.NoError(t, err)
+	assert.True(t, consents[0].Granted)
+	assert.Equal(t, "cookie_banner", consents[0].Source)
+	assert.NotNil(t, consents[2].UpdatedAt, "an explicit refusal is recorded too")
+
+	// Repeating a choice doesn't add history
+	_, err = svc.UpdateConsents(ctx, user.ID, services.UpdateConsentRequest{Consents: map[string]bool{services.ConsentMarketingEmail: true}}, "", "")
+	require.NoError(t, err)
+	_, err = svc.UpdateConsents(ctx, user.ID, services.UpdateConsentRequest{Consents: map[string]bool{services.ConsentMarketingEmail: false}, Source: "unsubscribe_link"}, "", "")
+	require.NoError(t, err)
+
+	history, err := svc.History(ctx, user.ID)
+	require.NoError(t, err)
+	require.Len(t, history, 3)
+	assert.False(t, history[0].Granted)
+	assert.Equal(t, "unsubscribe_link", history[0].Source)
+}
+
+func TestMessageDispatcher_EnforcesMarketingConsent(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	consents := services.NewConsentService(db)
+	dispatcher := services.NewMessageDispatcher(db)
+	ctx := context.Background()
+
+	optedIn := f.User(func(u *models.User) { u.Phone = "+15550100" })
+	notAsked := f.User(func(u *models.User) { u.Phone = "+15550101" })
+	_, err := consents.UpdateConsents(ctx, optedIn.ID, services.UpdateConsentRequest{
+		Consents: map[string]bool{services.ConsentMarketingEmail: true},
+	}, "", "")
+	require.NoError(t, err)
+
+	email := services.OutboundMessageRequest{Channel: services.MessageChannelEmail, Category: services.MessageCategoryMarketing, Subject: "Sale", Body: "20% off"}
+	result, err := dispatcher.DispatchToUsers(ctx, []uuid.UUID{optedIn.ID, notAsked.ID}, email)
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Queued)
+	assert.Equal(t, 1, result.Suppressed)
+
+	// Consent is per channel
+	sms := services.OutboundMessageRequest{UserID: optedIn.ID, Channel: services.MessageChannelSMS, Category: services.MessageCategoryMarketing, Body: "20% off"}
+	message, err := dispatcher.Dispatch(ctx, sms)
+	assert.ErrorIs(t, err, services.ErrNoMarketingConsent)
+	require.NotNil(t, message)
+	assert.Equal(t, services.OutboundStatusSuppressed, message.Status)
+
+	// Transactional messages don't need marketing consent
+	receipt := services.OutboundMessageRequest{UserID: notAsked.ID, Channel: services.MessageChannelEmail, Category: services.MessageCategoryTransactional, Subject: "Your order", Body: "Thanks!"}
+	message, err = dispatcher.Dispatch(ctx, receipt)
+	require.NoError(t, err)
+	assert.Equal(t, services.OutboundStatusPending, message.Status)
+	assert.Equal(t, notAsked.Email, message.Recipient)
+
+	var pending int64
+	require.NoError(t, db.Model(&models.OutboundMessage{}).
+		Where("user_id = ? AND category = ? AND status = ?", notAsked.ID, services.MessageCategoryMarketing, services.OutboundStatusPending).
+		Count(&pending).Error)
+	assert.Zero(t, pending, "users without consent never get marketing queued")
+}
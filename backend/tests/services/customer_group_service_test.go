@@ -0,0 +1,84 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"chat-ecommerce-backend/tests/testutil/factories"
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCustomerGroupService_PriceProducts(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	service := services.NewCustomerGroupService(db)
+	ctx := context.Background()
+
+	_, err := service.UpsertGroup(ctx, "retail", services.CustomerGroupRequest{Name: "Retail", IsDefault: true})
+	require.NoError(t, err)
+	_, err = service.UpsertGroup(ctx, "wholesale", services.CustomerGroupRequest{Name: "Wholesale", AdjustmentPercent: -20})
+	require.NoError(t, err)
+
+	desk := f.Product(func(p *models.Product) { p.Price = 200 })
+	lamp := f.Product(func(p *models.Product) { p.Price = 50 })
+	_, err = service.SetGroupPrices(ctx, "wholesale", []services.GroupPriceRequest{{ProductID: lamp.ID, Price: 30}})
+	require.NoError(t, err)
+
+	trader := f.User()
+	require.NoError(t, service.AssignUser(ctx, "wholesale", trader.ID))
+
+	products := []models.Product{*desk, *lamp}
+	require.NoError(t, service.PriceProducts(ctx, &trader.ID, products))
+	assert.InDelta(t, 160, products[0].Price, 0.001, "percentage adjustment")
+	assert.InDelta(t, 30, products[1].Price, 0.001, "price list wins over the adjustment")
+	require.NotNil(t, products[0].ListPrice)
+	assert.InDelta(t, 200, *products[0].ListPrice, 0.001)
+
+	// Guests get the default group, which leaves prices alone
+	products = []models.Product{*desk}
+	require.NoError(t, service.PriceProducts(ctx, nil, products))
+	assert.InDelta(t, 200, products[0].Price, 0.001)
+	assert.Nil(t, products[0].ListPrice)
+
+	require.NoError(t, service.RemoveUser(ctx, "wholesale", trader.ID))
+	group, err := service.ResolveGroup(ctx, &trader.ID)
+	require.NoError(t, err)
+	require.NotNil(t, group)
+	assert.Equal(t, "retail", group.Slug)
+}
+
+func TestCustomerGroupService_CartUsesGroupPrice(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	groups := services.NewCustomerGroupService(db)
+	carts := services.NewShoppingCartService(db)
+	ctx := context.Background()
+
+	_, err := groups.UpsertGroup(ctx, "vip", services.CustomerGroupRequest{Name: "VIP", AdjustmentPercent: -10})
+	require.NoError(t, err)
+	vip := f.User()
+	require.NoError(t, groups.AssignUser(ctx, "vip", vip.ID))
+
+	product := f.StockedProduct(10, func(p *models.Product) { p.Price = 80 })
+	require.NoError(t, carts.AddToCart("vip-session", &vip.ID, services.AddToCartRequest{ProductID: product.ID, Quantity: 2}))
+
+	cart, err := carts.GetCart("vip-session", &vip.ID)
+	require.NoError(t, err)
+	require.Len(t, cart.Items, 1)
+	assert.InDelta(t, 72, cart.Items[0].UnitPrice, 0.001)
+	assert.InDelta(t, 144, cart.Subtotal, 0.001)
+
+	// Deleting the group returns its members to list prices
+	require.NoError(t, groups.DeleteGroup(ctx, "vip"))
+	price, err := groups.UnitPrice(ctx, &vip.ID, product)
+	require.NoError(t, err)
+	assert.InDelta(t, 80, price, 0.001)
+
+	err = groups.AssignUser(ctx, "vip", uuid.New())
+	assert.ErrorIs(t, err, services.ErrCustomerGroupNotFound)
+}
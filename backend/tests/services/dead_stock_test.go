@@ -0,0 +1,70 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"chat-ecommerce-backend/tests/testutil/factories"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInventoryService_DeadStockReport(t *testing.T) {
+	// SQLite returns MAX() of a timestamp as text
+	testutil.RequirePostgres(t)
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	ctx := context.Background()
+	user := f.User()
+	now := time.Now()
+	longAgo := func(p *models.Product) { p.CreatedAt = now.AddDate(-1, 0, 0) }
+
+	selling := f.StockedProduct(40, longAgo)
+	f.Order(user, []factories.OrderLine{{Product: selling}}, func(o *models.Order) { o.CreatedAt = now.AddDate(0, 0, -5) })
+
+	stale := f.StockedProduct(40, longAgo)
+	f.Order(user, []factories.OrderLine{{Product: stale}}, func(o *models.Order) { o.CreatedAt = now.AddDate(0, 0, -200) })
+	// A recent cancelled order doesn't count as a sale
+	f.Order(user, []factories.OrderLine{{Product: stale}}, func(o *models.Order) {
+		o.Status = "cancelled"
+		o.CreatedAt = now.AddDate(0, 0, -2)
+	})
+
+	neverSold := f.StockedProduct(3, longAgo)
+	f.StockedProduct(3) // new products aren't dead yet
+
+	inventory := services.NewInventoryService(db)
+	report, err := inventory.GetDeadStockReport(ctx, services.DeadStockFilter{Days: 90}, now)
+	require.NoError(t, err)
+	require.Len(t, report.Items, 2)
+	assert.Equal(t, 43, report.Units)
+
+	first := report.Items[0]
+	assert.Equal(t, stale.ID, first.ProductID, "the most valuable dead stock is listed first")
+	require.NotNil(t, first.LastSoldAt)
+	assert.Equal(t, 200, first.DaysWithoutSale)
+	assert.Equal(t, "180-364", first.AgeBucket)
+	assert.Equal(t, services.DeadStockActionDiscount, first.SuggestedAction)
+	assert.Equal(t, 20, first.SuggestedDiscount, "two periods without a sale")
+
+	second := report.Items[1]
+	assert.Equal(t, neverSold.ID, second.ProductID)
+	assert.Nil(t, second.LastSoldAt)
+	assert.Equal(t, services.DeadStockActionBundle, second.SuggestedAction)
+
+	report, err = inventory.GetDeadStockReport(ctx, services.DeadStockFilter{Days: 90, MinStock: 10}, now)
+	require.NoError(t, err)
+	assert.Len(t, report.Items, 1, "products below the minimum stock are left out")
+
+	csvData, err := inventory.ExportDeadStockCSV(ctx, services.DeadStockFilter{}, now)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(csvData)), "\n")
+	require.Len(t, lines, 3)
+	assert.True(t, strings.HasPrefix(lines[0], "product_id,sku,name"))
+	assert.Contains(t, lines[1], stale.SKU)
+}
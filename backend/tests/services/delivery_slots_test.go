@@ -0,0 +1,100 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"chat-ecommerce-backend/tests/testutil/factories"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeliveryScheduleService_Slots(t *testing.T) {
+	t.Setenv("DELIVERY_CARRIERS", "standard:3:mon-fri,express:1:mon-sat")
+	db := testutil.NewTestDB(t)
+	ctx := context.Background()
+	hours := services.NewBusinessHoursService(db, time.UTC)
+	deliveries := services.NewDeliveryScheduleService(hours, services.DeliveryConfigFromEnv())
+
+	var week []services.StoreHoursRequest
+	for weekday := time.Monday; weekday <= time.Friday; weekday++ {
+		week = append(week, services.StoreHoursRequest{Weekday: int(weekday), Opens: "09:00", Closes: "17:00", ShippingCutoff: "14:00"})
+	}
+	_, err := hours.SetHours(ctx, "", week)
+	require.NoError(t, err)
+
+	friday := func(hour int) time.Time { return time.Date(2026, 10, 16, hour, 0, 0, 0, time.UTC) }
+	earliest := func(slots []services.DeliverySlot, carrier string) string {
+		for _, slot := range slots {
+			if slot.Carrier == carrier {
+				return slot.Date
+			}
+		}
+		return ""
+	}
+
+	slots, err := deliveries.Slots(ctx, friday(10))
+	require.NoError(t, err)
+	assert.Equal(t, "2026-10-17", earliest(slots, "express"), "express delivers on Saturdays")
+	assert.Equal(t, "2026-10-21", earliest(slots, "standard"))
+
+	// After the cutoff nothing ships until Monday
+	slots, err = deliveries.Slots(ctx, friday(15))
+	require.NoError(t, err)
+	assert.Equal(t, "2026-10-20", earliest(slots, "express"))
+	assert.Equal(t, "2026-10-22", earliest(slots, "standard"))
+
+	slot, err := deliveries.Slot(ctx, "2026-10-21", "express", friday(10))
+	require.NoError(t, err)
+	assert.Equal(t, "2026-10-20", slot.ShipBy, "later dates ship just in time")
+	slot, err = deliveries.Slot(ctx, "2026-10-21", "", friday(10))
+	require.NoError(t, err)
+	assert.Equal(t, "standard", slot.Carrier)
+	assert.Equal(t, "2026-10-16", slot.ShipBy)
+
+	_, err = deliveries.Slot(ctx, "2026-10-18", "", friday(10))
+	assert.ErrorIs(t, err, services.ErrDeliveryDateUnavailable, "nobody delivers on Sundays")
+	_, err = deliveries.Slot(ctx, "2026-10-16", "", friday(10))
+	assert.ErrorIs(t, err, services.ErrDeliveryDateUnavailable)
+}
+
+func TestOrderService_CreateOrder_DeliveryDate(t *testing.T) {
+	t.Setenv("STORE_TIMEZONE", "UTC")
+	t.Setenv("DELIVERY_CARRIERS", "standard:2:mon-sun")
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	ctx := context.Background()
+	product := f.StockedProduct(5)
+	orders := services.NewOrderService(db)
+
+	deliveries := services.NewDeliveryScheduleService(services.NewBusinessHoursService(db, time.UTC), services.DeliveryConfigFromEnv())
+	slots, err := deliveries.Slots(ctx, time.Now())
+	require.NoError(t, err)
+	require.NotEmpty(t, slots)
+	chosen := slots[len(slots)-1]
+
+	req := &services.CreateOrderRequest{
+		SessionID:       "delivery-session",
+		Items:           []services.OrderItemRequest{{ProductID: product.ID, Quantity: 1}},
+		ShippingAddress: map[string]interface{}{"country": "US"},
+		BillingAddress:  map[string]interface{}{"country": "US"},
+		DeliveryDate:    time.Now().UTC().Format("2006-01-02"),
+	}
+	_, err = orders.CreateOrder(ctx, req)
+	assert.ErrorIs(t, err, services.ErrDeliveryDateUnavailable, "orders can't arrive the day they're placed")
+
+	req.DeliveryDate = chosen.Date
+	order, err := orders.CreateOrder(ctx, req)
+	require.NoError(t, err)
+	require.NotNil(t, order.DeliveryDate)
+	assert.Equal(t, chosen.Date, order.DeliveryDate.Format("2006-01-02"))
+	assert.Equal(t, "standard", order.DeliveryCarrier)
+	require.NotNil(t, order.ShipBy)
+	assert.Equal(t, chosen.ShipBy, order.ShipBy.Format("2006-01-02"))
+
+	confirmation := services.NewOrderConfirmation(order)
+	assert.Contains(t, confirmation.Message, order.DeliveryDate.Format("Monday, January 2"))
+}
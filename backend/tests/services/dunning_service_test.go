@@ -0,0 +1,130 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"chat-ecommerce-backend/tests/testutil/factories"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeRetrier struct {
+	status string
+	calls  int
+}
+
+func (r *fakeRetrier) RetryPayment(provider, paymentIntentID string) (*services.PaymentStatus, error) {
+	r.calls++
+	return &services.PaymentStatus{PaymentIntentID: paymentIntentID, Status: r.status}, nil
+}
+
+type dunningNotifier struct {
+	notices []*services.DunningNotice
+}
+
+func (n *dunningNotifier) NotifySession(sessionID, messageType string, data interface{}) {
+	if notice, ok := data.(*services.DunningNotice); ok && messageType == services.PaymentRetryMessage {
+		n.notices = append(n.notices, notice)
+	}
+}
+
+func dunningConfig() services.DunningConfig {
+	return services.DunningConfig{
+		MaxAttempts:   3,
+		RetryInterval: time.Hour,
+		SweepInterval: time.Minute,
+		PayNowBaseURL: "https://shop.test/pay",
+	}
+}
+
+func placeDunningOrder(t *testing.T, f *factories.Factory, orders *services.OrderService, product *models.Product) *services.Order {
+	t.Helper()
+	order, err := orders.CreateOrder(context.Background(), &services.CreateOrderRequest{
+		UserID:          f.User().ID,
+		SessionID:       "dunning",
+		Items:           []services.OrderItemRequest{{ProductID: product.ID, Quantity: 2}},
+		ShippingAddress: map[string]interface{}{"country": "US"},
+		BillingAddress:  map[string]interface{}{"country": "US"},
+		PaymentMethod:   "card",
+	})
+	require.NoError(t, err)
+	return order
+}
+
+func TestDunningService_CancelsAfterMaxAttempts(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	orders := services.NewOrderService(db)
+	retrier := &fakeRetrier{status: "requires_payment_method"}
+	notifier := &dunningNotifier{}
+	dunning := services.NewDunningService(db, retrier, notifier, dunningConfig())
+	ctx := context.Background()
+
+	product := f.StockedProduct(5)
+	order := placeDunningOrder(t, f, orders, product)
+
+	retry, err := dunning.RecordFailure(ctx, order.ID, "pi_123", "card declined")
+	require.NoError(t, err)
+	assert.Equal(t, services.PaymentRetryRetrying, retry.Status)
+	require.NotNil(t, retry.NextRetryAt)
+	require.Len(t, notifier.notices, 1)
+	assert.Equal(t, "https://shop.test/pay/"+order.OrderNumber, notifier.notices[0].PayNowURL)
+
+	// Nothing is due until the backoff has passed
+	result, err := dunning.RunDueRetries(ctx, time.Now())
+	require.NoError(t, err)
+	assert.Zero(t, result.Retried)
+
+	result, err = dunning.RunDueRetries(ctx, time.Now().Add(2*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Retried)
+	assert.Zero(t, result.Cancelled)
+
+	result, err = dunning.RunDueRetries(ctx, time.Now().Add(10*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Cancelled)
+	assert.Equal(t, 2, retrier.calls)
+
+	cancelled, err := orders.GetOrderByID(ctx, order.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "cancelled", cancelled.Status)
+	assert.Equal(t, "failed", cancelled.PaymentStatus)
+
+	var inventory models.Inventory
+	require.NoError(t, db.Where("product_id = ?", product.ID).First(&inventory).Error)
+	assert.Equal(t, 5, inventory.QuantityAvailable, "stock is released when the order is cancelled")
+
+	last := notifier.notices[len(notifier.notices)-1]
+	assert.Equal(t, services.PaymentRetryCancelled, last.Status)
+	assert.Empty(t, last.PayNowURL)
+}
+
+func TestDunningService_RecoversOnRetry(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	orders := services.NewOrderService(db)
+	retrier := &fakeRetrier{status: "succeeded"}
+	dunning := services.NewDunningService(db, retrier, nil, dunningConfig())
+	ctx := context.Background()
+
+	order := placeDunningOrder(t, f, orders, f.StockedProduct(5))
+	_, err := dunning.RecordFailure(ctx, order.ID, "pi_456", "insufficient funds")
+	require.NoError(t, err)
+
+	result, err := dunning.RunDueRetries(ctx, time.Now().Add(2*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Recovered)
+
+	paid, err := orders.GetOrderByID(ctx, order.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "paid", paid.PaymentStatus)
+
+	var retry models.PaymentRetry
+	require.NoError(t, db.Where("order_id = ?", order.ID).First(&retry).Error)
+	assert.Equal(t, services.PaymentRetryRecovered, retry.Status)
+}
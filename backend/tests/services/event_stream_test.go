@@ -0,0 +1,196 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"chat-ecommerce-backend/tests/testutil/factories"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// kafkaProxy is a fake Kafka REST Proxy recording what was produced
+type kafkaProxy struct {
+	mu           sync.Mutex
+	contentTypes []string
+	bodies       []map[string]json.RawMessage
+}
+
+func (p *kafkaProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var body map[string]json.RawMessage
+	json.NewDecoder(r.Body).Decode(&body)
+	p.contentTypes = append(p.contentTypes, r.Header.Get("Content-Type"))
+	p.bodies = append(p.bodies, body)
+	w.Write([]byte(`{"offsets":[{"partition":0,"offset":1}]}`))
+}
+
+type producedRecord struct {
+	Key   string                 `json:"key"`
+	Value map[string]interface{} `json:"value"`
+}
+
+func (p *kafkaProxy) records(t *testing.T) []producedRecord {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var all []producedRecord
+	for _, body := range p.bodies {
+		var records []producedRecord
+		require.NoError(t, json.Unmarshal(body["records"], &records))
+		all = append(all, records...)
+	}
+	return all
+}
+
+func TestEventStream_PublishesCartAndOrderEventsToKafka(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	ctx := context.Background()
+
+	proxy := &kafkaProxy{}
+	server := httptest.NewServer(proxy)
+	defer server.Close()
+	events := services.NewEventStream(services.EventStreamConfig{Sink: services.EventSinkKafka, URL: server.URL, Topic: "shop"})
+	carts := services.NewShoppingCartService(db).WithEventStream(events)
+	orders := services.NewOrderService(db).WithEventStream(events)
+
+	user := f.User()
+	product := f.StockedProduct(10, func(p *models.Product) { p.Price = 25 })
+	require.NoError(t, carts.AddToCart("stream-session", &user.ID, services.AddToCartRequest{ProductID: product.ID, Quantity: 2}))
+	order, err := orders.CreateOrder(ctx, &services.CreateOrderRequest{
+		UserID:          user.ID,
+		SessionID:       "stream-session",
+		Items:           []services.OrderItemRequest{{ProductID: product.ID, Quantity: 2}},
+		ShippingAddress: map[string]interface{}{"country": "US"},
+		BillingAddress:  map[string]interface{}{"country": "US"},
+		PaymentMethod:   "card",
+	})
+	require.NoError(t, err)
+
+	published, err := events.Flush(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, published)
+	assert.Equal(t, []string{"application/vnd.kafka.json.v2+json"}, proxy.contentTypes)
+
+	records := proxy.records(t)
+	require.Len(t, records, 2)
+	assert.Equal(t, "stream-session", records[0].Key)
+	assert.Equal(t, services.DomainEventCartAdd, records[0].Value["type"])
+	assert.Equal(t, product.ID.String(), records[0].Value["product_id"])
+	assert.Equal(t, float64(50), records[0].Value["amount"])
+	assert.Equal(t, float64(services.DomainEventSchemaVersion), records[0].Value["schema_version"])
+	assert.Equal(t, services.DomainEventOrderCreated, records[1].Value["type"])
+	assert.Equal(t, order.ID.String(), records[1].Value["order_id"])
+	assert.Equal(t, float64(2), records[1].Value["quantity"])
+	assert.Equal(t, order.TotalAmount, records[1].Value["amount"])
+
+	stats := events.Stats()
+	assert.True(t, stats.Enabled)
+	assert.Equal(t, int64(2), stats.Published)
+	assert.Zero(t, stats.Queued)
+}
+
+func TestEventStream_AvroToKafka(t *testing.T) {
+	proxy := &kafkaProxy{}
+	server := httptest.NewServer(proxy)
+	defer server.Close()
+	events := services.NewEventStream(services.EventStreamConfig{Sink: services.EventSinkKafka, Format: services.EventFormatAvro, URL: server.URL, Topic: "shop"})
+
+	events.Publish(services.DomainEvent{Type: services.DomainEventProductViewed, ProductID: "p-1"})
+	_, err := events.Flush(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"application/vnd.kafka.avro.v2+json"}, proxy.contentTypes)
+	var schema string
+	require.NoError(t, json.Unmarshal(proxy.bodies[0]["value_schema"], &schema))
+	assert.JSONEq(t, services.DomainEventAvroSchema, schema)
+	records := proxy.records(t)
+	require.Len(t, records, 1)
+	assert.Equal(t, records[0].Value["id"], records[0].Key, "events without a session are keyed by ID")
+	_, isMillis := records[0].Value["occurred_at"].(float64)
+	assert.True(t, isMillis, "Avro timestamps are milliseconds")
+}
+
+func TestEventStream_PublishesToKinesis(t *testing.T) {
+	var mu sync.Mutex
+	var target, authorization string
+	var request struct {
+		StreamName string
+		Records    []struct {
+			Data         []byte
+			PartitionKey string
+		}
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		target = r.Header.Get("X-Amz-Target")
+		authorization = r.Header.Get("Authorization")
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &request)
+		w.Write([]byte(`{"FailedRecordCount":0,"Records":[{"SequenceNumber":"1","ShardId":"shardId-0"}]}`))
+	}))
+	defer server.Close()
+
+	events := services.NewEventStream(services.EventStreamConfig{
+		Sink:      services.EventSinkKinesis,
+		URL:       server.URL,
+		Topic:     "commerce-events",
+		Region:    "eu-west-1",
+		AccessKey: "AKIDEXAMPLE",
+		SecretKey: "secret",
+	})
+	events.Publish(services.DomainEvent{Type: services.DomainEventSuggestionShown, SessionID: "chat-1", ProductID: "p-2"})
+	published, err := events.Flush(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, published)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, "Kinesis_20131202.PutRecords", target)
+	assert.True(t, strings.HasPrefix(authorization, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/"))
+	assert.Contains(t, authorization, "/eu-west-1/kinesis/aws4_request")
+	assert.Equal(t, "commerce-events", request.StreamName)
+	require.Len(t, request.Records, 1)
+	assert.Equal(t, "chat-1", request.Records[0].PartitionKey)
+	var event services.DomainEvent
+	require.NoError(t, json.Unmarshal(request.Records[0].Data, &event))
+	assert.Equal(t, services.DomainEventSuggestionShown, event.Type)
+	assert.Equal(t, "p-2", event.ProductID)
+}
+
+func TestEventStream_DropsWhenFullAndCountsFailures(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "broker unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	events := services.NewEventStream(services.EventStreamConfig{Sink: services.EventSinkKafka, URL: server.URL, Topic: "shop", BufferSize: 2})
+
+	for i := 0; i < 3; i++ {
+		events.Publish(services.DomainEvent{Type: services.DomainEventProductViewed})
+	}
+	_, err := events.Flush(context.Background())
+	assert.ErrorContains(t, err, "503")
+
+	stats := events.Stats()
+	assert.Equal(t, int64(1), stats.Dropped)
+	assert.Equal(t, int64(2), stats.Failed)
+	assert.Zero(t, stats.Published)
+
+	// Without a sink events are ignored
+	disabled := services.NewEventStream(services.EventStreamConfig{})
+	disabled.Publish(services.DomainEvent{Type: services.DomainEventProductViewed})
+	assert.False(t, disabled.Stats().Enabled)
+	var none *services.EventStream
+	none.Publish(services.DomainEvent{Type: services.DomainEventProductViewed})
+}
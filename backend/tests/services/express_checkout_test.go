@@ -0,0 +1,167 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"chat-ecommerce-backend/tests/testutil/factories"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func walletContact() services.WalletContact {
+	return services.WalletContact{
+		GivenName:          "Ada",
+		FamilyName:         "Lovelace",
+		EmailAddress:       "ada@example.com",
+		AddressLines:       []string{"1 Infinite Loop"},
+		Locality:           "Cupertino",
+		AdministrativeArea: "CA",
+		PostalCode:         "95014",
+		CountryCode:        "us",
+	}
+}
+
+func TestExpressCheckout_WalletFastPath(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	carts := services.NewShoppingCartService(db)
+	payments := services.NewPaymentServiceWithRegistry(services.NewPaymentRegistry(services.PaymentProviderMock, nil, services.NewMockPaymentProvider()))
+	express := services.NewExpressCheckoutService(db, carts, services.NewOrderService(db), payments, services.ApplePayConfig{})
+	ctx := context.Background()
+
+	user := f.User()
+	product := f.StockedProduct(5)
+	require.NoError(t, carts.AddToCart("express", &user.ID, services.AddToCartRequest{ProductID: product.ID, Quantity: 2}))
+
+	result, err := express.Checkout(ctx, "express", user.ID, &services.ExpressCheckoutRequest{
+		Wallet:          services.WalletApplePay,
+		PaymentToken:    "tok_applepay",
+		ShippingContact: walletContact(),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "paid", result.Order.PaymentStatus)
+	assert.Equal(t, services.PaymentProviderMock, result.Order.PaymentProvider)
+
+	var shipping map[string]interface{}
+	require.NoError(t, json.Unmarshal(result.Order.ShippingAddress, &shipping))
+	assert.Equal(t, "Ada Lovelace", shipping["name"])
+	assert.Equal(t, "US", shipping["country"])
+
+	cart, err := carts.GetCart("express", &user.ID)
+	require.NoError(t, err)
+	assert.Empty(t, cart.Items, "the cart is emptied once the wallet is charged")
+
+	_, err = express.Checkout(ctx, "express", user.ID, &services.ExpressCheckoutRequest{
+		Wallet:          services.WalletApplePay,
+		PaymentToken:    "tok_applepay",
+		ShippingContact: walletContact(),
+	})
+	assert.ErrorIs(t, err, services.ErrEmptyCart)
+}
+
+func TestExpressCheckout_DeclinedWalletReleasesStock(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	carts := services.NewShoppingCartService(db)
+	payments := services.NewPaymentServiceWithRegistry(services.NewPaymentRegistry(services.PaymentProviderMock, nil, services.NewMockPaymentProvider()))
+	express := services.NewExpressCheckoutService(db, carts, services.NewOrderService(db), payments, services.ApplePayConfig{})
+	ctx := context.Background()
+
+	user := f.User()
+	product := f.StockedProduct(5)
+	require.NoError(t, carts.AddToCart("declined", &user.ID, services.AddToCartRequest{ProductID: product.ID, Quantity: 2}))
+
+	incomplete := walletContact()
+	incomplete.PostalCode = ""
+	_, err := express.Checkout(ctx, "declined", user.ID, &services.ExpressCheckoutRequest{
+		Wallet:          services.WalletGooglePay,
+		PaymentToken:    "tok_googlepay",
+		ShippingContact: incomplete,
+	})
+	assert.ErrorContains(t, err, "postalCode")
+
+	_, err = express.Checkout(ctx, "declined", user.ID, &services.ExpressCheckoutRequest{
+		Wallet:          services.WalletGooglePay,
+		PaymentToken:    services.MockDeclinedWalletToken,
+		ShippingContact: walletContact(),
+	})
+	require.ErrorIs(t, err, services.ErrWalletPaymentDeclined)
+
+	var order models.Order
+	require.NoError(t, db.Where("session_id = ?", "declined").First(&order).Error)
+	assert.Equal(t, "cancelled", order.Status)
+	assert.Equal(t, "failed", order.PaymentStatus)
+
+	var inventory models.Inventory
+	require.NoError(t, db.Where("product_id = ?", product.ID).First(&inventory).Error)
+	assert.Equal(t, 5, inventory.QuantityAvailable)
+
+	cart, err := carts.GetCart("declined", &user.ID)
+	require.NoError(t, err)
+	assert.Len(t, cart.Items, 1, "the cart is kept so the shopper can try another way to pay")
+}
+
+func TestExpressCheckout_FlashSaleWaitingRoom(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	ctx := context.Background()
+	sales := services.NewFlashSaleService(db, flashSaleConfig())
+	carts := services.NewShoppingCartService(db)
+	payments := services.NewPaymentServiceWithRegistry(services.NewPaymentRegistry(services.PaymentProviderMock, nil, services.NewMockPaymentProvider()))
+	express := services.NewExpressCheckoutService(db, carts, services.NewOrderService(db).WithFlashSales(sales), payments, services.ApplePayConfig{})
+
+	user := f.User()
+	product := f.StockedProduct(5)
+	_, err := sales.Plan(ctx, services.FlashSaleRequest{
+		Name:            "Wallet drop",
+		ProductIDs:      []uuid.UUID{product.ID},
+		StartsAt:        time.Now().Add(-time.Second),
+		DurationMinutes: 60,
+		PerUserLimit:    1,
+		AdmitPerMinute:  1,
+	}, nil, time.Now())
+	require.NoError(t, err)
+	require.NoError(t, carts.AddToCart("wallet-drop", &user.ID, services.AddToCartRequest{ProductID: product.ID, Quantity: 1}))
+
+	_, err = express.Checkout(ctx, "wallet-drop", user.ID, &services.ExpressCheckoutRequest{
+		Wallet:          services.WalletApplePay,
+		PaymentToken:    "tok_applepay",
+		ShippingContact: walletContact(),
+	})
+	var waitErr *services.WaitingRoomError
+	assert.ErrorAs(t, err, &waitErr, "wallet checkouts wait their turn like any other")
+}
+
+func TestExpressCheckout_PricingRules(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	ctx := context.Background()
+	rules := services.NewPricingRuleService(db, services.PricingRuleConfig{})
+	carts := services.NewShoppingCartService(db).WithPricingRules(rules)
+	payments := services.NewPaymentServiceWithRegistry(services.NewPaymentRegistry(services.PaymentProviderMock, nil, services.NewMockPaymentProvider()))
+	express := services.NewExpressCheckoutService(db, carts, services.NewOrderService(db).WithPricingRules(rules), payments, services.ApplePayConfig{})
+
+	user := f.User()
+	product := f.StockedProduct(5, func(p *models.Product) { p.Price = 100 })
+	_, err := rules.CreateRule(ctx, nil, services.PricingRuleRequest{
+		Name: "Members save", Type: services.PricingRuleMember, DiscountPercent: 10,
+	})
+	require.NoError(t, err)
+	require.NoError(t, carts.AddToCart("wallet-member", &user.ID, services.AddToCartRequest{ProductID: product.ID, Quantity: 1}))
+
+	result, err := express.Checkout(ctx, "wallet-member", user.ID, &services.ExpressCheckoutRequest{
+		Wallet:          services.WalletGooglePay,
+		PaymentToken:    "tok_googlepay",
+		ShippingContact: walletContact(),
+	})
+	require.NoError(t, err)
+	require.Len(t, result.Order.Items, 1)
+	assert.Equal(t, 90.0, result.Order.Items[0].UnitPrice, "wallet orders get the member price too")
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/internal/services"
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func unavailableLLM() *services.FakeLLM {
+	return services.NewFakeLLM().Fallback(services.FakeLLMResponse{
+		Err: fmt.Errorf("%w: circuit breaker open", services.ErrLLMUnavailable),
+	})
+}
+
+func TestFallbackResponder_Greeting(t *testing.T) {
+	service, _, _ := setupFakeLLMChat(t, unavailableLLM())
+
+	response, err := service.ProcessMessage(context.Background(), "fallback-greeting", nil, "Hello there!")
+	assert.NoError(t, err)
+	assert.Contains(t, response.Message, "limited mode")
+	assert.Equal(t, true, response.Context["fallback"])
+	assert.Equal(t, services.FallbackIntentGreeting, response.Context["fallback_intent"])
+	assert.Equal(t, services.FallbackReasonUnavailable, response.Context["fallback_reason"])
+}
+
+func TestFallbackResponder_AddToCartAndSummary(t *testing.T) {
+	service, db, product := setupFakeLLMChat(t, unavailableLLM())
+	assert.NoError(t, db.Create(&models.Inventory{ID: uuid.New(), ProductID: product.ID, QuantityAvailable: 10}).Error)
+
+	response, err := service.ProcessMessage(context.Background(), "fallback-cart", nil, "Please add 2 wireless headphones")
+	assert.NoError(t, err)
+	assert.Equal(t, services.FallbackIntentAddToCart, response.Context["fallback_intent"])
+	assert.Equal(t, "Added 2 x Wireless Headphones to your cart.", response.Message)
+	if assert.Len(t, response.Actions, 1) {
+		assert.Equal(t, product.ID.String(), response.Actions[0].Payload["product_id"])
+	}
+
+	response, err = service.ProcessMessage(context.Background(), "fallback-cart", nil, "What's in my cart?")
+	assert.NoError(t, err)
+	assert.Equal(t, services.FallbackIntentCartSummary, response.Context["fallback_intent"])
+	assert.Contains(t, response.Message, "Wireless Headphones (Qty: 2")
+}
+
+func TestFallbackResponder_AddUnknownProductFallsThroughToSearch(t *testing.T) {
+	service, _, _ := setupFakeLLMChat(t, unavailableLLM())
+
+	response, err := service.ProcessMessage(context.Background(), "fallback-search", nil, "add a blender")
+	assert.NoError(t, err)
+	assert.Equal(t, services.FallbackIntentSearch, response.Context["fallback_intent"])
+	assert.Empty(t, response.Actions)
+}
+
+func TestFallbackResponder_BudgetExhaustedTagsMetadata(t *testing.T) {
+	fake := services.NewFakeLLM().Fallback(services.FakeLLMResponse{Err: services.ErrLLMBudgetExhausted})
+	service, db, _ := setupFakeLLMChat(t, fake)
+	_, err := service.GetChatSession(context.Background(), "fallback-budget", nil)
+	assert.NoError(t, err)
+
+	response, err := service.ProcessMessage(context.Background(), "fallback-budget", nil, "Show me wireless headphones")
+	assert.NoError(t, err)
+	assert.Equal(t, services.FallbackReasonBudgetExhausted, response.Context["fallback_reason"])
+	assert.Equal(t, false, response.Context["assistant_busy"])
+	assert.NotEmpty(t, response.Suggestions)
+
+	var saved models.ChatMessage
+	assert.NoError(t, db.Where("session_id = ? AND role = ?", "fallback-budget", "assistant").First(&saved).Error)
+	var metadata map[string]interface{}
+	assert.NoError(t, json.Unmarshal(saved.Metadata, &metadata))
+	assert.Equal(t, true, metadata["fallback"])
+	assert.Equal(t, services.FallbackIntentSearch, metadata["fallback_intent"])
+	assert.Equal(t, services.FallbackReasonBudgetExhausted, metadata["fallback_reason"])
+}
+
+func TestFallbackResponder_CustomRules(t *testing.T) {
+	responder := services.NewFallbackResponder(
+		services.FallbackRule{
+			Intent: "declined",
+			Respond: func(ctx context.Context, req *services.FallbackRequest) (*services.ChatResponse, error) {
+				return nil, nil
+			},
+		},
+		services.FallbackRule{
+			Intent: "order_status",
+			Match:  func(message string) bool { return message == "where is my order" },
+			Respond: func(ctx context.Context, req *services.FallbackRequest) (*services.ChatResponse, error) {
+				return &services.ChatResponse{Message: "Check your orders page."}, nil
+			},
+		},
+	)
+
+	response, intent, err := responder.Respond(context.Background(), &services.FallbackRequest{Message: "  Where is my order"})
+	assert.NoError(t, err)
+	assert.Equal(t, "order_status", intent)
+	assert.Equal(t, "Check your orders page.", response.Message)
+
+	_, _, err = responder.Respond(context.Background(), &services.FallbackRequest{Message: "something else"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"chat-ecommerce-backend/tests/testutil/factories"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/datatypes"
+)
+
+func TestFinanceService_SettlementsAndReconciliation(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	finance := services.NewFinanceService(db).WithFees(map[string]services.PaymentFee{
+		services.PaymentProviderStripe: {Percent: 2.9, Fixed: 0.30},
+	})
+	ctx := context.Background()
+	user := f.User()
+
+	paid := func(intentID string, total float64) *services.Order {
+		order := &models.Order{
+			ID:              uuid.New(),
+			UserID:          user.ID,
+			SessionID:       intentID,
+			OrderNumber:     "ORD-" + intentID,
+			Status:          "pending",
+			Subtotal:        total,
+			TotalAmount:     total,
+			Currency:        "USD",
+			PaymentStatus:   "paid",
+			PaymentIntentID: intentID,
+			PaymentProvider: services.PaymentProviderStripe,
+			ShippingAddress: datatypes.JSON(`{}`),
+			BillingAddress:  datatypes.JSON(`{}`),
+		}
+		require.NoError(t, db.Create(order).Error)
+		require.NoError(t, finance.RecordCharge(ctx, order))
+		return order
+	}
+
+	first := paid("pi_first", 100)
+	paid("pi_second", 50)
+	paid("pi_unsettled", 20)
+	require.NoError(t, finance.RecordCharge(ctx, first), "charging an order twice is a no-op")
+	require.NoError(t, finance.RecordRefund(ctx, first, 30))
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	filter := services.SettlementFilter{From: today, To: today.Add(24 * time.Hour)}
+	summaries, err := finance.SettlementSummaries(ctx, filter)
+	require.NoError(t, err)
+	require.Len(t, summaries, 1)
+	summary := summaries[0]
+	assert.Equal(t, 3, summary.Charges)
+	assert.Equal(t, 1, summary.Refunds)
+	assert.Equal(t, 170.0, summary.Gross)
+	assert.Equal(t, 30.0, summary.Refunded)
+	assert.Equal(t, 5.83, summary.Fees) // 3.20 + 1.75 + 0.88
+	assert.Equal(t, 134.17, summary.Net)
+
+	csvData, err := finance.ExportSettlementsCSV(ctx, filter)
+	require.NoError(t, err)
+	assert.Contains(t, string(csvData), today.Format("2006-01-02")+",stripe,USD,3,1,170.00,30.00,5.83,134.17")
+
+	report := "source_id,reporting_category,gross,fee,created\n" +
+		"pi_first,charge,100.00,3.20," + today.Format("2006-01-02") + "\n" +
+		"pi_first,refund,-30.00,0.00," + today.Format("2006-01-02") + "\n" +
+		"pi_second,charge,55.00,1.90," + today.Format("2006-01-02") + "\n" +
+		"pi_unknown,charge,10.00,0.59," + today.Format("2006-01-02") + "\n" +
+		"po_123,payout,-120.00,0.00," + today.Format("2006-01-02") + "\n"
+	reconciliation, err := finance.Reconcile(ctx, services.PaymentProviderStripe, "balance.csv", strings.NewReader(report), nil)
+	require.NoError(t, err)
+	assert.Equal(t, 2, reconciliation.Matched)
+	assert.Equal(t, 1, reconciliation.Mismatched)
+	assert.Equal(t, 1, reconciliation.MissingInLedger)
+	assert.Equal(t, 1, reconciliation.MissingInReport)
+
+	var discrepancies []services.ReconciliationDiscrepancy
+	require.NoError(t, json.Unmarshal(reconciliation.Discrepancies, &discrepancies))
+	kinds := map[string]string{}
+	for _, discrepancy := range discrepancies {
+		kinds[discrepancy.PaymentIntentID] = discrepancy.Kind
+	}
+	assert.Equal(t, map[string]string{
+		"pi_second":    services.DiscrepancyAmountMismatch,
+		"pi_unknown":   services.DiscrepancyMissingInLedger,
+		"pi_unsettled": services.DiscrepancyMissingInReport,
+	}, kinds)
+
+	saved, err := finance.GetReconciliation(ctx, reconciliation.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "balance.csv", saved.FileName)
+
+	_, err = finance.Reconcile(ctx, services.PaymentProviderStripe, "bad.csv", strings.NewReader("foo,bar\n1,2\n"), nil)
+	assert.Error(t, err, "reports without a payment ID column are rejected")
+}
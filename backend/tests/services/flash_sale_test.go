@@ -0,0 +1,102 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"chat-ecommerce-backend/tests/testutil/factories"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func flashSaleConfig() services.FlashSaleConfig {
+	return services.FlashSaleConfig{WarmLead: 10 * time.Minute, WarmInterval: time.Minute, AdmissionTTL: 10 * time.Minute}
+}
+
+func TestFlashSaleService_WaitingRoomAndLimits(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	ctx := context.Background()
+	sales := services.NewFlashSaleService(db, flashSaleConfig())
+	orders := services.NewOrderService(db).WithFlashSales(sales)
+
+	onSale := f.StockedProduct(10)
+	regular := f.StockedProduct(10)
+	first, second := f.User(), f.User()
+
+	start := time.Now().Add(-time.Second)
+	status, err := sales.Plan(ctx, services.FlashSaleRequest{
+		Name:            "Sneaker drop",
+		ProductIDs:      []uuid.UUID{onSale.ID, onSale.ID},
+		StartsAt:        start,
+		DurationMinutes: 60,
+		PerUserLimit:    1,
+		AdmitPerMinute:  1,
+	}, nil, time.Now())
+	require.NoError(t, err)
+	assert.True(t, status.Active)
+	assert.True(t, status.WaitingRoom)
+
+	warm, ok := sales.Product(onSale.ID, time.Now())
+	require.True(t, ok, "sale products are warmed when it's planned")
+	assert.Equal(t, onSale.Name, warm.Name)
+	_, ok = sales.Product(regular.ID, time.Now())
+	assert.False(t, ok)
+
+	order := func(user uuid.UUID, productID uuid.UUID, quantity int) error {
+		_, err := orders.CreateOrder(ctx, &services.CreateOrderRequest{
+			UserID:          user,
+			SessionID:       "session-" + user.String(),
+			Items:           []services.OrderItemRequest{{ProductID: productID, Quantity: quantity}},
+			ShippingAddress: map[string]interface{}{"country": "US"},
+			BillingAddress:  map[string]interface{}{"country": "US"},
+			PaymentMethod:   "card",
+		})
+		return err
+	}
+
+	var waitErr *services.WaitingRoomError
+	require.ErrorAs(t, order(first.ID, onSale.ID, 1), &waitErr, "shoppers must join the waiting room first")
+	assert.Nil(t, waitErr.Ticket)
+
+	now := time.Now()
+	firstTicket, err := sales.Join(ctx, "", &first.ID, now)
+	require.NoError(t, err)
+	assert.True(t, firstTicket.Admitted)
+	secondTicket, err := sales.Join(ctx, "", &second.ID, now)
+	require.NoError(t, err)
+	assert.False(t, secondTicket.Admitted)
+	assert.Equal(t, 1, secondTicket.Position)
+	assert.Positive(t, secondTicket.EstimatedWaitSeconds)
+
+	again, err := sales.Join(ctx, "", &second.ID, now)
+	require.NoError(t, err)
+	assert.Equal(t, secondTicket.Token, again.Token, "joining again keeps the place in line")
+
+	require.ErrorAs(t, order(second.ID, onSale.ID, 1), &waitErr)
+	require.NotNil(t, waitErr.Ticket)
+	assert.Equal(t, 1, waitErr.Ticket.Position)
+	assert.NoError(t, order(second.ID, regular.ID, 1), "other products don't wait")
+
+	var validationErr *services.OrderValidationError
+	require.ErrorAs(t, order(first.ID, onSale.ID, 2), &validationErr)
+	assert.Equal(t, services.ViolationFlashSaleLimit, validationErr.Violations[0].Code)
+	require.NoError(t, order(first.ID, onSale.ID, 1))
+	require.ErrorAs(t, order(first.ID, onSale.ID, 1), &validationErr, "earlier orders count towards the limit")
+
+	polled, err := sales.Ticket(ctx, secondTicket.Token, start.Add(time.Minute))
+	require.NoError(t, err)
+	assert.True(t, polled.Admitted, "one more shopper is let through every minute")
+
+	_, err = sales.Ticket(ctx, firstTicket.Token, start.Add(11*time.Minute))
+	assert.ErrorIs(t, err, services.ErrWaitingRoomTicketNotFound, "admissions run out")
+
+	require.NoError(t, sales.End(ctx, time.Now()))
+	_, err = sales.Ticket(ctx, secondTicket.Token, time.Now())
+	assert.ErrorIs(t, err, services.ErrWaitingRoomTicketNotFound)
+	assert.NoError(t, order(first.ID, onSale.ID, 1), "limits end with the sale")
+}
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"chat-ecommerce-backend/tests/testutil/factories"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrderService_SplitsBackorderedItems(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	orders := services.NewOrderService(db)
+	ctx := context.Background()
+
+	inStock := f.StockedProduct(10, func(p *models.Product) { p.Price = 20 })
+	scarce := f.StockedProduct(1, func(p *models.Product) { p.Price = 50 })
+
+	req := &services.CreateOrderRequest{
+		UserID:    f.User().ID,
+		SessionID: "split",
+		Items: []services.OrderItemRequest{
+			{ProductID: inStock.ID, Quantity: 2},
+			{ProductID: scarce.ID, Quantity: 3},
+		},
+		ShippingAddress: map[string]interface{}{"country": "US"},
+		BillingAddress:  map[string]interface{}{"country": "US"},
+		PaymentMethod:   "card",
+	}
+
+	// Without opting in, a short line still fails the order
+	_, err := orders.CreateOrder(ctx, req)
+	var conflict *services.InventoryConflictError
+	require.ErrorAs(t, err, &conflict)
+
+	req.AllowBackorder = true
+	order, err := orders.CreateOrder(ctx, req)
+	require.NoError(t, err)
+	assert.InDelta(t, 190, order.Subtotal, 0.001, "backordered units are still charged")
+
+	require.Len(t, order.Fulfillments, 2)
+	shipNow, later := order.Fulfillments[0], order.Fulfillments[1]
+	assert.Equal(t, services.FulfillmentPending, shipNow.Status)
+	assert.Equal(t, services.FulfillmentBackordered, later.Status)
+	require.Len(t, shipNow.Items, 2)
+	require.Len(t, later.Items, 1)
+	assert.Equal(t, scarce.ID, later.Items[0].ProductID)
+	assert.Equal(t, 2, later.Items[0].Quantity)
+	assert.Equal(t, later.ID, services.BackorderedFulfillment(order).ID)
+
+	// Releasing the backorder needs the stock to be there
+	_, _, err = orders.UpdateFulfillment(ctx, order.ID, later.ID, services.UpdateFulfillmentRequest{Status: services.FulfillmentPending})
+	require.Error(t, err)
+	require.NoError(t, db.Model(&models.Inventory{}).Where("product_id = ?", scarce.ID).Update("quantity_available", 5).Error)
+	_, _, err = orders.UpdateFulfillment(ctx, order.ID, later.ID, services.UpdateFulfillmentRequest{Status: services.FulfillmentPending})
+	require.NoError(t, err)
+
+	var inventory models.Inventory
+	require.NoError(t, db.Where("product_id = ?", scarce.ID).First(&inventory).Error)
+	assert.Equal(t, 3, inventory.QuantityAvailable)
+
+	order, shipment, err := orders.UpdateFulfillment(ctx, order.ID, shipNow.ID, services.UpdateFulfillmentRequest{
+		Status:         services.FulfillmentShipped,
+		Carrier:        "UPS",
+		TrackingNumber: "1Z999",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "partially_shipped", order.Status)
+	assert.NotNil(t, shipment.ShippedAt)
+	assert.Equal(t, "1Z999", shipment.TrackingNumber)
+
+	_, _, err = orders.UpdateFulfillment(ctx, order.ID, later.ID, services.UpdateFulfillmentRequest{Status: services.FulfillmentDelivered})
+	assert.Error(t, err, "a pending shipment can't skip to delivered")
+
+	_, _, err = orders.UpdateFulfillment(ctx, order.ID, later.ID, services.UpdateFulfillmentRequest{Status: services.FulfillmentShipped})
+	require.NoError(t, err)
+	_, _, err = orders.UpdateFulfillment(ctx, order.ID, shipNow.ID, services.UpdateFulfillmentRequest{Status: services.FulfillmentDelivered})
+	require.NoError(t, err)
+	order, _, err = orders.UpdateFulfillment(ctx, order.ID, later.ID, services.UpdateFulfillmentRequest{Status: services.FulfillmentDelivered})
+	require.NoError(t, err)
+	assert.Equal(t, "delivered", order.Status)
+}
+
+func TestOrderService_CancelBackorderedOrder(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	orders := services.NewOrderService(db)
+	ctx := context.Background()
+
+	product := f.StockedProduct(1)
+	order, err := orders.CreateOrder(ctx, &services.CreateOrderRequest{
+		UserID:          f.User().ID,
+		SessionID:       "cancel",
+		Items:           []services.OrderItemRequest{{ProductID: product.ID, Quantity: 4}},
+		ShippingAddress: map[string]interface{}{"country": "US"},
+		BillingAddress:  map[string]interface{}{"country": "US"},
+		PaymentMethod:   "card",
+		AllowBackorder:  true,
+	})
+	require.NoError(t, err)
+	require.Len(t, order.Fulfillments, 2)
+
+	order, err = orders.CancelOrder(ctx, order.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "cancelled", order.Status)
+
+	// Only the unit that shipped from stock goes back on the shelf
+	var inventory models.Inventory
+	require.NoError(t, db.Where("product_id = ?", product.ID).First(&inventory).Error)
+	assert.Equal(t, 1, inventory.QuantityAvailable)
+	assert.Zero(t, inventory.QuantityReserved)
+}
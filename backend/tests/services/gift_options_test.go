@@ -0,0 +1,86 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"chat-ecommerce-backend/tests/testutil/factories"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrderService_CreateOrder_GiftOptions(t *testing.T) {
+	t.Setenv("GIFT_WRAP_FEE_CENTS", "350")
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	ctx := context.Background()
+	product := f.StockedProduct(5)
+	carts := services.NewShoppingCartService(db)
+	orders := services.NewOrderService(db)
+
+	require.NoError(t, carts.AddToCart("gift-session", nil, services.AddToCartRequest{ProductID: product.ID, Quantity: 1}))
+	cart, err := carts.SetGiftOptions("gift-session", nil, services.GiftOptions{GiftWrap: true, GiftMessage: "  Happy birthday!  "})
+	require.NoError(t, err)
+	assert.Equal(t, 3.5, cart.GiftWrapAmount)
+	assert.Equal(t, "Happy birthday!", cart.GiftMessage)
+	assert.InDelta(t, cart.Subtotal+3.5, cart.TotalAmount, 0.001)
+
+	// Checkout picks up the options chosen on the cart
+	order, err := orders.CreateOrder(ctx, &services.CreateOrderRequest{
+		SessionID:       "gift-session",
+		Items:           []services.OrderItemRequest{{ProductID: product.ID, Quantity: 1}},
+		ShippingAddress: map[string]interface{}{"country": "US"},
+		BillingAddress:  map[string]interface{}{"country": "US"},
+	})
+	require.NoError(t, err)
+	assert.True(t, order.GiftWrap)
+	assert.Equal(t, 3.5, order.GiftWrapAmount)
+	assert.Equal(t, "Happy birthday!", order.GiftMessage)
+	assert.InDelta(t, order.Subtotal+order.TaxAmount+order.ShippingAmount+3.5, order.TotalAmount, 0.001)
+
+	result, err := orders.RecalculateOrder(ctx, order.ID)
+	require.NoError(t, err)
+	assert.True(t, result.Balanced, "the gift wrap fee is part of the expected total")
+
+	// Order numbers only change once a second
+	require.NoError(t, db.Model(order).Update("order_number", "ORD-GIFT-1").Error)
+
+	// Options on the checkout request win over the cart's
+	order, err = orders.CreateOrder(ctx, &services.CreateOrderRequest{
+		SessionID:       "gift-session",
+		Items:           []services.OrderItemRequest{{ProductID: product.ID, Quantity: 1}},
+		ShippingAddress: map[string]interface{}{"country": "US"},
+		BillingAddress:  map[string]interface{}{"country": "US"},
+		Gift:            &services.GiftOptions{GiftMessage: "No wrap, just a note"},
+	})
+	require.NoError(t, err)
+	assert.False(t, order.GiftWrap)
+	assert.Zero(t, order.GiftWrapAmount)
+	assert.Equal(t, "No wrap, just a note", order.GiftMessage)
+
+	_, err = carts.SetGiftOptions("gift-session", nil, services.GiftOptions{GiftMessage: strings.Repeat("x", 251)})
+	assert.ErrorIs(t, err, services.ErrGiftMessageTooLong)
+}
+
+func TestChatService_SetGiftOptionsAction(t *testing.T) {
+	fake := services.NewFakeLLM().Enqueue(services.FakeLLMResponse{
+		Content:   "I'll wrap it as a gift for you.",
+		ToolCalls: []services.LLMToolCall{services.FakeToolCall("set_gift_options", map[string]interface{}{"gift_wrap": true, "gift_message": "Love, Sam"})},
+	})
+	service, db, product := setupFakeLLMChat(t, fake)
+	carts := services.NewShoppingCartService(db)
+	require.NoError(t, carts.AddToCart("chat-gift", nil, services.AddToCartRequest{ProductID: product.ID, Quantity: 1}))
+
+	response, err := service.ProcessMessage(context.Background(), "chat-gift", nil, "Please wrap it as a gift")
+	require.NoError(t, err)
+	require.Len(t, response.Actions, 1)
+	assert.Equal(t, "set_gift_options", response.Actions[0].Type)
+
+	cart, err := carts.GetCart("chat-gift", nil)
+	require.NoError(t, err)
+	assert.True(t, cart.GiftWrap)
+	assert.Equal(t, "Love, Sam", cart.GiftMessage)
+}
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"chat-ecommerce-backend/tests/testutil/factories"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminProductService_BulkImport_SkipsProbableDuplicate(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	service := services.NewAdminProductService(db)
+
+	category := f.Category()
+	existing := f.Product(func(p *models.Product) {
+		p.CategoryID = category.ID
+		p.Name = "Wireless Headphones"
+		p.SKU = "WH-100"
+		p.Price = 99.99
+	})
+
+	response, err := service.BulkImportProducts(services.BulkImportRequest{
+		DuplicateStrategy: services.DuplicateStrategySkip,
+		Products: []services.AdminProductRequest{{
+			Name:        "Headphones, Wireless",
+			Description: "Imported from supplier feed",
+			Price:       94.50,
+			CategoryID:  category.ID,
+			SKU:         "SUP-778",
+		}},
+	})
+	require.NoError(t, err)
+	require.Len(t, response.Duplicates, 1)
+
+	duplicate := response.Duplicates[0]
+	assert.Equal(t, "skipped", duplicate.Action)
+	assert.Equal(t, existing.ID.String(), duplicate.MatchedProductID)
+	assert.Equal(t, "WH-100", duplicate.MatchedSKU)
+	assert.Equal(t, 0, response.Created)
+
+	var count int64
+	db.Model(&models.Product{}).Where("sku = ?", "SUP-778").Count(&count)
+	assert.Equal(t, int64(0), count)
+}
+
+func TestAdminProductService_BulkImport_MergesProbableDuplicate(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	service := services.NewAdminProductService(db)
+
+	category := f.Category()
+	existing := f.Product(func(p *models.Product) {
+		p.CategoryID = category.ID
+		p.Name = "Wireless Headphones"
+		p.SKU = "WH-100"
+		p.Price = 99.99
+	})
+
+	response, err := service.BulkImportProducts(services.BulkImportRequest{
+		DuplicateStrategy: services.DuplicateStrategyMerge,
+		Products: []services.AdminProductRequest{{
+			Name:        "Wireless Headphones",
+			Description: "Updated description",
+			Price:       89.99,
+			CategoryID:  category.ID,
+			SKU:         "SUP-778",
+		}},
+	})
+	require.NoError(t, err)
+	require.Len(t, response.Duplicates, 1)
+	assert.Equal(t, "merged", response.Duplicates[0].Action)
+	assert.Equal(t, 1, response.Updated)
+	assert.Equal(t, 0, response.Created)
+
+	// The existing product keeps its SKU and takes the imported fields
+	var merged models.Product
+	require.NoError(t, db.First(&merged, "id = ?", existing.ID).Error)
+	assert.Equal(t, "WH-100", merged.SKU)
+	assert.Equal(t, "Updated description", merged.Description)
+	assert.InDelta(t, 89.99, merged.Price, 0.001)
+}
+
+func TestAdminProductService_BulkImport_IgnoresDissimilarProducts(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	service := services.NewAdminProductService(db)
+
+	category := f.Category()
+	other := f.Category()
+	f.Product(func(p *models.Product) {
+		p.CategoryID = category.ID
+		p.Name = "Wireless Headphones"
+		p.SKU = "WH-100"
+		p.Price = 99.99
+	})
+
+	rows := []services.AdminProductRequest{
+		// Same name, different category
+		{Name: "Wireless Headphones", Description: "d", Price: 99.99, CategoryID: other.ID, SKU: "NEW-1"},
+		// Same name and category, price far off
+		{Name: "Wireless Headphones", Description: "d", Price: 249.00, CategoryID: category.ID, SKU: "NEW-2"},
+		// Same category and price, different product
+		{Name: "Bluetooth Speaker", Description: "d", Price: 99.00, CategoryID: category.ID, SKU: "NEW-3"},
+	}
+
+	response, err := service.BulkImportProducts(services.BulkImportRequest{
+		DuplicateStrategy: services.DuplicateStrategySkip,
+		Products:          rows,
+	})
+	require.NoError(t, err)
+	assert.Empty(t, response.Duplicates)
+}
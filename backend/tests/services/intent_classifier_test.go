@@ -0,0 +1,87 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"chat-ecommerce-backend/tests/testutil/factories"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIntentClassifier_Rules(t *testing.T) {
+	classifier := services.NewIntentClassifier(services.IntentClassifierConfig{})
+	ctx := context.Background()
+
+	for message, label := range map[string]string{
+		"Show me wireless headphones":          services.MessageIntentBrowse,
+		"Do you sell running shoes?":           services.MessageIntentBrowse,
+		"Please add 2 wireless headphones":     services.MessageIntentAddToCart,
+		"remove the mug from my cart":          services.MessageIntentAddToCart,
+		"Where is my order? It never arrived":  services.MessageIntentSupport,
+		"I'd like to talk to a real person":    services.MessageIntentSupport,
+		"hello":                                services.MessageIntentSmalltalk,
+		"Thanks, that's all!":                  services.MessageIntentSmalltalk,
+		"Let's check out":                      services.MessageIntentCheckout,
+		"I'm ready to pay, place my order now": services.MessageIntentCheckout,
+	} {
+		intent := classifier.Classify(ctx, message)
+		assert.Equal(t, label, intent.Label, message)
+		assert.Equal(t, services.IntentSourceRules, intent.Source, message)
+		assert.GreaterOrEqual(t, intent.Confidence, 0.6, message)
+	}
+
+	// Checking out a product is browsing, not paying
+	assert.Equal(t, services.MessageIntentBrowse, classifier.Classify(ctx, "check out these lamps, show me more").Label)
+}
+
+func TestIntentClassifier_LLMLabelsUnclearMessages(t *testing.T) {
+	fake := services.NewFakeLLM(`{"intent": "support", "confidence": 0.85}`, "no idea")
+	classifier := services.NewIntentClassifier(services.IntentClassifierConfig{Model: "gpt-4o-mini"}).WithLLM(fake)
+	ctx := context.Background()
+
+	intent := classifier.Classify(ctx, "my headphones stopped working after a week")
+	assert.Equal(t, &services.MessageIntent{Label: services.MessageIntentSupport, Confidence: 0.85, Source: services.IntentSourceLLM}, intent)
+	req, err := fake.LastRequest()
+	require.NoError(t, err)
+	assert.Equal(t, "gpt-4o-mini", req.Model)
+
+	// Unreadable answers fall back to the rules' guess
+	intent = classifier.Classify(ctx, "hmm")
+	assert.Equal(t, services.MessageIntentBrowse, intent.Label)
+	assert.Equal(t, services.IntentSourceRules, intent.Source)
+
+	// Clear messages don't need the model
+	classifier.Classify(ctx, "show me lamps")
+	assert.Equal(t, 2, fake.CallCount())
+}
+
+func TestChatService_MessageIntent(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	ctx := context.Background()
+	f.StockedProduct(5, func(p *models.Product) { p.Name = "Wireless Headphones" })
+
+	fake := services.NewFakeLLM().Fallback(services.FakeLLMResponse{Content: "Happy to help."})
+	service := services.NewChatServiceWithProvider(db, fake, services.NewProductService(db), services.NewShoppingCartService(db))
+	_, err := service.GetChatSession(ctx, "intents", nil)
+	require.NoError(t, err)
+
+	response, err := service.ProcessMessage(ctx, "intents", nil, "show me wireless headphones")
+	require.NoError(t, err)
+	require.NotNil(t, response.Intent)
+	assert.Equal(t, services.MessageIntentBrowse, response.Intent.Label)
+	assert.NotEmpty(t, response.Suggestions)
+
+	response, err = service.ProcessMessage(ctx, "intents", nil, "where is my order? the tracking hasn't changed")
+	require.NoError(t, err)
+	assert.Equal(t, services.MessageIntentSupport, response.Intent.Label)
+	assert.Empty(t, response.Suggestions, "support questions don't get product suggestions")
+
+	var labels []string
+	require.NoError(t, db.Model(&models.ChatAnalytics{}).Where("session_id = ?", "intents").Order("created_at").Pluck("message_intent", &labels).Error)
+	assert.Equal(t, []string{services.MessageIntentBrowse, services.MessageIntentSupport}, labels)
+}
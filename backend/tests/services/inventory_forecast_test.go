@@ -0,0 +1,81 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"chat-ecommerce-backend/tests/testutil/factories"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInventoryForecastService_RunForecasts(t *testing.T) {
+	// SQLite returns MAX() of a timestamp as text
+	testutil.RequirePostgres(t)
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	ctx := context.Background()
+	user := f.User()
+	now := time.Now()
+
+	// Two units a day for the past 100 days
+	steady := f.StockedProduct(20)
+	for day := 1; day <= 100; day++ {
+		placedAt := now.AddDate(0, 0, -day)
+		f.Order(user, []factories.OrderLine{{Product: steady, Quantity: 2}}, func(o *models.Order) {
+			o.CreatedAt = placedAt
+		})
+	}
+	// Cancelled orders aren't demand
+	f.Order(user, []factories.OrderLine{{Product: steady, Quantity: 500}}, func(o *models.Order) {
+		o.Status = "cancelled"
+		o.CreatedAt = now.AddDate(0, 0, -3)
+	})
+	idle := f.StockedProduct(5)
+
+	forecasts := services.NewInventoryForecastService(db, services.ForecastConfig{LeadTimeDays: 14, SafetyStockDays: 7, Hour: 3})
+	result, err := forecasts.RunForecasts(ctx, now)
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.Products)
+	assert.Equal(t, 1, result.ReorderProducts)
+
+	list, err := forecasts.ListForecasts(ctx, false)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+
+	byProduct := map[string]models.DemandForecast{}
+	for _, forecast := range list {
+		byProduct[forecast.ProductID.String()] = forecast
+	}
+	forecast := byProduct[steady.ID.String()]
+	assert.Equal(t, services.ForecastExponentialSmoothing, forecast.Method)
+	assert.InDelta(t, 2, forecast.DailyDemand, 0.01)
+	assert.Equal(t, 60, forecast.Forecast30)
+	assert.Equal(t, 120, forecast.Forecast60)
+	assert.Equal(t, 180, forecast.Forecast90)
+	assert.Equal(t, 42-20, forecast.ReorderQuantity, "21 days of cover less the 20 units on hand")
+	assert.Zero(t, byProduct[idle.ID.String()].ReorderQuantity)
+
+	reorder, err := forecasts.ListForecasts(ctx, true)
+	require.NoError(t, err)
+	require.Len(t, reorder, 1)
+	assert.Equal(t, steady.ID, reorder[0].ProductID)
+
+	// The inventory report shows the forecast and what to reorder
+	report, err := services.NewInventoryService(db).GetInventoryReport(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 60, report.Forecast30)
+	require.Len(t, report.ReorderSuggestions, 1)
+	assert.Equal(t, steady.ID, report.ReorderSuggestions[0].ProductID)
+
+	// A new run replaces the previous forecasts
+	_, err = forecasts.RunForecasts(ctx, now)
+	require.NoError(t, err)
+	var count int64
+	require.NoError(t, db.Model(&models.DemandForecast{}).Count(&count).Error)
+	assert.Equal(t, int64(2), count)
+}
@@ -0,0 +1,92 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/pkg/listquery"
+	"chat-ecommerce-backend/pkg/validation"
+	"chat-ecommerce-backend/tests/testutil"
+	"chat-ecommerce-backend/tests/testutil/factories"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListQuery_RejectsUndeclaredParameters(t *testing.T) {
+	for _, raw := range []string{
+		"filter[password_hash]=x",
+		"filter[price][contains]=1",
+		"filter[price]=cheap",
+		"filter[category_id]=not-a-uuid",
+		"sort=name%3BDROP+TABLE+products",
+		"sort=description",
+		"sort_by=id desc",
+		"page[size]=lots",
+	} {
+		values, err := url.ParseQuery(raw)
+		require.NoError(t, err)
+		_, err = listquery.Parse(services.ProductListSchema, values)
+		var invalid validation.ValidationErrors
+		assert.ErrorAs(t, err, &invalid, raw)
+	}
+}
+
+func TestListQuery_ParsesFiltersSortAndPage(t *testing.T) {
+	values, err := url.ParseQuery("min_price=10&filter[price][lt]=50&filter[sku][in]=A,B&sort=-price,name&page[number]=3&page[size]=500")
+	require.NoError(t, err)
+
+	list, err := listquery.Parse(services.ProductListSchema, values)
+	require.NoError(t, err)
+	assert.Len(t, list.Conditions, 3, "the min_price alias is a price filter")
+	assert.Equal(t, []listquery.Sort{{Key: "price", Desc: true}, {Key: "name"}}, list.Sorts)
+	assert.Equal(t, 3, list.Page)
+	assert.Equal(t, 100, list.Size, "page sizes are capped")
+
+	legacy, err := listquery.Parse(services.ProductListSchema, url.Values{"sort_by": {"price"}, "sort_order": {"asc"}, "limit": {"5"}})
+	require.NoError(t, err)
+	assert.Equal(t, []listquery.Sort{{Key: "price"}}, legacy.Sorts)
+	assert.Equal(t, 5, legacy.Size)
+}
+
+func TestListQuery_FiltersProducts(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	service := services.NewProductService(db)
+
+	f.Product(func(p *models.Product) { p.Name = "Desk Lamp"; p.Price = 30 })
+	f.Product(func(p *models.Product) { p.Name = "Floor Lamp"; p.Price = 120 })
+	f.Product(func(p *models.Product) { p.Name = "Lamp Shade 100%"; p.Price = 15 })
+	f.Product(func(p *models.Product) { p.Name = "Office Chair"; p.Price = 80 })
+
+	list, err := listquery.Parse(services.ProductListSchema, url.Values{
+		"filter[name][contains]": {"lamp"},
+		"max_price":              {"100"},
+		"sort":                   {"price"},
+	})
+	require.NoError(t, err)
+	result, err := service.GetProducts(services.ProductFilters{List: list})
+	require.NoError(t, err)
+	require.Len(t, result.Products, 2)
+	assert.Equal(t, "Lamp Shade 100%", result.Products[0].Name)
+	assert.Equal(t, "Desk Lamp", result.Products[1].Name)
+	assert.Equal(t, int64(2), result.Total)
+
+	// Wildcards in values are matched literally
+	list, err = listquery.Parse(services.ProductListSchema, url.Values{"filter[name][contains]": {"100%"}})
+	require.NoError(t, err)
+	result, err = service.GetProducts(services.ProductFilters{List: list})
+	require.NoError(t, err)
+	require.Len(t, result.Products, 1)
+	assert.Equal(t, "Lamp Shade 100%", result.Products[0].Name)
+
+	list, err = listquery.Parse(services.ProductListSchema, url.Values{"page[number]": {"2"}, "page[size]": {"3"}, "sort": {"name"}})
+	require.NoError(t, err)
+	result, err = service.GetProducts(services.ProductFilters{List: list})
+	require.NoError(t, err)
+	require.Len(t, result.Products, 1)
+	assert.Equal(t, "Office Chair", result.Products[0].Name)
+	assert.True(t, result.HasPrevious)
+	assert.False(t, result.HasNext)
+}
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/datatypes"
+)
+
+func float32Ptr(v float32) *float32 { return &v }
+func intPtr(v int) *int             { return &v }
+
+func TestLLMSettingsService_ResolveConfig(t *testing.T) {
+	t.Setenv("OPENAI_MODEL", "gpt-4o")
+	t.Setenv("OPENAI_TEMPERATURE", "")
+	t.Setenv("OPENAI_MAX_TOKENS", "")
+
+	db := testutil.NewTestDB(t)
+	service := services.NewLLMSettingsService(db)
+	ctx := context.Background()
+
+	// Environment defaults
+	config := service.ResolveConfig(ctx, "session-1")
+	assert.Equal(t, "gpt-4o", config.Model)
+	assert.Equal(t, float32(0.7), config.Temperature)
+	assert.Equal(t, 500, config.MaxTokens)
+	assert.Equal(t, services.LLMConfigSourceEnvironment, config.Source)
+
+	// Store-wide settings
+	_, err := service.UpsertSettings(ctx, services.DefaultStoreVariant, services.LLMSettingsRequest{
+		Model:       "gpt-4o-mini",
+		Temperature: float32Ptr(0.2),
+	})
+	assert.NoError(t, err)
+
+	config = service.ResolveConfig(ctx, "session-1")
+	assert.Equal(t, "gpt-4o-mini", config.Model)
+	assert.Equal(t, float32(0.2), config.Temperature)
+	assert.Equal(t, 500, config.MaxTokens)
+	assert.Equal(t, services.LLMConfigSourceStore, config.Source)
+
+	// An experiment taking all traffic overrides the store defaults it sets
+	_, err = service.UpsertSettings(ctx, "concise", services.LLMSettingsRequest{
+		MaxTokens:      intPtr(200),
+		TrafficPercent: 100,
+	})
+	assert.NoError(t, err)
+
+	config = service.ResolveConfig(ctx, "session-1")
+	assert.Equal(t, "gpt-4o-mini", config.Model)
+	assert.Equal(t, 200, config.MaxTokens)
+	assert.Equal(t, "concise", config.Variant)
+	assert.Equal(t, services.LLMConfigSourceExperiment, config.Source)
+
+	// Traffic across experiments cannot exceed 100%
+	_, err = service.UpsertSettings(ctx, "creative", services.LLMSettingsRequest{TrafficPercent: 10})
+	assert.Error(t, err)
+}
+
+func TestLLMSettingsService_SessionOverride(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	service := services.NewLLMSettingsService(db)
+	ctx := context.Background()
+
+	assert.Error(t, service.SetSessionOverride(ctx, "missing-session", &services.LLMOverrideRequest{Model: "gpt-4o"}))
+
+	assert.NoError(t, db.Create(&models.ChatSession{
+		ID: uuid.New(), SessionID: "debug-session", Status: "active",
+		Context: datatypes.JSON(`{"memory":{"summary":"Likes trail running"}}`),
+	}).Error)
+	err := service.SetSessionOverride(ctx, "debug-session", &services.LLMOverrideRequest{
+		Model:       "gpt-4o",
+		Temperature: float32Ptr(0),
+	})
+	assert.NoError(t, err)
+
+	config := service.ResolveConfig(ctx, "debug-session")
+	assert.Equal(t, "gpt-4o", config.Model)
+	assert.Equal(t, float32(0), config.Temperature)
+	assert.Equal(t, services.LLMConfigSourceSessionOverride, config.Source)
+
+	assert.NoError(t, service.SetSessionOverride(ctx, "debug-session", nil))
+	override, err := service.GetSessionOverride(ctx, "debug-session")
+	assert.NoError(t, err)
+	assert.Nil(t, override)
+
+	var session models.ChatSession
+	assert.NoError(t, db.Where("session_id = ?", "debug-session").First(&session).Error)
+	assert.JSONEq(t, `{"memory":{"summary":"Likes trail running"}}`, string(session.Context), "the rest of the context is kept")
+}
+
+func TestChatService_ProcessMessage_UsesStoreSettings(t *testing.T) {
+	fake := services.NewFakeLLM("Hi!")
+	service, db, _ := setupFakeLLMChat(t, fake)
+	assert.NoError(t, db.AutoMigrate(&models.StoreSettings{}))
+
+	_, err := services.NewLLMSettingsService(db).UpsertSettings(context.Background(), services.DefaultStoreVariant, services.LLMSettingsRequest{
+		Model:       "gpt-4-turbo",
+		Temperature: float32Ptr(0.3),
+		MaxTokens:   intPtr(256),
+	})
+	assert.NoError(t, err)
+
+	_, err = service.ProcessMessage(context.Background(), "settings-session", nil, "Which headphones would you recommend for running?")
+	assert.NoError(t, err)
+
+	req, err := fake.LastRequest()
+	assert.NoError(t, err)
+	assert.Equal(t, "gpt-4-turbo", req.Model)
+	assert.Equal(t, float32(0.3), req.Temperature)
+	assert.Equal(t, 256, req.MaxTokens)
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"chat-ecommerce-backend/tests/testutil/factories"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoginSecurity_LockoutAndUnlock(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	security := services.NewLoginSecurityService(db).WithConfig(services.LoginSecurityConfig{
+		MaxFailedAttempts: 3,
+		LockoutDuration:   15 * time.Minute,
+		MaxIPFailures:     10,
+		IPWindow:          15 * time.Minute,
+	})
+	users := services.NewUserService(db).WithLoginSecurity(security)
+	ctx := context.Background()
+
+	user := f.User()
+	_, err := users.Login(&services.LoginRequest{Email: user.Email, Password: factories.DefaultPassword, IPAddress: "10.0.0.1"})
+	require.NoError(t, err)
+
+	wrong := &services.LoginRequest{Email: user.Email, Password: "not-the-password", IPAddress: "10.0.0.9"}
+	for i := 0; i < 2; i++ {
+		_, err = users.Login(wrong)
+		require.Error(t, err)
+		assert.NotErrorIs(t, err, services.ErrAccountLocked)
+	}
+	_, err = users.Login(wrong)
+	assert.ErrorIs(t, err, services.ErrAccountLocked, "the third failure locks the account")
+
+	right := &services.LoginRequest{Email: user.Email, Password: factories.DefaultPassword, IPAddress: "10.0.0.9"}
+	_, err = users.Login(right)
+	assert.ErrorIs(t, err, services.ErrAccountLocked, "locked accounts reject the right password too")
+
+	notifications, err := security.ListNotifications(ctx, user.ID, true)
+	require.NoError(t, err)
+	require.Len(t, notifications, 1)
+	assert.Equal(t, services.SecurityNotificationAccountLocked, notifications[0].Type)
+	assert.Contains(t, notifications[0].Message, "10.0.0.9")
+
+	var email models.SecurityNotification
+	require.NoError(t, db.Where("user_id = ? AND channel = ?", user.ID, services.SecurityChannelEmail).First(&email).Error)
+	assert.Equal(t, user.Email, email.Recipient)
+	_, link, found := strings.Cut(email.Message, "?token=")
+	require.True(t, found, "the email carries an unlock link")
+	token := strings.Fields(link)[0]
+
+	assert.ErrorIs(t, security.UnlockAccount(ctx, "not-a-token"), services.ErrInvalidUnlockToken)
+	require.NoError(t, security.UnlockAccount(ctx, token))
+	assert.ErrorIs(t, security.UnlockAccount(ctx, token), services.ErrInvalidUnlockToken, "unlock links work once")
+
+	_, err = users.Login(right)
+	require.NoError(t, err)
+
+	notifications, err = security.ListNotifications(ctx, user.ID, true)
+	require.NoError(t, err)
+	require.Len(t, notifications, 3)
+	assert.Equal(t, services.SecurityNotificationNewSignIn, notifications[0].Type, "first sign-in from 10.0.0.9")
+
+	require.NoError(t, security.MarkNotificationsRead(ctx, user.ID))
+	notifications, err = security.ListNotifications(ctx, user.ID, true)
+	require.NoError(t, err)
+	assert.Empty(t, notifications)
+}
+
+func TestLoginSecurity_ThrottlesIPAddress(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	security := services.NewLoginSecurityService(db).WithConfig(services.LoginSecurityConfig{
+		MaxFailedAttempts: 5,
+		LockoutDuration:   15 * time.Minute,
+		MaxIPFailures:     3,
+		IPWindow:          15 * time.Minute,
+	})
+	users := services.NewUserService(db).WithLoginSecurity(security)
+
+	user := f.User()
+	for _, email := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+		_, err := users.Login(&services.LoginRequest{Email: email, Password: "guess", IPAddress: "203.0.113.7"})
+		require.Error(t, err)
+		assert.NotErrorIs(t, err, services.ErrTooManyLoginAttempts)
+	}
+
+	_, err := users.Login(&services.LoginRequest{Email: user.Email, Password: factories.DefaultPassword, IPAddress: "203.0.113.7"})
+	assert.ErrorIs(t, err, services.ErrTooManyLoginAttempts)
+
+	_, err = users.Login(&services.LoginRequest{Email: user.Email, Password: factories.DefaultPassword, IPAddress: "198.51.100.2"})
+	require.NoError(t, err, "other addresses aren't affected")
+}
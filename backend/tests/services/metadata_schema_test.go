@@ -0,0 +1,192 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/pkg/listquery"
+	"chat-ecommerce-backend/tests/testutil"
+	"chat-ecommerce-backend/tests/testutil/factories"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/datatypes"
+)
+
+// electronicsSchema requires wattage and connectivity, both offered as facets
+func electronicsSchema(t *testing.T) *services.MetadataSchema {
+	var schema services.MetadataSchema
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"type": "object",
+		"properties": {
+			"wattage": {"type": "number", "title": "Wattage", "minimum": 1, "x-unit": "W", "x-facet": true},
+			"connectivity": {"type": "string", "enum": ["wifi", "bluetooth", "wired"], "x-facet": true},
+			"smart": {"type": "boolean", "x-facet": true}
+		},
+		"required": ["wattage", "connectivity"]
+	}`), &schema))
+	return &schema
+}
+
+func metadataRequest(category *models.Category, sku string, metadata map[string]interface{}) services.AdminProductRequest {
+	request := skuRequest(category, sku)
+	request.Metadata = metadata
+	return request
+}
+
+func TestAdminProductService_MetadataSchema(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	svc := services.NewAdminProductService(db).WithSKUConfig(services.SKUConfig{})
+	ctx := context.Background()
+	electronics := f.Category()
+	lamps := f.Category(func(c *models.Category) { c.ParentID = &electronics.ID })
+
+	legacy := f.Product(func(p *models.Product) {
+		p.CategoryID = lamps.ID
+		p.Metadata = datatypes.JSON(`{"wattage": "60W"}`)
+	})
+
+	// Schemas the store can't check products against are refused
+	_, err := svc.SetCategoryMetadataSchema(ctx, electronics.ID, &services.MetadataSchema{
+		Properties: map[string]*services.MetadataProperty{"Power Draw": {Type: services.MetadataNumber}},
+	})
+	assert.ErrorIs(t, err, services.ErrInvalidMetadataSchema)
+	_, err = svc.SetCategoryMetadataSchema(ctx, electronics.ID, &services.MetadataSchema{
+		Properties: map[string]*services.MetadataProperty{"wattage": {Type: services.MetadataNumber}},
+		Required:   []string{"voltage"},
+	})
+	assert.ErrorIs(t, err, services.ErrInvalidMetadataSchema)
+
+	// Products already in the subtree that don't match are reported
+	result, err := svc.SetCategoryMetadataSchema(ctx, electronics.ID, electronicsSchema(t))
+	require.NoError(t, err)
+	require.Len(t, result.Products, 1)
+	assert.Equal(t, legacy.ID, result.Products[0].ProductID)
+	assert.ElementsMatch(t, []services.MetadataViolation{
+		{Attribute: "connectivity", Message: "is required"},
+		{Attribute: "wattage", Message: "must be a number"},
+	}, result.Products[0].Violations)
+
+	// Subcategories add to their ancestors' schemas
+	_, err = svc.SetCategoryMetadataSchema(ctx, lamps.ID, &services.MetadataSchema{
+		Properties: map[string]*services.MetadataProperty{"bulb": {Type: services.MetadataString, Enum: []interface{}{"E26", "E27"}}},
+		Required:   []string{"bulb"},
+	})
+	require.NoError(t, err)
+	lampSchema, err := svc.GetCategoryMetadataSchema(ctx, lamps.ID)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"wattage", "connectivity", "bulb"}, lampSchema.Effective.Required)
+
+	_, err = svc.CreateProduct(metadataRequest(lamps, "LAMP-1", map[string]interface{}{"wattage": 0.5, "connectivity": "zigbee"}))
+	var validationErr *services.MetadataValidationError
+	require.True(t, errors.As(err, &validationErr), "got %v", err)
+	assert.ElementsMatch(t, []services.MetadataViolation{
+		{Attribute: "bulb", Message: "is required"},
+		{Attribute: "connectivity", Message: "must be one of wifi, bluetooth, wired"},
+		{Attribute: "wattage", Message: "must be at least 1"},
+	}, validationErr.Violations)
+
+	lamp, err := svc.CreateProduct(metadataRequest(lamps, "LAMP-2", map[string]interface{}{"wattage": 60, "connectivity": "wifi", "bulb": "E27", "finish": "brass"}))
+	require.NoError(t, err, "keys outside the schema are allowed")
+
+	// Patches are checked with the existing metadata merged in
+	_, err = svc.PatchProduct(ctx, lamp.Product.ID, services.AdminProductPatch{Metadata: map[string]interface{}{"wattage": "sixty"}})
+	require.True(t, errors.As(err, &validationErr), "got %v", err)
+	_, err = svc.PatchProduct(ctx, lamp.Product.ID, services.AdminProductPatch{Metadata: map[string]interface{}{"wattage": 40}})
+	assert.NoError(t, err)
+
+	// Moving a product into a category checks it against the new schema
+	plain := f.Category()
+	other, err := svc.CreateProduct(metadataRequest(plain, "MUG-1", nil))
+	require.NoError(t, err)
+	_, err = svc.PatchProduct(ctx, other.Product.ID, services.AdminProductPatch{CategoryID: &lamps.ID})
+	assert.True(t, errors.As(err, &validationErr), "got %v", err)
+
+	// Removing the schema lifts its rules
+	_, err = svc.SetCategoryMetadataSchema(ctx, lamps.ID, nil)
+	require.NoError(t, err)
+	_, err = svc.CreateProduct(metadataRequest(lamps, "LAMP-3", map[string]interface{}{"wattage": 9, "connectivity": "wired"}))
+	assert.NoError(t, err)
+}
+
+func TestProductService_MetadataFacetsAndFilters(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	admin := services.NewAdminProductService(db).WithSKUConfig(services.SKUConfig{})
+	products := services.NewProductService(db)
+	ctx := context.Background()
+	electronics := f.Category()
+	_, err := admin.SetCategoryMetadataSchema(ctx, electronics.ID, electronicsSchema(t))
+	require.NoError(t, err)
+
+	for i, metadata := range []map[string]interface{}{
+		{"wattage": 60, "connectivity": "wifi", "smart": true},
+		{"wattage": 9, "connectivity": "wifi"},
+		{"wattage": 1200, "connectivity": "wired", "smart": false},
+	} {
+		_, err := admin.CreateProduct(metadataRequest(electronics, []string{"BULB-60", "BULB-9", "KETTLE"}[i], metadata))
+		require.NoError(t, err)
+	}
+	// Metadata of other categories doesn't have to follow the schema
+	f.Product(func(p *models.Product) { p.Metadata = datatypes.JSON(`{"wattage": "lots", "smart": "yes"}`) })
+
+	facets, err := products.CategoryFacets(ctx, electronics.ID)
+	require.NoError(t, err)
+	require.Len(t, facets, 3)
+	assert.Equal(t, "connectivity", facets[0].Attribute)
+	assert.Equal(t, []services.FacetValue{{Value: "wifi", Count: 2}, {Value: "wired", Count: 1}}, facets[0].Values)
+	assert.Equal(t, "wattage", facets[2].Attribute)
+	assert.Equal(t, "W", facets[2].Unit)
+	assert.Equal(t, services.MetadataFilterPrefix+"wattage", facets[2].Filter)
+	require.NotNil(t, facets[2].Min)
+	assert.Equal(t, 9.0, *facets[2].Min)
+	assert.Equal(t, 1200.0, *facets[2].Max)
+
+	list := func(values url.Values) []string {
+		query, err := listquery.Parse(products.ListSchema(ctx), values)
+		require.NoError(t, err)
+		result, err := products.GetProducts(services.ProductFilters{List: query})
+		require.NoError(t, err)
+		var skus []string
+		for _, product := range result.Products {
+			skus = append(skus, product.SKU)
+		}
+		return skus
+	}
+	assert.ElementsMatch(t, []string{"BULB-60", "KETTLE"}, list(url.Values{"filter[attr_wattage][gte]": {"50"}}))
+	assert.ElementsMatch(t, []string{"BULB-60", "BULB-9"}, list(url.Values{"filter[attr_connectivity]": {"wifi"}}))
+	assert.ElementsMatch(t, []string{"BULB-60"}, list(url.Values{"filter[attr_smart]": {"true"}}))
+
+	_, err = listquery.Parse(services.ProductListSchema, url.Values{"filter[attr_wattage]": {"60"}})
+	assert.Error(t, err, "attribute filters come from the schemas")
+}
+
+func TestChatService_ProductAttributesInPrompt(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	admin := services.NewAdminProductService(db).WithSKUConfig(services.SKUConfig{})
+	ctx := context.Background()
+	electronics := f.Category()
+	_, err := admin.SetCategoryMetadataSchema(ctx, electronics.ID, electronicsSchema(t))
+	require.NoError(t, err)
+	_, err = admin.CreateProduct(metadataRequest(electronics, "BULB-60", map[string]interface{}{"wattage": 60, "connectivity": "wifi", "smart": true, "supplier_code": "ZX-881"}))
+	require.NoError(t, err)
+
+	fake := services.NewFakeLLM().Fallback(services.FakeLLMResponse{Content: "It draws 60 W."})
+	service := services.NewChatServiceWithProvider(db, fake, services.NewProductService(db), services.NewShoppingCartService(db))
+	_, err = service.GetChatSession(ctx, "attributes", nil)
+	require.NoError(t, err)
+	_, err = service.ProcessMessage(ctx, "attributes", nil, "how much power does the bulb use?")
+	require.NoError(t, err)
+
+	req, err := fake.LastRequest()
+	require.NoError(t, err)
+	prompt := req.Messages[0].Content
+	assert.Contains(t, prompt, `"attributes":{"Wattage":"60 W","connectivity":"wifi","smart":"yes"}`)
+	assert.NotContains(t, prompt, "ZX-881", "metadata outside the schema isn't described")
+}
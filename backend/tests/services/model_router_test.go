@@ -0,0 +1,81 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/internal/services"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModelRouter_Route(t *testing.T) {
+	router := services.NewModelRouter("cheap-model")
+	config := services.LLMConfig{Model: "premium-model", Source: services.LLMConfigSourceStore}
+
+	tests := []struct {
+		message string
+		intent  string
+		tier    string
+		model   string
+	}{
+		{"Hi there!", services.ChatIntentGreeting, services.ModelTierEconomy, "cheap-model"},
+		{"Where is my order?", services.ChatIntentOrderStatus, services.ModelTierEconomy, "cheap-model"},
+		{"What's in my cart?", services.ChatIntentCartSummary, services.ModelTierEconomy, "cheap-model"},
+		{"Add the blue mug to my cart", services.ChatIntentProductAdvice, services.ModelTierPremium, "premium-model"},
+		{"Which laptop is best for video editing under $1500?", services.ChatIntentProductAdvice, services.ModelTierPremium, "premium-model"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.message, func(t *testing.T) {
+			route := router.Route(tt.message, config)
+			assert.Equal(t, tt.intent, route.Intent)
+			assert.Equal(t, tt.tier, route.Tier)
+			assert.Equal(t, tt.model, route.Model)
+		})
+	}
+}
+
+func TestModelRouter_HonoursSessionOverride(t *testing.T) {
+	router := services.NewModelRouter("cheap-model")
+
+	route := router.Route("hello", services.LLMConfig{Model: "debug-model", Source: services.LLMConfigSourceSessionOverride})
+	assert.Equal(t, services.ModelTierPremium, route.Tier)
+	assert.Equal(t, "debug-model", route.Model)
+}
+
+func TestModelRouterFromEnv_Disabled(t *testing.T) {
+	t.Setenv("OPENAI_ECONOMY_MODEL", "off")
+
+	route := services.ModelRouterFromEnv().Route("hello", services.LLMConfig{Model: "premium-model"})
+	assert.Equal(t, "premium-model", route.Model)
+}
+
+func TestChatService_ProcessMessage_RecordsRoutingAnalytics(t *testing.T) {
+	t.Setenv("OPENAI_ECONOMY_MODEL", "cheap-model")
+	fake := services.NewFakeLLM("Sure!")
+	service, db, _ := setupFakeLLMChat(t, fake)
+	assert.NoError(t, db.AutoMigrate(&models.ChatAnalytics{}))
+
+	ctx := context.Background()
+	_, err := service.ProcessMessage(ctx, "routing-session", nil, "Hello")
+	assert.NoError(t, err)
+	req, _ := fake.LastRequest()
+	assert.Equal(t, "cheap-model", req.Model)
+
+	_, err = service.ProcessMessage(ctx, "routing-session", nil, "Can you recommend some wireless headphones?")
+	assert.NoError(t, err)
+	req, _ = fake.LastRequest()
+	assert.NotEqual(t, "cheap-model", req.Model)
+
+	report, err := services.NewChatAnalyticsService(db).GetModelRoutingReport(ctx, time.Now().Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), report.TotalTurns)
+	assert.InDelta(t, 0.5, report.EconomyShare, 0.001)
+
+	var greeting models.ChatAnalytics
+	assert.NoError(t, db.Where("intent = ?", services.ChatIntentGreeting).First(&greeting).Error)
+	assert.Equal(t, services.ModelTierEconomy, greeting.ModelTier)
+	assert.Greater(t, greeting.PromptTokens, 0)
+}
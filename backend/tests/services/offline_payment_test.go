@@ -0,0 +1,86 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"chat-ecommerce-backend/tests/testutil/factories"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func offlinePayments() services.OfflinePaymentConfig {
+	return services.OfflinePaymentConfig{
+		Methods: map[string]services.OfflinePaymentMethod{
+			services.PaymentMethodCOD:          {ID: services.PaymentMethodCOD, Instructions: "Pay the courier", ReservationTTL: 14 * 24 * time.Hour},
+			services.PaymentMethodBankTransfer: {ID: services.PaymentMethodBankTransfer, Instructions: "Wire to IBAN", ReservationTTL: 72 * time.Hour},
+		},
+		SweepInterval: time.Minute,
+	}
+}
+
+func placeOfflineOrder(t *testing.T, f *factories.Factory, orders *services.OrderService, product *models.Product, method string) (*services.Order, error) {
+	t.Helper()
+	return orders.CreateOrder(context.Background(), &services.CreateOrderRequest{
+		UserID:          f.User().ID,
+		SessionID:       "offline-" + method,
+		Items:           []services.OrderItemRequest{{ProductID: product.ID, Quantity: 1}},
+		ShippingAddress: map[string]interface{}{"country": "US"},
+		BillingAddress:  map[string]interface{}{"country": "US"},
+		PaymentMethod:   method,
+	})
+}
+
+func TestOrderService_OfflinePayments(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	ctx := context.Background()
+	product := f.StockedProduct(5)
+
+	_, err := placeOfflineOrder(t, f, services.NewOrderService(db).WithOfflinePayments(services.OfflinePaymentConfig{}), product, services.PaymentMethodCOD)
+	assert.Error(t, err, "disabled offline methods are rejected")
+
+	orders := services.NewOrderService(db).WithOfflinePayments(offlinePayments())
+	cod, err := placeOfflineOrder(t, f, orders, product, services.PaymentMethodCOD)
+	require.NoError(t, err)
+	transfer, err := placeOfflineOrder(t, f, orders, product, services.PaymentMethodBankTransfer)
+	require.NoError(t, err)
+
+	assert.Equal(t, services.OrderStatusAwaitingPayment, cod.Status)
+	assert.Equal(t, services.PaymentProviderOffline, cod.PaymentProvider)
+	assert.Equal(t, "Pay the courier", orders.OfflinePaymentInstructions(cod))
+	require.NotNil(t, cod.PaymentDueAt)
+	require.NotNil(t, transfer.PaymentDueAt)
+	assert.True(t, transfer.PaymentDueAt.Before(*cod.PaymentDueAt), "each method holds stock for its own TTL")
+
+	// Four days later the bank transfer hasn't arrived, but COD still has time
+	expired, err := orders.ExpireUnpaidOrders(ctx, time.Now().Add(96*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 1, expired)
+
+	transfer, err = orders.GetOrderByID(ctx, transfer.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "cancelled", transfer.Status)
+	assert.Equal(t, "expired", transfer.PaymentStatus)
+
+	var inventory models.Inventory
+	require.NoError(t, db.Where("product_id = ?", product.ID).First(&inventory).Error)
+	assert.Equal(t, 4, inventory.QuantityAvailable, "only the COD order still holds stock")
+
+	cod, err = orders.MarkOrderPaid(ctx, cod.ID, services.MarkOrderPaidRequest{Reference: "courier-123"})
+	require.NoError(t, err)
+	assert.Equal(t, "paid", cod.PaymentStatus)
+	assert.Equal(t, "pending", cod.Status)
+	assert.Equal(t, "courier-123", cod.PaymentReference)
+	assert.Nil(t, cod.PaymentDueAt)
+	assert.Empty(t, orders.OfflinePaymentInstructions(cod))
+
+	_, err = orders.MarkOrderPaid(ctx, cod.ID, services.MarkOrderPaidRequest{})
+	assert.Error(t, err, "an order can only be marked as paid once")
+	_, err = orders.MarkOrderPaid(ctx, transfer.ID, services.MarkOrderPaidRequest{})
+	assert.Error(t, err, "expired orders can't be marked as paid")
+}
@@ -0,0 +1,59 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"chat-ecommerce-backend/tests/testutil/factories"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrderService_RecalculateOrder(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	ctx := context.Background()
+	product := f.StockedProduct(5)
+	orders := services.NewOrderService(db)
+
+	order, err := orders.CreateOrder(ctx, &services.CreateOrderRequest{
+		UserID:          f.User().ID,
+		SessionID:       "audit-session",
+		Items:           []services.OrderItemRequest{{ProductID: product.ID, Quantity: 3}},
+		ShippingAddress: map[string]interface{}{"country": "US"},
+		BillingAddress:  map[string]interface{}{"country": "US"},
+	})
+	require.NoError(t, err)
+
+	result, err := orders.RecalculateOrder(ctx, order.ID)
+	require.NoError(t, err)
+	assert.True(t, result.Balanced, "a fresh checkout matches its recomputed totals")
+	assert.Empty(t, result.Discrepancies)
+	assert.Equal(t, result.Stored.TotalAmount, result.Computed.TotalAmount)
+	assert.Zero(t, result.Computed.DiscountAmount)
+	require.Len(t, result.Items, 1)
+
+	// Tamper with a line and the total as a bad migration would
+	require.NoError(t, db.Model(&models.OrderItem{}).Where("order_id = ?", order.ID).
+		Update("total_price", order.Items[0].TotalPrice+1).Error)
+	require.NoError(t, db.Model(&models.Order{}).Where("id = ?", order.ID).
+		Update("total_amount", order.TotalAmount-0.01).Error)
+
+	result, err = orders.RecalculateOrder(ctx, order.ID)
+	require.NoError(t, err)
+	assert.False(t, result.Balanced)
+	fields := map[string]services.TotalsDiscrepancy{}
+	for _, discrepancy := range result.Discrepancies {
+		fields[discrepancy.Field] = discrepancy
+	}
+	assert.Contains(t, fields, "item_total")
+	assert.Equal(t, -0.01, fields["total_amount"].Difference)
+	assert.NotContains(t, fields, "subtotal", "the subtotal is rebuilt from quantity and unit price")
+
+	var stored models.Order
+	require.NoError(t, db.First(&stored, "id = ?", order.ID).Error)
+	assert.InDelta(t, order.TotalAmount-0.01, stored.TotalAmount, 0.001, "recalculating never changes the order")
+}
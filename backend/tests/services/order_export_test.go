@@ -0,0 +1,156 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"chat-ecommerce-backend/tests/testutil/factories"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrderExport_PushesPaidOrdersAndRetries(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	ctx := context.Background()
+
+	var failing atomic.Bool
+	failing.Store(true)
+	received := make(chan services.ExportedOrder, 1)
+	erp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() {
+			http.Error(w, "ERP is down", http.StatusServiceUnavailable)
+			return
+		}
+		assert.Equal(t, "Bearer erp-token", r.Header.Get("Authorization"))
+		body, _ := io.ReadAll(r.Body)
+		var order services.ExportedOrder
+		assert.NoError(t, json.Unmarshal(body, &order))
+		received <- order
+		w.Write([]byte(`{"id":"ERP-42"}`))
+	}))
+	defer erp.Close()
+
+	exports := services.NewOrderExportService(db, services.OrderExportConfig{
+		Transport:     services.OrderExportTransportHTTP,
+		URL:           erp.URL,
+		Token:         "erp-token",
+		MaxAttempts:   2,
+		RetryInterval: time.Minute,
+	})
+	orders := services.NewOrderService(db).WithOrderExports(exports)
+
+	product := f.Product(func(p *models.Product) { p.SKU = "LAMP-1"; p.Price = 40 })
+	order := f.Order(f.User(), []factories.OrderLine{{Product: product, Quantity: 2}})
+
+	// Unpaid orders aren't exported
+	_, err := exports.GetExport(ctx, order.ID)
+	assert.ErrorIs(t, err, services.ErrOrderExportNotFound)
+	_, err = exports.Reexport(ctx, order.ID)
+	assert.ErrorIs(t, err, services.ErrOrderNotExportable)
+
+	_, err = orders.UpdatePaymentStatus(ctx, order.ID, "paid", "pi_1")
+	require.NoError(t, err)
+	export, err := exports.GetExport(ctx, order.ID)
+	require.NoError(t, err)
+	assert.Equal(t, services.OrderExportPending, export.Status)
+
+	// A failed push is retried after the backoff
+	now := time.Now()
+	sweep, err := exports.RunDueExports(ctx, now)
+	require.NoError(t, err)
+	assert.Equal(t, 1, sweep.Attempted)
+	export, err = exports.GetExport(ctx, order.ID)
+	require.NoError(t, err)
+	assert.Equal(t, services.OrderExportPending, export.Status)
+	assert.Equal(t, 1, export.Attempts)
+	assert.Contains(t, export.LastError, "503")
+	require.NotNil(t, export.NextAttemptAt)
+	assert.WithinDuration(t, now.Add(time.Minute), *export.NextAttemptAt, time.Second)
+
+	sweep, err = exports.RunDueExports(ctx, now)
+	require.NoError(t, err)
+	assert.Zero(t, sweep.Attempted, "not due yet")
+
+	// Running out of attempts fails the export
+	sweep, err = exports.RunDueExports(ctx, now.Add(2*time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, 1, sweep.Failed)
+	failed, err := exports.ListExports(ctx, services.OrderExportFailed, 10)
+	require.NoError(t, err)
+	require.Len(t, failed, 1)
+	assert.Nil(t, failed[0].NextAttemptAt)
+
+	// Admins export it again once the ERP is back
+	failing.Store(false)
+	export, err = exports.Reexport(ctx, order.ID)
+	require.NoError(t, err)
+	assert.Equal(t, services.OrderExportExported, export.Status)
+	assert.Equal(t, 1, export.Attempts)
+	assert.Equal(t, "ERP-42", export.Reference)
+	assert.NotNil(t, export.ExportedAt)
+	sent := <-received
+	assert.Equal(t, order.OrderNumber, sent.OrderNumber)
+	require.Len(t, sent.Lines, 1)
+	assert.Equal(t, "LAMP-1", sent.Lines[0].SKU)
+	assert.Equal(t, 2, sent.Lines[0].Quantity)
+	assert.Contains(t, string(sent.ShippingAddress), "Testville")
+}
+
+func TestOrderExport_WritesCSVFiles(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	exports := services.NewOrderExportService(db, services.OrderExportConfig{
+		Transport:   services.OrderExportTransportDirectory,
+		Format:      services.OrderExportFormatCSV,
+		Directory:   dir,
+		MaxAttempts: 3,
+	})
+	product := f.Product(func(p *models.Product) { p.SKU = "MUG-1"; p.Price = 12.5 })
+	order := f.Order(f.User(), []factories.OrderLine{{Product: product, Quantity: 3}}, func(o *models.Order) { o.PaymentStatus = "paid" })
+
+	export, err := exports.Reexport(ctx, order.ID)
+	require.NoError(t, err)
+	assert.Equal(t, services.OrderExportExported, export.Status)
+	assert.Equal(t, services.OrderExportFormatCSV, export.Format)
+
+	path := filepath.Join(dir, "order-"+order.OrderNumber+".csv")
+	assert.Equal(t, path, export.Reference)
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	require.Len(t, lines, 2)
+	assert.True(t, strings.HasPrefix(lines[0], "order_number,"))
+	assert.Contains(t, lines[1], "MUG-1")
+	assert.Contains(t, lines[1], ",3,12.50,37.50,")
+}
+
+func TestOrderExport_Disabled(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	ctx := context.Background()
+
+	exports := services.NewOrderExportService(db, services.OrderExportConfig{})
+	order := f.Order(f.User(), nil, func(o *models.Order) { o.PaymentStatus = "paid" })
+
+	require.NoError(t, exports.Enqueue(ctx, order.ID))
+	_, err := exports.GetExport(ctx, order.ID)
+	assert.ErrorIs(t, err, services.ErrOrderExportNotFound)
+	_, err = exports.Reexport(ctx, order.ID)
+	assert.ErrorIs(t, err, services.ErrOrderExportDisabled)
+}
@@ -0,0 +1,256 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"chat-ecommerce-backend/tests/testutil/factories"
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pipelineEvents records the submission progress sent to shoppers and the
+// messages sent to their chat sessions
+type pipelineEvents struct {
+	mu       sync.Mutex
+	stages   []string
+	sessions []string
+}
+
+func (e *pipelineEvents) NotifyUser(userID uuid.UUID, messageType string, data interface{}) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if messageType == services.OrderSubmissionMessage {
+		submission := data.(*models.OrderSubmission)
+		e.stages = append(e.stages, submission.Status+":"+submission.Stage)
+	}
+}
+
+func (e *pipelineEvents) NotifySession(sessionID, messageType string, data interface{}) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.sessions = append(e.sessions, messageType)
+}
+
+func orderPipeline(t *testing.T, f *factories.Factory, events *pipelineEvents) (*services.OrderPipeline, *services.OrderService) {
+	t.Helper()
+	db := f.DB()
+	orders := services.NewOrderService(db)
+	payments := services.NewPaymentServiceWithRegistry(services.NewPaymentRegistry(services.PaymentProviderMock, nil, services.NewMockPaymentProvider()))
+	config := services.OrderPipelineConfig{Workers: 1, MaxAttempts: 3, RetryInterval: time.Second}
+	return services.NewOrderPipeline(db, orders, payments, config).WithNotifier(events), orders
+}
+
+func pipelineOrder(user *models.User, product *models.Product, quantity int) *services.CreateOrderRequest {
+	return &services.CreateOrderRequest{
+		UserID:          user.ID,
+		SessionID:       "pipeline-session",
+		Items:           []services.OrderItemRequest{{ProductID: product.ID, Quantity: quantity}},
+		ShippingAddress: map[string]interface{}{"country": "US", "city": "Springfield"},
+		BillingAddress:  map[string]interface{}{"country": "US"},
+		PaymentMethod:   "card",
+	}
+}
+
+func TestOrderPipeline_AcceptsThenCompletesOrder(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	ctx := context.Background()
+	events := &pipelineEvents{}
+	pipeline, orders := orderPipeline(t, f, events)
+	user := f.User()
+	product := f.StockedProduct(5)
+
+	submission, err := pipeline.Submit(ctx, pipelineOrder(user, product, 2), "", time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, services.OrderSubmissionAccepted, submission.Status)
+	assert.Nil(t, submission.OrderID, "nothing heavy happens before answering")
+
+	processed, err := pipeline.Process(ctx, submission.ID, time.Now())
+	require.NoError(t, err)
+	require.NotNil(t, processed)
+	assert.Equal(t, services.OrderSubmissionCompleted, processed.Status, processed.Error)
+	require.NotNil(t, processed.OrderID)
+	assert.NotEmpty(t, processed.PaymentIntentID)
+	assert.Equal(t, services.PaymentProviderMock, processed.PaymentProvider)
+
+	order, err := orders.GetOrderByID(ctx, *processed.OrderID)
+	require.NoError(t, err)
+	assert.Equal(t, processed.PaymentIntentID, order.PaymentIntentID)
+	assert.Equal(t, "processing", order.PaymentStatus)
+	var inventory models.Inventory
+	require.NoError(t, db.Where("product_id = ?", product.ID).First(&inventory).Error)
+	assert.Equal(t, 2, inventory.QuantityReserved)
+
+	var email models.OutboundMessage
+	require.NoError(t, db.Where("user_id = ?", user.ID).First(&email).Error)
+	assert.Equal(t, services.MessageChannelEmail, email.Channel)
+	assert.Contains(t, email.Subject, order.OrderNumber)
+
+	assert.Equal(t, []string{
+		"processing:" + services.OrderStageReserving,
+		"processing:" + services.OrderStageReserving, // the order was created
+		"processing:" + services.OrderStagePayment,
+		"processing:" + services.OrderStageNotifying,
+		"completed:",
+	}, events.stages)
+	assert.Contains(t, events.sessions, services.OrderConfirmationMessage)
+
+	stored, err := pipeline.GetSubmission(ctx, submission.ID, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, services.OrderSubmissionCompleted, stored.Status)
+	_, err = pipeline.GetSubmission(ctx, submission.ID, f.User().ID)
+	assert.ErrorIs(t, err, services.ErrOrderSubmissionNotFound, "only the shopper sees their submission")
+
+	again, err := pipeline.Process(ctx, submission.ID, time.Now())
+	require.NoError(t, err)
+	assert.Nil(t, again, "completed submissions aren't processed twice")
+}
+
+func TestOrderPipeline_FailsSoldOutOrder(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	ctx := context.Background()
+	pipeline, _ := orderPipeline(t, f, &pipelineEvents{})
+	user := f.User()
+	product := f.StockedProduct(1)
+
+	submission, err := pipeline.Submit(ctx, pipelineOrder(user, product, 3), "", time.Now())
+	require.NoError(t, err)
+
+	processed, err := pipeline.RunDue(ctx, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, 1, processed, "the sweep picks up accepted submissions the queue missed")
+
+	failed, err := pipeline.GetSubmission(ctx, submission.ID, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, services.OrderSubmissionFailed, failed.Status)
+	assert.Equal(t, services.OrderStageReserving, failed.Stage)
+	assert.NotEmpty(t, failed.Error)
+	assert.Nil(t, failed.OrderID)
+	assert.NotNil(t, failed.FinishedAt)
+
+	var count int64
+	db.Model(&models.Order{}).Count(&count)
+	assert.Zero(t, count)
+	processed, err = pipeline.RunDue(ctx, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.Zero(t, processed, "orders the shopper has to fix aren't retried")
+}
+
+func TestOrderPipeline_PlacesEachCheckoutOnce(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	ctx := context.Background()
+	pipeline, orders := orderPipeline(t, f, &pipelineEvents{})
+	user := f.User()
+	product := f.StockedProduct(5)
+
+	submission, err := pipeline.Submit(ctx, pipelineOrder(user, product, 1), "checkout-1", time.Now())
+	require.NoError(t, err)
+	again, err := pipeline.Submit(ctx, pipelineOrder(user, product, 1), "checkout-1", time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, submission.ID, again.ID, "a checkout sent twice is accepted once")
+	other, err := pipeline.Submit(ctx, pipelineOrder(f.User(), product, 1), "checkout-1", time.Now())
+	require.NoError(t, err)
+	assert.NotEqual(t, submission.ID, other.ID, "keys are per shopper")
+
+	// A worker that went away after placing the order, before recording it
+	req := pipelineOrder(user, product, 1)
+	req.SubmissionID = &submission.ID
+	placed, err := orders.CreateOrder(ctx, req)
+	require.NoError(t, err)
+
+	processed, err := pipeline.Process(ctx, submission.ID, time.Now())
+	require.NoError(t, err)
+	require.NotNil(t, processed)
+	assert.Equal(t, services.OrderSubmissionCompleted, processed.Status, processed.Error)
+	require.NotNil(t, processed.OrderID)
+	assert.Equal(t, placed.ID, *processed.OrderID)
+
+	var count int64
+	db.Model(&models.Order{}).Where("user_id = ?", user.ID).Count(&count)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestOrderPipeline_PlacesAdmittedCheckoutOnAnotherInstance(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	ctx := context.Background()
+	payments := services.NewPaymentServiceWithRegistry(services.NewPaymentRegistry(services.PaymentProviderMock, nil, services.NewMockPaymentProvider()))
+	config := services.OrderPipelineConfig{Workers: 1, MaxAttempts: 3, RetryInterval: time.Second}
+	instance := func() (*services.OrderPipeline, *services.FlashSaleService) {
+		sales := services.NewFlashSaleService(db, flashSaleConfig())
+		return services.NewOrderPipeline(db, services.NewOrderService(db).WithFlashSales(sales), payments, config), sales
+	}
+	accepting, sales := instance()
+	user := f.User()
+	product := f.StockedProduct(5)
+
+	_, err := sales.Plan(ctx, services.FlashSaleRequest{
+		Name:            "Sneaker drop",
+		ProductIDs:      []uuid.UUID{product.ID},
+		StartsAt:        time.Now().Add(-time.Second),
+		DurationMinutes: 60,
+		PerUserLimit:    1,
+		AdmitPerMinute:  1,
+	}, nil, time.Now())
+	require.NoError(t, err)
+	_, err = accepting.Submit(ctx, pipelineOrder(user, product, 1), "", time.Now())
+	var waitErr *services.WaitingRoomError
+	require.ErrorAs(t, err, &waitErr, "shoppers must join the waiting room first")
+	ticket, err := sales.Join(ctx, "", &user.ID, time.Now())
+	require.NoError(t, err)
+	require.True(t, ticket.Admitted)
+	submission, err := accepting.Submit(ctx, pipelineOrder(user, product, 1), "", time.Now())
+	require.NoError(t, err)
+	require.NotNil(t, submission.FlashSaleID)
+
+	// The sweep on an instance whose waiting room never saw the shopper, after
+	// the admission ran out
+	processing, _ := instance()
+	processed, err := processing.Process(ctx, submission.ID, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	require.NotNil(t, processed)
+	assert.Equal(t, services.OrderSubmissionCompleted, processed.Status, processed.Error)
+	require.NotNil(t, processed.OrderID)
+}
+
+func TestOrderPipeline_RetriesReuseIntentAndEmail(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	ctx := context.Background()
+	pipeline, _ := orderPipeline(t, f, &pipelineEvents{})
+	user := f.User()
+	product := f.StockedProduct(5)
+
+	submission, err := pipeline.Submit(ctx, pipelineOrder(user, product, 1), "", time.Now())
+	require.NoError(t, err)
+	processed, err := pipeline.Process(ctx, submission.ID, time.Now())
+	require.NoError(t, err)
+	require.Equal(t, services.OrderSubmissionCompleted, processed.Status, processed.Error)
+	intentID := processed.PaymentIntentID
+
+	// A worker that went away after creating the intent and sending the email,
+	// before recording either
+	require.NoError(t, db.Model(&models.Order{}).Where("id = ?", *processed.OrderID).
+		Updates(map[string]interface{}{"payment_intent_id": "", "payment_status": "pending"}).Error)
+	require.NoError(t, db.Model(&models.OrderSubmission{}).Where("id = ?", submission.ID).
+		Updates(map[string]interface{}{"status": services.OrderSubmissionProcessing, "payment_intent_id": "", "finished_at": nil}).Error)
+
+	retried, err := pipeline.Process(ctx, submission.ID, time.Now())
+	require.NoError(t, err)
+	require.NotNil(t, retried)
+	assert.Equal(t, services.OrderSubmissionCompleted, retried.Status, retried.Error)
+	assert.Equal(t, intentID, retried.PaymentIntentID, "the provider answers the retry with the same intent")
+
+	var emails int64
+	db.Model(&models.OutboundMessage{}).Where("user_id = ?", user.ID).Count(&emails)
+	assert.Equal(t, int64(1), emails, "the confirmation is sent once")
+}
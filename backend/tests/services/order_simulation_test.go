@@ -0,0 +1,90 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"chat-ecommerce-backend/tests/testutil/factories"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrderService_SimulateOrder(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	ctx := context.Background()
+	rules := services.NewPricingRuleService(db, services.PricingRuleConfig{})
+	orders := services.NewOrderService(db).WithPricingRules(rules)
+
+	lamp := f.StockedProduct(5, func(p *models.Product) { p.Name = "Desk Lamp"; p.Price = 100 })
+	mug := f.StockedProduct(1, func(p *models.Product) { p.Name = "Mug"; p.Price = 50 })
+	_, err := services.NewCustomerGroupService(db).UpsertGroup(ctx, "wholesale", services.CustomerGroupRequest{Name: "Wholesale", AdjustmentPercent: -20})
+	require.NoError(t, err)
+	_, err = rules.CreateRule(ctx, nil, services.PricingRuleRequest{
+		Name: "Lunch deal", Type: services.PricingRuleTimeOfDay, DiscountPercent: 10, StartTime: "12:00", EndTime: "13:00",
+	})
+	require.NoError(t, err)
+	_, err = services.NewOrderValidator(db).CreateRule(ctx, services.OrderRuleRequest{Type: services.OrderRuleMinOrderTotal, MinTotal: 500})
+	require.NoError(t, err)
+
+	lunch := time.Date(2026, 3, 2, 12, 30, 0, 0, services.StoreLocationFromEnv())
+	items := []services.OrderItemRequest{{ProductID: lamp.ID, Quantity: 2}, {ProductID: mug.ID, Quantity: 3}}
+
+	simulation, err := orders.SimulateOrder(ctx, &services.OrderSimulationRequest{
+		Items: items, ShippingCountry: "us", GiftWrap: true, At: &lunch,
+	})
+	require.NoError(t, err)
+	assert.Nil(t, simulation.CustomerGroup)
+	assert.False(t, simulation.SignedIn)
+	require.Len(t, simulation.Lines, 2)
+	assert.Equal(t, 90.0, simulation.Lines[0].UnitPrice)
+	assert.Equal(t, 180.0, simulation.Lines[0].TotalPrice)
+	require.Len(t, simulation.Lines[0].Adjustments, 1)
+	assert.Equal(t, "Lunch deal", simulation.Lines[0].Adjustments[0].Name)
+	assert.Empty(t, simulation.Lines[0].StockIssue)
+	assert.Equal(t, 1, simulation.Lines[1].InStock)
+	assert.NotEmpty(t, simulation.Lines[1].StockIssue, "short stock is reported, not refused")
+
+	assert.Equal(t, 350.0, simulation.ListSubtotal)
+	assert.Equal(t, 315.0, simulation.Subtotal)
+	assert.Equal(t, 35.0, simulation.DiscountAmount)
+	assert.Equal(t, "US", simulation.ShippingCountry)
+	assert.Equal(t, 25.2, simulation.Tax.Tax)
+	assert.Equal(t, 4.99, simulation.GiftWrapAmount)
+	assert.Equal(t, 355.18, simulation.TotalAmount)
+	require.Len(t, simulation.Violations, 1)
+	assert.Equal(t, services.ViolationMinOrderTotal, simulation.Violations[0].Code)
+
+	// A group's members, outside the rule's hours
+	afternoon := lunch.Add(2 * time.Hour)
+	simulation, err = orders.SimulateOrder(ctx, &services.OrderSimulationRequest{
+		Items: items[:1], CustomerGroup: "wholesale", At: &afternoon,
+	})
+	require.NoError(t, err)
+	require.NotNil(t, simulation.CustomerGroup)
+	assert.True(t, simulation.SignedIn)
+	assert.Equal(t, 80.0, simulation.Lines[0].UnitPrice)
+	require.Len(t, simulation.Lines[0].Adjustments, 1)
+	assert.Equal(t, services.PriceSourceCustomerGroup, simulation.Lines[0].Adjustments[0].Source)
+
+	// Nothing is created or reserved
+	var count int64
+	require.NoError(t, db.Model(&models.Order{}).Count(&count).Error)
+	assert.Zero(t, count)
+	var inventory models.Inventory
+	require.NoError(t, db.Where("product_id = ?", lamp.ID).First(&inventory).Error)
+	assert.Equal(t, 5, inventory.QuantityAvailable)
+
+	_, err = orders.SimulateOrder(ctx, &services.OrderSimulationRequest{Items: items, CustomerGroup: "missing"})
+	assert.ErrorIs(t, err, services.ErrCustomerGroupNotFound)
+	missing := uuid.New()
+	_, err = orders.SimulateOrder(ctx, &services.OrderSimulationRequest{Items: []services.OrderItemRequest{{ProductID: missing, Quantity: 1}}})
+	assert.ErrorIs(t, err, services.ErrInvalidOrderSimulation)
+	_, err = orders.SimulateOrder(ctx, &services.OrderSimulationRequest{Items: items, UserID: &missing})
+	assert.ErrorIs(t, err, services.ErrInvalidOrderSimulation)
+}
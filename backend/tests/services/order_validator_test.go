@@ -0,0 +1,113 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"chat-ecommerce-backend/tests/testutil/factories"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func violationCodes(t *testing.T, err error) []string {
+	var validationErr *services.OrderValidationError
+	require.True(t, errors.As(err, &validationErr), "expected an order validation error, got %v", err)
+	codes := []string{}
+	for _, violation := range validationErr.Violations {
+		codes = append(codes, violation.Code)
+	}
+	return codes
+}
+
+func TestOrderValidator_Validate(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	validator := services.NewOrderValidator(db)
+	ctx := context.Background()
+
+	beer := f.Product()
+	battery := f.Product()
+
+	_, err := validator.CreateRule(ctx, services.OrderRuleRequest{Type: services.OrderRuleMinOrderTotal, MinTotal: 25})
+	require.NoError(t, err)
+	_, err = validator.CreateRule(ctx, services.OrderRuleRequest{Type: services.OrderRuleQuantityMultiple, ProductID: &beer.ID, Multiple: 6})
+	require.NoError(t, err)
+	_, err = validator.CreateRule(ctx, services.OrderRuleRequest{Type: services.OrderRuleRestrictedCountries, ProductID: &battery.ID, Countries: []string{"au", "NZ"}})
+	require.NoError(t, err)
+
+	err = validator.Validate(ctx, services.OrderValidationInput{
+		Items:           []services.OrderItemRequest{{ProductID: beer.ID, Quantity: 4}, {ProductID: battery.ID, Quantity: 1}},
+		Subtotal:        20,
+		ShippingCountry: "AU",
+	})
+	assert.ElementsMatch(t, []string{
+		services.ViolationMinOrderTotal,
+		services.ViolationQuantityMultiple,
+		services.ViolationShippingRestricted,
+	}, violationCodes(t, err))
+
+	err = validator.Validate(ctx, services.OrderValidationInput{
+		Items:           []services.OrderItemRequest{{ProductID: beer.ID, Quantity: 12}, {ProductID: battery.ID, Quantity: 1}},
+		Subtotal:        60,
+		ShippingCountry: "US",
+	})
+	assert.NoError(t, err)
+
+	// Product rules only apply when the product is ordered
+	err = validator.Validate(ctx, services.OrderValidationInput{
+		Items:           []services.OrderItemRequest{{ProductID: f.Product().ID, Quantity: 1}},
+		Subtotal:        30,
+		ShippingCountry: "AU",
+	})
+	assert.NoError(t, err)
+}
+
+func TestOrderService_CreateOrder_RejectsViolations(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	ctx := context.Background()
+
+	_, err := services.NewOrderValidator(db).CreateRule(ctx, services.OrderRuleRequest{
+		Type:     services.OrderRuleMinOrderTotal,
+		MinTotal: 100,
+		Message:  "Wholesale orders start at 100",
+	})
+	require.NoError(t, err)
+
+	buyer := f.User()
+	product := f.StockedProduct(10, func(p *models.Product) { p.Price = 30 })
+
+	_, err = services.NewOrderService(db).CreateOrder(ctx, &services.CreateOrderRequest{
+		UserID:          buyer.ID,
+		SessionID:       "rules",
+		Items:           []services.OrderItemRequest{{ProductID: product.ID, Quantity: 2}},
+		ShippingAddress: map[string]interface{}{"country": "US"},
+		BillingAddress:  map[string]interface{}{"country": "US"},
+		PaymentMethod:   "card",
+	})
+	assert.Equal(t, []string{services.ViolationMinOrderTotal}, violationCodes(t, err))
+	assert.Contains(t, err.Error(), "Wholesale orders start at 100")
+
+	var orders int64
+	db.Model(&models.Order{}).Count(&orders)
+	assert.Zero(t, orders)
+}
+
+func TestOrderValidator_CreateRule_RejectsInvalidRules(t *testing.T) {
+	validator := services.NewOrderValidator(testutil.NewTestDB(t))
+	ctx := context.Background()
+
+	for name, req := range map[string]services.OrderRuleRequest{
+		"unknown type":          {Type: "max_weight"},
+		"zero minimum":          {Type: services.OrderRuleMinOrderTotal},
+		"multiple of one":       {Type: services.OrderRuleQuantityMultiple, Multiple: 1},
+		"countries without sku": {Type: services.OrderRuleRestrictedCountries, Countries: []string{"AU"}},
+	} {
+		_, err := validator.CreateRule(ctx, req)
+		assert.Error(t, err, name)
+	}
+}
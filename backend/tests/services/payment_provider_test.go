@@ -0,0 +1,89 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/services"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// eurOnlyProvider is a mock provider that only accepts euros
+type eurOnlyProvider struct {
+	*services.MockPaymentProvider
+}
+
+func (p eurOnlyProvider) Name() string { return "eur_only" }
+
+func (p eurOnlyProvider) Capabilities() services.PaymentCapabilities {
+	capabilities := p.MockPaymentProvider.Capabilities()
+	capabilities.Currencies = []string{"eur"}
+	capabilities.Methods = []services.PaymentMethodInfo{{ID: "sepa", Name: "SEPA", Provider: "eur_only", Enabled: true}}
+	return capabilities
+}
+
+func TestPaymentRegistry_Route(t *testing.T) {
+	mock := services.NewMockPaymentProvider()
+	eur := eurOnlyProvider{services.NewMockPaymentProvider()}
+	registry := services.NewPaymentRegistry(services.PaymentProviderMock, map[string]string{"EUR": "eur_only"}, mock, eur)
+
+	provider, err := registry.Route("usd", "")
+	require.NoError(t, err)
+	assert.Equal(t, services.PaymentProviderMock, provider.Name())
+
+	provider, err = registry.Route("eur", "")
+	require.NoError(t, err)
+	assert.Equal(t, "eur_only", provider.Name(), "currency routes win over the default")
+
+	provider, err = registry.Route("eur", services.PaymentProviderMock)
+	require.NoError(t, err)
+	assert.Equal(t, services.PaymentProviderMock, provider.Name(), "an explicit provider wins over currency routes")
+
+	_, err = registry.Route("usd", "eur_only")
+	assert.Error(t, err, "a requested provider must accept the currency")
+
+	_, err = registry.Route("usd", services.PaymentProviderPayPal)
+	assert.Error(t, err, "a requested provider must be enabled")
+
+	ids := func(methods []services.PaymentMethodInfo) []string {
+		var result []string
+		for _, method := range methods {
+			result = append(result, method.ID)
+		}
+		return result
+	}
+	assert.Equal(t, []string{"mock", "apple_pay", "google_pay", "sepa"}, ids(registry.Methods("eur")))
+	assert.Equal(t, []string{"mock", "apple_pay", "google_pay"}, ids(registry.Methods("usd")), "the mock provider simulates wallets too")
+}
+
+func TestPaymentService_MockProvider(t *testing.T) {
+	mock := services.NewMockPaymentProvider()
+	payments := services.NewPaymentServiceWithRegistry(services.NewPaymentRegistry(services.PaymentProviderMock, nil, mock))
+	orderID := uuid.New()
+
+	intent, err := payments.CreatePaymentIntent(&services.CreatePaymentIntentRequest{OrderID: orderID, Amount: 2500, Currency: "usd"})
+	require.NoError(t, err)
+	assert.Equal(t, services.PaymentProviderMock, intent.Provider)
+
+	_, err = payments.ConfirmPayment(intent.Provider, &services.ConfirmPaymentRequest{PaymentIntentID: intent.ID, OrderID: uuid.New()})
+	assert.Error(t, err, "payments can't be confirmed for another order")
+
+	mock.FailOrder(orderID, true)
+	status, err := payments.ConfirmPayment(intent.Provider, &services.ConfirmPaymentRequest{PaymentIntentID: intent.ID, OrderID: orderID})
+	require.NoError(t, err)
+	assert.Equal(t, "requires_payment_method", status.Status)
+
+	mock.FailOrder(orderID, false)
+	status, err = payments.RetryPayment(intent.Provider, intent.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "succeeded", status.Status)
+
+	_, err = payments.RefundPayment(intent.Provider, intent.ID, 1000, "requested_by_customer")
+	require.NoError(t, err)
+	_, err = payments.RefundPayment(intent.Provider, intent.ID, 2000, "requested_by_customer")
+	assert.Error(t, err, "refunds can't exceed the payment")
+
+	_, err = payments.CancelPaymentIntent(intent.Provider, intent.ID)
+	assert.Error(t, err, "a successful payment can't be cancelled")
+}
@@ -0,0 +1,105 @@
+package services
+
+import (
+	"bytes"
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/pkg/encryption"
+	"chat-ecommerce-backend/tests/testutil"
+	"chat-ecommerce-backend/tests/testutil/factories"
+	"context"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testKey returns a keyring entry for a 32 byte key filled with b
+func testKey(id string, b byte) string {
+	return id + ":" + base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, 32))
+}
+
+func TestPIIEncryption_Rekey(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	ctx := context.Background()
+	f := factories.New(t, db)
+	models.SetPIIKeyring(nil)
+	t.Cleanup(func() { models.SetPIIKeyring(nil) })
+
+	rawColumn := func(table, column, id string) string {
+		var value string
+		require.NoError(t, db.Table(table).Select(column).Where("id = ?", id).Scan(&value).Error)
+		return value
+	}
+
+	// Rows written before encryption was turned on are plain text
+	birthday := time.Date(1990, 5, 17, 0, 0, 0, 0, time.UTC)
+	user := f.User(func(u *models.User) {
+		u.Phone = "+15555550123"
+		u.DateOfBirth = &birthday
+	})
+	order := f.Order(user, nil)
+	assert.Equal(t, "+15555550123", rawColumn("users", "phone", user.ID.String()))
+
+	v1, err := encryption.NewKeyring(testKey("v1", 1))
+	require.NoError(t, err)
+	models.SetPIIKeyring(v1)
+
+	results, err := services.NewPIIRekeyService(db, 1).Rekey(ctx)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, services.PIIRekeyResult{Table: "users", Scanned: 1, Rekeyed: 1}, results[0])
+	assert.Equal(t, services.PIIRekeyResult{Table: "orders", Scanned: 1, Rekeyed: 1}, results[1])
+
+	phone := rawColumn("users", "phone", user.ID.String())
+	assert.True(t, strings.HasPrefix(phone, "pii:v1:v1:"), "phone is encrypted with the current key")
+	assert.NotContains(t, phone, "5555550123")
+	assert.NotContains(t, rawColumn("orders", "shipping_address", order.ID.String()), "Testville")
+
+	var stored models.User
+	require.NoError(t, db.First(&stored, "id = ?", user.ID).Error)
+	assert.Equal(t, "+15555550123", stored.Phone)
+	require.NotNil(t, stored.DateOfBirth)
+	assert.True(t, birthday.Equal(*stored.DateOfBirth))
+
+	var storedOrder models.Order
+	require.NoError(t, db.First(&storedOrder, "id = ?", order.ID).Error)
+	assert.JSONEq(t, string(order.ShippingAddress), string(storedOrder.ShippingAddress))
+
+	// Raw queries into summaries decrypt too
+	list, err := services.NewAdminUserService(db).ListUsers(ctx, services.AdminUserFilters{})
+	require.NoError(t, err)
+	require.Len(t, list.Users, 1)
+	assert.Equal(t, "+15555550123", list.Users[0].Phone)
+
+	// Values are bound to their column and can't be copied to another one
+	_, err = v1.Decrypt(phone, []byte("last_name"))
+	assert.Error(t, err)
+
+	// Rotating: new writes use the new key, old values stay readable until
+	// they are re-encrypted
+	v2, err := encryption.NewKeyring(testKey("v2", 2) + "," + testKey("v1", 1))
+	require.NoError(t, err)
+	models.SetPIIKeyring(v2)
+	assert.True(t, v2.NeedsRotation(phone))
+
+	results, err = services.NewPIIRekeyService(db, 0).Rekey(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, results[0].Rekeyed)
+	assert.True(t, strings.HasPrefix(rawColumn("users", "phone", user.ID.String()), "pii:v1:v2:"))
+
+	results, err = services.NewPIIRekeyService(db, 0).Rekey(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, results[0].Rekeyed, "already current rows are left alone")
+
+	// Once everything is re-encrypted the old key can be dropped
+	onlyV2, err := encryption.NewKeyring(testKey("v2", 2))
+	require.NoError(t, err)
+	models.SetPIIKeyring(onlyV2)
+	stored = models.User{}
+	require.NoError(t, db.First(&stored, "id = ?", user.ID).Error)
+	assert.Equal(t, "+15555550123", stored.Phone)
+}
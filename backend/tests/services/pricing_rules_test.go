@@ -0,0 +1,142 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"chat-ecommerce-backend/tests/testutil/factories"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// clockWindow returns a time_of_day window starting offset from now in the
+// store's timezone and lasting an hour
+func clockWindow(offset time.Duration) (string, string) {
+	start := time.Now().In(services.StoreLocationFromEnv()).Add(offset)
+	return start.Format("15:04"), start.Add(time.Hour).Format("15:04")
+}
+
+func TestCustomerGroupService_PricingRules(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	ctx := context.Background()
+	rules := services.NewPricingRuleService(db, services.PricingRuleConfig{})
+	pricing := services.NewCustomerGroupService(db).WithPricingRules(rules)
+
+	category := f.Category()
+	overstocked := f.StockedProduct(50, func(p *models.Product) { p.Price = 100; p.CategoryID = category.ID })
+	selling := f.StockedProduct(10, func(p *models.Product) { p.Price = 100; p.CategoryID = category.ID })
+	for _, forecast := range []models.DemandForecast{
+		{ID: uuid.New(), ProductID: overstocked.ID, Method: services.ForecastExponentialSmoothing, DailyDemand: 2, QuantityAvailable: 50},
+		{ID: uuid.New(), ProductID: selling.ID, Method: services.ForecastExponentialSmoothing, DailyDemand: 2, QuantityAvailable: 10},
+	} {
+		require.NoError(t, db.Create(&forecast).Error)
+	}
+
+	clearance, err := rules.CreateRule(ctx, nil, services.PricingRuleRequest{
+		Name: "Clear overstock", Type: services.PricingRuleStockClearance, DiscountPercent: 30, Priority: 1, MinDaysOfStock: 20,
+	})
+	require.NoError(t, err)
+	_, err = rules.CreateRule(ctx, nil, services.PricingRuleRequest{
+		Name: "Members save", Type: services.PricingRuleMember, DiscountPercent: 10, Priority: 2,
+	})
+	require.NoError(t, err)
+	start, end := clockWindow(-30 * time.Minute)
+	_, err = rules.CreateRule(ctx, nil, services.PricingRuleRequest{
+		Name: "Happy hour", Type: services.PricingRuleTimeOfDay, DiscountPercent: 5, Priority: 3,
+		CategoryID: &category.ID, StartTime: start, EndTime: end,
+	})
+	require.NoError(t, err)
+	start, end = clockWindow(2 * time.Hour)
+	_, err = rules.CreateRule(ctx, nil, services.PricingRuleRequest{
+		Name: "Later", Type: services.PricingRuleTimeOfDay, DiscountPercent: 50, StartTime: start, EndTime: end,
+	})
+	require.NoError(t, err)
+
+	price := func(userID *uuid.UUID, product *models.Product) models.Product {
+		products := []models.Product{*product}
+		require.NoError(t, pricing.PriceProducts(ctx, userID, products))
+		return products[0]
+	}
+
+	// 50 units at 2 a day last 25 days, past the clearance threshold;
+	// discounts compound in priority order
+	guest := price(nil, overstocked)
+	assert.Equal(t, 66.5, guest.Price)
+	require.NotNil(t, guest.ListPrice)
+	assert.Equal(t, 100.0, *guest.ListPrice)
+	require.Len(t, guest.PriceAdjustments, 2)
+	assert.Equal(t, "Clear overstock", guest.PriceAdjustments[0].Name)
+	assert.Equal(t, 100.0, guest.PriceAdjustments[0].PriceBefore)
+	assert.Equal(t, 70.0, guest.PriceAdjustments[0].PriceAfter)
+	assert.Equal(t, services.PricingRuleTimeOfDay, guest.PriceAdjustments[1].Type)
+
+	assert.Equal(t, 95.0, price(nil, selling).Price, "5 days of stock isn't cleared")
+
+	user := f.User()
+	member := price(&user.ID, overstocked)
+	assert.Equal(t, 59.85, member.Price)
+	assert.Len(t, member.PriceAdjustments, 3)
+
+	// An exclusive rule is the last to apply
+	_, err = rules.UpdateRule(ctx, clearance.ID, services.PricingRuleRequest{
+		Name: "Clear overstock", Type: services.PricingRuleStockClearance, DiscountPercent: 30, Priority: 1, MinDaysOfStock: 20, Exclusive: true,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 70.0, price(&user.ID, overstocked).Price)
+
+	// Orders record the adjustments each line was priced with
+	order, err := services.NewOrderService(db).WithPricingRules(rules).CreateOrder(ctx, &services.CreateOrderRequest{
+		UserID:          user.ID,
+		SessionID:       "pricing-rules",
+		Items:           []services.OrderItemRequest{{ProductID: overstocked.ID, Quantity: 2}},
+		ShippingAddress: map[string]interface{}{"country": "US"},
+		BillingAddress:  map[string]interface{}{"country": "US"},
+	})
+	require.NoError(t, err)
+	require.Len(t, order.Items, 1)
+	assert.Equal(t, 70.0, order.Items[0].UnitPrice)
+	var recorded []models.PriceAdjustment
+	require.NoError(t, json.Unmarshal(order.Items[0].PriceAdjustments, &recorded))
+	require.Len(t, recorded, 1)
+	assert.Equal(t, clearance.ID, *recorded[0].ID)
+	assert.Equal(t, services.PriceSourcePricingRule, recorded[0].Source)
+}
+
+func TestPricingRuleService_Validation(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	ctx := context.Background()
+	rules := services.NewPricingRuleService(db, services.PricingRuleConfig{})
+
+	missing := uuid.New()
+	for name, req := range map[string]services.PricingRuleRequest{
+		"bad clock":       {Name: "Lunch", Type: services.PricingRuleTimeOfDay, DiscountPercent: 10, StartTime: "noon", EndTime: "13:00"},
+		"empty window":    {Name: "Lunch", Type: services.PricingRuleTimeOfDay, DiscountPercent: 10, StartTime: "12:00", EndTime: "12:00"},
+		"no threshold":    {Name: "Clearance", Type: services.PricingRuleStockClearance, DiscountPercent: 10},
+		"unknown group":   {Name: "VIP", Type: services.PricingRuleMember, DiscountPercent: 10, CustomerGroupID: &missing},
+		"unknown product": {Name: "VIP", Type: services.PricingRuleMember, DiscountPercent: 10, ProductID: &missing},
+	} {
+		_, err := rules.CreateRule(ctx, nil, req)
+		assert.ErrorIs(t, err, services.ErrInvalidPricingRule, name)
+	}
+
+	inactive := false
+	rule, err := rules.CreateRule(ctx, nil, services.PricingRuleRequest{
+		Name: "Night owls", Type: services.PricingRuleTimeOfDay, DiscountPercent: 10, StartTime: "22:00", EndTime: "02:00", Weekdays: []int{5, 6}, IsActive: &inactive,
+	})
+	require.NoError(t, err)
+	assert.False(t, rule.IsActive)
+	stored, err := rules.GetRule(ctx, rule.ID)
+	require.NoError(t, err)
+	assert.False(t, stored.IsActive)
+	assert.JSONEq(t, `[5, 6]`, string(stored.Weekdays))
+
+	require.NoError(t, rules.DeleteRule(ctx, rule.ID))
+	assert.ErrorIs(t, rules.DeleteRule(ctx, rule.ID), services.ErrPricingRuleNotFound)
+}
@@ -0,0 +1,91 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"chat-ecommerce-backend/tests/testutil/factories"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func shoeEmbeddings() *services.FakeEmbeddings {
+	return services.NewFakeEmbeddings(
+		[]string{"sneakers", "running", "shoes", "trainers"},
+		[]string{"lamp", "light", "lighting"},
+	)
+}
+
+func TestProductEmbeddings_IndexProducts(t *testing.T) {
+	db := testutil.NewTestDB(t, append(testutil.DefaultModels(), &models.ProductEmbedding{})...)
+	f := factories.New(t, db)
+	ctx := context.Background()
+	fake := shoeEmbeddings()
+	embeddings := services.NewProductEmbeddingService(db, fake, services.EmbeddingConfig{})
+
+	runner := f.Product(func(p *models.Product) { p.Name = "Trail Runner"; p.Description = "Lightweight running shoes" })
+	f.Product(func(p *models.Product) { p.Name = "Desk Lamp"; p.Description = "LED lighting for your desk" })
+	f.Product(func(p *models.Product) { p.Name = "Old Lamp"; p.Status = "inactive" })
+
+	indexed, err := embeddings.IndexProducts(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, indexed, "only published products are embedded")
+
+	indexed, err = embeddings.IndexProducts(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, indexed, "unchanged products aren't embedded again")
+
+	require.NoError(t, db.Model(runner).Update("description", "Trainers for trail running").Error)
+	indexed, err = embeddings.IndexProducts(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, indexed)
+
+	var stored models.ProductEmbedding
+	require.NoError(t, db.Where("product_id = ?", runner.ID).First(&stored).Error)
+	assert.Equal(t, "fake-embeddings", stored.Model)
+	assert.Equal(t, models.Vector{2, 0}, stored.Embedding)
+
+	// Without the vector table there's nothing to rank with
+	plain := services.NewProductEmbeddingService(testutil.NewTestDB(t), fake, services.EmbeddingConfig{})
+	assert.False(t, plain.Available())
+	_, err = plain.IndexProducts(ctx)
+	assert.ErrorIs(t, err, services.ErrEmbeddingsUnavailable)
+}
+
+func TestChatService_SemanticSuggestions(t *testing.T) {
+	db := testutil.NewTestDB(t, append(testutil.DefaultModels(), &models.ProductEmbedding{})...)
+	f := factories.New(t, db)
+	ctx := context.Background()
+	fake := shoeEmbeddings()
+
+	f.Product(func(p *models.Product) { p.Name = "Trail Runner"; p.Description = "Lightweight running shoes" })
+	f.Product(func(p *models.Product) { p.Name = "Desk Lamp"; p.Description = "LED lighting for your desk" })
+
+	service := services.NewChatServiceWithProvider(db, services.NewFakeLLM("Here you go!"),
+		services.NewProductService(db), services.NewShoppingCartService(db)).
+		WithEmbeddings(services.NewProductEmbeddingService(db, fake, services.EmbeddingConfig{MinSimilarity: 0.5}))
+	_, err := service.GetChatSession(ctx, "cs_semantic", nil)
+	require.NoError(t, err)
+
+	// "sneakers" shares no keyword with the product, only its meaning
+	response, err := service.ProcessMessage(ctx, "cs_semantic", nil, "Show me some sneakers")
+	require.NoError(t, err)
+	require.Len(t, response.Suggestions, 1)
+	assert.Equal(t, "Trail Runner", response.Suggestions[0].Product.Name)
+	assert.InDelta(t, 1.0, response.Suggestions[0].Confidence, 0.001)
+
+	var embedded int64
+	require.NoError(t, db.Model(&models.ProductEmbedding{}).Count(&embedded).Error)
+	assert.Equal(t, int64(2), embedded, "products not indexed yet are embedded while ranking")
+
+	// Keyword ranking takes over when the provider fails
+	fake.Fail(errors.New("provider down"))
+	response, err = service.ProcessMessage(ctx, "cs_semantic", nil, "Show me a desk lamp")
+	require.NoError(t, err)
+	require.NotEmpty(t, response.Suggestions)
+	assert.Equal(t, "Desk Lamp", response.Suggestions[0].Product.Name)
+}
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"chat-ecommerce-backend/tests/testutil/factories"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProductLifecycleService_RunScheduledTransitions(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	service := services.NewProductLifecycleService(db)
+	ctx := context.Background()
+
+	now := time.Now()
+	past := now.Add(-time.Hour)
+	future := now.Add(time.Hour)
+	longPast := now.Add(-2 * time.Hour)
+
+	drop := f.Product(func(p *models.Product) { p.Status = "inactive"; p.PublishAt = &past })
+	seasonal := f.Product(func(p *models.Product) { p.UnpublishAt = &past })
+	upcoming := f.Product(func(p *models.Product) { p.Status = "inactive"; p.PublishAt = &future })
+	missed := f.Product(func(p *models.Product) { p.Status = "inactive"; p.PublishAt = &longPast; p.UnpublishAt = &past })
+
+	result, err := service.RunScheduledTransitions(ctx, now)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []uuid.UUID{drop.ID}, result.Published)
+	assert.ElementsMatch(t, []uuid.UUID{seasonal.ID, missed.ID}, result.Unpublished)
+
+	statuses := map[string]string{}
+	for name, id := range map[string]uuid.UUID{"drop": drop.ID, "seasonal": seasonal.ID, "upcoming": upcoming.ID, "missed": missed.ID} {
+		var product models.Product
+		require.NoError(t, db.First(&product, "id = ?", id).Error)
+		statuses[name] = product.Status
+	}
+	assert.Equal(t, map[string]string{
+		"drop":     "active",
+		"seasonal": "inactive",
+		"upcoming": "inactive",
+		"missed":   "inactive",
+	}, statuses)
+
+	// Applied times are cleared, so a second run changes nothing
+	result, err = service.RunScheduledTransitions(ctx, now)
+	require.NoError(t, err)
+	assert.Empty(t, result.Published)
+	assert.Empty(t, result.Unpublished)
+}
+
+func TestProductService_HidesProductsOutsideWindow(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	productService := services.NewProductService(db)
+
+	future := time.Now().Add(time.Hour)
+	past := time.Now().Add(-time.Minute)
+
+	visible := f.Product()
+	// Still active because the scheduler has not run yet
+	early := f.Product(func(p *models.Product) { p.PublishAt = &future })
+	expired := f.Product(func(p *models.Product) { p.UnpublishAt = &past })
+
+	featured, err := productService.GetFeaturedProducts(10)
+	require.NoError(t, err)
+	require.Len(t, featured, 1)
+	assert.Equal(t, visible.ID, featured[0].ID)
+
+	_, err = productService.GetProductByID(early.ID)
+	assert.Error(t, err)
+	_, err = productService.GetProductBySKU(expired.SKU)
+	assert.Error(t, err)
+
+	list, err := productService.GetProducts(services.ProductFilters{Page: 1, Limit: 10})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), list.Total)
+
+	list, err = productService.GetProducts(services.ProductFilters{Page: 1, Limit: 10, IncludeUnpublished: true})
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), list.Total)
+}
+
+func TestProductLifecycleService_SetSchedule(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	service := services.NewProductLifecycleService(db)
+	ctx := context.Background()
+
+	product := f.Product()
+	publishAt := time.Now().Add(24 * time.Hour)
+	unpublishAt := publishAt.Add(-time.Hour)
+
+	_, err := service.SetSchedule(ctx, product.ID, services.ProductScheduleRequest{PublishAt: &publishAt, UnpublishAt: &unpublishAt})
+	assert.Error(t, err)
+
+	updated, err := service.SetSchedule(ctx, product.ID, services.ProductScheduleRequest{PublishAt: &publishAt})
+	require.NoError(t, err)
+	assert.Equal(t, "inactive", updated.Status)
+
+	scheduled, err := service.GetScheduledProducts(ctx)
+	require.NoError(t, err)
+	require.Len(t, scheduled, 1)
+	assert.Equal(t, product.ID, scheduled[0].ID)
+}
@@ -0,0 +1,60 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"chat-ecommerce-backend/tests/testutil/factories"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/datatypes"
+)
+
+func TestAdminProductService_PatchProduct(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	svc := services.NewAdminProductService(db)
+	ctx := context.Background()
+
+	product := f.Product(func(p *models.Product) {
+		p.Price = 50
+		p.Metadata = datatypes.JSON(`{"brand": "Acme", "color": "red"}`)
+	})
+	f.Variant(product)
+
+	price := 45.0
+	response, err := svc.PatchProduct(ctx, product.ID, services.AdminProductPatch{Price: &price})
+	require.NoError(t, err)
+	assert.Equal(t, 45.0, response.Product.Price)
+	assert.Equal(t, product.Name, response.Product.Name, "fields missing from the patch are untouched")
+	assert.Len(t, response.Variants, 1)
+
+	var metadata map[string]interface{}
+	require.NoError(t, json.Unmarshal(response.Product.Metadata, &metadata))
+	assert.Equal(t, "Acme", metadata["brand"])
+
+	status := "inactive"
+	response, err = svc.PatchProduct(ctx, product.ID, services.AdminProductPatch{
+		Status:   &status,
+		Metadata: map[string]interface{}{"color": nil, "material": "wool"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "inactive", response.Product.Status)
+	assert.Equal(t, 45.0, response.Product.Price)
+
+	metadata = nil
+	require.NoError(t, json.Unmarshal(response.Product.Metadata, &metadata))
+	assert.Equal(t, map[string]interface{}{"brand": "Acme", "material": "wool"}, metadata)
+
+	empty := ""
+	_, err = svc.PatchProduct(ctx, product.ID, services.AdminProductPatch{SKU: &empty})
+	assert.Error(t, err)
+
+	_, err = svc.PatchProduct(ctx, uuid.New(), services.AdminProductPatch{Price: &price})
+	assert.ErrorIs(t, err, services.ErrProductNotFound)
+}
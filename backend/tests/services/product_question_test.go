@@ -0,0 +1,100 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"chat-ecommerce-backend/tests/testutil/factories"
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProductQuestionService_AnswerFlow(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	fake := services.NewFakeLLM("Yes, it folds flat for travel.")
+	svc := services.NewProductQuestionService(db).WithLLM(fake)
+	ctx := context.Background()
+	admin := uuid.New()
+
+	product := f.Product(func(p *models.Product) { p.Name = "Travel Headphones" })
+
+	_, err := svc.AskQuestion(ctx, uuid.New(), nil, services.AskQuestionRequest{Question: "Does it fold?"})
+	assert.ErrorIs(t, err, services.ErrProductNotFound)
+
+	folds, err := svc.AskQuestion(ctx, product.ID, nil, services.AskQuestionRequest{Question: " Does it fold? "})
+	require.NoError(t, err)
+	assert.Equal(t, "Does it fold?", folds.Question)
+	assert.Equal(t, services.QuestionStatusPending, folds.Status)
+
+	battery, err := svc.AskQuestion(ctx, product.ID, nil, services.AskQuestionRequest{Question: "How long does the battery last?"})
+	require.NoError(t, err)
+
+	answered, err := svc.ListAnswered(ctx, product.ID)
+	require.NoError(t, err)
+	assert.Empty(t, answered, "unanswered questions aren't shown")
+
+	// The LLM drafts an answer that stays hidden until approved
+	suggested, err := svc.SuggestAnswer(ctx, folds.ID)
+	require.NoError(t, err)
+	assert.Equal(t, services.QuestionStatusSuggested, suggested.Status)
+	assert.Equal(t, services.AnswerSourceLLM, suggested.AnswerSource)
+	req, err := fake.LastRequest()
+	require.NoError(t, err)
+	assert.Contains(t, req.Messages[0].Content, "Travel Headphones")
+
+	answered, err = svc.ListAnswered(ctx, product.ID)
+	require.NoError(t, err)
+	assert.Empty(t, answered, "suggested answers need approval")
+
+	_, err = svc.ApproveAnswer(ctx, battery.ID, "", &admin)
+	assert.ErrorIs(t, err, services.ErrNoSuggestedAnswer)
+
+	approved, err := svc.ApproveAnswer(ctx, folds.ID, "", &admin)
+	require.NoError(t, err)
+	assert.Equal(t, services.QuestionStatusApproved, approved.Status)
+	assert.Equal(t, "Yes, it folds flat for travel.", approved.Answer)
+	assert.Equal(t, services.AnswerSourceLLM, approved.AnswerSource)
+
+	_, err = svc.AnswerQuestion(ctx, battery.ID, "  ", &admin)
+	assert.ErrorIs(t, err, services.ErrAnswerRequired)
+	_, err = svc.AnswerQuestion(ctx, battery.ID, "About 30 hours.", &admin)
+	require.NoError(t, err)
+
+	answered, err = svc.ListAnswered(ctx, product.ID)
+	require.NoError(t, err)
+	assert.Len(t, answered, 2)
+
+	byProduct, err := svc.AnsweredForProducts(ctx, []uuid.UUID{product.ID})
+	require.NoError(t, err)
+	assert.Len(t, byProduct[product.ID], 2)
+}
+
+func TestChatService_ProductQuestionsInPrompt(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	fake := services.NewFakeLLM("It lasts about 30 hours.")
+	chat := services.NewChatServiceWithProvider(db, fake, services.NewProductService(db), services.NewShoppingCartService(db))
+	svc := services.NewProductQuestionService(db)
+	ctx := context.Background()
+
+	product := f.Product(func(p *models.Product) { p.Name = "Travel Headphones" })
+	question, err := svc.AskQuestion(ctx, product.ID, nil, services.AskQuestionRequest{Question: "How long does the battery last?"})
+	require.NoError(t, err)
+	_, err = svc.AnswerQuestion(ctx, question.ID, "About 30 hours on a charge.", nil)
+	require.NoError(t, err)
+	pending, err := svc.AskQuestion(ctx, product.ID, nil, services.AskQuestionRequest{Question: "Is it waterproof?"})
+	require.NoError(t, err)
+
+	_, err = chat.ProcessMessage(ctx, "qa-session", nil, "What's the battery like on the travel headphones?")
+	require.NoError(t, err)
+
+	req, err := fake.LastRequest()
+	require.NoError(t, err)
+	assert.Contains(t, req.Messages[0].Content, "About 30 hours on a charge.")
+	assert.NotContains(t, req.Messages[0].Content, pending.Question, "only approved answers reach the assistant")
+}
@@ -0,0 +1,69 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"chat-ecommerce-backend/tests/testutil/factories"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminProductService_ChangeApprovalWorkflow(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	svc := services.NewAdminProductService(db).WithProductReview(services.ProductReviewConfig{
+		SeniorAdmins: map[string]bool{"lead@example.com": true},
+	})
+	ctx := context.Background()
+
+	assert.True(t, svc.CanPublish("Lead@example.com"))
+	assert.False(t, svc.CanPublish("junior@example.com"))
+	assert.True(t, services.ProductReviewConfig{}.CanPublish("anyone@example.com"), "without senior admins nobody needs review")
+
+	product := f.Product(func(p *models.Product) { p.Price = 30 })
+	junior := f.User()
+
+	price := 25.0
+	change, err := svc.SubmitProductChange(ctx, product.ID, services.ChangeKindPatch, services.AdminProductPatch{Price: &price}, &junior.ID, "junior@example.com", false)
+	require.NoError(t, err)
+	assert.Equal(t, services.ChangeStatusPending, change.Status)
+
+	var live models.Product
+	require.NoError(t, db.First(&live, "id = ?", product.ID).Error)
+	assert.Equal(t, 30.0, live.Price, "pending changes don't touch the live catalog")
+
+	_, err = svc.RejectProductChange(ctx, change.ID, nil, "")
+	assert.ErrorIs(t, err, services.ErrReviewCommentRequired)
+
+	approved, err := svc.ApproveProductChange(ctx, change.ID, nil, "looks good")
+	require.NoError(t, err)
+	assert.Equal(t, services.ChangeStatusApproved, approved.Status)
+	require.NoError(t, db.First(&live, "id = ?", product.ID).Error)
+	assert.Equal(t, 25.0, live.Price)
+
+	_, err = svc.ApproveProductChange(ctx, change.ID, nil, "")
+	assert.ErrorIs(t, err, services.ErrChangeNotReviewable)
+
+	// Drafts must be submitted before they can be reviewed
+	price = 10
+	draft, err := svc.SubmitProductChange(ctx, product.ID, services.ChangeKindPatch, services.AdminProductPatch{Price: &price}, &junior.ID, "junior@example.com", true)
+	require.NoError(t, err)
+	_, err = svc.RejectProductChange(ctx, draft.ID, nil, "too cheap")
+	assert.ErrorIs(t, err, services.ErrChangeNotReviewable)
+
+	_, err = svc.SubmitDraftChange(ctx, draft.ID)
+	require.NoError(t, err)
+	rejected, err := svc.RejectProductChange(ctx, draft.ID, nil, "too cheap")
+	require.NoError(t, err)
+	assert.Equal(t, "too cheap", rejected.ReviewComment)
+	require.NoError(t, db.First(&live, "id = ?", product.ID).Error)
+	assert.Equal(t, 25.0, live.Price)
+
+	pending, err := svc.ListProductChanges(ctx, services.ChangeStatusPending, nil)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+}
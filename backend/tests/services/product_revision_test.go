@@ -0,0 +1,75 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"chat-ecommerce-backend/tests/testutil/factories"
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminProductService_RollbackProduct(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	svc := services.NewAdminProductService(db)
+	ctx := context.Background()
+
+	category := f.Category()
+	req := services.AdminProductRequest{
+		Name:        "Mug",
+		Description: "Stoneware mug",
+		Price:       12,
+		CategoryID:  category.ID,
+		SKU:         "MUG-1",
+		Status:      "active",
+		Variants:    []services.ProductVariantRequest{{VariantName: "Color", VariantValue: "Blue"}},
+		Images:      []services.ProductImageRequest{{URL: "https://cdn.example.com/mug.jpg", IsPrimary: true}},
+	}
+	created, err := svc.CreateProduct(req)
+	require.NoError(t, err)
+	productID := created.Product.ID
+	blue := created.Variants[0]
+
+	// A bad import renames the product, reprices it and swaps the image
+	req.Name = "MUG"
+	req.Price = 1.2
+	req.Variants = []services.ProductVariantRequest{{VariantName: "Color", VariantValue: "Green"}}
+	req.Images = []services.ProductImageRequest{{URL: "https://cdn.example.com/wrong.jpg"}}
+	req.RemoveImageIDs = []uuid.UUID{created.Images[0].ID}
+	_, err = svc.BulkImportProducts(services.BulkImportRequest{Products: []services.AdminProductRequest{req}, UpdateExisting: true})
+	require.NoError(t, err)
+
+	revisions, err := svc.ListProductRevisions(ctx, productID)
+	require.NoError(t, err)
+	require.Len(t, revisions, 2)
+	assert.Equal(t, 2, revisions[0].Version, "newest revision first")
+	assert.Equal(t, services.RevisionSourceBulkImport, revisions[0].Source)
+	assert.Equal(t, services.RevisionSourceCreate, revisions[1].Source)
+
+	restored, err := svc.RollbackProduct(ctx, productID, 1, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "Mug", restored.Product.Name)
+	assert.Equal(t, 12.0, restored.Product.Price)
+	require.Len(t, restored.Variants, 1, "variants added since the revision are removed")
+	assert.Equal(t, blue.ID, restored.Variants[0].ID)
+	require.Len(t, restored.Images, 1)
+	assert.Equal(t, created.Images[0].ID, restored.Images[0].ID, "removed images come back with their IDs")
+
+	revisions, err = svc.ListProductRevisions(ctx, productID)
+	require.NoError(t, err)
+	require.Len(t, revisions, 3)
+	assert.Equal(t, services.RevisionSourceRollback, revisions[0].Source)
+
+	_, err = svc.RollbackProduct(ctx, productID, 99, nil)
+	assert.ErrorIs(t, err, services.ErrRevisionNotFound)
+
+	// Revisions go with the product
+	require.NoError(t, svc.DeleteProduct(productID))
+	revisions, err = svc.ListProductRevisions(ctx, productID)
+	require.NoError(t, err)
+	assert.Empty(t, revisions)
+}
@@ -3,37 +3,15 @@ package services
 import (
 	"chat-ecommerce-backend/internal/models"
 	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
 	"testing"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
-	"gorm.io/driver/sqlite"
-	"gorm.io/gorm"
 )
 
-func setupTestDB(t *testing.T) *gorm.DB {
-	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
-	if err != nil {
-		t.Fatal("Failed to connect to test database:", err)
-	}
-
-	// Auto-migrate the database
-	err = db.AutoMigrate(
-		&models.Category{},
-		&models.Product{},
-		&models.ProductVariant{},
-		&models.ProductImage{},
-		&models.Inventory{},
-	)
-	if err != nil {
-		t.Fatal("Failed to migrate test database:", err)
-	}
-
-	return db
-}
-
 func TestProductService_CreateProduct(t *testing.T) {
-	db := setupTestDB(t)
+	db := testutil.NewTestDB(t)
 	service := services.NewProductService(db)
 
 	// Create test category
@@ -66,7 +44,7 @@ func TestProductService_CreateProduct(t *testing.T) {
 }
 
 func TestProductService_GetProductByID(t *testing.T) {
-	db := setupTestDB(t)
+	db := testutil.NewTestDB(t)
 	service := services.NewProductService(db)
 
 	// Create test category
@@ -98,7 +76,7 @@ func TestProductService_GetProductByID(t *testing.T) {
 }
 
 func TestProductService_GetProducts(t *testing.T) {
-	db := setupTestDB(t)
+	db := testutil.NewTestDB(t)
 	service := services.NewProductService(db)
 
 	// Create test category
@@ -149,7 +127,7 @@ func TestProductService_GetProducts(t *testing.T) {
 }
 
 func TestProductService_UpdateProduct(t *testing.T) {
-	db := setupTestDB(t)
+	db := testutil.NewTestDB(t)
 	service := services.NewProductService(db)
 
 	// Create test category
@@ -192,7 +170,7 @@ func TestProductService_UpdateProduct(t *testing.T) {
 }
 
 func TestProductService_DeleteProduct(t *testing.T) {
-	db := setupTestDB(t)
+	db := testutil.NewTestDB(t)
 	service := services.NewProductService(db)
 
 	// Create test category
@@ -219,15 +197,15 @@ func TestProductService_DeleteProduct(t *testing.T) {
 	err := service.DeleteProduct(product.ID)
 	assert.NoError(t, err)
 
-	// Verify product was deleted
+	// Verify product was soft deleted
 	var deletedProduct models.Product
 	err = db.Where("id = ?", product.ID).First(&deletedProduct).Error
-	assert.Error(t, err)
-	assert.Equal(t, gorm.ErrRecordNotFound, err)
+	assert.NoError(t, err)
+	assert.Equal(t, "inactive", deletedProduct.Status)
 }
 
 func TestProductService_SearchProducts(t *testing.T) {
-	db := setupTestDB(t)
+	db := testutil.NewTestDB(t)
 	service := services.NewProductService(db)
 
 	// Create test category
@@ -272,7 +250,7 @@ func TestProductService_SearchProducts(t *testing.T) {
 }
 
 func TestProductService_GetFeaturedProducts(t *testing.T) {
-	db := setupTestDB(t)
+	db := testutil.NewTestDB(t)
 	service := services.NewProductService(db)
 
 	// Create test category
@@ -316,7 +294,7 @@ func TestProductService_GetFeaturedProducts(t *testing.T) {
 }
 
 func TestProductService_GetRelatedProducts(t *testing.T) {
-	db := setupTestDB(t)
+	db := testutil.NewTestDB(t)
 	service := services.NewProductService(db)
 
 	// Create test category
@@ -0,0 +1,106 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"chat-ecommerce-backend/tests/testutil/factories"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProductSignalsService_Viewers(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	ctx := context.Background()
+	signals := services.NewProductSignalsService(db, services.ProductSignalsConfig{ViewerTTL: time.Minute})
+	product := f.Product()
+	now := time.Now()
+
+	signals.View(product.ID, services.ViewerKey("shopper-1"), now)
+	got, err := signals.Signals(ctx, product.ID, now)
+	require.NoError(t, err)
+	assert.Zero(t, got.Viewers, "a lone viewer is never pointed at")
+
+	signals.View(product.ID, services.ViewerKey("shopper-2"), now)
+	signals.View(product.ID, services.ViewerKey("shopper-2"), now) // a second tab
+	got, err = signals.Signals(ctx, product.ID, now)
+	require.NoError(t, err)
+	assert.Equal(t, 2, got.Viewers)
+
+	signals.View(product.ID, services.ViewerKey("shopper-3"), now.Add(50*time.Second))
+	got, err = signals.Signals(ctx, product.ID, now.Add(90*time.Second))
+	require.NoError(t, err)
+	assert.Zero(t, got.Viewers, "viewers not heard from within the TTL stop counting")
+
+	signals.View(product.ID, services.ViewerKey("shopper-1"), now.Add(90*time.Second))
+	got, err = signals.Signals(ctx, product.ID, now.Add(90*time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, 2, got.Viewers)
+
+	signals.Leave(product.ID, services.ViewerKey("shopper-1"))
+	got, err = signals.Signals(ctx, product.ID, now.Add(90*time.Second))
+	require.NoError(t, err)
+	assert.Zero(t, got.Viewers)
+}
+
+func TestProductSignalsService_PurchasesAndLowStock(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	ctx := context.Background()
+	signals := services.NewProductSignalsService(db, services.ProductSignalsConfig{})
+
+	popular := f.StockedProduct(3)
+	plenty := f.StockedProduct(40)
+	user := f.User()
+	for i := 0; i < 3; i++ {
+		f.Order(user, []factories.OrderLine{{Product: popular}, {Product: plenty}})
+	}
+	f.Order(user, []factories.OrderLine{{Product: plenty}}, func(o *models.Order) { o.Status = "cancelled" })
+	f.Order(user, []factories.OrderLine{{Product: plenty}}, func(o *models.Order) { o.CreatedAt = time.Now().Add(-48 * time.Hour) })
+
+	snapshot, err := signals.Snapshot(ctx, []uuid.UUID{popular.ID, plenty.ID}, time.Now())
+	require.NoError(t, err)
+	require.Len(t, snapshot, 2)
+	assert.Equal(t, 3, snapshot[0].RecentPurchases)
+	assert.Equal(t, 24, snapshot[0].PurchaseWindowHours)
+	assert.Equal(t, 3, snapshot[0].LowStock)
+	assert.Equal(t, 3, snapshot[1].RecentPurchases, "cancelled and older orders don't count")
+	assert.Zero(t, snapshot[1].LowStock)
+
+	off := false
+	_, err = signals.UpdateSettings(ctx, services.SocialProofSettingsRequest{ShowLowStock: &off}, nil)
+	require.NoError(t, err)
+	got, err := signals.Signals(ctx, popular.ID, time.Now())
+	require.NoError(t, err)
+	assert.Zero(t, got.LowStock)
+	assert.Equal(t, 3, got.RecentPurchases)
+}
+
+func TestProductSignalsService_UpdateSettings(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	ctx := context.Background()
+	signals := services.NewProductSignalsService(db, services.ProductSignalsConfig{})
+
+	settings, err := signals.GetSettings(ctx)
+	require.NoError(t, err)
+	assert.True(t, settings.ShowViewers)
+	assert.Equal(t, 2, settings.MinViewers)
+
+	one, week, off := 1, 168, false
+	_, err = signals.UpdateSettings(ctx, services.SocialProofSettingsRequest{MinViewers: &one}, nil)
+	assert.ErrorIs(t, err, services.ErrInvalidSocialProofSettings)
+
+	_, err = signals.UpdateSettings(ctx, services.SocialProofSettingsRequest{ShowViewers: &off, PurchaseWindowHours: &week}, nil)
+	require.NoError(t, err)
+	settings, err = signals.GetSettings(ctx)
+	require.NoError(t, err)
+	assert.False(t, settings.ShowViewers)
+	assert.True(t, settings.ShowPurchases)
+	assert.Equal(t, 168, settings.PurchaseWindowHours)
+}
@@ -0,0 +1,127 @@
+package services
+
+import (
+	"bytes"
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"chat-ecommerce-backend/tests/testutil/factories"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuoteService_NegotiateApproveAndConvert(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	service := services.NewQuoteService(db)
+	carts := services.NewShoppingCartService(db)
+	ctx := context.Background()
+
+	buyer := f.User()
+	chair := f.StockedProduct(100, func(p *models.Product) { p.Price = 120 })
+	require.NoError(t, carts.AddToCart("b2b-session", &buyer.ID, services.AddToCartRequest{ProductID: chair.ID, Quantity: 50}))
+
+	quote, err := service.CreateFromCart(ctx, "b2b-session", buyer.ID, services.CreateQuoteRequest{Notes: "Office fit-out"})
+	require.NoError(t, err)
+	assert.Equal(t, services.QuoteStatusRequested, quote.Status)
+	require.Len(t, quote.Items, 1)
+	assert.InDelta(t, 6000, quote.Subtotal, 0.001)
+
+	_, err = service.AcceptQuote(ctx, quote.ID, buyer.ID)
+	assert.Error(t, err, "quotes must be approved before they can be accepted")
+
+	shipping := 0.0
+	quote, err = service.UpdateQuote(ctx, quote.ID, services.UpdateQuoteRequest{
+		Items:          []services.QuoteItemPrice{{ItemID: quote.Items[0].ID, UnitPrice: 100}},
+		ShippingAmount: &shipping,
+	})
+	require.NoError(t, err)
+	assert.InDelta(t, 5000, quote.Subtotal, 0.001)
+	assert.InDelta(t, 5400, quote.TotalAmount, 0.001)
+
+	quote, err = service.ApproveQuote(ctx, quote.ID, nil)
+	require.NoError(t, err)
+	require.NotNil(t, quote.ValidUntil)
+
+	// Approved quotes are no longer negotiable
+	_, err = service.UpdateQuote(ctx, quote.ID, services.UpdateQuoteRequest{AdminNotes: new(string)})
+	assert.Error(t, err)
+
+	_, err = service.AcceptQuote(ctx, quote.ID, f.User().ID)
+	assert.ErrorIs(t, err, services.ErrQuoteNotFound, "other users cannot accept the quote")
+
+	_, err = service.AcceptQuote(ctx, quote.ID, buyer.ID)
+	require.NoError(t, err)
+
+	order, err := service.ConvertToOrder(ctx, quote.ID, buyer.ID, services.ConvertQuoteRequest{
+		ShippingAddress: map[string]interface{}{"city": "Lisbon"},
+		BillingAddress:  map[string]interface{}{"city": "Lisbon"},
+		PaymentMethod:   "invoice",
+	})
+	require.NoError(t, err)
+	assert.InDelta(t, 5400, order.TotalAmount, 0.001)
+	require.Len(t, order.Items, 1)
+	assert.InDelta(t, 100, order.Items[0].UnitPrice, 0.001)
+
+	var stock models.Inventory
+	require.NoError(t, db.First(&stock, "product_id = ?", chair.ID).Error)
+	assert.Equal(t, 50, stock.QuantityAvailable)
+
+	converted, err := service.GetQuote(ctx, quote.ID, &buyer.ID)
+	require.NoError(t, err)
+	assert.Equal(t, services.QuoteStatusConverted, converted.Status)
+	require.NotNil(t, converted.OrderID)
+	assert.Equal(t, order.ID, *converted.OrderID)
+
+	_, err = service.ConvertToOrder(ctx, quote.ID, buyer.ID, services.ConvertQuoteRequest{})
+	assert.Error(t, err, "a quote converts only once")
+}
+
+func TestQuoteService_ExpiredQuotesCannotBeAccepted(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	service := services.NewQuoteService(db)
+	ctx := context.Background()
+
+	buyer := f.User()
+	product := f.StockedProduct(10)
+	require.NoError(t, services.NewShoppingCartService(db).AddToCart("expiring", &buyer.ID, services.AddToCartRequest{ProductID: product.ID, Quantity: 2}))
+
+	quote, err := service.CreateFromCart(ctx, "expiring", buyer.ID, services.CreateQuoteRequest{})
+	require.NoError(t, err)
+	quote, err = service.ApproveQuote(ctx, quote.ID, nil)
+	require.NoError(t, err)
+
+	require.NoError(t, db.Model(&models.Quote{}).Where("id = ?", quote.ID).Update("valid_until", time.Now().Add(-time.Minute)).Error)
+
+	_, err = service.AcceptQuote(ctx, quote.ID, buyer.ID)
+	assert.Error(t, err)
+
+	var stored models.Quote
+	require.NoError(t, db.First(&stored, "id = ?", quote.ID).Error)
+	assert.Equal(t, services.QuoteStatusExpired, stored.Status)
+}
+
+func TestRenderQuotePDF(t *testing.T) {
+	validUntil := time.Date(2026, 1, 31, 0, 0, 0, 0, time.UTC)
+	quote := &models.Quote{
+		QuoteNumber: "QTE-1",
+		Status:      services.QuoteStatusApproved,
+		Currency:    "USD",
+		ValidUntil:  &validUntil,
+		AdminNotes:  "Net 30 (invoice)",
+		Items: []models.QuoteItem{
+			{ProductName: "Desk Chair", SKU: "CH-1", Quantity: 50, ListPrice: 120, UnitPrice: 100, TotalPrice: 5000},
+		},
+	}
+
+	pdf := services.RenderQuotePDF(quote)
+	assert.True(t, bytes.HasPrefix(pdf, []byte("%PDF-1.4")))
+	assert.True(t, bytes.HasSuffix(pdf, []byte("%%EOF\n")))
+	assert.Contains(t, string(pdf), "Valid until: 2026-01-31")
+	assert.Contains(t, string(pdf), `Net 30 \(invoice\)`)
+}
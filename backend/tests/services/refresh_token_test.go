@@ -0,0 +1,74 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"chat-ecommerce-backend/tests/testutil/factories"
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRefreshTokenService_RotationAndReuse(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	svc := services.NewRefreshTokenService(db)
+	ctx := context.Background()
+
+	user := f.User()
+	first, err := svc.Issue(ctx, user.ID, "10.0.0.1", "test")
+	require.NoError(t, err)
+
+	userID, second, err := svc.Rotate(ctx, first.Token, "10.0.0.1", "test")
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, userID)
+	assert.NotEqual(t, first.Token, second.Token)
+	assert.Equal(t, first.FamilyID, second.FamilyID, "rotation stays in the sign-in's family")
+	assert.False(t, svc.IsRevoked(ctx, second.FamilyID.String()))
+
+	_, _, err = svc.Rotate(ctx, "not-a-token", "10.0.0.1", "test")
+	assert.ErrorIs(t, err, services.ErrInvalidRefreshToken)
+
+	// Replaying the spent token revokes the whole family, including the
+	// token the legitimate client holds
+	_, _, err = svc.Rotate(ctx, first.Token, "203.0.113.7", "attacker")
+	assert.ErrorIs(t, err, services.ErrRefreshTokenReused)
+	assert.True(t, svc.IsRevoked(ctx, first.FamilyID.String()))
+	_, _, err = svc.Rotate(ctx, second.Token, "10.0.0.1", "test")
+	assert.ErrorIs(t, err, services.ErrInvalidRefreshToken)
+}
+
+func TestRefreshTokenService_LogoutEverywhere(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	svc := services.NewRefreshTokenService(db)
+	admin := services.NewAdminUserService(db)
+	ctx := context.Background()
+
+	user := f.User()
+	other := f.User()
+	laptop, err := svc.Issue(ctx, user.ID, "10.0.0.1", "laptop")
+	require.NoError(t, err)
+	phone, err := svc.Issue(ctx, user.ID, "10.0.0.2", "phone")
+	require.NoError(t, err)
+	otherSession, err := svc.Issue(ctx, other.ID, "10.0.0.3", "laptop")
+	require.NoError(t, err)
+
+	require.NoError(t, svc.Revoke(ctx, laptop.Token))
+	assert.True(t, svc.IsRevoked(ctx, laptop.FamilyID.String()))
+	assert.False(t, svc.IsRevoked(ctx, phone.FamilyID.String()), "logout ends one sign-in")
+
+	revoked, err := svc.RevokeAll(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), revoked)
+	assert.True(t, svc.IsRevoked(ctx, phone.FamilyID.String()))
+	assert.False(t, svc.IsRevoked(ctx, otherSession.FamilyID.String()))
+
+	// Suspending a user ends their sign-ins too
+	_, err = admin.SuspendUsers(ctx, services.BulkUserRequest{UserIDs: []uuid.UUID{other.ID}})
+	require.NoError(t, err)
+	assert.True(t, svc.IsRevoked(ctx, otherSession.FamilyID.String()))
+}
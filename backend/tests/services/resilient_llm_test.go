@@ -0,0 +1,116 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/services"
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/assert"
+)
+
+func testResilientConfig() services.ResilientLLMConfig {
+	return services.ResilientLLMConfig{
+		Timeout:          time.Second,
+		MaxRetries:       2,
+		BaseBackoff:      time.Millisecond,
+		MaxBackoff:       5 * time.Millisecond,
+		FailureThreshold: 2,
+		Cooldown:         50 * time.Millisecond,
+	}
+}
+
+var testLLMRequest = services.LLMRequest{
+	Model:    "fake",
+	Messages: []services.LLMMessage{{Role: "user", Content: "hi"}},
+}
+
+func TestResilientLLM_RetriesTransientErrors(t *testing.T) {
+	fake := services.NewFakeLLM().Enqueue(
+		services.FakeLLMResponse{Err: errors.New("connection reset")},
+		services.FakeLLMResponse{Err: errors.New("connection reset")},
+		services.FakeLLMResponse{Content: "Hello!"},
+	)
+	llm := services.NewResilientLLM(fake, testResilientConfig())
+
+	response, err := llm.Complete(context.Background(), testLLMRequest)
+	assert.NoError(t, err)
+	assert.Equal(t, "Hello!", response.Content)
+	assert.Equal(t, 3, fake.CallCount())
+	assert.Equal(t, services.CircuitClosed, llm.State())
+}
+
+func TestResilientLLM_DoesNotRetryClientErrors(t *testing.T) {
+	fake := services.NewFakeLLM().Fallback(services.FakeLLMResponse{
+		Err: &openai.APIError{HTTPStatusCode: http.StatusUnauthorized, Message: "invalid api key"},
+	})
+	llm := services.NewResilientLLM(fake, testResilientConfig())
+
+	_, err := llm.Complete(context.Background(), testLLMRequest)
+	assert.Error(t, err)
+	assert.False(t, errors.Is(err, services.ErrLLMUnavailable))
+	assert.Equal(t, 1, fake.CallCount())
+}
+
+func TestResilientLLM_CircuitBreaker(t *testing.T) {
+	fake := services.NewFakeLLM().Fallback(services.FakeLLMResponse{Err: errors.New("503 service unavailable")})
+	config := testResilientConfig()
+	config.MaxRetries = 0
+	llm := services.NewResilientLLM(fake, config)
+
+	for i := 0; i < 2; i++ {
+		_, err := llm.Complete(context.Background(), testLLMRequest)
+		assert.ErrorIs(t, err, services.ErrLLMUnavailable)
+	}
+	assert.Equal(t, services.CircuitOpen, llm.State())
+
+	// Open circuit rejects without calling the provider
+	_, err := llm.Complete(context.Background(), testLLMRequest)
+	assert.ErrorIs(t, err, services.ErrLLMUnavailable)
+	assert.Equal(t, 2, fake.CallCount())
+
+	// After the cooldown a successful trial call closes the circuit
+	time.Sleep(config.Cooldown)
+	assert.Equal(t, services.CircuitHalfOpen, llm.State())
+	fake.Enqueue(services.FakeLLMResponse{Content: "back online"})
+
+	response, err := llm.Complete(context.Background(), testLLMRequest)
+	assert.NoError(t, err)
+	assert.Equal(t, "back online", response.Content)
+	assert.Equal(t, services.CircuitClosed, llm.State())
+}
+
+func TestResilientLLM_StreamConsumerErrorIsNotAProviderFailure(t *testing.T) {
+	fake := services.NewFakeLLM().Enqueue(services.FakeLLMResponse{Content: "partial"})
+	config := testResilientConfig()
+	config.FailureThreshold = 1
+	llm := services.NewResilientLLM(fake, config)
+
+	calls := 0
+	_, err := llm.Stream(context.Background(), testLLMRequest, func(delta string) error {
+		calls++
+		return errors.New("client disconnected")
+	})
+	assert.EqualError(t, err, "client disconnected")
+	assert.Equal(t, 1, fake.CallCount())
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, services.CircuitClosed, llm.State())
+}
+
+func TestChatService_ProcessMessage_AssistantBusy(t *testing.T) {
+	fake := services.NewFakeLLM().Fallback(services.FakeLLMResponse{Err: errors.New("503 service unavailable")})
+	config := testResilientConfig()
+	config.MaxRetries = 0
+	service, _, product := setupFakeLLMChat(t, services.NewResilientLLM(fake, config))
+
+	response, err := service.ProcessMessage(context.Background(), "busy-session", nil, "Show me wireless headphones")
+	assert.NoError(t, err)
+	assert.Equal(t, services.AssistantBusyMessage, response.Message)
+	assert.Equal(t, true, response.Context["assistant_busy"])
+	if assert.NotEmpty(t, response.Suggestions) {
+		assert.Equal(t, product.ID, response.Suggestions[0].Product.ID)
+	}
+}
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"context"
+	"encoding/csv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchMissService_MissReport(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	svc := services.NewSearchMissService(db)
+	ctx := context.Background()
+
+	svc.RecordMiss(ctx, "Standing Desk", services.MissSourceSearch, "s1", nil)
+	svc.RecordMiss(ctx, "standing  desk", services.MissSourceSearch, "s1", nil)
+	svc.RecordMiss(ctx, "standing desk", services.MissSourceChat, "s2", nil)
+	svc.RecordMiss(ctx, "yoga mat", services.MissSourceChat, "", nil)
+	svc.RecordMiss(ctx, "   ", services.MissSourceSearch, "s3", nil)
+
+	var count int64
+	require.NoError(t, db.Model(&models.SearchMiss{}).Count(&count).Error)
+	assert.Equal(t, int64(4), count, "empty queries aren't recorded")
+
+	filter := services.SearchMissFilter{From: time.Now().Add(-time.Hour), To: time.Now().Add(time.Hour)}
+	report, err := svc.MissReport(ctx, filter)
+	require.NoError(t, err)
+	require.Len(t, report, 2)
+	assert.Equal(t, "standing desk", report[0].Query, "most frequent misses first, case and spacing ignored")
+	assert.Equal(t, 3, report[0].Misses)
+	assert.Equal(t, 2, report[0].SearchMisses)
+	assert.Equal(t, 1, report[0].ChatMisses)
+	assert.Equal(t, 2, report[0].Sessions)
+
+	filter.Source = services.MissSourceChat
+	report, err = svc.MissReport(ctx, filter)
+	require.NoError(t, err)
+	assert.Len(t, report, 2)
+
+	filter.Limit = 1
+	data, err := svc.ExportMissReportCSV(ctx, filter)
+	require.NoError(t, err)
+	rows, err := csv.NewReader(strings.NewReader(string(data))).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	assert.Equal(t, "query", rows[0][0])
+}
+
+func TestProductService_SearchRecordsMisses(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	productService := services.NewProductService(db)
+	ctx := context.Background()
+
+	products, err := productService.SearchProducts("hoverboard", 10)
+	require.NoError(t, err)
+	require.Empty(t, products)
+	productService.RecordSearchMiss(ctx, "hoverboard", "session-1", nil)
+
+	report, err := services.NewSearchMissService(db).MissReport(ctx, services.SearchMissFilter{
+		From: time.Now().Add(-time.Hour),
+		To:   time.Now().Add(time.Hour),
+	})
+	require.NoError(t, err)
+	require.Len(t, report, 1)
+	assert.Equal(t, 1, report[0].SearchMisses)
+}
@@ -0,0 +1,82 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"chat-ecommerce-backend/tests/testutil/factories"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/datatypes"
+)
+
+func TestSearchRankingService_Factors(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	svc := services.NewSearchRankingService(db).WithConfig(services.SearchRankingConfig{LowStockThreshold: 5, LowStockFactor: 0.5})
+	ctx := context.Background()
+
+	furniture := f.Category()
+	desks := f.Category(func(c *models.Category) { c.ParentID = &furniture.ID })
+	desk := f.StockedProduct(50, func(p *models.Product) { p.CategoryID = desks.ID })
+	chair := f.StockedProduct(2, func(p *models.Product) {
+		p.CategoryID = furniture.ID
+		p.Metadata = datatypes.JSON(`{"brand":"Acme"}`)
+	})
+	lamp := f.Product()
+
+	_, err := svc.SetBoost(ctx, services.SearchBoostRequest{Kind: services.BoostKindCategory, Target: furniture.ID.String(), Factor: 2})
+	require.NoError(t, err)
+	_, err = svc.SetBoost(ctx, services.SearchBoostRequest{Kind: services.BoostKindBrand, Target: " ACME", Factor: 1.5})
+	require.NoError(t, err)
+
+	factors, err := svc.Factors(ctx, []models.Product{*desk, *chair, *lamp})
+	require.NoError(t, err)
+	assert.Equal(t, 2.0, factors[desk.ID], "subcategories inherit their parent's boost")
+	assert.Equal(t, 1.5, factors[chair.ID], "boosted category and brand, demoted for low stock")
+	assert.Equal(t, 1.0, factors[lamp.ID], "products without inventory records aren't demoted")
+
+	// Setting a boost again replaces it
+	_, err = svc.SetBoost(ctx, services.SearchBoostRequest{Kind: services.BoostKindCategory, Target: desks.ID.String(), Factor: 0.5})
+	require.NoError(t, err)
+	factors, err = svc.Factors(ctx, []models.Product{*desk})
+	require.NoError(t, err)
+	assert.Equal(t, 0.5, factors[desk.ID], "the most specific category boost wins")
+
+	boosts, err := svc.ListBoosts(ctx)
+	require.NoError(t, err)
+	assert.Len(t, boosts, 3)
+}
+
+func TestSearchRankingService_Pins(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	svc := services.NewSearchRankingService(db)
+	ctx := context.Background()
+
+	f.Product(func(p *models.Product) { p.Name = "Standing Desk" })
+	f.Product(func(p *models.Product) { p.Name = "Corner Desk" })
+	featured := f.Product(func(p *models.Product) { p.Name = "Desk Lamp" })
+
+	pin, err := svc.PinProduct(ctx, services.PinRequest{Query: "  Desk ", ProductID: featured.ID, Position: 1})
+	require.NoError(t, err)
+	assert.Equal(t, "desk", pin.Query)
+
+	products, err := services.NewProductService(db).SearchProducts("desk", 2)
+	require.NoError(t, err)
+	require.Len(t, products, 2)
+	assert.Equal(t, featured.ID, products[0].ID, "pinned products come first")
+
+	pinned, err := svc.PinnedForText(ctx, "I need a desk, any ideas?")
+	require.NoError(t, err)
+	require.Len(t, pinned, 1)
+	pinned, err = svc.PinnedForText(ctx, "show me desktops")
+	require.NoError(t, err)
+	assert.Empty(t, pinned, "pins match whole words only")
+
+	require.NoError(t, svc.UnpinProduct(ctx, pin.ID))
+	assert.ErrorIs(t, svc.UnpinProduct(ctx, pin.ID), services.ErrPinNotFound)
+}
@@ -3,30 +3,20 @@ package services
 import (
 	"chat-ecommerce-backend/internal/models"
 	"chat-ecommerce-backend/internal/services/search"
+	"chat-ecommerce-backend/tests/testutil"
 	"context"
 	"testing"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
-	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
 func setupTestDBWithSearch(t *testing.T) *gorm.DB {
-	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
-	if err != nil {
-		t.Fatal("Failed to connect to test database:", err)
-	}
-
-	// Auto-migrate the database
-	err = db.AutoMigrate(
-		&models.Product{},
-		&models.Category{},
-	)
-	if err != nil {
-		t.Fatal("Failed to migrate test database:", err)
-	}
+	// Searches use PostgreSQL full-text queries
+	testutil.RequirePostgres(t)
+	db := testutil.NewTestDB(t)
 
 	// Create test categories
 	category := models.Category{
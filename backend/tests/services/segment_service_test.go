@@ -0,0 +1,155 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"chat-ecommerce-backend/tests/testutil/factories"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func paid(o *models.Order) { o.PaymentStatus = "paid"; o.Status = "confirmed" }
+
+func TestSegmentService_EvaluateSegments(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	service := services.NewSegmentService(db)
+	ctx := context.Background()
+
+	cameras := f.Category()
+	camera := f.Product(func(p *models.Product) { p.CategoryID = cameras.ID; p.Price = 400 })
+	cable := f.Product(func(p *models.Product) { p.Price = 10 })
+
+	vip := f.User()
+	f.Order(vip, []factories.OrderLine{{Product: camera}}, paid)
+	f.Order(vip, []factories.OrderLine{{Product: camera}}, paid)
+
+	casual := f.User()
+	f.Order(casual, []factories.OrderLine{{Product: cable}}, paid)
+	// Unpaid and cancelled orders do not count
+	f.Order(casual, []factories.OrderLine{{Product: camera, Quantity: 5}})
+	f.Order(casual, []factories.OrderLine{{Product: camera, Quantity: 5}}, func(o *models.Order) {
+		paid(o)
+		o.Status = "cancelled"
+	})
+
+	f.User() // no orders
+
+	_, err := service.UpsertSegment(ctx, "vip", services.SegmentRequest{
+		Name:             "VIP",
+		Rules:            services.SegmentRules{MinOrders: 2, MinAverageOrderValue: 200},
+		Greeting:         "Welcome back, VIP!",
+		PromotionMessage: "Free express shipping on every order",
+		Priority:         10,
+	})
+	require.NoError(t, err)
+	_, err = service.UpsertSegment(ctx, "camera-buyers", services.SegmentRequest{
+		Name:  "Camera buyers",
+		Rules: services.SegmentRules{CategoryIDs: []uuid.UUID{cameras.ID}},
+	})
+	require.NoError(t, err)
+	_, err = service.UpsertSegment(ctx, "customers", services.SegmentRequest{Name: "All customers"})
+	require.NoError(t, err)
+
+	result, err := service.EvaluateSegments(ctx, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"vip": 1, "camera-buyers": 1, "customers": 2}, result.Members)
+
+	members, err := service.GetSegmentMembers(ctx, "vip")
+	require.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{vip.ID}, members)
+
+	segments, err := service.GetUserSegments(ctx, vip.ID)
+	require.NoError(t, err)
+	require.Len(t, segments, 3)
+	assert.Equal(t, "vip", segments[0].Slug, "highest priority first")
+
+	segments, err = service.GetUserSegments(ctx, casual.ID)
+	require.NoError(t, err)
+	require.Len(t, segments, 1)
+	assert.Equal(t, "customers", segments[0].Slug)
+}
+
+func TestSegmentService_InactiveSegmentsHaveNoMembers(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	service := services.NewSegmentService(db)
+	ctx := context.Background()
+
+	user := f.User()
+	f.Order(user, []factories.OrderLine{{Product: f.Product()}}, paid)
+
+	inactive := false
+	_, err := service.UpsertSegment(ctx, "paused", services.SegmentRequest{Name: "Paused", IsActive: &inactive})
+	require.NoError(t, err)
+
+	result, err := service.EvaluateSegments(ctx, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, 0, result.Members["paused"])
+
+	segments, err := service.GetUserSegments(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Empty(t, segments)
+}
+
+// joinSegment adds the user to a new active segment
+func joinSegment(t *testing.T, db *gorm.DB, userID uuid.UUID, segment models.Segment) {
+	segment.ID = uuid.New()
+	segment.IsActive = true
+	require.NoError(t, db.Create(&segment).Error)
+	require.NoError(t, db.Create(&models.SegmentMembership{
+		ID:          uuid.New(),
+		SegmentID:   segment.ID,
+		UserID:      userID,
+		EvaluatedAt: time.Now(),
+	}).Error)
+}
+
+func TestChatService_SegmentsInPrompt(t *testing.T) {
+	fake := services.NewFakeLLM("Welcome back!")
+	service, db, _ := setupFakeLLMChat(t, fake)
+	userID := uuid.New()
+	joinSegment(t, db, userID, models.Segment{
+		Slug:             "vip",
+		Name:             "VIP",
+		Greeting:         "Welcome back, VIP!",
+		PromotionMessage: "Free express shipping on every order",
+		Priority:         10,
+	})
+
+	response, err := service.ProcessMessage(context.Background(), "segment-prompt", &userID, "hi")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"vip"}, response.Context["segments"])
+
+	req, err := fake.LastRequest()
+	require.NoError(t, err)
+	assert.Contains(t, req.Messages[0].Content, "```segments")
+	assert.Contains(t, req.Messages[0].Content, "Free express shipping on every order")
+
+	// Anonymous sessions get no segment block
+	_, err = service.ProcessMessage(context.Background(), "segment-anonymous", nil, "hi")
+	require.NoError(t, err)
+	req, err = fake.LastRequest()
+	require.NoError(t, err)
+	assert.NotContains(t, req.Messages[0].Content, "```segments")
+}
+
+func TestFallbackResponder_SegmentGreeting(t *testing.T) {
+	service, db, _ := setupFakeLLMChat(t, unavailableLLM())
+	userID := uuid.New()
+	joinSegment(t, db, userID, models.Segment{Slug: "vip", Name: "VIP", Greeting: "Welcome back, VIP!", Priority: 10})
+	joinSegment(t, db, userID, models.Segment{Slug: "customers", Name: "Customers", Greeting: "Hi again!"})
+
+	response, err := service.ProcessMessage(context.Background(), "segment-fallback", &userID, "Hello")
+	require.NoError(t, err)
+	assert.Equal(t, services.FallbackIntentGreeting, response.Context["fallback_intent"])
+	assert.True(t, strings.HasPrefix(response.Message, "Welcome back, VIP!"))
+}
@@ -0,0 +1,70 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"chat-ecommerce-backend/tests/testutil/factories"
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/datatypes"
+)
+
+func TestStoreLocaleService_Resolve(t *testing.T) {
+	t.Setenv("SHIPPING_COUNTRIES", "us,ca,de")
+	t.Setenv("GEOIP_COUNTRY_HEADERS", "CF-IPCountry")
+	t.Setenv("GEOIP_RANGES", "81.2.69.0/24=GB,203.0.113.0/24=de,bogus")
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	ctx := context.Background()
+	locales := services.NewStoreLocaleService(db, services.LocaleConfigFromEnv())
+
+	// Unplaced visitors get the store default
+	locale := locales.Resolve(ctx, "192.0.2.1", http.Header{}, nil)
+	assert.Equal(t, "US", locale.Country)
+	assert.Equal(t, "USD", locale.Currency)
+	assert.False(t, locale.TaxInclusive)
+	assert.True(t, locale.ShipsToCountry)
+	assert.Equal(t, services.LocaleSourceDefault, locale.Source)
+
+	locale = locales.Resolve(ctx, "81.2.69.10", http.Header{}, nil)
+	assert.Equal(t, "GB", locale.Country)
+	assert.Equal(t, "GBP", locale.Currency)
+	assert.True(t, locale.TaxInclusive)
+	assert.False(t, locale.ShipsToCountry)
+	assert.Equal(t, services.LocaleSourceGeoIP, locale.Source)
+
+	// The CDN header wins over the address ranges, unless it doesn't know
+	header := http.Header{}
+	header.Set("CF-IPCountry", "de")
+	locale = locales.Resolve(ctx, "81.2.69.10", header, nil)
+	assert.Equal(t, "DE", locale.Country)
+	assert.Equal(t, "EUR", locale.Currency)
+	assert.True(t, locale.ShipsToCountry)
+
+	header.Set("CF-IPCountry", "XX")
+	assert.Equal(t, "GB", locales.Resolve(ctx, "81.2.69.10", header, nil).Country)
+
+	// Preferences override the detected locale
+	user := f.User(func(u *models.User) {
+		u.Preferences = datatypes.JSON(`{"country": "ca", "currency": "usd", "tax_display": "inclusive"}`)
+	})
+	locale = locales.Resolve(ctx, "81.2.69.10", http.Header{}, &user.ID)
+	assert.Equal(t, "CA", locale.Country)
+	assert.Equal(t, "USD", locale.Currency)
+	assert.True(t, locale.TaxInclusive)
+	assert.True(t, locale.ShipsToCountry)
+	assert.Equal(t, services.LocaleSourcePreference, locale.Source)
+
+	// Customers without preferences keep the detected locale
+	plain := f.User()
+	assert.Equal(t, "GB", locales.Resolve(ctx, "81.2.69.10", http.Header{}, &plain.ID).Country)
+
+	ctx = services.WithStoreLocale(ctx, locale)
+	fromContext, ok := services.StoreLocaleFromContext(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, locale, fromContext)
+}
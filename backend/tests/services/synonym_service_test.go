@@ -0,0 +1,74 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"chat-ecommerce-backend/tests/testutil/factories"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSynonymService_Rewrite(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	svc := services.NewSynonymService(db)
+	ctx := context.Background()
+
+	headphones := f.Product(func(p *models.Product) { p.Name = "Wireless Headphones" })
+	f.Product(func(p *models.Product) { p.Name = "Cotton T-Shirt" })
+
+	_, err := svc.CreateSynonym(ctx, services.SynonymRequest{Term: "Earbuds", Synonyms: []string{"headphones", "earbuds"}})
+	require.NoError(t, err)
+	_, err = svc.CreateSynonym(ctx, services.SynonymRequest{Term: "tee", Synonyms: []string{"t-shirt"}})
+	require.NoError(t, err)
+
+	_, err = svc.CreateSynonym(ctx, services.SynonymRequest{Term: "earbuds ", Synonyms: []string{"buds"}})
+	assert.ErrorIs(t, err, services.ErrSynonymExists, "terms are unique regardless of case and spacing")
+	_, err = svc.CreateSynonym(ctx, services.SynonymRequest{Term: "mug", Synonyms: []string{"mug"}})
+	assert.Error(t, err, "a term can't be its own synonym")
+
+	rewrite := svc.Rewrite(ctx, "wireless earbuds")
+	assert.Equal(t, "wireless earbuds", rewrite.Corrected)
+	assert.Contains(t, rewrite.Queries, "wireless headphones")
+
+	rewrite = svc.Rewrite(ctx, "Wireles headphnes")
+	assert.Equal(t, "wireless headphones", rewrite.Corrected, "misspelled words are corrected against the catalog")
+	assert.Equal(t, "wireless headphones", rewrite.Queries[0])
+
+	rewrite = svc.Rewrite(ctx, "blue tee")
+	assert.Contains(t, rewrite.Queries, "blue t-shirt")
+
+	// Searches go through the rewrite
+	products, err := services.NewProductService(db).WithSynonyms(svc).SearchProducts("earbuds", 10)
+	require.NoError(t, err)
+	require.Len(t, products, 1)
+	assert.Equal(t, headphones.ID, products[0].ID)
+}
+
+func TestSynonymService_ManageDictionary(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	svc := services.NewSynonymService(db)
+	ctx := context.Background()
+
+	entry, err := svc.CreateSynonym(ctx, services.SynonymRequest{Term: "sofa", Synonyms: []string{"couch"}})
+	require.NoError(t, err)
+	assert.Contains(t, svc.Rewrite(ctx, "sofa").Queries, "couch")
+
+	_, err = svc.UpdateSynonym(ctx, entry.ID, services.SynonymRequest{Term: "sofa", Synonyms: []string{"settee"}})
+	require.NoError(t, err)
+	queries := svc.Rewrite(ctx, "sofa").Queries
+	assert.Contains(t, queries, "settee", "changes apply to the next search")
+	assert.NotContains(t, queries, "couch")
+
+	require.NoError(t, svc.DeleteSynonym(ctx, entry.ID))
+	assert.Equal(t, []string{"sofa"}, svc.Rewrite(ctx, "sofa").Queries)
+	assert.ErrorIs(t, svc.DeleteSynonym(ctx, entry.ID), services.ErrSynonymNotFound)
+
+	entries, err := svc.ListSynonyms(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"chat-ecommerce-backend/tests/testutil/factories"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTaxConfig_Breakdown(t *testing.T) {
+	t.Setenv("TAX_RATES", "GB=20,DE=19%,bogus")
+	exclusive := services.TaxConfigFromEnv()
+	assert.Equal(t, 0.08, exclusive.Rate("US"))
+	assert.Equal(t, 0.2, exclusive.Rate("gb"))
+
+	breakdown := exclusive.Breakdown(100, "GB")
+	assert.False(t, breakdown.Included)
+	assert.Equal(t, 100.0, breakdown.Net)
+	assert.Equal(t, 20.0, breakdown.Tax)
+	assert.Equal(t, 120.0, breakdown.Gross)
+
+	// Tax-inclusive prices have their tax extracted, not added
+	t.Setenv("PRICES_INCLUDE_TAX", "true")
+	inclusive := services.TaxConfigFromEnv()
+	breakdown = inclusive.Breakdown(120, "GB")
+	assert.True(t, breakdown.Included)
+	assert.Equal(t, 100.0, breakdown.Net)
+	assert.Equal(t, 20.0, breakdown.Tax)
+	assert.Equal(t, 120.0, breakdown.Gross)
+
+	assert.Equal(t, 119.0, exclusive.DisplayPrice(100, "DE", true))
+	assert.Equal(t, 100.0, exclusive.DisplayPrice(100, "DE", false))
+	assert.Equal(t, 100.0, inclusive.DisplayPrice(119, "DE", false))
+	assert.Equal(t, 119.0, inclusive.DisplayPrice(119, "DE", true))
+}
+
+func TestOrderService_CreateOrder_TaxInclusivePrices(t *testing.T) {
+	t.Setenv("TAX_RATES", "GB=20")
+	t.Setenv("PRICES_INCLUDE_TAX", "true")
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	ctx := context.Background()
+	product := f.StockedProduct(5, func(p *models.Product) { p.Price = 60 })
+	orders := services.NewOrderService(db)
+
+	order, err := orders.CreateOrder(ctx, &services.CreateOrderRequest{
+		SessionID:       "vat-session",
+		Items:           []services.OrderItemRequest{{ProductID: product.ID, Quantity: 2}},
+		ShippingAddress: map[string]interface{}{"country": "GB"},
+		BillingAddress:  map[string]interface{}{"country": "GB"},
+	})
+	require.NoError(t, err)
+	assert.True(t, order.TaxIncluded)
+	require.NotNil(t, order.TaxRate)
+	assert.Equal(t, 0.2, *order.TaxRate)
+	assert.Equal(t, 120.0, order.Subtotal)
+	assert.Equal(t, 20.0, order.TaxAmount)
+	assert.InDelta(t, 120+order.ShippingAmount, order.TotalAmount, 0.001, "included tax isn't charged twice")
+
+	result, err := orders.RecalculateOrder(ctx, order.ID)
+	require.NoError(t, err)
+	assert.True(t, result.Balanced, "%+v", result.Discrepancies)
+
+	// The cart breaks the tax out at the customer's rate
+	carts := services.NewShoppingCartService(db)
+	require.NoError(t, carts.AddToCart("vat-session", nil, services.AddToCartRequest{ProductID: product.ID, Quantity: 1}))
+	cart, err := carts.GetCart("vat-session", nil)
+	require.NoError(t, err)
+	assert.True(t, cart.TaxIncluded)
+
+	totals, err := carts.CalculateCartTotals(services.WithStoreLocale(ctx, services.StoreLocale{Country: "GB"}), cart)
+	require.NoError(t, err)
+	assert.Equal(t, 0.2, totals.TaxRate)
+	assert.Equal(t, 10.0, totals.TaxAmount)
+	assert.InDelta(t, 60+totals.ShippingAmount, totals.TotalAmount, 0.001)
+}
+
+func TestChatService_QuotesPricesInLocaleTaxMode(t *testing.T) {
+	t.Setenv("TAX_RATES", "GB=20")
+	fake := services.NewFakeLLM("Here are some headphones.")
+	service, _, _ := setupFakeLLMChat(t, fake)
+
+	ctx := services.WithStoreLocale(context.Background(), services.StoreLocale{Country: "GB", Currency: "GBP", StoreCurrency: "USD", TaxInclusive: true, ShipsToCountry: true})
+	_, err := service.ProcessMessage(ctx, "vat-chat", nil, "Show me wireless headphones")
+	require.NoError(t, err)
+
+	req, err := fake.LastRequest()
+	require.NoError(t, err)
+	prompt := req.Messages[0].Content
+	assert.Contains(t, prompt, `"price":119.99`, "the 99.99 catalog price is quoted with 20% tax")
+	assert.Contains(t, prompt, "include 20% tax")
+	assert.Contains(t, prompt, "Catalog prices are in USD")
+}
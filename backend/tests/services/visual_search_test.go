@@ -0,0 +1,78 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"chat-ecommerce-backend/tests/testutil/factories"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pngHeader is enough of a PNG for its format to be sniffed
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func TestChatService_ImageMessageSuggestsLookalikes(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	ctx := context.Background()
+
+	bags := f.Category(func(c *models.Category) { c.Name = "Bags" })
+	tote := f.Product(func(p *models.Product) {
+		p.Name = "Red Leather Tote"
+		p.Description = "A roomy leather handbag"
+		p.CategoryID = bags.ID
+	})
+	f.Product(func(p *models.Product) { p.Name = "Red Wool Scarf" })
+	f.Product(func(p *models.Product) { p.Name = "Blue Denim Jacket" })
+	require.NoError(t, db.Create(&models.ChatSession{ID: uuid.New(), SessionID: "photo-chat", Status: "active", LastActivity: time.Now()}).Error)
+
+	fake := services.NewFakeLLM(
+		`{"description": "A red leather tote bag", "product_type": "tote", "category": "Bags", "colors": ["red"], "materials": ["leather"], "keywords": ["handbag"]}`,
+		"That looks like our Red Leather Tote!",
+	)
+	chat := services.NewChatServiceWithProvider(db, fake, services.NewProductService(db), services.NewShoppingCartService(db)).
+		WithVisualSearch(services.NewVisualSearchService(fake, services.VisualSearchConfig{Model: "gpt-4o-mini"}))
+
+	response, err := chat.ProcessImageMessage(ctx, "photo-chat", nil, "", services.ChatImage{Data: pngHeader})
+	require.NoError(t, err)
+	assert.Equal(t, "That looks like our Red Leather Tote!", response.Message)
+	require.NotEmpty(t, response.Suggestions)
+	assert.Equal(t, tote.ID, response.Suggestions[0].Product.ID)
+	assert.Equal(t, "Looks like your photo", response.Suggestions[0].Reason)
+	assert.Greater(t, response.Suggestions[0].Confidence, response.Suggestions[len(response.Suggestions)-1].Confidence)
+
+	requests := fake.Requests()
+	require.Len(t, requests, 2)
+	vision := requests[0].Messages[1]
+	require.Len(t, vision.Images, 1)
+	assert.True(t, strings.HasPrefix(vision.Images[0], "data:image/png;base64,"))
+	assert.Contains(t, requests[0].Messages[0].Content, "Bags")
+	last := requests[1].Messages[len(requests[1].Messages)-1]
+	assert.Contains(t, last.Content, "[Attached photo: A red leather tote bag]")
+}
+
+func TestChatService_ImageMessageValidation(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	ctx := context.Background()
+	fake := services.NewFakeLLM()
+	chat := services.NewChatServiceWithProvider(db, fake, services.NewProductService(db), services.NewShoppingCartService(db))
+
+	_, err := chat.ProcessImageMessage(ctx, "photo-chat", nil, "", services.ChatImage{Data: pngHeader})
+	assert.ErrorIs(t, err, services.ErrVisualSearchUnavailable)
+
+	chat.WithVisualSearch(services.NewVisualSearchService(fake, services.VisualSearchConfig{Model: "gpt-4o-mini", MaxImageBytes: 32}))
+	_, err = chat.ProcessImageMessage(ctx, "photo-chat", nil, "", services.ChatImage{Data: []byte("just some text")})
+	assert.ErrorIs(t, err, services.ErrInvalidChatImage)
+	_, err = chat.ProcessImageMessage(ctx, "photo-chat", nil, "", services.ChatImage{Data: append(pngHeader, make([]byte, 32)...)})
+	assert.ErrorIs(t, err, services.ErrChatImageTooLarge)
+	_, err = chat.ProcessImageMessage(ctx, "photo-chat", nil, "", services.ChatImage{URL: "file:///etc/passwd"})
+	assert.ErrorIs(t, err, services.ErrInvalidChatImage)
+	assert.Zero(t, fake.CallCount(), "invalid images never reach the model")
+}
@@ -0,0 +1,81 @@
+package services
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// webmHeader is enough of a WebM recording for its format to be sniffed
+var webmHeader = []byte("\x1a\x45\xdf\xa3\x9f\x42\x86\x81\x01")
+
+func voiceConfig() services.VoiceConfig {
+	return services.VoiceConfig{STTModel: "whisper-1", TTSModel: "tts-1", TTSVoice: "alloy", MaxAudioBytes: 64}
+}
+
+func TestChatService_VoiceMessageAnswersTranscript(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	ctx := context.Background()
+	require.NoError(t, db.Create(&models.ChatSession{ID: uuid.New(), SessionID: "voice-chat", Status: "active", LastActivity: time.Now()}).Error)
+
+	fake := services.NewFakeLLM("We have plenty of running shoes!")
+	speech := services.NewFakeSpeech("  Do you have running shoes?  ")
+	chat := services.NewChatServiceWithProvider(db, fake, services.NewProductService(db), services.NewShoppingCartService(db)).
+		WithVoice(services.NewVoiceService(speech, voiceConfig()))
+
+	response, err := chat.ProcessVoiceMessage(ctx, "voice-chat", nil, services.ChatAudio{Data: webmHeader})
+	require.NoError(t, err)
+	assert.Equal(t, "Do you have running shoes?", response.Transcript)
+	assert.Equal(t, "We have plenty of running shoes!", response.Message)
+	assert.Equal(t, "Do you have running shoes?", response.Context["transcript"])
+	require.Len(t, speech.Heard(), 1)
+	assert.Equal(t, "video/webm", speech.Heard()[0].ContentType)
+
+	last, err := fake.LastRequest()
+	require.NoError(t, err)
+	assert.Contains(t, last.Messages[len(last.Messages)-1].Content, "Do you have running shoes?")
+
+	require.NotNil(t, response.ReplyID)
+	spoken, err := chat.SpeakReply(ctx, "voice-chat", *response.ReplyID)
+	require.NoError(t, err)
+	assert.Equal(t, "audio/mpeg", spoken.ContentType)
+	assert.Equal(t, "We have plenty of running shoes!", string(spoken.Data))
+
+	_, err = chat.SpeakReply(ctx, "another-chat", *response.ReplyID)
+	assert.ErrorIs(t, err, services.ErrVoiceReplyNotFound, "replies are only read aloud in their own session")
+}
+
+func TestChatService_VoiceMessageValidation(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	ctx := context.Background()
+	fake := services.NewFakeLLM()
+	speech := services.NewFakeSpeech()
+	chat := services.NewChatServiceWithProvider(db, fake, services.NewProductService(db), services.NewShoppingCartService(db))
+
+	_, err := chat.ProcessVoiceMessage(ctx, "voice-chat", nil, services.ChatAudio{Data: webmHeader})
+	assert.ErrorIs(t, err, services.ErrVoiceUnavailable)
+
+	config := voiceConfig()
+	config.TTSModel = ""
+	chat.WithVoice(services.NewVoiceService(speech, config))
+	_, err = chat.ProcessVoiceMessage(ctx, "voice-chat", nil, services.ChatAudio{Data: []byte("just some text")})
+	assert.ErrorIs(t, err, services.ErrInvalidChatAudio)
+	_, err = chat.ProcessVoiceMessage(ctx, "voice-chat", nil, services.ChatAudio{Data: append(webmHeader, make([]byte, 64)...)})
+	assert.ErrorIs(t, err, services.ErrChatAudioTooLarge)
+	assert.Empty(t, speech.Heard(), "invalid recordings are never transcribed")
+
+	_, err = chat.ProcessVoiceMessage(ctx, "voice-chat", nil, services.ChatAudio{Data: []byte("fLaC\x00\x00\x00\x22")})
+	assert.ErrorIs(t, err, services.ErrInvalidChatAudio, "silence isn't answered")
+	assert.Zero(t, fake.CallCount())
+
+	assert.False(t, chat.CanSpeak())
+	_, err = chat.SpeakReply(ctx, "voice-chat", uuid.New())
+	assert.ErrorIs(t, err, services.ErrSpeechUnavailable)
+}
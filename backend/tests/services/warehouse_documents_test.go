@@ -0,0 +1,90 @@
+package services
+
+import (
+	"bytes"
+	"chat-ecommerce-backend/internal/models"
+	"chat-ecommerce-backend/internal/services"
+	"chat-ecommerce-backend/tests/testutil"
+	"chat-ecommerce-backend/tests/testutil/factories"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrderService_PickListAndPackingSlips(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	f := factories.New(t, db)
+	ctx := context.Background()
+	orders := services.NewOrderService(db)
+
+	mug := f.Product(func(p *models.Product) { p.SKU = "MUG-1"; p.Price = 12 })
+	f.Inventory(mug, func(i *models.Inventory) { i.WarehouseLocation = "B-02" })
+	tea := f.Product(func(p *models.Product) { p.SKU = "TEA-1"; p.Price = 8 })
+	f.Inventory(tea, func(i *models.Inventory) { i.WarehouseLocation = "A-01" })
+
+	place := func(session string, gift *services.GiftOptions, lines ...services.OrderItemRequest) *models.Order {
+		order, err := orders.CreateOrder(ctx, &services.CreateOrderRequest{
+			SessionID:       session,
+			Items:           lines,
+			ShippingAddress: map[string]interface{}{"name": "Ada Lovelace", "city": "London", "country": "GB"},
+			BillingAddress:  map[string]interface{}{"country": "GB"},
+			Gift:            gift,
+		})
+		require.NoError(t, err)
+		// Order numbers only change once a second
+		order.OrderNumber = "ORD-" + session
+		require.NoError(t, db.Model(order).Update("order_number", order.OrderNumber).Error)
+		return order
+	}
+	first := place("pick-1", nil,
+		services.OrderItemRequest{ProductID: mug.ID, Quantity: 2},
+		services.OrderItemRequest{ProductID: tea.ID, Quantity: 1})
+	second := place("pick-2", &services.GiftOptions{GiftWrap: true, GiftMessage: "Enjoy!"},
+		services.OrderItemRequest{ProductID: mug.ID, Quantity: 1})
+
+	list, err := orders.PickList(ctx, services.PickListFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, 2, list.Fulfillments)
+	assert.Equal(t, 4, list.Units)
+	require.Len(t, list.Locations, 2)
+	assert.Equal(t, "A-01", list.Locations[0].WarehouseLocation, "locations are walked in order")
+	mugs := list.Locations[1]
+	assert.Equal(t, "B-02", mugs.WarehouseLocation)
+	require.Len(t, mugs.Lines, 1)
+	assert.Equal(t, 3, mugs.Lines[0].Quantity, "the same product is picked once for every order")
+	assert.Len(t, mugs.Lines[0].Orders, 2)
+
+	list, err = orders.PickList(ctx, services.PickListFilter{Locations: []string{"a-01"}})
+	require.NoError(t, err)
+	require.Len(t, list.Locations, 1)
+	assert.Equal(t, 1, list.Units)
+
+	slip, err := orders.PackingSlip(ctx, first.ID, first.Fulfillments[0].ID)
+	require.NoError(t, err)
+	assert.False(t, slip.Gift)
+	require.Len(t, slip.Items, 2)
+	require.NotNil(t, slip.Subtotal)
+	assert.Equal(t, 32.0, *slip.Subtotal)
+	assert.True(t, bytes.HasPrefix(services.RenderPackingSlipPDF(slip), []byte("%PDF")))
+
+	// Gift orders show the message and no prices
+	slip, err = orders.PackingSlip(ctx, second.ID, second.Fulfillments[0].ID)
+	require.NoError(t, err)
+	assert.True(t, slip.Gift)
+	assert.Equal(t, "Enjoy!", slip.GiftMessage)
+	assert.Nil(t, slip.Subtotal)
+	assert.Nil(t, slip.Items[0].UnitPrice)
+	assert.Equal(t, "B-02", slip.Items[0].WarehouseLocation)
+
+	// Shipped fulfillments drop off the pick list
+	_, _, err = orders.UpdateFulfillment(ctx, second.ID, second.Fulfillments[0].ID, services.UpdateFulfillmentRequest{Status: services.FulfillmentShipped})
+	require.NoError(t, err)
+	list, err = orders.PickList(ctx, services.PickListFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, 1, list.Fulfillments)
+
+	_, err = orders.PackingSlip(ctx, first.ID, second.Fulfillments[0].ID)
+	assert.ErrorIs(t, err, services.ErrFulfillmentNotFound)
+}
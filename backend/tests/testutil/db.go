@@ -0,0 +1,318 @@
+// Package testutil provides shared helpers for the backend test suites.
+//
+// By default NewTestDB returns a SQLite database in a temporary file. Set
+// TEST_DB_DRIVER=postgres to run the same suites against PostgreSQL so jsonb,
+// ILIKE and gen_random_uuid behave as in production. The server is taken from
+// TEST_POSTGRES_DSN, or started with testcontainers-go when the tests are
+// built with the "testcontainers" tag.
+package testutil
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	authmodels "chat-ecommerce-backend/internal/models/auth"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/schema"
+)
+
+// startPostgres launches a throwaway PostgreSQL server and returns its DSN.
+// It is set by postgres_container.go when built with the testcontainers tag.
+var startPostgres func() (string, error)
+
+var (
+	postgresOnce sync.Once
+	postgresDSN  string
+	postgresErr  error
+)
+
+// DefaultModels lists the models migrated by NewTestDB when none are given
+func DefaultModels() []interface{} {
+	return []interface{}{
+		&models.Category{},
+		&models.Brand{},
+		&models.Product{},
+		&models.ProductVariant{},
+		&models.ProductImage{},
+		&models.CategorySlugRedirect{},
+		&models.Inventory{},
+		&models.InventoryAlert{},
+		&models.InventoryReservation{},
+		&models.User{},
+		&models.ChatSession{},
+		&models.ChatMessage{},
+		&models.ShoppingCart{},
+		&models.Order{},
+		&models.OrderItem{},
+		&models.StoreSettings{},
+		&models.ChatAnalytics{},
+		&models.ChatEvent{},
+		&models.StorefrontEvent{},
+		&models.SessionLink{},
+		&models.SocialProofSettings{},
+		&models.FlashSale{},
+		&models.FlashSaleShopper{},
+		&models.ChatTokenUsage{},
+		&models.PromptTemplate{},
+		&models.PricingRule{},
+		&models.Segment{},
+		&models.SegmentMembership{},
+		&models.Quote{},
+		&models.QuoteItem{},
+		&models.CustomerGroup{},
+		&models.GroupPrice{},
+		&models.OrderRule{},
+		&models.Fulfillment{},
+		&models.PaymentRetry{},
+		&models.OrderExport{},
+		&models.OrderSubmission{},
+		&models.PaymentTransaction{},
+		&models.PayoutReconciliation{},
+		&models.AccountingExport{},
+		&models.PriceHistory{},
+		&models.ProductChangeRequest{},
+		&models.ProductRevision{},
+		&models.SearchSynonym{},
+		&models.SearchMiss{},
+		&models.SearchBoost{},
+		&models.PinnedSearchResult{},
+		&models.ProductQuestion{},
+		&models.LoginAttempt{},
+		&models.SecurityNotification{},
+		&models.ConsentRecord{},
+		&models.OutboundMessage{},
+		&models.DemandForecast{},
+		&models.AdminAssistantAction{},
+		&models.BroadcastCampaign{},
+		&models.CampaignDelivery{},
+		&models.StoreHours{},
+		&models.EscalationMessage{},
+		&models.APIUsageBucket{},
+		&models.MaintenanceWindow{},
+		&models.BlockedRequest{},
+		&models.AsyncJob{},
+		&authmodels.PasswordResetToken{},
+		&authmodels.AccountUnlockToken{},
+		&authmodels.RefreshToken{},
+	}
+}
+
+// UsingPostgres reports whether the suites run against PostgreSQL
+func UsingPostgres() bool {
+	return strings.EqualFold(os.Getenv("TEST_DB_DRIVER"), "postgres")
+}
+
+// RequirePostgres skips tests of queries only PostgreSQL runs, such as
+// full-text search
+func RequirePostgres(t testing.TB) {
+	t.Helper()
+	if !UsingPostgres() {
+		t.Skip("needs PostgreSQL; run with TEST_DB_DRIVER=postgres")
+	}
+}
+
+// NewTestDB opens an isolated test database and migrates the given models
+// (or DefaultModels). The connection is closed when the test finishes.
+func NewTestDB(t testing.TB, migrate ...interface{}) *gorm.DB {
+	t.Helper()
+
+	var db *gorm.DB
+	if UsingPostgres() {
+		db = openPostgres(t)
+	} else {
+		db = openSQLite(t)
+	}
+
+	if len(migrate) == 0 {
+		migrate = DefaultModels()
+	}
+	if err := db.AutoMigrate(migrate...); err != nil {
+		t.Fatal("Failed to migrate test database:", err)
+	}
+
+	return db
+}
+
+func openSQLite(t testing.TB) *gorm.DB {
+	t.Helper()
+
+	// A file in WAL mode rather than ":memory:" lets the pool open several
+	// connections, so queries made outside a transaction wait for it as they
+	// would on PostgreSQL instead of deadlocking on a single connection.
+	// Transactions take the write lock up front, as one upgraded from a read
+	// fails at once with "database is locked" instead of waiting its turn.
+	dsn := filepath.Join(t.TempDir(), "test.db") + "?_journal_mode=WAL&_busy_timeout=5000&_txlock=immediate"
+	db, err := gorm.Open(sqliteDialector{sqlite.Open(dsn).(*sqlite.Dialector)}, &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal("Failed to connect to test database:", err)
+	}
+	if err := db.Callback().Create().Before("gorm:create").Register("testutil:assign_uuids", assignUUIDs); err != nil {
+		t.Fatal("Failed to register UUID callback:", err)
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal("Failed to get database handle:", err)
+	}
+
+	t.Cleanup(func() {
+		sqlDB.Close()
+	})
+
+	return db
+}
+
+// sqliteDialector migrates models without their gen_random_uuid() defaults,
+// which SQLite can't parse. assignUUIDs fills those columns in on create.
+type sqliteDialector struct {
+	*sqlite.Dialector
+}
+
+func (d sqliteDialector) Migrator(db *gorm.DB) gorm.Migrator {
+	return sqliteMigrator{d.Dialector.Migrator(db)}
+}
+
+type sqliteMigrator struct {
+	gorm.Migrator
+}
+
+func (m sqliteMigrator) FullDataTypeOf(field *schema.Field) clause.Expr {
+	return m.Migrator.FullDataTypeOf(withoutUUIDDefault(field))
+}
+
+func (m sqliteMigrator) MigrateColumn(value interface{}, field *schema.Field, columnType gorm.ColumnType) error {
+	return m.Migrator.MigrateColumn(value, withoutUUIDDefault(field), columnType)
+}
+
+// generatesUUID reports whether PostgreSQL fills the field with gen_random_uuid()
+func generatesUUID(field *schema.Field) bool {
+	return field.HasDefaultValue && strings.HasPrefix(strings.ToLower(field.DefaultValue), "gen_random_uuid")
+}
+
+func withoutUUIDDefault(field *schema.Field) *schema.Field {
+	if !generatesUUID(field) {
+		return field
+	}
+	stripped := *field
+	stripped.HasDefaultValue = false
+	stripped.DefaultValue = ""
+	return &stripped
+}
+
+// assignUUIDs gives created records the random UUIDs PostgreSQL's column
+// defaults would
+func assignUUIDs(db *gorm.DB) {
+	if db.Statement.Schema == nil {
+		return
+	}
+	ctx := db.Statement.Context
+	assign := func(record reflect.Value) {
+		if record.Kind() != reflect.Struct {
+			return
+		}
+		for _, field := range db.Statement.Schema.Fields {
+			if !generatesUUID(field) {
+				continue
+			}
+			if _, zero := field.ValueOf(ctx, record); zero {
+				db.AddError(field.Set(ctx, record, uuid.New()))
+			}
+		}
+	}
+
+	switch records := db.Statement.ReflectValue; records.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < records.Len(); i++ {
+			assign(reflect.Indirect(records.Index(i)))
+		}
+	case reflect.Struct:
+		assign(records)
+	}
+}
+
+// openPostgres connects to the shared server and isolates the test in its own schema
+func openPostgres(t testing.TB) *gorm.DB {
+	t.Helper()
+
+	dsn, err := postgresServerDSN()
+	if err != nil {
+		t.Fatal("Failed to start PostgreSQL for tests:", err)
+	}
+
+	admin, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal("Failed to connect to PostgreSQL:", err)
+	}
+
+	schema := "test_" + strings.ReplaceAll(uuid.New().String(), "-", "")
+	if err := admin.Exec("CREATE EXTENSION IF NOT EXISTS pg_trgm").Error; err != nil {
+		t.Fatal("Failed to create pg_trgm extension:", err)
+	}
+	admin.Exec("CREATE EXTENSION IF NOT EXISTS unaccent")
+	if err := admin.Exec(fmt.Sprintf("CREATE SCHEMA %s", schema)).Error; err != nil {
+		t.Fatal("Failed to create test schema:", err)
+	}
+
+	db, err := gorm.Open(postgres.Open(withSearchPath(dsn, schema+",public")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal("Failed to connect to PostgreSQL test schema:", err)
+	}
+
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+		admin.Exec(fmt.Sprintf("DROP SCHEMA IF EXISTS %s CASCADE", schema))
+		if sqlDB, err := admin.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	return db
+}
+
+// postgresServerDSN returns TEST_POSTGRES_DSN or starts a container once per test binary
+func postgresServerDSN() (string, error) {
+	if dsn := os.Getenv("TEST_POSTGRES_DSN"); dsn != "" {
+		return dsn, nil
+	}
+
+	postgresOnce.Do(func() {
+		if startPostgres == nil {
+			postgresErr = fmt.Errorf("TEST_POSTGRES_DSN is not set; set it or run tests with -tags testcontainers")
+			return
+		}
+		postgresDSN, postgresErr = startPostgres()
+	})
+
+	return postgresDSN, postgresErr
+}
+
+// withSearchPath adds a search_path runtime parameter to a URL or key/value DSN
+func withSearchPath(dsn, searchPath string) string {
+	if strings.Contains(dsn, "://") {
+		separator := "?"
+		if strings.Contains(dsn, "?") {
+			separator = "&"
+		}
+		return dsn + separator + "search_path=" + searchPath
+	}
+	return dsn + " search_path=" + searchPath
+}
@@ -0,0 +1,248 @@
+// Package factories builds persisted test fixtures with sane defaults.
+//
+// Every builder takes optional mutators that run before the record is saved:
+//
+//	f := factories.New(t, db)
+//	product := f.Product(func(p *models.Product) { p.Price = 10 })
+//	f.Inventory(product, func(i *models.Inventory) { i.QuantityAvailable = 0 })
+package factories
+
+import (
+	"chat-ecommerce-backend/internal/models"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// DefaultPassword is the plain-text password for users created by Factory.User
+const DefaultPassword = "Password123!"
+
+// Factory creates fixtures in a test database
+type Factory struct {
+	t  testing.TB
+	db *gorm.DB
+
+	mu  sync.Mutex
+	seq int
+}
+
+// OrderLine describes a product and quantity to include in an order
+type OrderLine struct {
+	Product  *models.Product
+	Quantity int
+}
+
+// New creates a Factory bound to the given test and database
+func New(t testing.TB, db *gorm.DB) *Factory {
+	return &Factory{t: t, db: db}
+}
+
+// DB returns the underlying database
+func (f *Factory) DB() *gorm.DB {
+	return f.db
+}
+
+// next returns a unique sequence number for generating distinct slugs, SKUs and emails
+func (f *Factory) next() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.seq++
+	return f.seq
+}
+
+func (f *Factory) create(value interface{}) {
+	f.t.Helper()
+	if err := f.db.Create(value).Error; err != nil {
+		f.t.Fatalf("factories: failed to create %T: %v", value, err)
+	}
+}
+
+// Category creates an active category
+func (f *Factory) Category(opts ...func(*models.Category)) *models.Category {
+	f.t.Helper()
+	n := f.next()
+	category := &models.Category{
+		ID:          uuid.New(),
+		Name:        fmt.Sprintf("Category %d", n),
+		Description: "Test category",
+		Slug:        fmt.Sprintf("category-%d", n),
+		IsActive:    true,
+	}
+	for _, opt := range opts {
+		opt(category)
+	}
+	f.create(category)
+	return category
+}
+
+// Product creates an active product, creating a category when none is set
+func (f *Factory) Product(opts ...func(*models.Product)) *models.Product {
+	f.t.Helper()
+	n := f.next()
+	product := &models.Product{
+		ID:          uuid.New(),
+		Name:        fmt.Sprintf("Product %d", n),
+		Description: "Test product description",
+		Price:       99.99,
+		SKU:         fmt.Sprintf("TEST-%03d", n),
+		Status:      "active",
+	}
+	for _, opt := range opts {
+		opt(product)
+	}
+	if product.CategoryID == uuid.Nil {
+		product.CategoryID = f.Category().ID
+	}
+	f.create(product)
+	return product
+}
+
+// Variant creates a variant for the product
+func (f *Factory) Variant(product *models.Product, opts ...func(*models.ProductVariant)) *models.ProductVariant {
+	f.t.Helper()
+	n := f.next()
+	variant := &models.ProductVariant{
+		ID:           uuid.New(),
+		ProductID:    product.ID,
+		VariantName:  "Color",
+		VariantValue: fmt.Sprintf("Color %d", n),
+		SKUSuffix:    fmt.Sprintf("V%d", n),
+	}
+	for _, opt := range opts {
+		opt(variant)
+	}
+	f.create(variant)
+	return variant
+}
+
+// Image creates an image for the product
+func (f *Factory) Image(product *models.Product, opts ...func(*models.ProductImage)) *models.ProductImage {
+	f.t.Helper()
+	n := f.next()
+	image := &models.ProductImage{
+		ID:        uuid.New(),
+		ProductID: product.ID,
+		URL:       fmt.Sprintf("https://example.com/images/%d.jpg", n),
+		AltText:   product.Name,
+		SortOrder: n,
+	}
+	for _, opt := range opts {
+		opt(image)
+	}
+	f.create(image)
+	return image
+}
+
+// Inventory creates a stock record for the product with 100 units available
+func (f *Factory) Inventory(product *models.Product, opts ...func(*models.Inventory)) *models.Inventory {
+	f.t.Helper()
+	inventory := &models.Inventory{
+		ID:                uuid.New(),
+		ProductID:         product.ID,
+		WarehouseLocation: "main",
+		QuantityAvailable: 100,
+		LowStockThreshold: 10,
+		ReorderPoint:      5,
+	}
+	for _, opt := range opts {
+		opt(inventory)
+	}
+	f.create(inventory)
+	return inventory
+}
+
+// StockedProduct creates a product together with its inventory record
+func (f *Factory) StockedProduct(quantity int, opts ...func(*models.Product)) *models.Product {
+	f.t.Helper()
+	product := f.Product(opts...)
+	f.Inventory(product, func(i *models.Inventory) { i.QuantityAvailable = quantity })
+	return product
+}
+
+// User creates an active, verified user whose password is DefaultPassword
+func (f *Factory) User(opts ...func(*models.User)) *models.User {
+	f.t.Helper()
+	n := f.next()
+
+	// MinCost keeps fixture creation fast; production code uses DefaultCost
+	hash, err := bcrypt.GenerateFromPassword([]byte(DefaultPassword), bcrypt.MinCost)
+	if err != nil {
+		f.t.Fatalf("factories: failed to hash password: %v", err)
+	}
+
+	user := &models.User{
+		ID:            uuid.New(),
+		Email:         fmt.Sprintf("user%d@example.com", n),
+		PasswordHash:  string(hash),
+		FirstName:     "Test",
+		LastName:      fmt.Sprintf("User%d", n),
+		EmailVerified: true,
+		Status:        "active",
+		AccountState:  "active",
+	}
+	for _, opt := range opts {
+		opt(user)
+	}
+	f.create(user)
+	return user
+}
+
+// Order creates a pending order for the user with the given lines, using the
+// same tax (8%) and shipping ($9.99) rules as OrderService.CreateOrder
+func (f *Factory) Order(user *models.User, lines []OrderLine, opts ...func(*models.Order)) *models.Order {
+	f.t.Helper()
+	n := f.next()
+
+	address, _ := json.Marshal(map[string]string{
+		"street":      "123 Test St",
+		"city":        "Testville",
+		"state":       "CA",
+		"postal_code": "90001",
+		"country":     "US",
+	})
+
+	order := &models.Order{
+		ID:              uuid.New(),
+		OrderNumber:     fmt.Sprintf("ORD-TEST-%d-%d", time.Now().UnixNano(), n),
+		UserID:          user.ID,
+		SessionID:       fmt.Sprintf("session-%d", n),
+		Status:          "pending",
+		Currency:        "USD",
+		PaymentStatus:   "pending",
+		ShippingAddress: datatypes.JSON(address),
+		BillingAddress:  datatypes.JSON(address),
+	}
+
+	for _, line := range lines {
+		quantity := line.Quantity
+		if quantity == 0 {
+			quantity = 1
+		}
+		total := line.Product.Price * float64(quantity)
+		order.Subtotal += total
+		order.Items = append(order.Items, models.OrderItem{
+			ID:         uuid.New(),
+			OrderID:    order.ID,
+			ProductID:  line.Product.ID,
+			Quantity:   quantity,
+			UnitPrice:  line.Product.Price,
+			TotalPrice: total,
+		})
+	}
+	order.TaxAmount = order.Subtotal * 0.08
+	order.ShippingAmount = 9.99
+	order.TotalAmount = order.Subtotal + order.TaxAmount + order.ShippingAmount
+
+	for _, opt := range opts {
+		opt(order)
+	}
+	f.create(order)
+	return order
+}
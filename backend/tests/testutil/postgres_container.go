@@ -0,0 +1,39 @@
+//go:build testcontainers
+
+package testutil
+
+import (
+	"context"
+	"time"
+
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/modules/postgres"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+func init() {
+	startPostgres = startPostgresContainer
+}
+
+// startPostgresContainer runs the same image as docker-compose.yml. The
+// container is removed by the testcontainers reaper when the test binary exits.
+func startPostgresContainer() (string, error) {
+	ctx := context.Background()
+
+	container, err := postgres.Run(ctx,
+		"postgres:15-alpine",
+		postgres.WithDatabase("chat_ecommerce_test"),
+		postgres.WithUsername("postgres"),
+		postgres.WithPassword("postgres"),
+		testcontainers.WithWaitStrategy(
+			wait.ForLog("database system is ready to accept connections").
+				WithOccurrence(2).
+				WithStartupTimeout(60*time.Second),
+		),
+	)
+	if err != nil {
+		return "", err
+	}
+
+	return container.ConnectionString(ctx, "sslmode=disable")
+}
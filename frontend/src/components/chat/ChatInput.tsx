@@ -2,18 +2,25 @@ import React, { useState, useRef, useEffect } from 'react';
 
 interface ChatInputProps {
   onSendMessage: (message: string) => void;
+  onSendImage?: (image: File, message: string) => void;
+  onSendVoice?: (audio: Blob) => void;
   disabled?: boolean;
   placeholder?: string;
 }
 
 const ChatInput: React.FC<ChatInputProps> = ({ 
   onSendMessage, 
+  onSendImage,
+  onSendVoice,
   disabled = false, 
   placeholder = "Type your message..." 
 }) => {
   const [message, setMessage] = useState('');
   const [isComposing, setIsComposing] = useState(false);
   const textareaRef = useRef<HTMLTextAreaElement>(null);
+  const fileInputRef = useRef<HTMLInputElement>(null);
+  const recorderRef = useRef<MediaRecorder | null>(null);
+  const [isRecording, setIsRecording] = useState(false);
 
   useEffect(() => {
     if (textareaRef.current) {
@@ -49,6 +56,46 @@ const ChatInput: React.FC<ChatInputProps> = ({
     adjustTextareaHeight();
   };
 
+  // A photo goes with whatever was typed, to find products that look like it
+  const handleImageChange = (e: React.ChangeEvent<HTMLInputElement>) => {
+    const image = e.target.files?.[0];
+    e.target.value = '';
+    if (image && onSendImage && !disabled) {
+      onSendImage(image, message.trim());
+      setMessage('');
+      adjustTextareaHeight();
+    }
+  };
+
+  // The first click starts recording a voice message and the second sends it
+  const toggleRecording = async () => {
+    if (recorderRef.current) {
+      recorderRef.current.stop();
+      return;
+    }
+    if (!onSendVoice || disabled) return;
+
+    try {
+      const stream = await navigator.mediaDevices.getUserMedia({ audio: true });
+      const recorder = new MediaRecorder(stream);
+      const chunks: Blob[] = [];
+      recorder.ondataavailable = (event) => chunks.push(event.data);
+      recorder.onstop = () => {
+        stream.getTracks().forEach(track => track.stop());
+        recorderRef.current = null;
+        setIsRecording(false);
+        if (chunks.length > 0) {
+          onSendVoice(new Blob(chunks, { type: recorder.mimeType }));
+        }
+      };
+      recorderRef.current = recorder;
+      recorder.start();
+      setIsRecording(true);
+    } catch (err) {
+      console.error('Could not record audio:', err);
+    }
+  };
+
   const handleCompositionStart = () => {
     setIsComposing(true);
   };
@@ -89,6 +136,58 @@ const ChatInput: React.FC<ChatInputProps> = ({
         )}
       </div>
 
+      {onSendImage && (
+        <>
+          <input
+            ref={fileInputRef}
+            type="file"
+            accept="image/jpeg,image/png,image/gif,image/webp"
+            onChange={handleImageChange}
+            className="hidden"
+          />
+          <button
+            type="button"
+            onClick={() => fileInputRef.current?.click()}
+            disabled={disabled}
+            className="flex items-center justify-center w-12 h-12 rounded-lg border border-gray-300 text-gray-600 hover:bg-gray-100 disabled:opacity-50 disabled:cursor-not-allowed"
+            title="Find products like a photo"
+          >
+            <svg className="w-5 h-5" fill="none" stroke="currentColor" viewBox="0 0 24 24">
+              <path
+                strokeLinecap="round"
+                strokeLinejoin="round"
+                strokeWidth={2}
+                d="M3 9a2 2 0 012-2h.93a2 2 0 001.664-.89l.812-1.22A2 2 0 0110.07 4h3.86a2 2 0 011.664.89l.812 1.22A2 2 0 0018.07 7H19a2 2 0 012 2v9a2 2 0 01-2 2H5a2 2 0 01-2-2V9z"
+              />
+              <path strokeLinecap="round" strokeLinejoin="round" strokeWidth={2} d="M15 13a3 3 0 11-6 0 3 3 0 016 0z" />
+            </svg>
+          </button>
+        </>
+      )}
+
+      {onSendVoice && (
+        <button
+          type="button"
+          onClick={toggleRecording}
+          disabled={disabled && !isRecording}
+          className={`flex items-center justify-center w-12 h-12 rounded-lg border disabled:opacity-50 disabled:cursor-not-allowed ${
+            isRecording
+              ? 'border-red-500 bg-red-50 text-red-600 animate-pulse'
+              : 'border-gray-300 text-gray-600 hover:bg-gray-100'
+          }`}
+          title={isRecording ? 'Stop and send' : 'Ask with your voice'}
+        >
+          <svg className="w-5 h-5" fill="none" stroke="currentColor" viewBox="0 0 24 24">
+            <path
+              strokeLinecap="round"
+              strokeLinejoin="round"
+              strokeWidth={2}
+              d="M19 11a7 7 0 01-7 7m0 0a7 7 0 01-7-7m7 7v4m0 0H8m4 0h4m-4-8a3 3 0 01-3-3V5a3 3 0 116 0v6a3 3 0 01-3 3z"
+            />
+          </svg>
+        </button>
+      )}
+
       <button
         type="submit"
         disabled={disabled || !message.trim()}
@@ -1,8 +1,12 @@
 import React, { useState, useEffect, useRef } from 'react';
-import type { ChatMessage, ProductSuggestion, ChatAction } from '../../types';
+import type { ChatMessage, ProductCardSuggestion, ChatAction } from '../../types';
+import type { ServerMessage } from '../../types/generated/chat';
 import ChatInput from './ChatInput';
 import ChatMessageComponent from './ChatMessage';
 import fetchService from '../../utils/fetch';
+import { API_CONFIG } from '../../config/api';
+import { trackEvent } from '../../utils/clickstream';
+import { ensureChatSession, getStoredChatSessionId, replayStamp, storeChatSessionId } from '../../utils/chatSession';
 
 interface ChatInterfaceProps {
   sessionId?: string;
@@ -24,14 +28,26 @@ const ChatInterface: React.FC<ChatInterfaceProps> = ({
   const reconnecting = useRef<boolean>(false);
   const messagesContainerRef = useRef<HTMLDivElement | null>(null);
 
-  // Generate session ID if not provided - use useMemo to prevent regeneration
-  const currentSessionId = React.useMemo(() => {
-    return sessionId || `session_${Date.now()}_${Math.random().toString(36).substr(2, 9)}`;
+  // Session IDs are issued by the API, which only shows a session's history
+  // to the browser holding its cookie
+  const [currentSessionId, setCurrentSessionId] = useState<string>(sessionId || '');
+
+  useEffect(() => {
+    if (sessionId) {
+      setCurrentSessionId(sessionId);
+      return;
+    }
+    ensureChatSession()
+      .then(setCurrentSessionId)
+      .catch(err => {
+        console.error('Failed to start chat session:', err);
+        setError('Failed to connect to chat service.');
+      });
   }, [sessionId]);
 
   useEffect(() => {
     // Prevent multiple connection attempts due to React StrictMode
-    if (connectionAttempted.current) {
+    if (!currentSessionId || connectionAttempted.current) {
       return;
     }
     connectionAttempted.current = true;
@@ -112,35 +128,64 @@ const ChatInterface: React.FC<ChatInterfaceProps> = ({
     }
   };
 
-  const handleWebSocketMessage = (data: any) => {
+  const handleWebSocketMessage = (data: ServerMessage) => {
+    // The API starts a new session when the stored one is gone or not ours
+    if (data.session_id && !sessionId && data.session_id !== getStoredChatSessionId()) {
+      storeChatSessionId(data.session_id);
+    }
+
     switch (data.type) {
       case 'message':
         const message: ChatMessage = {
           id: data.data.id,
           sessionId: data.data.session_id,
           userId: data.data.user_id,
-          role: data.data.role,
+          role: data.data.role as ChatMessage['role'],
           content: data.data.content,
           metadata: data.data.metadata,
           timestamp: data.data.timestamp,
         };
-        // Prevent duplicate messages by checking if message already exists
+        // The final reply replaces the text streamed under the same id
         setMessages(prev => {
           const messageExists = prev.some(msg => msg.id === message.id);
           if (messageExists) {
-            return prev;
+            return prev.map(msg => (msg.id === message.id ? message : msg));
           }
           return [...prev, message];
         });
         break;
 
+      case 'chat_response':
+        // A piece of the reply, shown as the assistant writes it
+        setMessages(prev => {
+          const streaming = prev.find(msg => msg.id === data.data.id);
+          if (streaming) {
+            return prev.map(msg =>
+              msg.id === data.data.id ? { ...msg, content: msg.content + data.data.delta } : msg
+            );
+          }
+          return [
+            ...prev,
+            {
+              id: data.data.id,
+              sessionId: data.session_id,
+              role: 'assistant',
+              content: data.data.delta,
+              timestamp: new Date().toISOString(),
+            },
+          ];
+        });
+        break;
+
       case 'typing':
         setIsTyping(data.data.is_typing);
         break;
 
       case 'suggestions':
-        // Suggestions are now handled in message metadata, no need to store separately
-        console.log('Received suggestions:', data.data);
+        // Suggestions render from message metadata; here they're counted as shown
+        data.data.forEach((suggestion, position) =>
+          trackEvent({ type: 'suggestion_impression', product_id: suggestion.product.id, placement: 'chat', position })
+        );
         break;
 
       case 'actions':
@@ -151,6 +196,36 @@ const ChatInterface: React.FC<ChatInterfaceProps> = ({
         setError(data.data.message);
         break;
 
+      case 'agent_message':
+        // A member of staff who joined the chat answered
+        setMessages(prev => [
+          ...prev,
+          {
+            id: data.data.id,
+            sessionId: data.data.session_id,
+            role: 'agent',
+            content: data.data.content,
+            metadata: data.data.metadata,
+            timestamp: data.data.created_at,
+          },
+        ]);
+        break;
+
+      case 'agent_status':
+        setMessages(prev => [
+          ...prev,
+          {
+            id: `agent-status-${Date.now()}`,
+            sessionId: data.data.session_id,
+            role: 'system',
+            content: data.data.status === 'with_agent'
+              ? 'A member of our team has joined the chat'
+              : 'You are chatting with our assistant again',
+            timestamp: new Date().toISOString(),
+          },
+        ]);
+        break;
+
       default:
         console.log('Unknown message type:', data.type);
     }
@@ -174,7 +249,7 @@ const ChatInterface: React.FC<ChatInterfaceProps> = ({
 
   const loadChatHistory = async () => {
     try {
-      const result = await fetchService.get(`/api/v1/chat/history/${currentSessionId}`);
+      const result = await fetchService.get(`/api/v1/chat/history/${currentSessionId}`, { credentials: 'include' });
       if (result.data && result.data.success && result.data.data.messages) {
         setMessages(result.data.data.messages);
       } else if (result.error) {
@@ -206,6 +281,7 @@ const ChatInterface: React.FC<ChatInterfaceProps> = ({
     // Send to server
     const message = {
       type: 'message',
+      ...replayStamp(),
       data: {
         content,
         session_id: currentSessionId,
@@ -216,8 +292,124 @@ const ChatInterface: React.FC<ChatInterfaceProps> = ({
     wsRef.current.send(JSON.stringify(message));
   };
 
-  const handleSuggestionClick = (suggestion: ProductSuggestion) => {
+  // A photo is answered over HTTP with the products that look most like it
+  const sendImage = async (image: File, content: string) => {
+    const text = content || 'Do you have anything like this?';
+    setMessages(prev => [
+      ...prev,
+      {
+        id: `user-${Date.now()}`,
+        sessionId: currentSessionId,
+        userId: userId,
+        role: 'user',
+        content: text,
+        timestamp: new Date().toISOString(),
+      },
+    ]);
+    setIsTyping(true);
+
+    const form = new FormData();
+    form.append('image', image);
+    form.append('message', content);
+    form.append('session_id', currentSessionId);
+    const token = localStorage.getItem('auth_token');
+    try {
+      const response = await fetch(`${API_CONFIG.BASE_URL}/api/v1/chat/image`, {
+        method: 'POST',
+        body: form,
+        credentials: 'include',
+        headers: token ? { Authorization: `Bearer ${token}` } : undefined,
+      });
+      const result = await response.json();
+      if (!response.ok) {
+        setError(result.error || 'Could not read the photo');
+        return;
+      }
+      setMessages(prev => [
+        ...prev,
+        {
+          id: `assistant-${Date.now()}`,
+          sessionId: result.data.session_id,
+          role: 'assistant',
+          content: result.data.message,
+          metadata: { suggestions: result.data.suggestions || [] },
+          timestamp: new Date().toISOString(),
+        },
+      ]);
+    } catch {
+      setError('Could not send the photo');
+    } finally {
+      setIsTyping(false);
+    }
+  };
+
+  // Voice messages are transcribed by the server, which answers the
+  // transcript and reads the reply aloud
+  const sendVoice = async (audio: Blob) => {
+    setIsTyping(true);
+
+    const form = new FormData();
+    form.append('audio', audio, 'voice');
+    form.append('session_id', currentSessionId);
+    form.append('reply_audio', 'true');
+    const token = localStorage.getItem('auth_token');
+    try {
+      const response = await fetch(`${API_CONFIG.BASE_URL}/api/v1/chat/voice`, {
+        method: 'POST',
+        body: form,
+        credentials: 'include',
+        headers: token ? { Authorization: `Bearer ${token}` } : undefined,
+      });
+      const result = await response.json();
+      if (!response.ok) {
+        setError(result.error || 'Could not understand the recording');
+        return;
+      }
+      setMessages(prev => [
+        ...prev,
+        {
+          id: `user-${Date.now()}`,
+          sessionId: result.data.session_id,
+          userId: userId,
+          role: 'user',
+          content: result.data.transcript,
+          timestamp: new Date().toISOString(),
+        },
+        {
+          id: `assistant-${Date.now()}`,
+          sessionId: result.data.session_id,
+          role: 'assistant',
+          content: result.data.message,
+          metadata: { suggestions: result.data.suggestions || [] },
+          timestamp: new Date().toISOString(),
+        },
+      ]);
+      if (result.data.audio_url) {
+        new Audio(`${API_CONFIG.BASE_URL}${result.data.audio_url}`).play().catch(() => {});
+      }
+    } catch {
+      setError('Could not send the recording');
+    } finally {
+      setIsTyping(false);
+    }
+  };
+
+  // Report what the shopper does with suggestions for the chat funnel
+  const trackSuggestion = (type: 'suggestion_clicked' | 'add_to_cart', suggestion: ProductCardSuggestion) => {
+    if (!suggestion.product || !currentSessionId) {
+      return;
+    }
+    fetchService.post(
+      '/api/v1/chat/events',
+      { session_id: currentSessionId, type, product_id: suggestion.product.id },
+      { credentials: 'include' }
+    );
+  };
+
+  const handleSuggestionClick = (suggestion: ProductCardSuggestion) => {
     if (suggestion.product) {
+      trackSuggestion('suggestion_clicked', suggestion);
+      trackEvent({ type: 'suggestion_click', product_id: suggestion.product.id, placement: 'chat' });
       sendMessage(`Tell me more about ${suggestion.product.name}`);
     }
   };
@@ -272,6 +464,7 @@ const ChatInterface: React.FC<ChatInterfaceProps> = ({
               key={message.id}
               message={message}
               onSuggestionClick={handleSuggestionClick}
+              onSuggestionAddedToCart={(suggestion) => trackSuggestion('add_to_cart', suggestion)}
             />
           ))
         )}
@@ -293,6 +486,8 @@ const ChatInterface: React.FC<ChatInterfaceProps> = ({
       <div className="border-t border-gray-200 p-4">
         <ChatInput
           onSendMessage={sendMessage}
+          onSendImage={sendImage}
+          onSendVoice={sendVoice}
           disabled={!isConnected}
           placeholder={isConnected ? "Ask me about products..." : "Connecting..."}
         />
@@ -1,18 +1,22 @@
 import React from 'react';
-import type { ChatMessage, ProductSuggestion } from '../../types';
+import type { ChatMessage, ProductCardSuggestion } from '../../types';
 import ProductSuggestionCard from './ProductSuggestionCard';
 
 interface ChatMessageProps {
   message: ChatMessage;
-  onSuggestionClick?: (suggestion: ProductSuggestion) => void;
+  onSuggestionClick?: (suggestion: ProductCardSuggestion) => void;
+  onSuggestionAddedToCart?: (suggestion: ProductCardSuggestion) => void;
 }
 
 const ChatMessageComponent: React.FC<ChatMessageProps> = ({ 
   message, 
-  onSuggestionClick 
+  onSuggestionClick,
+  onSuggestionAddedToCart
 }) => {
   const isUser = message.role === 'user';
   const isSystem = message.role === 'system';
+  // Replies from a member of staff who joined the chat
+  const isAgent = message.role === 'agent';
 
   const formatTimestamp = (timestamp: string) => {
     try {
@@ -25,7 +29,7 @@ const ChatMessageComponent: React.FC<ChatMessageProps> = ({
 
   const renderMessageContent = () => {
     // Check if message contains product suggestions in metadata
-    const suggestions = message.metadata?.suggestions as ProductSuggestion[] || [];
+    const suggestions = message.metadata?.suggestions as ProductCardSuggestion[] || [];
     const actions = message.metadata?.actions || [];
 
     return (
@@ -49,6 +53,7 @@ const ChatMessageComponent: React.FC<ChatMessageProps> = ({
                   key={index}
                   suggestion={suggestion}
                   onClick={() => onSuggestionClick?.(suggestion)}
+                  onAddedToCart={() => onSuggestionAddedToCart?.(suggestion)}
                   showAddToCart={true}
                 />
               ))}
@@ -99,10 +104,12 @@ const ChatMessageComponent: React.FC<ChatMessageProps> = ({
           flex-shrink-0 w-8 h-8 rounded-full flex items-center justify-center text-sm font-medium
           ${isUser 
             ? 'bg-blue-400 text-white' 
-            : 'bg-gray-200 text-gray-600'
+            : isAgent
+              ? 'bg-green-500 text-white'
+              : 'bg-gray-200 text-gray-600'
           }
         `}>
-          {isUser ? (
+          {isUser || isAgent ? (
             <svg className="w-4 h-4" fill="currentColor" viewBox="0 0 20 20">
               <path fillRule="evenodd" d="M10 9a3 3 0 100-6 3 3 0 000 6zm-7 9a7 7 0 1114 0H3z" clipRule="evenodd" />
             </svg>
@@ -118,9 +125,14 @@ const ChatMessageComponent: React.FC<ChatMessageProps> = ({
           px-4 py-3 rounded-lg shadow-sm
           ${isUser 
             ? 'bg-blue-400 text-white' 
-            : 'bg-white text-gray-900 border border-gray-200'
+            : isAgent
+              ? 'bg-green-50 text-gray-900 border border-green-200'
+              : 'bg-white text-gray-900 border border-gray-200'
           }
         `}>
+          {isAgent && (
+            <div className="text-xs font-medium text-green-700 mb-1">Store team</div>
+          )}
           {renderMessageContent()}
           
           {/* Timestamp */}
@@ -1,11 +1,12 @@
 import React from 'react';
 import { useCart } from '../../contexts/CartContext';
 import CartActionButton from '../cart/CartActionButton';
-import type { ProductSuggestion } from '../../types';
+import type { ProductCardSuggestion } from '../../types';
 
 interface ProductSuggestionCardProps {
-  suggestion: ProductSuggestion;
+  suggestion: ProductCardSuggestion;
   onClick?: () => void;
+  onAddedToCart?: () => void;
   compact?: boolean;
   showAddToCart?: boolean;
 }
@@ -13,6 +14,7 @@ interface ProductSuggestionCardProps {
 const ProductSuggestionCard: React.FC<ProductSuggestionCardProps> = ({ 
   suggestion, 
   onClick, 
+  onAddedToCart,
   compact = false,
   showAddToCart = false
 }) => {
@@ -38,8 +40,7 @@ const ProductSuggestionCard: React.FC<ProductSuggestionCardProps> = ({
     return `$${price.toFixed(2)}`;
   };
 
-  // Check if product is out of stock
-  const isOutOfStock = product.inventory && product.inventory.some(inv => inv.quantity_available === 0);
+  const isOutOfStock = !product.in_stock;
 
   const cardClasses = `
     bg-white rounded-lg shadow-sm hover:shadow-md transition-shadow
@@ -50,9 +51,13 @@ const ProductSuggestionCard: React.FC<ProductSuggestionCardProps> = ({
 
   return (
     <div className={cardClasses} onClick={handleClick}>
-      {/* Product Image Placeholder */}
-      <div className="h-48 bg-gray-200 rounded-t-lg flex items-center justify-center border-b border-gray-300">
-        <span className="text-4xl text-gray-400">🛍️</span>
+      {/* Product Image */}
+      <div className="h-48 bg-gray-200 rounded-t-lg flex items-center justify-center border-b border-gray-300 overflow-hidden">
+        {product.image_url ? (
+          <img src={product.image_url} alt={product.name} className="h-full w-full object-cover" />
+        ) : (
+          <span className="text-4xl text-gray-400">🛍️</span>
+        )}
       </div>
 
       {/* Product Info */}
@@ -61,28 +66,31 @@ const ProductSuggestionCard: React.FC<ProductSuggestionCardProps> = ({
           {product.name}
         </h3>
         
-        <p className="text-sm text-gray-600 mb-3 line-clamp-2">
-          {product.description}
-        </p>
+        {reason && (
+          <p className="text-sm text-gray-600 mb-3 line-clamp-2">
+            {reason}
+          </p>
+        )}
 
         {/* Price and Category */}
         <div className="flex items-center justify-between mb-2">
           <span className="text-lg font-bold text-blue-600">
             {formatPrice(product.price)}
           </span>
-          {product.category && (
+          {product.category_name && (
             <span className="text-xs text-gray-500">
-              {product.category.name}
+              {product.category_name}
             </span>
           )}
         </div>
 
-        {/* Tags */}
-        {product.tags && product.tags.length > 0 && (
+        {/* Variant options */}
+        {product.variants && product.variants.length > 0 && (
           <div className="mb-3 flex flex-wrap gap-1">
-            {product.tags.slice(0, 2).map((tag) => (
-              <span key={tag} className="text-xs bg-gray-100 text-gray-600 px-2 py-1 rounded">
-                {tag}
+            {product.variants.slice(0, 2).map((option) => (
+              <span key={option.name} className="text-xs bg-gray-100 text-gray-600 px-2 py-1 rounded">
+                {option.values.length} {option.name.toLowerCase()}
+                {option.values.length === 1 ? '' : 's'}
               </span>
             ))}
           </div>
@@ -94,7 +102,13 @@ const ProductSuggestionCard: React.FC<ProductSuggestionCardProps> = ({
             <CartActionButton
               productId={product.id}
               currentCart={cart}
-              onAddToCart={addToCart}
+              onAddToCart={async (item) => {
+                const added = await addToCart(item);
+                if (added) {
+                  onAddedToCart?.();
+                }
+                return added;
+              }}
               onUpdateQuantity={async (item) => {
                 return await updateCartItem({
                   product_id: item.product_id,
@@ -1,4 +1,4 @@
-import React, { useState } from 'react';
+import React, { useRef, useState } from 'react';
 import { useNavigate } from 'react-router-dom';
 import { useCart } from '../../contexts/CartContext';
 import { useAuth } from '../../contexts/AuthContext';
@@ -15,7 +15,11 @@ const CheckoutForm: React.FC<CheckoutFormProps> = ({ onOrderCreated }) => {
   const { user, isAuthenticated } = useAuth();
   
   const [isSubmitting, setIsSubmitting] = useState(false);
+  const [queuePosition, setQueuePosition] = useState<number | null>(null);
+  const [checkoutStage, setCheckoutStage] = useState<string | null>(null);
   const [errors, setErrors] = useState<Record<string, string>>({});
+  // Identifies this checkout to the API, so sending it twice places one order
+  const checkoutKey = useRef(crypto.randomUUID());
   
   // Form state
   const [formData, setFormData] = useState({
@@ -136,6 +140,26 @@ const CheckoutForm: React.FC<CheckoutFormProps> = ({ onOrderCreated }) => {
     return Object.keys(newErrors).length === 0;
   };
 
+  // During a flash sale, checkouts of its products wait their turn in the
+  // waiting room
+  const waitForAdmission = async () => {
+    const status = (await apiService.getFlashSale()).data?.data;
+    const onSale = status?.sale?.product_ids ?? [];
+    if (!status?.active || !status.waiting_room || !cart?.items.some(item => onSale.includes(item.product_id))) {
+      return true;
+    }
+
+    let ticket = (await apiService.joinFlashSaleQueue()).data?.data;
+    while (ticket && !ticket.admitted) {
+      setQueuePosition(ticket.position);
+      const wait = (ticket.retry_after_seconds || 5) * 1000;
+      await new Promise(resolve => setTimeout(resolve, wait));
+      ticket = (await apiService.getFlashSaleTicket(ticket.token)).data?.data;
+    }
+    setQueuePosition(null);
+    return !!ticket?.admitted;
+  };
+
   // Handle form submission
   const handleSubmit = async (e: React.FormEvent) => {
     e.preventDefault();
@@ -194,37 +218,48 @@ const CheckoutForm: React.FC<CheckoutFormProps> = ({ onOrderCreated }) => {
         notes: formData.notes
       };
 
-      // Create order
-      const orderResponse = await apiService.createOrder(orderData);
-      
-      if (orderResponse.data) {
-        // Create payment intent
-        const paymentResponse = await apiService.createPaymentIntent({
-          order_id: orderResponse.data.id,
-          amount: Math.round(cart.total_amount * 100), // Convert to cents
-          currency: 'USD',
-          description: `Order ${orderResponse.data.order_number}`,
-          metadata: {
-            order_number: orderResponse.data.order_number
-          }
-        });
+      if (!(await waitForAdmission())) {
+        alert('The flash sale waiting room is closed. Please try again.');
+        return;
+      }
 
-        if (paymentResponse.data) {
-          // In a real implementation, you would integrate with Stripe Elements
-          // For now, we'll simulate a successful payment
-          await apiService.confirmPayment({
-            payment_intent_id: paymentResponse.data.id,
-            order_id: orderResponse.data.id
-          });
+      // The order is accepted at once and created in the background
+      const accepted = (await apiService.submitOrder(orderData, checkoutKey.current)).data?.data;
+      if (!accepted) {
+        alert('Checkout failed. Please try again.');
+        return;
+      }
 
-          // Clear cart
-          await clearCart();
-          
-          // Redirect to order confirmation
-          onOrderCreated?.(orderResponse.data.id);
-          navigate(`/orders/${orderResponse.data.id}`);
-        }
+      let submission = accepted;
+      while (submission.status === 'accepted' || submission.status === 'processing') {
+        setCheckoutStage(submission.stage ?? null);
+        await new Promise(resolve => setTimeout(resolve, 1000));
+        submission = (await apiService.getOrderSubmission(submission.id)).data?.data ?? submission;
+      }
+      setCheckoutStage(null);
+
+      if (submission.status === 'failed' || !submission.order_id) {
+        // Trying again after fixing the order is a new checkout
+        checkoutKey.current = crypto.randomUUID();
+        alert(submission.error || 'Checkout failed. Please try again.');
+        return;
+      }
+
+      if (submission.payment_intent_id) {
+        // In a real implementation, you would integrate with Stripe Elements
+        // using the client secret. For now, we'll simulate a successful payment
+        await apiService.confirmPayment({
+          payment_intent_id: submission.payment_intent_id,
+          order_id: submission.order_id
+        });
       }
+
+      // Clear cart
+      await clearCart();
+
+      // Redirect to order confirmation
+      onOrderCreated?.(submission.order_id);
+      navigate(`/orders/${submission.order_id}`);
     } catch (error) {
       console.error('Checkout failed:', error);
       alert('Checkout failed. Please try again.');
@@ -581,7 +616,11 @@ const CheckoutForm: React.FC<CheckoutFormProps> = ({ onOrderCreated }) => {
                   disabled={isSubmitting}
                   className="w-full bg-blue-600 text-white py-3 px-6 rounded-lg font-semibold hover:bg-blue-700 transition-colors disabled:opacity-50 disabled:cursor-not-allowed"
                 >
-                  {isSubmitting ? 'Processing...' : 'Complete Order'}
+                  {queuePosition !== null
+                    ? `In line for the flash sale: #${queuePosition}`
+                    : checkoutStage === 'reserving_inventory' ? 'Reserving your items...'
+                    : checkoutStage === 'payment_intent' ? 'Starting payment...'
+                    : isSubmitting ? 'Processing...' : 'Complete Order'}
                 </button>
               </div>
             </div>
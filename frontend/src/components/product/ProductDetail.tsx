@@ -5,6 +5,8 @@ import { apiService } from '../../services/api';
 import { useCart } from '../../contexts/CartContext';
 import type { Product } from '../../types';
 import { formatCurrency } from '../../utils';
+import { trackPageView } from '../../utils/clickstream';
+import { useProductSignals } from '../../hooks/useProductSignals';
 
 const ProductDetail: React.FC = () => {
   const { id } = useParams<{ id: string }>();
@@ -23,6 +25,14 @@ const ProductDetail: React.FC = () => {
   });
 
   const product = productData?.data;
+  const signals = useProductSignals(product?.id, true);
+
+  // Viewed products shape the chat assistant's recommendations
+  useEffect(() => {
+    if (product?.id) {
+      trackPageView(product.id);
+    }
+  }, [product?.id]);
 
   // Handle add to cart
   const handleAddToCart = async () => {
@@ -129,6 +139,27 @@ const ProductDetail: React.FC = () => {
             {formatCurrency(product.price)}
           </div>
 
+          {/* Social proof and scarcity hints */}
+          {signals && (signals.viewers || signals.recent_purchases || signals.low_stock) ? (
+            <div className="flex flex-wrap gap-2 text-sm">
+              {signals.viewers ? (
+                <span className="bg-blue-50 text-blue-700 px-3 py-1 rounded-full">
+                  {signals.viewers} people are viewing this now
+                </span>
+              ) : null}
+              {signals.recent_purchases ? (
+                <span className="bg-green-50 text-green-700 px-3 py-1 rounded-full">
+                  Bought {signals.recent_purchases} times in the last {signals.purchase_window_hours} hours
+                </span>
+              ) : null}
+              {signals.low_stock ? (
+                <span className="bg-orange-50 text-orange-700 px-3 py-1 rounded-full">
+                  Only {signals.low_stock} left in stock
+                </span>
+              ) : null}
+            </div>
+          ) : null}
+
           {/* Description */}
           <div>
             <h3 className="text-lg font-semibold text-gray-900 mb-2">
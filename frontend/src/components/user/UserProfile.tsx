@@ -5,7 +5,8 @@ import { apiService } from '../../services/api';
 const UserProfile: React.FC = () => {
   const { user, updateProfile, changePassword } = useAuth();
   
-  const [activeTab, setActiveTab] = useState<'profile' | 'password' | 'orders'>('profile');
+  const [activeTab, setActiveTab] = useState<'profile' | 'password' | 'orders' | 'chat'>('profile');
+  const [chatDeletion, setChatDeletion] = useState<string | null>(null);
   
   // Profile form state
   const [profileData, setProfileData] = useState({
@@ -26,6 +27,43 @@ const UserProfile: React.FC = () => {
   const [isSubmitting, setIsSubmitting] = useState(false);
   const [successMessage, setSuccessMessage] = useState('');
 
+  // Chat history is downloaded as a file
+  const downloadChatHistory = async (format: 'json' | 'pdf') => {
+    try {
+      const blob = await apiService.exportChatHistory(format);
+      const url = URL.createObjectURL(blob);
+      const link = document.createElement('a');
+      link.href = url;
+      link.download = `chat-history.${format}`;
+      link.click();
+      URL.revokeObjectURL(url);
+    } catch {
+      setErrors({ general: 'Failed to download chat history' });
+    }
+  };
+
+  // Deleting chat history runs in the background; its progress is polled
+  const deleteChatHistory = async () => {
+    if (!window.confirm('Delete all your chat conversations? This cannot be undone.')) return;
+
+    const response = await apiService.deleteChatHistory();
+    if (!response.data) {
+      setErrors({ general: response.error || 'Failed to delete chat history' });
+      return;
+    }
+    const id = response.data.data.id;
+    setChatDeletion(response.data.data.status);
+    const poll = window.setInterval(async () => {
+      const status = await apiService.getChatHistoryDeletion(id);
+      const state = status.data?.data?.status;
+      if (!state) return;
+      setChatDeletion(state);
+      if (state === 'succeeded' || state === 'failed') {
+        window.clearInterval(poll);
+      }
+    }, 2000);
+  };
+
   // Handle profile form input changes
   const handleProfileInputChange = (e: React.ChangeEvent<HTMLInputElement>) => {
     const { name, value } = e.target;
@@ -216,6 +254,16 @@ const UserProfile: React.FC = () => {
           >
             Order History
           </button>
+          <button
+            onClick={() => setActiveTab('chat')}
+            className={`py-2 px-1 border-b-2 font-medium text-sm ${
+              activeTab === 'chat'
+                ? 'border-blue-500 text-blue-600'
+                : 'border-transparent text-gray-500 hover:text-gray-700 hover:border-gray-300'
+            }`}
+          >
+            Chat History
+          </button>
         </nav>
       </div>
 
@@ -416,6 +464,61 @@ const UserProfile: React.FC = () => {
           </div>
         </div>
       )}
+
+      {/* Chat History Tab */}
+      {activeTab === 'chat' && (
+        <div className="bg-white rounded-lg shadow-sm border p-6 space-y-6">
+          <h2 className="text-xl font-semibold text-gray-900">
+            Chat History
+          </h2>
+
+          {errors.general && (
+            <div className="bg-red-50 border border-red-200 text-red-700 px-4 py-3 rounded">
+              {errors.general}
+            </div>
+          )}
+
+          <div>
+            <p className="text-gray-600 mb-3">Download every conversation you had with our assistant and agents.</p>
+            <div className="flex space-x-3">
+              <button
+                onClick={() => downloadChatHistory('json')}
+                className="px-4 py-2 border border-gray-300 rounded-md text-gray-700 hover:bg-gray-50"
+              >
+                Download JSON
+              </button>
+              <button
+                onClick={() => downloadChatHistory('pdf')}
+                className="px-4 py-2 border border-gray-300 rounded-md text-gray-700 hover:bg-gray-50"
+              >
+                Download PDF
+              </button>
+            </div>
+          </div>
+
+          <div className="border-t pt-6">
+            <p className="text-gray-600 mb-3">
+              Delete all your conversations. This can't be undone.
+            </p>
+            <button
+              onClick={deleteChatHistory}
+              disabled={chatDeletion === 'queued' || chatDeletion === 'running'}
+              className="px-4 py-2 bg-red-600 text-white rounded-md hover:bg-red-700 disabled:opacity-50"
+            >
+              Delete chat history
+            </button>
+            {chatDeletion && (
+              <p className="mt-3 text-sm text-gray-600">
+                {chatDeletion === 'succeeded'
+                  ? 'Your chat history was deleted.'
+                  : chatDeletion === 'failed'
+                    ? 'Deleting your chat history failed, please try again.'
+                    : 'Deleting your chat history...'}
+              </p>
+            )}
+          </div>
+        </div>
+      )}
     </div>
   );
 };
@@ -1,7 +1,8 @@
 import { useState, useEffect, useCallback, useRef } from 'react';
-import type { ChatMessage, ChatSession, ProductSuggestion } from '../types';
+import type { ChatMessage, ChatSession, ProductCardSuggestion } from '../types';
 import { WebSocketService, createWebSocketService, disconnectWebSocketService } from '../services/websocket';
 import fetchService from '../utils/fetch';
+import { ensureChatSession } from '../utils/chatSession';
 
 export interface UseChatSessionOptions {
   sessionId?: string;
@@ -18,7 +19,7 @@ export interface UseChatSessionReturn {
   
   // Chat state
   messages: ChatMessage[];
-  suggestions: ProductSuggestion[];
+  suggestions: ProductCardSuggestion[];
   isTyping: boolean;
   
   // Actions
@@ -40,22 +41,40 @@ export const useChatSession = (options: UseChatSessionOptions = {}): UseChatSess
     onCartUpdate,
   } = options;
 
-  // Generate session ID if not provided
-  const sessionId = providedSessionId || `session_${Date.now()}_${Math.random().toString(36).substr(2, 9)}`;
+  // Session IDs are issued by the API, which only shows a session's history
+  // to the browser holding its cookie
+  const [sessionId, setSessionId] = useState<string>(providedSessionId || '');
 
   // State
   const [isConnected, setIsConnected] = useState(false);
   const [isConnecting, setIsConnecting] = useState(false);
   const [error, setError] = useState<string | null>(null);
   const [messages, setMessages] = useState<ChatMessage[]>([]);
-  const [suggestions, setSuggestions] = useState<ProductSuggestion[]>([]);
+  const [suggestions, setSuggestions] = useState<ProductCardSuggestion[]>([]);
   const [isTyping, setIsTyping] = useState(false);
 
   // Refs
   const wsServiceRef = useRef<WebSocketService | null>(null);
 
+  useEffect(() => {
+    if (providedSessionId) {
+      setSessionId(providedSessionId);
+      return;
+    }
+    ensureChatSession()
+      .then(setSessionId)
+      .catch(err => {
+        console.error('Failed to start chat session:', err);
+        setError('Failed to connect to chat service');
+      });
+  }, [providedSessionId]);
+
   // Initialize WebSocket service
   useEffect(() => {
+    if (!sessionId) {
+      return;
+    }
+
     const wsService = createWebSocketService({
       sessionId,
       userId,
@@ -147,7 +166,10 @@ export const useChatSession = (options: UseChatSessionOptions = {}): UseChatSess
 
   const loadHistory = useCallback(async () => {
     try {
-      const result = await fetchService.get(`/api/v1/chat/history/${sessionId}`);
+      if (!sessionId) {
+        return;
+      }
+      const result = await fetchService.get(`/api/v1/chat/history/${sessionId}`, { credentials: 'include' });
       if (result.data && result.data.success && result.data.data.messages) {
         setMessages(result.data.data.messages);
       } else if (result.error) {
@@ -181,7 +203,7 @@ export const useChatSessions = () => {
 
   const createSession = useCallback(async (userId?: string): Promise<string> => {
     try {
-      const result = await fetchService.post('/api/v1/chat/session', { user_id: userId });
+      const result = await fetchService.post('/api/v1/chat/session', { user_id: userId }, { credentials: 'include' });
       
       if (result.data && result.data.success) {
         const sessionId = result.data.data.id;
@@ -194,15 +216,13 @@ export const useChatSessions = () => {
       console.error('Failed to create chat session:', error);
     }
 
-    // Fallback: generate session ID locally
-    const sessionId = `session_${Date.now()}_${Math.random().toString(36).substr(2, 9)}`;
-    setActiveSessionId(sessionId);
-    return sessionId;
+    // Session IDs made up locally are refused by the API
+    throw new Error('Failed to start chat session');
   }, []);
 
   const getSession = useCallback(async (sessionId: string): Promise<ChatSession | null> => {
     try {
-      const result = await fetchService.get(`/api/v1/chat/session/${sessionId}`);
+      const result = await fetchService.get(`/api/v1/chat/session/${sessionId}`, { credentials: 'include' });
       if (result.data && result.data.success) {
         return result.data.data;
       } else if (result.error) {
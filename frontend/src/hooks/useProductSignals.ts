@@ -0,0 +1,39 @@
+import { useState, useEffect } from 'react';
+import type { ProductSignals } from '../types/generated/chat';
+
+// Follows a product's social proof and scarcity hints on the product channels.
+// viewing counts this shopper as looking at the product, as its page does.
+export const useProductSignals = (productId?: string, viewing: boolean = false) => {
+  const [signals, setSignals] = useState<ProductSignals | null>(null);
+
+  useEffect(() => {
+    if (!productId) return;
+    setSignals(null);
+
+    const protocol = window.location.protocol === 'https:' ? 'wss:' : 'ws:';
+    const sessionId = localStorage.getItem('session_id') || '';
+    const ws = new WebSocket(
+      `${protocol}//localhost:8080/api/v1/products/ws?session_id=${encodeURIComponent(sessionId)}`
+    );
+
+    ws.onopen = () => {
+      ws.send(JSON.stringify({ type: 'subscribe', data: { product_id: productId, viewing } }));
+    };
+    ws.onmessage = (event) => {
+      try {
+        const message = JSON.parse(event.data);
+        if (message.type === 'product_signals' && message.data?.product_id === productId) {
+          setSignals(message.data as ProductSignals);
+        }
+      } catch (err) {
+        console.error('Failed to parse product signals:', err);
+      }
+    };
+
+    return () => ws.close();
+  }, [productId, viewing]);
+
+  return signals;
+};
+
+export default useProductSignals;
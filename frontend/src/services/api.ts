@@ -1,13 +1,19 @@
 import type { 
   Product,
   ProductListResponse,
+  ProductAvailabilityResponse,
   ProductFilters,
   Category,
+  Brand,
+  ProductQuestion,
   CartResponse,
   AddToCartRequest,
   UpdateCartItemRequest,
   User,
   Order,
+  OrderSubmission,
+  FlashSaleStatus,
+  WaitingRoomTicket,
   ApiResponse,
   SearchParams,
   AuthResponse,
@@ -117,6 +123,9 @@ class ApiService {
     if (params.limit) {
       queryParams.append('limit', String(params.limit));
     }
+    if (params.brand_id) {
+      queryParams.append('brand_id', params.brand_id);
+    }
     
     return this.request<Product[]>(`/api/v1/products/search?${queryParams.toString()}`);
   }
@@ -129,6 +138,25 @@ class ApiService {
     return this.request<Product[]>(`/api/v1/products/${productId}/related?limit=${limit}`);
   }
 
+  async getProductAvailability(productIds: string[]): Promise<ApiResponse<ProductAvailabilityResponse>> {
+    return this.request<ProductAvailabilityResponse>(`/api/v1/products/availability?ids=${productIds.join(',')}`);
+  }
+
+  async getProductQuestions(productId: string): Promise<ApiResponse<ProductQuestion[]>> {
+    const response = await this.request<{ questions: ProductQuestion[] }>(`/api/v1/products/${productId}/questions`);
+    if (response.data) {
+      return { data: response.data.questions };
+    }
+    return { error: response.error || 'Failed to fetch questions' };
+  }
+
+  async askProductQuestion(productId: string, question: string, askerName?: string): Promise<ApiResponse<{ message: string; question: ProductQuestion }>> {
+    return this.request<{ message: string; question: ProductQuestion }>(`/api/v1/products/${productId}/questions`, {
+      method: 'POST',
+      body: JSON.stringify({ question, asker_name: askerName }),
+    });
+  }
+
   // Category API methods
   async getCategories(): Promise<ApiResponse<Category[]>> {
     const response = await this.request<{ categories: Category[] }>('/api/v1/categories/');
@@ -146,6 +174,23 @@ class ApiService {
     return this.request<Category>(`/api/v1/categories/slug/${slug}`);
   }
 
+  // Brand API methods
+  async getBrands(): Promise<ApiResponse<Brand[]>> {
+    const response = await this.request<{ brands: Brand[] }>('/api/v1/brands/');
+    if (response.data) {
+      return { data: response.data.brands };
+    }
+    return { error: response.error || 'Failed to fetch brands' };
+  }
+
+  async getBrand(slug: string): Promise<ApiResponse<Brand>> {
+    return this.request<Brand>(`/api/v1/brands/${slug}`);
+  }
+
+  async getBrandProducts(slug: string, page: number = 1, limit: number = 10): Promise<ApiResponse<ProductListResponse>> {
+    return this.request<ProductListResponse>(`/api/v1/brands/${slug}/products?page=${page}&limit=${limit}`);
+  }
+
   // Cart API methods
   async getCart(): Promise<ApiResponse<CartResponse>> {
     return this.request<CartResponse>('/api/v1/cart/');
@@ -280,6 +325,44 @@ class ApiService {
     return { error: response.error || 'Failed to fetch orders' };
   }
 
+  // Chat history: downloads of every conversation, and deleting them all,
+  // which runs in the background
+  async exportChatHistory(format: 'json' | 'pdf'): Promise<Blob> {
+    const token = this.getAuthToken();
+    const response = await fetch(`${this.baseURL}/api/v1/user/chat-history/export?format=${format}`, {
+      headers: token ? { Authorization: `Bearer ${token}` } : undefined,
+    });
+    if (!response.ok) {
+      throw new Error('Failed to export chat history');
+    }
+    return response.blob();
+  }
+
+  async deleteChatHistory(): Promise<ApiResponse<{ success: boolean; data: { id: string; status: string } }>> {
+    return this.request<{ success: boolean; data: { id: string; status: string } }>('/api/v1/user/chat-history', {
+      method: 'DELETE',
+    });
+  }
+
+  async getChatHistoryDeletion(id: string): Promise<ApiResponse<{ success: boolean; data: { id: string; status: string; error?: string } }>> {
+    return this.request<{ success: boolean; data: { id: string; status: string; error?: string } }>(`/api/v1/user/chat-history/deletions/${id}`);
+  }
+
+  // Flash sale and its checkout waiting room
+  async getFlashSale(): Promise<ApiResponse<{ success: boolean; data: FlashSaleStatus }>> {
+    return this.request<{ success: boolean; data: FlashSaleStatus }>('/api/v1/flash-sale');
+  }
+
+  async joinFlashSaleQueue(): Promise<ApiResponse<{ success: boolean; data: WaitingRoomTicket }>> {
+    return this.request<{ success: boolean; data: WaitingRoomTicket }>('/api/v1/flash-sale/queue', {
+      method: 'POST',
+    });
+  }
+
+  async getFlashSaleTicket(token: string): Promise<ApiResponse<{ success: boolean; data: WaitingRoomTicket }>> {
+    return this.request<{ success: boolean; data: WaitingRoomTicket }>(`/api/v1/flash-sale/queue/${token}`);
+  }
+
   // Order API methods
   async createOrder(orderData: any): Promise<ApiResponse<Order>> {
     return this.request<Order>('/api/v1/orders', {
@@ -288,6 +371,20 @@ class ApiService {
     });
   }
 
+  // Checkouts accepted at once and worked on in the background. Sending one
+  // again with the same key gets back the submission it was accepted as.
+  async submitOrder(orderData: any, idempotencyKey: string): Promise<ApiResponse<{ success: boolean; data: OrderSubmission }>> {
+    return this.request<{ success: boolean; data: OrderSubmission }>('/api/v1/orders/submissions', {
+      method: 'POST',
+      headers: { 'Idempotency-Key': idempotencyKey },
+      body: JSON.stringify(orderData),
+    });
+  }
+
+  async getOrderSubmission(id: string): Promise<ApiResponse<{ success: boolean; data: OrderSubmission }>> {
+    return this.request<{ success: boolean; data: OrderSubmission }>(`/api/v1/orders/submissions/${id}`);
+  }
+
   async getOrder(id: string): Promise<ApiResponse<Order>> {
     return this.request<Order>(`/api/v1/orders/${id}`);
   }
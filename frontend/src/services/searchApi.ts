@@ -49,6 +49,20 @@ export interface SuggestionsResponse {
   suggestions: string[];
 }
 
+export interface AutocompleteSuggestion {
+  kind: 'product' | 'category' | 'query';
+  text: string;
+  id?: string;
+  slug?: string;
+}
+
+export interface AutocompleteResponse {
+  query: string;
+  products: AutocompleteSuggestion[];
+  categories: AutocompleteSuggestion[];
+  queries: AutocompleteSuggestion[];
+}
+
 export interface FilterOptions {
   price_ranges: Array<{
     min: number;
@@ -95,21 +109,28 @@ class SearchApiClient {
     }
   }
 
-  // Get autocomplete suggestions
-  async getSuggestions(query: string, limit: number = 10): Promise<string[]> {
+  // Get type-ahead suggestions grouped by kind
+  async getAutocomplete(query: string, limit: number = 5): Promise<AutocompleteResponse> {
     try {
-      const response = await axios.get<SuggestionsResponse>(`${this.baseURL}/search/suggestions`, {
+      const response = await axios.get<AutocompleteResponse>(`${this.baseURL}/v1/products/autocomplete`, {
         params: { q: query, limit }
       });
-      return response.data.suggestions;
+      return response.data;
     } catch (error) {
       if (axios.isAxiosError(error)) {
-        throw new Error(error.response?.data?.error?.message || 'Failed to get suggestions');
+        throw new Error(error.response?.data?.error || 'Failed to get suggestions');
       }
       throw new Error('Failed to get suggestions');
     }
   }
 
+  // Get autocomplete suggestions: popular queries, then product and category names
+  async getSuggestions(query: string, limit: number = 10): Promise<string[]> {
+    const response = await this.getAutocomplete(query, limit);
+    const suggestions = [...response.queries, ...response.products, ...response.categories].map((s) => s.text);
+    return Array.from(new Set(suggestions)).slice(0, limit);
+  }
+
   // Get filter options
   async getFilterOptions(categoryId?: string): Promise<FilterOptions> {
     try {
@@ -1,4 +1,5 @@
-import type { ChatMessage, ChatAction, ProductSuggestion } from '../types';
+import type { ChatMessage, ChatAction, ProductCardSuggestion } from '../types';
+import { replayStamp } from '../utils/chatSession';
 
 export interface WebSocketMessage {
   type: 'message' | 'typing' | 'suggestions' | 'actions' | 'error';
@@ -10,9 +11,11 @@ export interface WebSocketMessage {
 export interface WebSocketServiceOptions {
   sessionId: string;
   userId?: string;
+  // Trims suggestion product cards to these fields, e.g. 'name,price'
+  suggestionFields?: string;
   onMessage?: (message: ChatMessage) => void;
   onTyping?: (isTyping: boolean) => void;
-  onSuggestions?: (suggestions: ProductSuggestion[]) => void;
+  onSuggestions?: (suggestions: ProductCardSuggestion[]) => void;
   onActions?: (actions: ChatAction[]) => void;
   onError?: (error: string) => void;
   onConnect?: () => void;
@@ -42,7 +45,8 @@ export class WebSocketService {
 
       try {
         const protocol = window.location.protocol === 'https:' ? 'wss:' : 'ws:';
-        const wsUrl = `${protocol}//${window.location.host}/api/v1/chat/ws?session_id=${this.options.sessionId}`;
+        const fields = this.options.suggestionFields ? `&fields=${encodeURIComponent(this.options.suggestionFields)}` : '';
+        const wsUrl = `${protocol}//${window.location.host}/api/v1/chat/ws?session_id=${this.options.sessionId}${fields}`;
         
         this.ws = new WebSocket(wsUrl);
 
@@ -102,6 +106,7 @@ export class WebSocketService {
 
     const message = {
       type: 'message',
+      ...replayStamp(),
       data: {
         content,
         session_id: this.options.sessionId,
@@ -0,0 +1,535 @@
+// Code generated by go run ./cmd/tsgen. DO NOT EDIT.
+//
+// The chat WebSocket messages and chat REST bodies, generated from the Go
+// structs they are encoded from.
+
+// WebSocketMessage represents a WebSocket message. The message types the
+// server sends and their data are listed in tsgen.ChatContract, which the
+// frontend's types are generated from.
+export interface WebSocketMessage<T extends string = string, D = unknown> {
+  type: T;
+  data: D;
+  session_id: string;
+  user_id?: string;
+}
+
+// ServerMessage is any message the server sends on the chat WebSocket
+export type ServerMessage =
+  | WebSocketMessage<'message', ChatMessage>
+  | WebSocketMessage<'chat_response', ChatDelta>
+  | WebSocketMessage<'typing', TypingIndicator>
+  | WebSocketMessage<'error', ChatError>
+  | WebSocketMessage<'actions', ChatAction[]>
+  | WebSocketMessage<'suggestions', ProductSuggestionDTO[]>
+  | WebSocketMessage<'system_notification', MaintenanceNotice>
+  | WebSocketMessage<'job_progress', JobStatus>
+  | WebSocketMessage<'reservation_expiring', CartReservationNotice>
+  | WebSocketMessage<'reservation_expired', CartReservationNotice>
+  | WebSocketMessage<'checkout_conflict', CheckoutConflict>
+  | WebSocketMessage<'order_confirmation', OrderConfirmation>
+  | WebSocketMessage<'fulfillment_update', FulfillmentNotice>
+  | WebSocketMessage<'campaign', CampaignNotice>
+  | WebSocketMessage<'payment_retry', DunningNotice>
+  | WebSocketMessage<'agent_message', ChatMessageService>
+  | WebSocketMessage<'agent_status', ChatHandoff>;
+
+export type ServerMessageType = ServerMessage['type'];
+
+// ChatMessage represents a chat message
+export interface ChatMessage {
+  id: string;
+  session_id: string;
+  user_id?: string;
+  role: string;
+  content: string;
+  metadata?: Record<string, unknown>;
+  timestamp: string;
+}
+
+// ChatDelta is a piece of an assistant reply streamed as the model writes it.
+// The final "message" with the same ID replaces the streamed text.
+export interface ChatDelta {
+  id: string;
+  delta: string;
+}
+
+// TypingIndicator tells whether the assistant is writing a reply
+export interface TypingIndicator {
+  is_typing: boolean;
+}
+
+// ChatError is a chat message that couldn't be answered
+export interface ChatError {
+  message: string;
+  code?: string; // chat_rate_limited when the shopper is sending too fast, invalid_message for a malformed frame, stale_message or replayed_message for a refused replay
+  retry_after?: number; // seconds until a limited shopper may send again
+}
+
+// ChatAction represents an action to be taken based on the chat
+export interface ChatAction {
+  type: string; // "add_to_cart", "remove_from_cart", "search_products", "set_gift_options", "share_cart", "checkout", "set_shipping_address", "place_order"
+  payload: Record<string, unknown>;
+}
+
+// ProductSuggestionDTO is a chat product suggestion with its product card
+export interface ProductSuggestionDTO {
+  product: ProductCardDTO;
+  reason: string;
+  confidence: number;
+}
+
+// MaintenanceNotice is the system notification shoppers get about a maintenance
+export interface MaintenanceNotice {
+  kind: string; // "maintenance", or "maintenance_ended" once it's over
+  maintenance: MaintenanceStatus | null;
+}
+
+// JobStatus is a background job as the API shows it
+export interface JobStatus {
+  id: string;
+  kind: string; // product_import, product_export, bulk_price, campaign_send or chat_purge
+  status: string; // queued, running, succeeded or failed
+  progress: number; // percent done
+  processed: number;
+  total: number;
+  params?: unknown;
+  result?: unknown;
+  error?: string;
+  artifact_name?: string;
+  artifact_type?: string;
+  created_by: string | null;
+  started_at: string | null;
+  finished_at: string | null;
+  created_at: string;
+  updated_at: string;
+  artifact_url?: string;
+}
+
+// CartReservationNotice is sent to a session when its held items are about to
+// expire or have been released
+export interface CartReservationNotice {
+  expires_at: string;
+  seconds_remaining: number;
+  product_ids: string[];
+}
+
+// CheckoutConflict describes a lost checkout race and what the shopper could buy instead
+export interface CheckoutConflict {
+  product_id: string;
+  variant_id?: string;
+  product_name: string;
+  requested: number;
+  available: number;
+  message: string;
+  alternatives: ProductSuggestion[];
+}
+
+// OrderConfirmation tells the shopper their order went through and when it
+// should arrive
+export interface OrderConfirmation {
+  order_id: string;
+  order_number: string;
+  message: string;
+  total_amount: number;
+  delivery_date?: string;
+  delivery_carrier?: string;
+}
+
+// FulfillmentNotice tells the shopper about a change to how their order ships
+export interface FulfillmentNotice {
+  order_id: string;
+  order_number: string;
+  order_status: string;
+  message: string;
+  fulfillment: Fulfillment | null;
+}
+
+// CampaignNotice is what connected clients receive
+export interface CampaignNotice {
+  campaign_id: string;
+  title: string;
+  message: string;
+  link_url?: string;
+}
+
+// DunningNotice tells the shopper a payment failed and how to fix it
+export interface DunningNotice {
+  order_id: string;
+  order_number: string;
+  status: string;
+  attempts: number;
+  max_attempts: number;
+  next_retry_at?: string;
+  pay_now_url?: string;
+  message: string;
+}
+
+// ChatMessageService represents a message in the chat conversation for service layer
+export interface ChatMessageService {
+  id: string;
+  session_id: string;
+  user_id?: string;
+  role: string; // "user", "assistant", "agent", "system"
+  content: string;
+  metadata?: Record<string, unknown>;
+  created_at: string;
+}
+
+// ChatHandoff is a chat handed to a person, as admins and the shopper see it
+export interface ChatHandoff {
+  session_id: string;
+  user_id?: string;
+  status: string; // needs_agent, with_agent, or active once handed back
+  reason?: string; // requested or frustrated
+  agent_id?: string;
+  requested_at?: string;
+  last_active: string;
+}
+
+// ChatRequest represents a chat request
+export interface ChatRequest {
+  message: string;
+  session_id: string;
+}
+
+// ChatResponse represents a chat response
+export interface ChatResponse {
+  session_id: string; // a new session when the requested one isn't the sender's
+  message: string;
+  intent?: MessageIntent; // what the shopper's message was about
+  language?: string; // the language the shopper writes in, when known
+  actions?: ChatAction[];
+  suggestions?: ProductSuggestionDTO[];
+  context?: Record<string, unknown>;
+  handoff?: ChatHandoff; // set once the chat is handed to a person
+  error?: string;
+}
+
+// ProductSignals are the social proof and scarcity hints for a product. A
+// hint that's off, or under its minimum, is left out.
+export interface ProductSignals {
+  product_id: string;
+  viewers?: number; // shoppers viewing the product now
+  recent_purchases?: number; // orders of it in the purchase window
+  purchase_window_hours?: number; // set with recent_purchases
+  low_stock?: number; // units left, when few are
+}
+
+// ProductCardDTO is the compact product sent in chat suggestions and
+// WebSocket messages, with only what a product card renders
+export interface ProductCardDTO {
+  id: string;
+  name: string;
+  price: number;
+  list_price?: number;
+  image_url?: string;
+  category_name?: string;
+  in_stock: boolean;
+  variants?: VariantOptionDTO[];
+}
+
+// MaintenanceStatus is the maintenance clients are told about
+export interface MaintenanceStatus {
+  planned: boolean;
+  active: boolean; // writes are refused
+  message?: string;
+  starts_at?: string;
+  expected_end?: string;
+  seconds_until_start: number; // countdown for the banner
+  retry_after_seconds?: number;
+}
+
+// ProductSuggestion represents a product suggestion
+export interface ProductSuggestion {
+  product: Product | null;
+  reason: string;
+  confidence: number;
+}
+
+// Fulfillment is a shipment of some of an order's items. Orders with items
+// that aren't all in stock are split into one fulfillment for what can ship
+// now and a backordered one for the rest.
+export interface Fulfillment {
+  id: string;
+  order_id: string;
+  sequence: number;
+  status: string; // pending, backordered, shipped, delivered, cancelled
+  carrier: string;
+  tracking_number: string;
+  shipped_at: string | null;
+  delivered_at: string | null;
+  created_at: string;
+  updated_at: string;
+  items: OrderItem[];
+}
+
+// MessageIntent is what a shopper's message is about
+export interface MessageIntent {
+  label: string; // browse, add_to_cart, support, smalltalk or checkout
+  confidence: number; // 0 to 1
+  source: string; // rules or llm
+}
+
+// VariantOptionDTO summarizes a product's variants of one kind, e.g. Color: Red, Blue
+export interface VariantOptionDTO {
+  name: string;
+  values: string[];
+}
+
+// Product represents a product in the catalog
+export interface Product {
+  id: string;
+  name: string;
+  description: string;
+  price: number;
+  category_id: string;
+  brand_id: string | null;
+  sku: string;
+  status: string;
+  metadata: unknown;
+  search_vector: string;
+  search_weight: number;
+  popularity: number;
+  publish_at: string | null; // product goes live at this time
+  unpublish_at: string | null; // product is taken down at this time
+  created_at: string;
+  updated_at: string;
+  list_price?: number;
+  price_adjustments?: PriceAdjustment[];
+  category: Category;
+  brand?: Brand;
+  variants: ProductVariant[];
+  images: ProductImage[];
+  inventory: Inventory[];
+  order_items: OrderItem[];
+}
+
+// OrderItem represents individual items within an order
+export interface OrderItem {
+  id: string;
+  order_id: string;
+  fulfillment_id: string | null;
+  product_id: string;
+  variant_id: string | null;
+  quantity: number;
+  unit_price: number;
+  total_price: number;
+  product_snapshot: unknown;
+  price_adjustments?: unknown; // the group price and pricing rules UnitPrice was reached with
+  created_at: string;
+  order: Order;
+  product: Product;
+  variant: ProductVariant | null;
+}
+
+// PriceAdjustment is one step from a product's catalog price to what a
+// shopper pays: their customer group's price or a pricing rule
+export interface PriceAdjustment {
+  source: string; // customer_group or pricing_rule
+  id?: string; // the customer group or pricing rule
+  name: string;
+  type?: string; // the pricing rule's type
+  percent?: number; // the rule's discount
+  price_before: number;
+  price_after: number;
+}
+
+// Category represents product categories
+export interface Category {
+  id: string;
+  name: string;
+  description: string;
+  parent_id: string | null;
+  slug: string;
+  sort_order: number;
+  is_active: boolean;
+  metadata_schema?: unknown;
+  created_at: string;
+  updated_at: string;
+  parent: Category | null;
+  children: Category[];
+  products: Product[];
+}
+
+// Brand is the manufacturer or label products are sold under
+export interface Brand {
+  id: string;
+  name: string;
+  slug: string;
+  description: string;
+  logo_url: string;
+  is_active: boolean;
+  created_at: string;
+  updated_at: string;
+}
+
+// ProductVariant represents product variations like size, color, material
+export interface ProductVariant {
+  id: string;
+  product_id: string;
+  variant_name: string;
+  variant_value: string;
+  price_modifier: number;
+  sku_suffix: string;
+  is_default: boolean;
+  created_at: string;
+  product: Product;
+}
+
+// ProductImage represents product images
+export interface ProductImage {
+  id: string;
+  product_id: string;
+  url: string;
+  alt_text: string;
+  is_primary: boolean;
+  sort_order: number;
+  created_at: string;
+  product: Product;
+}
+
+// Inventory represents stock levels and warehouse information
+export interface Inventory {
+  id: string;
+  product_id: string;
+  variant_id: string | null;
+  warehouse_location: string;
+  quantity_available: number;
+  quantity_reserved: number;
+  low_stock_threshold: number;
+  reorder_point: number;
+  last_restocked: string | null;
+  created_at: string;
+  updated_at: string;
+  product: Product;
+  variant: ProductVariant | null;
+  reservations: InventoryReservation[];
+}
+
+// Order represents completed purchase transactions
+export interface Order {
+  id: string;
+  order_number: string;
+  user_id: string;
+  session_id: string;
+  status: string;
+  subtotal: number;
+  tax_amount: number;
+  tax_rate?: number; // unset on orders placed before per-country rates, which were taxed at 8%
+  tax_included: boolean; // the subtotal already includes TaxAmount
+  shipping_amount: number;
+  total_amount: number;
+  currency: string;
+  payment_status: string;
+  shipping_address: unknown; // encrypted at rest
+  billing_address: unknown; // encrypted at rest
+  payment_method: string;
+  payment_intent_id: string;
+  payment_provider: string;
+  payment_reference?: string;
+  payment_due_at?: string; // offline payments must arrive by then
+  gift_wrap: boolean;
+  gift_wrap_amount: number;
+  gift_message?: string; // printed on the packing slip, which then shows no prices
+  delivery_date?: string; // the customer's preferred delivery day
+  delivery_carrier?: string;
+  ship_by?: string; // last warehouse ship day that still arrives on the delivery date
+  created_at: string;
+  updated_at: string;
+  user: User;
+  items: OrderItem[];
+  fulfillments: Fulfillment[];
+}
+
+// InventoryReservation represents temporary inventory reservations
+export interface InventoryReservation {
+  id: string;
+  inventory_id: string;
+  session_id: string;
+  user_id: string | null;
+  quantity_reserved: number;
+  expires_at: string;
+  status: string;
+  expiry_notified_at?: string;
+  created_at: string;
+  inventory: Inventory;
+  user: User | null;
+}
+
+// User represents customer accounts
+export interface User {
+  id: string;
+  email: string;
+  first_name: string;
+  last_name: string;
+  phone: string; // encrypted at rest
+  date_of_birth: string | null; // encrypted at rest
+  preferences: unknown;
+  email_verified: boolean;
+  status: string;
+  account_state: string;
+  failed_login_attempts: number;
+  lockout_until: string | null;
+  last_login_at: string | null;
+  password_reset_required: boolean; // set by admins; sign-in fails until a reset link is used
+  customer_group_id: string | null;
+  created_at: string;
+  updated_at: string;
+  chat_sessions: ChatSession[];
+  shopping_carts: ShoppingCart[];
+  orders: Order[];
+  reservations: InventoryReservation[];
+}
+
+// ChatSession represents chat conversation sessions
+export interface ChatSession {
+  id: string;
+  session_id: string;
+  user_id: string | null;
+  conversation_history: unknown;
+  context: unknown;
+  cart_state: unknown;
+  preferences: unknown;
+  locale: string; // language detected from the shopper's messages, e.g. "es"
+  status: string; // active, needs_agent or with_agent
+  agent_id?: string; // the admin who joined the chat
+  handoff_reason?: string; // requested or frustrated
+  handoff_at?: string; // when the shopper was handed to a person
+  last_activity: string;
+  created_at: string;
+  expires_at: string;
+  user: User;
+  messages: ModelsChatMessage[];
+}
+
+// ShoppingCart represents unified cart state
+export interface ShoppingCart {
+  id: string;
+  session_id: string;
+  user_id: string | null;
+  items: unknown;
+  subtotal: number;
+  tax_amount: number;
+  shipping_amount: number;
+  total_amount: number;
+  currency: string;
+  gift_wrap: boolean;
+  gift_message: string;
+  created_at: string;
+  updated_at: string;
+  user: User;
+}
+
+// ChatMessage represents a message in a chat conversation
+export interface ModelsChatMessage {
+  id: string;
+  chat_session_id: string;
+  session_id: string;
+  user_id: string | null;
+  role: string; // "user", "assistant", "agent", "system"
+  content: string;
+  metadata: unknown;
+  created_at: string;
+  user: User;
+  chat_session: ChatSession;
+}
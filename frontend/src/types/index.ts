@@ -6,6 +6,7 @@ export interface Product {
   description: string;
   price: number;
   category_id: string;
+  brand_id?: string;
   sku: string;
   status: string;
   metadata?: Record<string, any>;
@@ -13,6 +14,7 @@ export interface Product {
   created_at: string;
   updated_at: string;
   category?: Category;
+  brand?: Brand;
   variants?: ProductVariant[];
   inventory?: Inventory[];
 }
@@ -53,6 +55,31 @@ export interface Category {
   products?: Product[];
 }
 
+export interface Brand {
+  id: string;
+  name: string;
+  slug: string;
+  description: string;
+  logo_url: string;
+  is_active: boolean;
+  created_at: string;
+  updated_at: string;
+  product_count?: number;
+}
+
+export interface ProductQuestion {
+  id: string;
+  product_id: string;
+  asker_name: string;
+  question: string;
+  answer: string;
+  status: 'pending' | 'suggested' | 'approved' | 'rejected';
+  answer_source: 'admin' | 'llm' | '';
+  answered_at?: string;
+  created_at: string;
+  updated_at: string;
+}
+
 export interface Inventory {
   id: string;
   product_id: string;
@@ -152,6 +179,7 @@ export interface OrderItem {
 export interface ProductFilters {
   search?: string;
   category_id?: string;
+  brand_id?: string;
   min_price?: number;
   max_price?: number;
   status?: string;
@@ -172,6 +200,59 @@ export interface ProductListResponse {
   has_previous: boolean;
 }
 
+export interface ProductAvailability {
+  product_id: string;
+  status: 'in_stock' | 'low_stock' | 'out_of_stock';
+  in_stock: boolean;
+  low_stock: boolean;
+}
+
+export interface ProductAvailabilityResponse {
+  availability: ProductAvailability[];
+}
+
+export interface FlashSale {
+  id: string;
+  name: string;
+  product_ids: string[];
+  starts_at: string;
+  ends_at: string;
+  per_user_limit: number;
+  admit_per_minute: number;
+}
+
+export interface FlashSaleStatus {
+  planned: boolean;
+  active: boolean;
+  sale?: FlashSale;
+  seconds_until_start: number;
+  waiting_room: boolean;
+  waiting: number;
+}
+
+export interface WaitingRoomTicket {
+  token: string;
+  sale_id: string;
+  position: number;
+  admitted: boolean;
+  admitted_until?: string;
+  estimated_wait_seconds: number;
+  retry_after_seconds?: number;
+}
+
+export interface OrderSubmission {
+  id: string;
+  status: 'accepted' | 'processing' | 'completed' | 'failed';
+  stage?: 'reserving_inventory' | 'payment_intent' | 'notifying';
+  order_id?: string;
+  payment_intent_id?: string;
+  payment_provider?: string;
+  client_secret?: string;
+  error?: string;
+  order?: Order;
+  payment_instructions?: string;
+}
+
 export interface ApiResponse<T> {
   data?: T;
   error?: string;
@@ -186,6 +267,7 @@ export interface PaginationParams {
 export interface SearchParams {
   q: string;
   limit?: number;
+  brand_id?: string;
 }
 
 // Auth types
@@ -216,7 +298,7 @@ export interface RefreshTokenRequest {
 // Chat types
 export interface ChatMessage {
   id: string;
-  role: 'user' | 'assistant' | 'system';
+  role: 'user' | 'assistant' | 'agent' | 'system';
   content: string;
   timestamp: string;
   metadata?: Record<string, any>;
@@ -274,7 +356,7 @@ export interface ChatMessage {
   id: string;
   sessionId: string;
   userId?: string;
-  role: 'user' | 'assistant' | 'system';
+  role: 'user' | 'assistant' | 'agent' | 'system';
   content: string;
   metadata?: Record<string, any>;
   timestamp: string;
@@ -304,10 +386,33 @@ export interface ProductSuggestion {
   };
 }
 
+// ProductCard is the compact product the chat sends with its suggestions
+export interface ProductCard {
+  id: string;
+  name: string;
+  price: number;
+  list_price?: number;
+  image_url?: string;
+  category_name?: string;
+  in_stock: boolean;
+  variants?: VariantOption[];
+}
+
+export interface VariantOption {
+  name: string;
+  values: string[];
+}
+
+export interface ProductCardSuggestion {
+  product: ProductCard;
+  reason?: string;
+  confidence?: number;
+}
+
 export interface ChatResponse {
   message: string;
   actions?: ChatAction[];
-  suggestions?: ProductSuggestion[];
+  suggestions?: ProductCardSuggestion[];
   context?: Record<string, any>;
   error?: string;
 }
@@ -0,0 +1,34 @@
+// Chat sessions are issued by the API: their IDs are signed, and the
+// httpOnly cookie set alongside proves this browser owns the session
+import fetchService from './fetch';
+
+const CHAT_SESSION_KEY = 'chat_session_id';
+
+export const getStoredChatSessionId = (): string | null => localStorage.getItem(CHAT_SESSION_KEY);
+
+export const storeChatSessionId = (sessionId: string): void => {
+  localStorage.setItem(CHAT_SESSION_KEY, sessionId);
+};
+
+// ensureChatSession returns this browser's chat session, starting one if needed
+export const ensureChatSession = async (): Promise<string> => {
+  const stored = getStoredChatSessionId();
+  if (stored) {
+    return stored;
+  }
+
+  const result = await fetchService.post('/api/v1/chat/session', undefined, { credentials: 'include' });
+  const sessionId = result.data?.data?.id;
+  if (!sessionId) {
+    throw new Error(result.error || 'Failed to start chat session');
+  }
+  storeChatSessionId(sessionId);
+  return sessionId;
+};
+
+// replayStamp is the nonce and timestamp each chat message carries, so the
+// API refuses it when it's sent again from a captured connection
+export const replayStamp = (): { nonce: string; timestamp: string } => ({
+  nonce: crypto.randomUUID().replace(/-/g, ''),
+  timestamp: new Date().toISOString(),
+});
@@ -0,0 +1,61 @@
+// Batches storefront events (page views, suggestions shown and clicked) to
+// POST /api/v1/events, where they're stitched to the shopper's chat session
+import fetchService from './fetch';
+import { getStoredChatSessionId } from './chatSession';
+
+export type ClickstreamEventType = 'page_view' | 'suggestion_impression' | 'suggestion_click';
+
+export interface ClickstreamEvent {
+  type: ClickstreamEventType;
+  product_id?: string;
+  path?: string;
+  placement?: string;
+  position?: number;
+  occurred_at?: string;
+}
+
+const FLUSH_INTERVAL_MS = 5000;
+const MAX_BATCH = 50;
+
+let queue: ClickstreamEvent[] = [];
+let timer: number | undefined;
+
+const flush = () => {
+  timer = undefined;
+  if (queue.length === 0) {
+    return;
+  }
+  const events = queue.slice(0, MAX_BATCH);
+  queue = queue.slice(MAX_BATCH);
+  fetchService.post(
+    '/api/v1/events',
+    { chat_session_id: getStoredChatSessionId() || undefined, events },
+    { credentials: 'include', keepalive: true }
+  );
+  if (queue.length > 0) {
+    schedule();
+  }
+};
+
+const schedule = () => {
+  if (timer === undefined) {
+    timer = window.setTimeout(flush, FLUSH_INTERVAL_MS);
+  }
+};
+
+if (typeof window !== 'undefined') {
+  window.addEventListener('pagehide', flush);
+}
+
+export const trackEvent = (event: ClickstreamEvent) => {
+  queue.push({ ...event, occurred_at: event.occurred_at || new Date().toISOString() });
+  if (queue.length >= MAX_BATCH) {
+    flush();
+    return;
+  }
+  schedule();
+};
+
+export const trackPageView = (productId?: string) => {
+  trackEvent({ type: 'page_view', path: window.location.pathname, product_id: productId });
+};